@@ -0,0 +1,335 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+func TestAccessLogOperations(t *testing.T) {
+	t.Parallel()
+
+	t.Run("logs the normalized operation with its variables", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logs := observer.New(zapcore.InfoLevel)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithLogger(zap.New(logCore)),
+				core.WithAccessLogs(&config.AccessLogsConfiguration{
+					Operations: config.AccessLogsOperationsConfiguration{
+						Enabled:          true,
+						SampleRate:       1,
+						IncludeVariables: true,
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:         `query Employee { employee(id: 1) { id } }`,
+				OperationName: json.RawMessage(`"Employee"`),
+			})
+			require.Equal(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+
+			entries := accessLogs(logs).All()
+			require.Len(t, entries, 1)
+			fields := entries[0].ContextMap()
+			require.Equal(t, "Employee", fields["operation_name"])
+			require.Equal(t, "query", fields["operation_type"])
+			require.Contains(t, fields["operation_content"], "$a")
+			require.JSONEq(t, `{"a":1}`, fields["operation_variables"].(string))
+
+			// The logged operation can be replayed
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:         fields["operation_content"].(string),
+				Variables:     json.RawMessage(fields["operation_variables"].(string)),
+				OperationName: json.RawMessage(`"Employee"`),
+			})
+			require.Equal(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+		})
+	})
+
+	t.Run("omits the operation of requests that are not sampled", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logs := observer.New(zapcore.InfoLevel)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithLogger(zap.New(logCore)),
+				core.WithAccessLogs(&config.AccessLogsConfiguration{
+					Operations: config.AccessLogsOperationsConfiguration{
+						Enabled:    true,
+						SampleRate: 0,
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `{ employees { id } }`,
+			})
+			require.Equal(t, employeesIDData, res.Body)
+
+			entries := accessLogs(logs).All()
+			require.Len(t, entries, 1)
+			fields := entries[0].ContextMap()
+			require.NotContains(t, fields, "operation_content")
+			require.Contains(t, fields, "request_id")
+		})
+	})
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithAccessLogs(&config.AccessLogsConfiguration{
+				Operations: config.AccessLogsOperationsConfiguration{
+					Enabled:    true,
+					SampleRate: 0,
+				},
+				Sampling: config.AccessLogsSamplingConfiguration{
+					Enabled:    true,
+					SampleRate: 0,
+					AlwaysLog: config.AccessLogsSamplingExemptions{
+						OperationTypes: []string{"mutation"},
+						StatusCodes:    []string{"4xx"},
+					},
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		require.Equal(t, employeesIDData, res.Body)
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `mutation UpdateTag { updateEmployeeTag(id: 1, tag: "test") { id tag } }`,
+		})
+		require.Equal(t, `{"data":{"updateEmployeeTag":{"id":1,"tag":"test"}}}`, res.Body)
+
+		badRequest, err := xEnv.MakeRequest("POST", "/graphql", nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, 400, badRequest.StatusCode)
+		_ = badRequest.Body.Close()
+
+		entries := accessLogs(logs).All()
+		require.Len(t, entries, 2)
+
+		// The operation of an exempted request is logged regardless of the sample rate of the operations
+		fields := entries[0].ContextMap()
+		require.Equal(t, "UpdateTag", fields["operation_name"])
+		require.Equal(t, "mutation", fields["operation_type"])
+
+		fields = entries[1].ContextMap()
+		require.Equal(t, int64(400), fields["status"])
+		require.NotContains(t, fields, "operation_name")
+	})
+}
+
+func TestAccessLogger(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+	accessLogCore, accessLogEntries := observer.New(zapcore.InfoLevel)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithAccessLogger(zap.New(accessLogCore).Named("access")),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				CloseOnStart: true,
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:         `query Employee { employee(id: 1) { id } }`,
+			OperationName: json.RawMessage(`"Employee"`),
+			Header:        map[string][]string{"graphql-client-name": {"my-client"}, "graphql-client-version": {"1.0.0"}},
+		})
+		require.Contains(t, res.Body, "Failed to fetch from Subgraph")
+
+		// The access logs aren't written to the logger of the router
+		require.Equal(t, 0, accessLogs(logs).Len())
+
+		entries := accessLogEntries.All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		require.Equal(t, "POST", fields["method"])
+		require.Equal(t, "/graphql", fields["path"])
+		require.Equal(t, int64(200), fields["status"])
+		require.Equal(t, "Employee", fields["operation_name"])
+		require.Equal(t, "query", fields["operation_type"])
+		require.Equal(t, "my-client", fields["client_name"])
+		require.Equal(t, "1.0.0", fields["client_version"])
+		require.Contains(t, fields["subgraph_errors"], "Failed to fetch from Subgraph")
+	})
+}
+
+func TestAccessLogCacheDecisions(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		request := testenv.GraphQLRequest{
+			OperationName: json.RawMessage(`"Employees"`),
+			Extensions:    json.RawMessage(`{"persistedQuery": {"version": 1, "sha256Hash": "dc67510fb4289672bea757e862d6b00e83db5d3cbbcfb15260601b6f29bb2b8f"}}`),
+			Header:        map[string][]string{"graphql-client-name": {"my-client"}},
+		}
+		xEnv.MakeGraphQLRequestOK(request)
+		xEnv.MakeGraphQLRequestOK(request)
+
+		// The caches that aren't enabled, like the ETags of the responses, are omitted
+		entries := accessLogs(logs).All()
+		require.Len(t, entries, 2)
+		require.Equal(t, map[string]any{
+			"plan":                "miss",
+			"normalization":       "miss",
+			"persisted_operation": "miss",
+		}, entries[0].ContextMap()["cache"])
+		require.Equal(t, map[string]any{
+			"plan":                "hit",
+			"normalization":       "hit",
+			"persisted_operation": "hit",
+		}, entries[1].ContextMap()["cache"])
+	})
+}
+
+func TestAccessLogUserAgent(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithAccessLogs(&config.AccessLogsConfiguration{
+				UserAgent: config.AccessLogsUserAgentConfiguration{
+					Enabled:   true,
+					CacheSize: 10,
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+			Header: map[string][]string{"User-Agent": {
+				"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			}},
+		})
+
+		entries := accessLogs(logs).All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		require.Equal(t, "mobile", fields["device_class"])
+		require.Equal(t, "Safari", fields["browser_family"])
+		require.Equal(t, "iOS", fields["os_family"])
+	})
+}
+
+func TestAccessLogSchema(t *testing.T) {
+	t.Parallel()
+
+	sink := logging.NewMemorySink()
+	accessLogger, err := logging.NewAccessLogger(&logging.AccessLoggerOptions{Writer: sink})
+	require.NoError(t, err)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithAccessLogger(accessLogger),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:         `query Employee { employee(id: 1) { id } }`,
+			OperationName: json.RawMessage(`"Employee"`),
+			Header:        map[string][]string{"graphql-client-name": {"my-client"}, "graphql-client-version": {"1.0.0"}},
+		})
+		require.Equal(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+
+		sink.RequireEntry(t, "/graphql", map[string]any{"status": 200, "operation_name": "Employee"})
+		// The fields of the access logs are consumed by log pipelines, renaming or retyping one is a breaking change
+		sink.RequireGoldenSchema(t, filepath.Join("testdata", "access_log_schema.golden"))
+	})
+}
+
+func accessLogs(logs *observer.ObservedLogs) *observer.ObservedLogs {
+	return logs.Filter(func(e observer.LoggedEntry) bool {
+		return e.LoggerName == "access"
+	})
+}
+
+func TestAccessLogNonGraphQLRequests(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithNonGraphQLRequests(&config.NonGraphQLRequestsConfiguration{
+				AccessLogs:   true,
+				ExcludePaths: []string{"/health/live"},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res, err := xEnv.MakeRequest(http.MethodOptions, "/graphql", http.Header{
+			"Origin":                        []string{"https://example.com"},
+			"Access-Control-Request-Method": []string{"POST"},
+		}, nil)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		for _, path := range []string{"/health", "/health/live", "/unknown"} {
+			res, err = xEnv.MakeRequest(http.MethodGet, path, nil, nil)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		}
+
+		xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+
+		kinds := map[string]any{}
+		var graphqlEntries int
+		for _, entry := range accessLogs(logs).All() {
+			fields := entry.ContextMap()
+			kind, ok := fields["request_kind"]
+			if !ok {
+				graphqlEntries++
+				continue
+			}
+			kinds[fields["method"].(string)+" "+fields["path"].(string)] = kind
+		}
+		require.Equal(t, map[string]any{
+			"OPTIONS /graphql": "preflight",
+			"GET /health":      "health",
+			"GET /unknown":     "not_found",
+		}, kinds)
+		// The GraphQL requests are only logged once
+		require.Equal(t, 1, graphqlEntries)
+	})
+}
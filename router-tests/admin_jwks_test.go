@@ -0,0 +1,100 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/phayes/freeport"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/jwks"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+)
+
+func TestAdminJWKS(t *testing.T) {
+	t.Parallel()
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	adminAddr := fmt.Sprintf("localhost:%d", port)
+
+	authServer, err := jwks.NewServer(t)
+	require.NoError(t, err)
+	t.Cleanup(authServer.Close)
+	authenticator, err := authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+		Name:            jwksName,
+		URL:             authServer.JWKSURL(),
+		RefreshInterval: time.Minute,
+	})
+	require.NoError(t, err)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithAdminServer(&core.AdminServerConfig{
+				Enabled:    true,
+				ListenAddr: adminAddr,
+				Token:      "secret",
+			}),
+			core.WithAccessController(core.NewAccessController([]authentication.Authenticator{authenticator}, false)),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		call := func(method, path string) (int, map[string]any) {
+			req, err := http.NewRequest(method, "http://"+adminAddr+path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer secret")
+
+			var res *http.Response
+			require.Eventually(t, func() bool {
+				res, err = http.DefaultClient.Do(req)
+				return err == nil
+			}, 5*time.Second, 50*time.Millisecond)
+			defer res.Body.Close()
+
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+			return res.StatusCode, body
+		}
+		authenticatorStatus := func(body map[string]any) map[string]any {
+			authenticators := body["authenticators"].([]any)
+			require.Len(t, authenticators, 1)
+			return authenticators[0].(map[string]any)
+		}
+
+		status, body := call(http.MethodGet, "/authentication/jwks")
+		require.Equal(t, http.StatusOK, status)
+		jwksStatus := authenticatorStatus(body)
+		require.Equal(t, jwksName, jwksStatus["authenticator"])
+		require.Equal(t, true, jwksStatus["available"])
+		require.Equal(t, []any{"123456789"}, jwksStatus["kids"])
+		require.NotEmpty(t, jwksStatus["last_refresh"])
+		require.NotEmpty(t, jwksStatus["next_refresh"])
+
+		authServer.SetUnavailable(true)
+		status, body = call(http.MethodPost, "/authentication/jwks/refresh")
+		require.Equal(t, http.StatusBadGateway, status)
+		require.Contains(t, body["error"], jwksName)
+
+		_, body = call(http.MethodGet, "/authentication/jwks")
+		jwksStatus = authenticatorStatus(body)
+		require.Equal(t, false, jwksStatus["available"])
+		require.Equal(t, float64(1), jwksStatus["consecutive_failures"])
+		require.NotEmpty(t, jwksStatus["last_error"])
+		// The cached keys are kept
+		require.Equal(t, []any{"123456789"}, jwksStatus["kids"])
+
+		authServer.SetUnavailable(false)
+		status, body = call(http.MethodPost, "/authentication/jwks/refresh?authenticator="+jwksName)
+		require.Equal(t, http.StatusOK, status)
+		jwksStatus = authenticatorStatus(body)
+		require.Equal(t, true, jwksStatus["available"])
+		require.Equal(t, float64(0), jwksStatus["consecutive_failures"])
+
+		status, _ = call(http.MethodPost, "/authentication/jwks/refresh?authenticator=unknown")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+}
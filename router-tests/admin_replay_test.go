@@ -0,0 +1,101 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/phayes/freeport"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestAdminReplay(t *testing.T) {
+	t.Parallel()
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	adminAddr := fmt.Sprintf("localhost:%d", port)
+
+	var subgraphHeader string
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithAdminServer(&core.AdminServerConfig{
+				Enabled:    true,
+				ListenAddr: adminAddr,
+				Token:      "secret",
+			}),
+			core.WithHeaderRules(config.HeaderRules{
+				All: config.GlobalHeaderRule{
+					Request: []config.RequestHeaderRule{
+						{Operation: config.HeaderRuleOperationPropagate, Named: "X-Custom"},
+					},
+				},
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(handler http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						subgraphHeader = r.Header.Get("X-Custom")
+						handler.ServeHTTP(w, r)
+					})
+				},
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		replay := func(body string) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, "http://"+adminAddr+"/debug/replay", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer secret")
+
+			var res *http.Response
+			require.Eventually(t, func() bool {
+				res, err = http.DefaultClient.Do(req)
+				return err == nil
+			}, 5*time.Second, 50*time.Millisecond)
+			return res
+		}
+
+		res := replay(`{"query":"query Employee($id: Int!) { employee(id: $id) { id } }","operationName":"Employee","variables":{"id":1},"headers":{"X-Custom":"captured"}}`)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var result struct {
+			StatusCode int `json:"status_code"`
+			Body       struct {
+				Data       json.RawMessage `json:"data"`
+				Extensions struct {
+					Trace json.RawMessage `json:"trace"`
+				} `json:"extensions"`
+			} `json:"body"`
+			Logs []struct {
+				Level string `json:"level"`
+			} `json:"logs"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		require.JSONEq(t, `{"employee":{"id":1}}`, string(result.Body.Data))
+		// The trace is attached although the request tracing isn't enabled for the clients
+		require.NotEmpty(t, result.Body.Extensions.Trace)
+		// The debug entries are collected regardless of the level of the router logger
+		levels := make([]string, 0, len(result.Logs))
+		for _, entry := range result.Logs {
+			levels = append(levels, entry.Level)
+		}
+		require.Contains(t, levels, "debug")
+		require.Equal(t, "captured", subgraphHeader)
+
+		res = replay(`{"headers":{"X-Custom":"captured"}}`)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
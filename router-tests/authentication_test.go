@@ -2,11 +2,14 @@ package integration_test
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/require"
 	"github.com/wundergraph/cosmo/router-tests/jwks"
 	"github.com/wundergraph/cosmo/router-tests/testenv"
@@ -237,7 +240,7 @@ func TestAuthentication(t *testing.T) {
 			data, err := io.ReadAll(res.Body)
 			data = bytes.TrimSpace(data)
 			require.NoError(t, err)
-			require.Equal(t, `{"errors":[{"message":"Unauthorized"}],"data":null,"extensions":{"authorization":{"missingScopes":[{"coordinate":{"typeName":"Employee","fieldName":"startDate"},"required":[["read:employee","read:private"],["read:all"]]}],"actualScopes":["read:employee"]}}}`, string(data))
+			require.Equal(t, `{"errors":[{"message":"Unauthorized","extensions":{"code":"UNAUTHORIZED"}}],"data":null,"extensions":{"authorization":{"missingScopes":[{"coordinate":{"typeName":"Employee","fieldName":"startDate"},"required":[["read:employee","read:private"],["read:all"]]}],"actualScopes":["read:employee"]}}}`, string(data))
 		})
 	})
 	t.Run("reject unauthorized no scope", func(t *testing.T) {
@@ -266,7 +269,7 @@ func TestAuthentication(t *testing.T) {
 			data, err := io.ReadAll(res.Body)
 			data = bytes.TrimSpace(data)
 			require.NoError(t, err)
-			require.Equal(t, `{"errors":[{"message":"Unauthorized"}],"data":null,"extensions":{"authorization":{"missingScopes":[{"coordinate":{"typeName":"Employee","fieldName":"startDate"},"required":[["read:employee","read:private"],["read:all"]]}],"actualScopes":[]}}}`, string(data))
+			require.Equal(t, `{"errors":[{"message":"Unauthorized","extensions":{"code":"UNAUTHORIZED"}}],"data":null,"extensions":{"authorization":{"missingScopes":[{"coordinate":{"typeName":"Employee","fieldName":"startDate"},"required":[["read:employee","read:private"],["read:all"]]}],"actualScopes":[]}}}`, string(data))
 		})
 	})
 	t.Run("reject unauthorized invalid token", func(t *testing.T) {
@@ -314,7 +317,7 @@ func TestAuthentication(t *testing.T) {
 			data, err := io.ReadAll(res.Body)
 			data = bytes.TrimSpace(data)
 			require.NoError(t, err)
-			require.Equal(t, `{"errors":[{"message":"Unauthorized"}],"data":null}`, string(data))
+			require.Equal(t, `{"errors":[{"message":"Unauthorized","extensions":{"code":"UNAUTHORIZED"}}],"data":null}`, string(data))
 		})
 	})
 	t.Run("scopes required valid token OR scopes present", func(t *testing.T) {
@@ -594,7 +597,7 @@ func TestAuthentication(t *testing.T) {
 			data, err := io.ReadAll(res.Body)
 			data = bytes.TrimSpace(data)
 			require.NoError(t, err)
-			require.Equal(t, `{"errors":[{"message":"Unauthorized"}],"data":null,"extensions":{"authorization":{"missingScopes":[{"coordinate":{"typeName":"Mutation","fieldName":"addFact"},"required":[["write:fact"],["write:all"]]}],"actualScopes":["read:miscellaneous","read:all"]}}}`, string(data))
+			require.Equal(t, `{"errors":[{"message":"Unauthorized","extensions":{"code":"UNAUTHORIZED"}}],"data":null,"extensions":{"authorization":{"missingScopes":[{"coordinate":{"typeName":"Mutation","fieldName":"addFact"},"required":[["write:fact"],["write:all"]]}],"actualScopes":["read:miscellaneous","read:all"]}}}`, string(data))
 		})
 	})
 }
@@ -884,3 +887,147 @@ func TestAuthenticationOverWebsocket(t *testing.T) {
 		require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
 	})
 }
+
+func TestAuthenticationUnavailablePolicy(t *testing.T) {
+	t.Parallel()
+
+	authenticate := func(authenticator authentication.Authenticator, token string) error {
+		_, err := authentication.AuthenticateHeader(context.Background(), []authentication.Authenticator{authenticator}, http.Header{
+			"Authorization": []string{"Bearer " + token},
+		})
+		return err
+	}
+
+	t.Run("cached keys", func(t *testing.T) {
+		t.Parallel()
+
+		authServer, err := jwks.NewServer(t)
+		require.NoError(t, err)
+		t.Cleanup(authServer.Close)
+
+		authServer.SetUnavailable(true)
+		_, err = authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+			Name: jwksName,
+			URL:  authServer.JWKSURL(),
+		})
+		// The keys must be fetched once before the tokens can be verified
+		require.Error(t, err)
+
+		authServer.SetUnavailable(false)
+		authenticator, err := authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+			Name:            jwksName,
+			URL:             authServer.JWKSURL(),
+			RefreshInterval: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		counter := authenticator.(authentication.UnavailableDecisionCounter)
+		require.Equal(t, authentication.UnavailablePolicyCachedKeys, counter.Policy())
+
+		token, err := authServer.Token(nil)
+		require.NoError(t, err)
+
+		authServer.SetUnavailable(true)
+		require.Eventually(t, func() bool {
+			return authenticate(authenticator, token) == nil && counter.UnavailableDecisions()[authentication.UnavailableDecisionCachedKeys] > 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		t.Parallel()
+
+		authServer, err := jwks.NewServer(t)
+		require.NoError(t, err)
+		t.Cleanup(authServer.Close)
+
+		authenticator, err := authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+			Name:              jwksName,
+			URL:               authServer.JWKSURL(),
+			RefreshInterval:   10 * time.Millisecond,
+			UnavailablePolicy: authentication.UnavailablePolicyReject,
+		})
+		require.NoError(t, err)
+
+		token, err := authServer.Token(nil)
+		require.NoError(t, err)
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithAccessController(core.NewAccessController([]authentication.Authenticator{authenticator}, false)),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			header := http.Header{"Authorization": []string{"Bearer " + token}}
+			statusCode := func() int {
+				res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", header, strings.NewReader(employeesQuery))
+				require.NoError(t, err)
+				defer res.Body.Close()
+				return res.StatusCode
+			}
+
+			require.Equal(t, http.StatusOK, statusCode())
+
+			// Valid tokens are rejected while the keys can't be refreshed
+			authServer.SetUnavailable(true)
+			require.Eventually(t, func() bool {
+				return statusCode() == http.StatusUnauthorized
+			}, 5*time.Second, 10*time.Millisecond)
+			decisions := authenticator.(authentication.UnavailableDecisionCounter).UnavailableDecisions()
+			require.Positive(t, decisions[authentication.UnavailableDecisionRejected])
+
+			authServer.SetUnavailable(false)
+			require.Eventually(t, func() bool {
+				return statusCode() == http.StatusOK
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		t.Parallel()
+
+		authServer, err := jwks.NewServer(t)
+		require.NoError(t, err)
+		t.Cleanup(authServer.Close)
+
+		// The router starts while the endpoint is unavailable, without any cached key
+		authServer.SetUnavailable(true)
+		authenticator, err := authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+			Name:              jwksName,
+			URL:               authServer.JWKSURL(),
+			RefreshInterval:   10 * time.Millisecond,
+			UnavailablePolicy: authentication.UnavailablePolicyAllow,
+		})
+		require.NoError(t, err)
+
+		token, err := authServer.Token(map[string]any{"exp": time.Now().Add(time.Hour).Unix()})
+		require.NoError(t, err)
+		expired, err := authServer.Token(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})
+		require.NoError(t, err)
+
+		require.NoError(t, authenticate(authenticator, token))
+		require.ErrorIs(t, authenticate(authenticator, expired), jwt.ErrTokenExpired)
+		decisions := authenticator.(authentication.UnavailableDecisionCounter).UnavailableDecisions()
+		require.Equal(t, int64(1), decisions[authentication.UnavailableDecisionUnverified])
+		require.Equal(t, int64(1), decisions[authentication.UnavailableDecisionRejected])
+
+		// Once the keys are fetched, the signatures are verified again
+		authServer.SetUnavailable(false)
+		counter := authenticator.(authentication.UnavailableDecisionCounter)
+		require.Eventually(t, func() bool {
+			unverified := counter.UnavailableDecisions()[authentication.UnavailableDecisionUnverified]
+			return authenticate(authenticator, token) == nil &&
+				counter.UnavailableDecisions()[authentication.UnavailableDecisionUnverified] == unverified
+		}, 5*time.Second, 10*time.Millisecond)
+		// A token of the same key ID with an invalid signature
+		require.Error(t, authenticate(authenticator, "eyJhbGciOiJSUzI1NiIsImtpZCI6IjEyMzQ1Njc4OSJ9.e30.c2lnbmF0dXJl"))
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+			Name:              jwksName,
+			URL:               "http://localhost:0/jwks.json",
+			UnavailablePolicy: "ignore",
+		})
+		require.EqualError(t, err, "unknown unavailable policy 'ignore', must be cached_keys, reject or allow")
+	})
+}
@@ -0,0 +1,42 @@
+package integration_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestBotDetection(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithBotDetection(&config.BotDetectionConfiguration{
+				Enabled:   true,
+				Action:    string(core.BotDetectionActionBlock),
+				Threshold: 40,
+				TagHeader: "X-Bot-Score",
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		// The Go client sends its own user agent and no Accept-Language header
+		res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{}, strings.NewReader(employeesQuery))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, err = xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{
+			"User-Agent":      []string{"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"},
+			"Accept":          []string{"application/json"},
+			"Accept-Language": []string{"en-US"},
+		}, strings.NewReader(employeesQuery))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
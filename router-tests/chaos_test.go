@@ -0,0 +1,61 @@
+package integration_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestChaos(t *testing.T) {
+	t.Parallel()
+
+	var employeesRequests atomic.Int64
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithSubgraphRetryOptions(true, 3, 100*time.Millisecond, time.Millisecond),
+			core.WithChaos(&config.ChaosConfiguration{
+				Enabled: true,
+				Rules: []config.ChaosRule{
+					{
+						Subgraphs:      []string{"employees"},
+						Operations:     []string{"Employees"},
+						DropConnection: config.ChaosDropConnection{Rate: 1},
+					},
+				},
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(handler http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						employeesRequests.Add(1)
+						handler.ServeHTTP(w, r)
+					})
+				},
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		// The dropped connections are retried and never reach the subgraph
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			OperationName: []byte(`"Employees"`),
+			Query:         `query Employees { employees { id } }`,
+		})
+		require.Equal(t, `{"errors":[{"message":"Failed to fetch from Subgraph '0' at Path 'query'."}],"data":{"employees":null}}`, res.Body)
+		require.Equal(t, int64(0), employeesRequests.Load())
+
+		// Other operations aren't affected
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			OperationName: []byte(`"Employee"`),
+			Query:         `query Employee { employee(id: 1) { id } }`,
+		})
+		require.Equal(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+		require.Equal(t, int64(1), employeesRequests.Load())
+	})
+}
@@ -0,0 +1,84 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestContractEnforcement(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.WarnLevel)
+	metricReader := metric.NewManualReader()
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithContractEnforcement(&config.ContractEnforcementConfiguration{
+				Enabled:             true,
+				ExcludeTags:         []string{"internal"},
+				MaxLoggedViolations: 10,
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(_ http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						// firstEmployee is tagged as internal and wasn't requested
+						w.Header().Set("Content-Type", "application/json")
+						_, _ = w.Write([]byte(`{"data":{"employees":[{"id":1}],"firstEmployee":{"id":1}}}`))
+					})
+				},
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query:         `query Employees { employees { id } }`,
+			OperationName: []byte(`"Employees"`),
+		})
+		require.NoError(t, err)
+		require.Equal(t, `{"data":{"employees":[{"id":1}]}}`, res.Body)
+
+		entries := logs.Filter(func(e observer.LoggedEntry) bool {
+			return e.LoggerName == "contract_enforcement"
+		}).All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		require.Equal(t, "employees", fields["subgraph_name"])
+		require.Equal(t, "Employees", fields["operation_name"])
+		require.Equal(t, int64(1), fields["violation_count"])
+		require.Equal(t, []interface{}{"firstEmployee"}, fields["removed_fields"])
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var violations *metricdata.Sum[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "router.graphql.subgraph.contract_violations" {
+					sum := m.Data.(metricdata.Sum[int64])
+					violations = &sum
+				}
+			}
+		}
+		require.NotNil(t, violations)
+		require.Len(t, violations.DataPoints, 1)
+		require.Equal(t, int64(1), violations.DataPoints[0].Value)
+		subgraph, _ := violations.DataPoints[0].Attributes.Value(otel.WgSubgraphName)
+		require.Equal(t, "employees", subgraph.AsString())
+	})
+}
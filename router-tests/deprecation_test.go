@@ -0,0 +1,120 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestDeprecationWarnings(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.WarnLevel)
+	metricReader := metric.NewManualReader()
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithDeprecationWarnings(&config.DeprecationWarningsConfiguration{
+				Enabled:     true,
+				LogInterval: time.Hour,
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		require.Equal(t, employeesIDData, res.Body)
+		require.Zero(t, deprecationLogs(logs).Len())
+
+		for i := 0; i < 2; i++ {
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query Middlenames { employees { details { middlename } } }`,
+			})
+			require.Contains(t, res.Body, `"middlename"`)
+		}
+
+		// The second usage is throttled
+		entries := deprecationLogs(logs).All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		require.Equal(t, "field", fields["deprecation_kind"])
+		require.Equal(t, "Details.middlename", fields["deprecation_name"])
+		require.Equal(t, "Middlenames", fields["operation_name"])
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var usages *metricdata.Sum[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "router.deprecation.usages" {
+					sum := m.Data.(metricdata.Sum[int64])
+					usages = &sum
+				}
+			}
+		}
+		require.NotNil(t, usages)
+		require.Len(t, usages.DataPoints, 1)
+		require.Equal(t, int64(2), usages.DataPoints[0].Value)
+		name, _ := usages.DataPoints[0].Attributes.Value(attribute.Key("name"))
+		require.Equal(t, "Details.middlename", name.AsString())
+	})
+}
+
+func TestDeprecationResponseExtension(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithDeprecationWarnings(&config.DeprecationWarningsConfiguration{
+				Enabled: true,
+				ResponseExtension: config.DeprecationResponseExtensionConfiguration{
+					Enabled:  true,
+					Interval: time.Hour,
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		query := testenv.GraphQLRequest{
+			Query:  `{ employees { id details { middlename } } }`,
+			Header: http.Header{"Graphql-Client-Name": {"web"}},
+		}
+
+		res := xEnv.MakeGraphQLRequestOK(query)
+		require.Contains(t, res.Body, `"extensions":{"deprecations":[{"coordinate":"Details.middlename","reason":"No longer supported"}]}`)
+
+		// The same client is only warned once per interval
+		res = xEnv.MakeGraphQLRequestOK(query)
+		require.NotContains(t, res.Body, `"extensions"`)
+
+		query.Header = http.Header{"Graphql-Client-Name": {"mobile"}}
+		res = xEnv.MakeGraphQLRequestOK(query)
+		require.Contains(t, res.Body, `"deprecations"`)
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		require.Equal(t, employeesIDData, res.Body)
+	})
+}
+
+func deprecationLogs(logs *observer.ObservedLogs) *observer.ObservedLogs {
+	return logs.Filter(func(e observer.LoggedEntry) bool {
+		return e.LoggerName == "deprecation"
+	})
+}
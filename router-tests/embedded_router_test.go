@@ -0,0 +1,99 @@
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phayes/freeport"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestEmbeddedRouter(t *testing.T) {
+	t.Parallel()
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	listenAddr := fmt.Sprintf("localhost:%d", port)
+
+	routerConfigPath, err := filepath.Abs("testenv/testdata/config.json")
+	require.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(fmt.Sprintf(`
+version: "1"
+router_config_path: %q
+listen_addr: %q
+json_log: true
+telemetry:
+  metrics:
+    prometheus:
+      enabled: false
+`, routerConfigPath, listenAddr)), 0o600))
+
+	result, err := config.LoadConfig(configPath, "")
+	require.NoError(t, err)
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+
+	var mu sync.Mutex
+	var events []core.LifecycleEventType
+
+	r, err := router.New(router.Options{
+		Config:  &result.Config,
+		Logger:  zap.NewNop(),
+		LogCore: logCore,
+		Hooks: []core.LifecycleHook{func(event core.LifecycleEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event.Type)
+		}},
+	})
+	require.NoError(t, err)
+
+	go func() {
+		_ = r.Start(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		res, err := (&http.Client{Timeout: time.Second}).Post("http://"+listenAddr+"/graphql", "application/json", strings.NewReader(`{"query":"{ __schema { queryType { name } } }"}`))
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return err == nil && string(body) == `{"data":{"__schema":{"queryType":{"name":"Query"}}}}`
+	}, 10*time.Second, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, r.Shutdown(ctx))
+
+	mu.Lock()
+	require.Equal(t, []core.LifecycleEventType{core.LifecycleEventStartup, core.LifecycleEventShutdown}, events)
+	mu.Unlock()
+
+	// The entries of the router are teed into the logger of the embedding service
+	require.NotZero(t, logs.FilterMessage("Server listening and serving").Len())
+}
+
+func TestEmbeddedRouterRequiresConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := router.New(router.Options{})
+	require.EqualError(t, err, "the config of the router is required")
+}
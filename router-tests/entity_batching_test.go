@@ -0,0 +1,101 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestEntityBatching(t *testing.T) {
+	t.Parallel()
+
+	// The products of the employees are fetched from the products subgraph with the representations of all employees
+	const query = `{ employees { id products } }`
+
+	countRequests := func(requests *atomic.Int32) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	var expected string
+	testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+		expected = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: query}).Body
+	})
+
+	t.Run("splits the entity requests by the maximum batch size", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+		metricReader := metric.NewManualReader()
+
+		testenv.Run(t, &testenv.Config{
+			MetricReader: metricReader,
+			RouterOptions: []core.Option{
+				core.WithEntityBatching(&config.EntityBatchingConfiguration{MaxBatchSize: 3}),
+			},
+			Subgraphs: testenv.SubgraphsConfig{
+				Products: testenv.SubgraphConfig{Middleware: countRequests(&requests)},
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: query})
+			require.JSONEq(t, expected, res.Body)
+
+			// The 10 employees are sent in batches of 3, 3, 3 and 1
+			require.Equal(t, int32(4), requests.Load())
+
+			rm := metricdata.ResourceMetrics{}
+			require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+			var histogram *metricdata.Histogram[float64]
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name == "router.graphql.entity.batch.size" {
+						h := m.Data.(metricdata.Histogram[float64])
+						histogram = &h
+					}
+				}
+			}
+			require.NotNil(t, histogram)
+			require.Len(t, histogram.DataPoints, 1)
+			require.Equal(t, uint64(4), histogram.DataPoints[0].Count)
+			require.Equal(t, float64(10), histogram.DataPoints[0].Sum)
+			subgraph, _ := histogram.DataPoints[0].Attributes.Value(otel.WgSubgraphName)
+			require.Equal(t, "products", subgraph.AsString())
+		})
+	})
+
+	t.Run("removes representations with the same dedupe key", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithEntityBatching(&config.EntityBatchingConfiguration{
+					DedupeKeys: []config.EntityDedupeKey{{TypeName: "Employee", Fields: []string{"id"}}},
+				}),
+			},
+			Subgraphs: testenv.SubgraphsConfig{
+				Products: testenv.SubgraphConfig{Middleware: countRequests(&requests)},
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: query})
+			require.JSONEq(t, expected, res.Body)
+			require.Equal(t, int32(1), requests.Load())
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestETags(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithETags(&config.ETagsConfiguration{Enabled: true}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `query { employee(id: 1) { id } }`,
+		})
+		require.Equal(t, http.StatusOK, res.Response.StatusCode)
+		require.Equal(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+		etag := res.Response.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query:  `query { employee(id: 1) { id } }`,
+			Header: http.Header{"If-None-Match": []string{etag}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, res.Response.StatusCode)
+		require.Equal(t, etag, res.Response.Header.Get("ETag"))
+		require.Empty(t, res.Body)
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:  `query { employee(id: 2) { id } }`,
+			Header: http.Header{"If-None-Match": []string{etag}},
+		})
+		require.Equal(t, http.StatusOK, res.Response.StatusCode)
+		require.Equal(t, `{"data":{"employee":{"id":2}}}`, res.Body)
+		require.NotEqual(t, etag, res.Response.Header.Get("ETag"))
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:  `mutation { updateEmployeeTag(id: 1, tag: "test") { id tag } }`,
+			Header: http.Header{"If-None-Match": []string{"*"}},
+		})
+		require.Equal(t, http.StatusOK, res.Response.StatusCode)
+		require.Empty(t, res.Response.Header.Get("ETag"))
+	})
+}
@@ -1,9 +1,11 @@
 package integration
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"github.com/wundergraph/cosmo/router-tests/testenv"
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"go.opentelemetry.io/otel/sdk/metric"
 	"testing"
 )
 
@@ -108,4 +110,34 @@ func TestFeatureFlags(t *testing.T) {
 		})
 	})
 
+	t.Run("Should label the metrics with the feature flag without tracing", func(t *testing.T) {
+
+		t.Parallel()
+
+		metricReader := metric.NewManualReader()
+		promRegistry := prometheus.NewRegistry()
+
+		testenv.Run(t, &testenv.Config{
+			MetricReader:       metricReader,
+			PrometheusRegistry: promRegistry,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `{ employees { id productCount } }`,
+				Header: map[string][]string{
+					"X-Feature-Flag": {"myff"},
+				},
+			})
+			require.Equal(t, res.Response.Header.Get("X-Feature-Flag"), "myff")
+
+			mf, err := promRegistry.Gather()
+			require.NoError(t, err)
+
+			requestTotal := findMetricFamilyByName(mf, "router_http_requests_total")
+			require.NotNil(t, requestTotal)
+			featureFlag := findMetricLabelByName(requestTotal.GetMetric(), "wg_feature_flag")
+			require.NotNil(t, featureFlag)
+			require.Equal(t, "myff", featureFlag.GetValue())
+		})
+	})
+
 }
@@ -0,0 +1,77 @@
+package integration_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestFetchConcurrency(t *testing.T) {
+	t.Parallel()
+
+	// The root fields are resolved by the employees and the test1 subgraph, which are fetched in parallel
+	const query = `query Fanout { employees { id } delay(response: "done", ms: 100) }`
+
+	trackInFlight := func(inFlight, maxInFlight *atomic.Int32) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := inFlight.Add(1)
+				for {
+					m := maxInFlight.Load()
+					if n <= m || maxInFlight.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(100 * time.Millisecond)
+				next.ServeHTTP(w, r)
+				inFlight.Add(-1)
+			})
+		}
+	}
+
+	run := func(t *testing.T, cfg *config.FetchConcurrencyConfiguration) int32 {
+		var inFlight, maxInFlight atomic.Int32
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{core.WithFetchConcurrency(cfg)},
+			Subgraphs: testenv.SubgraphsConfig{
+				GlobalMiddleware: trackInFlight(&inFlight, &maxInFlight),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: query})
+			require.JSONEq(t, `{"data":{"employees":[{"id":1},{"id":2},{"id":3},{"id":4},{"id":5},{"id":7},{"id":8},{"id":10},{"id":11},{"id":12}],"delay":"done"}}`, res.Body)
+		})
+
+		return maxInFlight.Load()
+	}
+
+	t.Run("fetches in parallel without a limit", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, int32(2), run(t, &config.FetchConcurrencyConfiguration{}))
+	})
+
+	t.Run("limits the concurrent fetches of a request", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, int32(1), run(t, &config.FetchConcurrencyConfiguration{MaxPerRequest: 1}))
+	})
+
+	t.Run("overrides the limit by operation name", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, int32(2), run(t, &config.FetchConcurrencyConfiguration{
+			MaxPerRequest: 1,
+			Operations: []config.OperationFetchConcurrency{
+				{Name: "Fanout", MaxPerRequest: 2},
+			},
+		}))
+	})
+}
@@ -19,10 +19,14 @@ import (
 	"github.com/buger/jsonparser"
 	"github.com/sebdah/goldie/v2"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	"github.com/wundergraph/cosmo/router-tests/testenv"
 	"github.com/wundergraph/cosmo/router/core"
 	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/trace/tracetest"
 )
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -935,6 +939,193 @@ func TestBlockNonPersistedOperations(t *testing.T) {
 	})
 }
 
+func TestOperationRules(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		ModifySecurityConfiguration: func(securityConfiguration *config.SecurityConfiguration) {
+			securityConfiguration.OperationRules = []config.OperationRule{
+				{Action: "allow", Name: "Update*", Clients: []string{"internal-tools"}},
+				{Action: "deny", NameRegex: "^Update"},
+				{Action: "deny", Type: "query", Clients: []string{"legacy-app"}},
+			}
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		const mutation = `mutation UpdateTag { updateEmployeeTag(id: 1, tag: "test") { id tag } }`
+
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: mutation,
+		})
+		require.Equal(t, `{"errors":[{"message":"operation is blocked by an operation rule"}],"data":null}`, res.Body)
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:  mutation,
+			Header: map[string][]string{"graphql-client-name": {"internal-tools"}},
+		})
+		require.Equal(t, `{"data":{"updateEmployeeTag":{"id":1,"tag":"test"}}}`, res.Body)
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:  `query Employees { employees { id } }`,
+			Header: map[string][]string{"graphql-client-name": {"legacy-app"}},
+		})
+		require.Equal(t, `{"errors":[{"message":"operation is blocked by an operation rule"}],"data":null}`, res.Body)
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `query Employees { employees { id } }`,
+		})
+		require.Equal(t, employeesIDData, res.Body)
+	})
+}
+
+func TestJSONLimits(t *testing.T) {
+	t.Parallel()
+	testenv.Run(t, &testenv.Config{
+		ModifySecurityConfiguration: func(securityConfiguration *config.SecurityConfiguration) {
+			securityConfiguration.JSONLimits.MaxDepth = 3
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		require.Equal(t, employeesIDData, res.Body)
+
+		res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query:     `query Find($criteria: SearchInput!) {findEmployees(criteria: $criteria){id}}`,
+			Variables: json.RawMessage(`{"criteria":{"nested":{"deep":{"deeper":true}}}}`),
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, res.Response.StatusCode)
+		require.Equal(t, `{"errors":[{"message":"request body exceeds the maximum JSON nesting depth of 3"}],"data":null}`, res.Body)
+	})
+}
+
+func TestRequestHeaderLimit(t *testing.T) {
+	t.Parallel()
+	serverConfig := core.DefaultServerConfig()
+	serverConfig.MaxHeaderBytes = 4 << 10
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithServerConfig(serverConfig),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		require.Equal(t, employeesIDData, res.Body)
+
+		res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+			Header: http.Header{
+				"X-Large": []string{strings.Repeat("a", 4<<10)},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, res.Response.StatusCode)
+		require.Equal(t, `{"errors":[{"message":"request header fields too large"}],"data":null}`, res.Body)
+	})
+}
+
+func TestLogEscalation(t *testing.T) {
+	t.Parallel()
+
+	for _, enabled := range []bool{true, false} {
+		enabled := enabled
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			t.Parallel()
+
+			logCore, logs := observer.New(zapcore.InfoLevel)
+			testenv.Run(t, &testenv.Config{
+				RouterOptions: []core.Option{
+					core.WithLogger(zap.New(logCore)),
+					core.WithLogEscalation(&config.LogEscalationConfiguration{
+						Enabled:    enabled,
+						BufferSize: 10,
+					}),
+				},
+			}, func(t *testing.T, xEnv *testenv.Environment) {
+				res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+					Query: `{ employees { id } }`,
+				})
+				require.Equal(t, employeesIDData, res.Body)
+				require.Zero(t, logs.FilterLevelExact(zapcore.DebugLevel).Len())
+
+				res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+					Query:     `query ($id: Int!) { employee(id: $id) { id } }`,
+					Variables: []byte(`"invalid"`),
+				})
+				require.NoError(t, err)
+				require.Equal(t, http.StatusBadRequest, res.Response.StatusCode)
+
+				// The buffered entries are written after the response has been sent
+				debugLogs := func() *observer.ObservedLogs {
+					return logs.FilterLevelExact(zapcore.DebugLevel).FilterMessageSnippet("error parsing variables")
+				}
+				if !enabled {
+					require.Never(t, func() bool { return debugLogs().Len() > 0 }, 200*time.Millisecond, 20*time.Millisecond)
+					return
+				}
+				require.Eventually(t, func() bool { return debugLogs().Len() == 1 }, time.Second, 10*time.Millisecond)
+				require.NotEmpty(t, debugLogs().All()[0].ContextMap()["reqId"])
+			})
+		})
+	}
+}
+
+func TestRequestLogsConfigVersion(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.DebugLevel)
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:  `{ employees { id productCount } }`,
+			Header: map[string][]string{"X-Feature-Flag": {"myff"}},
+		})
+
+		// The logs of the requests carry the version of the config and the feature flag of the graph
+		planned := logs.FilterMessage("Operation planned").All()
+		require.Len(t, planned, 2)
+		require.Equal(t, "8b9533710fd67c8672461d09fc1bb787b14c5077", planned[0].ContextMap()["config_version"])
+		require.NotContains(t, planned[0].ContextMap(), "feature_flag")
+		require.NotEmpty(t, planned[1].ContextMap()["config_version"])
+		require.Equal(t, "myff", planned[1].ContextMap()["feature_flag"])
+	})
+}
+
+func TestRequestLogsTraceContext(t *testing.T) {
+	t.Parallel()
+
+	exporter := tracetest.NewInMemoryExporter(t)
+	logCore, logs := observer.New(zapcore.DebugLevel)
+	testenv.Run(t, &testenv.Config{
+		TraceExporter: exporter,
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+
+		// The logs of the request can be correlated with its trace
+		planned := logs.FilterMessage("Operation planned").All()
+		require.Len(t, planned, 1)
+		fields := planned[0].ContextMap()
+		require.NotEmpty(t, fields["reqId"])
+
+		spans := exporter.GetSpans().Snapshots()
+		require.NotEmpty(t, spans)
+		require.Equal(t, spans[0].SpanContext().TraceID().String(), fields["trace_id"])
+		require.NotEmpty(t, fields["span_id"])
+	})
+}
+
 func TestPartialOriginErrors(t *testing.T) {
 	t.Parallel()
 	testenv.Run(t, &testenv.Config{
@@ -0,0 +1,81 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestIntrospectionSecurity(t *testing.T) {
+	t.Parallel()
+
+	metricReader := metric.NewManualReader()
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		ModifySecurityConfiguration: func(cfg *config.SecurityConfiguration) {
+			cfg.Introspection.BlockUnknownClients = true
+			cfg.Introspection.KnownClients = []string{"studio"}
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query: `{ __schema { queryType { name } } }`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, `{"errors":[{"message":"introspection is not allowed for unknown clients"}],"data":null}`, res.Body)
+
+		res, err = xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query:  `{ __type(name: "Employee") { name } }`,
+			Header: http.Header{"Graphql-Client-Name": []string{"studio"}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, `{"data":{"__type":{"name":"Employee"}}}`, res.Body)
+
+		// Regular queries of unknown clients aren't affected
+		res, err = xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, employeesIDData, res.Body)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var counter *metricdata.Sum[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "router.graphql.introspection.requests" {
+					sum := m.Data.(metricdata.Sum[int64])
+					counter = &sum
+				}
+			}
+		}
+		require.NotNil(t, counter)
+		require.Len(t, counter.DataPoints, 2)
+
+		for _, dp := range counter.DataPoints {
+			require.Equal(t, int64(1), dp.Value)
+			client, _ := dp.Attributes.Value(otel.WgClientName)
+			kind, _ := dp.Attributes.Value(otel.WgIntrospectionKind)
+			blocked, _ := dp.Attributes.Value(otel.WgIntrospectionBlocked)
+			switch client.AsString() {
+			case "unknown":
+				require.Equal(t, "schema", kind.AsString())
+				require.True(t, blocked.AsBool())
+			case "studio":
+				require.Equal(t, "type", kind.AsString())
+				require.False(t, blocked.AsBool())
+			default:
+				t.Fatalf("unexpected client %q", client.AsString())
+			}
+		}
+	})
+}
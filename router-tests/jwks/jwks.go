@@ -9,6 +9,7 @@ import (
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -29,6 +30,13 @@ var (
 type Server struct {
 	privateKey *rsa.PrivateKey
 	httpServer *httptest.Server
+	// unavailable makes the JWKS endpoint respond with 503 Service Unavailable
+	unavailable atomic.Bool
+}
+
+// SetUnavailable makes the JWKS endpoint fail, like an identity provider that is down
+func (s *Server) SetUnavailable(unavailable bool) {
+	s.unavailable.Store(unavailable)
 }
 
 func (s *Server) Close() {
@@ -59,6 +67,10 @@ type jsonWebKey struct {
 }
 
 func (s *Server) jwksJSON(w http.ResponseWriter, r *http.Request) {
+	if s.unavailable.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 	k := jsonWebKey{
 		Type:      signingMethodType,
 		Algorithm: signingMethod.Name,
@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reject all operations", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithMaintenance(&config.MaintenanceConfiguration{
+					Enabled:    true,
+					StatusCode: http.StatusServiceUnavailable,
+					RetryAfter: 2 * time.Minute,
+					Message:    "under maintenance",
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := makeMaintenanceRequest(t, xEnv, `{"query":"query { employees { id } }"}`)
+			require.Equal(t, http.StatusServiceUnavailable, res.Response.StatusCode)
+			require.Equal(t, "120", res.Response.Header.Get("Retry-After"))
+			require.Equal(t, `{"errors":[{"message":"under maintenance"}],"data":null}`, res.Body)
+		})
+	})
+
+	t.Run("reject only selected operation types", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithMaintenance(&config.MaintenanceConfiguration{
+					Enabled:    true,
+					StatusCode: http.StatusServiceUnavailable,
+					Message:    "under maintenance",
+					Operations: config.MaintenanceOperations{
+						Types: []string{"mutation"},
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employees { id } }`,
+			})
+			require.JSONEq(t, employeesIDData, res.Body)
+
+			res = makeMaintenanceRequest(t, xEnv, `{"query":"mutation { updateEmployeeTag(id: 1, tag: \"test\") { id tag } }"}`)
+			require.Equal(t, http.StatusServiceUnavailable, res.Response.StatusCode)
+			require.Empty(t, res.Response.Header.Get("Retry-After"))
+			require.Equal(t, `{"errors":[{"message":"under maintenance"}],"data":null}`, res.Body)
+		})
+	})
+
+	t.Run("static response body", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithMaintenance(&config.MaintenanceConfiguration{
+					Enabled:      true,
+					StatusCode:   http.StatusServiceUnavailable,
+					RetryAfter:   30 * time.Second,
+					ResponseBody: `{"maintenance":true}`,
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := makeMaintenanceRequest(t, xEnv, `{"query":"query { employees { id } }"}`)
+			require.Equal(t, http.StatusServiceUnavailable, res.Response.StatusCode)
+			require.Equal(t, "30", res.Response.Header.Get("Retry-After"))
+			require.Equal(t, `{"maintenance":true}`, res.Body)
+
+			liveness, err := http.Get(xEnv.RouterURL + "/health/live")
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, liveness.StatusCode)
+		})
+	})
+}
+
+// makeMaintenanceRequest uses a client without retries because the router client
+// would otherwise respect the Retry-After header of the maintenance response
+func makeMaintenanceRequest(t *testing.T, xEnv *testenv.Environment, body string) *testenv.TestResponse {
+	t.Helper()
+
+	resp, err := http.Post(xEnv.GraphQLRequestURL(), "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return &testenv.TestResponse{
+		Body:     string(data),
+		Response: resp,
+	}
+}
@@ -0,0 +1,111 @@
+package integration_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestOperationTimeouts(t *testing.T) {
+	t.Parallel()
+
+	metricReader := metric.NewManualReader()
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithOperationTimeouts(&config.OperationTimeoutsConfiguration{
+				Query: 200 * time.Millisecond,
+				Operations: []config.OperationTimeout{
+					{Name: "SlowReport", Timeout: 5 * time.Second},
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		// The router client retries 5xx responses, so the requests are sent without it
+		post := func(body string) (int, string) {
+			resp, err := http.Post(xEnv.GraphQLRequestURL(), "application/json", strings.NewReader(body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			return resp.StatusCode, strings.TrimSpace(string(b))
+		}
+
+		status, body := post(`{"query":"query Delayed { delay(response: \"late\", ms: 1000) }"}`)
+		require.Equal(t, http.StatusGatewayTimeout, status)
+		require.Equal(t, `{"errors":[{"message":"Operation timed out","extensions":{"code":"OPERATION_TIMEOUT"}}],"data":null}`, body)
+
+		// The override of the operation name takes precedence over the timeout of queries
+		status, body = post(`{"query":"query SlowReport { delay(response: \"done\", ms: 500) }"}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, `{"data":{"delay":"done"}}`, body)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var counter *metricdata.Sum[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "router.graphql.operation.timeouts" {
+					sum := m.Data.(metricdata.Sum[int64])
+					counter = &sum
+				}
+			}
+		}
+		require.NotNil(t, counter)
+		require.Len(t, counter.DataPoints, 1)
+		require.Equal(t, int64(1), counter.DataPoints[0].Value)
+		name, _ := counter.DataPoints[0].Attributes.Value(otel.WgOperationName)
+		require.Equal(t, "Delayed", name.AsString())
+		operationType, _ := counter.DataPoints[0].Attributes.Value(otel.WgOperationType)
+		require.Equal(t, "query", operationType.AsString())
+	})
+}
+
+func TestOperationTimeoutsWebSocketSubscription(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithOperationTimeouts(&config.OperationTimeoutsConfiguration{
+				Subscription: 1500 * time.Millisecond,
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		conn := xEnv.InitGraphQLWebSocketConnection(nil, nil, nil)
+		err := conn.WriteJSON(testenv.WebSocketMessage{
+			ID:      "1",
+			Type:    "subscribe",
+			Payload: []byte(`{"query":"subscription { currentTime { unixTime timeStamp }}"}`),
+		})
+		require.NoError(t, err)
+
+		// The subscription delivers events until it exceeds its lifetime
+		var res testenv.WebSocketMessage
+		for {
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+			require.NoError(t, conn.ReadJSON(&res))
+			if res.Type != "next" {
+				break
+			}
+		}
+		require.Equal(t, "error", res.Type)
+		require.Equal(t, "1", res.ID)
+		require.JSONEq(t, `[{"message":"Operation timed out","extensions":{"code":"OPERATION_TIMEOUT"}}]`, string(res.Payload))
+
+		xEnv.WaitForSubscriptionCount(0, 5*time.Second)
+	})
+}
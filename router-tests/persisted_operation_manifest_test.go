@@ -0,0 +1,65 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestPersistedOperationManifest(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "operations.json")
+
+	readManifest := func() (core.PersistedOperationManifestDocument, error) {
+		var doc core.PersistedOperationManifestDocument
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return doc, err
+		}
+		return doc, json.Unmarshal(data, &doc)
+	}
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithPersistedOperationManifest(&config.PersistedOperationManifestConfiguration{
+				Enabled:       true,
+				Path:          path,
+				MaxOperations: 100,
+				WriteInterval: 10 * time.Millisecond,
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		const query = `query Employee { employee(id: 1) { id } }`
+
+		xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: query})
+		xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: query})
+
+		// Operations that fail the validation aren't recorded
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: `query Unknown { unknown }`})
+		require.Contains(t, res.Body, "errors")
+
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			doc, err := readManifest()
+			assert.NoError(t, err)
+			assert.Equal(t, core.PersistedOperationManifestFormat, doc.Format)
+			assert.Equal(t, []core.PersistedOperationManifestOperation{
+				{
+					ID:   "8f8041273406f6c0c6f965c7503bf749f2a592773aaae9bcf7e9df9e906cf9c7",
+					Name: "Employee",
+					Type: "query",
+					Body: query,
+				},
+			}, doc.Operations)
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
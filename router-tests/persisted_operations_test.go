@@ -1,17 +1,26 @@
 package integration_test
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/buger/jsonparser"
 	"github.com/wundergraph/cosmo/router/core"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wundergraph/cosmo/router-tests/testenv"
 	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func TestPersistedOperationNotFound(t *testing.T) {
@@ -367,3 +376,128 @@ func BenchmarkPersistedOperationCacheDisabled(b *testing.B) {
 		})
 	})
 }
+
+func TestPersistedOperationKillSwitch(t *testing.T) {
+	t.Parallel()
+
+	const (
+		employeesHash = "dc67510fb4289672bea757e862d6b00e83db5d3cbbcfb15260601b6f29bb2b8f"
+		blockedBody   = `{"errors":[{"message":"Employees is disabled during the incident"}],"data":null}`
+	)
+
+	request := func(hash string) testenv.GraphQLRequest {
+		header := make(http.Header)
+		header.Add("graphql-client-name", "my-client")
+		return testenv.GraphQLRequest{
+			OperationName: []byte(`"Employees"`),
+			Extensions:    []byte(`{"persistedQuery": {"version": 1, "sha256Hash": "` + hash + `"}}`),
+			Header:        header,
+		}
+	}
+
+	t.Run("blocks the hashes of the config", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifySecurityConfiguration: func(securityConfiguration *config.SecurityConfiguration) {
+				securityConfiguration.PersistedOperationKillSwitch = config.PersistedOperationKillSwitchConfiguration{
+					Hashes:  []string{strings.ToUpper(employeesHash)},
+					Message: "Employees is disabled during the incident",
+				}
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := xEnv.MakeGraphQLRequest(request(employeesHash))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.Response.StatusCode)
+			require.Equal(t, blockedBody, res.Body)
+
+			// Regular operations aren't affected
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: `query Employees { employees { id } }`})
+			require.Equal(t, employeesIDData, res.Body)
+		})
+	})
+
+	t.Run("reloads the file", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "blocked.txt")
+		require.NoError(t, os.WriteFile(file, []byte("# no blocked operations\n"), 0o600))
+
+		testenv.Run(t, &testenv.Config{
+			ModifySecurityConfiguration: func(securityConfiguration *config.SecurityConfiguration) {
+				securityConfiguration.PersistedOperationKillSwitch = config.PersistedOperationKillSwitchConfiguration{
+					File:           file,
+					ReloadInterval: 50 * time.Millisecond,
+					Message:        "Employees is disabled during the incident",
+				}
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := xEnv.MakeGraphQLRequest(request(employeesHash))
+			require.NoError(t, err)
+			require.Equal(t, employeesIDData, res.Body)
+
+			require.NoError(t, os.WriteFile(file, []byte("# incident 42\n"+employeesHash+"\n"), 0o600))
+
+			require.EventuallyWithT(t, func(t *assert.CollectT) {
+				res, err := xEnv.MakeGraphQLRequest(request(employeesHash))
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, blockedBody, res.Body)
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	})
+}
+
+func TestPersistedOperationUsage(t *testing.T) {
+	t.Parallel()
+
+	metricReader := metric.NewManualReader()
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithPersistedOperationUsage(&config.PersistedOperationUsageConfiguration{Enabled: true}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		header := make(http.Header)
+		header.Add("graphql-client-name", "my-client")
+		// The second request is served from the cache of the persisted operations
+		for i := 0; i < 2; i++ {
+			res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+				OperationName: []byte(`"Employees"`),
+				Extensions:    []byte(`{"persistedQuery": {"version": 1, "sha256Hash": "dc67510fb4289672bea757e862d6b00e83db5d3cbbcfb15260601b6f29bb2b8f"}}`),
+				Header:        header,
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.Response.StatusCode)
+		}
+
+		// Operations that are not found are not tracked
+		_, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Extensions: []byte(`{"persistedQuery": {"version": 1, "sha256Hash": "does-not-exist"}}`),
+			Header:     header,
+		})
+		require.NoError(t, err)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var hits *metricdata.Sum[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "router.graphql.persisted_operation.hits" {
+					sum := m.Data.(metricdata.Sum[int64])
+					hits = &sum
+				}
+			}
+		}
+		require.NotNil(t, hits)
+		require.Len(t, hits.DataPoints, 1)
+		require.Equal(t, int64(2), hits.DataPoints[0].Value)
+		id, _ := hits.DataPoints[0].Attributes.Value(otel.WgOperationPersistedID)
+		require.Equal(t, "dc67510fb4289672bea757e862d6b00e83db5d3cbbcfb15260601b6f29bb2b8f", id.AsString())
+		client, _ := hits.DataPoints[0].Attributes.Value(otel.WgClientName)
+		require.Equal(t, "my-client", client.AsString())
+	})
+}
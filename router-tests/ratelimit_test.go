@@ -3,6 +3,7 @@ package integration
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"testing"
 	"time"
@@ -252,7 +253,66 @@ func TestRateLimit(t *testing.T) {
 			})
 			require.NoError(t, err)
 			require.Equal(t, http.StatusOK, res.Response.StatusCode)
-			require.Equal(t, `{"errors":[{"message":"Rate limit exceeded"}],"data":null,"extensions":{"rateLimit":{"requestRate":2,"remaining":0,"retryAfterMs":1234,"resetAfterMs":1234}}}`, res.Body)
+			require.Equal(t, `{"errors":[{"message":"Rate limit exceeded","extensions":{"code":"RATE_LIMIT_EXCEEDED"}}],"data":null,"extensions":{"rateLimit":{"requestRate":2,"remaining":0,"retryAfterMs":1234,"resetAfterMs":1234}}}`, res.Body)
+		})
+	})
+	t.Run("quota endpoint by client name", func(t *testing.T) {
+		t.Parallel()
+
+		key := uuid.New().String()
+		t.Cleanup(func() {
+			client := redis.NewClient(&redis.Options{Addr: "localhost:6379", Password: "test"})
+			del := client.Del(context.Background(), "rate:"+key+":client-a", "rate:"+key+":client-b")
+			require.NoError(t, del.Err())
+		})
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithRateLimitConfig(&config.RateLimitConfiguration{
+					Enabled:  true,
+					Strategy: "simple",
+					SimpleStrategy: config.RateLimitSimpleStrategy{
+						Rate:   3,
+						Burst:  3,
+						Period: time.Minute,
+					},
+					Storage: config.RedisConfiguration{
+						Url:       "redis://localhost:6379",
+						KeyPrefix: key,
+					},
+					KeyBy: core.RateLimitKeyByClientName,
+					QuotaEndpoint: config.RateLimitQuotaEndpointConfiguration{
+						Enabled: true,
+						Path:    "/quota",
+					},
+					Debug: true,
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			quota := func(clientName string) string {
+				req, err := http.NewRequest(http.MethodGet, xEnv.RouterURL+"/quota", nil)
+				require.NoError(t, err)
+				req.Header.Set("graphql-client-name", clientName)
+				res, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				defer res.Body.Close()
+				require.Equal(t, http.StatusOK, res.StatusCode)
+				body, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				return string(body)
+			}
+
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:     `query ($n:Int!) { employee(id:$n) { id } }`,
+				Variables: json.RawMessage(`{"n":1}`),
+				Header:    http.Header{"Graphql-Client-Name": []string{"client-a"}},
+			})
+			require.Equal(t, `{"data":{"employee":{"id":1}},"extensions":{"rateLimit":{"requestRate":1,"remaining":2,"retryAfterMs":1234,"resetAfterMs":1234}}}`, res.Body)
+
+			// Looking up the quota doesn't consume it
+			for i := 0; i < 2; i++ {
+				require.JSONEq(t, `{"client":"client-a","rate":3,"burst":3,"periodMs":60000,"remaining":2,"resetAfterMs":1234}`, quota("client-a"))
+			}
+			require.JSONEq(t, `{"client":"client-b","rate":3,"burst":3,"periodMs":60000,"remaining":3,"resetAfterMs":1234}`, quota("client-b"))
 		})
 	})
 }
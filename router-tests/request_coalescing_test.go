@@ -0,0 +1,82 @@
+package integration_test
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestRequestCoalescing(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+	release := make(chan struct{})
+	var subgraphRequests atomic.Int32
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithRequestCoalescing(&config.RequestCoalescingConfiguration{
+				Enabled:      true,
+				ScopeHeaders: []string{"Authorization"},
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(handler http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						subgraphRequests.Add(1)
+						<-release
+						handler.ServeHTTP(w, r)
+					})
+				},
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		var wg sync.WaitGroup
+		for _, authorization := range []string{"Bearer a", "Bearer a", "Bearer a", "Bearer a", "Bearer b"} {
+			wg.Add(1)
+			go func(authorization string) {
+				defer wg.Done()
+				res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+					Query:  `query { employee(id: 1) { id } }`,
+					Header: http.Header{"Authorization": []string{authorization}},
+				})
+				require.NoError(t, err)
+				require.Equal(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+			}(authorization)
+		}
+
+		// The identical queries wait for the execution of the first one
+		require.Eventually(t, func() bool { return subgraphRequests.Load() > 0 }, 5*time.Second, 10*time.Millisecond)
+		time.Sleep(200 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		decisions := map[any]int{}
+		for _, entry := range accessLogs(logs).All() {
+			cache, ok := entry.ContextMap()["cache"].(map[string]any)
+			require.True(t, ok)
+			decisions[cache["coalescing"]]++
+		}
+		// The queries of another scope aren't coalesced
+		require.Equal(t, map[any]int{"hit": 3, "miss": 2}, decisions)
+
+		// Mutations are always executed
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `mutation { updateEmployeeTag(id: 1, tag: "test") { id } }`,
+		})
+		require.Equal(t, `{"data":{"updateEmployeeTag":{"id":1}}}`, res.Body)
+	})
+}
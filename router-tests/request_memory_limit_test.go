@@ -0,0 +1,34 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestRequestMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("aborts requests over the limit", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithRequestMemoryLimit(&config.RequestMemoryLimitConfiguration{
+					Enabled: true,
+					MaxSize: 1000,
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: `{ employees { id details { forename surname } } }`})
+			require.JSONEq(t, `{"errors":[{"message":"Request exceeds the memory limit","extensions":{"code":"REQUEST_MEMORY_LIMIT_EXCEEDED"}}],"data":null}`, res.Body)
+
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: `{ employee(id: 1) { id } }`})
+			require.JSONEq(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+		})
+	})
+}
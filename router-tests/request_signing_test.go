@@ -0,0 +1,63 @@
+package integration_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestRequestSigning(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithRequestSigning(&config.RequestSigningConfiguration{
+				Enabled:         true,
+				SignatureHeader: "X-Signature",
+				TimestampHeader: "X-Signature-Timestamp",
+				KeyIDHeader:     "X-Signature-Key-Id",
+				ReplayWindow:    time.Minute,
+				Keys:            []config.RequestSigningKey{{ID: "gateway", Secret: "gateway-secret"}},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res, err := xEnv.MakeRequest(http.MethodPost, "/graphql", http.Header{}, strings.NewReader(employeesQuery))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte("gateway-secret"))
+		mac.Write([]byte(timestamp + ".POST./graphql." + employeesQuery))
+
+		header := http.Header{
+			"X-Signature-Key-Id":    []string{"gateway"},
+			"X-Signature-Timestamp": []string{timestamp},
+			"X-Signature":           []string{hex.EncodeToString(mac.Sum(nil))},
+		}
+		res, err = xEnv.MakeRequest(http.MethodPost, "/graphql", header, strings.NewReader(employeesQuery))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, employeesIDData, string(data))
+
+		// The same request is rejected when it is replayed
+		res, err = xEnv.MakeRequest(http.MethodPost, "/graphql", header, strings.NewReader(employeesQuery))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
@@ -0,0 +1,98 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/trace/tracetest"
+)
+
+func TestRequestTags(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+	metricReader := metric.NewManualReader()
+	exporter := tracetest.NewInMemoryExporter(t)
+
+	testenv.Run(t, &testenv.Config{
+		TraceExporter: exporter,
+		MetricReader:  metricReader,
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithAccessLogs(&config.AccessLogsConfiguration{}),
+			core.WithRequestTags(&config.RequestTagsConfiguration{
+				Enabled: true,
+				Tags: []config.RequestTag{
+					{Name: "tenant", Expression: `request.header["x-tenant"]`},
+					{Name: "operation", Expression: `operation.type + ":" + operation.name`},
+					{Name: "missing", Expression: `request.header["x-missing"]`},
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:  `query Employees { employees { id } }`,
+			Header: http.Header{"X-Tenant": []string{"acme"}},
+		})
+		require.Equal(t, employeesIDData, res.Body)
+
+		// Logs
+		entries := accessLogs(logs).All()
+		require.Len(t, entries, 1)
+		require.Equal(t, map[string]any{
+			"tenant":    "acme",
+			"operation": "query:Employees",
+		}, entries[0].ContextMap()["tags"])
+
+		// Traces
+		var routerSpanFound bool
+		for _, span := range exporter.GetSpans().Snapshots() {
+			if span.SpanKind() != trace.SpanKindServer || span.Name() != "query Employees" {
+				continue
+			}
+			routerSpanFound = true
+			require.Contains(t, span.Attributes(), attribute.String("wg.request.tag.tenant", "acme"))
+			require.Contains(t, span.Attributes(), attribute.String("wg.request.tag.operation", "query:Employees"))
+			require.NotContains(t, span.Attributes(), attribute.String("wg.request.tag.missing", ""))
+		}
+		require.True(t, routerSpanFound)
+
+		// Metrics
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var requests *metricdata.Sum[int64]
+		for _, scope := range rm.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				if m.Name == "router.http.requests" {
+					sum := m.Data.(metricdata.Sum[int64])
+					requests = &sum
+				}
+			}
+		}
+		require.NotNil(t, requests)
+
+		// The data point of the router request is tagged, the data point of the subgraph request isn't
+		var tagged int
+		for _, dataPoint := range requests.DataPoints {
+			if tenant, ok := dataPoint.Attributes.Value("wg.request.tag.tenant"); ok {
+				require.Equal(t, "acme", tenant.AsString())
+				tagged++
+			}
+		}
+		require.Equal(t, 1, tagged)
+	})
+}
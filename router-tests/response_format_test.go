@@ -0,0 +1,87 @@
+package integration_test
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestResponseFormat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("negotiates the GraphQL response media type", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:  `{ employee(id: 1) { id } }`,
+				Header: http.Header{"Accept": {"application/graphql-response+json, application/json;q=0.9"}},
+			})
+			require.Equal(t, "application/graphql-response+json", res.Response.Header.Get("Content-Type"))
+			require.JSONEq(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:  `{ employee(id: 1) { id } }`,
+				Header: http.Header{"Accept": {"text/html"}},
+			})
+			require.Equal(t, "application/json", res.Response.Header.Get("Content-Type"))
+		})
+	})
+
+	t.Run("sends multipart responses", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:  `{ employee(id: 1) { id } }`,
+				Header: http.Header{"Accept": {"multipart/mixed, application/json;q=0.5"}},
+			})
+			require.Equal(t, `multipart/mixed; boundary="graphql"`, res.Response.Header.Get("Content-Type"))
+			require.Equal(t, "--graphql\r\nContent-Type: application/json; charset=utf-8\r\n\r\n{\"data\":{\"employee\":{\"id\":1}}}\r\n--graphql--", res.Body)
+		})
+	})
+
+	t.Run("encodes the responses with CBOR", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithResponseFormats(&config.ResponseFormatsConfiguration{CBOR: true}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:  `{ employee(id: 1) { id } }`,
+				Header: http.Header{"Accept": {"application/cbor"}},
+			})
+			require.Equal(t, "application/cbor", res.Response.Header.Get("Content-Type"))
+			// {"data":{"employee":{"id":1}}}
+			require.Equal(t, "a16464617461a168656d706c6f796565a162696401", hex.EncodeToString([]byte(res.Body)))
+
+			// Errors are sent as JSON
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:  `{ employee(id: 1) { unknown } }`,
+				Header: http.Header{"Accept": {"application/cbor"}},
+			})
+			require.JSONEq(t, `{"errors":[{"message":"field: unknown not defined on type: Employee","path":["query","employee","unknown"]}],"data":null}`, res.Body)
+		})
+	})
+
+	t.Run("doesn't encode with CBOR unless it is enabled", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query:  `{ employee(id: 1) { id } }`,
+				Header: http.Header{"Accept": {"application/cbor, application/json;q=0.1"}},
+			})
+			require.Equal(t, "application/json", res.Response.Header.Get("Content-Type"))
+			require.JSONEq(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+		})
+	})
+}
@@ -0,0 +1,72 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestResponseSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("replaces responses over the limit with an error", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithResponseSizeLimit(&config.ResponseSizeLimitConfiguration{
+					Enabled: true,
+					MaxSize: 100,
+					Mode:    core.ResponseSizeLimitModeError,
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: `{ employees { id } }`})
+			require.JSONEq(t, `{"errors":[{"message":"Response exceeds the maximum size","extensions":{"code":"RESPONSE_TOO_LARGE"}}],"data":null}`, res.Body)
+
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: `{ employee(id: 1) { id } }`})
+			require.JSONEq(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+		})
+	})
+
+	t.Run("truncates the lists of responses over the limit", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithResponseSizeLimit(&config.ResponseSizeLimitConfiguration{
+					Enabled: true,
+					MaxSize: 400,
+					Mode:    core.ResponseSizeLimitModeTruncate,
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{Query: `{ employees { id details { forename surname } } }`})
+			require.LessOrEqual(t, len(res.Body), 400)
+
+			var body struct {
+				Data struct {
+					Employees []struct {
+						ID int `json:"id"`
+					} `json:"employees"`
+				} `json:"data"`
+				Extensions struct {
+					Warnings []struct {
+						Code string `json:"code"`
+					} `json:"warnings"`
+				} `json:"extensions"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(res.Body), &body))
+			require.NotEmpty(t, body.Data.Employees)
+			require.Less(t, len(body.Data.Employees), 10)
+			require.Equal(t, 1, body.Data.Employees[0].ID)
+			require.Len(t, body.Extensions.Warnings, 1)
+			require.Equal(t, core.ResponseTruncatedWarningCode, body.Extensions.Warnings[0].Code)
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestResponseStreaming(t *testing.T) {
+	t.Parallel()
+
+	enableStreaming := func(cfg *config.EngineExecutionConfiguration) {
+		cfg.ResponseStreaming.Enabled = true
+		cfg.ResponseStreaming.FlushThreshold = 1024
+	}
+
+	t.Run("streamed responses match buffered responses", func(t *testing.T) {
+		t.Parallel()
+
+		var buffered string
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: bigEmployeesQuery,
+			})
+			require.Equal(t, http.StatusOK, res.Response.StatusCode)
+			buffered = res.Body
+		})
+
+		require.Greater(t, len(buffered), 1024*3)
+
+		testenv.Run(t, &testenv.Config{
+			ModifyEngineExecutionConfiguration: enableStreaming,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: bigEmployeesQuery,
+			})
+			require.Equal(t, http.StatusOK, res.Response.StatusCode)
+			require.Equal(t, "application/json", res.Response.Header.Get("Content-Type"))
+			require.True(t, json.Valid([]byte(res.Body)))
+			require.Equal(t, buffered, res.Body)
+		})
+	})
+
+	t.Run("small responses are not streamed", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyEngineExecutionConfiguration: enableStreaming,
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+				Query:  `{ employees { id } }`,
+				Header: http.Header{"Accept-Encoding": []string{"identity"}},
+			})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.Response.StatusCode)
+			require.Equal(t, int64(len(employeesIDData)), res.Response.ContentLength)
+			require.Equal(t, employeesIDData, res.Body)
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestRESTEndpoints(t *testing.T) {
+	t.Parallel()
+
+	const employeePets = "3367510fb4289672bea757e862d6b00e83db5d3cbbcfb15260601b6f29bb2b8f"
+
+	booleanParameters := []config.RESTParameterConfiguration{
+		{Name: "withAligators", In: "query", Type: "boolean", Required: true},
+		{Name: "withCats", In: "query", Type: "boolean", Required: true},
+		{Name: "skipDogs", In: "query", Type: "boolean", Required: true},
+		{Name: "skipMouses", In: "query", Type: "boolean", Required: true},
+	}
+
+	restConfig := &config.RESTEndpointsConfiguration{
+		Enabled:     true,
+		BasePath:    "/rest",
+		OpenAPIPath: "/openapi.json",
+		Endpoints: []config.RESTEndpointConfiguration{
+			{
+				Method:             http.MethodGet,
+				Path:               "/employees/{id}/pets",
+				PersistedOperation: employeePets,
+				ClientName:         "my-client",
+				Summary:            "Get the pets of an employee",
+				Parameters: append([]config.RESTParameterConfiguration{
+					{Name: "id", In: "path", Type: "integer"},
+				}, booleanParameters...),
+			},
+			{
+				Method:             http.MethodPost,
+				Path:               "/employees/{employee}/pets",
+				PersistedOperation: employeePets,
+				ClientName:         "my-client",
+				Parameters: []config.RESTParameterConfiguration{
+					{Name: "employee", In: "path", Variable: "id", Type: "integer"},
+				},
+			},
+		},
+	}
+
+	const pets = `{"data":{"employee":{"details":{"pets":[{"name":"Snappy","__typename":"Alligator","class":"REPTILE","dangerous":"yes","gender":"UNKNOWN"}]}}}}`
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithRESTEndpoints(restConfig),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		do := func(method, path, body string) (int, string) {
+			req, err := http.NewRequest(method, xEnv.RouterURL+path, strings.NewReader(body))
+			require.NoError(t, err)
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			data, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			return res.StatusCode, string(data)
+		}
+
+		t.Run("maps the parameters to the variables", func(t *testing.T) {
+			status, body := do(http.MethodGet, "/rest/employees/3/pets?withAligators=true&withCats=true&skipDogs=false&skipMouses=true", "")
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, pets, body)
+		})
+
+		t.Run("merges the body with the parameters", func(t *testing.T) {
+			status, body := do(http.MethodPost, "/rest/employees/3/pets", `{"id":1,"withAligators":true,"withCats":true,"skipDogs":false,"skipMouses":true}`)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, pets, body)
+		})
+
+		t.Run("rejects invalid parameters", func(t *testing.T) {
+			status, body := do(http.MethodGet, "/rest/employees/three/pets?withAligators=true&withCats=true&skipDogs=false&skipMouses=true", "")
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Equal(t, `{"errors":[{"message":"invalid value of the parameter 'id': expected an integer"}],"data":null}`, body)
+
+			status, body = do(http.MethodGet, "/rest/employees/3/pets?withAligators=true", "")
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Equal(t, `{"errors":[{"message":"the parameter 'withCats' is required"}],"data":null}`, body)
+		})
+
+		t.Run("serves the OpenAPI document", func(t *testing.T) {
+			status, body := do(http.MethodGet, "/rest/openapi.json", "")
+			require.Equal(t, http.StatusOK, status)
+
+			var doc struct {
+				OpenAPI string                                `json:"openapi"`
+				Paths   map[string]map[string]json.RawMessage `json:"paths"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(body), &doc))
+			require.Equal(t, "3.0.3", doc.OpenAPI)
+			require.Contains(t, doc.Paths, "/rest/employees/{id}/pets")
+			require.Contains(t, doc.Paths["/rest/employees/{id}/pets"], "get")
+			require.Contains(t, doc.Paths["/rest/employees/{employee}/pets"], "post")
+		})
+	})
+}
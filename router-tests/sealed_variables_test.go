@@ -0,0 +1,80 @@
+package integration_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSealedVariables(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	seal := func(value string) string {
+		block, err := aes.NewCipher(key)
+		require.NoError(t, err)
+		aead, err := cipher.NewGCM(block)
+		require.NoError(t, err)
+		nonce := make([]byte, aead.NonceSize())
+		_, err = rand.Read(nonce)
+		require.NoError(t, err)
+		return "sealed:2024-06:" + base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil))
+	}
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithAccessLogs(&config.AccessLogsConfiguration{
+				Operations: config.AccessLogsOperationsConfiguration{
+					Enabled:          true,
+					SampleRate:       1,
+					IncludeVariables: true,
+				},
+			}),
+			core.WithSealedVariables(&config.SealedVariablesConfiguration{
+				Enabled: true,
+				Keys:    []config.SealedVariablesKey{{ID: "2024-06", Secret: base64.StdEncoding.EncodeToString(key)}},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		sealedID := seal(`1`)
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:     `query Employee($id: Int!) { employee(id: $id) { id } }`,
+			Variables: json.RawMessage(`{"id":"` + sealedID + `"}`),
+		})
+		require.Equal(t, `{"data":{"employee":{"id":1}}}`, res.Body)
+
+		// The access logs keep the sealed value
+		entries := accessLogs(logs).All()
+		require.Len(t, entries, 1)
+		require.JSONEq(t, `{"id":"`+sealedID+`"}`, entries[0].ContextMap()["operation_variables"].(string))
+
+		// The validation errors don't reveal the decrypted values
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:     `query Employee($id: Int!) { employee(id: $id) { id } }`,
+			Variables: json.RawMessage(`{"id":"` + seal(`"secret-value"`) + `"}`),
+		})
+		require.NotContains(t, res.Body, "secret-value")
+		require.Contains(t, res.Body, "sealed values")
+
+		res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query:     `query Employee($id: Int!) { employee(id: $id) { id } }`,
+			Variables: json.RawMessage(`{"id":"sealed:unknown:abc"}`),
+		})
+		require.Contains(t, res.Body, "unknown key 'unknown'")
+	})
+}
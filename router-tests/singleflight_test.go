@@ -280,3 +280,65 @@ func TestSingleFlightSameHeaders(t *testing.T) {
 		require.NotEqual(t, int64(numOfOperations), xEnv.SubgraphRequestCount.Global.Load())
 	})
 }
+
+func TestSingleFlightCoalescing(t *testing.T) {
+	t.Parallel()
+
+	t.Run("identical fetches within the window share a response", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyEngineExecutionConfiguration: func(cfg *config.EngineExecutionConfiguration) {
+				// Coalescing doesn't depend on single flight
+				cfg.EnableSingleFlight = false
+				cfg.FetchCoalescing = config.FetchCoalescingConfiguration{Enabled: true, Window: 300 * time.Millisecond}
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			var (
+				numOfOperations = 5
+				wg              sync.WaitGroup
+			)
+			wg.Add(numOfOperations)
+			for i := 0; i < numOfOperations; i++ {
+				// The requests don't overlap without coalescing, because the subgraph responds immediately
+				go func(delay time.Duration) {
+					defer wg.Done()
+					time.Sleep(delay)
+					res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+						Query: `{ employees { id } }`,
+					})
+					require.Equal(t, `{"data":{"employees":[{"id":1},{"id":2},{"id":3},{"id":4},{"id":5},{"id":7},{"id":8},{"id":10},{"id":11},{"id":12}]}}`, res.Body)
+				}(time.Duration(i) * 20 * time.Millisecond)
+			}
+			wg.Wait()
+			require.Equal(t, int64(1), xEnv.SubgraphRequestCount.Global.Load())
+		})
+	})
+
+	t.Run("mutations are not coalesced", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			ModifyEngineExecutionConfiguration: func(cfg *config.EngineExecutionConfiguration) {
+				cfg.FetchCoalescing = config.FetchCoalescingConfiguration{Enabled: true, Window: 300 * time.Millisecond}
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			var (
+				numOfOperations = 3
+				wg              sync.WaitGroup
+			)
+			wg.Add(numOfOperations)
+			for i := 0; i < numOfOperations; i++ {
+				go func() {
+					defer wg.Done()
+					res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+						Query: `mutation { updateEmployeeTag(id: 1, tag: "test") { id tag } }`,
+					})
+					require.Equal(t, `{"data":{"updateEmployeeTag":{"id":1,"tag":"test"}}}`, res.Body)
+				}()
+			}
+			wg.Wait()
+			require.Equal(t, int64(numOfOperations), xEnv.SubgraphRequestCount.Global.Load())
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSLOMetrics(t *testing.T) {
+	t.Parallel()
+
+	metricReader := metric.NewManualReader()
+	var fail atomic.Bool
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithSLO(&config.SLOConfiguration{
+				Enabled:            true,
+				AvailabilityTarget: 0.9,
+				LatencyTarget:      0.99,
+				LatencyThreshold:   time.Minute,
+				Windows:            []time.Duration{5 * time.Minute},
+				MaxClients:         10,
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(handler http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if fail.Load() {
+							w.WriteHeader(http.StatusInternalServerError)
+							return
+						}
+						handler.ServeHTTP(w, r)
+					})
+				},
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		for i := 0; i < 3; i++ {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `{ employees { id } }`,
+			})
+			require.Equal(t, employeesIDData, res.Body)
+		}
+
+		// Errors of the client don't consume the error budget
+		res, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query: `{ employees { unknown } }`,
+		})
+		require.NoError(t, err)
+		require.Contains(t, res.Body, "errors")
+
+		fail.Store(true)
+		res, err = xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query: `{ employees { id } }`,
+		})
+		require.NoError(t, err)
+		require.Contains(t, res.Body, "errors")
+
+		collect := func() (sli, burnRate *metricdata.Gauge[float64]) {
+			rm := metricdata.ResourceMetrics{}
+			require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					switch m.Name {
+					case "router.slo.availability.sli":
+						gauge := m.Data.(metricdata.Gauge[float64])
+						sli = &gauge
+					case "router.slo.availability.burn_rate":
+						gauge := m.Data.(metricdata.Gauge[float64])
+						burnRate = &gauge
+					}
+				}
+			}
+			return sli, burnRate
+		}
+
+		// The request is recorded after the response is written
+		var sli, burnRate *metricdata.Gauge[float64]
+		require.Eventually(t, func() bool {
+			sli, burnRate = collect()
+			return sli != nil && len(sli.DataPoints) > 0 && sli.DataPoints[0].Value < 1
+		}, 5*time.Second, 50*time.Millisecond)
+		require.NotNil(t, burnRate)
+
+		// The series of the graph and of the client
+		require.Len(t, sli.DataPoints, 2)
+		for i, dp := range sli.DataPoints {
+			window, _ := dp.Attributes.Value(attribute.Key("slo.window"))
+			require.Equal(t, "5m0s", window.AsString())
+
+			// One of five requests failed, which burns the budget of 10% at twice the rate
+			require.InDelta(t, 0.8, dp.Value, 0.0001)
+			require.InDelta(t, 2.0, burnRate.DataPoints[i].Value, 0.0001)
+		}
+	})
+}
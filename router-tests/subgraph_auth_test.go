@@ -0,0 +1,38 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSubgraphAuthentication(t *testing.T) {
+	t.Parallel()
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithHeaderRules(config.HeaderRules{
+				All: config.GlobalHeaderRule{
+					Request: []config.RequestHeaderRule{
+						{Operation: config.HeaderRuleOperationPropagate, Named: "Authorization"},
+					},
+				},
+			}),
+			core.WithSubgraphAuthentication(config.SubgraphAuthenticationConfiguration{
+				Subgraphs: map[string]config.SubgraphCredentials{
+					"test1": {Type: core.SubgraphCredentialsTypeBearer, Token: "subgraph-token"},
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Header: http.Header{"Authorization": []string{"Bearer client-token"}},
+			Query:  `query { headerValue(name:"Authorization") }`,
+		})
+		require.Equal(t, `{"data":{"headerValue":"Bearer subgraph-token"}}`, res.Body)
+	})
+}
@@ -0,0 +1,84 @@
+package integration_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSubgraphCompression(t *testing.T) {
+	t.Parallel()
+
+	var (
+		employeesEncoding atomic.Value
+		productsEncoding  atomic.Value
+	)
+
+	// brotliMiddleware compresses the responses of the subgraph with brotli, if the router accepts it
+	brotliMiddleware := func(acceptEncoding *atomic.Value) func(http.Handler) http.Handler {
+		return func(handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncoding.Store(r.Header.Get("Accept-Encoding"))
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "br") {
+					handler.ServeHTTP(w, r)
+					return
+				}
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, r)
+
+				var buf bytes.Buffer
+				bw := brotli.NewWriter(&buf)
+				_, _ = bw.Write(rec.Body.Bytes())
+				_ = bw.Close()
+
+				for name, values := range rec.Header() {
+					w.Header()[name] = values
+				}
+				w.Header().Del("Content-Length")
+				w.Header().Set("Content-Encoding", "br")
+				w.WriteHeader(rec.Code)
+				_, _ = w.Write(buf.Bytes())
+			})
+		}
+	}
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithSubgraphCompression(&config.SubgraphCompressionConfiguration{
+				Enabled:    true,
+				Algorithms: []string{"br", "gzip"},
+				Subgraphs: map[string]config.SubgraphCompression{
+					"products": {Enabled: false},
+				},
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: brotliMiddleware(&employeesEncoding),
+			},
+			Products: testenv.SubgraphConfig{
+				Middleware: brotliMiddleware(&productsEncoding),
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+			Query: `{ employees { id notes } }`,
+		})
+		require.Equal(t, `{"data":{"employees":[{"id":1,"notes":"Jens notes resolved by products"},{"id":2,"notes":"Dustin notes resolved by products"},{"id":3,"notes":"Stefan notes resolved by products"},{"id":4,"notes":"Björn notes resolved by products"},{"id":5,"notes":"Sergiy notes resolved by products"},{"id":7,"notes":"Suvij notes resolved by products"},{"id":8,"notes":"Nithin notes resolved by products"},{"id":10,"notes":"Eelco notes resolved by products"},{"id":11,"notes":"Alexandra notes resolved by products"},{"id":12,"notes":"David notes resolved by products"}]}}`, res.Body)
+
+		// The compressed response of the employees subgraph is decompressed by the router
+		require.Equal(t, "br, gzip;q=0.9", employeesEncoding.Load())
+		// The products subgraph keeps the default negotiation
+		require.Equal(t, "gzip", productsEncoding.Load())
+	})
+}
@@ -0,0 +1,74 @@
+package integration_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSubgraphMocks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("mocks the flagged subgraphs without sending the requests", func(t *testing.T) {
+		t.Parallel()
+
+		var employeesRequests atomic.Int64
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithSubgraphMocks(&config.SubgraphMocksConfiguration{
+					Enabled:    true,
+					Subgraphs:  []string{"employees"},
+					ListLength: 2,
+					Fixtures:   map[string]any{"Employee.tag": "mocked"},
+				}),
+			},
+			Subgraphs: testenv.SubgraphsConfig{
+				Employees: testenv.SubgraphConfig{
+					Middleware: func(handler http.Handler) http.Handler {
+						return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+							employeesRequests.Add(1)
+							handler.ServeHTTP(w, r)
+						})
+					},
+				},
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `{ employees { id tag details { forename } } }`,
+			})
+			// The values are generated by type and unique within the response, unless there is a fixture
+			require.JSONEq(t, `{"data":{"employees":[{"id":1,"tag":"mocked","details":{"forename":"forename 2"}},{"id":3,"tag":"mocked","details":{"forename":"forename 4"}}]}}`, res.Body)
+			require.Equal(t, int64(0), employeesRequests.Load())
+		})
+	})
+
+	t.Run("mocks the unreachable subgraphs", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithSubgraphMocks(&config.SubgraphMocksConfiguration{
+					Enabled:     true,
+					Unreachable: true,
+					ListLength:  2,
+				}),
+			},
+			Subgraphs: testenv.SubgraphsConfig{
+				Products: testenv.SubgraphConfig{CloseOnStart: true},
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `{ employee(id: 1) { id products } }`,
+			})
+			// The employee is fetched from the employees subgraph, its products are mocked
+			require.JSONEq(t, `{"data":{"employee":{"id":1,"products":["CONSULTANCY","CONSULTANCY"]}}}`, res.Body)
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestSubgraphResponseValidation(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.WarnLevel)
+	metricReader := metric.NewManualReader()
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithSubgraphResponseValidation(&config.SubgraphResponseValidationConfiguration{
+				Enabled:             true,
+				SampleRate:          1,
+				MaxLoggedViolations: 10,
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(_ http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Type", "application/json")
+						_, _ = w.Write([]byte(`{"data":{"employees":[{"id":1},{"id":"2"},{"id":null}]}}`))
+					})
+				},
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		_, err := xEnv.MakeGraphQLRequest(testenv.GraphQLRequest{
+			Query:         `query Employees { employees { id } }`,
+			OperationName: []byte(`"Employees"`),
+		})
+		require.NoError(t, err)
+
+		entries := logs.Filter(func(e observer.LoggedEntry) bool {
+			return e.LoggerName == "subgraph_response_validation"
+		}).All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		require.Equal(t, "employees", fields["subgraph_name"])
+		require.Equal(t, "Employees", fields["operation_name"])
+		require.Equal(t, int64(2), fields["violation_count"])
+		require.Equal(t, []interface{}{
+			"employees.1.id: expected a value of type 'Int', got a string",
+			"employees.2.id: the non-nullable field of type 'Int!' is null",
+		}, fields["violations"])
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+		var violations *metricdata.Sum[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "router.graphql.subgraph.schema_violations" {
+					sum := m.Data.(metricdata.Sum[int64])
+					violations = &sum
+				}
+			}
+		}
+		require.NotNil(t, violations)
+		require.Len(t, violations.DataPoints, 1)
+		require.Equal(t, int64(2), violations.DataPoints[0].Value)
+		subgraph, _ := violations.DataPoints[0].Attributes.Value(otel.WgSubgraphName)
+		require.Equal(t, "employees", subgraph.AsString())
+	})
+}
@@ -0,0 +1,101 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSubscriptionWebhooks(t *testing.T) {
+	t.Parallel()
+
+	verifier, err := core.NewRequestSignatureVerifier(&core.RequestSignatureVerifierOptions{
+		Logger:          zap.NewNop(),
+		Keys:            []core.RequestSigningKey{{ID: "key-1", Secret: "webhook-secret"}},
+		SignatureHeader: "X-Signature",
+		TimestampHeader: "X-Signature-Timestamp",
+		KeyIDHeader:     "X-Signature-Key-Id",
+		ReplayWindow:    time.Minute,
+	})
+	require.NoError(t, err)
+
+	var (
+		mu     sync.Mutex
+		events []core.SubscriptionWebhookEvent
+	)
+	receiver := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event core.SubscriptionWebhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})))
+	defer receiver.Close()
+
+	received := func() []core.SubscriptionWebhookEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]core.SubscriptionWebhookEvent(nil), events...)
+	}
+
+	testenv.Run(t, &testenv.Config{
+		RouterOptions: []core.Option{
+			core.WithSubscriptionWebhooks(&config.SubscriptionWebhooksConfiguration{
+				Enabled:          true,
+				Timeout:          5 * time.Second,
+				MaxRetries:       3,
+				RetryInterval:    time.Hour,
+				MaxRetryInterval: time.Hour,
+				QueueSize:        10,
+				SignatureHeader:  "X-Signature",
+				TimestampHeader:  "X-Signature-Timestamp",
+				KeyIDHeader:      "X-Signature-Key-Id",
+				Webhooks: []config.SubscriptionWebhookConfiguration{
+					{
+						Name:          "countdown",
+						URL:           receiver.URL + "/hooks/countdown",
+						Query:         `subscription Countdown($repeat: Int) { headerValue(name: "foo", repeat: $repeat) { seq total } }`,
+						OperationName: "Countdown",
+						Variables:     map[string]any{"repeat": 3},
+						ClientName:    "webhooks",
+						Signing:       config.RequestSigningKey{ID: "key-1", Secret: "webhook-secret"},
+					},
+				},
+			}),
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		require.Eventually(t, func() bool {
+			return len(received()) == 3
+		}, 10*time.Second, 50*time.Millisecond)
+
+		for i, event := range received() {
+			require.Equal(t, "countdown", event.Webhook)
+			require.Equal(t, uint64(i+1), event.Sequence)
+
+			var payload struct {
+				Data struct {
+					HeaderValue struct {
+						Seq   int `json:"seq"`
+						Total int `json:"total"`
+					} `json:"headerValue"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(event.Payload, &payload))
+			require.Equal(t, i, payload.Data.HeaderValue.Seq)
+			require.Equal(t, 3, payload.Data.HeaderValue.Total)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSurrogateKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("tags query responses with the keys of the entities", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithSurrogateKeys(&config.SurrogateKeysConfiguration{
+					Enabled:  true,
+					Header:   "Surrogate-Key",
+					MaxKeys:  256,
+					TypeKeys: true,
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employee(id: 1) { id details { forename } } }`,
+			})
+			require.Equal(t, `{"data":{"employee":{"id":1,"details":{"forename":"Jens"}}}}`, res.Body)
+			require.Equal(t, "Employee Employee:1", res.Response.Header.Get("Surrogate-Key"))
+
+			res = xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query { employee(id: 1) { details { forename } } }`,
+			})
+			require.Equal(t, "Employee", res.Response.Header.Get("Surrogate-Key"))
+		})
+	})
+
+	t.Run("purges the keys of mutated entities", func(t *testing.T) {
+		t.Parallel()
+
+		purges := make(chan []string, 1)
+		purgeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				SurrogateKeys []string `json:"surrogate_keys"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			purges <- body.SurrogateKeys
+		}))
+		defer purgeServer.Close()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithSurrogateKeys(&config.SurrogateKeysConfiguration{
+					Enabled:  true,
+					Header:   "Surrogate-Key",
+					MaxKeys:  256,
+					TypeKeys: true,
+					Purge: config.SurrogateKeyPurgeConfiguration{
+						Enabled: true,
+						Timeout: 5 * time.Second,
+						Endpoints: []config.SurrogateKeyPurgeEndpoint{
+							{URL: purgeServer.URL, Method: http.MethodPost},
+						},
+					},
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `mutation { updateEmployeeTag(id: 1, tag: "test") { id tag } }`,
+			})
+			require.Equal(t, `{"data":{"updateEmployeeTag":{"id":1,"tag":"test"}}}`, res.Body)
+			require.Empty(t, res.Response.Header.Get("Surrogate-Key"))
+
+			select {
+			case keys := <-purges:
+				require.Equal(t, []string{"Employee:1"}, keys)
+			case <-time.After(5 * time.Second):
+				t.Fatal("keys weren't purged")
+			}
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package integration_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestSyntheticHealthOperation(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+	metricReader := metric.NewManualReader()
+	var fail atomic.Bool
+	var probes atomic.Int64
+
+	testenv.Run(t, &testenv.Config{
+		MetricReader: metricReader,
+		RouterOptions: []core.Option{
+			core.WithLogger(zap.New(logCore)),
+			core.WithSyntheticHealthOperation(&config.SyntheticHealthOperationConfiguration{
+				Enabled:       true,
+				Interval:      50 * time.Millisecond,
+				Timeout:       5 * time.Second,
+				Query:         `query SyntheticHealth($id: Int!) { employee(id: $id) { id } }`,
+				OperationName: "SyntheticHealth",
+				Variables:     map[string]any{"id": 1},
+				ClientName:    "synthetic",
+			}),
+		},
+		Subgraphs: testenv.SubgraphsConfig{
+			Employees: testenv.SubgraphConfig{
+				Middleware: func(handler http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						probes.Add(1)
+						if fail.Load() {
+							w.WriteHeader(http.StatusInternalServerError)
+							return
+						}
+						handler.ServeHTTP(w, r)
+					})
+				},
+			},
+		},
+	}, func(t *testing.T, xEnv *testenv.Environment) {
+		collect := func() (up *metricdata.DataPoint[int64], latency *metricdata.DataPoint[float64]) {
+			rm := metricdata.ResourceMetrics{}
+			require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					switch m.Name {
+					case "router.synthetic.up":
+						up = &m.Data.(metricdata.Gauge[int64]).DataPoints[0]
+					case "router.synthetic.latency":
+						latency = &m.Data.(metricdata.Gauge[float64]).DataPoints[0]
+					}
+				}
+			}
+			return up, latency
+		}
+
+		// The operation runs through the subgraphs
+		require.Eventually(t, func() bool {
+			up, _ := collect()
+			return up != nil && up.Value == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Positive(t, probes.Load())
+
+		up, latency := collect()
+		operationName, _ := up.Attributes.Value(otel.WgOperationName)
+		require.Equal(t, "SyntheticHealth", operationName.AsString())
+		require.NotNil(t, latency)
+		require.Positive(t, latency.Value)
+
+		fail.Store(true)
+		require.Eventually(t, func() bool {
+			up, _ := collect()
+			return up.Value == 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		failures := logs.FilterMessage("Synthetic health operation failed")
+		require.Eventually(t, func() bool { return failures.Len() > 0 }, 5*time.Second, 10*time.Millisecond)
+		require.Equal(t, "SyntheticHealth", failures.All()[0].ContextMap()["operation_name"])
+		require.Contains(t, failures.All()[0].ContextMap()["error"], "the response has errors")
+
+		fail.Store(false)
+		require.Eventually(t, func() bool {
+			return logs.FilterMessage("Synthetic health operation recovered").Len() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		up, _ = collect()
+		require.Equal(t, int64(1), up.Value)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/stretchr/testify/require"
 	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/otel"
 	"github.com/wundergraph/cosmo/router/pkg/trace/tracetest"
@@ -2135,3 +2136,105 @@ Downstream errors:
 		})
 	})
 }
+
+func TestTelemetrySemanticConventions(t *testing.T) {
+	t.Parallel()
+
+	hasAttribute := func(attrs []attribute.KeyValue, key attribute.Key) bool {
+		for _, attr := range attrs {
+			if attr.Key == key {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("Stable attribute names replace the legacy ones", func(t *testing.T) {
+		t.Parallel()
+
+		metricReader := metric.NewManualReader()
+		exporter := tracetest.NewInMemoryExporter(t)
+
+		testenv.Run(t, &testenv.Config{
+			TraceExporter: exporter,
+			MetricReader:  metricReader,
+			RouterOptions: []core.Option{
+				core.WithSemanticConventions(otel.SemConvStable),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res := xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query MyQuery { employees { id } }`,
+			})
+			require.JSONEq(t, employeesIDData, res.Body)
+
+			var serverSpan sdktrace.ReadOnlySpan
+			for _, span := range exporter.GetSpans().Snapshots() {
+				if span.SpanKind() == trace.SpanKindServer {
+					serverSpan = span
+				}
+			}
+			require.NotNil(t, serverSpan)
+
+			attrs := serverSpan.Attributes()
+			require.Contains(t, attrs, attribute.Int("http.response.status_code", 200))
+			require.Contains(t, attrs, attribute.String("http.request.method", http.MethodPost))
+			require.Contains(t, attrs, attribute.String("url.path", "/graphql"))
+			require.Contains(t, attrs, attribute.String("graphql.operation.name", "MyQuery"))
+			require.Contains(t, attrs, attribute.String("graphql.operation.type", "query"))
+			require.False(t, hasAttribute(attrs, semconv.HTTPStatusCodeKey))
+			require.False(t, hasAttribute(attrs, otel.WgOperationName))
+
+			rm := metricdata.ResourceMetrics{}
+			require.NoError(t, metricReader.Collect(context.Background(), &rm))
+
+			var requests *metricdata.Sum[int64]
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if m.Name == "router.http.requests" {
+						sum := m.Data.(metricdata.Sum[int64])
+						requests = &sum
+					}
+				}
+			}
+			require.NotNil(t, requests)
+
+			statusCodes := 0
+			for _, dp := range requests.DataPoints {
+				if dp.Attributes.HasValue("http.response.status_code") {
+					statusCodes++
+				}
+				require.True(t, dp.Attributes.HasValue("graphql.operation.name"))
+				require.False(t, dp.Attributes.HasValue(semconv.HTTPStatusCodeKey))
+			}
+			require.Positive(t, statusCodes)
+		})
+	})
+
+	t.Run("Duplicate mode emits both attribute names", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := tracetest.NewInMemoryExporter(t)
+
+		testenv.Run(t, &testenv.Config{
+			TraceExporter: exporter,
+			RouterOptions: []core.Option{
+				core.WithSemanticConventions(otel.SemConvDuplicate),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			xEnv.MakeGraphQLRequestOK(testenv.GraphQLRequest{
+				Query: `query MyQuery { employees { id } }`,
+			})
+
+			for _, span := range exporter.GetSpans().Snapshots() {
+				if span.SpanKind() != trace.SpanKindServer {
+					continue
+				}
+				attrs := span.Attributes()
+				require.Contains(t, attrs, attribute.Int("http.response.status_code", 200))
+				require.Contains(t, attrs, semconv.HTTPStatusCode(200))
+				require.Contains(t, attrs, attribute.String("graphql.operation.name", "MyQuery"))
+				require.Contains(t, attrs, otel.WgOperationName.String("MyQuery"))
+			}
+		})
+	})
+}
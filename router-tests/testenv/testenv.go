@@ -692,7 +692,8 @@ func configureRouter(listenerAddr string, testConfig *Config, routerConfig *node
 					"x-custom-*",
 				},
 			},
-			ForwardInitialPayload: true,
+			ForwardInitialPayload:    true,
+			AllowQueriesAndMutations: true,
 		}
 		if testConfig.ModifyWebsocketConfiguration != nil {
 			testConfig.ModifyWebsocketConfiguration(wsConfig)
@@ -1299,7 +1300,7 @@ func subgraphOptions(ctx context.Context, t testing.TB, natsServer *natsserver.S
 		js, err := jetstream.New(natsConnection)
 		require.NoError(t, err)
 
-		natsPubSubByProviderID[sourceName] = pubsubNats.NewConnector(zap.NewNop(), natsConnection, js).New(ctx)
+		natsPubSubByProviderID[sourceName] = pubsubNats.NewConnector(zap.NewNop(), natsConnection, js, nil).New(ctx)
 	}
 
 	return &subgraphs.SubgraphOptions{
@@ -0,0 +1,67 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router-tests/testenv"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled by default", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := http.Get(xEnv.RouterURL + "/version")
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusNotFound, res.StatusCode)
+		})
+	})
+
+	t.Run("returns the version and the feature flags", func(t *testing.T) {
+		t.Parallel()
+
+		testenv.Run(t, &testenv.Config{
+			RouterOptions: []core.Option{
+				core.WithVersionEndpoint(&config.VersionEndpointConfiguration{
+					Enabled: true,
+					Path:    "/version",
+				}),
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			res, err := http.Get(xEnv.RouterURL + "/version")
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			var info core.VersionInfo
+			require.NoError(t, json.Unmarshal(body, &info))
+			require.Equal(t, core.Version, info.Version)
+			require.NotEmpty(t, info.GoVersion)
+			require.Contains(t, info.FeatureFlags, "myff")
+			require.NotNil(t, info.Features)
+			require.Empty(t, info.Dependencies)
+
+			res, err = http.Get(xEnv.RouterURL + "/version?dependencies=true")
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			info = core.VersionInfo{}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&info))
+			require.NotEmpty(t, info.Dependencies)
+		})
+	})
+}
@@ -66,6 +66,53 @@ func TestWebSockets(t *testing.T) {
 			xEnv.WaitForSubscriptionCount(0, time.Second*5)
 		})
 	})
+	t.Run("queries reuse the operation id", func(t *testing.T) {
+		t.Parallel()
+		testenv.Run(t, &testenv.Config{}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil, nil)
+			for i := 0; i < 2; i++ {
+				err := conn.WriteJSON(testenv.WebSocketMessage{
+					ID:      "1",
+					Type:    "subscribe",
+					Payload: []byte(`{"query":"{ employee(id: 1) { id } }"}`),
+				})
+				require.NoError(t, err)
+				var res testenv.WebSocketMessage
+				err = conn.ReadJSON(&res)
+				require.NoError(t, err)
+				require.Equal(t, "next", res.Type)
+				require.JSONEq(t, `{"data":{"employee":{"id":1}}}`, string(res.Payload))
+				var complete testenv.WebSocketMessage
+				err = conn.ReadJSON(&complete)
+				require.NoError(t, err)
+				require.Equal(t, "complete", complete.Type)
+				require.Equal(t, "1", complete.ID)
+			}
+		})
+	})
+	t.Run("queries and mutations disallowed", func(t *testing.T) {
+		t.Parallel()
+		testenv.Run(t, &testenv.Config{
+			ModifyWebsocketConfiguration: func(cfg *config.WebSocketConfiguration) {
+				cfg.AllowQueriesAndMutations = false
+			},
+		}, func(t *testing.T, xEnv *testenv.Environment) {
+			conn := xEnv.InitGraphQLWebSocketConnection(nil, nil, nil)
+			err := conn.WriteJSON(testenv.WebSocketMessage{
+				ID:      "1",
+				Type:    "subscribe",
+				Payload: []byte(`{"query":"{ employees { id } }"}`),
+			})
+			require.NoError(t, err)
+			var res testenv.WebSocketMessage
+			err = conn.ReadJSON(&res)
+			require.NoError(t, err)
+			require.Equal(t, "error", res.Type)
+			require.Equal(t, "1", res.ID)
+			require.JSONEq(t, `[{"message":"only subscriptions are allowed over WebSocket connections"}]`, string(res.Payload))
+			xEnv.WaitForSubscriptionCount(0, time.Second*5)
+		})
+	})
 	t.Run("query with authorization reject", func(t *testing.T) {
 		t.Parallel()
 
@@ -105,7 +152,7 @@ func TestWebSockets(t *testing.T) {
 			require.NoError(t, err)
 			require.Equal(t, "error", res.Type)
 			require.Equal(t, "1", res.ID)
-			require.Equal(t, `[{"message":"Unauthorized"}]`, string(res.Payload))
+			require.Equal(t, `[{"message":"Unauthorized","extensions":{"code":"UNAUTHORIZED"}}]`, string(res.Payload))
 			var complete testenv.WebSocketMessage
 			err = conn.ReadJSON(&complete)
 			require.NoError(t, err)
@@ -267,7 +314,7 @@ func TestWebSockets(t *testing.T) {
 			require.NoError(t, err)
 			require.Equal(t, "error", res.Type)
 			require.Equal(t, "1", res.ID)
-			require.Equal(t, `[{"message":"Unauthorized"}]`, string(res.Payload))
+			require.Equal(t, `[{"message":"Unauthorized","extensions":{"code":"UNAUTHORIZED"}}]`, string(res.Payload))
 
 			require.NoError(t, conn.Close())
 			xEnv.WaitForSubscriptionCount(0, time.Second*5)
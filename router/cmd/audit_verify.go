@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+// VerifyAuditLogs implements the audit-verify command. It checks the HMAC chain of audit log files, so that changed,
+// removed or inserted entries are detected. The key is read from AUDIT_LOG_HMAC_KEY, like by the router, unless it
+// is passed as flag.
+func VerifyAuditLogs(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("audit-verify", flag.ContinueOnError)
+	key := fs.String("hmac-key", os.Getenv("AUDIT_LOG_HMAC_KEY"), "the key of the HMAC chain of the audit log")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: router audit-verify [-hmac-key key] file ...")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no audit log file given")
+	}
+	if *key == "" {
+		return errors.New("the hmac key is required, set AUDIT_LOG_HMAC_KEY or pass -hmac-key")
+	}
+
+	for _, path := range fs.Args() {
+		verified, err := verifyAuditLogFile(path, []byte(*key))
+		if err != nil {
+			return fmt.Errorf("could not verify %s: %w", path, err)
+		}
+		_, _ = fmt.Fprintf(out, "%s: %d entries verified\n", path, verified)
+	}
+
+	return nil
+}
+
+func verifyAuditLogFile(path string, key []byte) (int, error) {
+	r, err := openLogFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	return logging.VerifyAuditLog(r, key)
+}
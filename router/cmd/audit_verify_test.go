@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+func TestVerifyAuditLogs(t *testing.T) {
+	t.Setenv("AUDIT_LOG_HMAC_KEY", "")
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := logging.NewAuditLogger(&logging.AuditOptions{Path: path, HMACKey: []byte("secret")})
+	require.NoError(t, err)
+	audit.ConfigReload("static", "v1", "", "abc", nil)
+	audit.ConfigReload("cdn", "v2", "v1", "def", nil)
+	require.NoError(t, audit.Close())
+
+	var out bytes.Buffer
+	require.NoError(t, VerifyAuditLogs([]string{"-hmac-key", "secret", path}, &out))
+	require.Equal(t, path+": 2 entries verified\n", out.String())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(data), `"cdn"`, `"static"`, 1)), 0o600))
+	err = VerifyAuditLogs([]string{"-hmac-key", "secret", path}, &out)
+	require.ErrorContains(t, err, "the hmac of the entry on line 2 doesn't match")
+
+	err = VerifyAuditLogs([]string{path}, &out)
+	require.EqualError(t, err, "the hmac key is required, set AUDIT_LOG_HMAC_KEY or pass -hmac-key")
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// ConfigCommand implements the config command. Its only subcommand is render, which prints the effective config
+// after merging the layered config files, the environment variables and the defaults. Secrets are redacted, so that
+// the output can be shared, e.g. to review the config of an environment.
+func ConfigCommand(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "render" {
+		return errors.New("usage: router config render [-config path[,path...]] [-config-profile profile] [-override-env file]")
+	}
+
+	fs := flag.NewFlagSet("config render", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_PATH"), "path to config file, or a comma separated list of files that are merged in order")
+	profile := fs.String("config-profile", os.Getenv("CONFIG_PROFILE"), "profile of the config overlay, e.g. production for config.production.yaml")
+	overrideEnv := fs.String("override-env", os.Getenv("OVERRIDE_ENV"), "env file name to override env variables")
+	showFiles := fs.Bool("files", false, "list the merged config files before the config")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	result, err := config.LoadConfigWithProfile(*configPath, *profile, *overrideEnv)
+	if err != nil {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+
+	if *showFiles {
+		for _, file := range result.Files {
+			if _, err := fmt.Fprintf(out, "# %s\n", file); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := redactConfig(&result.Config)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(data)
+	return err
+}
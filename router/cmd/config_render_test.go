@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestConfigRender(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(base, []byte("version: \"1\"\ngraph:\n  token: graph-token\nlisten_addr: localhost:3002\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.staging.yaml"), []byte("listen_addr: localhost:4000\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, ConfigCommand([]string{"render", "-config", base, "-config-profile", "staging", "-files"}, &out))
+
+	rendered := out.String()
+	require.True(t, strings.HasPrefix(rendered, "# "+base+"\n# "+filepath.Join(dir, "config.staging.yaml")+"\n"))
+	require.NotContains(t, rendered, "graph-token")
+
+	var cfg config.Config
+	require.NoError(t, yaml.Unmarshal(out.Bytes(), &cfg))
+	require.Equal(t, "localhost:4000", cfg.ListenAddr)
+	require.Equal(t, redactedValue, cfg.Graph.Token)
+	// The defaults are part of the effective config
+	require.Equal(t, "info", cfg.LogLevel)
+
+	require.Error(t, ConfigCommand(nil, &out))
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveConfigKey matches config keys whose values must never leave the host
+var sensitiveConfigKey = regexp.MustCompile(`(?i)(token|secret|password|passwd|sign_key|api_key|private_key|credential|authorization|cookie)`)
+
+type debugBundleOptions struct {
+	configPath  string
+	profile     string
+	overrideEnv string
+	output      string
+	adminAddr   string
+	adminToken  string
+	timeout     time.Duration
+}
+
+type debugBundleVersion struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	CreatedAt string `json:"created_at"`
+}
+
+// DebugBundle implements the debug-bundle command. It collects the redacted config, recent logs, a metrics snapshot,
+// goroutine and heap profiles and version information into a tar.gz archive that can be attached to support tickets.
+// Everything that requires the running router is collected through the admin API and the Prometheus endpoint.
+func DebugBundle(args []string) error {
+	now := time.Now().UTC()
+
+	opts := debugBundleOptions{}
+
+	fs := flag.NewFlagSet("debug-bundle", flag.ContinueOnError)
+	fs.StringVar(&opts.configPath, "config", os.Getenv("CONFIG_PATH"), "path to config file")
+	fs.StringVar(&opts.profile, "config-profile", os.Getenv("CONFIG_PROFILE"), "profile of the config overlay")
+	fs.StringVar(&opts.overrideEnv, "override-env", os.Getenv("OVERRIDE_ENV"), "env file name to override env variables")
+	fs.StringVar(&opts.output, "output", fmt.Sprintf("router-debug-bundle-%s.tar.gz", now.Format("20060102T150405Z")), "path of the created archive")
+	fs.StringVar(&opts.adminAddr, "admin-addr", "", "address of the admin API. Defaults to the address of the config")
+	fs.StringVar(&opts.adminToken, "admin-token", "", "token of the admin API. Defaults to the token of the config")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout for collecting the data from the running router")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	result, err := config.LoadConfigWithProfile(opts.configPath, opts.profile, opts.overrideEnv)
+	if err != nil {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+
+	cfg := &result.Config
+
+	if opts.adminAddr == "" && cfg.Admin.Enabled {
+		opts.adminAddr = cfg.Admin.ListenAddr
+	}
+	if opts.adminToken == "" {
+		opts.adminToken = cfg.Admin.Token
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	bundle, err := newDebugBundleWriter(opts.output, strings.TrimSuffix(filepath.Base(opts.output), ".tar.gz"))
+	if err != nil {
+		return err
+	}
+
+	var collectErrs []string
+
+	addFile := func(name string, collect func() ([]byte, error)) {
+		data, err := collect()
+		if err != nil {
+			collectErrs = append(collectErrs, fmt.Sprintf("%s: %s", name, err))
+			return
+		}
+		if err := bundle.add(name, data, now); err != nil {
+			collectErrs = append(collectErrs, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	addFile("version.json", func() ([]byte, error) {
+		return json.MarshalIndent(debugBundleVersion{
+			Version:   core.Version,
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			CreatedAt: now.Format(time.RFC3339),
+		}, "", "  ")
+	})
+
+	addFile("config.yaml", func() ([]byte, error) {
+		return redactConfig(cfg)
+	})
+
+	if opts.adminAddr != "" {
+		admin := &debugBundleClient{
+			baseURL: "http://" + opts.adminAddr,
+			token:   opts.adminToken,
+		}
+		addFile("router/info.json", func() ([]byte, error) { return admin.get(ctx, "/debug/info") })
+		addFile("router/logs.ndjson", func() ([]byte, error) { return admin.get(ctx, "/debug/logs") })
+		addFile("router/goroutine.pprof", func() ([]byte, error) { return admin.get(ctx, "/debug/pprof/goroutine") })
+		addFile("router/heap.pprof", func() ([]byte, error) { return admin.get(ctx, "/debug/pprof/heap?gc=1") })
+	} else {
+		collectErrs = append(collectErrs, "admin API is not enabled: logs, profiles and runtime information are not collected")
+	}
+
+	if cfg.Telemetry.Metrics.Prometheus.Enabled {
+		prometheus := &debugBundleClient{
+			baseURL: "http://" + cfg.Telemetry.Metrics.Prometheus.ListenAddr,
+		}
+		addFile("router/metrics.txt", func() ([]byte, error) {
+			return prometheus.get(ctx, cfg.Telemetry.Metrics.Prometheus.Path)
+		})
+	}
+
+	if len(collectErrs) > 0 {
+		if err := bundle.add("errors.txt", []byte(strings.Join(collectErrs, "\n")+"\n"), now); err != nil {
+			return errors.Join(err, bundle.close())
+		}
+	}
+
+	if err := bundle.close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Debug bundle written to %s\n", opts.output)
+	for _, collectErr := range collectErrs {
+		fmt.Fprintf(os.Stderr, "  incomplete: %s\n", collectErr)
+	}
+
+	return nil
+}
+
+// redactConfig renders the resolved config as YAML with all secrets replaced
+func redactConfig(cfg *config.Config) ([]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.MapSlice
+	if err := yaml.UnmarshalWithOptions(data, &doc, yaml.UseOrderedMap()); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(redactValue("", doc))
+}
+
+func redactValue(key string, value any) any {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		// Headers often carry credentials, therefore all of their values are redacted
+		redactAll := key == "headers"
+		for i := range v {
+			childKey := fmt.Sprint(v[i].Key)
+			if redactAll {
+				v[i].Value = redactString(v[i].Value)
+				continue
+			}
+			v[i].Value = redactValue(childKey, v[i].Value)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = redactValue(key, v[i])
+		}
+		return v
+	case string:
+		if sensitiveConfigKey.MatchString(key) {
+			return redactString(v)
+		}
+		return redactURL(v)
+	default:
+		return v
+	}
+}
+
+func redactString(value any) any {
+	if s, ok := value.(string); ok && s == "" {
+		return s
+	}
+	return redactedValue
+}
+
+// redactURL removes the password of URLs, e.g. of the Redis connection string
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
+
+type debugBundleClient struct {
+	baseURL string
+	token   string
+}
+
+func (c *debugBundleClient) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+type debugBundleWriter struct {
+	file *os.File
+	gz   *gzip.Writer
+	tw   *tar.Writer
+	root string
+}
+
+func newDebugBundleWriter(path, root string) (*debugBundleWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create debug bundle: %w", err)
+	}
+
+	gz := gzip.NewWriter(file)
+
+	return &debugBundleWriter{
+		file: file,
+		gz:   gz,
+		tw:   tar.NewWriter(gz),
+		root: root,
+	}, nil
+}
+
+func (w *debugBundleWriter) add(name string, data []byte, modTime time.Time) error {
+	if err := w.tw.WriteHeader(&tar.Header{
+		Name:    w.root + "/" + name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+
+	_, err := w.tw.Write(data)
+	return err
+}
+
+func (w *debugBundleWriter) close() error {
+	return errors.Join(w.tw.Close(), w.gz.Close(), w.file.Close())
+}
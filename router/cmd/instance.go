@@ -3,13 +3,16 @@ package cmd
 import (
 	"fmt"
 	"github.com/wundergraph/cosmo/router/pkg/execution_config"
+	"runtime/debug"
 
 	"github.com/wundergraph/cosmo/router/internal/cdn"
 	"github.com/wundergraph/cosmo/router/internal/controlplane/configpoller"
 	"github.com/wundergraph/cosmo/router/internal/controlplane/selfregister"
+	"github.com/wundergraph/cosmo/router/internal/localcomposition"
 	"github.com/wundergraph/cosmo/router/pkg/authentication"
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/cors"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
 	"go.uber.org/automaxprocs/maxprocs"
 
 	"github.com/wundergraph/cosmo/router/core"
@@ -21,6 +24,31 @@ import (
 type Params struct {
 	Config *config.Config
 	Logger *zap.Logger
+	// LogBuffer holds the recent log entries that are served by the admin API. Optional.
+	LogBuffer *logging.RingBuffer
+	// LogLevel is the level of the Logger that can be changed with the admin API. Optional.
+	LogLevel *zap.AtomicLevel
+	// LogRedactor redacts the entries of the Logger. It's also applied to the access logs. Optional.
+	LogRedactor *logging.Redactor
+	// LogFilter applies the filter rules to the entries of the Logger. Its rules can be replaced with the admin API.
+	// Optional.
+	LogFilter *logging.Filter
+	// LogIPAnonymizer anonymizes the client addresses in the entries of the Logger. It's also applied to the access
+	// logs. Optional.
+	LogIPAnonymizer *logging.IPAnonymizer
+	// LogRotator rotates the log files on demand. The file of the access logger is added to it. Optional.
+	LogRotator *logging.FileRotator
+	// LogEncoding is the built-in or registered encoding of the Logger. The access logger uses it when no encoding
+	// is configured for the access logs. If empty, the access logs are encoded as JSON. Optional.
+	LogEncoding string
+	// LogOutput is the asynchronous output of the Logger. Its dropped entries are exposed as metric. Optional.
+	LogOutput *logging.AsyncWriteSyncer
+	// LogStreams are the Kafka and NATS sinks of the Logger. Their entries that couldn't be published are exposed as
+	// metric. Optional.
+	LogStreams []*logging.StreamSink
+	// LogMetrics counts the entries of the Logger, the errors of its sinks and the rotations of its files. The
+	// entries of the access logger are counted too. Optional.
+	LogMetrics *core.LogMetrics
 }
 
 // NewRouter creates a new router instance.
@@ -33,6 +61,8 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 		return nil, fmt.Errorf("could not set max GOMAXPROCS: %w", err)
 	}
 
+	setMemoryLimits(params.Logger, &params.Config.Memory)
+
 	var routerConfig *nodev1.RouterConfig
 	var configPoller configpoller.ConfigPoller
 	var selfRegister selfregister.SelfRegister
@@ -40,10 +70,15 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 	cfg := params.Config
 	logger := params.Logger
 
-	if cfg.RouterConfigPath != "" {
+	if cfg.LocalComposition.Enabled {
+		configPoller, err = newLocalComposer(logger, &cfg.LocalComposition)
+		if err != nil {
+			return nil, err
+		}
+	} else if cfg.RouterConfigPath != "" {
 		routerConfig, err = execution_config.SerializeConfigFromFile(cfg.RouterConfigPath)
 		if err != nil {
-			logger.Fatal("Could not read router config", zap.Error(err), zap.String("path", cfg.RouterConfigPath))
+			return nil, fmt.Errorf("could not read router config %s: %w", cfg.RouterConfigPath, err)
 		}
 	} else if cfg.Graph.Token != "" {
 		routerCDN, err := cdn.NewRouterConfigClient(cfg.CDN.URL, cfg.Graph.Token, cdn.RouterConfigOptions{
@@ -80,10 +115,12 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 				HeaderNames:         auth.JWKS.HeaderNames,
 				HeaderValuePrefixes: auth.JWKS.HeaderValuePrefixes,
 				RefreshInterval:     auth.JWKS.RefreshInterval,
+				UnavailablePolicy:   authentication.UnavailablePolicy(auth.JWKS.UnavailablePolicy),
+				Logger:              logger,
 			}
 			authenticator, err := authentication.NewJWKSAuthenticator(opts)
 			if err != nil {
-				logger.Fatal("Could not create JWKS authenticator", zap.Error(err), zap.String("name", name))
+				return nil, fmt.Errorf("could not create JWKS authenticator %s: %w", name, err)
 			}
 			authenticators = append(authenticators, authenticator)
 		}
@@ -102,6 +139,8 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 		core.WithGraphQLPath(cfg.GraphQLPath),
 		core.WithModulesConfig(cfg.Modules),
 		core.WithGracePeriod(cfg.GracePeriod),
+		core.WithConfigDriftThreshold(cfg.ConfigDriftThreshold),
+		core.WithRouterConfigFallbackPath(cfg.RouterConfigFallbackPath),
 		core.WithPlaygroundPath(cfg.PlaygroundPath),
 		core.WithHealthCheckPath(cfg.HealthCheckPath),
 		core.WithLivenessCheckPath(cfg.LivenessCheckPath),
@@ -110,11 +149,14 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 			CollectorEndpoint: cfg.GraphqlMetrics.CollectorEndpoint,
 		}),
 		core.WithAnonymization(&core.IPAnonymizationConfig{
-			Enabled: cfg.Compliance.AnonymizeIP.Enabled,
-			Method:  core.IPAnonymizationMethod(cfg.Compliance.AnonymizeIP.Method),
+			Enabled:              cfg.Compliance.AnonymizeIP.Enabled,
+			Method:               core.IPAnonymizationMethod(cfg.Compliance.AnonymizeIP.Method),
+			SaltRotationInterval: cfg.Compliance.AnonymizeIP.SaltRotationInterval,
 		}),
+		core.WithPseudonymization(&cfg.Compliance.Pseudonymization),
 		core.WithClusterName(cfg.Cluster.Name),
 		core.WithInstanceID(cfg.InstanceID),
+		core.WithClusterPeers(&cfg.Cluster.Peers),
 		core.WithReadinessCheckPath(cfg.ReadinessCheckPath),
 		core.WithHeaderRules(cfg.Headers),
 		core.WithStaticRouterConfig(routerConfig),
@@ -154,6 +196,8 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 		core.WithDevelopmentMode(cfg.DevelopmentMode),
 		core.WithTracing(core.TraceConfigFromTelemetry(&cfg.Telemetry)),
 		core.WithMetrics(core.MetricConfigFromTelemetry(&cfg.Telemetry)),
+		core.WithProfiling(core.ProfilingConfigFromTelemetry(&cfg.Telemetry)),
+		core.WithSemanticConventions(core.SemConvStabilityFromTelemetry(&cfg.Telemetry)),
 		core.WithEngineExecutionConfig(cfg.EngineExecutionConfiguration),
 		core.WithSecurityConfig(cfg.SecurityConfiguration),
 		core.WithAuthorizationConfig(&cfg.Authorization),
@@ -164,9 +208,185 @@ func NewRouter(params Params, additionalOptions ...core.Option) (*core.Router, e
 		core.WithCDN(cfg.CDN),
 		core.WithEvents(cfg.Events),
 		core.WithRateLimitConfig(&cfg.RateLimit),
+		core.WithAdminServer(&core.AdminServerConfig{
+			Enabled:    cfg.Admin.Enabled,
+			ListenAddr: cfg.Admin.ListenAddr,
+			Token:      cfg.Admin.Token,
+			TLS: core.AdminTLSConfig{
+				Enabled:      cfg.Admin.TLS.Enabled,
+				CertFile:     cfg.Admin.TLS.CertFile,
+				KeyFile:      cfg.Admin.TLS.KeyFile,
+				ClientCAFile: cfg.Admin.TLS.ClientCAFile,
+			},
+			OIDC: core.AdminOIDCConfig{
+				Enabled:         cfg.Admin.OIDC.Enabled,
+				Issuer:          cfg.Admin.OIDC.Issuer,
+				JWKSURL:         cfg.Admin.OIDC.JWKSURL,
+				Audience:        cfg.Admin.OIDC.Audience,
+				RefreshInterval: cfg.Admin.OIDC.RefreshInterval,
+			},
+			LogBuffer: params.LogBuffer,
+			LogLevel:  params.LogLevel,
+			LogFilter: params.LogFilter,
+			Pprof: core.AdminPprofConfig{
+				Enabled:     cfg.Admin.Pprof.Enabled,
+				MaxDuration: cfg.Admin.Pprof.MaxDuration,
+			},
+		}),
+		core.WithMaintenance(&cfg.Maintenance),
+		core.WithStartupReport(&cfg.StartupReport),
+		core.WithMemorySoftLimit(&cfg.Memory.SoftLimit),
+		core.WithVersionEndpoint(&cfg.VersionEndpoint),
+		core.WithCustomResponseHeaders(&cfg.CustomResponseHeaders),
+		core.WithConnectionTimings(&cfg.ConnectionTimings),
+		core.WithProblemDetails(&cfg.ProblemDetails),
+		core.WithServerConfig(&cfg.Server),
+		core.WithAccessLogs(&cfg.AccessLogs),
+		core.WithLogEscalation(&cfg.LogEscalation),
+		core.WithPanicRecovery(&cfg.PanicRecovery),
+		core.WithSubscriptionLimits(&cfg.SubscriptionLimits),
+		core.WithSubscriptionReaping(&cfg.SubscriptionReaping),
+		core.WithSubscriptionBackpressure(&cfg.SubscriptionBackpressure),
+		core.WithDeprecationWarnings(&cfg.DeprecationWarnings),
+		core.WithClientProtocols(&cfg.ClientProtocols),
+		core.WithLogRetention(&cfg.LogRetention),
+		core.WithSLO(&cfg.SLO),
+		core.WithTopOperations(&cfg.TopOperations),
+		core.WithAnomalyDetection(&cfg.AnomalyDetection),
+		core.WithLifecycleWebhooks(&cfg.LifecycleWebhooks),
+		core.WithSubgraphAuthentication(cfg.SubgraphAuthentication),
+		core.WithRequestSigning(&cfg.RequestSigning),
+		core.WithBotDetection(&cfg.BotDetection),
+		core.WithOperationTimeouts(&cfg.OperationTimeouts),
+		core.WithFetchConcurrency(&cfg.FetchConcurrency),
+		core.WithEntityBatching(&cfg.EntityBatching),
+		core.WithSubgraphResponseValidation(&cfg.SubgraphResponseValidation),
+		core.WithResponseSizeLimit(&cfg.ResponseSizeLimit),
+		core.WithRequestMemoryLimit(&cfg.RequestMemoryLimit),
+		core.WithPersistedOperationUsage(&cfg.PersistedOperationUsage),
+		core.WithConfigAudit(&cfg.ConfigAudit),
+		core.WithAuditLog(&cfg.AuditLog),
+		core.WithExecutionAudit(&cfg.ExecutionAudit),
+		core.WithRESTEndpoints(&cfg.RESTEndpoints),
+		core.WithSubscriptionWebhooks(&cfg.SubscriptionWebhooks),
+		core.WithSurrogateKeys(&cfg.SurrogateKeys),
+		core.WithETags(&cfg.ETags),
+		core.WithPersistedOperationManifest(&cfg.PersistedOperationManifest),
+		core.WithRequestTags(&cfg.RequestTags),
+		core.WithSyntheticHealthOperation(&cfg.SyntheticHealthOperation),
+		core.WithChaos(&cfg.Chaos),
+		core.WithClockSkew(&cfg.ClockSkew),
+		core.WithCertificateExpiry(&cfg.CertificateExpiry),
+		core.WithSubgraphCompression(&cfg.SubgraphCompression),
+		core.WithSubgraphPayloadSize(&cfg.SubgraphPayloadSize),
+		core.WithSubgraphEndpoints(&cfg.SubgraphEndpoints),
+		core.WithAdaptiveConcurrency(&cfg.AdaptiveConcurrency),
+		core.WithResponseFormats(&cfg.ResponseFormats),
+		core.WithTenants(&cfg.Tenants),
+		core.WithRequestCoalescing(&cfg.RequestCoalescing),
+		core.WithSealedVariables(&cfg.SealedVariables),
+		core.WithNonGraphQLRequests(&cfg.NonGraphQLRequests),
+		core.WithContractEnforcement(&cfg.ContractEnforcement),
+		core.WithSlowSubgraphWarnings(&cfg.SlowSubgraphWarnings),
+		core.WithSubgraphTunnels(cfg.SubgraphTunnels),
+		core.WithLogShutdownFlush(&cfg.LogShutdownFlush),
+		core.WithSubgraphMocks(&cfg.SubgraphMocks),
+		core.WithVariableRedaction(&cfg.VariableRedaction),
+		core.WithOperationFingerprint(&cfg.OperationFingerprint),
+		core.WithConfigSignatureVerified(configPoller != nil && !cfg.LocalComposition.Enabled && cfg.Graph.SignKey != ""),
+	}
+
+	if cfg.AccessLogs.Logger.Enabled {
+		loggerCfg := &cfg.AccessLogs.Logger
+		encoding := loggerCfg.Encoding
+		if encoding == "" {
+			encoding = params.LogEncoding
+		}
+		accessLogger, err := logging.NewAccessLogger(&logging.AccessLoggerOptions{
+			Output:   loggerCfg.Output,
+			Encoding: encoding,
+			File: logging.FileOutput{
+				Path:       loggerCfg.File.Path,
+				MaxSize:    int64(loggerCfg.File.MaxSize),
+				MaxBackups: loggerCfg.File.MaxBackups,
+				MaxAge:     loggerCfg.File.MaxAge,
+				Compress:   loggerCfg.File.Compress,
+				LocalTime:  loggerCfg.File.LocalTime,
+			},
+			Fields:  loggerCfg.Fields,
+			Rotator: params.LogRotator,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create the access logger: %w", err)
+		}
+		if params.LogRedactor != nil {
+			accessLogger = accessLogger.WithOptions(logging.WithRedaction(params.LogRedactor))
+		}
+		if params.LogIPAnonymizer != nil {
+			accessLogger = accessLogger.WithOptions(logging.WithIPAnonymization(params.LogIPAnonymizer))
+		}
+		if params.LogMetrics != nil {
+			accessLogger = accessLogger.WithOptions(logging.WithMetrics(params.LogMetrics))
+		}
+		options = append(options, core.WithAccessLogger(accessLogger))
+	}
+
+	if params.LogRedactor != nil {
+		options = append(options, core.WithLogRedactor(params.LogRedactor))
+	}
+	if params.LogIPAnonymizer != nil {
+		options = append(options, core.WithLogIPAnonymizer(params.LogIPAnonymizer))
+	}
+
+	if params.LogOutput != nil {
+		options = append(options, core.WithLogDropCounter(cfg.LogOutput, params.LogOutput))
+	}
+	for _, stream := range params.LogStreams {
+		options = append(options, core.WithLogDropCounter(stream.Name(), stream))
+	}
+	if params.LogMetrics != nil {
+		options = append(options, core.WithLogMetrics(params.LogMetrics))
 	}
 
 	options = append(options, additionalOptions...)
 
 	return core.NewRouter(options...)
 }
+
+// setMemoryLimits applies the memory limit and the garbage collection target to the Go runtime.
+// Unset values keep the GOMEMLIMIT and GOGC environment variables in effect.
+// newLocalComposer composes the router config from the local subgraph schemas instead of the CDN
+func newLocalComposer(logger *zap.Logger, cfg *config.LocalCompositionConfiguration) (*localcomposition.Composer, error) {
+	subgraphs := make([]localcomposition.Subgraph, 0, len(cfg.Subgraphs))
+	for _, sg := range cfg.Subgraphs {
+		subgraphs = append(subgraphs, localcomposition.Subgraph{
+			Name:       sg.Name,
+			RoutingURL: sg.RoutingURL,
+			SchemaFile: sg.SchemaFile,
+		})
+	}
+
+	opts := []localcomposition.Option{
+		localcomposition.WithLogger(logger.With(zap.String("component", "local_composition"))),
+	}
+	if cfg.Watch {
+		opts = append(opts, localcomposition.WithWatchInterval(cfg.WatchInterval))
+	}
+
+	composer, err := localcomposition.New(subgraphs, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the local composition: %w", err)
+	}
+	return composer, nil
+}
+
+func setMemoryLimits(logger *zap.Logger, cfg *config.MemoryConfiguration) {
+	if cfg.Limit > 0 {
+		debug.SetMemoryLimit(int64(cfg.Limit.Uint64()))
+		logger.Debug("Memory limit set", zap.Uint64("limit_bytes", cfg.Limit.Uint64()))
+	}
+	if cfg.GCPercent != 0 {
+		debug.SetGCPercent(cfg.GCPercent)
+		logger.Debug("GC target percentage set", zap.Int("gc_percent", cfg.GCPercent))
+	}
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+// DecodeLogs implements the log-decode command. It converts log files with the msgpack encoding to newline
+// delimited JSON. Rotated files compressed with gzip are decompressed. Without files, stdin is decoded.
+func DecodeLogs(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("log-decode", flag.ContinueOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: router log-decode [file ...]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return logging.DecodeMsgpack(in, out)
+	}
+
+	for _, path := range fs.Args() {
+		if err := decodeLogFile(path, out); err != nil {
+			return fmt.Errorf("could not decode %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func decodeLogFile(path string, out io.Writer) error {
+	r, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return logging.DecodeMsgpack(r, out)
+}
+
+// openLogFile opens the log file. Rotated files compressed with gzip are decompressed.
+func openLogFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.f.Close())
+}
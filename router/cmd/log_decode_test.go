@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+func TestDecodeLogs(t *testing.T) {
+	buf, err := logging.NewMsgpackEncoder().EncodeEntry(
+		zapcore.Entry{Level: zapcore.InfoLevel, Time: time.UnixMilli(1700000000000), Message: "request"},
+		[]zap.Field{zap.Int("status", 200)},
+	)
+	require.NoError(t, err)
+	entry := buf.Bytes()
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "access.log")
+	require.NoError(t, os.WriteFile(plain, entry, 0o600))
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write(append(bytes.Clone(entry), entry...))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	rotated := filepath.Join(dir, "access-2024-01-01T00-00-00.000.log.gz")
+	require.NoError(t, os.WriteFile(rotated, compressed.Bytes(), 0o600))
+
+	const line = `{"level":"info","time":1700000000000,"msg":"request","status":200}` + "\n"
+
+	var out bytes.Buffer
+	require.NoError(t, DecodeLogs([]string{plain, rotated}, nil, &out))
+	require.Equal(t, line+line+line, out.String())
+
+	out.Reset()
+	require.NoError(t, DecodeLogs(nil, bytes.NewReader(entry), &out))
+	require.Equal(t, line, out.String())
+
+	require.Error(t, DecodeLogs([]string{filepath.Join(dir, "missing.log")}, nil, &out))
+}
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/wundergraph/cosmo/router/core"
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/logging"
@@ -11,30 +15,71 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/nats-io/nuid"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/wundergraph/cosmo/router/internal/profile"
-)
-
-var (
-	overrideEnvFlag = flag.String("override-env", os.Getenv("OVERRIDE_ENV"), "env file name to override env variables")
-	configPathFlag  = flag.String("config", os.Getenv("CONFIG_PATH"), "path to config file")
+	rtrace "github.com/wundergraph/cosmo/router/pkg/trace"
 )
 
 func Main() {
-	// Parse flags before calling profile.Start(), since it may add flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "audit-verify":
+			if err := VerifyAuditLogs(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "config":
+			if err := ConfigCommand(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "debug-bundle":
+			if err := DebugBundle(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "log-decode":
+			if err := DecodeLogs(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "replay":
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			err := ReplayOperations(ctx, os.Args[2:], os.Stdin, os.Stdout)
+			stop()
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "version":
+			if err := PrintVersion(os.Args[2:], os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	// The flags are registered here instead of on import, so that the router can be embedded in other applications
+	overrideEnvFlag := flag.String("override-env", os.Getenv("OVERRIDE_ENV"), "env file name to override env variables")
+	configPathFlag := flag.String("config", os.Getenv("CONFIG_PATH"), "path to config file, or a comma separated list of files that are merged in order")
+	profileFlag := flag.String("config-profile", os.Getenv("CONFIG_PROFILE"), "profile of the config overlay, e.g. production for config.production.yaml")
+	profile.RegisterFlags()
+
+	// Parse flags before calling profile.Start(), since it reads the profiling flags
 	flag.Parse()
 
 	profiler := profile.Start()
 
-	result, err := config.LoadConfig(*configPathFlag, *overrideEnvFlag)
+	result, err := config.LoadConfigWithProfile(*configPathFlag, *profileFlag, *overrideEnvFlag)
 	if err != nil {
 		log.Fatal("Could not load config", zap.Error(err))
 	}
 
-	// Handling shutdown
+	// Handling shutdown. SIGHUP toggles the debug level instead.
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
-		syscall.SIGHUP,  // process is detached from terminal
 		syscall.SIGTERM, // default for kill
 		syscall.SIGKILL,
 		syscall.SIGQUIT, // ctrl + \
@@ -42,16 +87,197 @@ func Main() {
 	)
 	defer stop()
 
+	// The instance ID identifies the router in the logs, the metrics and the traces, so the same one is used for all
+	if result.Config.InstanceID == "" {
+		result.Config.InstanceID = nuid.Next()
+	}
+
 	logLevel, err := logging.ZapLogLevelFromString(result.Config.LogLevel)
 	if err != nil {
 		log.Fatal("Could not parse log level", zap.Error(err))
 	}
 
-	logger := logging.New(!result.Config.JSONLog, result.Config.LogLevel == "debug", logLevel).
-		With(
-			zap.String("component", "@wundergraph/router"),
-			zap.String("service_version", core.Version),
-		)
+	// The formats apply to all encoders, so they are set before the first logger is created
+	if err := logging.SetEncoderFormats(result.Config.LogTimeFormat, result.Config.LogDurationFormat); err != nil {
+		log.Fatal("Could not set the log formats", zap.Error(err))
+	}
+	if err := logging.SetPrettyOptions(result.Config.LogPretty.Theme, result.Config.LogPretty.FieldFormat); err != nil {
+		log.Fatal("Could not set the pretty log options", zap.Error(err))
+	}
+
+	// The level can be changed at runtime with the admin API and SIGHUP
+	atomicLevel := zap.NewAtomicLevelAt(logLevel)
+
+	// The outputs must enable the entries of the subgraphs whose level is lower than the level of the router
+	subgraphLevels, err := subgraphLogLevels(result.Config.LogSubgraphLevels)
+	if err != nil {
+		log.Fatal("Could not parse the log levels of the subgraphs", zap.Error(err))
+	}
+	outputLevel := subgraphLevels.OutputLevel(atomicLevel)
+
+	// The logs of the router can be written to stderr, to separate them from the access logs on stdout
+	stdout, err := logging.StandardOutput(result.Config.LogOutput)
+	if err != nil {
+		log.Fatal("Could not create the log output", zap.Error(err))
+	}
+	var bufferedStdout *zapcore.BufferedWriteSyncer
+	if result.Config.LogBuffering.Enabled {
+		bufferedStdout = logging.NewBufferedOutput(stdout, int(result.Config.LogBuffering.Size), result.Config.LogBuffering.FlushInterval)
+		stdout = bufferedStdout
+	}
+	// The asynchronous output keeps a slow stdout from stalling the requests
+	var asyncStdout *logging.AsyncWriteSyncer
+	if result.Config.LogAsync.Enabled {
+		asyncStdout, err = logging.NewAsyncWriteSyncer(stdout, &logging.AsyncOptions{
+			Size:          result.Config.LogAsync.Size,
+			FlushInterval: result.Config.LogAsync.FlushInterval,
+			Policy:        result.Config.LogAsync.Policy,
+		})
+		if err != nil {
+			log.Fatal("Could not create the asynchronous log output", zap.Error(err))
+		}
+		stdout = asyncStdout
+	}
+
+	// The log volume, the errors of the sinks and the rotations are exposed with the metrics of the router
+	logMetrics := core.NewLogMetrics()
+
+	// The log files are rotated on SIGUSR1, e.g. by logrotate after it moved them
+	logRotator := logging.NewFileRotator(logMetrics)
+
+	var logger *zap.Logger
+	if result.Config.LogFiles.Enabled || len(result.Config.LogLevelOutputs) > 0 {
+		outputs := &logging.FileOutputs{}
+		if result.Config.LogFiles.Enabled {
+			outputs = logFileOutputs(&result.Config.LogFiles)
+		}
+		if outputs.Levels, err = logLevelOutputs(result.Config.LogLevelOutputs); err != nil {
+			log.Fatal("Could not create the level outputs of the logs", zap.Error(err))
+		}
+		outputs.Stdout = stdout
+		outputs.Rotator = logRotator
+		outputs.Encoding = result.Config.LogEncoding
+		logger, err = logging.NewWithFileOutputs(!result.Config.JSONLog, result.Config.LogLevel == "debug", outputLevel, outputs)
+		if err != nil {
+			log.Fatal("Could not create the log files", zap.Error(err))
+		}
+	} else if result.Config.LogEncoding != "" {
+		logger, err = logging.NewWithEncoding(stdout, result.Config.LogEncoding, result.Config.LogLevel == "debug", outputLevel)
+		if err != nil {
+			log.Fatal("Could not create the logger", zap.Error(err))
+		}
+	} else {
+		logger = logging.NewWithOutput(stdout, !result.Config.JSONLog, result.Config.LogLevel == "debug", outputLevel)
+	}
+
+	if bufferedStdout != nil && asyncStdout == nil {
+		// Warnings and errors are written immediately
+		logger = logger.WithOptions(logging.WithFlushOnLevel(zap.WarnLevel))
+	}
+
+	var otlpLogs *logging.OTLPExporter
+	if result.Config.Telemetry.Logs.Enabled {
+		otlpLogs, err = newOTLPLogExporter(ctx, &result.Config, logger)
+		if err != nil {
+			log.Fatal("Could not create the OpenTelemetry log exporter", zap.Error(err))
+		}
+		logger = logger.WithOptions(logging.WithOTLP(otlpLogs, outputLevel))
+	}
+
+	// The entries that can't be published to Kafka or NATS are written to the log output instead
+	logSinks, logStreams, err := newLogSinks(&result.Config.LogSinks, outputLevel, stdout, logMetrics)
+	if err != nil {
+		log.Fatal("Could not create the log sinks", zap.Error(err))
+	}
+	if len(logSinks) > 0 {
+		logger = logger.WithOptions(logging.WithSinks(logSinks...))
+	}
+
+	// Keep the recent log entries in memory so that they can be collected with the debug bundle
+	var logBuffer *logging.RingBuffer
+	if result.Config.Admin.Enabled {
+		logBuffer = logging.NewRingBuffer(result.Config.Admin.LogBufferSize)
+		logger = logger.WithOptions(logging.WithRingBuffer(logBuffer, outputLevel))
+	}
+
+	if result.Config.JSONLog && result.Config.JSONLogStacktraceFrames {
+		logger = logger.WithOptions(logging.WithStacktraceFrames())
+	}
+
+	// The summaries of the deduplication must not be sampled, so the sampling wraps it
+	if result.Config.LogDeduplication.Enabled {
+		levels, err := logLevelSet(result.Config.LogDeduplication.Levels)
+		if err != nil {
+			log.Fatal("Could not create the log deduplication", zap.Error(err))
+		}
+		logger = logger.WithOptions(logging.WithDeduplication(&logging.DeduplicationOptions{
+			Window:  result.Config.LogDeduplication.Window,
+			MaxKeys: result.Config.LogDeduplication.MaxKeys,
+			Levels:  levels,
+		}))
+	}
+
+	if result.Config.LogSampling.Enabled {
+		levels, err := logLevelSet(result.Config.LogSampling.Levels)
+		if err != nil {
+			log.Fatal("Could not create the log sampling", zap.Error(err))
+		}
+		logger = logger.WithOptions(logging.WithSampling(&logging.SamplingOptions{
+			Tick:       result.Config.LogSampling.Tick,
+			Initial:    result.Config.LogSampling.Initial,
+			Thereafter: result.Config.LogSampling.Thereafter,
+			Levels:     levels,
+		}))
+	}
+
+	// The redaction wraps all outputs of the logger, so it's applied last
+	// The default rules are applied in production, but not while debugging
+	logRedactor, err := newLogRedactor(&result.Config.LogRedaction, result.Config.LogLevel == "debug" || result.Config.DevelopmentMode)
+	if err != nil {
+		log.Fatal("Could not create the log redaction", zap.Error(err))
+	}
+	if logRedactor != nil {
+		logger = logger.WithOptions(logging.WithRedaction(logRedactor))
+	}
+
+	var logIPAnonymizer *logging.IPAnonymizer
+	if result.Config.Compliance.AnonymizeIP.Enabled {
+		logIPAnonymizer, err = logging.NewIPAnonymizer(&logging.IPAnonymizationOptions{
+			Mode:                 logging.IPAnonymizationMode(result.Config.Compliance.AnonymizeIP.Method),
+			SaltRotationInterval: result.Config.Compliance.AnonymizeIP.SaltRotationInterval,
+			Fields:               result.Config.Compliance.AnonymizeIP.Fields,
+		})
+		if err != nil {
+			log.Fatal("Could not create the log ip anonymization", zap.Error(err))
+		}
+		logger = logger.WithOptions(logging.WithIPAnonymization(logIPAnonymizer))
+	}
+
+	// The filter rules match the entries before their redaction. They can be replaced with the admin API.
+	logFilter, err := newLogFilter(&result.Config.LogFilters)
+	if err != nil {
+		log.Fatal("Could not create the log filter", zap.Error(err))
+	}
+	logger = logger.WithOptions(logging.WithFilter(logFilter))
+
+	// The overrides of the subgraphs filter the entries before all other cores
+	logger = logger.WithOptions(logging.WithSubgraphLevels(atomicLevel, subgraphLevels))
+
+	// Only the entries that passed all filters are counted
+	logger = logger.WithOptions(logging.WithMetrics(logMetrics))
+
+	identity := []zap.Field{
+		zap.String("component", "@wundergraph/router"),
+		zap.String("service_version", core.Version),
+		zap.String("instance_id", result.Config.InstanceID),
+	}
+	if result.Config.Cluster.Name != "" {
+		identity = append(identity, zap.String("cluster_name", result.Config.Cluster.Name))
+	}
+	logger = logger.With(identity...)
+
+	logging.ToggleDebugLevelOnSignal(ctx, logger, &atomicLevel, syscall.SIGHUP)
+	logging.RotateOnSignal(ctx, logger, logRotator)
 
 	if *configPathFlag != "" {
 		logger.Info(
@@ -65,8 +291,18 @@ func Main() {
 	}
 
 	router, err := NewRouter(Params{
-		Config: &result.Config,
-		Logger: logger,
+		Config:          &result.Config,
+		Logger:          logger,
+		LogBuffer:       logBuffer,
+		LogLevel:        &atomicLevel,
+		LogRedactor:     logRedactor,
+		LogFilter:       logFilter,
+		LogIPAnonymizer: logIPAnonymizer,
+		LogRotator:      logRotator,
+		LogEncoding:     result.Config.LogEncoding,
+		LogOutput:       asyncStdout,
+		LogStreams:      logStreams,
+		LogMetrics:      logMetrics,
 	})
 
 	if err != nil {
@@ -102,5 +338,371 @@ func Main() {
 	profiler.Finish()
 
 	logger.Debug("Server exiting")
+
+	if asyncStdout != nil {
+		if dropped := asyncStdout.Dropped(); dropped > 0 {
+			logger.Warn("Log entries were dropped because the asynchronous log output was full", zap.Int64("dropped", dropped))
+		}
+	}
+
+	// The flush has its own deadline because the shutdown delay can be used up by the draining requests
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), result.Config.LogShutdownFlush.Timeout)
+	defer cancelFlush()
+
+	logFlush := newLogFlushBarrier(otlpLogs, logSinks, stdout, asyncStdout != nil || bufferedStdout != nil)
+	var flushMarker *zap.Logger
+	if result.Config.LogShutdownFlush.Marker {
+		flushMarker = logger
+	}
+	logFlush.Run(flushCtx, flushMarker)
+
+	if otlpLogs != nil {
+		if err := otlpLogs.Shutdown(flushCtx); err != nil {
+			logger.Error("Could not export the remaining logs", zap.Error(err))
+		}
+	}
+
+	// Publish the remaining entries to Kafka and NATS before the log output is stopped
+	for _, sink := range logSinks {
+		_ = sink.Close()
+	}
+
+	if asyncStdout != nil {
+		// Write the remaining entries before exiting
+		_ = asyncStdout.Stop()
+	}
+
+	if bufferedStdout != nil {
+		// Flush the remaining entries before exiting
+		_ = bufferedStdout.Stop()
+	}
+
 	os.Exit(0)
 }
+
+// newLogFlushBarrier flushes the outputs of the router logs that buffer their entries on shutdown
+func newLogFlushBarrier(otlpLogs *logging.OTLPExporter, sinks []*logging.SinkOutput, stdout zapcore.WriteSyncer, stdoutBuffered bool) *logging.FlushBarrier {
+	barrier := &logging.FlushBarrier{}
+	if otlpLogs != nil {
+		barrier.Add("otlp", otlpLogs.Flush)
+	}
+	for _, sink := range sinks {
+		sink := sink
+		barrier.Add(sink.Name(), func(context.Context) error {
+			return sink.Sync()
+		})
+	}
+	if stdoutBuffered {
+		barrier.Add("stdout", func(context.Context) error {
+			// The buffered entries are written before the output is synced. Like zap, the error of the sync is
+			// ignored because stdout can't be synced when it is a pipe.
+			_ = stdout.Sync()
+			return nil
+		})
+	}
+	return barrier
+}
+
+// newOTLPLogExporter creates the exporter of the logs with the resource of the traces. The export errors are logged
+// with the logger, so it must not export its own entries.
+func newOTLPLogExporter(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*logging.OTLPExporter, error) {
+	res, err := rtrace.NewResource(ctx, core.TraceConfigFromTelemetry(&cfg.Telemetry), cfg.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	logsCfg := &cfg.Telemetry.Logs
+	return logging.NewOTLPExporter(&logging.OTLPOptions{
+		Exporter:      logsCfg.Exporter,
+		Endpoint:      logsCfg.Endpoint,
+		HTTPPath:      logsCfg.HTTPPath,
+		Headers:       logsCfg.Headers,
+		Resource:      res,
+		BatchSize:     logsCfg.BatchSize,
+		QueueSize:     logsCfg.QueueSize,
+		BatchTimeout:  logsCfg.BatchTimeout,
+		ExportTimeout: logsCfg.ExportTimeout,
+		OnError: func(err error) {
+			logger.Warn("Failed to export the logs to OpenTelemetry", zap.Error(err))
+		},
+	})
+}
+
+// newLogSinks creates the enabled sinks of journald, syslog, Kafka and NATS. Their entries follow the level of the
+// router. The Kafka and NATS sinks are returned as streams too.
+func newLogSinks(cfg *config.LogSinksConfiguration, level zapcore.LevelEnabler, fallback zapcore.WriteSyncer, metrics logging.Metrics) ([]*logging.SinkOutput, []*logging.StreamSink, error) {
+	var outputs []*logging.SinkOutput
+	var streams []*logging.StreamSink
+
+	if cfg.Journald.Enabled {
+		sink, err := logging.NewJournaldSink(&logging.JournaldOptions{Identifier: cfg.Journald.Identifier})
+		if err != nil {
+			return nil, nil, err
+		}
+		output, err := logging.NewSinkOutput(sink, cfg.Journald.Encoding, level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid journald sink: %w", err)
+		}
+		outputs = append(outputs, output.WithMetrics("journald", metrics))
+	}
+
+	if cfg.Syslog.Enabled {
+		var tlsConfig *tls.Config
+		if cfg.Syslog.Network == logging.SyslogNetworkTLS {
+			var err error
+			if tlsConfig, err = newSyslogTLSConfig(&cfg.Syslog.TLS); err != nil {
+				return nil, nil, err
+			}
+		}
+		sink, err := logging.NewSyslogSink(&logging.SyslogOptions{
+			Network:   cfg.Syslog.Network,
+			Address:   cfg.Syslog.Address,
+			TLSConfig: tlsConfig,
+			Facility:  cfg.Syslog.Facility,
+			AppName:   cfg.Syslog.AppName,
+			Timeout:   cfg.Syslog.Timeout,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		output, err := logging.NewSinkOutput(sink, cfg.Syslog.Encoding, level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid syslog sink: %w", err)
+		}
+		outputs = append(outputs, output.WithMetrics("syslog", metrics))
+	}
+
+	if cfg.Kafka.Enabled {
+		opts := &logging.KafkaSinkOptions{
+			Brokers:     cfg.Kafka.Brokers,
+			Topic:       cfg.Kafka.Topic,
+			Compression: cfg.Kafka.Compression,
+			Stream: logging.StreamOptions{
+				BatchSize:     cfg.Kafka.BatchSize,
+				FlushInterval: cfg.Kafka.FlushInterval,
+				Timeout:       cfg.Kafka.Timeout,
+				Fallback:      fallback,
+				Metrics:       metrics,
+			},
+		}
+		if cfg.Kafka.TLS != nil && cfg.Kafka.TLS.Enabled {
+			opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if auth := cfg.Kafka.Authentication; auth != nil && auth.SASLPlain.Username != nil && auth.SASLPlain.Password != nil {
+			opts.Username = *auth.SASLPlain.Username
+			opts.Password = *auth.SASLPlain.Password
+		}
+		sink, err := logging.NewKafkaSink(opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		output, err := logging.NewSinkOutput(sink, cfg.Kafka.Encoding, level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid kafka sink: %w", err)
+		}
+		outputs = append(outputs, output.WithMetrics("kafka", metrics))
+		streams = append(streams, sink)
+	}
+
+	if cfg.Nats.Enabled {
+		opts := &logging.NatsSinkOptions{
+			URL:         cfg.Nats.URL,
+			Subject:     cfg.Nats.Subject,
+			Token:       cfg.Nats.Token,
+			Username:    cfg.Nats.Username,
+			Password:    cfg.Nats.Password,
+			Compression: cfg.Nats.Compression,
+			Stream: logging.StreamOptions{
+				BatchSize:     cfg.Nats.BatchSize,
+				FlushInterval: cfg.Nats.FlushInterval,
+				Timeout:       cfg.Nats.Timeout,
+				Fallback:      fallback,
+				Metrics:       metrics,
+			},
+		}
+		sink, err := logging.NewNatsSink(opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect the nats sink: %w", err)
+		}
+		output, err := logging.NewSinkOutput(sink, cfg.Nats.Encoding, level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid nats sink: %w", err)
+		}
+		outputs = append(outputs, output.WithMetrics("nats", metrics))
+		streams = append(streams, sink)
+	}
+
+	return outputs, streams, nil
+}
+
+func newSyslogTLSConfig(cfg *config.SyslogTLSConfiguration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CAFile != "" {
+		caCert, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA of the syslog server: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if ok := tlsConfig.RootCAs.AppendCertsFromPEM(caCert); !ok {
+			return nil, errors.New("failed to append the CA of the syslog server")
+		}
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate of the syslog sink: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// newLogRedactor creates the redactor of the configured rules and the default rules. It returns nil when no rule
+// applies. In auto mode, the default rules are applied unless debugging.
+func newLogRedactor(cfg *config.LogRedactionConfiguration, debugging bool) (*logging.Redactor, error) {
+	var rules []logging.RedactionRule
+
+	switch cfg.DefaultRules {
+	case "", "auto":
+		if !debugging {
+			rules = logging.DefaultRedactionRules(cfg.ExcludeDefaultFields)
+		}
+	case "enabled":
+		rules = logging.DefaultRedactionRules(cfg.ExcludeDefaultFields)
+	case "disabled":
+	default:
+		return nil, fmt.Errorf("unknown default redaction rules '%s', must be auto, enabled or disabled", cfg.DefaultRules)
+	}
+
+	if cfg.Enabled {
+		for _, rule := range cfg.Rules {
+			rules = append(rules, logging.RedactionRule{
+				Field:   rule.Field,
+				Path:    rule.Path,
+				Pattern: rule.Pattern,
+				Action:  rule.Action,
+			})
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	return logging.NewRedactor(&logging.RedactionOptions{
+		Replacement: cfg.Replacement,
+		Rules:       rules,
+	})
+}
+
+func newLogFilter(cfg *config.LogFiltersConfiguration) (*logging.Filter, error) {
+	rules := make([]logging.FilterRule, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		rules = append(rules, logging.FilterRule{
+			Logger:  rule.Logger,
+			Message: rule.Message,
+			Field:   rule.Field,
+			Value:   rule.Value,
+			Action:  rule.Action,
+			Level:   rule.Level,
+			Fields:  rule.Fields,
+		})
+	}
+
+	return logging.NewFilter(rules)
+}
+
+func toFileOutput(file *config.LogFileConfiguration) logging.FileOutput {
+	return logging.FileOutput{
+		Path:       file.Path,
+		Encoding:   file.Encoding,
+		Level:      file.Level,
+		MaxSize:    int64(file.MaxSize),
+		MaxBackups: file.MaxBackups,
+		MaxAge:     file.MaxAge,
+		Compress:   file.Compress,
+		LocalTime:  file.LocalTime,
+	}
+}
+
+func logFileOutputs(cfg *config.LogFilesConfiguration) *logging.FileOutputs {
+	outputs := &logging.FileOutputs{}
+	if cfg.Default.Path != "" {
+		defaultFile := toFileOutput(&cfg.Default)
+		outputs.Default = &defaultFile
+	}
+	for i := range cfg.Loggers {
+		outputs.Loggers = append(outputs.Loggers, logging.LoggerFileOutput{
+			LoggerName: cfg.Loggers[i].Name,
+			File:       toFileOutput(&cfg.Loggers[i].LogFileConfiguration),
+		})
+	}
+
+	return outputs
+}
+
+// logLevelSet converts the names of the levels. Without names, all levels are enabled.
+func logLevelSet(names []string) (zapcore.LevelEnabler, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	levels := make([]zapcore.Level, 0, len(names))
+	for _, name := range names {
+		level, err := logging.ZapLogLevelFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return logging.LevelSet(levels...), nil
+}
+
+// subgraphLogLevels parses the level overrides of the subgraphs
+func subgraphLogLevels(cfg map[string]string) (logging.SubgraphLevels, error) {
+	levels := make(logging.SubgraphLevels, len(cfg))
+	for subgraph, name := range cfg {
+		level, err := logging.ZapLogLevelFromString(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level of subgraph '%s': %w", subgraph, err)
+		}
+		levels[subgraph] = level
+	}
+	return levels, nil
+}
+
+// logLevelOutputs converts the level outputs of the config. An open range is bounded by the lowest or highest level.
+func logLevelOutputs(cfg []config.LogLevelOutput) ([]logging.LevelOutput, error) {
+	outputs := make([]logging.LevelOutput, 0, len(cfg))
+	for i := range cfg {
+		minLevel, maxLevel := zapcore.DebugLevel, zapcore.FatalLevel
+		var err error
+		if cfg[i].MinLevel != "" {
+			if minLevel, err = logging.ZapLogLevelFromString(cfg[i].MinLevel); err != nil {
+				return nil, err
+			}
+		}
+		if cfg[i].MaxLevel != "" {
+			if maxLevel, err = logging.ZapLogLevelFromString(cfg[i].MaxLevel); err != nil {
+				return nil, err
+			}
+		}
+		if minLevel > maxLevel {
+			return nil, fmt.Errorf("the min level '%s' is higher than the max level '%s'", cfg[i].MinLevel, cfg[i].MaxLevel)
+		}
+
+		output := logging.LevelOutput{
+			Levels:   logging.LevelRange(minLevel, maxLevel),
+			Stream:   cfg[i].Stream,
+			Encoding: cfg[i].Encoding,
+		}
+		if cfg[i].Path != "" {
+			file := toFileOutput(&cfg[i].LogFileConfiguration)
+			output.File = &file
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+// maxReplayEntrySize limits the size of an access log entry, which contains the whole operation
+const maxReplayEntrySize = 16 << 20
+
+type replayOptions struct {
+	target      string
+	speed       float64
+	concurrency int
+	mutations   bool
+	timeout     time.Duration
+	headers     headerFlags
+}
+
+// headerFlags collects the repeated header flags
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	name, _, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header '%s' must have the format 'Name: value'", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// replayEntry contains the fields of an access log entry that are logged with access_logs.operations
+type replayEntry struct {
+	Time      int64  `json:"time"`
+	Name      string `json:"operation_name"`
+	Type      string `json:"operation_type"`
+	Content   string `json:"operation_content"`
+	Variables string `json:"operation_variables"`
+}
+
+type replayRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+}
+
+// ReplayOperations implements the replay command. It sends the operations of the access logs, logged with
+// access_logs.operations enabled, to the target at the pacing of the original requests, e.g. to load a staging
+// environment with realistic traffic. The access logs can be encoded as JSON or msgpack, and rotated files
+// compressed with gzip are decompressed. Without files, stdin is read. Subscriptions are never replayed.
+func ReplayOperations(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	opts := replayOptions{}
+
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.StringVar(&opts.target, "target", "", "URL of the GraphQL endpoint that receives the operations")
+	fs.Float64Var(&opts.speed, "speed", 1, "factor of the original pacing, e.g. 2 sends the operations twice as fast. 0 sends them without delay")
+	fs.IntVar(&opts.concurrency, "concurrency", 16, "maximum number of concurrent requests")
+	fs.BoolVar(&opts.mutations, "mutations", false, "replay the mutations in addition to the queries")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout of a request")
+	fs.Var(&opts.headers, "header", "header of the requests as 'Name: value'. Can be repeated")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintln(fs.Output(), "Usage: router replay -target <url> [flags] [file ...]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	target, err := url.Parse(opts.target)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("the target '%s' must be an http or https URL", opts.target)
+	}
+	if opts.speed < 0 {
+		return errors.New("the speed must not be negative")
+	}
+	if opts.concurrency <= 0 {
+		return errors.New("the concurrency must be greater than zero")
+	}
+
+	r := &replayer{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.timeout},
+		sem:    make(chan struct{}, opts.concurrency),
+	}
+
+	start := time.Now()
+	if fs.NArg() == 0 {
+		err = r.replay(ctx, in)
+	} else {
+		for _, path := range fs.Args() {
+			if err = r.replayFile(ctx, path); err != nil {
+				break
+			}
+		}
+	}
+	r.wg.Wait()
+
+	_, _ = fmt.Fprintf(out, "replayed %d operations in %s: %d failed, %d skipped\n",
+		r.sent.Load(), time.Since(start).Round(time.Millisecond), r.failed.Load(), r.skipped)
+	if r.failed.Load() > 0 {
+		_, _ = fmt.Fprintf(out, "first failure: %s\n", r.firstFailure.Load())
+	}
+
+	// Interrupting the replay is not an error
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+type replayer struct {
+	opts   replayOptions
+	client *http.Client
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	// The pacing is relative to the first replayed entry, also across files
+	started    bool
+	firstEntry int64
+	startTime  time.Time
+
+	sent         atomic.Int64
+	failed       atomic.Int64
+	firstFailure atomic.Value
+	skipped      int64
+}
+
+func (r *replayer) replayFile(ctx context.Context, path string) error {
+	f, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := r.replay(ctx, f); err != nil {
+		return fmt.Errorf("could not replay %s: %w", path, err)
+	}
+	return nil
+}
+
+func (r *replayer) replay(ctx context.Context, in io.Reader) error {
+	entries := ndjsonLogReader(in)
+	defer entries.Close()
+
+	scanner := bufio.NewScanner(entries)
+	scanner.Buffer(make([]byte, 64<<10), maxReplayEntrySize)
+
+	for scanner.Scan() {
+		var entry replayEntry
+		// Lines that are no JSON entries, e.g. of other outputs, are ignored like entries without an operation
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Content == "" {
+			continue
+		}
+
+		switch entry.Type {
+		case "query":
+		case "mutation":
+			if !r.opts.mutations {
+				r.skipped++
+				continue
+			}
+		default:
+			r.skipped++
+			continue
+		}
+
+		if err := r.pace(ctx, entry.Time); err != nil {
+			return err
+		}
+		if err := r.send(ctx, &entry); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// pace waits until the entry is due relative to the first entry
+func (r *replayer) pace(ctx context.Context, entryTime int64) error {
+	if r.opts.speed == 0 {
+		return nil
+	}
+	if !r.started {
+		r.started = true
+		r.firstEntry = entryTime
+		r.startTime = time.Now()
+		return nil
+	}
+
+	offset := time.Duration(entryTime-r.firstEntry) * time.Millisecond
+	delay := time.Duration(float64(offset)/r.opts.speed) - time.Since(r.startTime)
+	// Entries are written when the requests are done, so they are not strictly ordered
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (r *replayer) send(ctx context.Context, entry *replayEntry) error {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	r.sent.Add(1)
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			<-r.sem
+			r.wg.Done()
+		}()
+
+		if err := r.do(ctx, entry); err != nil {
+			if r.failed.Add(1) == 1 {
+				r.firstFailure.Store(err.Error())
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (r *replayer) do(ctx context.Context, entry *replayEntry) error {
+	body := replayRequest{
+		Query:         entry.Content,
+		OperationName: entry.Name,
+	}
+	if entry.Variables != "" {
+		body.Variables = json.RawMessage(entry.Variables)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("operation '%s': %w", entry.Name, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.opts.target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range r.opts.headers {
+		name, value, _ := strings.Cut(header, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("operation '%s': unexpected status %d", entry.Name, resp.StatusCode)
+	}
+
+	var result struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("operation '%s': invalid response: %w", entry.Name, err)
+	}
+	if len(result.Errors) > 0 && string(result.Errors) != "null" {
+		return fmt.Errorf("operation '%s': %s", entry.Name, result.Errors)
+	}
+
+	return nil
+}
+
+// ndjsonLogReader returns the entries of the log as newline delimited JSON. Logs with the msgpack encoding are
+// decoded on the fly.
+func ndjsonLogReader(in io.Reader) io.ReadCloser {
+	br := bufio.NewReader(in)
+	if b, err := br.Peek(1); err != nil || b[0] == '{' {
+		return io.NopCloser(br)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(logging.DecodeMsgpack(br, pw))
+	}()
+	return pr
+}
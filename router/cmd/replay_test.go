@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+type replayTarget struct {
+	mu       sync.Mutex
+	requests []replayRequest
+	headers  []http.Header
+}
+
+func newReplayTarget(t *testing.T) (*replayTarget, *httptest.Server) {
+	target := &replayTarget{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req replayRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		target.mu.Lock()
+		target.requests = append(target.requests, req)
+		target.headers = append(target.headers, r.Header.Clone())
+		target.mu.Unlock()
+
+		if req.OperationName == "Failing" {
+			_, _ = io.WriteString(w, `{"errors":[{"message":"failed"}]}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"data":{}}`)
+	}))
+	t.Cleanup(srv.Close)
+	return target, srv
+}
+
+func TestReplayOperations(t *testing.T) {
+	t.Run("replays the queries and skips the other operations", func(t *testing.T) {
+		target, srv := newReplayTarget(t)
+
+		logs := strings.Join([]string{
+			`{"level":"info","time":1700000000000,"msg":"/graphql","status":200}`,
+			`{"level":"info","time":1700000000000,"msg":"/graphql","operation_name":"Employee","operation_type":"query","operation_content":"query Employee($a: Int!) {employee(id: $a){id}}","operation_variables":"{\"a\":1}"}`,
+			`not a JSON entry`,
+			`{"level":"info","time":1700000000001,"msg":"/graphql","operation_name":"Update","operation_type":"mutation","operation_content":"mutation Update {update}"}`,
+			`{"level":"info","time":1700000000002,"msg":"/graphql","operation_name":"OnUpdate","operation_type":"subscription","operation_content":"subscription OnUpdate {updated}"}`,
+			`{"level":"info","time":1700000000003,"msg":"/graphql","operation_name":"Failing","operation_type":"query","operation_content":"query Failing {failing}"}`,
+		}, "\n")
+
+		var out bytes.Buffer
+		err := ReplayOperations(context.Background(), []string{"-target", srv.URL, "-speed", "0", "-header", "Authorization: Bearer token"}, strings.NewReader(logs), &out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), "replayed 2 operations")
+		require.Contains(t, out.String(), "1 failed, 2 skipped")
+		require.Contains(t, out.String(), `first failure: operation 'Failing'`)
+
+		require.Len(t, target.requests, 2)
+		names := []string{target.requests[0].OperationName, target.requests[1].OperationName}
+		require.ElementsMatch(t, []string{"Employee", "Failing"}, names)
+		for i, req := range target.requests {
+			if req.OperationName == "Employee" {
+				require.Equal(t, "query Employee($a: Int!) {employee(id: $a){id}}", req.Query)
+				require.JSONEq(t, `{"a":1}`, string(req.Variables))
+			}
+			require.Equal(t, "Bearer token", target.headers[i].Get("Authorization"))
+		}
+	})
+
+	t.Run("replays the mutations if enabled", func(t *testing.T) {
+		target, srv := newReplayTarget(t)
+
+		logs := `{"time":1700000000001,"operation_name":"Update","operation_type":"mutation","operation_content":"mutation Update {update}"}`
+
+		var out bytes.Buffer
+		err := ReplayOperations(context.Background(), []string{"-target", srv.URL, "-mutations"}, strings.NewReader(logs), &out)
+		require.NoError(t, err)
+		require.Len(t, target.requests, 1)
+		require.Contains(t, out.String(), "0 failed, 0 skipped")
+	})
+
+	t.Run("keeps the scaled pacing of the entries", func(t *testing.T) {
+		target, srv := newReplayTarget(t)
+
+		logs := strings.Join([]string{
+			`{"time":1700000000000,"operation_name":"A","operation_type":"query","operation_content":"query A {a}"}`,
+			`{"time":1700000000400,"operation_name":"B","operation_type":"query","operation_content":"query B {b}"}`,
+		}, "\n")
+
+		start := time.Now()
+		err := ReplayOperations(context.Background(), []string{"-target", srv.URL, "-speed", "2"}, strings.NewReader(logs), io.Discard)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+		require.Len(t, target.requests, 2)
+	})
+
+	t.Run("reads the access log files with the msgpack encoding", func(t *testing.T) {
+		target, srv := newReplayTarget(t)
+
+		buf, err := logging.NewMsgpackEncoder().EncodeEntry(
+			zapcore.Entry{Level: zapcore.InfoLevel, Time: time.UnixMilli(1700000000000), Message: "/graphql"},
+			[]zap.Field{
+				zap.String("operation_name", "Employee"),
+				zap.String("operation_type", "query"),
+				zap.String("operation_content", "query Employee {employee(id: 1){id}}"),
+			},
+		)
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "access.log")
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+		err = ReplayOperations(context.Background(), []string{"-target", srv.URL, path}, nil, io.Discard)
+		require.NoError(t, err)
+		require.Len(t, target.requests, 1)
+		require.Equal(t, "query Employee {employee(id: 1){id}}", target.requests[0].Query)
+	})
+
+	t.Run("stops without an error when it is canceled", func(t *testing.T) {
+		_, srv := newReplayTarget(t)
+
+		logs := strings.Join([]string{
+			`{"time":1700000000000,"operation_name":"A","operation_type":"query","operation_content":"query A {a}"}`,
+			`{"time":1700003600000,"operation_name":"B","operation_type":"query","operation_content":"query B {b}"}`,
+		}, "\n")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		var out bytes.Buffer
+		err := ReplayOperations(ctx, []string{"-target", srv.URL}, strings.NewReader(logs), &out)
+		require.NoError(t, err)
+		require.Contains(t, out.String(), "replayed 1 operations")
+	})
+
+	t.Run("requires a target", func(t *testing.T) {
+		err := ReplayOperations(context.Background(), nil, strings.NewReader(""), io.Discard)
+		require.ErrorContains(t, err, "must be an http or https URL")
+	})
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/wundergraph/cosmo/router/core"
+)
+
+// PrintVersion implements the version command. With -json, the output is the same document
+// that is served by the version endpoint, without the runtime information of a running router.
+func PrintVersion(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the version information as JSON")
+	withDependencies := fs.Bool("dependencies", false, "include the Go modules compiled into the binary")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	info := core.BuildVersionInfo(*withDependencies)
+
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	commit := info.Commit
+	if commit == "" {
+		commit = "unknown"
+	}
+	buildDate := info.BuildDate
+	if buildDate == "" {
+		buildDate = "unknown"
+	}
+
+	if _, err := fmt.Fprintf(out, "router %s (commit: %s, built: %s, %s %s/%s)\n", info.Version, commit, buildDate, info.GoVersion, info.OS, info.Arch); err != nil {
+		return err
+	}
+
+	for _, dep := range info.Dependencies {
+		line := dep.Path + " " + dep.Version
+		if dep.Replace != "" {
+			line += " => " + dep.Replace
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
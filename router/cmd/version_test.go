@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/core"
+)
+
+func TestPrintVersion(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, PrintVersion(nil, &out))
+		require.True(t, strings.HasPrefix(out.String(), "router "+core.Version+" "))
+		require.Contains(t, out.String(), runtime.Version())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, PrintVersion([]string{"-json"}, &out))
+
+		var info core.VersionInfo
+		require.NoError(t, json.Unmarshal(out.Bytes(), &info))
+		require.Equal(t, core.Version, info.Version)
+		require.Equal(t, runtime.Version(), info.GoVersion)
+		require.Equal(t, runtime.GOOS, info.OS)
+		require.Equal(t, runtime.GOARCH, info.Arch)
+		require.Empty(t, info.Dependencies)
+	})
+}
@@ -1,10 +1,18 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+
 	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
 )
 
 var (
@@ -18,6 +26,8 @@ var (
 type AccessController struct {
 	authenticationRequired bool
 	authenticators         []authentication.Authenticator
+	// audit records the outcomes of the authentication. Optional.
+	audit *logging.AuditLogger
 }
 
 func NewAccessController(authenticators []authentication.Authenticator, authenticationRequired bool) *AccessController {
@@ -39,14 +49,97 @@ func DefaultAccessController() *AccessController {
 func (a *AccessController) Access(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
 	auth, err := authentication.AuthenticateHTTPRequest(r.Context(), a.authenticators, r)
 	if err != nil {
+		a.audit.Authentication("", nil, err, auditRequestFields(r)...)
 		return nil, ErrUnauthorized
 	}
 	if auth != nil {
+		a.audit.Authentication(auth.Authenticator(), auth.Claims(), nil, auditRequestFields(r)...)
 		w.Header().Set("X-Authenticated-By", auth.Authenticator())
 		return r.WithContext(authentication.NewContext(r.Context(), auth)), nil
 	}
 	if a.authenticationRequired {
+		a.audit.Authentication("", nil, ErrUnauthorized, auditRequestFields(r)...)
+		return nil, ErrUnauthorized
+	}
+	return r, nil
+}
+
+// AccessHeader authenticates the authentication information of the header on behalf of the request, e.g. the
+// token of the initial payload of a WebSocket connection. If the header has no authentication information, the
+// authentication of the request is kept.
+func (a *AccessController) AccessHeader(r *http.Request, header http.Header) (*http.Request, error) {
+	auth, err := authentication.AuthenticateHeader(r.Context(), a.authenticators, header)
+	if err != nil {
+		a.audit.Authentication("", nil, err, auditRequestFields(r)...)
+		return nil, ErrUnauthorized
+	}
+	if auth != nil {
+		a.audit.Authentication(auth.Authenticator(), auth.Claims(), nil, auditRequestFields(r)...)
+		return r.WithContext(authentication.NewContext(r.Context(), auth)), nil
+	}
+	if a.authenticationRequired && authentication.FromContext(r.Context()) == nil {
+		a.audit.Authentication("", nil, ErrUnauthorized, auditRequestFields(r)...)
 		return nil, ErrUnauthorized
 	}
 	return r, nil
 }
+
+// JWKSCaches returns the authenticators that cache the keys of a JWKS endpoint
+func (a *AccessController) JWKSCaches() []authentication.JWKSCache {
+	var caches []authentication.JWKSCache
+	for _, authenticator := range a.authenticators {
+		if cache, ok := authenticator.(authentication.JWKSCache); ok {
+			caches = append(caches, cache)
+		}
+	}
+	return caches
+}
+
+// RegisterMetrics exposes the decisions of the authenticators while the endpoints of their keys are unreachable on
+// the meter provider. The metric stays registered until the meter provider is shut down.
+func (a *AccessController) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	var counters []authentication.UnavailableDecisionCounter
+	for _, authenticator := range a.authenticators {
+		if counter, ok := authenticator.(authentication.UnavailableDecisionCounter); ok {
+			counters = append(counters, counter)
+		}
+	}
+	if len(counters) == 0 {
+		return nil
+	}
+
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	decisions, err := meter.Int64ObservableCounter(
+		"router.authentication.unavailable_decisions",
+		otelmetric.WithDescription("Number of tokens authenticated while the key endpoint of the authenticator was unreachable, by decision"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		for _, counter := range counters {
+			for decision, count := range counter.UnavailableDecisions() {
+				o.ObserveInt64(decisions, count, otelmetric.WithAttributes(
+					attribute.String("authenticator", counter.Name()),
+					attribute.String("policy", string(counter.Policy())),
+					attribute.String("decision", string(decision)),
+				))
+			}
+		}
+		return nil
+	}, decisions)
+
+	return err
+}
+
+// auditRequestFields identify the request in the audit log
+func auditRequestFields(r *http.Request) []zap.Field {
+	return []zap.Field{
+		logging.WithRequestID(middleware.GetReqID(r.Context())),
+		logging.WithClientIP(botClientKey(r)),
+	}
+}
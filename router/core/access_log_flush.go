@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+// flushAccessLogs flushes the access logs that aren't written with the logger of the router, which is flushed by
+// its owner. The flush has its own deadline because the shutdown delay can be used up by the draining requests.
+func (r *Router) flushAccessLogs() {
+	if r.accessLogger == nil && r.accessLogKafkaSink == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.logShutdownFlush.Timeout)
+	defer cancel()
+
+	barrier := &logging.FlushBarrier{}
+	marker := r.logger.Named("access")
+	if r.accessLogger != nil {
+		marker = r.accessLogger
+		barrier.Add("access_logs", func(context.Context) error {
+			// Like zap, the error is ignored because stdout can't be synced when it is a pipe
+			_ = r.accessLogger.Sync()
+			return nil
+		})
+	}
+	if r.accessLogKafkaSink != nil {
+		marker = marker.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, r.accessLogKafkaSink.Core())
+		}))
+		barrier.Add("access_logs_kafka", r.accessLogKafkaSink.Flush)
+	}
+	if !r.logShutdownFlush.Marker {
+		marker = nil
+	}
+
+	report := barrier.Run(ctx, marker)
+	for name, err := range report.Failed {
+		r.logger.Error("Could not flush the pending access log entries", zap.String("output", name), zap.Error(err))
+	}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+func TestFlushAccessLogs(t *testing.T) {
+	t.Parallel()
+
+	out := logging.NewMemorySink()
+	buffered := &zapcore.BufferedWriteSyncer{WS: out, Size: 64 * 1024, FlushInterval: time.Hour}
+	t.Cleanup(func() { _ = buffered.Stop() })
+	accessLogger, err := logging.NewAccessLogger(&logging.AccessLoggerOptions{Writer: buffered})
+	require.NoError(t, err)
+
+	r := &Router{Config: Config{
+		logger:           zap.NewNop(),
+		accessLogger:     accessLogger,
+		logShutdownFlush: &config.LogShutdownFlushConfiguration{Timeout: time.Second, Marker: true},
+	}}
+
+	accessLogger.Info("/graphql", zap.Int("status", 200))
+	require.Empty(t, out.Lines())
+
+	r.flushAccessLogs()
+	entries := out.Entries(t)
+	require.Len(t, entries, 2)
+	require.Equal(t, "/graphql", entries[0]["msg"])
+	out.RequireEntry(t, logging.FlushMarkerMessage, map[string]any{
+		"complete":        true,
+		"flushed_outputs": []any{"access_logs"},
+	})
+
+	// Without the marker only the pending entries are flushed
+	r.logShutdownFlush.Marker = false
+	accessLogger.Info("/graphql", zap.Int("status", 200))
+	r.flushAccessLogs()
+	require.Len(t, out.Lines(), 3)
+}
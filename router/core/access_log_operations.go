@@ -0,0 +1,142 @@
+package core
+
+import (
+	"math/rand"
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/internal/useragent"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// accessLogOperationFields returns the fields of the sampled operation of the request for the access log. Requests
+// that fail before the operation is planned have no operation. The operations of requests that are exempted from the
+// sampling of the access log are always logged. The name and the type are left out with withoutSummary, when they
+// are already logged by accessLogRequestFields. The variables are redacted with the redactor, if it's not nil.
+func accessLogOperationFields(cfg *config.AccessLogsOperationsConfiguration, redactor *VariableRedactor, r *http.Request, withoutSummary bool) []zapcore.Field {
+	lc := getLogEntryContext(r.Context())
+	if lc == nil || lc.requestContext == nil || lc.requestContext.operation == nil {
+		return nil
+	}
+	if cfg.SampleRate < 1 && !lc.samplingExempt && rand.Float64() >= cfg.SampleRate {
+		return nil
+	}
+
+	operation := lc.requestContext.operation
+	fields := make([]zapcore.Field, 0, 4)
+	if !withoutSummary {
+		fields = append(fields,
+			zap.String("operation_name", operation.name),
+			zap.String("operation_type", operation.opType),
+		)
+	}
+	fields = append(fields, zap.String("operation_content", operation.content))
+	if variables := operation.loggedVariables(); cfg.IncludeVariables && len(variables) > 0 {
+		fields = append(fields, zap.ByteString("operation_variables", redactor.redact(operation.name, variables)))
+	}
+
+	return fields
+}
+
+// accessLogRequestFields returns the operation, the client and the subgraph errors of the request for the dedicated
+// access logger. Requests that fail before the operation is planned only have the client fields.
+func accessLogRequestFields(r *http.Request) []zapcore.Field {
+	lc := getLogEntryContext(r.Context())
+	if lc == nil || lc.requestContext == nil || lc.requestContext.operation == nil {
+		clientInfo := NewClientInfoFromRequest(r)
+		return []zapcore.Field{
+			zap.String("client_name", clientInfo.Name),
+			zap.String("client_version", clientInfo.Version),
+		}
+	}
+
+	operation := lc.requestContext.operation
+	fields := []zapcore.Field{
+		zap.String("operation_name", operation.name),
+		zap.String("operation_type", operation.opType),
+	}
+	if operation.clientInfo != nil {
+		fields = append(fields,
+			zap.String("client_name", operation.clientInfo.Name),
+			zap.String("client_version", operation.clientInfo.Version),
+		)
+	}
+	if err := lc.requestContext.subgraphErrors; err != nil {
+		fields = append(fields, zap.String("subgraph_errors", err.Error()))
+	}
+
+	return fields
+}
+
+const (
+	cacheDecisionHit  = "hit"
+	cacheDecisionMiss = "miss"
+	// cacheDecisionBypass is a lookup that was skipped, e.g. the plan of a traced request isn't cached
+	cacheDecisionBypass = "bypass"
+)
+
+func cacheDecision(hit bool) string {
+	if hit {
+		return cacheDecisionHit
+	}
+	return cacheDecisionMiss
+}
+
+// cacheDecisions are the outcomes of the cache lookups of an operation. The caches that weren't consulted are empty.
+type cacheDecisions struct {
+	// plan is the cache of the execution plans
+	plan string
+	// normalization is the cache of the normalized persisted operations
+	normalization string
+	// persistedOperation is the resolution of a persisted operation from the caches, a miss is fetched from the CDN
+	persistedOperation string
+	// response is the revalidation of the response of the client with its ETag
+	response string
+	// coalescing is the lookup of an identical query in flight, a hit shares the response of its execution
+	coalescing string
+}
+
+func (c cacheDecisions) empty() bool {
+	return c == cacheDecisions{}
+}
+
+func (c cacheDecisions) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if c.plan != "" {
+		enc.AddString("plan", c.plan)
+	}
+	if c.normalization != "" {
+		enc.AddString("normalization", c.normalization)
+	}
+	if c.persistedOperation != "" {
+		enc.AddString("persisted_operation", c.persistedOperation)
+	}
+	if c.response != "" {
+		enc.AddString("response", c.response)
+	}
+	if c.coalescing != "" {
+		enc.AddString("coalescing", c.coalescing)
+	}
+	return nil
+}
+
+// accessLogCacheFields returns the outcomes of the cache lookups of the operation of the request, so that the cache
+// efficiency can be analyzed per client and operation. Requests that fail before the operation is planned have none.
+func accessLogCacheFields(r *http.Request) []zapcore.Field {
+	lc := getLogEntryContext(r.Context())
+	if lc == nil || lc.requestContext == nil || lc.requestContext.operation == nil || lc.requestContext.operation.cacheDecisions.empty() {
+		return nil
+	}
+	return []zapcore.Field{zap.Object("cache", lc.requestContext.operation.cacheDecisions)}
+}
+
+// accessLogUserAgentFields returns the device class and the browser and OS families of the user agent of the request
+func accessLogUserAgentFields(parser *useragent.Parser, r *http.Request) []zapcore.Field {
+	info := parser.Parse(r.UserAgent())
+	return []zapcore.Field{
+		zap.String("device_class", info.DeviceClass),
+		zap.String("browser_family", info.BrowserFamily),
+		zap.String("os_family", info.OSFamily),
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// AccessLogSampler only logs a share of the requests. Requests that match an exemption, e.g. mutations or failed
+// requests, are always logged, so that audit-critical traffic is never dropped by the sampling.
+type AccessLogSampler struct {
+	sampleRate     float64
+	operationTypes map[string]struct{}
+	operationNames map[string]struct{}
+	clientNames    map[string]struct{}
+	statusCodes    map[int]struct{}
+	// statusClasses are the exempted classes like 5 for 5xx
+	statusClasses map[int]struct{}
+}
+
+func NewAccessLogSampler(cfg *config.AccessLogsSamplingConfiguration) (*AccessLogSampler, error) {
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("the access log sample rate must be between 0 and 1, got %v", cfg.SampleRate)
+	}
+
+	s := &AccessLogSampler{
+		sampleRate:     cfg.SampleRate,
+		operationTypes: make(map[string]struct{}, len(cfg.AlwaysLog.OperationTypes)),
+		operationNames: make(map[string]struct{}, len(cfg.AlwaysLog.OperationNames)),
+		clientNames:    make(map[string]struct{}, len(cfg.AlwaysLog.ClientNames)),
+		statusCodes:    map[int]struct{}{},
+		statusClasses:  map[int]struct{}{},
+	}
+
+	for _, opType := range cfg.AlwaysLog.OperationTypes {
+		switch opType {
+		case "query", "mutation", "subscription":
+			s.operationTypes[opType] = struct{}{}
+		default:
+			return nil, fmt.Errorf("unknown operation type '%s' of the access log sampling exemptions", opType)
+		}
+	}
+	for _, name := range cfg.AlwaysLog.OperationNames {
+		s.operationNames[name] = struct{}{}
+	}
+	for _, name := range cfg.AlwaysLog.ClientNames {
+		s.clientNames[name] = struct{}{}
+	}
+	for _, code := range cfg.AlwaysLog.StatusCodes {
+		if len(code) == 3 && code[1:] == "xx" && code[0] >= '1' && code[0] <= '5' {
+			s.statusClasses[int(code[0]-'0')] = struct{}{}
+			continue
+		}
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid status code '%s' of the access log sampling exemptions", code)
+		}
+		s.statusCodes[status] = struct{}{}
+	}
+
+	return s, nil
+}
+
+// Sample reports whether the access log entry of the request is logged. Exempted requests are marked in the log
+// entry context, so that their operation is logged as well.
+func (s *AccessLogSampler) Sample(r *http.Request, status int) bool {
+	return s.sample(r, status, s.sampleRate)
+}
+
+// sample is Sample with another sample rate, e.g. the one of the tenant of the request
+func (s *AccessLogSampler) sample(r *http.Request, status int, sampleRate float64) bool {
+	lc := getLogEntryContext(r.Context())
+	if s.exempt(lc, status) {
+		if lc != nil {
+			lc.samplingExempt = true
+		}
+		return true
+	}
+	return sampled(sampleRate)
+}
+
+// sampled returns true for the share of the calls given by the sample rate
+func sampled(sampleRate float64) bool {
+	return sampleRate >= 1 || (sampleRate > 0 && rand.Float64() < sampleRate)
+}
+
+func (s *AccessLogSampler) exempt(lc *logEntryContext, status int) bool {
+	if _, ok := s.statusCodes[status]; ok {
+		return true
+	}
+	if _, ok := s.statusClasses[status/100]; ok {
+		return true
+	}
+
+	if lc == nil || lc.requestContext == nil || lc.requestContext.operation == nil {
+		return false
+	}
+	operation := lc.requestContext.operation
+	if _, ok := s.operationTypes[operation.opType]; ok {
+		return true
+	}
+	if _, ok := s.operationNames[operation.name]; ok {
+		return true
+	}
+	if operation.clientInfo != nil {
+		if _, ok := s.clientNames[operation.clientInfo.Name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestAccessLogSampler(t *testing.T) {
+	t.Parallel()
+
+	sampler, err := NewAccessLogSampler(&config.AccessLogsSamplingConfiguration{
+		SampleRate: 0,
+		AlwaysLog: config.AccessLogsSamplingExemptions{
+			OperationTypes: []string{"mutation"},
+			OperationNames: []string{"Checkout"},
+			ClientNames:    []string{"admin"},
+			StatusCodes:    []string{"5xx", "401"},
+		},
+	})
+	require.NoError(t, err)
+
+	request := func(operation *operationContext) (*http.Request, *logEntryContext) {
+		ctx, lc := withLogEntryContext(context.Background())
+		if operation != nil {
+			lc.requestContext = &requestContext{operation: operation}
+		}
+		return httptest.NewRequest(http.MethodPost, "/graphql", nil).WithContext(ctx), lc
+	}
+
+	query := &operationContext{opType: "query", name: "Employees", clientInfo: &ClientInfo{Name: "web"}}
+
+	r, lc := request(query)
+	assert.False(t, sampler.Sample(r, http.StatusOK))
+	assert.False(t, lc.samplingExempt)
+
+	assert.False(t, sampler.Sample(httptest.NewRequest(http.MethodGet, "/graphql", nil), http.StatusBadRequest))
+
+	cases := map[string]struct {
+		operation *operationContext
+		status    int
+	}{
+		"operation type": {operation: &operationContext{opType: "mutation", clientInfo: &ClientInfo{}}, status: http.StatusOK},
+		"operation name": {operation: &operationContext{opType: "query", name: "Checkout", clientInfo: &ClientInfo{}}, status: http.StatusOK},
+		"client name":    {operation: &operationContext{opType: "query", clientInfo: &ClientInfo{Name: "admin"}}, status: http.StatusOK},
+		"status class":   {operation: query, status: http.StatusBadGateway},
+		"status code":    {status: http.StatusUnauthorized},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, lc := request(tc.operation)
+			assert.True(t, sampler.Sample(r, tc.status))
+			assert.True(t, lc.samplingExempt)
+		})
+	}
+}
+
+func TestNewAccessLogSamplerValidation(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewAccessLogSampler(&config.AccessLogsSamplingConfiguration{SampleRate: 1.5})
+	require.EqualError(t, err, "the access log sample rate must be between 0 and 1, got 1.5")
+
+	_, err = NewAccessLogSampler(&config.AccessLogsSamplingConfiguration{
+		SampleRate: 1,
+		AlwaysLog:  config.AccessLogsSamplingExemptions{OperationTypes: []string{"mutations"}},
+	})
+	require.EqualError(t, err, "unknown operation type 'mutations' of the access log sampling exemptions")
+
+	for _, code := range []string{"6xx", "5XX", "99", "abc"} {
+		_, err = NewAccessLogSampler(&config.AccessLogsSamplingConfiguration{
+			SampleRate: 1,
+			AlwaysLog:  config.AccessLogsSamplingExemptions{StatusCodes: []string{code}},
+		})
+		require.EqualError(t, err, "invalid status code '"+code+"' of the access log sampling exemptions")
+	}
+}
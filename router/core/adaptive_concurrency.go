@@ -0,0 +1,312 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// SubgraphConcurrencyLimitExceededErrorCode is the code in the extensions of the error that replaces the response of
+// a subgraph request that didn't get a slot of the adaptive concurrency limit
+const SubgraphConcurrencyLimitExceededErrorCode = "SUBGRAPH_CONCURRENCY_LIMIT_EXCEEDED"
+
+// adaptiveConcurrencyWindowSize is the number of requests after which the latency without load is estimated again,
+// so that the estimate follows a subgraph that became slower, e.g. after a deployment
+const adaptiveConcurrencyWindowSize = 500
+
+// AdaptiveConcurrency limits the in-flight requests of every subgraph. The limit is adapted to the subgraph with
+// additive increase and multiplicative decrease: it grows by one per round trip of the limit while the latency
+// stays within the tolerance of the latency without load and shrinks by the backoff factor, at most once per round
+// trip, when the latency exceeds the tolerance or a request fails.
+type AdaptiveConcurrency struct {
+	logger           *zap.Logger
+	initialLimit     float64
+	minLimit         float64
+	maxLimit         float64
+	latencyTolerance float64
+	backoff          float64
+	maxWait          time.Duration
+
+	mu sync.Mutex
+	// subgraphs are the limits by the name of the subgraph
+	subgraphs map[string]*concurrencyLimit
+
+	now func() time.Time
+}
+
+type concurrencyLimit struct {
+	subgraph string
+	rejected atomic.Int64
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	// noLoadLatency is the estimate of the latency of the subgraph without load
+	noLoadLatency time.Duration
+	// windowLatency is the lowest latency of the current window, which replaces the estimate at its end
+	windowLatency time.Duration
+	windowSamples int
+	lastDecrease  time.Time
+	// released is closed and replaced when a slot is released, to wake up the waiting requests
+	released chan struct{}
+}
+
+// SubgraphConcurrencyStatus describes the adaptive concurrency limit of a subgraph
+type SubgraphConcurrencyStatus struct {
+	Subgraph string `json:"subgraph"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	// NoLoadLatencyMs is the estimate of the latency of the subgraph without load in milliseconds
+	NoLoadLatencyMs float64 `json:"no_load_latency_ms"`
+	// Rejected is the number of requests that didn't get a slot
+	Rejected int64 `json:"rejected"`
+}
+
+func NewAdaptiveConcurrency(logger *zap.Logger, cfg *config.AdaptiveConcurrencyConfiguration) (*AdaptiveConcurrency, error) {
+	if cfg.MinLimit < 1 {
+		return nil, errors.New("the minimum concurrency limit must be at least 1")
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		return nil, fmt.Errorf("the maximum concurrency limit %d must not be lower than the minimum limit %d", cfg.MaxLimit, cfg.MinLimit)
+	}
+	if cfg.InitialLimit < cfg.MinLimit || cfg.InitialLimit > cfg.MaxLimit {
+		return nil, fmt.Errorf("the initial concurrency limit must be between %d and %d, got %d", cfg.MinLimit, cfg.MaxLimit, cfg.InitialLimit)
+	}
+	if cfg.LatencyTolerance < 1 {
+		return nil, fmt.Errorf("the latency tolerance must be at least 1, got %v", cfg.LatencyTolerance)
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		return nil, fmt.Errorf("the backoff must be between 0 and 1, got %v", cfg.Backoff)
+	}
+	if cfg.MaxWait < 0 {
+		return nil, errors.New("the maximum wait for a concurrency slot must not be negative")
+	}
+
+	return &AdaptiveConcurrency{
+		logger:           logger,
+		initialLimit:     float64(cfg.InitialLimit),
+		minLimit:         float64(cfg.MinLimit),
+		maxLimit:         float64(cfg.MaxLimit),
+		latencyTolerance: cfg.LatencyTolerance,
+		backoff:          cfg.Backoff,
+		maxWait:          cfg.MaxWait,
+		subgraphs:        map[string]*concurrencyLimit{},
+		now:              time.Now,
+	}, nil
+}
+
+// Limits returns the status of the limits of the subgraphs that were requested, sorted by the name of the subgraph
+func (c *AdaptiveConcurrency) Limits() []SubgraphConcurrencyStatus {
+	c.mu.Lock()
+	limits := make([]*concurrencyLimit, 0, len(c.subgraphs))
+	for _, l := range c.subgraphs {
+		limits = append(limits, l)
+	}
+	c.mu.Unlock()
+
+	statuses := make([]SubgraphConcurrencyStatus, 0, len(limits))
+	for _, l := range limits {
+		l.mu.Lock()
+		statuses = append(statuses, SubgraphConcurrencyStatus{
+			Subgraph:        l.subgraph,
+			Limit:           int(l.limit),
+			InFlight:        l.inFlight,
+			NoLoadLatencyMs: float64(l.noLoadLatency) / float64(time.Millisecond),
+			Rejected:        l.rejected.Load(),
+		})
+		l.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Subgraph < statuses[j].Subgraph
+	})
+	return statuses
+}
+
+func (c *AdaptiveConcurrency) limitOf(subgraph string) *concurrencyLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.subgraphs[subgraph]
+	if !ok {
+		l = &concurrencyLimit{
+			subgraph: subgraph,
+			limit:    c.initialLimit,
+			released: make(chan struct{}),
+		}
+		c.subgraphs[subgraph] = l
+	}
+	return l
+}
+
+// acquire takes a slot of the limit. It waits up to the maximum wait for a released slot and returns false if
+// it didn't get one.
+func (c *AdaptiveConcurrency) acquire(ctx context.Context, l *concurrencyLimit) bool {
+	var timeout <-chan time.Time
+	if c.maxWait > 0 {
+		timer := time.NewTimer(c.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		l.mu.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			l.mu.Unlock()
+			return true
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		if timeout == nil {
+			return false
+		}
+		select {
+		case <-released:
+		case <-timeout:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// release frees the slot of a request and adapts the limit to its latency
+func (c *AdaptiveConcurrency) release(l *concurrencyLimit, latency time.Duration, failed bool) {
+	now := c.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The requests at the limit count as utilized, because the slot was released only now
+	utilized := l.inFlight >= int(l.limit)/2
+	l.inFlight--
+	close(l.released)
+	l.released = make(chan struct{})
+
+	// The latency of failed requests says nothing about the latency without load
+	if !failed {
+		if l.noLoadLatency == 0 || latency < l.noLoadLatency {
+			l.noLoadLatency = latency
+		}
+		if l.windowLatency == 0 || latency < l.windowLatency {
+			l.windowLatency = latency
+		}
+		l.windowSamples++
+		if l.windowSamples >= adaptiveConcurrencyWindowSize {
+			l.noLoadLatency = l.windowLatency
+			l.windowLatency = 0
+			l.windowSamples = 0
+		}
+	}
+
+	overloaded := failed || float64(latency) > float64(l.noLoadLatency)*c.latencyTolerance
+	if overloaded {
+		// The requests that were in flight together with this one shouldn't decrease the limit again
+		if now.Sub(l.lastDecrease) < latency {
+			return
+		}
+		l.lastDecrease = now
+		l.limit = math.Max(c.minLimit, math.Floor(l.limit*c.backoff))
+		return
+	}
+	// A limit that isn't used isn't increased, so that it doesn't grow unbounded while the load is low
+	if utilized {
+		l.limit = math.Min(c.maxLimit, l.limit+1/l.limit)
+	}
+}
+
+// RoundTripper wraps the transport to the subgraphs with the adaptive concurrency limit
+func (c *AdaptiveConcurrency) RoundTripper(transport http.RoundTripper) http.RoundTripper {
+	return adaptiveConcurrencyTransport{concurrency: c, transport: transport}
+}
+
+type adaptiveConcurrencyTransport struct {
+	concurrency *AdaptiveConcurrency
+	transport   http.RoundTripper
+}
+
+func (t adaptiveConcurrencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Subscriptions hold their connection for their lifetime
+	if req.Header.Get("Upgrade") != "" || req.Header.Get("Accept") == "text/event-stream" {
+		return t.transport.RoundTrip(req)
+	}
+	reqContext := getRequestContext(req.Context())
+	if reqContext == nil {
+		return t.transport.RoundTrip(req)
+	}
+	subgraph := reqContext.ActiveSubgraph(req)
+	if subgraph == nil {
+		return t.transport.RoundTrip(req)
+	}
+
+	l := t.concurrency.limitOf(subgraph.Name)
+	if !t.concurrency.acquire(req.Context(), l) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		l.rejected.Add(1)
+		return t.concurrency.limitExceeded(req, l), nil
+	}
+
+	start := t.concurrency.now()
+	res, err := t.transport.RoundTrip(req)
+	latency := t.concurrency.now().Sub(start)
+
+	if err != nil {
+		// A request that is canceled by the client says nothing about the subgraph
+		t.concurrency.release(l, latency, !errors.Is(err, context.Canceled))
+		return nil, err
+	}
+
+	failed := res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests
+	if res.Body == nil {
+		t.concurrency.release(l, latency, failed)
+		return res, nil
+	}
+	// The slot is held until the body was read
+	res.Body = &releaseOnClose{ReadCloser: res.Body, release: func() {
+		t.concurrency.release(l, latency, failed)
+	}}
+	return res, nil
+}
+
+// limitExceeded returns the response that replaces the response of a request without a slot. The engine handles its
+// error like any other error of the subgraph.
+func (c *AdaptiveConcurrency) limitExceeded(req *http.Request, l *concurrencyLimit) *http.Response {
+	l.mu.Lock()
+	limit := int(l.limit)
+	l.mu.Unlock()
+
+	c.logger.Debug("Subgraph request rejected by the adaptive concurrency limit",
+		zap.String("subgraph_name", l.subgraph),
+		zap.Int("limit", limit),
+	)
+
+	body := fmt.Sprintf(`{"errors":[{"message":"The subgraph has reached its limit of %d concurrent requests","extensions":{"code":"%s"}}]}`,
+		limit, SubgraphConcurrencyLimitExceededErrorCode)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)),
+		StatusCode:    http.StatusServiceUnavailable,
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Header:        http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
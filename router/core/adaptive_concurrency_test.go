@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+type adaptiveConcurrencyTestTransport struct {
+	statusCode int
+	// latency advances the clock of the limiter during the round trip
+	latency time.Duration
+	now     *time.Time
+}
+
+func (t *adaptiveConcurrencyTestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	*t.now = t.now.Add(t.latency)
+	return &http.Response{StatusCode: t.statusCode, Body: io.NopCloser(strings.NewReader(`{"data":{}}`)), Request: req}, nil
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	t.Parallel()
+
+	newConcurrency := func(t *testing.T, initialLimit int, maxWait time.Duration) (*AdaptiveConcurrency, *time.Time) {
+		c, err := NewAdaptiveConcurrency(zap.NewNop(), &config.AdaptiveConcurrencyConfiguration{
+			InitialLimit:     initialLimit,
+			MinLimit:         1,
+			MaxLimit:         100,
+			LatencyTolerance: 2,
+			Backoff:          0.5,
+			MaxWait:          maxWait,
+		})
+		require.NoError(t, err)
+		now := time.Unix(1_000_000, 0)
+		c.now = func() time.Time { return now }
+		return c, &now
+	}
+
+	roundTrip := func(t *testing.T, rt http.RoundTripper) *http.Response {
+		res, err := rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+		require.NoError(t, err)
+		return res
+	}
+
+	t.Run("increases the limit while the latency is low", func(t *testing.T) {
+		t.Parallel()
+
+		c, now := newConcurrency(t, 2, 0)
+		rt := c.RoundTripper(&adaptiveConcurrencyTestTransport{statusCode: http.StatusOK, latency: 10 * time.Millisecond, now: now})
+
+		for i := 0; i < 10; i++ {
+			first, second := roundTrip(t, rt), roundTrip(t, rt)
+			require.NoError(t, first.Body.Close())
+			require.NoError(t, second.Body.Close())
+		}
+
+		status := c.Limits()[0]
+		require.Equal(t, "employees", status.Subgraph)
+		require.Greater(t, status.Limit, 2)
+		require.Equal(t, 0, status.InFlight)
+		require.Equal(t, float64(10), status.NoLoadLatencyMs)
+	})
+
+	t.Run("decreases the limit when the latency or the errors grow", func(t *testing.T) {
+		t.Parallel()
+
+		c, now := newConcurrency(t, 8, 0)
+		transport := &adaptiveConcurrencyTestTransport{statusCode: http.StatusOK, latency: 10 * time.Millisecond, now: now}
+		rt := c.RoundTripper(transport)
+
+		require.NoError(t, roundTrip(t, rt).Body.Close())
+
+		transport.latency = 50 * time.Millisecond
+		require.NoError(t, roundTrip(t, rt).Body.Close())
+		require.Equal(t, 4, c.Limits()[0].Limit)
+
+		transport.latency = 10 * time.Millisecond
+		transport.statusCode = http.StatusBadGateway
+		require.NoError(t, roundTrip(t, rt).Body.Close())
+		require.Equal(t, 2, c.Limits()[0].Limit)
+	})
+
+	t.Run("rejects the requests over the limit", func(t *testing.T) {
+		t.Parallel()
+
+		c, now := newConcurrency(t, 1, 0)
+		rt := c.RoundTripper(&adaptiveConcurrencyTestTransport{statusCode: http.StatusOK, now: now})
+
+		first := roundTrip(t, rt)
+		rejected := roundTrip(t, rt)
+		require.Equal(t, http.StatusServiceUnavailable, rejected.StatusCode)
+		body, err := io.ReadAll(rejected.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), SubgraphConcurrencyLimitExceededErrorCode)
+		require.Equal(t, int64(1), c.Limits()[0].Rejected)
+
+		// Other subgraphs have their own limit
+		res, err := rt.RoundTrip(newChaosTestRequest(t, "products", "Employees"))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		require.NoError(t, first.Body.Close())
+		require.Equal(t, http.StatusOK, roundTrip(t, rt).StatusCode)
+	})
+
+	t.Run("waits for a released slot", func(t *testing.T) {
+		t.Parallel()
+
+		c, _ := newConcurrency(t, 1, time.Second)
+		l := c.limitOf("employees")
+		require.True(t, c.acquire(context.Background(), l))
+
+		acquired := make(chan bool)
+		go func() {
+			acquired <- c.acquire(context.Background(), l)
+		}()
+		c.release(l, 0, false)
+		require.True(t, <-acquired)
+		// The released slot increased the limit to 2
+		require.True(t, c.acquire(context.Background(), l))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.False(t, c.acquire(ctx, l))
+	})
+
+	t.Run("rejects invalid limits", func(t *testing.T) {
+		t.Parallel()
+
+		for _, cfg := range []config.AdaptiveConcurrencyConfiguration{
+			{InitialLimit: 1, MinLimit: 0, MaxLimit: 10, LatencyTolerance: 2, Backoff: 0.9},
+			{InitialLimit: 20, MinLimit: 1, MaxLimit: 10, LatencyTolerance: 2, Backoff: 0.9},
+			{InitialLimit: 1, MinLimit: 1, MaxLimit: 10, LatencyTolerance: 0.5, Backoff: 0.9},
+			{InitialLimit: 1, MinLimit: 1, MaxLimit: 10, LatencyTolerance: 2, Backoff: 1},
+		} {
+			_, err := NewAdaptiveConcurrency(zap.NewNop(), &cfg)
+			require.Error(t, err)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"go.uber.org/zap"
+)
+
+const oidcDiscoveryPath = "/.well-known/openid-configuration"
+
+type AdminTLSConfig struct {
+	Enabled  bool
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is the CA the client certificates are verified with. When set, every client
+	// must present a valid certificate (mTLS).
+	ClientCAFile string
+}
+
+type AdminOIDCConfig struct {
+	Enabled bool
+	// Issuer is the expected issuer of the tokens. If JWKSURL is empty, the keys are discovered from
+	// the OpenID configuration of the issuer.
+	Issuer  string
+	JWKSURL string
+	// Audience is the expected audience of the tokens. Empty accepts any audience.
+	Audience string
+	// RefreshInterval is the minimum time between two refreshes of the keys
+	RefreshInterval time.Duration
+}
+
+// adminAuthenticator authenticates the requests to the admin API with the static token or an OIDC token.
+// Clients with a valid certificate are already authenticated on the TLS layer.
+type adminAuthenticator struct {
+	token    string
+	oidc     authentication.Authenticator
+	issuer   string
+	audience string
+}
+
+func (a *adminAuthenticator) enabled() bool {
+	return a.token != "" || a.oidc != nil
+}
+
+func (a *adminAuthenticator) authenticate(r *http.Request) error {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || provided == "" {
+		return errors.New("missing bearer token")
+	}
+
+	if a.token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(a.token)) == 1 {
+		return nil
+	}
+	if a.oidc == nil {
+		return errors.New("invalid token")
+	}
+
+	auth, err := authentication.AuthenticateHTTPRequest(r.Context(), []authentication.Authenticator{a.oidc}, r)
+	if err != nil {
+		return err
+	}
+	if auth == nil {
+		return errors.New("invalid token")
+	}
+
+	claims := auth.Claims()
+	if a.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.issuer {
+			return fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+	if a.audience != "" && !hasAudience(claims["aud"], a.audience) {
+		return errors.New("token is not issued for the admin API")
+	}
+
+	return nil
+}
+
+// hasAudience checks the aud claim, which is either a string or an array of strings
+func hasAudience(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []any:
+		return slices.ContainsFunc(v, func(a any) bool {
+			s, ok := a.(string)
+			return ok && s == audience
+		})
+	}
+	return false
+}
+
+func (a *adminAuthenticator) middleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !a.enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := a.authenticate(r); err != nil {
+				logger.Debug("Unauthorized admin API request", zap.String("path", r.URL.Path), zap.Error(err))
+				writeAdminJSON(w, http.StatusUnauthorized, adminError{Error: "unauthorized"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newAdminAuthenticator(ctx context.Context, cfg *AdminServerConfig) (*adminAuthenticator, error) {
+	a := &adminAuthenticator{token: cfg.Token}
+
+	if !cfg.OIDC.Enabled {
+		return a, nil
+	}
+
+	jwksURL := cfg.OIDC.JWKSURL
+	if jwksURL == "" {
+		if cfg.OIDC.Issuer == "" {
+			return nil, errors.New("admin OIDC requires an issuer or a JWKS URL")
+		}
+		discovered, err := discoverJWKSURL(ctx, cfg.OIDC.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover the JWKS URL of the admin OIDC issuer: %w", err)
+		}
+		jwksURL = discovered
+	}
+
+	authenticator, err := authentication.NewJWKSAuthenticator(authentication.JWKSAuthenticatorOptions{
+		Name:            "admin",
+		URL:             jwksURL,
+		RefreshInterval: cfg.OIDC.RefreshInterval,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.oidc = authenticator
+	a.issuer = cfg.OIDC.Issuer
+	a.audience = cfg.OIDC.Audience
+
+	return a, nil
+}
+
+// discoverJWKSURL returns the jwks_uri of the OpenID configuration of the issuer
+func discoverJWKSURL(ctx context.Context, issuer string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuer, "/")+oidcDiscoveryPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return "", err
+	}
+	if discovery.JWKSURI == "" {
+		return "", errors.New("the OpenID configuration has no jwks_uri")
+	}
+
+	return discovery.JWKSURI, nil
+}
+
+// newAdminTLSConfig loads the certificate of the admin listener. With a client CA, client certificates are required.
+func newAdminTLSConfig(cfg *AdminTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("admin TLS requires a cert file and a key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin tls cert and key: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		caCert, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read admin client CA file: %w", err)
+		}
+		caPool := x509.NewCertPool()
+		if ok := caPool.AppendCertsFromPEM(caCert); !ok {
+			return nil, errors.New("failed to append admin client CA to pool")
+		}
+
+		tlsConfig.ClientCAs = caPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
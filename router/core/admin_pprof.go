@@ -0,0 +1,151 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCPUProfileDuration = 30 * time.Second
+	defaultTraceDuration      = 1 * time.Second
+)
+
+type adminProfile struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// registerPprofRoutes registers the profiling endpoints. They are compatible with 'go tool pprof' and 'go tool trace'.
+// We don't use net/http/pprof because importing it registers the handlers on the default serve mux.
+// The cmdline endpoint is not exposed on purpose because the command line can contain secrets.
+func (r *Router) registerPprofRoutes(cr chi.Router) {
+	cr.Get("/pprof", handlePprofIndex)
+	cr.Get("/pprof/profile", r.handlePprofCPUProfile)
+	cr.Get("/pprof/trace", r.handlePprofTrace)
+	cr.Get("/pprof/{name}", func(w http.ResponseWriter, req *http.Request) {
+		handleDebugProfile(chi.URLParam(req, "name"))(w, req)
+	})
+}
+
+func handlePprofIndex(w http.ResponseWriter, _ *http.Request) {
+	profiles := pprof.Profiles()
+	result := make([]adminProfile, 0, len(profiles))
+	for _, p := range profiles {
+		result = append(result, adminProfile{Name: p.Name(), Count: p.Count()})
+	}
+	writeAdminJSON(w, http.StatusOK, result)
+}
+
+func (r *Router) handlePprofCPUProfile(w http.ResponseWriter, req *http.Request) {
+	duration, err := r.profileDuration(req, defaultCPUProfileDuration)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	extendWriteDeadline(w, duration)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+
+	if err := pprof.StartCPUProfile(w); err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{Error: fmt.Sprintf("could not enable CPU profiling: %s", err)})
+		return
+	}
+
+	r.logger.Info("CPU profile started through the admin API", zap.Duration("duration", duration))
+
+	sleepWithContext(req, duration)
+	pprof.StopCPUProfile()
+}
+
+func (r *Router) handlePprofTrace(w http.ResponseWriter, req *http.Request) {
+	duration, err := r.profileDuration(req, defaultTraceDuration)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	extendWriteDeadline(w, duration)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+
+	if err := trace.Start(w); err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{Error: fmt.Sprintf("could not enable runtime tracing: %s", err)})
+		return
+	}
+
+	r.logger.Info("Runtime trace started through the admin API", zap.Duration("duration", duration))
+
+	sleepWithContext(req, duration)
+	trace.Stop()
+}
+
+// profileDuration parses the seconds query parameter and enforces the configured maximum duration
+func (r *Router) profileDuration(req *http.Request, defaultDuration time.Duration) (time.Duration, error) {
+	duration := defaultDuration
+
+	if s := req.URL.Query().Get("seconds"); s != "" {
+		seconds, err := strconv.ParseFloat(s, 64)
+		if err != nil || seconds <= 0 {
+			return 0, fmt.Errorf("invalid value for seconds: %s", s)
+		}
+		duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	if maxDuration := r.adminConfig.Pprof.MaxDuration; maxDuration > 0 && duration > maxDuration {
+		return 0, fmt.Errorf("duration exceeds the maximum of %s", maxDuration)
+	}
+
+	return duration, nil
+}
+
+// extendWriteDeadline makes sure that the write timeout of the admin server does not abort long-running profiles
+func extendWriteDeadline(w http.ResponseWriter, duration time.Duration) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(duration + 10*time.Second))
+}
+
+func sleepWithContext(req *http.Request, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-req.Context().Done():
+	}
+}
+
+// handleDebugProfile writes the named runtime profile. The debug query parameter selects the text format like net/http/pprof.
+func handleDebugProfile(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			writeAdminJSON(w, http.StatusNotFound, adminError{Error: "unknown profile"})
+			return
+		}
+
+		debug, _ := strconv.Atoi(req.URL.Query().Get("debug"))
+
+		if name == "heap" && req.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.pprof"`)
+		}
+
+		_ = profile.WriteTo(w, debug)
+	}
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+// adminReplayRequest is a captured request of a client that is re-executed through the admin API
+type adminReplayRequest struct {
+	Query         string            `json:"query"`
+	OperationName string            `json:"operationName,omitempty"`
+	Variables     json.RawMessage   `json:"variables,omitempty"`
+	Extensions    json.RawMessage   `json:"extensions,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+}
+
+type adminReplayResponse struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers"`
+	// Body is the response of the router. The ART trace is part of its extensions.
+	Body json.RawMessage `json:"body"`
+	// Logs are the log entries of the request, including the debug entries
+	Logs []json.RawMessage `json:"logs"`
+}
+
+type adminReplayContextKey struct{}
+
+// adminReplay collects the log entries of a replayed request at the debug level, regardless of the level of the
+// router logger
+type adminReplay struct {
+	buf  bytes.Buffer
+	core zapcore.Core
+}
+
+func newAdminReplay() *adminReplay {
+	replay := &adminReplay{}
+	replay.core = zapcore.NewCore(logging.ZapJsonEncoder(), zapcore.Lock(zapcore.AddSync(&replay.buf)), zapcore.DebugLevel)
+	return replay
+}
+
+func withAdminReplay(ctx context.Context, replay *adminReplay) context.Context {
+	return context.WithValue(ctx, adminReplayContextKey{}, replay)
+}
+
+func getAdminReplay(ctx context.Context) *adminReplay {
+	replay, _ := ctx.Value(adminReplayContextKey{}).(*adminReplay)
+	return replay
+}
+
+// logger tees the log entries of the request logger into the replay
+func (a *adminReplay) logger(logger *zap.Logger) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, a.core)
+	}))
+}
+
+// entries returns the collected log entries. It must only be called after the request was handled.
+func (a *adminReplay) entries() []json.RawMessage {
+	entries := make([]json.RawMessage, 0)
+	for _, line := range bytes.Split(a.buf.Bytes(), []byte("\n")) {
+		if len(line) > 0 {
+			entries = append(entries, line)
+		}
+	}
+	return entries
+}
+
+// handleDebugReplay re-executes a captured request through the handler of the active server, like a request of a
+// client. The request is always traced with ART and its log entries are returned at the debug level. The request
+// goes through the whole pipeline including the authentication, so the headers of the client must be captured too.
+// Mutations are executed again.
+func (r *Router) handleDebugReplay(w http.ResponseWriter, req *http.Request) {
+	handler := r.activeHandler.Load()
+	if handler == nil {
+		writeAdminJSON(w, http.StatusServiceUnavailable, adminError{Error: "no router config loaded"})
+		return
+	}
+
+	var body adminReplayRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "invalid body"})
+		return
+	}
+	if body.Query == "" && len(body.Extensions) == 0 {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "the query or the extensions of a persisted operation are required"})
+		return
+	}
+
+	operation, err := json.Marshal(struct {
+		Query         string          `json:"query,omitempty"`
+		OperationName string          `json:"operationName,omitempty"`
+		Variables     json.RawMessage `json:"variables,omitempty"`
+		Extensions    json.RawMessage `json:"extensions,omitempty"`
+	}{body.Query, body.OperationName, body.Variables, body.Extensions})
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	// The routing of the admin API must not leak into the handler of the server, which would route the request
+	// as a sub router otherwise
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, (*chi.Context)(nil))
+	replay := newAdminReplay()
+	replayReq, err := http.NewRequestWithContext(withAdminReplay(ctx, replay), http.MethodPost, r.graphqlPath, bytes.NewReader(operation))
+	if err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{Error: err.Error()})
+		return
+	}
+	replayReq.RemoteAddr = req.RemoteAddr
+	for name, value := range body.Headers {
+		replayReq.Header.Set(name, value)
+	}
+	replayReq.Header.Set("Content-Type", "application/json")
+	// The trace is returned in the extensions of the response with all the options of ART
+	replayReq.Header.Set(RequestTraceHeader, "true")
+
+	r.logger.Info("Replaying request through the admin API", zap.String("operation_name", body.OperationName))
+
+	rec := httptest.NewRecorder()
+	(*handler).ServeHTTP(rec, replayReq)
+
+	result := adminReplayResponse{
+		StatusCode: rec.Code,
+		Headers:    rec.Header(),
+		Body:       rec.Body.Bytes(),
+		Logs:       replay.entries(),
+	}
+	// Responses that aren't JSON, e.g. of unknown paths, are returned as a string
+	if !json.Valid(result.Body) {
+		result.Body, _ = json.Marshal(rec.Body.String())
+	}
+
+	writeAdminJSON(w, http.StatusOK, result)
+}
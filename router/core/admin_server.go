@@ -0,0 +1,761 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type AdminServerConfig struct {
+	Enabled    bool
+	ListenAddr string
+	// Token is the bearer token required to call the admin API. If empty and OIDC is disabled, the API is not protected.
+	Token string
+	// TLS serves the admin API with TLS. With a client CA, clients authenticate with certificates (mTLS).
+	TLS AdminTLSConfig
+	// OIDC accepts tokens of an OpenID Connect provider in addition to the static token
+	OIDC AdminOIDCConfig
+	// LogBuffer holds the recent log entries of the router. If nil, no logs are served.
+	LogBuffer *logging.RingBuffer
+	// LogLevel is the level of the router logger. If nil, the level can't be changed at runtime.
+	LogLevel *zap.AtomicLevel
+	// LogFilter applies the filter rules to the router logger. If nil, the rules can't be changed at runtime.
+	LogFilter *logging.Filter
+	Pprof     AdminPprofConfig
+}
+
+type AdminPprofConfig struct {
+	Enabled bool
+	// MaxDuration is the maximum duration of CPU profiles and runtime traces
+	MaxDuration time.Duration
+}
+
+type AdminDebugInfo struct {
+	Version     string    `json:"version"`
+	GoVersion   string    `json:"go_version"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+	InstanceID  string    `json:"instance_id"`
+	ClusterName string    `json:"cluster_name,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	Uptime      string    `json:"uptime"`
+	NumCPU      int       `json:"num_cpu"`
+	Goroutines  int       `json:"goroutines"`
+	Maintenance bool      `json:"maintenance"`
+}
+
+type AdminHealth struct {
+	Ready         bool   `json:"ready"`
+	Maintenance   bool   `json:"maintenance"`
+	ConfigVersion string `json:"config_version,omitempty"`
+	// MemoryExceeded is set when the memory soft limit is enabled
+	MemoryExceeded *bool  `json:"memory_exceeded,omitempty"`
+	Uptime         string `json:"uptime"`
+}
+
+type AdminConfigInfo struct {
+	Version      string              `json:"version"`
+	FeatureFlags []string            `json:"feature_flags"`
+	Subgraphs    []AdminSubgraphInfo `json:"subgraphs"`
+}
+
+type AdminSubgraphInfo struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	RoutingURL string `json:"routing_url"`
+}
+
+type adminBlockedPersistedOperations struct {
+	Hashes []string `json:"hashes"`
+}
+
+type adminBlockedFields struct {
+	Fields []BlockedField `json:"fields"`
+}
+
+type adminPersistedOperationUsage struct {
+	// Since is the start of the tracking. Operations that weren't used since then are unknown.
+	Since         time.Time                 `json:"since"`
+	UntrackedHits int64                     `json:"untracked_hits"`
+	Operations    []PersistedOperationUsage `json:"operations"`
+}
+
+type adminTopOperations struct {
+	Window string `json:"window"`
+	By     string `json:"by"`
+	// UntrackedRequests are the requests of the operations above the maximum number of operations
+	UntrackedRequests int64          `json:"untracked_requests"`
+	Operations        []TopOperation `json:"operations"`
+}
+
+type adminUsageQuotas struct {
+	// Client is empty for the quotas shared by the requests without a client
+	Client string             `json:"client,omitempty"`
+	Quotas []UsageQuotaStatus `json:"quotas"`
+}
+
+type adminSubgraphEndpoints struct {
+	Endpoints []SubgraphEndpointStatus `json:"endpoints"`
+}
+
+type adminSubgraphConcurrency struct {
+	Subgraphs []SubgraphConcurrencyStatus `json:"subgraphs"`
+}
+
+type adminJWKS struct {
+	Authenticators []authentication.JWKSStatus `json:"authenticators"`
+}
+
+type adminSubgraphEndpointWeight struct {
+	Weight *int `json:"weight"`
+}
+
+type adminErrorCodes struct {
+	Codes []ErrorCode `json:"codes"`
+}
+
+type adminConfigChanges struct {
+	Changes []ConfigChange `json:"changes"`
+}
+
+type adminLogLevel struct {
+	Level string `json:"level"`
+}
+
+type adminLogFilters struct {
+	Rules []adminLogFilterRule `json:"rules"`
+}
+
+type adminLogFilterRule struct {
+	Logger  string            `json:"logger,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Field   string            `json:"field,omitempty"`
+	Value   string            `json:"value,omitempty"`
+	Action  string            `json:"action"`
+	Level   string            `json:"level,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// newAdminServer creates the HTTP server for the admin API. The admin API is served on a dedicated listener
+// and is not swapped on router config updates, therefore all handlers must only depend on router wide state.
+func (r *Router) newAdminServer(ctx context.Context) (*http.Server, error) {
+	authenticator, err := newAdminAuthenticator(ctx, r.adminConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ar := chi.NewRouter()
+	ar.Use(middleware.Recoverer)
+	if headers := newCustomResponseHeaders(r.customResponseHeaders, ResponseHeadersListenerAdmin, r.activeConfigVersion); headers != nil {
+		ar.Use(headers.Handler)
+	}
+	ar.Use(authenticator.middleware(r.logger))
+
+	ar.Get("/health", r.handleAdminHealth)
+	ar.Get("/config", r.handleAdminConfig)
+	ar.Get("/error-codes", r.handleErrorCodes)
+
+	if r.configAudit != nil {
+		ar.Get("/config/changes", r.handleConfigChanges)
+	}
+
+	if r.adminConfig.LogLevel != nil {
+		ar.Get("/log/level", r.handleGetLogLevel)
+		ar.Put("/log/level", r.handleSetLogLevel)
+	}
+
+	if r.adminConfig.LogFilter != nil {
+		ar.Get("/log/filters", r.handleGetLogFilters)
+		ar.Put("/log/filters", r.handleSetLogFilters)
+	}
+
+	if r.clusterPeers != nil {
+		ar.Get(clusterPeersPath, r.handleClusterPeers)
+		ar.Post(clusterPeersPath, r.handleClusterPeersGossip)
+	}
+
+	if r.replicatedRateLimits != nil {
+		ar.Post(clusterRateLimitsPath, r.handleClusterRateLimits)
+	}
+
+	ar.Route("/maintenance", func(cr chi.Router) {
+		cr.Get("/", r.handleMaintenanceStatus)
+		cr.Post("/enable", r.handleMaintenanceToggle(true))
+		cr.Post("/disable", r.handleMaintenanceToggle(false))
+	})
+
+	ar.Route("/persisted-operations/blocked", func(cr chi.Router) {
+		cr.Get("/", r.handleBlockedPersistedOperations)
+		cr.Put("/{hash}", r.handleBlockPersistedOperation)
+		cr.Delete("/{hash}", r.handleUnblockPersistedOperation)
+	})
+
+	if r.persistedOpUsage != nil {
+		ar.Get("/persisted-operations/usage", r.handlePersistedOperationUsage)
+	}
+
+	if r.fieldBlocker != nil {
+		ar.Route("/fields/blocked", func(cr chi.Router) {
+			cr.Get("/", r.handleBlockedFields)
+			cr.Put("/{coordinate}", r.handleBlockField)
+			cr.Delete("/{coordinate}", r.handleUnblockField)
+		})
+	}
+
+	if r.topOperations != nil {
+		ar.Get("/operations/top", r.handleTopOperations)
+	}
+
+	if r.subgraphEndpoints != nil {
+		ar.Route("/subgraphs/endpoints", func(cr chi.Router) {
+			cr.Get("/", r.handleSubgraphEndpoints)
+			cr.Put("/{subgraph}", r.handleSetSubgraphEndpointWeight)
+		})
+	}
+
+	if r.adaptiveConcurrency != nil {
+		ar.Get("/subgraphs/concurrency", r.handleSubgraphConcurrency)
+	}
+
+	if len(r.accessController.JWKSCaches()) > 0 {
+		ar.Route("/authentication/jwks", func(cr chi.Router) {
+			cr.Get("/", r.handleJWKS)
+			cr.Post("/refresh", r.handleRefreshJWKS)
+		})
+	}
+
+	if r.rateLimit != nil && r.rateLimit.Enabled && len(r.rateLimit.UsageQuotas) > 0 {
+		ar.Route("/rate-limit/usage-quotas", func(cr chi.Router) {
+			cr.Get("/", r.handleUsageQuotas)
+			cr.Delete("/", r.handleResetUsageQuotas)
+		})
+	}
+
+	ar.Route("/debug", func(cr chi.Router) {
+		cr.Get("/info", r.handleDebugInfo)
+		cr.Get("/logs", r.handleDebugLogs)
+		cr.Post("/replay", r.handleDebugReplay)
+		cr.Get("/pprof/goroutine", handleDebugProfile("goroutine"))
+		cr.Get("/pprof/heap", handleDebugProfile("heap"))
+
+		if r.adminConfig.Pprof.Enabled {
+			r.registerPprofRoutes(cr)
+		}
+	})
+
+	svr := &http.Server{
+		Addr:              r.adminConfig.ListenAddr,
+		ReadTimeout:       1 * time.Minute,
+		WriteTimeout:      1 * time.Minute,
+		ReadHeaderTimeout: 2 * time.Second,
+		IdleTimeout:       30 * time.Second,
+		ErrorLog:          zap.NewStdLog(r.logger),
+		Handler:           ar,
+	}
+
+	mTLS := false
+	if r.adminConfig.TLS.Enabled {
+		svr.TLSConfig, err = newAdminTLSConfig(&r.adminConfig.TLS)
+		if err != nil {
+			return nil, err
+		}
+		mTLS = svr.TLSConfig.ClientCAs != nil
+		if r.certExpiry != nil {
+			if err := r.certExpiry.Add(ResponseHeadersListenerAdmin, &svr.TLSConfig.Certificates[0]); err != nil {
+				return nil, err
+			}
+		}
+		svr.TLSConfig = r.connectionTimings.TLSConfig(ResponseHeadersListenerAdmin, svr.TLSConfig)
+	}
+
+	if !authenticator.enabled() && !mTLS {
+		r.logger.Warn("Admin API is enabled without authentication. Everyone with access to the listener can operate the router")
+	}
+
+	r.logger.Info("Admin API enabled",
+		zap.String("listen_addr", svr.Addr),
+		zap.Bool("tls", r.adminConfig.TLS.Enabled),
+		zap.Bool("mtls", mTLS),
+		zap.Bool("oidc", r.adminConfig.OIDC.Enabled),
+	)
+
+	return svr, nil
+}
+
+// listenAndServeAdminTLS serves the admin listener like ListenAndServeTLS, but records when the connections are
+// accepted for the connection timings
+func (r *Router) listenAndServeAdminTLS() error {
+	addr := r.adminServer.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	// Leave the cert and key empty to use the ones of the TLS config
+	return r.adminServer.ServeTLS(r.connectionTimings.Listener(ln), "", "")
+}
+
+func (r *Router) handleAdminHealth(w http.ResponseWriter, _ *http.Request) {
+	health := AdminHealth{
+		Maintenance: r.maintenanceMode.Enabled(),
+		Uptime:      time.Since(r.processStartTime).Round(time.Second).String(),
+	}
+
+	if cfg := r.activeRouterConfig.Load(); cfg != nil {
+		health.Ready = true
+		health.ConfigVersion = cfg.GetVersion()
+	}
+	if r.memoryGuard != nil {
+		exceeded := r.memoryGuard.Exceeded()
+		health.MemoryExceeded = &exceeded
+	}
+
+	statusCode := http.StatusOK
+	if !health.Ready || health.Maintenance {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	writeAdminJSON(w, statusCode, health)
+}
+
+// handleAdminConfig describes the active router config. It doesn't contain the schema or any secrets.
+func (r *Router) handleAdminConfig(w http.ResponseWriter, _ *http.Request) {
+	cfg := r.activeRouterConfig.Load()
+	if cfg == nil {
+		writeAdminJSON(w, http.StatusServiceUnavailable, adminError{Error: "no router config loaded"})
+		return
+	}
+
+	info := AdminConfigInfo{
+		Version:      cfg.GetVersion(),
+		FeatureFlags: []string{},
+		Subgraphs:    make([]AdminSubgraphInfo, 0, len(cfg.GetSubgraphs())),
+	}
+	for name := range cfg.GetFeatureFlagConfigs().GetConfigByFeatureFlagName() {
+		info.FeatureFlags = append(info.FeatureFlags, name)
+	}
+	sort.Strings(info.FeatureFlags)
+	for _, sg := range cfg.GetSubgraphs() {
+		info.Subgraphs = append(info.Subgraphs, AdminSubgraphInfo{
+			ID:         sg.GetId(),
+			Name:       sg.GetName(),
+			RoutingURL: sg.GetRoutingUrl(),
+		})
+	}
+
+	writeAdminJSON(w, http.StatusOK, info)
+}
+
+// handleConfigChanges lists the most recent router config changes, the latest first. The limit query parameter
+// restricts the number of listed changes.
+func (r *Router) handleConfigChanges(w http.ResponseWriter, req *http.Request) {
+	limit := 0
+	if value := req.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "limit must be a positive integer"})
+			return
+		}
+	}
+
+	writeAdminJSON(w, http.StatusOK, adminConfigChanges{Changes: r.configAudit.Changes(limit)})
+}
+
+func (r *Router) handleErrorCodes(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminErrorCodes{Codes: ErrorCodes()})
+}
+
+func (r *Router) handleGetLogLevel(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminLogLevel{Level: r.adminConfig.LogLevel.Level().String()})
+}
+
+func (r *Router) handleSetLogLevel(w http.ResponseWriter, req *http.Request) {
+	var body adminLogLevel
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "invalid body"})
+		return
+	}
+
+	level, err := zapcore.ParseLevel(body.Level)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	previous := r.adminConfig.LogLevel.Level()
+	r.adminConfig.LogLevel.SetLevel(level)
+
+	r.logger.Info("Log level changed through the admin API",
+		zap.String("previous_level", previous.String()),
+		zap.String("level", level.String()),
+	)
+
+	writeAdminJSON(w, http.StatusOK, adminLogLevel{Level: level.String()})
+}
+
+func (r *Router) handleGetLogFilters(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, toAdminLogFilters(r.adminConfig.LogFilter.Rules()))
+}
+
+func (r *Router) handleSetLogFilters(w http.ResponseWriter, req *http.Request) {
+	var body adminLogFilters
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "invalid body"})
+		return
+	}
+
+	rules := make([]logging.FilterRule, 0, len(body.Rules))
+	for _, rule := range body.Rules {
+		rules = append(rules, logging.FilterRule{
+			Logger:  rule.Logger,
+			Message: rule.Message,
+			Field:   rule.Field,
+			Value:   rule.Value,
+			Action:  rule.Action,
+			Level:   rule.Level,
+			Fields:  rule.Fields,
+		})
+	}
+
+	if err := r.adminConfig.LogFilter.SetRules(rules); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	r.logger.Info("Log filter rules changed through the admin API", zap.Int("rules", len(rules)))
+
+	writeAdminJSON(w, http.StatusOK, toAdminLogFilters(rules))
+}
+
+func toAdminLogFilters(rules []logging.FilterRule) adminLogFilters {
+	filters := adminLogFilters{Rules: make([]adminLogFilterRule, 0, len(rules))}
+	for _, rule := range rules {
+		filters.Rules = append(filters.Rules, adminLogFilterRule{
+			Logger:  rule.Logger,
+			Message: rule.Message,
+			Field:   rule.Field,
+			Value:   rule.Value,
+			Action:  rule.Action,
+			Level:   rule.Level,
+			Fields:  rule.Fields,
+		})
+	}
+	return filters
+}
+
+func (r *Router) handleMaintenanceStatus(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, r.maintenanceMode.Status())
+}
+
+func (r *Router) handleMaintenanceToggle(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		r.maintenanceMode.SetEnabled(enabled)
+
+		r.logger.Info("Maintenance mode changed through the admin API", zap.Bool("enabled", enabled))
+
+		writeAdminJSON(w, http.StatusOK, r.maintenanceMode.Status())
+	}
+}
+
+func (r *Router) handleBlockedPersistedOperations(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminBlockedPersistedOperations{Hashes: r.persistedOpKillSwitch.Hashes()})
+}
+
+func (r *Router) handleBlockPersistedOperation(w http.ResponseWriter, req *http.Request) {
+	hash := chi.URLParam(req, "hash")
+	r.persistedOpKillSwitch.Block(hash)
+
+	r.logger.Warn("Persisted operation blocked through the admin API", zap.String("hash", hash))
+
+	writeAdminJSON(w, http.StatusOK, adminBlockedPersistedOperations{Hashes: r.persistedOpKillSwitch.Hashes()})
+}
+
+func (r *Router) handleUnblockPersistedOperation(w http.ResponseWriter, req *http.Request) {
+	hash := chi.URLParam(req, "hash")
+	if !r.persistedOpKillSwitch.Unblock(hash) {
+		writeAdminJSON(w, http.StatusNotFound, adminError{Error: "the persisted operation wasn't blocked through the admin API"})
+		return
+	}
+
+	r.logger.Info("Persisted operation unblocked through the admin API", zap.String("hash", hash))
+
+	writeAdminJSON(w, http.StatusOK, adminBlockedPersistedOperations{Hashes: r.persistedOpKillSwitch.Hashes()})
+}
+
+func (r *Router) handleBlockedFields(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminBlockedFields{Fields: r.fieldBlocker.Fields()})
+}
+
+// handleBlockField blocks the field with the coordinate. With the action query parameter "mask", the values of the
+// field are replaced with null instead.
+func (r *Router) handleBlockField(w http.ResponseWriter, req *http.Request) {
+	coordinate := chi.URLParam(req, "coordinate")
+	action := req.URL.Query().Get("action")
+	if err := r.fieldBlocker.Block(coordinate, action); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	r.logger.Warn("Field blocked through the admin API", zap.String("coordinate", coordinate), zap.String("action", action))
+
+	writeAdminJSON(w, http.StatusOK, adminBlockedFields{Fields: r.fieldBlocker.Fields()})
+}
+
+func (r *Router) handleUnblockField(w http.ResponseWriter, req *http.Request) {
+	coordinate := chi.URLParam(req, "coordinate")
+	if !r.fieldBlocker.Unblock(coordinate) {
+		writeAdminJSON(w, http.StatusNotFound, adminError{Error: "the field wasn't blocked through the admin API"})
+		return
+	}
+
+	r.logger.Info("Field unblocked through the admin API", zap.String("coordinate", coordinate))
+
+	writeAdminJSON(w, http.StatusOK, adminBlockedFields{Fields: r.fieldBlocker.Fields()})
+}
+
+func (r *Router) handleSubgraphEndpoints(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminSubgraphEndpoints{Endpoints: r.subgraphEndpoints.Endpoints()})
+}
+
+func (r *Router) handleSubgraphConcurrency(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, adminSubgraphConcurrency{Subgraphs: r.adaptiveConcurrency.Limits()})
+}
+
+func (r *Router) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, r.jwksStatus())
+}
+
+// handleRefreshJWKS fetches the keys of the authenticators immediately, e.g. after an emergency rotation of the keys.
+// The authenticator query parameter refreshes a single authenticator.
+func (r *Router) handleRefreshJWKS(w http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("authenticator")
+
+	var refreshed int
+	var errs error
+	for _, cache := range r.accessController.JWKSCaches() {
+		if name != "" && cache.Name() != name {
+			continue
+		}
+		refreshed++
+		if err := cache.RefreshJWKS(req.Context()); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to refresh the JWKS of authenticator '%s': %w", cache.Name(), err))
+		}
+	}
+	if refreshed == 0 {
+		writeAdminJSON(w, http.StatusNotFound, adminError{Error: fmt.Sprintf("unknown authenticator '%s'", name)})
+		return
+	}
+
+	r.logger.Info("JWKS refreshed through the admin API", zap.String("authenticator", name), zap.Error(errs))
+
+	if errs != nil {
+		writeAdminJSON(w, http.StatusBadGateway, adminError{Error: errs.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, r.jwksStatus())
+}
+
+func (r *Router) jwksStatus() adminJWKS {
+	caches := r.accessController.JWKSCaches()
+	status := adminJWKS{Authenticators: make([]authentication.JWKSStatus, 0, len(caches))}
+	for _, cache := range caches {
+		status.Authenticators = append(status.Authenticators, cache.JWKSStatus())
+	}
+	return status
+}
+
+// handleSetSubgraphEndpointWeight changes the percentage of the requests to the subgraph that are sent to its
+// alternate URL, e.g. 100 to switch all requests and 0 to switch them back
+func (r *Router) handleSetSubgraphEndpointWeight(w http.ResponseWriter, req *http.Request) {
+	var body adminSubgraphEndpointWeight
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Weight == nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "invalid body, the weight is required"})
+		return
+	}
+
+	subgraph := chi.URLParam(req, "subgraph")
+	status, err := r.subgraphEndpoints.SetWeight(subgraph, *body.Weight)
+	if errors.Is(err, ErrUnknownSubgraphEndpoint) {
+		writeAdminJSON(w, http.StatusNotFound, adminError{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	r.logger.Info("Subgraph endpoint weight changed through the admin API",
+		zap.String("subgraph_name", subgraph),
+		zap.Int("weight", status.Weight),
+	)
+
+	writeAdminJSON(w, http.StatusOK, status)
+}
+
+// handlePersistedOperationUsage lists the usage of the persisted operations, the least recently used first.
+// With the unused_for query parameter, only the operations that weren't used for the given duration are listed.
+func (r *Router) handlePersistedOperationUsage(w http.ResponseWriter, req *http.Request) {
+	var usedBefore time.Time
+	if value := req.URL.Query().Get("unused_for"); value != "" {
+		unusedFor, err := time.ParseDuration(value)
+		if err != nil || unusedFor <= 0 {
+			writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "unused_for must be a positive duration"})
+			return
+		}
+		usedBefore = time.Now().Add(-unusedFor)
+	}
+
+	writeAdminJSON(w, http.StatusOK, adminPersistedOperationUsage{
+		Since:         r.persistedOpUsage.Since(),
+		UntrackedHits: r.persistedOpUsage.UntrackedHits(),
+		Operations:    r.persistedOpUsage.Usages(usedBefore),
+	})
+}
+
+// handleTopOperations lists the operations of the window ranked by the by query parameter, count by default. The
+// limit query parameter is the number of operations, 10 by default, and min_requests the number of requests an
+// operation needs to be ranked.
+func (r *Router) handleTopOperations(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	by := query.Get("by")
+	if by == "" {
+		by = TopOperationsByCount
+	}
+
+	limit := 10
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "limit must be a positive integer"})
+			return
+		}
+	}
+
+	var minRequests int64 = 1
+	if value := query.Get("min_requests"); value != "" {
+		var err error
+		minRequests, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || minRequests <= 0 {
+			writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "min_requests must be a positive integer"})
+			return
+		}
+	}
+
+	operations, untracked, err := r.topOperations.Top(by, limit, minRequests)
+	if err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, adminTopOperations{
+		Window:            r.topOperations.Window().String(),
+		By:                by,
+		UntrackedRequests: untracked,
+		Operations:        operations,
+	})
+}
+
+// handleUsageQuotas returns the usage of the quotas of the client in the current periods. The client is the value of
+// the rate limit key_by of the client query parameter, without it the quotas of the requests without a client are
+// returned.
+func (r *Router) handleUsageQuotas(w http.ResponseWriter, req *http.Request) {
+	client := req.URL.Query().Get("client")
+	quotas, err := r.usageQuotas.Usage(req.Context(), rateLimitClientKey(r.rateLimit, client))
+	if err != nil {
+		r.logger.Error("Failed to read the usage quotas", zap.Error(err))
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{Error: "the usage quotas are not available"})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, adminUsageQuotas{Client: client, Quotas: quotas})
+}
+
+// handleResetUsageQuotas resets the quotas of the client in the current periods, or only the quota of the quota
+// query parameter
+func (r *Router) handleResetUsageQuotas(w http.ResponseWriter, req *http.Request) {
+	client, name := req.URL.Query().Get("client"), req.URL.Query().Get("quota")
+	key := rateLimitClientKey(r.rateLimit, client)
+
+	found, err := r.usageQuotas.Reset(req.Context(), key, name)
+	if err != nil {
+		r.logger.Error("Failed to reset the usage quotas", zap.Error(err))
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{Error: "the usage quotas are not available"})
+		return
+	}
+	if !found {
+		writeAdminJSON(w, http.StatusNotFound, adminError{Error: "unknown usage quota '" + name + "'"})
+		return
+	}
+
+	r.logger.Info("Usage quotas reset through the admin API", zap.String("client", client), zap.String("quota", name))
+
+	quotas, err := r.usageQuotas.Usage(req.Context(), key)
+	if err != nil {
+		r.logger.Error("Failed to read the usage quotas", zap.Error(err))
+		writeAdminJSON(w, http.StatusInternalServerError, adminError{Error: "the usage quotas are not available"})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, adminUsageQuotas{Client: client, Quotas: quotas})
+}
+
+func (r *Router) handleDebugInfo(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, AdminDebugInfo{
+		Version:     Version,
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		InstanceID:  r.instanceID,
+		ClusterName: r.clusterName,
+		StartedAt:   r.processStartTime,
+		Uptime:      time.Since(r.processStartTime).Round(time.Second).String(),
+		NumCPU:      runtime.NumCPU(),
+		Goroutines:  runtime.NumGoroutine(),
+		Maintenance: r.maintenanceMode.Enabled(),
+	})
+}
+
+// handleDebugLogs writes the buffered log entries as newline delimited JSON
+func (r *Router) handleDebugLogs(w http.ResponseWriter, _ *http.Request) {
+	if r.adminConfig.LogBuffer == nil {
+		writeAdminJSON(w, http.StatusNotFound, adminError{Error: "log buffer is not enabled"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := r.adminConfig.LogBuffer.WriteTo(w); err != nil {
+		r.logger.Debug("Failed to write buffered logs", zap.Error(err))
+	}
+}
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+func writeAdminJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,574 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAdminServerMaintenance(t *testing.T) {
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{
+		Enabled:    true,
+		ListenAddr: "localhost:0",
+		Token:      "secret",
+	}))
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	doRequest := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := doRequest(http.MethodPost, "/maintenance/enable", "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doRequest(http.MethodPost, "/maintenance/enable", "wrong")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.False(t, r.maintenanceMode.Enabled())
+
+	rec = doRequest(http.MethodPost, "/maintenance/enable", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"enabled":true}`, rec.Body.String())
+	require.True(t, r.maintenanceMode.Enabled())
+
+	rec = doRequest(http.MethodGet, "/maintenance", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"enabled":true}`, rec.Body.String())
+
+	rec = doRequest(http.MethodPost, "/maintenance/disable", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"enabled":false}`, rec.Body.String())
+	require.False(t, r.maintenanceMode.Enabled())
+}
+
+func TestAdminServerDebug(t *testing.T) {
+	logBuffer := logging.NewRingBuffer(2)
+	logger := zap.NewNop().WithOptions(logging.WithRingBuffer(logBuffer, zapcore.InfoLevel))
+
+	r, err := NewRouter(
+		WithLogger(logger),
+		WithAdminServer(&AdminServerConfig{
+			Enabled:    true,
+			ListenAddr: "localhost:0",
+			LogBuffer:  logBuffer,
+		}),
+	)
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	logger.Info("first")
+	logger.Info("second")
+	logger.Info("third")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/logs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"msg":"second"`)
+	require.Contains(t, lines[1], `"msg":"third"`)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/info", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"version":"dev"`)
+}
+
+func TestAdminServerPprof(t *testing.T) {
+	_, err := NewRouter(WithAdminServer(&AdminServerConfig{
+		Enabled: true,
+		Pprof:   AdminPprofConfig{Enabled: true},
+	}))
+	require.Error(t, err)
+
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{
+		Enabled: true,
+		Token:   "secret",
+	}))
+	require.NoError(t, err)
+
+	doRequest := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := doRequest(newTestAdminHandler(t, r), "/debug/pprof/allocs")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	r, err = NewRouter(WithAdminServer(&AdminServerConfig{
+		Enabled: true,
+		Token:   "secret",
+		Pprof: AdminPprofConfig{
+			Enabled:     true,
+			MaxDuration: time.Second,
+		},
+	}))
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	rec = doRequest(handler, "/debug/pprof")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"name":"allocs"`)
+
+	rec = doRequest(handler, "/debug/pprof/allocs")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, rec.Body.Bytes())
+
+	rec = doRequest(handler, "/debug/pprof/goroutine?debug=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "goroutine profile:")
+
+	rec = doRequest(handler, "/debug/pprof/trace?seconds=0.05")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, rec.Body.Bytes())
+
+	rec = doRequest(handler, "/debug/pprof/profile?seconds=0.05")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, rec.Body.Bytes())
+
+	rec = doRequest(handler, "/debug/pprof/profile?seconds=5")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func newTestAdminHandler(t *testing.T, r *Router) http.Handler {
+	t.Helper()
+
+	svr, err := r.newAdminServer(context.Background())
+	require.NoError(t, err)
+
+	return svr.Handler
+}
+
+func TestAdminServerHealthAndConfig(t *testing.T) {
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{Enabled: true}))
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), `"ready":false`)
+
+	r.activeRouterConfig.Store(&nodev1.RouterConfig{
+		Version: "v1",
+		Subgraphs: []*nodev1.Subgraph{
+			{Id: "1", Name: "employees", RoutingUrl: "http://localhost:4001/graphql"},
+		},
+		FeatureFlagConfigs: &nodev1.FeatureFlagRouterExecutionConfigs{
+			ConfigByFeatureFlagName: map[string]*nodev1.FeatureFlagRouterExecutionConfig{"beta": {}},
+		},
+	})
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"ready":true`)
+	require.Contains(t, rec.Body.String(), `"config_version":"v1"`)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"version":"v1","feature_flags":["beta"],"subgraphs":[{"id":"1","name":"employees","routing_url":"http://localhost:4001/graphql"}]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/error-codes", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var errorCodes adminErrorCodes
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errorCodes))
+	require.Equal(t, ErrorCodes(), errorCodes.Codes)
+}
+
+func TestAdminServerLogLevel(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{Enabled: true, LogLevel: &level}))
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log/level", nil))
+	require.JSONEq(t, `{"level":"info"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"verbose"}`)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, zapcore.DebugLevel, level.Level())
+}
+
+func TestAdminServerLogFilters(t *testing.T) {
+	filter, err := logging.NewFilter(nil)
+	require.NoError(t, err)
+
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{Enabled: true, LogFilter: filter}))
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log/filters", nil))
+	require.JSONEq(t, `{"rules":[]}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/filters", strings.NewReader(`{"rules":[{"message":"(","action":"drop"}]}`)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/filters", strings.NewReader(`{"rules":[{"logger":"kafka","action":"level","level":"debug"}]}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, []logging.FilterRule{{Logger: "kafka", Action: logging.FilterActionLevel, Level: "debug"}}, filter.Rules())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log/filters", nil))
+	require.JSONEq(t, `{"rules":[{"logger":"kafka","action":"level","level":"debug"}]}`, rec.Body.String())
+}
+
+func TestAdminServerReplay(t *testing.T) {
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{Enabled: true}))
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/replay", strings.NewReader(`{"query":"{ a }"}`)))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var activeHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "/graphql", req.URL.Path)
+		require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		require.Equal(t, "true", req.Header.Get(RequestTraceHeader))
+
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"query":"query A($id: Int!) { a(id: $id) }","operationName":"A","variables":{"id":1}}`, string(body))
+
+		replay := getAdminReplay(req.Context())
+		require.NotNil(t, replay)
+		replay.logger(zap.NewNop()).Debug("replayed")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	})
+	r.activeHandler.Store(&activeHandler)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/replay", strings.NewReader(`{"headers":{}}`)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/replay", strings.NewReader(
+		`{"query":"query A($id: Int!) { a(id: $id) }","operationName":"A","variables":{"id":1},"headers":{"Authorization":"Bearer token"}}`,
+	)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result adminReplayResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.JSONEq(t, `{"data":{"a":1}}`, string(result.Body))
+	require.Len(t, result.Logs, 1)
+	require.Contains(t, string(result.Logs[0]), `"msg":"replayed"`)
+}
+
+func TestAdminServerPersistedOperationKillSwitch(t *testing.T) {
+	r, err := NewRouter(
+		WithAdminServer(&AdminServerConfig{Enabled: true}),
+		WithSecurityConfig(config.SecurityConfiguration{
+			PersistedOperationKillSwitch: config.PersistedOperationKillSwitchConfiguration{Hashes: []string{"aaaa"}},
+		}),
+	)
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	doRequest := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := doRequest(http.MethodGet, "/persisted-operations/blocked")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"hashes":["aaaa"]}`, rec.Body.String())
+
+	rec = doRequest(http.MethodPut, "/persisted-operations/blocked/BBBB")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"hashes":["aaaa","bbbb"]}`, rec.Body.String())
+	require.True(t, r.persistedOpKillSwitch.Blocked("bbbb"))
+
+	rec = doRequest(http.MethodDelete, "/persisted-operations/blocked/aaaa")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(http.MethodDelete, "/persisted-operations/blocked/bbbb")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"hashes":["aaaa"]}`, rec.Body.String())
+}
+
+func TestAdminServerPersistedOperationUsage(t *testing.T) {
+	r, err := NewRouter(
+		WithAdminServer(&AdminServerConfig{Enabled: true}),
+		WithPersistedOperationUsage(&config.PersistedOperationUsageConfiguration{Enabled: true}),
+	)
+	require.NoError(t, err)
+
+	now := time.Now()
+	r.persistedOpUsage.now = func() time.Time { return now.Add(-2 * time.Hour) }
+	r.persistedOpUsage.Track("web", "aaaa")
+	r.persistedOpUsage.now = func() time.Time { return now }
+	r.persistedOpUsage.Track("web", "bbbb")
+
+	handler := newTestAdminHandler(t, r)
+
+	doRequest := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	var usage adminPersistedOperationUsage
+	rec := doRequest("/persisted-operations/usage")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &usage))
+	require.Len(t, usage.Operations, 2)
+	// The least recently used operation comes first
+	require.Equal(t, "aaaa", usage.Operations[0].ID)
+
+	rec = doRequest("/persisted-operations/usage?unused_for=1h")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &usage))
+	require.Len(t, usage.Operations, 1)
+	require.Equal(t, "aaaa", usage.Operations[0].ID)
+	require.Equal(t, "web", usage.Operations[0].ClientName)
+	require.Equal(t, int64(1), usage.Operations[0].Hits)
+
+	rec = doRequest("/persisted-operations/usage?unused_for=yesterday")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminServerConfigChanges(t *testing.T) {
+	r, err := NewRouter(
+		WithAdminServer(&AdminServerConfig{Enabled: true}),
+		WithConfigAudit(&config.ConfigAuditConfiguration{Enabled: true, MaxEntries: 10}),
+	)
+	require.NoError(t, err)
+
+	v1 := &nodev1.RouterConfig{Version: "1", Subgraphs: []*nodev1.Subgraph{{Name: "employees"}}}
+	v2 := &nodev1.RouterConfig{Version: "2", Subgraphs: []*nodev1.Subgraph{{Name: "employees"}, {Name: "family"}}}
+	r.recordConfigChange(nil, v1, nil)
+	r.recordConfigChange(v1, v2, nil)
+
+	handler := newTestAdminHandler(t, r)
+
+	doRequest := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	var changes adminConfigChanges
+	rec := doRequest("/config/changes")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &changes))
+	require.Len(t, changes.Changes, 2)
+	require.Equal(t, "2", changes.Changes[0].Version)
+	require.Equal(t, ConfigSourceStatic, changes.Changes[0].Source)
+	require.Equal(t, ConfigSignatureUnverified, changes.Changes[0].Signature)
+	require.Equal(t, []string{"family"}, changes.Changes[0].Diff.AddedSubgraphs)
+
+	rec = doRequest("/config/changes?limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &changes))
+	require.Len(t, changes.Changes, 1)
+
+	rec = doRequest("/config/changes?limit=all")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminServerOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var issuer string
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case oidcDiscoveryPath:
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "admin",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(idp.Close)
+	issuer = idp.URL
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "admin"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{
+		Enabled: true,
+		Token:   "secret",
+		OIDC: AdminOIDCConfig{
+			Enabled:  true,
+			Issuer:   issuer,
+			Audience: "router-admin",
+		},
+	}))
+	require.NoError(t, err)
+
+	handler := newTestAdminHandler(t, r)
+
+	doRequest := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/maintenance", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	expires := time.Now().Add(time.Hour).Unix()
+
+	require.Equal(t, http.StatusOK, doRequest("secret"))
+	require.Equal(t, http.StatusOK, doRequest(sign(jwt.MapClaims{"iss": issuer, "aud": "router-admin", "exp": expires})))
+	require.Equal(t, http.StatusOK, doRequest(sign(jwt.MapClaims{"iss": issuer, "aud": []string{"other", "router-admin"}, "exp": expires})))
+	require.Equal(t, http.StatusUnauthorized, doRequest(sign(jwt.MapClaims{"iss": issuer, "aud": "other", "exp": expires})))
+	require.Equal(t, http.StatusUnauthorized, doRequest(sign(jwt.MapClaims{"iss": "https://evil.example.com", "aud": "router-admin", "exp": expires})))
+	require.Equal(t, http.StatusUnauthorized, doRequest(sign(jwt.MapClaims{"iss": issuer, "aud": "router-admin", "exp": time.Now().Add(-time.Hour).Unix()})))
+	require.Equal(t, http.StatusUnauthorized, doRequest("invalid"))
+}
+
+func TestAdminServerMTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeTestCertificate(t, dir)
+
+	r, err := NewRouter(WithAdminServer(&AdminServerConfig{
+		Enabled: true,
+		TLS: AdminTLSConfig{
+			Enabled:      true,
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: certFile,
+		},
+		Pprof: AdminPprofConfig{Enabled: true},
+	}))
+	// Client certificates are sufficient to enable the profiling endpoints
+	require.NoError(t, err)
+
+	svr, err := r.newAdminServer(context.Background())
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(svr.Handler)
+	ts.TLS = svr.TLSConfig
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(cert.Leaf)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}}}
+	_, err = client.Get(ts.URL + "/maintenance")
+	require.Error(t, err)
+
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{cert}}}}
+	resp, err := client.Get(ts.URL + "/maintenance")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// writeTestCertificate writes a self-signed certificate that is valid as server certificate for 127.0.0.1,
+// as client certificate and as CA
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string, cert tls.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "router-admin"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+
+	cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	cert.Leaf, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return certFile, keyFile, cert
+}
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type AnomalySignal string
+
+const (
+	// AnomalySignalErrorLogs counts the entries of the router logger with level error or above
+	AnomalySignalErrorLogs AnomalySignal = "error_logs"
+	// AnomalySignalServerErrors counts the GraphQL requests that were answered with a 5xx status code
+	AnomalySignalServerErrors AnomalySignal = "server_errors"
+)
+
+const anomalyLoggerName = "anomaly"
+
+type AnomalyDetectorOptions struct {
+	Logger *zap.Logger
+	// Interval is the period the events are counted in. A spike is detected at the end of an interval.
+	Interval time.Duration
+	// BaselineIntervals is the number of previous intervals the baseline rate is computed from
+	BaselineIntervals int
+	// Threshold is the factor the count of an interval must exceed the baseline by to be a spike
+	Threshold float64
+	// MinEvents is the minimum count of an interval to be a spike, so that a few errors on an idle router don't alert
+	MinEvents int64
+	// WebhookURL is called with a POST request for every alert. Empty disables the webhook.
+	WebhookURL     string
+	WebhookTimeout time.Duration
+}
+
+// AnomalyDetector notices sudden spikes of error logs and server errors. Every interval, the count of the events is
+// compared with the average of the previous intervals. When it exceeds the baseline by the threshold, a single alert
+// is logged, and optionally sent to a webhook, until the rate is back to normal.
+type AnomalyDetector struct {
+	logger            *zap.Logger
+	interval          time.Duration
+	baselineIntervals int
+	threshold         float64
+	minEvents         int64
+	webhookURL        string
+	httpClient        *http.Client
+
+	signals map[AnomalySignal]*anomalySignalState
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	// webhooks tracks the in-flight webhook calls, so that Shutdown can wait for them
+	webhooks sync.WaitGroup
+}
+
+type anomalySignalState struct {
+	current atomic.Int64
+	// history holds the counts of the previous intervals, the oldest first
+	history  []int64
+	alerting bool
+}
+
+// AnomalyAlert is the payload of the webhook
+type AnomalyAlert struct {
+	Signal    AnomalySignal `json:"signal"`
+	Count     int64         `json:"count"`
+	Baseline  float64       `json:"baseline"`
+	Threshold float64       `json:"threshold"`
+	Interval  string        `json:"interval"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+func NewAnomalyDetector(opts *AnomalyDetectorOptions) (*AnomalyDetector, error) {
+	if opts.Interval <= 0 {
+		return nil, errors.New("anomaly detection requires an interval")
+	}
+	if opts.BaselineIntervals < 1 {
+		return nil, errors.New("anomaly detection requires at least one baseline interval")
+	}
+	if opts.Threshold <= 1 {
+		return nil, fmt.Errorf("anomaly detection threshold must be greater than 1, got %v", opts.Threshold)
+	}
+
+	timeout := opts.WebhookTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &AnomalyDetector{
+		logger:            opts.Logger.Named(anomalyLoggerName),
+		interval:          opts.Interval,
+		baselineIntervals: opts.BaselineIntervals,
+		threshold:         opts.Threshold,
+		minEvents:         opts.MinEvents,
+		webhookURL:        opts.WebhookURL,
+		httpClient:        &http.Client{Timeout: timeout},
+		signals: map[AnomalySignal]*anomalySignalState{
+			AnomalySignalErrorLogs:    {},
+			AnomalySignalServerErrors: {},
+		},
+	}, nil
+}
+
+// Observe counts an event of the signal in the current interval
+func (d *AnomalyDetector) Observe(signal AnomalySignal) {
+	if state, ok := d.signals[signal]; ok {
+		state.current.Add(1)
+	}
+}
+
+// WrapCore returns a core that counts the error entries of the logger. The alerts of the detector aren't counted.
+func (d *AnomalyDetector) WrapCore(core zapcore.Core) zapcore.Core {
+	return &anomalyCore{Core: core, detector: d}
+}
+
+func (d *AnomalyDetector) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d.mu.Lock()
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	d.mu.Unlock()
+
+	go func() {
+		defer close(d.done)
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				d.evaluate()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (d *AnomalyDetector) Shutdown() {
+	d.mu.Lock()
+	cancel, done := d.cancel, d.done
+	d.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	d.webhooks.Wait()
+}
+
+// evaluate closes the current interval of all signals and alerts on spikes
+func (d *AnomalyDetector) evaluate() {
+	now := time.Now()
+
+	for signal, state := range d.signals {
+		count := state.current.Swap(0)
+
+		// Without history there is no baseline to compare with
+		if len(state.history) > 0 {
+			baseline := averageCount(state.history)
+			spike := count >= d.minEvents && float64(count) > d.threshold*baseline
+
+			if spike && !state.alerting {
+				d.alert(AnomalyAlert{
+					Signal:    signal,
+					Count:     count,
+					Baseline:  baseline,
+					Threshold: d.threshold,
+					Interval:  d.interval.String(),
+					Timestamp: now,
+				})
+			} else if !spike && state.alerting {
+				d.logger.Info("Spike resolved",
+					zap.String("signal", string(signal)),
+					zap.Int64("count", count),
+					zap.Float64("baseline", baseline),
+				)
+			}
+			state.alerting = spike
+		}
+
+		state.history = append(state.history, count)
+		if len(state.history) > d.baselineIntervals {
+			state.history = state.history[1:]
+		}
+	}
+}
+
+func (d *AnomalyDetector) alert(alert AnomalyAlert) {
+	d.logger.Error("Spike detected",
+		zap.String("signal", string(alert.Signal)),
+		zap.Int64("count", alert.Count),
+		zap.Float64("baseline", alert.Baseline),
+		zap.Float64("threshold", alert.Threshold),
+		zap.Duration("interval", d.interval),
+	)
+
+	if d.webhookURL == "" {
+		return
+	}
+
+	d.webhooks.Add(1)
+	go func() {
+		defer d.webhooks.Done()
+
+		if err := d.callWebhook(alert); err != nil {
+			d.logger.Warn("Failed to call the anomaly webhook", zap.String("url", d.webhookURL), zap.Error(err))
+		}
+	}()
+}
+
+func (d *AnomalyDetector) callWebhook(alert AnomalyAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func averageCount(values []int64) float64 {
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	return float64(sum) / float64(len(values))
+}
+
+type anomalyCore struct {
+	zapcore.Core
+	detector *AnomalyDetector
+}
+
+// Enabled is always true for errors, so that they are counted even when the level of the logger is higher
+func (c *anomalyCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.ErrorLevel || c.Core.Enabled(level)
+}
+
+func (c *anomalyCore) With(fields []zapcore.Field) zapcore.Core {
+	return &anomalyCore{Core: c.Core.With(fields), detector: c.detector}
+}
+
+func (c *anomalyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level >= zapcore.ErrorLevel && ent.LoggerName != c.detector.logger.Name() {
+		c.detector.Observe(AnomalySignalErrorLogs)
+	}
+	return c.Core.Check(ent, ce)
+}
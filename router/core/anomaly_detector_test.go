@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestAnomalyDetector(t *testing.T, webhookURL string) (*AnomalyDetector, *observer.ObservedLogs) {
+	t.Helper()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	d, err := NewAnomalyDetector(&AnomalyDetectorOptions{
+		Logger:            zap.New(core),
+		Interval:          time.Minute,
+		BaselineIntervals: 3,
+		Threshold:         5,
+		MinEvents:         10,
+		WebhookURL:        webhookURL,
+	})
+	require.NoError(t, err)
+
+	return d, logs
+}
+
+func observeN(d *AnomalyDetector, signal AnomalySignal, n int) {
+	for i := 0; i < n; i++ {
+		d.Observe(signal)
+	}
+}
+
+func anomalyLogs(logs *observer.ObservedLogs, message string) *observer.ObservedLogs {
+	return logs.Filter(func(e observer.LoggedEntry) bool {
+		return e.LoggerName == anomalyLoggerName && e.Message == message
+	})
+}
+
+func TestAnomalyDetector(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates the options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewAnomalyDetector(&AnomalyDetectorOptions{Logger: zap.NewNop(), Interval: time.Minute, BaselineIntervals: 1, Threshold: 1})
+		require.Error(t, err)
+		_, err = NewAnomalyDetector(&AnomalyDetectorOptions{Logger: zap.NewNop(), BaselineIntervals: 1, Threshold: 2})
+		require.Error(t, err)
+	})
+
+	t.Run("alerts once per spike", func(t *testing.T) {
+		t.Parallel()
+
+		d, logs := newTestAnomalyDetector(t, "")
+
+		for i := 0; i < 3; i++ {
+			observeN(d, AnomalySignalServerErrors, 2)
+			d.evaluate()
+		}
+		require.Zero(t, anomalyLogs(logs, "Spike detected").Len())
+
+		observeN(d, AnomalySignalServerErrors, 20)
+		d.evaluate()
+		observeN(d, AnomalySignalServerErrors, 100)
+		d.evaluate()
+
+		alerts := anomalyLogs(logs, "Spike detected").All()
+		require.Len(t, alerts, 1)
+		require.Equal(t, zapcore.ErrorLevel, alerts[0].Level)
+		fields := alerts[0].ContextMap()
+		require.Equal(t, string(AnomalySignalServerErrors), fields["signal"])
+		require.Equal(t, int64(20), fields["count"])
+		require.Equal(t, 2.0, fields["baseline"])
+
+		d.evaluate()
+		require.Equal(t, 1, anomalyLogs(logs, "Spike resolved").Len())
+	})
+
+	t.Run("doesn't alert below the minimum number of events", func(t *testing.T) {
+		t.Parallel()
+
+		d, logs := newTestAnomalyDetector(t, "")
+
+		d.evaluate()
+		observeN(d, AnomalySignalServerErrors, 9)
+		d.evaluate()
+
+		require.Zero(t, anomalyLogs(logs, "Spike detected").Len())
+	})
+
+	t.Run("counts error logs but not its own alerts", func(t *testing.T) {
+		t.Parallel()
+
+		d, logs := newTestAnomalyDetector(t, "")
+		logger := zap.New(d.WrapCore(zapcore.NewNopCore()))
+
+		d.evaluate()
+		for i := 0; i < 10; i++ {
+			logger.Error("failed")
+			logger.Warn("not counted")
+		}
+		d.evaluate()
+		require.Equal(t, 1, anomalyLogs(logs, "Spike detected").Len())
+
+		wrapped := zap.New(d.WrapCore(zapcore.NewNopCore())).Named(anomalyLoggerName)
+		wrapped.Error("Spike detected")
+		require.Zero(t, d.signals[AnomalySignalErrorLogs].current.Load())
+	})
+
+	t.Run("calls the webhook", func(t *testing.T) {
+		t.Parallel()
+
+		alerts := make(chan AnomalyAlert, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var alert AnomalyAlert
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+			alerts <- alert
+		}))
+		t.Cleanup(server.Close)
+
+		d, _ := newTestAnomalyDetector(t, server.URL)
+
+		d.evaluate()
+		observeN(d, AnomalySignalErrorLogs, 10)
+		d.evaluate()
+		d.Shutdown()
+
+		select {
+		case alert := <-alerts:
+			require.Equal(t, AnomalySignalErrorLogs, alert.Signal)
+			require.Equal(t, int64(10), alert.Count)
+			require.Equal(t, "1m0s", alert.Interval)
+		default:
+			t.Fatal("webhook was not called")
+		}
+	})
+}
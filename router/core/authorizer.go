@@ -7,26 +7,33 @@ import (
 	"slices"
 	"sync"
 
+	"github.com/go-chi/chi/v5/middleware"
+
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
 	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 )
 
 type CosmoAuthorizerOptions struct {
 	FieldConfigurations           []*nodev1.FieldConfiguration
 	RejectOperationIfUnauthorized bool
+	// AuditLogger records the authorization denials. Optional.
+	AuditLogger *logging.AuditLogger
 }
 
 func NewCosmoAuthorizer(opts *CosmoAuthorizerOptions) *CosmoAuthorizer {
 	return &CosmoAuthorizer{
 		fieldConfigurations: opts.FieldConfigurations,
 		rejectUnauthorized:  opts.RejectOperationIfUnauthorized,
+		audit:               opts.AuditLogger,
 	}
 }
 
 type CosmoAuthorizer struct {
 	fieldConfigurations []*nodev1.FieldConfiguration
 	rejectUnauthorized  bool
+	audit               *logging.AuditLogger
 }
 
 func (a *CosmoAuthorizer) HasResponseExtensionData(ctx *resolve.Context) bool {
@@ -68,13 +75,32 @@ func (a *CosmoAuthorizer) handleRejectUnauthorized(result *resolve.Authorization
 func (a *CosmoAuthorizer) AuthorizePreFetch(ctx *resolve.Context, dataSourceID string, input json.RawMessage, coordinate resolve.GraphCoordinate) (result *resolve.AuthorizationDeny, err error) {
 	isAuthenticated, actual := a.getAuth(ctx.Context())
 	required := a.requiredScopesForField(coordinate)
-	return a.handleRejectUnauthorized(a.validateScopes(ctx, coordinate, required, isAuthenticated, actual))
+	return a.handleRejectUnauthorized(a.auditDenial(ctx, coordinate, a.validateScopes(ctx, coordinate, required, isAuthenticated, actual)))
 }
 
 func (a *CosmoAuthorizer) AuthorizeObjectField(ctx *resolve.Context, dataSourceID string, object json.RawMessage, coordinate resolve.GraphCoordinate) (result *resolve.AuthorizationDeny, err error) {
 	isAuthenticated, actual := a.getAuth(ctx.Context())
 	required := a.requiredScopesForField(coordinate)
-	return a.handleRejectUnauthorized(a.validateScopes(ctx, coordinate, required, isAuthenticated, actual))
+	return a.handleRejectUnauthorized(a.auditDenial(ctx, coordinate, a.validateScopes(ctx, coordinate, required, isAuthenticated, actual)))
+}
+
+// auditDenial records the denied access to the field in the audit log and the execution audit record
+func (a *CosmoAuthorizer) auditDenial(ctx *resolve.Context, coordinate resolve.GraphCoordinate, result *resolve.AuthorizationDeny) *resolve.AuthorizationDeny {
+	if result == nil {
+		return result
+	}
+	executionAuditOf(ctx.Context()).authorizationDenied(coordinate.TypeName, coordinate.FieldName)
+	if a.audit == nil {
+		return result
+	}
+	var claims map[string]any
+	if auth := authentication.FromContext(ctx.Context()); auth != nil {
+		claims = auth.Claims()
+	}
+	a.audit.AuthorizationDenied(coordinate.TypeName, coordinate.FieldName, result.Reason, claims,
+		logging.WithRequestID(middleware.GetReqID(ctx.Context())),
+	)
+	return result
 }
 
 func (a *CosmoAuthorizer) validateScopes(ctx *resolve.Context, coordinate resolve.GraphCoordinate, requiredOrScopes []*nodev1.Scopes, isAuthenticated bool, actual []string) (result *resolve.AuthorizationDeny) {
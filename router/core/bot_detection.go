@@ -0,0 +1,310 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type BotDetectionAction string
+
+const (
+	// BotDetectionActionTag only sets the score header and logs the flagged requests
+	BotDetectionActionTag BotDetectionAction = "tag"
+	// BotDetectionActionRateLimit limits the flagged requests of a client to a lower rate
+	BotDetectionActionRateLimit BotDetectionAction = "rate_limit"
+	// BotDetectionActionBlock rejects the flagged requests
+	BotDetectionActionBlock BotDetectionAction = "block"
+)
+
+const (
+	botLoggerName = "bot"
+	maxBotScore   = 100
+	// maxBotTrackedClients bounds the memory of the per client counters
+	maxBotTrackedClients = 100_000
+)
+
+// The weights of the heuristics. A request is flagged when the sum reaches the threshold.
+const (
+	botScoreMissingUserAgent    = 30
+	botScoreAutomationUserAgent = 30
+	botScoreMissingAccept       = 10
+	botScoreMissingLanguage     = 10
+	botScoreClientHintsMismatch = 25
+	botScoreBurst               = 40
+)
+
+const (
+	botReasonMissingUserAgent    = "missing_user_agent"
+	botReasonAutomationUserAgent = "automation_user_agent"
+	botReasonMissingAccept       = "missing_accept"
+	botReasonMissingLanguage     = "missing_accept_language"
+	botReasonClientHintsMismatch = "client_hints_mismatch"
+	botReasonBurst               = "burst"
+)
+
+var (
+	ErrBotDetected         = errors.New("the request was classified as automated traffic")
+	ErrBotRateLimitReached = errors.New("too many requests. Please try again later")
+
+	automationUserAgents = []string{
+		"bot", "crawler", "spider", "scrapy", "curl", "wget", "python-requests", "python-urllib", "aiohttp",
+		"go-http-client", "java/", "okhttp", "libwww-perl", "headlesschrome", "phantomjs", "puppeteer", "playwright",
+	}
+)
+
+type BotDetectorOptions struct {
+	Logger    *zap.Logger
+	Action    BotDetectionAction
+	Threshold int
+	// TagHeader is set on the request with the score, so that modules and header rules can use it. Empty disables it.
+	TagHeader string
+	// LogScores logs every request with a score, not only the flagged ones, for tuning the threshold
+	LogScores bool
+	// BurstWindow and BurstMaxRequests define a burst: more than BurstMaxRequests requests of a client in BurstWindow
+	BurstWindow      time.Duration
+	BurstMaxRequests int
+	// RateLimitWindow and RateLimitMaxRequests limit the flagged requests of a client with the rate_limit action
+	RateLimitWindow      time.Duration
+	RateLimitMaxRequests int
+}
+
+// BotDetector scores requests with lightweight heuristics for automated traffic: missing standard headers, user
+// agents of automation tools, client hints that contradict the user agent and bursts of requests of a client.
+// Depending on the action, flagged requests are only tagged, rate limited or blocked.
+type BotDetector struct {
+	logger    *zap.Logger
+	action    BotDetectionAction
+	threshold int
+	tagHeader string
+	logScores bool
+
+	burstMaxRequests     int
+	rateLimitMaxRequests int
+	bursts               *clientWindowCounter
+	rateLimits           *clientWindowCounter
+}
+
+type BotScore struct {
+	Score   int
+	Reasons []string
+}
+
+func NewBotDetector(opts *BotDetectorOptions) (*BotDetector, error) {
+	switch opts.Action {
+	case BotDetectionActionTag, BotDetectionActionBlock:
+	case BotDetectionActionRateLimit:
+		if opts.RateLimitWindow <= 0 || opts.RateLimitMaxRequests < 1 {
+			return nil, errors.New("the bot detection rate limit requires a window and a maximum number of requests")
+		}
+	default:
+		return nil, fmt.Errorf("unknown bot detection action '%s'", opts.Action)
+	}
+	if opts.Threshold < 1 || opts.Threshold > maxBotScore {
+		return nil, fmt.Errorf("the bot detection threshold must be between 1 and %d, got %d", maxBotScore, opts.Threshold)
+	}
+
+	d := &BotDetector{
+		logger:               opts.Logger.Named(botLoggerName),
+		action:               opts.Action,
+		threshold:            opts.Threshold,
+		tagHeader:            opts.TagHeader,
+		logScores:            opts.LogScores,
+		burstMaxRequests:     opts.BurstMaxRequests,
+		rateLimitMaxRequests: opts.RateLimitMaxRequests,
+	}
+	if opts.BurstWindow > 0 && opts.BurstMaxRequests > 0 {
+		d.bursts = newClientWindowCounter(opts.BurstWindow)
+	}
+	if opts.Action == BotDetectionActionRateLimit {
+		d.rateLimits = newClientWindowCounter(opts.RateLimitWindow)
+	}
+
+	return d, nil
+}
+
+func (d *BotDetector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		client := botClientKey(r)
+		score := d.Score(r, client, now)
+		flagged := score.Score >= d.threshold
+
+		if d.tagHeader != "" {
+			// Overwrites a header sent by the client, so the score can't be spoofed
+			r.Header.Set(d.tagHeader, strconv.Itoa(score.Score))
+		}
+
+		if flagged || (d.logScores && score.Score > 0) {
+			d.logger.Info("Scored request",
+				zap.String("client", client),
+				zap.Int("score", score.Score),
+				zap.Strings("reasons", score.Reasons),
+				zap.Bool("flagged", flagged),
+				zap.String("user_agent", r.UserAgent()),
+				zap.String("path", r.URL.Path),
+			)
+		}
+
+		if flagged {
+			switch d.action {
+			case BotDetectionActionBlock:
+				writeHTTPError(r, w, http.StatusForbidden, ErrBotDetected, d.logger)
+				return
+			case BotDetectionActionRateLimit:
+				if count, resetAt := d.rateLimits.increment(client, now); count > d.rateLimitMaxRequests {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(resetAt.Sub(now).Seconds()))))
+					writeHTTPError(r, w, http.StatusTooManyRequests, ErrBotRateLimitReached, d.logger)
+					return
+				}
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Score returns the bot score of the request between 0 and 100 with the reasons that contributed to it
+func (d *BotDetector) Score(r *http.Request, client string, now time.Time) BotScore {
+	var score BotScore
+	add := func(points int, reason string) {
+		score.Score += points
+		score.Reasons = append(score.Reasons, reason)
+	}
+
+	userAgent := r.UserAgent()
+	if userAgent == "" {
+		add(botScoreMissingUserAgent, botReasonMissingUserAgent)
+	} else if isAutomationUserAgent(userAgent) {
+		add(botScoreAutomationUserAgent, botReasonAutomationUserAgent)
+	}
+	if r.Header.Get("Accept") == "" {
+		add(botScoreMissingAccept, botReasonMissingAccept)
+	}
+	if r.Header.Get("Accept-Language") == "" {
+		add(botScoreMissingLanguage, botReasonMissingLanguage)
+	}
+	if userAgent != "" && clientHintsMismatch(r.Header, userAgent) {
+		add(botScoreClientHintsMismatch, botReasonClientHintsMismatch)
+	}
+	if d.bursts != nil {
+		if count, _ := d.bursts.increment(client, now); count > d.burstMaxRequests {
+			add(botScoreBurst, botReasonBurst)
+		}
+	}
+
+	score.Score = min(score.Score, maxBotScore)
+
+	return score
+}
+
+func isAutomationUserAgent(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, s := range automationUserAgents {
+		if strings.Contains(ua, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientHintsMismatch detects user agent client hints that a browser with the user agent wouldn't send.
+// Client hints are only sent by Chromium based browsers.
+func clientHintsMismatch(header http.Header, userAgent string) bool {
+	brands := header.Get("Sec-CH-UA")
+	mobile := header.Get("Sec-CH-UA-Mobile")
+	platform := strings.Trim(header.Get("Sec-CH-UA-Platform"), `"`)
+
+	if brands == "" && mobile == "" && platform == "" {
+		return false
+	}
+	if !strings.Contains(userAgent, "Chrome/") && !strings.Contains(userAgent, "Chromium/") {
+		return true
+	}
+	if mobile == "?1" && !strings.Contains(userAgent, "Mobile") {
+		return true
+	}
+
+	switch platform {
+	case "Windows":
+		return !strings.Contains(userAgent, "Windows")
+	case "macOS":
+		return !strings.Contains(userAgent, "Macintosh")
+	case "Android":
+		return !strings.Contains(userAgent, "Android")
+	case "Linux":
+		return !strings.Contains(userAgent, "Linux")
+	case "iOS":
+		// Chromium on iOS doesn't send client hints
+		return true
+	}
+
+	return false
+}
+
+// botClientKey identifies the client by its IP. The IP is taken from the proxy headers by the RealIP middleware.
+func botClientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientWindowCounter counts the requests of the clients in fixed windows
+type clientWindowCounter struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*clientWindow
+}
+
+type clientWindow struct {
+	start time.Time
+	count int
+}
+
+func newClientWindowCounter(window time.Duration) *clientWindowCounter {
+	return &clientWindowCounter{
+		window:  window,
+		clients: make(map[string]*clientWindow),
+	}
+}
+
+// increment counts a request of the client and returns the count of the current window and its end
+func (c *clientWindowCounter) increment(client string, now time.Time) (int, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w, ok := c.clients[client]
+	if !ok || now.Sub(w.start) >= c.window {
+		if !ok && len(c.clients) >= maxBotTrackedClients {
+			c.prune(now)
+		}
+		w = &clientWindow{start: now}
+		c.clients[client] = w
+	}
+	w.count++
+
+	return w.count, w.start.Add(c.window)
+}
+
+// prune removes the clients with expired windows, or all clients if none has expired
+func (c *clientWindowCounter) prune(now time.Time) {
+	for client, w := range c.clients {
+		if now.Sub(w.start) >= c.window {
+			delete(c.clients, client)
+		}
+	}
+	if len(c.clients) >= maxBotTrackedClients {
+		clear(c.clients)
+	}
+}
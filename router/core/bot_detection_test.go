@@ -0,0 +1,139 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const chromeUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+func newBrowserRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	r.Header.Set("User-Agent", chromeUserAgent)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Accept-Language", "en-US")
+	r.Header.Set("Sec-CH-UA", `"Chromium";v="124", "Google Chrome";v="124"`)
+	r.Header.Set("Sec-CH-UA-Mobile", "?0")
+	r.Header.Set("Sec-CH-UA-Platform", `"Windows"`)
+	return r
+}
+
+func TestBotDetectorScore(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewBotDetector(&BotDetectorOptions{Logger: zap.NewNop(), Action: BotDetectionActionTag, Threshold: 50})
+	require.NoError(t, err)
+
+	score := d.Score(newBrowserRequest(), "1.2.3.4", time.Now())
+	require.Zero(t, score.Score)
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	score = d.Score(r, "1.2.3.4", time.Now())
+	require.Equal(t, 50, score.Score)
+	require.Equal(t, []string{botReasonMissingUserAgent, botReasonMissingAccept, botReasonMissingLanguage}, score.Reasons)
+
+	r = newBrowserRequest()
+	r.Header.Set("User-Agent", "python-requests/2.31.0")
+	score = d.Score(r, "1.2.3.4", time.Now())
+	require.Equal(t, []string{botReasonAutomationUserAgent, botReasonClientHintsMismatch}, score.Reasons)
+
+	r = newBrowserRequest()
+	r.Header.Set("Sec-CH-UA-Platform", `"macOS"`)
+	score = d.Score(r, "1.2.3.4", time.Now())
+	require.Equal(t, []string{botReasonClientHintsMismatch}, score.Reasons)
+
+	r = newBrowserRequest()
+	r.Header.Set("Sec-CH-UA-Mobile", "?1")
+	score = d.Score(r, "1.2.3.4", time.Now())
+	require.Equal(t, []string{botReasonClientHintsMismatch}, score.Reasons)
+}
+
+func TestBotDetectorMiddleware(t *testing.T) {
+	t.Parallel()
+
+	serve := func(d *BotDetector, r *http.Request) (*httptest.ResponseRecorder, string) {
+		var tag string
+		rec := httptest.NewRecorder()
+		d.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tag = r.Header.Get("X-Bot-Score")
+		})).ServeHTTP(rec, r)
+		return rec, tag
+	}
+
+	t.Run("tags and logs flagged requests", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		d, err := NewBotDetector(&BotDetectorOptions{Logger: zap.New(core), Action: BotDetectionActionTag, Threshold: 50, TagHeader: "X-Bot-Score"})
+		require.NoError(t, err)
+
+		r := newBrowserRequest()
+		r.Header.Set("X-Bot-Score", "0")
+		r.Header.Del("User-Agent")
+		r.Header.Del("Accept")
+		r.Header.Del("Accept-Language")
+		rec, tag := serve(d, r)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "50", tag)
+
+		entries := logs.Filter(func(e observer.LoggedEntry) bool { return e.LoggerName == botLoggerName }).All()
+		require.Len(t, entries, 1)
+		require.Equal(t, int64(50), entries[0].ContextMap()["score"])
+		require.Equal(t, true, entries[0].ContextMap()["flagged"])
+
+		_, tag = serve(d, newBrowserRequest())
+		require.Equal(t, "0", tag)
+		require.Equal(t, 1, logs.Len())
+	})
+
+	t.Run("blocks flagged requests", func(t *testing.T) {
+		t.Parallel()
+
+		d, err := NewBotDetector(&BotDetectorOptions{Logger: zap.NewNop(), Action: BotDetectionActionBlock, Threshold: 30})
+		require.NoError(t, err)
+
+		rec, _ := serve(d, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		require.Equal(t, http.StatusForbidden, rec.Code)
+		require.Contains(t, rec.Body.String(), ErrBotDetected.Error())
+
+		rec, _ = serve(d, newBrowserRequest())
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("rate limits flagged requests of bursting clients", func(t *testing.T) {
+		t.Parallel()
+
+		d, err := NewBotDetector(&BotDetectorOptions{
+			Logger:               zap.NewNop(),
+			Action:               BotDetectionActionRateLimit,
+			Threshold:            40,
+			BurstWindow:          time.Minute,
+			BurstMaxRequests:     3,
+			RateLimitWindow:      time.Minute,
+			RateLimitMaxRequests: 2,
+		})
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			rec, _ := serve(d, newBrowserRequest())
+			require.Equal(t, http.StatusOK, rec.Code, "request %d", i)
+		}
+
+		rec, _ := serve(d, newBrowserRequest())
+		require.Equal(t, http.StatusTooManyRequests, rec.Code)
+		require.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+		// Other clients aren't affected
+		r := newBrowserRequest()
+		r.RemoteAddr = "10.0.0.1:1234"
+		rec, _ = serve(d, r)
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+}
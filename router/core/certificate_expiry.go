@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+type CertificateExpiryCheckOptions struct {
+	Logger   *zap.Logger
+	Interval time.Duration
+	// WarnBefore is the remaining time in which a warning is logged for a certificate
+	WarnBefore time.Duration
+}
+
+// CertificateExpiryCheck checks periodically when the TLS certificates loaded by the router expire. The remaining
+// time is exported as a metric and a warning is logged for the certificates that expire within WarnBefore, so that
+// they are renewed before the clients fail to connect.
+type CertificateExpiryCheck struct {
+	logger     *zap.Logger
+	interval   time.Duration
+	warnBefore time.Duration
+	now        func() time.Time
+
+	mu     sync.Mutex
+	certs  []expiringCertificate
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type expiringCertificate struct {
+	// listener is the listener that serves the certificate, e.g. graphql or admin
+	listener string
+	leaf     *x509.Certificate
+}
+
+func NewCertificateExpiryCheck(opts *CertificateExpiryCheckOptions) (*CertificateExpiryCheck, error) {
+	if opts.Interval <= 0 {
+		return nil, errors.New("the interval of the certificate expiry check must be positive")
+	}
+
+	return &CertificateExpiryCheck{
+		logger:     opts.Logger,
+		interval:   opts.Interval,
+		warnBefore: opts.WarnBefore,
+		now:        time.Now,
+	}, nil
+}
+
+// Add checks the leaf of the certificate served by the listener
+func (c *CertificateExpiryCheck) Add(listener string, cert *tls.Certificate) error {
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return fmt.Errorf("the certificate of the %s listener is empty", listener)
+		}
+		var err error
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse the certificate of the %s listener: %w", listener, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.certs = append(c.certs, expiringCertificate{listener: listener, leaf: leaf})
+	return nil
+}
+
+// Start checks the certificates in the interval in the background until Shutdown is called
+func (c *CertificateExpiryCheck) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	done := c.done
+	c.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			c.check()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown stops the checks
+func (c *CertificateExpiryCheck) Shutdown() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// check logs a warning for every certificate that expired or expires within warnBefore. The warning is repeated in
+// every interval until the certificate is renewed.
+func (c *CertificateExpiryCheck) check() {
+	now := c.now()
+
+	for _, cert := range c.certificates() {
+		remaining := cert.leaf.NotAfter.Sub(now)
+		if remaining > c.warnBefore {
+			continue
+		}
+
+		fields := []zap.Field{
+			zap.String("listener", cert.listener),
+			zap.String("subject", cert.leaf.Subject.String()),
+			zap.String("serial_number", cert.leaf.SerialNumber.String()),
+			zap.Time("not_after", cert.leaf.NotAfter),
+		}
+		if remaining <= 0 {
+			c.logger.Error("TLS certificate expired", fields...)
+			continue
+		}
+		c.logger.Warn("TLS certificate expires soon", append(fields, zap.Duration("remaining", remaining))...)
+	}
+}
+
+func (c *CertificateExpiryCheck) certificates() []expiringCertificate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]expiringCertificate(nil), c.certs...)
+}
+
+// RegisterMetrics exposes the remaining time of the certificates on the meter provider
+func (c *CertificateExpiryCheck) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	gauge, err := meter.Float64ObservableGauge(
+		"router.tls.certificate.expiry",
+		otelmetric.WithDescription("Time until the TLS certificate expires, negative for an expired certificate"),
+		otelmetric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		now := c.now()
+		for _, cert := range c.certificates() {
+			o.ObserveFloat64(gauge, cert.leaf.NotAfter.Sub(now).Seconds(), otelmetric.WithAttributes(
+				attribute.String("listener", cert.listener),
+				attribute.String("subject", cert.leaf.Subject.String()),
+			))
+		}
+		return nil
+	}, gauge)
+
+	return err
+}
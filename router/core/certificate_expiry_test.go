@@ -0,0 +1,47 @@
+package core
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCertificateExpiryCheck(t *testing.T) {
+	t.Parallel()
+
+	// The certificate expires in an hour
+	_, _, cert := writeTestCertificate(t, t.TempDir())
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	c, err := NewCertificateExpiryCheck(&CertificateExpiryCheckOptions{
+		Logger:     zap.New(core),
+		Interval:   time.Hour,
+		WarnBefore: 30 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Add(ResponseHeadersListenerGraphQL, &cert))
+	// The leaf is parsed when the certificate was loaded without it
+	require.NoError(t, c.Add(ResponseHeadersListenerAdmin, &tls.Certificate{Certificate: cert.Certificate}))
+	require.Error(t, c.Add(ResponseHeadersListenerAdmin, &tls.Certificate{}))
+
+	c.check()
+	require.Zero(t, logs.Len())
+
+	now := time.Now().Add(45 * time.Minute)
+	c.now = func() time.Time { return now }
+	c.check()
+	require.Equal(t, 2, logs.FilterMessage("TLS certificate expires soon").Len())
+	fields := logs.All()[0].ContextMap()
+	require.Equal(t, "graphql", fields["listener"])
+	require.Equal(t, "CN=router-admin", fields["subject"])
+
+	now = now.Add(time.Hour)
+	c.check()
+	require.Equal(t, 2, logs.FilterMessage("TLS certificate expired").Len())
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// ChaosInjector injects faults into the requests to the subgraphs, to validate the resilience settings of the router
+// safely. The faults are injected below the retries, so that a retried request can fail again or succeed.
+type ChaosInjector struct {
+	logger *zap.Logger
+	rules  []chaosRule
+	// random returns a number in [0,1) and is replaced in tests
+	random func() float64
+}
+
+type chaosRule struct {
+	subgraphs      []string
+	operations     []string
+	latencyRate    float64
+	latency        time.Duration
+	errorRate      float64
+	statusCode     int
+	dropConnection float64
+}
+
+func NewChaosInjector(logger *zap.Logger, cfg *config.ChaosConfiguration) (*ChaosInjector, error) {
+	rules := make([]chaosRule, 0, len(cfg.Rules))
+	for i, r := range cfg.Rules {
+		for _, rate := range []float64{r.Latency.Rate, r.Error.Rate, r.DropConnection.Rate} {
+			if rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid chaos rule %d: the rates must be between 0 and 1, got %v", i, rate)
+			}
+		}
+		if r.Latency.Rate > 0 && r.Latency.Duration <= 0 {
+			return nil, fmt.Errorf("invalid chaos rule %d: the latency requires a duration", i)
+		}
+
+		statusCode := r.Error.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusServiceUnavailable
+		}
+		if statusCode < 100 || statusCode > 599 {
+			return nil, fmt.Errorf("invalid chaos rule %d: invalid status code %d", i, statusCode)
+		}
+
+		rules = append(rules, chaosRule{
+			subgraphs:      r.Subgraphs,
+			operations:     r.Operations,
+			latencyRate:    r.Latency.Rate,
+			latency:        r.Latency.Duration,
+			errorRate:      r.Error.Rate,
+			statusCode:     statusCode,
+			dropConnection: r.DropConnection.Rate,
+		})
+	}
+
+	return &ChaosInjector{
+		logger: logger,
+		rules:  rules,
+		random: rand.Float64,
+	}, nil
+}
+
+// RoundTripper wraps the transport to the subgraphs with the injection of the faults
+func (c *ChaosInjector) RoundTripper(transport http.RoundTripper) http.RoundTripper {
+	return chaosTransport{injector: c, transport: transport}
+}
+
+type chaosTransport struct {
+	injector  *ChaosInjector
+	transport http.RoundTripper
+}
+
+func (t chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var subgraphName, operationName string
+	if reqContext := getRequestContext(req.Context()); reqContext != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			subgraphName = subgraph.Name
+		}
+		if reqContext.operation != nil {
+			operationName = reqContext.operation.name
+		}
+	}
+
+	rule := t.injector.match(subgraphName, operationName)
+	if rule == nil {
+		return t.transport.RoundTrip(req)
+	}
+
+	// The latency is added before the other faults, so that a slow failure can be injected as well
+	if rule.latencyRate > 0 && t.injector.random() < rule.latencyRate {
+		t.injector.logger.Debug("Injecting latency into the subgraph request",
+			zap.String("subgraph_name", subgraphName),
+			zap.Duration("latency", rule.latency),
+		)
+
+		timer := time.NewTimer(rule.latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	if rule.dropConnection > 0 && t.injector.random() < rule.dropConnection {
+		t.injector.logger.Debug("Dropping the connection of the subgraph request", zap.String("subgraph_name", subgraphName))
+
+		// The error looks like a reset by the subgraph, so that it is handled like one
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+
+	if rule.errorRate > 0 && t.injector.random() < rule.errorRate {
+		t.injector.logger.Debug("Injecting an error response into the subgraph request",
+			zap.String("subgraph_name", subgraphName),
+			zap.Int("status_code", rule.statusCode),
+		)
+
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+
+		return &http.Response{
+			StatusCode: rule.statusCode,
+			Status:     fmt.Sprintf("%d %s", rule.statusCode, http.StatusText(rule.statusCode)),
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"errors":[{"message":"fault injected by the router chaos mode"}]}`)),
+			Request:    req,
+		}, nil
+	}
+
+	return t.transport.RoundTrip(req)
+}
+
+// match returns the first rule that matches the subgraph and the operation
+func (c *ChaosInjector) match(subgraphName, operationName string) *chaosRule {
+	for i := range c.rules {
+		rule := &c.rules[i]
+		if len(rule.subgraphs) > 0 && !slices.Contains(rule.subgraphs, subgraphName) {
+			continue
+		}
+		if len(rule.operations) > 0 && !slices.Contains(rule.operations, operationName) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/internal/retrytransport"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+type chaosTestTransport struct {
+	requests int
+}
+
+func (t *chaosTestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(nil), Request: req}, nil
+}
+
+func newChaosTestRequest(t *testing.T, subgraphName, operationName string) *http.Request {
+	employeesURL, _ := url.Parse("http://employees.local/graphql")
+	productsURL, _ := url.Parse("http://products.local/graphql")
+
+	reqContext := &requestContext{
+		logger:    zap.NewNop(),
+		operation: &operationContext{name: operationName},
+		subgraphs: []Subgraph{
+			{Name: "employees", Id: "0", Url: employeesURL},
+			{Name: "products", Id: "1", Url: productsURL},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(withRequestContext(context.Background(), reqContext), http.MethodPost, "http://"+subgraphName+".local/graphql", nil)
+	require.NoError(t, err)
+	return req
+}
+
+func TestChaosInjector(t *testing.T) {
+	injector, err := NewChaosInjector(zap.NewNop(), &config.ChaosConfiguration{
+		Enabled: true,
+		Rules: []config.ChaosRule{
+			{
+				Subgraphs:      []string{"employees"},
+				Operations:     []string{"Employees"},
+				DropConnection: config.ChaosDropConnection{Rate: 1},
+			},
+			{
+				Subgraphs: []string{"employees"},
+				Error:     config.ChaosError{Rate: 1},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	next := &chaosTestTransport{}
+	transport := injector.RoundTripper(next)
+
+	// The first matching rule applies
+	_, err = transport.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+	require.EqualError(t, err, "read tcp: connection reset by peer")
+	require.True(t, retrytransport.IsRetryableError(err, nil))
+
+	resp, err := transport.RoundTrip(newChaosTestRequest(t, "employees", "Other"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"errors":[{"message":"fault injected by the router chaos mode"}]}`, string(body))
+	require.Equal(t, 0, next.requests)
+
+	// Requests that match no rule are sent unchanged
+	resp, err = transport.RoundTrip(newChaosTestRequest(t, "products", "Employees"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 1, next.requests)
+}
+
+func TestChaosInjectorRates(t *testing.T) {
+	injector, err := NewChaosInjector(zap.NewNop(), &config.ChaosConfiguration{
+		Enabled: true,
+		Rules: []config.ChaosRule{
+			{
+				Latency: config.ChaosLatency{Rate: 0.5, Duration: 20 * time.Millisecond},
+				Error:   config.ChaosError{Rate: 0.5, StatusCode: http.StatusBadGateway},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	next := &chaosTestTransport{}
+	transport := injector.RoundTripper(next)
+
+	// Below the rate the fault is injected
+	injector.random = func() float64 { return 0.4 }
+	start := time.Now()
+	resp, err := transport.RoundTrip(newChaosTestRequest(t, "products", ""))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	injector.random = func() float64 { return 0.5 }
+	resp, err = transport.RoundTrip(newChaosTestRequest(t, "products", ""))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 1, next.requests)
+
+	// The latency respects the cancellation of the request
+	injector.random = func() float64 { return 0 }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := newChaosTestRequest(t, "products", "")
+	_, err = transport.RoundTrip(req.WithContext(withRequestContext(ctx, getRequestContext(req.Context()))))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewChaosInjector(t *testing.T) {
+	_, err := NewChaosInjector(zap.NewNop(), &config.ChaosConfiguration{
+		Rules: []config.ChaosRule{{Error: config.ChaosError{Rate: 1.5}}},
+	})
+	require.EqualError(t, err, "invalid chaos rule 0: the rates must be between 0 and 1, got 1.5")
+
+	_, err = NewChaosInjector(zap.NewNop(), &config.ChaosConfiguration{
+		Rules: []config.ChaosRule{{Latency: config.ChaosLatency{Rate: 1}}},
+	})
+	require.EqualError(t, err, "invalid chaos rule 0: the latency requires a duration")
+
+	_, err = NewChaosInjector(zap.NewNop(), &config.ChaosConfiguration{
+		Rules: []config.ChaosRule{{Error: config.ChaosError{Rate: 1, StatusCode: 42}}},
+	})
+	require.EqualError(t, err, "invalid chaos rule 0: invalid status code 42")
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/wundergraph/cosmo/router/internal/wsproto"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+// ClientProtocol is the transport of the GraphQL operations of a client
+type ClientProtocol string
+
+const (
+	ClientProtocolHTTPPost ClientProtocol = "http_post"
+	ClientProtocolHTTPGet  ClientProtocol = "http_get"
+	// ClientProtocolAPQ is an operation sent with the persistedQuery extension instead of its query
+	ClientProtocolAPQ ClientProtocol = "apq"
+	// ClientProtocolMultipart is an operation with file uploads
+	ClientProtocolMultipart ClientProtocol = "multipart"
+	// ClientProtocolSSE is a subscription over HTTP
+	ClientProtocolSSE ClientProtocol = "sse"
+	// ClientProtocolGraphQLWS is the graphql-ws protocol with the graphql-transport-ws subprotocol
+	ClientProtocolGraphQLWS ClientProtocol = "graphql_ws"
+	// ClientProtocolSubscriptionsTransportWS is the legacy subscriptions-transport-ws protocol
+	ClientProtocolSubscriptionsTransportWS ClientProtocol = "subscriptions_transport_ws"
+	ClientProtocolAbsinthe                 ClientProtocol = "absinthe"
+)
+
+// httpClientProtocol returns the protocol of an operation over HTTP
+func httpClientProtocol(r *http.Request, operation *ParsedOperation, operationType string) ClientProtocol {
+	switch {
+	case operationType == "subscription":
+		return ClientProtocolSSE
+	case operation.IsPersistedOperation:
+		return ClientProtocolAPQ
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data"):
+		return ClientProtocolMultipart
+	case r.Method == http.MethodGet:
+		return ClientProtocolHTTPGet
+	default:
+		return ClientProtocolHTTPPost
+	}
+}
+
+// webSocketClientProtocol returns the protocol of a WebSocket subprotocol
+func webSocketClientProtocol(subProtocol string) ClientProtocol {
+	switch subProtocol {
+	case wsproto.SubscriptionsTransportWSSubprotocol:
+		return ClientProtocolSubscriptionsTransportWS
+	case wsproto.AbsintheWSSubProtocol:
+		return ClientProtocolAbsinthe
+	default:
+		return ClientProtocolGraphQLWS
+	}
+}
+
+// ClientProtocols counts the operations by the protocol and the name of the client, so that the migrations of the
+// clients between the protocols can be tracked
+type ClientProtocols struct {
+	mu            sync.Mutex
+	operations    map[clientProtocolKey]int64
+	registrations []otelmetric.Registration
+}
+
+type clientProtocolKey struct {
+	protocol   ClientProtocol
+	clientName string
+}
+
+func NewClientProtocols() *ClientProtocols {
+	return &ClientProtocols{
+		operations: map[clientProtocolKey]int64{},
+	}
+}
+
+// Record counts an operation of the client over the protocol
+func (c *ClientProtocols) Record(protocol ClientProtocol, clientName string) {
+	c.mu.Lock()
+	c.operations[clientProtocolKey{protocol: protocol, clientName: clientName}]++
+	c.mu.Unlock()
+}
+
+// Operations returns the number of operations of the client over the protocol
+func (c *ClientProtocols) Operations(protocol ClientProtocol, clientName string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.operations[clientProtocolKey{protocol: protocol, clientName: clientName}]
+}
+
+// RegisterMetrics exposes the operations on the meter provider
+func (c *ClientProtocols) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	operations, err := meter.Int64ObservableCounter(
+		"router.client.protocol.operations",
+		otelmetric.WithDescription("Number of operations by the protocol of the client, e.g. http_post, apq, sse or graphql_ws"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for key, count := range c.operations {
+			o.ObserveInt64(operations, count, otelmetric.WithAttributes(
+				attribute.String("protocol", string(key.protocol)),
+				otel.WgClientName.String(key.clientName),
+			))
+		}
+		return nil
+	}, operations)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.registrations = append(c.registrations, reg)
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *ClientProtocols) Shutdown() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for _, reg := range c.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	c.registrations = nil
+
+	return err
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/internal/wsproto"
+)
+
+func TestClientProtocols(t *testing.T) {
+	t.Parallel()
+
+	post := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	get := httptest.NewRequest(http.MethodGet, "/graphql?query={a}", nil)
+	upload := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	upload.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+
+	require.Equal(t, ClientProtocolHTTPPost, httpClientProtocol(post, &ParsedOperation{}, "query"))
+	require.Equal(t, ClientProtocolHTTPGet, httpClientProtocol(get, &ParsedOperation{}, "query"))
+	require.Equal(t, ClientProtocolAPQ, httpClientProtocol(get, &ParsedOperation{IsPersistedOperation: true}, "query"))
+	require.Equal(t, ClientProtocolMultipart, httpClientProtocol(upload, &ParsedOperation{}, "mutation"))
+	require.Equal(t, ClientProtocolSSE, httpClientProtocol(post, &ParsedOperation{}, "subscription"))
+
+	require.Equal(t, ClientProtocolGraphQLWS, webSocketClientProtocol(wsproto.GraphQLWSSubprotocol))
+	require.Equal(t, ClientProtocolSubscriptionsTransportWS, webSocketClientProtocol(wsproto.SubscriptionsTransportWSSubprotocol))
+	require.Equal(t, ClientProtocolAbsinthe, webSocketClientProtocol(wsproto.AbsintheWSSubProtocol))
+
+	protocols := NewClientProtocols()
+	protocols.Record(ClientProtocolHTTPPost, "web")
+	protocols.Record(ClientProtocolHTTPPost, "web")
+	protocols.Record(ClientProtocolGraphQLWS, "web")
+	require.Equal(t, int64(2), protocols.Operations(ClientProtocolHTTPPost, "web"))
+	require.Equal(t, int64(1), protocols.Operations(ClientProtocolGraphQLWS, "web"))
+	require.Zero(t, protocols.Operations(ClientProtocolHTTPPost, "ios"))
+}
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+const (
+	ClockSkewSourceHTTP = "http"
+	ClockSkewSourceNTP  = "ntp"
+
+	clockSkewTimeout = 10 * time.Second
+	// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and the Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+)
+
+type ClockSkewCheckOptions struct {
+	Logger *zap.Logger
+	// Source is the reference clock, ClockSkewSourceHTTP or ClockSkewSourceNTP
+	Source string
+	// URL is requested with the http source, the skew is measured with the Date header of the response
+	URL string
+	// NTPServer is the host and port of the NTP server that is queried with the ntp source
+	NTPServer string
+	Interval  time.Duration
+	// Threshold is the skew in either direction above which a warning is logged
+	Threshold  time.Duration
+	HTTPClient *http.Client
+}
+
+// ClockSkewCheck compares the system clock periodically with a reference clock. A skewed clock breaks the validation
+// of time based claims like the expiry of tokens, so the skew is exported as a metric and a skew above the threshold
+// is logged.
+type ClockSkewCheck struct {
+	logger    *zap.Logger
+	source    string
+	target    string
+	interval  time.Duration
+	threshold time.Duration
+	// offset measures the offset of the reference clock from the system clock
+	offset func(ctx context.Context) (time.Duration, error)
+
+	mu       sync.Mutex
+	skew     time.Duration
+	measured bool
+	warned   bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewClockSkewCheck(opts *ClockSkewCheckOptions) (*ClockSkewCheck, error) {
+	if opts.Interval <= 0 {
+		return nil, errors.New("the interval of the clock skew check must be positive")
+	}
+
+	c := &ClockSkewCheck{
+		logger:    opts.Logger,
+		source:    opts.Source,
+		interval:  opts.Interval,
+		threshold: opts.Threshold,
+	}
+
+	switch opts.Source {
+	case ClockSkewSourceHTTP, "":
+		if opts.URL == "" {
+			return nil, errors.New("the clock skew check with the http source requires a url")
+		}
+		client := opts.HTTPClient
+		if client == nil {
+			client = &http.Client{Timeout: clockSkewTimeout}
+		}
+		c.source = ClockSkewSourceHTTP
+		c.target = opts.URL
+		c.offset = func(ctx context.Context) (time.Duration, error) {
+			return httpClockOffset(ctx, client, opts.URL)
+		}
+	case ClockSkewSourceNTP:
+		if opts.NTPServer == "" {
+			return nil, errors.New("the clock skew check with the ntp source requires an ntp server")
+		}
+		c.target = opts.NTPServer
+		c.offset = func(ctx context.Context) (time.Duration, error) {
+			return ntpClockOffset(ctx, opts.NTPServer)
+		}
+	default:
+		return nil, fmt.Errorf("unknown clock skew source '%s', must be http or ntp", opts.Source)
+	}
+
+	return c, nil
+}
+
+// Start checks the clock in the interval in the background until Shutdown is called
+func (c *ClockSkewCheck) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	done := c.done
+	c.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			c.check(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown stops the checks and waits for a running check to return
+func (c *ClockSkewCheck) Shutdown() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (c *ClockSkewCheck) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, clockSkewTimeout)
+	defer cancel()
+
+	skew, err := c.offset(ctx)
+	if err != nil {
+		// A check that was stopped by the shutdown is not a failure
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		c.logger.Warn("Failed to check the clock skew",
+			zap.String("source", c.source),
+			zap.String("target", c.target),
+			zap.Error(err),
+		)
+		return
+	}
+
+	c.record(skew)
+}
+
+// record keeps the skew and logs when it exceeds the threshold and when it recovers
+func (c *ClockSkewCheck) record(skew time.Duration) {
+	c.mu.Lock()
+	c.skew = skew
+	c.measured = true
+	wasWarned := c.warned
+	c.warned = c.threshold > 0 && skew.Abs() > c.threshold
+	warned := c.warned
+	c.mu.Unlock()
+
+	if warned {
+		c.logger.Warn("The system clock is skewed. Time based validations like the expiry of tokens may fail",
+			zap.String("source", c.source),
+			zap.String("target", c.target),
+			zap.Duration("skew", skew),
+			zap.Duration("threshold", c.threshold),
+		)
+		return
+	}
+
+	if wasWarned {
+		c.logger.Info("The system clock is no longer skewed",
+			zap.String("source", c.source),
+			zap.Duration("skew", skew),
+		)
+	}
+}
+
+// Skew returns the last measured offset of the reference clock from the system clock. It is positive when the
+// system clock is behind. The second return value is false before the first successful check.
+func (c *ClockSkewCheck) Skew() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.skew, c.measured
+}
+
+// RegisterMetrics exposes the last measured skew on the meter provider
+func (c *ClockSkewCheck) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	gauge, err := meter.Float64ObservableGauge(
+		"router.clock.skew",
+		otelmetric.WithDescription("Offset of the reference clock from the system clock, positive when the system clock is behind"),
+		otelmetric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		skew, ok := c.Skew()
+		// Nothing to report before the first check, zero would claim a synchronized clock
+		if !ok {
+			return nil
+		}
+		o.ObserveFloat64(gauge, skew.Seconds())
+		return nil
+	}, gauge)
+
+	return err
+}
+
+// httpClockOffset measures the offset with the Date header of a response. The header has a resolution of a second,
+// so the server time is estimated in the middle of the second and compared with the middle of the round trip.
+func httpClockOffset(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	end := time.Now()
+	_ = resp.Body.Close()
+
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return 0, errors.New("the response has no Date header")
+	}
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Date header: %w", err)
+	}
+
+	local := start.Add(end.Sub(start) / 2)
+	return serverTime.Add(500 * time.Millisecond).Sub(local), nil
+}
+
+// ntpClockOffset queries the NTP server with a single SNTP request (RFC 4330) and computes the offset with the
+// timestamps of the request and the response
+func ntpClockOffset(ctx context.Context, server string) (time.Duration, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(clockSkewTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	// Leap indicator 0, version 3, mode 3 (client)
+	req := make([]byte, 48)
+	req[0] = 0x1B
+
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, err
+	}
+	received := time.Now()
+
+	if n < 48 {
+		return 0, fmt.Errorf("the NTP response has %d bytes, expected 48", n)
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	// Stratum 0 is a kiss-o'-death, the server refuses to serve the time
+	if resp[1] == 0 {
+		return 0, errors.New("the NTP server sent a kiss-o'-death")
+	}
+
+	serverReceived := ntpTime(resp[32:40])
+	serverTransmitted := ntpTime(resp[40:48])
+
+	return (serverReceived.Sub(sent) + serverTransmitted.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes a 64-bit NTP timestamp of seconds since 1900 and the fraction of a second
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, (fraction*int64(time.Second))>>32)
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestClockSkewCheck(t *testing.T) {
+	t.Parallel()
+
+	t.Run("measures the skew with the Date header", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Date", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+		}))
+		t.Cleanup(server.Close)
+
+		offset, err := httpClockOffset(context.Background(), server.Client(), server.URL)
+		require.NoError(t, err)
+		require.InDelta(t, -time.Minute, offset, float64(2*time.Second))
+	})
+
+	t.Run("measures the skew with an NTP server", func(t *testing.T) {
+		t.Parallel()
+
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		go func() {
+			req := make([]byte, 48)
+			_, addr, err := conn.ReadFrom(req)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 48)
+			// Version 3, mode 4 (server), stratum 1
+			resp[0] = 0x1C
+			resp[1] = 1
+			serverTime := time.Now().Add(30 * time.Second)
+			putNTPTime(resp[32:40], serverTime)
+			putNTPTime(resp[40:48], serverTime)
+			_, _ = conn.WriteTo(resp, addr)
+		}()
+
+		offset, err := ntpClockOffset(context.Background(), conn.LocalAddr().String())
+		require.NoError(t, err)
+		require.InDelta(t, 30*time.Second, offset, float64(time.Second))
+	})
+
+	t.Run("warns when the skew exceeds the threshold", func(t *testing.T) {
+		t.Parallel()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		c, err := NewClockSkewCheck(&ClockSkewCheckOptions{
+			Logger:    zap.New(core),
+			Source:    ClockSkewSourceNTP,
+			NTPServer: "127.0.0.1:123",
+			Interval:  time.Minute,
+			Threshold: 5 * time.Second,
+		})
+		require.NoError(t, err)
+
+		_, ok := c.Skew()
+		require.False(t, ok)
+
+		c.record(time.Second)
+		require.Zero(t, logs.Len())
+
+		c.record(-10 * time.Second)
+		skew, ok := c.Skew()
+		require.True(t, ok)
+		require.Equal(t, -10*time.Second, skew)
+		require.Equal(t, 1, logs.FilterMessage("The system clock is skewed. Time based validations like the expiry of tokens may fail").Len())
+
+		c.record(0)
+		require.Equal(t, 1, logs.FilterMessage("The system clock is no longer skewed").Len())
+	})
+
+	t.Run("validates the options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewClockSkewCheck(&ClockSkewCheckOptions{Logger: zap.NewNop(), Source: "gps", Interval: time.Minute})
+		require.EqualError(t, err, "unknown clock skew source 'gps', must be http or ntp")
+		_, err = NewClockSkewCheck(&ClockSkewCheckOptions{Logger: zap.NewNop(), Interval: time.Minute})
+		require.EqualError(t, err, "the clock skew check with the http source requires a url")
+	})
+}
+
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32((int64(t.Nanosecond())<<32)/int64(time.Second)))
+}
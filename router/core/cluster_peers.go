@@ -0,0 +1,276 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const clusterPeersPath = "/cluster/peers"
+
+// ClusterPeer describes an instance of the router in the inventory of the cluster
+type ClusterPeer struct {
+	InstanceID    string    `json:"instance_id"`
+	ClusterName   string    `json:"cluster_name,omitempty"`
+	URL           string    `json:"url,omitempty"`
+	Version       string    `json:"version"`
+	ConfigVersion string    `json:"config_version,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+type ClusterPeersOptions struct {
+	Logger      *zap.Logger
+	InstanceID  string
+	ClusterName string
+	// AdvertiseURL is the URL of the admin API of this instance that the peers reach it at
+	AdvertiseURL string
+	// Seeds are the URLs of the admin APIs of other instances the gossip starts with
+	Seeds []string
+	// Interval is the period in which a random peer is gossiped with
+	Interval time.Duration
+	// TTL removes the peers that weren't seen for this long
+	TTL time.Duration
+	// Token is the bearer token of the admin APIs of the peers
+	Token string
+	// ConfigVersion returns the version of the active router config
+	ConfigVersion func() string
+	StartedAt     time.Time
+}
+
+// ClusterPeers keeps an inventory of the instances of the cluster with a push-pull gossip over the admin API.
+// Periodically, an instance sends all peers it knows to a random peer or seed and merges the peers of the answer.
+// Peers of other clusters are ignored.
+type ClusterPeers struct {
+	logger     *zap.Logger
+	self       ClusterPeer
+	seeds      []string
+	interval   time.Duration
+	ttl        time.Duration
+	token      string
+	configVer  func() string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu    sync.Mutex
+	peers map[string]ClusterPeer
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewClusterPeers(opts *ClusterPeersOptions) (*ClusterPeers, error) {
+	if opts.AdvertiseURL == "" {
+		return nil, errors.New("cluster peers require the advertise url of the admin api")
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = 6 * interval
+	}
+	configVersion := opts.ConfigVersion
+	if configVersion == nil {
+		configVersion = func() string { return "" }
+	}
+
+	return &ClusterPeers{
+		logger: opts.Logger,
+		self: ClusterPeer{
+			InstanceID:  opts.InstanceID,
+			ClusterName: opts.ClusterName,
+			URL:         strings.TrimSuffix(opts.AdvertiseURL, "/"),
+			Version:     Version,
+			StartedAt:   opts.StartedAt,
+		},
+		seeds:      opts.Seeds,
+		interval:   interval,
+		ttl:        ttl,
+		token:      opts.Token,
+		configVer:  configVersion,
+		httpClient: &http.Client{Timeout: interval},
+		now:        time.Now,
+		peers:      map[string]ClusterPeer{},
+	}, nil
+}
+
+// Peers returns this instance and all peers that were seen within the TTL, sorted by their instance ID
+func (c *ClusterPeers) Peers() []ClusterPeer {
+	now := c.now()
+
+	self := c.self
+	self.ConfigVersion = c.configVer()
+	self.LastSeen = now
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peers := make([]ClusterPeer, 0, len(c.peers)+1)
+	peers = append(peers, self)
+	for id, peer := range c.peers {
+		if now.Sub(peer.LastSeen) > c.ttl {
+			delete(c.peers, id)
+			continue
+		}
+		peers = append(peers, peer)
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].InstanceID < peers[j].InstanceID
+	})
+	return peers
+}
+
+// merge adds the peers or updates them when they were seen more recently
+func (c *ClusterPeers) merge(peers []ClusterPeer) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, peer := range peers {
+		if peer.InstanceID == "" || peer.InstanceID == c.self.InstanceID || peer.ClusterName != c.self.ClusterName {
+			continue
+		}
+		// A clock ahead of ours must not keep the peer alive forever
+		if peer.LastSeen.After(now) {
+			peer.LastSeen = now
+		}
+		if now.Sub(peer.LastSeen) > c.ttl {
+			continue
+		}
+		if known, ok := c.peers[peer.InstanceID]; ok && !peer.LastSeen.After(known.LastSeen) {
+			continue
+		}
+		c.peers[peer.InstanceID] = peer
+	}
+}
+
+// target returns the URL of a random seed or peer. Seeds are kept, so that partitions of the cluster heal.
+func (c *ClusterPeers) target() string {
+	c.mu.Lock()
+	urls := make([]string, 0, len(c.seeds)+len(c.peers))
+	for _, seed := range c.seeds {
+		if seed = strings.TrimSuffix(seed, "/"); seed != c.self.URL {
+			urls = append(urls, seed)
+		}
+	}
+	for _, peer := range c.peers {
+		if peer.URL != "" {
+			urls = append(urls, peer.URL)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(urls) == 0 {
+		return ""
+	}
+	return urls[rand.Intn(len(urls))]
+}
+
+// gossip sends the known peers to a random peer and merges its answer
+func (c *ClusterPeers) gossip(ctx context.Context) error {
+	target := c.target()
+	if target == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(c.Peers())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target+clusterPeersPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to gossip with %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to gossip with %s: unexpected status code %d", target, resp.StatusCode)
+	}
+
+	var peers []ClusterPeer
+	if err := json.NewDecoder(resp.Body).Decode(&peers); err != nil {
+		return fmt.Errorf("failed to decode the peers of %s: %w", target, err)
+	}
+	c.merge(peers)
+	return nil
+}
+
+func (c *ClusterPeers) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	c.mu.Unlock()
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.gossip(ctx); err != nil && ctx.Err() == nil {
+				// Unreachable peers are expected while instances are replaced
+				c.logger.Debug("Failed to gossip with a cluster peer", zap.Error(err))
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (c *ClusterPeers) Shutdown() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (r *Router) handleClusterPeers(w http.ResponseWriter, _ *http.Request) {
+	writeAdminJSON(w, http.StatusOK, r.clusterPeers.Peers())
+}
+
+// handleClusterPeersGossip merges the peers of the sender and answers with the known peers
+func (r *Router) handleClusterPeersGossip(w http.ResponseWriter, req *http.Request) {
+	var peers []ClusterPeer
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<20)).Decode(&peers); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "invalid peers"})
+		return
+	}
+	r.clusterPeers.merge(peers)
+	writeAdminJSON(w, http.StatusOK, r.clusterPeers.Peers())
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestClusterPeers(t *testing.T, instanceID, clusterName string, seeds ...string) (*ClusterPeers, *httptest.Server) {
+	t.Helper()
+
+	r := &Router{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(clusterPeersPath, func(w http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+		if req.Method == http.MethodPost {
+			r.handleClusterPeersGossip(w, req)
+			return
+		}
+		r.handleClusterPeers(w, req)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	peers, err := NewClusterPeers(&ClusterPeersOptions{
+		Logger:       zap.NewNop(),
+		InstanceID:   instanceID,
+		ClusterName:  clusterName,
+		AdvertiseURL: server.URL,
+		Seeds:        seeds,
+		Token:        "secret",
+		TTL:          time.Minute,
+	})
+	require.NoError(t, err)
+	r.clusterPeers = peers
+	return peers, server
+}
+
+func instanceIDs(peers []ClusterPeer) []string {
+	ids := make([]string, len(peers))
+	for i, peer := range peers {
+		ids[i] = peer.InstanceID
+	}
+	return ids
+}
+
+func TestClusterPeersGossip(t *testing.T) {
+	a, seed := newTestClusterPeers(t, "a", "eu")
+	b, _ := newTestClusterPeers(t, "b", "eu", seed.URL)
+	c, _ := newTestClusterPeers(t, "c", "eu", seed.URL)
+	other, _ := newTestClusterPeers(t, "d", "us", seed.URL)
+
+	ctx := context.Background()
+	require.NoError(t, b.gossip(ctx))
+	require.NoError(t, c.gossip(ctx))
+	require.NoError(t, other.gossip(ctx))
+
+	// The seed knows all instances of its cluster, c learned of b from the answer of the seed
+	require.Equal(t, []string{"a", "b", "c"}, instanceIDs(a.Peers()))
+	require.Equal(t, []string{"a", "b", "c"}, instanceIDs(c.Peers()))
+	require.Equal(t, []string{"a", "b"}, instanceIDs(b.Peers()))
+	require.Equal(t, []string{"d"}, instanceIDs(other.Peers()))
+}
+
+func TestClusterPeersExpire(t *testing.T) {
+	peers, err := NewClusterPeers(&ClusterPeersOptions{InstanceID: "a", AdvertiseURL: "http://a:8088", TTL: time.Minute})
+	require.NoError(t, err)
+
+	now := time.Now()
+	peers.now = func() time.Time { return now }
+	peers.merge([]ClusterPeer{
+		{InstanceID: "b", LastSeen: now.Add(-30 * time.Second)},
+		{InstanceID: "c", LastSeen: now.Add(-2 * time.Minute)},
+	})
+	require.Equal(t, []string{"a", "b"}, instanceIDs(peers.Peers()))
+
+	// An older sighting doesn't replace a newer one
+	peers.merge([]ClusterPeer{{InstanceID: "b", LastSeen: now.Add(-50 * time.Second)}})
+	now = now.Add(40 * time.Second)
+	require.Equal(t, []string{"a"}, instanceIDs(peers.Peers()))
+
+	_, err = NewClusterPeers(&ClusterPeersOptions{InstanceID: "a"})
+	require.EqualError(t, err, "cluster peers require the advertise url of the admin api")
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+)
+
+const (
+	// ConfigSourceCDN is the source of router configs that are polled from the CDN
+	ConfigSourceCDN = "cdn"
+	// ConfigSourceStatic is the source of the router config passed on startup, e.g. from a file
+	ConfigSourceStatic = "static"
+)
+
+const (
+	ConfigSignatureVerified   = "verified"
+	ConfigSignatureUnverified = "unverified"
+)
+
+// ConfigChange is an entry of the config audit log
+type ConfigChange struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	// Version is the version of the router config as assigned by the control plane
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	// Hash is the SHA-256 of the serialized router config
+	Hash string `json:"hash"`
+	// Signature tells whether the signature of the config was verified before it was applied
+	Signature string            `json:"signature"`
+	Applied   bool              `json:"applied"`
+	Error     string            `json:"error,omitempty"`
+	Diff      ConfigDiffSummary `json:"diff"`
+}
+
+// ConfigDiffSummary summarizes the differences of a router config to the previously applied one
+type ConfigDiffSummary struct {
+	SchemaChanged       bool     `json:"schema_changed"`
+	AddedSubgraphs      []string `json:"added_subgraphs,omitempty"`
+	RemovedSubgraphs    []string `json:"removed_subgraphs,omitempty"`
+	ChangedSubgraphs    []string `json:"changed_subgraphs,omitempty"`
+	AddedFeatureFlags   []string `json:"added_feature_flags,omitempty"`
+	RemovedFeatureFlags []string `json:"removed_feature_flags,omitempty"`
+	ChangedFeatureFlags []string `json:"changed_feature_flags,omitempty"`
+}
+
+// ConfigAuditLog keeps the most recent router config changes. Like the kill switch, it is shared between all
+// servers so that it survives router config updates.
+type ConfigAuditLog struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries []ConfigChange
+}
+
+func NewConfigAuditLog(maxEntries int) (*ConfigAuditLog, error) {
+	if maxEntries <= 0 {
+		return nil, errors.New("the maximum number of config audit entries must be greater than zero")
+	}
+
+	return &ConfigAuditLog{
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}, nil
+}
+
+// Record adds an entry for the router config cfg that replaces prev. A non-nil applyErr records a change that
+// failed to be applied.
+func (l *ConfigAuditLog) Record(source, signature string, prev, cfg *nodev1.RouterConfig, applyErr error) {
+	change := ConfigChange{
+		Source:          source,
+		Version:         cfg.GetVersion(),
+		PreviousVersion: prev.GetVersion(),
+		Hash:            routerConfigHash(cfg),
+		Signature:       signature,
+		Applied:         applyErr == nil,
+		Diff:            diffRouterConfigs(prev, cfg),
+	}
+	if applyErr != nil {
+		change.Error = applyErr.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	change.Time = l.now()
+	if len(l.entries) >= l.maxEntries {
+		// Drop the oldest entry
+		copy(l.entries, l.entries[1:])
+		l.entries = l.entries[:len(l.entries)-1]
+	}
+	l.entries = append(l.entries, change)
+}
+
+// Changes returns up to limit of the most recent changes, the latest first. A limit of zero returns all entries.
+func (l *ConfigAuditLog) Changes(limit int) []ConfigChange {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit <= 0 || limit > len(l.entries) {
+		limit = len(l.entries)
+	}
+
+	changes := make([]ConfigChange, 0, limit)
+	for i := len(l.entries) - 1; i >= len(l.entries)-limit; i-- {
+		changes = append(changes, l.entries[i])
+	}
+	return changes
+}
+
+func routerConfigHash(cfg *nodev1.RouterConfig) string {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// diffRouterConfigs compares the subgraphs by name and their routing URL, and the feature flags by their version.
+// The subgraphs of the feature flags are not compared.
+func diffRouterConfigs(prev, cfg *nodev1.RouterConfig) ConfigDiffSummary {
+	var diff ConfigDiffSummary
+
+	diff.SchemaChanged = prev.GetEngineConfig().GetGraphqlSchema() != cfg.GetEngineConfig().GetGraphqlSchema()
+
+	prevSubgraphs := make(map[string]string, len(prev.GetSubgraphs()))
+	for _, sg := range prev.GetSubgraphs() {
+		prevSubgraphs[sg.GetName()] = sg.GetRoutingUrl()
+	}
+	for _, sg := range cfg.GetSubgraphs() {
+		routingURL, ok := prevSubgraphs[sg.GetName()]
+		switch {
+		case !ok:
+			diff.AddedSubgraphs = append(diff.AddedSubgraphs, sg.GetName())
+		case routingURL != sg.GetRoutingUrl():
+			diff.ChangedSubgraphs = append(diff.ChangedSubgraphs, sg.GetName())
+		}
+		delete(prevSubgraphs, sg.GetName())
+	}
+	for name := range prevSubgraphs {
+		diff.RemovedSubgraphs = append(diff.RemovedSubgraphs, name)
+	}
+
+	prevFlags := prev.GetFeatureFlagConfigs().GetConfigByFeatureFlagName()
+	flags := cfg.GetFeatureFlagConfigs().GetConfigByFeatureFlagName()
+	for name, flag := range flags {
+		prevFlag, ok := prevFlags[name]
+		switch {
+		case !ok:
+			diff.AddedFeatureFlags = append(diff.AddedFeatureFlags, name)
+		case prevFlag.GetVersion() != flag.GetVersion():
+			diff.ChangedFeatureFlags = append(diff.ChangedFeatureFlags, name)
+		}
+	}
+	for name := range prevFlags {
+		if _, ok := flags[name]; !ok {
+			diff.RemovedFeatureFlags = append(diff.RemovedFeatureFlags, name)
+		}
+	}
+
+	sort.Strings(diff.RemovedSubgraphs)
+	sort.Strings(diff.AddedFeatureFlags)
+	sort.Strings(diff.RemovedFeatureFlags)
+	sort.Strings(diff.ChangedFeatureFlags)
+
+	return diff
+}
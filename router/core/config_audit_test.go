@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+)
+
+func TestDiffRouterConfigs(t *testing.T) {
+	t.Parallel()
+
+	prev := &nodev1.RouterConfig{
+		Version:      "1",
+		EngineConfig: &nodev1.EngineConfiguration{GraphqlSchema: "type Query { a: String }"},
+		Subgraphs: []*nodev1.Subgraph{
+			{Name: "employees", RoutingUrl: "http://employees/graphql"},
+			{Name: "products", RoutingUrl: "http://products/graphql"},
+			{Name: "family", RoutingUrl: "http://family/graphql"},
+		},
+		FeatureFlagConfigs: &nodev1.FeatureFlagRouterExecutionConfigs{
+			ConfigByFeatureFlagName: map[string]*nodev1.FeatureFlagRouterExecutionConfig{
+				"beta":   {Version: "1"},
+				"legacy": {Version: "1"},
+			},
+		},
+	}
+	cfg := &nodev1.RouterConfig{
+		Version:      "2",
+		EngineConfig: &nodev1.EngineConfiguration{GraphqlSchema: "type Query { a: String b: Int }"},
+		Subgraphs: []*nodev1.Subgraph{
+			{Name: "employees", RoutingUrl: "http://employees/graphql"},
+			{Name: "products", RoutingUrl: "http://products-v2/graphql"},
+			{Name: "mood", RoutingUrl: "http://mood/graphql"},
+		},
+		FeatureFlagConfigs: &nodev1.FeatureFlagRouterExecutionConfigs{
+			ConfigByFeatureFlagName: map[string]*nodev1.FeatureFlagRouterExecutionConfig{
+				"beta": {Version: "2"},
+				"new":  {Version: "1"},
+			},
+		},
+	}
+
+	require.Equal(t, ConfigDiffSummary{
+		SchemaChanged:       true,
+		AddedSubgraphs:      []string{"mood"},
+		RemovedSubgraphs:    []string{"family"},
+		ChangedSubgraphs:    []string{"products"},
+		AddedFeatureFlags:   []string{"new"},
+		RemovedFeatureFlags: []string{"legacy"},
+		ChangedFeatureFlags: []string{"beta"},
+	}, diffRouterConfigs(prev, cfg))
+
+	// The initial config adds everything
+	initial := diffRouterConfigs(nil, prev)
+	require.True(t, initial.SchemaChanged)
+	require.Equal(t, []string{"employees", "products", "family"}, initial.AddedSubgraphs)
+	require.Equal(t, []string{"beta", "legacy"}, initial.AddedFeatureFlags)
+
+	require.Equal(t, ConfigDiffSummary{}, diffRouterConfigs(cfg, cfg))
+}
+
+func TestConfigAuditLog(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewConfigAuditLog(0)
+	require.Error(t, err)
+
+	l, err := NewConfigAuditLog(2)
+	require.NoError(t, err)
+
+	v1 := &nodev1.RouterConfig{Version: "1"}
+	v2 := &nodev1.RouterConfig{Version: "2"}
+	v3 := &nodev1.RouterConfig{Version: "3"}
+
+	l.Record(ConfigSourceCDN, ConfigSignatureVerified, nil, v1, nil)
+	l.Record(ConfigSourceCDN, ConfigSignatureVerified, v1, v2, nil)
+	l.Record(ConfigSourceCDN, ConfigSignatureVerified, v2, v3, errors.New("invalid config"))
+
+	// The oldest change was dropped, the latest comes first
+	changes := l.Changes(0)
+	require.Len(t, changes, 2)
+	require.Equal(t, "3", changes[0].Version)
+	require.Equal(t, "2", changes[0].PreviousVersion)
+	require.False(t, changes[0].Applied)
+	require.Equal(t, "invalid config", changes[0].Error)
+	require.Equal(t, "2", changes[1].Version)
+	require.True(t, changes[1].Applied)
+	require.Equal(t, ConfigSourceCDN, changes[1].Source)
+	require.Equal(t, ConfigSignatureVerified, changes[1].Signature)
+	require.False(t, changes[1].Time.IsZero())
+
+	require.Len(t, changes[0].Hash, 64)
+	require.NotEqual(t, changes[0].Hash, changes[1].Hash)
+	require.Equal(t, routerConfigHash(&nodev1.RouterConfig{Version: "2"}), changes[1].Hash)
+
+	changes = l.Changes(1)
+	require.Len(t, changes, 1)
+	require.Equal(t, "3", changes[0].Version)
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+// configDriftTracker tracks the drift between the active router config and the latest config available from the CDN
+// or the control plane. The configs drift when a newer config can't be applied, e.g. because its engine fails to
+// build, while the router keeps serving the previous config.
+type configDriftTracker struct {
+	logger *zap.Logger
+	// threshold is the drift after which a warning is logged. Zero never warns.
+	threshold time.Duration
+	now       func() time.Time
+
+	mu            sync.Mutex
+	activeVersion string
+	latestVersion string
+	// since is when the latest version was first seen without being applied. Zero while the configs don't drift.
+	since  time.Time
+	warned bool
+}
+
+func newConfigDriftTracker(logger *zap.Logger, threshold time.Duration) *configDriftTracker {
+	return &configDriftTracker{
+		logger:    logger,
+		threshold: threshold,
+		now:       time.Now,
+	}
+}
+
+// applied records that the version is active. It is the latest version, until a newer one fails to be applied.
+func (d *configDriftTracker) applied(version string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.since.IsZero() {
+		d.logger.Info("Router config drift resolved",
+			zap.String("active_version", version),
+			zap.Duration("drift", d.now().Sub(d.since)),
+		)
+	}
+
+	d.activeVersion = version
+	d.latestVersion = version
+	d.since = time.Time{}
+	d.warned = false
+}
+
+// failed records that the latest available version couldn't be applied. It warns once per version when the drift
+// exceeds the threshold.
+func (d *configDriftTracker) failed(latestVersion string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if d.since.IsZero() {
+		d.since = now
+	}
+	if latestVersion != d.latestVersion {
+		d.latestVersion = latestVersion
+		d.warned = false
+	}
+
+	drift := now.Sub(d.since)
+	if d.threshold <= 0 || d.warned || drift < d.threshold {
+		return
+	}
+	d.warned = true
+
+	d.logger.Warn("Router config drifts from the latest available config",
+		zap.String("active_version", d.activeVersion),
+		zap.String("latest_version", d.latestVersion),
+		zap.Duration("drift", drift),
+		zap.Duration("threshold", d.threshold),
+	)
+}
+
+// stale returns how long a newer config is available without being applied. Zero while the active config is the
+// latest.
+func (d *configDriftTracker) stale() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.since.IsZero() {
+		return 0
+	}
+	return d.now().Sub(d.since)
+}
+
+// RegisterMetrics exposes the staleness of the active config on the meter provider. The metric stays registered until
+// the meter provider is shut down.
+func (d *configDriftTracker) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	stale, err := meter.Float64ObservableGauge(
+		"router.config.stale",
+		otelmetric.WithDescription("Time a newer router config is available without being applied, zero while the active config is the latest"),
+		otelmetric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		o.ObserveFloat64(stale, d.stale().Seconds())
+		return nil
+	}, stale)
+
+	return err
+}
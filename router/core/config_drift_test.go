@@ -0,0 +1,52 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestConfigDriftTracker(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	now := time.Unix(1_000_000, 0)
+	d := newConfigDriftTracker(zap.New(core), 5*time.Minute)
+	d.now = func() time.Time { return now }
+
+	d.applied("v1")
+	require.Zero(t, d.stale())
+
+	d.failed("v2")
+	now = now.Add(time.Minute)
+	d.failed("v2")
+	require.Equal(t, time.Minute, d.stale())
+	require.Zero(t, logs.Len())
+
+	// The drift is measured from the first version that failed, not from the latest one
+	now = now.Add(4 * time.Minute)
+	d.failed("v3")
+	require.Equal(t, 5*time.Minute, d.stale())
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	require.Equal(t, "v1", fields["active_version"])
+	require.Equal(t, "v3", fields["latest_version"])
+
+	// A version is warned about once
+	now = now.Add(time.Minute)
+	d.failed("v3")
+	require.Equal(t, 1, logs.Len())
+	d.failed("v4")
+	require.Equal(t, 2, logs.Len())
+
+	d.applied("v4")
+	require.Zero(t, d.stale())
+
+	never := newConfigDriftTracker(zap.New(core), 0)
+	never.failed("v2")
+	require.Equal(t, 2, logs.Len())
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+const (
+	// ConnectionPhaseAcceptToTLS is the time from the accept of a connection of a listener to the completion of its
+	// TLS handshake
+	ConnectionPhaseAcceptToTLS = "accept_to_tls"
+	// ConnectionPhaseTLSHandshake is the time from the ClientHello to the completion of the TLS handshake of a listener
+	ConnectionPhaseTLSHandshake = "tls_handshake"
+	// ConnectionPhaseDNS is the DNS lookup of a subgraph
+	ConnectionPhaseDNS = "dns"
+	// ConnectionPhaseConnect is the connect to a subgraph
+	ConnectionPhaseConnect = "connect"
+	// ConnectionPhaseTLS is the TLS handshake with a subgraph
+	ConnectionPhaseTLS = "tls"
+)
+
+// ConnectionTimings measures the setup of the connections, so that network latency can be localized. The TLS
+// handshakes are measured per listener, and the DNS lookups, connects and TLS handshakes of new connections to the
+// subgraphs per subgraph. Reused connections aren't measured.
+type ConnectionTimings struct {
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	histograms []otelmetric.Float64Histogram
+}
+
+func NewConnectionTimings(logger *zap.Logger) *ConnectionTimings {
+	return &ConnectionTimings{logger: logger}
+}
+
+// RegisterMetrics records the timings on the meter provider
+func (t *ConnectionTimings) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	histogram, err := meter.Float64Histogram(
+		"router.connection.setup.duration",
+		otelmetric.WithDescription("Duration of the phases of the connection setup of the listeners and to the subgraphs"),
+		otelmetric.WithUnit("ms"),
+	)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.histograms = append(t.histograms, histogram)
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *ConnectionTimings) record(phase string, duration time.Duration, attrs ...attribute.KeyValue) {
+	t.mu.Lock()
+	histograms := t.histograms
+	t.mu.Unlock()
+
+	opt := otelmetric.WithAttributes(append(attrs, attribute.String("phase", phase))...)
+	for _, histogram := range histograms {
+		histogram.Record(context.Background(), float64(duration)/float64(time.Millisecond), opt)
+	}
+}
+
+// Listener records when the connections of the listener are accepted, so that the time until their TLS handshake is
+// complete can be measured
+func (t *ConnectionTimings) Listener(ln net.Listener) net.Listener {
+	if t == nil {
+		return ln
+	}
+	return &timedListener{Listener: ln}
+}
+
+// TLSConfig returns a copy of the config of the listener that measures the TLS handshakes
+func (t *ConnectionTimings) TLSConfig(listener string, cfg *tls.Config) *tls.Config {
+	if t == nil || cfg == nil {
+		return cfg
+	}
+
+	base := cfg.Clone()
+	// The config of a connection replaces the config of the server, including the protocols that net/http adds to
+	// it, so they are set beforehand
+	if len(base.NextProtos) == 0 {
+		base.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	server := base.Clone()
+	server.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		start := time.Now()
+
+		conn := base.Clone()
+		verify := conn.VerifyConnection
+		// VerifyConnection is called on every successful handshake, including resumptions
+		conn.VerifyConnection = func(state tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(state); err != nil {
+					return err
+				}
+			}
+			t.recordHandshake(listener, hello.Conn, start)
+			return nil
+		}
+		return conn, nil
+	}
+
+	return server
+}
+
+func (t *ConnectionTimings) recordHandshake(listener string, conn net.Conn, start time.Time) {
+	end := time.Now()
+	attr := attribute.String("listener", listener)
+
+	handshake := end.Sub(start)
+	t.record(ConnectionPhaseTLSHandshake, handshake, attr)
+	fields := []zap.Field{
+		zap.String("listener", listener),
+		logging.WithClientIP(remoteIP(conn)),
+		zap.Duration(ConnectionPhaseTLSHandshake, handshake),
+	}
+
+	if timed, ok := conn.(*timedConn); ok {
+		acceptToTLS := end.Sub(timed.acceptedAt)
+		t.record(ConnectionPhaseAcceptToTLS, acceptToTLS, attr)
+		fields = append(fields, zap.Duration(ConnectionPhaseAcceptToTLS, acceptToTLS))
+	}
+
+	if ce := t.logger.Check(zap.DebugLevel, "TLS handshake completed"); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// traceRequest measures the setup of the connection of the request to the subgraph
+func (t *ConnectionTimings) traceRequest(req *http.Request, logger *zap.Logger, subgraphName, subgraphID string) *http.Request {
+	trace := &subgraphConnectionTrace{
+		timings:     t,
+		logger:      logger,
+		attrs:       []attribute.KeyValue{otel.WgSubgraphName.String(subgraphName), otel.WgSubgraphID.String(subgraphID)},
+		connStarted: map[string]time.Time{},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart:          trace.dnsStart,
+		DNSDone:           trace.dnsDone,
+		ConnectStart:      trace.connectStart,
+		ConnectDone:       trace.connectDone,
+		TLSHandshakeStart: trace.tlsStart,
+		TLSHandshakeDone:  trace.tlsDone,
+	}))
+}
+
+// subgraphConnectionTrace holds the start of the phases of a request. The dialer connects to several addresses in
+// parallel and the callbacks can be called after the request was canceled, so the state is locked.
+type subgraphConnectionTrace struct {
+	timings *ConnectionTimings
+	logger  *zap.Logger
+	attrs   []attribute.KeyValue
+
+	mu          sync.Mutex
+	dnsStarted  time.Time
+	connStarted map[string]time.Time
+	tlsStarted  time.Time
+}
+
+func (c *subgraphConnectionTrace) dnsStart(httptrace.DNSStartInfo) {
+	c.mu.Lock()
+	c.dnsStarted = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *subgraphConnectionTrace) dnsDone(info httptrace.DNSDoneInfo) {
+	c.mu.Lock()
+	start := c.dnsStarted
+	c.mu.Unlock()
+	c.done(ConnectionPhaseDNS, start, "", info.Err)
+}
+
+func (c *subgraphConnectionTrace) connectStart(_, addr string) {
+	c.mu.Lock()
+	c.connStarted[addr] = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *subgraphConnectionTrace) connectDone(_, addr string, err error) {
+	c.mu.Lock()
+	start := c.connStarted[addr]
+	c.mu.Unlock()
+	c.done(ConnectionPhaseConnect, start, addr, err)
+}
+
+func (c *subgraphConnectionTrace) tlsStart() {
+	c.mu.Lock()
+	c.tlsStarted = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *subgraphConnectionTrace) tlsDone(_ tls.ConnectionState, err error) {
+	c.mu.Lock()
+	start := c.tlsStarted
+	c.mu.Unlock()
+	c.done(ConnectionPhaseTLS, start, "", err)
+}
+
+// done records the phase if it succeeded and logs it in any case
+func (c *subgraphConnectionTrace) done(phase string, start time.Time, addr string, err error) {
+	if start.IsZero() {
+		return
+	}
+	duration := time.Since(start)
+	if err == nil {
+		c.timings.record(phase, duration, c.attrs...)
+	}
+
+	ce := c.logger.Check(zap.DebugLevel, "Subgraph connection setup")
+	if ce == nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("phase", phase),
+		zap.Duration("duration", duration),
+	}
+	if addr != "" {
+		fields = append(fields, zap.String("addr", addr))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
+type timedListener struct {
+	net.Listener
+}
+
+func (ln *timedListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{Conn: conn, acceptedAt: time.Now()}, nil
+}
+
+type timedConn struct {
+	net.Conn
+	acceptedAt time.Time
+}
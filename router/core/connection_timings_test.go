@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestConnectionTimings(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	timings := NewConnectionTimings(zap.New(core))
+
+	reader := sdkmetric.NewManualReader()
+	require.NoError(t, timings.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	_, _, cert := writeTestCertificate(t, t.TempDir())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		Handler:   http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		TLSConfig: timings.TLSConfig(ResponseHeadersListenerGraphQL, &tls.Config{Certificates: []tls.Certificate{cert}}),
+	}
+	go func() {
+		_ = srv.ServeTLS(timings.Listener(ln), "", "")
+	}()
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(cert.Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}, ForceAttemptHTTP2: true}}
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+ln.Addr().String(), nil)
+	require.NoError(t, err)
+	resp, err := client.Do(timings.traceRequest(req, zap.New(core), "employees", "1"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	// The protocols of net/http are still negotiated
+	require.Equal(t, 2, resp.ProtoMajor)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+
+	counts := map[string]uint64{}
+	for _, dp := range rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64]).DataPoints {
+		phase, _ := dp.Attributes.Value("phase")
+		owner, ok := dp.Attributes.Value("listener")
+		if !ok {
+			owner, _ = dp.Attributes.Value(otel.WgSubgraphName)
+		}
+		counts[owner.AsString()+"/"+phase.AsString()] = dp.Count
+	}
+	// The address is an IP, so there is no DNS lookup
+	require.Equal(t, map[string]uint64{
+		"graphql/" + ConnectionPhaseAcceptToTLS:  1,
+		"graphql/" + ConnectionPhaseTLSHandshake: 1,
+		"employees/" + ConnectionPhaseConnect:    1,
+		"employees/" + ConnectionPhaseTLS:        1,
+	}, counts)
+
+	require.Equal(t, 1, logs.FilterMessage("TLS handshake completed").Len())
+	require.Equal(t, 2, logs.FilterMessage("Subgraph connection setup").Len())
+}
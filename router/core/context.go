@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
 	ctrace "github.com/wundergraph/cosmo/router/pkg/trace"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
@@ -50,6 +51,21 @@ func NewClientInfoFromRequest(r *http.Request) *ClientInfo {
 	}
 }
 
+// loggingOperation returns the metadata of the parsed operation that the entries of the request are enriched with
+func loggingOperation(operation *ParsedOperation, clientInfo *ClientInfo) *logging.Operation {
+	op := &logging.Operation{
+		Name:          operation.Request.OperationName,
+		Type:          operation.Type,
+		Sha256Hash:    logging.OperationSha256(operation.NormalizedRepresentation),
+		ClientName:    clientInfo.Name,
+		ClientVersion: clientInfo.Version,
+	}
+	if persistedQuery := operation.GraphQLRequestExtensions.PersistedQuery; persistedQuery != nil {
+		op.PersistedID = persistedQuery.Sha256Hash
+	}
+	return op
+}
+
 type RequestContext interface {
 	// ResponseWriter is the original response writer received by the router.
 	ResponseWriter() http.ResponseWriter
@@ -145,6 +161,18 @@ type requestContext struct {
 	sendError error
 	// subgraphs is the list of subgraphs taken from the router config
 	subgraphs []Subgraph
+	// fetchLimiter limits the concurrent subgraph fetches of the request. Nil is unlimited.
+	fetchLimiter *fetchLimiter
+	// memoryBudget accounts the memory of the request. Nil is unlimited.
+	memoryBudget *memoryBudget
+	// tags are the custom tags of the request
+	tags requestTags
+	// tenant are the overrides of the tenant of the request. Nil uses the settings of the router.
+	tenant *tenantOverride
+	// subgraphErrors are the errors of the subgraph requests of the response
+	subgraphErrors error
+	// executionAudit is the execution audit record of the request. Nil if the execution audit is disabled.
+	executionAudit *executionAuditRecord
 }
 
 func (c *requestContext) SendError() error {
@@ -362,11 +390,16 @@ type operationContext struct {
 	opType string
 	// Hash is the hash of the operation
 	hash uint64
+	// fingerprint is the hash of the operation in the logs, the traces and the metrics
+	fingerprint uint64
 	// Content is the content of the operation
-	content    string
-	variables  []byte
-	files      []httpclient.File
-	clientInfo *ClientInfo
+	content   string
+	variables []byte
+	// sealedVariables are the variables with the sealed values of the client. They are logged instead of the
+	// unsealed variables.
+	sealedVariables []byte
+	files           []httpclient.File
+	clientInfo      *ClientInfo
 	// preparedPlan is the prepared plan of the operation
 	preparedPlan               *planWithMetaData
 	traceOptions               resolve.TraceOptions
@@ -376,12 +409,26 @@ type operationContext struct {
 	persistedID                string
 	protocol                   OperationProtocol
 	persistedOperationCacheHit bool
+	// maskedFields are the fields of the operation whose values are replaced with null in the response
+	maskedFields []operationField
+	// deprecationWarnings are the deprecated fields of the operation that are listed in the extensions of the response
+	deprecationWarnings []deprecatedField
+	// cacheDecisions are the outcomes of the cache lookups of the operation, which are logged with the request
+	cacheDecisions cacheDecisions
 }
 
 func (o *operationContext) Variables() []byte {
 	return o.variables
 }
 
+// loggedVariables returns the variables for the logs, which keep the sealed values of the client
+func (o *operationContext) loggedVariables() []byte {
+	if o.sealedVariables != nil {
+		return o.sealedVariables
+	}
+	return o.variables
+}
+
 func (o *operationContext) Files() []httpclient.File {
 	return o.files
 }
@@ -0,0 +1,372 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const contractEnforcementLoggerName = "contract_enforcement"
+
+type ContractEnforcerOptions struct {
+	Logger      *zap.Logger
+	MetricStore metric.Provider
+	// ExcludeTags are the names of the @tag directives of the excluded fields
+	ExcludeTags []string
+	// MaxLoggedViolations limits the removed fields that are logged of a single response
+	MaxLoggedViolations int
+}
+
+// ContractEnforcer removes the fields that are excluded from the contract from the subgraph responses, in case a
+// subgraph returns them without being asked for. The excluded fields are the fields of the router schema that aren't
+// part of the client schema, e.g. because of @inaccessible or the tags of a contract, and the fields with the
+// configured tags. The fields the router requested, e.g. the keys of the entities, are kept because the engine needs
+// them. The removed fields are logged with the subgraph name and counted.
+type ContractEnforcer struct {
+	logger              *zap.Logger
+	metricStore         metric.Provider
+	excludeTags         map[string]struct{}
+	maxLoggedViolations int
+
+	// contract is set when the executor was built, before the first request
+	contract atomic.Pointer[contractSchema]
+}
+
+type contractSchema struct {
+	definition *ast.Document
+	// excluded are the names of the excluded fields by the name of their type
+	excluded map[string]map[string]struct{}
+}
+
+func NewContractEnforcer(opts *ContractEnforcerOptions) *ContractEnforcer {
+	metricStore := opts.MetricStore
+	if metricStore == nil {
+		metricStore = metric.NewNoopMetrics()
+	}
+
+	excludeTags := make(map[string]struct{}, len(opts.ExcludeTags))
+	for _, tag := range opts.ExcludeTags {
+		excludeTags[tag] = struct{}{}
+	}
+
+	return &ContractEnforcer{
+		logger:              opts.Logger.Named(contractEnforcementLoggerName),
+		metricStore:         metricStore,
+		excludeTags:         excludeTags,
+		maxLoggedViolations: opts.MaxLoggedViolations,
+	}
+}
+
+// setSchemas derives the excluded fields from the router schema and the client schema
+func (c *ContractEnforcer) setSchemas(routerSchema, clientSchema *ast.Document) {
+	c.contract.Store(&contractSchema{
+		definition: routerSchema,
+		excluded:   contractExcludedFields(routerSchema, clientSchema, c.excludeTags),
+	})
+}
+
+func contractExcludedFields(routerSchema, clientSchema *ast.Document, excludeTags map[string]struct{}) map[string]map[string]struct{} {
+	excluded := make(map[string]map[string]struct{})
+
+	for _, node := range routerSchema.RootNodes {
+		if node.Kind != ast.NodeKindObjectTypeDefinition && node.Kind != ast.NodeKindInterfaceTypeDefinition {
+			continue
+		}
+		typeName := routerSchema.NodeNameString(node)
+		if strings.HasPrefix(typeName, "__") {
+			continue
+		}
+		// Without a client schema all the fields are part of the contract
+		clientNode, clientNodeExists := clientSchema.Index.FirstNodeByNameStr(typeName)
+
+		for _, ref := range routerSchema.NodeFieldDefinitions(node) {
+			fieldName := routerSchema.FieldDefinitionNameString(ref)
+			if strings.HasPrefix(fieldName, "__") {
+				continue
+			}
+
+			exclude := false
+			if clientSchema != routerSchema {
+				if !clientNodeExists {
+					exclude = true
+				} else if _, ok := clientSchema.NodeFieldDefinitionByName(clientNode, []byte(fieldName)); !ok {
+					exclude = true
+				}
+			}
+			if !exclude && len(excludeTags) > 0 {
+				exclude = fieldDefinitionHasTag(routerSchema, ref, excludeTags)
+			}
+			if !exclude {
+				continue
+			}
+
+			if excluded[typeName] == nil {
+				excluded[typeName] = make(map[string]struct{})
+			}
+			excluded[typeName][fieldName] = struct{}{}
+		}
+	}
+
+	return excluded
+}
+
+func fieldDefinitionHasTag(definition *ast.Document, ref int, tags map[string]struct{}) bool {
+	for _, directive := range definition.FieldDefinitionDirectives(ref) {
+		if definition.DirectiveNameString(directive) != "tag" {
+			continue
+		}
+		value, ok := definition.DirectiveArgumentValueByName(directive, []byte("name"))
+		if !ok || value.Kind != ast.ValueKindString {
+			continue
+		}
+		if _, ok := tags[definition.ValueContentString(value)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// EnforceResponse removes the excluded fields that the router didn't request from the response of a subgraph
+// request. The body of the response is replaced if fields were removed, restored otherwise.
+func (c *ContractEnforcer) EnforceResponse(req *http.Request, res *http.Response) error {
+	contract := c.contract.Load()
+	if contract == nil || len(contract.excluded) == 0 || res.StatusCode != http.StatusOK || req.GetBody == nil {
+		return nil
+	}
+	if req.Header.Get("Upgrade") != "" || req.Header.Get("Accept") == "text/event-stream" {
+		return nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	data, err := decodeResponseBody(res.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		return nil
+	}
+
+	reqBody, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer reqBody.Close()
+
+	var request struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(reqBody).Decode(&request); err != nil || request.Query == "" {
+		return nil
+	}
+
+	enforced, removed, ok := enforceSubgraphResponseContract(contract, request.Query, data)
+	if !ok || len(removed) == 0 {
+		return nil
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(enforced))
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = int64(len(enforced))
+
+	var (
+		subgraphName string
+		attributes   []attribute.KeyValue
+	)
+	reqContext := getRequestContext(req.Context())
+	if reqContext != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			subgraphName = subgraph.Name
+			attributes = append(attributes, otel.WgSubgraphName.String(subgraph.Name), otel.WgSubgraphID.String(subgraph.Id))
+		}
+	}
+
+	c.metricStore.MeasureSubgraphContractViolations(req.Context(), int64(len(removed)), attributes...)
+
+	logged := removed
+	if c.maxLoggedViolations > 0 && len(logged) > c.maxLoggedViolations {
+		logged = logged[:c.maxLoggedViolations]
+	}
+
+	fields := []zap.Field{
+		zap.String("subgraph_name", subgraphName),
+		zap.Int("violation_count", len(removed)),
+		zap.Strings("removed_fields", logged),
+	}
+	if reqContext != nil && reqContext.operation != nil {
+		fields = append(fields, zap.String("operation_name", reqContext.operation.Name()))
+	}
+	c.logger.Warn("Subgraph response contains fields excluded from the contract", fields...)
+
+	return nil
+}
+
+// enforceSubgraphResponseContract removes the excluded fields that aren't selected by the subgraph query from the
+// response. It returns the response and the paths of the removed fields, and false if the query or the response
+// can't be parsed.
+func enforceSubgraphResponseContract(contract *contractSchema, query string, body []byte) ([]byte, []string, bool) {
+	operation, report := astparser.ParseGraphqlDocumentString(query)
+	if report.HasErrors() || len(operation.OperationDefinitions) == 0 {
+		return nil, nil, false
+	}
+
+	var response map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&response); err != nil {
+		return nil, nil, false
+	}
+	data, ok := response["data"].(map[string]any)
+	if !ok {
+		return nil, nil, true
+	}
+
+	op := operation.OperationDefinitions[0]
+	var rootTypeName string
+	switch op.OperationType {
+	case ast.OperationTypeQuery:
+		rootTypeName = contract.definition.Index.QueryTypeName.String()
+	case ast.OperationTypeMutation:
+		rootTypeName = contract.definition.Index.MutationTypeName.String()
+	default:
+		return nil, nil, true
+	}
+	if !op.HasSelections {
+		return nil, nil, true
+	}
+
+	w := &contractResponseWalker{contract: contract, operation: &operation}
+	w.object(op.SelectionSet, rootTypeName, data, "")
+	if len(w.removed) == 0 {
+		return nil, nil, true
+	}
+
+	enforced, err := json.Marshal(response)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return enforced, w.removed, true
+}
+
+type contractResponseWalker struct {
+	contract  *contractSchema
+	operation *ast.Document
+	removed   []string
+}
+
+func (w *contractResponseWalker) object(selectionSet int, typeName string, object map[string]any, path string) {
+	if name, ok := object["__typename"].(string); ok {
+		typeName = name
+	}
+
+	requested := make(map[string]struct{}, len(object))
+	w.selectionSet(selectionSet, typeName, object, path, requested)
+
+	excluded := w.contract.excluded[typeName]
+	if len(excluded) == 0 {
+		return
+	}
+	for key := range object {
+		if _, ok := requested[key]; ok {
+			continue
+		}
+		if _, ok := excluded[key]; ok {
+			delete(object, key)
+			w.removed = append(w.removed, joinResponsePath(path, key))
+		}
+	}
+}
+
+// selectionSet collects the response keys of the selections that apply to the type and walks their values
+func (w *contractResponseWalker) selectionSet(ref int, typeName string, object map[string]any, path string, requested map[string]struct{}) {
+	for _, selectionRef := range w.operation.SelectionSets[ref].SelectionRefs {
+		selection := w.operation.Selections[selectionRef]
+		switch selection.Kind {
+		case ast.SelectionKindField:
+			w.field(selection.Ref, typeName, object, path, requested)
+		case ast.SelectionKindInlineFragment:
+			if w.operation.InlineFragmentHasTypeCondition(selection.Ref) &&
+				!typeConditionApplies(w.contract.definition, w.operation.InlineFragmentTypeConditionNameString(selection.Ref), typeName) {
+				continue
+			}
+			if set, ok := w.operation.InlineFragmentSelectionSet(selection.Ref); ok {
+				w.selectionSet(set, typeName, object, path, requested)
+			}
+		case ast.SelectionKindFragmentSpread:
+			fragmentRef, ok := w.operation.FragmentDefinitionRef(w.operation.FragmentSpreadNameBytes(selection.Ref))
+			if !ok || !typeConditionApplies(w.contract.definition, w.operation.FragmentDefinitionTypeNameString(fragmentRef), typeName) {
+				continue
+			}
+			w.selectionSet(w.operation.FragmentDefinitions[fragmentRef].SelectionSet, typeName, object, path, requested)
+		}
+	}
+}
+
+func (w *contractResponseWalker) field(ref int, typeName string, object map[string]any, path string, requested map[string]struct{}) {
+	name := w.operation.FieldNameString(ref)
+	key := w.operation.FieldAliasOrNameString(ref)
+	requested[key] = struct{}{}
+
+	value, ok := object[key]
+	if !ok || value == nil {
+		return
+	}
+	selectionSet, hasSelections := w.operation.FieldSelectionSet(ref)
+	if !hasSelections {
+		return
+	}
+	fieldPath := joinResponsePath(path, key)
+
+	// _entities isn't part of the federated schema. Its items are walked with their type names.
+	if name == "_entities" && typeName == w.contract.definition.Index.QueryTypeName.String() {
+		items, ok := value.([]any)
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			if object, ok := item.(map[string]any); ok {
+				if _, ok := object["__typename"].(string); ok {
+					w.object(selectionSet, "", object, joinResponsePath(fieldPath, strconv.Itoa(i)))
+				}
+			}
+		}
+		return
+	}
+
+	node, ok := w.contract.definition.Index.FirstNodeByNameStr(typeName)
+	if !ok {
+		return
+	}
+	fieldDefinition, ok := w.contract.definition.NodeFieldDefinitionByName(node, []byte(name))
+	if !ok {
+		return
+	}
+
+	w.value(w.contract.definition.ResolveTypeNameString(w.contract.definition.FieldDefinitionType(fieldDefinition)), value, selectionSet, fieldPath)
+}
+
+func (w *contractResponseWalker) value(typeName string, value any, selectionSet int, path string) {
+	switch v := value.(type) {
+	case []any:
+		for i, item := range v {
+			w.value(typeName, item, selectionSet, joinResponsePath(path, strconv.Itoa(i)))
+		}
+	case map[string]any:
+		w.object(selectionSet, typeName, v, path)
+	}
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const contractEnforcementTestRouterSchema = `
+directive @tag(name: String!) repeatable on FIELD_DEFINITION
+
+type Query {
+	employees: [Employee!]!
+	pets: [Pet!]!
+	internalStats: Int @tag(name: "internal")
+}
+
+type Employee {
+	id: Int!
+	name: String
+	salary: Int
+	notes: String @tag(name: "internal")
+}
+
+interface Pet {
+	name: String!
+}
+
+type Cat implements Pet {
+	name: String!
+	vetRecord: String
+}
+`
+
+const contractEnforcementTestClientSchema = `
+type Query {
+	employees: [Employee!]!
+	pets: [Pet!]!
+	internalStats: Int
+}
+
+type Employee {
+	id: Int!
+	name: String
+	notes: String
+}
+
+interface Pet {
+	name: String!
+}
+
+type Cat implements Pet {
+	name: String!
+}
+`
+
+func parseContractEnforcementTestSchema(t *testing.T, schema string) *ast.Document {
+	t.Helper()
+
+	definition, report := astparser.ParseGraphqlDocumentString(schema)
+	require.False(t, report.HasErrors(), report.Error())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+	return &definition
+}
+
+func newContractEnforcementTestSchema(t *testing.T, excludeTags ...string) *contractSchema {
+	t.Helper()
+
+	tags := make(map[string]struct{})
+	for _, tag := range excludeTags {
+		tags[tag] = struct{}{}
+	}
+	routerSchema := parseContractEnforcementTestSchema(t, contractEnforcementTestRouterSchema)
+	clientSchema := parseContractEnforcementTestSchema(t, contractEnforcementTestClientSchema)
+	return &contractSchema{
+		definition: routerSchema,
+		excluded:   contractExcludedFields(routerSchema, clientSchema, tags),
+	}
+}
+
+func TestContractExcludedFields(t *testing.T) {
+	t.Parallel()
+
+	contract := newContractEnforcementTestSchema(t, "internal")
+	require.Equal(t, map[string]map[string]struct{}{
+		"Query":    {"internalStats": {}},
+		"Employee": {"salary": {}, "notes": {}},
+		"Cat":      {"vetRecord": {}},
+	}, contract.excluded)
+
+	// Without a client schema only the tagged fields are excluded
+	routerSchema := parseContractEnforcementTestSchema(t, contractEnforcementTestRouterSchema)
+	require.Equal(t, map[string]map[string]struct{}{
+		"Query":    {"internalStats": {}},
+		"Employee": {"notes": {}},
+	}, contractExcludedFields(routerSchema, routerSchema, map[string]struct{}{"internal": {}}))
+}
+
+func TestEnforceSubgraphResponseContract(t *testing.T) {
+	t.Parallel()
+
+	contract := newContractEnforcementTestSchema(t, "internal")
+
+	tests := []struct {
+		name     string
+		query    string
+		response string
+		expected string
+		removed  []string
+	}{
+		{
+			name:     "removes the excluded fields that weren't requested",
+			query:    `{ employees { id name } }`,
+			response: `{"data":{"employees":[{"id":1,"name":"A","salary":100},{"id":2,"name":"B","notes":"x"}],"internalStats":3}}`,
+			expected: `{"data":{"employees":[{"id":1,"name":"A"},{"id":2,"name":"B"}]}}`,
+			removed:  []string{"employees.0.salary", "employees.1.notes", "internalStats"},
+		},
+		{
+			name:     "keeps the excluded fields that were requested",
+			query:    `{ employees { id salary } }`,
+			response: `{"data":{"employees":[{"id":1,"salary":100}]}}`,
+		},
+		{
+			name:     "keeps the unknown fields",
+			query:    `{ employees { id } }`,
+			response: `{"data":{"employees":[{"id":1,"extra":true}]}}`,
+		},
+		{
+			name:     "uses the type names of the objects",
+			query:    `{ pets { __typename name } }`,
+			response: `{"data":{"pets":[{"__typename":"Cat","name":"C","vetRecord":"ok"}]}}`,
+			expected: `{"data":{"pets":[{"__typename":"Cat","name":"C"}]}}`,
+			removed:  []string{"pets.0.vetRecord"},
+		},
+		{
+			name:     "walks the fragments and the aliases",
+			query:    `{ staff: employees { ... on Employee { id } ...Name } } fragment Name on Employee { name }`,
+			response: `{"data":{"staff":[{"id":1,"name":"A","salary":100}]}}`,
+			expected: `{"data":{"staff":[{"id":1,"name":"A"}]}}`,
+			removed:  []string{"staff.0.salary"},
+		},
+		{
+			name:     "walks the entities",
+			query:    `query($representations: [_Any!]!) { _entities(representations: $representations) { ... on Employee { __typename name } } }`,
+			response: `{"data":{"_entities":[{"__typename":"Employee","name":"A","salary":100}]}}`,
+			expected: `{"data":{"_entities":[{"__typename":"Employee","name":"A"}]}}`,
+			removed:  []string{"_entities.0.salary"},
+		},
+		{
+			name:     "ignores the responses without data",
+			query:    `{ employees { id } }`,
+			response: `{"errors":[{"message":"failed"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			enforced, removed, ok := enforceSubgraphResponseContract(contract, tt.query, []byte(tt.response))
+			require.True(t, ok)
+			require.ElementsMatch(t, tt.removed, removed)
+			if tt.expected == "" {
+				require.Nil(t, enforced)
+				return
+			}
+			require.JSONEq(t, tt.expected, string(enforced))
+		})
+	}
+
+	_, _, ok := enforceSubgraphResponseContract(contract, `{ employees {`, []byte(`{"data":{}}`))
+	require.False(t, ok)
+}
+
+func TestContractEnforcer(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.WarnLevel)
+	c := NewContractEnforcer(&ContractEnforcerOptions{
+		Logger:              zap.New(logCore),
+		ExcludeTags:         []string{"internal"},
+		MaxLoggedViolations: 1,
+	})
+
+	body := `{"data":{"employees":[{"id":1,"salary":100,"notes":"x"}]}}`
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	newExchange := func() (*http.Request, *http.Response) {
+		req, err := http.NewRequest(http.MethodPost, "http://employees/graphql", strings.NewReader(`{"query":"{ employees { id } }"}`))
+		require.NoError(t, err)
+		res := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": []string{"gzip"}},
+			Body:       io.NopCloser(bytes.NewReader(compressed.Bytes())),
+		}
+		return req, res
+	}
+
+	// The responses aren't changed until the schemas are known
+	req, res := newExchange()
+	require.NoError(t, c.EnforceResponse(req, res))
+	restored, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, compressed.Bytes(), restored)
+
+	c.setSchemas(
+		parseContractEnforcementTestSchema(t, contractEnforcementTestRouterSchema),
+		parseContractEnforcementTestSchema(t, contractEnforcementTestClientSchema),
+	)
+
+	req, res = newExchange()
+	require.NoError(t, c.EnforceResponse(req, res))
+
+	enforced, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":{"employees":[{"id":1}]}}`, string(enforced))
+	require.Empty(t, res.Header.Get("Content-Encoding"))
+	require.Equal(t, int64(len(enforced)), res.ContentLength)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	require.Equal(t, contractEnforcementLoggerName, entries[0].LoggerName)
+	require.Equal(t, int64(2), entries[0].ContextMap()["violation_count"])
+	require.Len(t, entries[0].ContextMap()["removed_fields"], 1)
+}
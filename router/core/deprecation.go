@@ -0,0 +1,306 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astvisitor"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+type DeprecationKind string
+
+const (
+	// DeprecationKindConfig is the usage of a deprecated option of the router config
+	DeprecationKindConfig DeprecationKind = "config"
+	// DeprecationKindField is the usage of a field of the schema that is marked with @deprecated
+	DeprecationKindField DeprecationKind = "field"
+)
+
+const (
+	deprecatedDirectiveName       = "deprecated"
+	deprecationReasonArgumentName = "reason"
+	defaultDeprecationReason      = "No longer supported"
+	// maxClientDeprecationWarnings bounds the number of fields listed to clients that are throttled,
+	// since the client names and versions are sent by the clients
+	maxClientDeprecationWarnings = 10_000
+)
+
+type DeprecationReporterOptions struct {
+	Logger *zap.Logger
+	// LogInterval is the minimum time between two log entries of the same deprecation. Zero logs every usage.
+	LogInterval time.Duration
+	// ClientWarnings lists the deprecated fields used by the operation in the extensions of the response
+	ClientWarnings bool
+	// ClientWarningInterval is the minimum time between two responses to the same client that list the same field.
+	// Zero lists the fields in every response.
+	ClientWarningInterval time.Duration
+}
+
+// DeprecationReporter logs the usage of deprecated config options and schema fields to a dedicated logger
+// and counts every usage. The log entries of the same deprecation are throttled, the counter is not.
+type DeprecationReporter struct {
+	logger                *zap.Logger
+	logInterval           time.Duration
+	clientWarnings        bool
+	clientWarningInterval time.Duration
+
+	mu            sync.Mutex
+	usages        map[deprecationKey]*deprecationUsage
+	registrations []otelmetric.Registration
+	// warnedClients is the last time a field was listed in a response to the client
+	warnedClients map[clientDeprecationKey]time.Time
+}
+
+type clientDeprecationKey struct {
+	clientName, clientVersion string
+	coordinate                string
+}
+
+type deprecationKey struct {
+	kind DeprecationKind
+	name string
+}
+
+type deprecationUsage struct {
+	count      int64
+	lastLogged time.Time
+}
+
+func NewDeprecationReporter(opts *DeprecationReporterOptions) *DeprecationReporter {
+	return &DeprecationReporter{
+		logger:                opts.Logger.Named("deprecation"),
+		logInterval:           opts.LogInterval,
+		clientWarnings:        opts.ClientWarnings,
+		clientWarningInterval: opts.ClientWarningInterval,
+		usages:                map[deprecationKey]*deprecationUsage{},
+		warnedClients:         map[clientDeprecationKey]time.Time{},
+	}
+}
+
+// Report records a usage of the deprecated config option or schema field with the given name
+func (d *DeprecationReporter) Report(kind DeprecationKind, name, reason string, fields ...zap.Field) {
+	key := deprecationKey{kind: kind, name: name}
+	now := time.Now()
+
+	d.mu.Lock()
+	usage, ok := d.usages[key]
+	if !ok {
+		usage = &deprecationUsage{}
+		d.usages[key] = usage
+	}
+	usage.count++
+	count := usage.count
+	shouldLog := usage.lastLogged.IsZero() || now.Sub(usage.lastLogged) >= d.logInterval
+	if shouldLog {
+		usage.lastLogged = now
+	}
+	d.mu.Unlock()
+
+	if !shouldLog {
+		return
+	}
+
+	d.logger.Warn("Deprecated "+string(kind)+" used",
+		append([]zap.Field{
+			zap.String("deprecation_kind", string(kind)),
+			zap.String("deprecation_name", name),
+			zap.String("deprecation_reason", reason),
+			zap.Int64("usage_count", count),
+		}, fields...)...,
+	)
+}
+
+// warnClient returns the deprecated fields to list in the response to the client. A field is listed to the same
+// client once per interval, so that the responses aren't bloated while the client isn't migrated yet.
+func (d *DeprecationReporter) warnClient(clientInfo *ClientInfo, fields []deprecatedField) []deprecatedField {
+	if !d.clientWarnings || len(fields) == 0 {
+		return nil
+	}
+	if d.clientWarningInterval <= 0 {
+		return fields
+	}
+
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.warnedClients) >= maxClientDeprecationWarnings {
+		for key, warned := range d.warnedClients {
+			if now.Sub(warned) >= d.clientWarningInterval {
+				delete(d.warnedClients, key)
+			}
+		}
+	}
+
+	var warnings []deprecatedField
+	for _, field := range fields {
+		key := clientDeprecationKey{clientName: clientInfo.Name, clientVersion: clientInfo.Version, coordinate: field.coordinate}
+		if warned, ok := d.warnedClients[key]; ok && now.Sub(warned) < d.clientWarningInterval {
+			continue
+		}
+		// Without space left the field is listed anyway, at the cost of not being throttled
+		if _, ok := d.warnedClients[key]; ok || len(d.warnedClients) < maxClientDeprecationWarnings {
+			d.warnedClients[key] = now
+		}
+		warnings = append(warnings, field)
+	}
+
+	return warnings
+}
+
+// Usage returns the number of reported usages of the deprecated config option or schema field
+func (d *DeprecationReporter) Usage(kind DeprecationKind, name string) int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if usage, ok := d.usages[deprecationKey{kind: kind, name: name}]; ok {
+		return usage.count
+	}
+	return 0
+}
+
+// RegisterMetrics exposes the usages on the meter provider
+func (d *DeprecationReporter) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	usages, err := meter.Int64ObservableCounter(
+		"router.deprecation.usages",
+		otelmetric.WithDescription("Number of usages of deprecated config options and schema fields"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		for key, usage := range d.usages {
+			o.ObserveInt64(usages, usage.count, otelmetric.WithAttributes(
+				attribute.String("kind", string(key.kind)),
+				attribute.String("name", key.name),
+			))
+		}
+		return nil
+	}, usages)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.registrations = append(d.registrations, reg)
+	d.mu.Unlock()
+
+	return nil
+}
+
+func (d *DeprecationReporter) Shutdown() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var err error
+	for _, reg := range d.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	d.registrations = nil
+
+	return err
+}
+
+type deprecatedField struct {
+	// coordinate is the schema coordinate of the field, e.g. Query.employee
+	coordinate string
+	reason     string
+}
+
+// deprecatedFields returns the fields of the operation that are marked with @deprecated in the schema.
+// Every field is returned once, even if it is selected multiple times.
+func deprecatedFields(operation, definition *ast.Document) []deprecatedField {
+	walker := astvisitor.NewWalker(8)
+	v := &deprecatedFieldsVisitor{
+		walker:     &walker,
+		operation:  operation,
+		definition: definition,
+	}
+	walker.RegisterEnterFieldVisitor(v)
+
+	report := &operationreport.Report{}
+	walker.Walk(operation, definition, report)
+	if report.HasErrors() {
+		return nil
+	}
+
+	return v.fields
+}
+
+type deprecatedFieldsVisitor struct {
+	walker                *astvisitor.Walker
+	operation, definition *ast.Document
+	fields                []deprecatedField
+}
+
+func (v *deprecatedFieldsVisitor) EnterField(ref int) {
+	enclosing := v.walker.EnclosingTypeDefinition
+	fieldDefinition, ok := v.definition.NodeFieldDefinitionByName(enclosing, v.operation.FieldNameBytes(ref))
+	if !ok {
+		return
+	}
+
+	directive, ok := v.definition.FieldDefinitionDirectiveByName(fieldDefinition, []byte(deprecatedDirectiveName))
+	if !ok {
+		return
+	}
+
+	coordinate := v.definition.NodeNameString(enclosing) + "." + v.definition.FieldDefinitionNameString(fieldDefinition)
+	for _, field := range v.fields {
+		if field.coordinate == coordinate {
+			return
+		}
+	}
+
+	reason := defaultDeprecationReason
+	if value, ok := v.definition.DirectiveArgumentValueByName(directive, []byte(deprecationReasonArgumentName)); ok {
+		reason = v.definition.ValueContentString(value)
+	}
+
+	v.fields = append(v.fields, deprecatedField{coordinate: coordinate, reason: reason})
+}
+
+type deprecationWarning struct {
+	Coordinate string `json:"coordinate"`
+	Reason     string `json:"reason"`
+}
+
+// addDeprecationsExtension lists the deprecated fields in the deprecations extension of the response in buf
+func addDeprecationsExtension(buf *bytes.Buffer, fields []deprecatedField) error {
+	warnings := make([]deprecationWarning, 0, len(fields))
+	for _, field := range fields {
+		warnings = append(warnings, deprecationWarning{Coordinate: field.coordinate, Reason: field.reason})
+	}
+	value, err := json.Marshal(warnings)
+	if err != nil {
+		return err
+	}
+
+	response, err := jsonparser.Set(bytes.Clone(buf.Bytes()), value, "extensions", "deprecations")
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	_, err = buf.Write(response)
+	return err
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDeprecationReporter(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	reporter := NewDeprecationReporter(&DeprecationReporterOptions{
+		Logger:      zap.New(core),
+		LogInterval: time.Hour,
+	})
+
+	reporter.Report(DeprecationKindConfig, "override_routing_url", "Use overrides instead")
+	reporter.Report(DeprecationKindConfig, "override_routing_url", "Use overrides instead")
+	reporter.Report(DeprecationKindField, "Query.old", defaultDeprecationReason)
+
+	require.Equal(t, int64(2), reporter.Usage(DeprecationKindConfig, "override_routing_url"))
+	require.Equal(t, int64(1), reporter.Usage(DeprecationKindField, "Query.old"))
+	require.Zero(t, reporter.Usage(DeprecationKindField, "Query.new"))
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	require.Equal(t, "deprecation", entries[0].LoggerName)
+	require.Equal(t, "override_routing_url", entries[0].ContextMap()["deprecation_name"])
+	require.Equal(t, "Query.old", entries[1].ContextMap()["deprecation_name"])
+
+	unthrottled := NewDeprecationReporter(&DeprecationReporterOptions{Logger: zap.New(core)})
+	unthrottled.Report(DeprecationKindField, "Query.old", defaultDeprecationReason)
+	unthrottled.Report(DeprecationKindField, "Query.old", defaultDeprecationReason)
+	require.Equal(t, 4, logs.Len())
+}
+
+func TestDeprecationClientWarnings(t *testing.T) {
+	t.Parallel()
+
+	fields := []deprecatedField{
+		{coordinate: "Details.middlename", reason: defaultDeprecationReason},
+		{coordinate: "Query.old", reason: "Use new"},
+	}
+	web := &ClientInfo{Name: "web", Version: "1.0.0"}
+
+	reporter := NewDeprecationReporter(&DeprecationReporterOptions{
+		Logger:                zap.NewNop(),
+		ClientWarnings:        true,
+		ClientWarningInterval: time.Hour,
+	})
+	require.Equal(t, fields, reporter.warnClient(web, fields))
+	// The fields are listed once per interval to the same client
+	require.Empty(t, reporter.warnClient(web, fields))
+	require.Equal(t, fields, reporter.warnClient(&ClientInfo{Name: "web", Version: "1.1.0"}, fields))
+	require.Empty(t, reporter.warnClient(web, fields[1:]))
+
+	unthrottled := NewDeprecationReporter(&DeprecationReporterOptions{Logger: zap.NewNop(), ClientWarnings: true})
+	require.Equal(t, fields, unthrottled.warnClient(web, fields))
+	require.Equal(t, fields, unthrottled.warnClient(web, fields))
+
+	disabled := NewDeprecationReporter(&DeprecationReporterOptions{Logger: zap.NewNop()})
+	require.Empty(t, disabled.warnClient(web, fields))
+
+	buf := bytes.NewBufferString(`{"data":{"old":1},"extensions":{"trace":{}}}`)
+	require.NoError(t, addDeprecationsExtension(buf, fields[1:]))
+	require.Equal(t, `{"data":{"old":1},"extensions":{"trace":{},"deprecations":[{"coordinate":"Query.old","reason":"Use new"}]}}`, buf.String())
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+type DrainKind string
+
+const (
+	// DrainKindShutdown is the drain of the connections and requests when the router shuts down
+	DrainKindShutdown DrainKind = "shutdown"
+	// DrainKindConfigSwap is the drain of the requests of the previous server when the config is swapped
+	DrainKindConfigSwap DrainKind = "config_swap"
+)
+
+// DrainReport is the outcome of a drain
+type DrainReport struct {
+	Kind DrainKind
+	// Connections is the number of open connections when the drain started. Connections are only closed on shutdown.
+	Connections int64
+	// Requests is the number of in-flight requests when the drain started
+	Requests int64
+	// CanceledSubscriptions is the number of subscriptions that were still active and are canceled
+	CanceledSubscriptions int64
+	// Duration is the time that was waited for the connections and requests
+	Duration time.Duration
+	// Completed is false if the grace period expired before everything was drained
+	Completed bool
+}
+
+type drainTotals struct {
+	drains                int64
+	incomplete            int64
+	connections           int64
+	requests              int64
+	canceledSubscriptions int64
+	seconds               float64
+}
+
+// drainTracker counts the open connections of the HTTP server and reports the drains on shutdowns and config swaps,
+// so that deploy automation can verify that rollouts are graceful
+type drainTracker struct {
+	logger *zap.Logger
+
+	mu sync.Mutex
+	// connections are the open connections that the HTTP server still manages, hijacked connections are excluded
+	connections map[net.Conn]struct{}
+	totals      map[DrainKind]*drainTotals
+}
+
+func newDrainTracker(logger *zap.Logger) *drainTracker {
+	return &drainTracker{
+		logger:      logger,
+		connections: map[net.Conn]struct{}{},
+		totals:      map[DrainKind]*drainTotals{},
+	}
+}
+
+// connState is the ConnState hook of the HTTP server
+func (t *drainTracker) connState(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		t.connections[conn] = struct{}{}
+	case http.StateHijacked, http.StateClosed:
+		delete(t.connections, conn)
+	}
+}
+
+func (t *drainTracker) openConnections() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return int64(len(t.connections))
+}
+
+// report logs the drain and adds it to the metrics
+func (t *drainTracker) report(r DrainReport) {
+	fields := []zap.Field{
+		zap.String("kind", string(r.Kind)),
+		zap.Int64("connections", r.Connections),
+		zap.Int64("requests", r.Requests),
+		zap.Int64("canceled_subscriptions", r.CanceledSubscriptions),
+		zap.Duration("duration", r.Duration),
+	}
+	if r.Completed {
+		t.logger.Info("Connections drained", fields...)
+	} else {
+		t.logger.Warn("Connections not fully drained within the grace period", fields...)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	totals, ok := t.totals[r.Kind]
+	if !ok {
+		totals = &drainTotals{}
+		t.totals[r.Kind] = totals
+	}
+	totals.drains++
+	if !r.Completed {
+		totals.incomplete++
+	}
+	totals.connections += r.Connections
+	totals.requests += r.Requests
+	totals.canceledSubscriptions += r.CanceledSubscriptions
+	totals.seconds += r.Duration.Seconds()
+}
+
+// RegisterMetrics exposes the drains on the meter provider. The metrics stay registered until the meter provider
+// is shut down, so that the drain of the shutdown is exported with the final collection.
+func (t *drainTracker) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	drains, err := meter.Int64ObservableCounter(
+		"router.http.server.drains",
+		otelmetric.WithDescription("Number of drains of connections and requests on shutdowns and config swaps"),
+	)
+	if err != nil {
+		return err
+	}
+	connections, err := meter.Int64ObservableCounter(
+		"router.http.server.drained_connections",
+		otelmetric.WithDescription("Number of connections that were open when a drain started"),
+	)
+	if err != nil {
+		return err
+	}
+	requests, err := meter.Int64ObservableCounter(
+		"router.http.server.drained_requests",
+		otelmetric.WithDescription("Number of requests that were in flight when a drain started"),
+	)
+	if err != nil {
+		return err
+	}
+	canceledSubscriptions, err := meter.Int64ObservableCounter(
+		"router.http.server.canceled_subscriptions",
+		otelmetric.WithDescription("Number of subscriptions that were canceled by a drain"),
+	)
+	if err != nil {
+		return err
+	}
+	duration, err := meter.Float64ObservableCounter(
+		"router.http.server.drain_duration",
+		otelmetric.WithDescription("Total time waited for drains"),
+		otelmetric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		for kind, totals := range t.totals {
+			kindAttr := attribute.String("kind", string(kind))
+			if completed := totals.drains - totals.incomplete; completed > 0 {
+				o.ObserveInt64(drains, completed, otelmetric.WithAttributes(kindAttr, attribute.Bool("completed", true)))
+			}
+			if totals.incomplete > 0 {
+				o.ObserveInt64(drains, totals.incomplete, otelmetric.WithAttributes(kindAttr, attribute.Bool("completed", false)))
+			}
+			o.ObserveInt64(connections, totals.connections, otelmetric.WithAttributes(kindAttr))
+			o.ObserveInt64(requests, totals.requests, otelmetric.WithAttributes(kindAttr))
+			o.ObserveInt64(canceledSubscriptions, totals.canceledSubscriptions, otelmetric.WithAttributes(kindAttr))
+			o.ObserveFloat64(duration, totals.seconds, otelmetric.WithAttributes(kindAttr))
+		}
+		return nil
+	}, drains, connections, requests, canceledSubscriptions, duration)
+
+	return err
+}
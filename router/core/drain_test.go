@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDrainTracker(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	tracker := newDrainTracker(zap.New(core))
+
+	reader := sdkmetric.NewManualReader()
+	require.NoError(t, tracker.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Config.ConnState = tracker.connState
+	srv.Start()
+	defer srv.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := http.Get(srv.URL)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+	}()
+	<-started
+	require.Equal(t, int64(1), tracker.openConnections())
+
+	drain := DrainReport{Kind: DrainKindShutdown, Connections: tracker.openConnections(), Requests: 1, CanceledSubscriptions: 2}
+	drainStart := time.Now()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	require.NoError(t, srv.Config.Shutdown(context.Background()))
+	drain.Duration = time.Since(drainStart)
+	drain.Completed = true
+	tracker.report(drain)
+	<-done
+
+	require.Eventually(t, func() bool { return tracker.openConnections() == 0 }, time.Second, 10*time.Millisecond)
+
+	tracker.report(DrainReport{Kind: DrainKindConfigSwap, Requests: 3, Duration: time.Second})
+
+	entries := logs.FilterMessage("Connections drained").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, "shutdown", entries[0].ContextMap()["kind"])
+	require.Equal(t, int64(1), entries[0].ContextMap()["connections"])
+	require.Equal(t, int64(2), entries[0].ContextMap()["canceled_subscriptions"])
+	require.Len(t, logs.FilterMessage("Connections not fully drained within the grace period").All(), 1)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	metrics := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+
+	drains := metrics["router.http.server.drains"].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, drains, 2)
+	for _, dp := range drains {
+		kind, _ := dp.Attributes.Value("kind")
+		completed, _ := dp.Attributes.Value("completed")
+		require.Equal(t, kind.AsString() == string(DrainKindShutdown), completed.AsBool())
+		require.Equal(t, int64(1), dp.Value)
+	}
+
+	for _, dp := range metrics["router.http.server.drained_requests"].(metricdata.Sum[int64]).DataPoints {
+		kind, _ := dp.Attributes.Value("kind")
+		if kind.AsString() == string(DrainKindConfigSwap) {
+			require.Equal(t, int64(3), dp.Value)
+		} else {
+			require.Equal(t, int64(1), dp.Value)
+		}
+	}
+
+	for _, dp := range metrics["router.http.server.drain_duration"].(metricdata.Sum[float64]).DataPoints {
+		kind, _ := dp.Attributes.Value("kind")
+		if kind.AsString() == string(DrainKindConfigSwap) {
+			require.Equal(t, float64(1), dp.Value)
+		} else {
+			require.Greater(t, dp.Value, float64(0))
+		}
+	}
+}
@@ -0,0 +1,611 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wundergraph/cosmo/router/internal/unsafebytes"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/pool"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type EntityBatcherOptions struct {
+	MaxBatchSize int
+	BatchWait    time.Duration
+	// DedupeKeys are the top level fields that identify the representations by entity type
+	DedupeKeys  map[string][]string
+	MetricStore metric.Provider
+}
+
+// EntityBatcher rewrites the _entities requests of the engine to the subgraphs. It merges the requests of concurrent
+// operations within the batch window, removes representations with the same dedupe key and splits batches over the
+// maximum size into parallel requests. The responses are mapped back, so that every request of the engine gets one
+// entity for each of its representations in the original order.
+type EntityBatcher struct {
+	maxBatchSize int
+	batchWait    time.Duration
+	dedupeKeys   map[string][]string
+	metricStore  metric.Provider
+
+	mu      sync.Mutex
+	pending map[uint64]*entityBatch
+}
+
+// roundTripFunc sends a request to the subgraph
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func NewEntityBatcher(opts *EntityBatcherOptions) (*EntityBatcher, error) {
+	if opts.MaxBatchSize < 0 {
+		return nil, errors.New("the maximum entity batch size must not be negative")
+	}
+	if opts.BatchWait < 0 {
+		return nil, errors.New("the entity batch wait window must not be negative")
+	}
+	for typeName, fields := range opts.DedupeKeys {
+		if typeName == "" {
+			return nil, errors.New("entity dedupe keys require a type name")
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("the entity dedupe key of type '%s' requires at least one field", typeName)
+		}
+	}
+
+	metricStore := opts.MetricStore
+	if metricStore == nil {
+		metricStore = metric.NewNoopMetrics()
+	}
+
+	return &EntityBatcher{
+		maxBatchSize: opts.MaxBatchSize,
+		batchWait:    opts.BatchWait,
+		dedupeKeys:   opts.DedupeKeys,
+		metricStore:  metricStore,
+		pending:      make(map[uint64]*entityBatch),
+	}, nil
+}
+
+// RoundTrip sends the request with next. Requests that aren't _entities requests are passed through unchanged.
+func (b *EntityBatcher) RoundTrip(req *http.Request, next roundTripFunc) (*http.Response, error) {
+	er, err := readEntityRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if er == nil || len(er.representations) == 0 {
+		return next(req)
+	}
+
+	if b.batchWait <= 0 {
+		batch := &entityBatch{leader: req, request: er, next: next}
+		w := batch.add(er.representations)
+		b.execute(req.Context(), batch)
+		return w.response(req)
+	}
+
+	key, err := b.batchKey(req, er)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	if !ok || batch.ctx.Err() != nil {
+		batch = newEntityBatch(req, er, next)
+		b.pending[key] = batch
+		batch.timer = time.AfterFunc(b.batchWait, func() {
+			b.flush(key, batch)
+		})
+	}
+	w := batch.add(er.representations)
+	full := b.maxBatchSize > 0 && batch.size >= b.maxBatchSize
+	b.mu.Unlock()
+
+	if full {
+		b.flush(key, batch)
+	}
+
+	select {
+	case <-w.done:
+		return w.response(req)
+	case <-req.Context().Done():
+		batch.leave()
+		return nil, req.Context().Err()
+	}
+}
+
+// flush sends the pending batch once, either after the batch window or when it is full
+func (b *EntityBatcher) flush(key uint64, batch *entityBatch) {
+	b.mu.Lock()
+	if b.pending[key] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	b.mu.Unlock()
+
+	batch.timer.Stop()
+
+	go func() {
+		defer batch.cancel()
+		b.execute(batch.ctx, batch)
+	}()
+}
+
+// execute sends the unique representations of the batch in requests of at most the maximum batch size and
+// distributes the responses to the waiters
+func (b *EntityBatcher) execute(ctx context.Context, batch *entityBatch) {
+	defer func() {
+		for _, w := range batch.waiters {
+			close(w.done)
+		}
+	}()
+
+	var (
+		unique  []json.RawMessage
+		indexes = make(map[string]int)
+	)
+	for _, w := range batch.waiters {
+		w.targets = make([]int, len(w.representations))
+		for i, rep := range w.representations {
+			key := b.dedupeKey(rep)
+			index, ok := indexes[key]
+			if !ok {
+				index = len(unique)
+				indexes[key] = index
+				unique = append(unique, rep)
+			}
+			w.targets[i] = index
+		}
+	}
+
+	attributes := entityBatchAttributes(batch.leader)
+
+	// The request of a single operation without removed representations is sent as it is
+	if len(batch.waiters) == 1 && len(unique) == len(batch.request.representations) && (b.maxBatchSize <= 0 || len(unique) <= b.maxBatchSize) {
+		b.metricStore.MeasureEntityBatchSize(ctx, len(unique), attributes...)
+		w := batch.waiters[0]
+		w.res, w.err = batch.next(batch.leader.WithContext(ctx))
+		w.passThrough = true
+		return
+	}
+
+	chunkSize := len(unique)
+	if b.maxBatchSize > 0 && b.maxBatchSize < chunkSize {
+		chunkSize = b.maxBatchSize
+	}
+
+	chunks := make([]*entityChunk, 0, (len(unique)+chunkSize-1)/chunkSize)
+	for start := 0; start < len(unique); start += chunkSize {
+		end := min(start+chunkSize, len(unique))
+		chunks = append(chunks, &entityChunk{start: start, representations: unique[start:end]})
+	}
+
+	var wg sync.WaitGroup
+	for _, chunk := range chunks {
+		wg.Add(1)
+		go func(chunk *entityChunk) {
+			defer wg.Done()
+			b.metricStore.MeasureEntityBatchSize(ctx, len(chunk.representations), attributes...)
+			chunk.send(ctx, batch)
+		}(chunk)
+	}
+	wg.Wait()
+
+	for _, chunk := range chunks {
+		if chunk.err != nil {
+			for _, w := range batch.waiters {
+				w.err = chunk.err
+			}
+			return
+		}
+	}
+	for _, chunk := range chunks {
+		// Failed or unexpected responses are returned as they are for the engine to handle them
+		if chunk.raw {
+			for _, w := range batch.waiters {
+				w.res = chunk.res
+				w.body = chunk.body
+			}
+			return
+		}
+	}
+
+	for _, w := range batch.waiters {
+		w.res = chunks[0].res
+		w.body, w.err = mergeEntityResponses(chunks, chunkSize, w.targets)
+	}
+}
+
+// dedupeKey returns the key of a representation. Without a dedupe key of its type, only equal representations
+// have the same key.
+func (b *EntityBatcher) dedupeKey(rep json.RawMessage) string {
+	if len(b.dedupeKeys) == 0 {
+		return string(rep)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rep, &fields); err != nil {
+		return string(rep)
+	}
+	var typeName string
+	if err := json.Unmarshal(fields["__typename"], &typeName); err != nil {
+		return string(rep)
+	}
+	keyFields, ok := b.dedupeKeys[typeName]
+	if !ok {
+		return string(rep)
+	}
+
+	// The key starts with the type name, so it can't collide with a representation, which starts with a brace
+	key := typeName
+	for _, field := range keyFields {
+		value, ok := fields[field]
+		if !ok {
+			return string(rep)
+		}
+		key += "\x00" + string(value)
+	}
+	return key
+}
+
+// batchKey hashes everything that must be equal to merge two requests: the URL, the headers and the body without
+// the representations
+func (b *EntityBatcher) batchKey(req *http.Request, er *entityRequest) (uint64, error) {
+	body, err := er.render(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	keyGen := pool.Hash64.Get()
+	defer pool.Hash64.Put(keyGen)
+
+	_, _ = keyGen.WriteString(req.Method)
+	_, _ = keyGen.WriteString(req.URL.String())
+	_, _ = keyGen.Write(body)
+
+	headers := make([]string, 0, len(req.Header))
+	for name, values := range req.Header {
+		for _, value := range values {
+			headers = append(headers, name+":"+value)
+		}
+	}
+	sort.Strings(headers)
+	for i := range headers {
+		_, _ = keyGen.Write(unsafebytes.StringToBytes(headers[i]))
+	}
+
+	return keyGen.Sum64(), nil
+}
+
+func entityBatchAttributes(req *http.Request) []attribute.KeyValue {
+	reqContext := getRequestContext(req.Context())
+	if reqContext == nil {
+		return nil
+	}
+	if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+		return []attribute.KeyValue{
+			otel.WgSubgraphName.String(subgraph.Name),
+			otel.WgSubgraphID.String(subgraph.Id),
+		}
+	}
+	return nil
+}
+
+// entityBatch collects the representations of the requests that are sent together
+type entityBatch struct {
+	// leader is the first request of the batch and the template of the subgraph requests
+	leader  *http.Request
+	request *entityRequest
+	next    roundTripFunc
+	timer   *time.Timer
+
+	// ctx is canceled when all waiters left the batch
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	waiters []*entityBatchWaiter
+	size    int
+
+	mu   sync.Mutex
+	refs int
+}
+
+func newEntityBatch(req *http.Request, er *entityRequest, next roundTripFunc) *entityBatch {
+	// The batch outlives the request of the leader, which might be canceled while other requests still wait
+	ctx, cancel := context.WithCancel(context.WithoutCancel(req.Context()))
+	return &entityBatch{
+		leader:  req,
+		request: er,
+		next:    next,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (b *entityBatch) add(representations []json.RawMessage) *entityBatchWaiter {
+	w := &entityBatchWaiter{representations: representations, done: make(chan struct{})}
+	b.waiters = append(b.waiters, w)
+	b.size += len(representations)
+
+	b.mu.Lock()
+	b.refs++
+	b.mu.Unlock()
+
+	return w
+}
+
+func (b *entityBatch) leave() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refs--
+	if b.refs == 0 {
+		b.cancel()
+	}
+}
+
+type entityBatchWaiter struct {
+	representations []json.RawMessage
+	// targets are the indexes of the representations in the unique representations of the batch
+	targets []int
+	done    chan struct{}
+
+	res *http.Response
+	// passThrough is set when res is the response of the unchanged request
+	passThrough bool
+	body        []byte
+	err         error
+}
+
+func (w *entityBatchWaiter) response(req *http.Request) (*http.Response, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	if w.passThrough {
+		return w.res, nil
+	}
+
+	res := &http.Response{
+		Status:        w.res.Status,
+		StatusCode:    w.res.StatusCode,
+		Proto:         w.res.Proto,
+		ProtoMajor:    w.res.ProtoMajor,
+		ProtoMinor:    w.res.ProtoMinor,
+		Header:        w.res.Header.Clone(),
+		Trailer:       w.res.Trailer.Clone(),
+		ContentLength: int64(len(w.body)),
+		Body:          io.NopCloser(bytes.NewReader(w.body)),
+		Request:       req,
+	}
+	res.Header.Del("Content-Length")
+
+	return res, nil
+}
+
+type entityChunk struct {
+	start           int
+	representations []json.RawMessage
+
+	res  *http.Response
+	body []byte
+	err  error
+	// raw is set when the response isn't a successful entity response
+	raw      bool
+	entities []json.RawMessage
+	errors   []map[string]json.RawMessage
+	// extensions of the response are kept for the first chunk only
+	extensions json.RawMessage
+}
+
+type entityResponse struct {
+	Data struct {
+		Entities []json.RawMessage `json:"_entities"`
+	} `json:"data"`
+	Errors     []map[string]json.RawMessage `json:"errors"`
+	Extensions json.RawMessage              `json:"extensions"`
+}
+
+func (c *entityChunk) send(ctx context.Context, batch *entityBatch) {
+	body, err := batch.request.render(c.representations)
+	if err != nil {
+		c.err = err
+		return
+	}
+
+	req := batch.leader.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Del("Content-Length")
+	// The response is decoded, so its compression is left to the transport
+	req.Header.Del("Accept-Encoding")
+
+	res, err := batch.next(req)
+	if err != nil {
+		c.err = err
+		return
+	}
+	defer res.Body.Close()
+
+	c.res = res
+	c.body, c.err = io.ReadAll(res.Body)
+	if c.err != nil {
+		return
+	}
+
+	var decoded entityResponse
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Encoding") != "" || json.Unmarshal(c.body, &decoded) != nil {
+		c.raw = true
+		return
+	}
+	c.entities = decoded.Data.Entities
+	c.errors = decoded.Errors
+	c.extensions = decoded.Extensions
+}
+
+// mergeEntityResponses builds the response of a waiter from the responses of the chunks. The errors of an entity
+// are repeated for every representation of the waiter with the entity, with the index of the representation.
+func mergeEntityResponses(chunks []*entityChunk, chunkSize int, targets []int) ([]byte, error) {
+	var errs []map[string]json.RawMessage
+
+	for _, chunk := range chunks {
+		for _, gqlErr := range chunk.errors {
+			var path []json.RawMessage
+			_ = json.Unmarshal(gqlErr["path"], &path)
+
+			index := -1
+			if len(path) >= 2 && string(path[0]) == `"_entities"` {
+				if i, err := strconv.Atoi(string(path[1])); err == nil {
+					index = chunk.start + i
+				}
+			}
+			if index == -1 {
+				errs = append(errs, gqlErr)
+				continue
+			}
+
+			for i, target := range targets {
+				if target != index {
+					continue
+				}
+				mapped := make(map[string]json.RawMessage, len(gqlErr))
+				for k, v := range gqlErr {
+					mapped[k] = v
+				}
+				mappedPath := append([]json.RawMessage{}, path...)
+				mappedPath[1] = json.RawMessage(strconv.Itoa(i))
+				p, err := json.Marshal(mappedPath)
+				if err != nil {
+					return nil, err
+				}
+				mapped["path"] = p
+				errs = append(errs, mapped)
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"data":{"_entities":[`)
+	for i, target := range targets {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		chunk := chunks[target/chunkSize]
+		offset := target - chunk.start
+		if offset < len(chunk.entities) && len(chunk.entities[offset]) > 0 {
+			buf.Write(chunk.entities[offset])
+		} else {
+			buf.WriteString("null")
+		}
+	}
+	buf.WriteString("]}")
+
+	if len(errs) > 0 {
+		buf.WriteString(`,"errors":`)
+		if err := writeJSON(buf, errs); err != nil {
+			return nil, err
+		}
+	}
+	if len(chunks[0].extensions) > 0 {
+		buf.WriteString(`,"extensions":`)
+		buf.Write(chunks[0].extensions)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// entityRequest is a decoded _entities request of the engine
+type entityRequest struct {
+	body            map[string]json.RawMessage
+	variables       map[string]json.RawMessage
+	representations []json.RawMessage
+}
+
+// readEntityRequest decodes the body of an _entities request and restores it. It returns nil for other requests.
+func readEntityRequest(req *http.Request) (*entityRequest, error) {
+	if req.Method != http.MethodPost || req.Body == nil || req.Header.Get("Upgrade") != "" {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !bytes.Contains(body, []byte("_entities")) {
+		return nil, nil
+	}
+
+	er := &entityRequest{}
+	if err := json.Unmarshal(body, &er.body); err != nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(er.body["variables"], &er.variables); err != nil {
+		return nil, nil
+	}
+	if err := json.Unmarshal(er.variables["representations"], &er.representations); err != nil || er.representations == nil {
+		return nil, nil
+	}
+
+	return er, nil
+}
+
+// render encodes the request with the representations
+func (r *entityRequest) render(representations []json.RawMessage) ([]byte, error) {
+	if representations == nil {
+		representations = []json.RawMessage{}
+	}
+
+	variables := make(map[string]json.RawMessage, len(r.variables))
+	for k, v := range r.variables {
+		variables[k] = v
+	}
+	buf := &bytes.Buffer{}
+	if err := writeJSON(buf, representations); err != nil {
+		return nil, err
+	}
+	variables["representations"] = bytes.Clone(buf.Bytes())
+
+	buf.Reset()
+	if err := writeJSON(buf, variables); err != nil {
+		return nil, err
+	}
+	body := make(map[string]json.RawMessage, len(r.body))
+	for k, v := range r.body {
+		body[k] = v
+	}
+	body["variables"] = bytes.Clone(buf.Bytes())
+
+	buf.Reset()
+	if err := writeJSON(buf, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJSON encodes v without escaping HTML characters, so that the values are sent as the engine rendered them
+func writeJSON(buf *bytes.Buffer, v any) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// Encode terminates the value with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
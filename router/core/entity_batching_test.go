@@ -0,0 +1,272 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const entityBatchingTestQuery = `query($representations: [_Any!]!){_entities(representations: $representations){... on Employee {name}}}`
+
+func newEntityTestRequest(representations ...string) *http.Request {
+	body := fmt.Sprintf(`{"query":%q,"variables":{"representations":[%s]}}`, entityBatchingTestQuery, strings.Join(representations, ","))
+	r := httptest.NewRequest(http.MethodPost, "http://employees/graphql", strings.NewReader(body))
+	r.Header.Set("Accept-Encoding", "gzip")
+	return r
+}
+
+// entityTestSubgraph resolves the name of an employee from its id and records the representations of the requests.
+// The employee with the id 3 fails.
+type entityTestSubgraph struct {
+	mu       sync.Mutex
+	requests [][]json.RawMessage
+}
+
+func (s *entityTestSubgraph) roundTrip(req *http.Request) (*http.Response, error) {
+	var body struct {
+		Variables struct {
+			Representations []json.RawMessage `json:"representations"`
+		} `json:"variables"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, body.Variables.Representations)
+	s.mu.Unlock()
+
+	var (
+		entities []string
+		errs     []string
+	)
+	for i, rep := range body.Variables.Representations {
+		var employee struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(rep, &employee); err != nil {
+			return nil, err
+		}
+		if employee.ID == 3 {
+			entities = append(entities, "null")
+			errs = append(errs, fmt.Sprintf(`{"message":"employee not found","path":["_entities",%d,"name"]}`, i))
+			continue
+		}
+		entities = append(entities, fmt.Sprintf(`{"__typename":"Employee","name":"employee %d"}`, employee.ID))
+	}
+
+	out := `{"data":{"_entities":[` + strings.Join(entities, ",") + `]}`
+	if len(errs) > 0 {
+		out += `,"errors":[` + strings.Join(errs, ",") + `]`
+	}
+	out += "}"
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(out)),
+		Request:    req,
+	}, nil
+}
+
+func (s *entityTestSubgraph) requestSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sizes := make([]int, 0, len(s.requests))
+	for _, r := range s.requests {
+		sizes = append(sizes, len(r))
+	}
+	return sizes
+}
+
+func readEntityTestResponse(t *testing.T, res *http.Response, err error) string {
+	t.Helper()
+
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestEntityBatcher(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates the options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewEntityBatcher(&EntityBatcherOptions{MaxBatchSize: -1})
+		require.Error(t, err)
+		_, err = NewEntityBatcher(&EntityBatcherOptions{BatchWait: -time.Second})
+		require.Error(t, err)
+		_, err = NewEntityBatcher(&EntityBatcherOptions{DedupeKeys: map[string][]string{"Employee": nil}})
+		require.Error(t, err)
+	})
+
+	t.Run("passes other requests through", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := NewEntityBatcher(&EntityBatcherOptions{MaxBatchSize: 1})
+		require.NoError(t, err)
+
+		const body = `{"query":"{ employees { id } }"}`
+		var sent string
+		res, err := b.RoundTrip(httptest.NewRequest(http.MethodPost, "http://employees/graphql", strings.NewReader(body)), func(req *http.Request) (*http.Response, error) {
+			b, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			sent = string(b)
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":{}}`))}, nil
+		})
+		require.Equal(t, `{"data":{}}`, readEntityTestResponse(t, res, err))
+		require.Equal(t, body, sent)
+	})
+
+	t.Run("splits batches over the maximum size", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := NewEntityBatcher(&EntityBatcherOptions{MaxBatchSize: 2})
+		require.NoError(t, err)
+
+		subgraph := &entityTestSubgraph{}
+		req := newEntityTestRequest(
+			`{"__typename":"Employee","id":1}`,
+			`{"__typename":"Employee","id":2}`,
+			`{"__typename":"Employee","id":3}`,
+			`{"__typename":"Employee","id":4}`,
+			`{"__typename":"Employee","id":5}`,
+		)
+		res, err := b.RoundTrip(req, subgraph.roundTrip)
+		require.JSONEq(t, `{
+			"data":{"_entities":[
+				{"__typename":"Employee","name":"employee 1"},
+				{"__typename":"Employee","name":"employee 2"},
+				null,
+				{"__typename":"Employee","name":"employee 4"},
+				{"__typename":"Employee","name":"employee 5"}
+			]},
+			"errors":[{"message":"employee not found","path":["_entities",2,"name"]}]
+		}`, readEntityTestResponse(t, res, err))
+		require.ElementsMatch(t, []int{2, 2, 1}, subgraph.requestSizes())
+	})
+
+	t.Run("removes representations with the same dedupe key", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := NewEntityBatcher(&EntityBatcherOptions{DedupeKeys: map[string][]string{"Employee": {"id"}}})
+		require.NoError(t, err)
+
+		subgraph := &entityTestSubgraph{}
+		req := newEntityTestRequest(
+			`{"__typename":"Employee","id":3,"tag":"a"}`,
+			`{"__typename":"Employee","id":1,"tag":"a"}`,
+			`{"__typename":"Employee","id":3,"tag":"b"}`,
+			`{"__typename":"Employee","id":1,"tag":"b"}`,
+		)
+		res, err := b.RoundTrip(req, subgraph.roundTrip)
+		require.JSONEq(t, `{
+			"data":{"_entities":[
+				null,
+				{"__typename":"Employee","name":"employee 1"},
+				null,
+				{"__typename":"Employee","name":"employee 1"}
+			]},
+			"errors":[
+				{"message":"employee not found","path":["_entities",0,"name"]},
+				{"message":"employee not found","path":["_entities",2,"name"]}
+			]
+		}`, readEntityTestResponse(t, res, err))
+		require.Equal(t, []int{2}, subgraph.requestSizes())
+	})
+
+	t.Run("merges the requests of concurrent operations", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := NewEntityBatcher(&EntityBatcherOptions{BatchWait: 100 * time.Millisecond})
+		require.NoError(t, err)
+
+		subgraph := &entityTestSubgraph{}
+		requests := []*http.Request{
+			newEntityTestRequest(`{"__typename":"Employee","id":1}`, `{"__typename":"Employee","id":2}`),
+			newEntityTestRequest(`{"__typename":"Employee","id":2}`, `{"__typename":"Employee","id":4}`),
+			newEntityTestRequest(`{"__typename":"Employee","id":5}`),
+		}
+		// Requests with other headers aren't merged
+		requests[2].Header.Set("Authorization", "Bearer other")
+
+		responses := make([]string, len(requests))
+		var wg sync.WaitGroup
+		for i, req := range requests {
+			wg.Add(1)
+			go func(i int, req *http.Request) {
+				defer wg.Done()
+				res, err := b.RoundTrip(req, subgraph.roundTrip)
+				responses[i] = readEntityTestResponse(t, res, err)
+			}(i, req)
+		}
+		wg.Wait()
+
+		require.JSONEq(t, `{"data":{"_entities":[{"__typename":"Employee","name":"employee 1"},{"__typename":"Employee","name":"employee 2"}]}}`, responses[0])
+		require.JSONEq(t, `{"data":{"_entities":[{"__typename":"Employee","name":"employee 2"},{"__typename":"Employee","name":"employee 4"}]}}`, responses[1])
+		require.JSONEq(t, `{"data":{"_entities":[{"__typename":"Employee","name":"employee 5"}]}}`, responses[2])
+		require.ElementsMatch(t, []int{3, 1}, subgraph.requestSizes())
+	})
+
+	t.Run("sends full batches before the window ends", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := NewEntityBatcher(&EntityBatcherOptions{BatchWait: time.Minute, MaxBatchSize: 2})
+		require.NoError(t, err)
+
+		subgraph := &entityTestSubgraph{}
+		res, err := b.RoundTrip(newEntityTestRequest(`{"__typename":"Employee","id":1}`, `{"__typename":"Employee","id":2}`), subgraph.roundTrip)
+		require.JSONEq(t, `{"data":{"_entities":[{"__typename":"Employee","name":"employee 1"},{"__typename":"Employee","name":"employee 2"}]}}`, readEntityTestResponse(t, res, err))
+	})
+
+	t.Run("returns failed responses as they are", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := NewEntityBatcher(&EntityBatcherOptions{MaxBatchSize: 1})
+		require.NoError(t, err)
+
+		var calls atomic.Int32
+		res, err := b.RoundTrip(newEntityTestRequest(`{"__typename":"Employee","id":1}`, `{"__typename":"Employee","id":2}`), func(req *http.Request) (*http.Response, error) {
+			calls.Add(1)
+			require.Empty(t, req.Header.Get("Accept-Encoding"))
+			return &http.Response{StatusCode: http.StatusBadGateway, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("bad gateway"))}, nil
+		})
+		require.Equal(t, "bad gateway", readEntityTestResponse(t, res, err))
+		require.Equal(t, http.StatusBadGateway, res.StatusCode)
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("leaves the batch when the request is canceled", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := NewEntityBatcher(&EntityBatcherOptions{BatchWait: time.Minute})
+		require.NoError(t, err)
+
+		req := newEntityTestRequest(`{"__typename":"Employee","id":1}`)
+		ctx, cancel := context.WithTimeout(req.Context(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err = b.RoundTrip(req.WithContext(ctx), (&entityTestSubgraph{}).roundTrip)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for _, batch := range b.pending {
+			require.Error(t, batch.ctx.Err())
+		}
+	})
+}
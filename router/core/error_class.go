@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+// errorClass distinguishes the requests that the client canceled from the requests that timed out in the router
+// or in a subgraph. Canceled requests aren't failures of the router and are kept out of the error metrics.
+type errorClass string
+
+const (
+	errorClassNone errorClass = ""
+	// errorClassClientCanceled is a request that the client canceled or that failed after the client disconnected
+	errorClassClientCanceled errorClass = "client_canceled"
+	// errorClassOperationTimeout is an operation that exceeded the operation timeout of the router
+	errorClassOperationTimeout errorClass = "operation_timeout"
+	// errorClassSubgraphTimeout is a request to a subgraph that timed out
+	errorClassSubgraphTimeout errorClass = "subgraph_timeout"
+	// errorClassRouterTimeout is any other timeout of the router, e.g. of a connection
+	errorClassRouterTimeout errorClass = "router_timeout"
+)
+
+const (
+	// ClientCanceledErrorCode is the code in the extensions of the error of a request that the client canceled
+	ClientCanceledErrorCode = "CLIENT_CANCELED"
+	// SubgraphTimeoutErrorCode is the code in the extensions of the error of a request to a subgraph that timed out
+	SubgraphTimeoutErrorCode = "SUBGRAPH_TIMEOUT"
+	// RouterTimeoutErrorCode is the code in the extensions of the error of other timeouts of the router
+	RouterTimeoutErrorCode = "ROUTER_TIMEOUT"
+)
+
+// classifyError returns the class of the error of a request. The context is the context of the request, or one
+// derived from it, which is canceled when the client disconnects. Errors that are neither caused by the client nor
+// by a timeout have no class.
+func classifyError(ctx context.Context, err error) errorClass {
+	if err == nil {
+		return errorClassNone
+	}
+	if isOperationTimeout(ctx) {
+		return errorClassOperationTimeout
+	}
+
+	clientCanceled := errors.Is(ctx.Err(), context.Canceled)
+
+	switch getErrorType(err) {
+	case errorTypeOperationTimeout:
+		return errorClassOperationTimeout
+	case errorTypeContextCanceled:
+		// The router cancels contexts on its own as well, only the cancellation of the request is the client
+		if clientCanceled {
+			return errorClassClientCanceled
+		}
+		return errorClassNone
+	case errorTypeContextTimeout:
+		var subgraphErr *resolve.SubgraphError
+		if errors.As(err, &subgraphErr) {
+			return errorClassSubgraphTimeout
+		}
+		return errorClassRouterTimeout
+	}
+
+	// Other errors of a request whose client is gone, e.g. of writing the response, are caused by the client too
+	if clientCanceled {
+		return errorClassClientCanceled
+	}
+	return errorClassNone
+}
+
+// code returns the code in the extensions of the error of the class
+func (c errorClass) code() string {
+	switch c {
+	case errorClassClientCanceled:
+		return ClientCanceledErrorCode
+	case errorClassOperationTimeout:
+		return OperationTimeoutErrorCode
+	case errorClassSubgraphTimeout:
+		return SubgraphTimeoutErrorCode
+	case errorClassRouterTimeout:
+		return RouterTimeoutErrorCode
+	}
+	return ""
+}
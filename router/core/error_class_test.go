@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timedOutCtx, cancelTimeout := context.WithTimeoutCause(context.Background(), time.Nanosecond, ErrOperationTimeout)
+	defer cancelTimeout()
+	<-timedOutCtx.Done()
+
+	subgraphErr := resolve.NewSubgraphError("employees", "query", "", 0)
+
+	for _, tc := range []struct {
+		name  string
+		ctx   context.Context
+		err   error
+		class errorClass
+	}{
+		{name: "no error", ctx: canceledCtx, err: nil, class: errorClassNone},
+		{name: "canceled by the client", ctx: canceledCtx, err: errors.Join(context.Canceled, subgraphErr), class: errorClassClientCanceled},
+		{name: "canceled by the router", ctx: context.Background(), err: context.Canceled, class: errorClassNone},
+		{name: "failed after the client disconnected", ctx: canceledCtx, err: errors.New("broken pipe"), class: errorClassClientCanceled},
+		{name: "operation timeout", ctx: context.Background(), err: ErrOperationTimeout, class: errorClassOperationTimeout},
+		{name: "fetch of a timed out operation", ctx: timedOutCtx, err: errors.Join(context.DeadlineExceeded, subgraphErr), class: errorClassOperationTimeout},
+		{name: "subgraph timeout", ctx: context.Background(), err: errors.Join(context.DeadlineExceeded, subgraphErr), class: errorClassSubgraphTimeout},
+		{name: "router timeout", ctx: context.Background(), err: context.DeadlineExceeded, class: errorClassRouterTimeout},
+		{name: "other error", ctx: context.Background(), err: errors.New("subgraph unavailable"), class: errorClassNone},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.class, classifyError(tc.ctx, tc.err))
+		})
+	}
+
+	require.Equal(t, ClientCanceledErrorCode, errorClassClientCanceled.code())
+	require.Equal(t, SubgraphTimeoutErrorCode, errorClassSubgraphTimeout.code())
+	require.Empty(t, errorClassNone.code())
+}
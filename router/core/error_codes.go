@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+
+	"github.com/wundergraph/cosmo/router/internal/cdn"
+)
+
+// ErrorCodeCategory groups the error codes by their cause, e.g. to alert on all subgraph errors
+type ErrorCodeCategory string
+
+const (
+	ErrorCodeCategoryAuth      ErrorCodeCategory = "auth"
+	ErrorCodeCategoryRateLimit ErrorCodeCategory = "rate_limit"
+	ErrorCodeCategoryTimeout   ErrorCodeCategory = "timeout"
+	ErrorCodeCategoryPlan      ErrorCodeCategory = "plan"
+	ErrorCodeCategorySubgraph  ErrorCodeCategory = "subgraph"
+	ErrorCodeCategoryLimit     ErrorCodeCategory = "limit"
+	ErrorCodeCategoryClient    ErrorCodeCategory = "client"
+	ErrorCodeCategoryRouter    ErrorCodeCategory = "router"
+)
+
+const (
+	// UnauthorizedErrorCode is the code of a request that isn't authenticated or not authorized
+	UnauthorizedErrorCode = "UNAUTHORIZED"
+	// IntrospectionBlockedErrorCode is the code of an introspection query that is blocked for the client
+	IntrospectionBlockedErrorCode = "INTROSPECTION_BLOCKED"
+	// BotDetectedErrorCode is the code of a request that was classified as automated traffic
+	BotDetectedErrorCode = "BOT_DETECTED"
+	// RateLimitExceededErrorCode is the code of a request that exceeded the rate limit
+	RateLimitExceededErrorCode = "RATE_LIMIT_EXCEEDED"
+	// UsageQuotaExceededErrorCode is the code of a request of a client that used up its usage quota
+	UsageQuotaExceededErrorCode = "USAGE_QUOTA_EXCEEDED"
+	// SubscriptionBackpressureErrorCode is the code of a subscription whose client can't keep up with the events
+	SubscriptionBackpressureErrorCode = "SUBSCRIPTION_BACKPRESSURE"
+	// InvalidRequestErrorCode is the code of a request that isn't a valid GraphQL request, e.g. with a malformed body
+	InvalidRequestErrorCode = "INVALID_REQUEST"
+	// InvalidOperationErrorCode is the code of an operation that can't be parsed, normalized or validated
+	InvalidOperationErrorCode = "INVALID_OPERATION"
+	// OperationBlockedErrorCode is the code of an operation that is blocked by its type or by an operation rule
+	OperationBlockedErrorCode = "OPERATION_BLOCKED"
+	// PersistedOperationNotFoundErrorCode is the code of a persisted operation that isn't known to the router
+	PersistedOperationNotFoundErrorCode = "PERSISTED_OPERATION_NOT_FOUND"
+	// PersistedOperationBlockedErrorCode is the code of a persisted operation that is blocked by the kill switch
+	PersistedOperationBlockedErrorCode = "PERSISTED_OPERATION_BLOCKED"
+	// SubgraphRequestFailedErrorCode is the code of an operation whose subgraph requests failed
+	SubgraphRequestFailedErrorCode = "SUBGRAPH_REQUEST_FAILED"
+	// MaintenanceModeErrorCode is the code of an operation that is rejected due to the maintenance mode
+	MaintenanceModeErrorCode = "MAINTENANCE_MODE"
+	// RouterOverloadedErrorCode is the code of a request that is rejected because the router is overloaded or
+	// updating its config. It can be retried.
+	RouterOverloadedErrorCode = "ROUTER_OVERLOADED"
+	// InternalServerErrorCode is the code of the errors of the router that have no other code
+	InternalServerErrorCode = "INTERNAL_SERVER_ERROR"
+)
+
+// ErrorCode is a stable code of the errors that the router produces. The code is set in the extensions of the errors
+// the router writes itself and in the problem details, and as the field error_code of the access logs and the
+// attribute wg.request.error.code of the request metrics and the router span. The errors of the validation keep the
+// format of the GraphQL spec, their requests are labeled with INVALID_OPERATION.
+type ErrorCode struct {
+	Code        string            `json:"code"`
+	Category    ErrorCodeCategory `json:"category"`
+	Description string            `json:"description"`
+}
+
+var errorCodes = []ErrorCode{
+	{UnauthorizedErrorCode, ErrorCodeCategoryAuth, "The request isn't authenticated or not authorized."},
+	{IntrospectionBlockedErrorCode, ErrorCodeCategoryAuth, "The introspection query is blocked for unauthenticated or unknown clients."},
+	{BotDetectedErrorCode, ErrorCodeCategoryAuth, "The request was classified as automated traffic."},
+	{RateLimitExceededErrorCode, ErrorCodeCategoryRateLimit, "The request exceeded the rate limit."},
+	{UsageQuotaExceededErrorCode, ErrorCodeCategoryRateLimit, "The client used up its usage quota."},
+	{SubscriptionLimitErrorCode, ErrorCodeCategoryRateLimit, "The subscription or connection exceeded the limit of active subscriptions."},
+	{OperationTimeoutErrorCode, ErrorCodeCategoryTimeout, "The operation exceeded its timeout."},
+	{RouterTimeoutErrorCode, ErrorCodeCategoryTimeout, "The request timed out in the router, e.g. while connecting to a subgraph."},
+	{SubgraphTimeoutErrorCode, ErrorCodeCategoryTimeout, "A request to a subgraph timed out."},
+	{InvalidOperationErrorCode, ErrorCodeCategoryPlan, "The operation can't be parsed, normalized or validated against the schema."},
+	{FieldBlockedErrorCode, ErrorCodeCategoryPlan, "The operation requests a field that is blocked."},
+	{OperationBlockedErrorCode, ErrorCodeCategoryPlan, "The operation is blocked by its type or by an operation rule."},
+	{PersistedOperationNotFoundErrorCode, ErrorCodeCategoryPlan, "The persisted operation isn't known to the router."},
+	{PersistedOperationBlockedErrorCode, ErrorCodeCategoryPlan, "The persisted operation is blocked by the kill switch."},
+	{SubgraphRequestFailedErrorCode, ErrorCodeCategorySubgraph, "A request to a subgraph failed."},
+	{SubgraphResponseTooLargeErrorCode, ErrorCodeCategorySubgraph, "The response of a subgraph exceeds the maximum size."},
+	{SubgraphConcurrencyLimitExceededErrorCode, ErrorCodeCategorySubgraph, "The subgraph has reached its limit of concurrent requests."},
+	{ResponseTooLargeErrorCode, ErrorCodeCategoryLimit, "The response exceeds the maximum size."},
+	{ResponseTruncatedWarningCode, ErrorCodeCategoryLimit, "The response was truncated to the maximum size. It is a warning, the response has data."},
+	{RequestMemoryLimitExceededErrorCode, ErrorCodeCategoryLimit, "The request exceeds the memory limit."},
+	{SubscriptionBackpressureErrorCode, ErrorCodeCategoryLimit, "The client of the subscription can't keep up with its events."},
+	{InvalidRequestErrorCode, ErrorCodeCategoryClient, "The request isn't a valid GraphQL request."},
+	{ClientCanceledErrorCode, ErrorCodeCategoryClient, "The client canceled the request."},
+	{MaintenanceModeErrorCode, ErrorCodeCategoryRouter, "The operation is rejected due to the maintenance mode."},
+	{RouterOverloadedErrorCode, ErrorCodeCategoryRouter, "The router is overloaded or updating its config. The request can be retried."},
+	{InternalServerErrorCode, ErrorCodeCategoryRouter, "An unexpected error of the router."},
+}
+
+// ErrorCodes returns all error codes of the router ordered by their category and code
+func ErrorCodes() []ErrorCode {
+	codes := make([]ErrorCode, len(errorCodes))
+	copy(codes, errorCodes)
+	sort.Slice(codes, func(i, j int) bool {
+		if codes[i].Category != codes[j].Category {
+			return codes[i].Category < codes[j].Category
+		}
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// LookupErrorCode returns the registered error code
+func LookupErrorCode(code string) (ErrorCode, bool) {
+	for _, c := range errorCodes {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return ErrorCode{}, false
+}
+
+// errorCodeOf returns the code of the error of a request. The context is the context of the request, like for
+// classifyError. Errors without a more specific code are internal server errors.
+func errorCodeOf(ctx context.Context, err error) string {
+	if err == nil {
+		return ""
+	}
+	if code := knownErrorCode(ctx, err); code != "" {
+		return code
+	}
+	return InternalServerErrorCode
+}
+
+// knownErrorCode returns the code of the error, empty if the error has no specific code
+func knownErrorCode(ctx context.Context, err error) string {
+	if code := classifyError(ctx, err).code(); code != "" {
+		return code
+	}
+
+	switch getErrorType(err) {
+	case errorTypeRateLimit:
+		return RateLimitExceededErrorCode
+	case errorTypeUnauthorized:
+		return UnauthorizedErrorCode
+	case errorTypeResponseTooLarge:
+		return ResponseTooLargeErrorCode
+	case errorTypeRequestMemoryLimit:
+		return RequestMemoryLimitExceededErrorCode
+	}
+
+	var fieldBlockedErr *FieldBlockedError
+	var poNotFoundErr cdn.PersistentOperationNotFoundError
+	var reportErr ReportError
+	var inputErr InputError
+	var subgraphErr *resolve.SubgraphError
+	switch {
+	case errors.Is(err, ErrIntrospectionUnauthenticated), errors.Is(err, ErrIntrospectionUnknownClient):
+		return IntrospectionBlockedErrorCode
+	case errors.Is(err, ErrRequestSignatureMissing), errors.Is(err, ErrRequestSignatureInvalid),
+		errors.Is(err, ErrRequestSignatureExpired), errors.Is(err, ErrRequestSignatureReused):
+		return UnauthorizedErrorCode
+	case errors.Is(err, ErrBotDetected):
+		return BotDetectedErrorCode
+	case errors.Is(err, ErrBotRateLimitReached):
+		return RateLimitExceededErrorCode
+	case errors.Is(err, ErrUsageQuotaExceeded):
+		return UsageQuotaExceededErrorCode
+	case errors.Is(err, ErrSubscriptionLimit), errors.Is(err, ErrSubscriptionConnectionLimit):
+		return SubscriptionLimitErrorCode
+	case errors.Is(err, ErrSubscriptionBackpressure):
+		return SubscriptionBackpressureErrorCode
+	case errors.Is(err, ErrMutationOperationBlocked), errors.Is(err, ErrSubscriptionOperationBlocked),
+		errors.Is(err, ErrNonPersistedOperationBlocked), errors.Is(err, ErrOperationBlockedByRule):
+		return OperationBlockedErrorCode
+	case errors.Is(err, ErrMemoryLimitExceeded), errors.Is(err, ErrConfigSwapQueueFull):
+		return RouterOverloadedErrorCode
+	case errors.Is(err, ErrRequestHeaderTooLarge):
+		return InvalidRequestErrorCode
+	case errors.Is(err, ErrMaintenanceMode):
+		return MaintenanceModeErrorCode
+	case errors.Is(err, ErrPersistedOperationKilled):
+		return PersistedOperationBlockedErrorCode
+	case errors.As(err, &fieldBlockedErr):
+		return FieldBlockedErrorCode
+	case errors.As(err, &poNotFoundErr):
+		return PersistedOperationNotFoundErrorCode
+	case errors.As(err, &reportErr):
+		// Only internal errors, e.g. of the planner, are errors of the router
+		if report := reportErr.Report(); report != nil && len(report.ExternalErrors) > 0 {
+			return InvalidOperationErrorCode
+		}
+		return InternalServerErrorCode
+	case errors.As(err, &inputErr):
+		return InvalidRequestErrorCode
+	case errors.As(err, &subgraphErr):
+		return SubgraphRequestFailedErrorCode
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+)
+
+func TestErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	codes := ErrorCodes()
+	require.Len(t, codes, len(errorCodes))
+
+	seen := make(map[string]struct{}, len(codes))
+	for i, code := range codes {
+		require.NotEmpty(t, code.Category, code.Code)
+		require.NotEmpty(t, code.Description, code.Code)
+		_, duplicate := seen[code.Code]
+		require.False(t, duplicate, code.Code)
+		seen[code.Code] = struct{}{}
+
+		if i > 0 && codes[i-1].Category == code.Category {
+			require.Less(t, codes[i-1].Code, code.Code)
+		}
+	}
+
+	code, ok := LookupErrorCode(SubgraphTimeoutErrorCode)
+	require.True(t, ok)
+	require.Equal(t, ErrorCodeCategoryTimeout, code.Category)
+	_, ok = LookupErrorCode("UNKNOWN")
+	require.False(t, ok)
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	externalReport := &operationreport.Report{}
+	externalReport.AddExternalError(operationreport.ExternalError{Message: "field 'foo' does not exist"})
+	internalReport := &operationreport.Report{}
+	internalReport.AddInternalError(errors.New("planning failed"))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		code string
+	}{
+		{name: "no error", ctx: ctx, err: nil, code: ""},
+		{name: "rate limit", ctx: ctx, err: fmt.Errorf("subgraph: %w", ErrRateLimitExceeded), code: RateLimitExceededErrorCode},
+		{name: "unauthorized", ctx: ctx, err: ErrUnauthorized, code: UnauthorizedErrorCode},
+		{name: "request signature", ctx: ctx, err: ErrRequestSignatureExpired, code: UnauthorizedErrorCode},
+		{name: "operation timeout", ctx: ctx, err: ErrOperationTimeout, code: OperationTimeoutErrorCode},
+		{name: "client canceled", ctx: canceledCtx, err: context.Canceled, code: ClientCanceledErrorCode},
+		{name: "invalid operation", ctx: ctx, err: &reportError{report: externalReport}, code: InvalidOperationErrorCode},
+		{name: "planning", ctx: ctx, err: &reportError{report: internalReport}, code: InternalServerErrorCode},
+		{name: "invalid request", ctx: ctx, err: &inputError{message: "invalid body", statusCode: http.StatusBadRequest}, code: InvalidRequestErrorCode},
+		{name: "subgraph", ctx: ctx, err: resolve.NewSubgraphError("employees", "query", "failed", http.StatusBadGateway), code: SubgraphRequestFailedErrorCode},
+		{name: "maintenance", ctx: ctx, err: ErrMaintenanceMode, code: MaintenanceModeErrorCode},
+		{name: "unknown", ctx: ctx, err: errors.New("boom"), code: InternalServerErrorCode},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			code := errorCodeOf(tt.ctx, tt.err)
+			require.Equal(t, tt.code, code)
+			if code != "" {
+				_, ok := LookupErrorCode(code)
+				require.True(t, ok)
+			}
+		})
+	}
+
+	// Only errors with a specific code have a known code
+	require.Empty(t, knownErrorCode(ctx, errors.New("boom")))
+}
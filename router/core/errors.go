@@ -30,6 +30,9 @@ const (
 	errorTypeUpgradeFailed
 	errorTypeEDFS
 	errorTypeInvalidWsSubprotocol
+	errorTypeOperationTimeout
+	errorTypeResponseTooLarge
+	errorTypeRequestMemoryLimit
 )
 
 type (
@@ -44,6 +47,7 @@ type (
 		Authorization json.RawMessage `json:"authorization,omitempty"`
 		Trace         json.RawMessage `json:"trace,omitempty"`
 		StatusCode    int             `json:"statusCode,omitempty"`
+		Code          string          `json:"code,omitempty"`
 	}
 )
 
@@ -54,6 +58,15 @@ func getErrorType(err error) errorType {
 	if errors.Is(err, ErrUnauthorized) {
 		return errorTypeUnauthorized
 	}
+	if errors.Is(err, ErrOperationTimeout) {
+		return errorTypeOperationTimeout
+	}
+	if errors.Is(err, ErrResponseTooLarge) {
+		return errorTypeResponseTooLarge
+	}
+	if errors.Is(err, ErrRequestMemoryLimitExceeded) {
+		return errorTypeRequestMemoryLimit
+	}
 	if errors.Is(err, context.Canceled) {
 		return errorTypeContextCanceled
 	}
@@ -113,8 +126,16 @@ func propagateSubgraphErrors(ctx *resolve.Context, logger *zap.Logger) {
 	err := ctx.SubgraphErrors()
 
 	if err != nil {
-		logger.Error("subgraph errors", zap.Error(err))
+		// Failed subgraph requests of a client that is gone are expected and would pollute the error logs
+		if classifyError(ctx.Context(), err) == errorClassClientCanceled {
+			logger.Debug("subgraph errors of a canceled request", zap.Error(err))
+		} else {
+			logger.Error("subgraph errors", zap.Error(err))
+		}
 		trackResponseError(ctx.Context(), err)
+		if reqCtx := getRequestContext(ctx.Context()); reqCtx != nil {
+			reqCtx.subgraphErrors = err
+		}
 	}
 }
 
@@ -148,7 +169,13 @@ func writeOperationError(r *http.Request, w http.ResponseWriter, requestLogger *
 	var reportErr ReportError
 	var inputErr InputError
 	var poNotFoundErr cdn.PersistentOperationNotFoundError
+	var fieldBlockedErr *FieldBlockedError
 	switch {
+	case errors.As(err, &fieldBlockedErr):
+		writeFieldBlockedError(w, fieldBlockedErr, requestLogger)
+	case errors.As(err, &inputErr) && inputErr.StatusCode() == http.StatusRequestEntityTooLarge:
+		// The body wasn't read, so the request isn't a GraphQL request yet
+		writeHTTPError(r, w, inputErr.StatusCode(), err, requestLogger)
 	case errors.As(err, &inputErr):
 		requestLogger.Debug(inputErr.Error())
 		writeRequestErrors(r, w, inputErr.StatusCode(), graphqlerrors.RequestErrorsFromError(err), requestLogger)
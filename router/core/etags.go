@@ -0,0 +1,79 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/buger/jsonparser"
+	"github.com/cespare/xxhash/v2"
+)
+
+// ETags adds content based ETags to the responses of queries. Polling clients send the ETag of their last
+// response in the If-None-Match header and receive 304 Not Modified without a body while it is unchanged.
+type ETags struct {
+	weak bool
+}
+
+type ETagsOptions struct {
+	// Weak marks the ETags as weak validators
+	Weak bool
+}
+
+func NewETags(opts *ETagsOptions) *ETags {
+	return &ETags{weak: opts.Weak}
+}
+
+// apply sets the ETag header of a cacheable response and reports whether the If-None-Match header of the request
+// matches it. The caller responds with 304 Not Modified instead of the response in that case.
+func (e *ETags) apply(w http.ResponseWriter, r *http.Request, operationCtx *operationContext, response []byte) (notModified bool) {
+	if !e.cacheable(operationCtx, response) {
+		return false
+	}
+
+	etag := e.etag(response)
+	w.Header().Set("ETag", etag)
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		operationCtx.cacheDecisions.response = cacheDecisionMiss
+		return false
+	}
+	// The client revalidated its cached response
+	operationCtx.cacheDecisions.response = cacheDecisionHit
+	// A 304 response has no content
+	w.Header().Del("Content-Type")
+	return true
+}
+
+// cacheable reports whether the response is the result of a query without errors. Mutations aren't idempotent and
+// responses with errors shouldn't be revalidated as unchanged.
+func (e *ETags) cacheable(operationCtx *operationContext, response []byte) bool {
+	if operationCtx.Type() != "query" {
+		return false
+	}
+	_, _, _, err := jsonparser.Get(response, "errors")
+	return err == jsonparser.KeyPathNotFoundError
+}
+
+func (e *ETags) etag(response []byte) string {
+	tag := `"` + strconv.FormatUint(xxhash.Sum64(response), 16) + `"`
+	if e.weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// etagMatches compares the list of entity tags of an If-None-Match header with the weak comparison of RFC 9110
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagMatches(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		ifNoneMatch string
+		etag        string
+		matches     bool
+	}{
+		"empty":              {ifNoneMatch: "", etag: `"a"`, matches: false},
+		"equal":              {ifNoneMatch: `"a"`, etag: `"a"`, matches: true},
+		"different":          {ifNoneMatch: `"b"`, etag: `"a"`, matches: false},
+		"list":               {ifNoneMatch: `"b", "a"`, etag: `"a"`, matches: true},
+		"wildcard":           {ifNoneMatch: "*", etag: `"a"`, matches: true},
+		"weak request tag":   {ifNoneMatch: `W/"a"`, etag: `"a"`, matches: true},
+		"weak response tag":  {ifNoneMatch: `"a"`, etag: `W/"a"`, matches: true},
+		"unquoted candidate": {ifNoneMatch: `a`, etag: `"a"`, matches: false},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.matches, etagMatches(tc.ifNoneMatch, tc.etag))
+		})
+	}
+}
+
+func TestETagsApply(t *testing.T) {
+	t.Parallel()
+
+	response := []byte(`{"data":{"employee":{"id":1}}}`)
+	// The operation context records the cache decision, so the parallel tests don't share it
+	newQuery := func() *operationContext { return &operationContext{opType: "query"} }
+
+	t.Run("sets the ETag of query responses", func(t *testing.T) {
+		t.Parallel()
+
+		etags := NewETags(&ETagsOptions{})
+		rec := httptest.NewRecorder()
+		notModified := etags.apply(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil), newQuery(), response)
+		require.False(t, notModified)
+
+		etag := rec.Header().Get("ETag")
+		require.Regexp(t, `^"[0-9a-f]+"$`, etag)
+
+		// The ETag only depends on the content of the response
+		rec = httptest.NewRecorder()
+		etags.apply(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil), newQuery(), response)
+		require.Equal(t, etag, rec.Header().Get("ETag"))
+
+		rec = httptest.NewRecorder()
+		etags.apply(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil), newQuery(), []byte(`{"data":{"employee":{"id":2}}}`))
+		require.NotEqual(t, etag, rec.Header().Get("ETag"))
+	})
+
+	t.Run("reports matching conditional requests", func(t *testing.T) {
+		t.Parallel()
+
+		etags := NewETags(&ETagsOptions{Weak: true})
+		rec := httptest.NewRecorder()
+		etags.apply(rec, httptest.NewRequest(http.MethodGet, "/graphql", nil), newQuery(), response)
+		etag := rec.Header().Get("ETag")
+		require.Regexp(t, `^W/"[0-9a-f]+"$`, etag)
+
+		req := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+		req.Header.Set("If-None-Match", etag)
+		rec = httptest.NewRecorder()
+		rec.Header().Set("Content-Type", "application/json")
+		revalidated := newQuery()
+		require.True(t, etags.apply(rec, req, revalidated, response))
+		require.Equal(t, etag, rec.Header().Get("ETag"))
+		require.Empty(t, rec.Header().Get("Content-Type"))
+		require.Equal(t, cacheDecisionHit, revalidated.cacheDecisions.response)
+
+		req.Header.Set("If-None-Match", `"other"`)
+		changed := newQuery()
+		require.False(t, etags.apply(httptest.NewRecorder(), req, changed, response))
+		require.Equal(t, cacheDecisionMiss, changed.cacheDecisions.response)
+	})
+
+	t.Run("skips responses that aren't cacheable", func(t *testing.T) {
+		t.Parallel()
+
+		etags := NewETags(&ETagsOptions{})
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		req.Header.Set("If-None-Match", "*")
+
+		rec := httptest.NewRecorder()
+		require.False(t, etags.apply(rec, req, &operationContext{opType: "mutation"}, response))
+		require.Empty(t, rec.Header().Get("ETag"))
+
+		rec = httptest.NewRecorder()
+		require.False(t, etags.apply(rec, req, newQuery(), []byte(`{"errors":[{"message":"failed"}],"data":null}`)))
+		require.Empty(t, rec.Header().Get("ETag"))
+	})
+}
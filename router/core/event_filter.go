@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/buger/jsonparser"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub"
+)
+
+// eventFilterCostLimit bounds the cost of the evaluation of a filter, so that an expression can't stall the events
+const eventFilterCostLimit = 10000
+
+var _ pubsub.EventFilter = (*EventFilter)(nil)
+
+// EventFilter drops the events of the EDFS subscriptions that don't match the configured filters, before they fan
+// out to the clients. A filter compares a field of the JSON payload or evaluates a CEL expression on it. An event
+// must match all filters of its provider and subject. Events that can't be evaluated, e.g. without a JSON payload,
+// are dropped.
+type EventFilter struct {
+	filters []eventFilter
+}
+
+type eventFilter struct {
+	providerID string
+	subjects   map[string]struct{}
+	field      []string
+	equals     string
+	program    cel.Program
+}
+
+func NewEventFilter(cfg []config.EventFilterConfiguration) (*EventFilter, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("event", cel.DynType),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &EventFilter{filters: make([]eventFilter, 0, len(cfg))}
+	for i, filterCfg := range cfg {
+		if filterCfg.Field == "" && filterCfg.Expression == "" {
+			return nil, fmt.Errorf("the event filter %d must have a field or an expression", i)
+		}
+
+		filter := eventFilter{providerID: filterCfg.ProviderID, equals: filterCfg.Equals}
+		if len(filterCfg.Subjects) > 0 {
+			filter.subjects = make(map[string]struct{}, len(filterCfg.Subjects))
+			for _, subject := range filterCfg.Subjects {
+				filter.subjects[subject] = struct{}{}
+			}
+		}
+		if filterCfg.Field != "" {
+			filter.field = strings.Split(filterCfg.Field, ".")
+		}
+		if filterCfg.Expression != "" {
+			ast, issues := env.Compile(filterCfg.Expression)
+			if issues != nil && issues.Err() != nil {
+				return nil, fmt.Errorf("failed to compile the expression of the event filter %d: %w", i, issues.Err())
+			}
+			if kind := ast.OutputType().Kind(); kind != types.BoolKind && kind != types.DynKind {
+				return nil, fmt.Errorf("the expression of the event filter %d must evaluate to a bool, got %s", i, ast.OutputType())
+			}
+			filter.program, err = env.Program(ast, cel.CostLimit(eventFilterCostLimit))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the program of the event filter %d: %w", i, err)
+			}
+		}
+		f.filters = append(f.filters, filter)
+	}
+
+	return f, nil
+}
+
+func (f *EventFilter) Allow(providerID, subject string, data []byte) bool {
+	// The payload is only decoded for the expressions, and only once
+	var event any
+	var decodeErr error
+	decoded := false
+
+	for i := range f.filters {
+		filter := &f.filters[i]
+		if !filter.appliesTo(providerID, subject) {
+			continue
+		}
+		if filter.field != nil && !filter.fieldEquals(data) {
+			return false
+		}
+		if filter.program != nil {
+			if !decoded {
+				decodeErr = json.Unmarshal(data, &event)
+				decoded = true
+			}
+			if decodeErr != nil || !filter.evaluate(event) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (f *eventFilter) appliesTo(providerID, subject string) bool {
+	if f.providerID != "" && f.providerID != providerID {
+		return false
+	}
+	if f.subjects != nil {
+		if _, ok := f.subjects[subject]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldEquals compares strings without their quotes and the other values with their JSON representation
+func (f *eventFilter) fieldEquals(data []byte) bool {
+	value, dataType, _, err := jsonparser.Get(data, f.field...)
+	if err != nil {
+		return false
+	}
+	if dataType == jsonparser.String {
+		str, err := jsonparser.ParseString(value)
+		return err == nil && str == f.equals
+	}
+	return string(value) == f.equals
+}
+
+func (f *eventFilter) evaluate(event any) bool {
+	out, _, err := f.program.Eval(map[string]any{"event": event})
+	if err != nil {
+		return false
+	}
+	allowed, ok := out.Value().(bool)
+	return ok && allowed
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestEventFilter(t *testing.T) {
+	t.Parallel()
+
+	f, err := NewEventFilter([]config.EventFilterConfiguration{
+		{ProviderID: "my-kafka", Subjects: []string{"orders"}, Field: "order.status", Equals: "shipped"},
+		{ProviderID: "my-kafka", Subjects: []string{"orders"}, Field: "order.express", Equals: "true"},
+		{ProviderID: "my-nats", Expression: "event.priority > 2"},
+	})
+	require.NoError(t, err)
+
+	require.True(t, f.Allow("my-kafka", "orders", []byte(`{"order":{"status":"shipped","express":true}}`)))
+	require.False(t, f.Allow("my-kafka", "orders", []byte(`{"order":{"status":"pending","express":true}}`)))
+	require.False(t, f.Allow("my-kafka", "orders", []byte(`{"order":{"status":"shipped"}}`)))
+	// The filters apply to their provider and subjects only
+	require.True(t, f.Allow("my-kafka", "invoices", []byte(`{"order":{"status":"pending"}}`)))
+
+	require.True(t, f.Allow("my-nats", "alerts", []byte(`{"priority":3}`)))
+	require.False(t, f.Allow("my-nats", "alerts", []byte(`{"priority":1}`)))
+	// Events that can't be evaluated are dropped
+	require.False(t, f.Allow("my-nats", "alerts", []byte(`not json`)))
+	require.False(t, f.Allow("my-nats", "alerts", []byte(`{"level":"high"}`)))
+}
+
+func TestEventFilterInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewEventFilter([]config.EventFilterConfiguration{{ProviderID: "my-nats"}})
+	require.EqualError(t, err, "the event filter 0 must have a field or an expression")
+
+	_, err = NewEventFilter([]config.EventFilterConfiguration{{Expression: "event.priority +"}})
+	require.ErrorContains(t, err, "failed to compile the expression of the event filter 0")
+
+	_, err = NewEventFilter([]config.EventFilterConfiguration{{Expression: "'high'"}})
+	require.EqualError(t, err, "the expression of the event filter 0 must evaluate to a bool, got string")
+}
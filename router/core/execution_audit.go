@@ -0,0 +1,365 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+const (
+	ExecutionAuditExporterNDJSON = "ndjson"
+	ExecutionAuditExporterOTLP   = "otlp"
+)
+
+// ExecutionAuditMessage is the message of the execution audit records
+const ExecutionAuditMessage = "Operation executed"
+
+// The policies of the router that are listed in the execution audit records when they were applied to the request
+const (
+	ExecutionAuditPolicyMaintenanceMode     = "maintenance_mode"
+	ExecutionAuditPolicyKillSwitch          = "persisted_operation_kill_switch"
+	ExecutionAuditPolicyOperationBlocker    = "operation_blocker"
+	ExecutionAuditPolicyAuthentication      = "authentication"
+	ExecutionAuditPolicyIntrospectionGuard  = "introspection_guard"
+	ExecutionAuditPolicyTenantOverride      = "tenant_override"
+	ExecutionAuditPolicyAuthorization       = "authorization"
+	ExecutionAuditPolicyRateLimit           = "rate_limit"
+	ExecutionAuditPolicyUsageQuota          = "usage_quota"
+	ExecutionAuditPolicyFieldMasking        = "field_masking"
+	ExecutionAuditPolicyFetchConcurrency    = "fetch_concurrency"
+	ExecutionAuditPolicyRequestMemoryBudget = "request_memory_limit"
+)
+
+type ExecutionAuditOptions struct {
+	// Exporter is ExecutionAuditExporterNDJSON or ExecutionAuditExporterOTLP
+	Exporter string
+	// Path is the file of the NDJSON records. It is created if it doesn't exist.
+	Path string
+	// OTLP are the options of the OTLP exporter
+	OTLP *logging.OTLPOptions
+	// Claims are the claims of the authenticated clients that are recorded
+	Claims []string
+}
+
+// ExecutionAudit exports a record of the execution of every operation, with a summary of the plan, the subgraphs
+// that were requested, the outcome of the authentication and the policies that were applied. The records are
+// appended to an NDJSON file or exported as OTLP log records, regardless of the log level of the router.
+type ExecutionAudit struct {
+	logger *zap.Logger
+	file   *os.File
+	otlp   *logging.OTLPExporter
+	claims []string
+}
+
+func NewExecutionAudit(opts *ExecutionAuditOptions) (*ExecutionAudit, error) {
+	a := &ExecutionAudit{claims: opts.Claims}
+
+	switch opts.Exporter {
+	case ExecutionAuditExporterNDJSON, "":
+		if opts.Path == "" {
+			return nil, errors.New("the path of the execution audit must not be empty")
+		}
+		file, err := os.OpenFile(opts.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("could not open the execution audit: %w", err)
+		}
+		a.file = file
+		a.logger = zap.New(zapcore.NewCore(logging.ZapJsonEncoder(), zapcore.Lock(file), zapcore.InfoLevel))
+	case ExecutionAuditExporterOTLP:
+		if opts.OTLP == nil {
+			return nil, errors.New("the otlp exporter of the execution audit requires an endpoint")
+		}
+		exporter, err := logging.NewOTLPExporter(opts.OTLP)
+		if err != nil {
+			return nil, err
+		}
+		a.otlp = exporter
+		a.logger = zap.New(zapcore.NewNopCore(), logging.WithOTLP(exporter, zapcore.InfoLevel))
+	default:
+		return nil, fmt.Errorf("unknown exporter of the execution audit '%s'", opts.Exporter)
+	}
+
+	a.logger = a.logger.Named("execution_audit")
+	return a, nil
+}
+
+// newExecutionAuditFromConfig creates the execution audit of the router config. The OTLP records have the resource
+// of the traces.
+func newExecutionAuditFromConfig(cfg *config.ExecutionAuditConfiguration, res *resource.Resource, logger *zap.Logger) (*ExecutionAudit, error) {
+	opts := &ExecutionAuditOptions{
+		Exporter: cfg.Exporter,
+		Path:     cfg.Path,
+		Claims:   cfg.Claims,
+	}
+	if cfg.Exporter == ExecutionAuditExporterOTLP && cfg.OTLP.Endpoint != "" {
+		opts.OTLP = &logging.OTLPOptions{
+			Exporter: cfg.OTLP.Exporter,
+			Endpoint: cfg.OTLP.Endpoint,
+			HTTPPath: cfg.OTLP.HTTPPath,
+			Headers:  cfg.OTLP.Headers,
+			Resource: res,
+			OnError: func(err error) {
+				logger.Warn("Failed to export the execution audit records", zap.Error(err))
+			},
+		}
+	}
+	return NewExecutionAudit(opts)
+}
+
+// start returns the record of a request. All methods of the record can be called on nil, when the execution audit
+// is disabled.
+func (a *ExecutionAudit) start() *executionAuditRecord {
+	if a == nil {
+		return nil
+	}
+	return &executionAuditRecord{start: time.Now()}
+}
+
+// write exports the record after the request was handled. The context is the context of the request.
+func (a *ExecutionAudit) write(ctx context.Context, record *executionAuditRecord, clientInfo *ClientInfo, statusCode int, err error) {
+	if a == nil || record == nil {
+		return
+	}
+
+	fields := make([]zap.Field, 0, 16)
+	fields = append(fields,
+		zap.String("request_id", middleware.GetReqID(ctx)),
+		zap.String("client_name", clientInfo.Name),
+		zap.String("client_version", clientInfo.Version),
+	)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		fields = append(fields, zap.String("trace_id", spanContext.TraceID().String()))
+	}
+	if record.operationName != "" || record.operationType != "" {
+		fields = append(fields,
+			zap.String("operation_name", record.operationName),
+			zap.String("operation_type", record.operationType),
+		)
+	}
+	if record.persistedID != "" {
+		fields = append(fields, zap.String("persisted_operation_id", record.persistedID))
+	}
+	if record.planned {
+		fields = append(fields, zap.Object("plan", executionAuditPlan{
+			operationHash: record.operationHash,
+			cacheHit:      record.planCacheHit,
+		}))
+	}
+
+	record.mu.Lock()
+	fields = append(fields,
+		zap.Array("subgraphs", executionAuditSubgraphs(record.subgraphs)),
+		zap.Strings("policies", record.policies),
+	)
+	if len(record.deniedFields) > 0 {
+		fields = append(fields, zap.Strings("denied_fields", record.deniedFields))
+	}
+	record.mu.Unlock()
+
+	fields = append(fields, zap.Object("authentication", a.authenticationOf(ctx, record.authEvaluated)))
+	fields = append(fields, zap.Int("status_code", statusCode))
+	if code := errorCodeOf(ctx, err); code != "" {
+		fields = append(fields, zap.String("error_code", code))
+	}
+	fields = append(fields, zap.Duration("duration", time.Since(record.start)))
+
+	a.logger.Info(ExecutionAuditMessage, fields...)
+}
+
+// authenticationOf returns the outcome of the authentication with the configured claims
+func (a *ExecutionAudit) authenticationOf(ctx context.Context, evaluated bool) executionAuditAuthentication {
+	result := executionAuditAuthentication{evaluated: evaluated}
+	auth := authentication.FromContext(ctx)
+	if auth == nil {
+		return result
+	}
+	result.authenticated = true
+	result.authenticator = auth.Authenticator()
+	claims := auth.Claims()
+	for _, name := range a.claims {
+		if value, ok := claims[name]; ok {
+			if result.claims == nil {
+				result.claims = make(map[string]any, len(a.claims))
+			}
+			result.claims[name] = value
+		}
+	}
+	return result
+}
+
+// Shutdown writes the remaining records and closes the file or the exporter
+func (a *ExecutionAudit) Shutdown(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	if a.otlp != nil {
+		return a.otlp.Shutdown(ctx)
+	}
+	return errors.Join(a.logger.Sync(), a.file.Close())
+}
+
+// executionAuditRecord collects the decisions of a request until the record is written. The subgraphs and the
+// denied fields are added concurrently by the fetches of the engine.
+type executionAuditRecord struct {
+	start time.Time
+
+	operationName string
+	operationType string
+	persistedID   string
+	planned       bool
+	operationHash uint64
+	planCacheHit  bool
+	authEvaluated bool
+
+	mu           sync.Mutex
+	policies     []string
+	subgraphs    []executionAuditSubgraph
+	deniedFields []string
+}
+
+type executionAuditSubgraph struct {
+	name     string
+	requests int
+	failed   int
+}
+
+// executionAuditOf returns the record of the request, nil if the execution audit is disabled
+func executionAuditOf(ctx context.Context) *executionAuditRecord {
+	reqContext := getRequestContext(ctx)
+	if reqContext == nil {
+		return nil
+	}
+	return reqContext.executionAudit
+}
+
+func (r *executionAuditRecord) operation(name, opType string) {
+	if r == nil {
+		return
+	}
+	r.operationName = name
+	r.operationType = opType
+}
+
+func (r *executionAuditRecord) plan(opContext *operationContext) {
+	if r == nil {
+		return
+	}
+	r.planned = true
+	r.persistedID = opContext.persistedID
+	r.operationHash = opContext.fingerprint
+	r.planCacheHit = opContext.planCacheHit
+}
+
+// authenticate records that the authenticators were evaluated
+func (r *executionAuditRecord) authenticate() {
+	if r == nil {
+		return
+	}
+	r.authEvaluated = true
+	r.policy(ExecutionAuditPolicyAuthentication)
+}
+
+// policy adds a policy that was applied to the request
+func (r *executionAuditRecord) policy(name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies = append(r.policies, name)
+}
+
+// subgraphRequest counts a request to a subgraph
+func (r *executionAuditRecord) subgraphRequest(name string, failed bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := 0
+	for ; i < len(r.subgraphs); i++ {
+		if r.subgraphs[i].name == name {
+			break
+		}
+	}
+	if i == len(r.subgraphs) {
+		r.subgraphs = append(r.subgraphs, executionAuditSubgraph{name: name})
+	}
+	r.subgraphs[i].requests++
+	if failed {
+		r.subgraphs[i].failed++
+	}
+}
+
+// authorizationDenied adds a field whose access was denied
+func (r *executionAuditRecord) authorizationDenied(typeName, fieldName string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deniedFields = append(r.deniedFields, typeName+"."+fieldName)
+}
+
+type executionAuditPlan struct {
+	operationHash uint64
+	cacheHit      bool
+}
+
+func (p executionAuditPlan) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("operation_hash", strconv.FormatUint(p.operationHash, 10))
+	enc.AddBool("cache_hit", p.cacheHit)
+	return nil
+}
+
+type executionAuditSubgraphs []executionAuditSubgraph
+
+func (s executionAuditSubgraphs) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, subgraph := range s {
+		if err := enc.AppendObject(subgraph); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s executionAuditSubgraph) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", s.name)
+	enc.AddInt("requests", s.requests)
+	enc.AddInt("failed_requests", s.failed)
+	return nil
+}
+
+type executionAuditAuthentication struct {
+	evaluated     bool
+	authenticated bool
+	authenticator string
+	claims        map[string]any
+}
+
+func (a executionAuditAuthentication) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddBool("evaluated", a.evaluated)
+	enc.AddBool("authenticated", a.authenticated)
+	if a.authenticator != "" {
+		enc.AddString("authenticator", a.authenticator)
+	}
+	if len(a.claims) > 0 {
+		return enc.AddReflected("claims", a.claims)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+)
+
+func TestExecutionAuditNDJSON(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "execution-audit.ndjson")
+	audit, err := NewExecutionAudit(&ExecutionAuditOptions{
+		Exporter: ExecutionAuditExporterNDJSON,
+		Path:     path,
+		Claims:   []string{"sub"},
+	})
+	require.NoError(t, err)
+
+	record := audit.start()
+	record.operation("Employees", "query")
+	record.plan(&operationContext{fingerprint: 42, planCacheHit: true, persistedID: "abc"})
+	record.authenticate()
+	record.policy(ExecutionAuditPolicyAuthorization)
+
+	// The subgraphs are requested concurrently by the engine
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			record.subgraphRequest("employees", i == 0)
+		}(i)
+	}
+	wg.Wait()
+	record.subgraphRequest("products", false)
+	record.authorizationDenied("Employee", "salary")
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "request-1")
+	ctx = authentication.NewContext(ctx, &testAuthentication{claims: authentication.Claims{"sub": "alice", "email": "alice@example.com"}})
+	audit.write(ctx, record, &ClientInfo{Name: "web", Version: "1.0.0"}, 200, nil)
+
+	// Records of requests that failed before the operation was parsed
+	audit.write(context.Background(), audit.start(), &ClientInfo{Name: "web"}, 400, &inputError{message: "invalid request", statusCode: 400})
+	require.NoError(t, audit.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, ExecutionAuditMessage, entry["msg"])
+	require.Equal(t, "request-1", entry["request_id"])
+	require.Equal(t, "Employees", entry["operation_name"])
+	require.Equal(t, "query", entry["operation_type"])
+	require.Equal(t, "abc", entry["persisted_operation_id"])
+	require.Equal(t, map[string]any{"operation_hash": "42", "cache_hit": true}, entry["plan"])
+	require.Equal(t, []any{
+		map[string]any{"name": "employees", "requests": float64(10), "failed_requests": float64(1)},
+		map[string]any{"name": "products", "requests": float64(1), "failed_requests": float64(0)},
+	}, entry["subgraphs"])
+	require.Equal(t, []any{ExecutionAuditPolicyAuthentication, ExecutionAuditPolicyAuthorization}, entry["policies"])
+	require.Equal(t, []any{"Employee.salary"}, entry["denied_fields"])
+	// Only the configured claims are recorded
+	require.Equal(t, map[string]any{
+		"evaluated":     true,
+		"authenticated": true,
+		"authenticator": "test",
+		"claims":        map[string]any{"sub": "alice"},
+	}, entry["authentication"])
+	require.Equal(t, float64(200), entry["status_code"])
+	require.NotContains(t, entry, "error_code")
+
+	entry = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	require.NotContains(t, entry, "operation_name")
+	require.NotContains(t, entry, "plan")
+	require.Equal(t, map[string]any{"evaluated": false, "authenticated": false}, entry["authentication"])
+	require.Equal(t, InvalidRequestErrorCode, entry["error_code"])
+}
+
+func TestExecutionAuditConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewExecutionAudit(&ExecutionAuditOptions{Exporter: ExecutionAuditExporterNDJSON})
+	require.Error(t, err)
+	_, err = NewExecutionAudit(&ExecutionAuditOptions{Exporter: ExecutionAuditExporterOTLP})
+	require.Error(t, err)
+	_, err = NewExecutionAudit(&ExecutionAuditOptions{Exporter: "syslog", Path: "audit.ndjson"})
+	require.Error(t, err)
+
+	// All methods can be called without the execution audit
+	var audit *ExecutionAudit
+	record := audit.start()
+	require.Nil(t, record)
+	record.policy(ExecutionAuditPolicyRateLimit)
+	record.subgraphRequest("employees", false)
+	audit.write(context.Background(), record, &ClientInfo{}, 200, nil)
+	require.NoError(t, audit.Shutdown(context.Background()))
+}
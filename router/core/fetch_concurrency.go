@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FetchConcurrency limits the number of concurrent subgraph fetches of a request. Without a limit, the engine
+// fetches all entities of a plan step in parallel, which amplifies list heavy queries into bursts of subgraph
+// requests. A limit of zero disables it.
+type FetchConcurrency struct {
+	maxPerRequest int
+	operations    map[string]int
+}
+
+type FetchConcurrencyOptions struct {
+	MaxPerRequest int
+	// Operations override MaxPerRequest by operation name
+	Operations map[string]int
+}
+
+func NewFetchConcurrency(opts *FetchConcurrencyOptions) (*FetchConcurrency, error) {
+	if opts.MaxPerRequest < 0 {
+		return nil, errors.New("the maximum of concurrent fetches per request must not be negative")
+	}
+	for name, limit := range opts.Operations {
+		if name == "" {
+			return nil, errors.New("fetch concurrency overrides require an operation name")
+		}
+		if limit < 0 {
+			return nil, fmt.Errorf("the maximum of concurrent fetches of operation '%s' must not be negative", name)
+		}
+	}
+
+	return &FetchConcurrency{
+		maxPerRequest: opts.MaxPerRequest,
+		operations:    opts.Operations,
+	}, nil
+}
+
+// Limit returns the maximum of concurrent fetches of the operation. Zero is unlimited.
+func (c *FetchConcurrency) Limit(operationName string) int {
+	if c == nil {
+		return 0
+	}
+	if limit, ok := c.operations[operationName]; ok {
+		return limit
+	}
+	return c.maxPerRequest
+}
+
+// newFetchLimiter returns the limiter of a request or nil if the operation has no limit. Subscriptions aren't
+// limited, because their upstream connection would hold a slot for the lifetime of the subscription.
+func (c *FetchConcurrency) newFetchLimiter(operationName, operationType string) *fetchLimiter {
+	if operationType == "subscription" {
+		return nil
+	}
+	limit := c.Limit(operationName)
+	if limit <= 0 {
+		return nil
+	}
+	return &fetchLimiter{slots: make(chan struct{}, limit)}
+}
+
+// fetchLimiter is a semaphore for the subgraph fetches of a single request
+type fetchLimiter struct {
+	slots chan struct{}
+}
+
+// acquire blocks until a fetch can be started or the request is canceled
+func (l *fetchLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *fetchLimiter) release() {
+	<-l.slots
+}
+
+// releaseOnClose holds the slot of a fetch until the response body is closed, because the body is read after
+// the round trip returned
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
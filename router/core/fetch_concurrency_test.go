@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchConcurrency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates the options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewFetchConcurrency(&FetchConcurrencyOptions{MaxPerRequest: -1})
+		require.Error(t, err)
+		_, err = NewFetchConcurrency(&FetchConcurrencyOptions{Operations: map[string]int{"": 1}})
+		require.Error(t, err)
+		_, err = NewFetchConcurrency(&FetchConcurrencyOptions{Operations: map[string]int{"Dashboard": -1}})
+		require.Error(t, err)
+	})
+
+	t.Run("limits by operation name", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := NewFetchConcurrency(&FetchConcurrencyOptions{
+			MaxPerRequest: 4,
+			Operations:    map[string]int{"Dashboard": 16, "Unlimited": 0},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 4, c.Limit("Employees"))
+		require.Equal(t, 16, c.Limit("Dashboard"))
+		require.Equal(t, 0, c.Limit("Unlimited"))
+
+		require.Nil(t, c.newFetchLimiter("Unlimited", "query"))
+		require.Nil(t, c.newFetchLimiter("Employees", "subscription"))
+		require.Equal(t, 4, cap(c.newFetchLimiter("Employees", "query").slots))
+
+		var disabled *FetchConcurrency
+		require.Zero(t, disabled.Limit("Employees"))
+		require.Nil(t, disabled.newFetchLimiter("Employees", "query"))
+	})
+
+	t.Run("blocks fetches over the limit", func(t *testing.T) {
+		t.Parallel()
+
+		l := &fetchLimiter{slots: make(chan struct{}, 1)}
+		require.NoError(t, l.acquire(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, l.acquire(ctx), context.DeadlineExceeded)
+
+		body := &releaseOnClose{ReadCloser: io.NopCloser(strings.NewReader("{}")), release: l.release}
+		require.NoError(t, body.Close())
+		require.NoError(t, body.Close())
+
+		require.NoError(t, l.acquire(context.Background()))
+		require.Len(t, l.slots, 1)
+	})
+}
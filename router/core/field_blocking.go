@@ -0,0 +1,440 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astvisitor"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const (
+	// FieldBlockingActionBlock rejects the operations that select the field
+	FieldBlockingActionBlock = "block"
+	// FieldBlockingActionMask replaces the values of the field in the responses with null
+	FieldBlockingActionMask = "mask"
+)
+
+// FieldBlockedErrorCode is the code in the extensions of the error of an operation that selects a blocked field
+const FieldBlockedErrorCode = "FIELD_BLOCKED"
+
+// FieldBlockedError is returned when an operation selects a blocked field
+type FieldBlockedError struct {
+	Coordinate string
+}
+
+func (e *FieldBlockedError) Error() string {
+	return fmt.Sprintf("field '%s' is blocked", e.Coordinate)
+}
+
+// BlockedField is a field of the schema that is blocked or masked
+type BlockedField struct {
+	Coordinate string `json:"coordinate"`
+	Action     string `json:"action"`
+}
+
+// FieldBlocker blocks or masks fields of the schema by their coordinate, e.g. Employee.salary, as an emergency measure
+// when a field leaks data or overloads a subgraph. The fields come from the config, a file that is reloaded when it
+// changes and the admin API. Like the persisted operation kill switch, the state is shared between all servers so that
+// it survives router config updates.
+type FieldBlocker struct {
+	logger         *zap.Logger
+	file           string
+	reloadInterval time.Duration
+
+	// fields are the actions of all sources by coordinate. The map is replaced on every change, so lookups don't lock.
+	fields atomic.Pointer[map[string]string]
+
+	mu         sync.Mutex
+	static     map[string]string
+	fromFile   map[string]string
+	fromAdmin  map[string]string
+	fileMod    time.Time
+	fileSize   int64
+	cancel     context.CancelFunc
+	reloadDone chan struct{}
+}
+
+func NewFieldBlocker(logger *zap.Logger, cfg *config.FieldBlockingConfiguration) (*FieldBlocker, error) {
+	b := &FieldBlocker{
+		logger:         logger,
+		file:           cfg.File,
+		reloadInterval: cfg.ReloadInterval,
+		static:         make(map[string]string, len(cfg.Fields)),
+		fromAdmin:      make(map[string]string),
+	}
+
+	for _, field := range cfg.Fields {
+		coordinate, action, err := parseBlockedField(field.Coordinate, field.Action)
+		if err != nil {
+			return nil, err
+		}
+		b.static[coordinate] = action
+	}
+
+	if b.file != "" {
+		// A missing or invalid file is a misconfiguration at startup. Later, the last valid state is kept.
+		if _, err := b.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	b.mu.Lock()
+	b.update()
+	b.mu.Unlock()
+
+	return b, nil
+}
+
+// Block blocks or masks the field with the coordinate until it is unblocked or the router restarts
+func (b *FieldBlocker) Block(coordinate, action string) error {
+	coordinate, action, err := parseBlockedField(coordinate, action)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.fromAdmin[coordinate] = action
+	b.update()
+
+	return nil
+}
+
+// Unblock removes a field that was blocked with Block. Fields of the config and the file are kept.
+// It returns false if the field wasn't blocked with Block.
+func (b *FieldBlocker) Unblock(coordinate string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	coordinate = strings.TrimSpace(coordinate)
+	if _, ok := b.fromAdmin[coordinate]; !ok {
+		return false
+	}
+	delete(b.fromAdmin, coordinate)
+	b.update()
+
+	return true
+}
+
+// Fields returns the blocked and masked fields sorted by coordinate
+func (b *FieldBlocker) Fields() []BlockedField {
+	fields := b.fields.Load()
+	if fields == nil {
+		return []BlockedField{}
+	}
+	blocked := make([]BlockedField, 0, len(*fields))
+	for coordinate, action := range *fields {
+		blocked = append(blocked, BlockedField{Coordinate: coordinate, Action: action})
+	}
+	slices.SortFunc(blocked, func(a, b BlockedField) int {
+		return strings.Compare(a.Coordinate, b.Coordinate)
+	})
+	return blocked
+}
+
+// check returns a FieldBlockedError if the operation selects a blocked field, and the selected fields that are
+// masked otherwise
+func (b *FieldBlocker) check(fields []operationField) ([]operationField, error) {
+	actions := b.fields.Load()
+	if actions == nil || len(*actions) == 0 {
+		return nil, nil
+	}
+
+	var masked []operationField
+	for _, field := range fields {
+		switch (*actions)[field.coordinate] {
+		case FieldBlockingActionBlock:
+			return nil, &FieldBlockedError{Coordinate: field.coordinate}
+		case FieldBlockingActionMask:
+			masked = append(masked, field)
+		}
+	}
+	return masked, nil
+}
+
+// Start reloads the file in the background when it was modified. It's a no-op without a file.
+func (b *FieldBlocker) Start() {
+	if b.file == "" || b.reloadInterval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b.mu.Lock()
+	b.cancel = cancel
+	b.reloadDone = make(chan struct{})
+	done := b.reloadDone
+	b.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(b.reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				changed, err := b.reload()
+				if err != nil {
+					b.logger.Warn("Failed to reload the field blocking file. The previous fields are kept",
+						zap.String("file", b.file),
+						zap.Error(err),
+					)
+					continue
+				}
+				if changed {
+					b.mu.Lock()
+					b.update()
+					count := len(b.fromFile)
+					b.mu.Unlock()
+
+					b.logger.Info("Reloaded the field blocking file",
+						zap.String("file", b.file),
+						zap.Int("fields", count),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown stops reloading the file
+func (b *FieldBlocker) Shutdown() {
+	b.mu.Lock()
+	cancel, done := b.cancel, b.reloadDone
+	b.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// reload reads the file when its modification time or size changed. The file contains one coordinate per line,
+// optionally followed by the action. Empty lines and lines starting with # are ignored.
+func (b *FieldBlocker) reload() (bool, error) {
+	info, err := os.Stat(b.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat the field blocking file: %w", err)
+	}
+
+	b.mu.Lock()
+	unchanged := info.ModTime().Equal(b.fileMod) && info.Size() == b.fileSize
+	b.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	content, err := os.ReadFile(b.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the field blocking file: %w", err)
+	}
+
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		coordinate, action, _ := strings.Cut(line, " ")
+		coordinate, action, err = parseBlockedField(coordinate, action)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse the field blocking file: %w", err)
+		}
+		fields[coordinate] = action
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to parse the field blocking file: %w", err)
+	}
+
+	b.mu.Lock()
+	b.fromFile = fields
+	b.fileMod = info.ModTime()
+	b.fileSize = info.Size()
+	b.mu.Unlock()
+
+	return true, nil
+}
+
+// update rebuilds the actions of the fields. Blocking wins over masking. The caller must hold the lock.
+func (b *FieldBlocker) update() {
+	fields := make(map[string]string, len(b.static)+len(b.fromFile)+len(b.fromAdmin))
+	for _, source := range []map[string]string{b.static, b.fromFile, b.fromAdmin} {
+		for coordinate, action := range source {
+			if fields[coordinate] != FieldBlockingActionBlock {
+				fields[coordinate] = action
+			}
+		}
+	}
+	b.fields.Store(&fields)
+}
+
+// parseBlockedField validates the coordinate of the form Type.field and the action. An empty action blocks the field.
+func parseBlockedField(coordinate, action string) (string, string, error) {
+	coordinate = strings.TrimSpace(coordinate)
+	typeName, fieldName, ok := strings.Cut(coordinate, ".")
+	if !ok || typeName == "" || fieldName == "" || strings.Contains(fieldName, ".") {
+		return "", "", fmt.Errorf("invalid schema coordinate '%s', must be Type.field", coordinate)
+	}
+
+	switch action = strings.ToLower(strings.TrimSpace(action)); action {
+	case "":
+		action = FieldBlockingActionBlock
+	case FieldBlockingActionBlock, FieldBlockingActionMask:
+	default:
+		return "", "", fmt.Errorf("unknown action '%s' of the field '%s', must be block or mask", action, coordinate)
+	}
+
+	return coordinate, action, nil
+}
+
+// writeFieldBlockedError rejects the operation that selects a blocked field
+func writeFieldBlockedError(w http.ResponseWriter, err *FieldBlockedError, requestLogger *zap.Logger) {
+	requestLogger.Debug("Rejected operation with a blocked field", zap.String("coordinate", err.Coordinate))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	response := GraphQLErrorResponse{Errors: []graphqlError{{
+		Message:    err.Error(),
+		Extensions: &Extensions{Code: FieldBlockedErrorCode},
+	}}}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		requestLogger.Debug("Failed to write the field blocked error", zap.Error(err))
+	}
+}
+
+// operationField is a field selected by an operation
+type operationField struct {
+	// coordinate is the schema coordinate of the field, e.g. Employee.salary
+	coordinate string
+	// path are the response keys from the data of the response to the field. Lists are not part of the path.
+	path []string
+}
+
+// operationFields returns the fields selected by the operation. Every field is returned once per path.
+func operationFields(operation, definition *ast.Document) []operationField {
+	walker := astvisitor.NewWalker(8)
+	v := &operationFieldsVisitor{
+		walker:     &walker,
+		operation:  operation,
+		definition: definition,
+	}
+	walker.RegisterEnterFieldVisitor(v)
+
+	report := &operationreport.Report{}
+	walker.Walk(operation, definition, report)
+	if report.HasErrors() {
+		return nil
+	}
+
+	return v.fields
+}
+
+type operationFieldsVisitor struct {
+	walker                *astvisitor.Walker
+	operation, definition *ast.Document
+	fields                []operationField
+}
+
+func (v *operationFieldsVisitor) EnterField(ref int) {
+	enclosing := v.walker.EnclosingTypeDefinition
+	fieldDefinition, ok := v.definition.NodeFieldDefinitionByName(enclosing, v.operation.FieldNameBytes(ref))
+	if !ok {
+		return
+	}
+
+	field := operationField{
+		coordinate: v.definition.NodeNameString(enclosing) + "." + v.definition.FieldDefinitionNameString(fieldDefinition),
+	}
+	for _, ancestor := range v.walker.Ancestors {
+		if ancestor.Kind == ast.NodeKindField {
+			field.path = append(field.path, v.operation.FieldAliasOrNameString(ancestor.Ref))
+		}
+	}
+	field.path = append(field.path, v.operation.FieldAliasOrNameString(ref))
+
+	for _, existing := range v.fields {
+		if existing.coordinate == field.coordinate && slices.Equal(existing.path, field.path) {
+			return
+		}
+	}
+	v.fields = append(v.fields, field)
+}
+
+// maskResponseFields replaces the values of the fields in the data of the response in buf with null
+func maskResponseFields(buf *bytes.Buffer, fields []operationField) error {
+	response := bytes.Clone(buf.Bytes())
+	var err error
+	for _, field := range fields {
+		if response, err = maskResponsePath(response, []string{"data"}, field.path); err != nil {
+			return err
+		}
+	}
+	buf.Reset()
+	_, err = buf.Write(response)
+	return err
+}
+
+// maskResponsePath sets the value at the path below the prefix to null. Lists on the path are masked in every item.
+func maskResponsePath(response []byte, prefix, path []string) ([]byte, error) {
+	value, dataType, _, err := jsonparser.Get(response, prefix...)
+	if errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return response, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch dataType {
+	case jsonparser.Array:
+		count := 0
+		_, err = jsonparser.ArrayEach(value, func([]byte, jsonparser.ValueType, int, error) {
+			count++
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < count; i++ {
+			item := append(slices.Clip(prefix), "["+strconv.Itoa(i)+"]")
+			if response, err = maskResponsePath(response, item, path); err != nil {
+				return nil, err
+			}
+		}
+		return response, nil
+	case jsonparser.Object:
+		next := append(slices.Clip(prefix), path[0])
+		if len(path) > 1 {
+			return maskResponsePath(response, next, path[1:])
+		}
+		if _, _, _, err := jsonparser.Get(value, path[0]); err != nil {
+			return response, nil
+		}
+		return jsonparser.Set(response, []byte("null"), next...)
+	default:
+		// Null values have nothing to mask
+		return response, nil
+	}
+}
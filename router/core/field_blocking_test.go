@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const fieldBlockingTestSchema = `
+type Query {
+	employees: [Employee!]!
+	employee(id: Int!): Employee
+}
+
+type Employee {
+	id: Int!
+	salary: Int
+	details: Details
+}
+
+type Details {
+	forename: String!
+	salary: Int
+}
+`
+
+func TestFieldBlocker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("blocks the fields of all sources", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "fields.txt")
+		require.NoError(t, os.WriteFile(file, []byte("# incident 42\n\nDetails.salary mask\nEmployee.salary\n"), 0o600))
+
+		b, err := NewFieldBlocker(zap.NewNop(), &config.FieldBlockingConfiguration{
+			Fields: []config.BlockedField{{Coordinate: "Employee.salary", Action: "mask"}},
+			File:   file,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, b.Block("Query.employees", ""))
+		// Blocking wins over masking
+		require.Equal(t, []BlockedField{
+			{Coordinate: "Details.salary", Action: FieldBlockingActionMask},
+			{Coordinate: "Employee.salary", Action: FieldBlockingActionBlock},
+			{Coordinate: "Query.employees", Action: FieldBlockingActionBlock},
+		}, b.Fields())
+
+		require.True(t, b.Unblock("Query.employees"))
+		// Only the fields blocked at runtime can be unblocked
+		require.False(t, b.Unblock("Employee.salary"))
+		require.Len(t, b.Fields(), 2)
+
+		require.EqualError(t, b.Block("Employee", ""), "invalid schema coordinate 'Employee', must be Type.field")
+		require.EqualError(t, b.Block("Employee.id", "hide"), "unknown action 'hide' of the field 'Employee.id', must be block or mask")
+	})
+
+	t.Run("reloads the file when it changes", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "fields.txt")
+		require.NoError(t, os.WriteFile(file, []byte("Employee.salary\n"), 0o600))
+
+		b, err := NewFieldBlocker(zap.NewNop(), &config.FieldBlockingConfiguration{
+			File:           file,
+			ReloadInterval: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		b.Start()
+		t.Cleanup(b.Shutdown)
+
+		require.NoError(t, os.WriteFile(file, []byte("Details.salary mask\n"), 0o600))
+		require.Eventually(t, func() bool {
+			fields := b.Fields()
+			return len(fields) == 1 && fields[0].Coordinate == "Details.salary"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// The previous fields are kept while the file is invalid
+		require.NoError(t, os.WriteFile(file, []byte("Details\n"), 0o600))
+		time.Sleep(50 * time.Millisecond)
+		require.Equal(t, []BlockedField{{Coordinate: "Details.salary", Action: FieldBlockingActionMask}}, b.Fields())
+	})
+
+	t.Run("checks and masks the fields of the operation", func(t *testing.T) {
+		t.Parallel()
+
+		definition, report := astparser.ParseGraphqlDocumentString(fieldBlockingTestSchema)
+		require.False(t, report.HasErrors(), report.Error())
+		require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+		operation, report := astparser.ParseGraphqlDocumentString(`{ employees { id pay: salary details { salary } } employee(id: 1) { id } }`)
+		require.False(t, report.HasErrors(), report.Error())
+		fields := operationFields(&operation, &definition)
+
+		b, err := NewFieldBlocker(zap.NewNop(), &config.FieldBlockingConfiguration{
+			Fields: []config.BlockedField{
+				{Coordinate: "Employee.salary", Action: "mask"},
+				{Coordinate: "Details.salary", Action: "mask"},
+			},
+		})
+		require.NoError(t, err)
+
+		masked, err := b.check(fields)
+		require.NoError(t, err)
+		require.Equal(t, []operationField{
+			{coordinate: "Employee.salary", path: []string{"employees", "pay"}},
+			{coordinate: "Details.salary", path: []string{"employees", "details", "salary"}},
+		}, masked)
+
+		buf := bytes.NewBufferString(`{"data":{"employees":[{"id":1,"pay":100,"details":{"salary":100}},{"id":2,"pay":200,"details":null}],"employee":{"id":1}}}`)
+		require.NoError(t, maskResponseFields(buf, masked))
+		require.Equal(t, `{"data":{"employees":[{"id":1,"pay":null,"details":{"salary":null}},{"id":2,"pay":null,"details":null}],"employee":{"id":1}}}`, buf.String())
+
+		require.NoError(t, b.Block("Query.employee", FieldBlockingActionBlock))
+		_, err = b.check(fields)
+		require.Equal(t, &FieldBlockedError{Coordinate: "Query.employee"}, err)
+	})
+}
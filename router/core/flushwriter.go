@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"context"
+	"io"
 	"net/http"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
@@ -21,6 +22,7 @@ type HttpFlushWriter struct {
 	flusher       http.Flusher
 	subscribeOnce bool
 	sse           bool
+	multipart     bool
 	buf           *bytes.Buffer
 	variables     []byte
 }
@@ -32,6 +34,9 @@ func (f *HttpFlushWriter) Complete() {
 	if f.sse {
 		_, _ = f.writer.Write([]byte("event: complete"))
 	}
+	if f.multipart {
+		_, _ = io.WriteString(f.writer, multipartEnd)
+	}
 	f.Close()
 }
 
@@ -57,6 +62,14 @@ func (f *HttpFlushWriter) Flush() (err error) {
 	resp := f.buf.Bytes()
 	f.buf.Reset()
 
+	if f.multipart {
+		if err = writeMultipartPart(f.writer, resp); err != nil {
+			return err
+		}
+		f.flusher.Flush()
+		return nil
+	}
+
 	if f.sse {
 		_, err = f.writer.Write([]byte("event: next\ndata: "))
 		if err != nil {
@@ -85,8 +98,16 @@ func GetSubscriptionResponseWriter(ctx *resolve.Context, variables []byte, r *ht
 	type withFlushWriter interface {
 		SubscriptionResponseWriter() resolve.SubscriptionResponseWriter
 	}
-	if wfw, ok := w.(withFlushWriter); ok {
-		return ctx, wfw.SubscriptionResponseWriter(), true
+	// The writer can be wrapped by the middlewares of the mux, e.g. the access logger
+	for unwrapped := w; unwrapped != nil; {
+		if wfw, ok := unwrapped.(withFlushWriter); ok {
+			return ctx, wfw.SubscriptionResponseWriter(), true
+		}
+		u, ok := unwrapped.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		unwrapped = u.Unwrap()
 	}
 	wgParams := NewWgRequestParams(r)
 
@@ -95,14 +116,23 @@ func GetSubscriptionResponseWriter(ctx *resolve.Context, variables []byte, r *ht
 		return ctx, nil, false
 	}
 
+	// Multipart responses are chosen by the Accept header, unless the query parameters request another protocol
+	multipart := !wgParams.UseSse && !wgParams.SubscribeOnce &&
+		negotiateResponseFormat(r.Header.Get("Accept"), false) == responseFormatMultipart
+
 	if !wgParams.SubscribeOnce {
-		setSubscriptionHeaders(w)
+		contentType := "text/event-stream"
+		if multipart {
+			contentType = responseFormatMultipart.contentType()
+		}
+		setSubscriptionHeaders(w, contentType)
 	}
 
 	flushWriter := &HttpFlushWriter{
 		writer:    w,
 		flusher:   flusher,
 		sse:       wgParams.UseSse,
+		multipart: multipart,
 		buf:       &bytes.Buffer{},
 		ctx:       ctx.Context(),
 		variables: variables,
@@ -116,8 +146,8 @@ func GetSubscriptionResponseWriter(ctx *resolve.Context, variables []byte, r *ht
 	return ctx, flushWriter, true
 }
 
-func setSubscriptionHeaders(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "text/event-stream")
+func setSubscriptionHeaders(w http.ResponseWriter, contentType string) {
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	// allow unbuffered responses, it's used when it's necessary just to pass response through
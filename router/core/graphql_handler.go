@@ -7,8 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 
@@ -20,6 +23,7 @@ import (
 
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/logging"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
 
 	"github.com/wundergraph/cosmo/router/internal/pool"
 
@@ -83,8 +87,31 @@ type HandlerOptions struct {
 	Authorizer                                  *CosmoAuthorizer
 	RateLimiter                                 *CosmoRateLimiter
 	RateLimitConfig                             *config.RateLimitConfiguration
-	SubgraphErrorPropagation                    config.SubgraphErrorPropagationConfiguration
-	EngineLoaderHooks                           resolve.LoaderHooks
+	// UsageQuotas are the daily or monthly budgets of the rate limit keys
+	UsageQuotas              *UsageQuotas
+	SubgraphErrorPropagation config.SubgraphErrorPropagationConfiguration
+	EngineLoaderHooks        resolve.LoaderHooks
+	// StreamingFlushThreshold enables streaming of responses that are larger than the threshold in bytes
+	StreamingFlushThreshold int
+	OperationTimeouts       *OperationTimeouts
+	FetchConcurrency        *FetchConcurrency
+	ResponseSizeLimit       *ResponseSizeLimit
+	RequestMemoryLimit      *RequestMemoryLimit
+	MetricStore             metric.Provider
+	SurrogateKeys           *SurrogateKeys
+	// EntityKeyFields are the entity keys of the graph the surrogate keys are derived from
+	EntityKeyFields EntityKeyFields
+	ETags           *ETags
+	// CBORResponses encodes the responses with CBOR for the clients that accept application/cbor
+	CBORResponses bool
+	// SubscriptionLimits limits the SSE and multipart subscriptions and the subscriptions of the WebSockets
+	SubscriptionLimits *SubscriptionLimits
+	// SubscriptionReaper closes the idle subscriptions
+	SubscriptionReaper *SubscriptionReaper
+	// SubscriptionBackpressure buffers the events of the slow subscribers
+	SubscriptionBackpressure *SubscriptionBackpressure
+	// RequestCoalescing executes identical concurrent queries once
+	RequestCoalescing *RequestCoalescing
 }
 
 func NewGraphQLHandler(opts HandlerOptions) *GraphQLHandler {
@@ -101,8 +128,23 @@ func NewGraphQLHandler(opts HandlerOptions) *GraphQLHandler {
 		authorizer:               opts.Authorizer,
 		rateLimiter:              opts.RateLimiter,
 		rateLimitConfig:          opts.RateLimitConfig,
+		usageQuotas:              opts.UsageQuotas,
 		subgraphErrorPropagation: opts.SubgraphErrorPropagation,
 		engineLoaderHooks:        opts.EngineLoaderHooks,
+		streamingFlushThreshold:  opts.StreamingFlushThreshold,
+		operationTimeouts:        opts.OperationTimeouts,
+		fetchConcurrency:         opts.FetchConcurrency,
+		responseSizeLimit:        opts.ResponseSizeLimit,
+		requestMemoryLimit:       opts.RequestMemoryLimit,
+		cborResponses:            opts.CBORResponses,
+		metricStore:              opts.MetricStore,
+		surrogateKeys:            opts.SurrogateKeys,
+		entityKeyFields:          opts.EntityKeyFields,
+		etags:                    opts.ETags,
+		subscriptionLimits:       opts.SubscriptionLimits,
+		subscriptionReaper:       opts.SubscriptionReaper,
+		subscriptionBackpressure: opts.SubscriptionBackpressure,
+		requestCoalescing:        opts.RequestCoalescing,
 	}
 	return graphQLHandler
 }
@@ -128,12 +170,27 @@ type GraphQLHandler struct {
 
 	rateLimiter              *CosmoRateLimiter
 	rateLimitConfig          *config.RateLimitConfiguration
+	usageQuotas              *UsageQuotas
 	subgraphErrorPropagation config.SubgraphErrorPropagationConfiguration
 	engineLoaderHooks        resolve.LoaderHooks
+	streamingFlushThreshold  int
+	operationTimeouts        *OperationTimeouts
+	fetchConcurrency         *FetchConcurrency
+	responseSizeLimit        *ResponseSizeLimit
+	requestMemoryLimit       *RequestMemoryLimit
+	cborResponses            bool
+	metricStore              metric.Provider
+	surrogateKeys            *SurrogateKeys
+	entityKeyFields          EntityKeyFields
+	etags                    *ETags
+	subscriptionLimits       *SubscriptionLimits
+	subscriptionReaper       *SubscriptionReaper
+	subscriptionBackpressure *SubscriptionBackpressure
+	requestCoalescing        *RequestCoalescing
 }
 
 func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	requestLogger := h.log.With(logging.WithRequestID(middleware.GetReqID(r.Context())))
+	requestLogger := logging.WithTraceContext(r.Context(), logging.EnrichFromOperationContext(r.Context(), h.log.With(logging.WithRequestID(middleware.GetReqID(r.Context())))))
 	operationCtx := getOperationContext(r.Context())
 
 	var baseAttributes []attribute.KeyValue
@@ -148,6 +205,26 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	)
 	defer graphqlExecutionSpan.End()
 
+	timeout := tenantOf(r.Context()).operationTimeout(h.operationTimeouts, operationCtx.Name(), operationCtx.Type())
+	executionContext, cancelTimeout := withOperationTimeout(executionContext, timeout)
+	defer cancelTimeout()
+
+	budget, executionContext, cancelBudget := h.requestMemoryLimit.newBudget(executionContext, operationCtx.Type())
+	defer cancelBudget()
+	// The operation and its variables are kept until the request is completed
+	_ = budget.charge(int64(len(operationCtx.Content()) + len(operationCtx.Variables())))
+
+	if reqCtx := getRequestContext(r.Context()); reqCtx != nil {
+		reqCtx.fetchLimiter = h.fetchConcurrency.newFetchLimiter(operationCtx.Name(), operationCtx.Type())
+		reqCtx.memoryBudget = budget
+		if reqCtx.fetchLimiter != nil {
+			reqCtx.executionAudit.policy(ExecutionAuditPolicyFetchConcurrency)
+		}
+		if budget != nil {
+			reqCtx.executionAudit.policy(ExecutionAuditPolicyRequestMemoryBudget)
+		}
+	}
+
 	ctx := &resolve.Context{
 		Variables: operationCtx.Variables(),
 		Files:     operationCtx.Files(),
@@ -170,26 +247,141 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx = h.configureRateLimiting(ctx)
 
+	if audit := executionAuditOf(r.Context()); audit != nil {
+		if h.authorizer != nil {
+			audit.policy(ExecutionAuditPolicyAuthorization)
+		}
+		if ctx.RateLimitOptions.Enable {
+			audit.policy(ExecutionAuditPolicyRateLimit)
+		}
+		if h.usageQuotas != nil {
+			audit.policy(ExecutionAuditPolicyUsageQuota)
+		}
+		if len(operationCtx.maskedFields) > 0 {
+			audit.policy(ExecutionAuditPolicyFieldMasking)
+		}
+	}
+
+	if h.usageQuotas != nil {
+		key := h.rateLimitKey(ctx)
+		if !h.allowUsageQuotas(ctx, key, w, r, requestLogger) {
+			return
+		}
+		defer h.recordUsageQuotas(ctx, key, r, requestLogger)
+	}
+
 	defer propagateSubgraphErrors(ctx, requestLogger)
 
 	switch p := operationCtx.preparedPlan.preparedPlan.(type) {
 	case *plan.SynchronousResponsePlan:
-		w.Header().Set("Content-Type", "application/json")
-		executionBuf := pool.GetBytesBuffer()
+		format := negotiateResponseFormat(r.Header.Get("Accept"), h.cborResponses)
+		w.Header().Set("Content-Type", format.contentType())
+		// The headers are set upfront because a streamed response is sent before the execution finished
+		h.setExecutionPlanCacheResponseHeader(w, operationCtx.planCacheHit)
+		h.setPersistedOperationCacheHeader(w, operationCtx.persistedOperationCacheHit)
+
+		// Responses of the same plan usually have a similar size. Starting with a buffer of the
+		// matching size class avoids growing it step by step while the response is resolved.
+		executionBuf := pool.GetBytesBufferWithSize(operationCtx.preparedPlan.responseSize.Load())
 		defer pool.PutBytesBuffer(executionBuf)
 
-		err := h.executor.Resolver.ResolveGraphQLResponse(ctx, p.Response, nil, executionBuf)
+		var out io.Writer = executionBuf
+		var stream *streamingResponseWriter
+		// Masking the fields and listing the deprecated fields require the complete response
+		if h.streamingFlushThreshold > 0 && format.streamable() && len(operationCtx.maskedFields) == 0 && len(operationCtx.deprecationWarnings) == 0 {
+			stream = newStreamingResponseWriter(w, executionBuf, h.streamingFlushThreshold)
+			out = stream
+			if h.responseSizeLimit != nil {
+				out = &streamLimitWriter{stream: stream, maxSize: h.responseSizeLimit.MaxSize()}
+			}
+		}
+		if budget != nil {
+			out = &memoryBudgetWriter{w: out, budget: budget}
+		}
+
+		var err error
+		// Streamed responses are sent while they are resolved, so they can't be shared
+		if key, ok := h.requestCoalescing.key(r, operationCtx); ok && stream == nil {
+			var shared bool
+			shared, err = h.requestCoalescing.do(executionContext, key, executionBuf, func() error {
+				return h.executor.Resolver.ResolveGraphQLResponse(ctx, p.Response, nil, out)
+			})
+			operationCtx.cacheDecisions.coalescing = cacheDecision(shared)
+		} else {
+			err = h.executor.Resolver.ResolveGraphQLResponse(ctx, p.Response, nil, out)
+		}
+		h.setRateLimitHeaders(ctx, w)
+		if isOperationTimeout(executionContext) {
+			// Failed fetches of a timed out operation are not errors of the subgraphs. The partial response
+			// is replaced with the timeout error.
+			h.trackOperationTimeout(executionContext, operationCtx, requestLogger)
+			err = ErrOperationTimeout
+		} else if isRequestMemoryLimitExceeded(executionContext) {
+			requestLogger.Warn("Request exceeded the memory limit",
+				zap.Int64("max_size", h.requestMemoryLimit.MaxSize()),
+				zap.Int64("used", budget.Used()),
+			)
+			err = ErrRequestMemoryLimitExceeded
+		}
+		if stream != nil && stream.streaming() {
+			operationCtx.preparedPlan.responseSize.Store(int64(h.streamingFlushThreshold))
+		} else {
+			operationCtx.preparedPlan.responseSize.Store(int64(executionBuf.Len()))
+		}
+		if err == nil && len(operationCtx.maskedFields) > 0 {
+			err = maskResponseFields(executionBuf, operationCtx.maskedFields)
+		}
+		if err == nil && len(operationCtx.deprecationWarnings) > 0 {
+			err = addDeprecationsExtension(executionBuf, operationCtx.deprecationWarnings)
+		}
+		if err == nil && (stream == nil || !stream.streaming()) {
+			var truncated bool
+			truncated, err = h.responseSizeLimit.apply(executionBuf)
+			if truncated {
+				requestLogger.Warn("Truncated the lists of a response that exceeded the maximum size",
+					zap.Int("max_size", h.responseSizeLimit.MaxSize()),
+				)
+			}
+		}
+		if errors.Is(err, ErrResponseTooLarge) || errors.Is(err, ErrRequestMemoryLimitExceeded) {
+			if errors.Is(err, ErrResponseTooLarge) {
+				requestLogger.Warn("Response exceeded the maximum size",
+					zap.Int("max_size", h.responseSizeLimit.MaxSize()),
+				)
+			}
+			trackResponseError(ctx.Context(), err)
+			if stream == nil || !stream.streaming() {
+				w.Header().Set("Content-Type", format.errorContentType())
+				h.WriteError(ctx, err, p.Response, w, executionBuf)
+			}
+			return
+		}
 		if err != nil {
-			requestLogger.Error("unable to resolve response", zap.Error(err))
 			trackResponseError(ctx.Context(), err)
+			// Parts of the response were already sent. We can't replace them with an error response anymore.
+			if stream != nil && stream.streaming() {
+				requestLogger.Error("unable to resolve streamed response", zap.Error(err))
+				return
+			}
+			requestLogger.Error("unable to resolve response", zap.Error(err))
+			w.Header().Set("Content-Type", format.errorContentType())
 			h.WriteError(ctx, err, p.Response, w, executionBuf)
 			return
 		}
 
-		h.setExecutionPlanCacheResponseHeader(w, operationCtx.planCacheHit)
-		h.setPersistedOperationCacheHeader(w, operationCtx.persistedOperationCacheHit)
-
-		_, err = executionBuf.WriteTo(w)
+		if stream != nil {
+			err = stream.Close()
+		} else {
+			// The headers of streamed responses were sent before the response was resolved
+			if h.surrogateKeys != nil {
+				h.surrogateKeys.apply(w, operationCtx, h.entityKeyFields, executionBuf.Bytes())
+			}
+			if h.etags != nil && h.etags.apply(w, r, operationCtx, executionBuf.Bytes()) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			err = format.writeResponse(w, executionBuf)
+		}
 		if err != nil {
 			requestLogger.Error("unable to write response", zap.Error(err))
 			trackResponseError(ctx.Context(), err)
@@ -200,6 +392,23 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			writer resolve.SubscriptionResponseWriter
 			ok     bool
 		)
+		if h.subscriptionLimits != nil {
+			release, err := h.subscriptionLimits.acquireStream(h.subscriptionLimits.clientKey(r))
+			if err != nil {
+				trackResponseError(r.Context(), err)
+				writeSubscriptionLimitError(w, err, requestLogger)
+				return
+			}
+			defer release()
+		}
+		// The reaper and the backpressure end an idle or slow subscription by canceling it
+		var cancelSubscription context.CancelFunc
+		if h.subscriptionReaper != nil || h.subscriptionBackpressure != nil {
+			var cancelCtx context.Context
+			cancelCtx, cancelSubscription = context.WithCancel(ctx.Context())
+			defer cancelSubscription()
+			ctx = ctx.WithContext(cancelCtx)
+		}
 		h.setExecutionPlanCacheResponseHeader(w, operationCtx.planCacheHit)
 		ctx, writer, ok = GetSubscriptionResponseWriter(ctx, ctx.Variables, r, w)
 		if !ok {
@@ -208,10 +417,35 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			writeRequestErrors(r, w, http.StatusInternalServerError, graphqlerrors.RequestErrorsFromError(errCouldNotFlushResponse), requestLogger)
 			return
 		}
+		if h.subscriptionReaper != nil {
+			// The subscription is canceled when it receives no events for the idle timeout
+			protocol := "http"
+			if NewWgRequestParams(r).UseSse {
+				protocol = "sse"
+			}
+			tracker := h.subscriptionReaper.track(protocol, func() int { return 1 }, cancelSubscription,
+				logging.WithRequestID(middleware.GetReqID(r.Context())),
+				zap.String("operation_name", operationCtx.Name()),
+			)
+			defer h.subscriptionReaper.untrack(tracker)
+			writer = &idleSubscriptionWriter{SubscriptionResponseWriter: writer, tracker: tracker}
+		}
+		if h.subscriptionBackpressure != nil {
+			// The buffered events are written before the response ends
+			backpressureWriter := h.subscriptionBackpressure.wrap(ctx.Context(), writer, cancelSubscription)
+			defer backpressureWriter.finish()
+			writer = backpressureWriter
+		}
 		h.websocketStats.ConnectionsInc()
 		defer h.websocketStats.ConnectionsDec()
 
 		err := h.executor.Resolver.ResolveGraphQLSubscription(ctx, p.Response, writer)
+		if isOperationTimeout(executionContext) {
+			// The subscription ends when its lifetime is exceeded
+			h.trackOperationTimeout(executionContext, operationCtx, requestLogger)
+			trackResponseError(r.Context(), ErrOperationTimeout)
+			return
+		}
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				requestLogger.Debug("context canceled: unable to resolve subscription response", zap.Error(err))
@@ -219,7 +453,7 @@ func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			} else if errors.Is(err, ErrUnauthorized) {
 				trackResponseError(ctx.Context(), err)
-				writeRequestErrors(r, w, http.StatusUnauthorized, graphqlerrors.RequestErrorsFromError(err), requestLogger)
+				writeHTTPError(r, w, http.StatusUnauthorized, err, requestLogger)
 				return
 			}
 
@@ -248,6 +482,7 @@ func (h *GraphQLHandler) configureRateLimiting(ctx *resolve.Context) *resolve.Co
 	if h.rateLimitConfig.Strategy != "simple" {
 		return ctx
 	}
+	key := h.rateLimitKey(ctx)
 	ctx.SetRateLimiter(h.rateLimiter)
 	ctx.RateLimitOptions = resolve.RateLimitOptions{
 		Enable:                          true,
@@ -255,17 +490,73 @@ func (h *GraphQLHandler) configureRateLimiting(ctx *resolve.Context) *resolve.Co
 		Rate:                            h.rateLimitConfig.SimpleStrategy.Rate,
 		Burst:                           h.rateLimitConfig.SimpleStrategy.Burst,
 		Period:                          h.rateLimitConfig.SimpleStrategy.Period,
-		RateLimitKey:                    h.rateLimitConfig.Storage.KeyPrefix,
+		RateLimitKey:                    key,
 		RejectExceedingRequests:         h.rateLimitConfig.SimpleStrategy.RejectExceedingRequests,
 	}
+	if tenant := tenantOf(ctx.Context()); tenant != nil && tenant.rateLimit != nil {
+		ctx.RateLimitOptions.Rate = tenant.rateLimit.rate
+		ctx.RateLimitOptions.Burst = tenant.rateLimit.burst
+		ctx.RateLimitOptions.Period = tenant.rateLimit.period
+	}
 	return WithRateLimiterStats(ctx)
 }
 
+// rateLimitKey returns the key of the quotas of the request
+func (h *GraphQLHandler) rateLimitKey(ctx *resolve.Context) string {
+	key := h.rateLimitConfig.Storage.KeyPrefix
+	if reqCtx := getRequestContext(ctx.Context()); reqCtx != nil && reqCtx.operation != nil {
+		key, _ = rateLimitKey(h.rateLimitConfig, reqCtx.operation.clientInfo, reqCtx.Authentication(), reqCtx.tags)
+	}
+	return key
+}
+
+// allowUsageQuotas rejects the request if a usage quota of the key is exhausted. The requests are allowed if the
+// quotas can't be checked, so that an outage of Redis doesn't take the graph down.
+func (h *GraphQLHandler) allowUsageQuotas(ctx *resolve.Context, key string, w http.ResponseWriter, r *http.Request, requestLogger *zap.Logger) bool {
+	err := h.usageQuotas.Allow(ctx.Context(), key)
+	if err == nil {
+		return true
+	}
+
+	var exceeded *UsageQuotaExceededError
+	if !errors.As(err, &exceeded) {
+		requestLogger.Error("failed to check the usage quotas", zap.Error(err))
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(time.Until(exceeded.ResetsAt).Seconds())), 10))
+	trackResponseError(ctx.Context(), err)
+	writeHTTPError(r, w, http.StatusTooManyRequests, err, requestLogger)
+	return false
+}
+
+// recordUsageQuotas consumes the fetches of the executed request. The fetches are counted by the rate limiter, so
+// that the limited fetches count as well.
+func (h *GraphQLHandler) recordUsageQuotas(ctx *resolve.Context, key string, r *http.Request, requestLogger *zap.Logger) {
+	if !h.usageQuotas.countsFetches() || h.rateLimiter == nil {
+		return
+	}
+	// The request may have been canceled, but its fetches were sent
+	fetches := h.rateLimiter.getRateLimitStats(ctx).RequestRate
+	if err := h.usageQuotas.Record(context.WithoutCancel(r.Context()), key, fetches); err != nil {
+		requestLogger.Error("failed to record the usage quotas", zap.Error(err))
+	}
+}
+
+// setRateLimitHeaders sets the RateLimit headers after the execution, when the fetches consumed the quota. The headers
+// of streamed responses may have been sent already.
+func (h *GraphQLHandler) setRateLimitHeaders(ctx *resolve.Context, w http.ResponseWriter) {
+	if h.rateLimiter == nil || h.rateLimitConfig == nil || !h.rateLimitConfig.ResponseHeaders {
+		return
+	}
+	h.rateLimiter.SetResponseHeaders(ctx, w.Header())
+}
+
 // WriteError writes the error to the response writer. This function must be concurrency-safe.
 // @TODO This function should be refactored to be a helper function for websocket and http error writing
 // In the websocket case, we call this function concurrently as part of the polling loop. This is error-prone.
 func (h *GraphQLHandler) WriteError(ctx *resolve.Context, err error, res *resolve.GraphQLResponse, w io.Writer, buf *bytes.Buffer) {
-	requestLogger := h.log.With(logging.WithRequestID(middleware.GetReqID(ctx.Context())))
+	requestLogger := logging.WithTraceContext(ctx.Context(), logging.EnrichFromOperationContext(ctx.Context(), h.log.With(logging.WithRequestID(middleware.GetReqID(ctx.Context())))))
 	httpWriter, isHttpResponseWriter := w.(http.ResponseWriter)
 	buf.Reset()
 	response := GraphQLErrorResponse{
@@ -275,6 +566,9 @@ func (h *GraphQLHandler) WriteError(ctx *resolve.Context, err error, res *resolv
 	switch getErrorType(err) {
 	case errorTypeRateLimit:
 		response.Errors[0].Message = "Rate limit exceeded"
+		response.Errors[0].Extensions = &Extensions{
+			Code: RateLimitExceededErrorCode,
+		}
 		err = h.rateLimiter.RenderResponseExtension(ctx, buf)
 		if err != nil {
 			requestLogger.Error("unable to render rate limit stats", zap.Error(err))
@@ -291,6 +585,9 @@ func (h *GraphQLHandler) WriteError(ctx *resolve.Context, err error, res *resolv
 		}
 	case errorTypeUnauthorized:
 		response.Errors[0].Message = "Unauthorized"
+		response.Errors[0].Extensions = &Extensions{
+			Code: UnauthorizedErrorCode,
+		}
 		if h.authorizer.HasResponseExtensionData(ctx) {
 			err = h.authorizer.RenderResponseExtension(ctx, buf)
 			if err != nil {
@@ -307,18 +604,53 @@ func (h *GraphQLHandler) WriteError(ctx *resolve.Context, err error, res *resolv
 		if isHttpResponseWriter {
 			httpWriter.WriteHeader(http.StatusOK) // Always return 200 OK when we return a well-formed response
 		}
+	case errorTypeOperationTimeout:
+		response.Errors[0].Message = "Operation timed out"
+		response.Errors[0].Extensions = &Extensions{
+			Code: OperationTimeoutErrorCode,
+		}
+		if isHttpResponseWriter {
+			httpWriter.WriteHeader(http.StatusGatewayTimeout)
+		}
+	case errorTypeResponseTooLarge:
+		response.Errors[0].Message = "Response exceeds the maximum size"
+		response.Errors[0].Extensions = &Extensions{
+			Code: ResponseTooLargeErrorCode,
+		}
+		if isHttpResponseWriter {
+			httpWriter.WriteHeader(http.StatusOK) // Always return 200 OK when we return a well-formed response
+		}
+	case errorTypeRequestMemoryLimit:
+		response.Errors[0].Message = "Request exceeds the memory limit"
+		response.Errors[0].Extensions = &Extensions{
+			Code: RequestMemoryLimitExceededErrorCode,
+		}
+		if isHttpResponseWriter {
+			httpWriter.WriteHeader(http.StatusOK) // Always return 200 OK when we return a well-formed response
+		}
 	case errorTypeContextCanceled:
 		response.Errors[0].Message = "Client disconnected"
+		if code := classifyError(ctx.Context(), err).code(); code != "" {
+			response.Errors[0].Extensions = &Extensions{
+				Code: code,
+			}
+		}
 		if isHttpResponseWriter {
 			httpWriter.WriteHeader(http.StatusRequestTimeout)
 		}
 	case errorTypeContextTimeout:
 		response.Errors[0].Message = "Server timeout"
+		response.Errors[0].Extensions = &Extensions{
+			Code: classifyError(ctx.Context(), err).code(),
+		}
 		if isHttpResponseWriter {
 			httpWriter.WriteHeader(http.StatusRequestTimeout)
 		}
 	case errorTypeUnknown:
 		response.Errors[0].Message = "Internal server error"
+		response.Errors[0].Extensions = &Extensions{
+			Code: InternalServerErrorCode,
+		}
 		if isHttpResponseWriter {
 			httpWriter.WriteHeader(http.StatusInternalServerError)
 		}
@@ -368,6 +700,18 @@ func (h *GraphQLHandler) WriteError(ctx *resolve.Context, err error, res *resolv
 	}
 }
 
+// trackOperationTimeout records an operation that exceeded its timeout in the metrics and the log. The logger must
+// have the fields of the operation, see logging.EnrichFromOperationContext.
+func (h *GraphQLHandler) trackOperationTimeout(ctx context.Context, operationCtx *operationContext, requestLogger *zap.Logger) {
+	if h.metricStore != nil {
+		// The execution context is already canceled and would drop the measurement
+		h.metricStore.MeasureOperationTimeout(context.WithoutCancel(ctx), operationTimeoutAttributes(operationCtx)...)
+	}
+	requestLogger.Warn("Operation exceeded its timeout",
+		zap.Duration("timeout", h.operationTimeouts.Timeout(operationCtx.Name(), operationCtx.Type())),
+	)
+}
+
 func (h *GraphQLHandler) setExecutionPlanCacheResponseHeader(w http.ResponseWriter, planCacheHit bool) {
 	if !h.enableExecutionPlanCacheResponseHeader {
 		return
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"fmt"
@@ -19,6 +20,8 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/wundergraph/cosmo/router/pkg/art"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/logging"
 	"github.com/wundergraph/cosmo/router/pkg/otel"
 	rtrace "github.com/wundergraph/cosmo/router/pkg/trace"
@@ -26,6 +29,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/wundergraph/cosmo/router/internal/pool"
 
@@ -33,22 +37,35 @@ import (
 )
 
 type PreHandlerOptions struct {
-	Logger                      *zap.Logger
-	Executor                    *Executor
-	Metrics                     RouterMetrics
-	OperationProcessor          *OperationProcessor
-	Planner                     *OperationPlanner
-	AccessController            *AccessController
-	OperationBlocker            *OperationBlocker
-	DevelopmentMode             bool
-	RouterPublicKey             *ecdsa.PublicKey
-	EnableRequestTracing        bool
-	TracerProvider              *sdktrace.TracerProvider
-	FlushTelemetryAfterResponse bool
-	TraceExportVariables        bool
-	FileUploadEnabled           bool
-	MaxUploadFiles              int
-	MaxUploadFileSize           int
+	Logger                       *zap.Logger
+	Executor                     *Executor
+	Metrics                      RouterMetrics
+	OperationProcessor           *OperationProcessor
+	Planner                      *OperationPlanner
+	AccessController             *AccessController
+	OperationBlocker             *OperationBlocker
+	IntrospectionGuard           *IntrospectionGuard
+	MaintenanceMode              *MaintenanceMode
+	PersistedOperationKillSwitch *PersistedOperationKillSwitch
+	DevelopmentMode              bool
+	RouterPublicKey              *ecdsa.PublicKey
+	EnableRequestTracing         bool
+	TracerProvider               *sdktrace.TracerProvider
+	FlushTelemetryAfterResponse  bool
+	TraceExportVariables         bool
+	VariableRedactor             *VariableRedactor
+	SealedVariables              *SealedVariables
+	FileUploadEnabled            bool
+	MaxUploadFiles               int
+	MaxUploadFileSize            int
+	LogEscalation                *config.LogEscalationConfiguration
+	LogEntryHandlers             []LogEntryHandler
+	SLOTracker                   *SLOTracker
+	AnomalyDetector              *AnomalyDetector
+	RequestTagger                *RequestTagger
+	ClientProtocols              *ClientProtocols
+	TenantOverrides              *TenantOverrides
+	ExecutionAudit               *ExecutionAudit
 }
 
 type PreHandler struct {
@@ -59,6 +76,9 @@ type PreHandler struct {
 	planner                     *OperationPlanner
 	accessController            *AccessController
 	operationBlocker            *OperationBlocker
+	introspectionGuard          *IntrospectionGuard
+	maintenanceMode             *MaintenanceMode
+	persistedOpKillSwitch       *PersistedOperationKillSwitch
 	developmentMode             bool
 	routerPublicKey             *ecdsa.PublicKey
 	enableRequestTracing        bool
@@ -66,12 +86,27 @@ type PreHandler struct {
 	flushTelemetryAfterResponse bool
 	tracer                      trace.Tracer
 	traceExportVariables        bool
+	variableRedactor            *VariableRedactor
+	sealedVariables             *SealedVariables
 	fileUploadEnabled           bool
 	maxUploadFiles              int
 	maxUploadFileSize           int
+	logEscalationBufferSize     int
+	logEntryHandlers            []LogEntryHandler
+	sloTracker                  *SLOTracker
+	anomalyDetector             *AnomalyDetector
+	requestTagger               *RequestTagger
+	clientProtocols             *ClientProtocols
+	tenantOverrides             *TenantOverrides
+	executionAudit              *ExecutionAudit
 }
 
 func NewPreHandler(opts *PreHandlerOptions) *PreHandler {
+	var logEscalationBufferSize int
+	if opts.LogEscalation != nil && opts.LogEscalation.Enabled {
+		logEscalationBufferSize = opts.LogEscalation.BufferSize
+	}
+
 	return &PreHandler{
 		log:                         opts.Logger,
 		executor:                    opts.Executor,
@@ -80,12 +115,17 @@ func NewPreHandler(opts *PreHandlerOptions) *PreHandler {
 		planner:                     opts.Planner,
 		accessController:            opts.AccessController,
 		operationBlocker:            opts.OperationBlocker,
+		introspectionGuard:          opts.IntrospectionGuard,
+		maintenanceMode:             opts.MaintenanceMode,
+		persistedOpKillSwitch:       opts.PersistedOperationKillSwitch,
 		routerPublicKey:             opts.RouterPublicKey,
 		developmentMode:             opts.DevelopmentMode,
 		enableRequestTracing:        opts.EnableRequestTracing,
 		flushTelemetryAfterResponse: opts.FlushTelemetryAfterResponse,
 		tracerProvider:              opts.TracerProvider,
 		traceExportVariables:        opts.TraceExportVariables,
+		variableRedactor:            opts.VariableRedactor,
+		sealedVariables:             opts.SealedVariables,
 		tracer: opts.TracerProvider.Tracer(
 			"wundergraph/cosmo/router/pre_handler",
 			trace.WithInstrumentationVersion("0.0.1"),
@@ -93,6 +133,15 @@ func NewPreHandler(opts *PreHandlerOptions) *PreHandler {
 		fileUploadEnabled: opts.FileUploadEnabled,
 		maxUploadFiles:    opts.MaxUploadFiles,
 		maxUploadFileSize: opts.MaxUploadFileSize,
+
+		logEscalationBufferSize: logEscalationBufferSize,
+		logEntryHandlers:        opts.LogEntryHandlers,
+		sloTracker:              opts.SLOTracker,
+		anomalyDetector:         opts.AnomalyDetector,
+		requestTagger:           opts.RequestTagger,
+		clientProtocols:         opts.ClientProtocols,
+		tenantOverrides:         opts.TenantOverrides,
+		executionAudit:          opts.ExecutionAudit,
 	}
 }
 
@@ -108,7 +157,16 @@ func NewPreHandler(opts *PreHandlerOptions) *PreHandler {
 
 func (h *PreHandler) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestLogger := h.log.With(logging.WithRequestID(middleware.GetReqID(r.Context())))
+		requestLogger := h.log
+		// Requests that are replayed through the admin API collect all their log entries
+		replay := getAdminReplay(r.Context())
+		if replay != nil {
+			requestLogger = replay.logger(requestLogger)
+		}
+		requestID := logging.WithRequestID(middleware.GetReqID(r.Context()))
+		requestLogger = requestLogger.With(requestID)
+		// A panic of the request is logged with its request ID and operation name
+		logging.AddRecoveryFields(r.Context(), requestID)
 
 		var (
 			// In GraphQL the statusCode does not always express the error state of the request
@@ -119,6 +177,35 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 			traceOptions = resolve.TraceOptions{}
 		)
 
+		// The context is also created for the operations of the access log
+		logEntryCtx := getLogEntryContext(r.Context())
+		if len(h.logEntryHandlers) > 0 {
+			if logEntryCtx == nil {
+				_, logEntryCtx = withLogEntryContext(r.Context())
+			}
+			requestLogger = requestLogger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+				return newLogEntryCore(core, h.logEntryHandlers, logEntryCtx)
+			}))
+		}
+
+		if h.logEscalationBufferSize > 0 && !h.log.Core().Enabled(zapcore.DebugLevel) {
+			escalationBuffer := logging.NewEscalationBuffer(h.logEscalationBufferSize, zapcore.DebugLevel)
+			requestLogger = requestLogger.WithOptions(logging.WithEscalationBuffer(escalationBuffer))
+
+			defer func() {
+				if finalErr == nil && statusCode < http.StatusInternalServerError {
+					return
+				}
+				if err := escalationBuffer.Escalate(); err != nil {
+					h.log.Error("Failed to write buffered log entries of failed request", zap.Error(err))
+				}
+			}()
+		}
+
+		// Handlers and modules get the logger of the request from the context with the fields of their active span
+		r = r.WithContext(logging.NewContext(r.Context(), requestLogger))
+		requestLogger = logging.WithTraceContext(r.Context(), requestLogger)
+
 		routerSpan := trace.SpanFromContext(r.Context())
 
 		clientInfo := NewClientInfoFromRequest(r)
@@ -128,7 +215,10 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 			otel.WgOperationProtocol.String(OperationProtocolHTTP.String()),
 		}
 
-		if h.enableRequestTracing {
+		if replay != nil {
+			// The admin API is authenticated on its own, the replayed requests are always traced
+			traceOptions = ParseRequestTraceOptions(r)
+		} else if h.enableRequestTracing {
 			if clientInfo.WGRequestToken != "" && h.routerPublicKey != nil {
 				_, err := jwt.Parse(clientInfo.WGRequestToken, func(token *jwt.Token) (interface{}, error) {
 					return h.routerPublicKey, nil
@@ -137,7 +227,7 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 					err := errors.New("invalid request token. Router version 0.42.1 or above is required to use request tracing in production")
 					finalErr = err
 					requestLogger.Error(fmt.Sprintf("failed to parse request token: %s", err.Error()))
-					writeRequestErrors(r, w, http.StatusForbidden, graphqlerrors.RequestErrorsFromError(err), requestLogger)
+					writeHTTPError(r, w, http.StatusForbidden, err, requestLogger)
 					return
 				}
 
@@ -172,14 +262,51 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 		}
 
 		defer func() {
-			metrics.Finish(finalErr, statusCode, writtenBytes)
+			class := classifyError(r.Context(), finalErr)
+			if class != errorClassNone {
+				routerSpan.SetAttributes(otel.WgRequestErrorClass.String(string(class)))
+				if logEntryCtx != nil {
+					logEntryCtx.errorClass = class
+				}
+			}
+			if code := errorCodeOf(r.Context(), finalErr); code != "" {
+				routerSpan.SetAttributes(otel.WgRequestErrorCode.String(code))
+				metrics.AddAttributes(otel.WgRequestErrorCode.String(code))
+				if logEntryCtx != nil {
+					logEntryCtx.errorCode = code
+				}
+			}
+			metrics.Finish(finalErr, class, statusCode, writtenBytes)
 		}()
 
+		// The record is written with the context of the last request, which has the authentication of the client
+		audit := h.executionAudit.start()
+		if audit != nil {
+			defer func() {
+				h.executionAudit.write(r.Context(), audit, clientInfo, statusCode, finalErr)
+			}()
+		}
+
+		if h.sloTracker != nil {
+			start := time.Now()
+			defer func() {
+				h.sloTracker.Record(commonAttributes, clientInfo.Name, sloRequestFailed(finalErr, statusCode), time.Since(start))
+			}()
+		}
+
+		if h.anomalyDetector != nil {
+			defer func() {
+				if statusCode >= http.StatusInternalServerError {
+					h.anomalyDetector.Observe(AnomalySignalServerErrors)
+				}
+			}()
+		}
+
 		var body []byte
 		var files []httpclient.File
 		// XXX: This buffer needs to be returned to the pool only
 		// AFTER we're done with body (retrieved from parser.ReadBody())
-		buf := pool.GetBytesBuffer()
+		buf := pool.GetBytesBufferWithSize(r.ContentLength + bytes.MinRead)
 		defer pool.PutBytesBuffer(buf)
 
 		if strings.Contains(r.Header.Get("Content-Type"), "multipart/form-data") {
@@ -281,7 +408,37 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 			traceTimings.EndParse()
 		}
 
-		if blockedErr := h.operationBlocker.OperationIsBlocked(operationKit.parsedOperation); blockedErr != nil {
+		audit.operation(operationKit.parsedOperation.Request.OperationName, operationKit.parsedOperation.Type)
+
+		if h.maintenanceMode != nil {
+			audit.policy(ExecutionAuditPolicyMaintenanceMode)
+		}
+		if h.maintenanceMode != nil && h.maintenanceMode.AppliesTo(operationKit.parsedOperation.Request.OperationName, operationKit.parsedOperation.Type) {
+			finalErr = ErrMaintenanceMode
+			statusCode = h.maintenanceMode.statusCode
+
+			rtrace.AttachErrToSpan(routerSpan, finalErr)
+
+			h.maintenanceMode.WriteResponse(r, w, requestLogger)
+			return
+		}
+
+		if operationKit.parsedOperation.IsPersistedOperation && h.persistedOpKillSwitch != nil {
+			audit.policy(ExecutionAuditPolicyKillSwitch)
+		}
+		if operationKit.parsedOperation.IsPersistedOperation && h.persistedOpKillSwitch != nil &&
+			h.persistedOpKillSwitch.Blocked(operationKit.parsedOperation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash) {
+			finalErr = ErrPersistedOperationKilled
+			statusCode = h.persistedOpKillSwitch.statusCode
+
+			rtrace.AttachErrToSpan(routerSpan, finalErr)
+
+			h.persistedOpKillSwitch.WriteResponse(r, w, requestLogger)
+			return
+		}
+
+		audit.policy(ExecutionAuditPolicyOperationBlocker)
+		if blockedErr := h.operationBlocker.OperationIsBlocked(operationKit.parsedOperation, clientInfo); blockedErr != nil {
 			// Mark the root span of the router as failed, so we can easily identify failed requests
 			rtrace.AttachErrToSpan(routerSpan, blockedErr)
 
@@ -289,6 +446,11 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 			return
 		}
 
+		logging.AddRecoveryFields(r.Context(),
+			zap.String("operation_name", operationKit.parsedOperation.Request.OperationName),
+			zap.String("operation_type", operationKit.parsedOperation.Type),
+		)
+
 		// Set the router span name after we have the operation name
 		routerSpan.SetName(GetSpanName(operationKit.parsedOperation.Request.OperationName, operationKit.parsedOperation.Type))
 
@@ -344,11 +506,24 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 
 		if h.traceExportVariables {
 			// At this stage the variables are normalized
-			routerSpan.SetAttributes(otel.WgOperationVariables.String(string(operationKit.parsedOperation.Request.Variables)))
+			variables := h.variableRedactor.redact(operationKit.parsedOperation.Request.OperationName, operationKit.parsedOperation.Request.Variables)
+			routerSpan.SetAttributes(otel.WgOperationVariables.String(string(variables)))
+		}
+
+		// The variables are unsealed after they were added to the trace
+		err = operationKit.UnsealVariables(h.sealedVariables)
+		if err != nil {
+			finalErr = err
+
+			// Mark the root span of the router as failed, so we can easily identify failed requests
+			rtrace.AttachErrToSpan(routerSpan, err)
+
+			writeOperationError(r, w, requestLogger, err)
+			return
 		}
 
 		attributes = []attribute.KeyValue{
-			otel.WgOperationHash.String(strconv.FormatUint(operationKit.parsedOperation.ID, 10)),
+			otel.WgOperationHash.String(strconv.FormatUint(operationKit.parsedOperation.Fingerprint, 10)),
 		}
 
 		// Set the normalized operation as soon as we have it
@@ -357,6 +532,10 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 
 		metrics.AddAttributes(attributes...)
 
+		// The entries of the following phases have the metadata of the normalized operation
+		r = r.WithContext(logging.WithOperation(r.Context(), loggingOperation(operationKit.parsedOperation, clientInfo)))
+		requestLogger = logging.EnrichFromOperationContext(r.Context(), requestLogger)
+
 		/**
 		* Validate the operation
 		 */
@@ -417,21 +596,33 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 
 			enginePlanSpan.End()
 
-			requestLogger.Error("failed to plan operation", zap.Error(err))
+			// Blocked fields are rejected on purpose and logged in the debug level
+			var fieldBlockedErr *FieldBlockedError
+			if !errors.As(err, &fieldBlockedErr) {
+				requestLogger.Error("failed to plan operation", zap.Error(err))
+			}
 			writeOperationError(r, w, requestLogger, err)
 			return
 		}
 
 		enginePlanSpan.SetAttributes(otel.WgEnginePlanCacheHit.Bool(opContext.planCacheHit))
+		audit.plan(opContext)
 
 		enginePlanSpan.End()
 
+		requestLogger.Debug("Operation planned",
+			zap.Uint64("operation_hash", opContext.fingerprint),
+			zap.Bool("plan_cache_hit", opContext.planCacheHit),
+		)
+
 		if !traceOptions.ExcludePlannerStats {
 			traceTimings.EndPlanning()
 		}
 
 		// If we have authenticators, we try to authenticate the request
 		if len(h.accessController.authenticators) > 0 {
+			audit.authenticate()
+
 			_, authenticateSpan := h.tracer.Start(r.Context(), "Authenticate",
 				trace.WithSpanKind(trace.SpanKindServer),
 				trace.WithAttributes(commonAttributes...),
@@ -448,7 +639,7 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 
 				authenticateSpan.End()
 
-				writeRequestErrors(r, w, http.StatusUnauthorized, graphqlerrors.RequestErrorsFromError(err), requestLogger)
+				writeHTTPError(r, w, http.StatusUnauthorized, err, requestLogger)
 				return
 			}
 
@@ -457,6 +648,68 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 			r = validatedReq
 		}
 
+		// Evaluated after the authentication, so that the tags can be computed from the claims
+		tags := h.requestTagger.Tags(r, opContext, clientInfo)
+		if len(tags) > 0 {
+			tagAttributes := tags.attributes()
+			routerSpan.SetAttributes(tagAttributes...)
+			metrics.AddAttributes(tagAttributes...)
+			requestLogger = requestLogger.With(zap.Object("tags", tags))
+		}
+
+		// Identified after the authentication, so that the tenant can be taken from the claims
+		var tenant *tenantOverride
+		if h.tenantOverrides != nil {
+			var tenantID string
+			tenantID, tenant = h.tenantOverrides.tenantOf(r)
+			if tenantID != "" {
+				requestLogger = requestLogger.With(zap.String("tenant", tenantID))
+			}
+			if tenant != nil {
+				audit.policy(ExecutionAuditPolicyTenantOverride)
+			}
+		}
+
+		if h.clientProtocols != nil {
+			protocol := httpClientProtocol(r, operationKit.parsedOperation, opContext.Type())
+			h.clientProtocols.Record(protocol, clientInfo.Name)
+			if logEntryCtx != nil {
+				logEntryCtx.clientProtocol = protocol
+			}
+		}
+
+		if kind := operationKit.parsedOperation.IntrospectionKind; kind != "" && h.introspectionGuard != nil {
+			// Checked after the authentication, so that introspection can be allowed for authenticated requests only
+			blockedErr := h.introspectionGuard.IntrospectionIsBlocked(clientInfo, authentication.FromContext(r.Context()) != nil)
+			audit.policy(ExecutionAuditPolicyIntrospectionGuard)
+
+			introspectionAttributes := []attribute.KeyValue{
+				otel.WgIntrospectionKind.String(kind),
+				otel.WgIntrospectionBlocked.Bool(blockedErr != nil),
+			}
+			routerSpan.SetAttributes(introspectionAttributes...)
+
+			metricAttributes := make([]attribute.KeyValue, 0, len(commonAttributes)+4)
+			metricAttributes = append(metricAttributes, commonAttributes...)
+			metricAttributes = append(metricAttributes, otel.WgClientName.String(clientInfo.Name), otel.WgClientVersion.String(clientInfo.Version))
+			metricAttributes = append(metricAttributes, introspectionAttributes...)
+			h.metrics.MetricStore().MeasureIntrospectionRequest(r.Context(), metricAttributes...)
+			requestLogger.Info("Introspection query",
+				zap.String("client_name", clientInfo.Name),
+				zap.String("client_version", clientInfo.Version),
+				zap.String("kind", kind),
+				zap.Bool("blocked", blockedErr != nil),
+			)
+
+			if blockedErr != nil {
+				finalErr = blockedErr
+				rtrace.AttachErrToSpan(routerSpan, blockedErr)
+
+				writeRequestErrors(r, w, http.StatusOK, graphqlerrors.RequestErrorsFromError(blockedErr), requestLogger)
+				return
+			}
+		}
+
 		// Free the operation kit after we're done with it
 		// We don't need to hold onto it while processing the operation
 		operationKit.Free()
@@ -465,6 +718,12 @@ func (h *PreHandler) Handler(next http.Handler) http.Handler {
 		art.SetRequestTracingStats(r.Context(), traceOptions, traceTimings)
 
 		requestContext := buildRequestContext(w, r, opContext, requestLogger)
+		requestContext.tags = tags
+		requestContext.tenant = tenant
+		requestContext.executionAudit = audit
+		if logEntryCtx != nil {
+			logEntryCtx.requestContext = requestContext
+		}
 		metrics.AddOperationContext(opContext)
 
 		ctxWithRequest := withRequestContext(r.Context(), requestContext)
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"errors"
+	"slices"
+)
+
+const (
+	// IntrospectionKindSchema is a full introspection of the schema with __schema
+	IntrospectionKindSchema = "schema"
+	// IntrospectionKindType probes single types with __type
+	IntrospectionKindType = "type"
+)
+
+var (
+	ErrIntrospectionUnauthenticated = errors.New("introspection is not allowed for unauthenticated requests")
+	ErrIntrospectionUnknownClient   = errors.New("introspection is not allowed for unknown clients")
+)
+
+type IntrospectionGuardOptions struct {
+	BlockUnauthenticated bool
+	BlockUnknownClients  bool
+	// KnownClients are the client names allowed to introspect with BlockUnknownClients. When empty, only requests
+	// without a client name are unknown.
+	KnownClients []string
+}
+
+// IntrospectionGuard decides whether an introspection query of a client is blocked. Introspection is always
+// allowed for authenticated, known clients, so that tools like the playground keep working.
+type IntrospectionGuard struct {
+	blockUnauthenticated bool
+	blockUnknownClients  bool
+	knownClients         []string
+}
+
+func NewIntrospectionGuard(opts *IntrospectionGuardOptions) *IntrospectionGuard {
+	return &IntrospectionGuard{
+		blockUnauthenticated: opts.BlockUnauthenticated,
+		blockUnknownClients:  opts.BlockUnknownClients,
+		knownClients:         opts.KnownClients,
+	}
+}
+
+func (g *IntrospectionGuard) IntrospectionIsBlocked(clientInfo *ClientInfo, authenticated bool) error {
+	if g.blockUnauthenticated && !authenticated {
+		return ErrIntrospectionUnauthenticated
+	}
+	if g.blockUnknownClients && !g.isKnownClient(clientInfo.Name) {
+		return ErrIntrospectionUnknownClient
+	}
+	return nil
+}
+
+func (g *IntrospectionGuard) isKnownClient(name string) bool {
+	if len(g.knownClients) == 0 {
+		// The client name defaults to "unknown" when the request has no client name header
+		return name != "" && name != "unknown"
+	}
+	return slices.Contains(g.knownClients, name)
+}
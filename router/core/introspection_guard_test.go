@@ -0,0 +1,30 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospectionGuard(t *testing.T) {
+	t.Parallel()
+
+	unknown := &ClientInfo{Name: "unknown"}
+	studio := &ClientInfo{Name: "studio"}
+	other := &ClientInfo{Name: "other"}
+
+	g := NewIntrospectionGuard(&IntrospectionGuardOptions{})
+	require.NoError(t, g.IntrospectionIsBlocked(unknown, false))
+
+	g = NewIntrospectionGuard(&IntrospectionGuardOptions{BlockUnauthenticated: true})
+	require.ErrorIs(t, g.IntrospectionIsBlocked(studio, false), ErrIntrospectionUnauthenticated)
+	require.NoError(t, g.IntrospectionIsBlocked(unknown, true))
+
+	g = NewIntrospectionGuard(&IntrospectionGuardOptions{BlockUnknownClients: true})
+	require.ErrorIs(t, g.IntrospectionIsBlocked(unknown, true), ErrIntrospectionUnknownClient)
+	require.NoError(t, g.IntrospectionIsBlocked(other, false))
+
+	g = NewIntrospectionGuard(&IntrospectionGuardOptions{BlockUnknownClients: true, KnownClients: []string{"studio"}})
+	require.ErrorIs(t, g.IntrospectionIsBlocked(other, true), ErrIntrospectionUnknownClient)
+	require.NoError(t, g.IntrospectionIsBlocked(studio, false))
+}
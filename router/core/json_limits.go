@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type JSONLimit string
+
+const (
+	JSONLimitDepth        JSONLimit = "depth"
+	JSONLimitKeys         JSONLimit = "keys"
+	JSONLimitStringLength JSONLimit = "string_length"
+)
+
+// JSONLimits restricts the structure of JSON request bodies to protect the router against pathological payloads.
+// A value of zero disables the limit.
+type JSONLimits struct {
+	MaxDepth        int
+	MaxKeys         int
+	MaxStringLength int
+}
+
+// JSONLimitError is returned when a request body exceeds one of the JSON limits
+type JSONLimitError struct {
+	Limit JSONLimit
+	Max   int
+}
+
+var _ InputError = (*JSONLimitError)(nil)
+
+func (e *JSONLimitError) Error() string {
+	switch e.Limit {
+	case JSONLimitDepth:
+		return fmt.Sprintf("request body exceeds the maximum JSON nesting depth of %d", e.Max)
+	case JSONLimitKeys:
+		return fmt.Sprintf("request body exceeds the maximum number of %d JSON object keys", e.Max)
+	case JSONLimitStringLength:
+		return fmt.Sprintf("request body contains a JSON string longer than %d bytes", e.Max)
+	default:
+		return fmt.Sprintf("request body exceeds the JSON %s limit of %d", e.Limit, e.Max)
+	}
+}
+
+func (e *JSONLimitError) Message() string {
+	return e.Error()
+}
+
+func (e *JSONLimitError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (l *JSONLimits) enabled() bool {
+	return l.MaxDepth > 0 || l.MaxKeys > 0 || l.MaxStringLength > 0
+}
+
+// validate scans the document once without decoding it. Invalid JSON is not reported here
+// because it is rejected by the parser afterward with a more precise error.
+func (l *JSONLimits) validate(data []byte) error {
+	depth, keys := 0, 0
+
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '{', '[':
+			depth++
+			if l.MaxDepth > 0 && depth > l.MaxDepth {
+				return &JSONLimitError{Limit: JSONLimitDepth, Max: l.MaxDepth}
+			}
+		case '}', ']':
+			depth--
+		case ':':
+			// Outside of strings, every colon separates a key from its value
+			keys++
+			if l.MaxKeys > 0 && keys > l.MaxKeys {
+				return &JSONLimitError{Limit: JSONLimitKeys, Max: l.MaxKeys}
+			}
+		case '"':
+			start := i + 1
+			for i = start; i < len(data); i++ {
+				if data[i] == '\\' {
+					i++
+					continue
+				}
+				if data[i] == '"' {
+					break
+				}
+			}
+			if l.MaxStringLength > 0 && i-start > l.MaxStringLength {
+				return &JSONLimitError{Limit: JSONLimitStringLength, Max: l.MaxStringLength}
+			}
+		}
+	}
+
+	return nil
+}
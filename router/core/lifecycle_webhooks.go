@@ -0,0 +1,213 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type LifecycleEventType string
+
+const (
+	LifecycleEventStartup           LifecycleEventType = "startup"
+	LifecycleEventShutdown          LifecycleEventType = "shutdown"
+	LifecycleEventConfigReload      LifecycleEventType = "config_reload"
+	LifecycleEventConfigFetchFailed LifecycleEventType = "config_fetch_failed"
+)
+
+type LifecycleWebhookFormat string
+
+const (
+	LifecycleWebhookFormatJSON LifecycleWebhookFormat = "json"
+	// LifecycleWebhookFormatSlack sends the payload of Slack incoming webhooks, which is also understood by
+	// Mattermost, Rocket.Chat and others
+	LifecycleWebhookFormatSlack LifecycleWebhookFormat = "slack"
+)
+
+// LifecycleEvent is the payload of the webhooks in the JSON format
+type LifecycleEvent struct {
+	Type          LifecycleEventType `json:"type"`
+	Message       string             `json:"message"`
+	Timestamp     time.Time          `json:"timestamp"`
+	InstanceID    string             `json:"instance_id"`
+	ClusterName   string             `json:"cluster_name,omitempty"`
+	ConfigVersion string             `json:"config_version,omitempty"`
+	Error         string             `json:"error,omitempty"`
+}
+
+// LifecycleHook is called synchronously on the lifecycle events of the router, e.g. by an application that embeds the
+// router. It must return quickly, because it delays the router.
+type LifecycleHook func(event LifecycleEvent)
+
+func newLifecycleEvent(eventType LifecycleEventType, message, configVersion, instanceID, clusterName string, err error) LifecycleEvent {
+	event := LifecycleEvent{
+		Type:          eventType,
+		Message:       message,
+		Timestamp:     time.Now(),
+		InstanceID:    instanceID,
+		ClusterName:   clusterName,
+		ConfigVersion: configVersion,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+type LifecycleWebhookEndpoint struct {
+	URL    string
+	Format LifecycleWebhookFormat
+	// Events are the types of events sent to the endpoint. Empty sends all events.
+	Events  []LifecycleEventType
+	Headers map[string]string
+}
+
+type LifecycleNotifierOptions struct {
+	Logger      *zap.Logger
+	Endpoints   []LifecycleWebhookEndpoint
+	Timeout     time.Duration
+	InstanceID  string
+	ClusterName string
+}
+
+// LifecycleNotifier sends webhooks on state changes of the router, like the startup or a config reload.
+// The webhooks are sent in the background, failures are logged and not retried.
+type LifecycleNotifier struct {
+	logger      *zap.Logger
+	endpoints   []LifecycleWebhookEndpoint
+	httpClient  *http.Client
+	instanceID  string
+	clusterName string
+
+	wg sync.WaitGroup
+}
+
+func NewLifecycleNotifier(opts *LifecycleNotifierOptions) (*LifecycleNotifier, error) {
+	for i, endpoint := range opts.Endpoints {
+		if endpoint.URL == "" {
+			return nil, fmt.Errorf("lifecycle webhook %d requires a url", i)
+		}
+		switch endpoint.Format {
+		case "", LifecycleWebhookFormatJSON, LifecycleWebhookFormatSlack:
+		default:
+			return nil, fmt.Errorf("lifecycle webhook %d has an unknown format %q", i, endpoint.Format)
+		}
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &LifecycleNotifier{
+		logger:      opts.Logger,
+		endpoints:   opts.Endpoints,
+		httpClient:  &http.Client{Timeout: timeout},
+		instanceID:  opts.InstanceID,
+		clusterName: opts.ClusterName,
+	}, nil
+}
+
+// Notify sends the event to all endpoints that subscribed to its type
+func (n *LifecycleNotifier) Notify(eventType LifecycleEventType, message, configVersion string, err error) {
+	event := newLifecycleEvent(eventType, message, configVersion, n.instanceID, n.clusterName, err)
+
+	for _, endpoint := range n.endpoints {
+		if len(endpoint.Events) > 0 && !slices.Contains(endpoint.Events, eventType) {
+			continue
+		}
+
+		n.wg.Add(1)
+		go func(endpoint LifecycleWebhookEndpoint) {
+			defer n.wg.Done()
+
+			if err := n.send(endpoint, event); err != nil {
+				n.logger.Warn("Failed to send lifecycle webhook",
+					zap.String("event", string(eventType)),
+					zap.String("url", endpoint.URL),
+					zap.Error(err),
+				)
+			}
+		}(endpoint)
+	}
+}
+
+// Wait blocks until all webhooks are sent or the context is done
+func (n *LifecycleNotifier) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *LifecycleNotifier) send(endpoint LifecycleWebhookEndpoint, event LifecycleEvent) error {
+	var payload any = event
+	if endpoint.Format == LifecycleWebhookFormatSlack {
+		payload = struct {
+			Text string `json:"text"`
+		}{Text: slackText(event)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range endpoint.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func slackText(event LifecycleEvent) string {
+	var sb strings.Builder
+	sb.WriteString("*")
+	sb.WriteString(event.Message)
+	sb.WriteString("*\nInstance: ")
+	sb.WriteString(event.InstanceID)
+	if event.ClusterName != "" {
+		sb.WriteString("\nCluster: ")
+		sb.WriteString(event.ClusterName)
+	}
+	if event.ConfigVersion != "" {
+		sb.WriteString("\nConfig version: ")
+		sb.WriteString(event.ConfigVersion)
+	}
+	if event.Error != "" {
+		sb.WriteString("\nError: ")
+		sb.WriteString(event.Error)
+	}
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type webhookRecorder struct {
+	mu       sync.Mutex
+	payloads []map[string]any
+	headers  []http.Header
+}
+
+func (w *webhookRecorder) handler(rw http.ResponseWriter, r *http.Request) {
+	var payload map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+
+	w.mu.Lock()
+	w.payloads = append(w.payloads, payload)
+	w.headers = append(w.headers, r.Header.Clone())
+	w.mu.Unlock()
+}
+
+func TestLifecycleNotifier(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates the endpoints", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewLifecycleNotifier(&LifecycleNotifierOptions{Logger: zap.NewNop(), Endpoints: []LifecycleWebhookEndpoint{{}}})
+		require.Error(t, err)
+		_, err = NewLifecycleNotifier(&LifecycleNotifierOptions{Logger: zap.NewNop(), Endpoints: []LifecycleWebhookEndpoint{{URL: "http://localhost", Format: "xml"}}})
+		require.Error(t, err)
+	})
+
+	t.Run("sends the events the endpoints subscribed to", func(t *testing.T) {
+		t.Parallel()
+
+		all, startup := &webhookRecorder{}, &webhookRecorder{}
+		allServer := httptest.NewServer(http.HandlerFunc(all.handler))
+		t.Cleanup(allServer.Close)
+		startupServer := httptest.NewServer(http.HandlerFunc(startup.handler))
+		t.Cleanup(startupServer.Close)
+
+		n, err := NewLifecycleNotifier(&LifecycleNotifierOptions{
+			Logger: zap.NewNop(),
+			Endpoints: []LifecycleWebhookEndpoint{
+				{URL: allServer.URL, Headers: map[string]string{"Authorization": "Bearer token"}},
+				{URL: startupServer.URL, Format: LifecycleWebhookFormatSlack, Events: []LifecycleEventType{LifecycleEventStartup}},
+			},
+			InstanceID:  "instance",
+			ClusterName: "cluster",
+		})
+		require.NoError(t, err)
+
+		n.Notify(LifecycleEventStartup, "Router started", "v1", nil)
+		require.NoError(t, n.Wait(context.Background()))
+		n.Notify(LifecycleEventConfigFetchFailed, "Failed to fetch a router config update", "", errors.New("cdn unavailable"))
+		require.NoError(t, n.Wait(context.Background()))
+
+		require.Len(t, all.payloads, 2)
+		require.Equal(t, "startup", all.payloads[0]["type"])
+		require.Equal(t, "instance", all.payloads[0]["instance_id"])
+		require.Equal(t, "v1", all.payloads[0]["config_version"])
+		require.Equal(t, "config_fetch_failed", all.payloads[1]["type"])
+		require.Equal(t, "cdn unavailable", all.payloads[1]["error"])
+		require.Equal(t, "Bearer token", all.headers[0].Get("Authorization"))
+
+		require.Len(t, startup.payloads, 1)
+		require.Equal(t, "*Router started*\nInstance: instance\nCluster: cluster\nConfig version: v1", startup.payloads[0]["text"])
+	})
+
+	t.Run("wait returns when the context is done", func(t *testing.T) {
+		t.Parallel()
+
+		blocked := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-blocked
+		}))
+		t.Cleanup(func() {
+			close(blocked)
+			server.Close()
+		})
+
+		n, err := NewLifecycleNotifier(&LifecycleNotifierOptions{
+			Logger:    zap.NewNop(),
+			Endpoints: []LifecycleWebhookEndpoint{{URL: server.URL}},
+		})
+		require.NoError(t, err)
+
+		n.Notify(LifecycleEventShutdown, "Router shutting down", "", nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, n.Wait(ctx), context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// LogDropCounter is an output of the logs that drops entries instead of stalling the router, like the asynchronous
+// output or the Kafka and NATS sinks of the logging package
+type LogDropCounter interface {
+	// Dropped returns the total number of dropped entries
+	Dropped() int64
+}
+
+// registerLogDropMetrics exposes the dropped entries of the outputs by their name, so that lost log lines can be
+// alerted on
+func registerLogDropMetrics(meterProvider *sdkmetric.MeterProvider, outputs map[string]LogDropCounter) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	dropped, err := meter.Int64ObservableCounter(
+		"router.logs.dropped",
+		otelmetric.WithDescription("Number of log entries that were dropped because the output was full or unavailable"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		for output, counter := range outputs {
+			o.ObserveInt64(dropped, counter.Dropped(), otelmetric.WithAttributes(attribute.String("output", output)))
+		}
+		return nil
+	}, dropped)
+
+	return err
+}
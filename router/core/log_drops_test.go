@@ -0,0 +1,38 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type staticDropCounter int64
+
+func (c staticDropCounter) Dropped() int64 {
+	return int64(c)
+}
+
+func TestRegisterLogDropMetrics(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	require.NoError(t, registerLogDropMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), map[string]LogDropCounter{
+		"stdout": staticDropCounter(3),
+	}))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+
+	metric := rm.ScopeMetrics[0].Metrics[0]
+	require.Equal(t, "router.logs.dropped", metric.Name)
+	dataPoints := metric.Data.(metricdata.Sum[int64]).DataPoints
+	require.Len(t, dataPoints, 1)
+	require.Equal(t, int64(3), dataPoints[0].Value)
+	output, _ := dataPoints[0].Attributes.Value("output")
+	require.Equal(t, "stdout", output.AsString())
+}
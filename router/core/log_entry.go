@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const logEntryContextKey = key("logEntry")
+
+// logEntryContext makes the request context available to the access log, which is written by a middleware
+// that runs before the request context is created
+type logEntryContext struct {
+	requestContext *requestContext
+	// samplingExempt is set when the access log entry is exempted from the sampling
+	samplingExempt bool
+	// errorClass is set when the request was canceled by the client or timed out
+	errorClass errorClass
+	// errorCode is the registered code of the error of the request, see ErrorCodes
+	errorCode string
+	// clientProtocol is set when the protocols of the clients are counted
+	clientProtocol ClientProtocol
+}
+
+func withLogEntryContext(ctx context.Context) (context.Context, *logEntryContext) {
+	lc := &logEntryContext{}
+	return context.WithValue(ctx, logEntryContextKey, lc), lc
+}
+
+func getLogEntryContext(ctx context.Context) *logEntryContext {
+	lc, _ := ctx.Value(logEntryContextKey).(*logEntryContext)
+	return lc
+}
+
+// RequestContext returns nil until the request context is created
+func (lc *logEntryContext) RequestContext() RequestContext {
+	if lc.requestContext == nil {
+		return nil
+	}
+	return lc.requestContext
+}
+
+// handleLogEntry calls the handlers sequentially and returns the fields to add to the entry
+func handleLogEntry(handlers []LogEntryHandler, ctx RequestContext, entry *LogEntry) ([]zap.Field, bool) {
+	var fields []zap.Field
+	for _, handler := range handlers {
+		extra, drop := handler.OnLogEntry(ctx, entry)
+		if drop {
+			return nil, true
+		}
+		fields = append(fields, extra...)
+	}
+	return fields, false
+}
+
+// logEntryCore calls the LogEntryHandler of the modules for every application log entry of a request
+type logEntryCore struct {
+	zapcore.Core
+	handlers []LogEntryHandler
+	lc       *logEntryContext
+	// fields are the fields added with With. They are passed to the handlers together with the fields of the entry.
+	fields []zapcore.Field
+}
+
+func newLogEntryCore(core zapcore.Core, handlers []LogEntryHandler, lc *logEntryContext) zapcore.Core {
+	return &logEntryCore{Core: core, handlers: handlers, lc: lc}
+}
+
+func (c *logEntryCore) With(fields []zapcore.Field) zapcore.Core {
+	return &logEntryCore{
+		Core:     c.Core.With(fields),
+		handlers: c.handlers,
+		lc:       c.lc,
+		fields:   append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *logEntryCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *logEntryCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+
+	extra, drop := handleLogEntry(c.handlers, c.lc.RequestContext(), &LogEntry{
+		Level:   ent.Level,
+		Message: ent.Message,
+		Fields:  all,
+	})
+	if drop {
+		return nil
+	}
+
+	return c.Core.Write(ent, append(fields, extra...))
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type tenantLogEntryHandler struct{}
+
+func (tenantLogEntryHandler) OnLogEntry(ctx RequestContext, entry *LogEntry) ([]zap.Field, bool) {
+	if entry.Message == "noisy" {
+		return nil, true
+	}
+	if ctx == nil {
+		return []zap.Field{zap.Bool("no_context", true)}, false
+	}
+	tenant, _ := ctx.Get("tenant")
+	return []zap.Field{zap.Any("tenant", tenant)}, false
+}
+
+func TestLogEntryCore(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	_, lc := withLogEntryContext(context.Background())
+
+	logger := zap.New(core).WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newLogEntryCore(core, []LogEntryHandler{tenantLogEntryHandler{}}, lc)
+	})).With(zap.String("reqId", "1"))
+
+	logger.Info("before")
+	logger.Debug("disabled")
+
+	lc.requestContext = &requestContext{keys: map[string]any{"tenant": "acme"}}
+	logger.Info("after")
+	logger.Warn("noisy")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	require.Equal(t, map[string]any{"reqId": "1", "no_context": true}, entries[0].ContextMap())
+	require.Equal(t, map[string]any{"reqId": "1", "tenant": "acme"}, entries[1].ContextMap())
+}
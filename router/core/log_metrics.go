@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+var _ logging.Metrics = (*LogMetrics)(nil)
+
+// LogMetrics counts the entries of the logs by level, the failed writes to the sinks and the rotated log files. It's
+// created before the loggers, which report to it as logging.Metrics, and is exposed with the metrics of the router.
+type LogMetrics struct {
+	entries   [zapcore.FatalLevel - zapcore.DebugLevel + 1]atomic.Int64
+	rotations atomic.Int64
+
+	mu            sync.Mutex
+	sinkErrors    map[string]int64
+	registrations []otelmetric.Registration
+}
+
+func NewLogMetrics() *LogMetrics {
+	return &LogMetrics{sinkErrors: map[string]int64{}}
+}
+
+func (m *LogMetrics) Entry(level zapcore.Level) {
+	if level < zapcore.DebugLevel || level > zapcore.FatalLevel {
+		return
+	}
+	m.entries[level-zapcore.DebugLevel].Add(1)
+}
+
+func (m *LogMetrics) SinkError(sink string) {
+	m.mu.Lock()
+	m.sinkErrors[sink]++
+	m.mu.Unlock()
+}
+
+func (m *LogMetrics) Rotation() {
+	m.rotations.Add(1)
+}
+
+// Entries returns the number of written entries of the level
+func (m *LogMetrics) Entries(level zapcore.Level) int64 {
+	if level < zapcore.DebugLevel || level > zapcore.FatalLevel {
+		return 0
+	}
+	return m.entries[level-zapcore.DebugLevel].Load()
+}
+
+// SinkErrors returns the number of failed writes to the sink
+func (m *LogMetrics) SinkErrors(sink string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sinkErrors[sink]
+}
+
+// Rotations returns the number of rotated log files
+func (m *LogMetrics) Rotations() int64 {
+	return m.rotations.Load()
+}
+
+// RegisterMetrics exposes the counters on the meter provider
+func (m *LogMetrics) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	entries, err := meter.Int64ObservableCounter(
+		"router.logs.entries",
+		otelmetric.WithDescription("Number of log entries written by level"),
+	)
+	if err != nil {
+		return err
+	}
+	sinkErrors, err := meter.Int64ObservableCounter(
+		"router.logs.sink_errors",
+		otelmetric.WithDescription("Number of failed writes to the log sinks, like syslog or Kafka"),
+	)
+	if err != nil {
+		return err
+	}
+	rotations, err := meter.Int64ObservableCounter(
+		"router.logs.rotations",
+		otelmetric.WithDescription("Number of log files rotated on demand"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		for level := zapcore.DebugLevel; level <= zapcore.FatalLevel; level++ {
+			// The series are only exported once an entry of the level was written
+			count := m.Entries(level)
+			if count == 0 {
+				continue
+			}
+			o.ObserveInt64(entries, count, otelmetric.WithAttributes(attribute.String("level", level.String())))
+		}
+
+		m.mu.Lock()
+		for sink, count := range m.sinkErrors {
+			o.ObserveInt64(sinkErrors, count, otelmetric.WithAttributes(attribute.String("sink", sink)))
+		}
+		m.mu.Unlock()
+
+		o.ObserveInt64(rotations, m.Rotations())
+		return nil
+	}, entries, sinkErrors, rotations)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.registrations = append(m.registrations, reg)
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *LogMetrics) Shutdown() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	for _, reg := range m.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	m.registrations = nil
+
+	return err
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+func TestLogMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics := NewLogMetrics()
+	// Only the entries that a core accepts are counted
+	observed, _ := observer.New(zapcore.InfoLevel)
+	logger := zap.New(observed, logging.WithMetrics(metrics))
+	logger.Debug("disabled")
+	logger.Info("first")
+	logger.Warn("second")
+	logger.Warn("third")
+	metrics.SinkError("kafka")
+	metrics.Rotation()
+
+	reader := sdkmetric.NewManualReader()
+	require.NoError(t, metrics.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	values := map[string]map[string]int64{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		values[metric.Name] = map[string]int64{}
+		for _, dp := range metric.Data.(metricdata.Sum[int64]).DataPoints {
+			var attr string
+			for _, kv := range dp.Attributes.ToSlice() {
+				attr = kv.Value.AsString()
+			}
+			values[metric.Name][attr] = dp.Value
+		}
+	}
+	require.Equal(t, map[string]map[string]int64{
+		"router.logs.entries":     {"info": 1, "warn": 2},
+		"router.logs.sink_errors": {"kafka": 1},
+		"router.logs.rotations":   {"": 1},
+	}, values)
+
+	require.NoError(t, metrics.Shutdown())
+}
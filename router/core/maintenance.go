@@ -0,0 +1,134 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"go.uber.org/zap"
+)
+
+var ErrMaintenanceMode = errors.New("operation rejected due to maintenance mode")
+
+// MaintenanceMode rejects operations with a configurable response while it is active.
+// The state is shared between all servers so that it survives router config updates.
+type MaintenanceMode struct {
+	active             atomic.Bool
+	statusCode         int
+	retryAfter         time.Duration
+	message            string
+	responseBody       []byte
+	operationNames     map[string]struct{}
+	operationTypes     map[string]struct{}
+	failReadinessCheck bool
+}
+
+type MaintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+func NewMaintenanceMode(cfg *config.MaintenanceConfiguration) (*MaintenanceMode, error) {
+	m := &MaintenanceMode{
+		statusCode:         cfg.StatusCode,
+		retryAfter:         cfg.RetryAfter,
+		message:            cfg.Message,
+		failReadinessCheck: cfg.FailReadinessCheck,
+		operationNames:     make(map[string]struct{}, len(cfg.Operations.Names)),
+		operationTypes:     make(map[string]struct{}, len(cfg.Operations.Types)),
+	}
+
+	if m.statusCode == 0 {
+		m.statusCode = http.StatusServiceUnavailable
+	}
+
+	if cfg.ResponseBody != "" {
+		if !json.Valid([]byte(cfg.ResponseBody)) {
+			return nil, errors.New("maintenance response body is not valid JSON")
+		}
+		m.responseBody = []byte(cfg.ResponseBody)
+	}
+
+	for _, name := range cfg.Operations.Names {
+		m.operationNames[name] = struct{}{}
+	}
+	for _, typ := range cfg.Operations.Types {
+		m.operationTypes[typ] = struct{}{}
+	}
+
+	m.active.Store(cfg.Enabled)
+
+	return m, nil
+}
+
+// SetEnabled activates or deactivates the maintenance mode
+func (m *MaintenanceMode) SetEnabled(enabled bool) {
+	m.active.Store(enabled)
+}
+
+// Enabled returns true if the maintenance mode is active
+func (m *MaintenanceMode) Enabled() bool {
+	return m.active.Load()
+}
+
+// Status returns the current state of the maintenance mode
+func (m *MaintenanceMode) Status() MaintenanceStatus {
+	return MaintenanceStatus{
+		Enabled: m.Enabled(),
+	}
+}
+
+// AppliesTo returns true if the maintenance mode is active and the operation is affected by it.
+// When no operation names and types are configured, all operations are affected.
+func (m *MaintenanceMode) AppliesTo(operationName, operationType string) bool {
+	if !m.Enabled() {
+		return false
+	}
+
+	if len(m.operationNames) == 0 && len(m.operationTypes) == 0 {
+		return true
+	}
+
+	if _, ok := m.operationNames[operationName]; ok {
+		return true
+	}
+
+	_, ok := m.operationTypes[operationType]
+	return ok
+}
+
+// WriteResponse writes the maintenance response. The static response body takes precedence over the GraphQL error.
+func (m *MaintenanceMode) WriteResponse(r *http.Request, w http.ResponseWriter, requestLogger *zap.Logger) {
+	if m.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(m.retryAfter.Seconds())))
+	}
+
+	if m.responseBody != nil {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(m.statusCode)
+		if _, err := w.Write(m.responseBody); err != nil {
+			requestLogger.Error("error writing response", zap.Error(err))
+		}
+		return
+	}
+
+	writeHTTPError(r, w, m.statusCode, errors.New(m.message), requestLogger)
+}
+
+// Readiness wraps the readiness handler and reports the router as not ready
+// while the maintenance mode is active, if configured.
+func (m *MaintenanceMode) Readiness(next http.HandlerFunc) http.HandlerFunc {
+	if !m.failReadinessCheck {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.Enabled() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestMaintenanceReadiness(t *testing.T) {
+	ready := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	m, err := NewMaintenanceMode(&config.MaintenanceConfiguration{
+		Enabled: true,
+	})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	m.Readiness(ready)(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	m, err = NewMaintenanceMode(&config.MaintenanceConfiguration{
+		Enabled:            true,
+		FailReadinessCheck: true,
+	})
+	require.NoError(t, err)
+
+	rec = httptest.NewRecorder()
+	m.Readiness(ready)(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	m.SetEnabled(false)
+
+	rec = httptest.NewRecorder()
+	m.Readiness(ready)(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestMaintenanceAppliesTo(t *testing.T) {
+	m, err := NewMaintenanceMode(&config.MaintenanceConfiguration{
+		Enabled: true,
+		Operations: config.MaintenanceOperations{
+			Names: []string{"Checkout"},
+			Types: []string{"subscription"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.True(t, m.AppliesTo("Checkout", "mutation"))
+	require.True(t, m.AppliesTo("OnPrice", "subscription"))
+	require.False(t, m.AppliesTo("Employees", "query"))
+
+	_, err = NewMaintenanceMode(&config.MaintenanceConfiguration{
+		ResponseBody: `{"maintenance":`,
+	})
+	require.Error(t, err)
+}
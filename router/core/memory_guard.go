@@ -0,0 +1,299 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+var ErrMemoryLimitExceeded = errors.New("the router is overloaded. Please try again")
+
+const (
+	cosmoRouterMemoryMeterName    = "cosmo.router.memory"
+	cosmoRouterMemoryMeterVersion = "0.0.1"
+
+	// The soft limit is left when the usage drops below this ratio of the threshold
+	// to avoid flapping between both states
+	memoryGuardRecoveryRatio = 0.9
+	// Ratio of the runtime memory limit that is used as soft limit when no threshold is configured
+	defaultMemorySoftLimitRatio = 0.9
+)
+
+type MemoryGuardOptions struct {
+	Logger *zap.Logger
+	// Threshold is the soft memory limit in bytes
+	Threshold     uint64
+	CheckInterval time.Duration
+	// ShrinkCaches clears all registered caches when the soft limit is exceeded
+	ShrinkCaches bool
+	// ShedLoad rejects new requests while the soft limit is exceeded
+	ShedLoad bool
+}
+
+// MemoryGuard watches the memory usage of the process and reacts before the hard memory limit is reached.
+// When the usage exceeds the soft limit, all registered caches are cleared and new requests are rejected
+// until the usage drops below the recovery threshold again.
+type MemoryGuard struct {
+	logger            *zap.Logger
+	threshold         uint64
+	recoveryThreshold uint64
+	checkInterval     time.Duration
+	shrinkCaches      bool
+	shedLoad          bool
+
+	exceeded      atomic.Bool
+	exceededCount atomic.Int64
+	shedCount     atomic.Int64
+
+	mu            sync.Mutex
+	shrinkers     map[uint64]func()
+	nextShrinker  uint64
+	registrations []otelmetric.Registration
+
+	// readUsage returns the current memory usage in bytes. It can be replaced in tests.
+	readUsage func() uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewMemoryGuard(opts *MemoryGuardOptions) (*MemoryGuard, error) {
+	if opts.Threshold == 0 {
+		return nil, errors.New("memory soft limit requires a threshold or a memory limit")
+	}
+
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = time.Second
+	}
+
+	return &MemoryGuard{
+		logger:            opts.Logger,
+		threshold:         opts.Threshold,
+		recoveryThreshold: uint64(float64(opts.Threshold) * memoryGuardRecoveryRatio),
+		checkInterval:     opts.CheckInterval,
+		shrinkCaches:      opts.ShrinkCaches,
+		shedLoad:          opts.ShedLoad,
+		shrinkers:         map[uint64]func(){},
+		readUsage:         currentMemoryUsage,
+	}, nil
+}
+
+// DefaultMemorySoftLimitThreshold returns 90% of the memory limit of the Go runtime or zero if no limit is set
+func DefaultMemorySoftLimitThreshold() uint64 {
+	// A negative input only returns the current limit
+	limit := debug.SetMemoryLimit(-1)
+	if limit <= 0 || limit == math.MaxInt64 {
+		return 0
+	}
+	return uint64(float64(limit) * defaultMemorySoftLimitRatio)
+}
+
+// currentMemoryUsage returns the memory that is mapped by the Go runtime and not released to the OS.
+// This is the same value that is compared against GOMEMLIMIT.
+func currentMemoryUsage() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+
+	total, released := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+	if released > total {
+		return 0
+	}
+	return total - released
+}
+
+// RegisterShrinker registers a function that frees memory, e.g. by clearing a cache.
+// The returned function removes the shrinker again.
+func (g *MemoryGuard) RegisterShrinker(shrink func()) func() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := g.nextShrinker
+	g.nextShrinker++
+	g.shrinkers[id] = shrink
+
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		delete(g.shrinkers, id)
+	}
+}
+
+// Exceeded returns true if the memory usage is above the soft limit
+func (g *MemoryGuard) Exceeded() bool {
+	return g.exceeded.Load()
+}
+
+func (g *MemoryGuard) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	g.cancel = cancel
+	g.done = make(chan struct{})
+
+	go func() {
+		defer close(g.done)
+
+		ticker := time.NewTicker(g.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				g.check()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (g *MemoryGuard) Shutdown() error {
+	if g.cancel != nil {
+		g.cancel()
+		<-g.done
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var err error
+	for _, reg := range g.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	g.registrations = nil
+
+	return err
+}
+
+func (g *MemoryGuard) check() {
+	usage := g.readUsage()
+
+	if !g.exceeded.Load() {
+		if usage < g.threshold {
+			return
+		}
+
+		g.exceeded.Store(true)
+		g.exceededCount.Add(1)
+
+		g.logger.Warn("Memory soft limit exceeded",
+			zap.Uint64("usage_bytes", usage),
+			zap.Uint64("threshold_bytes", g.threshold),
+			zap.Bool("shrink_caches", g.shrinkCaches),
+			zap.Bool("shed_load", g.shedLoad),
+		)
+
+		if g.shrinkCaches {
+			g.shrink()
+		}
+		return
+	}
+
+	if usage < g.recoveryThreshold {
+		g.exceeded.Store(false)
+
+		g.logger.Info("Memory usage recovered below soft limit",
+			zap.Uint64("usage_bytes", usage),
+			zap.Uint64("recovery_threshold_bytes", g.recoveryThreshold),
+		)
+	}
+}
+
+func (g *MemoryGuard) shrink() {
+	g.mu.Lock()
+	shrinkers := make([]func(), 0, len(g.shrinkers))
+	for _, shrink := range g.shrinkers {
+		shrinkers = append(shrinkers, shrink)
+	}
+	g.mu.Unlock()
+
+	for _, shrink := range shrinkers {
+		shrink()
+	}
+
+	g.logger.Debug("Caches cleared due to memory pressure", zap.Int("caches", len(shrinkers)))
+}
+
+// Middleware rejects requests while the soft limit is exceeded and load shedding is enabled
+func (g *MemoryGuard) Middleware(next http.Handler) http.Handler {
+	if !g.shedLoad {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.exceeded.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		g.shedCount.Add(1)
+
+		w.Header().Set("Retry-After", "1")
+		writeHTTPError(r, w, http.StatusServiceUnavailable, ErrMemoryLimitExceeded, g.logger)
+	})
+}
+
+// RegisterMetrics exposes the soft limit events on the meter provider
+func (g *MemoryGuard) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterMemoryMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterMemoryMeterVersion),
+	)
+
+	exceeded, err := meter.Int64ObservableCounter(
+		"router.memory.soft_limit.exceeded",
+		otelmetric.WithDescription("Number of times the memory soft limit was exceeded"),
+	)
+	if err != nil {
+		return err
+	}
+
+	shed, err := meter.Int64ObservableCounter(
+		"router.memory.soft_limit.shed_requests",
+		otelmetric.WithDescription("Number of requests rejected because the memory soft limit was exceeded"),
+	)
+	if err != nil {
+		return err
+	}
+
+	active, err := meter.Int64ObservableUpDownCounter(
+		"router.memory.soft_limit.active",
+		otelmetric.WithDescription("1 if the memory usage is above the soft limit, 0 otherwise"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		o.ObserveInt64(exceeded, g.exceededCount.Load())
+		o.ObserveInt64(shed, g.shedCount.Load())
+
+		var value int64
+		if g.exceeded.Load() {
+			value = 1
+		}
+		o.ObserveInt64(active, value)
+
+		return nil
+	}, exceeded, shed, active)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	g.registrations = append(g.registrations, reg)
+	g.mu.Unlock()
+
+	return nil
+}
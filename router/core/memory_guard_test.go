@@ -0,0 +1,131 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestMemoryGuard(t *testing.T, usage *uint64) (*MemoryGuard, *observer.ObservedLogs) {
+	t.Helper()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	g, err := NewMemoryGuard(&MemoryGuardOptions{
+		Logger:       zap.New(core),
+		Threshold:    1000,
+		ShrinkCaches: true,
+		ShedLoad:     true,
+	})
+	require.NoError(t, err)
+
+	g.readUsage = func() uint64 { return *usage }
+
+	return g, logs
+}
+
+func TestMemoryGuard(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requires a threshold", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewMemoryGuard(&MemoryGuardOptions{Logger: zap.NewNop()})
+		require.Error(t, err)
+	})
+
+	t.Run("shrinks caches once when the soft limit is exceeded and recovers with hysteresis", func(t *testing.T) {
+		t.Parallel()
+
+		usage := uint64(500)
+		g, logs := newTestMemoryGuard(t, &usage)
+
+		shrunk := 0
+		g.RegisterShrinker(func() { shrunk++ })
+
+		g.check()
+		require.False(t, g.Exceeded())
+		require.Equal(t, 0, shrunk)
+
+		usage = 1000
+		g.check()
+		require.True(t, g.Exceeded())
+		require.Equal(t, 1, shrunk)
+		require.Equal(t, int64(1), g.exceededCount.Load())
+
+		// Still above the recovery threshold of 900 bytes
+		usage = 950
+		g.check()
+		require.True(t, g.Exceeded())
+		require.Equal(t, 1, shrunk)
+
+		usage = 800
+		g.check()
+		require.False(t, g.Exceeded())
+
+		require.Equal(t, 1, logs.FilterMessage("Memory soft limit exceeded").Len())
+		require.Equal(t, 1, logs.FilterMessage("Memory usage recovered below soft limit").Len())
+	})
+
+	t.Run("unregistered shrinkers are not called", func(t *testing.T) {
+		t.Parallel()
+
+		usage := uint64(2000)
+		g, _ := newTestMemoryGuard(t, &usage)
+
+		shrunk := false
+		unregister := g.RegisterShrinker(func() { shrunk = true })
+		unregister()
+
+		g.check()
+		require.True(t, g.Exceeded())
+		require.False(t, shrunk)
+	})
+
+	t.Run("sheds load while the soft limit is exceeded", func(t *testing.T) {
+		t.Parallel()
+
+		usage := uint64(0)
+		g, _ := newTestMemoryGuard(t, &usage)
+
+		handler := g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		usage = 1000
+		g.check()
+
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+		body, err := io.ReadAll(rec.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"errors":[{"message":"the router is overloaded. Please try again"}],"data":null}`, string(body))
+		require.Equal(t, int64(1), g.shedCount.Load())
+	})
+
+	t.Run("clears the operation cache", func(t *testing.T) {
+		t.Parallel()
+
+		c := &OperationCache{
+			persistetOperationVariableNames: map[string][]string{"a": {"b"}},
+			persistedOperationCache:         map[uint64]normalizedOperationCacheEntry{1: {}},
+		}
+		c.clear()
+
+		require.Empty(t, c.persistetOperationVariableNames)
+		require.Empty(t, c.persistedOperationCache)
+	})
+}
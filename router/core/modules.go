@@ -10,6 +10,7 @@ import (
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var (
@@ -81,6 +82,25 @@ type EnginePostOriginHandler interface {
 	OnOriginResponse(resp *http.Response, ctx RequestContext) *http.Response
 }
 
+// LogEntryHandler allows you to add fields to or drop the access log entry and the application log entries of a request.
+// The handler is called for every entry that is written. All handlers are called sequentially and the entry is dropped
+// as soon as one handler drops it. Keep the handler fast, it is called synchronously while the entry is written.
+type LogEntryHandler interface {
+	// OnLogEntry is called before the entry is written. The returned fields are added to the entry.
+	// ctx is nil for entries that are written before the operation was parsed, e.g. when the request body is invalid.
+	OnLogEntry(ctx RequestContext, entry *LogEntry) (fields []zap.Field, drop bool)
+}
+
+// LogEntry is a log entry of a request that is passed to the LogEntryHandler
+type LogEntry struct {
+	// Access is true for the access log entry of the request
+	Access  bool
+	Level   zapcore.Level
+	Message string
+	// Fields are the fields of the entry. Don't modify them, return additional fields instead.
+	Fields []zap.Field
+}
+
 // Provisioner is called before the server starts
 // It allows you to initialize your module e.g. create a database connection
 // or load a configuration file
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/internal/requestlogger"
+)
+
+const graphQLRequestMarkerKey = key("graphQLRequestMarker")
+
+const (
+	nonGraphQLRequestPreflight  = "preflight"
+	nonGraphQLRequestHealth     = "health"
+	nonGraphQLRequestPlayground = "playground"
+	nonGraphQLRequestNotFound   = "not_found"
+	nonGraphQLRequestOther      = "other"
+
+	// unmatchedPath labels the paths that the router doesn't serve, so that scanners can't inflate the metrics
+	unmatchedPath = "unmatched"
+)
+
+// NonGraphQLRequests logs and counts the requests that don't reach the GraphQL handler, e.g. the CORS preflight
+// requests, the health checks, the playground and unknown paths. The requests of the GraphQL handler are logged by
+// the access logs of the graph.
+type NonGraphQLRequests struct {
+	logger         *zap.Logger
+	ipAnonymizer   *requestlogger.IPAnonymizationConfig
+	metrics        bool
+	playgroundPath string
+	healthPaths    map[string]struct{}
+	knownPaths     map[string]struct{}
+	excludePaths   map[string]struct{}
+
+	mu            sync.Mutex
+	requests      map[nonGraphQLRequestKey]int64
+	registrations []otelmetric.Registration
+}
+
+type NonGraphQLRequestsOptions struct {
+	// Logger writes the access logs of the requests. The requests aren't logged without a logger.
+	Logger         *zap.Logger
+	IPAnonymizer   *requestlogger.IPAnonymizationConfig
+	Metrics        bool
+	PlaygroundPath string
+	HealthPaths    []string
+	// KnownPaths are the paths the router serves. The other paths are labeled as unmatched.
+	KnownPaths   []string
+	ExcludePaths []string
+}
+
+type nonGraphQLRequestKey struct {
+	kind       string
+	path       string
+	statusCode int
+}
+
+// graphQLRequestMarker is set when the request reached the GraphQL handler
+type graphQLRequestMarker struct {
+	reached bool
+}
+
+func NewNonGraphQLRequests(opts *NonGraphQLRequestsOptions) *NonGraphQLRequests {
+	set := func(paths ...[]string) map[string]struct{} {
+		m := make(map[string]struct{})
+		for _, p := range paths {
+			for _, path := range p {
+				if path != "" {
+					m[path] = struct{}{}
+				}
+			}
+		}
+		return m
+	}
+
+	return &NonGraphQLRequests{
+		logger:         opts.Logger,
+		ipAnonymizer:   opts.IPAnonymizer,
+		metrics:        opts.Metrics,
+		playgroundPath: opts.PlaygroundPath,
+		healthPaths:    set(opts.HealthPaths),
+		knownPaths:     set(opts.KnownPaths, opts.HealthPaths, []string{opts.PlaygroundPath}),
+		excludePaths:   set(opts.ExcludePaths),
+		requests:       make(map[nonGraphQLRequestKey]int64),
+	}
+}
+
+// markGraphQLRequest marks the request as handled by the GraphQL handler, which logs it on its own
+func markGraphQLRequest(ctx context.Context) {
+	if marker, ok := ctx.Value(graphQLRequestMarkerKey).(*graphQLRequestMarker); ok {
+		marker.reached = true
+	}
+}
+
+func reachedGraphQLHandler(r *http.Request) bool {
+	marker, ok := r.Context().Value(graphQLRequestMarkerKey).(*graphQLRequestMarker)
+	return ok && marker.reached
+}
+
+// Middleware logs and counts the requests that don't reach the GraphQL handler. It must be registered before the
+// CORS middleware, which answers the preflight requests.
+func (n *NonGraphQLRequests) Middleware(next http.Handler) http.Handler {
+	opts := []requestlogger.Option{
+		requestlogger.WithDefaultOptions(),
+		requestlogger.WithNoTimeField(),
+		requestlogger.WithRequestFields(func(r *http.Request) []zapcore.Field {
+			return []zapcore.Field{
+				zap.String("request_id", middleware.GetReqID(r.Context())),
+				zap.String("request_kind", n.kind(r, 0)),
+			}
+		}),
+		requestlogger.WithObserver(n.observe),
+		requestlogger.WithSampler(func(r *http.Request, _ int) bool {
+			return n.logger != nil && !reachedGraphQLHandler(r)
+		}),
+	}
+	if n.ipAnonymizer != nil && n.ipAnonymizer.Enabled {
+		opts = append(opts, requestlogger.WithAnonymization(n.ipAnonymizer))
+	}
+
+	logger := n.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	handler := requestlogger.New(logger, opts...)(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := n.excludePaths[r.URL.Path]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), graphQLRequestMarkerKey, &graphQLRequestMarker{})
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (n *NonGraphQLRequests) observe(r *http.Request, statusCode int, _ time.Duration) {
+	if !n.metrics || reachedGraphQLHandler(r) {
+		return
+	}
+
+	key := nonGraphQLRequestKey{kind: n.kind(r, statusCode), path: n.path(r, statusCode), statusCode: statusCode}
+	n.mu.Lock()
+	n.requests[key]++
+	n.mu.Unlock()
+}
+
+// kind classifies the request. The status code is 0 while it's unknown.
+func (n *NonGraphQLRequests) kind(r *http.Request, statusCode int) string {
+	switch {
+	case r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "":
+		return nonGraphQLRequestPreflight
+	case n.isHealthPath(r.URL.Path):
+		return nonGraphQLRequestHealth
+	case n.playgroundPath != "" && r.URL.Path == n.playgroundPath && r.Method == http.MethodGet:
+		return nonGraphQLRequestPlayground
+	case statusCode == http.StatusNotFound:
+		return nonGraphQLRequestNotFound
+	}
+	if statusCode == 0 {
+		if _, ok := n.knownPaths[r.URL.Path]; !ok {
+			return nonGraphQLRequestNotFound
+		}
+	}
+	return nonGraphQLRequestOther
+}
+
+func (n *NonGraphQLRequests) isHealthPath(path string) bool {
+	_, ok := n.healthPaths[path]
+	return ok
+}
+
+// path returns the label of the path of the request
+func (n *NonGraphQLRequests) path(r *http.Request, statusCode int) string {
+	if _, ok := n.knownPaths[r.URL.Path]; ok && statusCode != http.StatusNotFound {
+		return r.URL.Path
+	}
+	return unmatchedPath
+}
+
+// Requests returns the number of requests of the kind and the path label with the status code
+func (n *NonGraphQLRequests) Requests(kind, path string, statusCode int) int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.requests[nonGraphQLRequestKey{kind: kind, path: path, statusCode: statusCode}]
+}
+
+// RegisterMetrics exposes the requests on the meter provider
+func (n *NonGraphQLRequests) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	if !n.metrics {
+		return nil
+	}
+
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	requests, err := meter.Int64ObservableCounter(
+		"router.http.non_graphql.requests",
+		otelmetric.WithDescription("Number of requests that don't reach the GraphQL handler by their kind, path and status code"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		for key, count := range n.requests {
+			o.ObserveInt64(requests, count, otelmetric.WithAttributes(
+				attribute.String("kind", key.kind),
+				attribute.String("path", key.path),
+				attribute.Int("status_code", key.statusCode),
+			))
+		}
+		return nil
+	}, requests)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.registrations = append(n.registrations, reg)
+	n.mu.Unlock()
+
+	return nil
+}
+
+func (n *NonGraphQLRequests) Shutdown() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var err error
+	for _, reg := range n.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	n.registrations = nil
+
+	return err
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNonGraphQLRequests(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+	n := NewNonGraphQLRequests(&NonGraphQLRequestsOptions{
+		Logger:         zap.New(logCore),
+		Metrics:        true,
+		PlaygroundPath: "/",
+		HealthPaths:    []string{"/health", "/health/live", "/health/ready"},
+		KnownPaths:     []string{"/graphql"},
+		ExcludePaths:   []string{"/health/live"},
+	})
+
+	handler := n.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/graphql":
+			// The GraphQL handler logs its requests on its own
+			markGraphQLRequest(r.Context())
+		case r.URL.Path == "/" || r.URL.Path == "/health" || r.URL.Path == "/health/live":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	serve := func(method, path string, header http.Header) {
+		r := httptest.NewRequest(method, path, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve(http.MethodOptions, "/graphql", http.Header{"Access-Control-Request-Method": []string{"POST"}})
+	serve(http.MethodGet, "/health", nil)
+	serve(http.MethodGet, "/health/live", nil)
+	serve(http.MethodGet, "/", nil)
+	serve(http.MethodGet, "/wp-admin", nil)
+	serve(http.MethodGet, "/.env", nil)
+	serve(http.MethodPost, "/graphql", nil)
+
+	require.Equal(t, int64(1), n.Requests(nonGraphQLRequestPreflight, "/graphql", http.StatusNoContent))
+	require.Equal(t, int64(1), n.Requests(nonGraphQLRequestHealth, "/health", http.StatusOK))
+	require.Equal(t, int64(1), n.Requests(nonGraphQLRequestPlayground, "/", http.StatusOK))
+	// The unknown paths share a label
+	require.Equal(t, int64(2), n.Requests(nonGraphQLRequestNotFound, unmatchedPath, http.StatusNotFound))
+	// The excluded paths and the requests of the GraphQL handler are neither counted nor logged
+	require.Zero(t, n.Requests(nonGraphQLRequestHealth, "/health/live", http.StatusOK))
+	require.Zero(t, n.Requests(nonGraphQLRequestOther, "/graphql", http.StatusOK))
+
+	entries := logs.All()
+	require.Len(t, entries, 5)
+	require.Equal(t, "/graphql", entries[0].Message)
+	require.Equal(t, nonGraphQLRequestPreflight, entries[0].ContextMap()["request_kind"])
+	require.Equal(t, int64(http.StatusNoContent), entries[0].ContextMap()["status"])
+	require.Equal(t, nonGraphQLRequestHealth, entries[1].ContextMap()["request_kind"])
+	require.Equal(t, nonGraphQLRequestPlayground, entries[2].ContextMap()["request_kind"])
+	require.Equal(t, nonGraphQLRequestNotFound, entries[3].ContextMap()["request_kind"])
+}
@@ -2,35 +2,130 @@ package core
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
 )
 
 var (
 	ErrMutationOperationBlocked     = errors.New("operation type 'mutation' is blocked")
 	ErrSubscriptionOperationBlocked = errors.New("operation type 'subscription' is blocked")
 	ErrNonPersistedOperationBlocked = errors.New("non-persisted operation is blocked")
+	ErrOperationBlockedByRule       = errors.New("operation is blocked by an operation rule")
+)
+
+const (
+	OperationRuleActionAllow = "allow"
+	OperationRuleActionDeny  = "deny"
 )
 
 type OperationBlocker struct {
 	blockMutations     bool
 	blockSubscriptions bool
 	blockNonPersisted  bool
+	rules              []operationRule
 }
 
 type OperationBlockerOptions struct {
 	BlockMutations     bool
 	BlockSubscriptions bool
 	BlockNonPersisted  bool
+	// Rules are evaluated in order and the first matching rule decides. Operations that match no rule are allowed.
+	Rules []config.OperationRule
+}
+
+// operationRule matches an operation when all of its conditions match. A rule without conditions matches every
+// operation, e.g. to deny all operations that weren't allowed by a previous rule.
+type operationRule struct {
+	deny      bool
+	name      string
+	nameRegex *regexp.Regexp
+	opType    string
+	clients   []string
 }
 
-func NewOperationBlocker(opts *OperationBlockerOptions) *OperationBlocker {
+func NewOperationBlocker(opts *OperationBlockerOptions) (*OperationBlocker, error) {
+	rules := make([]operationRule, 0, len(opts.Rules))
+	for i, r := range opts.Rules {
+		rule, err := newOperationRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid operation rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+
 	return &OperationBlocker{
 		blockMutations:     opts.BlockMutations,
 		blockSubscriptions: opts.BlockSubscriptions,
 		blockNonPersisted:  opts.BlockNonPersisted,
+		rules:              rules,
+	}, nil
+}
+
+func newOperationRule(r config.OperationRule) (operationRule, error) {
+	rule := operationRule{
+		name:    r.Name,
+		opType:  r.Type,
+		clients: r.Clients,
+	}
+
+	switch r.Action {
+	case OperationRuleActionAllow:
+	case OperationRuleActionDeny:
+		rule.deny = true
+	default:
+		return rule, fmt.Errorf("unknown action '%s'", r.Action)
+	}
+
+	switch r.Type {
+	case "", "query", "mutation", "subscription":
+	default:
+		return rule, fmt.Errorf("unknown operation type '%s'", r.Type)
+	}
+
+	if r.Name != "" && r.NameRegex != "" {
+		return rule, errors.New("name and name_regex are mutually exclusive")
 	}
+	if r.Name != "" {
+		if _, err := path.Match(r.Name, ""); err != nil {
+			return rule, fmt.Errorf("invalid name pattern '%s': %w", r.Name, err)
+		}
+	}
+	if r.NameRegex != "" {
+		re, err := regexp.Compile(r.NameRegex)
+		if err != nil {
+			return rule, fmt.Errorf("invalid name regex '%s': %w", r.NameRegex, err)
+		}
+		rule.nameRegex = re
+	}
+
+	return rule, nil
+}
+
+func (r *operationRule) matches(operation *ParsedOperation, clientInfo *ClientInfo) bool {
+	name := operation.Request.OperationName
+	if r.name != "" {
+		// The pattern was validated when the rule was created
+		if ok, _ := path.Match(r.name, name); !ok {
+			return false
+		}
+	}
+	if r.nameRegex != nil && !r.nameRegex.MatchString(name) {
+		return false
+	}
+	if r.opType != "" && r.opType != operation.Type {
+		return false
+	}
+	if len(r.clients) > 0 && (clientInfo == nil || !slices.Contains(r.clients, clientInfo.Name)) {
+		return false
+	}
+	return true
 }
 
-func (o *OperationBlocker) OperationIsBlocked(operation *ParsedOperation) error {
+func (o *OperationBlocker) OperationIsBlocked(operation *ParsedOperation, clientInfo *ClientInfo) error {
 
 	if !operation.IsPersistedOperation && o.blockNonPersisted {
 		return ErrNonPersistedOperationBlocked
@@ -46,5 +141,15 @@ func (o *OperationBlocker) OperationIsBlocked(operation *ParsedOperation) error
 			return ErrSubscriptionOperationBlocked
 		}
 	}
+
+	for i := range o.rules {
+		if o.rules[i].matches(operation, clientInfo) {
+			if o.rules[i].deny {
+				return ErrOperationBlockedByRule
+			}
+			return nil
+		}
+	}
+
 	return nil
 }
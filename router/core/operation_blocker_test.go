@@ -0,0 +1,70 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestOperationBlockerRules(t *testing.T) {
+	t.Parallel()
+
+	operation := func(name, opType string) *ParsedOperation {
+		op := &ParsedOperation{Type: opType}
+		op.Request.OperationName = name
+		return op
+	}
+
+	t.Run("validates the rules", func(t *testing.T) {
+		t.Parallel()
+
+		for _, rule := range []config.OperationRule{
+			{Action: "block"},
+			{Action: "deny", Type: "fragment"},
+			{Action: "deny", Name: "[Admin"},
+			{Action: "deny", NameRegex: "(Admin"},
+			{Action: "deny", Name: "Admin*", NameRegex: "^Admin"},
+		} {
+			_, err := NewOperationBlocker(&OperationBlockerOptions{Rules: []config.OperationRule{rule}})
+			require.Error(t, err, "%+v", rule)
+		}
+	})
+
+	t.Run("the first matching rule decides", func(t *testing.T) {
+		t.Parallel()
+
+		blocker, err := NewOperationBlocker(&OperationBlockerOptions{
+			Rules: []config.OperationRule{
+				{Action: "allow", Name: "Admin*", Clients: []string{"internal-tools"}},
+				{Action: "deny", NameRegex: "^Admin"},
+				{Action: "deny", Name: "Expensive", Type: "query"},
+			},
+		})
+		require.NoError(t, err)
+
+		internal := &ClientInfo{Name: "internal-tools"}
+		unknown := &ClientInfo{Name: "unknown"}
+
+		require.NoError(t, blocker.OperationIsBlocked(operation("AdminUsers", "query"), internal))
+		require.ErrorIs(t, blocker.OperationIsBlocked(operation("AdminUsers", "query"), unknown), ErrOperationBlockedByRule)
+		require.ErrorIs(t, blocker.OperationIsBlocked(operation("Expensive", "query"), unknown), ErrOperationBlockedByRule)
+		require.NoError(t, blocker.OperationIsBlocked(operation("Expensive", "mutation"), unknown))
+		require.NoError(t, blocker.OperationIsBlocked(operation("", "query"), unknown))
+	})
+
+	t.Run("denies everything that wasn't allowed", func(t *testing.T) {
+		t.Parallel()
+
+		blocker, err := NewOperationBlocker(&OperationBlockerOptions{
+			Rules: []config.OperationRule{
+				{Action: "allow", Name: "Employees"},
+				{Action: "deny"},
+			},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, blocker.OperationIsBlocked(operation("Employees", "query"), &ClientInfo{}))
+		require.ErrorIs(t, blocker.OperationIsBlocked(operation("", "query"), &ClientInfo{}), ErrOperationBlockedByRule)
+	})
+}
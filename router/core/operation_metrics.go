@@ -45,14 +45,20 @@ func (m *OperationMetrics) AddOperationContext(opContext *operationContext) {
 	m.opContext = opContext
 }
 
-func (m *OperationMetrics) Finish(err error, statusCode int, responseSize int) {
+func (m *OperationMetrics) Finish(err error, class errorClass, statusCode int, responseSize int) {
 	m.inflightMetric()
 
 	ctx := context.Background()
 
 	rm := m.routerMetrics.MetricStore()
 
-	if err != nil {
+	if class != errorClassNone {
+		m.metricBaseFields = append(m.metricBaseFields, otel.WgRequestErrorClass.String(string(class)))
+	}
+
+	// Requests that the client canceled are not errors of the router and don't count towards the error rate
+	hasError := err != nil && class != errorClassClientCanceled
+	if hasError {
 		// We don't store false values in the metrics, so only add the error attribute if it's true
 		m.metricBaseFields = append(m.metricBaseFields, otel.WgRequestError.Bool(true))
 		rm.MeasureRequestError(ctx, m.metricBaseFields...)
@@ -68,7 +74,7 @@ func (m *OperationMetrics) Finish(err error, statusCode int, responseSize int) {
 	rm.MeasureResponseSize(ctx, int64(responseSize), m.metricBaseFields...)
 
 	if m.opContext != nil {
-		m.exportSchemaUsageInfo(m.opContext, statusCode, err != nil)
+		m.exportSchemaUsageInfo(m.opContext, statusCode, hasError)
 	}
 }
 
@@ -126,7 +132,7 @@ func getAttributesFromOperationContext(operationContext *operationContext) []att
 	baseMetricAttributeValues = append(baseMetricAttributeValues, otel.WgOperationName.String(operationContext.Name()))
 	baseMetricAttributeValues = append(baseMetricAttributeValues, otel.WgOperationType.String(operationContext.Type()))
 	baseMetricAttributeValues = append(baseMetricAttributeValues, otel.WgOperationProtocol.String(operationContext.Protocol().String()))
-	baseMetricAttributeValues = append(baseMetricAttributeValues, otel.WgOperationHash.String(strconv.FormatUint(operationContext.Hash(), 10)))
+	baseMetricAttributeValues = append(baseMetricAttributeValues, otel.WgOperationHash.String(strconv.FormatUint(operationContext.fingerprint, 10)))
 
 	// Common Field that will be present in both metrics and traces if not empty
 	if operationContext.PersistedID() != "" {
@@ -3,7 +3,9 @@ package core
 import (
 	"errors"
 	"strconv"
+	"sync/atomic"
 
+	"go.uber.org/zap"
 	"golang.org/x/sync/singleflight"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
@@ -19,12 +21,21 @@ import (
 type planWithMetaData struct {
 	preparedPlan                      plan.Plan
 	operationDocument, schemaDocument *ast.Document
+	// responseSize is the size of the last response of the plan. It is used to pick the size of the response buffer.
+	responseSize atomic.Int64
+	// deprecatedFields are the fields of the operation that are deprecated in the client schema
+	deprecatedFields []deprecatedField
+	// fields are the fields of the operation, when the field blocking is enabled
+	fields []operationField
 }
 
 type OperationPlanner struct {
-	sf        singleflight.Group
-	planCache ExecutionPlanCache
-	executor  *Executor
+	sf           singleflight.Group
+	planCache    ExecutionPlanCache
+	executor     *Executor
+	deprecations *DeprecationReporter
+	manifest     *PersistedOperationManifest
+	fieldBlocker *FieldBlocker
 }
 
 type ExecutionPlanCache interface {
@@ -46,10 +57,16 @@ func (n *noopExecutionPlanCache) Set(key, value interface{}, cost int64) bool {
 	return true
 }
 
-func NewOperationPlanner(executor *Executor, planCache ExecutionPlanCache) *OperationPlanner {
+// NewOperationPlanner creates a planner. deprecations is optional, when set the usage of deprecated fields is reported.
+// manifest is optional, when set the planned operations are recorded in the persisted operation manifest.
+// fieldBlocker is optional, when set the operations that select blocked fields are rejected.
+func NewOperationPlanner(executor *Executor, planCache ExecutionPlanCache, deprecations *DeprecationReporter, manifest *PersistedOperationManifest, fieldBlocker *FieldBlocker) *OperationPlanner {
 	return &OperationPlanner{
-		planCache: planCache,
-		executor:  executor,
+		planCache:    planCache,
+		executor:     executor,
+		deprecations: deprecations,
+		manifest:     manifest,
+		fieldBlocker: fieldBlocker,
 	}
 }
 
@@ -67,6 +84,18 @@ func (p *OperationPlanner) preparePlan(requestOperationName []byte, requestOpera
 		return nil, &reportError{report: &report}
 	}
 
+	var deprecated []deprecatedField
+	if p.deprecations != nil {
+		// The fields are collected once per plan, so the cost is only paid on a cache miss
+		deprecated = deprecatedFields(&doc, p.executor.ClientSchema)
+	}
+
+	var fields []operationField
+	if p.fieldBlocker != nil {
+		// The fields are blocked at runtime, so all fields are collected and checked on every request
+		fields = operationFields(&doc, p.executor.ClientSchema)
+	}
+
 	planner, err := plan.NewPlanner(p.executor.PlanConfig)
 	if err != nil {
 		return nil, err
@@ -85,6 +114,8 @@ func (p *OperationPlanner) preparePlan(requestOperationName []byte, requestOpera
 		preparedPlan:      preparedPlan,
 		operationDocument: &doc,
 		schemaDocument:    p.executor.RouterSchema,
+		deprecatedFields:  deprecated,
+		fields:            fields,
 	}, nil
 }
 
@@ -95,6 +126,7 @@ func (p *OperationPlanner) Plan(operation *ParsedOperation, clientInfo *ClientIn
 		opType:                     operation.Type,
 		content:                    operation.NormalizedRepresentation,
 		hash:                       operation.ID,
+		fingerprint:                operation.Fingerprint,
 		clientInfo:                 clientInfo,
 		variables:                  operation.Request.Variables,
 		files:                      operation.Files,
@@ -102,10 +134,13 @@ func (p *OperationPlanner) Plan(operation *ParsedOperation, clientInfo *ClientIn
 		extensions:                 operation.Request.Extensions,
 		protocol:                   protocol,
 		persistedOperationCacheHit: operation.PersistedOperationCacheHit,
+		sealedVariables:            operation.sealedVariables,
 	}
 
 	if operation.IsPersistedOperation {
 		opContext.persistedID = operation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash
+		opContext.cacheDecisions.normalization = cacheDecision(operation.PersistedOperationCacheHit)
+		opContext.cacheDecisions.persistedOperation = cacheDecision(!operation.PersistedOperationFetched)
 	}
 
 	if traceOptions.Enable {
@@ -117,6 +152,12 @@ func (p *OperationPlanner) Plan(operation *ParsedOperation, clientInfo *ClientIn
 			return nil, err
 		}
 		opContext.preparedPlan = prepared
+		opContext.cacheDecisions.plan = cacheDecisionBypass
+		if err := p.checkBlockedFields(opContext); err != nil {
+			return nil, err
+		}
+		p.reportDeprecatedFields(opContext)
+		p.manifest.Record(operation, clientInfo)
 		return opContext, nil
 	}
 
@@ -148,5 +189,49 @@ func (p *OperationPlanner) Plan(operation *ParsedOperation, clientInfo *ClientIn
 			return nil, errors.New("unexpected prepared plan type")
 		}
 	}
+	opContext.cacheDecisions.plan = cacheDecision(opContext.planCacheHit)
+	if err := p.checkBlockedFields(opContext); err != nil {
+		return nil, err
+	}
+	p.reportDeprecatedFields(opContext)
+	p.manifest.Record(operation, clientInfo)
 	return opContext, nil
 }
+
+// checkBlockedFields rejects the operations that select a blocked field and sets the masked fields of the operation
+func (p *OperationPlanner) checkBlockedFields(opContext *operationContext) error {
+	if p.fieldBlocker == nil {
+		return nil
+	}
+	masked, err := p.fieldBlocker.check(opContext.preparedPlan.fields)
+	if err != nil {
+		return err
+	}
+	if len(masked) == 0 {
+		return nil
+	}
+	// The fields are masked in the complete response. Subscriptions and operations over WebSocket are rejected instead.
+	if _, ok := opContext.preparedPlan.preparedPlan.(*plan.SubscriptionResponsePlan); ok || opContext.protocol == OperationProtocolWS {
+		return &FieldBlockedError{Coordinate: masked[0].coordinate}
+	}
+	opContext.maskedFields = masked
+	return nil
+}
+
+func (p *OperationPlanner) reportDeprecatedFields(opContext *operationContext) {
+	if p.deprecations == nil {
+		return
+	}
+	for _, field := range opContext.preparedPlan.deprecatedFields {
+		p.deprecations.Report(DeprecationKindField, field.coordinate, field.reason,
+			zap.String("operation_name", opContext.Name()),
+			zap.Uint64("operation_hash", opContext.fingerprint),
+			zap.String("client_name", opContext.ClientInfo().Name),
+			zap.String("client_version", opContext.ClientInfo().Version),
+		)
+	}
+	// Only complete responses over HTTP have extensions the fields can be listed in
+	if _, ok := opContext.preparedPlan.preparedPlan.(*plan.SynchronousResponsePlan); ok && opContext.protocol == OperationProtocolHTTP {
+		opContext.deprecationWarnings = p.deprecations.warnClient(opContext.clientInfo, opContext.preparedPlan.deprecatedFields)
+	}
+}
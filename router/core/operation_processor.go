@@ -17,6 +17,7 @@ import (
 
 	"github.com/wundergraph/cosmo/router/internal/cdn"
 	"github.com/wundergraph/cosmo/router/internal/pool"
+	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/astnormalization"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
@@ -35,6 +36,9 @@ type ParsedOperation struct {
 	// ID represents a unique-ish ID for the operation calculated by hashing
 	// its normalized representation and its variables
 	ID uint64
+	// Fingerprint is the hash of the operation in the logs, the traces and the metrics. It's hashed by the
+	// fingerprint options of the operation parser, without options it's the ID. The plans are always cached by the ID.
+	Fingerprint uint64
 	// Type is a string representing the operation type. One of
 	// "query", "mutation", "subscription"
 	Type         string
@@ -49,6 +53,14 @@ type ParsedOperation struct {
 	GraphQLRequestExtensions   GraphQLRequestExtensions
 	IsPersistedOperation       bool
 	PersistedOperationCacheHit bool
+	// PersistedOperationFetched is true when the persisted operation was neither cached normalized nor as content,
+	// and was fetched from the CDN
+	PersistedOperationFetched bool
+	// IntrospectionKind is IntrospectionKindSchema when the operation selects __schema, IntrospectionKindType when
+	// it only probes types with __type and empty otherwise
+	IntrospectionKind string
+	// sealedVariables are the variables with the sealed values of the client, if the variables were unsealed
+	sealedVariables []byte
 }
 
 type invalidExtensionsTypeError jsonparser.ValueType
@@ -75,6 +87,49 @@ type OperationParserOptions struct {
 	PersistentOpClient      *cdn.PersistentOperationClient
 
 	EnablePersistedOperationsCache bool
+	JSONLimits                     JSONLimits
+	PersistedOperationUsage        *PersistedOperationUsageTracker
+	// Fingerprint hashes the operations for the logs, the traces and the metrics differently from the ID, e.g. to
+	// match the fingerprints of client tooling. Optional.
+	Fingerprint *OperationFingerprintOptions
+}
+
+// OperationFingerprintOptions are the options of the normalization of the operations for their fingerprint. The zero
+// value hashes the operations like their ID.
+type OperationFingerprintOptions struct {
+	// InlineLiterals includes the values of the inline literals, so that operations that only differ in their
+	// literals get different fingerprints. By default, the literals are extracted as variables and ignored.
+	InlineLiterals bool
+	// RemoveAliases ignores the aliases of the fields
+	RemoveAliases bool
+	// Indent prints the operation with an indentation of two spaces instead of compactly before it's hashed
+	Indent bool
+}
+
+func NewOperationFingerprintOptions(cfg *config.OperationFingerprintConfiguration) (*OperationFingerprintOptions, error) {
+	opts := &OperationFingerprintOptions{}
+	switch cfg.Literals {
+	case "", "extract":
+	case "inline":
+		opts.InlineLiterals = true
+	default:
+		return nil, fmt.Errorf("unknown literals '%s', supported are extract and inline", cfg.Literals)
+	}
+	switch cfg.Aliases {
+	case "", "keep":
+	case "remove":
+		opts.RemoveAliases = true
+	default:
+		return nil, fmt.Errorf("unknown aliases '%s', supported are keep and remove", cfg.Aliases)
+	}
+	switch cfg.Whitespace {
+	case "", "compact":
+	case "indent":
+		opts.Indent = true
+	default:
+		return nil, fmt.Errorf("unknown whitespace '%s', supported are compact and indent", cfg.Whitespace)
+	}
+	return opts, nil
 }
 
 // OperationProcessor provides shared resources to the parseKit and OperationKit.
@@ -85,6 +140,9 @@ type OperationProcessor struct {
 	cdn                     *cdn.PersistentOperationClient
 	parseKitPool            *sync.Pool
 	operationCache          *OperationCache
+	jsonLimits              JSONLimits
+	persistedOpUsage        *PersistedOperationUsageTracker
+	fingerprint             *OperationFingerprintOptions
 }
 
 // parseKit is a helper struct to parse, normalize and validate operations
@@ -106,6 +164,17 @@ type OperationCache struct {
 	persistedOperationCacheLock sync.RWMutex
 }
 
+// clear removes all entries. It is used to free memory when the memory soft limit is exceeded.
+func (c *OperationCache) clear() {
+	c.persistetOperationVariableNamesLock.Lock()
+	c.persistetOperationVariableNames = map[string][]string{}
+	c.persistetOperationVariableNamesLock.Unlock()
+
+	c.persistedOperationCacheLock.Lock()
+	c.persistedOperationCache = map[uint64]normalizedOperationCacheEntry{}
+	c.persistedOperationCacheLock.Unlock()
+}
+
 // OperationKit provides methods to parse, normalize and validate operations.
 // After each step, the operation is available as a ParsedOperation.
 // It must be created for each request and freed after the request is done.
@@ -162,6 +231,12 @@ func (o *OperationKit) Parse(ctx context.Context, clientInfo *ClientInfo) error
 		anonymousOperationDefinitionRef = -1
 	)
 
+	if o.operationParser.jsonLimits.enabled() {
+		if err := o.operationParser.jsonLimits.validate(o.data); err != nil {
+			return err
+		}
+	}
+
 	err := json.Unmarshal(o.data, &o.parsedOperation.Request)
 	if err != nil {
 		return &inputError{
@@ -221,12 +296,19 @@ func (o *OperationKit) Parse(ctx context.Context, clientInfo *ClientInfo) error
 			}
 		}
 		if fromCache {
+			o.operationParser.persistedOpUsage.Track(clientInfo.Name, o.parsedOperation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash)
+			o.detectIntrospection()
 			return nil
 		}
-		persistedOperationData, err := o.operationParser.cdn.PersistedOperation(ctx, clientInfo.Name, o.parsedOperation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash)
-		if err != nil {
-			return err
+		persistedOperationData := o.operationParser.cdn.CachedPersistedOperation(clientInfo.Name, o.parsedOperation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash)
+		if persistedOperationData == nil {
+			persistedOperationData, err = o.operationParser.cdn.PersistedOperation(ctx, clientInfo.Name, o.parsedOperation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash)
+			if err != nil {
+				return err
+			}
+			o.parsedOperation.PersistedOperationFetched = true
 		}
+		o.operationParser.persistedOpUsage.Track(clientInfo.Name, o.parsedOperation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash)
 		o.parsedOperation.Request.Query = string(persistedOperationData)
 	}
 
@@ -324,9 +406,24 @@ func (o *OperationKit) Parse(ctx context.Context, clientInfo *ClientInfo) error
 	// Replace the operation name with a static name to avoid different IDs for the same operation
 	replaceOperationName := o.kit.doc.Input.AppendInputBytes(staticOperationName)
 	o.kit.doc.OperationDefinitions[o.operationDefinitionRef].Name = replaceOperationName
+
+	o.detectIntrospection()
+
 	return nil
 }
 
+func (o *OperationKit) detectIntrospection() {
+	for ref := range o.kit.doc.Fields {
+		switch o.kit.doc.FieldNameUnsafeString(ref) {
+		case "__schema":
+			o.parsedOperation.IntrospectionKind = IntrospectionKindSchema
+			return
+		case "__type":
+			o.parsedOperation.IntrospectionKind = IntrospectionKindType
+		}
+	}
+}
+
 // Normalize normalizes the operation. After normalization the normalized representation of the operation
 // and variables is available. Also, the final operation ID is generated.
 func (o *OperationKit) Normalize() error {
@@ -398,6 +495,11 @@ WithNext:
 	o.parsedOperation.ID = o.kit.keyGen.Sum64()
 	o.kit.keyGen.Reset()
 
+	o.parsedOperation.Fingerprint, err = o.fingerprint(exportedVariables)
+	if err != nil {
+		return err
+	}
+
 	// Print the operation with the original operation name
 	o.kit.doc.OperationDefinitions[o.operationDefinitionRef].Name = o.originalOperationNameRef
 	err = o.kit.printer.Print(o.kit.doc, o.operationParser.executor.ClientSchema, o.kit.normalizedOperation)
@@ -439,6 +541,11 @@ func (o *OperationKit) normalizeNonPersistedOperation() error {
 	// Generate the operation ID
 	o.parsedOperation.ID = o.kit.keyGen.Sum64()
 
+	o.parsedOperation.Fingerprint, err = o.fingerprint(o.kit.doc.Input.Variables)
+	if err != nil {
+		return err
+	}
+
 	// Print the operation with the original operation name
 	o.kit.doc.OperationDefinitions[o.operationDefinitionRef].Name = o.originalOperationNameRef
 	err = o.kit.printer.Print(o.kit.doc, o.operationParser.executor.ClientSchema, o.kit.normalizedOperation)
@@ -452,8 +559,62 @@ func (o *OperationKit) normalizeNonPersistedOperation() error {
 	return nil
 }
 
+// fingerprint hashes the normalized operation with the static operation name by the fingerprint options. The
+// variables are the variables after the normalization, the inline literals are the ones that the request didn't send.
+func (o *OperationKit) fingerprint(variables []byte) (uint64, error) {
+	opts := o.operationParser.fingerprint
+	if opts == nil {
+		return o.parsedOperation.ID, nil
+	}
+
+	doc := o.kit.doc
+	if opts.RemoveAliases {
+		aliases := make([]ast.Alias, len(doc.Fields))
+		for i := range doc.Fields {
+			aliases[i] = doc.Fields[i].Alias
+			doc.Fields[i].Alias = ast.Alias{}
+		}
+		// The document is validated after the normalization
+		defer func() {
+			for i := range aliases {
+				doc.Fields[i].Alias = aliases[i]
+			}
+		}()
+	}
+
+	o.kit.keyGen.Reset()
+	defer o.kit.keyGen.Reset()
+
+	var err error
+	if opts.Indent {
+		err = astprinter.PrintIndent(doc, o.operationParser.executor.ClientSchema, []byte("  "), o.kit.keyGen)
+	} else {
+		err = o.kit.printer.Print(doc, o.operationParser.executor.ClientSchema, o.kit.keyGen)
+	}
+	if err != nil {
+		return 0, errors.WithStack(fmt.Errorf("failed to print the operation for its fingerprint: %w", err))
+	}
+
+	if opts.InlineLiterals {
+		err = jsonparser.ObjectEach(variables, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+			if _, exists := o.parsedOperation.VariablesMap[string(key)]; exists {
+				return nil
+			}
+			_, _ = o.kit.keyGen.Write(key)
+			_, _ = o.kit.keyGen.Write(value)
+			return nil
+		})
+		if err != nil {
+			return 0, errors.WithStack(fmt.Errorf("failed to hash the literals of the operation: %w", err))
+		}
+	}
+
+	return o.kit.keyGen.Sum64(), nil
+}
+
 type normalizedOperationCacheEntry struct {
 	operationID              uint64
+	fingerprint              uint64
 	normalizedRepresentation string
 	operationType            string
 	exportedVariables        []byte
@@ -478,6 +639,7 @@ func (o *OperationKit) loadPersistedOperationFromCache() (ok bool, err error) {
 	}
 	o.parsedOperation.PersistedOperationCacheHit = true
 	o.parsedOperation.ID = entry.operationID
+	o.parsedOperation.Fingerprint = entry.fingerprint
 	o.parsedOperation.NormalizedRepresentation = entry.normalizedRepresentation
 	o.parsedOperation.Type = entry.operationType
 	if o.parsedOperation.Request.Variables == nil || bytes.Equal(o.parsedOperation.Request.Variables, literalNull) {
@@ -524,6 +686,7 @@ func (o *OperationKit) savePersistedOperationToCache(skipIncludeVariableNames []
 
 	entry := normalizedOperationCacheEntry{
 		operationID:              o.parsedOperation.ID,
+		fingerprint:              o.parsedOperation.Fingerprint,
 		normalizedRepresentation: o.parsedOperation.NormalizedRepresentation,
 		operationType:            o.parsedOperation.Type,
 		exportedVariables:        make([]byte, len(exportedVariables)),
@@ -581,10 +744,31 @@ func (o *OperationKit) generatePersistedOperationCacheKey(skipIncludeVariableNam
 	return sum
 }
 
+// UnsealVariables decrypts the sealed values of the normalized variables. The variables with the sealed values are
+// kept for the logs.
+func (o *OperationKit) UnsealVariables(sealed *SealedVariables) error {
+	variables, err := sealed.unseal(o.parsedOperation.Request.Variables)
+	if err != nil {
+		return err
+	}
+	if variables != nil {
+		o.parsedOperation.sealedVariables = bytes.Clone(o.parsedOperation.Request.Variables)
+		o.parsedOperation.Request.Variables = variables
+	}
+	return nil
+}
+
 // Validate validates the operation variables.
 func (o *OperationKit) Validate() error {
 	err := o.kit.variablesValidator.Validate(o.kit.doc, o.operationParser.executor.ClientSchema, o.parsedOperation.Request.Variables)
 	if err != nil {
+		if o.parsedOperation.sealedVariables != nil {
+			// The errors contain the invalid values, which must not be revealed
+			return &inputError{
+				message:    "the variables don't match the types of the operation. The details are omitted because the variables have sealed values",
+				statusCode: http.StatusOK,
+			}
+		}
 		return &inputError{
 			message:    err.Error(),
 			statusCode: http.StatusOK,
@@ -629,6 +813,9 @@ func NewOperationParser(opts OperationParserOptions) *OperationProcessor {
 		executor:                opts.Executor,
 		maxOperationSizeInBytes: opts.MaxOperationSizeInBytes,
 		cdn:                     opts.PersistentOpClient,
+		jsonLimits:              opts.JSONLimits,
+		persistedOpUsage:        opts.PersistedOperationUsage,
+		fingerprint:             opts.Fingerprint,
 		parseKitPool: &sync.Pool{
 			New: func() interface{} {
 				return &parseKit{
@@ -649,6 +836,9 @@ func NewOperationParser(opts OperationParserOptions) *OperationProcessor {
 			},
 		},
 	}
+	if opts.Fingerprint != nil && *opts.Fingerprint == (OperationFingerprintOptions{}) {
+		processor.fingerprint = nil
+	}
 	if opts.EnablePersistedOperationsCache {
 		processor.operationCache = &OperationCache{
 			persistetOperationVariableNames:     map[string][]string{},
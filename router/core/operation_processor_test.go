@@ -10,6 +10,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
 )
 
 func TestOperationParser(t *testing.T) {
@@ -218,3 +220,122 @@ func TestOperationParserExtensions(t *testing.T) {
 		})
 	}
 }
+
+func TestOperationParserJSONLimits(t *testing.T) {
+	parser := NewOperationParser(OperationParserOptions{
+		Executor:                &Executor{},
+		MaxOperationSizeInBytes: 10 << 20,
+		JSONLimits: JSONLimits{
+			MaxDepth:        4,
+			MaxKeys:         5,
+			MaxStringLength: 40,
+		},
+	})
+	clientInfo := &ClientInfo{
+		Name:    "test",
+		Version: "1.0.0",
+	}
+	testCases := []struct {
+		Name          string
+		Input         string
+		ExpectedLimit JSONLimit
+	}{
+		{
+			Name:  "within limits",
+			Input: `{"query":"query { employees { id } }","variables":{"a":{"b":[1]}}}`,
+		},
+		{
+			Name:          "depth",
+			Input:         `{"query":"query { employees { id } }","variables":{"a":{"b":[[1]]}}}`,
+			ExpectedLimit: JSONLimitDepth,
+		},
+		{
+			Name:          "keys",
+			Input:         `{"query":"query { employees { id } }","variables":{"a":1,"b":2,"c":3,"d":4}}`,
+			ExpectedLimit: JSONLimitKeys,
+		},
+		{
+			Name:          "string length",
+			Input:         `{"query":"query { employees { id details { forename } } }"}`,
+			ExpectedLimit: JSONLimitStringLength,
+		},
+		{
+			Name:  "structural characters and escaped quotes in strings are ignored",
+			Input: `{"query":"query { employees { id } }","variables":{"a":"[[[{:\"::"}}`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			kit, err := parser.NewKitFromReader(strings.NewReader(tc.Input))
+			require.NoError(t, err)
+			defer kit.Free()
+
+			err = kit.Parse(context.Background(), clientInfo)
+
+			var limitErr *JSONLimitError
+			if tc.ExpectedLimit == "" {
+				require.False(t, errors.As(err, &limitErr), "unexpected limit error: %s", err)
+				return
+			}
+			require.ErrorAs(t, err, &limitErr)
+			require.Equal(t, tc.ExpectedLimit, limitErr.Limit)
+			require.Equal(t, 400, limitErr.StatusCode())
+		})
+	}
+}
+
+func TestOperationParserIntrospectionKind(t *testing.T) {
+	parser := NewOperationParser(OperationParserOptions{
+		Executor:                &Executor{},
+		MaxOperationSizeInBytes: 10 << 20,
+	})
+	clientInfo := &ClientInfo{
+		Name:    "test",
+		Version: "1.0.0",
+	}
+	testCases := []struct {
+		Name         string
+		Input        string
+		ExpectedKind string
+	}{
+		{
+			Name:  "regular query",
+			Input: `{"query":"query { employees { id __typename } }"}`,
+		},
+		{
+			Name:         "schema introspection",
+			Input:        `{"query":"query { __type(name: \"Employee\") { name } __schema { types { name } } }"}`,
+			ExpectedKind: IntrospectionKindSchema,
+		},
+		{
+			Name:         "type probing in a fragment",
+			Input:        `{"query":"query { ...F } fragment F on Query { __type(name: \"Employee\") { name } }"}`,
+			ExpectedKind: IntrospectionKindType,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			kit, err := parser.NewKitFromReader(strings.NewReader(tc.Input))
+			require.NoError(t, err)
+			defer kit.Free()
+
+			require.NoError(t, kit.Parse(context.Background(), clientInfo))
+			require.Equal(t, tc.ExpectedKind, kit.parsedOperation.IntrospectionKind)
+		})
+	}
+}
+
+func TestNewOperationFingerprintOptions(t *testing.T) {
+	opts, err := NewOperationFingerprintOptions(&config.OperationFingerprintConfiguration{
+		Literals:   "inline",
+		Aliases:    "remove",
+		Whitespace: "compact",
+	})
+	require.NoError(t, err)
+	require.Equal(t, &OperationFingerprintOptions{InlineLiterals: true, RemoveAliases: true}, opts)
+
+	_, err = NewOperationFingerprintOptions(&config.OperationFingerprintConfiguration{Whitespace: "tabs"})
+	require.EqualError(t, err, "unknown whitespace 'tabs', supported are compact and indent")
+}
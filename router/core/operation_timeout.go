@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrOperationTimeout is the cause of the context of an operation that exceeded its timeout
+var ErrOperationTimeout = errors.New("operation timed out")
+
+// OperationTimeoutErrorCode is the code in the extensions of the error of a timed out operation. It distinguishes
+// the timeout of the router from timeouts of the subgraphs.
+const OperationTimeoutErrorCode = "OPERATION_TIMEOUT"
+
+// OperationTimeouts holds the execution timeouts of the operation types and the overrides by operation name.
+// A timeout of zero disables it.
+type OperationTimeouts struct {
+	query        time.Duration
+	mutation     time.Duration
+	subscription time.Duration
+	operations   map[string]time.Duration
+}
+
+func NewOperationTimeouts(cfg *config.OperationTimeoutsConfiguration) (*OperationTimeouts, error) {
+	if cfg.Query < 0 || cfg.Mutation < 0 || cfg.Subscription < 0 {
+		return nil, errors.New("operation timeouts must not be negative")
+	}
+
+	t := &OperationTimeouts{
+		query:        cfg.Query,
+		mutation:     cfg.Mutation,
+		subscription: cfg.Subscription,
+		operations:   make(map[string]time.Duration, len(cfg.Operations)),
+	}
+
+	for _, op := range cfg.Operations {
+		if op.Name == "" {
+			return nil, errors.New("operation timeout overrides require an operation name")
+		}
+		if op.Timeout < 0 {
+			return nil, fmt.Errorf("the timeout of operation '%s' must not be negative", op.Name)
+		}
+		if _, ok := t.operations[op.Name]; ok {
+			return nil, fmt.Errorf("duplicate operation timeout override for operation '%s'", op.Name)
+		}
+		t.operations[op.Name] = op.Timeout
+	}
+
+	return t, nil
+}
+
+// Timeout returns the timeout of the operation. An override of the operation name takes precedence over the
+// timeout of the operation type.
+func (t *OperationTimeouts) Timeout(operationName, operationType string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	if timeout, ok := t.operations[operationName]; ok {
+		return timeout
+	}
+	switch operationType {
+	case "query":
+		return t.query
+	case "mutation":
+		return t.mutation
+	case "subscription":
+		return t.subscription
+	}
+	return 0
+}
+
+// withTimeout returns a context that is canceled with ErrOperationTimeout as cause when the timeout of the
+// operation is exceeded
+func (t *OperationTimeouts) withTimeout(ctx context.Context, operationName, operationType string) (context.Context, context.CancelFunc) {
+	return withOperationTimeout(ctx, t.Timeout(operationName, operationType))
+}
+
+// withOperationTimeout returns a context that is canceled with ErrOperationTimeout as cause after the timeout.
+// Without a timeout, the context is returned unchanged.
+func withOperationTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, ErrOperationTimeout)
+}
+
+// isOperationTimeout returns true if the context was canceled because the operation exceeded its timeout
+func isOperationTimeout(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrOperationTimeout)
+}
+
+func operationTimeoutAttributes(operationCtx *operationContext) []attribute.KeyValue {
+	// The name and the client point into buffers of the request. The metric keeps the attributes, so they are copied.
+	attributes := []attribute.KeyValue{
+		otel.WgOperationName.String(strings.Clone(operationCtx.Name())),
+		otel.WgOperationType.String(operationCtx.Type()),
+		otel.WgOperationProtocol.String(operationCtx.Protocol().String()),
+	}
+	if operationCtx.clientInfo != nil {
+		attributes = append(attributes, otel.WgClientName.String(strings.Clone(operationCtx.clientInfo.Name)))
+	}
+	return attributes
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestOperationTimeouts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates the config", func(t *testing.T) {
+		t.Parallel()
+
+		for _, cfg := range []config.OperationTimeoutsConfiguration{
+			{Query: -time.Second},
+			{Operations: []config.OperationTimeout{{Timeout: time.Second}}},
+			{Operations: []config.OperationTimeout{{Name: "A", Timeout: -time.Second}}},
+			{Operations: []config.OperationTimeout{{Name: "A", Timeout: time.Second}, {Name: "A", Timeout: 2 * time.Second}}},
+		} {
+			_, err := NewOperationTimeouts(&cfg)
+			require.Error(t, err, "%+v", cfg)
+		}
+	})
+
+	t.Run("overrides the timeout of the type by name", func(t *testing.T) {
+		t.Parallel()
+
+		timeouts, err := NewOperationTimeouts(&config.OperationTimeoutsConfiguration{
+			Query:        time.Second,
+			Mutation:     2 * time.Second,
+			Subscription: time.Hour,
+			Operations: []config.OperationTimeout{
+				{Name: "Report", Timeout: time.Minute},
+				{Name: "Unlimited", Timeout: 0},
+			},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, time.Second, timeouts.Timeout("Employees", "query"))
+		require.Equal(t, time.Second, timeouts.Timeout("", "query"))
+		require.Equal(t, 2*time.Second, timeouts.Timeout("UpdateEmployee", "mutation"))
+		require.Equal(t, time.Hour, timeouts.Timeout("OnEvent", "subscription"))
+		require.Equal(t, time.Minute, timeouts.Timeout("Report", "query"))
+		require.Zero(t, timeouts.Timeout("Unlimited", "mutation"))
+
+		var disabled *OperationTimeouts
+		require.Zero(t, disabled.Timeout("Employees", "query"))
+	})
+
+	t.Run("cancels the context with the timeout as cause", func(t *testing.T) {
+		t.Parallel()
+
+		timeouts, err := NewOperationTimeouts(&config.OperationTimeoutsConfiguration{Query: 10 * time.Millisecond})
+		require.NoError(t, err)
+
+		ctx, cancel := timeouts.withTimeout(context.Background(), "Employees", "query")
+		defer cancel()
+		<-ctx.Done()
+		require.True(t, isOperationTimeout(ctx))
+
+		ctx, cancel = timeouts.withTimeout(context.Background(), "UpdateEmployee", "mutation")
+		cancel()
+		require.NoError(t, ctx.Err())
+		require.False(t, isOperationTimeout(ctx))
+
+		// Canceled requests aren't timeouts
+		parent, cancelParent := context.WithCancel(context.Background())
+		ctx, cancel = timeouts.withTimeout(parent, "Employees", "query")
+		defer cancel()
+		cancelParent()
+		require.False(t, isOperationTimeout(ctx))
+	})
+
+	t.Run("classifies the timeout error", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, errorTypeOperationTimeout, getErrorType(ErrOperationTimeout))
+	})
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+	"go.uber.org/zap"
+)
+
+var ErrPersistedOperationKilled = errors.New("persisted operation is blocked by the kill switch")
+
+// PersistedOperationKillSwitch rejects persisted operations by their hash before they are executed, e.g. when one
+// operation overloads a subgraph. The hashes come from the config, a file that is reloaded when it changes and the
+// admin API. Like the maintenance mode, the state is shared between all servers so that it survives router config
+// updates.
+type PersistedOperationKillSwitch struct {
+	logger         *zap.Logger
+	file           string
+	reloadInterval time.Duration
+	statusCode     int
+	message        string
+
+	// blocked is the union of the hashes of all sources. It is replaced on every change, so lookups don't lock.
+	blocked atomic.Pointer[map[string]struct{}]
+
+	mu         sync.Mutex
+	static     []string
+	fromFile   []string
+	fromAdmin  map[string]struct{}
+	fileMod    time.Time
+	fileSize   int64
+	cancel     context.CancelFunc
+	reloadDone chan struct{}
+}
+
+func NewPersistedOperationKillSwitch(logger *zap.Logger, cfg *config.PersistedOperationKillSwitchConfiguration) (*PersistedOperationKillSwitch, error) {
+	k := &PersistedOperationKillSwitch{
+		logger:         logger,
+		file:           cfg.File,
+		reloadInterval: cfg.ReloadInterval,
+		statusCode:     cfg.StatusCode,
+		message:        cfg.Message,
+		fromAdmin:      make(map[string]struct{}),
+	}
+
+	if k.statusCode == 0 {
+		k.statusCode = http.StatusOK
+	}
+	if k.message == "" {
+		k.message = ErrPersistedOperationKilled.Error()
+	}
+
+	for _, hash := range cfg.Hashes {
+		if hash = normalizePersistedOperationHash(hash); hash != "" {
+			k.static = append(k.static, hash)
+		}
+	}
+
+	if k.file != "" {
+		// A missing or unreadable file is a misconfiguration at startup. Later, the last valid state is kept.
+		if _, err := k.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	k.mu.Lock()
+	k.update()
+	k.mu.Unlock()
+
+	return k, nil
+}
+
+// Blocked returns true if the persisted operation with the hash is blocked
+func (k *PersistedOperationKillSwitch) Blocked(hash string) bool {
+	blocked := k.blocked.Load()
+	if blocked == nil || len(*blocked) == 0 {
+		return false
+	}
+	_, ok := (*blocked)[normalizePersistedOperationHash(hash)]
+	return ok
+}
+
+// Block blocks the persisted operation with the hash until it is unblocked or the router restarts
+func (k *PersistedOperationKillSwitch) Block(hash string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.fromAdmin[normalizePersistedOperationHash(hash)] = struct{}{}
+	k.update()
+}
+
+// Unblock removes a hash that was blocked with Block. Hashes of the config and the file are kept.
+// It returns false if the hash wasn't blocked with Block.
+func (k *PersistedOperationKillSwitch) Unblock(hash string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	hash = normalizePersistedOperationHash(hash)
+	if _, ok := k.fromAdmin[hash]; !ok {
+		return false
+	}
+	delete(k.fromAdmin, hash)
+	k.update()
+
+	return true
+}
+
+// Hashes returns the sorted hashes of all blocked persisted operations
+func (k *PersistedOperationKillSwitch) Hashes() []string {
+	blocked := k.blocked.Load()
+	if blocked == nil {
+		return []string{}
+	}
+	hashes := make([]string, 0, len(*blocked))
+	for hash := range *blocked {
+		hashes = append(hashes, hash)
+	}
+	slices.Sort(hashes)
+	return hashes
+}
+
+// WriteResponse writes the configured error of a blocked persisted operation
+func (k *PersistedOperationKillSwitch) WriteResponse(r *http.Request, w http.ResponseWriter, requestLogger *zap.Logger) {
+	writeRequestErrors(r, w, k.statusCode, graphqlerrors.RequestErrorsFromError(errors.New(k.message)), requestLogger)
+}
+
+// Err returns the configured error of a blocked persisted operation
+func (k *PersistedOperationKillSwitch) Err() error {
+	return errors.New(k.message)
+}
+
+// Start reloads the file in the background when it was modified. It's a no-op without a file.
+func (k *PersistedOperationKillSwitch) Start() {
+	if k.file == "" || k.reloadInterval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	k.mu.Lock()
+	k.cancel = cancel
+	k.reloadDone = make(chan struct{})
+	done := k.reloadDone
+	k.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(k.reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				changed, err := k.reload()
+				if err != nil {
+					k.logger.Warn("Failed to reload the persisted operation kill switch file. The previous hashes are kept",
+						zap.String("file", k.file),
+						zap.Error(err),
+					)
+					continue
+				}
+				if changed {
+					k.mu.Lock()
+					k.update()
+					count := len(k.fromFile)
+					k.mu.Unlock()
+
+					k.logger.Info("Reloaded the persisted operation kill switch file",
+						zap.String("file", k.file),
+						zap.Int("hashes", count),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown stops reloading the file
+func (k *PersistedOperationKillSwitch) Shutdown() {
+	k.mu.Lock()
+	cancel, done := k.cancel, k.reloadDone
+	k.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// reload reads the file when its modification time or size changed. The file contains one hash per line.
+// Empty lines and lines starting with # are ignored.
+func (k *PersistedOperationKillSwitch) reload() (bool, error) {
+	info, err := os.Stat(k.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat the persisted operation kill switch file: %w", err)
+	}
+
+	k.mu.Lock()
+	unchanged := info.ModTime().Equal(k.fileMod) && info.Size() == k.fileSize
+	k.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	content, err := os.ReadFile(k.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the persisted operation kill switch file: %w", err)
+	}
+
+	var hashes []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hashes = append(hashes, normalizePersistedOperationHash(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to parse the persisted operation kill switch file: %w", err)
+	}
+
+	k.mu.Lock()
+	k.fromFile = hashes
+	k.fileMod = info.ModTime()
+	k.fileSize = info.Size()
+	k.mu.Unlock()
+
+	return true, nil
+}
+
+// update rebuilds the set of blocked hashes. The caller must hold the lock.
+func (k *PersistedOperationKillSwitch) update() {
+	blocked := make(map[string]struct{}, len(k.static)+len(k.fromFile)+len(k.fromAdmin))
+	for _, hash := range k.static {
+		blocked[hash] = struct{}{}
+	}
+	for _, hash := range k.fromFile {
+		blocked[hash] = struct{}{}
+	}
+	for hash := range k.fromAdmin {
+		blocked[hash] = struct{}{}
+	}
+	k.blocked.Store(&blocked)
+}
+
+// normalizePersistedOperationHash makes the lookup independent of the case of the hex encoded hash
+func normalizePersistedOperationHash(hash string) string {
+	return strings.ToLower(strings.TrimSpace(hash))
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"go.uber.org/zap"
+)
+
+func TestPersistedOperationKillSwitch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("blocks the hashes of all sources", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "blocked.txt")
+		require.NoError(t, os.WriteFile(file, []byte("# incident 42\n\n  BBBB  \n"), 0o600))
+
+		k, err := NewPersistedOperationKillSwitch(zap.NewNop(), &config.PersistedOperationKillSwitchConfiguration{
+			Hashes: []string{"AAAA"},
+			File:   file,
+		})
+		require.NoError(t, err)
+
+		require.True(t, k.Blocked("aaaa"))
+		require.True(t, k.Blocked("bbbb"))
+		require.False(t, k.Blocked("cccc"))
+
+		k.Block("CCCC")
+		require.True(t, k.Blocked("cccc"))
+		require.Equal(t, []string{"aaaa", "bbbb", "cccc"}, k.Hashes())
+
+		require.True(t, k.Unblock("cccc"))
+		require.False(t, k.Blocked("cccc"))
+		// Only the hashes blocked at runtime can be unblocked
+		require.False(t, k.Unblock("aaaa"))
+		require.True(t, k.Blocked("aaaa"))
+	})
+
+	t.Run("requires the file at startup", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewPersistedOperationKillSwitch(zap.NewNop(), &config.PersistedOperationKillSwitchConfiguration{
+			File: filepath.Join(t.TempDir(), "missing.txt"),
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("reloads the file when it changes", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "blocked.txt")
+		require.NoError(t, os.WriteFile(file, []byte("aaaa\n"), 0o600))
+
+		k, err := NewPersistedOperationKillSwitch(zap.NewNop(), &config.PersistedOperationKillSwitchConfiguration{
+			File:           file,
+			ReloadInterval: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		k.Start()
+		t.Cleanup(k.Shutdown)
+
+		require.NoError(t, os.WriteFile(file, []byte("bbbb\ncccc\n"), 0o600))
+		require.Eventually(t, func() bool {
+			return k.Blocked("bbbb") && !k.Blocked("aaaa")
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// The previous hashes are kept while the file can't be read
+		require.NoError(t, os.Remove(file))
+		time.Sleep(50 * time.Millisecond)
+		require.True(t, k.Blocked("cccc"))
+	})
+
+	t.Run("writes the configured error", func(t *testing.T) {
+		t.Parallel()
+
+		k, err := NewPersistedOperationKillSwitch(zap.NewNop(), &config.PersistedOperationKillSwitchConfiguration{
+			StatusCode: http.StatusServiceUnavailable,
+			Message:    "the operation is disabled",
+		})
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		k.WriteResponse(httptest.NewRequest(http.MethodPost, "/graphql", nil), rec, zap.NewNop())
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.Contains(t, rec.Body.String(), "the operation is disabled")
+	})
+}
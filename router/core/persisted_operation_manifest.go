@@ -0,0 +1,268 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// PersistedOperationManifestFormat is the format of the manifest, the persisted query manifest of Apollo that is
+// also accepted by wgc operations push
+const PersistedOperationManifestFormat = "apollo-persisted-query-manifest"
+
+type PersistedOperationManifestOptions struct {
+	Logger *zap.Logger
+	// Path is the manifest file
+	Path string
+	// ClientName only records the operations of the client. Empty records the operations of all clients.
+	ClientName string
+	// MaxOperations is the maximum number of operations of the manifest. Zero records all operations.
+	MaxOperations int
+	// WriteInterval is the interval in which new operations are written to the file
+	WriteInterval time.Duration
+}
+
+// PersistedOperationManifest records the distinct operations of the traffic in a manifest of persisted operations, to
+// bootstrap the safelisting of an existing API. The operations are identified by the SHA-256 hash of their body like
+// persisted operations, so that clients can send the hashes of the operations they already send. Only operations that
+// were planned successfully are recorded. Like the usage of persisted operations, the manifest is shared between all
+// servers.
+type PersistedOperationManifest struct {
+	logger        *zap.Logger
+	path          string
+	clientName    string
+	maxOperations int
+	writeInterval time.Duration
+
+	mu         sync.Mutex
+	operations map[string]PersistedOperationManifestOperation
+	changed    bool
+	cancel     context.CancelFunc
+	writeDone  chan struct{}
+}
+
+// PersistedOperationManifestDocument is the content of the manifest file
+type PersistedOperationManifestDocument struct {
+	Format     string                                `json:"format"`
+	Version    int                                   `json:"version"`
+	Operations []PersistedOperationManifestOperation `json:"operations"`
+}
+
+type PersistedOperationManifestOperation struct {
+	// ID is the SHA-256 hash of the body
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Body string `json:"body"`
+}
+
+func NewPersistedOperationManifest(opts *PersistedOperationManifestOptions) (*PersistedOperationManifest, error) {
+	if opts.Path == "" {
+		return nil, errors.New("the path of the persisted operation manifest must not be empty")
+	}
+	if opts.MaxOperations < 0 {
+		return nil, errors.New("the maximum number of operations of the persisted operation manifest must not be negative")
+	}
+
+	m := &PersistedOperationManifest{
+		logger:        opts.Logger,
+		path:          opts.Path,
+		clientName:    opts.ClientName,
+		maxOperations: opts.MaxOperations,
+		writeInterval: opts.WriteInterval,
+		operations:    map[string]PersistedOperationManifestOperation{},
+	}
+
+	// The operations of a previous run are kept, so that the recording continues across restarts
+	if err := m.load(); err != nil {
+		return nil, fmt.Errorf("failed to read the persisted operation manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+func (m *PersistedOperationManifest) load() error {
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var doc PersistedOperationManifestDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Format != PersistedOperationManifestFormat {
+		return fmt.Errorf("unknown format '%s'", doc.Format)
+	}
+	for _, operation := range doc.Operations {
+		m.operations[operation.ID] = operation
+	}
+
+	return nil
+}
+
+// Record adds the operation to the manifest if it's new. Persisted operations and introspection operations, e.g.
+// of the playground, aren't recorded.
+func (m *PersistedOperationManifest) Record(operation *ParsedOperation, clientInfo *ClientInfo) {
+	if m == nil || operation.IsPersistedOperation || operation.IntrospectionKind != "" {
+		return
+	}
+	if m.clientName != "" && (clientInfo == nil || clientInfo.Name != m.clientName) {
+		return
+	}
+
+	sum := sha256.Sum256([]byte(operation.Request.Query))
+	id := hex.EncodeToString(sum[:])
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.operations[id]; ok {
+		return
+	}
+	if m.maxOperations > 0 && len(m.operations) >= m.maxOperations {
+		return
+	}
+	// The query and the name point into buffers of the request
+	m.operations[id] = PersistedOperationManifestOperation{
+		ID:   id,
+		Name: strings.Clone(operation.Request.OperationName),
+		Type: operation.Type,
+		Body: strings.Clone(operation.Request.Query),
+	}
+	m.changed = true
+}
+
+// Operations returns the recorded operations sorted by their name and ID
+func (m *PersistedOperationManifest) Operations() []PersistedOperationManifestOperation {
+	m.mu.Lock()
+	operations := make([]PersistedOperationManifestOperation, 0, len(m.operations))
+	for _, operation := range m.operations {
+		operations = append(operations, operation)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].Name != operations[j].Name {
+			return operations[i].Name < operations[j].Name
+		}
+		return operations[i].ID < operations[j].ID
+	})
+
+	return operations
+}
+
+// Write writes the manifest to the file if operations were recorded since the last write. The file is replaced
+// atomically, so that it can be pushed while the router is running.
+func (m *PersistedOperationManifest) Write() error {
+	m.mu.Lock()
+	changed := m.changed
+	m.changed = false
+	m.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(PersistedOperationManifestDocument{
+		Format:     PersistedOperationManifestFormat,
+		Version:    1,
+		Operations: m.Operations(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := m.writeFile(data); err != nil {
+		// The operations are written with the next write
+		m.mu.Lock()
+		m.changed = true
+		m.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (m *PersistedOperationManifest) writeFile(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), m.path)
+}
+
+// Start writes the new operations to the file in the background
+func (m *PersistedOperationManifest) Start() {
+	if m.writeInterval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	m.cancel = cancel
+	m.writeDone = make(chan struct{})
+	done := m.writeDone
+	m.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(m.writeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Write(); err != nil {
+					m.logger.Error("Failed to write the persisted operation manifest",
+						zap.String("path", m.path),
+						zap.Error(err),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown stops the background writes and writes the operations that were recorded since the last write
+func (m *PersistedOperationManifest) Shutdown() error {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.writeDone
+	m.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	return m.Write()
+}
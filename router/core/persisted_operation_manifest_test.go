@@ -0,0 +1,137 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func manifestOperation(query, name, opType string) *ParsedOperation {
+	return &ParsedOperation{
+		Type:    opType,
+		Request: GraphQLRequest{Query: query, OperationName: name},
+	}
+}
+
+func readManifest(t *testing.T, path string) PersistedOperationManifestDocument {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var doc PersistedOperationManifestDocument
+	require.NoError(t, json.Unmarshal(data, &doc))
+	return doc
+}
+
+func TestPersistedOperationManifest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records the distinct operations", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		m, err := NewPersistedOperationManifest(&PersistedOperationManifestOptions{Logger: zap.NewNop(), Path: path})
+		require.NoError(t, err)
+
+		client := &ClientInfo{Name: "web"}
+		m.Record(manifestOperation("query B { employees { id } }", "B", "query"), client)
+		m.Record(manifestOperation("query B { employees { id } }", "B", "query"), client)
+		m.Record(manifestOperation("mutation A { updateEmployeeTag(id: 1, tag: \"a\") { id } }", "A", "mutation"), client)
+
+		persisted := manifestOperation("query C { employees { id } }", "C", "query")
+		persisted.IsPersistedOperation = true
+		m.Record(persisted, client)
+
+		introspection := manifestOperation("{ __schema { types { name } } }", "", "query")
+		introspection.IntrospectionKind = IntrospectionKindSchema
+		m.Record(introspection, client)
+
+		require.NoError(t, m.Shutdown())
+
+		doc := readManifest(t, path)
+		assert.Equal(t, PersistedOperationManifestFormat, doc.Format)
+		assert.Equal(t, 1, doc.Version)
+		assert.Equal(t, []PersistedOperationManifestOperation{
+			{
+				ID:   "8e5e938ac66cf078357919252dd9776efd6c27d8008d5c8f838011471cdcd3db",
+				Name: "A",
+				Type: "mutation",
+				Body: "mutation A { updateEmployeeTag(id: 1, tag: \"a\") { id } }",
+			},
+			{
+				ID:   "bd09a5fd334f3a677d292e274a0129cbb8caf134c50dc7fd8fd30c6a129d4502",
+				Name: "B",
+				Type: "query",
+				Body: "query B { employees { id } }",
+			},
+		}, doc.Operations)
+	})
+
+	t.Run("keeps the operations of an existing manifest", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		m, err := NewPersistedOperationManifest(&PersistedOperationManifestOptions{Logger: zap.NewNop(), Path: path})
+		require.NoError(t, err)
+		m.Record(manifestOperation("query A { employees { id } }", "A", "query"), &ClientInfo{})
+		require.NoError(t, m.Shutdown())
+
+		m, err = NewPersistedOperationManifest(&PersistedOperationManifestOptions{Logger: zap.NewNop(), Path: path})
+		require.NoError(t, err)
+		m.Record(manifestOperation("query B { employees { id } }", "B", "query"), &ClientInfo{})
+		require.NoError(t, m.Shutdown())
+
+		operations := readManifest(t, path).Operations
+		require.Len(t, operations, 2)
+		assert.Equal(t, "A", operations[0].Name)
+		assert.Equal(t, "B", operations[1].Name)
+	})
+
+	t.Run("filters by client and limits the operations", func(t *testing.T) {
+		t.Parallel()
+
+		m, err := NewPersistedOperationManifest(&PersistedOperationManifestOptions{
+			Logger:        zap.NewNop(),
+			Path:          filepath.Join(t.TempDir(), "manifest.json"),
+			ClientName:    "web",
+			MaxOperations: 1,
+		})
+		require.NoError(t, err)
+
+		m.Record(manifestOperation("query A { employees { id } }", "A", "query"), &ClientInfo{Name: "ios"})
+		m.Record(manifestOperation("query B { employees { id } }", "B", "query"), &ClientInfo{Name: "web"})
+		m.Record(manifestOperation("query C { employees { id } }", "C", "query"), &ClientInfo{Name: "web"})
+
+		operations := m.Operations()
+		require.Len(t, operations, 1)
+		assert.Equal(t, "B", operations[0].Name)
+	})
+
+	t.Run("doesn't write without new operations", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		m, err := NewPersistedOperationManifest(&PersistedOperationManifestOptions{Logger: zap.NewNop(), Path: path})
+		require.NoError(t, err)
+		require.NoError(t, m.Shutdown())
+
+		_, err = os.Stat(path)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("rejects an invalid manifest", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"format":"relay","version":1,"operations":[]}`), 0o600))
+
+		_, err := NewPersistedOperationManifest(&PersistedOperationManifestOptions{Logger: zap.NewNop(), Path: path})
+		require.EqualError(t, err, "failed to read the persisted operation manifest: unknown format 'relay'")
+	})
+}
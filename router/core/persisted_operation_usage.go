@@ -0,0 +1,204 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+type PersistedOperationUsageOptions struct {
+	// MaxOperations is the maximum number of tracked persisted operations. The hits of other operations are only
+	// counted in total. Zero tracks all operations.
+	MaxOperations int
+}
+
+// PersistedOperationUsageTracker counts the hits of the persisted operations and records when they were last used,
+// to find operations that are no longer used by the clients. Like the kill switch, the usage is shared between all
+// servers so that it survives router config updates. It only knows the operations used since the router started.
+type PersistedOperationUsageTracker struct {
+	maxOperations int
+	since         time.Time
+	now           func() time.Time
+
+	mu            sync.Mutex
+	usages        map[persistedOperationKey]*persistedOperationUsage
+	untrackedHits int64
+	registrations []otelmetric.Registration
+}
+
+type persistedOperationKey struct {
+	clientName string
+	hash       string
+}
+
+type persistedOperationUsage struct {
+	hits      int64
+	firstUsed time.Time
+	lastUsed  time.Time
+}
+
+// PersistedOperationUsage is the usage of a persisted operation of a client
+type PersistedOperationUsage struct {
+	ID         string    `json:"id"`
+	ClientName string    `json:"client_name"`
+	Hits       int64     `json:"hits"`
+	FirstUsed  time.Time `json:"first_used"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+func NewPersistedOperationUsageTracker(opts *PersistedOperationUsageOptions) (*PersistedOperationUsageTracker, error) {
+	if opts.MaxOperations < 0 {
+		return nil, errors.New("the maximum number of tracked persisted operations must not be negative")
+	}
+
+	t := &PersistedOperationUsageTracker{
+		maxOperations: opts.MaxOperations,
+		now:           time.Now,
+		usages:        map[persistedOperationKey]*persistedOperationUsage{},
+	}
+	t.since = t.now()
+
+	return t, nil
+}
+
+// Track records a hit of the persisted operation with the given hash of the client
+func (t *PersistedOperationUsageTracker) Track(clientName, hash string) {
+	if t == nil || hash == "" {
+		return
+	}
+
+	key := persistedOperationKey{clientName: clientName, hash: hash}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	usage, ok := t.usages[key]
+	if !ok {
+		if t.maxOperations > 0 && len(t.usages) >= t.maxOperations {
+			t.untrackedHits++
+			return
+		}
+		// The hash and the client name point into buffers of the request
+		key.clientName = strings.Clone(clientName)
+		key.hash = strings.Clone(hash)
+		usage = &persistedOperationUsage{firstUsed: now}
+		t.usages[key] = usage
+	}
+	usage.hits++
+	usage.lastUsed = now
+}
+
+// Since returns the time from which on the usage was tracked
+func (t *PersistedOperationUsageTracker) Since() time.Time {
+	return t.since
+}
+
+// UntrackedHits returns the number of hits of operations over the maximum number of tracked operations
+func (t *PersistedOperationUsageTracker) UntrackedHits() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.untrackedHits
+}
+
+// Usages returns the usage of the operations that were not used after the given time, the least recently used
+// first. A zero time returns all operations.
+func (t *PersistedOperationUsageTracker) Usages(usedBefore time.Time) []PersistedOperationUsage {
+	t.mu.Lock()
+	usages := make([]PersistedOperationUsage, 0, len(t.usages))
+	for key, usage := range t.usages {
+		if !usedBefore.IsZero() && !usage.lastUsed.Before(usedBefore) {
+			continue
+		}
+		usages = append(usages, PersistedOperationUsage{
+			ID:         key.hash,
+			ClientName: key.clientName,
+			Hits:       usage.hits,
+			FirstUsed:  usage.firstUsed,
+			LastUsed:   usage.lastUsed,
+		})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(usages, func(i, j int) bool {
+		if !usages[i].LastUsed.Equal(usages[j].LastUsed) {
+			return usages[i].LastUsed.Before(usages[j].LastUsed)
+		}
+		if usages[i].ClientName != usages[j].ClientName {
+			return usages[i].ClientName < usages[j].ClientName
+		}
+		return usages[i].ID < usages[j].ID
+	})
+
+	return usages
+}
+
+// RegisterMetrics exposes the hits and the last usage of the operations on the meter provider
+func (t *PersistedOperationUsageTracker) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	hits, err := meter.Int64ObservableCounter(
+		"router.graphql.persisted_operation.hits",
+		otelmetric.WithDescription("Number of requests of persisted operations"),
+	)
+	if err != nil {
+		return err
+	}
+
+	lastUsed, err := meter.Int64ObservableGauge(
+		"router.graphql.persisted_operation.last_used",
+		otelmetric.WithDescription("Unix time of the last request of persisted operations"),
+		otelmetric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		for key, usage := range t.usages {
+			attributes := otelmetric.WithAttributes(
+				otel.WgOperationPersistedID.String(key.hash),
+				otel.WgClientName.String(key.clientName),
+			)
+			o.ObserveInt64(hits, usage.hits, attributes)
+			o.ObserveInt64(lastUsed, usage.lastUsed.Unix(), attributes)
+		}
+		return nil
+	}, hits, lastUsed)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.registrations = append(t.registrations, reg)
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *PersistedOperationUsageTracker) Shutdown() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var err error
+	for _, reg := range t.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	t.registrations = nil
+
+	return err
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestPersistedOperationUsageTracker(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewPersistedOperationUsageTracker(&PersistedOperationUsageOptions{MaxOperations: -1})
+	require.Error(t, err)
+
+	tracker, err := NewPersistedOperationUsageTracker(&PersistedOperationUsageOptions{MaxOperations: 2})
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	tracker.now = func() time.Time { return now }
+
+	tracker.Track("web", "aaaa")
+	now = now.Add(time.Hour)
+	tracker.Track("web", "bbbb")
+	tracker.Track("web", "aaaa")
+	now = now.Add(time.Hour)
+	tracker.Track("web", "bbbb")
+	// The maximum number of operations is reached
+	tracker.Track("mobile", "aaaa")
+
+	require.Equal(t, []PersistedOperationUsage{
+		{ID: "aaaa", ClientName: "web", Hits: 2, FirstUsed: start, LastUsed: start.Add(time.Hour)},
+		{ID: "bbbb", ClientName: "web", Hits: 2, FirstUsed: start.Add(time.Hour), LastUsed: start.Add(2 * time.Hour)},
+	}, tracker.Usages(time.Time{}))
+	require.Equal(t, int64(1), tracker.UntrackedHits())
+
+	unused := tracker.Usages(start.Add(90 * time.Minute))
+	require.Len(t, unused, 1)
+	require.Equal(t, "aaaa", unused[0].ID)
+
+	reader := sdkmetric.NewManualReader()
+	require.NoError(t, tracker.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	defer func() {
+		require.NoError(t, tracker.Shutdown())
+	}()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	var (
+		hits     metricdata.Sum[int64]
+		lastUsed metricdata.Gauge[int64]
+	)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch m.Name {
+		case "router.graphql.persisted_operation.hits":
+			hits = m.Data.(metricdata.Sum[int64])
+		case "router.graphql.persisted_operation.last_used":
+			lastUsed = m.Data.(metricdata.Gauge[int64])
+		}
+	}
+	require.Len(t, hits.DataPoints, 2)
+	require.Len(t, lastUsed.DataPoints, 2)
+	for _, dp := range lastUsed.DataPoints {
+		id, _ := dp.Attributes.Value(otel.WgOperationPersistedID)
+		if id.AsString() == "aaaa" {
+			require.Equal(t, start.Add(time.Hour).Unix(), dp.Value)
+		} else {
+			require.Equal(t, start.Add(2*time.Hour).Unix(), dp.Value)
+		}
+	}
+}
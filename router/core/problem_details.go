@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// ProblemDetailsContentType is the media type of the problem details of RFC 9457
+const ProblemDetailsContentType = "application/problem+json"
+
+// ProblemDetails is the body of the errors of the requests that are rejected before they are handled as GraphQL
+// requests, as defined in RFC 9457. The request and trace IDs correlate the response with the logs and traces.
+type ProblemDetails struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	TraceID   string `json:"traceId,omitempty"`
+	// Code is the registered code of the error, see ErrorCodes
+	Code string `json:"code,omitempty"`
+}
+
+type problemDetailsCtxKey struct{}
+
+// problemDetailsMiddleware makes the HTTP errors of the request use the problem details
+func problemDetailsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), problemDetailsCtxKey{}, true)))
+	})
+}
+
+func problemDetailsEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(problemDetailsCtxKey{}).(bool)
+	return enabled
+}
+
+// newProblemDetails returns the problem details of the error of the request. The type is about:blank, so the title is
+// the text of the status code.
+func newProblemDetails(r *http.Request, statusCode int, err error) ProblemDetails {
+	problem := ProblemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    err.Error(),
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+		Code:      knownErrorCode(r.Context(), err),
+	}
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+		problem.TraceID = spanContext.TraceID().String()
+	}
+	return problem
+}
+
+// writeHTTPError writes the error of a request that is rejected before it is handled as a GraphQL request. The error
+// is written as problem details if they are enabled, and as GraphQL errors otherwise.
+func writeHTTPError(r *http.Request, w http.ResponseWriter, statusCode int, err error, logger *zap.Logger) {
+	logHTTPError(logger, r, statusCode, err)
+
+	if !problemDetailsEnabled(r.Context()) {
+		writeRequestErrors(r, w, statusCode, graphqlerrors.RequestErrorsFromError(err), logger)
+		return
+	}
+	writeProblemDetails(r, w, statusCode, err, logger)
+}
+
+func writeProblemDetails(r *http.Request, w http.ResponseWriter, statusCode int, err error, logger *zap.Logger) {
+	w.Header().Set("Content-Type", ProblemDetailsContentType)
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(newProblemDetails(r, statusCode, err)); err != nil && logger != nil {
+		logger.Error("error writing response", zap.Error(err))
+	}
+}
+
+// logHTTPError logs the rejected requests with the same message and fields. Internal server errors are logged as
+// errors, the rejections by the limits and the errors of the clients only in the debug level.
+func logHTTPError(logger *zap.Logger, r *http.Request, statusCode int, err error) {
+	if logger == nil {
+		return
+	}
+	level := zap.DebugLevel
+	if statusCode == http.StatusInternalServerError {
+		level = zap.ErrorLevel
+	}
+	if ce := logger.Check(level, "Request rejected"); ce != nil {
+		ce.Write(
+			zap.Int("status", statusCode),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWriteHTTPError(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		writeHTTPError(r, w, http.StatusTooManyRequests, ErrBotRateLimitReached, zap.New(core))
+	}
+
+	t.Run("GraphQL errors by default", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		middleware.RequestID(http.HandlerFunc(handler)).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+
+		require.Equal(t, http.StatusTooManyRequests, rec.Code)
+		require.JSONEq(t, `{"errors":[{"message":"`+ErrBotRateLimitReached.Error()+`"}],"data":null}`, rec.Body.String())
+	})
+
+	t.Run("problem details", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		middleware.RequestID(problemDetailsMiddleware(http.HandlerFunc(handler))).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+
+		require.Equal(t, http.StatusTooManyRequests, rec.Code)
+		require.Equal(t, ProblemDetailsContentType, rec.Header().Get("Content-Type"))
+
+		var problem ProblemDetails
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+		require.NotEmpty(t, problem.RequestID)
+		problem.RequestID = ""
+		require.Equal(t, ProblemDetails{
+			Type:     "about:blank",
+			Title:    "Too Many Requests",
+			Status:   http.StatusTooManyRequests,
+			Detail:   ErrBotRateLimitReached.Error(),
+			Instance: "/graphql",
+			Code:     RateLimitExceededErrorCode,
+		}, problem)
+	})
+
+	entries := logs.FilterMessage("Request rejected").All()
+	require.Len(t, entries, 2)
+	require.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	require.Equal(t, int64(http.StatusTooManyRequests), entries[0].ContextMap()["status"])
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-redis/redis_rate/v10"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+)
+
+var errRateLimitQuotaUnavailable = errors.New("the quota is not available")
+
+// RateLimitQuota is the remaining quota of a client as served by the quota endpoint
+type RateLimitQuota struct {
+	// Client is empty if the request shares the quota with all requests without a client
+	Client                 string `json:"client,omitempty"`
+	Rate                   int    `json:"rate"`
+	Burst                  int    `json:"burst"`
+	PeriodMilliseconds     int64  `json:"periodMs"`
+	Remaining              int    `json:"remaining"`
+	ResetAfterMilliseconds int64  `json:"resetAfterMs"`
+}
+
+// quotaHandler serves the remaining quota of the calling client. The client is identified like on the GraphQL
+// endpoint, so the requests are authenticated with the same authenticators.
+func (r *Router) quotaHandler(limiter *CosmoRateLimiter) http.HandlerFunc {
+	cfg := r.rateLimit
+	limit := redis_rate.Limit{
+		Rate:   cfg.SimpleStrategy.Rate,
+		Burst:  cfg.SimpleStrategy.Burst,
+		Period: cfg.SimpleStrategy.Period,
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if len(r.accessController.authenticators) > 0 {
+			validatedReq, err := r.accessController.Access(w, req)
+			if err != nil {
+				writeHTTPError(req, w, http.StatusUnauthorized, err, r.logger)
+				return
+			}
+			req = validatedReq
+		}
+
+		clientInfo := NewClientInfoFromRequest(req)
+		key, client := rateLimitKey(cfg, clientInfo, authentication.FromContext(req.Context()), r.requestTagger.Tags(req, nil, clientInfo))
+		result, err := limiter.Quota(req.Context(), key, limit)
+		if err != nil {
+			r.logger.Error("failed to read the rate limit quota", zap.Error(err))
+			writeHTTPError(req, w, http.StatusInternalServerError, errRateLimitQuotaUnavailable, r.logger)
+			return
+		}
+
+		quota := RateLimitQuota{
+			Client:                 client,
+			Rate:                   limit.Rate,
+			Burst:                  limit.Burst,
+			PeriodMilliseconds:     limit.Period.Milliseconds(),
+			Remaining:              result.Remaining,
+			ResetAfterMilliseconds: result.ResetAfter.Milliseconds(),
+		}
+		if limiter.debug {
+			quota.ResetAfterMilliseconds = 1234
+		}
+
+		_ = json.NewEncoder(w).Encode(quota)
+	}
+}
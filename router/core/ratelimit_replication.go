@@ -0,0 +1,261 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis_rate/v10"
+	"go.uber.org/zap"
+)
+
+const clusterRateLimitsPath = "/cluster/rate-limits"
+
+// rateLimitStore keeps the quotas of the rate limiter, like the limiter of redis_rate
+type rateLimitStore interface {
+	AllowN(ctx context.Context, key string, limit redis_rate.Limit, n int) (*redis_rate.Result, error)
+}
+
+type ReplicatedRateLimitsOptions struct {
+	Logger *zap.Logger
+	// Peers are the instances of the cluster the consumed quotas are replicated to
+	Peers *ClusterPeers
+	// SyncInterval is the period in which the consumed quotas are sent to the peers
+	SyncInterval time.Duration
+	// Token is the bearer token of the admin APIs of the peers
+	Token string
+	// Limit is the limit of the keys that were only consumed by peers so far
+	Limit redis_rate.Limit
+}
+
+// ReplicatedRateLimits keeps the quotas of the rate limiter in memory and replicates them to the instances of the
+// cluster without Redis. Every instance consumes its local token buckets and periodically sends the consumed tokens
+// to all peers, which deduct them from their buckets. The limits are shared eventually, so the cluster can exceed a
+// limit by the requests of one sync interval. Quotas that weren't sent because a peer was unreachable are lost.
+type ReplicatedRateLimits struct {
+	logger       *zap.Logger
+	peers        *ClusterPeers
+	syncInterval time.Duration
+	token        string
+	limit        redis_rate.Limit
+	httpClient   *http.Client
+	now          func() time.Time
+
+	mu       sync.Mutex
+	buckets  map[string]*rateLimitBucket
+	consumed map[string]int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type rateLimitBucket struct {
+	limit   redis_rate.Limit
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimitSync is the payload of the consumed quotas that are sent to the peers
+type rateLimitSync struct {
+	InstanceID string         `json:"instance_id"`
+	Consumed   map[string]int `json:"consumed"`
+}
+
+func NewReplicatedRateLimits(opts *ReplicatedRateLimitsOptions) *ReplicatedRateLimits {
+	syncInterval := opts.SyncInterval
+	if syncInterval <= 0 {
+		syncInterval = 250 * time.Millisecond
+	}
+	return &ReplicatedRateLimits{
+		logger:       opts.Logger,
+		peers:        opts.Peers,
+		syncInterval: syncInterval,
+		token:        opts.Token,
+		limit:        opts.Limit,
+		httpClient:   &http.Client{Timeout: 5 * time.Second},
+		now:          time.Now,
+		buckets:      map[string]*rateLimitBucket{},
+		consumed:     map[string]int{},
+	}
+}
+
+// bucket returns the refilled bucket of the key. Must be called with the lock held.
+func (r *ReplicatedRateLimits) bucket(key string, limit redis_rate.Limit, now time.Time) *rateLimitBucket {
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{limit: limit, tokens: float64(limit.Burst), updated: now}
+		r.buckets[key] = b
+		return b
+	}
+	b.limit = limit
+	if elapsed := now.Sub(b.updated); elapsed > 0 && limit.Period > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*float64(limit.Rate)/limit.Period.Seconds())
+	}
+	b.updated = now
+	return b
+}
+
+// after returns the time until the bucket has the tokens
+func (b *rateLimitBucket) after(tokens float64) time.Duration {
+	if b.tokens >= tokens || b.limit.Rate <= 0 {
+		return 0
+	}
+	return time.Duration((tokens - b.tokens) / float64(b.limit.Rate) * float64(b.limit.Period))
+}
+
+// AllowN consumes n tokens of the bucket of the key, if it has them. With n 0, the state of the bucket is returned.
+func (r *ReplicatedRateLimits) AllowN(_ context.Context, key string, limit redis_rate.Limit, n int) (*redis_rate.Result, error) {
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.bucket(key, limit, now)
+	result := &redis_rate.Result{Limit: limit, RetryAfter: -1}
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		if n > 0 {
+			r.consumed[key] += n
+		}
+		result.Allowed = n
+	} else {
+		result.RetryAfter = b.after(float64(n))
+	}
+	result.Remaining = max(0, int(b.tokens))
+	result.ResetAfter = b.after(float64(limit.Burst))
+	return result, nil
+}
+
+// deduct removes the tokens that a peer consumed from the buckets. A bucket can go into debt, so that the limit is
+// kept across the cluster.
+func (r *ReplicatedRateLimits) deduct(consumed map[string]int) {
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, n := range consumed {
+		limit := r.limit
+		if b, ok := r.buckets[key]; ok {
+			limit = b.limit
+		}
+		b := r.bucket(key, limit, now)
+		b.tokens = math.Max(b.tokens-float64(n), -float64(b.limit.Burst))
+	}
+}
+
+// sync sends the consumed tokens to all peers and removes the buckets that are full again
+func (r *ReplicatedRateLimits) sync(ctx context.Context) {
+	now := r.now()
+
+	r.mu.Lock()
+	consumed := r.consumed
+	r.consumed = map[string]int{}
+	for key, b := range r.buckets {
+		if b.after(float64(b.limit.Burst)) <= now.Sub(b.updated) {
+			delete(r.buckets, key)
+		}
+	}
+	r.mu.Unlock()
+
+	if len(consumed) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(rateLimitSync{
+		InstanceID: r.peers.self.InstanceID,
+		Consumed:   consumed,
+	})
+	if err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, peer := range r.peers.Peers() {
+		if peer.InstanceID == r.peers.self.InstanceID || peer.URL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if err := r.send(ctx, url, body); err != nil && ctx.Err() == nil {
+				r.logger.Debug("Failed to replicate the rate limits to a cluster peer", zap.Error(err))
+			}
+		}(peer.URL)
+	}
+	wg.Wait()
+}
+
+func (r *ReplicatedRateLimits) send(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url+clusterRateLimitsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to replicate to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to replicate to %s: unexpected status code %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (r *ReplicatedRateLimits) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	r.mu.Unlock()
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.syncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.sync(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (r *ReplicatedRateLimits) Shutdown() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// handleClusterRateLimits deducts the tokens that a peer consumed
+func (r *Router) handleClusterRateLimits(w http.ResponseWriter, req *http.Request) {
+	var payload rateLimitSync
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 8<<20)).Decode(&payload); err != nil {
+		writeAdminJSON(w, http.StatusBadRequest, adminError{Error: "invalid rate limits"})
+		return
+	}
+	r.replicatedRateLimits.deduct(payload.Consumed)
+	w.WriteHeader(http.StatusNoContent)
+}
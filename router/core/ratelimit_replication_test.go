@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis_rate/v10"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReplicatedRateLimitsAllowN(t *testing.T) {
+	limit := redis_rate.Limit{Rate: 10, Burst: 2, Period: time.Second}
+	limits := NewReplicatedRateLimits(&ReplicatedRateLimitsOptions{Limit: limit})
+	now := time.Now()
+	limits.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := limits.AllowN(ctx, "client", limit, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Allowed)
+		require.Equal(t, time.Duration(-1), result.RetryAfter)
+	}
+
+	result, err := limits.AllowN(ctx, "client", limit, 1)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Allowed)
+	require.Equal(t, 100*time.Millisecond, result.RetryAfter)
+	require.Equal(t, 200*time.Millisecond, result.ResetAfter)
+
+	// The tokens are refilled with the rate
+	now = now.Add(100 * time.Millisecond)
+	result, err = limits.AllowN(ctx, "client", limit, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Allowed)
+
+	// The quota of other keys is separate, the peers consume it too
+	limits.deduct(map[string]int{"other": 2})
+	result, err = limits.AllowN(ctx, "other", limit, 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Remaining)
+}
+
+func TestReplicatedRateLimitsSync(t *testing.T) {
+	limit := redis_rate.Limit{Rate: 1, Burst: 5, Period: time.Minute}
+
+	peer := &Router{}
+	peer.replicatedRateLimits = NewReplicatedRateLimits(&ReplicatedRateLimitsOptions{Limit: limit})
+	server := httptest.NewServer(http.HandlerFunc(peer.handleClusterRateLimits))
+	defer server.Close()
+
+	peers, err := NewClusterPeers(&ClusterPeersOptions{InstanceID: "a", AdvertiseURL: "http://a:8088"})
+	require.NoError(t, err)
+	peers.merge([]ClusterPeer{{InstanceID: "b", URL: server.URL, LastSeen: time.Now()}})
+
+	limits := NewReplicatedRateLimits(&ReplicatedRateLimitsOptions{Logger: zap.NewNop(), Peers: peers, Limit: limit})
+	ctx := context.Background()
+	_, err = limits.AllowN(ctx, "client", limit, 3)
+	require.NoError(t, err)
+
+	limits.sync(ctx)
+
+	result, err := peer.replicatedRateLimits.AllowN(ctx, "client", limit, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Remaining)
+
+	// The consumed tokens are sent once
+	limits.sync(ctx)
+	result, err = peer.replicatedRateLimits.AllowN(ctx, "client", limit, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Remaining)
+}
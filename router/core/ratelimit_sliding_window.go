@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis_rate/v10"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript counts the requests of the current and the previous period of the key. The requests of the
+// previous period are weighted with the share of the sliding window that overlaps it.
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+
+local period = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local window = math.floor(now / period)
+local elapsed = now - window * period
+
+local current_key = KEYS[1] .. ":" .. window
+local current = tonumber(redis.call("GET", current_key) or "0")
+local previous = tonumber(redis.call("GET", KEYS[1] .. ":" .. (window - 1)) or "0")
+
+local allowed = 0
+if n > 0 and previous * (period - elapsed) / period + current + n <= limit then
+  current = redis.call("INCRBY", current_key, n)
+  redis.call("PEXPIRE", current_key, period * 2)
+  allowed = n
+end
+
+return {allowed, current, previous, elapsed}
+`)
+
+// slidingWindowLimiter limits the requests of a key to the rate in every window of the period in Redis. The window
+// is approximated with the counts of the current and the previous period, so only two counters are kept per key.
+type slidingWindowLimiter struct {
+	client *redis.Client
+}
+
+func (l *slidingWindowLimiter) AllowN(ctx context.Context, key string, limit redis_rate.Limit, n int) (*redis_rate.Result, error) {
+	values, err := slidingWindowScript.Run(ctx, l.client, []string{"sliding:" + key}, limit.Period.Milliseconds(), limit.Rate, n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return slidingWindowResult(limit, n, int(values[0]), int(values[1]), int(values[2]), time.Duration(values[3])*time.Millisecond), nil
+}
+
+// slidingWindowResult returns the result of the counts of the current and the previous period at the elapsed time of
+// the current period
+func slidingWindowResult(limit redis_rate.Limit, n, allowed, current, previous int, elapsed time.Duration) *redis_rate.Result {
+	period := float64(limit.Period)
+	count := float64(previous)*(period-float64(elapsed))/period + float64(current)
+
+	result := &redis_rate.Result{
+		Limit:      limit,
+		Allowed:    allowed,
+		Remaining:  max(0, limit.Rate-int(math.Ceil(count))),
+		RetryAfter: -1,
+	}
+	if allowed < n {
+		result.RetryAfter = slidingWindowRetryAfter(limit, n, current, previous, elapsed)
+	}
+
+	// The requests of the current period count until the end of the next one
+	switch {
+	case current > 0:
+		result.ResetAfter = 2*limit.Period - elapsed
+	case previous > 0:
+		result.ResetAfter = limit.Period - elapsed
+	}
+
+	return result
+}
+
+// slidingWindowRetryAfter returns the time until the window has room for n requests
+func slidingWindowRetryAfter(limit redis_rate.Limit, n, current, previous int, elapsed time.Duration) time.Duration {
+	period := float64(limit.Period)
+	free := float64(limit.Rate - n)
+	if free < 0 {
+		return limit.Period
+	}
+
+	// The current period alone exceeds the limit, so the request has to wait until enough of it left the window
+	if float64(current) > free {
+		return time.Duration(period - float64(elapsed) + period*(1-free/float64(current)))
+	}
+	// Otherwise, until enough of the previous period left the window
+	return time.Duration(period - float64(elapsed) - (free-float64(current))*period/float64(previous))
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis_rate/v10"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlidingWindowResult(t *testing.T) {
+	t.Parallel()
+
+	limit := redis_rate.Limit{Rate: 10, Period: time.Minute}
+
+	// A quarter of the previous period still overlaps the window: 8 * 0.25 + 6 = 8 requests
+	result := slidingWindowResult(limit, 1, 1, 6, 8, 45*time.Second)
+	require.Equal(t, 1, result.Allowed)
+	require.Equal(t, 2, result.Remaining)
+	require.Equal(t, time.Duration(-1), result.RetryAfter)
+	require.Equal(t, 75*time.Second, result.ResetAfter)
+
+	// 8 * 0.75 + 4 = 10 requests, there is room again when 1 request of the previous period left the window
+	result = slidingWindowResult(limit, 1, 0, 4, 8, 15*time.Second)
+	require.Equal(t, 0, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	require.Equal(t, 7500*time.Millisecond, result.RetryAfter)
+
+	// The current period alone is full, so the request waits until 1 of its requests left the window
+	result = slidingWindowResult(limit, 1, 0, 10, 0, 30*time.Second)
+	require.Equal(t, 0, result.Allowed)
+	require.Equal(t, 36*time.Second, result.RetryAfter)
+	require.Equal(t, 90*time.Second, result.ResetAfter)
+
+	// Only the previous period counts
+	result = slidingWindowResult(limit, 0, 0, 0, 4, 30*time.Second)
+	require.Equal(t, 8, result.Remaining)
+	require.Equal(t, 30*time.Second, result.ResetAfter)
+}
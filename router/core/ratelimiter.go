@@ -4,36 +4,74 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"strconv"
 	"sync"
 
 	"github.com/go-redis/redis_rate/v10"
 	"github.com/redis/go-redis/v9"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
 )
 
 var (
 	ErrRateLimitExceeded = errors.New("rate limit exceeded")
 )
 
+const (
+	// RateLimitKeyByClientName gives every client name its own quota
+	RateLimitKeyByClientName = "client_name"
+	// RateLimitKeyByClaim gives every value of a claim of the authenticated requests its own quota
+	RateLimitKeyByClaim = "claim"
+	// RateLimitKeyByTag gives every value of a request tag its own quota
+	RateLimitKeyByTag = "tag"
+)
+
+const (
+	// RateLimitAlgorithmTokenBucket refills the quota with the rate per period and allows bursts
+	RateLimitAlgorithmTokenBucket = "token_bucket"
+	// RateLimitAlgorithmSlidingWindow allows the rate in every window of the period
+	RateLimitAlgorithmSlidingWindow = "sliding_window"
+)
+
 type CosmoRateLimiterOptions struct {
 	RedisClient *redis.Client
-	Debug       bool
+	// Replicated keeps the quotas in memory and replicates them to the cluster peers instead of Redis
+	Replicated *ReplicatedRateLimits
+	// SlidingWindow limits the requests in Redis with a sliding window instead of a token bucket
+	SlidingWindow bool
+	Debug         bool
 }
 
 func NewCosmoRateLimiter(opts *CosmoRateLimiterOptions) *CosmoRateLimiter {
-	limiter := redis_rate.NewLimiter(opts.RedisClient)
+	var limiter rateLimitStore = opts.Replicated
+	if opts.Replicated == nil {
+		if opts.SlidingWindow {
+			limiter = &slidingWindowLimiter{client: opts.RedisClient}
+		} else {
+			limiter = redis_rate.NewLimiter(opts.RedisClient)
+		}
+	}
 	return &CosmoRateLimiter{
-		client:  opts.RedisClient,
-		limiter: limiter,
-		debug:   opts.Debug,
+		limiter:       limiter,
+		slidingWindow: opts.SlidingWindow && opts.Replicated == nil,
+		debug:         opts.Debug,
 	}
 }
 
 type CosmoRateLimiter struct {
-	client  *redis.Client
-	limiter *redis_rate.Limiter
-	debug   bool
+	limiter       rateLimitStore
+	slidingWindow bool
+	debug         bool
+}
+
+// rateLimitingEnabled reports whether the quotas are stored in Redis or replicated to the cluster peers
+func (c *Config) rateLimitingEnabled() bool {
+	return c.redisClient != nil || c.replicatedRateLimits != nil
 }
 
 func (c *CosmoRateLimiter) RateLimitPreFetch(ctx *resolve.Context, info *resolve.FetchInfo, input json.RawMessage) (result *resolve.RateLimitDeny, err error) {
@@ -54,6 +92,7 @@ func (c *CosmoRateLimiter) RateLimitPreFetch(ctx *resolve.Context, info *resolve
 	if allow.Allowed >= requestRate {
 		return nil, nil
 	}
+	c.setRateLimited(ctx)
 	if ctx.RateLimitOptions.RejectExceedingRequests {
 		return nil, ErrRateLimitExceeded
 	}
@@ -67,6 +106,11 @@ type RateLimitStats struct {
 	ResetAfterMilliseconds int64 `json:"resetAfterMs"`
 }
 
+// Quota returns the state of the quota of the key without consuming it
+func (c *CosmoRateLimiter) Quota(ctx context.Context, key string, limit redis_rate.Limit) (*redis_rate.Result, error) {
+	return c.limiter.AllowN(ctx, key, limit, 0)
+}
+
 func (c *CosmoRateLimiter) RenderResponseExtension(ctx *resolve.Context, out io.Writer) error {
 	data, err := c.statsJSON(ctx)
 	if err != nil {
@@ -94,12 +138,50 @@ func (c *CosmoRateLimiter) calculateRate() int {
 }
 
 func (c *CosmoRateLimiter) statsJSON(ctx *resolve.Context) ([]byte, error) {
+	return json.Marshal(c.stats(ctx))
+}
+
+// stats returns the stats of the request, with stable times in the debug mode
+func (c *CosmoRateLimiter) stats(ctx *resolve.Context) RateLimitStats {
 	stats := c.getRateLimitStats(ctx)
 	if c.debug {
 		stats.ResetAfterMilliseconds = 1234
 		stats.RetryAfterMilliseconds = 1234
 	}
-	return json.Marshal(stats)
+	return stats
+}
+
+// SetResponseHeaders sets the RateLimit headers of the quota of the request, and Retry-After if a fetch of the
+// request was limited. Requests without a limited fetch, e.g. introspection queries, get no headers.
+func (c *CosmoRateLimiter) SetResponseHeaders(ctx *resolve.Context, header http.Header) {
+	stats := c.stats(ctx)
+	if stats.RequestRate == 0 {
+		return
+	}
+
+	opts := ctx.RateLimitOptions
+	window := rateLimitSeconds(opts.Period.Milliseconds())
+	if c.slidingWindow {
+		header.Set("RateLimit-Limit", strconv.Itoa(opts.Rate))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", opts.Rate, window))
+	} else {
+		header.Set("RateLimit-Limit", strconv.Itoa(opts.Burst))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", opts.Rate, window, opts.Burst))
+	}
+	header.Set("RateLimit-Remaining", strconv.Itoa(stats.Remaining))
+	header.Set("RateLimit-Reset", strconv.FormatInt(rateLimitSeconds(stats.ResetAfterMilliseconds), 10))
+
+	if c.rateLimited(ctx) {
+		header.Set("Retry-After", strconv.FormatInt(rateLimitSeconds(stats.RetryAfterMilliseconds), 10))
+	}
+}
+
+// rateLimitSeconds rounds the milliseconds up to whole seconds, so that the clients don't retry too early
+func rateLimitSeconds(milliseconds int64) int64 {
+	if milliseconds <= 0 {
+		return 0
+	}
+	return (milliseconds + 999) / 1000
 }
 
 func (c *CosmoRateLimiter) setRateLimitStats(ctx *resolve.Context, requestRate, remaining int, retryAfter, resetAfter int64) {
@@ -116,6 +198,28 @@ func (c *CosmoRateLimiter) setRateLimitStats(ctx *resolve.Context, requestRate,
 	statsCtx.mux.Unlock()
 }
 
+func (c *CosmoRateLimiter) setRateLimited(ctx *resolve.Context) {
+	v := ctx.Context().Value(rateLimitStatsCtxKey{})
+	if v == nil {
+		return
+	}
+	statsCtx := v.(*rateLimitStatsCtx)
+	statsCtx.mux.Lock()
+	statsCtx.limited = true
+	statsCtx.mux.Unlock()
+}
+
+func (c *CosmoRateLimiter) rateLimited(ctx *resolve.Context) bool {
+	v := ctx.Context().Value(rateLimitStatsCtxKey{})
+	if v == nil {
+		return false
+	}
+	statsCtx := v.(*rateLimitStatsCtx)
+	statsCtx.mux.Lock()
+	defer statsCtx.mux.Unlock()
+	return statsCtx.limited
+}
+
 func (c *CosmoRateLimiter) getRateLimitStats(ctx *resolve.Context) RateLimitStats {
 	v := ctx.Context().Value(rateLimitStatsCtxKey{})
 	if v == nil {
@@ -125,9 +229,37 @@ func (c *CosmoRateLimiter) getRateLimitStats(ctx *resolve.Context) RateLimitStat
 	return statsCtx.stats
 }
 
+// rateLimitKey returns the key of the quota of a request and the client it belongs to. Requests without a client
+// share the quota of the key prefix.
+func rateLimitKey(cfg *config.RateLimitConfiguration, clientInfo *ClientInfo, auth authentication.Authentication, tags requestTags) (key string, client string) {
+	switch cfg.KeyBy {
+	case RateLimitKeyByClientName:
+		if clientInfo != nil {
+			client = clientInfo.Name
+		}
+	case RateLimitKeyByClaim:
+		if auth != nil {
+			client, _ = auth.Claims()[cfg.KeyClaim].(string)
+		}
+	case RateLimitKeyByTag:
+		client = tags.get(cfg.KeyTag)
+	}
+	return rateLimitClientKey(cfg, client), client
+}
+
+// rateLimitClientKey returns the key of the quota of the client, or the shared key of the requests without a client
+func rateLimitClientKey(cfg *config.RateLimitConfiguration, client string) string {
+	if client == "" {
+		return cfg.Storage.KeyPrefix
+	}
+	return cfg.Storage.KeyPrefix + ":" + client
+}
+
 type rateLimitStatsCtx struct {
 	stats RateLimitStats
-	mux   sync.Mutex
+	// limited is set when a fetch of the request exceeded the quota
+	limited bool
+	mux     sync.Mutex
 }
 
 type rateLimitStatsCtxKey struct{}
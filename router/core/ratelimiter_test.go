@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+type testAuthentication struct {
+	claims authentication.Claims
+}
+
+func (a *testAuthentication) Authenticator() string         { return "test" }
+func (a *testAuthentication) Claims() authentication.Claims { return a.claims }
+func (a *testAuthentication) SetScopes(scopes []string)     {}
+func (a *testAuthentication) Scopes() []string              { return nil }
+
+func TestRateLimitKey(t *testing.T) {
+	clientInfo := &ClientInfo{Name: "my-client"}
+	auth := &testAuthentication{claims: authentication.Claims{"sub": "user-1", "tenant": 42}}
+
+	t.Run("shares the quota by default", func(t *testing.T) {
+		cfg := &config.RateLimitConfiguration{Storage: config.RedisConfiguration{KeyPrefix: "prefix"}}
+		key, client := rateLimitKey(cfg, clientInfo, auth, nil)
+		require.Equal(t, "prefix", key)
+		require.Empty(t, client)
+	})
+
+	t.Run("keys by the client name", func(t *testing.T) {
+		cfg := &config.RateLimitConfiguration{
+			KeyBy:   RateLimitKeyByClientName,
+			Storage: config.RedisConfiguration{KeyPrefix: "prefix"},
+		}
+		key, client := rateLimitKey(cfg, clientInfo, nil, nil)
+		require.Equal(t, "prefix:my-client", key)
+		require.Equal(t, "my-client", client)
+	})
+
+	t.Run("keys by the claim", func(t *testing.T) {
+		cfg := &config.RateLimitConfiguration{
+			KeyBy:    RateLimitKeyByClaim,
+			KeyClaim: "sub",
+			Storage:  config.RedisConfiguration{KeyPrefix: "prefix"},
+		}
+		key, client := rateLimitKey(cfg, clientInfo, auth, nil)
+		require.Equal(t, "prefix:user-1", key)
+		require.Equal(t, "user-1", client)
+
+		// Unauthenticated requests share the quota
+		key, client = rateLimitKey(cfg, clientInfo, nil, nil)
+		require.Equal(t, "prefix", key)
+		require.Empty(t, client)
+
+		// Only string claims identify a client
+		cfg.KeyClaim = "tenant"
+		key, _ = rateLimitKey(cfg, clientInfo, auth, nil)
+		require.Equal(t, "prefix", key)
+	})
+
+	t.Run("keys by the request tag", func(t *testing.T) {
+		cfg := &config.RateLimitConfiguration{
+			KeyBy:   RateLimitKeyByTag,
+			KeyTag:  "tenant",
+			Storage: config.RedisConfiguration{KeyPrefix: "prefix"},
+		}
+		key, client := rateLimitKey(cfg, clientInfo, auth, requestTags{{name: "tenant", value: "acme"}})
+		require.Equal(t, "prefix:acme", key)
+		require.Equal(t, "acme", client)
+
+		// Requests without the tag share the quota
+		key, client = rateLimitKey(cfg, clientInfo, auth, requestTags{{name: "plan", value: "free"}})
+		require.Equal(t, "prefix", key)
+		require.Empty(t, client)
+	})
+}
+
+func TestRateLimitResponseHeaders(t *testing.T) {
+	limiter := NewCosmoRateLimiter(&CosmoRateLimiterOptions{
+		Replicated: NewReplicatedRateLimits(&ReplicatedRateLimitsOptions{}),
+	})
+
+	ctx := WithRateLimiterStats(resolve.NewContext(context.Background()))
+	ctx.RateLimitOptions = resolve.RateLimitOptions{
+		Rate:         1,
+		Burst:        2,
+		Period:       time.Minute,
+		RateLimitKey: "prefix",
+	}
+
+	// No fetch consumed the quota yet
+	header := http.Header{}
+	limiter.SetResponseHeaders(ctx, header)
+	require.Empty(t, header)
+
+	for i := 0; i < 2; i++ {
+		deny, err := limiter.RateLimitPreFetch(ctx, &resolve.FetchInfo{}, nil)
+		require.NoError(t, err)
+		require.Nil(t, deny)
+	}
+	limiter.SetResponseHeaders(ctx, header)
+	require.Equal(t, "2", header.Get("RateLimit-Limit"))
+	require.Equal(t, "0", header.Get("RateLimit-Remaining"))
+	require.Equal(t, "120", header.Get("RateLimit-Reset"))
+	require.Equal(t, "1;w=60;burst=2", header.Get("RateLimit-Policy"))
+	require.Empty(t, header.Get("Retry-After"))
+
+	deny, err := limiter.RateLimitPreFetch(ctx, &resolve.FetchInfo{}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, deny)
+	limiter.SetResponseHeaders(ctx, header)
+	require.Equal(t, "60", header.Get("Retry-After"))
+}
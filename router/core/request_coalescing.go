@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+)
+
+// RequestCoalescing executes identical concurrent queries once and serves the response to all of their callers, e.g.
+// to absorb a stampede of clients whose caches expired at the same time. Queries are identical when their normalized
+// operation, variables and extensions, their tenant, the claims of their token and the values of the scope headers
+// are equal, so that responses are only shared within the same authorization scope.
+type RequestCoalescing struct {
+	scopeHeaders []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type RequestCoalescingOptions struct {
+	// ScopeHeaders are the request headers whose values scope the coalescing, e.g. the Authorization header
+	ScopeHeaders []string
+}
+
+// coalescedCall is an execution that the callers of an identical query wait for
+type coalescedCall struct {
+	done chan struct{}
+	// waiters is guarded by the mutex of the coalescing
+	waiters int
+	// shared reports whether the execution succeeded. Otherwise, the waiting callers execute the query on their own.
+	shared   bool
+	response []byte
+}
+
+func NewRequestCoalescing(opts *RequestCoalescingOptions) *RequestCoalescing {
+	scopeHeaders := make([]string, 0, len(opts.ScopeHeaders))
+	for _, name := range opts.ScopeHeaders {
+		scopeHeaders = append(scopeHeaders, http.CanonicalHeaderKey(name))
+	}
+	return &RequestCoalescing{
+		scopeHeaders: scopeHeaders,
+		calls:        make(map[string]*coalescedCall),
+	}
+}
+
+// key returns the key of the identical queries of the request. Mutations, uploads and traced requests aren't coalesced.
+func (c *RequestCoalescing) key(r *http.Request, operationCtx *operationContext) (string, bool) {
+	if c == nil || operationCtx.Type() != "query" || len(operationCtx.Files()) > 0 || operationCtx.traceOptions.Enable {
+		return "", false
+	}
+
+	d := xxhash.New()
+	write := func(value []byte) {
+		_, _ = d.WriteString(strconv.Itoa(len(value)))
+		_, _ = d.WriteString(":")
+		_, _ = d.Write(value)
+	}
+
+	write([]byte(operationCtx.Content()))
+	write(operationCtx.Variables())
+	write(operationCtx.extensions)
+
+	if tenant := tenantOf(r.Context()); tenant != nil {
+		write([]byte(tenant.id))
+	}
+	if auth := authentication.FromContext(r.Context()); auth != nil {
+		// The keys of the claims are sorted, so that the same claims have the same key
+		claims, err := json.Marshal(auth.Claims())
+		if err != nil {
+			return "", false
+		}
+		write([]byte(auth.Authenticator()))
+		write(claims)
+	}
+	for _, name := range c.scopeHeaders {
+		write([]byte(name))
+		for _, value := range r.Header.Values(name) {
+			write([]byte(value))
+		}
+	}
+
+	return strconv.FormatUint(d.Sum64(), 16), true
+}
+
+// do writes the response of the query to buf. The first caller of a key executes the query with execute, which
+// writes its response to buf. The callers of the same key that arrive in the meantime wait for the execution and
+// receive a copy of its response. If the execution failed or its context ended, they execute the query on their
+// own. It reports whether the response was shared by the execution of another caller.
+func (c *RequestCoalescing) do(ctx context.Context, key string, buf *bytes.Buffer, execute func() error) (bool, error) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		call.waiters++
+		c.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		if !call.shared {
+			return false, execute()
+		}
+		_, err := buf.Write(call.response)
+		return true, err
+	}
+
+	call := &coalescedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	err := execute()
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	waiters := call.waiters
+	c.mu.Unlock()
+
+	// The response is only copied when other callers wait for it. They read it after the call is done.
+	if waiters > 0 && err == nil && ctx.Err() == nil {
+		call.shared = true
+		call.response = bytes.Clone(buf.Bytes())
+	}
+	close(call.done)
+
+	return false, err
+}
+
+// Waiting returns the number of callers that wait for the executions of identical queries
+func (c *RequestCoalescing) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	waiting := 0
+	for _, call := range c.calls {
+		waiting += call.waiters
+	}
+	return waiting
+}
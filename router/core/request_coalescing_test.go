@@ -0,0 +1,173 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+)
+
+func TestRequestCoalescing(t *testing.T) {
+	t.Parallel()
+
+	t.Run("scopes the key of the queries", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewRequestCoalescing(&RequestCoalescingOptions{ScopeHeaders: []string{"authorization"}})
+		query := &operationContext{opType: "query", content: "{employees{id}}", variables: []byte(`{}`)}
+
+		newRequest := func(authorization string) *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			if authorization != "" {
+				r.Header.Set("Authorization", authorization)
+			}
+			return r
+		}
+
+		key, ok := c.key(newRequest("Bearer a"), query)
+		require.True(t, ok)
+		same, _ := c.key(newRequest("Bearer a"), query)
+		require.Equal(t, key, same)
+
+		other, _ := c.key(newRequest("Bearer b"), query)
+		require.NotEqual(t, key, other)
+
+		otherVariables, _ := c.key(newRequest("Bearer a"), &operationContext{opType: "query", content: "{employees{id}}", variables: []byte(`{"a":1}`)})
+		require.NotEqual(t, key, otherVariables)
+
+		r := newRequest("Bearer a")
+		r = r.WithContext(authentication.NewContext(r.Context(), &testAuthentication{claims: authentication.Claims{"sub": "alice"}}))
+		otherClaims, _ := c.key(r, query)
+		require.NotEqual(t, key, otherClaims)
+
+		_, ok = c.key(newRequest(""), &operationContext{opType: "mutation", content: "mutation{updateEmployee}"})
+		require.False(t, ok)
+
+		var disabled *RequestCoalescing
+		_, ok = disabled.key(newRequest(""), query)
+		require.False(t, ok)
+	})
+
+	t.Run("shares the response of the execution", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewRequestCoalescing(&RequestCoalescingOptions{})
+		release := make(chan struct{})
+		var executions atomic.Int32
+
+		execute := func(buf *bytes.Buffer) func() error {
+			return func() error {
+				executions.Add(1)
+				<-release
+				buf.WriteString(`{"data":{}}`)
+				return nil
+			}
+		}
+
+		leaderBuf := &bytes.Buffer{}
+		leaderDone := make(chan struct{})
+		go func() {
+			defer close(leaderDone)
+			shared, err := c.do(context.Background(), "key", leaderBuf, execute(leaderBuf))
+			require.NoError(t, err)
+			require.False(t, shared)
+		}()
+		require.Eventually(t, func() bool { return executions.Load() == 1 }, time.Second, time.Millisecond)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				buf := &bytes.Buffer{}
+				shared, err := c.do(context.Background(), "key", buf, execute(buf))
+				require.NoError(t, err)
+				require.True(t, shared)
+				require.Equal(t, `{"data":{}}`, buf.String())
+			}()
+		}
+		require.Eventually(t, func() bool { return c.Waiting() == 3 }, time.Second, time.Millisecond)
+
+		close(release)
+		wg.Wait()
+		<-leaderDone
+		require.Equal(t, int32(1), executions.Load())
+		require.Equal(t, `{"data":{}}`, leaderBuf.String())
+		require.Zero(t, c.Waiting())
+	})
+
+	t.Run("executes the query again when the execution failed", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewRequestCoalescing(&RequestCoalescingOptions{})
+		release := make(chan struct{})
+		failed := errors.New("failed")
+
+		leaderDone := make(chan struct{})
+		go func() {
+			defer close(leaderDone)
+			_, err := c.do(context.Background(), "key", &bytes.Buffer{}, func() error {
+				<-release
+				return failed
+			})
+			require.ErrorIs(t, err, failed)
+		}()
+		require.Eventually(t, func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return len(c.calls) == 1
+		}, time.Second, time.Millisecond)
+
+		waiterDone := make(chan struct{})
+		go func() {
+			defer close(waiterDone)
+			buf := &bytes.Buffer{}
+			shared, err := c.do(context.Background(), "key", buf, func() error {
+				buf.WriteString(`{"data":{}}`)
+				return nil
+			})
+			require.NoError(t, err)
+			require.False(t, shared)
+			require.Equal(t, `{"data":{}}`, buf.String())
+		}()
+		require.Eventually(t, func() bool { return c.Waiting() == 1 }, time.Second, time.Millisecond)
+
+		close(release)
+		<-leaderDone
+		<-waiterDone
+	})
+
+	t.Run("stops waiting when the context of the caller ends", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewRequestCoalescing(&RequestCoalescingOptions{})
+		release := make(chan struct{})
+		defer close(release)
+
+		go func() {
+			_, _ = c.do(context.Background(), "key", &bytes.Buffer{}, func() error {
+				<-release
+				return nil
+			})
+		}()
+		require.Eventually(t, func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return len(c.calls) == 1
+		}, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := c.do(ctx, "key", &bytes.Buffer{}, func() error { return nil })
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// ErrRequestMemoryLimitExceeded is the cause of the context of a request that exceeded its memory limit
+var ErrRequestMemoryLimitExceeded = errors.New("request exceeds the memory limit")
+
+// RequestMemoryLimitExceededErrorCode is the code in the extensions of the error of a request that was aborted
+// because it exceeded its memory limit
+const RequestMemoryLimitExceededErrorCode = "REQUEST_MEMORY_LIMIT_EXCEEDED"
+
+// subgraphResponseMemoryFactor approximates the memory of a subgraph response. The engine keeps the body of the
+// response and the values decoded from it until the response to the client is assembled.
+const subgraphResponseMemoryFactor = 2
+
+// RequestMemoryLimit limits the approximate memory of a single request. The operation and its variables, the
+// responses of the subgraphs and the assembled response are accounted. A request over the limit is aborted, so
+// that a single request can't exhaust the memory of the router.
+type RequestMemoryLimit struct {
+	maxSize int64
+}
+
+func NewRequestMemoryLimit(cfg *config.RequestMemoryLimitConfiguration) (*RequestMemoryLimit, error) {
+	if cfg.MaxSize == 0 {
+		return nil, errors.New("the request memory limit must be greater than zero")
+	}
+	if uint64(cfg.MaxSize) > math.MaxInt64 {
+		return nil, fmt.Errorf("the request memory limit must not exceed %d bytes", int64(math.MaxInt64))
+	}
+	return &RequestMemoryLimit{maxSize: int64(cfg.MaxSize)}, nil
+}
+
+// MaxSize returns the maximum memory of a request in bytes
+func (l *RequestMemoryLimit) MaxSize() int64 {
+	return l.maxSize
+}
+
+// newBudget returns the memory budget of a request and a context that is canceled with
+// ErrRequestMemoryLimitExceeded as cause when the budget is exceeded. Without a limit, the budget is nil and
+// the context is returned unchanged. Subscriptions aren't limited, because their memory is released after every event.
+func (l *RequestMemoryLimit) newBudget(ctx context.Context, operationType string) (*memoryBudget, context.Context, context.CancelFunc) {
+	if l == nil || operationType == "subscription" {
+		return nil, ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	budget := &memoryBudget{maxSize: l.maxSize, cancel: cancel}
+	return budget, ctx, func() { cancel(nil) }
+}
+
+// isRequestMemoryLimitExceeded returns true if the context was canceled because the request exceeded its memory limit
+func isRequestMemoryLimitExceeded(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrRequestMemoryLimitExceeded)
+}
+
+// memoryBudget accounts the memory of a single request. The subgraph responses are read concurrently, so the
+// accounting is safe for concurrent use.
+type memoryBudget struct {
+	maxSize int64
+	used    atomic.Int64
+	cancel  context.CancelCauseFunc
+}
+
+// charge adds n bytes to the memory of the request. When the limit is exceeded, the request is canceled and
+// ErrRequestMemoryLimitExceeded is returned.
+func (b *memoryBudget) charge(n int64) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+	if b.used.Add(n) > b.maxSize {
+		b.cancel(ErrRequestMemoryLimitExceeded)
+		return ErrRequestMemoryLimitExceeded
+	}
+	return nil
+}
+
+// Used returns the accounted memory of the request in bytes
+func (b *memoryBudget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.used.Load()
+}
+
+// memoryBudgetBody charges the bytes read from a subgraph response to the budget of the request
+type memoryBudgetBody struct {
+	io.ReadCloser
+	budget *memoryBudget
+}
+
+func (b *memoryBudgetBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if chargeErr := b.budget.charge(int64(n) * subgraphResponseMemoryFactor); chargeErr != nil {
+		return n, chargeErr
+	}
+	return n, err
+}
+
+// memoryBudgetWriter charges the assembled response to the budget of the request before it is written
+type memoryBudgetWriter struct {
+	w      io.Writer
+	budget *memoryBudget
+}
+
+func (w *memoryBudgetWriter) Write(p []byte) (int, error) {
+	if err := w.budget.charge(int64(len(p))); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestRequestMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cancels the request when the budget is exceeded", func(t *testing.T) {
+		t.Parallel()
+
+		limit, err := NewRequestMemoryLimit(&config.RequestMemoryLimitConfiguration{MaxSize: 100})
+		require.NoError(t, err)
+
+		budget, ctx, cancel := limit.newBudget(context.Background(), "query")
+		defer cancel()
+
+		require.NoError(t, budget.charge(60))
+		require.NoError(t, ctx.Err())
+		require.ErrorIs(t, budget.charge(41), ErrRequestMemoryLimitExceeded)
+		require.Equal(t, int64(101), budget.Used())
+		require.True(t, isRequestMemoryLimitExceeded(ctx))
+	})
+
+	t.Run("accounts the subgraph responses and the response", func(t *testing.T) {
+		t.Parallel()
+
+		limit, err := NewRequestMemoryLimit(&config.RequestMemoryLimitConfiguration{MaxSize: 100})
+		require.NoError(t, err)
+
+		budget, ctx, cancel := limit.newBudget(context.Background(), "query")
+		defer cancel()
+
+		body := &memoryBudgetBody{ReadCloser: io.NopCloser(strings.NewReader(strings.Repeat("x", 40))), budget: budget}
+		_, err = io.ReadAll(body)
+		require.NoError(t, err)
+		require.Equal(t, int64(40*subgraphResponseMemoryFactor), budget.Used())
+
+		var out bytes.Buffer
+		w := &memoryBudgetWriter{w: &out, budget: budget}
+		_, err = w.Write(make([]byte, 21))
+		require.ErrorIs(t, err, ErrRequestMemoryLimitExceeded)
+		require.Zero(t, out.Len())
+		require.True(t, isRequestMemoryLimitExceeded(ctx))
+	})
+
+	t.Run("doesn't limit subscriptions", func(t *testing.T) {
+		t.Parallel()
+
+		limit, err := NewRequestMemoryLimit(&config.RequestMemoryLimitConfiguration{MaxSize: 100})
+		require.NoError(t, err)
+
+		budget, _, cancel := limit.newBudget(context.Background(), "subscription")
+		defer cancel()
+		require.Nil(t, budget)
+		require.NoError(t, budget.charge(1000))
+	})
+
+	t.Run("requires a maximum size", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewRequestMemoryLimit(&config.RequestMemoryLimitConfiguration{})
+		require.Error(t, err)
+	})
+}
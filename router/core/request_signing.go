@@ -0,0 +1,222 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRequestSignatureMissing = errors.New("the request is not signed")
+	ErrRequestSignatureInvalid = errors.New("invalid request signature")
+	ErrRequestSignatureExpired = errors.New("the request signature is outside of the replay window")
+	ErrRequestSignatureReused  = errors.New("the request signature was already used")
+)
+
+type RequestSigningKey struct {
+	ID     string
+	Secret string
+	// Algorithm is either "sha256" or "sha512". Empty defaults to "sha256".
+	Algorithm string
+}
+
+type RequestSignatureVerifierOptions struct {
+	Logger          *zap.Logger
+	Keys            []RequestSigningKey
+	SignatureHeader string
+	TimestampHeader string
+	KeyIDHeader     string
+	ReplayWindow    time.Duration
+}
+
+// RequestSignatureVerifier rejects requests that aren't signed with the HMAC of one of the keys. The signature covers
+// the timestamp, the method, the request URI and the body, so that a signed request can't be altered. Requests are
+// only accepted within the replay window around their timestamp, and every signature only once.
+type RequestSignatureVerifier struct {
+	logger          *zap.Logger
+	keys            map[string]requestSigningKey
+	signatureHeader string
+	timestampHeader string
+	keyIDHeader     string
+	replayWindow    time.Duration
+	now             func() time.Time
+
+	mu sync.Mutex
+	// seen holds the signatures accepted within the replay window with the time they can be forgotten
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+type requestSigningKey struct {
+	secret []byte
+	hash   func() hash.Hash
+}
+
+func NewRequestSignatureVerifier(opts *RequestSignatureVerifierOptions) (*RequestSignatureVerifier, error) {
+	if len(opts.Keys) == 0 {
+		return nil, errors.New("request signing requires at least one key")
+	}
+	if opts.ReplayWindow <= 0 {
+		return nil, errors.New("request signing requires a replay window")
+	}
+	if opts.SignatureHeader == "" || opts.TimestampHeader == "" {
+		return nil, errors.New("request signing requires a signature and a timestamp header")
+	}
+
+	keys := make(map[string]requestSigningKey, len(opts.Keys))
+	for i, key := range opts.Keys {
+		if key.ID == "" || key.Secret == "" {
+			return nil, fmt.Errorf("request signing key %d requires an id and a secret", i)
+		}
+		if _, ok := keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate request signing key '%s'", key.ID)
+		}
+
+		k, err := newRequestSigningKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("request signing key '%s': %w", key.ID, err)
+		}
+		keys[key.ID] = k
+	}
+
+	return &RequestSignatureVerifier{
+		logger:          opts.Logger,
+		keys:            keys,
+		signatureHeader: opts.SignatureHeader,
+		timestampHeader: opts.TimestampHeader,
+		keyIDHeader:     opts.KeyIDHeader,
+		replayWindow:    opts.ReplayWindow,
+		now:             time.Now,
+		seen:            make(map[string]time.Time),
+	}, nil
+}
+
+func (v *RequestSignatureVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.verify(r); err != nil {
+			statusCode := http.StatusUnauthorized
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				statusCode = http.StatusRequestEntityTooLarge
+			}
+			writeHTTPError(r, w, statusCode, err, v.logger)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *RequestSignatureVerifier) verify(r *http.Request) error {
+	signatureHex := r.Header.Get(v.signatureHeader)
+	timestampValue := r.Header.Get(v.timestampHeader)
+	if signatureHex == "" || timestampValue == "" {
+		return ErrRequestSignatureMissing
+	}
+
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return ErrRequestSignatureInvalid
+	}
+
+	timestamp, err := strconv.ParseInt(timestampValue, 10, 64)
+	if err != nil {
+		return ErrRequestSignatureInvalid
+	}
+	now := v.now()
+	signedAt := time.Unix(timestamp, 0)
+	if signedAt.Before(now.Add(-v.replayWindow)) || signedAt.After(now.Add(v.replayWindow)) {
+		return ErrRequestSignatureExpired
+	}
+
+	// The body is read to be signed and restored for the handlers. Its size is limited by the request size middleware.
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if !v.validSignature(r, timestampValue, body, signature) {
+		return ErrRequestSignatureInvalid
+	}
+
+	// A signature is accepted once. It can be forgotten when its timestamp leaves the replay window.
+	return v.markSeen(hex.EncodeToString(signature), signedAt.Add(v.replayWindow), now)
+}
+
+func (v *RequestSignatureVerifier) validSignature(r *http.Request, timestamp string, body, signature []byte) bool {
+	// Without the key ID, all keys are tried, e.g. while a key is rotated
+	if keyID := r.Header.Get(v.keyIDHeader); v.keyIDHeader != "" && keyID != "" {
+		key, ok := v.keys[keyID]
+		return ok && hmac.Equal(signature, key.sign(r, timestamp, body))
+	}
+
+	for _, key := range v.keys {
+		if hmac.Equal(signature, key.sign(r, timestamp, body)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *RequestSignatureVerifier) markSeen(signature string, forgetAt, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if now.Sub(v.lastPrune) > v.replayWindow/10 {
+		for s, t := range v.seen {
+			if now.After(t) {
+				delete(v.seen, s)
+			}
+		}
+		v.lastPrune = now
+	}
+
+	if _, ok := v.seen[signature]; ok {
+		return ErrRequestSignatureReused
+	}
+	v.seen[signature] = forgetAt
+
+	return nil
+}
+
+func newRequestSigningKey(key RequestSigningKey) (requestSigningKey, error) {
+	k := requestSigningKey{secret: []byte(key.Secret)}
+	switch key.Algorithm {
+	case "", "sha256":
+		k.hash = sha256.New
+	case "sha512":
+		k.hash = sha512.New
+	default:
+		return k, fmt.Errorf("unknown algorithm '%s'", key.Algorithm)
+	}
+	return k, nil
+}
+
+// sign returns the HMAC of "<timestamp>.<method>.<request uri>.<body>"
+func (k requestSigningKey) sign(r *http.Request, timestamp string, body []byte) []byte {
+	mac := hmac.New(k.hash, k.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(r.Method))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(r.URL.RequestURI()))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func signTestRequest(r *http.Request, h func() hash.Hash, secret string, timestamp time.Time, body string) {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(ts + "." + r.Method + "." + r.URL.RequestURI() + "." + body))
+
+	r.Header.Set("X-Signature-Timestamp", ts)
+	r.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestRequestSignatureVerifier(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"query":"{ employees { id } }"}`
+
+	newVerifier := func(t *testing.T) (*RequestSignatureVerifier, http.Handler) {
+		v, err := NewRequestSignatureVerifier(&RequestSignatureVerifierOptions{
+			Logger: zap.NewNop(),
+			Keys: []RequestSigningKey{
+				{ID: "gateway", Secret: "gateway-secret"},
+				{ID: "mobile", Secret: "mobile-secret", Algorithm: "sha512"},
+			},
+			SignatureHeader: "X-Signature",
+			TimestampHeader: "X-Signature-Timestamp",
+			KeyIDHeader:     "X-Signature-Key-Id",
+			ReplayWindow:    5 * time.Minute,
+		})
+		require.NoError(t, err)
+
+		handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			_, _ = w.Write(b)
+		}))
+		return v, handler
+	}
+
+	serve := func(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	newRequest := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/graphql?wg_variant=a", strings.NewReader(body))
+	}
+
+	t.Run("validates the options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewRequestSignatureVerifier(&RequestSignatureVerifierOptions{
+			Logger: zap.NewNop(), SignatureHeader: "X-Signature", TimestampHeader: "X-Signature-Timestamp", ReplayWindow: time.Minute,
+		})
+		require.Error(t, err)
+		_, err = NewRequestSignatureVerifier(&RequestSignatureVerifierOptions{
+			Logger: zap.NewNop(), SignatureHeader: "X-Signature", TimestampHeader: "X-Signature-Timestamp", ReplayWindow: time.Minute,
+			Keys: []RequestSigningKey{{ID: "a", Secret: "b", Algorithm: "md5"}},
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("accepts signed requests and restores the body", func(t *testing.T) {
+		t.Parallel()
+
+		_, handler := newVerifier(t)
+
+		r := newRequest()
+		signTestRequest(r, sha256.New, "gateway-secret", time.Now(), body)
+		rec := serve(handler, r)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, body, rec.Body.String())
+
+		r = newRequest()
+		signTestRequest(r, sha512.New, "mobile-secret", time.Now(), body)
+		r.Header.Set("X-Signature-Key-Id", "mobile")
+		require.Equal(t, http.StatusOK, serve(handler, r).Code)
+	})
+
+	t.Run("rejects invalid signatures", func(t *testing.T) {
+		t.Parallel()
+
+		_, handler := newVerifier(t)
+
+		rec := serve(handler, newRequest())
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Contains(t, rec.Body.String(), ErrRequestSignatureMissing.Error())
+
+		r := newRequest()
+		signTestRequest(r, sha256.New, "wrong-secret", time.Now(), body)
+		require.Equal(t, http.StatusUnauthorized, serve(handler, r).Code)
+
+		// The signature of the gateway key doesn't match the mobile key
+		r = newRequest()
+		signTestRequest(r, sha256.New, "gateway-secret", time.Now(), body)
+		r.Header.Set("X-Signature-Key-Id", "mobile")
+		require.Equal(t, http.StatusUnauthorized, serve(handler, r).Code)
+
+		// The body was altered after signing
+		r = newRequest()
+		signTestRequest(r, sha256.New, "gateway-secret", time.Now(), `{"query":"{ __typename }"}`)
+		require.Equal(t, http.StatusUnauthorized, serve(handler, r).Code)
+	})
+
+	t.Run("enforces the replay window", func(t *testing.T) {
+		t.Parallel()
+
+		v, handler := newVerifier(t)
+
+		r := newRequest()
+		signTestRequest(r, sha256.New, "gateway-secret", time.Now().Add(-6*time.Minute), body)
+		rec := serve(handler, r)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Contains(t, rec.Body.String(), ErrRequestSignatureExpired.Error())
+
+		signedAt := time.Now()
+		r = newRequest()
+		signTestRequest(r, sha256.New, "gateway-secret", signedAt, body)
+		require.Equal(t, http.StatusOK, serve(handler, r).Code)
+
+		replayed := newRequest()
+		replayed.Header = r.Header.Clone()
+		rec = serve(handler, replayed)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Contains(t, rec.Body.String(), ErrRequestSignatureReused.Error())
+
+		// The signature is forgotten once its timestamp left the window
+		v.now = func() time.Time { return signedAt.Add(6 * time.Minute) }
+		require.NoError(t, v.markSeen("other", signedAt.Add(11*time.Minute), v.now()))
+		require.Len(t, v.seen, 1)
+	})
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+// requestTagCostLimit bounds the cost of the evaluation of a tag, so that an expression can't stall the requests
+const requestTagCostLimit = 10000
+
+// RequestTagger computes the custom tags of the requests with CEL expressions. The same tags are attached to the logs,
+// the traces and the metrics of a request and can key its rate limit, so that all signals of a request can be
+// correlated by e.g. the tenant.
+type RequestTagger struct {
+	tags []requestTagProgram
+}
+
+type requestTagProgram struct {
+	name    string
+	program cel.Program
+}
+
+type requestTag struct {
+	name  string
+	value string
+}
+
+// requestTags are the tags of a request in the order of the configuration
+type requestTags []requestTag
+
+func NewRequestTagger(cfg *config.RequestTagsConfiguration) (*RequestTagger, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("operation", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("client", cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &RequestTagger{tags: make([]requestTagProgram, 0, len(cfg.Tags))}
+	names := make(map[string]struct{}, len(cfg.Tags))
+
+	for _, tag := range cfg.Tags {
+		if tag.Name == "" {
+			return nil, errors.New("the name of a request tag must not be empty")
+		}
+		if _, ok := names[tag.Name]; ok {
+			return nil, fmt.Errorf("duplicate request tag '%s'", tag.Name)
+		}
+		names[tag.Name] = struct{}{}
+
+		ast, issues := env.Compile(tag.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("failed to compile the expression of the request tag '%s': %w", tag.Name, issues.Err())
+		}
+		if kind := ast.OutputType().Kind(); kind != types.StringKind && kind != types.DynKind {
+			return nil, fmt.Errorf("the expression of the request tag '%s' must evaluate to a string, got %s", tag.Name, ast.OutputType())
+		}
+
+		program, err := env.Program(ast, cel.CostLimit(requestTagCostLimit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the program of the request tag '%s': %w", tag.Name, err)
+		}
+		t.tags = append(t.tags, requestTagProgram{name: tag.Name, program: program})
+	}
+
+	return t, nil
+}
+
+// hasTag reports whether the tag is configured
+func (t *RequestTagger) hasTag(name string) bool {
+	if t == nil {
+		return false
+	}
+	for _, tag := range t.tags {
+		if tag.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags evaluates the tags of the request. The operation is nil for requests without an operation, e.g. of the quota
+// endpoint. Tags whose expression fails or doesn't evaluate to a non-empty string are omitted.
+func (t *RequestTagger) Tags(r *http.Request, operation *operationContext, clientInfo *ClientInfo) requestTags {
+	if t == nil || len(t.tags) == 0 {
+		return nil
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	claims := map[string]any{}
+	if auth := authentication.FromContext(r.Context()); auth != nil && auth.Claims() != nil {
+		claims = auth.Claims()
+	}
+
+	// The fields are always set, so that the expressions don't fail for requests without an operation or a client
+	operationVars := map[string]string{"name": "", "type": "", "hash": ""}
+	if operation != nil {
+		operationVars["name"] = operation.name
+		operationVars["type"] = operation.opType
+		operationVars["hash"] = strconv.FormatUint(operation.fingerprint, 10)
+	}
+
+	clientVars := map[string]string{"name": "", "version": ""}
+	if clientInfo != nil {
+		clientVars["name"] = clientInfo.Name
+		clientVars["version"] = clientInfo.Version
+	}
+
+	vars := map[string]any{
+		"request": map[string]any{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"header": headers,
+		},
+		"claims":    claims,
+		"operation": operationVars,
+		"client":    clientVars,
+	}
+
+	tags := make(requestTags, 0, len(t.tags))
+	for _, tag := range t.tags {
+		out, _, err := tag.program.Eval(vars)
+		if err != nil {
+			continue
+		}
+		if value, ok := out.Value().(string); ok && value != "" {
+			tags = append(tags, requestTag{name: tag.name, value: value})
+		}
+	}
+
+	return tags
+}
+
+// get returns the value of the tag or an empty string if the request has no such tag
+func (t requestTags) get(name string) string {
+	for _, tag := range t {
+		if tag.name == name {
+			return tag.value
+		}
+	}
+	return ""
+}
+
+// attributes returns the tags as attributes of the spans and the metrics
+func (t requestTags) attributes() []attribute.KeyValue {
+	attributes := make([]attribute.KeyValue, len(t))
+	for i, tag := range t {
+		attributes[i] = attribute.String(otel.WgRequestTagPrefix+tag.name, tag.value)
+	}
+	return attributes
+}
+
+func (t requestTags) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, tag := range t {
+		enc.AddString(tag.name, tag.value)
+	}
+	return nil
+}
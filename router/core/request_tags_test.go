@@ -0,0 +1,99 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestRequestTagger(t *testing.T) {
+	t.Parallel()
+
+	tagger, err := NewRequestTagger(&config.RequestTagsConfiguration{
+		Tags: []config.RequestTag{
+			{Name: "tenant", Expression: `"tenant" in claims ? claims.tenant : request.header["x-tenant"]`},
+			{Name: "operation", Expression: `operation.type + ":" + operation.name`},
+			{Name: "client", Expression: `client.name + "@" + client.version`},
+			{Name: "method", Expression: `request.method`},
+			{Name: "missing", Expression: `request.header["x-missing"]`},
+			{Name: "empty", Expression: `""`},
+		},
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	r.Header.Set("X-Tenant", "acme")
+
+	operation := &operationContext{name: "Employees", opType: "query"}
+	clientInfo := &ClientInfo{Name: "web", Version: "1.0.0"}
+
+	tags := tagger.Tags(r, operation, clientInfo)
+	assert.Equal(t, requestTags{
+		{name: "tenant", value: "acme"},
+		{name: "operation", value: "query:Employees"},
+		{name: "client", value: "web@1.0.0"},
+		{name: "method", value: "POST"},
+	}, tags)
+	assert.Equal(t, "acme", tags.get("tenant"))
+	assert.Empty(t, tags.get("missing"))
+	assert.Contains(t, tags.attributes(), attribute.String("wg.request.tag.tenant", "acme"))
+
+	// The claims of the authenticated request take precedence
+	auth := &testAuthentication{claims: authentication.Claims{"tenant": "globex"}}
+	r = r.WithContext(authentication.NewContext(r.Context(), auth))
+	assert.Equal(t, "globex", tagger.Tags(r, operation, clientInfo).get("tenant"))
+
+	// Requests without an operation, e.g. of the quota endpoint
+	tags = tagger.Tags(r, nil, nil)
+	assert.Equal(t, ":", tags.get("operation"))
+	assert.Equal(t, "@", tags.get("client"))
+
+	var nilTagger *RequestTagger
+	assert.Nil(t, nilTagger.Tags(r, operation, clientInfo))
+}
+
+func TestNewRequestTaggerValidation(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		tags []config.RequestTag
+		err  string
+	}{
+		"empty name": {
+			tags: []config.RequestTag{{Expression: `"a"`}},
+			err:  "the name of a request tag must not be empty",
+		},
+		"duplicate name": {
+			tags: []config.RequestTag{{Name: "a", Expression: `"a"`}, {Name: "a", Expression: `"b"`}},
+			err:  "duplicate request tag 'a'",
+		},
+		"invalid expression": {
+			tags: []config.RequestTag{{Name: "a", Expression: `request.header[`}},
+			err:  "failed to compile the expression of the request tag 'a'",
+		},
+		"unknown variable": {
+			tags: []config.RequestTag{{Name: "a", Expression: `response.status`}},
+			err:  "failed to compile the expression of the request tag 'a'",
+		},
+		"not a string": {
+			tags: []config.RequestTag{{Name: "a", Expression: `operation.name == "Employees"`}},
+			err:  "the expression of the request tag 'a' must evaluate to a string, got bool",
+		},
+	}
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewRequestTagger(&config.RequestTagsConfiguration{Tags: tc.tags})
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/wundergraph/cosmo/router/internal/cbor"
+)
+
+const (
+	contentTypeJSON                = "application/json"
+	contentTypeGraphQLResponseJSON = "application/graphql-response+json"
+	contentTypeMultipartMixed      = "multipart/mixed"
+	contentTypeCBOR                = "application/cbor"
+
+	// multipartBoundary separates the parts of the multipart responses
+	multipartBoundary = "graphql"
+)
+
+// responseFormat is the encoding of a response that was negotiated with the Accept header of the client
+type responseFormat int
+
+const (
+	responseFormatJSON responseFormat = iota
+	responseFormatGraphQLResponseJSON
+	// responseFormatMultipart sends the response as the parts of an incremental delivery, one part per response of
+	// a query and one per event of a subscription
+	responseFormatMultipart
+	responseFormatCBOR
+)
+
+// negotiateResponseFormat returns the supported format with the highest quality in the Accept header. Formats of the
+// same quality are chosen in the order of the header. Without a supported format, the response is JSON.
+func negotiateResponseFormat(accept string, cborEnabled bool) responseFormat {
+	if accept == "" {
+		return responseFormatJSON
+	}
+
+	format, quality := responseFormatJSON, 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		var candidate responseFormat
+		switch mediaType {
+		case contentTypeJSON, "application/*", "*/*":
+			candidate = responseFormatJSON
+		case contentTypeGraphQLResponseJSON:
+			candidate = responseFormatGraphQLResponseJSON
+		case contentTypeMultipartMixed:
+			candidate = responseFormatMultipart
+		case contentTypeCBOR:
+			if !cborEnabled {
+				continue
+			}
+			candidate = responseFormatCBOR
+		default:
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > quality {
+			format, quality = candidate, q
+		}
+	}
+
+	return format
+}
+
+// contentType returns the Content-Type header of the responses of the format
+func (f responseFormat) contentType() string {
+	switch f {
+	case responseFormatGraphQLResponseJSON:
+		return contentTypeGraphQLResponseJSON
+	case responseFormatMultipart:
+		return contentTypeMultipartMixed + `; boundary="` + multipartBoundary + `"`
+	case responseFormatCBOR:
+		return contentTypeCBOR
+	}
+	return contentTypeJSON
+}
+
+// errorContentType returns the Content-Type header of the error responses of the format. The errors are always
+// written as JSON.
+func (f responseFormat) errorContentType() string {
+	if f == responseFormatGraphQLResponseJSON {
+		return contentTypeGraphQLResponseJSON
+	}
+	return contentTypeJSON
+}
+
+// streamable returns true if the response can be sent while it is resolved
+func (f responseFormat) streamable() bool {
+	return f == responseFormatJSON || f == responseFormatGraphQLResponseJSON
+}
+
+// writeResponse writes the JSON response in buf to w in the format
+func (f responseFormat) writeResponse(w io.Writer, buf *bytes.Buffer) error {
+	switch f {
+	case responseFormatMultipart:
+		if err := writeMultipartPart(w, buf.Bytes()); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, multipartEnd)
+		return err
+	case responseFormatCBOR:
+		encoded, err := cbor.FromJSON(make([]byte, 0, buf.Len()), buf.Bytes())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(encoded)
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+const (
+	multipartPartHeader = "\r\n--" + multipartBoundary + "\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"
+	multipartEnd        = "\r\n--" + multipartBoundary + "--\r\n"
+)
+
+// writeMultipartPart writes the JSON payload as a part of a multipart response
+func writeMultipartPart(w io.Writer, payload []byte) error {
+	if _, err := io.WriteString(w, multipartPartHeader); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
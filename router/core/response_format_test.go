@@ -0,0 +1,61 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateResponseFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		accept string
+		cbor   bool
+		want   responseFormat
+	}{
+		{accept: "", want: responseFormatJSON},
+		{accept: "*/*", want: responseFormatJSON},
+		{accept: "text/html", want: responseFormatJSON},
+		{accept: "application/json, application/graphql-response+json", want: responseFormatJSON},
+		{accept: "application/graphql-response+json, application/json", want: responseFormatGraphQLResponseJSON},
+		{accept: "application/json;q=0.9, multipart/mixed", want: responseFormatMultipart},
+		{accept: `multipart/mixed;deferSpec=20220824;q=0.5, application/json`, want: responseFormatJSON},
+		{accept: "application/cbor, application/json;q=0.9", cbor: true, want: responseFormatCBOR},
+		{accept: "application/cbor, application/json;q=0.9", want: responseFormatJSON},
+		{accept: "application/cbor;q=invalid, application/graphql-response+json;q=0.1", cbor: true, want: responseFormatGraphQLResponseJSON},
+	} {
+		require.Equal(t, tc.want, negotiateResponseFormat(tc.accept, tc.cbor), tc.accept)
+	}
+}
+
+func TestHttpFlushWriterMultipart(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &HttpFlushWriter{
+		ctx:       ctx,
+		cancel:    cancel,
+		writer:    rec,
+		flusher:   rec,
+		multipart: true,
+		buf:       &bytes.Buffer{},
+	}
+
+	for _, event := range []string{`{"data":{"a":1}}`, `{"data":{"a":2}}`} {
+		_, err := w.Write([]byte(event))
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+	}
+	w.Complete()
+
+	require.Equal(t, "\r\n--graphql\r\nContent-Type: application/json; charset=utf-8\r\n\r\n{\"data\":{\"a\":1}}"+
+		"\r\n--graphql\r\nContent-Type: application/json; charset=utf-8\r\n\r\n{\"data\":{\"a\":2}}"+
+		"\r\n--graphql--\r\n", rec.Body.String())
+}
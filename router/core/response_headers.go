@@ -0,0 +1,93 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const (
+	ResponseHeadersListenerGraphQL = "graphql"
+	ResponseHeadersListenerAdmin   = "admin"
+)
+
+// customResponseHeaders adds the configured headers to the responses of a listener
+type customResponseHeaders struct {
+	rules []config.CustomResponseHeaderRule
+	// configVersion returns the version of the active router config
+	configVersion func() string
+}
+
+// newCustomResponseHeaders returns the headers of the rules of the listener, or nil if the listener has no rules
+func newCustomResponseHeaders(cfg *config.CustomResponseHeadersConfiguration, listener string, configVersion func() string) *customResponseHeaders {
+	if cfg == nil {
+		return nil
+	}
+
+	var rules []config.CustomResponseHeaderRule
+	for _, rule := range cfg.Rules {
+		ruleListener := rule.Listener
+		if ruleListener == "" {
+			ruleListener = ResponseHeadersListenerGraphQL
+		}
+		if ruleListener == listener {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	return &customResponseHeaders{rules: rules, configVersion: configVersion}
+}
+
+// Handler sets the headers before the request is handled, so that the handlers can still override them
+func (h *customResponseHeaders) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range h.rules {
+			if !matchesResponseHeaderPath(rule.Path, r.URL.Path) {
+				continue
+			}
+			for _, header := range rule.Headers {
+				w.Header().Set(header.Name, h.value(header.Value, r))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *customResponseHeaders) value(value string, r *http.Request) string {
+	if !strings.Contains(value, "{") {
+		return value
+	}
+
+	var configVersion string
+	if h.configVersion != nil {
+		configVersion = h.configVersion()
+	}
+
+	return strings.NewReplacer(
+		"{router_version}", Version,
+		"{config_version}", configVersion,
+		"{request_id}", middleware.GetReqID(r.Context()),
+	).Replace(value)
+}
+
+// activeConfigVersion returns the version of the active router config, or an empty string before the first config
+// was loaded
+func (r *Router) activeConfigVersion() string {
+	return r.activeRouterConfig.Load().GetVersion()
+}
+
+func matchesResponseHeaderPath(pattern, path string) bool {
+	if pattern == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return pattern == path
+}
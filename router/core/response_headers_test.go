@@ -0,0 +1,57 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestCustomResponseHeaders(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.CustomResponseHeadersConfiguration{
+		Rules: []config.CustomResponseHeaderRule{
+			{Headers: []config.CustomResponseHeader{{Name: "X-Router", Value: "{router_version}/{config_version}/{request_id}"}}},
+			{Path: "/", Headers: []config.CustomResponseHeader{{Name: "Content-Security-Policy", Value: "default-src 'self'"}}},
+			{Path: "/rest/*", Headers: []config.CustomResponseHeader{{Name: "Cache-Control", Value: "max-age=60"}}},
+			{Listener: ResponseHeadersListenerAdmin, Headers: []config.CustomResponseHeader{{Name: "Cache-Control", Value: "no-store"}}},
+		},
+	}
+
+	require.Nil(t, newCustomResponseHeaders(nil, ResponseHeadersListenerGraphQL, nil))
+	require.Nil(t, newCustomResponseHeaders(&config.CustomResponseHeadersConfiguration{Rules: cfg.Rules[:1]}, ResponseHeadersListenerAdmin, nil))
+
+	headers := newCustomResponseHeaders(cfg, ResponseHeadersListenerGraphQL, func() string {
+		return "v1"
+	})
+	handler := middleware.RequestID(headers.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handlers can override the headers
+		if r.URL.Path == "/rest/override" {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+	})))
+
+	serve := func(path string) http.Header {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(middleware.RequestIDHeader, "req-1")
+		handler.ServeHTTP(rec, req)
+		return rec.Header()
+	}
+
+	playground := serve("/")
+	require.Equal(t, Version+"/v1/req-1", playground.Get("X-Router"))
+	require.Equal(t, "default-src 'self'", playground.Get("Content-Security-Policy"))
+	require.Empty(t, playground.Get("Cache-Control"))
+
+	rest := serve("/rest/employees")
+	require.Empty(t, rest.Get("Content-Security-Policy"))
+	require.Equal(t, "max-age=60", rest.Get("Cache-Control"))
+
+	require.Equal(t, "no-cache", serve("/rest/override").Get("Cache-Control"))
+}
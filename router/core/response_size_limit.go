@@ -0,0 +1,303 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// ErrResponseTooLarge is returned when a response exceeds the maximum size and can't be truncated
+var ErrResponseTooLarge = errors.New("response exceeds the maximum size")
+
+const (
+	// ResponseTooLargeErrorCode is the code in the extensions of the error of a response that exceeded the maximum size
+	ResponseTooLargeErrorCode = "RESPONSE_TOO_LARGE"
+	// ResponseTruncatedWarningCode is the code of the warning in the extensions of a truncated response
+	ResponseTruncatedWarningCode = "RESPONSE_TRUNCATED"
+)
+
+const (
+	ResponseSizeLimitModeError    = "error"
+	ResponseSizeLimitModeTruncate = "truncate"
+)
+
+// ResponseSizeLimit enforces the maximum size of the responses to the clients
+type ResponseSizeLimit struct {
+	maxSize  int
+	truncate bool
+}
+
+func NewResponseSizeLimit(cfg *config.ResponseSizeLimitConfiguration) (*ResponseSizeLimit, error) {
+	if cfg.MaxSize == 0 {
+		return nil, errors.New("the maximum response size must be greater than zero")
+	}
+	if cfg.MaxSize > math.MaxInt32 {
+		return nil, fmt.Errorf("the maximum response size must not exceed %d bytes", math.MaxInt32)
+	}
+
+	l := &ResponseSizeLimit{maxSize: int(cfg.MaxSize)}
+
+	switch cfg.Mode {
+	case ResponseSizeLimitModeError, "":
+	case ResponseSizeLimitModeTruncate:
+		l.truncate = true
+	default:
+		return nil, fmt.Errorf("unknown response size limit mode '%s'", cfg.Mode)
+	}
+
+	return l, nil
+}
+
+// MaxSize returns the maximum size of a response in bytes
+func (l *ResponseSizeLimit) MaxSize() int {
+	return l.maxSize
+}
+
+// apply enforces the limit on the response in buf. A response within the limit is left as it is. In the truncate
+// mode, the lists in the data of the response are shortened to the largest length that fits into the limit and
+// a warning is added to the extensions. ErrResponseTooLarge is returned if the response doesn't fit.
+func (l *ResponseSizeLimit) apply(buf *bytes.Buffer) (truncated bool, err error) {
+	if l == nil || buf.Len() <= l.maxSize {
+		return false, nil
+	}
+	if !l.truncate {
+		return false, ErrResponseTooLarge
+	}
+
+	response := bytes.Clone(buf.Bytes())
+
+	// The first pass finds the longest list. Every shorter length is a candidate for the truncation.
+	longest, err := truncateResponseLists(io.Discard, response, math.MaxInt, "")
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		out   bytes.Buffer
+		found = -1
+	)
+	// The size of the response grows with the length of its lists, so the largest length that fits is searched
+	low, high := 0, longest-1
+	for low <= high {
+		length := low + (high-low)/2
+		out.Reset()
+		if _, err = truncateResponseLists(&out, response, length, l.warning(length)); err != nil {
+			return false, err
+		}
+		if out.Len() <= l.maxSize {
+			found = length
+			low = length + 1
+		} else {
+			high = length - 1
+		}
+	}
+
+	if found == -1 {
+		return false, ErrResponseTooLarge
+	}
+
+	buf.Reset()
+	if _, err = truncateResponseLists(buf, response, found, l.warning(found)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (l *ResponseSizeLimit) warning(length int) string {
+	warning, _ := json.Marshal([]struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}{{
+		Message: fmt.Sprintf("The response exceeded the maximum size of %d bytes. Lists were truncated to %d items.", l.maxSize, length),
+		Code:    ResponseTruncatedWarningCode,
+	}})
+	return string(warning)
+}
+
+// truncateResponseLists writes the GraphQL response to w with all lists in its data shortened to the given length.
+// A non-empty warnings is added to the extensions of the response. It returns the length of the longest list.
+func truncateResponseLists(w io.Writer, response []byte, length int, warnings string) (int, error) {
+	dec := json.NewDecoder(bytes.NewReader(response))
+	dec.UseNumber()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, errors.New("the response is not a JSON object")
+	}
+
+	t := &listTruncator{dec: dec, length: length}
+	t.enc = json.NewEncoder(&t.out)
+	t.enc.SetEscapeHTML(false)
+
+	t.out.WriteByte('{')
+	hasExtensions := false
+	for i := 0; dec.More(); i++ {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+		key, _ := tok.(string)
+		if i > 0 {
+			t.out.WriteByte(',')
+		}
+		if err = t.writeString(key); err != nil {
+			return 0, err
+		}
+		t.out.WriteByte(':')
+
+		switch key {
+		case "data":
+			err = t.value()
+		case "extensions":
+			hasExtensions = true
+			err = t.extensions(warnings)
+		default:
+			var raw json.RawMessage
+			if err = dec.Decode(&raw); err == nil {
+				t.out.Write(raw)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if warnings != "" && !hasExtensions {
+		t.out.WriteString(`,"extensions":{"warnings":`)
+		t.out.WriteString(warnings)
+		t.out.WriteByte('}')
+	}
+	t.out.WriteByte('}')
+
+	if _, err := t.out.WriteTo(w); err != nil {
+		return 0, err
+	}
+	return t.longest, nil
+}
+
+type listTruncator struct {
+	dec     *json.Decoder
+	enc     *json.Encoder
+	out     bytes.Buffer
+	length  int
+	longest int
+}
+
+func (t *listTruncator) value() error {
+	tok, err := t.dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			t.out.WriteByte('{')
+			for i := 0; t.dec.More(); i++ {
+				key, err := t.dec.Token()
+				if err != nil {
+					return err
+				}
+				if i > 0 {
+					t.out.WriteByte(',')
+				}
+				if err = t.writeString(key.(string)); err != nil {
+					return err
+				}
+				t.out.WriteByte(':')
+				if err = t.value(); err != nil {
+					return err
+				}
+			}
+			t.out.WriteByte('}')
+		} else {
+			t.out.WriteByte('[')
+			items := 0
+			for ; t.dec.More(); items++ {
+				if items >= t.length {
+					var skipped json.RawMessage
+					if err = t.dec.Decode(&skipped); err != nil {
+						return err
+					}
+					continue
+				}
+				if items > 0 {
+					t.out.WriteByte(',')
+				}
+				if err = t.value(); err != nil {
+					return err
+				}
+			}
+			t.out.WriteByte(']')
+			t.longest = max(t.longest, items)
+		}
+		// The closing delimiter
+		_, err = t.dec.Token()
+		return err
+	case string:
+		return t.writeString(v)
+	case json.Number:
+		t.out.WriteString(v.String())
+	case bool:
+		if v {
+			t.out.WriteString("true")
+		} else {
+			t.out.WriteString("false")
+		}
+	case nil:
+		t.out.WriteString("null")
+	}
+
+	return nil
+}
+
+// extensions copies the extensions of the response and adds the warnings
+func (t *listTruncator) extensions(warnings string) error {
+	var raw json.RawMessage
+	if err := t.dec.Decode(&raw); err != nil {
+		return err
+	}
+	raw = bytes.TrimSpace(raw)
+	if warnings == "" || len(raw) < 2 || raw[0] != '{' {
+		t.out.Write(raw)
+		return nil
+	}
+
+	t.out.Write(raw[:len(raw)-1])
+	if len(bytes.TrimSpace(raw[1:len(raw)-1])) > 0 {
+		t.out.WriteByte(',')
+	}
+	t.out.WriteString(`"warnings":`)
+	t.out.WriteString(warnings)
+	t.out.WriteByte('}')
+	return nil
+}
+
+func (t *listTruncator) writeString(s string) error {
+	if err := t.enc.Encode(s); err != nil {
+		return err
+	}
+	// The encoder terminates every value with a newline
+	t.out.Truncate(t.out.Len() - 1)
+	return nil
+}
+
+// streamLimitWriter stops a streamed response before it exceeds the maximum size. A response that stays below
+// the flush threshold of the stream is limited after the execution, when it is complete.
+type streamLimitWriter struct {
+	stream  *streamingResponseWriter
+	maxSize int
+	size    int
+}
+
+func (s *streamLimitWriter) Write(p []byte) (int, error) {
+	s.size += len(p)
+	if s.size > s.maxSize && (s.stream.streaming() || s.stream.buf.Len()+len(p) >= s.stream.threshold) {
+		return 0, ErrResponseTooLarge
+	}
+	return s.stream.Write(p)
+}
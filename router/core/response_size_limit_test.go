@@ -0,0 +1,116 @@
+package core
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestResponseSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	const response = `{"errors":[{"message":"failed","path":["employees",3]}],"data":{"employees":[{"id":1,"tags":["a","b","c"]},{"id":2,"tags":[]},{"id":3,"tags":["<d>"]},{"id":4,"tags":null}],"count":4}}`
+
+	t.Run("validates the configuration", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewResponseSizeLimit(&config.ResponseSizeLimitConfiguration{MaxSize: 0})
+		require.Error(t, err)
+		_, err = NewResponseSizeLimit(&config.ResponseSizeLimitConfiguration{MaxSize: 1024, Mode: "drop"})
+		require.Error(t, err)
+	})
+
+	t.Run("leaves responses within the limit as they are", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := NewResponseSizeLimit(&config.ResponseSizeLimitConfiguration{MaxSize: config.BytesString(len(response)), Mode: ResponseSizeLimitModeTruncate})
+		require.NoError(t, err)
+
+		buf := bytes.NewBufferString(response)
+		truncated, err := l.apply(buf)
+		require.NoError(t, err)
+		require.False(t, truncated)
+		require.Equal(t, response, buf.String())
+	})
+
+	t.Run("rejects responses over the limit", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := NewResponseSizeLimit(&config.ResponseSizeLimitConfiguration{MaxSize: 64, Mode: ResponseSizeLimitModeError})
+		require.NoError(t, err)
+
+		_, err = l.apply(bytes.NewBufferString(response))
+		require.ErrorIs(t, err, ErrResponseTooLarge)
+	})
+
+	t.Run("truncates the lists to the largest length that fits", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := NewResponseSizeLimit(&config.ResponseSizeLimitConfiguration{MaxSize: 300, Mode: ResponseSizeLimitModeTruncate})
+		require.NoError(t, err)
+
+		// The trailing whitespace exceeds the limit and is removed by the truncation, which adds the warning instead
+		buf := bytes.NewBufferString(response)
+		buf.WriteString(strings.Repeat(" ", 200))
+		truncated, err := l.apply(buf)
+		require.NoError(t, err)
+		require.True(t, truncated)
+		require.LessOrEqual(t, buf.Len(), 300)
+		require.JSONEq(t, `{
+			"errors":[{"message":"failed","path":["employees",3]}],
+			"data":{"employees":[{"id":1,"tags":["a","b"]},{"id":2,"tags":[]}],"count":4},
+			"extensions":{"warnings":[{"message":"The response exceeded the maximum size of 300 bytes. Lists were truncated to 2 items.","code":"RESPONSE_TRUNCATED"}]}
+		}`, buf.String())
+	})
+
+	t.Run("adds the warning to the existing extensions", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := NewResponseSizeLimit(&config.ResponseSizeLimitConfiguration{MaxSize: 197, Mode: ResponseSizeLimitModeTruncate})
+		require.NoError(t, err)
+
+		buf := bytes.NewBufferString(`{"data":{"ids":[1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20,21,22,23,24,25,26,27,28,29,30]},"extensions":{"trace":{"id":1}}}`)
+		buf.WriteString(strings.Repeat(" ", 100))
+		truncated, err := l.apply(buf)
+		require.NoError(t, err)
+		require.True(t, truncated)
+		require.True(t, strings.HasPrefix(buf.String(), `{"data":{"ids":[1,2,3]},"extensions":{"trace":{"id":1},"warnings":[`), buf.String())
+	})
+
+	t.Run("rejects responses that don't fit with empty lists", func(t *testing.T) {
+		t.Parallel()
+
+		l, err := NewResponseSizeLimit(&config.ResponseSizeLimitConfiguration{MaxSize: 32, Mode: ResponseSizeLimitModeTruncate})
+		require.NoError(t, err)
+
+		_, err = l.apply(bytes.NewBufferString(response))
+		require.ErrorIs(t, err, ErrResponseTooLarge)
+	})
+}
+
+func TestStreamLimitWriter(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	stream := newStreamingResponseWriter(rec, &bytes.Buffer{}, 8)
+	w := &streamLimitWriter{stream: stream, maxSize: 12}
+
+	// The first chunk stays in the buffer of the stream
+	_, err := w.Write([]byte("1234"))
+	require.NoError(t, err)
+	require.False(t, stream.streaming())
+
+	_, err = w.Write([]byte("5678"))
+	require.NoError(t, err)
+	require.True(t, stream.streaming())
+
+	// Data over the limit is never sent
+	_, err = w.Write([]byte("90123"))
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+	require.Equal(t, "12345678", rec.Body.String())
+}
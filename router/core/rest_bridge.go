@@ -0,0 +1,400 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const (
+	restParameterInPath  = "path"
+	restParameterInQuery = "query"
+)
+
+const (
+	restParameterTypeString  = "string"
+	restParameterTypeInteger = "integer"
+	restParameterTypeNumber  = "number"
+	restParameterTypeBoolean = "boolean"
+)
+
+var restPathParameter = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+type RESTBridgeOptions struct {
+	BasePath    string
+	OpenAPIPath string
+	Endpoints   []config.RESTEndpointConfiguration
+	Logger      *zap.Logger
+}
+
+// RESTBridge exposes persisted operations as REST endpoints. A request is translated to a GraphQL request of the
+// persisted operation, which is executed by the GraphQL handler like any other request of the client.
+type RESTBridge struct {
+	basePath    string
+	openAPIPath string
+	endpoints   []*config.RESTEndpointConfiguration
+	openAPI     []byte
+	logger      *zap.Logger
+}
+
+func NewRESTBridge(opts *RESTBridgeOptions) (*RESTBridge, error) {
+	basePath := strings.TrimSuffix(opts.BasePath, "/")
+	if basePath != "" && !strings.HasPrefix(basePath, "/") {
+		return nil, fmt.Errorf("the base path '%s' of the REST endpoints must start with a slash", opts.BasePath)
+	}
+	if !strings.HasPrefix(opts.OpenAPIPath, "/") {
+		return nil, fmt.Errorf("the OpenAPI path '%s' must start with a slash", opts.OpenAPIPath)
+	}
+
+	b := &RESTBridge{
+		basePath:    basePath,
+		openAPIPath: opts.OpenAPIPath,
+		logger:      opts.Logger,
+	}
+
+	seen := make(map[string]struct{}, len(opts.Endpoints))
+	for i := range opts.Endpoints {
+		endpoint := &opts.Endpoints[i]
+		if err := validateRESTEndpoint(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid REST endpoint %s %s: %w", endpoint.Method, endpoint.Path, err)
+		}
+		route := endpoint.Method + " " + endpoint.Path
+		if _, ok := seen[route]; ok {
+			return nil, fmt.Errorf("duplicate REST endpoint %s", route)
+		}
+		seen[route] = struct{}{}
+		b.endpoints = append(b.endpoints, endpoint)
+	}
+
+	openAPI, err := json.Marshal(b.openAPIDocument())
+	if err != nil {
+		return nil, err
+	}
+	b.openAPI = openAPI
+
+	return b, nil
+}
+
+func validateRESTEndpoint(endpoint *config.RESTEndpointConfiguration) error {
+	switch endpoint.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return fmt.Errorf("unsupported method '%s'", endpoint.Method)
+	}
+	if !strings.HasPrefix(endpoint.Path, "/") {
+		return errors.New("the path must start with a slash")
+	}
+	if strings.ContainsAny(restPathParameter.ReplaceAllString(endpoint.Path, ""), "{}") {
+		return errors.New("path parameters must be written as {name}")
+	}
+	if endpoint.PersistedOperation == "" {
+		return errors.New("the persisted operation must not be empty")
+	}
+	if endpoint.ClientName == "" {
+		return errors.New("the client name must not be empty")
+	}
+
+	pathParameters := map[string]bool{}
+	for _, match := range restPathParameter.FindAllStringSubmatch(endpoint.Path, -1) {
+		pathParameters[match[1]] = false
+	}
+
+	variables := make(map[string]struct{}, len(endpoint.Parameters))
+	for _, p := range endpoint.Parameters {
+		switch p.In {
+		case restParameterInPath:
+			if _, ok := pathParameters[p.Name]; !ok {
+				return fmt.Errorf("the path parameter '%s' is not part of the path", p.Name)
+			}
+			pathParameters[p.Name] = true
+		case restParameterInQuery:
+			if p.Name == "" {
+				return errors.New("the name of a query parameter must not be empty")
+			}
+		default:
+			return fmt.Errorf("the parameter '%s' must be in path or query", p.Name)
+		}
+		switch p.Type {
+		case "", restParameterTypeString, restParameterTypeInteger, restParameterTypeNumber, restParameterTypeBoolean:
+		default:
+			return fmt.Errorf("unsupported type '%s' of the parameter '%s'", p.Type, p.Name)
+		}
+
+		variable := restParameterVariable(&p)
+		if _, ok := variables[variable]; ok {
+			return fmt.Errorf("the variable '%s' is mapped twice", variable)
+		}
+		variables[variable] = struct{}{}
+	}
+	for name, configured := range pathParameters {
+		if !configured {
+			return fmt.Errorf("the path parameter '%s' is not configured", name)
+		}
+	}
+
+	return nil
+}
+
+func restParameterVariable(p *config.RESTParameterConfiguration) string {
+	if p.Variable != "" {
+		return p.Variable
+	}
+	return p.Name
+}
+
+// Mount registers the endpoints and the OpenAPI document on the router. The translated requests are served by the
+// handler of the GraphQL endpoint.
+func (b *RESTBridge) Mount(r chi.Router, graphqlHandler http.Handler) {
+	for _, endpoint := range b.endpoints {
+		r.Method(endpoint.Method, b.basePath+endpoint.Path, b.handler(endpoint, graphqlHandler))
+	}
+	r.Get(b.basePath+b.openAPIPath, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b.openAPI)
+	})
+}
+
+type restGraphQLRequest struct {
+	OperationName string          `json:"operationName,omitempty"`
+	Variables     json.RawMessage `json:"variables"`
+	Extensions    json.RawMessage `json:"extensions"`
+}
+
+func (b *RESTBridge) handler(endpoint *config.RESTEndpointConfiguration, graphqlHandler http.Handler) http.HandlerFunc {
+	extensions := json.RawMessage(`{"persistedQuery":{"version":1,"sha256Hash":` + strconv.Quote(endpoint.PersistedOperation) + `}}`)
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		variables, err := restVariables(endpoint, req)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeHTTPError(req, w, http.StatusBadRequest, err, b.logger)
+			return
+		}
+
+		body, err := json.Marshal(restGraphQLRequest{
+			OperationName: endpoint.OperationName,
+			Variables:     variables,
+			Extensions:    extensions,
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeHTTPError(req, w, http.StatusInternalServerError, err, b.logger)
+			return
+		}
+
+		// The GraphQL endpoint is mounted on its own router, which routes the request by a fresh route context
+		routeCtx := chi.NewRouteContext()
+		routeCtx.RoutePath = "/"
+
+		graphqlReq := req.Clone(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+		graphqlReq.Method = http.MethodPost
+		graphqlReq.URL.RawQuery = ""
+		graphqlReq.Body = io.NopCloser(bytes.NewReader(body))
+		graphqlReq.ContentLength = int64(len(body))
+		graphqlReq.Header.Set("Content-Type", "application/json")
+		graphqlReq.Header.Set("Accept", "application/json")
+		graphqlReq.Header.Del("Content-Length")
+		graphqlReq.Header.Set("graphql-client-name", endpoint.ClientName)
+
+		graphqlHandler.ServeHTTP(w, graphqlReq)
+	}
+}
+
+// restVariables merges the JSON object of the body with the parameters of the request
+func restVariables(endpoint *config.RESTEndpointConfiguration, req *http.Request) (json.RawMessage, error) {
+	variables := map[string]json.RawMessage{}
+
+	if req.Method != http.MethodGet && req.Method != http.MethodDelete && req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := json.Unmarshal(data, &variables); err != nil {
+				return nil, errors.New("the body must be a JSON object")
+			}
+		}
+	}
+
+	query := req.URL.Query()
+	for i := range endpoint.Parameters {
+		p := &endpoint.Parameters[i]
+
+		var value string
+		var ok bool
+		switch p.In {
+		case restParameterInPath:
+			value = chi.URLParam(req, p.Name)
+			ok = value != ""
+		case restParameterInQuery:
+			value, ok = query.Get(p.Name), query.Has(p.Name)
+		}
+		if !ok {
+			if p.Required || p.In == restParameterInPath {
+				return nil, fmt.Errorf("the parameter '%s' is required", p.Name)
+			}
+			continue
+		}
+
+		raw, err := restParameterValue(p.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of the parameter '%s': %w", p.Name, err)
+		}
+		variables[restParameterVariable(p)] = raw
+	}
+
+	return json.Marshal(variables)
+}
+
+// restParameterValue converts the value of a parameter to the JSON value of its type
+func restParameterValue(typ, value string) (json.RawMessage, error) {
+	switch typ {
+	case restParameterTypeInteger:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errors.New("expected an integer")
+		}
+		return strconv.AppendInt(nil, v, 10), nil
+	case restParameterTypeNumber:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New("expected a number")
+		}
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+	case restParameterTypeBoolean:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("expected a boolean")
+		}
+		return strconv.AppendBool(nil, v), nil
+	default:
+		return json.Marshal(value)
+	}
+}
+
+type openAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    openAPIInfo                             `json:"info"`
+	Paths   map[string]map[string]*openAPIOperation `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Content map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type       string                    `json:"type,omitempty"`
+	Nullable   bool                      `json:"nullable,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+}
+
+var nonIdentifierCharacters = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+func (b *RESTBridge) openAPIDocument() *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   "Cosmo Router REST endpoints",
+			Version: Version,
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+	}
+
+	graphqlResponse := &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"data":   {Type: "object", Nullable: true},
+			"errors": {Type: "array", Items: &openAPISchema{Type: "object"}},
+		},
+	}
+
+	for _, endpoint := range b.endpoints {
+		method := strings.ToLower(endpoint.Method)
+		op := &openAPIOperation{
+			// The method and the path are unique
+			OperationID: method + strings.TrimSuffix(nonIdentifierCharacters.ReplaceAllString(endpoint.Path, "_"), "_"),
+			Summary:     endpoint.Summary,
+			Responses: map[string]openAPIResponse{
+				"200": {
+					Description: "The GraphQL response of the operation",
+					Content:     map[string]openAPIMediaType{"application/json": {Schema: graphqlResponse}},
+				},
+				"400": {
+					Description: "The parameters are invalid",
+					Content:     map[string]openAPIMediaType{"application/json": {Schema: graphqlResponse}},
+				},
+			},
+		}
+		for _, p := range endpoint.Parameters {
+			typ := p.Type
+			if typ == "" {
+				typ = restParameterTypeString
+			}
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:        p.Name,
+				In:          p.In,
+				Description: p.Description,
+				Required:    p.Required || p.In == restParameterInPath,
+				Schema:      &openAPISchema{Type: typ},
+			})
+		}
+		switch endpoint.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			op.RequestBody = &openAPIRequestBody{
+				Content: map[string]openAPIMediaType{"application/json": {Schema: &openAPISchema{Type: "object"}}},
+			}
+		}
+
+		path := b.basePath + endpoint.Path
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[path][method] = op
+	}
+
+	return doc
+}
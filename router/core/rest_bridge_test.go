@@ -0,0 +1,101 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestNewRESTBridge(t *testing.T) {
+	endpoint := func(path string, parameters ...config.RESTParameterConfiguration) config.RESTEndpointConfiguration {
+		return config.RESTEndpointConfiguration{
+			Method:             http.MethodGet,
+			Path:               path,
+			PersistedOperation: "hash",
+			ClientName:         "client",
+			Parameters:         parameters,
+		}
+	}
+	newBridge := func(endpoints ...config.RESTEndpointConfiguration) error {
+		_, err := NewRESTBridge(&RESTBridgeOptions{BasePath: "/rest", OpenAPIPath: "/openapi.json", Endpoints: endpoints, Logger: zap.NewNop()})
+		return err
+	}
+
+	require.NoError(t, newBridge(endpoint("/employees/{id}", config.RESTParameterConfiguration{Name: "id", In: "path", Type: "integer"})))
+
+	require.ErrorContains(t, newBridge(endpoint("/employees/{id}")), "the path parameter 'id' is not configured")
+	require.ErrorContains(t, newBridge(endpoint("/employees", config.RESTParameterConfiguration{Name: "id", In: "path"})), "the path parameter 'id' is not part of the path")
+	require.ErrorContains(t, newBridge(endpoint("/employees/{id:[0-9]+}")), "path parameters must be written as {name}")
+	require.ErrorContains(t, newBridge(endpoint("/employees", config.RESTParameterConfiguration{Name: "id", In: "query", Type: "date"})), "unsupported type 'date'")
+	require.ErrorContains(t, newBridge(endpoint("/employees",
+		config.RESTParameterConfiguration{Name: "id", In: "query"},
+		config.RESTParameterConfiguration{Name: "employee", In: "query", Variable: "id"},
+	)), "the variable 'id' is mapped twice")
+	require.ErrorContains(t, newBridge(endpoint("/employees"), endpoint("/employees")), "duplicate REST endpoint GET /employees")
+
+	noOperation := endpoint("/employees")
+	noOperation.PersistedOperation = ""
+	require.ErrorContains(t, newBridge(noOperation), "the persisted operation must not be empty")
+}
+
+func TestRESTParameterValue(t *testing.T) {
+	for _, tc := range []struct {
+		typ, value, expected string
+	}{
+		{"", `a "b"`, `"a \"b\""`},
+		{"string", "1", `"1"`},
+		{"integer", "-42", "-42"},
+		{"number", "1.5", "1.5"},
+		{"boolean", "true", "true"},
+	} {
+		raw, err := restParameterValue(tc.typ, tc.value)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, string(raw))
+	}
+
+	_, err := restParameterValue("integer", "1.5")
+	require.ErrorContains(t, err, "expected an integer")
+	_, err = restParameterValue("number", "NaN")
+	require.ErrorContains(t, err, "expected a number")
+	_, err = restParameterValue("boolean", "yes")
+	require.ErrorContains(t, err, "expected a boolean")
+}
+
+func TestRESTBridgeOpenAPIDocument(t *testing.T) {
+	b, err := NewRESTBridge(&RESTBridgeOptions{
+		BasePath:    "/rest/",
+		OpenAPIPath: "/openapi.json",
+		Endpoints: []config.RESTEndpointConfiguration{
+			{
+				Method:             http.MethodPut,
+				Path:               "/employees/{id}",
+				PersistedOperation: "hash",
+				ClientName:         "client",
+				Summary:            "Update an employee",
+				Parameters: []config.RESTParameterConfiguration{
+					{Name: "id", In: "path", Type: "integer", Description: "The ID of the employee"},
+					{Name: "dryRun", In: "query", Type: "boolean"},
+				},
+			},
+		},
+		Logger: zap.NewNop(),
+	})
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(b.openAPI, &doc))
+
+	op := doc["paths"].(map[string]any)["/rest/employees/{id}"].(map[string]any)["put"].(map[string]any)
+	require.Equal(t, "put_employees_id", op["operationId"])
+	require.Equal(t, "Update an employee", op["summary"])
+	require.Equal(t, []any{
+		map[string]any{"name": "id", "in": "path", "description": "The ID of the employee", "required": true, "schema": map[string]any{"type": "integer"}},
+		map[string]any{"name": "dryRun", "in": "query", "required": false, "schema": map[string]any{"type": "boolean"}},
+	}, op["parameters"])
+	require.Contains(t, op, "requestBody")
+}
@@ -10,22 +10,25 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/wundergraph/cosmo/router/internal/anonymize"
 	"github.com/wundergraph/cosmo/router/internal/docker"
 	"github.com/wundergraph/cosmo/router/internal/graphiql"
 	rjwt "github.com/wundergraph/cosmo/router/internal/jwt"
 	rmiddleware "github.com/wundergraph/cosmo/router/internal/middleware"
-	"github.com/wundergraph/cosmo/router/internal/recoveryhandler"
 	"github.com/wundergraph/cosmo/router/pkg/otel"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
 	"golang.org/x/exp/maps"
+	"golang.org/x/net/http2"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-redis/redis_rate/v10"
 	"github.com/nats-io/nuid"
 	"github.com/redis/go-redis/v9"
 
@@ -33,21 +36,26 @@ import (
 	"github.com/wundergraph/cosmo/router/pkg/config"
 	"github.com/wundergraph/cosmo/router/pkg/cors"
 	"github.com/wundergraph/cosmo/router/pkg/health"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
 	rmetric "github.com/wundergraph/cosmo/router/pkg/metric"
 	"github.com/wundergraph/cosmo/router/pkg/otel/otelconfig"
+	"github.com/wundergraph/cosmo/router/pkg/profiling"
 	rtrace "github.com/wundergraph/cosmo/router/pkg/trace"
 	brotli "go.withmatt.com/connect-brotli"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/graphqlmetrics/v1/graphqlmetricsv1connect"
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/cosmo/router/internal/accesslog"
 	"github.com/wundergraph/cosmo/router/internal/cdn"
 	"github.com/wundergraph/cosmo/router/internal/controlplane/configpoller"
 	"github.com/wundergraph/cosmo/router/internal/controlplane/selfregister"
 	"github.com/wundergraph/cosmo/router/internal/debug"
 	"github.com/wundergraph/cosmo/router/internal/graphqlmetrics"
+	"github.com/wundergraph/cosmo/router/internal/requestlogger"
 	"github.com/wundergraph/cosmo/router/internal/retrytransport"
 	"github.com/wundergraph/cosmo/router/internal/stringsx"
+	"github.com/wundergraph/cosmo/router/internal/useragent"
 	"go.opentelemetry.io/otel/attribute"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -57,8 +65,9 @@ import (
 type IPAnonymizationMethod string
 
 const (
-	Hash   IPAnonymizationMethod = "hash"
-	Redact IPAnonymizationMethod = "redact"
+	Hash     IPAnonymizationMethod = "hash"
+	Redact   IPAnonymizationMethod = "redact"
+	Truncate IPAnonymizationMethod = "truncate"
 )
 
 var CustomCompressibleContentTypes = []string{
@@ -79,7 +88,15 @@ type (
 	// Router is the main application instance.
 	Router struct {
 		Config
-		activeServer   *server
+		activeServer *server
+		// httpServer is the long-lived HTTP server started by Start. It forwards requests to the active server
+		// through the swapHandler so that the listener is kept open across config updates.
+		httpServer  *http.Server
+		swapHandler *swapHandler
+		// activeRouterConfig is the config of the active server. It is read by the admin API.
+		activeRouterConfig atomic.Pointer[nodev1.RouterConfig]
+		// activeHandler is the handler of the active server. It is used by the admin API to replay requests.
+		activeHandler  atomic.Pointer[http.Handler]
 		modules        []Module
 		WebsocketStats WebSocketsStatistics
 	}
@@ -102,6 +119,8 @@ type (
 	IPAnonymizationConfig struct {
 		Enabled bool
 		Method  IPAnonymizationMethod
+		// SaltRotationInterval is the interval after which the salt of the hash method is replaced
+		SaltRotationInterval time.Duration
 	}
 
 	TlsClientAuthConfig struct {
@@ -124,17 +143,26 @@ type (
 		logger                   *zap.Logger
 		traceConfig              *rtrace.Config
 		metricConfig             *rmetric.Config
+		profilingConfig          *profiling.Config
+		profiler                 *profiling.Profiler
 		tracerProvider           *sdktrace.TracerProvider
 		otlpMeterProvider        *sdkmetric.MeterProvider
 		promMeterProvider        *sdkmetric.MeterProvider
 		gqlMetricsExporter       graphqlmetrics.SchemaUsageExporter
 		corsOptions              *cors.Config
 		gracePeriod              time.Duration
+		configDriftThreshold     time.Duration
+		configDrift              *configDriftTracker
+		configFallbackPath       string
+		configFallback           *routerConfigFallback
 		staticRouterConfig       *nodev1.RouterConfig
 		awsLambda                bool
 		shutdown                 bool
 		bootstrapped             bool
 		ipAnonymization          *IPAnonymizationConfig
+		ipHasher                 *anonymize.Hasher
+		pseudonymizationConfig   *config.PseudonymizationConfiguration
+		pseudonymizer            *anonymize.Hasher
 		listenAddr               string
 		baseURL                  string
 		graphqlWebURL            string
@@ -151,9 +179,133 @@ type (
 		cdnPersistentOpClient    *cdn.PersistentOperationClient
 		eventsConfig             config.EventsConfiguration
 		prometheusServer         *http.Server
+		adminConfig              *AdminServerConfig
+		adminServer              *http.Server
+		maintenanceConfig        *config.MaintenanceConfiguration
+		maintenanceMode          *MaintenanceMode
+		persistedOpKillSwitch    *PersistedOperationKillSwitch
+		fieldBlocker             *FieldBlocker
+		startupReportConfig      *config.StartupReportConfiguration
+		memorySoftLimitConfig    *config.MemorySoftLimitConfiguration
+		versionEndpointConfig    *config.VersionEndpointConfiguration
+		customResponseHeaders    *config.CustomResponseHeadersConfiguration
+		connectionTimingsConfig  *config.ConnectionTimingsConfiguration
+		connectionTimings        *ConnectionTimings
+		problemDetails           *config.ProblemDetailsConfiguration
+		memoryGuard              *MemoryGuard
+		serverConfig             *config.ServerConfiguration
+		serverLimits             *ServerLimits
+		subscriptionLimitsConfig *config.SubscriptionLimitsConfiguration
+		subscriptionLimits       *SubscriptionLimits
+		subscriptionReapingCfg   *config.SubscriptionReapingConfiguration
+		backpressureConfig       *config.SubscriptionBackpressureConfiguration
+		subscriptionBackpressure *SubscriptionBackpressure
+		subscriptionReaper       *SubscriptionReaper
+		webSocketTransport       *WebSocketTransport
+		drains                   *drainTracker
+		accessLogsConfig         *config.AccessLogsConfiguration
+		accessLogKafkaSink       *accesslog.KafkaSink
+		accessLogger             *zap.Logger
+		logShutdownFlush         *config.LogShutdownFlushConfiguration
+		logRedactor              *logging.Redactor
+		logIPAnonymizer          *logging.IPAnonymizer
+		accessLogSampler         *AccessLogSampler
+		userAgentParser          *useragent.Parser
+		semConvStability         otel.SemConvStability
+		logEscalationConfig      *config.LogEscalationConfiguration
+		panicRecoveryConfig      *config.PanicRecoveryConfiguration
+		deprecationConfig        *config.DeprecationWarningsConfiguration
+		deprecations             *DeprecationReporter
+		clientProtocolsConfig    *config.ClientProtocolsConfiguration
+		clientProtocols          *ClientProtocols
+		logRetentionConfig       *config.LogRetentionConfiguration
+		logRetentionJanitor      *logging.RetentionJanitor
+		sloConfig                *config.SLOConfiguration
+		sloTracker               *SLOTracker
+		topOperationsConfig      *config.TopOperationsConfiguration
+		topOperations            *TopOperations
+		anomalyDetectionConfig   *config.AnomalyDetectionConfiguration
+		anomalyDetector          *AnomalyDetector
+		lifecycleWebhooksConfig  *config.LifecycleWebhooksConfiguration
+		lifecycleNotifier        *LifecycleNotifier
+		lifecycleHooks           []LifecycleHook
+		subgraphAuthentication   config.SubgraphAuthenticationConfiguration
+		requestSigningConfig     *config.RequestSigningConfiguration
+		requestSignatureVerifier *RequestSignatureVerifier
+		botDetectionConfig       *config.BotDetectionConfiguration
+		botDetector              *BotDetector
+		operationTimeoutsConfig  *config.OperationTimeoutsConfiguration
+		operationTimeouts        *OperationTimeouts
+		fetchConcurrencyConfig   *config.FetchConcurrencyConfiguration
+		fetchConcurrency         *FetchConcurrency
+		entityBatchingConfig     *config.EntityBatchingConfiguration
+		entityBatching           *EntityBatcherOptions
+		responseValidationConfig *config.SubgraphResponseValidationConfiguration
+		responseSizeLimitConfig  *config.ResponseSizeLimitConfiguration
+		responseSizeLimit        *ResponseSizeLimit
+		requestMemoryLimitConfig *config.RequestMemoryLimitConfiguration
+		requestMemoryLimit       *RequestMemoryLimit
+		persistedOpUsageConfig   *config.PersistedOperationUsageConfiguration
+		persistedOpUsage         *PersistedOperationUsageTracker
+		persistedOpManifestCfg   *config.PersistedOperationManifestConfiguration
+		persistedOpManifest      *PersistedOperationManifest
+		configAuditConfig        *config.ConfigAuditConfiguration
+		configAudit              *ConfigAuditLog
+		auditLogConfig           *config.AuditLogConfiguration
+		auditLogger              *logging.AuditLogger
+		executionAuditConfig     *config.ExecutionAuditConfiguration
+		executionAudit           *ExecutionAudit
+		restEndpointsConfig      *config.RESTEndpointsConfiguration
+		restBridge               *RESTBridge
+		subWebhooksConfig        *config.SubscriptionWebhooksConfiguration
+		subscriptionWebhooks     *SubscriptionWebhooks
+		surrogateKeysConfig      *config.SurrogateKeysConfiguration
+		surrogateKeys            *SurrogateKeys
+		etagsConfig              *config.ETagsConfiguration
+		etags                    *ETags
+		requestTagsConfig        *config.RequestTagsConfiguration
+		requestTagger            *RequestTagger
+		syntheticHealthConfig    *config.SyntheticHealthOperationConfiguration
+		syntheticHealth          *SyntheticHealthOperation
+		chaosConfig              *config.ChaosConfiguration
+		chaos                    *ChaosInjector
+		subgraphCompression      *config.SubgraphCompressionConfiguration
+		subgraphPayloadSize      *config.SubgraphPayloadSizeConfiguration
+		clockSkewConfig          *config.ClockSkewConfiguration
+		clockSkew                *ClockSkewCheck
+		certExpiryConfig         *config.CertificateExpiryConfiguration
+		certExpiry               *CertificateExpiryCheck
+		subgraphEndpointsConfig  *config.SubgraphEndpointsConfiguration
+		subgraphEndpoints        *SubgraphEndpointSwitch
+		adaptiveConcurrencyCfg   *config.AdaptiveConcurrencyConfiguration
+		adaptiveConcurrency      *AdaptiveConcurrency
+		slowSubgraphWarningsCfg  *config.SlowSubgraphWarningsConfiguration
+		slowSubgraphWarnings     *SlowSubgraphWarnings
+		subgraphTunnelsConfig    config.SubgraphTunnelsConfiguration
+		subgraphTunnels          *SubgraphTunnels
+		responseFormats          *config.ResponseFormatsConfiguration
+		tenantsConfig            *config.TenantsConfiguration
+		tenantOverrides          *TenantOverrides
+		requestCoalescingConfig  *config.RequestCoalescingConfiguration
+		sealedVariablesConfig    *config.SealedVariablesConfiguration
+		sealedVariables          *SealedVariables
+		nonGraphQLRequestsConfig *config.NonGraphQLRequestsConfiguration
+		nonGraphQLRequests       *NonGraphQLRequests
+		contractEnforcement      *config.ContractEnforcementConfiguration
+		subgraphMocks            *config.SubgraphMocksConfiguration
+		configSignatureVerified  bool
+		variableRedactionConfig  *config.VariableRedactionConfiguration
+		variableRedactor         *VariableRedactor
+		logDropCounters          map[string]LogDropCounter
+		logMetrics               *LogMetrics
+		operationFingerprintCfg  *config.OperationFingerprintConfiguration
+		clusterPeersConfig       *config.ClusterPeersConfiguration
+		clusterPeers             *ClusterPeers
+		operationFingerprint     *OperationFingerprintOptions
 		modulesConfig            map[string]interface{}
 		routerMiddlewares        []func(http.Handler) http.Handler
 		preOriginHandlers        []TransportPreHandler
+		logEntryHandlers         []LogEntryHandler
 		postOriginHandlers       []TransportPostHandler
 		headerRuleEngine         *HeaderRuleEngine
 		headerRules              config.HeaderRules
@@ -164,6 +316,8 @@ type (
 		accessController         *AccessController
 		retryOptions             retrytransport.RetryOptions
 		redisClient              *redis.Client
+		replicatedRateLimits     *ReplicatedRateLimits
+		usageQuotas              *UsageQuotas
 		processStartTime         time.Time
 		developmentMode          bool
 		// If connecting to localhost inside Docker fails, fallback to the docker internal address for the host
@@ -213,6 +367,24 @@ func NewRouter(opts ...Option) (*Router, error) {
 		r.logger = zap.NewNop()
 	}
 
+	if r.anomalyDetectionConfig != nil && r.anomalyDetectionConfig.Enabled {
+		detector, err := NewAnomalyDetector(&AnomalyDetectorOptions{
+			Logger:            r.logger,
+			Interval:          r.anomalyDetectionConfig.Interval,
+			BaselineIntervals: r.anomalyDetectionConfig.BaselineIntervals,
+			Threshold:         r.anomalyDetectionConfig.Threshold,
+			MinEvents:         r.anomalyDetectionConfig.MinEvents,
+			WebhookURL:        r.anomalyDetectionConfig.Webhook.URL,
+			WebhookTimeout:    r.anomalyDetectionConfig.Webhook.Timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.anomalyDetector = detector
+		// All components must log with the wrapped logger, so that their errors are counted
+		r.logger = r.logger.WithOptions(zap.WrapCore(detector.WrapCore))
+	}
+
 	// Default value for graphql path
 	if r.graphqlPath == "" {
 		r.graphqlPath = "/graphql"
@@ -232,6 +404,58 @@ func NewRouter(opts ...Option) (*Router, error) {
 
 	r.processStartTime = time.Now()
 
+	if r.lifecycleWebhooksConfig != nil && r.lifecycleWebhooksConfig.Enabled {
+		endpoints := make([]LifecycleWebhookEndpoint, 0, len(r.lifecycleWebhooksConfig.Endpoints))
+		for _, endpoint := range r.lifecycleWebhooksConfig.Endpoints {
+			events := make([]LifecycleEventType, 0, len(endpoint.Events))
+			for _, event := range endpoint.Events {
+				events = append(events, LifecycleEventType(event))
+			}
+			endpoints = append(endpoints, LifecycleWebhookEndpoint{
+				URL:     endpoint.URL,
+				Format:  LifecycleWebhookFormat(endpoint.Format),
+				Events:  events,
+				Headers: endpoint.Headers,
+			})
+		}
+
+		notifier, err := NewLifecycleNotifier(&LifecycleNotifierOptions{
+			Logger:      r.logger,
+			Endpoints:   endpoints,
+			Timeout:     r.lifecycleWebhooksConfig.Timeout,
+			InstanceID:  r.instanceID,
+			ClusterName: r.clusterName,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.lifecycleNotifier = notifier
+	}
+
+	if r.clusterPeersConfig != nil && r.clusterPeersConfig.Enabled {
+		if r.adminConfig == nil || !r.adminConfig.Enabled {
+			return nil, errors.New("cluster peers require the admin api")
+		}
+		peers, err := NewClusterPeers(&ClusterPeersOptions{
+			Logger:       r.logger,
+			InstanceID:   r.instanceID,
+			ClusterName:  r.clusterName,
+			AdvertiseURL: r.clusterPeersConfig.AdvertiseURL,
+			Seeds:        r.clusterPeersConfig.Seeds,
+			Interval:     r.clusterPeersConfig.Interval,
+			TTL:          r.clusterPeersConfig.TTL,
+			Token:        r.adminConfig.Token,
+			ConfigVersion: func() string {
+				return r.activeRouterConfig.Load().GetVersion()
+			},
+			StartedAt: r.processStartTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.clusterPeers = peers
+	}
+
 	// Create noop tracer and meter to avoid nil pointer panics and to avoid checking for nil everywhere
 
 	r.tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
@@ -273,447 +497,1556 @@ func NewRouter(opts ...Option) (*Router, error) {
 		}
 	}
 
-	if r.ipAnonymization == nil {
-		r.ipAnonymization = &IPAnonymizationConfig{
-			Enabled: true,
-			Method:  Redact,
-		}
+	if r.adminConfig == nil {
+		r.adminConfig = &AdminServerConfig{}
 	}
 
-	// Default values for health check paths
+	if r.maintenanceConfig == nil {
+		r.maintenanceConfig = DefaultMaintenanceConfig()
+	}
 
-	if r.healthCheckPath == "" {
-		r.healthCheckPath = "/health"
+	if r.adminConfig.Enabled && r.adminConfig.Pprof.Enabled && r.adminConfig.Token == "" && !r.adminConfig.OIDC.Enabled &&
+		(!r.adminConfig.TLS.Enabled || r.adminConfig.TLS.ClientCAFile == "") {
+		return nil, errors.New("the admin API requires a token, OIDC or client certificates when the pprof endpoints are enabled")
 	}
-	if r.readinessCheckPath == "" {
-		r.readinessCheckPath = "/health/ready"
+
+	if r.startupReportConfig == nil {
+		r.startupReportConfig = DefaultStartupReportConfig()
 	}
-	if r.livenessCheckPath == "" {
-		r.livenessCheckPath = "/health/live"
+
+	maintenanceMode, err := NewMaintenanceMode(r.maintenanceConfig)
+	if err != nil {
+		return nil, err
 	}
+	r.maintenanceMode = maintenanceMode
 
-	hr, err := NewHeaderTransformer(r.headerRules)
+	persistedOpKillSwitch, err := NewPersistedOperationKillSwitch(r.logger, &r.securityConfiguration.PersistedOperationKillSwitch)
 	if err != nil {
 		return nil, err
 	}
+	r.persistedOpKillSwitch = persistedOpKillSwitch
 
-	r.headerRuleEngine = hr
+	if r.securityConfiguration.FieldBlocking.Enabled {
+		r.fieldBlocker, err = NewFieldBlocker(r.logger, &r.securityConfiguration.FieldBlocking)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create field blocker: %w", err)
+		}
+	}
 
-	r.preOriginHandlers = append(r.preOriginHandlers, r.headerRuleEngine.OnOriginRequest)
+	if r.memorySoftLimitConfig != nil && r.memorySoftLimitConfig.Enabled {
+		threshold := r.memorySoftLimitConfig.Threshold.Uint64()
+		if threshold == 0 {
+			threshold = DefaultMemorySoftLimitThreshold()
+		}
 
-	defaultHeaders := []string{
-		// Common headers
-		"authorization",
-		"origin",
-		"content-length",
-		"content-type",
-		// Semi standard client info headers
-		"graphql-client-name",
-		"graphql-client-version",
-		// Apollo client info headers
-		"apollographql-client-name",
-		"apollographql-client-version",
-		// Required for WunderGraph ART
-		"x-wg-trace",
-		"x-wg-token",
-		// Required for Trace Context propagation
-		"traceparent",
-		"tracestate",
-		// Required for feature flags
-		"x-feature-flag",
+		r.memoryGuard, err = NewMemoryGuard(&MemoryGuardOptions{
+			Logger:        r.logger,
+			Threshold:     threshold,
+			CheckInterval: r.memorySoftLimitConfig.CheckInterval,
+			ShrinkCaches:  r.memorySoftLimitConfig.ShrinkCaches,
+			ShedLoad:      r.memorySoftLimitConfig.ShedLoad,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	defaultMethods := []string{
-		"HEAD", "GET", "POST",
-	}
-	r.corsOptions.AllowHeaders = stringsx.RemoveDuplicates(append(r.corsOptions.AllowHeaders, defaultHeaders...))
-	r.corsOptions.AllowMethods = stringsx.RemoveDuplicates(append(r.corsOptions.AllowMethods, defaultMethods...))
+	if r.requestSigningConfig != nil && r.requestSigningConfig.Enabled {
+		keys := make([]RequestSigningKey, 0, len(r.requestSigningConfig.Keys))
+		for _, key := range r.requestSigningConfig.Keys {
+			secret, err := readSecret(key.Secret, key.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid request signing key '%s': %w", key.ID, err)
+			}
+			keys = append(keys, RequestSigningKey{ID: key.ID, Secret: secret, Algorithm: key.Algorithm})
+		}
 
-	if r.tlsConfig != nil && r.tlsConfig.Enabled {
-		r.baseURL = fmt.Sprintf("https://%s", r.listenAddr)
-	} else {
-		r.baseURL = fmt.Sprintf("http://%s", r.listenAddr)
+		r.requestSignatureVerifier, err = NewRequestSignatureVerifier(&RequestSignatureVerifierOptions{
+			Logger:          r.logger,
+			Keys:            keys,
+			SignatureHeader: r.requestSigningConfig.SignatureHeader,
+			TimestampHeader: r.requestSigningConfig.TimestampHeader,
+			KeyIDHeader:     r.requestSigningConfig.KeyIDHeader,
+			ReplayWindow:    r.requestSigningConfig.ReplayWindow,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if r.tlsConfig != nil && r.tlsConfig.Enabled {
-		if r.tlsConfig.CertFile == "" {
-			return nil, errors.New("tls cert file not provided")
+	if r.sealedVariablesConfig != nil && r.sealedVariablesConfig.Enabled {
+		keys := make([]SealedVariablesKey, 0, len(r.sealedVariablesConfig.Keys))
+		for _, key := range r.sealedVariablesConfig.Keys {
+			secret, err := readSecret(key.Secret, key.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sealed variables key '%s': %w", key.ID, err)
+			}
+			keys = append(keys, SealedVariablesKey{ID: key.ID, Secret: secret})
 		}
 
-		if r.tlsConfig.KeyFile == "" {
-			return nil, errors.New("tls key file not provided")
+		r.sealedVariables, err = NewSealedVariables(&SealedVariablesOptions{Keys: keys})
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		var caCertPool *x509.CertPool
-		clientAuthMode := tls.NoClientCert
+	if r.botDetectionConfig != nil && r.botDetectionConfig.Enabled {
+		r.botDetector, err = NewBotDetector(&BotDetectorOptions{
+			Logger:               r.logger,
+			Action:               BotDetectionAction(r.botDetectionConfig.Action),
+			Threshold:            r.botDetectionConfig.Threshold,
+			TagHeader:            r.botDetectionConfig.TagHeader,
+			LogScores:            r.botDetectionConfig.LogScores,
+			BurstWindow:          r.botDetectionConfig.Burst.Window,
+			BurstMaxRequests:     r.botDetectionConfig.Burst.MaxRequests,
+			RateLimitWindow:      r.botDetectionConfig.RateLimit.Window,
+			RateLimitMaxRequests: r.botDetectionConfig.RateLimit.MaxRequests,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		if r.tlsConfig.ClientAuth != nil && r.tlsConfig.ClientAuth.CertFile != "" {
-			caCert, err := os.ReadFile(r.tlsConfig.ClientAuth.CertFile)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read cert file: %w", err)
-			}
+	if r.operationTimeoutsConfig != nil {
+		r.operationTimeouts, err = NewOperationTimeouts(r.operationTimeoutsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			// Create a CA an empty cert pool and add the CA cert to it to serve as authority to validate client certs
-			caPool := x509.NewCertPool()
-			if ok := caPool.AppendCertsFromPEM(caCert); !ok {
-				return nil, errors.New("failed to append cert to pool")
+	if r.fetchConcurrencyConfig != nil {
+		operations := make(map[string]int, len(r.fetchConcurrencyConfig.Operations))
+		for _, op := range r.fetchConcurrencyConfig.Operations {
+			if _, ok := operations[op.Name]; ok {
+				return nil, fmt.Errorf("duplicate fetch concurrency override for operation '%s'", op.Name)
 			}
-			caCertPool = caPool
+			operations[op.Name] = op.MaxPerRequest
+		}
+		r.fetchConcurrency, err = NewFetchConcurrency(&FetchConcurrencyOptions{
+			MaxPerRequest: r.fetchConcurrencyConfig.MaxPerRequest,
+			Operations:    operations,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			if r.tlsConfig.ClientAuth.Required {
-				clientAuthMode = tls.RequireAndVerifyClientCert
-			} else {
-				clientAuthMode = tls.VerifyClientCertIfGiven
+	if c := r.entityBatchingConfig; c != nil && (c.MaxBatchSize > 0 || c.BatchWait > 0 || len(c.DedupeKeys) > 0) {
+		dedupeKeys := make(map[string][]string, len(c.DedupeKeys))
+		for _, key := range c.DedupeKeys {
+			if _, ok := dedupeKeys[key.TypeName]; ok {
+				return nil, fmt.Errorf("duplicate entity dedupe key for type '%s'", key.TypeName)
 			}
-
-			r.logger.Debug("Client auth enabled", zap.String("mode", clientAuthMode.String()))
+			dedupeKeys[key.TypeName] = key.Fields
 		}
+		// The batcher is created by every graph server with its metric store
+		r.entityBatching = &EntityBatcherOptions{
+			MaxBatchSize: c.MaxBatchSize,
+			BatchWait:    c.BatchWait,
+			DedupeKeys:   dedupeKeys,
+		}
+	}
 
-		// Load the server cert and private key
-		cer, err := tls.LoadX509KeyPair(r.tlsConfig.CertFile, r.tlsConfig.KeyFile)
+	if r.responseSizeLimitConfig != nil && r.responseSizeLimitConfig.Enabled {
+		r.responseSizeLimit, err = NewResponseSizeLimit(r.responseSizeLimitConfig)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load tls cert and key: %w", err)
+			return nil, err
 		}
+	}
 
-		r.tlsServerConfig = &tls.Config{
-			ClientCAs:    caCertPool,
-			Certificates: []tls.Certificate{cer},
-			ClientAuth:   clientAuthMode,
+	if r.requestMemoryLimitConfig != nil && r.requestMemoryLimitConfig.Enabled {
+		r.requestMemoryLimit, err = NewRequestMemoryLimit(r.requestMemoryLimitConfig)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// Add default tracing exporter if needed
-	if r.traceConfig.Enabled && len(r.traceConfig.Exporters) == 0 && r.traceConfig.TestMemoryExporter == nil {
-		if endpoint := otelconfig.DefaultEndpoint(); endpoint != "" {
-			r.logger.Debug("Using default trace exporter", zap.String("endpoint", endpoint))
-			r.traceConfig.Exporters = append(r.traceConfig.Exporters, &rtrace.ExporterConfig{
-				Endpoint: endpoint,
-				Exporter: otelconfig.ExporterOLTPHTTP,
-				HTTPPath: "/v1/traces",
-				Headers:  otelconfig.DefaultEndpointHeaders(r.graphApiToken),
-			})
+	if r.persistedOpUsageConfig != nil && r.persistedOpUsageConfig.Enabled {
+		r.persistedOpUsage, err = NewPersistedOperationUsageTracker(&PersistedOperationUsageOptions{
+			MaxOperations: r.persistedOpUsageConfig.MaxOperations,
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// Add default metric exporter if none are configured
-	if r.metricConfig.OpenTelemetry.Enabled && len(r.metricConfig.OpenTelemetry.Exporters) == 0 && r.metricConfig.OpenTelemetry.TestReader == nil {
-		if endpoint := otelconfig.DefaultEndpoint(); endpoint != "" {
-			r.logger.Debug("Using default metrics exporter", zap.String("endpoint", endpoint))
-			r.metricConfig.OpenTelemetry.Exporters = append(r.metricConfig.OpenTelemetry.Exporters, &rmetric.OpenTelemetryExporter{
-				Endpoint: endpoint,
-				Exporter: otelconfig.ExporterOLTPHTTP,
-				HTTPPath: "/v1/metrics",
-				Headers:  otelconfig.DefaultEndpointHeaders(r.graphApiToken),
-			})
+	if r.persistedOpManifestCfg != nil && r.persistedOpManifestCfg.Enabled {
+		r.persistedOpManifest, err = NewPersistedOperationManifest(&PersistedOperationManifestOptions{
+			Logger:        r.logger.Named("persisted_operation_manifest"),
+			Path:          r.persistedOpManifestCfg.Path,
+			ClientName:    r.persistedOpManifestCfg.ClientName,
+			MaxOperations: r.persistedOpManifestCfg.MaxOperations,
+			WriteInterval: r.persistedOpManifestCfg.WriteInterval,
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	var disabledFeatures []string
-
-	// The user might want to start the server with a static config
-	// Disable all features that requires a valid graph token and inform the user
-	if r.graphApiToken == "" {
-		r.graphqlMetricsConfig.Enabled = false
+	if r.configAuditConfig != nil && r.configAuditConfig.Enabled {
+		r.configAudit, err = NewConfigAuditLog(r.configAuditConfig.MaxEntries)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		disabledFeatures = append(disabledFeatures, "Schema Usage Tracking", "Persistent operations")
+	if r.auditLogConfig != nil && r.auditLogConfig.Enabled {
+		r.auditLogger, err = logging.NewAuditLogger(&logging.AuditOptions{
+			Path:    r.auditLogConfig.Path,
+			HMACKey: []byte(r.auditLogConfig.HMACKey),
+			Claims:  r.auditLogConfig.Claims,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.accessController.audit = r.auditLogger
+	}
 
-		if !r.developmentMode {
-			disabledFeatures = append(disabledFeatures, "Advanced Request Tracing")
+	if r.executionAuditConfig != nil && r.executionAuditConfig.Enabled {
+		res, err := rtrace.NewResource(context.Background(), r.traceConfig, r.instanceID)
+		if err != nil {
+			return nil, err
+		}
+		r.executionAudit, err = newExecutionAuditFromConfig(r.executionAuditConfig, res, r.logger)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if r.traceConfig.Enabled {
-			defaultExporter := rtrace.DefaultExporter(r.traceConfig)
-			if defaultExporter != nil {
-				disabledFeatures = append(disabledFeatures, "Cosmo Cloud Tracing")
-				defaultExporter.Disabled = true
-			}
+	if r.restEndpointsConfig != nil && r.restEndpointsConfig.Enabled {
+		r.restBridge, err = NewRESTBridge(&RESTBridgeOptions{
+			BasePath:    r.restEndpointsConfig.BasePath,
+			OpenAPIPath: r.restEndpointsConfig.OpenAPIPath,
+			Endpoints:   r.restEndpointsConfig.Endpoints,
+			Logger:      r.logger.Named("rest"),
+		})
+		if err != nil {
+			return nil, err
 		}
-		if r.metricConfig.OpenTelemetry.Enabled {
-			defaultExporter := rmetric.GetDefaultExporter(r.metricConfig)
-			if defaultExporter != nil {
-				disabledFeatures = append(disabledFeatures, "Cosmo Cloud Metrics")
-				defaultExporter.Disabled = true
+	}
+
+	if r.subWebhooksConfig != nil && r.subWebhooksConfig.Enabled {
+		webhooks := make([]SubscriptionWebhook, 0, len(r.subWebhooksConfig.Webhooks))
+		for _, webhook := range r.subWebhooksConfig.Webhooks {
+			secret, err := readSecret(webhook.Signing.Secret, webhook.Signing.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid signing key of subscription webhook '%s': %w", webhook.Name, err)
 			}
+			webhooks = append(webhooks, SubscriptionWebhook{
+				Name:                webhook.Name,
+				URL:                 webhook.URL,
+				Headers:             webhook.Headers,
+				Query:               webhook.Query,
+				OperationName:       webhook.OperationName,
+				Variables:           webhook.Variables,
+				ClientName:          webhook.ClientName,
+				SubscriptionHeaders: webhook.SubscriptionHeaders,
+				SigningKey: RequestSigningKey{
+					ID:        webhook.Signing.ID,
+					Secret:    secret,
+					Algorithm: webhook.Signing.Algorithm,
+				},
+			})
 		}
 
-		r.logger.Warn("No graph token provided. The following features are disabled. Not recommended for Production.", zap.Strings("features", disabledFeatures))
+		r.subscriptionWebhooks, err = NewSubscriptionWebhooks(&SubscriptionWebhooksOptions{
+			Logger:           r.logger.Named("subscription_webhooks"),
+			Webhooks:         webhooks,
+			Timeout:          r.subWebhooksConfig.Timeout,
+			MaxRetries:       r.subWebhooksConfig.MaxRetries,
+			RetryInterval:    r.subWebhooksConfig.RetryInterval,
+			MaxRetryInterval: r.subWebhooksConfig.MaxRetryInterval,
+			QueueSize:        r.subWebhooksConfig.QueueSize,
+			SignatureHeader:  r.subWebhooksConfig.SignatureHeader,
+			TimestampHeader:  r.subWebhooksConfig.TimestampHeader,
+			KeyIDHeader:      r.subWebhooksConfig.KeyIDHeader,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if r.graphApiToken != "" {
-		routerCDN, err := cdn.NewPersistentOperationClient(r.cdnConfig.URL, r.graphApiToken, cdn.PersistentOperationsOptions{
-			CacheSize: r.cdnConfig.CacheSize.Uint64(),
-			Logger:    r.logger,
+	if r.surrogateKeysConfig != nil && r.surrogateKeysConfig.Enabled {
+		endpoints := make([]SurrogateKeyPurgeEndpoint, 0, len(r.surrogateKeysConfig.Purge.Endpoints))
+		for _, endpoint := range r.surrogateKeysConfig.Purge.Endpoints {
+			endpoints = append(endpoints, SurrogateKeyPurgeEndpoint{
+				URL:        endpoint.URL,
+				Method:     endpoint.Method,
+				Headers:    endpoint.Headers,
+				KeysHeader: endpoint.KeysHeader,
+			})
+		}
+
+		r.surrogateKeys, err = NewSurrogateKeys(&SurrogateKeysOptions{
+			Logger:         r.logger.Named("surrogate_keys"),
+			Header:         r.surrogateKeysConfig.Header,
+			MaxKeys:        r.surrogateKeysConfig.MaxKeys,
+			TypeKeys:       r.surrogateKeysConfig.TypeKeys,
+			Purge:          r.surrogateKeysConfig.Purge.Enabled,
+			PurgeTimeout:   r.surrogateKeysConfig.Purge.Timeout,
+			PurgeEndpoints: endpoints,
 		})
 		if err != nil {
 			return nil, err
 		}
-		r.cdnPersistentOpClient = routerCDN
 	}
 
-	if r.developmentMode {
-		r.logger.Warn("Development mode enabled. This should only be used for testing purposes")
+	if r.accessLogsConfig != nil && r.accessLogsConfig.Sampling.Enabled {
+		r.accessLogSampler, err = NewAccessLogSampler(&r.accessLogsConfig.Sampling)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	for _, source := range r.eventsConfig.Providers.Nats {
-		r.logger.Info("Nats Event source enabled", zap.String("providerID", source.ID), zap.String("url", source.URL))
+	if r.accessLogsConfig != nil && r.accessLogsConfig.UserAgent.Enabled {
+		if r.accessLogsConfig.UserAgent.CacheSize < 0 {
+			return nil, errors.New("the cache size of the user agents must not be negative")
+		}
+		r.userAgentParser = useragent.NewParser(r.accessLogsConfig.UserAgent.CacheSize)
 	}
-	for _, source := range r.eventsConfig.Providers.Kafka {
-		r.logger.Info("Kafka Event source enabled", zap.String("providerID", source.ID), zap.Strings("brokers", source.Brokers))
+
+	if r.etagsConfig != nil && r.etagsConfig.Enabled {
+		r.etags = NewETags(&ETagsOptions{Weak: r.etagsConfig.Weak})
 	}
 
-	return r, nil
-}
+	if r.requestTagsConfig != nil && r.requestTagsConfig.Enabled {
+		r.requestTagger, err = NewRequestTagger(r.requestTagsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the request tags: %w", err)
+		}
+	}
 
-// UpdateServer creates a new server and swaps the active server with the new one. The old server is shutdown gracefully.
-// When the router can't be swapped due to an error the old server kept running. Not safe for concurrent use.
-func (r *Router) UpdateServer(ctx context.Context, cfg *nodev1.RouterConfig) (Server, error) {
-	// Rebuild server with new router config
-	// In case of an error, we return early and keep the old server running
-	newServer, err := r.newServer(ctx, cfg)
-	if err != nil {
-		r.logger.Error("Failed to create a new router instance. Keeping old router running", zap.Error(err))
-		return nil, err
+	if r.operationFingerprintCfg != nil {
+		r.operationFingerprint, err = NewOperationFingerprintOptions(r.operationFingerprintCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the operation fingerprint: %w", err)
+		}
 	}
 
-	// Shutdown old server
-	if r.activeServer != nil {
-
-		// Respect grace period
-		if r.gracePeriod > 0 {
-			ctxWithTimer, cancel := context.WithTimeout(ctx, r.gracePeriod)
-			defer cancel()
-
-			ctx = ctxWithTimer
-		}
-
-		if err := r.activeServer.Shutdown(ctx); err != nil {
-			r.logger.Error("Could not shutdown router", zap.Error(err))
-
-			if errors.Is(err, context.DeadlineExceeded) {
-				r.logger.Warn(
-					"Shutdown deadline exceeded. Router took too long to shutdown. Consider increasing the grace period",
-					zap.Duration("grace_period", r.gracePeriod),
-				)
-			}
+	if r.variableRedactionConfig != nil && r.variableRedactionConfig.Enabled {
+		r.variableRedactor = NewVariableRedactor(r.variableRedactionConfig)
+	}
 
+	if r.syntheticHealthConfig != nil && r.syntheticHealthConfig.Enabled {
+		r.syntheticHealth, err = NewSyntheticHealthOperation(&SyntheticHealthOperationOptions{
+			Logger:        r.logger.Named("synthetic_health"),
+			Path:          r.graphqlPath,
+			Interval:      r.syntheticHealthConfig.Interval,
+			Timeout:       r.syntheticHealthConfig.Timeout,
+			Query:         r.syntheticHealthConfig.Query,
+			OperationName: r.syntheticHealthConfig.OperationName,
+			Variables:     r.syntheticHealthConfig.Variables,
+			Headers:       r.syntheticHealthConfig.Headers,
+			ClientName:    r.syntheticHealthConfig.ClientName,
+		})
+		if err != nil {
 			return nil, err
 		}
 	}
 
-	// Swap active server
-	r.activeServer = newServer
-
-	return newServer, nil
-}
-
-func (r *Router) updateServerAndStart(ctx context.Context, cfg *nodev1.RouterConfig) error {
-
-	if _, err := r.UpdateServer(ctx, cfg); err != nil {
-		return err
+	if r.clockSkewConfig != nil && r.clockSkewConfig.Enabled {
+		url := r.clockSkewConfig.URL
+		if url == "" {
+			url = r.cdnConfig.URL
+		}
+		r.clockSkew, err = NewClockSkewCheck(&ClockSkewCheckOptions{
+			Logger:    r.logger,
+			Source:    r.clockSkewConfig.Source,
+			URL:       url,
+			NTPServer: r.clockSkewConfig.NTPServer,
+			Interval:  r.clockSkewConfig.Interval,
+			Threshold: r.clockSkewConfig.Threshold,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the clock skew check: %w", err)
+		}
 	}
 
-	// read here to avoid race condition
-	version := r.activeServer.baseRouterConfigVersion
-
-	// Start new server
-	go func() {
+	if r.certExpiryConfig != nil && r.certExpiryConfig.Enabled {
+		r.certExpiry, err = NewCertificateExpiryCheck(&CertificateExpiryCheckOptions{
+			Logger:     r.logger,
+			Interval:   r.certExpiryConfig.Interval,
+			WarnBefore: r.certExpiryConfig.WarnBefore,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the certificate expiry check: %w", err)
+		}
+	}
 
-		r.logger.Info("Server listening and serving",
-			zap.String("listen_addr", r.listenAddr),
-			zap.Bool("playground", r.playground),
-			zap.Bool("introspection", r.introspection),
-			zap.String("config_version", cfg.GetVersion()),
+	if r.chaosConfig != nil && r.chaosConfig.Enabled {
+		r.chaos, err = NewChaosInjector(r.logger.Named("chaos"), r.chaosConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the chaos mode: %w", err)
+		}
+		r.logger.Warn("Chaos mode enabled. Faults are injected into the subgraph requests. This should only be used for testing purposes",
+			zap.Int("rules", len(r.chaosConfig.Rules)),
 		)
+	}
 
-		r.activeServer.healthChecks.SetReady(true)
+	if r.subgraphMocks != nil && r.subgraphMocks.Enabled {
+		r.logger.Warn("Subgraph mocks enabled. The requests to the subgraphs are answered with generated data. This should only be used for development",
+			zap.Bool("unreachable", r.subgraphMocks.Unreachable),
+			zap.Strings("subgraphs", r.subgraphMocks.Subgraphs),
+		)
+	}
 
-		// This is a blocking call
-		if err := r.activeServer.listenAndServe(); err != nil {
-			r.activeServer.healthChecks.SetReady(false)
-			r.logger.Error("Failed to start new server", zap.Error(err))
+	if r.subgraphEndpointsConfig != nil && r.subgraphEndpointsConfig.Enabled {
+		r.subgraphEndpoints, err = NewSubgraphEndpointSwitch(r.logger.Named("subgraph_endpoints"), r.subgraphEndpointsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the subgraph endpoints: %w", err)
 		}
+	}
 
-		r.logger.Info("Server stopped", zap.String("config_version", version))
-	}()
-
-	return nil
-}
-
-func (r *Router) initModules(ctx context.Context) error {
-	for _, moduleInfo := range modules {
-		now := time.Now()
-
-		moduleInstance := moduleInfo.New()
-
-		mc := &ModuleContext{
-			Context: ctx,
-			Module:  moduleInstance,
-			Logger:  r.logger.With(zap.String("module", string(moduleInfo.ID))),
+	if r.adaptiveConcurrencyCfg != nil && r.adaptiveConcurrencyCfg.Enabled {
+		r.adaptiveConcurrency, err = NewAdaptiveConcurrency(r.logger.Named("adaptive_concurrency"), r.adaptiveConcurrencyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the adaptive concurrency: %w", err)
 		}
+	}
 
-		moduleConfig, ok := r.modulesConfig[string(moduleInfo.ID)]
-		if ok {
-			if err := mapstructure.Decode(moduleConfig, &moduleInstance); err != nil {
-				return fmt.Errorf("failed to decode module config from module %s: %w", moduleInfo.ID, err)
-			}
-		} else {
-			r.logger.Debug("No config found for module", zap.String("id", string(moduleInfo.ID)))
+	if len(r.subgraphTunnelsConfig.Subgraphs) > 0 {
+		r.subgraphTunnels, err = NewSubgraphTunnels(r.logger.Named("subgraph_tunnels"), r.subgraphTunnelsConfig, r.subgraphTransportOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the subgraph tunnels: %w", err)
 		}
+	}
 
-		if fn, ok := moduleInstance.(Provisioner); ok {
-			if err := fn.Provision(mc); err != nil {
-				return fmt.Errorf("failed to provision module '%s': %w", moduleInfo.ID, err)
-			}
+	if r.slowSubgraphWarningsCfg != nil && r.slowSubgraphWarningsCfg.Enabled {
+		r.slowSubgraphWarnings, err = NewSlowSubgraphWarnings(r.logger.Named("slow_subgraphs"), r.slowSubgraphWarningsCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the slow subgraph warnings: %w", err)
 		}
+	}
 
-		if fn, ok := moduleInstance.(RouterMiddlewareHandler); ok {
-			r.routerMiddlewares = append(r.routerMiddlewares, func(handler http.Handler) http.Handler {
-				return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-					reqContext := getRequestContext(request.Context())
-					// Ensure we work with latest request in the chain to work with the right context
-					reqContext.request = request
-					fn.Middleware(reqContext, handler)
-				})
-			})
+	if r.tenantsConfig != nil && r.tenantsConfig.Enabled {
+		r.tenantOverrides, err = NewTenantOverrides(r.logger.Named("tenants"), r.tenantsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the tenant overrides: %w", err)
 		}
+	}
 
-		if handler, ok := moduleInstance.(EnginePreOriginHandler); ok {
-			r.preOriginHandlers = append(r.preOriginHandlers, handler.OnOriginRequest)
-		}
+	if r.serverConfig == nil {
+		r.serverConfig = DefaultServerConfig()
+	}
 
-		if handler, ok := moduleInstance.(EnginePostOriginHandler); ok {
-			r.postOriginHandlers = append(r.postOriginHandlers, handler.OnOriginResponse)
+	r.drains = newDrainTracker(r.logger)
+
+	if r.configPoller != nil {
+		r.configDrift = newConfigDriftTracker(r.logger, r.configDriftThreshold)
+		if r.configFallbackPath != "" {
+			r.configFallback = newRouterConfigFallback(r.logger, r.configFallbackPath)
 		}
+	}
 
-		r.modules = append(r.modules, moduleInstance)
+	r.serverLimits = NewServerLimits(&ServerLimitsOptions{
+		Logger:              r.logger,
+		MaxHeaderBytes:      int(r.serverConfig.MaxHeaderBytes),
+		MaxConnections:      r.serverConfig.MaxConnections,
+		MaxConnectionsPerIP: r.serverConfig.MaxConnectionsPerIP,
+	})
 
-		r.logger.Info("Module registered",
-			zap.String("id", string(moduleInfo.ID)),
-			zap.String("duration", time.Since(now).String()),
-		)
+	if r.connectionTimingsConfig != nil && r.connectionTimingsConfig.Enabled {
+		r.connectionTimings = NewConnectionTimings(r.logger.Named("connection_timings"))
 	}
 
-	return nil
-}
+	if r.subscriptionLimitsConfig != nil {
+		limits, err := NewSubscriptionLimits(r.subscriptionLimitsConfig)
+		if err != nil {
+			return nil, err
+		}
+		if limits.Enabled() {
+			r.subscriptionLimits = limits
+		}
+	}
 
-// NewServer prepares a new server instance but does not start it. The method should only be used when you want to bootstrap
-// the server manually otherwise you can use Router.Start(). You're responsible for setting health checks status to ready with Server.HealthChecks().
-// The server can be shutdown with Router.Shutdown(). Use core.WithStaticRouterConfig to pass the initial config otherwise the Router will
-// try to fetch the config from the control plane. You can swap the router config by using Router.UpdateServer().
-func (r *Router) NewServer(ctx context.Context) (Server, error) {
-	if r.shutdown {
-		return nil, fmt.Errorf("router is shutdown. Create a new instance with router.NewRouter()")
+	if r.subscriptionReapingCfg != nil && r.subscriptionReapingCfg.Enabled {
+		r.subscriptionReaper = NewSubscriptionReaper(&SubscriptionReaperOptions{
+			Logger:      r.logger,
+			IdleTimeout: r.subscriptionReapingCfg.IdleTimeout,
+			Interval:    r.subscriptionReapingCfg.Interval,
+		})
 	}
 
-	if err := r.bootstrap(ctx); err != nil {
-		return nil, fmt.Errorf("failed to bootstrap application: %w", err)
+	if r.backpressureConfig != nil && r.backpressureConfig.Enabled {
+		backpressure, err := NewSubscriptionBackpressure(r.backpressureConfig, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		r.subscriptionBackpressure = backpressure
 	}
 
-	// Start the server with the static config without polling
-	if r.staticRouterConfig != nil {
-		r.logger.Info("Static router config provided. Polling is disabled. Updating router config is only possible by providing a config.")
-		return r.UpdateServer(ctx, r.staticRouterConfig)
+	if r.webSocketConfiguration != nil && r.webSocketConfiguration.Enabled {
+		transport, err := NewWebSocketTransport(r.webSocketConfiguration)
+		if err != nil {
+			return nil, err
+		}
+		r.webSocketTransport = transport
 	}
 
-	// when no static config is provided and no poller is configured, we can't start the server
-	if r.configPoller == nil {
-		return nil, fmt.Errorf("config fetcher not provided. Please provide a static router config instead")
+	if r.deprecationConfig != nil && r.deprecationConfig.Enabled {
+		r.deprecations = NewDeprecationReporter(&DeprecationReporterOptions{
+			Logger:                r.logger,
+			LogInterval:           r.deprecationConfig.LogInterval,
+			ClientWarnings:        r.deprecationConfig.ResponseExtension.Enabled,
+			ClientWarningInterval: r.deprecationConfig.ResponseExtension.Interval,
+		})
+
+		if len(r.overrideRoutingURLConfiguration.Subgraphs) > 0 {
+			r.deprecations.Report(DeprecationKindConfig, "override_routing_url", "Use overrides.subgraphs.<name>.routing_url instead")
+		}
 	}
 
-	routerConfig, err := r.configPoller.GetRouterConfig(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get initial router config: %w", err)
+	if r.clientProtocolsConfig != nil && r.clientProtocolsConfig.Enabled {
+		r.clientProtocols = NewClientProtocols()
 	}
 
-	if _, err := r.UpdateServer(ctx, routerConfig); err != nil {
-		r.logger.Error("Failed to start server with initial config", zap.Error(err))
-		return nil, err
+	if r.sloConfig != nil && r.sloConfig.Enabled {
+		r.sloTracker, err = NewSLOTracker(&SLOTrackerOptions{
+			AvailabilityTarget: r.sloConfig.AvailabilityTarget,
+			LatencyTarget:      r.sloConfig.LatencyTarget,
+			LatencyThreshold:   r.sloConfig.LatencyThreshold,
+			Windows:            r.sloConfig.Windows,
+			MaxClients:         r.sloConfig.MaxClients,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return r.activeServer, nil
-}
+	if r.topOperationsConfig != nil && r.topOperationsConfig.Enabled {
+		r.topOperations, err = NewTopOperations(&TopOperationsOptions{
+			Window:        r.topOperationsConfig.Window,
+			MaxOperations: r.topOperationsConfig.MaxOperations,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-// bootstrap initializes the Router. It is called by Start() and NewServer().
-// It should only be called once for a Router instance. Not safe for concurrent use.
-func (r *Router) bootstrap(ctx context.Context) error {
-	if r.bootstrapped {
-		return fmt.Errorf("router is already bootstrapped")
+	configSwap := r.routerTrafficConfig.ConfigSwap
+	if configSwap.QueueTimeout <= 0 {
+		configSwap = DefaultRouterTrafficConfig().ConfigSwap
 	}
 
-	r.bootstrapped = true
+	r.swapHandler = newSwapHandler(&SwapHandlerOptions{
+		Logger:            r.logger,
+		MaxQueuedRequests: configSwap.MaxQueuedRequests,
+		QueueTimeout:      configSwap.QueueTimeout,
+	})
 
-	cosmoCloudTracingEnabled := r.traceConfig.Enabled && rtrace.DefaultExporter(r.traceConfig) != nil
-	artInProductionEnabled := r.engineExecutionConfiguration.EnableRequestTracing && !r.developmentMode
-	needsRegistration := cosmoCloudTracingEnabled || artInProductionEnabled
+	if r.ipAnonymization == nil {
+		r.ipAnonymization = &IPAnonymizationConfig{
+			Enabled: true,
+			Method:  Redact,
+		}
+	}
 
-	if needsRegistration && r.selfRegister != nil {
+	// The hashers are kept across config updates, so pseudonyms only change when the salt is rotated
+	r.ipHasher = anonymize.NewHasher(r.ipAnonymization.SaltRotationInterval)
+	if r.pseudonymizationConfig != nil && r.pseudonymizationConfig.Enabled {
+		r.pseudonymizer = anonymize.NewHasher(r.pseudonymizationConfig.SaltRotationInterval)
+	}
 
-		r.logger.Info("Registering router with control plane because you opted in to send telemetry to Cosmo Cloud or advanced request tracing (ART) in production")
+	// Default values for health check paths
 
-		ri, registerErr := r.selfRegister.Register(ctx)
-		if registerErr != nil {
-			r.logger.Warn("Failed to register router on the control plane. If this warning persists, please contact support.")
-		} else {
-			r.registrationInfo = ri
+	if r.healthCheckPath == "" {
+		r.healthCheckPath = "/health"
+	}
+	if r.readinessCheckPath == "" {
+		r.readinessCheckPath = "/health/ready"
+	}
+	if r.livenessCheckPath == "" {
+		r.livenessCheckPath = "/health/live"
+	}
 
-			// Only ensure sampling rate if the user exports traces to Cosmo Cloud
-			if cosmoCloudTracingEnabled {
-				if r.traceConfig.Sampler > float64(r.registrationInfo.AccountLimits.TraceSamplingRate) {
-					r.logger.Warn("Trace sampling rate is higher than account limit. Using account limit instead. Please contact support to increase your account limit.",
-						zap.Float64("limit", r.traceConfig.Sampler),
-						zap.String("account_limit", fmt.Sprintf("%.2f", r.registrationInfo.AccountLimits.TraceSamplingRate)),
-					)
-					r.traceConfig.Sampler = float64(r.registrationInfo.AccountLimits.TraceSamplingRate)
-				}
-			}
+	if r.nonGraphQLRequestsConfig != nil && (r.nonGraphQLRequestsConfig.AccessLogs || r.nonGraphQLRequestsConfig.Metrics) {
+		knownPaths := []string{r.graphqlPath}
+		if r.versionEndpointConfig != nil && r.versionEndpointConfig.Enabled {
+			knownPaths = append(knownPaths, r.versionEndpointConfig.Path)
+		}
+		if r.rateLimitingEnabled() && r.rateLimit.QuotaEndpoint.Enabled {
+			knownPaths = append(knownPaths, r.rateLimit.QuotaEndpoint.Path)
+		}
+		if r.webSocketConfiguration != nil && r.webSocketConfiguration.Enabled && r.webSocketConfiguration.AbsintheProtocol.Enabled {
+			knownPaths = append(knownPaths, r.webSocketConfiguration.AbsintheProtocol.HandlerPath)
 		}
-	}
 
-	if r.traceConfig.Enabled {
-		tp, err := rtrace.NewTracerProvider(ctx, &rtrace.ProviderConfig{
-			Logger:            r.logger,
-			Config:            r.traceConfig,
-			ServiceInstanceID: r.instanceID,
-			IPAnonymization: &rtrace.IPAnonymizationConfig{
+		opts := &NonGraphQLRequestsOptions{
+			Metrics:      r.nonGraphQLRequestsConfig.Metrics,
+			HealthPaths:  []string{r.healthCheckPath, r.readinessCheckPath, r.livenessCheckPath},
+			KnownPaths:   knownPaths,
+			ExcludePaths: r.nonGraphQLRequestsConfig.ExcludePaths,
+			IPAnonymizer: &requestlogger.IPAnonymizationConfig{
 				Enabled: r.ipAnonymization.Enabled,
-				Method:  rtrace.IPAnonymizationMethod(r.ipAnonymization.Method),
+				Method:  requestlogger.IPAnonymizationMethod(r.ipAnonymization.Method),
+				Hasher:  r.ipHasher,
 			},
-			MemoryExporter: r.traceConfig.TestMemoryExporter,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to start trace agent: %w", err)
 		}
-		r.tracerProvider = tp
+		if r.playground {
+			opts.PlaygroundPath = r.playgroundPath
+		}
+		if r.nonGraphQLRequestsConfig.AccessLogs {
+			opts.Logger = r.logger.Named("access")
+			if r.accessLogger != nil {
+				opts.Logger = r.accessLogger
+			}
+		}
+		r.nonGraphQLRequests = NewNonGraphQLRequests(opts)
 	}
 
-	// Prometheus metrics rely on OTLP metrics
-	if r.metricConfig.IsEnabled() {
-		if r.metricConfig.Prometheus.Enabled {
-			mp, registry, err := rmetric.NewPrometheusMeterProvider(ctx, r.metricConfig, r.instanceID)
-			if err != nil {
-				return fmt.Errorf("failed to create Prometheus exporter: %w", err)
-			}
-			r.promMeterProvider = mp
+	hr, err := NewHeaderTransformer(r.headerRules)
+	if err != nil {
+		return nil, err
+	}
 
-			r.prometheusServer = rmetric.NewPrometheusServer(r.logger, r.metricConfig.Prometheus.ListenAddr, r.metricConfig.Prometheus.Path, registry)
-			go func() {
-				if err := r.prometheusServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-					r.logger.Error("Failed to start Prometheus server", zap.Error(err))
-				}
-			}()
-		}
+	r.headerRuleEngine = hr
 
-		if r.metricConfig.OpenTelemetry.Enabled {
-			mp, err := rmetric.NewOtlpMeterProvider(ctx, r.logger, r.metricConfig, r.instanceID)
-			if err != nil {
-				return fmt.Errorf("failed to start trace agent: %w", err)
-			}
-			r.otlpMeterProvider = mp
-		}
+	r.preOriginHandlers = append(r.preOriginHandlers, r.headerRuleEngine.OnOriginRequest)
 
+	if r.tenantOverrides != nil {
+		r.preOriginHandlers = append(r.preOriginHandlers, r.tenantOverrides.OnOriginRequest)
 	}
 
-	r.gqlMetricsExporter = graphqlmetrics.NewNoopExporter()
+	// The credentials are set after the header rules, so that they replace a propagated Authorization header
+	if len(r.subgraphAuthentication.Subgraphs) > 0 {
+		subgraphAuthenticator, err := NewSubgraphAuthenticator(r.logger, r.subgraphAuthentication)
+		if err != nil {
+			return nil, err
+		}
+		r.preOriginHandlers = append(r.preOriginHandlers, subgraphAuthenticator.OnOriginRequest)
+	}
+
+	defaultHeaders := []string{
+		// Common headers
+		"authorization",
+		"origin",
+		"content-length",
+		"content-type",
+		// Semi standard client info headers
+		"graphql-client-name",
+		"graphql-client-version",
+		// Apollo client info headers
+		"apollographql-client-name",
+		"apollographql-client-version",
+		// Required for WunderGraph ART
+		"x-wg-trace",
+		"x-wg-token",
+		// Required for Trace Context propagation
+		"traceparent",
+		"tracestate",
+		// Required for feature flags
+		"x-feature-flag",
+	}
+
+	defaultMethods := []string{
+		"HEAD", "GET", "POST",
+	}
+	r.corsOptions.AllowHeaders = stringsx.RemoveDuplicates(append(r.corsOptions.AllowHeaders, defaultHeaders...))
+	r.corsOptions.AllowMethods = stringsx.RemoveDuplicates(append(r.corsOptions.AllowMethods, defaultMethods...))
+
+	if r.tlsConfig != nil && r.tlsConfig.Enabled {
+		r.baseURL = fmt.Sprintf("https://%s", r.listenAddr)
+	} else {
+		r.baseURL = fmt.Sprintf("http://%s", r.listenAddr)
+	}
+
+	if r.tlsConfig != nil && r.tlsConfig.Enabled {
+		if r.tlsConfig.CertFile == "" {
+			return nil, errors.New("tls cert file not provided")
+		}
+
+		if r.tlsConfig.KeyFile == "" {
+			return nil, errors.New("tls key file not provided")
+		}
+
+		var caCertPool *x509.CertPool
+		clientAuthMode := tls.NoClientCert
+
+		if r.tlsConfig.ClientAuth != nil && r.tlsConfig.ClientAuth.CertFile != "" {
+			caCert, err := os.ReadFile(r.tlsConfig.ClientAuth.CertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read cert file: %w", err)
+			}
+
+			// Create a CA an empty cert pool and add the CA cert to it to serve as authority to validate client certs
+			caPool := x509.NewCertPool()
+			if ok := caPool.AppendCertsFromPEM(caCert); !ok {
+				return nil, errors.New("failed to append cert to pool")
+			}
+			caCertPool = caPool
+
+			if r.tlsConfig.ClientAuth.Required {
+				clientAuthMode = tls.RequireAndVerifyClientCert
+			} else {
+				clientAuthMode = tls.VerifyClientCertIfGiven
+			}
+
+			r.logger.Debug("Client auth enabled", zap.String("mode", clientAuthMode.String()))
+		}
+
+		// Load the server cert and private key
+		cer, err := tls.LoadX509KeyPair(r.tlsConfig.CertFile, r.tlsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls cert and key: %w", err)
+		}
+		if r.certExpiry != nil {
+			if err := r.certExpiry.Add(ResponseHeadersListenerGraphQL, &cer); err != nil {
+				return nil, err
+			}
+		}
+
+		r.tlsServerConfig = &tls.Config{
+			ClientCAs:    caCertPool,
+			Certificates: []tls.Certificate{cer},
+			ClientAuth:   clientAuthMode,
+		}
+		r.tlsServerConfig = r.connectionTimings.TLSConfig(ResponseHeadersListenerGraphQL, r.tlsServerConfig)
+	}
+
+	// Add default tracing exporter if needed
+	if r.traceConfig.Enabled && len(r.traceConfig.Exporters) == 0 && r.traceConfig.TestMemoryExporter == nil {
+		if endpoint := otelconfig.DefaultEndpoint(); endpoint != "" {
+			r.logger.Debug("Using default trace exporter", zap.String("endpoint", endpoint))
+			r.traceConfig.Exporters = append(r.traceConfig.Exporters, &rtrace.ExporterConfig{
+				Endpoint: endpoint,
+				Exporter: otelconfig.ExporterOLTPHTTP,
+				HTTPPath: "/v1/traces",
+				Headers:  otelconfig.DefaultEndpointHeaders(r.graphApiToken),
+			})
+		}
+	}
+
+	// Add default metric exporter if none are configured
+	if r.metricConfig.OpenTelemetry.Enabled && len(r.metricConfig.OpenTelemetry.Exporters) == 0 && r.metricConfig.OpenTelemetry.TestReader == nil {
+		if endpoint := otelconfig.DefaultEndpoint(); endpoint != "" {
+			r.logger.Debug("Using default metrics exporter", zap.String("endpoint", endpoint))
+			r.metricConfig.OpenTelemetry.Exporters = append(r.metricConfig.OpenTelemetry.Exporters, &rmetric.OpenTelemetryExporter{
+				Endpoint: endpoint,
+				Exporter: otelconfig.ExporterOLTPHTTP,
+				HTTPPath: "/v1/metrics",
+				Headers:  otelconfig.DefaultEndpointHeaders(r.graphApiToken),
+			})
+		}
+	}
+
+	var disabledFeatures []string
+
+	// The user might want to start the server with a static config
+	// Disable all features that requires a valid graph token and inform the user
+	if r.graphApiToken == "" {
+		r.graphqlMetricsConfig.Enabled = false
+
+		disabledFeatures = append(disabledFeatures, "Schema Usage Tracking", "Persistent operations")
+
+		if !r.developmentMode {
+			disabledFeatures = append(disabledFeatures, "Advanced Request Tracing")
+		}
+
+		if r.traceConfig.Enabled {
+			defaultExporter := rtrace.DefaultExporter(r.traceConfig)
+			if defaultExporter != nil {
+				disabledFeatures = append(disabledFeatures, "Cosmo Cloud Tracing")
+				defaultExporter.Disabled = true
+			}
+		}
+		if r.metricConfig.OpenTelemetry.Enabled {
+			defaultExporter := rmetric.GetDefaultExporter(r.metricConfig)
+			if defaultExporter != nil {
+				disabledFeatures = append(disabledFeatures, "Cosmo Cloud Metrics")
+				defaultExporter.Disabled = true
+			}
+		}
+
+		r.logger.Warn("No graph token provided. The following features are disabled. Not recommended for Production.", zap.Strings("features", disabledFeatures))
+	}
+
+	if r.graphApiToken != "" {
+		routerCDN, err := cdn.NewPersistentOperationClient(r.cdnConfig.URL, r.graphApiToken, cdn.PersistentOperationsOptions{
+			CacheSize: r.cdnConfig.CacheSize.Uint64(),
+			Logger:    r.logger,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.cdnPersistentOpClient = routerCDN
+	}
+
+	if r.developmentMode {
+		r.logger.Warn("Development mode enabled. This should only be used for testing purposes")
+	}
+
+	if r.maintenanceMode.Enabled() {
+		r.logger.Warn("Router starts in maintenance mode. Matching operations are rejected until the mode is disabled")
+	}
+
+	for _, source := range r.eventsConfig.Providers.Nats {
+		r.logger.Info("Nats Event source enabled", zap.String("providerID", source.ID), zap.String("url", source.URL))
+	}
+	for _, source := range r.eventsConfig.Providers.Kafka {
+		r.logger.Info("Kafka Event source enabled", zap.String("providerID", source.ID), zap.Strings("brokers", source.Brokers))
+	}
+
+	return r, nil
+}
+
+// UpdateServer creates a new server and swaps the active server with the new one. The old server is shutdown gracefully.
+// When the router can't be swapped due to an error the old server kept running. Not safe for concurrent use.
+func (r *Router) UpdateServer(ctx context.Context, cfg *nodev1.RouterConfig) (Server, error) {
+	// Rebuild server with new router config
+	// In case of an error, we return early and keep the old server running
+	newServer, err := r.newServer(ctx, cfg)
+	if err != nil {
+		r.logger.Error("Failed to create a new router instance. Keeping old router running", zap.Error(err))
+		return nil, err
+	}
+
+	// Shutdown old server
+	if r.activeServer != nil {
+
+		// Respect grace period
+		if r.gracePeriod > 0 {
+			ctxWithTimer, cancel := context.WithTimeout(ctx, r.gracePeriod)
+			defer cancel()
+
+			ctx = ctxWithTimer
+		}
+
+		if err := r.activeServer.Shutdown(ctx); err != nil {
+			r.logger.Error("Could not shutdown router", zap.Error(err))
+
+			if errors.Is(err, context.DeadlineExceeded) {
+				r.logger.Warn(
+					"Shutdown deadline exceeded. Router took too long to shutdown. Consider increasing the grace period",
+					zap.Duration("grace_period", r.gracePeriod),
+				)
+			}
+
+			return nil, err
+		}
+	}
+
+	// Swap active server
+	r.activeServer = newServer
+	r.activeHandler.Store(&newServer.httpServer.Handler)
+	r.startServerTasks(newServer)
+
+	return newServer, nil
+}
+
+// startServerTasks runs the subscriptions of the webhooks and the synthetic health operation on the active server.
+// They are stopped with the server, so that the events of a subscription aren't sent twice while the servers are
+// swapped.
+func (r *Router) startServerTasks(s *server) {
+	if r.subscriptionWebhooks != nil {
+		s.stopSubscriptionWebhooks = r.subscriptionWebhooks.Start(s.graphqlHandler)
+	}
+	// The operation runs through the whole handler of the server, like a request of a client
+	if r.syntheticHealth != nil {
+		s.stopSyntheticHealth = r.syntheticHealth.Start(s.httpServer.Handler)
+	}
+}
+
+func (r *Router) updateServerAndStart(ctx context.Context, cfg *nodev1.RouterConfig) error {
+	prevConfig := r.activeRouterConfig.Load()
+
+	// Rebuild server with new router config
+	// In case of an error, we return early and keep the old server running
+	newServer, err := r.newServer(ctx, cfg)
+	if err != nil {
+		r.logger.Error("Failed to create a new router instance. Keeping old router running", zap.Error(err))
+		r.recordConfigChange(prevConfig, cfg, err)
+		return err
+	}
+
+	var shutdownErr error
+
+	if r.activeServer != nil {
+		shutdownErr = r.swapActiveServer(ctx)
+	}
+
+	// Swap active server and release all requests that were queued during the swap
+	r.activeServer = newServer
+	r.activeRouterConfig.Store(cfg)
+	r.activeHandler.Store(&newServer.httpServer.Handler)
+	r.swapHandler.completeSwap(newServer.httpServer.Handler)
+	r.recordConfigChange(prevConfig, cfg, nil)
+	r.startServerTasks(newServer)
+
+	if r.profiler != nil {
+		r.profiler.SetLabel(profiling.LabelRouterConfigVersion, cfg.GetVersion())
+	}
+
+	newServer.healthChecks.SetReady(true)
+
+	if r.httpServer != nil {
+		r.logger.Info("Server config updated", zap.String("config_version", cfg.GetVersion()))
+		r.notifyLifecycle(LifecycleEventConfigReload, "Router config updated", cfg.GetVersion(), nil)
+		return shutdownErr
+	}
+
+	r.httpServer = &http.Server{
+		Addr:              newServer.httpServer.Addr,
+		ReadTimeout:       newServer.httpServer.ReadTimeout,
+		WriteTimeout:      newServer.httpServer.WriteTimeout,
+		ReadHeaderTimeout: newServer.httpServer.ReadHeaderTimeout,
+		IdleTimeout:       newServer.httpServer.IdleTimeout,
+		MaxHeaderBytes:    newServer.httpServer.MaxHeaderBytes,
+		ErrorLog:          newServer.httpServer.ErrorLog,
+		TLSConfig:         newServer.httpServer.TLSConfig,
+		Handler:           r.swapHandler,
+		ConnState:         r.drains.connState,
+	}
+
+	if err := r.configureHTTP2(r.httpServer); err != nil {
+		return errors.Join(shutdownErr, err)
+	}
+
+	r.logStartupReport(cfg)
+
+	// Start server
+	go func() {
+
+		r.logger.Info("Server listening and serving",
+			zap.String("listen_addr", r.listenAddr),
+			zap.Bool("playground", r.playground),
+			zap.Bool("introspection", r.introspection),
+			zap.String("config_version", cfg.GetVersion()),
+		)
+
+		// This is a blocking call
+		if err := r.listenAndServe(); err != nil {
+			newServer.healthChecks.SetReady(false)
+			r.logger.Error("Failed to start server", zap.Error(err))
+		}
+
+		r.logger.Info("Server stopped")
+	}()
+
+	r.notifyLifecycle(LifecycleEventStartup, "Router started", cfg.GetVersion(), nil)
+
+	return shutdownErr
+}
+
+// recordConfigChange adds the router config to the config audit log and the security audit log, if they are
+// enabled. Only the configs polled from the CDN can have a verified signature.
+func (r *Router) recordConfigChange(prev, cfg *nodev1.RouterConfig, applyErr error) {
+	if r.configAudit == nil && r.auditLogger == nil {
+		return
+	}
+
+	source, signature := ConfigSourceStatic, ConfigSignatureUnverified
+	if r.configPoller != nil && cfg != r.staticRouterConfig {
+		source = ConfigSourceCDN
+		if r.configSignatureVerified {
+			signature = ConfigSignatureVerified
+		}
+	}
+
+	if r.configAudit != nil {
+		r.configAudit.Record(source, signature, prev, cfg, applyErr)
+	}
+	r.auditLogger.ConfigReload(source, cfg.GetVersion(), prev.GetVersion(), routerConfigHash(cfg), applyErr)
+}
+
+// notifyLifecycle sends the lifecycle webhooks of the event, if they are enabled, and calls the lifecycle hooks
+func (r *Router) notifyLifecycle(eventType LifecycleEventType, message, configVersion string, err error) {
+	if r.lifecycleNotifier != nil {
+		r.lifecycleNotifier.Notify(eventType, message, configVersion, err)
+	}
+	if len(r.lifecycleHooks) > 0 {
+		event := newLifecycleEvent(eventType, message, configVersion, r.instanceID, r.clusterName, err)
+		for _, hook := range r.lifecycleHooks {
+			hook(event)
+		}
+	}
+}
+
+// swapActiveServer queues all incoming requests, waits for the in-flight requests of the active server
+// and shuts it down. The grace period applies to the whole swap.
+func (r *Router) swapActiveServer(ctx context.Context) error {
+	if r.gracePeriod > 0 {
+		ctxWithTimer, cancel := context.WithTimeout(ctx, r.gracePeriod)
+		defer cancel()
+
+		ctx = ctxWithTimer
+	}
+
+	previous := r.swapHandler.beginSwap()
+
+	drainStart := time.Now()
+	requests := previous.requests.Load()
+
+	err := previous.wait(ctx)
+	r.drains.report(DrainReport{
+		Kind:      DrainKindConfigSwap,
+		Requests:  requests,
+		Duration:  time.Since(drainStart),
+		Completed: err == nil,
+	})
+	if err == nil {
+		err = r.activeServer.Shutdown(ctx)
+	}
+
+	if err != nil {
+		r.logger.Error("Could not shutdown router", zap.Error(err))
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			r.logger.Warn(
+				"Shutdown deadline exceeded. Router took too long to shutdown. Consider increasing the grace period",
+				zap.Duration("grace_period", r.gracePeriod),
+			)
+		}
+	}
+
+	return err
+}
+
+func (r *Router) listenAndServe() error {
+	tlsEnabled := r.tlsConfig != nil && r.tlsConfig.Enabled
+
+	addr := r.httpServer.Addr
+	if addr == "" {
+		addr = ":http"
+		if tlsEnabled {
+			addr = ":https"
+		}
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	// The connection limits are applied before the TLS handshake to reject excess connections as early as possible
+	ln = r.serverLimits.Listener(ln)
+
+	if tlsEnabled {
+		ln = r.connectionTimings.Listener(ln)
+		// Leave the cert and key empty to use the default ones
+		err = r.httpServer.ServeTLS(ln, "", "")
+	} else {
+		err = r.httpServer.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (r *Router) initModules(ctx context.Context) error {
+	for _, moduleInfo := range modules {
+		now := time.Now()
+
+		moduleInstance := moduleInfo.New()
+
+		mc := &ModuleContext{
+			Context: ctx,
+			Module:  moduleInstance,
+			Logger:  r.logger.With(zap.String("module", string(moduleInfo.ID))),
+		}
+
+		moduleConfig, ok := r.modulesConfig[string(moduleInfo.ID)]
+		if ok {
+			if err := mapstructure.Decode(moduleConfig, &moduleInstance); err != nil {
+				return fmt.Errorf("failed to decode module config from module %s: %w", moduleInfo.ID, err)
+			}
+		} else {
+			r.logger.Debug("No config found for module", zap.String("id", string(moduleInfo.ID)))
+		}
+
+		if fn, ok := moduleInstance.(Provisioner); ok {
+			if err := fn.Provision(mc); err != nil {
+				return fmt.Errorf("failed to provision module '%s': %w", moduleInfo.ID, err)
+			}
+		}
+
+		if fn, ok := moduleInstance.(RouterMiddlewareHandler); ok {
+			r.routerMiddlewares = append(r.routerMiddlewares, func(handler http.Handler) http.Handler {
+				return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+					reqContext := getRequestContext(request.Context())
+					// Ensure we work with latest request in the chain to work with the right context
+					reqContext.request = request
+					fn.Middleware(reqContext, handler)
+				})
+			})
+		}
+
+		if handler, ok := moduleInstance.(EnginePreOriginHandler); ok {
+			r.preOriginHandlers = append(r.preOriginHandlers, handler.OnOriginRequest)
+		}
+
+		if handler, ok := moduleInstance.(EnginePostOriginHandler); ok {
+			r.postOriginHandlers = append(r.postOriginHandlers, handler.OnOriginResponse)
+		}
+
+		if handler, ok := moduleInstance.(LogEntryHandler); ok {
+			r.logEntryHandlers = append(r.logEntryHandlers, handler)
+		}
+
+		if handler, ok := moduleInstance.(SurrogateKeyPurgeHandler); ok && r.surrogateKeys != nil {
+			r.surrogateKeys.addPurgeHandler(handler)
+		}
+
+		r.modules = append(r.modules, moduleInstance)
+
+		r.logger.Info("Module registered",
+			zap.String("id", string(moduleInfo.ID)),
+			zap.String("duration", time.Since(now).String()),
+		)
+	}
+
+	return nil
+}
+
+// NewServer prepares a new server instance but does not start it. The method should only be used when you want to bootstrap
+// the server manually otherwise you can use Router.Start(). You're responsible for setting health checks status to ready with Server.HealthChecks().
+// The server can be shutdown with Router.Shutdown(). Use core.WithStaticRouterConfig to pass the initial config otherwise the Router will
+// try to fetch the config from the control plane. You can swap the router config by using Router.UpdateServer().
+func (r *Router) NewServer(ctx context.Context) (Server, error) {
+	if r.shutdown {
+		return nil, fmt.Errorf("router is shutdown. Create a new instance with router.NewRouter()")
+	}
+
+	if err := r.bootstrap(ctx); err != nil {
+		return nil, fmt.Errorf("failed to bootstrap application: %w", err)
+	}
+
+	// Start the server with the static config without polling
+	if r.staticRouterConfig != nil {
+		r.logger.Info("Static router config provided. Polling is disabled. Updating router config is only possible by providing a config.")
+		return r.UpdateServer(ctx, r.staticRouterConfig)
+	}
+
+	// when no static config is provided and no poller is configured, we can't start the server
+	if r.configPoller == nil {
+		return nil, fmt.Errorf("config fetcher not provided. Please provide a static router config instead")
+	}
+
+	routerConfig, err := r.configPoller.GetRouterConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get initial router config: %w", err)
+	}
+
+	if _, err := r.UpdateServer(ctx, routerConfig); err != nil {
+		r.logger.Error("Failed to start server with initial config", zap.Error(err))
+		return nil, err
+	}
+
+	return r.activeServer, nil
+}
+
+// bootstrap initializes the Router. It is called by Start() and NewServer().
+// It should only be called once for a Router instance. Not safe for concurrent use.
+func (r *Router) bootstrap(ctx context.Context) error {
+	if r.bootstrapped {
+		return fmt.Errorf("router is already bootstrapped")
+	}
+
+	r.bootstrapped = true
+
+	cosmoCloudTracingEnabled := r.traceConfig.Enabled && rtrace.DefaultExporter(r.traceConfig) != nil
+	artInProductionEnabled := r.engineExecutionConfiguration.EnableRequestTracing && !r.developmentMode
+	needsRegistration := cosmoCloudTracingEnabled || artInProductionEnabled
+
+	if needsRegistration && r.selfRegister != nil {
+
+		r.logger.Info("Registering router with control plane because you opted in to send telemetry to Cosmo Cloud or advanced request tracing (ART) in production")
+
+		ri, registerErr := r.selfRegister.Register(ctx)
+		if registerErr != nil {
+			r.logger.Warn("Failed to register router on the control plane. If this warning persists, please contact support.")
+		} else {
+			r.registrationInfo = ri
+
+			// Only ensure sampling rate if the user exports traces to Cosmo Cloud
+			if cosmoCloudTracingEnabled {
+				if r.traceConfig.Sampler > float64(r.registrationInfo.AccountLimits.TraceSamplingRate) {
+					r.logger.Warn("Trace sampling rate is higher than account limit. Using account limit instead. Please contact support to increase your account limit.",
+						zap.Float64("limit", r.traceConfig.Sampler),
+						zap.String("account_limit", fmt.Sprintf("%.2f", r.registrationInfo.AccountLimits.TraceSamplingRate)),
+					)
+					r.traceConfig.Sampler = float64(r.registrationInfo.AccountLimits.TraceSamplingRate)
+				}
+			}
+		}
+	}
+
+	if r.traceConfig.Enabled {
+		tp, err := rtrace.NewTracerProvider(ctx, &rtrace.ProviderConfig{
+			Logger:            r.logger,
+			Config:            r.traceConfig,
+			ServiceInstanceID: r.instanceID,
+			IPAnonymization: &rtrace.IPAnonymizationConfig{
+				Enabled: r.ipAnonymization.Enabled,
+				Method:  rtrace.IPAnonymizationMethod(r.ipAnonymization.Method),
+			},
+			MemoryExporter:   r.traceConfig.TestMemoryExporter,
+			SemConvStability: r.semConvStability,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start trace agent: %w", err)
+		}
+		r.tracerProvider = tp
+	}
+
+	// Prometheus metrics rely on OTLP metrics
+	if r.metricConfig.IsEnabled() {
+		if r.metricConfig.Prometheus.Enabled {
+			mp, registry, err := rmetric.NewPrometheusMeterProvider(ctx, r.metricConfig, r.instanceID)
+			if err != nil {
+				return fmt.Errorf("failed to create Prometheus exporter: %w", err)
+			}
+			r.promMeterProvider = mp
+
+			r.prometheusServer = rmetric.NewPrometheusServer(r.logger, r.metricConfig.Prometheus.ListenAddr, r.metricConfig.Prometheus.Path, registry)
+			go func() {
+				if err := r.prometheusServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					r.logger.Error("Failed to start Prometheus server", zap.Error(err))
+				}
+			}()
+		}
+
+		if r.metricConfig.OpenTelemetry.Enabled {
+			mp, err := rmetric.NewOtlpMeterProvider(ctx, r.logger, r.metricConfig, r.instanceID)
+			if err != nil {
+				return fmt.Errorf("failed to start trace agent: %w", err)
+			}
+			r.otlpMeterProvider = mp
+		}
+
+	}
+
+	if r.profilingConfig != nil && r.profilingConfig.Enabled {
+		labels := map[string]string{
+			profiling.LabelRouterVersion:     Version,
+			profiling.LabelRouterClusterName: r.clusterName,
+			profiling.LabelRouterInstanceID:  r.instanceID,
+		}
+
+		if r.graphApiToken != "" {
+			claims, err := rjwt.ExtractFederatedGraphTokenClaims(r.graphApiToken)
+			if err != nil {
+				return err
+			}
+			labels[profiling.LabelFederatedGraphID] = claims.FederatedGraphID
+		}
+
+		p, err := profiling.NewProfiler(r.logger, r.profilingConfig, labels)
+		if err != nil {
+			return fmt.Errorf("failed to create profiler: %w", err)
+		}
+		p.Start()
+		r.profiler = p
+
+		r.logger.Info("Continuous profiling enabled",
+			zap.String("exporter", string(r.profilingConfig.Exporter)),
+			zap.String("endpoint", r.profilingConfig.Endpoint),
+			zap.Duration("interval", r.profilingConfig.Interval),
+		)
+	}
+
+	if r.memoryGuard != nil {
+		if r.metricConfig.IsEnabled() {
+			if err := r.memoryGuard.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register memory metrics: %w", err)
+			}
+			if err := r.memoryGuard.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register memory metrics: %w", err)
+			}
+		}
+
+		r.memoryGuard.Start()
+
+		r.logger.Info("Memory soft limit enabled",
+			zap.Uint64("threshold_bytes", r.memoryGuard.threshold),
+			zap.Bool("shrink_caches", r.memoryGuard.shrinkCaches),
+			zap.Bool("shed_load", r.memoryGuard.shedLoad),
+		)
+	}
+
+	if r.subscriptionReaper != nil {
+		r.subscriptionReaper.Start()
+
+		r.logger.Info("Subscription reaping enabled",
+			zap.Duration("idle_timeout", r.subscriptionReapingCfg.IdleTimeout),
+		)
+	}
+
+	if r.anomalyDetector != nil {
+		r.anomalyDetector.Start()
+
+		r.logger.Info("Anomaly detection enabled",
+			zap.Duration("interval", r.anomalyDetectionConfig.Interval),
+			zap.Float64("threshold", r.anomalyDetectionConfig.Threshold),
+			zap.Bool("webhook", r.anomalyDetectionConfig.Webhook.URL != ""),
+		)
+	}
+
+	if r.logRetentionConfig != nil && r.logRetentionConfig.Enabled {
+		janitor, err := logging.NewRetentionJanitor(&logging.RetentionJanitorOptions{
+			Logger:        r.logger,
+			Patterns:      r.logRetentionConfig.Paths,
+			MaxAge:        r.logRetentionConfig.MaxAge,
+			CompressAfter: r.logRetentionConfig.CompressAfter,
+			Interval:      r.logRetentionConfig.Interval,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create log retention janitor: %w", err)
+		}
+		r.logRetentionJanitor = janitor
+		r.logRetentionJanitor.Start()
+
+		r.logger.Info("Log retention enabled",
+			zap.Strings("paths", r.logRetentionConfig.Paths),
+			zap.Duration("max_age", r.logRetentionConfig.MaxAge),
+			zap.Duration("compress_after", r.logRetentionConfig.CompressAfter),
+		)
+	}
+
+	r.persistedOpKillSwitch.Start()
+	if hashes := r.persistedOpKillSwitch.Hashes(); len(hashes) > 0 {
+		r.logger.Warn("Persisted operations are blocked by the kill switch", zap.Strings("hashes", hashes))
+	}
+
+	if r.tenantOverrides != nil {
+		r.tenantOverrides.Start()
+		r.logger.Info("Tenant overrides enabled", zap.Int("tenants", r.tenantOverrides.Tenants()))
+	}
+
+	if r.fieldBlocker != nil {
+		r.fieldBlocker.Start()
+		if fields := r.fieldBlocker.Fields(); len(fields) > 0 {
+			r.logger.Warn("Fields of the schema are blocked", zap.Any("fields", fields))
+		}
+	}
+
+	if r.persistedOpManifest != nil {
+		r.persistedOpManifest.Start()
+		r.logger.Info("Recording the operations in the persisted operation manifest",
+			zap.String("path", r.persistedOpManifestCfg.Path),
+			zap.String("client_name", r.persistedOpManifestCfg.ClientName),
+		)
+	}
+
+	if r.accessLogsConfig != nil && r.accessLogsConfig.Kafka.Enabled {
+		kafkaCfg := r.accessLogsConfig.Kafka
+
+		kafkaOpts, err := buildKafkaOptions(config.KafkaEventSource{
+			Brokers:        kafkaCfg.Brokers,
+			Authentication: kafkaCfg.Authentication,
+			TLS:            kafkaCfg.TLS,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build access log kafka options: %w", err)
+		}
+
+		r.accessLogKafkaSink, err = accesslog.NewKafkaSink(ctx, &accesslog.KafkaSinkOptions{
+			Logger:       r.logger,
+			Topic:        kafkaCfg.Topic,
+			Format:       accesslog.Format(kafkaCfg.Format),
+			KafkaOptions: kafkaOpts,
+			SchemaRegistry: accesslog.SchemaRegistryOptions{
+				URL:      kafkaCfg.SchemaRegistry.URL,
+				Subject:  kafkaCfg.SchemaRegistry.Subject,
+				Username: kafkaCfg.SchemaRegistry.Username,
+				Password: kafkaCfg.SchemaRegistry.Password,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create access log kafka sink: %w", err)
+		}
+
+		r.logger.Info("Publishing access logs to Kafka",
+			zap.String("topic", kafkaCfg.Topic),
+			zap.String("format", kafkaCfg.Format),
+			zap.Int("schema_id", r.accessLogKafkaSink.SchemaID()),
+		)
+	}
+
+	if r.metricConfig.IsEnabled() {
+		if err := r.serverLimits.RegisterMetrics(r.promMeterProvider); err != nil {
+			return fmt.Errorf("failed to register server metrics: %w", err)
+		}
+		if err := r.serverLimits.RegisterMetrics(r.otlpMeterProvider); err != nil {
+			return fmt.Errorf("failed to register server metrics: %w", err)
+		}
+		if r.subscriptionLimits != nil {
+			if err := r.subscriptionLimits.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register subscription limit metrics: %w", err)
+			}
+			if err := r.subscriptionLimits.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register subscription limit metrics: %w", err)
+			}
+		}
+		if r.subscriptionBackpressure != nil {
+			if err := r.subscriptionBackpressure.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register subscription backpressure metrics: %w", err)
+			}
+			if err := r.subscriptionBackpressure.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register subscription backpressure metrics: %w", err)
+			}
+		}
+		if r.webSocketTransport != nil {
+			if err := r.webSocketTransport.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register websocket transport metrics: %w", err)
+			}
+			if err := r.webSocketTransport.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register websocket transport metrics: %w", err)
+			}
+		}
+		if r.connectionTimings != nil {
+			if err := r.connectionTimings.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register connection timing metrics: %w", err)
+			}
+			if err := r.connectionTimings.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register connection timing metrics: %w", err)
+			}
+		}
+		if err := r.drains.RegisterMetrics(r.promMeterProvider); err != nil {
+			return fmt.Errorf("failed to register drain metrics: %w", err)
+		}
+		if err := r.drains.RegisterMetrics(r.otlpMeterProvider); err != nil {
+			return fmt.Errorf("failed to register drain metrics: %w", err)
+		}
+		if err := r.accessController.RegisterMetrics(r.promMeterProvider); err != nil {
+			return fmt.Errorf("failed to register authentication metrics: %w", err)
+		}
+		if err := r.accessController.RegisterMetrics(r.otlpMeterProvider); err != nil {
+			return fmt.Errorf("failed to register authentication metrics: %w", err)
+		}
+		if r.configDrift != nil {
+			if err := r.configDrift.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register config drift metrics: %w", err)
+			}
+			if err := r.configDrift.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register config drift metrics: %w", err)
+			}
+		}
+		if r.configFallback != nil {
+			if err := r.configFallback.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register config fallback metrics: %w", err)
+			}
+			if err := r.configFallback.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register config fallback metrics: %w", err)
+			}
+		}
+		if len(r.logDropCounters) > 0 {
+			if err := registerLogDropMetrics(r.promMeterProvider, r.logDropCounters); err != nil {
+				return fmt.Errorf("failed to register log drop metrics: %w", err)
+			}
+			if err := registerLogDropMetrics(r.otlpMeterProvider, r.logDropCounters); err != nil {
+				return fmt.Errorf("failed to register log drop metrics: %w", err)
+			}
+		}
+		if r.logMetrics != nil {
+			if err := r.logMetrics.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register log metrics: %w", err)
+			}
+			if err := r.logMetrics.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register log metrics: %w", err)
+			}
+		}
+		if r.deprecations != nil {
+			if err := r.deprecations.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register deprecation metrics: %w", err)
+			}
+			if err := r.deprecations.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register deprecation metrics: %w", err)
+			}
+		}
+		if r.nonGraphQLRequests != nil {
+			if err := r.nonGraphQLRequests.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register non-GraphQL request metrics: %w", err)
+			}
+			if err := r.nonGraphQLRequests.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register non-GraphQL request metrics: %w", err)
+			}
+		}
+		if r.clientProtocols != nil {
+			if err := r.clientProtocols.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register client protocol metrics: %w", err)
+			}
+			if err := r.clientProtocols.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register client protocol metrics: %w", err)
+			}
+		}
+		if r.persistedOpUsage != nil {
+			if err := r.persistedOpUsage.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register persisted operation usage metrics: %w", err)
+			}
+			if err := r.persistedOpUsage.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register persisted operation usage metrics: %w", err)
+			}
+		}
+		if r.sloTracker != nil {
+			if err := r.sloTracker.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register slo metrics: %w", err)
+			}
+			if err := r.sloTracker.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register slo metrics: %w", err)
+			}
+		}
+		if r.syntheticHealth != nil {
+			if err := r.syntheticHealth.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register synthetic health metrics: %w", err)
+			}
+			if err := r.syntheticHealth.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register synthetic health metrics: %w", err)
+			}
+		}
+		if r.clockSkew != nil {
+			if err := r.clockSkew.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register clock skew metrics: %w", err)
+			}
+			if err := r.clockSkew.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register clock skew metrics: %w", err)
+			}
+		}
+		if r.certExpiry != nil {
+			if err := r.certExpiry.RegisterMetrics(r.promMeterProvider); err != nil {
+				return fmt.Errorf("failed to register certificate expiry metrics: %w", err)
+			}
+			if err := r.certExpiry.RegisterMetrics(r.otlpMeterProvider); err != nil {
+				return fmt.Errorf("failed to register certificate expiry metrics: %w", err)
+			}
+		}
+	}
+
+	if r.adminConfig.Enabled {
+		adminServer, err := r.newAdminServer(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create admin server: %w", err)
+		}
+		r.adminServer = adminServer
+		go func() {
+			var err error
+			if r.adminServer.TLSConfig != nil {
+				err = r.listenAndServeAdminTLS()
+			} else {
+				err = r.adminServer.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				r.logger.Error("Failed to start admin server", zap.Error(err))
+			}
+		}()
+
+		if r.clusterPeers != nil {
+			r.clusterPeers.Start()
+		}
+		if r.replicatedRateLimits != nil {
+			r.replicatedRateLimits.Start()
+		}
+	}
+
+	if r.clockSkew != nil {
+		r.clockSkew.Start()
+	}
+	// Started after the admin server, so that the first check includes its certificate
+	if r.certExpiry != nil {
+		r.certExpiry.Start()
+	}
+
+	r.gqlMetricsExporter = graphqlmetrics.NewNoopExporter()
 
 	if r.graphqlMetricsConfig.Enabled {
 		client := graphqlmetricsv1connect.NewGraphQLMetricsServiceClient(
@@ -730,645 +2063,1533 @@ func (r *Router) bootstrap(ctx context.Context) error {
 			graphqlmetrics.NewDefaultExporterSettings(),
 		)
 		if err != nil {
-			return fmt.Errorf("failed to validate graphql metrics exporter: %w", err)
+			return fmt.Errorf("failed to validate graphql metrics exporter: %w", err)
+		}
+		r.gqlMetricsExporter = ge
+
+		r.logger.Info("GraphQL schema coverage metrics enabled")
+	}
+
+	if r.Config.rateLimit != nil && r.Config.rateLimit.Enabled {
+		switch r.Config.rateLimit.KeyBy {
+		case "", RateLimitKeyByClientName, RateLimitKeyByClaim:
+		case RateLimitKeyByTag:
+			if !r.requestTagger.hasTag(r.Config.rateLimit.KeyTag) {
+				return fmt.Errorf("the rate limit key_tag '%s' is not a request tag", r.Config.rateLimit.KeyTag)
+			}
+		default:
+			return fmt.Errorf("unknown rate limit key_by '%s'", r.Config.rateLimit.KeyBy)
+		}
+
+		if r.Config.rateLimit.Replication.Enabled {
+			if r.clusterPeers == nil {
+				return errors.New("the replication of the rate limits requires the cluster peers")
+			}
+			if r.Config.rateLimit.SimpleStrategy.Algorithm == RateLimitAlgorithmSlidingWindow {
+				return errors.New("the sliding window algorithm of the rate limits requires the Redis storage")
+			}
+			if len(r.Config.rateLimit.UsageQuotas) > 0 {
+				return errors.New("the usage quotas of the rate limits require the Redis storage")
+			}
+			r.replicatedRateLimits = NewReplicatedRateLimits(&ReplicatedRateLimitsOptions{
+				Logger:       r.logger,
+				Peers:        r.clusterPeers,
+				SyncInterval: r.Config.rateLimit.Replication.SyncInterval,
+				Token:        r.adminConfig.Token,
+				Limit: redis_rate.Limit{
+					Rate:   r.Config.rateLimit.SimpleStrategy.Rate,
+					Burst:  r.Config.rateLimit.SimpleStrategy.Burst,
+					Period: r.Config.rateLimit.SimpleStrategy.Period,
+				},
+			})
+		} else {
+			options, err := redis.ParseURL(r.Config.rateLimit.Storage.Url)
+			if err != nil {
+				return fmt.Errorf("failed to parse the redis connection url: %w", err)
+			}
+			r.redisClient = redis.NewClient(options)
+
+			if len(r.Config.rateLimit.UsageQuotas) > 0 {
+				r.usageQuotas, err = NewUsageQuotas(r.redisClient, r.Config.rateLimit.UsageQuotas)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if r.engineExecutionConfiguration.Debug.ReportWebSocketConnections {
+		r.WebsocketStats = NewWebSocketStats(ctx, r.logger)
+	}
+
+	if r.engineExecutionConfiguration.Debug.ReportMemoryUsage {
+		debug.ReportMemoryUsage(ctx, r.logger)
+	}
+
+	// Modules are only initialized once and not on every config change
+	if err := r.initModules(ctx); err != nil {
+		return fmt.Errorf("failed to init user modules: %w", err)
+	}
+
+	return nil
+}
+
+// Start starts the server. It does not block. The server can be shutdown with Router.Shutdown().
+// Not safe for concurrent use.
+func (r *Router) Start(ctx context.Context) error {
+	if r.shutdown {
+		return fmt.Errorf("router is shutdown. Create a new instance with router.NewRouter()")
+	}
+
+	if err := r.bootstrap(ctx); err != nil {
+		return fmt.Errorf("failed to bootstrap application: %w", err)
+	}
+
+	// Start the server with the static config without polling
+	if r.staticRouterConfig != nil {
+		r.logger.Info("Static router config provided. Polling is disabled. Updating router config is only possible by providing a config.")
+		return r.updateServerAndStart(ctx, r.staticRouterConfig)
+	}
+
+	// when no static config is provided and no poller is configured, we can't start the server
+	if r.configPoller == nil {
+		return fmt.Errorf("config fetcher not provided. Please provide a static router config instead")
+	}
+
+	routerConfig, err := r.configPoller.GetRouterConfig(ctx)
+	fromFallback := false
+	if err != nil {
+		r.notifyLifecycle(LifecycleEventConfigFetchFailed, "Failed to fetch the initial router config", "", err)
+		if r.configFallback == nil {
+			return fmt.Errorf("failed to get initial router config: %w", err)
+		}
+
+		fallbackConfig, fallbackErr := r.configFallback.load()
+		if fallbackErr != nil {
+			r.logger.Error("Failed to load the fallback router config", zap.String("path", r.configFallbackPath), zap.Error(fallbackErr))
+			return fmt.Errorf("failed to get initial router config: %w", err)
+		}
+
+		r.logger.Warn("Failed to fetch the initial router config. Starting with the last known good router config until a new config can be fetched",
+			zap.String("path", r.configFallbackPath),
+			zap.String("fallback_version", fallbackConfig.GetVersion()),
+			zap.Error(err),
+		)
+		routerConfig = fallbackConfig
+		fromFallback = true
+	}
+
+	if err := r.updateServerAndStart(ctx, routerConfig); err != nil {
+		r.logger.Error("Failed to start server with initial config", zap.Error(err))
+		return err
+	}
+	r.configDrift.applied(routerConfig.GetVersion())
+	if r.configFallback != nil && !fromFallback {
+		r.configFallback.applied(routerConfig)
+	}
+
+	r.logger.Info("Polling for router config updates in the background")
+
+	if r.lifecycleNotifier != nil || len(r.lifecycleHooks) > 0 {
+		r.configPoller.OnFetchError(func(err error) {
+			r.notifyLifecycle(LifecycleEventConfigFetchFailed, "Failed to fetch a router config update", "", err)
+		})
+	}
+
+	r.configPoller.Subscribe(ctx, func(newConfig *nodev1.RouterConfig, oldVersion string) error {
+		r.logger.Info("Router execution config has changed, upgrading server",
+			zap.String("old_version", oldVersion),
+			zap.String("new_version", newConfig.GetVersion()),
+		)
+		if err := r.updateServerAndStart(ctx, newConfig); err != nil {
+			r.logger.Error("Failed to start server with new config. Trying again on the next update cycle.", zap.Error(err))
+			// The poller offers the same config again on the next update cycle, until it is applied
+			r.configDrift.failed(newConfig.GetVersion())
+			return err
+		}
+		r.configDrift.applied(newConfig.GetVersion())
+		if r.configFallback != nil {
+			r.configFallback.applied(newConfig)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+// newServer creates a new server instance.
+// All stateful data is copied from the Router over to the new server instance. Not safe for concurrent use.
+func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfig) (*server, error) {
+	s := &server{
+		Config:                  r.Config,
+		websocketStats:          r.WebsocketStats,
+		metricStore:             rmetric.NewNoopMetrics(),
+		executionTransport:      newHTTPTransport(r.subgraphTransportOptions),
+		baseRouterConfigVersion: routerConfig.GetVersion(),
+		pubSubProviders: &EnginePubSubProviders{
+			nats:  map[string]pubsub_datasource.NatsPubSub{},
+			kafka: map[string]pubsub_datasource.KafkaPubSub{},
+		},
+	}
+
+	baseOtelAttributes := []attribute.KeyValue{
+		otel.WgRouterVersion.String(Version),
+		otel.WgRouterClusterName.String(r.clusterName),
+	}
+
+	if s.graphApiToken != "" {
+		claims, err := rjwt.ExtractFederatedGraphTokenClaims(s.graphApiToken)
+		if err != nil {
+			return nil, err
+		}
+		baseOtelAttributes = append(baseOtelAttributes, otel.WgFederatedGraphID.String(claims.FederatedGraphID))
+	}
+
+	s.baseOtelAttributes = baseOtelAttributes
+
+	if s.metricConfig.OpenTelemetry.RouterRuntime {
+		// Create runtime metrics exported to OTEL
+		s.runtimeMetrics = rmetric.NewRuntimeMetrics(
+			s.logger,
+			r.otlpMeterProvider,
+			// We track runtime metrics with base router config version
+			// even when we have multiple feature flags
+			append([]attribute.KeyValue{
+				otel.WgRouterConfigVersion.String(s.baseRouterConfigVersion),
+			}, s.baseOtelAttributes...),
+			s.processStartTime,
+		)
+
+		// Start runtime metrics
+		if err := s.runtimeMetrics.Start(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Prometheus metricStore rely on OTLP metricStore
+	if s.metricConfig.IsEnabled() {
+		m, err := rmetric.NewStore(
+			rmetric.WithPromMeterProvider(s.promMeterProvider),
+			rmetric.WithOtlpMeterProvider(s.otlpMeterProvider),
+			rmetric.WithLogger(s.logger),
+			rmetric.WithProcessStartTime(s.processStartTime),
+			rmetric.WithSemConvStability(s.semConvStability),
+			// Don't pass the router config version or feature flags here
+			// We scope the metrics to the feature flags and config version in the handler
+			rmetric.WithAttributes(baseOtelAttributes...),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metric handler: %w", err)
+		}
+
+		s.metricStore = m
+	}
+
+	if s.registrationInfo != nil {
+		publicKey, err := jwt.ParseECPublicKeyFromPEM([]byte(s.registrationInfo.GetGraphPublicKey()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse router public key: %w", err)
+		}
+		s.publicKey = publicKey
+	}
+
+	recoveryOpts := &logging.RecoveryOptions{Logger: s.logger}
+	if s.panicRecoveryConfig != nil {
+		recoveryOpts.CrashDumpDir = s.panicRecoveryConfig.CrashDumpDir
+		recoveryOpts.Exit = s.panicRecoveryConfig.Exit
+	}
+	recoveryHandler := logging.NewRecoveryHandler(recoveryOpts)
+
+	if s.healthChecks == nil {
+		s.healthChecks = health.New(&health.Options{
+			Logger: s.logger,
+		})
+	}
+
+	if s.playground {
+		playgroundUrl, err := url.JoinPath(s.baseURL, s.playgroundPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to join playground url: %w", err)
+		}
+		s.logger.Info("Serving GraphQL playground", zap.String("url", playgroundUrl))
+		s.playgroundHandler = graphiql.NewPlayground(&graphiql.PlaygroundOptions{
+			Log:        s.logger,
+			Html:       graphiql.PlaygroundHTML(),
+			GraphqlURL: s.graphqlWebURL,
+		})
+	}
+
+	graphqlEndpointURL, err := url.JoinPath(r.baseURL, r.graphqlPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join graphql endpoint url: %w", err)
+	}
+
+	httpRouter := chi.NewRouter()
+
+	/**
+	* Middlewares
+	 */
+
+	httpRouter.Use(recoveryHandler.Handler)
+	httpRouter.Use(rmiddleware.RequestSize(int64(s.routerTrafficConfig.MaxRequestBodyBytes)))
+	httpRouter.Use(middleware.RequestID)
+	httpRouter.Use(middleware.RealIP)
+	// The requests that don't reach the GraphQL handler are logged before CORS answers the preflight requests
+	if r.nonGraphQLRequests != nil {
+		httpRouter.Use(r.nonGraphQLRequests.Middleware)
+	}
+	if r.problemDetails != nil && r.problemDetails.Enabled {
+		httpRouter.Use(problemDetailsMiddleware)
+	}
+	// The custom headers are added before CORS, so that they are also set on the responses to preflight requests
+	if headers := newCustomResponseHeaders(r.customResponseHeaders, ResponseHeadersListenerGraphQL, r.activeConfigVersion); headers != nil {
+		httpRouter.Use(headers.Handler)
+	}
+	httpRouter.Use(cors.New(*s.corsOptions))
+
+	baseMux, err := s.buildMux(ctx, "", s.baseRouterConfigVersion, routerConfig.GetEngineConfig(), routerConfig.GetSubgraphs())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build base mux: %w", err)
+	}
+
+	featureFlagConfigMap := routerConfig.FeatureFlagConfigs.GetConfigByFeatureFlagName()
+	if len(featureFlagConfigMap) > 0 {
+		s.logger.Info("Feature flags enabled", zap.Strings("flags", maps.Keys(featureFlagConfigMap)))
+	}
+
+	multiGraphHandler, err := s.buildMultiGraphHandler(ctx, baseMux, featureFlagConfigMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build feature flag handler: %w", err)
+	}
+
+	// The subscriptions of the webhooks are started when the server becomes active
+	s.graphqlHandler = multiGraphHandler
+
+	brCompressor := middleware.NewCompressor(5, CustomCompressibleContentTypes...)
+	brCompressor.SetEncoder("br", func(w io.Writer, level int) io.Writer {
+		return br.NewWriterLevel(w, level)
+	})
+
+	/**
+	* A group where we can selectively apply middlewares to the graphql endpoint
+	 */
+	httpRouter.Group(func(cr chi.Router) {
+
+		// Reject requests before any work is done while the router is under memory pressure
+		if r.memoryGuard != nil {
+			cr.Use(r.memoryGuard.Middleware)
+		}
+
+		if r.requestSignatureVerifier != nil {
+			cr.Use(r.requestSignatureVerifier.Middleware)
+		}
+
+		if r.botDetector != nil {
+			cr.Use(r.botDetector.Middleware)
+		}
+
+		// We are applying it conditionally because brotli compressing the 3MB playground is very slow
+		cr.Use(middleware.Compress(5, CustomCompressibleContentTypes...))
+		cr.Use(brCompressor.Handler)
+
+		// Mount the feature flag handler. It calls the base mux if no feature flag is set.
+		cr.Mount(r.graphqlPath, multiGraphHandler)
+
+		if r.restBridge != nil {
+			r.restBridge.Mount(cr, multiGraphHandler)
+		}
+		if r.webSocketConfiguration != nil && r.webSocketConfiguration.Enabled && r.webSocketConfiguration.AbsintheProtocol.Enabled {
+			// Mount the Absinthe protocol handler for WebSockets
+			httpRouter.Mount(r.webSocketConfiguration.AbsintheProtocol.HandlerPath, multiGraphHandler)
+		}
+	})
+
+	/**
+	* Routes
+	 */
+
+	// We mount the playground once here when we don't have a conflict with the websocket handler
+	// If we have a conflict, we mount the playground during building the individual muxes
+	if s.playgroundHandler != nil && s.graphqlPath != s.playgroundPath {
+		httpRouter.Get(r.playgroundPath, s.playgroundHandler(nil).ServeHTTP)
+	}
+
+	httpRouter.Get(s.healthCheckPath, s.healthChecks.Liveness())
+	httpRouter.Get(s.livenessCheckPath, s.healthChecks.Liveness())
+	httpRouter.Get(s.readinessCheckPath, s.maintenanceMode.Readiness(s.healthChecks.Readiness()))
+
+	if r.versionEndpointConfig != nil && r.versionEndpointConfig.Enabled {
+		httpRouter.Get(r.versionEndpointConfig.Path, r.versionHandler(maps.Keys(featureFlagConfigMap)))
+	}
+
+	if s.rateLimitingEnabled() && s.rateLimit.QuotaEndpoint.Enabled {
+		httpRouter.Get(s.rateLimit.QuotaEndpoint.Path, r.quotaHandler(NewCosmoRateLimiter(&CosmoRateLimiterOptions{
+			RedisClient:   s.redisClient,
+			Replicated:    s.replicatedRateLimits,
+			SlidingWindow: s.rateLimit.SimpleStrategy.Algorithm == RateLimitAlgorithmSlidingWindow,
+			Debug:         s.rateLimit.Debug,
+		})))
+	}
+
+	/**
+	* Server logging after features has been initialized / disabled
+	 */
+
+	r.logger.Info("GraphQL endpoint",
+		zap.String("method", http.MethodPost),
+		zap.String("url", graphqlEndpointURL),
+	)
+
+	if s.localhostFallbackInsideDocker && docker.Inside() {
+		s.logger.Info("localhost fallback enabled, connections that fail to connect to localhost will be retried using host.docker.internal")
+	}
+
+	if s.developmentMode && s.engineExecutionConfiguration.EnableRequestTracing && s.graphApiToken == "" {
+		s.logger.Warn("Advanced Request Tracing (ART) is enabled in development mode but requires a graph token to work in production. For more information see https://cosmo-docs.wundergraph.com/router/advanced-request-tracing-art")
+	}
+
+	if s.rateLimitingEnabled() {
+		s.logger.Info("Rate limiting enabled",
+			zap.Int("rate", s.rateLimit.SimpleStrategy.Rate),
+			zap.Int("burst", s.rateLimit.SimpleStrategy.Burst),
+			zap.Duration("duration", s.Config.rateLimit.SimpleStrategy.Period),
+			zap.Bool("rejectExceeding", s.Config.rateLimit.SimpleStrategy.RejectExceedingRequests),
+			zap.String("keyBy", s.Config.rateLimit.KeyBy),
+			zap.Bool("replicated", s.replicatedRateLimits != nil),
+		)
+	}
+
+	s.httpServer = &http.Server{
+		Addr: r.listenAddr,
+		// https://ieftimov.com/posts/make-resilient-golang-net-http-servers-using-timeouts-deadlines-context-cancellation/
+		ReadTimeout:       r.serverConfig.ReadTimeout,
+		WriteTimeout:      r.serverConfig.WriteTimeout,
+		ReadHeaderTimeout: r.serverConfig.ReadHeaderTimeout,
+		IdleTimeout:       r.serverConfig.IdleTimeout,
+		MaxHeaderBytes:    int(r.serverConfig.MaxHeaderBytes),
+		Handler:           r.serverLimits.Middleware(httpRouter),
+		ErrorLog:          zap.NewStdLog(r.logger),
+		TLSConfig:         r.tlsServerConfig,
+	}
+
+	if err := r.configureHTTP2(s.httpServer); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// configureHTTP2 applies the HTTP/2 limits to the server. HTTP/2 is only negotiated over TLS.
+func (r *Router) configureHTTP2(srv *http.Server) error {
+	if r.tlsConfig == nil || !r.tlsConfig.Enabled {
+		return nil
+	}
+
+	err := http2.ConfigureServer(srv, &http2.Server{
+		MaxConcurrentStreams: r.serverConfig.MaxConcurrentStreams,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure http2 server: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown gracefully shuts down the router. It blocks until the server is shutdown.
+// If the router is already shutdown, the method returns immediately without error. Not safe for concurrent use.
+func (r *Router) Shutdown(ctx context.Context) (err error) {
+
+	if r.shutdown {
+		return nil
+	}
+
+	r.shutdown = true
+	r.activeRouterConfig.Store(nil)
+	r.activeHandler.Store(nil)
+
+	r.notifyLifecycle(LifecycleEventShutdown, "Router shutting down", "", nil)
+
+	if r.configPoller != nil {
+		if subErr := r.configPoller.Stop(ctx); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to stop config poller: %w", subErr))
+		}
+	}
+
+	if r.syntheticHealth != nil {
+		if subErr := r.syntheticHealth.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to shutdown synthetic health operation: %w", subErr))
+		}
+	}
+
+	if r.httpServer != nil {
+		drain := DrainReport{
+			Kind:        DrainKindShutdown,
+			Connections: r.drains.openConnections(),
+			Requests:    r.swapHandler.inFlightRequests(),
+		}
+		// The subscriptions run on hijacked connections, which the HTTP server doesn't wait for
+		if report := r.WebsocketStats.GetReport(); report != nil {
+			drain.CanceledSubscriptions = int64(report.Subscriptions)
+		}
+		drainStart := time.Now()
+
+		subErr := r.httpServer.Shutdown(ctx)
+		drain.Duration = time.Since(drainStart)
+		drain.Completed = subErr == nil
+		r.drains.report(drain)
+
+		if subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to shutdown http server: %w", subErr))
+		}
+	}
+
+	if r.activeServer != nil {
+		if subErr := r.activeServer.Shutdown(ctx); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to shutdown primary server: %w", subErr))
+		}
+	}
+
+	// No access log entries are written after the servers were shut down
+	if r.logShutdownFlush != nil {
+		r.flushAccessLogs()
+	}
+
+	var wg sync.WaitGroup
+
+	if r.prometheusServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if subErr := r.prometheusServer.Close(); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to shutdown prometheus server: %w", subErr))
+			}
+		}()
+	}
+
+	if r.profiler != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if subErr := r.profiler.Shutdown(ctx); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to shutdown profiler: %w", subErr))
+			}
+		}()
+	}
+
+	if r.memoryGuard != nil {
+		if subErr := r.memoryGuard.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to shutdown memory guard: %w", subErr))
+		}
+	}
+
+	if r.serverLimits != nil {
+		if subErr := r.serverLimits.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister server metrics: %w", subErr))
+		}
+	}
+
+	if r.subscriptionReaper != nil {
+		r.subscriptionReaper.Shutdown()
+	}
+
+	if r.subscriptionLimits != nil {
+		if subErr := r.subscriptionLimits.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister subscription limit metrics: %w", subErr))
+		}
+	}
+
+	if r.subscriptionBackpressure != nil {
+		if subErr := r.subscriptionBackpressure.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister subscription backpressure metrics: %w", subErr))
+		}
+	}
+
+	if r.webSocketTransport != nil {
+		if subErr := r.webSocketTransport.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister websocket transport metrics: %w", subErr))
+		}
+	}
+
+	if r.auditLogger != nil {
+		if subErr := r.auditLogger.Close(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close the audit log: %w", subErr))
+		}
+	}
+
+	if r.executionAudit != nil {
+		if subErr := r.executionAudit.Shutdown(ctx); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close the execution audit: %w", subErr))
+		}
+	}
+
+	if r.logMetrics != nil {
+		if subErr := r.logMetrics.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister log metrics: %w", subErr))
+		}
+	}
+
+	if r.logRetentionJanitor != nil {
+		r.logRetentionJanitor.Shutdown()
+	}
+
+	if r.persistedOpKillSwitch != nil {
+		r.persistedOpKillSwitch.Shutdown()
+	}
+
+	if r.fieldBlocker != nil {
+		r.fieldBlocker.Shutdown()
+	}
+
+	if r.tenantOverrides != nil {
+		r.tenantOverrides.Shutdown()
+	}
+
+	if r.clockSkew != nil {
+		r.clockSkew.Shutdown()
+	}
+
+	if r.subgraphTunnels != nil {
+		if subErr := r.subgraphTunnels.Close(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close the subgraph tunnels: %w", subErr))
+		}
+	}
+
+	if r.certExpiry != nil {
+		r.certExpiry.Shutdown()
+	}
+
+	if r.anomalyDetector != nil {
+		r.anomalyDetector.Shutdown()
+	}
+
+	if r.replicatedRateLimits != nil {
+		r.replicatedRateLimits.Shutdown()
+	}
+
+	if r.clusterPeers != nil {
+		r.clusterPeers.Shutdown()
+	}
+
+	if r.deprecations != nil {
+		if subErr := r.deprecations.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister deprecation metrics: %w", subErr))
+		}
+	}
+
+	if r.nonGraphQLRequests != nil {
+		if subErr := r.nonGraphQLRequests.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister non-GraphQL request metrics: %w", subErr))
+		}
+	}
+
+	if r.clientProtocols != nil {
+		if subErr := r.clientProtocols.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister client protocol metrics: %w", subErr))
 		}
-		r.gqlMetricsExporter = ge
+	}
 
-		r.logger.Info("GraphQL schema coverage metrics enabled")
+	if r.persistedOpUsage != nil {
+		if subErr := r.persistedOpUsage.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister persisted operation usage metrics: %w", subErr))
+		}
 	}
 
-	if r.Config.rateLimit != nil && r.Config.rateLimit.Enabled {
-		options, err := redis.ParseURL(r.Config.rateLimit.Storage.Url)
-		if err != nil {
-			return fmt.Errorf("failed to parse the redis connection url: %w", err)
+	if r.sloTracker != nil {
+		if subErr := r.sloTracker.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to unregister slo metrics: %w", subErr))
 		}
+	}
 
-		r.redisClient = redis.NewClient(options)
+	if r.persistedOpManifest != nil {
+		if subErr := r.persistedOpManifest.Shutdown(); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to write persisted operation manifest: %w", subErr))
+		}
 	}
 
-	if r.engineExecutionConfiguration.Debug.ReportWebSocketConnections {
-		r.WebsocketStats = NewWebSocketStats(ctx, r.logger)
+	if r.adminServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if subErr := r.adminServer.Close(); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to shutdown admin server: %w", subErr))
+			}
+		}()
 	}
 
-	if r.engineExecutionConfiguration.Debug.ReportMemoryUsage {
-		debug.ReportMemoryUsage(ctx, r.logger)
+	if r.tracerProvider != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if subErr := r.tracerProvider.Shutdown(ctx); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to shutdown tracer: %w", subErr))
+			}
+		}()
 	}
 
-	// Modules are only initialized once and not on every config change
-	if err := r.initModules(ctx); err != nil {
-		return fmt.Errorf("failed to init user modules: %w", err)
+	if r.gqlMetricsExporter != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if subErr := r.gqlMetricsExporter.Shutdown(ctx); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to shutdown graphql metrics exporter: %w", subErr))
+			}
+		}()
 	}
 
-	return nil
+	if r.accessLogKafkaSink != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if subErr := r.accessLogKafkaSink.Close(ctx); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to flush access logs to kafka: %w", subErr))
+			}
+		}()
+	}
+
+	if r.promMeterProvider != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if subErr := r.promMeterProvider.Shutdown(ctx); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to shutdown prometheus meter provider: %w", subErr))
+			}
+		}()
+	}
+
+	if r.otlpMeterProvider != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if subErr := r.otlpMeterProvider.Shutdown(ctx); subErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to shutdown OTLP meter provider: %w", subErr))
+			}
+		}()
+	}
+
+	if r.redisClient != nil {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if subErr := r.redisClient.FlushAll(ctx); subErr.Err() != nil {
+				err = errors.Join(err, fmt.Errorf("failed to flush redis client: %w", subErr.Err()))
+			}
+			if closeErr := r.redisClient.Close(); closeErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to close redis client: %w", closeErr))
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for _, module := range r.modules {
+			if cleaner, ok := module.(Cleaner); ok {
+				if subErr := cleaner.Cleanup(); subErr != nil {
+					err = errors.Join(err, fmt.Errorf("failed to clean module %s: %w", module.Module().ID, subErr))
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if r.lifecycleNotifier != nil {
+		if subErr := r.lifecycleNotifier.Wait(ctx); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to send lifecycle webhooks: %w", subErr))
+		}
+	}
+
+	if r.surrogateKeys != nil {
+		if subErr := r.surrogateKeys.Wait(ctx); subErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to purge surrogate keys: %w", subErr))
+		}
+	}
+
+	return err
 }
 
-// Start starts the server. It does not block. The server can be shutdown with Router.Shutdown().
-// Not safe for concurrent use.
-func (r *Router) Start(ctx context.Context) error {
-	if r.shutdown {
-		return fmt.Errorf("router is shutdown. Create a new instance with router.NewRouter()")
+func WithListenerAddr(addr string) Option {
+	return func(r *Router) {
+		r.listenAddr = addr
 	}
+}
 
-	if err := r.bootstrap(ctx); err != nil {
-		return fmt.Errorf("failed to bootstrap application: %w", err)
+func WithLogger(logger *zap.Logger) Option {
+	return func(r *Router) {
+		r.logger = logger
 	}
+}
 
-	// Start the server with the static config without polling
-	if r.staticRouterConfig != nil {
-		r.logger.Info("Static router config provided. Polling is disabled. Updating router config is only possible by providing a config.")
-		return r.updateServerAndStart(ctx, r.staticRouterConfig)
+func WithPlayground(enable bool) Option {
+	return func(r *Router) {
+		r.playground = enable
 	}
+}
 
-	// when no static config is provided and no poller is configured, we can't start the server
-	if r.configPoller == nil {
-		return fmt.Errorf("config fetcher not provided. Please provide a static router config instead")
+func WithIntrospection(enable bool) Option {
+	return func(r *Router) {
+		r.introspection = enable
 	}
+}
 
-	routerConfig, err := r.configPoller.GetRouterConfig(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get initial router config: %w", err)
+// WithProfiling enables continuous profiling. The profiles are pushed to the configured backend.
+func WithProfiling(cfg *profiling.Config) Option {
+	return func(r *Router) {
+		r.profilingConfig = cfg
 	}
+}
 
-	if err := r.updateServerAndStart(ctx, routerConfig); err != nil {
-		r.logger.Error("Failed to start server with initial config", zap.Error(err))
-		return err
+func WithTracing(cfg *rtrace.Config) Option {
+	return func(r *Router) {
+		r.traceConfig = cfg
 	}
+}
 
-	r.logger.Info("Polling for router config updates in the background")
+func WithCors(corsOpts *cors.Config) Option {
+	return func(r *Router) {
+		r.corsOptions = corsOpts
+	}
+}
 
-	r.configPoller.Subscribe(ctx, func(newConfig *nodev1.RouterConfig, oldVersion string) error {
-		r.logger.Info("Router execution config has changed, upgrading server",
-			zap.String("old_version", oldVersion),
-			zap.String("new_version", newConfig.GetVersion()),
-		)
-		if err := r.updateServerAndStart(ctx, newConfig); err != nil {
-			r.logger.Error("Failed to start server with new config. Trying again on the next update cycle.", zap.Error(err))
-			return err
-		}
-		return nil
-	})
+// WithGraphQLPath sets the path where the GraphQL endpoint is served.
+func WithGraphQLPath(p string) Option {
+	return func(r *Router) {
+		r.graphqlPath = p
+	}
+}
 
-	return nil
+// WithGraphQLWebURL sets the URL to the GraphQL endpoint used by the GraphQL Playground.
+// This is useful when the path differs from the actual GraphQL endpoint e.g. when the router is behind a reverse proxy.
+// If not set, the GraphQL Playground uses the same URL as the GraphQL endpoint.
+func WithGraphQLWebURL(p string) Option {
+	return func(r *Router) {
+		r.graphqlWebURL = p
+	}
 }
 
-// newServer creates a new server instance.
-// All stateful data is copied from the Router over to the new server instance. Not safe for concurrent use.
-func (r *Router) newServer(ctx context.Context, routerConfig *nodev1.RouterConfig) (*server, error) {
-	s := &server{
-		Config:                  r.Config,
-		websocketStats:          r.WebsocketStats,
-		metricStore:             rmetric.NewNoopMetrics(),
-		executionTransport:      newHTTPTransport(r.subgraphTransportOptions),
-		baseRouterConfigVersion: routerConfig.GetVersion(),
-		pubSubProviders: &EnginePubSubProviders{
-			nats:  map[string]pubsub_datasource.NatsPubSub{},
-			kafka: map[string]pubsub_datasource.KafkaPubSub{},
-		},
+// WithPlaygroundPath sets the path where the GraphQL Playground is served.
+func WithPlaygroundPath(p string) Option {
+	return func(r *Router) {
+		r.playgroundPath = p
 	}
+}
 
-	baseOtelAttributes := []attribute.KeyValue{
-		otel.WgRouterVersion.String(Version),
-		otel.WgRouterClusterName.String(r.clusterName),
+func WithConfigPoller(cf configpoller.ConfigPoller) Option {
+	return func(r *Router) {
+		r.configPoller = cf
 	}
+}
 
-	if s.graphApiToken != "" {
-		claims, err := rjwt.ExtractFederatedGraphTokenClaims(s.graphApiToken)
-		if err != nil {
-			return nil, err
-		}
-		baseOtelAttributes = append(baseOtelAttributes, otel.WgFederatedGraphID.String(claims.FederatedGraphID))
+func WithSelfRegistration(sr selfregister.SelfRegister) Option {
+	return func(r *Router) {
+		r.selfRegister = sr
 	}
+}
 
-	s.baseOtelAttributes = baseOtelAttributes
+func WithGracePeriod(timeout time.Duration) Option {
+	return func(r *Router) {
+		r.gracePeriod = timeout
+	}
+}
 
-	if s.metricConfig.OpenTelemetry.RouterRuntime {
-		// Create runtime metrics exported to OTEL
-		s.runtimeMetrics = rmetric.NewRuntimeMetrics(
-			s.logger,
-			r.otlpMeterProvider,
-			// We track runtime metrics with base router config version
-			// even when we have multiple feature flags
-			append([]attribute.KeyValue{
-				otel.WgRouterConfigVersion.String(s.baseRouterConfigVersion),
-			}, s.baseOtelAttributes...),
-			s.processStartTime,
-		)
+// WithConfigDriftThreshold logs a warning when a newer router config from the config poller can't be applied for
+// longer than the threshold. Zero never warns.
+func WithConfigDriftThreshold(threshold time.Duration) Option {
+	return func(r *Router) {
+		r.configDriftThreshold = threshold
+	}
+}
+
+// WithRouterConfigFallbackPath caches the last successfully applied router config from the config poller at the
+// path. The router starts with the cached config when the initial config can't be fetched.
+func WithRouterConfigFallbackPath(path string) Option {
+	return func(r *Router) {
+		r.configFallbackPath = path
+	}
+}
+
+func WithMetrics(cfg *rmetric.Config) Option {
+	return func(r *Router) {
+		r.metricConfig = cfg
+	}
+}
+
+// CorsDefaultOptions returns the default CORS options for the rs/cors package.
+func CorsDefaultOptions() *cors.Config {
+	return &cors.Config{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+		},
+		AllowHeaders:     []string{},
+		AllowCredentials: false,
+	}
+}
 
-		// Start runtime metrics
-		if err := s.runtimeMetrics.Start(); err != nil {
-			return nil, err
-		}
+func WithGraphApiToken(token string) Option {
+	return func(r *Router) {
+		r.graphApiToken = token
 	}
+}
 
-	// Prometheus metricStore rely on OTLP metricStore
-	if s.metricConfig.IsEnabled() {
-		m, err := rmetric.NewStore(
-			rmetric.WithPromMeterProvider(s.promMeterProvider),
-			rmetric.WithOtlpMeterProvider(s.otlpMeterProvider),
-			rmetric.WithLogger(s.logger),
-			rmetric.WithProcessStartTime(s.processStartTime),
-			// Don't pass the router config version or feature flags here
-			// We scope the metrics to the feature flags and config version in the handler
-			rmetric.WithAttributes(baseOtelAttributes...),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create metric handler: %w", err)
-		}
+func WithModulesConfig(config map[string]interface{}) Option {
+	return func(r *Router) {
+		r.modulesConfig = config
+	}
+}
 
-		s.metricStore = m
+func WithStaticRouterConfig(cfg *nodev1.RouterConfig) Option {
+	return func(r *Router) {
+		r.staticRouterConfig = cfg
 	}
+}
 
-	if s.registrationInfo != nil {
-		publicKey, err := jwt.ParseECPublicKeyFromPEM([]byte(s.registrationInfo.GetGraphPublicKey()))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse router public key: %w", err)
-		}
-		s.publicKey = publicKey
+// WithAwsLambdaRuntime enables the AWS Lambda behaviour.
+// This flushes all telemetry data synchronously after the request is handled.
+func WithAwsLambdaRuntime() Option {
+	return func(r *Router) {
+		r.awsLambda = true
 	}
+}
 
-	recoveryHandler := recoveryhandler.New(
-		recoveryhandler.WithLogger(s.logger),
-		recoveryhandler.WithPrintStack(),
-	)
+func WithHealthCheckPath(path string) Option {
+	return func(r *Router) {
+		r.healthCheckPath = path
+	}
+}
 
-	if s.healthChecks == nil {
-		s.healthChecks = health.New(&health.Options{
-			Logger: s.logger,
-		})
+func WithHealthChecks(healthChecks health.Checker) Option {
+	return func(r *Router) {
+		r.healthChecks = healthChecks
 	}
+}
 
-	if s.playground {
-		playgroundUrl, err := url.JoinPath(s.baseURL, s.playgroundPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to join playground url: %w", err)
-		}
-		s.logger.Info("Serving GraphQL playground", zap.String("url", playgroundUrl))
-		s.playgroundHandler = graphiql.NewPlayground(&graphiql.PlaygroundOptions{
-			Log:        s.logger,
-			Html:       graphiql.PlaygroundHTML(),
-			GraphqlURL: s.graphqlWebURL,
-		})
+func WithReadinessCheckPath(path string) Option {
+	return func(r *Router) {
+		r.readinessCheckPath = path
 	}
+}
 
-	graphqlEndpointURL, err := url.JoinPath(r.baseURL, r.graphqlPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to join graphql endpoint url: %w", err)
+func WithLivenessCheckPath(path string) Option {
+	return func(r *Router) {
+		r.livenessCheckPath = path
 	}
+}
 
-	httpRouter := chi.NewRouter()
+// WithCDN sets the configuration for the CDN client
+func WithCDN(cfg config.CDNConfiguration) Option {
+	return func(r *Router) {
+		r.cdnConfig = cfg
+	}
+}
 
-	/**
-	* Middlewares
-	 */
+// WithEvents sets the configuration for the events client
+func WithEvents(cfg config.EventsConfiguration) Option {
+	return func(r *Router) {
+		r.eventsConfig = cfg
+	}
+}
 
-	httpRouter.Use(recoveryHandler)
-	httpRouter.Use(rmiddleware.RequestSize(int64(s.routerTrafficConfig.MaxRequestBodyBytes)))
-	httpRouter.Use(middleware.RequestID)
-	httpRouter.Use(middleware.RealIP)
-	httpRouter.Use(cors.New(*s.corsOptions))
+func WithHeaderRules(headers config.HeaderRules) Option {
+	return func(r *Router) {
+		r.headerRules = headers
+	}
+}
 
-	baseMux, err := s.buildMux(ctx, "", s.baseRouterConfigVersion, routerConfig.GetEngineConfig(), routerConfig.GetSubgraphs())
-	if err != nil {
-		return nil, fmt.Errorf("failed to build base mux: %w", err)
+func WithOverrideRoutingURL(overrideRoutingURL config.OverrideRoutingURLConfiguration) Option {
+	return func(r *Router) {
+		r.overrideRoutingURLConfiguration = overrideRoutingURL
 	}
+}
 
-	featureFlagConfigMap := routerConfig.FeatureFlagConfigs.GetConfigByFeatureFlagName()
-	if len(featureFlagConfigMap) > 0 {
-		s.logger.Info("Feature flags enabled", zap.Strings("flags", maps.Keys(featureFlagConfigMap)))
+func WithOverrides(overrides config.OverridesConfiguration) Option {
+	return func(r *Router) {
+		r.overrides = overrides
 	}
+}
 
-	multiGraphHandler, err := s.buildMultiGraphHandler(ctx, baseMux, featureFlagConfigMap)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build feature flag handler: %w", err)
+func WithSecurityConfig(cfg config.SecurityConfiguration) Option {
+	return func(r *Router) {
+		r.securityConfiguration = cfg
 	}
+}
 
-	brCompressor := middleware.NewCompressor(5, CustomCompressibleContentTypes...)
-	brCompressor.SetEncoder("br", func(w io.Writer, level int) io.Writer {
-		return br.NewWriterLevel(w, level)
-	})
+func WithEngineExecutionConfig(cfg config.EngineExecutionConfiguration) Option {
+	return func(r *Router) {
+		r.engineExecutionConfiguration = cfg
+	}
+}
 
-	/**
-	* A group where we can selectively apply middlewares to the graphql endpoint
-	 */
-	httpRouter.Group(func(cr chi.Router) {
+func WithSubgraphTransportOptions(opts *SubgraphTransportOptions) Option {
+	return func(r *Router) {
+		r.subgraphTransportOptions = opts
+	}
+}
 
-		// We are applying it conditionally because brotli compressing the 3MB playground is very slow
-		cr.Use(middleware.Compress(5, CustomCompressibleContentTypes...))
-		cr.Use(brCompressor.Handler)
+func WithSubgraphRetryOptions(enabled bool, maxRetryCount int, retryMaxDuration, retryInterval time.Duration) Option {
+	return func(r *Router) {
+		r.retryOptions = retrytransport.RetryOptions{
+			Enabled:       enabled,
+			MaxRetryCount: maxRetryCount,
+			MaxDuration:   retryMaxDuration,
+			Interval:      retryInterval,
+		}
+	}
+}
 
-		// Mount the feature flag handler. It calls the base mux if no feature flag is set.
-		cr.Mount(r.graphqlPath, multiGraphHandler)
+func WithRouterTrafficConfig(cfg *config.RouterTrafficConfiguration) Option {
+	return func(r *Router) {
+		r.routerTrafficConfig = cfg
+	}
+}
 
-		if r.webSocketConfiguration != nil && r.webSocketConfiguration.Enabled && r.webSocketConfiguration.AbsintheProtocol.Enabled {
-			// Mount the Absinthe protocol handler for WebSockets
-			httpRouter.Mount(r.webSocketConfiguration.AbsintheProtocol.HandlerPath, multiGraphHandler)
-		}
-	})
+func WithFileUploadConfig(cfg *config.FileUpload) Option {
+	return func(r *Router) {
+		r.fileUploadConfig = cfg
+	}
+}
 
-	/**
-	* Routes
-	 */
+func WithAccessController(controller *AccessController) Option {
+	return func(r *Router) {
+		r.accessController = controller
+	}
+}
 
-	// We mount the playground once here when we don't have a conflict with the websocket handler
-	// If we have a conflict, we mount the playground during building the individual muxes
-	if s.playgroundHandler != nil && s.graphqlPath != s.playgroundPath {
-		httpRouter.Get(r.playgroundPath, s.playgroundHandler(nil).ServeHTTP)
+func WithAuthorizationConfig(cfg *config.AuthorizationConfiguration) Option {
+	return func(r *Router) {
+		r.Config.authorization = cfg
 	}
+}
 
-	httpRouter.Get(s.healthCheckPath, s.healthChecks.Liveness())
-	httpRouter.Get(s.livenessCheckPath, s.healthChecks.Liveness())
-	httpRouter.Get(s.readinessCheckPath, s.healthChecks.Readiness())
+func WithRateLimitConfig(cfg *config.RateLimitConfiguration) Option {
+	return func(r *Router) {
+		r.Config.rateLimit = cfg
+	}
+}
 
-	/**
-	* Server logging after features has been initialized / disabled
-	 */
+// WithAdminServer enables the admin API on a dedicated listener.
+func WithAdminServer(cfg *AdminServerConfig) Option {
+	return func(r *Router) {
+		r.adminConfig = cfg
+	}
+}
 
-	r.logger.Info("GraphQL endpoint",
-		zap.String("method", http.MethodPost),
-		zap.String("url", graphqlEndpointURL),
-	)
+// WithMaintenance sets the configuration of the maintenance mode. The mode can be toggled at runtime with the admin API.
+func WithMaintenance(cfg *config.MaintenanceConfiguration) Option {
+	return func(r *Router) {
+		r.maintenanceConfig = cfg
+	}
+}
 
-	if s.localhostFallbackInsideDocker && docker.Inside() {
-		s.logger.Info("localhost fallback enabled, connections that fail to connect to localhost will be retried using host.docker.internal")
+// WithStartupReport configures the report that summarizes the resolved configuration on boot
+func WithStartupReport(cfg *config.StartupReportConfiguration) Option {
+	return func(r *Router) {
+		r.startupReportConfig = cfg
 	}
+}
 
-	if s.developmentMode && s.engineExecutionConfiguration.EnableRequestTracing && s.graphApiToken == "" {
-		s.logger.Warn("Advanced Request Tracing (ART) is enabled in development mode but requires a graph token to work in production. For more information see https://cosmo-docs.wundergraph.com/router/advanced-request-tracing-art")
+// WithMemorySoftLimit configures the soft memory limit that clears caches and sheds load under memory pressure
+func WithMemorySoftLimit(cfg *config.MemorySoftLimitConfiguration) Option {
+	return func(r *Router) {
+		r.memorySoftLimitConfig = cfg
 	}
+}
 
-	if s.redisClient != nil {
-		s.logger.Info("Rate limiting enabled",
-			zap.Int("rate", s.rateLimit.SimpleStrategy.Rate),
-			zap.Int("burst", s.rateLimit.SimpleStrategy.Burst),
-			zap.Duration("duration", s.Config.rateLimit.SimpleStrategy.Period),
-			zap.Bool("rejectExceeding", s.Config.rateLimit.SimpleStrategy.RejectExceedingRequests),
-		)
+// WithServerConfig configures the timeouts and limits of the HTTP server of the GraphQL listener
+func WithServerConfig(cfg *config.ServerConfiguration) Option {
+	return func(r *Router) {
+		r.serverConfig = cfg
 	}
+}
 
-	s.httpServer = &http.Server{
-		Addr: r.listenAddr,
-		// https://ieftimov.com/posts/make-resilient-golang-net-http-servers-using-timeouts-deadlines-context-cancellation/
-		ReadTimeout:       1 * time.Minute,
-		WriteTimeout:      2 * time.Minute,
-		ReadHeaderTimeout: 20 * time.Second,
-		Handler:           httpRouter,
-		ErrorLog:          zap.NewStdLog(r.logger),
-		TLSConfig:         r.tlsServerConfig,
+// WithSemanticConventions names the HTTP and GraphQL attributes of traces, metrics and access logs
+// after the current OpenTelemetry semantic conventions
+func WithSemanticConventions(stability otel.SemConvStability) Option {
+	return func(r *Router) {
+		r.semConvStability = stability
 	}
+}
 
-	return s, nil
+// WithAccessLogs configures additional outputs and fields of the access logs
+func WithAccessLogs(cfg *config.AccessLogsConfiguration) Option {
+	return func(r *Router) {
+		r.accessLogsConfig = cfg
+	}
 }
 
-// Shutdown gracefully shuts down the router. It blocks until the server is shutdown.
-// If the router is already shutdown, the method returns immediately without error. Not safe for concurrent use.
-func (r *Router) Shutdown(ctx context.Context) (err error) {
+// WithAccessLogger writes the access logs with the logger instead of the logger of the router. The entries get the
+// operation, the client and the subgraph errors of the request as fields.
+func WithAccessLogger(logger *zap.Logger) Option {
+	return func(r *Router) {
+		r.accessLogger = logger
+	}
+}
 
-	if r.shutdown {
-		return nil
+// WithLogRedactor redacts the access logs that are sent to Kafka. The redactor must already be applied to the
+// logger of the router and to the access logger.
+func WithLogRedactor(redactor *logging.Redactor) Option {
+	return func(r *Router) {
+		r.logRedactor = redactor
+	}
+}
+
+// WithLogIPAnonymizer anonymizes the addresses in the access logs that are sent to Kafka. The anonymizer must already
+// be applied to the logger of the router and to the access logger.
+func WithLogIPAnonymizer(anonymizer *logging.IPAnonymizer) Option {
+	return func(r *Router) {
+		r.logIPAnonymizer = anonymizer
 	}
+}
 
-	r.shutdown = true
+// WithOperationFingerprint normalizes the operations differently for their hash in the logs, the traces and the
+// metrics
+func WithOperationFingerprint(cfg *config.OperationFingerprintConfiguration) Option {
+	return func(r *Router) {
+		r.operationFingerprintCfg = cfg
+	}
+}
 
-	if r.configPoller != nil {
-		if subErr := r.configPoller.Stop(ctx); subErr != nil {
-			err = errors.Join(err, fmt.Errorf("failed to stop config poller: %w", subErr))
-		}
+// WithVariableRedaction redacts the variables of the operations in the access logs and the traces, except the
+// allowed variables of the operations
+func WithVariableRedaction(cfg *config.VariableRedactionConfiguration) Option {
+	return func(r *Router) {
+		r.variableRedactionConfig = cfg
 	}
+}
 
-	if r.activeServer != nil {
-		if subErr := r.activeServer.Shutdown(ctx); subErr != nil {
-			err = errors.Join(err, fmt.Errorf("failed to shutdown primary server: %w", subErr))
+// WithLogDropCounter exposes the dropped entries of an output of the logs with the name as metric
+func WithLogDropCounter(output string, counter LogDropCounter) Option {
+	return func(r *Router) {
+		if r.logDropCounters == nil {
+			r.logDropCounters = map[string]LogDropCounter{}
 		}
+		r.logDropCounters[output] = counter
 	}
+}
 
-	var wg sync.WaitGroup
+// WithLogMetrics exposes the counters of the logs as metrics. The loggers must report to the same LogMetrics.
+func WithLogMetrics(metrics *LogMetrics) Option {
+	return func(r *Router) {
+		r.logMetrics = metrics
+	}
+}
 
-	if r.prometheusServer != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if subErr := r.prometheusServer.Close(); subErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to shutdown prometheus server: %w", subErr))
-			}
-		}()
+// WithLogEscalation writes the buffered debug entries of a request when the request fails
+func WithLogEscalation(cfg *config.LogEscalationConfiguration) Option {
+	return func(r *Router) {
+		r.logEscalationConfig = cfg
 	}
+}
 
-	if r.tracerProvider != nil {
-		wg.Add(1)
+// WithSubscriptionLimits limits the concurrent subscription connections and the active subscriptions
+func WithSubscriptionLimits(cfg *config.SubscriptionLimitsConfiguration) Option {
+	return func(r *Router) {
+		r.subscriptionLimitsConfig = cfg
+	}
+}
 
-		go func() {
-			defer wg.Done()
+// WithSubscriptionBackpressure buffers the events of the subscriptions, so that slow clients don't hold up the others
+func WithSubscriptionBackpressure(cfg *config.SubscriptionBackpressureConfiguration) Option {
+	return func(r *Router) {
+		r.backpressureConfig = cfg
+	}
+}
 
-			if subErr := r.tracerProvider.Shutdown(ctx); subErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to shutdown tracer: %w", subErr))
-			}
-		}()
+// WithSubscriptionReaping closes the subscription connections that are idle for too long
+func WithSubscriptionReaping(cfg *config.SubscriptionReapingConfiguration) Option {
+	return func(r *Router) {
+		r.subscriptionReapingCfg = cfg
 	}
+}
 
-	if r.gqlMetricsExporter != nil {
-		wg.Add(1)
+// WithPanicRecovery configures the crash dumps of the panics of requests and whether the router exits on them
+func WithPanicRecovery(cfg *config.PanicRecoveryConfiguration) Option {
+	return func(r *Router) {
+		r.panicRecoveryConfig = cfg
+	}
+}
 
-		go func() {
-			defer wg.Done()
+// WithDeprecationWarnings reports the usage of deprecated config options and schema fields
+func WithDeprecationWarnings(cfg *config.DeprecationWarningsConfiguration) Option {
+	return func(r *Router) {
+		r.deprecationConfig = cfg
+	}
+}
 
-			if subErr := r.gqlMetricsExporter.Shutdown(ctx); subErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to shutdown graphql metrics exporter: %w", subErr))
-			}
-		}()
+// WithClientProtocols counts the operations by the protocol of the client
+func WithClientProtocols(cfg *config.ClientProtocolsConfiguration) Option {
+	return func(r *Router) {
+		r.clientProtocolsConfig = cfg
 	}
+}
 
-	if r.promMeterProvider != nil {
-		wg.Add(1)
+// WithPseudonymization replaces the values of the configured access log fields with pseudonyms
+func WithPseudonymization(cfg *config.PseudonymizationConfiguration) Option {
+	return func(r *Router) {
+		r.pseudonymizationConfig = cfg
+	}
+}
 
-		go func() {
-			defer wg.Done()
+// WithLogRetention deletes and compresses log files after the configured periods
+func WithLogRetention(cfg *config.LogRetentionConfiguration) Option {
+	return func(r *Router) {
+		r.logRetentionConfig = cfg
+	}
+}
 
-			if subErr := r.promMeterProvider.Shutdown(ctx); subErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to shutdown prometheus meter provider: %w", subErr))
-			}
-		}()
+// WithSLO computes the availability and latency SLIs and exports the burn rates of the error budgets as metrics
+func WithSLO(cfg *config.SLOConfiguration) Option {
+	return func(r *Router) {
+		r.sloConfig = cfg
 	}
+}
 
-	if r.otlpMeterProvider != nil {
-		wg.Add(1)
+// WithTopOperations keeps the statistics of the operations for the top operations of the admin API
+func WithTopOperations(cfg *config.TopOperationsConfiguration) Option {
+	return func(r *Router) {
+		r.topOperationsConfig = cfg
+	}
+}
 
-		go func() {
-			defer wg.Done()
+// WithAnomalyDetection logs an alert, and optionally calls a webhook, when error logs or server errors spike
+func WithAnomalyDetection(cfg *config.AnomalyDetectionConfiguration) Option {
+	return func(r *Router) {
+		r.anomalyDetectionConfig = cfg
+	}
+}
 
-			if subErr := r.otlpMeterProvider.Shutdown(ctx); subErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to shutdown OTLP meter provider: %w", subErr))
-			}
-		}()
+// WithLifecycleWebhooks sends webhooks on the startup, the shutdown and config changes of the router
+func WithLifecycleWebhooks(cfg *config.LifecycleWebhooksConfiguration) Option {
+	return func(r *Router) {
+		r.lifecycleWebhooksConfig = cfg
 	}
+}
 
-	if r.redisClient != nil {
-		wg.Add(1)
+// WithLifecycleHooks calls the hooks on the startup, the shutdown and config changes of the router, e.g. to couple
+// the router to the lifecycle of the application that embeds it
+func WithLifecycleHooks(hooks ...LifecycleHook) Option {
+	return func(r *Router) {
+		r.lifecycleHooks = append(r.lifecycleHooks, hooks...)
+	}
+}
 
-		go func() {
-			defer wg.Done()
+// WithSubgraphAuthentication authenticates the requests to the subgraphs with static or OAuth2 credentials
+func WithSubgraphAuthentication(cfg config.SubgraphAuthenticationConfiguration) Option {
+	return func(r *Router) {
+		r.subgraphAuthentication = cfg
+	}
+}
 
-			if subErr := r.redisClient.FlushAll(ctx); subErr.Err() != nil {
-				err = errors.Join(err, fmt.Errorf("failed to flush redis client: %w", subErr.Err()))
-			}
-			if closeErr := r.redisClient.Close(); closeErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to close redis client: %w", closeErr))
-			}
-		}()
+// WithRequestSigning requires requests to the GraphQL endpoint to be signed with the HMAC of a configured key
+func WithRequestSigning(cfg *config.RequestSigningConfiguration) Option {
+	return func(r *Router) {
+		r.requestSigningConfig = cfg
 	}
+}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+// WithBotDetection scores requests for automated traffic and tags, rate limits or blocks the flagged ones
+func WithBotDetection(cfg *config.BotDetectionConfiguration) Option {
+	return func(r *Router) {
+		r.botDetectionConfig = cfg
+	}
+}
 
-		for _, module := range r.modules {
-			if cleaner, ok := module.(Cleaner); ok {
-				if subErr := cleaner.Cleanup(); subErr != nil {
-					err = errors.Join(err, fmt.Errorf("failed to clean module %s: %w", module.Module().ID, subErr))
-				}
-			}
-		}
-	}()
+// WithOperationTimeouts limits the execution time of operations by type and name
+func WithOperationTimeouts(cfg *config.OperationTimeoutsConfiguration) Option {
+	return func(r *Router) {
+		r.operationTimeoutsConfig = cfg
+	}
+}
 
-	wg.Wait()
+// WithFetchConcurrency limits the concurrent subgraph fetches of a request, globally and by operation name
+func WithFetchConcurrency(cfg *config.FetchConcurrencyConfiguration) Option {
+	return func(r *Router) {
+		r.fetchConcurrencyConfig = cfg
+	}
+}
 
-	return err
+// WithEntityBatching tunes the batching and deduplication of the entity requests to the subgraphs
+func WithEntityBatching(cfg *config.EntityBatchingConfiguration) Option {
+	return func(r *Router) {
+		r.entityBatchingConfig = cfg
+	}
 }
 
-func WithListenerAddr(addr string) Option {
+// WithSubgraphResponseValidation validates the subgraph responses against the federated schema and logs the violations
+func WithSubgraphResponseValidation(cfg *config.SubgraphResponseValidationConfiguration) Option {
 	return func(r *Router) {
-		r.listenAddr = addr
+		r.responseValidationConfig = cfg
 	}
 }
 
-func WithLogger(logger *zap.Logger) Option {
+// WithResponseSizeLimit limits the size of the responses by replacing them with an error or truncating their lists
+func WithResponseSizeLimit(cfg *config.ResponseSizeLimitConfiguration) Option {
 	return func(r *Router) {
-		r.logger = logger
+		r.responseSizeLimitConfig = cfg
 	}
 }
 
-func WithPlayground(enable bool) Option {
+// WithRequestMemoryLimit aborts the requests that exceed the approximate memory limit of a single request
+func WithRequestMemoryLimit(cfg *config.RequestMemoryLimitConfiguration) Option {
 	return func(r *Router) {
-		r.playground = enable
+		r.requestMemoryLimitConfig = cfg
 	}
 }
 
-func WithIntrospection(enable bool) Option {
+// WithPersistedOperationUsage tracks the hits and the last usage of the persisted operations
+func WithPersistedOperationUsage(cfg *config.PersistedOperationUsageConfiguration) Option {
 	return func(r *Router) {
-		r.introspection = enable
+		r.persistedOpUsageConfig = cfg
 	}
 }
 
-func WithTracing(cfg *rtrace.Config) Option {
+// WithConfigAudit records the applied router configs in an audit log that is served on the admin API
+func WithConfigAudit(cfg *config.ConfigAuditConfiguration) Option {
 	return func(r *Router) {
-		r.traceConfig = cfg
+		r.configAuditConfig = cfg
 	}
 }
 
-func WithCors(corsOpts *cors.Config) Option {
+// WithAuditLog records the authentication outcomes, the authorization denials and the config reloads in a
+// dedicated append-only file
+func WithAuditLog(cfg *config.AuditLogConfiguration) Option {
 	return func(r *Router) {
-		r.corsOptions = corsOpts
+		r.auditLogConfig = cfg
 	}
 }
 
-// WithGraphQLPath sets the path where the GraphQL endpoint is served.
-func WithGraphQLPath(p string) Option {
+// WithExecutionAudit exports a record of the execution of every operation as NDJSON or as OTLP log records
+func WithExecutionAudit(cfg *config.ExecutionAuditConfiguration) Option {
 	return func(r *Router) {
-		r.graphqlPath = p
+		r.executionAuditConfig = cfg
 	}
 }
 
-// WithGraphQLWebURL sets the URL to the GraphQL endpoint used by the GraphQL Playground.
-// This is useful when the path differs from the actual GraphQL endpoint e.g. when the router is behind a reverse proxy.
-// If not set, the GraphQL Playground uses the same URL as the GraphQL endpoint.
-func WithGraphQLWebURL(p string) Option {
+// WithRESTEndpoints exposes persisted operations as REST endpoints with a generated OpenAPI document
+func WithRESTEndpoints(cfg *config.RESTEndpointsConfiguration) Option {
 	return func(r *Router) {
-		r.graphqlWebURL = p
+		r.restEndpointsConfig = cfg
 	}
 }
 
-// WithPlaygroundPath sets the path where the GraphQL Playground is served.
-func WithPlaygroundPath(p string) Option {
+// WithSubscriptionWebhooks runs subscriptions in the router and sends their events to webhooks
+func WithSubscriptionWebhooks(cfg *config.SubscriptionWebhooksConfiguration) Option {
 	return func(r *Router) {
-		r.playgroundPath = p
+		r.subWebhooksConfig = cfg
 	}
 }
 
-func WithConfigPoller(cf configpoller.ConfigPoller) Option {
+// WithSurrogateKeys tags the responses with the keys of their entities and purges the keys of mutation responses
+func WithSurrogateKeys(cfg *config.SurrogateKeysConfiguration) Option {
 	return func(r *Router) {
-		r.configPoller = cf
+		r.surrogateKeysConfig = cfg
 	}
 }
 
-func WithSelfRegistration(sr selfregister.SelfRegister) Option {
+// WithETags adds content based ETags to the responses of queries and answers matching conditional requests with
+// 304 Not Modified
+func WithETags(cfg *config.ETagsConfiguration) Option {
 	return func(r *Router) {
-		r.selfRegister = sr
+		r.etagsConfig = cfg
 	}
 }
 
-func WithGracePeriod(timeout time.Duration) Option {
+// WithPersistedOperationManifest records the distinct operations of the traffic in a manifest of persisted operations
+func WithPersistedOperationManifest(cfg *config.PersistedOperationManifestConfiguration) Option {
 	return func(r *Router) {
-		r.gracePeriod = timeout
+		r.persistedOpManifestCfg = cfg
 	}
 }
 
-func WithMetrics(cfg *rmetric.Config) Option {
+// WithRequestTags computes custom tags of the requests with CEL expressions and attaches them to the logs, the traces
+// and the metrics of the requests
+func WithRequestTags(cfg *config.RequestTagsConfiguration) Option {
 	return func(r *Router) {
-		r.metricConfig = cfg
+		r.requestTagsConfig = cfg
 	}
 }
 
-// CorsDefaultOptions returns the default CORS options for the rs/cors package.
-func CorsDefaultOptions() *cors.Config {
-	return &cors.Config{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{
-			http.MethodHead,
-			http.MethodGet,
-			http.MethodPost,
-		},
-		AllowHeaders:     []string{},
-		AllowCredentials: false,
+// WithSyntheticHealthOperation runs a GraphQL operation periodically through the router to probe the whole pipeline
+// including the subgraphs, and exports the result as metrics
+func WithSyntheticHealthOperation(cfg *config.SyntheticHealthOperationConfiguration) Option {
+	return func(r *Router) {
+		r.syntheticHealthConfig = cfg
 	}
 }
 
-func WithGraphApiToken(token string) Option {
+// WithChaos injects faults into the requests to the subgraphs, to validate the resilience settings. It must never be
+// enabled in production.
+func WithChaos(cfg *config.ChaosConfiguration) Option {
 	return func(r *Router) {
-		r.graphApiToken = token
+		r.chaosConfig = cfg
 	}
 }
 
-func WithModulesConfig(config map[string]interface{}) Option {
+// WithClockSkew compares the system clock periodically with a reference clock and warns about a skewed clock
+func WithClockSkew(cfg *config.ClockSkewConfiguration) Option {
 	return func(r *Router) {
-		r.modulesConfig = config
+		r.clockSkewConfig = cfg
 	}
 }
 
-func WithStaticRouterConfig(cfg *nodev1.RouterConfig) Option {
+// WithCertificateExpiry checks periodically when the TLS certificates of the listeners expire and warns about the
+// certificates that expire soon
+func WithCertificateExpiry(cfg *config.CertificateExpiryConfiguration) Option {
 	return func(r *Router) {
-		r.staticRouterConfig = cfg
+		r.certExpiryConfig = cfg
 	}
 }
 
-// WithAwsLambdaRuntime enables the AWS Lambda behaviour.
-// This flushes all telemetry data synchronously after the request is handled.
-func WithAwsLambdaRuntime() Option {
+// WithSubgraphCompression requests compressed responses from the subgraphs and decompresses them in the router
+func WithSubgraphCompression(cfg *config.SubgraphCompressionConfiguration) Option {
 	return func(r *Router) {
-		r.awsLambda = true
+		r.subgraphCompression = cfg
 	}
 }
 
-func WithHealthCheckPath(path string) Option {
+// WithSubgraphEndpoints sends a share of the requests to the subgraphs to their alternate endpoints, to switch
+// between a blue and a green deployment of a subgraph
+func WithSubgraphEndpoints(cfg *config.SubgraphEndpointsConfiguration) Option {
 	return func(r *Router) {
-		r.healthCheckPath = path
+		r.subgraphEndpointsConfig = cfg
 	}
 }
 
-func WithHealthChecks(healthChecks health.Checker) Option {
+// WithAdaptiveConcurrency limits the in-flight requests of every subgraph with a limit that adapts to the latency
+// and the errors of the subgraph
+func WithAdaptiveConcurrency(cfg *config.AdaptiveConcurrencyConfiguration) Option {
 	return func(r *Router) {
-		r.healthChecks = healthChecks
+		r.adaptiveConcurrencyCfg = cfg
 	}
 }
 
-func WithReadinessCheckPath(path string) Option {
+// WithResponseFormats enables the optional encodings of the responses, e.g. CBOR
+func WithResponseFormats(cfg *config.ResponseFormatsConfiguration) Option {
 	return func(r *Router) {
-		r.readinessCheckPath = path
+		r.responseFormats = cfg
 	}
 }
 
-func WithLivenessCheckPath(path string) Option {
+// WithTenants overrides selected settings, e.g. the rate limits and timeouts, per tenant of a multi-tenant router
+func WithTenants(cfg *config.TenantsConfiguration) Option {
 	return func(r *Router) {
-		r.livenessCheckPath = path
+		r.tenantsConfig = cfg
 	}
 }
 
-// WithCDN sets the configuration for the CDN client
-func WithCDN(cfg config.CDNConfiguration) Option {
+// WithRequestCoalescing executes identical concurrent queries once and serves the response to all of their callers
+func WithRequestCoalescing(cfg *config.RequestCoalescingConfiguration) Option {
 	return func(r *Router) {
-		r.cdnConfig = cfg
+		r.requestCoalescingConfig = cfg
 	}
 }
 
-// WithEvents sets the configuration for the events client
-func WithEvents(cfg config.EventsConfiguration) Option {
+// WithSealedVariables decrypts the sealed values of the variables before the operations are executed
+func WithSealedVariables(cfg *config.SealedVariablesConfiguration) Option {
 	return func(r *Router) {
-		r.eventsConfig = cfg
+		r.sealedVariablesConfig = cfg
 	}
 }
 
-func WithHeaderRules(headers config.HeaderRules) Option {
+// WithNonGraphQLRequests logs and counts the requests that don't reach the GraphQL handler, e.g. the CORS preflight
+// requests, the health checks, the playground and unknown paths
+func WithNonGraphQLRequests(cfg *config.NonGraphQLRequestsConfiguration) Option {
 	return func(r *Router) {
-		r.headerRules = headers
+		r.nonGraphQLRequestsConfig = cfg
 	}
 }
 
-func WithOverrideRoutingURL(overrideRoutingURL config.OverrideRoutingURLConfiguration) Option {
+// WithContractEnforcement removes the fields that are excluded from the contract from the subgraph responses and logs
+// the removed fields
+func WithContractEnforcement(cfg *config.ContractEnforcementConfiguration) Option {
 	return func(r *Router) {
-		r.overrideRoutingURLConfiguration = overrideRoutingURL
+		r.contractEnforcement = cfg
 	}
 }
 
-func WithOverrides(overrides config.OverridesConfiguration) Option {
+// WithSlowSubgraphWarnings logs a warning when a subgraph request exceeds the p95 latency of the subgraph by a factor
+func WithSlowSubgraphWarnings(cfg *config.SlowSubgraphWarningsConfiguration) Option {
 	return func(r *Router) {
-		r.overrides = overrides
+		r.slowSubgraphWarningsCfg = cfg
 	}
 }
 
-func WithSecurityConfig(cfg config.SecurityConfiguration) Option {
+// WithSubgraphTunnels sends the requests to single subgraphs through a SOCKS5 proxy or an SSH tunnel
+func WithSubgraphTunnels(cfg config.SubgraphTunnelsConfiguration) Option {
 	return func(r *Router) {
-		r.securityConfiguration = cfg
+		r.subgraphTunnelsConfig = cfg
 	}
 }
 
-func WithEngineExecutionConfig(cfg config.EngineExecutionConfiguration) Option {
+// WithLogShutdownFlush flushes the access logs with a deadline on shutdown and writes the marker entry after them
+func WithLogShutdownFlush(cfg *config.LogShutdownFlushConfiguration) Option {
 	return func(r *Router) {
-		r.engineExecutionConfiguration = cfg
+		r.logShutdownFlush = cfg
 	}
 }
 
-func WithSubgraphTransportOptions(opts *SubgraphTransportOptions) Option {
+// WithSubgraphMocks answers the requests to the subgraphs with generated data. It must only be used for development.
+func WithSubgraphMocks(cfg *config.SubgraphMocksConfiguration) Option {
 	return func(r *Router) {
-		r.subgraphTransportOptions = opts
+		r.subgraphMocks = cfg
 	}
 }
 
-func WithSubgraphRetryOptions(enabled bool, maxRetryCount int, retryMaxDuration, retryInterval time.Duration) Option {
+// WithSubgraphPayloadSize records the size of the subgraph payloads and limits the size of the subgraph responses
+func WithSubgraphPayloadSize(cfg *config.SubgraphPayloadSizeConfiguration) Option {
 	return func(r *Router) {
-		r.retryOptions = retrytransport.RetryOptions{
-			Enabled:       enabled,
-			MaxRetryCount: maxRetryCount,
-			MaxDuration:   retryMaxDuration,
-			Interval:      retryInterval,
-		}
+		r.subgraphPayloadSize = cfg
 	}
 }
 
-func WithRouterTrafficConfig(cfg *config.RouterTrafficConfiguration) Option {
+// WithConfigSignatureVerified marks the configs of the config poller as verified in the config audit log.
+// Set it when the CDN client of the poller validates the signature of the configs.
+func WithConfigSignatureVerified(verified bool) Option {
 	return func(r *Router) {
-		r.routerTrafficConfig = cfg
+		r.configSignatureVerified = verified
 	}
 }
 
-func WithFileUploadConfig(cfg *config.FileUpload) Option {
+// WithVersionEndpoint serves the version information of the router on the GraphQL listener
+func WithVersionEndpoint(cfg *config.VersionEndpointConfiguration) Option {
 	return func(r *Router) {
-		r.fileUploadConfig = cfg
+		r.versionEndpointConfig = cfg
 	}
 }
 
-func WithAccessController(controller *AccessController) Option {
+// WithConnectionTimings measures the TLS handshakes of the listeners and the connection setup to the subgraphs
+func WithConnectionTimings(cfg *config.ConnectionTimingsConfiguration) Option {
 	return func(r *Router) {
-		r.accessController = controller
+		r.connectionTimingsConfig = cfg
 	}
 }
 
-func WithAuthorizationConfig(cfg *config.AuthorizationConfiguration) Option {
+// WithProblemDetails writes the errors outside of GraphQL as problem details of RFC 9457
+func WithProblemDetails(cfg *config.ProblemDetailsConfiguration) Option {
 	return func(r *Router) {
-		r.Config.authorization = cfg
+		r.problemDetails = cfg
 	}
 }
 
-func WithRateLimitConfig(cfg *config.RateLimitConfiguration) Option {
+// WithCustomResponseHeaders adds the headers of the rules to the responses of the GraphQL and the admin listener
+func WithCustomResponseHeaders(cfg *config.CustomResponseHeadersConfiguration) Option {
 	return func(r *Router) {
-		r.Config.rateLimit = cfg
+		r.customResponseHeaders = cfg
 	}
 }
 
@@ -1381,6 +3602,36 @@ func WithLocalhostFallbackInsideDocker(fallback bool) Option {
 func DefaultRouterTrafficConfig() *config.RouterTrafficConfiguration {
 	return &config.RouterTrafficConfiguration{
 		MaxRequestBodyBytes: 1000 * 1000 * 5, // 5 MB
+		ConfigSwap: config.ConfigSwapConfiguration{
+			MaxQueuedRequests: 1000,
+			QueueTimeout:      5 * time.Second,
+		},
+	}
+}
+
+func DefaultServerConfig() *config.ServerConfiguration {
+	return &config.ServerConfiguration{
+		MaxHeaderBytes:       1 << 20, // 1 MB
+		ReadTimeout:          1 * time.Minute,
+		ReadHeaderTimeout:    20 * time.Second,
+		WriteTimeout:         2 * time.Minute,
+		IdleTimeout:          90 * time.Second,
+		MaxConcurrentStreams: 250,
+	}
+}
+
+func DefaultStartupReportConfig() *config.StartupReportConfiguration {
+	return &config.StartupReportConfiguration{
+		Enabled: true,
+	}
+}
+
+func DefaultMaintenanceConfig() *config.MaintenanceConfiguration {
+	return &config.MaintenanceConfiguration{
+		Enabled:    false,
+		StatusCode: http.StatusServiceUnavailable,
+		RetryAfter: 30 * time.Second,
+		Message:    "The service is temporarily unavailable due to maintenance",
 	}
 }
 
@@ -1431,6 +3682,13 @@ func WithClusterName(name string) Option {
 	}
 }
 
+// WithClusterPeers keeps an inventory of the instances of the cluster that is served by the admin API
+func WithClusterPeers(cfg *config.ClusterPeersConfiguration) Option {
+	return func(r *Router) {
+		r.clusterPeersConfig = cfg
+	}
+}
+
 func WithInstanceID(id string) Option {
 	return func(r *Router) {
 		r.instanceID = id
@@ -1570,6 +3828,31 @@ func buildResourceAttributes(attributes []config.OtelResourceAttribute) []attrib
 	return r.ToSlice()
 }
 
+// SemConvStabilityFromTelemetry returns the legacy mode unless the semantic convention mode is enabled
+func SemConvStabilityFromTelemetry(cfg *config.Telemetry) otel.SemConvStability {
+	if !cfg.SemanticConventions.Enabled {
+		return otel.SemConvLegacy
+	}
+	return otel.ParseSemConvStability(cfg.SemanticConventions.StabilityOptIn)
+}
+
+func ProfilingConfigFromTelemetry(cfg *config.Telemetry) *profiling.Config {
+	profileTypes := make([]profiling.ProfileType, 0, len(cfg.Profiling.ProfileTypes))
+	for _, profileType := range cfg.Profiling.ProfileTypes {
+		profileTypes = append(profileTypes, profiling.ProfileType(profileType))
+	}
+
+	return &profiling.Config{
+		Enabled:      cfg.Profiling.Enabled,
+		Exporter:     profiling.Exporter(cfg.Profiling.Exporter),
+		Endpoint:     cfg.Profiling.Endpoint,
+		ServiceName:  cfg.ServiceName,
+		Interval:     cfg.Profiling.Interval,
+		ProfileTypes: profileTypes,
+		Headers:      cfg.Profiling.Headers,
+	}
+}
+
 func MetricConfigFromTelemetry(cfg *config.Telemetry) *rmetric.Config {
 	var openTelemetryExporters []*rmetric.OpenTelemetryExporter
 	for _, exp := range cfg.Metrics.OTLP.Exporters {
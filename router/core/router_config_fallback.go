@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/cosmo/router/pkg/execution_config"
+)
+
+// routerConfigFallback caches the last successfully applied router config on disk. The router starts with the cached
+// config when the initial config can't be fetched from the CDN or the control plane, and replaces it with the first
+// config the config poller fetches.
+type routerConfigFallback struct {
+	logger *zap.Logger
+	path   string
+
+	mu sync.Mutex
+	// activeVersion is the version of the cached config while the router serves it, empty otherwise
+	activeVersion string
+}
+
+func newRouterConfigFallback(logger *zap.Logger, path string) *routerConfigFallback {
+	return &routerConfigFallback{
+		logger: logger,
+		path:   path,
+	}
+}
+
+// applied caches the router config that was applied successfully. Failures to write the cache are logged, they don't
+// affect the active config.
+func (f *routerConfigFallback) applied(routerConfig *nodev1.RouterConfig) {
+	f.mu.Lock()
+	f.activeVersion = ""
+	f.mu.Unlock()
+
+	if err := f.store(routerConfig); err != nil {
+		f.logger.Warn("Failed to cache the router config as the fallback config",
+			zap.String("path", f.path),
+			zap.String("version", routerConfig.GetVersion()),
+			zap.Error(err),
+		)
+	}
+}
+
+func (f *routerConfigFallback) store(routerConfig *nodev1.RouterConfig) error {
+	data, err := protojson.Marshal(routerConfig)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// load returns the cached router config and marks it as active
+func (f *routerConfigFallback) load() (*nodev1.RouterConfig, error) {
+	routerConfig, err := execution_config.SerializeConfigFromFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.activeVersion = routerConfig.GetVersion()
+	f.mu.Unlock()
+
+	return routerConfig, nil
+}
+
+func (f *routerConfigFallback) active() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.activeVersion != ""
+}
+
+// RegisterMetrics exposes whether the router serves the cached config on the meter provider. The metric stays
+// registered until the meter provider is shut down.
+func (f *routerConfigFallback) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	fallback, err := meter.Int64ObservableGauge(
+		"router.config.fallback",
+		otelmetric.WithDescription("One while the router serves the cached router config because the initial config couldn't be fetched, zero otherwise"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		var value int64
+		if f.active() {
+			value = 1
+		}
+		o.ObserveInt64(fallback, value)
+		return nil
+	}, fallback)
+
+	return err
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+)
+
+func TestRouterConfigFallback(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.WarnLevel)
+	path := filepath.Join(t.TempDir(), "cache", "router-config.json")
+	f := newRouterConfigFallback(zap.New(logCore), path)
+
+	// Nothing was cached yet
+	_, err := f.load()
+	require.Error(t, err)
+	require.False(t, f.active())
+
+	f.applied(&nodev1.RouterConfig{
+		Version:   "v1",
+		Subgraphs: []*nodev1.Subgraph{{Name: "employees"}},
+	})
+	require.False(t, f.active())
+
+	routerConfig, err := f.load()
+	require.NoError(t, err)
+	require.Equal(t, "v1", routerConfig.GetVersion())
+	require.Equal(t, "employees", routerConfig.GetSubgraphs()[0].GetName())
+	require.True(t, f.active())
+
+	// The next applied config replaces the cached config
+	f.applied(&nodev1.RouterConfig{Version: "v2"})
+	require.False(t, f.active())
+	routerConfig, err = f.load()
+	require.NoError(t, err)
+	require.Equal(t, "v2", routerConfig.GetVersion())
+
+	// The temporary files are removed
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Zero(t, logs.Len())
+
+	// A cache that can't be written is logged
+	unwritable := newRouterConfigFallback(zap.New(logCore), filepath.Join(path, "router-config.json"))
+	unwritable.applied(&nodev1.RouterConfig{Version: "v3"})
+	require.Equal(t, 1, logs.Len())
+	require.Equal(t, "v3", logs.All()[0].ContextMap()["version"])
+}
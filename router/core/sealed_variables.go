@@ -0,0 +1,197 @@
+package core
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+// sealedValuePrefix starts the sealed values of the variables, followed by the ID of the key and the payload
+const sealedValuePrefix = "sealed:"
+
+// SealedVariables decrypts the sealed values of the variables before the operations are executed. Clients, or the
+// backends of the clients, seal sensitive inputs with a key shared with the router, so that the values are neither
+// visible to the intermediaries between the clients and the router nor in the logs and the traces, which keep the
+// sealed values.
+//
+// A sealed value is a string of the form "sealed:<key id>:<payload>". The payload is the base64url encoded nonce of
+// 12 bytes followed by the AES-256-GCM encrypted JSON value of the variable.
+type SealedVariables struct {
+	keys map[string]cipher.AEAD
+}
+
+type SealedVariablesKey struct {
+	ID string
+	// Secret is the base64 encoded key of 32 bytes
+	Secret string
+}
+
+type SealedVariablesOptions struct {
+	Keys []SealedVariablesKey
+}
+
+func NewSealedVariables(opts *SealedVariablesOptions) (*SealedVariables, error) {
+	if len(opts.Keys) == 0 {
+		return nil, errors.New("the sealed variables require at least one key")
+	}
+
+	s := &SealedVariables{keys: make(map[string]cipher.AEAD, len(opts.Keys))}
+	for _, key := range opts.Keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("invalid ID of a sealed variables key '%s': it must not be empty or contain ':'", key.ID)
+		}
+		if _, ok := s.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate sealed variables key '%s'", key.ID)
+		}
+		secret, err := base64.StdEncoding.DecodeString(key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sealed variables key '%s': %w", key.ID, err)
+		}
+		if len(secret) != 32 {
+			return nil, fmt.Errorf("invalid sealed variables key '%s': the key must have 32 bytes, got %d", key.ID, len(secret))
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sealed variables key '%s': %w", key.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sealed variables key '%s': %w", key.ID, err)
+		}
+		s.keys[key.ID] = aead
+	}
+
+	return s, nil
+}
+
+// unseal returns the variables with their sealed values replaced by the decrypted values, or nil if the variables
+// have no sealed values. The errors don't contain the values.
+func (s *SealedVariables) unseal(variables []byte) ([]byte, error) {
+	if s == nil || !bytes.Contains(variables, []byte(sealedValuePrefix)) {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(variables))
+	unsealed, err := s.unsealValue(&buf, variables, jsonparser.Object)
+	if err != nil {
+		return nil, &inputError{
+			message:    err.Error(),
+			statusCode: http.StatusOK,
+		}
+	}
+	if !unsealed {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// unsealValue writes the value to buf with its sealed values decrypted. The objects and lists are unsealed
+// recursively. It reports whether a sealed value was decrypted.
+func (s *SealedVariables) unsealValue(buf *bytes.Buffer, value []byte, dataType jsonparser.ValueType) (bool, error) {
+	unsealed := false
+
+	switch dataType {
+	case jsonparser.Object:
+		buf.WriteByte('{')
+		first := true
+		err := jsonparser.ObjectEach(value, func(key []byte, value []byte, dataType jsonparser.ValueType, _ int) error {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.WriteByte('"')
+			buf.Write(key)
+			buf.WriteString(`":`)
+
+			ok, err := s.unsealValue(buf, value, dataType)
+			unsealed = unsealed || ok
+			return err
+		})
+		if err != nil {
+			return false, err
+		}
+		buf.WriteByte('}')
+	case jsonparser.Array:
+		buf.WriteByte('[')
+		first := true
+		var arrayErr error
+		_, err := jsonparser.ArrayEach(value, func(value []byte, dataType jsonparser.ValueType, _ int, _ error) {
+			if arrayErr != nil {
+				return
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+
+			ok, err := s.unsealValue(buf, value, dataType)
+			unsealed = unsealed || ok
+			arrayErr = err
+		})
+		if arrayErr != nil {
+			return false, arrayErr
+		}
+		if err != nil {
+			return false, err
+		}
+		buf.WriteByte(']')
+	case jsonparser.String:
+		// The value of a string is returned without its quotes but still escaped. The sealed values don't need
+		// escaping.
+		if !bytes.HasPrefix(value, []byte(sealedValuePrefix)) {
+			buf.WriteByte('"')
+			buf.Write(value)
+			buf.WriteByte('"')
+			return false, nil
+		}
+		plaintext, err := s.decrypt(string(value[len(sealedValuePrefix):]))
+		if err != nil {
+			return false, err
+		}
+		buf.Write(plaintext)
+		return true, nil
+	default:
+		buf.Write(value)
+	}
+
+	return unsealed, nil
+}
+
+// decrypt returns the JSON value of a sealed value without its prefix
+func (s *SealedVariables) decrypt(sealed string) ([]byte, error) {
+	keyID, payload, ok := strings.Cut(sealed, ":")
+	if !ok {
+		return nil, errors.New("invalid sealed value: it must be of the form 'sealed:<key id>:<payload>'")
+	}
+	aead, ok := s.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("invalid sealed value: unknown key '%s'", keyID)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return nil, errors.New("invalid sealed value: the payload isn't base64url encoded")
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("invalid sealed value: the payload is too short")
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed value: the payload can't be decrypted with key '%s'", keyID)
+	}
+	if !json.Valid(plaintext) {
+		return nil, errors.New("invalid sealed value: the decrypted value isn't JSON")
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSealedVariablesSecret = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func sealTestValue(t *testing.T, secret, keyID, value string) string {
+	t.Helper()
+
+	key, err := base64.StdEncoding.DecodeString(secret)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+
+	return sealedValuePrefix + keyID + ":" + base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil))
+}
+
+func TestSealedVariables(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewSealedVariables(&SealedVariablesOptions{Keys: []SealedVariablesKey{{ID: "k1", Secret: testSealedVariablesSecret}}})
+	require.NoError(t, err)
+
+	t.Run("decrypts the sealed values", func(t *testing.T) {
+		t.Parallel()
+
+		ssn := sealTestValue(t, testSealedVariablesSecret, "k1", `"123-45-6789"`)
+		id := sealTestValue(t, testSealedVariablesSecret, "k1", `7`)
+		address := sealTestValue(t, testSealedVariablesSecret, "k1", `{"zip":"10115"}`)
+
+		unsealed, err := s.unseal([]byte(`{"ssn":"` + ssn + `","input":{"ids":["` + id + `",2],"address":"` + address + `","note":"a \"quoted\" note"},"n":null}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"ssn":"123-45-6789","input":{"ids":[7,2],"address":{"zip":"10115"},"note":"a \"quoted\" note"},"n":null}`, string(unsealed))
+	})
+
+	t.Run("returns nil without sealed values", func(t *testing.T) {
+		t.Parallel()
+
+		unsealed, err := s.unseal([]byte(`{"a":"plain","b":[1,2]}`))
+		require.NoError(t, err)
+		require.Nil(t, unsealed)
+
+		// The prefix in the middle of a value isn't a sealed value
+		unsealed, err = s.unseal([]byte(`{"a":"not sealed:k1:abc"}`))
+		require.NoError(t, err)
+		require.Nil(t, unsealed)
+
+		var disabled *SealedVariables
+		unsealed, err = disabled.unseal([]byte(`{"a":"sealed:k1:abc"}`))
+		require.NoError(t, err)
+		require.Nil(t, unsealed)
+	})
+
+	t.Run("rejects invalid sealed values", func(t *testing.T) {
+		t.Parallel()
+
+		otherSecret := base64.StdEncoding.EncodeToString([]byte("abcdefghijklmnopqrstuvwxyz012345"))
+		for _, sealed := range []string{
+			"sealed:k1",
+			"sealed:k2:" + sealTestValue(t, testSealedVariablesSecret, "k2", `1`)[len("sealed:k2:"):],
+			"sealed:k1:!!!",
+			"sealed:k1:YWJj",
+			sealTestValue(t, otherSecret, "k1", `1`),
+			sealTestValue(t, testSealedVariablesSecret, "k1", `not json`),
+		} {
+			_, err := s.unseal([]byte(`{"a":["` + sealed + `"]}`))
+			require.Error(t, err, sealed)
+			require.NotContains(t, err.Error(), "not json")
+		}
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		t.Parallel()
+
+		for _, keys := range [][]SealedVariablesKey{
+			nil,
+			{{ID: "", Secret: testSealedVariablesSecret}},
+			{{ID: "a:b", Secret: testSealedVariablesSecret}},
+			{{ID: "k1", Secret: "not base64"}},
+			{{ID: "k1", Secret: base64.StdEncoding.EncodeToString([]byte("short"))}},
+			{{ID: "k1", Secret: testSealedVariablesSecret}, {ID: "k1", Secret: testSealedVariablesSecret}},
+		} {
+			_, err := NewSealedVariables(&SealedVariablesOptions{Keys: keys})
+			require.Error(t, err)
+		}
+	})
+}
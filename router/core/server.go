@@ -32,6 +32,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const (
@@ -69,6 +70,14 @@ type (
 		runtimeMetrics          *rmetric.RuntimeMetrics
 		metricStore             rmetric.Store
 		baseRouterConfigVersion string
+		// unregisterShrinkers removes the caches of this server from the memory guard on shutdown
+		unregisterShrinkers []func()
+		// graphqlHandler serves the GraphQL requests of all feature flags
+		graphqlHandler http.Handler
+		// stopSubscriptionWebhooks stops the subscriptions of the webhooks that run on this server
+		stopSubscriptionWebhooks func(ctx context.Context) error
+		// stopSyntheticHealth stops the synthetic health operation that runs on this server
+		stopSyntheticHealth func(ctx context.Context) error
 	}
 )
 
@@ -225,27 +234,116 @@ func (s *server) buildMux(ctx context.Context,
 		baseLogFields = append(baseLogFields, zap.String("feature_flag", featureFlagName))
 	}
 
+	// The logs of the requests carry the config version as well, so that errors can be correlated with a publish of
+	// the graph
+	muxLogger := s.logger.With(baseLogFields...)
+
+	var operationsConfig *config.AccessLogsOperationsConfiguration
+	if s.accessLogsConfig != nil && s.accessLogsConfig.Operations.Enabled {
+		operationsConfig = &s.accessLogsConfig.Operations
+	}
+
 	// Request logger
 	requestLoggerOpts := []requestlogger.Option{
 		requestlogger.WithDefaultOptions(),
 		requestlogger.WithNoTimeField(),
 		requestlogger.WithFields(baseLogFields...),
 		requestlogger.WithRequestFields(func(request *http.Request) []zapcore.Field {
-			return []zapcore.Field{
+			fields := []zapcore.Field{
 				zap.String("request_id", middleware.GetReqID(request.Context())),
 			}
+			if s.accessLogger != nil {
+				fields = append(fields, accessLogRequestFields(request)...)
+			}
+			if operationsConfig != nil {
+				fields = append(fields, accessLogOperationFields(operationsConfig, s.variableRedactor, request, s.accessLogger != nil)...)
+			}
+			if lc := getLogEntryContext(request.Context()); lc != nil && lc.requestContext != nil && len(lc.requestContext.tags) > 0 {
+				fields = append(fields, zap.Object("tags", lc.requestContext.tags))
+			}
+			if lc := getLogEntryContext(request.Context()); lc != nil && lc.errorClass != errorClassNone {
+				fields = append(fields, zap.String("error_class", string(lc.errorClass)))
+			}
+			if lc := getLogEntryContext(request.Context()); lc != nil && lc.errorCode != "" {
+				fields = append(fields, zap.String("error_code", lc.errorCode))
+			}
+			if lc := getLogEntryContext(request.Context()); lc != nil && lc.clientProtocol != "" {
+				fields = append(fields, zap.String("client_protocol", string(lc.clientProtocol)))
+			}
+			fields = append(fields, accessLogCacheFields(request)...)
+			if s.userAgentParser != nil {
+				fields = append(fields, accessLogUserAgentFields(s.userAgentParser, request)...)
+			}
+			return fields
 		}),
 	}
 
+	if s.semConvStability != otel.SemConvLegacy {
+		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithSemConvStability(s.semConvStability))
+	}
+
+	if s.accessLogsConfig != nil && s.accessLogsConfig.TraceContext.Enabled {
+		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithTraceContext(s.accessLogsConfig.TraceContext.LogUnsampled))
+	}
+
+	if s.topOperations != nil {
+		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithObserver(s.topOperations.Observe))
+	}
+
+	if s.tenantOverrides != nil {
+		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithSampler(s.tenantOverrides.accessLogSampler(s.accessLogSampler)))
+	} else if s.accessLogSampler != nil {
+		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithSampler(s.accessLogSampler.Sample))
+	}
+
 	if s.ipAnonymization.Enabled {
 		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithAnonymization(&requestlogger.IPAnonymizationConfig{
 			Enabled: s.ipAnonymization.Enabled,
 			Method:  requestlogger.IPAnonymizationMethod(s.ipAnonymization.Method),
+			Hasher:  s.ipHasher,
+		}))
+	}
+
+	if s.pseudonymizer != nil {
+		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithPseudonymization(s.pseudonymizer, s.pseudonymizationConfig.Fields...))
+	}
+
+	if len(s.logEntryHandlers) > 0 {
+		requestLoggerOpts = append(requestLoggerOpts, requestlogger.WithEntryHandler(func(r *http.Request, fields []zapcore.Field) ([]zapcore.Field, bool) {
+			var ctx RequestContext
+			if lc := getLogEntryContext(r.Context()); lc != nil {
+				ctx = lc.RequestContext()
+			}
+			return handleLogEntry(s.logEntryHandlers, ctx, &LogEntry{
+				Access:  true,
+				Level:   zapcore.InfoLevel,
+				Message: r.URL.Path,
+				Fields:  fields,
+			})
+		}))
+	}
+
+	// The name allows to write the access logs to a dedicated file
+	requestLoggerBase := s.logger.Named("access")
+	if s.accessLogger != nil {
+		requestLoggerBase = s.accessLogger
+	}
+	if s.accessLogKafkaSink != nil {
+		requestLoggerBase = requestLoggerBase.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			// The sink is teed after the redaction of the logger
+			kafkaCore := s.accessLogKafkaSink.Core()
+			if s.logRedactor != nil {
+				kafkaCore = s.logRedactor.Core(kafkaCore)
+			}
+			if s.logIPAnonymizer != nil {
+				kafkaCore = s.logIPAnonymizer.Core(kafkaCore)
+			}
+			return zapcore.NewTee(core, kafkaCore)
 		}))
 	}
 
 	requestLogger := requestlogger.New(
-		s.logger,
+		requestLoggerBase,
 		requestLoggerOpts...,
 	)
 
@@ -257,30 +355,39 @@ func (s *server) buildMux(ctx context.Context,
 		})
 	})
 
-	if s.traceConfig.Enabled {
-		httpRouter.Use(func(h http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// The base attributes label the metrics of the requests as well, so that the feature flags can be compared
+	// without tracing
+	httpRouter.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-				var attributes []attribute.KeyValue
-				for _, mapper := range otelAttributesMappers {
-					attributes = append(attributes, mapper(r)...)
-				}
+			var attributes []attribute.KeyValue
+			for _, mapper := range otelAttributesMappers {
+				attributes = append(attributes, mapper(r)...)
+			}
 
-				attributes = append(attributes, baseOtelAttributes...)
+			attributes = append(attributes, baseOtelAttributes...)
 
-				r = r.WithContext(
-					withBaseAttributes(r.Context(), attributes),
-				)
+			r = r.WithContext(
+				withBaseAttributes(r.Context(), attributes),
+			)
 
-				h.ServeHTTP(w, r)
-			})
+			h.ServeHTTP(w, r)
 		})
-	}
+	})
 
 	// Register the trace middleware before the request logger, so we can log the trace ID
 	if traceHandler != nil {
 		httpRouter.Use(traceHandler.Handler)
 	}
+	// The access log is written after the request context is gone, so it is kept for the handlers and the
+	// error class of the request
+	httpRouter.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, _ := withLogEntryContext(r.Context())
+			markGraphQLRequest(ctx)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
 	httpRouter.Use(requestLogger)
 
 	routerEngineConfig := &RouterEngineConfiguration{
@@ -295,11 +402,82 @@ func (s *server) buildMux(ctx context.Context,
 		return nil, fmt.Errorf("failed to build pubsub configuration: %w", err)
 	}
 
+	var entityBatcher *EntityBatcher
+	if s.entityBatching != nil {
+		opts := *s.entityBatching
+		opts.MetricStore = s.metricStore
+		entityBatcher, err = NewEntityBatcher(&opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create entity batcher: %w", err)
+		}
+	}
+
+	var coalescingWindow time.Duration
+	if s.engineExecutionConfiguration.FetchCoalescing.Enabled {
+		coalescingWindow = s.engineExecutionConfiguration.FetchCoalescing.Window
+	}
+
+	var responseValidator *SubgraphResponseValidator
+	if s.responseValidationConfig != nil && s.responseValidationConfig.Enabled {
+		responseValidator, err = NewSubgraphResponseValidator(&SubgraphResponseValidatorOptions{
+			Logger:              muxLogger,
+			MetricStore:         s.metricStore,
+			SampleRate:          s.responseValidationConfig.SampleRate,
+			MaxLoggedViolations: s.responseValidationConfig.MaxLoggedViolations,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create subgraph response validator: %w", err)
+		}
+	}
+
+	var contractEnforcer *ContractEnforcer
+	if s.contractEnforcement != nil && s.contractEnforcement.Enabled {
+		contractEnforcer = NewContractEnforcer(&ContractEnforcerOptions{
+			Logger:              muxLogger,
+			MetricStore:         s.metricStore,
+			ExcludeTags:         s.contractEnforcement.ExcludeTags,
+			MaxLoggedViolations: s.contractEnforcement.MaxLoggedViolations,
+		})
+	}
+
+	var compression *SubgraphCompression
+	if s.subgraphCompression != nil && s.subgraphCompression.Enabled {
+		compression, err = NewSubgraphCompression(&SubgraphCompressionOptions{
+			Config:      s.subgraphCompression,
+			MetricStore: s.metricStore,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create subgraph compression: %w", err)
+		}
+	}
+
+	var mocks *SubgraphMocks
+	if s.subgraphMocks != nil && s.subgraphMocks.Enabled {
+		mocks, err = NewSubgraphMocks(&SubgraphMocksOptions{
+			Config:       s.subgraphMocks,
+			Logger:       muxLogger,
+			EngineConfig: engineConfig,
+			Subgraphs:    configSubgraphs,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create subgraph mocks: %w", err)
+		}
+	}
+
+	var payloadSize *SubgraphPayloadSize
+	if s.subgraphPayloadSize != nil && s.subgraphPayloadSize.Enabled {
+		payloadSize = NewSubgraphPayloadSize(&SubgraphPayloadSizeOptions{
+			Config:      s.subgraphPayloadSize,
+			MetricStore: s.metricStore,
+			Logger:      muxLogger,
+		})
+	}
+
 	ecb := &ExecutorConfigurationBuilder{
 		introspection: s.introspection,
 		baseURL:       s.baseURL,
 		transport:     s.executionTransport,
-		logger:        s.logger,
+		logger:        muxLogger,
 		includeInfo:   s.graphqlMetricsConfig.Enabled,
 		transportOptions: &TransportOptions{
 			RequestTimeout: s.subgraphTransportOptions.RequestTimeout,
@@ -317,7 +495,20 @@ func (s *server) buildMux(ctx context.Context,
 			},
 			TracerProvider:                s.tracerProvider,
 			LocalhostFallbackInsideDocker: s.localhostFallbackInsideDocker,
-			Logger:                        s.logger,
+			Logger:                        muxLogger,
+			EntityBatcher:                 entityBatcher,
+			CoalescingWindow:              coalescingWindow,
+			ResponseValidator:             responseValidator,
+			ContractEnforcer:              contractEnforcer,
+			Chaos:                         s.chaos,
+			Endpoints:                     s.subgraphEndpoints,
+			AdaptiveConcurrency:           s.adaptiveConcurrency,
+			Mocks:                         mocks,
+			Compression:                   compression,
+			PayloadSize:                   payloadSize,
+			ConnectionTimings:             s.connectionTimings,
+			SlowSubgraphs:                 s.slowSubgraphWarnings,
+			Tunnels:                       s.subgraphTunnels,
 		},
 	}
 
@@ -335,17 +526,36 @@ func (s *server) buildMux(ctx context.Context,
 		return nil, fmt.Errorf("failed to build plan configuration: %w", err)
 	}
 
+	if responseValidator != nil {
+		responseValidator.setDefinition(executor.RouterSchema)
+	}
+	if contractEnforcer != nil {
+		contractEnforcer.setSchemas(executor.RouterSchema, executor.ClientSchema)
+	}
+
 	operationParser := NewOperationParser(OperationParserOptions{
 		Executor:                       executor,
 		MaxOperationSizeInBytes:        int64(s.routerTrafficConfig.MaxRequestBodyBytes),
 		PersistentOpClient:             s.cdnPersistentOpClient,
 		EnablePersistedOperationsCache: s.engineExecutionConfiguration.EnablePersistedOperationsCache,
+		JSONLimits: JSONLimits{
+			MaxDepth:        s.securityConfiguration.JSONLimits.MaxDepth,
+			MaxKeys:         s.securityConfiguration.JSONLimits.MaxKeys,
+			MaxStringLength: s.securityConfiguration.JSONLimits.MaxStringLength,
+		},
+		PersistedOperationUsage: s.persistedOpUsage,
+		Fingerprint:             s.operationFingerprint,
 	})
-	operationPlanner := NewOperationPlanner(executor, planCache)
+	operationPlanner := NewOperationPlanner(executor, planCache, s.deprecations, s.persistedOpManifest, s.fieldBlocker)
+
+	if s.memoryGuard != nil {
+		s.registerCacheShrinker(planCache, operationParser.operationCache)
+	}
 
 	authorizerOptions := &CosmoAuthorizerOptions{
 		FieldConfigurations:           engineConfig.FieldConfigurations,
 		RejectOperationIfUnauthorized: false,
+		AuditLogger:                   s.auditLogger,
 	}
 
 	if s.Config.authorization != nil {
@@ -354,7 +564,7 @@ func (s *server) buildMux(ctx context.Context,
 
 	handlerOpts := HandlerOptions{
 		Executor:                               executor,
-		Log:                                    s.logger,
+		Log:                                    muxLogger,
 		EnableExecutionPlanCacheResponseHeader: s.engineExecutionConfiguration.EnableExecutionPlanCacheResponseHeader,
 		EnablePersistedOperationCacheResponseHeader: s.engineExecutionConfiguration.Debug.EnablePersistedOperationsCacheResponseHeader,
 		WebSocketStats:           s.websocketStats,
@@ -362,59 +572,115 @@ func (s *server) buildMux(ctx context.Context,
 		Authorizer:               NewCosmoAuthorizer(authorizerOptions),
 		SubgraphErrorPropagation: s.subgraphErrorPropagation,
 		EngineLoaderHooks:        NewEngineRequestHooks(s.metricStore),
+		OperationTimeouts:        s.operationTimeouts,
+		FetchConcurrency:         s.fetchConcurrency,
+		ResponseSizeLimit:        s.responseSizeLimit,
+		RequestMemoryLimit:       s.requestMemoryLimit,
+		CBORResponses:            s.responseFormats != nil && s.responseFormats.CBOR,
+		MetricStore:              s.metricStore,
+		ETags:                    s.etags,
+		SubscriptionLimits:       s.subscriptionLimits,
+		SubscriptionReaper:       s.subscriptionReaper,
+		SubscriptionBackpressure: s.subscriptionBackpressure,
+	}
+
+	if s.surrogateKeys != nil {
+		handlerOpts.SurrogateKeys = s.surrogateKeys
+		handlerOpts.EntityKeyFields = NewEntityKeyFields(engineConfig)
 	}
 
-	if s.redisClient != nil {
+	if s.requestCoalescingConfig != nil && s.requestCoalescingConfig.Enabled {
+		// The coalescing is scoped to the graph of the mux
+		handlerOpts.RequestCoalescing = NewRequestCoalescing(&RequestCoalescingOptions{
+			ScopeHeaders: s.requestCoalescingConfig.ScopeHeaders,
+		})
+	}
+
+	if s.engineExecutionConfiguration.ResponseStreaming.Enabled {
+		handlerOpts.StreamingFlushThreshold = int(s.engineExecutionConfiguration.ResponseStreaming.FlushThreshold.Uint64())
+	}
+
+	if s.rateLimitingEnabled() {
 		handlerOpts.RateLimitConfig = s.rateLimit
 		handlerOpts.RateLimiter = NewCosmoRateLimiter(&CosmoRateLimiterOptions{
-			RedisClient: s.redisClient,
-			Debug:       s.rateLimit.Debug,
+			RedisClient:   s.redisClient,
+			Replicated:    s.replicatedRateLimits,
+			SlidingWindow: s.rateLimit.SimpleStrategy.Algorithm == RateLimitAlgorithmSlidingWindow,
+			Debug:         s.rateLimit.Debug,
 		})
+		handlerOpts.UsageQuotas = s.usageQuotas
 	}
 
 	graphqlHandler := NewGraphQLHandler(handlerOpts)
 	executor.Resolver.SetAsyncErrorWriter(graphqlHandler)
 
-	operationBlocker := NewOperationBlocker(&OperationBlockerOptions{
+	operationBlocker, err := NewOperationBlocker(&OperationBlockerOptions{
 		BlockMutations:     s.securityConfiguration.BlockMutations,
 		BlockSubscriptions: s.securityConfiguration.BlockSubscriptions,
 		BlockNonPersisted:  s.securityConfiguration.BlockNonPersistedOperations,
+		Rules:              s.securityConfiguration.OperationRules,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create operation blocker: %w", err)
+	}
+
+	introspectionGuard := NewIntrospectionGuard(&IntrospectionGuardOptions{
+		BlockUnauthenticated: s.securityConfiguration.Introspection.BlockUnauthenticated,
+		BlockUnknownClients:  s.securityConfiguration.Introspection.BlockUnknownClients,
+		KnownClients:         s.securityConfiguration.Introspection.KnownClients,
 	})
 
 	graphqlPreHandler := NewPreHandler(&PreHandlerOptions{
-		Logger:                      s.logger,
-		Executor:                    executor,
-		Metrics:                     routerMetrics,
-		OperationProcessor:          operationParser,
-		Planner:                     operationPlanner,
-		AccessController:            s.accessController,
-		OperationBlocker:            operationBlocker,
-		RouterPublicKey:             s.publicKey,
-		EnableRequestTracing:        s.engineExecutionConfiguration.EnableRequestTracing,
-		DevelopmentMode:             s.developmentMode,
-		TracerProvider:              s.tracerProvider,
-		FlushTelemetryAfterResponse: s.awsLambda,
-		TraceExportVariables:        s.traceConfig.ExportGraphQLVariables.Enabled,
-		FileUploadEnabled:           s.fileUploadConfig.Enabled,
-		MaxUploadFiles:              s.fileUploadConfig.MaxFiles,
-		MaxUploadFileSize:           int(s.fileUploadConfig.MaxFileSizeBytes),
+		Logger:                       muxLogger,
+		Executor:                     executor,
+		Metrics:                      routerMetrics,
+		OperationProcessor:           operationParser,
+		Planner:                      operationPlanner,
+		AccessController:             s.accessController,
+		OperationBlocker:             operationBlocker,
+		IntrospectionGuard:           introspectionGuard,
+		MaintenanceMode:              s.maintenanceMode,
+		PersistedOperationKillSwitch: s.persistedOpKillSwitch,
+		RouterPublicKey:              s.publicKey,
+		EnableRequestTracing:         s.engineExecutionConfiguration.EnableRequestTracing,
+		DevelopmentMode:              s.developmentMode,
+		TracerProvider:               s.tracerProvider,
+		FlushTelemetryAfterResponse:  s.awsLambda,
+		TraceExportVariables:         s.traceConfig.ExportGraphQLVariables.Enabled,
+		VariableRedactor:             s.variableRedactor,
+		SealedVariables:              s.sealedVariables,
+		FileUploadEnabled:            s.fileUploadConfig.Enabled,
+		MaxUploadFiles:               s.fileUploadConfig.MaxFiles,
+		MaxUploadFileSize:            int(s.fileUploadConfig.MaxFileSizeBytes),
+		LogEscalation:                s.logEscalationConfig,
+		LogEntryHandlers:             s.logEntryHandlers,
+		SLOTracker:                   s.sloTracker,
+		AnomalyDetector:              s.anomalyDetector,
+		RequestTagger:                s.requestTagger,
+		ClientProtocols:              s.clientProtocols,
+		TenantOverrides:              s.tenantOverrides,
+		ExecutionAudit:               s.executionAudit,
 	})
 
 	if s.webSocketConfiguration != nil && s.webSocketConfiguration.Enabled {
 		wsMiddleware := NewWebsocketMiddleware(ctx, WebsocketMiddlewareOptions{
-			OperationProcessor:         operationParser,
-			OperationBlocker:           operationBlocker,
-			Planner:                    operationPlanner,
-			GraphQLHandler:             graphqlHandler,
-			Metrics:                    routerMetrics,
-			AccessController:           s.accessController,
-			Logger:                     s.logger,
-			Stats:                      s.websocketStats,
-			ReadTimeout:                s.engineExecutionConfiguration.WebSocketReadTimeout,
-			EnableWebSocketEpollKqueue: s.engineExecutionConfiguration.EnableWebSocketEpollKqueue,
-			EpollKqueuePollTimeout:     s.engineExecutionConfiguration.EpollKqueuePollTimeout,
-			EpollKqueueConnBufferSize:  s.engineExecutionConfiguration.EpollKqueueConnBufferSize,
-			WebSocketConfiguration:     s.webSocketConfiguration,
+			OperationProcessor:           operationParser,
+			OperationBlocker:             operationBlocker,
+			PersistedOperationKillSwitch: s.persistedOpKillSwitch,
+			SealedVariables:              s.sealedVariables,
+			Planner:                      operationPlanner,
+			GraphQLHandler:               graphqlHandler,
+			Metrics:                      routerMetrics,
+			AccessController:             s.accessController,
+			Logger:                       muxLogger,
+			Stats:                        s.websocketStats,
+			ReadTimeout:                  s.engineExecutionConfiguration.WebSocketReadTimeout,
+			EnableWebSocketEpollKqueue:   s.engineExecutionConfiguration.EnableWebSocketEpollKqueue,
+			EpollKqueuePollTimeout:       s.engineExecutionConfiguration.EpollKqueuePollTimeout,
+			EpollKqueueConnBufferSize:    s.engineExecutionConfiguration.EpollKqueueConnBufferSize,
+			WebSocketConfiguration:       s.webSocketConfiguration,
+			WebSocketTransport:           s.webSocketTransport,
+			ClientProtocols:              s.clientProtocols,
 		})
 
 		// When the playground path is equal to the graphql path, we need to handle
@@ -439,6 +705,16 @@ func (s *server) buildMux(ctx context.Context,
 
 func (s *server) buildPubSubConfiguration(ctx context.Context, engineConfig *nodev1.EngineConfiguration, routerEngineCfg *RouterEngineConfiguration) error {
 
+	// The filters drop the irrelevant events of the subscriptions before they fan out to the clients
+	var eventFilter pubsub.EventFilter
+	if len(routerEngineCfg.Events.Filters) > 0 {
+		filter, err := NewEventFilter(routerEngineCfg.Events.Filters)
+		if err != nil {
+			return fmt.Errorf("failed to create the event filters: %w", err)
+		}
+		eventFilter = filter
+	}
+
 	datasourceConfigurations := engineConfig.GetDatasourceConfigurations()
 	for _, datasourceConfiguration := range datasourceConfigurations {
 		if datasourceConfiguration.CustomEvents == nil {
@@ -469,7 +745,7 @@ func (s *server) buildPubSubConfiguration(ctx context.Context, engineConfig *nod
 						return err
 					}
 
-					s.pubSubProviders.nats[providerID] = pubsubNats.NewConnector(s.logger, natsConnection, js).New(ctx)
+					s.pubSubProviders.nats[providerID] = pubsubNats.NewConnector(s.logger, natsConnection, js, eventFilter).New(ctx)
 
 					break
 				}
@@ -492,7 +768,14 @@ func (s *server) buildPubSubConfiguration(ctx context.Context, engineConfig *nod
 					if err != nil {
 						return fmt.Errorf("failed to build options for Kafka provider with ID \"%s\": %w", providerID, err)
 					}
-					ps, err := kafka.NewConnector(s.logger, options)
+					var consumerGroup string
+					if eventSource.DurableSubscriptions.Enabled {
+						if eventSource.DurableSubscriptions.ConsumerGroup == "" {
+							return fmt.Errorf("the durable subscriptions of the Kafka provider with ID \"%s\" require a consumer group", providerID)
+						}
+						consumerGroup = eventSource.DurableSubscriptions.ConsumerGroup
+					}
+					ps, err := kafka.NewConnector(s.logger, options, eventFilter, consumerGroup)
 					if err != nil {
 						return fmt.Errorf("failed to create connection for Kafka provider with ID \"%s\": %w", providerID, err)
 					}
@@ -509,17 +792,48 @@ func (s *server) buildPubSubConfiguration(ctx context.Context, engineConfig *nod
 	return nil
 }
 
+// registerCacheShrinker allows the memory guard to clear the caches of a mux under memory pressure
+func (s *server) registerCacheShrinker(planCache ExecutionPlanCache, operationCache *OperationCache) {
+	s.unregisterShrinkers = append(s.unregisterShrinkers, s.memoryGuard.RegisterShrinker(func() {
+		if c, ok := planCache.(interface{ Clear() }); ok {
+			c.Clear()
+		}
+		if operationCache != nil {
+			operationCache.clear()
+		}
+	}))
+}
+
 // Shutdown gracefully shutdown the server.
 func (s *server) Shutdown(ctx context.Context) error {
 
 	s.healthChecks.SetReady(false)
 
+	for _, unregister := range s.unregisterShrinkers {
+		unregister()
+	}
+
 	s.logger.Info("Gracefully shutting down the router ...",
 		zap.String("grace_period", s.gracePeriod.String()),
 	)
 
 	var finalErr error
 
+	if s.stopSubscriptionWebhooks != nil {
+		if err := s.stopSubscriptionWebhooks(ctx); err != nil {
+			s.logger.Error("Failed to stop subscription webhooks", zap.Error(err))
+			finalErr = errors.Join(finalErr, err)
+		}
+	}
+
+	// Stopped before the HTTP server, so that the shutdown isn't reported as a failure of the pipeline
+	if s.stopSyntheticHealth != nil {
+		if err := s.stopSyntheticHealth(ctx); err != nil {
+			s.logger.Error("Failed to stop synthetic health operation", zap.Error(err))
+			finalErr = errors.Join(finalErr, err)
+		}
+	}
+
 	if s.httpServer != nil {
 		if err := s.httpServer.Shutdown(ctx); err != nil {
 			s.logger.Error("Failed to shutdown HTTP server", zap.Error(err))
@@ -579,20 +893,6 @@ func (s *server) BaseURL() string {
 }
 
 // listenAndServe starts the server and blocks until the server is shutdown.
-func (s *server) listenAndServe() error {
-	if s.tlsConfig != nil && s.tlsConfig.Enabled {
-		// Leave the cert and key empty to use the default ones
-		if err := s.httpServer.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return err
-		}
-	} else {
-		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return err
-		}
-	}
-	return nil
-}
-
 func configureSubgraphOverwrites(
 	engineConfig *nodev1.EngineConfiguration,
 	configSubgraphs []*nodev1.Subgraph,
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+)
+
+var ErrRequestHeaderTooLarge = errors.New("request header fields too large")
+
+type ServerRejectionReason string
+
+const (
+	ServerRejectionHeaderTooLarge        ServerRejectionReason = "header_too_large"
+	ServerRejectionConnectionLimit       ServerRejectionReason = "connection_limit"
+	ServerRejectionClientConnectionLimit ServerRejectionReason = "client_connection_limit"
+)
+
+const (
+	cosmoRouterServerMeterName    = "cosmo.router.server"
+	cosmoRouterServerMeterVersion = "0.0.1"
+)
+
+type ServerLimitsOptions struct {
+	Logger *zap.Logger
+	// MaxHeaderBytes is the maximum size of the request line and the headers. Zero disables the check.
+	MaxHeaderBytes int
+	// MaxConnections is the maximum number of open connections. Zero means unlimited.
+	MaxConnections int
+	// MaxConnectionsPerIP is the maximum number of open connections per client IP. Zero means unlimited.
+	MaxConnectionsPerIP int
+}
+
+// ServerLimits enforces the limits of the HTTP server that net/http doesn't report and counts the rejections.
+// The header limit of net/http allows 4KB of slack and answers with 431 without calling a handler, so the exact
+// limit is enforced in a middleware. Requests that exceed even the limit of net/http can't be counted.
+type ServerLimits struct {
+	logger              *zap.Logger
+	maxHeaderBytes      int
+	maxConnections      int
+	maxConnectionsPerIP int
+
+	headerTooLarge        atomic.Int64
+	connectionLimit       atomic.Int64
+	clientConnectionLimit atomic.Int64
+
+	mu               sync.Mutex
+	connections      int
+	connectionsPerIP map[string]int
+	registrations    []otelmetric.Registration
+}
+
+func NewServerLimits(opts *ServerLimitsOptions) *ServerLimits {
+	return &ServerLimits{
+		logger:              opts.Logger,
+		maxHeaderBytes:      opts.MaxHeaderBytes,
+		maxConnections:      opts.MaxConnections,
+		maxConnectionsPerIP: opts.MaxConnectionsPerIP,
+		connectionsPerIP:    map[string]int{},
+	}
+}
+
+// Rejections returns the number of rejected requests or connections for the given reason
+func (l *ServerLimits) Rejections(reason ServerRejectionReason) int64 {
+	switch reason {
+	case ServerRejectionHeaderTooLarge:
+		return l.headerTooLarge.Load()
+	case ServerRejectionConnectionLimit:
+		return l.connectionLimit.Load()
+	case ServerRejectionClientConnectionLimit:
+		return l.clientConnectionLimit.Load()
+	default:
+		return 0
+	}
+}
+
+// Middleware rejects requests whose request line and headers exceed the maximum header size
+func (l *ServerLimits) Middleware(next http.Handler) http.Handler {
+	if l.maxHeaderBytes <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestHeaderSize(r) <= l.maxHeaderBytes {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		l.headerTooLarge.Add(1)
+
+		writeHTTPError(r, w, http.StatusRequestHeaderFieldsTooLarge, ErrRequestHeaderTooLarge, l.logger)
+	})
+}
+
+// requestHeaderSize approximates the size of the request line and the headers on the wire
+func requestHeaderSize(r *http.Request) int {
+	// "METHOD URI PROTO\r\n" and "Host: HOST\r\n"
+	size := len(r.Method) + len(r.RequestURI) + len(r.Proto) + 4
+	size += len("Host: ") + len(r.Host) + 2
+
+	for name, values := range r.Header {
+		for _, value := range values {
+			// "Name: Value\r\n"
+			size += len(name) + len(value) + 4
+		}
+	}
+
+	return size
+}
+
+// Listener wraps the listener to close connections that exceed the connection limits right after they are accepted
+func (l *ServerLimits) Listener(ln net.Listener) net.Listener {
+	if l.maxConnections <= 0 && l.maxConnectionsPerIP <= 0 {
+		return ln
+	}
+	return &limitListener{Listener: ln, limits: l}
+}
+
+func (l *ServerLimits) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxConnections > 0 && l.connections >= l.maxConnections {
+		l.connectionLimit.Add(1)
+		return false
+	}
+
+	if l.maxConnectionsPerIP > 0 && l.connectionsPerIP[ip] >= l.maxConnectionsPerIP {
+		l.clientConnectionLimit.Add(1)
+		return false
+	}
+
+	l.connections++
+	l.connectionsPerIP[ip]++
+
+	return true
+}
+
+func (l *ServerLimits) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.connections--
+	if l.connectionsPerIP[ip] <= 1 {
+		delete(l.connectionsPerIP, ip)
+		return
+	}
+	l.connectionsPerIP[ip]--
+}
+
+// RegisterMetrics exposes the rejections on the meter provider
+func (l *ServerLimits) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	rejections, err := meter.Int64ObservableCounter(
+		"router.http.server.rejections",
+		otelmetric.WithDescription("Number of requests and connections rejected by the limits of the HTTP server"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		for _, reason := range []ServerRejectionReason{
+			ServerRejectionHeaderTooLarge,
+			ServerRejectionConnectionLimit,
+			ServerRejectionClientConnectionLimit,
+		} {
+			// The limits are always enforced, so the series are only exported once a rejection happened
+			count := l.Rejections(reason)
+			if count == 0 {
+				continue
+			}
+			o.ObserveInt64(rejections, count, otelmetric.WithAttributes(
+				attribute.String("reason", string(reason)),
+			))
+		}
+		return nil
+	}, rejections)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.registrations = append(l.registrations, reg)
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *ServerLimits) Shutdown() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var err error
+	for _, reg := range l.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	l.registrations = nil
+
+	return err
+}
+
+type limitListener struct {
+	net.Listener
+	limits *ServerLimits
+}
+
+func (ln *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		ip := remoteIP(conn)
+		if ln.limits.acquire(ip) {
+			return &limitConn{Conn: conn, release: func() { ln.limits.release(ip) }}, nil
+		}
+
+		_ = conn.Close()
+	}
+}
+
+// limitConn releases its connection slot once. Hijacked connections, e.g. WebSockets, keep the slot until they are closed.
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
+
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestServerLimitsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	l := NewServerLimits(&ServerLimitsOptions{Logger: zap.NewNop(), MaxHeaderBytes: 512})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("Authorization", strings.Repeat("a", 512))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, rec.Code)
+	require.Contains(t, rec.Body.String(), ErrRequestHeaderTooLarge.Error())
+
+	require.Equal(t, int64(1), l.Rejections(ServerRejectionHeaderTooLarge))
+}
+
+func TestServerLimitsListener(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the listener when no connection limit is set", func(t *testing.T) {
+		t.Parallel()
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		l := NewServerLimits(&ServerLimitsOptions{Logger: zap.NewNop()})
+		require.Equal(t, ln, l.Listener(ln))
+	})
+
+	t.Run("closes connections above the limit per IP", func(t *testing.T) {
+		t.Parallel()
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		l := NewServerLimits(&ServerLimitsOptions{Logger: zap.NewNop(), MaxConnectionsPerIP: 1})
+		limited := l.Listener(ln)
+		defer limited.Close()
+
+		accepted := make(chan net.Conn, 2)
+		go func() {
+			for {
+				conn, err := limited.Accept()
+				if err != nil {
+					return
+				}
+				accepted <- conn
+			}
+		}()
+
+		first, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer first.Close()
+
+		serverConn := <-accepted
+
+		// The second connection is closed by the listener right after it is accepted
+		second, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer second.Close()
+
+		require.NoError(t, second.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, err = second.Read(make([]byte, 1))
+		require.Error(t, err)
+		require.Equal(t, int64(1), l.Rejections(ServerRejectionClientConnectionLimit))
+
+		// Closing the first connection frees the slot for the next one
+		require.NoError(t, serverConn.Close())
+
+		third, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		defer third.Close()
+
+		select {
+		case conn := <-accepted:
+			require.NoError(t, conn.Close())
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection was not accepted after the slot was released")
+		}
+		require.Equal(t, int64(1), l.Rejections(ServerRejectionClientConnectionLimit))
+	})
+}
@@ -0,0 +1,355 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+const (
+	// sloOtherClientName is the client name of the requests of clients above the limit of MaxClients
+	sloOtherClientName = "other"
+	// sloMaxBuckets limits the memory of a series, the buckets get wider for long windows
+	sloMaxBuckets = 1000
+	sloWindowKey  = attribute.Key("slo.window")
+)
+
+type SLOTrackerOptions struct {
+	// AvailabilityTarget is the ratio of requests that must not fail, e.g. 0.999
+	AvailabilityTarget float64
+	// LatencyTarget is the ratio of requests that must be faster than the LatencyThreshold, e.g. 0.99
+	LatencyTarget    float64
+	LatencyThreshold time.Duration
+	// Windows are the periods the SLIs and burn rates are computed for
+	Windows []time.Duration
+	// MaxClients limits the number of clients with their own series per graph. Zero only tracks the graphs.
+	MaxClients int
+}
+
+// SLOTracker computes the availability and latency SLIs of the requests over rolling windows, per graph and per client.
+// The burn rates, i.e. how fast the error budget is consumed, are exported as gauges, so that alerts can be
+// defined directly on the metrics of the router. A burn rate of 1 consumes the budget exactly within the SLO period.
+type SLOTracker struct {
+	availabilityTarget float64
+	latencyTarget      float64
+	latencyThreshold   time.Duration
+	windows            []time.Duration
+	maxClients         int
+
+	bucketWidth time.Duration
+	bucketCount int
+
+	// now returns the current time. It can be replaced in tests.
+	now func() time.Time
+
+	mu            sync.Mutex
+	graphs        map[attribute.Distinct]*sloGraph
+	registrations []otelmetric.Registration
+}
+
+type sloGraph struct {
+	attributes attribute.Set
+	all        *sloSeries
+	clients    map[string]*sloSeries
+}
+
+type sloBucket struct {
+	index  int64
+	total  int64
+	failed int64
+	slow   int64
+}
+
+// sloSeries is a ring of time buckets. A bucket is reused when its index is outdated.
+type sloSeries struct {
+	buckets []sloBucket
+}
+
+// SLIs are the ratios of good requests of a window
+type SLIs struct {
+	Requests     int64
+	Availability float64
+	Latency      float64
+}
+
+func NewSLOTracker(opts *SLOTrackerOptions) (*SLOTracker, error) {
+	if opts.AvailabilityTarget <= 0 || opts.AvailabilityTarget >= 1 {
+		return nil, fmt.Errorf("slo availability target must be between 0 and 1, got %v", opts.AvailabilityTarget)
+	}
+	if opts.LatencyTarget <= 0 || opts.LatencyTarget >= 1 {
+		return nil, fmt.Errorf("slo latency target must be between 0 and 1, got %v", opts.LatencyTarget)
+	}
+	if opts.LatencyThreshold <= 0 {
+		return nil, errors.New("slo latency threshold must be greater than 0")
+	}
+	if len(opts.Windows) == 0 {
+		return nil, errors.New("slo requires at least one window")
+	}
+	for _, window := range opts.Windows {
+		if window <= 0 {
+			return nil, fmt.Errorf("slo window must be greater than 0, got %s", window)
+		}
+	}
+
+	windows := slices.Clone(opts.Windows)
+	slices.Sort(windows)
+	windows = slices.Compact(windows)
+
+	// A window spans at least 10 buckets, so that it doesn't jump when the oldest bucket drops out
+	bucketWidth := max(windows[0]/10, windows[len(windows)-1]/sloMaxBuckets, time.Millisecond)
+
+	return &SLOTracker{
+		availabilityTarget: opts.AvailabilityTarget,
+		latencyTarget:      opts.LatencyTarget,
+		latencyThreshold:   opts.LatencyThreshold,
+		windows:            windows,
+		maxClients:         max(opts.MaxClients, 0),
+		bucketWidth:        bucketWidth,
+		bucketCount:        int((windows[len(windows)-1]+bucketWidth-1)/bucketWidth) + 1,
+		now:                time.Now,
+		graphs:             map[attribute.Distinct]*sloGraph{},
+	}, nil
+}
+
+// Record adds a request of the graph identified by the attributes. Failed requests count against the availability,
+// requests slower than the latency threshold against the latency.
+func (t *SLOTracker) Record(graphAttributes []attribute.KeyValue, clientName string, failed bool, latency time.Duration) {
+	set := attribute.NewSet(graphAttributes...)
+	index := t.now().UnixNano() / int64(t.bucketWidth)
+	slow := latency > t.latencyThreshold
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	graph, ok := t.graphs[set.Equivalent()]
+	if !ok {
+		graph = &sloGraph{
+			attributes: set,
+			all:        t.newSeries(),
+			clients:    map[string]*sloSeries{},
+		}
+		t.graphs[set.Equivalent()] = graph
+	}
+
+	graph.all.add(index, failed, slow)
+
+	if t.maxClients == 0 {
+		return
+	}
+
+	client, ok := graph.clients[clientName]
+	if !ok {
+		if len(graph.clients) >= t.maxClients {
+			clientName = sloOtherClientName
+			client, ok = graph.clients[clientName]
+		}
+		if !ok {
+			client = t.newSeries()
+			graph.clients[clientName] = client
+		}
+	}
+
+	client.add(index, failed, slow)
+}
+
+// SLIs returns the SLIs of the graph in the window. Zero requests are returned when nothing was recorded.
+func (t *SLOTracker) SLIs(graphAttributes []attribute.KeyValue, clientName string, window time.Duration) SLIs {
+	set := attribute.NewSet(graphAttributes...)
+	index := t.now().UnixNano() / int64(t.bucketWidth)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	graph, ok := t.graphs[set.Equivalent()]
+	if !ok {
+		return SLIs{}
+	}
+
+	series := graph.all
+	if clientName != "" {
+		if series, ok = graph.clients[clientName]; !ok {
+			return SLIs{}
+		}
+	}
+
+	return series.slis(index, t.windowBuckets(window))
+}
+
+// BurnRate returns the rate the error budget of the SLO with the target is consumed at
+func BurnRate(sli, target float64) float64 {
+	return (1 - sli) / (1 - target)
+}
+
+// RegisterMetrics exposes the SLIs and burn rates of all windows on the meter provider
+func (t *SLOTracker) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	availability, err := meter.Float64ObservableGauge(
+		"router.slo.availability.sli",
+		otelmetric.WithDescription("Ratio of requests that did not fail in the window"),
+	)
+	if err != nil {
+		return err
+	}
+	availabilityBurnRate, err := meter.Float64ObservableGauge(
+		"router.slo.availability.burn_rate",
+		otelmetric.WithDescription("Rate the error budget of the availability SLO is consumed at in the window"),
+	)
+	if err != nil {
+		return err
+	}
+	latency, err := meter.Float64ObservableGauge(
+		"router.slo.latency.sli",
+		otelmetric.WithDescription("Ratio of requests that were faster than the latency threshold in the window"),
+	)
+	if err != nil {
+		return err
+	}
+	latencyBurnRate, err := meter.Float64ObservableGauge(
+		"router.slo.latency.burn_rate",
+		otelmetric.WithDescription("Rate the error budget of the latency SLO is consumed at in the window"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		index := t.now().UnixNano() / int64(t.bucketWidth)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		observe := func(series *sloSeries, attributes []attribute.KeyValue) {
+			for _, window := range t.windows {
+				slis := series.slis(index, t.windowBuckets(window))
+				// Nothing to report without requests, a ratio of zero would be an outage
+				if slis.Requests == 0 {
+					continue
+				}
+
+				opt := otelmetric.WithAttributes(append(attributes, sloWindowKey.String(window.String()))...)
+				o.ObserveFloat64(availability, slis.Availability, opt)
+				o.ObserveFloat64(availabilityBurnRate, BurnRate(slis.Availability, t.availabilityTarget), opt)
+				o.ObserveFloat64(latency, slis.Latency, opt)
+				o.ObserveFloat64(latencyBurnRate, BurnRate(slis.Latency, t.latencyTarget), opt)
+			}
+		}
+
+		for _, graph := range t.graphs {
+			attributes := graph.attributes.ToSlice()
+			observe(graph.all, attributes)
+
+			for clientName, client := range graph.clients {
+				observe(client, append(attributes[:len(attributes):len(attributes)], otel.WgClientName.String(clientName)))
+			}
+		}
+
+		return nil
+	}, availability, availabilityBurnRate, latency, latencyBurnRate)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.registrations = append(t.registrations, reg)
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *SLOTracker) Shutdown() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var err error
+	for _, reg := range t.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	t.registrations = nil
+
+	return err
+}
+
+func (t *SLOTracker) newSeries() *sloSeries {
+	return &sloSeries{buckets: make([]sloBucket, t.bucketCount)}
+}
+
+func (t *SLOTracker) windowBuckets(window time.Duration) int64 {
+	return int64((window + t.bucketWidth - 1) / t.bucketWidth)
+}
+
+func (s *sloSeries) add(index int64, failed, slow bool) {
+	b := &s.buckets[index%int64(len(s.buckets))]
+	if b.index != index {
+		*b = sloBucket{index: index}
+	}
+
+	b.total++
+	if failed {
+		b.failed++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+// slis sums the buckets of the last n indexes up to the current one
+func (s *sloSeries) slis(index, n int64) SLIs {
+	var total, failed, slow int64
+	for _, b := range s.buckets {
+		if b.index > index-n && b.index <= index {
+			total += b.total
+			failed += b.failed
+			slow += b.slow
+		}
+	}
+
+	if total == 0 {
+		return SLIs{}
+	}
+
+	return SLIs{
+		Requests:     total,
+		Availability: 1 - float64(failed)/float64(total),
+		Latency:      1 - float64(slow)/float64(total),
+	}
+}
+
+// sloRequestFailed reports whether the request counts against the availability. Errors caused by the client,
+// e.g. invalid operations, exceeded rate limits or missing authorization, don't.
+func sloRequestFailed(err error, statusCode int) bool {
+	if statusCode >= http.StatusInternalServerError {
+		return true
+	}
+	if err == nil {
+		return false
+	}
+
+	var inputErr InputError
+	if errors.As(err, &inputErr) {
+		return inputErr.StatusCode() >= http.StatusInternalServerError
+	}
+	var reportErr ReportError
+	if errors.As(err, &reportErr) {
+		return reportErr.Report() != nil && len(reportErr.Report().InternalErrors) > 0
+	}
+
+	switch getErrorType(err) {
+	case errorTypeRateLimit, errorTypeUnauthorized, errorTypeContextCanceled:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func newTestSLOTracker(t *testing.T, now *time.Time, maxClients int) *SLOTracker {
+	t.Helper()
+
+	tracker, err := NewSLOTracker(&SLOTrackerOptions{
+		AvailabilityTarget: 0.9,
+		LatencyTarget:      0.8,
+		LatencyThreshold:   100 * time.Millisecond,
+		Windows:            []time.Duration{time.Minute, 10 * time.Minute},
+		MaxClients:         maxClients,
+	})
+	require.NoError(t, err)
+
+	tracker.now = func() time.Time { return *now }
+
+	return tracker
+}
+
+func TestSLOTracker(t *testing.T) {
+	t.Parallel()
+
+	graph := []attribute.KeyValue{otel.WgFederatedGraphID.String("graph")}
+
+	t.Run("validates the options", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewSLOTracker(&SLOTrackerOptions{AvailabilityTarget: 1, LatencyTarget: 0.9, LatencyThreshold: time.Second, Windows: []time.Duration{time.Minute}})
+		require.Error(t, err)
+		_, err = NewSLOTracker(&SLOTrackerOptions{AvailabilityTarget: 0.9, LatencyTarget: 0.9, LatencyThreshold: time.Second})
+		require.Error(t, err)
+		_, err = NewSLOTracker(&SLOTrackerOptions{AvailabilityTarget: 0.9, LatencyTarget: 0.9, Windows: []time.Duration{time.Minute}})
+		require.Error(t, err)
+	})
+
+	t.Run("computes the SLIs per window", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Unix(1_700_000_000, 0)
+		tracker := newTestSLOTracker(t, &now, 10)
+
+		for i := 0; i < 8; i++ {
+			tracker.Record(graph, "web", false, 10*time.Millisecond)
+		}
+		tracker.Record(graph, "web", true, 10*time.Millisecond)
+		tracker.Record(graph, "web", false, time.Second)
+
+		slis := tracker.SLIs(graph, "", time.Minute)
+		require.Equal(t, int64(10), slis.Requests)
+		require.InDelta(t, 0.9, slis.Availability, 0.0001)
+		require.InDelta(t, 0.9, slis.Latency, 0.0001)
+
+		// The requests are outside the short window, but still inside the long one
+		now = now.Add(2 * time.Minute)
+		tracker.Record(graph, "web", false, 10*time.Millisecond)
+
+		slis = tracker.SLIs(graph, "", time.Minute)
+		require.Equal(t, int64(1), slis.Requests)
+		require.Equal(t, 1.0, slis.Availability)
+
+		slis = tracker.SLIs(graph, "web", 10*time.Minute)
+		require.Equal(t, int64(11), slis.Requests)
+
+		now = now.Add(time.Hour)
+		require.Equal(t, SLIs{}, tracker.SLIs(graph, "", 10*time.Minute))
+	})
+
+	t.Run("aggregates clients above the limit", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Unix(1_700_000_000, 0)
+		tracker := newTestSLOTracker(t, &now, 1)
+
+		tracker.Record(graph, "web", false, 0)
+		tracker.Record(graph, "ios", false, 0)
+		tracker.Record(graph, "android", true, 0)
+
+		require.Equal(t, int64(1), tracker.SLIs(graph, "web", time.Minute).Requests)
+		require.Equal(t, int64(0), tracker.SLIs(graph, "ios", time.Minute).Requests)
+		require.Equal(t, int64(2), tracker.SLIs(graph, sloOtherClientName, time.Minute).Requests)
+		require.Equal(t, int64(3), tracker.SLIs(graph, "", time.Minute).Requests)
+	})
+
+	t.Run("exports the burn rates", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		tracker := newTestSLOTracker(t, &now, 10)
+
+		reader := sdkmetric.NewManualReader()
+		meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		require.NoError(t, tracker.RegisterMetrics(meterProvider))
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		require.Empty(t, rm.ScopeMetrics)
+
+		// 20% failed requests with a budget of 10% burn at twice the rate
+		for i := 0; i < 4; i++ {
+			tracker.Record(graph, "web", false, 0)
+		}
+		tracker.Record(graph, "web", true, 0)
+
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+
+		var burnRate metricdata.Gauge[float64]
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name == "router.slo.availability.burn_rate" {
+				burnRate = m.Data.(metricdata.Gauge[float64])
+			}
+		}
+		// Both windows for the graph and the client
+		require.Len(t, burnRate.DataPoints, 4)
+		for _, dp := range burnRate.DataPoints {
+			require.InDelta(t, 2.0, dp.Value, 0.0001)
+			_, ok := dp.Attributes.Value(sloWindowKey)
+			require.True(t, ok)
+		}
+
+		require.NoError(t, tracker.Shutdown())
+	})
+}
+
+func TestSLORequestFailed(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, sloRequestFailed(nil, http.StatusOK))
+	require.True(t, sloRequestFailed(nil, http.StatusBadGateway))
+	require.False(t, sloRequestFailed(&inputError{message: "invalid", statusCode: http.StatusBadRequest}, http.StatusBadRequest))
+	require.False(t, sloRequestFailed(ErrRateLimitExceeded, http.StatusOK))
+	require.False(t, sloRequestFailed(context.Canceled, http.StatusOK))
+	require.True(t, sloRequestFailed(errors.New("subgraph unavailable"), http.StatusOK))
+}
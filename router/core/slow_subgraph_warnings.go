@@ -0,0 +1,146 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// slowSubgraphBaselinePercentile is the percentile of the latencies of a subgraph that is its baseline
+const slowSubgraphBaselinePercentile = 0.95
+
+// SlowSubgraphWarnings logs a warning when the latency of a subgraph request exceeds the p95 latency of the latest
+// requests of the subgraph by a factor. The warnings of a subgraph are throttled, the slow requests in between are
+// counted and reported with the next warning. Failed requests are neither part of the baseline nor warned about.
+type SlowSubgraphWarnings struct {
+	logger      *zap.Logger
+	factor      float64
+	windowSize  int
+	minSamples  int
+	logInterval time.Duration
+	now         func() time.Time
+
+	mu        sync.Mutex
+	subgraphs map[string]*subgraphLatencyBaseline
+}
+
+type subgraphLatencyBaseline struct {
+	// latencies are the latencies of the latest requests, the oldest one is replaced next
+	latencies []time.Duration
+	next      int
+	count     int
+	// p95 is computed again after every tenth of the window
+	p95 time.Duration
+
+	lastWarned time.Time
+	// suppressed are the slow requests since the last warning that weren't logged
+	suppressed int
+}
+
+func NewSlowSubgraphWarnings(logger *zap.Logger, cfg *config.SlowSubgraphWarningsConfiguration) (*SlowSubgraphWarnings, error) {
+	if cfg.Factor <= 1 {
+		return nil, fmt.Errorf("the slow subgraph factor must be greater than 1, got %v", cfg.Factor)
+	}
+	if cfg.WindowSize < 1 {
+		return nil, fmt.Errorf("the slow subgraph window size must be at least 1, got %d", cfg.WindowSize)
+	}
+	if cfg.MinSamples < 1 || cfg.MinSamples > cfg.WindowSize {
+		return nil, fmt.Errorf("the slow subgraph minimum samples must be between 1 and the window size %d, got %d", cfg.WindowSize, cfg.MinSamples)
+	}
+	if cfg.LogInterval < 0 {
+		return nil, fmt.Errorf("the slow subgraph log interval must not be negative, got %s", cfg.LogInterval)
+	}
+
+	return &SlowSubgraphWarnings{
+		logger:      logger,
+		factor:      cfg.Factor,
+		windowSize:  cfg.WindowSize,
+		minSamples:  cfg.MinSamples,
+		logInterval: cfg.LogInterval,
+		now:         time.Now,
+		subgraphs:   map[string]*subgraphLatencyBaseline{},
+	}, nil
+}
+
+// observe records the latency of a successful request to the subgraph and warns if it's slow
+func (s *SlowSubgraphWarnings) observe(subgraph string, latency time.Duration, operationName string) {
+	s.mu.Lock()
+	b, ok := s.subgraphs[subgraph]
+	if !ok {
+		b = &subgraphLatencyBaseline{latencies: make([]time.Duration, 0, s.windowSize)}
+		s.subgraphs[subgraph] = b
+	}
+
+	baseline := b.p95
+	slow := b.count >= s.minSamples && baseline > 0 && float64(latency) > float64(baseline)*s.factor
+
+	if len(b.latencies) < s.windowSize {
+		b.latencies = append(b.latencies, latency)
+	} else {
+		b.latencies[b.next] = latency
+		b.next = (b.next + 1) % s.windowSize
+	}
+	b.count++
+	if b.count == s.minSamples || (b.count > s.minSamples && b.count%max(s.windowSize/10, 1) == 0) {
+		b.p95 = latencyPercentile(b.latencies, slowSubgraphBaselinePercentile)
+	}
+
+	if !slow {
+		s.mu.Unlock()
+		return
+	}
+
+	now := s.now()
+	if !b.lastWarned.IsZero() && now.Sub(b.lastWarned) < s.logInterval {
+		b.suppressed++
+		s.mu.Unlock()
+		return
+	}
+	suppressed := b.suppressed
+	b.lastWarned = now
+	b.suppressed = 0
+	s.mu.Unlock()
+
+	fields := []zap.Field{
+		zap.String("subgraph_name", subgraph),
+		zap.Duration("latency", latency),
+		zap.Duration("baseline_p95", baseline),
+		zap.Float64("factor", s.factor),
+		zap.Int("suppressed_warnings", suppressed),
+	}
+	if operationName != "" {
+		fields = append(fields, zap.String("operation_name", operationName))
+	}
+	s.logger.Warn("Subgraph request is slower than the baseline latency of the subgraph", fields...)
+}
+
+// Baseline returns the p95 latency of the subgraph, zero until it has the minimum samples
+func (s *SlowSubgraphWarnings) Baseline(subgraph string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.subgraphs[subgraph]; ok {
+		return b.p95
+	}
+	return 0
+}
+
+// latencyPercentile returns the nearest-rank percentile of the latencies
+func latencyPercentile(latencies []time.Duration, percentile float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(percentile*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
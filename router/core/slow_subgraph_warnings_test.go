@@ -0,0 +1,96 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSlowSubgraphWarnings(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.WarnLevel)
+	s, err := NewSlowSubgraphWarnings(zap.New(logCore), &config.SlowSubgraphWarningsConfiguration{
+		Factor:      2,
+		WindowSize:  100,
+		MinSamples:  20,
+		LogInterval: time.Minute,
+	})
+	require.NoError(t, err)
+	now := time.Unix(1_000_000, 0)
+	s.now = func() time.Time { return now }
+
+	// No warnings until the baseline has the minimum samples
+	s.observe("employees", time.Second, "")
+	for i := 1; i < 20; i++ {
+		s.observe("employees", time.Duration(i)*time.Millisecond, "")
+	}
+	require.Zero(t, logs.Len())
+	// The p95 of 1s and 1ms to 19ms
+	require.Equal(t, 19*time.Millisecond, s.Baseline("employees"))
+	require.Zero(t, s.Baseline("products"))
+
+	s.observe("employees", 38*time.Millisecond, "")
+	require.Zero(t, logs.Len())
+
+	s.observe("employees", 39*time.Millisecond, "Employees")
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	require.Equal(t, "employees", fields["subgraph_name"])
+	require.Equal(t, "Employees", fields["operation_name"])
+	require.Equal(t, 39*time.Millisecond, fields["latency"])
+	require.Equal(t, 19*time.Millisecond, fields["baseline_p95"])
+	require.Equal(t, int64(0), fields["suppressed_warnings"])
+
+	// The warnings are throttled and the suppressed ones are counted
+	s.observe("employees", time.Second, "")
+	s.observe("employees", time.Second, "")
+	require.Equal(t, 1, logs.Len())
+
+	now = now.Add(time.Minute)
+	s.observe("employees", time.Second, "")
+	require.Equal(t, 2, logs.Len())
+	require.Equal(t, int64(2), logs.All()[1].ContextMap()["suppressed_warnings"])
+
+	// Every subgraph has its own baseline
+	for i := 0; i < 20; i++ {
+		s.observe("products", time.Second, "")
+	}
+	require.Equal(t, time.Second, s.Baseline("products"))
+	require.Equal(t, 2, logs.Len())
+}
+
+func TestSlowSubgraphWarningsConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range []config.SlowSubgraphWarningsConfiguration{
+		{Factor: 1, WindowSize: 10, MinSamples: 1},
+		{Factor: 2, WindowSize: 0, MinSamples: 1},
+		{Factor: 2, WindowSize: 10, MinSamples: 11},
+		{Factor: 2, WindowSize: 10, MinSamples: 1, LogInterval: -time.Second},
+	} {
+		_, err := NewSlowSubgraphWarnings(zap.NewNop(), &cfg)
+		require.Error(t, err)
+	}
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	t.Parallel()
+
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, 95*time.Millisecond, latencyPercentile(latencies, 0.95))
+	require.Equal(t, 100*time.Millisecond, latencyPercentile(latencies, 1))
+	require.Equal(t, time.Millisecond, latencyPercentile(latencies[99:], 0.95))
+	require.Zero(t, latencyPercentile(nil, 0.95))
+	// The latencies aren't reordered
+	require.Equal(t, 100*time.Millisecond, latencies[0])
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"go.uber.org/zap"
+)
+
+// StartupReport summarizes the resolved router configuration on boot. It must never contain secrets.
+// For secrets, only the information whether they are set is reported.
+type StartupReport struct {
+	RouterVersion string                 `json:"router_version"`
+	InstanceID    string                 `json:"instance_id"`
+	ClusterName   string                 `json:"cluster_name,omitempty"`
+	ConfigVersion string                 `json:"config_version"`
+	StartedAt     time.Time              `json:"started_at"`
+	Listeners     StartupReportListeners `json:"listeners"`
+	Features      StartupReportFeatures  `json:"features"`
+	Subgraphs     int                    `json:"subgraphs"`
+	FeatureFlags  int                    `json:"feature_flags"`
+	Modules       []string               `json:"modules"`
+	Telemetry     StartupReportTelemetry `json:"telemetry"`
+	Secrets       map[string]string      `json:"secrets"`
+}
+
+type StartupReportListeners struct {
+	GraphQL    string `json:"graphql"`
+	Prometheus string `json:"prometheus,omitempty"`
+	Admin      string `json:"admin,omitempty"`
+}
+
+type StartupReportFeatures struct {
+	Playground        bool `json:"playground"`
+	Introspection     bool `json:"introspection"`
+	DevelopmentMode   bool `json:"development_mode"`
+	TLS               bool `json:"tls"`
+	TLSClientAuth     bool `json:"tls_client_auth"`
+	Authentication    bool `json:"authentication"`
+	AuthRequired      bool `json:"authentication_required"`
+	RateLimit         bool `json:"rate_limit"`
+	FileUpload        bool `json:"file_upload"`
+	WebSocket         bool `json:"websocket"`
+	RequestTracing    bool `json:"request_tracing"`
+	MaintenanceMode   bool `json:"maintenance_mode"`
+	PersistedOpsCache bool `json:"persisted_operations_cache"`
+	EventProviders    int  `json:"event_providers"`
+	MemorySoftLimit   bool `json:"memory_soft_limit"`
+}
+
+type StartupReportTelemetry struct {
+	Tracing         bool     `json:"tracing"`
+	TraceExporters  []string `json:"trace_exporters"`
+	Metrics         bool     `json:"metrics"`
+	MetricExporters []string `json:"metric_exporters"`
+	Prometheus      bool     `json:"prometheus"`
+	SchemaUsage     bool     `json:"schema_usage"`
+	Profiling       bool     `json:"profiling"`
+}
+
+const (
+	startupReportSecretSet   = "set"
+	startupReportSecretUnset = "unset"
+)
+
+func secretState(secret string) string {
+	if secret == "" {
+		return startupReportSecretUnset
+	}
+	return startupReportSecretSet
+}
+
+// redactEndpoint removes credentials from the endpoint so that it can be reported safely
+func redactEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "invalid"
+	}
+	u.RawQuery = ""
+	return u.Redacted()
+}
+
+func (r *Router) newStartupReport(routerConfig *nodev1.RouterConfig) *StartupReport {
+	report := &StartupReport{
+		RouterVersion: Version,
+		InstanceID:    r.instanceID,
+		ClusterName:   r.clusterName,
+		ConfigVersion: routerConfig.GetVersion(),
+		StartedAt:     r.processStartTime,
+		Listeners: StartupReportListeners{
+			GraphQL: r.listenAddr,
+		},
+		Subgraphs:    len(routerConfig.GetSubgraphs()),
+		FeatureFlags: len(routerConfig.GetFeatureFlagConfigs().GetConfigByFeatureFlagName()),
+		Modules:      make([]string, 0, len(r.modules)),
+		Features:     r.enabledFeatures(),
+		Telemetry: StartupReportTelemetry{
+			Tracing:         r.traceConfig.Enabled,
+			TraceExporters:  []string{},
+			Metrics:         r.metricConfig.IsEnabled(),
+			MetricExporters: []string{},
+			Prometheus:      r.metricConfig.Prometheus.Enabled,
+			SchemaUsage:     r.gqlMetricsExporter != nil,
+			Profiling:       r.profiler != nil,
+		},
+		Secrets: map[string]string{
+			"graph_api_token": secretState(r.graphApiToken),
+		},
+	}
+
+	for _, module := range r.modules {
+		report.Modules = append(report.Modules, string(module.Module().ID))
+	}
+
+	if r.metricConfig.Prometheus.Enabled {
+		report.Listeners.Prometheus = r.metricConfig.Prometheus.ListenAddr
+	}
+
+	if r.adminConfig.Enabled {
+		report.Listeners.Admin = r.adminConfig.ListenAddr
+		report.Secrets["admin_token"] = secretState(r.adminConfig.Token)
+	}
+
+	if r.rateLimit != nil && r.rateLimit.Enabled {
+		report.Secrets["rate_limit_redis_url"] = secretState(r.rateLimit.Storage.Url)
+	}
+
+	if report.Features.TLS {
+		report.Secrets["tls_key_file"] = secretState(r.tlsConfig.KeyFile)
+	}
+
+	if r.traceConfig.Enabled {
+		for _, exporter := range r.traceConfig.Exporters {
+			if exporter.Disabled {
+				continue
+			}
+			report.Telemetry.TraceExporters = append(report.Telemetry.TraceExporters, redactEndpoint(exporter.Endpoint))
+		}
+	}
+
+	if r.metricConfig.OpenTelemetry.Enabled {
+		for _, exporter := range r.metricConfig.OpenTelemetry.Exporters {
+			if exporter.Disabled {
+				continue
+			}
+			report.Telemetry.MetricExporters = append(report.Telemetry.MetricExporters, redactEndpoint(exporter.Endpoint))
+		}
+	}
+
+	return report
+}
+
+// logStartupReport logs the report as a single entry and writes it to the configured file
+func (r *Router) logStartupReport(routerConfig *nodev1.RouterConfig) {
+	if !r.startupReportConfig.Enabled {
+		return
+	}
+
+	report := r.newStartupReport(routerConfig)
+
+	r.logger.Info("Startup report", zap.Any("report", report))
+
+	if r.startupReportConfig.FilePath == "" {
+		return
+	}
+
+	if err := writeStartupReport(r.startupReportConfig.FilePath, report); err != nil {
+		r.logger.Warn("Failed to write startup report", zap.String("path", r.startupReportConfig.FilePath), zap.Error(err))
+	}
+}
+
+func writeStartupReport(path string, report *StartupReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// enabledFeatures returns which of the router features are enabled
+func (r *Router) enabledFeatures() StartupReportFeatures {
+	return StartupReportFeatures{
+		Playground:        r.playground,
+		Introspection:     r.introspection,
+		DevelopmentMode:   r.developmentMode,
+		TLS:               r.tlsConfig != nil && r.tlsConfig.Enabled,
+		TLSClientAuth:     r.tlsConfig != nil && r.tlsConfig.Enabled && r.tlsConfig.ClientAuth != nil && r.tlsConfig.ClientAuth.CertFile != "",
+		Authentication:    len(r.accessController.authenticators) > 0,
+		AuthRequired:      r.accessController.authenticationRequired,
+		RateLimit:         r.rateLimit != nil && r.rateLimit.Enabled,
+		FileUpload:        r.fileUploadConfig != nil && r.fileUploadConfig.Enabled,
+		WebSocket:         r.webSocketConfiguration != nil && r.webSocketConfiguration.Enabled,
+		RequestTracing:    r.engineExecutionConfiguration.EnableRequestTracing,
+		MaintenanceMode:   r.maintenanceMode.Enabled(),
+		PersistedOpsCache: r.engineExecutionConfiguration.EnablePersistedOperationsCache,
+		EventProviders:    len(r.eventsConfig.Providers.Nats) + len(r.eventsConfig.Providers.Kafka),
+		MemorySoftLimit:   r.memoryGuard != nil,
+	}
+}
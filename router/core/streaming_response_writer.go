@@ -0,0 +1,75 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+// streamingResponseWriter buffers the response until the flush threshold is reached. From then on, the buffered data
+// is written and flushed to the client every time the threshold is reached again. This way the response is never held
+// in memory entirely. Responses smaller than the threshold are written at once by the caller and keep their Content-Length.
+type streamingResponseWriter struct {
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	buf       *bytes.Buffer
+	threshold int
+	written   int64
+	err       error
+}
+
+func newStreamingResponseWriter(w http.ResponseWriter, buf *bytes.Buffer, threshold int) *streamingResponseWriter {
+	return &streamingResponseWriter{
+		w:         w,
+		rc:        http.NewResponseController(w),
+		buf:       buf,
+		threshold: threshold,
+	}
+}
+
+func (s *streamingResponseWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	n, _ := s.buf.Write(p)
+
+	if s.threshold > 0 && s.buf.Len() >= s.threshold {
+		if err := s.flush(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// streaming returns true when data has been written to the client. The status code and the headers can't be changed anymore.
+func (s *streamingResponseWriter) streaming() bool {
+	return s.written > 0
+}
+
+func (s *streamingResponseWriter) flush() error {
+	n, err := s.buf.WriteTo(s.w)
+	s.written += n
+	if err != nil {
+		s.err = err
+		return err
+	}
+
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.err = err
+		return err
+	}
+
+	return nil
+}
+
+// Close writes the remaining buffered data to the client
+func (s *streamingResponseWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	n, err := s.buf.WriteTo(s.w)
+	s.written += n
+	return err
+}
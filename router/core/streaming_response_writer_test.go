@@ -0,0 +1,55 @@
+package core
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamingResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("buffers responses below the threshold", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		s := newStreamingResponseWriter(rec, &bytes.Buffer{}, 10)
+
+		_, err := s.Write([]byte("12345"))
+		require.NoError(t, err)
+		require.False(t, s.streaming())
+		require.Zero(t, rec.Body.Len())
+
+		require.NoError(t, s.Close())
+		require.Equal(t, "12345", rec.Body.String())
+		require.False(t, rec.Flushed)
+	})
+
+	t.Run("flushes every time the threshold is reached", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		buf := &bytes.Buffer{}
+		s := newStreamingResponseWriter(rec, buf, 4)
+
+		_, err := s.Write([]byte("123"))
+		require.NoError(t, err)
+		require.False(t, s.streaming())
+
+		_, err = s.Write([]byte("45"))
+		require.NoError(t, err)
+		require.True(t, s.streaming())
+		require.True(t, rec.Flushed)
+		require.Equal(t, "12345", rec.Body.String())
+		require.Zero(t, buf.Len())
+
+		_, err = s.Write([]byte("67"))
+		require.NoError(t, err)
+		require.Equal(t, "12345", rec.Body.String())
+
+		require.NoError(t, s.Close())
+		require.Equal(t, "1234567", rec.Body.String())
+	})
+}
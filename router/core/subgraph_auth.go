@@ -0,0 +1,252 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"go.uber.org/zap"
+)
+
+const (
+	SubgraphCredentialsTypeBearer                  = "bearer"
+	SubgraphCredentialsTypeBasic                   = "basic"
+	SubgraphCredentialsTypeOAuth2ClientCredentials = "oauth2_client_credentials"
+
+	// defaultOAuth2TokenLifetime is used when the token endpoint doesn't return expires_in
+	defaultOAuth2TokenLifetime = time.Hour
+	// maxOAuth2RefreshMargin is the longest time before the expiry a token is refreshed
+	maxOAuth2RefreshMargin    = time.Minute
+	oauth2TokenRequestTimeout = 10 * time.Second
+)
+
+var _ EnginePreOriginHandler = (*SubgraphAuthenticator)(nil)
+
+// subgraphCredentials returns the value of the Authorization header sent to a subgraph
+type subgraphCredentials interface {
+	authorization() (string, error)
+}
+
+// SubgraphAuthenticator is a pre-origin handler that sets the Authorization header of the requests to the subgraphs
+// with configured credentials. The credentials replace the header propagated from the client request.
+type SubgraphAuthenticator struct {
+	logger      *zap.Logger
+	credentials map[string]subgraphCredentials
+}
+
+func NewSubgraphAuthenticator(logger *zap.Logger, cfg config.SubgraphAuthenticationConfiguration) (*SubgraphAuthenticator, error) {
+	a := &SubgraphAuthenticator{
+		logger:      logger,
+		credentials: make(map[string]subgraphCredentials, len(cfg.Subgraphs)),
+	}
+
+	for name, c := range cfg.Subgraphs {
+		credentials, err := newSubgraphCredentials(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credentials for subgraph '%s': %w", name, err)
+		}
+		a.credentials[name] = credentials
+	}
+
+	return a, nil
+}
+
+func (a *SubgraphAuthenticator) OnOriginRequest(request *http.Request, ctx RequestContext) (*http.Request, *http.Response) {
+	subgraph := ctx.ActiveSubgraph(request)
+	if subgraph == nil {
+		return request, nil
+	}
+
+	credentials, ok := a.credentials[subgraph.Name]
+	if !ok {
+		return request, nil
+	}
+
+	authorization, err := credentials.authorization()
+	if err != nil {
+		// Don't send the request without credentials, the subgraph could answer it differently
+		a.logger.Error("Failed to get the credentials of the subgraph", zap.String("subgraph_name", subgraph.Name), zap.Error(err))
+		return request, &http.Response{
+			StatusCode: http.StatusBadGateway,
+			Status:     http.StatusText(http.StatusBadGateway),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"errors":[{"message":"failed to authenticate the request to the subgraph"}]}`)),
+			Request:    request,
+		}
+	}
+
+	request.Header.Set("Authorization", authorization)
+
+	return request, nil
+}
+
+func newSubgraphCredentials(c config.SubgraphCredentials) (subgraphCredentials, error) {
+	switch c.Type {
+	case SubgraphCredentialsTypeBearer:
+		token, err := readSecret(c.Token, c.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return nil, errors.New("bearer credentials require a token")
+		}
+		return staticCredentials("Bearer " + token), nil
+	case SubgraphCredentialsTypeBasic:
+		if c.Username == "" {
+			return nil, errors.New("basic credentials require a username")
+		}
+		password, err := readSecret(c.Password, c.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		return staticCredentials("Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+password))), nil
+	case SubgraphCredentialsTypeOAuth2ClientCredentials:
+		if c.TokenURL == "" || c.ClientID == "" {
+			return nil, errors.New("oauth2 client credentials require a token url and a client id")
+		}
+		secret, err := readSecret(c.ClientSecret, c.ClientSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		return &oauth2TokenSource{
+			tokenURL:       c.TokenURL,
+			clientID:       c.ClientID,
+			clientSecret:   secret,
+			scopes:         c.Scopes,
+			endpointParams: c.EndpointParams,
+			httpClient:     &http.Client{Timeout: oauth2TokenRequestTimeout},
+			now:            time.Now,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown type '%s'", c.Type)
+	}
+}
+
+// readSecret returns the inline value or the content of the file, without surrounding whitespace
+func readSecret(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", errors.New("a secret can be set either inline or as a file, not both")
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+type staticCredentials string
+
+func (s staticCredentials) authorization() (string, error) {
+	return string(s), nil
+}
+
+// oauth2TokenSource fetches tokens with the client credentials grant. The token is cached and refreshed shortly
+// before it expires. When a refresh fails, the previous token is used until it expires.
+type oauth2TokenSource struct {
+	tokenURL       string
+	clientID       string
+	clientSecret   string
+	scopes         []string
+	endpointParams map[string]string
+	httpClient     *http.Client
+	now            func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	refreshAt time.Time
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *oauth2TokenSource) authorization() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.token != "" && now.Before(s.refreshAt) {
+		return "Bearer " + s.token, nil
+	}
+
+	token, lifetime, err := s.fetch()
+	if err != nil {
+		if s.token != "" && now.Before(s.expiresAt) {
+			return "Bearer " + s.token, nil
+		}
+		return "", err
+	}
+
+	margin := min(lifetime/5, maxOAuth2RefreshMargin)
+
+	s.token = token
+	s.expiresAt = now.Add(lifetime)
+	s.refreshAt = s.expiresAt.Add(-margin)
+
+	return "Bearer " + s.token, nil
+}
+
+func (s *oauth2TokenSource) fetch() (string, time.Duration, error) {
+	form := url.Values{"grant_type": []string{"client_credentials"}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+	for name, value := range s.endpointParams {
+		form.Set(name, value)
+	}
+
+	// The token is shared by all requests to the subgraph, so a canceled client request must not cancel the fetch
+	ctx, cancel := context.WithTimeout(context.Background(), oauth2TokenRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to fetch oauth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("failed to fetch oauth2 token: unexpected status code %d", resp.StatusCode)
+	}
+
+	var token oauth2TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", 0, fmt.Errorf("failed to decode oauth2 token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", 0, errors.New("the oauth2 token response has no access_token")
+	}
+
+	lifetime := defaultOAuth2TokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+
+	return token.AccessToken, lifetime, nil
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSubgraphCredentials(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates the credentials", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newSubgraphCredentials(config.SubgraphCredentials{Type: "digest"})
+		require.Error(t, err)
+		_, err = newSubgraphCredentials(config.SubgraphCredentials{Type: SubgraphCredentialsTypeBearer})
+		require.Error(t, err)
+		_, err = newSubgraphCredentials(config.SubgraphCredentials{Type: SubgraphCredentialsTypeOAuth2ClientCredentials, ClientID: "router"})
+		require.Error(t, err)
+		_, err = newSubgraphCredentials(config.SubgraphCredentials{Type: SubgraphCredentialsTypeBearer, Token: "a", TokenFile: "b"})
+		require.Error(t, err)
+	})
+
+	t.Run("static credentials", func(t *testing.T) {
+		t.Parallel()
+
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0o600))
+
+		bearer, err := newSubgraphCredentials(config.SubgraphCredentials{Type: SubgraphCredentialsTypeBearer, TokenFile: tokenFile})
+		require.NoError(t, err)
+		authorization, err := bearer.authorization()
+		require.NoError(t, err)
+		require.Equal(t, "Bearer file-token", authorization)
+
+		basic, err := newSubgraphCredentials(config.SubgraphCredentials{Type: SubgraphCredentialsTypeBasic, Username: "user", Password: "pass"})
+		require.NoError(t, err)
+		authorization, err = basic.authorization()
+		require.NoError(t, err)
+		require.Equal(t, "Basic dXNlcjpwYXNz", authorization)
+	})
+}
+
+func TestOAuth2TokenSource(t *testing.T) {
+	t.Parallel()
+
+	newTokenServer := func(t *testing.T, fail *atomic.Bool) (*httptest.Server, *atomic.Int32) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := calls.Add(1)
+			if fail.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			clientID, clientSecret, ok := r.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, "router", clientID)
+			require.Equal(t, "secret", clientSecret)
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			require.Equal(t, "a b", r.PostForm.Get("scope"))
+			require.Equal(t, "products", r.PostForm.Get("audience"))
+
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "token-" + string(rune('0'+n)),
+				"token_type":   "Bearer",
+				"expires_in":   600,
+			})
+		}))
+		t.Cleanup(server.Close)
+		return server, &calls
+	}
+
+	newSource := func(t *testing.T, tokenURL string, now *time.Time) *oauth2TokenSource {
+		credentials, err := newSubgraphCredentials(config.SubgraphCredentials{
+			Type:           SubgraphCredentialsTypeOAuth2ClientCredentials,
+			TokenURL:       tokenURL,
+			ClientID:       "router",
+			ClientSecret:   "secret",
+			Scopes:         []string{"a", "b"},
+			EndpointParams: map[string]string{"audience": "products"},
+		})
+		require.NoError(t, err)
+		source := credentials.(*oauth2TokenSource)
+		source.now = func() time.Time { return *now }
+		return source
+	}
+
+	t.Run("caches the token and refreshes it before the expiry", func(t *testing.T) {
+		t.Parallel()
+
+		server, calls := newTokenServer(t, &atomic.Bool{})
+		now := time.Now()
+		source := newSource(t, server.URL, &now)
+
+		authorization, err := source.authorization()
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token-1", authorization)
+
+		now = now.Add(8 * time.Minute)
+		authorization, err = source.authorization()
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token-1", authorization)
+		require.Equal(t, int32(1), calls.Load())
+
+		// Within the refresh margin of a minute before the expiry
+		now = now.Add(90 * time.Second)
+		authorization, err = source.authorization()
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token-2", authorization)
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("uses the previous token until it expires when the refresh fails", func(t *testing.T) {
+		t.Parallel()
+
+		fail := &atomic.Bool{}
+		server, _ := newTokenServer(t, fail)
+		now := time.Now()
+		source := newSource(t, server.URL, &now)
+
+		_, err := source.authorization()
+		require.NoError(t, err)
+
+		fail.Store(true)
+		now = now.Add(9*time.Minute + 30*time.Second)
+		authorization, err := source.authorization()
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token-1", authorization)
+
+		now = now.Add(time.Minute)
+		_, err = source.authorization()
+		require.Error(t, err)
+	})
+}
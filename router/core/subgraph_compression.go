@@ -0,0 +1,289 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+const (
+	SubgraphCompressionZstd   = "zstd"
+	SubgraphCompressionBrotli = "br"
+	SubgraphCompressionGzip   = "gzip"
+)
+
+// subgraphDecoder decompresses a response body. The decoders are pooled and reset for every response.
+type subgraphDecoder interface {
+	io.Reader
+	reset(body io.Reader) error
+	// release drops the reference to the body, before the decoder is returned to the pool
+	release()
+}
+
+type zstdDecoder struct {
+	*zstd.Decoder
+}
+
+func (d zstdDecoder) reset(body io.Reader) error {
+	return d.Decoder.Reset(body)
+}
+
+func (d zstdDecoder) release() {
+	_ = d.Decoder.Reset(nil)
+}
+
+type brotliDecoder struct {
+	*brotli.Reader
+}
+
+func (d brotliDecoder) reset(body io.Reader) error {
+	return d.Reader.Reset(body)
+}
+
+func (d brotliDecoder) release() {
+	_ = d.Reader.Reset(nil)
+}
+
+type gzipDecoder struct {
+	*gzip.Reader
+}
+
+func (d gzipDecoder) reset(body io.Reader) error {
+	return d.Reader.Reset(body)
+}
+
+func (d gzipDecoder) release() {
+	// The reader doesn't accept nil, the header of the empty reader fails but the decoder stays reusable
+	_ = d.Reader.Reset(eofReader{})
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+var subgraphDecoderPools = map[string]*sync.Pool{
+	SubgraphCompressionZstd: {New: func() any {
+		// A single goroutine decodes the stream synchronously, like the other decoders
+		d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return nil
+		}
+		return zstdDecoder{Decoder: d}
+	}},
+	SubgraphCompressionBrotli: {New: func() any {
+		return brotliDecoder{Reader: brotli.NewReader(nil)}
+	}},
+	SubgraphCompressionGzip: {New: func() any {
+		return gzipDecoder{Reader: new(gzip.Reader)}
+	}},
+}
+
+type SubgraphCompressionOptions struct {
+	Config      *config.SubgraphCompressionConfiguration
+	MetricStore metric.Provider
+}
+
+// SubgraphCompression requests compressed responses from the subgraphs and decompresses them while the engine reads
+// them. Responses in other encodings are passed on unchanged. Subgraphs without the compression keep the default
+// negotiation of the engine.
+type SubgraphCompression struct {
+	metricStore metric.Provider
+	// acceptEncoding is the Accept-Encoding header of the subgraphs without an override
+	acceptEncoding string
+	// subgraphs are the Accept-Encoding headers of the overrides by subgraph name. Empty disables the compression.
+	subgraphs map[string]string
+}
+
+func NewSubgraphCompression(opts *SubgraphCompressionOptions) (*SubgraphCompression, error) {
+	acceptEncoding, err := subgraphAcceptEncoding(opts.Config.Algorithms)
+	if err != nil {
+		return nil, err
+	}
+	if acceptEncoding == "" {
+		return nil, errors.New("the subgraph compression requires at least one algorithm")
+	}
+
+	metricStore := opts.MetricStore
+	if metricStore == nil {
+		metricStore = metric.NewNoopMetrics()
+	}
+
+	c := &SubgraphCompression{
+		metricStore:    metricStore,
+		acceptEncoding: acceptEncoding,
+		subgraphs:      make(map[string]string, len(opts.Config.Subgraphs)),
+	}
+
+	for name, subgraph := range opts.Config.Subgraphs {
+		if !subgraph.Enabled {
+			c.subgraphs[name] = ""
+			continue
+		}
+		if len(subgraph.Algorithms) == 0 {
+			c.subgraphs[name] = acceptEncoding
+			continue
+		}
+		if c.subgraphs[name], err = subgraphAcceptEncoding(subgraph.Algorithms); err != nil {
+			return nil, fmt.Errorf("invalid compression of subgraph '%s': %w", name, err)
+		}
+	}
+
+	return c, nil
+}
+
+// subgraphAcceptEncoding returns the Accept-Encoding header of the algorithms. The order of preference is
+// expressed with decreasing weights.
+func subgraphAcceptEncoding(algorithms []string) (string, error) {
+	values := make([]string, 0, len(algorithms))
+	for i, algorithm := range algorithms {
+		if _, ok := subgraphDecoderPools[algorithm]; !ok {
+			return "", fmt.Errorf("unsupported subgraph compression algorithm '%s'", algorithm)
+		}
+		if i == 0 {
+			values = append(values, algorithm)
+			continue
+		}
+		weight := max(1000-i*100, 100)
+		values = append(values, algorithm+";q="+strconv.FormatFloat(float64(weight)/1000, 'f', -1, 64))
+	}
+	return strings.Join(values, ", "), nil
+}
+
+func (c *SubgraphCompression) subgraphAcceptEncoding(subgraphName string) string {
+	if acceptEncoding, ok := c.subgraphs[subgraphName]; ok {
+		return acceptEncoding
+	}
+	return c.acceptEncoding
+}
+
+// RoundTripper wraps the transport to the subgraphs with the compression of the responses
+func (c *SubgraphCompression) RoundTripper(transport http.RoundTripper) http.RoundTripper {
+	return subgraphCompressionTransport{compression: c, transport: transport}
+}
+
+type subgraphCompressionTransport struct {
+	compression *SubgraphCompression
+	transport   http.RoundTripper
+}
+
+func (t subgraphCompressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var attributes []attribute.KeyValue
+	var subgraphName string
+	if reqContext := getRequestContext(req.Context()); reqContext != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			subgraphName = subgraph.Name
+			attributes = append(attributes, otel.WgSubgraphName.String(subgraph.Name), otel.WgSubgraphID.String(subgraph.Id))
+		}
+	}
+
+	acceptEncoding := t.compression.subgraphAcceptEncoding(subgraphName)
+	if acceptEncoding == "" || req.Header.Get("Upgrade") != "" {
+		return t.transport.RoundTrip(req)
+	}
+
+	// The request belongs to the caller and must not be modified
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	pool, ok := subgraphDecoderPools[encoding]
+	if !ok {
+		return res, nil
+	}
+
+	decoder, _ := pool.Get().(subgraphDecoder)
+	if decoder == nil {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("failed to create the %s decoder of the subgraph response", encoding)
+	}
+
+	compressed := &countingReader{reader: res.Body}
+	if err := decoder.reset(compressed); err != nil {
+		_ = res.Body.Close()
+		decoder.release()
+		pool.Put(decoder)
+		return nil, fmt.Errorf("failed to decompress the %s response of the subgraph: %w", encoding, err)
+	}
+
+	res.Body = &decompressedBody{
+		decoder:    decoder,
+		pool:       pool,
+		body:       res.Body,
+		compressed: compressed,
+		measure: func(compressed, decompressed int64) {
+			// The body is usually closed after the request context was canceled, which would drop the measurement
+			t.compression.metricStore.MeasureSubgraphResponseCompression(context.WithoutCancel(req.Context()), compressed, decompressed,
+				append(attributes, otel.WgSubgraphContentEncoding.String(encoding))...)
+		},
+	}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return res, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// decompressedBody streams the decompressed response. The decoder is returned to the pool when the body is closed.
+type decompressedBody struct {
+	decoder    subgraphDecoder
+	pool       *sync.Pool
+	body       io.ReadCloser
+	compressed *countingReader
+	n          int64
+	measure    func(compressed, decompressed int64)
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.decoder == nil {
+		return 0, errors.New("read on closed subgraph response body")
+	}
+	n, err := b.decoder.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *decompressedBody) Close() error {
+	if b.decoder == nil {
+		return nil
+	}
+	b.measure(b.compressed.n, b.n)
+
+	b.decoder.release()
+	b.pool.Put(b.decoder)
+	b.decoder = nil
+
+	return b.body.Close()
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+)
+
+type compressionMetrics struct {
+	metric.NoopMetrics
+	mu           sync.Mutex
+	compressed   int64
+	decompressed int64
+}
+
+func (m *compressionMetrics) MeasureSubgraphResponseCompression(_ context.Context, compressed, decompressed int64, _ ...attribute.KeyValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.compressed += compressed
+	m.decompressed += decompressed
+}
+
+func compressBody(t *testing.T, encoding string, body []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case SubgraphCompressionZstd:
+		zw, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		w = zw
+	case SubgraphCompressionBrotli:
+		w = brotli.NewWriter(&buf)
+	case SubgraphCompressionGzip:
+		w = gzip.NewWriter(&buf)
+	}
+	_, err := w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestSubgraphCompression(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validates the config", func(t *testing.T) {
+		t.Parallel()
+
+		for _, cfg := range []config.SubgraphCompressionConfiguration{
+			{},
+			{Algorithms: []string{"deflate"}},
+			{Algorithms: []string{"gzip"}, Subgraphs: map[string]config.SubgraphCompression{"a": {Enabled: true, Algorithms: []string{"lz4"}}}},
+		} {
+			_, err := NewSubgraphCompression(&SubgraphCompressionOptions{Config: &cfg})
+			require.Error(t, err, "%+v", cfg)
+		}
+	})
+
+	t.Run("negotiates the algorithms by subgraph", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := NewSubgraphCompression(&SubgraphCompressionOptions{Config: &config.SubgraphCompressionConfiguration{
+			Algorithms: []string{"zstd", "br", "gzip"},
+			Subgraphs: map[string]config.SubgraphCompression{
+				"employees": {Enabled: true, Algorithms: []string{"gzip"}},
+				"products":  {Enabled: false},
+				"family":    {Enabled: true},
+			},
+		}})
+		require.NoError(t, err)
+
+		require.Equal(t, "zstd, br;q=0.9, gzip;q=0.8", c.subgraphAcceptEncoding("test1"))
+		require.Equal(t, "gzip", c.subgraphAcceptEncoding("employees"))
+		require.Equal(t, "", c.subgraphAcceptEncoding("products"))
+		require.Equal(t, "zstd, br;q=0.9, gzip;q=0.8", c.subgraphAcceptEncoding("family"))
+	})
+
+	t.Run("decompresses the responses", func(t *testing.T) {
+		t.Parallel()
+
+		body := []byte(`{"data":{"employees":[` + strings.Repeat(`{"id":1},`, 100) + `{"id":2}]}}`)
+
+		compressed := map[string][]byte{}
+		for _, algorithm := range []string{SubgraphCompressionZstd, SubgraphCompressionBrotli, SubgraphCompressionGzip} {
+			compressed[algorithm] = compressBody(t, algorithm, body)
+		}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.SplitN(r.Header.Get("Accept-Encoding"), ",", 2)[0]
+			w.Header().Set("Content-Encoding", encoding)
+			_, _ = w.Write(compressed[encoding])
+		}))
+		defer server.Close()
+
+		for _, algorithm := range []string{SubgraphCompressionZstd, SubgraphCompressionBrotli, SubgraphCompressionGzip} {
+			metrics := &compressionMetrics{}
+			c, err := NewSubgraphCompression(&SubgraphCompressionOptions{
+				Config:      &config.SubgraphCompressionConfiguration{Algorithms: []string{algorithm}},
+				MetricStore: metrics,
+			})
+			require.NoError(t, err)
+
+			// The decoders are reused by the second request
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+				require.NoError(t, err)
+
+				res, err := c.RoundTripper(http.DefaultTransport).RoundTrip(req)
+				require.NoError(t, err)
+				require.Empty(t, res.Header.Get("Content-Encoding"), algorithm)
+				require.Empty(t, req.Header.Get("Accept-Encoding"))
+
+				data, err := io.ReadAll(res.Body)
+				require.NoError(t, err)
+				require.NoError(t, res.Body.Close())
+				require.Equal(t, body, data, algorithm)
+			}
+
+			require.Equal(t, int64(2*len(body)), metrics.decompressed, algorithm)
+			require.Less(t, metrics.compressed, metrics.decompressed, algorithm)
+		}
+	})
+
+	t.Run("passes other encodings on", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"data":{}}`))
+		}))
+		defer server.Close()
+
+		c, err := NewSubgraphCompression(&SubgraphCompressionOptions{
+			Config: &config.SubgraphCompressionConfiguration{Algorithms: []string{SubgraphCompressionZstd}},
+		})
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+		require.NoError(t, err)
+		res, err := c.RoundTripper(http.DefaultTransport).RoundTrip(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, `{"data":{}}`, string(data))
+	})
+}
@@ -0,0 +1,278 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const (
+	defaultSubgraphEndpointRollbackMinRequests = 10
+	defaultSubgraphEndpointRollbackWindow      = time.Minute
+)
+
+var ErrUnknownSubgraphEndpoint = errors.New("the subgraph has no alternate endpoint")
+
+// SubgraphEndpointSwitch sends a share of the requests to a subgraph to its alternate URL instead of its routing URL,
+// to switch between a blue and a green deployment of the subgraph without publishing the graph again. The share is
+// changed at runtime through the admin API. When the error rate of the alternate URL exceeds the rollback threshold,
+// all requests are switched back to the routing URL.
+type SubgraphEndpointSwitch struct {
+	logger *zap.Logger
+	// endpoints are the alternate endpoints by the name of the subgraph
+	endpoints map[string]*subgraphEndpoint
+	// random returns a number in [0,1) and is replaced in tests
+	random func() float64
+	now    func() time.Time
+}
+
+type subgraphEndpoint struct {
+	subgraph     string
+	alternateURL *url.URL
+	errorRate    float64
+	minRequests  int64
+	window       time.Duration
+
+	mu     sync.Mutex
+	weight int
+	// windowStart is the start of the window in which the requests and the failures are counted
+	windowStart time.Time
+	requests    int64
+	failures    int64
+	// rolledBackAt is when the requests were switched back automatically. Zero until the next rollback.
+	rolledBackAt time.Time
+}
+
+// SubgraphEndpointStatus describes the switch of the requests of a subgraph
+type SubgraphEndpointStatus struct {
+	Subgraph     string `json:"subgraph"`
+	AlternateURL string `json:"alternate_url"`
+	// Weight is the percentage of the requests that are sent to the alternate URL
+	Weight       int        `json:"weight"`
+	RolledBackAt *time.Time `json:"rolled_back_at,omitempty"`
+}
+
+func NewSubgraphEndpointSwitch(logger *zap.Logger, cfg *config.SubgraphEndpointsConfiguration) (*SubgraphEndpointSwitch, error) {
+	endpoints := make(map[string]*subgraphEndpoint, len(cfg.Subgraphs))
+	for _, sg := range cfg.Subgraphs {
+		if sg.Name == "" {
+			return nil, errors.New("the alternate endpoint of a subgraph requires the name of the subgraph")
+		}
+		if _, ok := endpoints[sg.Name]; ok {
+			return nil, fmt.Errorf("the subgraph '%s' has more than one alternate endpoint", sg.Name)
+		}
+		alternateURL, err := url.Parse(sg.AlternateURL)
+		if err != nil || (alternateURL.Scheme != "http" && alternateURL.Scheme != "https") || alternateURL.Host == "" {
+			return nil, fmt.Errorf("invalid alternate url '%s' of the subgraph '%s'", sg.AlternateURL, sg.Name)
+		}
+		if err := validateSubgraphEndpointWeight(sg.Weight); err != nil {
+			return nil, fmt.Errorf("invalid alternate endpoint of the subgraph '%s': %w", sg.Name, err)
+		}
+		if sg.Rollback.ErrorRate < 0 || sg.Rollback.ErrorRate > 1 {
+			return nil, fmt.Errorf("invalid alternate endpoint of the subgraph '%s': the error rate must be between 0 and 1, got %v", sg.Name, sg.Rollback.ErrorRate)
+		}
+
+		minRequests := int64(sg.Rollback.MinRequests)
+		if minRequests <= 0 {
+			minRequests = defaultSubgraphEndpointRollbackMinRequests
+		}
+		window := sg.Rollback.Window
+		if window <= 0 {
+			window = defaultSubgraphEndpointRollbackWindow
+		}
+
+		endpoints[sg.Name] = &subgraphEndpoint{
+			subgraph:     sg.Name,
+			alternateURL: alternateURL,
+			errorRate:    sg.Rollback.ErrorRate,
+			minRequests:  minRequests,
+			window:       window,
+			weight:       sg.Weight,
+		}
+	}
+
+	return &SubgraphEndpointSwitch{
+		logger:    logger,
+		endpoints: endpoints,
+		random:    rand.Float64,
+		now:       time.Now,
+	}, nil
+}
+
+func validateSubgraphEndpointWeight(weight int) error {
+	if weight < 0 || weight > 100 {
+		return fmt.Errorf("the weight must be between 0 and 100, got %d", weight)
+	}
+	return nil
+}
+
+// SetWeight changes the percentage of the requests to the subgraph that are sent to its alternate URL. It resets the
+// error rate of the rollback, so that a rolled back subgraph can be switched again.
+func (s *SubgraphEndpointSwitch) SetWeight(subgraph string, weight int) (SubgraphEndpointStatus, error) {
+	endpoint, ok := s.endpoints[subgraph]
+	if !ok {
+		return SubgraphEndpointStatus{}, ErrUnknownSubgraphEndpoint
+	}
+	if err := validateSubgraphEndpointWeight(weight); err != nil {
+		return SubgraphEndpointStatus{}, err
+	}
+
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+
+	endpoint.weight = weight
+	endpoint.windowStart = time.Time{}
+	endpoint.requests = 0
+	endpoint.failures = 0
+	endpoint.rolledBackAt = time.Time{}
+
+	return endpoint.statusLocked(), nil
+}
+
+// Endpoints returns the status of the alternate endpoints, sorted by the name of the subgraph
+func (s *SubgraphEndpointSwitch) Endpoints() []SubgraphEndpointStatus {
+	statuses := make([]SubgraphEndpointStatus, 0, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		endpoint.mu.Lock()
+		statuses = append(statuses, endpoint.statusLocked())
+		endpoint.mu.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Subgraph < statuses[j].Subgraph
+	})
+	return statuses
+}
+
+func (e *subgraphEndpoint) statusLocked() SubgraphEndpointStatus {
+	status := SubgraphEndpointStatus{
+		Subgraph:     e.subgraph,
+		AlternateURL: e.alternateURL.String(),
+		Weight:       e.weight,
+	}
+	if !e.rolledBackAt.IsZero() {
+		rolledBackAt := e.rolledBackAt
+		status.RolledBackAt = &rolledBackAt
+	}
+	return status
+}
+
+// RoundTripper wraps the transport to the subgraphs with the switch of the endpoints
+func (s *SubgraphEndpointSwitch) RoundTripper(transport http.RoundTripper) http.RoundTripper {
+	return subgraphEndpointTransport{endpoints: s, transport: transport}
+}
+
+type subgraphEndpointTransport struct {
+	endpoints *SubgraphEndpointSwitch
+	transport http.RoundTripper
+}
+
+func (t subgraphEndpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqContext := getRequestContext(req.Context())
+	if reqContext == nil {
+		return t.transport.RoundTrip(req)
+	}
+	subgraph := reqContext.ActiveSubgraph(req)
+	if subgraph == nil {
+		return t.transport.RoundTrip(req)
+	}
+	endpoint, ok := t.endpoints.endpoints[subgraph.Name]
+	if !ok || !t.endpoints.switched(endpoint) {
+		return t.transport.RoundTrip(req)
+	}
+
+	switchedReq := req.Clone(req.Context())
+	switchedReq.URL = t.endpoints.alternateURL(endpoint, req.URL)
+	// The host header is derived from the alternate URL
+	switchedReq.Host = ""
+
+	resp, err := t.transport.RoundTrip(switchedReq)
+	// A request that is canceled by the client says nothing about the alternate endpoint
+	if !errors.Is(err, context.Canceled) {
+		t.endpoints.record(endpoint, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	}
+	return resp, err
+}
+
+// switched decides by the weight whether the request is sent to the alternate URL
+func (s *SubgraphEndpointSwitch) switched(endpoint *subgraphEndpoint) bool {
+	endpoint.mu.Lock()
+	weight := endpoint.weight
+	endpoint.mu.Unlock()
+
+	switch weight {
+	case 0:
+		return false
+	case 100:
+		return true
+	default:
+		return s.random()*100 < float64(weight)
+	}
+}
+
+// alternateURL returns the alternate URL with the query of the request, unless the alternate URL has its own query
+func (s *SubgraphEndpointSwitch) alternateURL(endpoint *subgraphEndpoint, requestURL *url.URL) *url.URL {
+	u := *endpoint.alternateURL
+	if u.RawQuery == "" {
+		u.RawQuery = requestURL.RawQuery
+	}
+	return &u
+}
+
+// record counts the request to the alternate URL and switches all requests back to the routing URL when the error
+// rate of the window exceeds the threshold
+func (s *SubgraphEndpointSwitch) record(endpoint *subgraphEndpoint, failed bool) {
+	if endpoint.errorRate <= 0 {
+		return
+	}
+
+	now := s.now()
+
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+
+	// The weight was changed while the request was in flight
+	if endpoint.weight == 0 {
+		return
+	}
+	if now.Sub(endpoint.windowStart) >= endpoint.window {
+		endpoint.windowStart = now
+		endpoint.requests = 0
+		endpoint.failures = 0
+	}
+	endpoint.requests++
+	if failed {
+		endpoint.failures++
+	}
+
+	if endpoint.requests < endpoint.minRequests {
+		return
+	}
+	errorRate := float64(endpoint.failures) / float64(endpoint.requests)
+	if errorRate < endpoint.errorRate {
+		return
+	}
+
+	s.logger.Warn("Switched the subgraph back to its routing URL because the alternate URL fails",
+		zap.String("subgraph_name", endpoint.subgraph),
+		zap.String("alternate_url", endpoint.alternateURL.String()),
+		zap.Int("weight", endpoint.weight),
+		zap.Float64("error_rate", errorRate),
+		zap.Int64("requests", endpoint.requests),
+	)
+
+	endpoint.weight = 0
+	endpoint.rolledBackAt = now
+	endpoint.windowStart = time.Time{}
+	endpoint.requests = 0
+	endpoint.failures = 0
+}
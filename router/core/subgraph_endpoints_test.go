@@ -0,0 +1,147 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+type subgraphEndpointTestTransport struct {
+	hosts      []string
+	statusCode int
+}
+
+func (t *subgraphEndpointTestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.hosts = append(t.hosts, req.URL.Host)
+	return &http.Response{StatusCode: t.statusCode, Body: io.NopCloser(nil), Request: req}, nil
+}
+
+func TestSubgraphEndpointSwitch(t *testing.T) {
+	t.Parallel()
+
+	newSwitch := func(t *testing.T, logger *zap.Logger, weight int, rollback config.SubgraphEndpointRollback) *SubgraphEndpointSwitch {
+		s, err := NewSubgraphEndpointSwitch(logger, &config.SubgraphEndpointsConfiguration{
+			Enabled: true,
+			Subgraphs: []config.SubgraphEndpointConfiguration{
+				{Name: "employees", AlternateURL: "http://employees-green.local/graphql", Weight: weight, Rollback: rollback},
+			},
+		})
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("sends the requests by the weight", func(t *testing.T) {
+		t.Parallel()
+
+		s := newSwitch(t, zap.NewNop(), 30, config.SubgraphEndpointRollback{})
+		random := 0.0
+		s.random = func() float64 { return random }
+
+		transport := &subgraphEndpointTestTransport{statusCode: http.StatusOK}
+		rt := s.RoundTripper(transport)
+
+		_, err := rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+		require.NoError(t, err)
+		random = 0.5
+		_, err = rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+		require.NoError(t, err)
+		// Only the subgraphs with an alternate endpoint are switched
+		random = 0
+		_, err = rt.RoundTrip(newChaosTestRequest(t, "products", "Employees"))
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"employees-green.local", "employees.local", "products.local"}, transport.hosts)
+	})
+
+	t.Run("changes the weight at runtime", func(t *testing.T) {
+		t.Parallel()
+
+		s := newSwitch(t, zap.NewNop(), 0, config.SubgraphEndpointRollback{})
+		transport := &subgraphEndpointTestTransport{statusCode: http.StatusOK}
+		rt := s.RoundTripper(transport)
+
+		_, err := rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+		require.NoError(t, err)
+
+		status, err := s.SetWeight("employees", 100)
+		require.NoError(t, err)
+		require.Equal(t, 100, status.Weight)
+		_, err = rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"employees.local", "employees-green.local"}, transport.hosts)
+
+		_, err = s.SetWeight("products", 100)
+		require.ErrorIs(t, err, ErrUnknownSubgraphEndpoint)
+		_, err = s.SetWeight("employees", 101)
+		require.Error(t, err)
+	})
+
+	t.Run("rolls back when the alternate endpoint fails", func(t *testing.T) {
+		t.Parallel()
+
+		logCore, logs := observer.New(zapcore.WarnLevel)
+		s := newSwitch(t, zap.New(logCore), 100, config.SubgraphEndpointRollback{ErrorRate: 0.5, MinRequests: 4, Window: time.Minute})
+		now := time.Unix(1_000_000, 0)
+		s.now = func() time.Time { return now }
+
+		transport := &subgraphEndpointTestTransport{statusCode: http.StatusOK}
+		rt := s.RoundTripper(transport)
+
+		for _, statusCode := range []int{http.StatusOK, http.StatusOK, http.StatusBadGateway} {
+			transport.statusCode = statusCode
+			_, err := rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+			require.NoError(t, err)
+		}
+		require.Equal(t, 100, s.Endpoints()[0].Weight)
+
+		// The failures of a previous window don't count
+		now = now.Add(time.Minute)
+		for i := 0; i < 2; i++ {
+			_, err := rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+			require.NoError(t, err)
+		}
+		require.Equal(t, 100, s.Endpoints()[0].Weight)
+		transport.statusCode = http.StatusOK
+		for i := 0; i < 2; i++ {
+			_, err := rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+			require.NoError(t, err)
+		}
+
+		status := s.Endpoints()[0]
+		require.Equal(t, 0, status.Weight)
+		require.NotNil(t, status.RolledBackAt)
+		require.Equal(t, 1, logs.Len())
+
+		_, err := rt.RoundTrip(newChaosTestRequest(t, "employees", "Employees"))
+		require.NoError(t, err)
+		require.Equal(t, "employees.local", transport.hosts[len(transport.hosts)-1])
+
+		// Switching again resets the rollback
+		status, err = s.SetWeight("employees", 100)
+		require.NoError(t, err)
+		require.Nil(t, status.RolledBackAt)
+	})
+
+	t.Run("rejects invalid endpoints", func(t *testing.T) {
+		t.Parallel()
+
+		for _, sg := range []config.SubgraphEndpointConfiguration{
+			{Name: "employees", AlternateURL: "employees-green"},
+			{Name: "employees", AlternateURL: "http://employees-green.local", Weight: -1},
+			{Name: "employees", AlternateURL: "http://employees-green.local", Rollback: config.SubgraphEndpointRollback{ErrorRate: 2}},
+		} {
+			_, err := NewSubgraphEndpointSwitch(zap.NewNop(), &config.SubgraphEndpointsConfiguration{
+				Subgraphs: []config.SubgraphEndpointConfiguration{sg},
+			})
+			require.Error(t, err)
+		}
+	})
+}
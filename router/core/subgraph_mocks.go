@@ -0,0 +1,480 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astnormalization"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"go.uber.org/zap"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+type SubgraphMocksOptions struct {
+	Config *config.SubgraphMocksConfiguration
+	Logger *zap.Logger
+	// EngineConfig contains the schemas of the subgraphs
+	EngineConfig *nodev1.EngineConfiguration
+	Subgraphs    []*nodev1.Subgraph
+}
+
+// SubgraphMocks answers the requests to the subgraphs with generated data that matches their schemas, so that the
+// clients can be developed against a graph whose subgraphs aren't all deployed. The values are generated by type and
+// can be replaced with fixtures. It must only be used for development.
+type SubgraphMocks struct {
+	logger *zap.Logger
+	// unreachable mocks the requests to the subgraphs that fail with a transport error
+	unreachable bool
+	// always are the names of the subgraphs whose requests are mocked without sending them
+	always     map[string]struct{}
+	listLength int
+	fixtures   map[string]any
+	// schemas are the schemas of the subgraphs by their name
+	schemas map[string]*ast.Document
+}
+
+func NewSubgraphMocks(opts *SubgraphMocksOptions) (*SubgraphMocks, error) {
+	names := make(map[string]string, len(opts.Subgraphs))
+	for _, sg := range opts.Subgraphs {
+		names[sg.GetId()] = sg.GetName()
+	}
+
+	schemas := make(map[string]*ast.Document, len(names))
+	for _, ds := range opts.EngineConfig.GetDatasourceConfigurations() {
+		if ds.GetCustomGraphql() == nil {
+			continue
+		}
+		name, ok := names[ds.GetId()]
+		if !ok {
+			continue
+		}
+		sdl, ok := opts.EngineConfig.GetStringStorage()[ds.GetCustomGraphql().GetUpstreamSchema().GetKey()]
+		if !ok {
+			continue
+		}
+		schema, err := parseSubgraphMockSchema(sdl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the schema of the subgraph '%s': %w", name, err)
+		}
+		schemas[name] = schema
+	}
+
+	always := make(map[string]struct{}, len(opts.Config.Subgraphs))
+	for _, name := range opts.Config.Subgraphs {
+		if _, ok := schemas[name]; !ok {
+			opts.Logger.Warn("The mocked subgraph is not part of the graph", zap.String("subgraph_name", name))
+		}
+		always[name] = struct{}{}
+	}
+
+	listLength := opts.Config.ListLength
+	if listLength < 0 {
+		return nil, fmt.Errorf("the list length of the subgraph mocks must not be negative, got %d", listLength)
+	}
+
+	return &SubgraphMocks{
+		logger:      opts.Logger,
+		unreachable: opts.Config.Unreachable,
+		always:      always,
+		listLength:  listLength,
+		fixtures:    opts.Config.Fixtures,
+		schemas:     schemas,
+	}, nil
+}
+
+// parseSubgraphMockSchema parses the schema of a subgraph with the built-in scalars and the type extensions merged
+// into their types
+func parseSubgraphMockSchema(sdl string) (*ast.Document, error) {
+	schema, report := astparser.ParseGraphqlDocumentString(sdl)
+	if report.HasErrors() {
+		return nil, report
+	}
+	if err := asttransform.MergeDefinitionWithBaseSchema(&schema); err != nil {
+		return nil, err
+	}
+	astnormalization.NormalizeDefinition(&schema, &report)
+	if report.HasErrors() {
+		return nil, report
+	}
+	return &schema, nil
+}
+
+// RoundTripper wraps the transport to the subgraphs with the mocks
+func (m *SubgraphMocks) RoundTripper(transport http.RoundTripper) http.RoundTripper {
+	return subgraphMocksTransport{mocks: m, transport: transport}
+}
+
+type subgraphMocksTransport struct {
+	mocks     *SubgraphMocks
+	transport http.RoundTripper
+}
+
+func (t subgraphMocksTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Subscriptions are not mocked
+	if req.Header.Get("Upgrade") != "" || req.Header.Get("Accept") == "text/event-stream" {
+		return t.transport.RoundTrip(req)
+	}
+	reqContext := getRequestContext(req.Context())
+	if reqContext == nil {
+		return t.transport.RoundTrip(req)
+	}
+	subgraph := reqContext.ActiveSubgraph(req)
+	if subgraph == nil {
+		return t.transport.RoundTrip(req)
+	}
+	schema, ok := t.mocks.schemas[subgraph.Name]
+	if !ok {
+		return t.transport.RoundTrip(req)
+	}
+	_, always := t.mocks.always[subgraph.Name]
+	if !always && !t.mocks.unreachable {
+		return t.transport.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !always {
+		// The body is kept to mock the request when the subgraph can't be reached
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+
+		resp, err := t.transport.RoundTrip(req)
+		if err == nil || errors.Is(err, context.Canceled) {
+			return resp, err
+		}
+		t.mocks.logger.Debug("Mocking the request to the unreachable subgraph",
+			zap.String("subgraph_name", subgraph.Name),
+			zap.Error(err),
+		)
+	}
+
+	return t.mocks.respond(req, schema, body), nil
+}
+
+type subgraphMockRequest struct {
+	Query     string          `json:"query"`
+	Variables json.RawMessage `json:"variables,omitempty"`
+}
+
+// respond answers the request with the generated data. A request that can't be mocked is answered with an error.
+func (m *SubgraphMocks) respond(req *http.Request, schema *ast.Document, body []byte) *http.Response {
+	payload, err := m.generate(schema, body)
+	if err != nil {
+		payload, _ = json.Marshal(GraphQLErrorResponse{
+			Errors: []graphqlError{{Message: fmt.Sprintf("failed to mock the subgraph response: %s", err)}},
+		})
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Status:        "200 OK",
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(payload)),
+		ContentLength: int64(len(payload)),
+		Request:       req,
+	}
+}
+
+func (m *SubgraphMocks) generate(schema *ast.Document, body []byte) ([]byte, error) {
+	var request subgraphMockRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	operation, report := astparser.ParseGraphqlDocumentString(request.Query)
+	if report.HasErrors() {
+		return nil, report
+	}
+	if len(operation.OperationDefinitions) == 0 {
+		return nil, errors.New("the request has no operation")
+	}
+
+	g := &mockGenerator{
+		schema:     schema,
+		operation:  &operation,
+		fixtures:   m.fixtures,
+		listLength: m.listLength,
+	}
+	if len(request.Variables) > 0 && !bytes.Equal(request.Variables, []byte("null")) {
+		if err := json.Unmarshal(request.Variables, &g.variables); err != nil {
+			return nil, fmt.Errorf("invalid variables: %w", err)
+		}
+	}
+
+	def := operation.OperationDefinitions[0]
+	rootTypeName := g.rootTypeName(def.OperationType)
+	if rootTypeName == "" {
+		return nil, errors.New("the schema has no root type for the operation")
+	}
+
+	return json.Marshal(map[string]any{
+		"data": g.selectionSet(def.SelectionSet, rootTypeName),
+	})
+}
+
+// mockGenerator generates the data of one request
+type mockGenerator struct {
+	schema     *ast.Document
+	operation  *ast.Document
+	variables  map[string]any
+	fixtures   map[string]any
+	listLength int
+	// counter makes the generated values unique within the response
+	counter int
+}
+
+func (g *mockGenerator) rootTypeName(operationType ast.OperationType) string {
+	var name, fallback string
+	switch operationType {
+	case ast.OperationTypeQuery:
+		name, fallback = string(g.schema.Index.QueryTypeName), "Query"
+	case ast.OperationTypeMutation:
+		name, fallback = string(g.schema.Index.MutationTypeName), "Mutation"
+	default:
+		return ""
+	}
+	if name == "" {
+		name = fallback
+	}
+	if _, ok := g.schema.Index.FirstNodeByNameStr(name); !ok {
+		return ""
+	}
+	return name
+}
+
+// selectionSet generates the object of the type with the selections of the operation
+func (g *mockGenerator) selectionSet(ref int, typeName string) map[string]any {
+	obj := map[string]any{}
+
+	for _, selectionRef := range g.operation.SelectionSets[ref].SelectionRefs {
+		selection := g.operation.Selections[selectionRef]
+		switch selection.Kind {
+		case ast.SelectionKindField:
+			key := g.operation.FieldAliasOrNameString(selection.Ref)
+			mergeMockValue(obj, key, g.field(selection.Ref, typeName))
+		case ast.SelectionKindInlineFragment:
+			condition := g.operation.InlineFragmentTypeConditionNameString(selection.Ref)
+			if condition == "" || g.matches(typeName, condition) {
+				mergeMockObject(obj, g.selectionSet(g.operation.InlineFragments[selection.Ref].SelectionSet, typeName))
+			}
+		case ast.SelectionKindFragmentSpread:
+			fragmentRef, ok := g.operation.FragmentDefinitionRef(g.operation.FragmentSpreadNameBytes(selection.Ref))
+			if ok && g.matches(typeName, g.operation.FragmentDefinitionTypeNameString(fragmentRef)) {
+				mergeMockObject(obj, g.selectionSet(g.operation.FragmentDefinitions[fragmentRef].SelectionSet, typeName))
+			}
+		}
+	}
+
+	return obj
+}
+
+func (g *mockGenerator) field(ref int, typeName string) any {
+	name := g.operation.FieldNameString(ref)
+	switch name {
+	case "__typename":
+		return typeName
+	case "_entities":
+		return g.entities(ref)
+	}
+
+	node, ok := g.schema.Index.FirstNodeByNameStr(typeName)
+	if !ok {
+		return nil
+	}
+	definitionRef, ok := g.schema.NodeFieldDefinitionByName(node, []byte(name))
+	if !ok {
+		return nil
+	}
+
+	return g.value(g.schema.FieldDefinitionType(definitionRef), typeName+"."+name, name, ref)
+}
+
+// entities generates the entities of the representations. The fields of the representations, like the keys, are
+// returned unchanged, so that the entities can be merged with the entities of the other subgraphs.
+func (g *mockGenerator) entities(ref int) any {
+	argumentRef, ok := g.operation.FieldArgument(ref, []byte("representations"))
+	if !ok {
+		return nil
+	}
+	value := g.operation.ArgumentValue(argumentRef)
+	if value.Kind != ast.ValueKindVariable {
+		return nil
+	}
+	representations, _ := g.variables[g.operation.VariableValueNameString(value.Ref)].([]any)
+
+	entities := make([]any, 0, len(representations))
+	for _, r := range representations {
+		representation, _ := r.(map[string]any)
+		typeName, _ := representation["__typename"].(string)
+		if typeName == "" {
+			entities = append(entities, nil)
+			continue
+		}
+		entity := g.selectionSet(g.operation.Fields[ref].SelectionSet, typeName)
+		for key, value := range representation {
+			if _, ok := entity[key]; ok {
+				entity[key] = value
+			}
+		}
+		entities = append(entities, entity)
+	}
+	return entities
+}
+
+// value generates the value of the type. coordinate is the coordinate of the field, e.g. Employee.name.
+func (g *mockGenerator) value(typeRef int, coordinate, fieldName string, fieldRef int) any {
+	if fixture, ok := g.fixtures[coordinate]; ok {
+		return fixture
+	}
+
+	t := g.schema.Types[typeRef]
+	switch t.TypeKind {
+	case ast.TypeKindNonNull:
+		return g.value(t.OfType, coordinate, fieldName, fieldRef)
+	case ast.TypeKindList:
+		items := make([]any, g.listLength)
+		for i := range items {
+			items[i] = g.value(t.OfType, coordinate, fieldName, fieldRef)
+		}
+		return items
+	}
+
+	typeName := g.schema.TypeNameString(typeRef)
+	node, ok := g.schema.Index.FirstNodeByNameStr(typeName)
+	if !ok {
+		return nil
+	}
+
+	switch node.Kind {
+	case ast.NodeKindScalarTypeDefinition:
+		return g.scalar(typeName, fieldName)
+	case ast.NodeKindEnumTypeDefinition:
+		values := g.schema.EnumTypeDefinitions[node.Ref].EnumValuesDefinition.Refs
+		if len(values) == 0 {
+			return nil
+		}
+		return g.schema.EnumValueDefinitionNameString(values[0])
+	case ast.NodeKindObjectTypeDefinition:
+		return g.selectionSet(g.operation.Fields[fieldRef].SelectionSet, typeName)
+	case ast.NodeKindInterfaceTypeDefinition, ast.NodeKindUnionTypeDefinition:
+		concreteTypeName := g.concreteTypeName(node)
+		if concreteTypeName == "" {
+			return nil
+		}
+		return g.selectionSet(g.operation.Fields[fieldRef].SelectionSet, concreteTypeName)
+	}
+
+	return nil
+}
+
+// scalar generates a value of the scalar. The values are unique within the response, so that lists of entities
+// don't contain duplicates.
+func (g *mockGenerator) scalar(typeName, fieldName string) any {
+	if fixture, ok := g.fixtures[typeName]; ok {
+		return fixture
+	}
+
+	g.counter++
+	switch typeName {
+	case "ID":
+		return strconv.Itoa(g.counter)
+	case "Int":
+		return g.counter
+	case "Float":
+		return float64(g.counter) + 0.5
+	case "Boolean":
+		return g.counter%2 == 1
+	case "String":
+		if strings.Contains(strings.ToLower(fieldName), "email") {
+			return fmt.Sprintf("user%d@example.com", g.counter)
+		}
+		return fmt.Sprintf("%s %d", fieldName, g.counter)
+	default:
+		// The custom scalars are serialized as strings, unless they have a fixture
+		return fmt.Sprintf("%s %d", typeName, g.counter)
+	}
+}
+
+// concreteTypeName returns the first object type of the union or the interface
+func (g *mockGenerator) concreteTypeName(node ast.Node) string {
+	switch node.Kind {
+	case ast.NodeKindUnionTypeDefinition:
+		members := g.schema.UnionTypeDefinitions[node.Ref].UnionMemberTypes.Refs
+		if len(members) == 0 {
+			return ""
+		}
+		return g.schema.TypeNameString(members[0])
+	case ast.NodeKindInterfaceTypeDefinition:
+		typeNames, ok := g.schema.InterfaceTypeDefinitionImplementedByObjectWithNames(node.Ref)
+		if !ok {
+			return ""
+		}
+		return typeNames[0]
+	}
+	return ""
+}
+
+// matches returns whether the selections of the type condition apply to the object type
+func (g *mockGenerator) matches(typeName, condition string) bool {
+	if typeName == condition {
+		return true
+	}
+	node, ok := g.schema.Index.FirstNodeByNameStr(condition)
+	if !ok {
+		return false
+	}
+	switch node.Kind {
+	case ast.NodeKindInterfaceTypeDefinition:
+		object, ok := g.schema.Index.FirstNodeByNameStr(typeName)
+		return ok && g.schema.NodeImplementsInterface(object, []byte(condition))
+	case ast.NodeKindUnionTypeDefinition:
+		for _, member := range g.schema.UnionTypeDefinitions[node.Ref].UnionMemberTypes.Refs {
+			if g.schema.TypeNameString(member) == typeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeMockValue sets the value of the key. The objects of a field that is selected more than once are merged.
+func mergeMockValue(obj map[string]any, key string, value any) {
+	existing, ok := obj[key].(map[string]any)
+	if incoming, isObject := value.(map[string]any); ok && isObject {
+		mergeMockObject(existing, incoming)
+		return
+	}
+	if _, exists := obj[key]; !exists {
+		obj[key] = value
+	}
+}
+
+func mergeMockObject(obj map[string]any, other map[string]any) {
+	for key, value := range other {
+		mergeMockValue(obj, key, value)
+	}
+}
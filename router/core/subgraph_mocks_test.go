@@ -0,0 +1,55 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubgraphMocksGenerate(t *testing.T) {
+	t.Parallel()
+
+	schema, err := parseSubgraphMockSchema(`
+		scalar DateTime
+		enum Role { ENGINEER MARKETER }
+		interface Pet { name: String! }
+		type Cat implements Pet { name: String! lives: Int! }
+		type Employee @key(fields: "id") { id: Int! email: String! role: Role! pets: [Pet!]! hiredAt: DateTime }
+		extend type Query { employee(id: Int!): Employee }
+	`)
+	require.NoError(t, err)
+
+	mocks := &SubgraphMocks{
+		listLength: 1,
+		fixtures:   map[string]any{"DateTime": "2024-01-01T00:00:00Z"},
+	}
+
+	t.Run("generates the values by type", func(t *testing.T) {
+		t.Parallel()
+
+		payload, err := mocks.generate(schema, []byte(`{"query":"{ employee(id: 1) { id email role hiredAt pets { __typename name ... on Cat { lives } } } }"}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"data":{"employee":{
+			"id":1,"email":"user2@example.com","role":"ENGINEER","hiredAt":"2024-01-01T00:00:00Z",
+			"pets":[{"__typename":"Cat","name":"name 3","lives":4}]
+		}}}`, string(payload))
+	})
+
+	t.Run("keeps the fields of the representations", func(t *testing.T) {
+		t.Parallel()
+
+		payload, err := mocks.generate(schema, []byte(`{
+			"query":"query($representations: [_Any!]!) { _entities(representations: $representations) { ... on Employee { __typename id role } } }",
+			"variables":{"representations":[{"__typename":"Employee","id":7}]}
+		}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"data":{"_entities":[{"__typename":"Employee","id":7,"role":"ENGINEER"}]}}`, string(payload))
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := mocks.generate(schema, []byte(`{"query":"subscription { employee }"}`))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+// SubgraphResponseTooLargeErrorCode is the code in the extensions of the error that replaces a subgraph response
+// over the maximum size. It is distinct from ResponseTooLargeErrorCode, which limits the responses to the clients.
+const SubgraphResponseTooLargeErrorCode = "SUBGRAPH_RESPONSE_TOO_LARGE"
+
+const subgraphPayloadSizeLoggerName = "subgraph_payload_size"
+
+type SubgraphPayloadSizeOptions struct {
+	Config      *config.SubgraphPayloadSizeConfiguration
+	MetricStore metric.Provider
+	Logger      *zap.Logger
+}
+
+// SubgraphPayloadSize records the bytes sent to and received from the subgraphs and replaces the subgraph responses
+// over the maximum size with an error, before the engine buffers them.
+type SubgraphPayloadSize struct {
+	metricStore metric.Provider
+	logger      *zap.Logger
+	// maxResponseSize is the maximum response size of the subgraphs without an override. Zero doesn't limit it.
+	maxResponseSize int64
+	// subgraphs are the maximum response sizes of the overrides by subgraph name
+	subgraphs map[string]int64
+}
+
+func NewSubgraphPayloadSize(opts *SubgraphPayloadSizeOptions) *SubgraphPayloadSize {
+	metricStore := opts.MetricStore
+	if metricStore == nil {
+		metricStore = metric.NewNoopMetrics()
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	s := &SubgraphPayloadSize{
+		metricStore:     metricStore,
+		logger:          logger.Named(subgraphPayloadSizeLoggerName),
+		maxResponseSize: int64(opts.Config.MaxResponseSize),
+		subgraphs:       make(map[string]int64, len(opts.Config.Subgraphs)),
+	}
+	for name, subgraph := range opts.Config.Subgraphs {
+		s.subgraphs[name] = int64(subgraph.MaxResponseSize)
+	}
+
+	return s
+}
+
+// MaxResponseSize returns the maximum response size of the subgraph. Zero doesn't limit the size.
+func (s *SubgraphPayloadSize) MaxResponseSize(subgraphName string) int64 {
+	if maxSize, ok := s.subgraphs[subgraphName]; ok {
+		return maxSize
+	}
+	return s.maxResponseSize
+}
+
+// RoundTripper wraps the transport to the subgraphs with the measurement and the limit of the payloads
+func (s *SubgraphPayloadSize) RoundTripper(transport http.RoundTripper) http.RoundTripper {
+	return subgraphPayloadSizeTransport{size: s, transport: transport}
+}
+
+type subgraphPayloadSizeTransport struct {
+	size      *SubgraphPayloadSize
+	transport http.RoundTripper
+}
+
+func (t subgraphPayloadSizeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Upgrade") != "" {
+		return t.transport.RoundTrip(req)
+	}
+
+	var attributes []attribute.KeyValue
+	var subgraphName string
+	if reqContext := getRequestContext(req.Context()); reqContext != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			subgraphName = subgraph.Name
+			attributes = append(attributes, otel.WgSubgraphName.String(subgraph.Name), otel.WgSubgraphID.String(subgraph.Id))
+		}
+	}
+
+	// The body is usually closed after the request context was canceled, which would drop the measurements
+	ctx := context.WithoutCancel(req.Context())
+	if req.ContentLength >= 0 {
+		t.size.metricStore.MeasureSubgraphRequestBytes(ctx, req.ContentLength, attributes...)
+	}
+
+	res, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize := t.size.MaxResponseSize(subgraphName)
+	// Subscriptions over SSE are streamed and can't be buffered
+	if maxSize <= 0 || req.Header.Get("Accept") == "text/event-stream" {
+		res.Body = &measuredBody{ReadCloser: res.Body, measure: func(n int64) {
+			t.size.metricStore.MeasureSubgraphResponseBytes(ctx, n, attributes...)
+		}}
+		return res, nil
+	}
+
+	// A declared length over the limit is rejected without reading the body
+	if res.ContentLength > maxSize {
+		_ = res.Body.Close()
+		t.size.metricStore.MeasureSubgraphResponseBytes(ctx, res.ContentLength, attributes...)
+		return t.size.responseTooLarge(req, res, subgraphName, maxSize), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	_ = res.Body.Close()
+	// Responses over the limit are recorded with the bytes read until the limit was exceeded
+	t.size.metricStore.MeasureSubgraphResponseBytes(ctx, int64(len(body)), attributes...)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxSize {
+		return t.size.responseTooLarge(req, res, subgraphName, maxSize), nil
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+
+	return res, nil
+}
+
+// responseTooLarge returns the response that replaces a subgraph response over the maximum size. The engine handles
+// its error like any other error of the subgraph.
+func (s *SubgraphPayloadSize) responseTooLarge(req *http.Request, res *http.Response, subgraphName string, maxSize int64) *http.Response {
+	fields := []zap.Field{
+		zap.String("subgraph_name", subgraphName),
+		zap.Int64("max_response_size", maxSize),
+	}
+	if res.ContentLength > 0 {
+		fields = append(fields, zap.Int64("content_length", res.ContentLength))
+	}
+	if reqContext := getRequestContext(req.Context()); reqContext != nil && reqContext.operation != nil {
+		fields = append(fields, zap.String("operation_name", reqContext.operation.Name()))
+	}
+	s.logger.Warn("Subgraph response exceeds the maximum size", fields...)
+
+	body := fmt.Sprintf(`{"errors":[{"message":"The response of the subgraph exceeds the maximum size of %d bytes","extensions":{"code":"%s"}}]}`,
+		maxSize, SubgraphResponseTooLargeErrorCode)
+
+	return &http.Response{
+		Status:        res.Status,
+		StatusCode:    res.StatusCode,
+		Proto:         res.Proto,
+		ProtoMajor:    res.ProtoMajor,
+		ProtoMinor:    res.ProtoMinor,
+		Header:        http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// measuredBody passes the measured number of bytes read from the body when it is closed
+type measuredBody struct {
+	io.ReadCloser
+	n       int64
+	measure func(n int64)
+}
+
+func (b *measuredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *measuredBody) Close() error {
+	if b.measure != nil {
+		b.measure(b.n)
+		b.measure = nil
+	}
+	return b.ReadCloser.Close()
+}
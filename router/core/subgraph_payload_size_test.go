@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+)
+
+type payloadSizeMetrics struct {
+	metric.NoopMetrics
+	mu        sync.Mutex
+	requests  []int64
+	responses []int64
+}
+
+func (m *payloadSizeMetrics) MeasureSubgraphRequestBytes(_ context.Context, size int64, _ ...attribute.KeyValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, size)
+}
+
+func (m *payloadSizeMetrics) MeasureSubgraphResponseBytes(_ context.Context, size int64, _ ...attribute.KeyValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, size)
+}
+
+func TestSubgraphPayloadSize(t *testing.T) {
+	t.Parallel()
+
+	body := `{"data":{"employees":[` + strings.Repeat(`{"id":1},`, 100) + `{"id":2}]}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("chunked") {
+			// Flushing before the end of the body omits the Content-Length
+			_, _ = w.Write([]byte(body[:10]))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(body[10:]))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	roundTrip := func(t *testing.T, s *SubgraphPayloadSize, query string) string {
+		req, err := http.NewRequest(http.MethodPost, server.URL+query, strings.NewReader(`{"query":"{employees{id}}"}`))
+		require.NoError(t, err)
+		res, err := s.RoundTripper(http.DefaultTransport).RoundTrip(req)
+		require.NoError(t, err)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return string(data)
+	}
+
+	t.Run("measures the payloads", func(t *testing.T) {
+		t.Parallel()
+
+		metrics := &payloadSizeMetrics{}
+		s := NewSubgraphPayloadSize(&SubgraphPayloadSizeOptions{
+			Config:      &config.SubgraphPayloadSizeConfiguration{},
+			MetricStore: metrics,
+		})
+
+		require.Equal(t, body, roundTrip(t, s, "?chunked"))
+		require.Equal(t, []int64{int64(len(`{"query":"{employees{id}}"}`))}, metrics.requests)
+		require.Equal(t, []int64{int64(len(body))}, metrics.responses)
+	})
+
+	t.Run("replaces the responses over the limit", func(t *testing.T) {
+		t.Parallel()
+
+		tooLarge := `{"errors":[{"message":"The response of the subgraph exceeds the maximum size of 100 bytes","extensions":{"code":"SUBGRAPH_RESPONSE_TOO_LARGE"}}]}`
+		for _, query := range []string{"", "?chunked"} {
+			metrics := &payloadSizeMetrics{}
+			s := NewSubgraphPayloadSize(&SubgraphPayloadSizeOptions{
+				Config:      &config.SubgraphPayloadSizeConfiguration{MaxResponseSize: 100},
+				MetricStore: metrics,
+			})
+
+			require.Equal(t, tooLarge, roundTrip(t, s, query), query)
+			require.Len(t, metrics.responses, 1)
+			require.Greater(t, metrics.responses[0], int64(100), query)
+		}
+
+		// Within the limit the response is passed on
+		s := NewSubgraphPayloadSize(&SubgraphPayloadSizeOptions{
+			Config: &config.SubgraphPayloadSizeConfiguration{MaxResponseSize: config.BytesString(len(body))},
+		})
+		require.Equal(t, body, roundTrip(t, s, "?chunked"))
+	})
+
+	t.Run("overrides the limit by subgraph", func(t *testing.T) {
+		t.Parallel()
+
+		s := NewSubgraphPayloadSize(&SubgraphPayloadSizeOptions{
+			Config: &config.SubgraphPayloadSizeConfiguration{
+				MaxResponseSize: 100,
+				Subgraphs: map[string]config.SubgraphPayloadSize{
+					"employees": {MaxResponseSize: 1000},
+					"products":  {},
+				},
+			},
+		})
+
+		require.Equal(t, int64(100), s.MaxResponseSize("family"))
+		require.Equal(t, int64(1000), s.MaxResponseSize("employees"))
+		require.Zero(t, s.MaxResponseSize("products"))
+	})
+}
@@ -0,0 +1,464 @@
+package core
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/wundergraph/cosmo/router/pkg/metric"
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const subgraphResponseValidationLoggerName = "subgraph_response_validation"
+
+type SubgraphResponseValidatorOptions struct {
+	Logger      *zap.Logger
+	MetricStore metric.Provider
+	// SampleRate is the share of the subgraph responses that are validated between 0 and 1
+	SampleRate float64
+	// MaxLoggedViolations limits the violations that are logged of a single response
+	MaxLoggedViolations int
+}
+
+// SubgraphResponseValidator validates the responses of the subgraphs against the types and nullability of the
+// federated schema. Violations are logged with the subgraph name and counted, the responses are passed to the engine
+// unchanged. It catches subgraphs that drifted from the contract composed into the federated graph.
+type SubgraphResponseValidator struct {
+	logger              *zap.Logger
+	metricStore         metric.Provider
+	sampleRate          float64
+	maxLoggedViolations int
+
+	// definition is the router schema. It is set when the executor was built, before the first request.
+	definition atomic.Pointer[ast.Document]
+}
+
+type subgraphResponseViolation struct {
+	path    string
+	message string
+}
+
+func (v subgraphResponseViolation) String() string {
+	return v.path + ": " + v.message
+}
+
+func NewSubgraphResponseValidator(opts *SubgraphResponseValidatorOptions) (*SubgraphResponseValidator, error) {
+	if opts.SampleRate < 0 || opts.SampleRate > 1 {
+		return nil, fmt.Errorf("the subgraph response validation sample rate must be between 0 and 1, got %v", opts.SampleRate)
+	}
+
+	metricStore := opts.MetricStore
+	if metricStore == nil {
+		metricStore = metric.NewNoopMetrics()
+	}
+
+	return &SubgraphResponseValidator{
+		logger:              opts.Logger.Named(subgraphResponseValidationLoggerName),
+		metricStore:         metricStore,
+		sampleRate:          opts.SampleRate,
+		maxLoggedViolations: opts.MaxLoggedViolations,
+	}, nil
+}
+
+func (v *SubgraphResponseValidator) setDefinition(definition *ast.Document) {
+	v.definition.Store(definition)
+}
+
+// ValidateResponse validates the response of a subgraph request. The body of the response is restored.
+func (v *SubgraphResponseValidator) ValidateResponse(req *http.Request, res *http.Response) error {
+	definition := v.definition.Load()
+	if definition == nil || res.StatusCode != http.StatusOK || req.GetBody == nil {
+		return nil
+	}
+	if req.Header.Get("Upgrade") != "" || req.Header.Get("Accept") == "text/event-stream" {
+		return nil
+	}
+	if v.sampleRate < 1 && rand.Float64() >= v.sampleRate {
+		return nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	data, err := decodeResponseBody(res.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		return nil
+	}
+
+	reqBody, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer reqBody.Close()
+
+	var request struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(reqBody).Decode(&request); err != nil || request.Query == "" {
+		return nil
+	}
+
+	violations, ok := validateSubgraphResponse(definition, request.Query, data)
+	if !ok || len(violations) == 0 {
+		return nil
+	}
+
+	var (
+		subgraphName string
+		attributes   []attribute.KeyValue
+	)
+	reqContext := getRequestContext(req.Context())
+	if reqContext != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			subgraphName = subgraph.Name
+			attributes = append(attributes, otel.WgSubgraphName.String(subgraph.Name), otel.WgSubgraphID.String(subgraph.Id))
+		}
+	}
+
+	v.metricStore.MeasureSubgraphResponseViolations(req.Context(), int64(len(violations)), attributes...)
+
+	logged := violations
+	if v.maxLoggedViolations > 0 && len(logged) > v.maxLoggedViolations {
+		logged = logged[:v.maxLoggedViolations]
+	}
+	messages := make([]string, len(logged))
+	for i := range logged {
+		messages[i] = logged[i].String()
+	}
+
+	fields := []zap.Field{
+		zap.String("subgraph_name", subgraphName),
+		zap.Int("violation_count", len(violations)),
+		zap.Strings("violations", messages),
+	}
+	if reqContext != nil && reqContext.operation != nil {
+		fields = append(fields, zap.String("operation_name", reqContext.operation.Name()))
+	}
+	v.logger.Warn("Subgraph response violates the federated schema", fields...)
+
+	return nil
+}
+
+func decodeResponseBody(encoding string, body []byte) ([]byte, error) {
+	var r io.ReadCloser
+	switch encoding {
+	case "":
+		return body, nil
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// validateSubgraphResponse validates the data of a response against the selections of the subgraph query. It returns
+// false if the query or the response can't be parsed.
+func validateSubgraphResponse(definition *ast.Document, query string, body []byte) ([]subgraphResponseViolation, bool) {
+	operation, report := astparser.ParseGraphqlDocumentString(query)
+	if report.HasErrors() || len(operation.OperationDefinitions) == 0 {
+		return nil, false
+	}
+
+	var response struct {
+		Data any `json:"data"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&response); err != nil {
+		return nil, false
+	}
+	data, ok := response.Data.(map[string]any)
+	if !ok {
+		// A response without data only has errors
+		return nil, true
+	}
+
+	op := operation.OperationDefinitions[0]
+	var rootTypeName string
+	switch op.OperationType {
+	case ast.OperationTypeQuery:
+		rootTypeName = definition.Index.QueryTypeName.String()
+	case ast.OperationTypeMutation:
+		rootTypeName = definition.Index.MutationTypeName.String()
+	default:
+		return nil, true
+	}
+	if !op.HasSelections {
+		return nil, true
+	}
+
+	w := &subgraphResponseWalker{definition: definition, operation: &operation}
+	w.selectionSet(op.SelectionSet, rootTypeName, data, "")
+
+	return w.violations, true
+}
+
+type subgraphResponseWalker struct {
+	definition *ast.Document
+	operation  *ast.Document
+	violations []subgraphResponseViolation
+}
+
+func (w *subgraphResponseWalker) violation(path, format string, args ...any) {
+	w.violations = append(w.violations, subgraphResponseViolation{path: path, message: fmt.Sprintf(format, args...)})
+}
+
+func (w *subgraphResponseWalker) selectionSet(ref int, typeName string, data map[string]any, path string) {
+	for _, selectionRef := range w.operation.SelectionSets[ref].SelectionRefs {
+		selection := w.operation.Selections[selectionRef]
+		switch selection.Kind {
+		case ast.SelectionKindField:
+			w.field(selection.Ref, typeName, data, path)
+		case ast.SelectionKindInlineFragment:
+			if w.operation.InlineFragmentHasTypeCondition(selection.Ref) &&
+				!w.typeConditionApplies(w.operation.InlineFragmentTypeConditionNameString(selection.Ref), typeName) {
+				continue
+			}
+			if set, ok := w.operation.InlineFragmentSelectionSet(selection.Ref); ok {
+				w.selectionSet(set, typeName, data, path)
+			}
+		case ast.SelectionKindFragmentSpread:
+			fragmentRef, ok := w.operation.FragmentDefinitionRef(w.operation.FragmentSpreadNameBytes(selection.Ref))
+			if !ok || !w.typeConditionApplies(w.operation.FragmentDefinitionTypeNameString(fragmentRef), typeName) {
+				continue
+			}
+			w.selectionSet(w.operation.FragmentDefinitions[fragmentRef].SelectionSet, typeName, data, path)
+		}
+	}
+}
+
+func (w *subgraphResponseWalker) field(ref int, typeName string, data map[string]any, path string) {
+	name := w.operation.FieldNameString(ref)
+	key := w.operation.FieldAliasOrNameString(ref)
+	fieldPath := joinResponsePath(path, key)
+
+	value, ok := data[key]
+	if !ok {
+		// Fields with @skip or @include depend on the variables
+		if !w.operation.FieldHasDirectives(ref) {
+			w.violation(fieldPath, "the field is missing")
+		}
+		return
+	}
+	if name == "__typename" {
+		return
+	}
+
+	selectionSet, hasSelections := w.operation.FieldSelectionSet(ref)
+
+	// _entities isn't part of the federated schema. Its items are validated with their type names.
+	if name == "_entities" && typeName == w.definition.Index.QueryTypeName.String() {
+		items, ok := value.([]any)
+		if !ok {
+			w.violation(fieldPath, "expected a list, got %s", responseValueKind(value))
+			return
+		}
+		for i, item := range items {
+			itemPath := joinResponsePath(fieldPath, strconv.Itoa(i))
+			object, ok := item.(map[string]any)
+			if !ok || !hasSelections {
+				continue
+			}
+			entityTypeName, ok := object["__typename"].(string)
+			if !ok {
+				continue
+			}
+			if _, exists := w.definition.Index.FirstNodeByNameStr(entityTypeName); !exists {
+				w.violation(itemPath, "unknown type '%s'", entityTypeName)
+				continue
+			}
+			w.selectionSet(selectionSet, entityTypeName, object, itemPath)
+		}
+		return
+	}
+
+	node, ok := w.definition.Index.FirstNodeByNameStr(typeName)
+	if !ok {
+		return
+	}
+	fieldDefinition, ok := w.definition.NodeFieldDefinitionByName(node, []byte(name))
+	if !ok {
+		// Fields that only exist in the subgraph, e.g. of the federation spec, aren't validated
+		return
+	}
+
+	w.value(w.definition.FieldDefinitionType(fieldDefinition), value, selectionSet, hasSelections, fieldPath)
+}
+
+func (w *subgraphResponseWalker) value(typeRef int, value any, selectionSet int, hasSelections bool, path string) {
+	t := w.definition.Types[typeRef]
+	switch t.TypeKind {
+	case ast.TypeKindNonNull:
+		if value == nil {
+			typeName, _ := w.definition.PrintTypeBytes(typeRef, nil)
+			w.violation(path, "the non-nullable field of type '%s' is null", typeName)
+			return
+		}
+		w.value(t.OfType, value, selectionSet, hasSelections, path)
+	case ast.TypeKindList:
+		if value == nil {
+			return
+		}
+		items, ok := value.([]any)
+		if !ok {
+			w.violation(path, "expected a list, got %s", responseValueKind(value))
+			return
+		}
+		for i, item := range items {
+			w.value(t.OfType, item, selectionSet, hasSelections, joinResponsePath(path, strconv.Itoa(i)))
+		}
+	case ast.TypeKindNamed:
+		if value == nil {
+			return
+		}
+		w.named(w.definition.TypeNameString(typeRef), value, selectionSet, hasSelections, path)
+	}
+}
+
+func (w *subgraphResponseWalker) named(typeName string, value any, selectionSet int, hasSelections bool, path string) {
+	node, ok := w.definition.Index.FirstNodeByNameStr(typeName)
+	if !ok {
+		return
+	}
+
+	switch node.Kind {
+	case ast.NodeKindScalarTypeDefinition:
+		if !validScalarValue(typeName, value) {
+			w.violation(path, "expected a value of type '%s', got %s", typeName, responseValueKind(value))
+		}
+	case ast.NodeKindEnumTypeDefinition:
+		s, ok := value.(string)
+		if !ok {
+			w.violation(path, "expected a value of enum '%s', got %s", typeName, responseValueKind(value))
+			return
+		}
+		if !w.definition.EnumTypeDefinitionContainsEnumValue(node.Ref, []byte(s)) {
+			w.violation(path, "'%s' is not a value of enum '%s'", s, typeName)
+		}
+	case ast.NodeKindObjectTypeDefinition, ast.NodeKindInterfaceTypeDefinition, ast.NodeKindUnionTypeDefinition:
+		object, ok := value.(map[string]any)
+		if !ok {
+			w.violation(path, "expected an object of type '%s', got %s", typeName, responseValueKind(value))
+			return
+		}
+		concreteTypeName := typeName
+		if name, ok := object["__typename"].(string); ok {
+			if !w.typeConditionApplies(typeName, name) {
+				w.violation(path, "'%s' is not a possible type of '%s'", name, typeName)
+				return
+			}
+			concreteTypeName = name
+		}
+		if hasSelections {
+			w.selectionSet(selectionSet, concreteTypeName, object, path)
+		}
+	}
+}
+
+func (w *subgraphResponseWalker) typeConditionApplies(condition, typeName string) bool {
+	return typeConditionApplies(w.definition, condition, typeName)
+}
+
+// typeConditionApplies returns true if an object of the type matches the type condition
+func typeConditionApplies(definition *ast.Document, condition, typeName string) bool {
+	if condition == typeName {
+		return true
+	}
+	node, ok := definition.Index.FirstNodeByNameStr(condition)
+	if !ok {
+		return false
+	}
+	switch node.Kind {
+	case ast.NodeKindInterfaceTypeDefinition:
+		return definition.TypeDefinitionContainsImplementsInterface([]byte(typeName), []byte(condition))
+	case ast.NodeKindUnionTypeDefinition:
+		members, _ := definition.UnionTypeDefinitionMemberTypeNames(node.Ref)
+		for _, member := range members {
+			if member == typeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validScalarValue checks the values of the built-in scalars. Custom scalars accept any value.
+func validScalarValue(typeName string, value any) bool {
+	switch typeName {
+	case "String":
+		_, ok := value.(string)
+		return ok
+	case "ID":
+		switch value.(type) {
+		case string, json.Number:
+			return true
+		}
+		return false
+	case "Boolean":
+		_, ok := value.(bool)
+		return ok
+	case "Int":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := strconv.ParseInt(n.String(), 10, 32)
+		return err == nil
+	case "Float":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return true
+}
+
+func responseValueKind(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "a float"
+		}
+		return "an integer"
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	}
+	return "an unknown value"
+}
+
+func joinResponsePath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
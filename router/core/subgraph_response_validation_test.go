@@ -0,0 +1,192 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const subgraphResponseValidationTestSchema = `
+type Query {
+	employees: [Employee!]!
+	search: [SearchResult!]!
+}
+
+type Employee {
+	id: Int!
+	name: String
+	role: Role!
+	pets: [Pet]
+}
+
+enum Role {
+	ENGINEER
+	MANAGER
+}
+
+interface Pet {
+	name: String!
+}
+
+type Cat implements Pet {
+	name: String!
+	lives: Int!
+}
+
+type Dog implements Pet {
+	name: String!
+}
+
+union SearchResult = Employee | Cat
+`
+
+func newSubgraphResponseValidationTestSchema(t *testing.T) *ast.Document {
+	definition, report := astparser.ParseGraphqlDocumentString(subgraphResponseValidationTestSchema)
+	require.False(t, report.HasErrors(), report.Error())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+	return &definition
+}
+
+func TestValidateSubgraphResponse(t *testing.T) {
+	t.Parallel()
+
+	definition := newSubgraphResponseValidationTestSchema(t)
+
+	validate := func(t *testing.T, query, response string) []string {
+		violations, ok := validateSubgraphResponse(definition, query, []byte(response))
+		require.True(t, ok)
+		messages := make([]string, 0, len(violations))
+		for _, v := range violations {
+			messages = append(messages, v.String())
+		}
+		return messages
+	}
+
+	t.Run("accepts valid responses", func(t *testing.T) {
+		t.Parallel()
+
+		require.Empty(t, validate(t,
+			`{ employees { id staff: name role pets { __typename name ... on Cat { lives } } } }`,
+			`{"data":{"employees":[{"id":1,"staff":null,"role":"ENGINEER","pets":[{"__typename":"Cat","name":"Tom","lives":9},null,{"__typename":"Dog","name":"Rex"}]}]}}`,
+		))
+		require.Empty(t, validate(t, `{ employees { id } }`, `{"data":null,"errors":[{"message":"failed"}]}`))
+	})
+
+	t.Run("reports type and nullability violations", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, []string{
+			"employees.0.id: expected a value of type 'Int', got a string",
+			"employees.0.role: 'DESIGNER' is not a value of enum 'Role'",
+			"employees.0.pets: expected a list, got an object",
+			"employees.1.id: expected a value of type 'Int', got a float",
+			"employees.1.role: the non-nullable field of type 'Role!' is null",
+			"employees.1.pets.0.lives: the field is missing",
+			"employees.1.pets.1: 'Employee' is not a possible type of 'Pet'",
+		}, validate(t,
+			`{ employees { id role pets { name ... on Cat { lives } } } }`,
+			`{"data":{"employees":[
+				{"id":"1","role":"DESIGNER","pets":{"name":"Tom"}},
+				{"id":1.5,"role":null,"pets":[{"__typename":"Cat","name":"Tom"},{"__typename":"Employee","name":"Bob"}]}
+			]}}`,
+		))
+	})
+
+	t.Run("validates unions by the type name", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, []string{
+			"search.1.lives: expected a value of type 'Int', got a boolean",
+			"search.2: 'Dog' is not a possible type of 'SearchResult'",
+		}, validate(t,
+			`{ search { ... on Employee { id } ... on Cat { lives } } }`,
+			`{"data":{"search":[{"__typename":"Employee","id":1},{"__typename":"Cat","lives":true},{"__typename":"Dog"}]}}`,
+		))
+	})
+
+	t.Run("validates entities by the type name", func(t *testing.T) {
+		t.Parallel()
+
+		require.Equal(t, []string{
+			"_entities.1.role: the non-nullable field of type 'Role!' is null",
+			"_entities.2: unknown type 'Unknown'",
+		}, validate(t,
+			`query($representations: [_Any!]!){ _entities(representations: $representations){ __typename ... on Employee { role } } }`,
+			`{"data":{"_entities":[{"__typename":"Employee","role":"MANAGER"},{"__typename":"Employee","role":null},{"__typename":"Unknown"},null]}}`,
+		))
+	})
+
+	t.Run("ignores fields with directives", func(t *testing.T) {
+		t.Parallel()
+
+		require.Empty(t, validate(t,
+			`query($skip: Boolean!) { employees { id name @skip(if: $skip) } }`,
+			`{"data":{"employees":[{"id":1}]}}`,
+		))
+	})
+}
+
+func TestSubgraphResponseValidator(t *testing.T) {
+	t.Parallel()
+
+	logCore, logs := observer.New(zapcore.WarnLevel)
+	v, err := NewSubgraphResponseValidator(&SubgraphResponseValidatorOptions{
+		Logger:              zap.New(logCore),
+		SampleRate:          1,
+		MaxLoggedViolations: 1,
+	})
+	require.NoError(t, err)
+
+	_, err = NewSubgraphResponseValidator(&SubgraphResponseValidatorOptions{Logger: zap.NewNop(), SampleRate: 2})
+	require.Error(t, err)
+
+	body := `{"data":{"employees":[{"id":"1","role":null}]}}`
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	newExchange := func() (*http.Request, *http.Response) {
+		// The engine creates the requests with a body that can be read again
+		req, err := http.NewRequest(http.MethodPost, "http://employees/graphql", strings.NewReader(`{"query":"{ employees { id role } }"}`))
+		require.NoError(t, err)
+		res := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": []string{"gzip"}},
+			Body:       io.NopCloser(bytes.NewReader(compressed.Bytes())),
+		}
+		return req, res
+	}
+
+	// The responses aren't validated until the schema is known
+	req, res := newExchange()
+	require.NoError(t, v.ValidateResponse(req, res))
+	require.Zero(t, logs.Len())
+
+	v.setDefinition(newSubgraphResponseValidationTestSchema(t))
+
+	req, res = newExchange()
+	require.NoError(t, v.ValidateResponse(req, res))
+
+	restored, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, compressed.Bytes(), restored)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	require.Equal(t, subgraphResponseValidationLoggerName, entries[0].LoggerName)
+	require.Equal(t, int64(2), entries[0].ContextMap()["violation_count"])
+	require.Equal(t, []interface{}{"employees.0.id: expected a value of type 'Int', got a string"}, entries[0].ContextMap()["violations"])
+}
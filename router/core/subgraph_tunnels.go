@@ -0,0 +1,260 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const (
+	SubgraphTunnelTypeSOCKS5 = "socks5"
+	SubgraphTunnelTypeSSH    = "ssh"
+)
+
+// subgraphTunnel opens the connections to a subgraph through a proxy or a jump host
+type subgraphTunnel interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	Close() error
+}
+
+// SubgraphTunnels sends the requests to single subgraphs through a SOCKS5 proxy or an SSH tunnel, e.g. to reach
+// subgraphs in isolated networks during development or migrations. Every tunneled subgraph has its own connection
+// pool, the other subgraphs are requested with the shared transport.
+type SubgraphTunnels struct {
+	tunnels    map[string]subgraphTunnel
+	transports map[string]*http.Transport
+}
+
+func NewSubgraphTunnels(logger *zap.Logger, cfg config.SubgraphTunnelsConfiguration, transportOptions *SubgraphTransportOptions) (*SubgraphTunnels, error) {
+	t := &SubgraphTunnels{
+		tunnels:    make(map[string]subgraphTunnel, len(cfg.Subgraphs)),
+		transports: make(map[string]*http.Transport, len(cfg.Subgraphs)),
+	}
+
+	for name, c := range cfg.Subgraphs {
+		tunnel, err := newSubgraphTunnel(logger.With(zap.String("subgraph_name", name)), c, transportOptions.DialTimeout)
+		if err != nil {
+			_ = t.Close()
+			return nil, fmt.Errorf("invalid tunnel for subgraph '%s': %w", name, err)
+		}
+		transport := newHTTPTransport(transportOptions)
+		transport.DialContext = tunnel.DialContext
+		t.tunnels[name] = tunnel
+		t.transports[name] = transport
+	}
+
+	return t, nil
+}
+
+func newSubgraphTunnel(logger *zap.Logger, c config.SubgraphTunnel, dialTimeout time.Duration) (subgraphTunnel, error) {
+	if c.Address == "" {
+		return nil, errors.New("the tunnel requires an address")
+	}
+	password, err := readSecret(c.Password, c.PasswordFile)
+	if err != nil {
+		return nil, err
+	}
+	forward := &net.Dialer{Timeout: dialTimeout}
+
+	switch c.Type {
+	case SubgraphTunnelTypeSOCKS5:
+		var auth *proxy.Auth
+		if c.Username != "" {
+			auth = &proxy.Auth{User: c.Username, Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", c.Address, auth, forward)
+		if err != nil {
+			return nil, err
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, errors.New("the SOCKS5 dialer doesn't support contexts")
+		}
+		return socks5Tunnel{dialer: contextDialer}, nil
+	case SubgraphTunnelTypeSSH:
+		if c.Username == "" {
+			return nil, errors.New("the ssh tunnel requires a username")
+		}
+
+		var auth []ssh.AuthMethod
+		if c.PrivateKeyFile != "" {
+			key, err := os.ReadFile(c.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			signer, err := ssh.ParsePrivateKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key: %w", err)
+			}
+			auth = append(auth, ssh.PublicKeys(signer))
+		}
+		if password != "" {
+			auth = append(auth, ssh.Password(password))
+		}
+		if len(auth) == 0 {
+			return nil, errors.New("the ssh tunnel requires a private key or a password")
+		}
+
+		var hostKeyCallback ssh.HostKeyCallback
+		switch {
+		case c.KnownHostsFile != "":
+			hostKeyCallback, err = knownhosts.New(c.KnownHostsFile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid known hosts file: %w", err)
+			}
+		case c.InsecureIgnoreHostKey:
+			logger.Warn("The host key of the ssh tunnel isn't verified. This must only be used for development.")
+			hostKeyCallback = ssh.InsecureIgnoreHostKey()
+		default:
+			return nil, errors.New("the ssh tunnel requires a known hosts file to verify the host key")
+		}
+
+		return &sshTunnel{
+			logger:  logger,
+			address: c.Address,
+			dialer:  forward,
+			config: &ssh.ClientConfig{
+				User:            c.Username,
+				Auth:            auth,
+				HostKeyCallback: hostKeyCallback,
+				Timeout:         dialTimeout,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown type '%s'", c.Type)
+	}
+}
+
+// RoundTripper sends the requests to the tunneled subgraphs with their own transports
+func (t *SubgraphTunnels) RoundTripper(transport http.RoundTripper) http.RoundTripper {
+	return subgraphTunnelsTransport{tunnels: t, transport: transport}
+}
+
+type subgraphTunnelsTransport struct {
+	tunnels   *SubgraphTunnels
+	transport http.RoundTripper
+}
+
+func (t subgraphTunnelsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqContext := getRequestContext(req.Context())
+	if reqContext == nil {
+		return t.transport.RoundTrip(req)
+	}
+	subgraph := reqContext.ActiveSubgraph(req)
+	if subgraph == nil {
+		return t.transport.RoundTrip(req)
+	}
+	transport, ok := t.tunnels.transports[subgraph.Name]
+	if !ok {
+		return t.transport.RoundTrip(req)
+	}
+	return transport.RoundTrip(req)
+}
+
+// Close closes the idle connections and the ssh connections of the tunnels
+func (t *SubgraphTunnels) Close() error {
+	var err error
+	for _, transport := range t.transports {
+		transport.CloseIdleConnections()
+	}
+	for _, tunnel := range t.tunnels {
+		err = errors.Join(err, tunnel.Close())
+	}
+	return err
+}
+
+type socks5Tunnel struct {
+	dialer proxy.ContextDialer
+}
+
+func (t socks5Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return t.dialer.DialContext(ctx, network, addr)
+}
+
+func (t socks5Tunnel) Close() error {
+	return nil
+}
+
+// sshTunnel forwards the connections through an ssh connection to a jump host. The ssh connection is opened with
+// the first connection and opened again after it was closed.
+type sshTunnel struct {
+	logger  *zap.Logger
+	address string
+	dialer  *net.Dialer
+	config  *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+func (t *sshTunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the ssh tunnel: %w", err)
+	}
+	return client.DialContext(ctx, network, addr)
+}
+
+func (t *sshTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, errors.New("the tunnel is closed")
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	conn, err := t.dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, t.address, t.config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	t.client = client
+
+	go func() {
+		err := client.Wait()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.client == client {
+			t.client = nil
+			if !t.closed {
+				t.logger.Warn("The ssh tunnel was closed. It is opened again with the next request.", zap.Error(err))
+			}
+		}
+	}()
+
+	return client, nil
+}
+
+func (t *sshTunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
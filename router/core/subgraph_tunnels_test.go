@@ -0,0 +1,159 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSubgraphTunnelsConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []config.SubgraphTunnel{
+		{Type: "http", Address: "localhost:1080"},
+		{Type: SubgraphTunnelTypeSOCKS5},
+		{Type: SubgraphTunnelTypeSSH, Address: "localhost:22", Password: "secret", InsecureIgnoreHostKey: true},
+		{Type: SubgraphTunnelTypeSSH, Address: "localhost:22", Username: "router", InsecureIgnoreHostKey: true},
+		{Type: SubgraphTunnelTypeSSH, Address: "localhost:22", Username: "router", Password: "secret"},
+		{Type: SubgraphTunnelTypeSSH, Address: "localhost:22", Username: "router", PrivateKeyFile: "does-not-exist", InsecureIgnoreHostKey: true},
+	} {
+		_, err := NewSubgraphTunnels(zap.NewNop(), config.SubgraphTunnelsConfiguration{
+			Subgraphs: map[string]config.SubgraphTunnel{"employees": c},
+		}, DefaultSubgraphTransportOptions())
+		require.Error(t, err)
+	}
+
+	tunnels, err := NewSubgraphTunnels(zap.NewNop(), config.SubgraphTunnelsConfiguration{
+		Subgraphs: map[string]config.SubgraphTunnel{
+			"employees": {Type: SubgraphTunnelTypeSOCKS5, Address: "localhost:1080"},
+			"products":  {Type: SubgraphTunnelTypeSSH, Address: "localhost:22", Username: "router", Password: "secret", InsecureIgnoreHostKey: true},
+		},
+	}, DefaultSubgraphTransportOptions())
+	require.NoError(t, err)
+	require.Len(t, tunnels.transports, 2)
+	require.NoError(t, tunnels.Close())
+}
+
+func TestSubgraphTunnelsSOCKS5(t *testing.T) {
+	t.Parallel()
+
+	subgraph := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	t.Cleanup(subgraph.Close)
+
+	// The tunneled subgraph is only reachable through the proxy
+	proxyAddr, requested := startTestSOCKS5Proxy(t, subgraph.Listener.Addr().String())
+
+	tunnels, err := NewSubgraphTunnels(zap.NewNop(), config.SubgraphTunnelsConfiguration{
+		Subgraphs: map[string]config.SubgraphTunnel{
+			"employees": {Type: SubgraphTunnelTypeSOCKS5, Address: proxyAddr},
+		},
+	}, DefaultSubgraphTransportOptions())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tunnels.Close() })
+
+	employeesURL, err := url.Parse("http://employees.internal:4001/graphql")
+	require.NoError(t, err)
+	productsURL, err := url.Parse(subgraph.URL + "/graphql")
+	require.NoError(t, err)
+
+	reqContext := &requestContext{subgraphs: []Subgraph{
+		{Id: "0", Name: "employees", Url: employeesURL},
+		{Id: "1", Name: "products", Url: productsURL},
+	}}
+	ctx := withRequestContext(context.Background(), reqContext)
+	client := &http.Client{Transport: tunnels.RoundTripper(http.DefaultTransport)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, employeesURL.String(), nil)
+	require.NoError(t, err)
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, "employees.internal:4001", string(body))
+	require.Equal(t, "employees.internal:4001", <-requested)
+
+	// Subgraphs without a tunnel are requested directly
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, productsURL.String(), nil)
+	require.NoError(t, err)
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Empty(t, requested)
+}
+
+// startTestSOCKS5Proxy starts a SOCKS5 proxy without authentication that connects every request to the target and
+// reports the requested addresses
+func startTestSOCKS5Proxy(t *testing.T, target string) (string, chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	requested := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+				// Greeting: version, number of methods and the methods
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+					return
+				}
+				if _, err := conn.Write([]byte{5, 0}); err != nil {
+					return
+				}
+
+				// Connect request with a domain name: version, command, reserved, address type and length
+				request := make([]byte, 5)
+				if _, err := io.ReadFull(conn, request); err != nil || request[3] != 3 {
+					return
+				}
+				host := make([]byte, request[4]+2)
+				if _, err := io.ReadFull(conn, host); err != nil {
+					return
+				}
+				port := binary.BigEndian.Uint16(host[len(host)-2:])
+				requested <- net.JoinHostPort(string(host[:len(host)-2]), strconv.Itoa(int(port)))
+
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+					return
+				}
+				go func() { _, _ = io.Copy(upstream, conn) }()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), requested
+}
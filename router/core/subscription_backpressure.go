@@ -0,0 +1,288 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const (
+	// BackpressureStrategyBlock waits until the client catches up, which holds up the events of the same trigger
+	BackpressureStrategyBlock = "block"
+	// BackpressureStrategyDropOldest drops the oldest buffered event
+	BackpressureStrategyDropOldest = "drop_oldest"
+	// BackpressureStrategyDropNewest drops the new event
+	BackpressureStrategyDropNewest = "drop_newest"
+	// BackpressureStrategyDisconnect closes the connection of the client
+	BackpressureStrategyDisconnect = "disconnect"
+)
+
+// ErrSubscriptionBackpressure is returned to the engine when a slow client is disconnected, so that it unsubscribes
+// the subscription
+var ErrSubscriptionBackpressure = errors.New("the client can't keep up with the events of the subscription")
+
+// SubscriptionBackpressure buffers the events of every subscription and writes them to the client in the background,
+// so that a slow client doesn't hold up the delivery of the events to the other clients. The strategy bounds the
+// buffer of a client that can't keep up. The counters are kept for the lifetime of the router.
+type SubscriptionBackpressure struct {
+	logger     *zap.Logger
+	bufferSize int
+	strategy   string
+
+	dropped     atomic.Int64
+	disconnects atomic.Int64
+
+	mu            sync.Mutex
+	registrations []otelmetric.Registration
+}
+
+func NewSubscriptionBackpressure(cfg *config.SubscriptionBackpressureConfiguration, logger *zap.Logger) (*SubscriptionBackpressure, error) {
+	switch cfg.Strategy {
+	case BackpressureStrategyBlock, BackpressureStrategyDropOldest, BackpressureStrategyDropNewest, BackpressureStrategyDisconnect:
+	case "":
+		cfg.Strategy = BackpressureStrategyBlock
+	default:
+		return nil, fmt.Errorf("unknown subscription backpressure strategy '%s'", cfg.Strategy)
+	}
+	if cfg.BufferSize <= 0 {
+		return nil, fmt.Errorf("the subscription backpressure buffer size must be positive, got %d", cfg.BufferSize)
+	}
+
+	return &SubscriptionBackpressure{
+		logger:     logger,
+		bufferSize: cfg.BufferSize,
+		strategy:   cfg.Strategy,
+	}, nil
+}
+
+// Dropped returns the number of events that were dropped for slow clients
+func (b *SubscriptionBackpressure) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// Disconnects returns the number of slow clients that were disconnected
+func (b *SubscriptionBackpressure) Disconnects() int64 {
+	return b.disconnects.Load()
+}
+
+// wrap buffers the events of the writer of a subscription. The writer is written to by a goroutine until the
+// subscription is completed or the context is done. disconnect closes the connection of the client.
+func (b *SubscriptionBackpressure) wrap(ctx context.Context, writer resolve.SubscriptionResponseWriter, disconnect func()) *backpressureWriter {
+	w := &backpressureWriter{
+		writer:       writer,
+		backpressure: b,
+		disconnect:   disconnect,
+		done:         make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		w.closed = true
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	})
+	go func() {
+		defer stop()
+		w.run()
+	}()
+
+	return w
+}
+
+// backpressureWriter buffers the events of a subscription. The engine writes the events with Write and Flush, and a
+// goroutine writes them to the writer of the client.
+type backpressureWriter struct {
+	writer       resolve.SubscriptionResponseWriter
+	backpressure *SubscriptionBackpressure
+	disconnect   func()
+
+	// buf is the event that the engine writes
+	buf bytes.Buffer
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	events    [][]byte
+	completed bool
+	// finished stops the goroutine once the buffered events are written, without completing the subscription
+	finished bool
+	// closed stops the goroutine and discards the buffered events
+	closed bool
+	failed bool
+	done   chan struct{}
+}
+
+func (w *backpressureWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *backpressureWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	event := bytes.Clone(w.buf.Bytes())
+	w.buf.Reset()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed {
+		return ErrSubscriptionBackpressure
+	}
+	if len(w.events) >= w.backpressure.bufferSize {
+		switch w.backpressure.strategy {
+		case BackpressureStrategyDropOldest:
+			w.events = w.events[1:]
+			w.backpressure.dropped.Add(1)
+		case BackpressureStrategyDropNewest:
+			w.backpressure.dropped.Add(1)
+			return nil
+		case BackpressureStrategyDisconnect:
+			w.failed = true
+			w.closed = true
+			w.cond.Broadcast()
+			w.backpressure.dropped.Add(int64(len(w.events) + 1))
+			w.backpressure.disconnects.Add(1)
+			w.backpressure.logger.Debug("Disconnected a slow subscriber", zap.Int("buffered_events", len(w.events)))
+			w.events = nil
+			if w.disconnect != nil {
+				// Closing the connection can wait for the subscriptions, so it mustn't hold up the engine
+				go w.disconnect()
+			}
+			return ErrSubscriptionBackpressure
+		default:
+			for len(w.events) >= w.backpressure.bufferSize && !w.closed {
+				w.cond.Wait()
+			}
+			if w.closed {
+				return nil
+			}
+		}
+	}
+	w.events = append(w.events, event)
+	w.cond.Broadcast()
+	return nil
+}
+
+func (w *backpressureWriter) Complete() {
+	w.mu.Lock()
+	w.completed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+// finish writes the buffered events and waits for the goroutine, e.g. before the handler of an SSE subscription
+// returns. The subscription is only completed if the engine completed it.
+func (w *backpressureWriter) finish() {
+	w.mu.Lock()
+	w.finished = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+func (w *backpressureWriter) run() {
+	defer close(w.done)
+
+	for {
+		w.mu.Lock()
+		for len(w.events) == 0 && !w.completed && !w.finished && !w.closed {
+			w.cond.Wait()
+		}
+		if w.closed {
+			w.mu.Unlock()
+			return
+		}
+		if len(w.events) == 0 {
+			completed := w.completed
+			w.mu.Unlock()
+			if completed {
+				w.writer.Complete()
+			}
+			return
+		}
+		event := w.events[0]
+		w.events = w.events[1:]
+		// Wakes up the engine, if it waits for space
+		w.cond.Broadcast()
+		w.mu.Unlock()
+
+		_, err := w.writer.Write(event)
+		if err == nil {
+			err = w.writer.Flush()
+		}
+		if err != nil {
+			// The client is gone, the next event lets the engine unsubscribe
+			w.mu.Lock()
+			w.failed = true
+			w.closed = true
+			w.events = nil
+			w.cond.Broadcast()
+			w.mu.Unlock()
+			return
+		}
+	}
+}
+
+// RegisterMetrics exposes the dropped events and the disconnected clients on the meter provider
+func (b *SubscriptionBackpressure) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterSubscriptionsMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterSubscriptionsMeterVersion),
+	)
+
+	dropped, err := meter.Int64ObservableCounter(
+		"router.subscriptions.dropped_events",
+		otelmetric.WithDescription("Number of subscription events that were dropped because the client couldn't keep up"),
+	)
+	if err != nil {
+		return err
+	}
+	disconnects, err := meter.Int64ObservableCounter(
+		"router.subscriptions.backpressure_disconnects",
+		otelmetric.WithDescription("Number of subscription clients that were disconnected because they couldn't keep up"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		strategy := otelmetric.WithAttributes(attribute.String("strategy", b.strategy))
+		o.ObserveInt64(dropped, b.Dropped(), strategy)
+		o.ObserveInt64(disconnects, b.Disconnects(), strategy)
+		return nil
+	}, dropped, disconnects)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.registrations = append(b.registrations, reg)
+	b.mu.Unlock()
+
+	return nil
+}
+
+func (b *SubscriptionBackpressure) Shutdown() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var err error
+	for _, reg := range b.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	b.registrations = nil
+
+	return err
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// slowSubscriptionWriter waits for the release of every event, like a client that can't keep up
+type slowSubscriptionWriter struct {
+	release chan struct{}
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	events    []string
+	completed bool
+}
+
+func (w *slowSubscriptionWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *slowSubscriptionWriter) Flush() error {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, w.buf.String())
+	w.buf.Reset()
+	return nil
+}
+
+func (w *slowSubscriptionWriter) Complete() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.completed = true
+}
+
+func (w *slowSubscriptionWriter) written() ([]string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.events...), w.completed
+}
+
+func writeBackpressureEvents(t *testing.T, w *backpressureWriter, events ...string) []error {
+	t.Helper()
+
+	errs := make([]error, 0, len(events))
+	for _, event := range events {
+		_, err := w.Write([]byte(event))
+		require.NoError(t, err)
+		errs = append(errs, w.Flush())
+	}
+	return errs
+}
+
+func TestSubscriptionBackpressure(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		strategy string
+		expected []string
+	}{
+		{strategy: BackpressureStrategyDropOldest, expected: []string{"1", "3", "4"}},
+		{strategy: BackpressureStrategyDropNewest, expected: []string{"1", "2", "3"}},
+	} {
+		tc := tc
+		t.Run(tc.strategy, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := NewSubscriptionBackpressure(&config.SubscriptionBackpressureConfiguration{BufferSize: 2, Strategy: tc.strategy}, zap.NewNop())
+			require.NoError(t, err)
+
+			client := &slowSubscriptionWriter{release: make(chan struct{})}
+			w := b.wrap(context.Background(), client, nil)
+
+			// The first event waits for the client, the next two are buffered
+			writeBackpressureEvents(t, w, "1")
+			require.Eventually(t, func() bool {
+				w.mu.Lock()
+				defer w.mu.Unlock()
+				return len(w.events) == 0
+			}, time.Second, time.Millisecond)
+			require.Equal(t, []error{nil, nil, nil}, writeBackpressureEvents(t, w, "2", "3", "4"))
+			require.Equal(t, int64(1), b.Dropped())
+
+			w.Complete()
+			close(client.release)
+			w.finish()
+
+			events, completed := client.written()
+			require.Equal(t, tc.expected, events)
+			require.True(t, completed)
+		})
+	}
+}
+
+func TestSubscriptionBackpressureDisconnect(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewSubscriptionBackpressure(&config.SubscriptionBackpressureConfiguration{BufferSize: 1, Strategy: BackpressureStrategyDisconnect}, zap.NewNop())
+	require.NoError(t, err)
+
+	disconnected := make(chan struct{})
+	client := &slowSubscriptionWriter{release: make(chan struct{})}
+	w := b.wrap(context.Background(), client, func() { close(disconnected) })
+
+	writeBackpressureEvents(t, w, "1")
+	require.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return len(w.events) == 0
+	}, time.Second, time.Millisecond)
+	errs := writeBackpressureEvents(t, w, "2", "3", "4")
+	require.Equal(t, []error{nil, ErrSubscriptionBackpressure, ErrSubscriptionBackpressure}, errs)
+
+	<-disconnected
+	close(client.release)
+	w.finish()
+
+	require.Equal(t, int64(2), b.Dropped())
+	require.Equal(t, int64(1), b.Disconnects())
+	events, completed := client.written()
+	require.Equal(t, []string{"1"}, events)
+	require.False(t, completed)
+}
+
+func TestSubscriptionBackpressureBlock(t *testing.T) {
+	t.Parallel()
+
+	b, err := NewSubscriptionBackpressure(&config.SubscriptionBackpressureConfiguration{BufferSize: 1, Strategy: BackpressureStrategyBlock}, zap.NewNop())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &slowSubscriptionWriter{release: make(chan struct{})}
+	w := b.wrap(ctx, client, nil)
+
+	writeBackpressureEvents(t, w, "1", "2")
+
+	// The engine waits for space until the subscription is canceled
+	blocked := make(chan error)
+	go func() {
+		_, _ = w.Write([]byte("3"))
+		blocked <- w.Flush()
+	}()
+	select {
+	case <-blocked:
+		t.Fatal("the event must wait for space")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	require.NoError(t, <-blocked)
+	require.Equal(t, int64(0), b.Dropped())
+
+	close(client.release)
+	w.finish()
+
+	_, err = NewSubscriptionBackpressure(&config.SubscriptionBackpressureConfiguration{BufferSize: 1, Strategy: "drop"}, zap.NewNop())
+	require.EqualError(t, err, "unknown subscription backpressure strategy 'drop'")
+}
@@ -0,0 +1,287 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+var (
+	ErrSubscriptionConnectionLimit = errors.New("too many subscription connections")
+	ErrSubscriptionLimit           = errors.New("too many active subscriptions")
+)
+
+// SubscriptionLimitErrorCode is the code in the extensions of the error of a rejected connection or subscription
+const SubscriptionLimitErrorCode = "SUBSCRIPTION_LIMIT_EXCEEDED"
+
+type SubscriptionRejectionReason string
+
+const (
+	SubscriptionRejectionConnectionLimit         SubscriptionRejectionReason = "connection_limit"
+	SubscriptionRejectionClientConnectionLimit   SubscriptionRejectionReason = "client_connection_limit"
+	SubscriptionRejectionSubscriptionLimit       SubscriptionRejectionReason = "subscription_limit"
+	SubscriptionRejectionClientSubscriptionLimit SubscriptionRejectionReason = "client_subscription_limit"
+)
+
+const (
+	SubscriptionLimitKeyByIP         = "ip"
+	SubscriptionLimitKeyByClientName = "client_name"
+	SubscriptionLimitKeyByClaim      = "claim"
+)
+
+const (
+	cosmoRouterSubscriptionsMeterName    = "cosmo.router.subscriptions"
+	cosmoRouterSubscriptionsMeterVersion = "0.0.1"
+)
+
+// SubscriptionLimits limits the concurrent subscription connections and the active subscriptions, globally and per
+// client. A connection is a WebSocket connection, which can carry many subscriptions, or the SSE or multipart
+// connection of a single subscription. The counts are kept for the lifetime of the router, so they survive config
+// swaps.
+type SubscriptionLimits struct {
+	maxConnections            int
+	maxConnectionsPerClient   int
+	maxSubscriptions          int
+	maxSubscriptionsPerClient int
+	keyBy                     string
+	keyClaim                  string
+
+	connectionLimit         atomic.Int64
+	clientConnectionLimit   atomic.Int64
+	subscriptionLimit       atomic.Int64
+	clientSubscriptionLimit atomic.Int64
+
+	mu                     sync.Mutex
+	connections            int
+	connectionsPerClient   map[string]int
+	subscriptions          int
+	subscriptionsPerClient map[string]int
+	registrations          []otelmetric.Registration
+}
+
+func NewSubscriptionLimits(cfg *config.SubscriptionLimitsConfiguration) (*SubscriptionLimits, error) {
+	switch cfg.KeyBy {
+	case SubscriptionLimitKeyByIP, SubscriptionLimitKeyByClientName, SubscriptionLimitKeyByClaim, "":
+	default:
+		return nil, fmt.Errorf("unknown subscription limits key_by '%s'", cfg.KeyBy)
+	}
+
+	return &SubscriptionLimits{
+		maxConnections:            cfg.MaxConnections,
+		maxConnectionsPerClient:   cfg.MaxConnectionsPerClient,
+		maxSubscriptions:          cfg.MaxSubscriptions,
+		maxSubscriptionsPerClient: cfg.MaxSubscriptionsPerClient,
+		keyBy:                     cfg.KeyBy,
+		keyClaim:                  cfg.KeyClaim,
+		connectionsPerClient:      map[string]int{},
+		subscriptionsPerClient:    map[string]int{},
+	}, nil
+}
+
+// Enabled reports whether any limit is set
+func (l *SubscriptionLimits) Enabled() bool {
+	return l != nil && (l.maxConnections > 0 || l.maxConnectionsPerClient > 0 || l.maxSubscriptions > 0 || l.maxSubscriptionsPerClient > 0)
+}
+
+// Rejections returns the number of rejected connections or subscriptions for the given reason
+func (l *SubscriptionLimits) Rejections(reason SubscriptionRejectionReason) int64 {
+	switch reason {
+	case SubscriptionRejectionConnectionLimit:
+		return l.connectionLimit.Load()
+	case SubscriptionRejectionClientConnectionLimit:
+		return l.clientConnectionLimit.Load()
+	case SubscriptionRejectionSubscriptionLimit:
+		return l.subscriptionLimit.Load()
+	case SubscriptionRejectionClientSubscriptionLimit:
+		return l.clientSubscriptionLimit.Load()
+	default:
+		return 0
+	}
+}
+
+// clientKey returns the client of the request the limits per client apply to. The authentication must have
+// happened before, for the claim to be known.
+func (l *SubscriptionLimits) clientKey(r *http.Request) string {
+	switch l.keyBy {
+	case SubscriptionLimitKeyByClientName:
+		return NewClientInfoFromRequest(r).Name
+	case SubscriptionLimitKeyByClaim:
+		if auth := authentication.FromContext(r.Context()); auth != nil {
+			client, _ := auth.Claims()[l.keyClaim].(string)
+			return client
+		}
+		return ""
+	default:
+		return botClientKey(r)
+	}
+}
+
+// acquireConnection takes a connection slot of the client. The returned function releases it and can be called
+// more than once.
+func (l *SubscriptionLimits) acquireConnection(client string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxConnections > 0 && l.connections >= l.maxConnections {
+		l.connectionLimit.Add(1)
+		return nil, ErrSubscriptionConnectionLimit
+	}
+	if l.maxConnectionsPerClient > 0 && l.connectionsPerClient[client] >= l.maxConnectionsPerClient {
+		l.clientConnectionLimit.Add(1)
+		return nil, ErrSubscriptionConnectionLimit
+	}
+
+	l.connections++
+	l.connectionsPerClient[client]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.connections--
+			releaseClient(l.connectionsPerClient, client)
+		})
+	}, nil
+}
+
+// acquireSubscription takes a subscription slot of the client. The returned function releases it and can be called
+// more than once.
+func (l *SubscriptionLimits) acquireSubscription(client string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxSubscriptions > 0 && l.subscriptions >= l.maxSubscriptions {
+		l.subscriptionLimit.Add(1)
+		return nil, ErrSubscriptionLimit
+	}
+	if l.maxSubscriptionsPerClient > 0 && l.subscriptionsPerClient[client] >= l.maxSubscriptionsPerClient {
+		l.clientSubscriptionLimit.Add(1)
+		return nil, ErrSubscriptionLimit
+	}
+
+	l.subscriptions++
+	l.subscriptionsPerClient[client]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.subscriptions--
+			releaseClient(l.subscriptionsPerClient, client)
+		})
+	}, nil
+}
+
+// acquireStream takes the connection and the subscription slot of an SSE or multipart subscription
+func (l *SubscriptionLimits) acquireStream(client string) (func(), error) {
+	releaseConnection, err := l.acquireConnection(client)
+	if err != nil {
+		return nil, err
+	}
+	releaseSubscription, err := l.acquireSubscription(client)
+	if err != nil {
+		releaseConnection()
+		return nil, err
+	}
+	return func() {
+		releaseSubscription()
+		releaseConnection()
+	}, nil
+}
+
+func releaseClient(counts map[string]int, client string) {
+	if counts[client] <= 1 {
+		delete(counts, client)
+		return
+	}
+	counts[client]--
+}
+
+// subscriptionLimitErrors returns the errors of a rejected connection or subscription
+func subscriptionLimitErrors(err error) []graphqlError {
+	return []graphqlError{{
+		Message:    err.Error(),
+		Extensions: &Extensions{Code: SubscriptionLimitErrorCode},
+	}}
+}
+
+// writeSubscriptionLimitError rejects the request of a connection above the limits
+func writeSubscriptionLimitError(w http.ResponseWriter, err error, requestLogger *zap.Logger) {
+	requestLogger.Debug("Rejected subscription connection", zap.Error(err))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+	if err := json.NewEncoder(w).Encode(GraphQLErrorResponse{Errors: subscriptionLimitErrors(err)}); err != nil {
+		requestLogger.Debug("Failed to write the subscription limit error", zap.Error(err))
+	}
+}
+
+// RegisterMetrics exposes the rejections on the meter provider
+func (l *SubscriptionLimits) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterSubscriptionsMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterSubscriptionsMeterVersion),
+	)
+
+	rejections, err := meter.Int64ObservableCounter(
+		"router.subscriptions.rejections",
+		otelmetric.WithDescription("Number of subscription connections and subscriptions rejected by the subscription limits"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		for _, reason := range []SubscriptionRejectionReason{
+			SubscriptionRejectionConnectionLimit,
+			SubscriptionRejectionClientConnectionLimit,
+			SubscriptionRejectionSubscriptionLimit,
+			SubscriptionRejectionClientSubscriptionLimit,
+		} {
+			// The series are only exported once a rejection happened
+			count := l.Rejections(reason)
+			if count == 0 {
+				continue
+			}
+			o.ObserveInt64(rejections, count, otelmetric.WithAttributes(
+				attribute.String("reason", string(reason)),
+			))
+		}
+		return nil
+	}, rejections)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.registrations = append(l.registrations, reg)
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *SubscriptionLimits) Shutdown() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var err error
+	for _, reg := range l.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	l.registrations = nil
+
+	return err
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestSubscriptionLimits(t *testing.T) {
+	t.Parallel()
+
+	l, err := NewSubscriptionLimits(&config.SubscriptionLimitsConfiguration{
+		MaxConnections:            3,
+		MaxConnectionsPerClient:   2,
+		MaxSubscriptions:          2,
+		MaxSubscriptionsPerClient: 5,
+		KeyBy:                     SubscriptionLimitKeyByIP,
+	})
+	require.NoError(t, err)
+	require.True(t, l.Enabled())
+
+	releaseA1, err := l.acquireConnection("a")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("a")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("a")
+	require.ErrorIs(t, err, ErrSubscriptionConnectionLimit)
+	require.Equal(t, int64(1), l.Rejections(SubscriptionRejectionClientConnectionLimit))
+
+	_, err = l.acquireConnection("b")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("c")
+	require.ErrorIs(t, err, ErrSubscriptionConnectionLimit)
+	require.Equal(t, int64(1), l.Rejections(SubscriptionRejectionConnectionLimit))
+
+	// A release frees the slot once
+	releaseA1()
+	releaseA1()
+	_, err = l.acquireConnection("c")
+	require.NoError(t, err)
+	_, err = l.acquireConnection("c")
+	require.ErrorIs(t, err, ErrSubscriptionConnectionLimit)
+
+	releaseSubscription, err := l.acquireSubscription("a")
+	require.NoError(t, err)
+	_, err = l.acquireSubscription("b")
+	require.NoError(t, err)
+	_, err = l.acquireSubscription("c")
+	require.ErrorIs(t, err, ErrSubscriptionLimit)
+	require.Equal(t, int64(1), l.Rejections(SubscriptionRejectionSubscriptionLimit))
+	releaseSubscription()
+	_, err = l.acquireSubscription("c")
+	require.NoError(t, err)
+
+	_, err = NewSubscriptionLimits(&config.SubscriptionLimitsConfiguration{KeyBy: "header"})
+	require.EqualError(t, err, "unknown subscription limits key_by 'header'")
+}
+
+func TestSubscriptionLimitsStream(t *testing.T) {
+	t.Parallel()
+
+	l, err := NewSubscriptionLimits(&config.SubscriptionLimitsConfiguration{
+		MaxSubscriptionsPerClient: 1,
+		KeyBy:                     SubscriptionLimitKeyByClientName,
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set("graphql-client-name", "dashboard")
+	client := l.clientKey(req)
+	require.Equal(t, "dashboard", client)
+
+	release, err := l.acquireStream(client)
+	require.NoError(t, err)
+	_, err = l.acquireStream(client)
+	require.ErrorIs(t, err, ErrSubscriptionLimit)
+	require.Equal(t, int64(1), l.Rejections(SubscriptionRejectionClientSubscriptionLimit))
+
+	// The connection of the rejected stream is released too
+	release()
+	require.Empty(t, l.connectionsPerClient)
+	require.Empty(t, l.subscriptionsPerClient)
+
+	rec := httptest.NewRecorder()
+	writeSubscriptionLimitError(rec, err, zap.NewNop())
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	var response GraphQLErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Errors, 1)
+	require.Equal(t, "too many active subscriptions", response.Errors[0].Message)
+	require.Equal(t, SubscriptionLimitErrorCode, response.Errors[0].Extensions.Code)
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+)
+
+const (
+	// ReapReasonNoSubscriptions is the reason of a reaped WebSocket connection without subscriptions
+	ReapReasonNoSubscriptions = "no_subscriptions"
+	// ReapReasonNoEvents is the reason of a reaped connection whose subscriptions received no events
+	ReapReasonNoEvents = "no_events"
+)
+
+type SubscriptionReaperOptions struct {
+	Logger *zap.Logger
+	// IdleTimeout closes the connections without events and client messages for this long
+	IdleTimeout time.Duration
+	// Interval is the period in which the connections are checked
+	Interval time.Duration
+}
+
+// SubscriptionReaper closes the subscription connections that received no events and no messages of the client,
+// e.g. pings, for the idle timeout. It reclaims the resources of abandoned clients whose connections are kept open.
+type SubscriptionReaper struct {
+	logger      *zap.Logger
+	idleTimeout time.Duration
+	interval    time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	trackers map[*idleTracker]struct{}
+	reaped   atomic.Int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// idleTracker tracks the last activity of a subscription connection
+type idleTracker struct {
+	lastActivity atomic.Int64
+	now          func() time.Time
+	protocol     string
+	fields       []zap.Field
+	// subscriptions returns the number of active subscriptions of the connection
+	subscriptions func() int
+	// close closes the connection
+	close func()
+}
+
+func NewSubscriptionReaper(opts *SubscriptionReaperOptions) *SubscriptionReaper {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &SubscriptionReaper{
+		logger:      opts.Logger,
+		idleTimeout: opts.IdleTimeout,
+		interval:    interval,
+		now:         time.Now,
+		trackers:    map[*idleTracker]struct{}{},
+	}
+}
+
+// Reaped returns the number of reaped connections
+func (r *SubscriptionReaper) Reaped() int64 {
+	return r.reaped.Load()
+}
+
+// track starts to track the activity of a connection until it's untracked. The fields identify the connection in
+// the log entry of the reap.
+func (r *SubscriptionReaper) track(protocol string, subscriptions func() int, close func(), fields ...zap.Field) *idleTracker {
+	t := &idleTracker{
+		now:           r.now,
+		protocol:      protocol,
+		fields:        fields,
+		subscriptions: subscriptions,
+		close:         close,
+	}
+	t.touch()
+
+	r.mu.Lock()
+	r.trackers[t] = struct{}{}
+	r.mu.Unlock()
+
+	return t
+}
+
+func (r *SubscriptionReaper) untrack(t *idleTracker) {
+	if t == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.trackers, t)
+	r.mu.Unlock()
+}
+
+// touch records an activity of the connection. It can be called on a nil tracker, when reaping is disabled.
+func (t *idleTracker) touch() {
+	if t == nil {
+		return
+	}
+	t.lastActivity.Store(t.now().UnixNano())
+}
+
+// reap closes the connections that are idle for longer than the idle timeout
+func (r *SubscriptionReaper) reap() {
+	now := r.now()
+
+	var idle []*idleTracker
+	r.mu.Lock()
+	for t := range r.trackers {
+		if now.Sub(time.Unix(0, t.lastActivity.Load())) >= r.idleTimeout {
+			idle = append(idle, t)
+			delete(r.trackers, t)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, t := range idle {
+		subscriptions := t.subscriptions()
+		reason := ReapReasonNoEvents
+		if subscriptions == 0 {
+			reason = ReapReasonNoSubscriptions
+		}
+
+		r.logger.Info("Reaped idle subscription connection", append([]zap.Field{
+			zap.String("reason", reason),
+			zap.String("protocol", t.protocol),
+			zap.Int("subscriptions", subscriptions),
+			zap.Duration("idle", now.Sub(time.Unix(0, t.lastActivity.Load()))),
+		}, t.fields...)...)
+
+		r.reaped.Add(1)
+		t.close()
+	}
+}
+
+func (r *SubscriptionReaper) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	r.mu.Unlock()
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.reap()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (r *SubscriptionReaper) Shutdown() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// idleSubscriptionWriter records the events of an SSE or multipart subscription as activity
+type idleSubscriptionWriter struct {
+	resolve.SubscriptionResponseWriter
+	tracker *idleTracker
+}
+
+func (w *idleSubscriptionWriter) Flush() error {
+	w.tracker.touch()
+	return w.SubscriptionResponseWriter.Flush()
+}
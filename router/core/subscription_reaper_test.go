@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSubscriptionReaper(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	reaper := NewSubscriptionReaper(&SubscriptionReaperOptions{Logger: zap.New(core), IdleTimeout: time.Minute})
+	now := time.Now()
+	reaper.now = func() time.Time { return now }
+
+	var closed []string
+	reaper.track("websocket", func() int { return 0 }, func() { closed = append(closed, "abandoned") },
+		zap.String("client_name", "dashboard"),
+	)
+	reaper.track("sse", func() int { return 1 }, func() { closed = append(closed, "silent") })
+	active := reaper.track("websocket", func() int { return 2 }, func() { closed = append(closed, "active") })
+	untracked := reaper.track("websocket", func() int { return 0 }, func() { closed = append(closed, "untracked") })
+	reaper.untrack(untracked)
+
+	now = now.Add(30 * time.Second)
+	active.touch()
+	reaper.reap()
+	require.Empty(t, closed)
+
+	now = now.Add(40 * time.Second)
+	reaper.reap()
+	require.ElementsMatch(t, []string{"abandoned", "silent"}, closed)
+	require.Equal(t, int64(2), reaper.Reaped())
+
+	entries := logs.FilterField(zap.String("reason", ReapReasonNoSubscriptions)).All()
+	require.Len(t, entries, 1)
+	require.Equal(t, "dashboard", entries[0].ContextMap()["client_name"])
+	require.Equal(t, time.Minute+10*time.Second, entries[0].ContextMap()["idle"])
+	require.Equal(t, 1, logs.FilterField(zap.String("reason", ReapReasonNoEvents)).Len())
+
+	// A reaped connection is only closed once
+	now = now.Add(time.Hour)
+	reaper.reap()
+	require.Len(t, closed, 3)
+	require.Equal(t, "active", closed[2])
+}
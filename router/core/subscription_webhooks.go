@@ -0,0 +1,442 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+)
+
+// SubscriptionWebhook is a subscription that is run by the router. Its events are sent to the URL.
+type SubscriptionWebhook struct {
+	Name    string
+	URL     string
+	Headers map[string]string
+
+	Query         string
+	OperationName string
+	Variables     map[string]any
+	ClientName    string
+	// SubscriptionHeaders are the headers of the subscription request
+	SubscriptionHeaders map[string]string
+
+	// SigningKey signs the webhook requests. The requests are not signed without a secret.
+	SigningKey RequestSigningKey
+}
+
+type SubscriptionWebhooksOptions struct {
+	Logger   *zap.Logger
+	Webhooks []SubscriptionWebhook
+	Timeout  time.Duration
+	// MaxRetries is the number of retries of an event after a failed request
+	MaxRetries int
+	// RetryInterval is the delay before the first retry. It doubles with every retry up to the MaxRetryInterval.
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+	// QueueSize is the number of buffered events per webhook
+	QueueSize       int
+	SignatureHeader string
+	TimestampHeader string
+	KeyIDHeader     string
+}
+
+// SubscriptionWebhookEvent is the payload of the webhook requests
+type SubscriptionWebhookEvent struct {
+	Webhook string `json:"webhook"`
+	// Sequence counts the events of the webhook from 1, also across restarts of the subscription, so that
+	// receivers can detect dropped events
+	Sequence  uint64    `json:"sequence"`
+	Timestamp time.Time `json:"timestamp"`
+	// Payload is the GraphQL response of the event
+	Payload json.RawMessage `json:"payload"`
+}
+
+// SubscriptionWebhooks runs subscriptions in the router and sends their events to webhooks, so that consumers get
+// push updates without holding a connection. Every webhook receives its events in order. Failed requests are
+// retried with an exponential backoff, and subscriptions that end are started again.
+type SubscriptionWebhooks struct {
+	logger           *zap.Logger
+	webhooks         []subscriptionWebhook
+	httpClient       *http.Client
+	maxRetries       int
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+	queueSize        int
+	signatureHeader  string
+	timestampHeader  string
+	keyIDHeader      string
+}
+
+type subscriptionWebhook struct {
+	SubscriptionWebhook
+	// request is the body of the subscription request
+	request []byte
+	key     *requestSigningKey
+}
+
+type subscriptionWebhookRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+func NewSubscriptionWebhooks(opts *SubscriptionWebhooksOptions) (*SubscriptionWebhooks, error) {
+	w := &SubscriptionWebhooks{
+		logger:           opts.Logger,
+		webhooks:         make([]subscriptionWebhook, 0, len(opts.Webhooks)),
+		maxRetries:       opts.MaxRetries,
+		retryInterval:    opts.RetryInterval,
+		maxRetryInterval: opts.MaxRetryInterval,
+		queueSize:        opts.QueueSize,
+		signatureHeader:  opts.SignatureHeader,
+		timestampHeader:  opts.TimestampHeader,
+		keyIDHeader:      opts.KeyIDHeader,
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	w.httpClient = &http.Client{Timeout: timeout}
+
+	if w.maxRetries < 0 {
+		w.maxRetries = 0
+	}
+	if w.retryInterval <= 0 {
+		w.retryInterval = time.Second
+	}
+	if w.maxRetryInterval < w.retryInterval {
+		w.maxRetryInterval = w.retryInterval
+	}
+	if w.queueSize <= 0 {
+		w.queueSize = 100
+	}
+
+	names := make(map[string]struct{}, len(opts.Webhooks))
+	for i, webhook := range opts.Webhooks {
+		if webhook.Name == "" {
+			return nil, fmt.Errorf("subscription webhook %d requires a name", i)
+		}
+		if _, ok := names[webhook.Name]; ok {
+			return nil, fmt.Errorf("duplicate subscription webhook '%s'", webhook.Name)
+		}
+		names[webhook.Name] = struct{}{}
+
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("subscription webhook '%s' requires an http or https url", webhook.Name)
+		}
+		if webhook.Query == "" {
+			return nil, fmt.Errorf("subscription webhook '%s' requires a query", webhook.Name)
+		}
+
+		request, err := json.Marshal(subscriptionWebhookRequest{
+			Query:         webhook.Query,
+			OperationName: webhook.OperationName,
+			Variables:     webhook.Variables,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid variables of subscription webhook '%s': %w", webhook.Name, err)
+		}
+
+		sw := subscriptionWebhook{SubscriptionWebhook: webhook, request: request}
+		if webhook.SigningKey.Secret != "" {
+			if w.signatureHeader == "" || w.timestampHeader == "" {
+				return nil, errors.New("signed subscription webhooks require a signature and a timestamp header")
+			}
+			key, err := newRequestSigningKey(webhook.SigningKey)
+			if err != nil {
+				return nil, fmt.Errorf("signing key of subscription webhook '%s': %w", webhook.Name, err)
+			}
+			sw.key = &key
+		}
+
+		w.webhooks = append(w.webhooks, sw)
+	}
+
+	return w, nil
+}
+
+// Start runs the subscriptions against the GraphQL handler of a server until the returned stop function is called.
+// The stop function blocks until the subscriptions and the pending requests are done or the context is done.
+func (w *SubscriptionWebhooks) Start(graphqlHandler http.Handler) (stop func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+
+	for i := range w.webhooks {
+		webhook := &w.webhooks[i]
+		events := make(chan SubscriptionWebhookEvent, w.queueSize)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			w.subscribe(ctx, graphqlHandler, webhook, events)
+		}()
+		go func() {
+			defer wg.Done()
+			w.deliver(ctx, webhook, events)
+		}()
+	}
+
+	return func(stopCtx context.Context) error {
+		cancel()
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	}
+}
+
+// subscribe runs the subscription of the webhook and starts it again when it ends, until the context is done
+func (w *SubscriptionWebhooks) subscribe(ctx context.Context, graphqlHandler http.Handler, webhook *subscriptionWebhook, events chan<- SubscriptionWebhookEvent) {
+	var sequence uint64
+	onEvent := func(payload []byte) {
+		sequence++
+		event := SubscriptionWebhookEvent{
+			Webhook:   webhook.Name,
+			Sequence:  sequence,
+			Timestamp: time.Now(),
+			Payload:   payload,
+		}
+		select {
+		case events <- event:
+		default:
+			w.logger.Warn("Dropped subscription webhook event, the queue is full",
+				zap.String("webhook", webhook.Name),
+				zap.Uint64("sequence", sequence),
+			)
+		}
+	}
+
+	delay := w.retryInterval
+	for {
+		started := time.Now()
+		err := w.runSubscription(ctx, graphqlHandler, webhook, onEvent)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			w.logger.Warn("Subscription of webhook failed", zap.String("webhook", webhook.Name), zap.Error(err))
+		} else {
+			w.logger.Debug("Subscription of webhook completed", zap.String("webhook", webhook.Name))
+		}
+
+		// Subscriptions that ran for a while start again after the retry interval, repeated failures back off
+		if time.Since(started) > w.maxRetryInterval {
+			delay = w.retryInterval
+		}
+		if !sleepContext(ctx, delay) {
+			return
+		}
+		delay = min(delay*2, w.maxRetryInterval)
+	}
+}
+
+func (w *SubscriptionWebhooks) runSubscription(ctx context.Context, graphqlHandler http.Handler, webhook *subscriptionWebhook, onEvent func(payload []byte)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The GraphQL handler is mounted on its own router, which routes the request by a fresh route context
+	routeCtx := chi.NewRouteContext()
+	routeCtx.RoutePath = "/"
+
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, chi.RouteCtxKey, routeCtx), http.MethodPost, "/", bytes.NewReader(webhook.request))
+	if err != nil {
+		return err
+	}
+	for name, value := range webhook.SubscriptionHeaders {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.ClientName != "" {
+		req.Header.Set("graphql-client-name", webhook.ClientName)
+	}
+
+	rw := &subscriptionWebhookResponseWriter{
+		header: make(http.Header),
+		events: &subscriptionWebhookEventWriter{onEvent: onEvent, complete: cancel},
+	}
+	graphqlHandler.ServeHTTP(rw, req)
+
+	// Responses that are written directly instead of as events are errors, e.g. of the validation
+	if rw.body.Len() > 0 {
+		return fmt.Errorf("unexpected response with status code %d: %s", rw.statusCode(), bytes.TrimSpace(rw.body.Bytes()))
+	}
+	return nil
+}
+
+// deliver sends the events of the webhook in order until the context is done
+func (w *SubscriptionWebhooks) deliver(ctx context.Context, webhook *subscriptionWebhook, events <-chan SubscriptionWebhookEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if err := w.send(ctx, webhook, event); err != nil && ctx.Err() == nil {
+				w.logger.Warn("Failed to send subscription webhook",
+					zap.String("webhook", webhook.Name),
+					zap.String("url", webhook.URL),
+					zap.Uint64("sequence", event.Sequence),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// send posts the event to the webhook and retries failed requests with an exponential backoff
+func (w *SubscriptionWebhooks) send(ctx context.Context, webhook *subscriptionWebhook, event SubscriptionWebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	delay := w.retryInterval
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, webhook, body)
+		if err == nil || !retry || attempt >= w.maxRetries {
+			return err
+		}
+		if !sleepContext(ctx, delay) {
+			return ctx.Err()
+		}
+		delay = min(delay*2, w.maxRetryInterval)
+	}
+}
+
+// post sends a single request. It reports whether a failed request can be retried.
+func (w *SubscriptionWebhooks) post(ctx context.Context, webhook *subscriptionWebhook, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	// Every attempt is signed with its own timestamp to stay within the replay window of the receiver
+	if webhook.key != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(w.signatureHeader, hex.EncodeToString(webhook.key.sign(req, timestamp, body)))
+		req.Header.Set(w.timestampHeader, timestamp)
+		if webhook.SigningKey.ID != "" && w.keyIDHeader != "" {
+			req.Header.Set(w.keyIDHeader, webhook.SigningKey.ID)
+		}
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return retry, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return false, nil
+}
+
+// sleepContext waits for the duration. It returns false when the context is done before.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// subscriptionWebhookResponseWriter is the response writer of the subscriptions of the webhooks. The events are
+// written to the subscription response writer, everything else is an error response.
+type subscriptionWebhookResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	events *subscriptionWebhookEventWriter
+}
+
+func (w *subscriptionWebhookResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *subscriptionWebhookResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *subscriptionWebhookResponseWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+func (w *subscriptionWebhookResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// SubscriptionResponseWriter makes the GraphQL handler write the events to the webhooks
+func (w *subscriptionWebhookResponseWriter) SubscriptionResponseWriter() resolve.SubscriptionResponseWriter {
+	return w.events
+}
+
+// subscriptionWebhookEventWriter buffers the response of an event until it is flushed
+type subscriptionWebhookEventWriter struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	onEvent  func(payload []byte)
+	complete context.CancelFunc
+}
+
+func (w *subscriptionWebhookEventWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *subscriptionWebhookEventWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	payload := bytes.Clone(w.buf.Bytes())
+	w.buf.Reset()
+	w.onEvent(payload)
+	return nil
+}
+
+// Complete ends the subscription request. The subscription is started again by the webhooks.
+func (w *subscriptionWebhookEventWriter) Complete() {
+	w.complete()
+}
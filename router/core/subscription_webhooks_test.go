@@ -0,0 +1,281 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+)
+
+// fakeSubscriptionHandler writes the events to the subscription response writer and completes the subscription
+func fakeSubscriptionHandler(t *testing.T, requests *atomic.Int32, events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"query":"subscription { countdown { value } }","variables":{"from":3}}`, string(body))
+		assert.Equal(t, "webhooks", r.Header.Get("graphql-client-name"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		sw, ok := w.(interface {
+			SubscriptionResponseWriter() resolve.SubscriptionResponseWriter
+		})
+		if !assert.True(t, ok) {
+			return
+		}
+		writer := sw.SubscriptionResponseWriter()
+
+		for _, event := range events {
+			_, _ = writer.Write([]byte(event))
+			assert.NoError(t, writer.Flush())
+		}
+		writer.Complete()
+
+		<-r.Context().Done()
+	}
+}
+
+type webhookReceiver struct {
+	mu     sync.Mutex
+	events []SubscriptionWebhookEvent
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var event SubscriptionWebhookEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.mu.Lock()
+	rc.events = append(rc.events, event)
+	rc.mu.Unlock()
+}
+
+func (rc *webhookReceiver) received() []SubscriptionWebhookEvent {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]SubscriptionWebhookEvent(nil), rc.events...)
+}
+
+func testSubscriptionWebhook(url string) SubscriptionWebhook {
+	return SubscriptionWebhook{
+		Name:                "countdown",
+		URL:                 url,
+		Headers:             map[string]string{"X-Webhook": "countdown"},
+		Query:               "subscription { countdown { value } }",
+		Variables:           map[string]any{"from": 3},
+		ClientName:          "webhooks",
+		SubscriptionHeaders: map[string]string{"Authorization": "Bearer token"},
+	}
+}
+
+func TestSubscriptionWebhooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sends the events signed and in order", func(t *testing.T) {
+		t.Parallel()
+
+		verifier, err := NewRequestSignatureVerifier(&RequestSignatureVerifierOptions{
+			Logger:          zap.NewNop(),
+			Keys:            []RequestSigningKey{{ID: "key-1", Secret: "secret", Algorithm: "sha512"}},
+			SignatureHeader: "X-Signature",
+			TimestampHeader: "X-Signature-Timestamp",
+			KeyIDHeader:     "X-Signature-Key-Id",
+			ReplayWindow:    time.Minute,
+		})
+		require.NoError(t, err)
+
+		receiver := &webhookReceiver{}
+		var keyIDs sync.Map
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "countdown", r.Header.Get("X-Webhook"))
+			keyIDs.Store(r.Header.Get("X-Signature-Key-Id"), true)
+			verifier.Middleware(receiver).ServeHTTP(w, r)
+		}))
+		defer server.Close()
+
+		webhook := testSubscriptionWebhook(server.URL)
+		webhook.SigningKey = RequestSigningKey{ID: "key-1", Secret: "secret", Algorithm: "sha512"}
+
+		webhooks, err := NewSubscriptionWebhooks(&SubscriptionWebhooksOptions{
+			Logger:          zap.NewNop(),
+			Webhooks:        []SubscriptionWebhook{webhook},
+			RetryInterval:   time.Hour,
+			SignatureHeader: "X-Signature",
+			TimestampHeader: "X-Signature-Timestamp",
+			KeyIDHeader:     "X-Signature-Key-Id",
+		})
+		require.NoError(t, err)
+
+		var requests atomic.Int32
+		stop := webhooks.Start(fakeSubscriptionHandler(t, &requests,
+			`{"data":{"countdown":{"value":3}}}`,
+			`{"data":{"countdown":{"value":2}}}`,
+			`{"data":{"countdown":{"value":1}}}`,
+		))
+
+		require.Eventually(t, func() bool {
+			return len(receiver.received()) == 3
+		}, 5*time.Second, 10*time.Millisecond)
+		require.NoError(t, stop(context.Background()))
+
+		events := receiver.received()
+		for i, event := range events {
+			assert.Equal(t, "countdown", event.Webhook)
+			assert.Equal(t, uint64(i+1), event.Sequence)
+			assert.False(t, event.Timestamp.IsZero())
+		}
+		assert.JSONEq(t, `{"data":{"countdown":{"value":3}}}`, string(events[0].Payload))
+		assert.JSONEq(t, `{"data":{"countdown":{"value":1}}}`, string(events[2].Payload))
+
+		_, ok := keyIDs.Load("key-1")
+		assert.True(t, ok)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("retries failed requests", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+		receiver := &webhookReceiver{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch attempts.Add(1) {
+			case 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case 2:
+				w.WriteHeader(http.StatusTooManyRequests)
+			default:
+				receiver.ServeHTTP(w, r)
+			}
+		}))
+		defer server.Close()
+
+		webhooks, err := NewSubscriptionWebhooks(&SubscriptionWebhooksOptions{
+			Logger:           zap.NewNop(),
+			Webhooks:         []SubscriptionWebhook{testSubscriptionWebhook(server.URL)},
+			MaxRetries:       2,
+			RetryInterval:    10 * time.Millisecond,
+			MaxRetryInterval: time.Hour,
+		})
+		require.NoError(t, err)
+
+		webhook := &webhooks.webhooks[0]
+		err = webhooks.send(context.Background(), webhook, SubscriptionWebhookEvent{Webhook: webhook.Name, Sequence: 1, Payload: []byte(`{}`)})
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), attempts.Load())
+
+		events := receiver.received()
+		require.Len(t, events, 1)
+		assert.Equal(t, uint64(1), events[0].Sequence)
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		webhooks, err := NewSubscriptionWebhooks(&SubscriptionWebhooksOptions{
+			Logger:        zap.NewNop(),
+			Webhooks:      []SubscriptionWebhook{testSubscriptionWebhook(server.URL)},
+			MaxRetries:    3,
+			RetryInterval: time.Hour,
+		})
+		require.NoError(t, err)
+
+		webhook := &webhooks.webhooks[0]
+		err = webhooks.send(context.Background(), webhook, SubscriptionWebhookEvent{Webhook: webhook.Name, Payload: []byte(`{}`)})
+		require.EqualError(t, err, "unexpected status code 400")
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("starts the subscription again after errors", func(t *testing.T) {
+		t.Parallel()
+
+		webhooks, err := NewSubscriptionWebhooks(&SubscriptionWebhooksOptions{
+			Logger:           zap.NewNop(),
+			Webhooks:         []SubscriptionWebhook{testSubscriptionWebhook("http://localhost")},
+			RetryInterval:    5 * time.Millisecond,
+			MaxRetryInterval: 20 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		var requests atomic.Int32
+		stop := webhooks.Start(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"unknown field"}]}`))
+		}))
+
+		require.Eventually(t, func() bool {
+			return requests.Load() >= 3
+		}, 5*time.Second, 10*time.Millisecond)
+		require.NoError(t, stop(context.Background()))
+	})
+}
+
+func TestNewSubscriptionWebhooksValidation(t *testing.T) {
+	t.Parallel()
+
+	valid := testSubscriptionWebhook("https://hooks.example.com")
+
+	cases := map[string]struct {
+		modify func(w *SubscriptionWebhook)
+		err    string
+	}{
+		"missing name": {
+			modify: func(w *SubscriptionWebhook) { w.Name = "" },
+			err:    "subscription webhook 0 requires a name",
+		},
+		"invalid url": {
+			modify: func(w *SubscriptionWebhook) { w.URL = "hooks.example.com" },
+			err:    "subscription webhook 'countdown' requires an http or https url",
+		},
+		"missing query": {
+			modify: func(w *SubscriptionWebhook) { w.Query = "" },
+			err:    "subscription webhook 'countdown' requires a query",
+		},
+		"unknown algorithm": {
+			modify: func(w *SubscriptionWebhook) {
+				w.SigningKey = RequestSigningKey{Secret: "secret", Algorithm: "md5"}
+			},
+			err: "signing key of subscription webhook 'countdown': unknown algorithm 'md5'",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			webhook := valid
+			tc.modify(&webhook)
+
+			_, err := NewSubscriptionWebhooks(&SubscriptionWebhooksOptions{
+				Logger:          zap.NewNop(),
+				Webhooks:        []SubscriptionWebhook{webhook},
+				SignatureHeader: "X-Signature",
+				TimestampHeader: "X-Signature-Timestamp",
+			})
+			require.EqualError(t, err, tc.err)
+		})
+	}
+
+	_, err := NewSubscriptionWebhooks(&SubscriptionWebhooksOptions{
+		Logger:   zap.NewNop(),
+		Webhooks: []SubscriptionWebhook{valid, valid},
+	})
+	require.EqualError(t, err, "duplicate subscription webhook 'countdown'")
+}
@@ -0,0 +1,444 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"go.uber.org/zap"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+)
+
+// SurrogateKeyPurgeHandler allows you to purge the surrogate keys of the entities of mutation responses, e.g. from a
+// CDN without an HTTP purge API. The handler is called in the background after the response was written. It requires
+// surrogate_keys.purge to be enabled.
+type SurrogateKeyPurgeHandler interface {
+	// OnPurgeSurrogateKeys is called with the keys of the entities of a mutation response
+	OnPurgeSurrogateKeys(ctx context.Context, keys []string) error
+}
+
+type SurrogateKeyPurgeEndpoint struct {
+	URL string
+	// Method defaults to POST
+	Method  string
+	Headers map[string]string
+	// KeysHeader is the header of the space separated keys. It defaults to Surrogate-Key.
+	KeysHeader string
+}
+
+type SurrogateKeysOptions struct {
+	Logger *zap.Logger
+	// Header is the response header of the keys
+	Header  string
+	MaxKeys int
+	// TypeKeys adds the names of the entity types of the response as keys
+	TypeKeys bool
+	// Purge purges the entity keys of mutation responses from the endpoints and with the purge handlers
+	Purge          bool
+	PurgeTimeout   time.Duration
+	PurgeEndpoints []SurrogateKeyPurgeEndpoint
+}
+
+// SurrogateKeys tags the responses of queries with the keys of their entities, so that CDN caches can invalidate
+// the cached responses of an entity. An object of an entity type gets the key "<type>:<key field values>" when all
+// fields of one of its keys are selected. The entity keys of mutation responses are purged in the background,
+// failures are logged and not retried.
+type SurrogateKeys struct {
+	logger         *zap.Logger
+	header         string
+	maxKeys        int
+	typeKeys       bool
+	purge          bool
+	purgeEndpoints []SurrogateKeyPurgeEndpoint
+	purgeHandlers  []SurrogateKeyPurgeHandler
+	purgeTimeout   time.Duration
+	httpClient     *http.Client
+
+	wg sync.WaitGroup
+}
+
+// EntityKeyFields are the sets of key fields by entity type name
+type EntityKeyFields map[string][][]string
+
+func NewSurrogateKeys(opts *SurrogateKeysOptions) (*SurrogateKeys, error) {
+	if opts.Header == "" {
+		return nil, errors.New("surrogate keys require a header")
+	}
+
+	endpoints := make([]SurrogateKeyPurgeEndpoint, 0, len(opts.PurgeEndpoints))
+	for i, endpoint := range opts.PurgeEndpoints {
+		if endpoint.URL == "" {
+			return nil, fmt.Errorf("surrogate key purge endpoint %d requires a url", i)
+		}
+		if endpoint.Method == "" {
+			endpoint.Method = http.MethodPost
+		}
+		if endpoint.KeysHeader == "" {
+			endpoint.KeysHeader = "Surrogate-Key"
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = 256
+	}
+	timeout := opts.PurgeTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &SurrogateKeys{
+		logger:         opts.Logger,
+		header:         opts.Header,
+		maxKeys:        maxKeys,
+		typeKeys:       opts.TypeKeys,
+		purge:          opts.Purge,
+		purgeEndpoints: endpoints,
+		purgeTimeout:   timeout,
+		httpClient:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// addPurgeHandler registers the purge handler of a module
+func (s *SurrogateKeys) addPurgeHandler(handler SurrogateKeyPurgeHandler) {
+	s.purgeHandlers = append(s.purgeHandlers, handler)
+}
+
+// NewEntityKeyFields collects the keys of the entities of all subgraphs. Keys with nested fields are ignored.
+func NewEntityKeyFields(engineConfig *nodev1.EngineConfiguration) EntityKeyFields {
+	keys := EntityKeyFields{}
+	seen := map[string]struct{}{}
+
+	for _, ds := range engineConfig.GetDatasourceConfigurations() {
+		for _, key := range ds.GetKeys() {
+			if key.GetFieldName() != "" || strings.ContainsAny(key.GetSelectionSet(), "{}") {
+				continue
+			}
+			fields := strings.Fields(key.GetSelectionSet())
+			if len(fields) == 0 {
+				continue
+			}
+			id := key.GetTypeName() + " " + strings.Join(fields, " ")
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			keys[key.GetTypeName()] = append(keys[key.GetTypeName()], fields)
+		}
+	}
+
+	return keys
+}
+
+// apply sets the keys of the response header before the response is written. The entity keys of mutations are purged.
+func (s *SurrogateKeys) apply(w http.ResponseWriter, operationCtx *operationContext, entityKeys EntityKeyFields, response []byte) {
+	isMutation := operationCtx.Type() == "mutation"
+	if isMutation && !s.purge {
+		return
+	}
+
+	keys, typeNames := surrogateKeysOfResponse(operationCtx.preparedPlan.operationDocument, operationCtx.preparedPlan.schemaDocument, entityKeys, response)
+
+	if isMutation {
+		if len(keys) > 0 {
+			s.purgeKeys(keys)
+		}
+		return
+	}
+
+	if s.typeKeys {
+		keys = append(typeNames, keys...)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if len(keys) > s.maxKeys {
+		keys = keys[:s.maxKeys]
+	}
+
+	value := strings.Join(keys, " ")
+	// Keys that were set before, e.g. propagated from a subgraph, are kept
+	if existing := w.Header().Get(s.header); existing != "" {
+		value = existing + " " + value
+	}
+	w.Header().Set(s.header, value)
+}
+
+func (s *SurrogateKeys) purgeKeys(keys []string) {
+	for _, endpoint := range s.purgeEndpoints {
+		s.wg.Add(1)
+		go func(endpoint SurrogateKeyPurgeEndpoint) {
+			defer s.wg.Done()
+
+			if err := s.sendPurge(endpoint, keys); err != nil {
+				s.logger.Warn("Failed to purge surrogate keys",
+					zap.String("url", endpoint.URL),
+					zap.Int("keys", len(keys)),
+					zap.Error(err),
+				)
+			}
+		}(endpoint)
+	}
+
+	for _, handler := range s.purgeHandlers {
+		s.wg.Add(1)
+		go func(handler SurrogateKeyPurgeHandler) {
+			defer s.wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), s.purgeTimeout)
+			defer cancel()
+
+			if err := handler.OnPurgeSurrogateKeys(ctx, keys); err != nil {
+				s.logger.Warn("Failed to purge surrogate keys with module", zap.Int("keys", len(keys)), zap.Error(err))
+			}
+		}(handler)
+	}
+}
+
+func (s *SurrogateKeys) sendPurge(endpoint SurrogateKeyPurgeEndpoint, keys []string) error {
+	body, err := json.Marshal(struct {
+		SurrogateKeys []string `json:"surrogate_keys"`
+	}{SurrogateKeys: keys})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(endpoint.Method, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range endpoint.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set(endpoint.KeysHeader, strings.Join(keys, " "))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Wait blocks until all purge requests are sent or the context is done
+func (s *SurrogateKeys) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// surrogateKeysOfResponse returns the keys of the entities and the names of the entity types of a response in the
+// order of their first occurrence
+func surrogateKeysOfResponse(operation, definition *ast.Document, entityKeys EntityKeyFields, response []byte) (keys, typeNames []string) {
+	if len(entityKeys) == 0 || len(operation.OperationDefinitions) == 0 {
+		return nil, nil
+	}
+
+	var resp struct {
+		Data any `json:"data"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(response))
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		return nil, nil
+	}
+	data, ok := resp.Data.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	op := operation.OperationDefinitions[0]
+	var rootTypeName string
+	switch op.OperationType {
+	case ast.OperationTypeQuery:
+		rootTypeName = definition.Index.QueryTypeName.String()
+	case ast.OperationTypeMutation:
+		rootTypeName = definition.Index.MutationTypeName.String()
+	default:
+		return nil, nil
+	}
+	if !op.HasSelections {
+		return nil, nil
+	}
+
+	w := &surrogateKeyWalker{
+		definition: definition,
+		operation:  operation,
+		entityKeys: entityKeys,
+		seen:       map[string]struct{}{},
+	}
+	w.object(rootTypeName, data, op.SelectionSet)
+
+	return w.keys, w.typeNames
+}
+
+type surrogateKeyWalker struct {
+	definition *ast.Document
+	operation  *ast.Document
+	entityKeys EntityKeyFields
+	keys       []string
+	typeNames  []string
+	seen       map[string]struct{}
+}
+
+// surrogateKeyObject is an object of the response. The keys of an object are added before the keys of its children.
+type surrogateKeyObject struct {
+	// fields are the values of the leaf fields without arguments by their name
+	fields   map[string]any
+	children []surrogateKeyChild
+}
+
+type surrogateKeyChild struct {
+	typeName     string
+	value        any
+	selectionSet int
+}
+
+func (w *surrogateKeyWalker) selectionSet(ref int, typeName string, data map[string]any, object *surrogateKeyObject) {
+	for _, selectionRef := range w.operation.SelectionSets[ref].SelectionRefs {
+		selection := w.operation.Selections[selectionRef]
+		switch selection.Kind {
+		case ast.SelectionKindField:
+			w.field(selection.Ref, typeName, data, object)
+		case ast.SelectionKindInlineFragment:
+			if w.operation.InlineFragmentHasTypeCondition(selection.Ref) &&
+				!typeConditionApplies(w.definition, w.operation.InlineFragmentTypeConditionNameString(selection.Ref), typeName) {
+				continue
+			}
+			if set, ok := w.operation.InlineFragmentSelectionSet(selection.Ref); ok {
+				w.selectionSet(set, typeName, data, object)
+			}
+		case ast.SelectionKindFragmentSpread:
+			fragmentRef, ok := w.operation.FragmentDefinitionRef(w.operation.FragmentSpreadNameBytes(selection.Ref))
+			if !ok || !typeConditionApplies(w.definition, w.operation.FragmentDefinitionTypeNameString(fragmentRef), typeName) {
+				continue
+			}
+			w.selectionSet(w.operation.FragmentDefinitions[fragmentRef].SelectionSet, typeName, data, object)
+		}
+	}
+}
+
+func (w *surrogateKeyWalker) field(ref int, typeName string, data map[string]any, object *surrogateKeyObject) {
+	name := w.operation.FieldNameString(ref)
+	value, ok := data[w.operation.FieldAliasOrNameString(ref)]
+	if !ok || name == "__typename" {
+		return
+	}
+
+	selectionSet, hasSelections := w.operation.FieldSelectionSet(ref)
+	if !hasSelections {
+		if !w.operation.FieldHasArguments(ref) {
+			object.fields[name] = value
+		}
+		return
+	}
+
+	node, ok := w.definition.Index.FirstNodeByNameStr(typeName)
+	if !ok {
+		return
+	}
+	fieldDefinition, ok := w.definition.NodeFieldDefinitionByName(node, []byte(name))
+	if !ok {
+		return
+	}
+	object.children = append(object.children, surrogateKeyChild{
+		typeName:     w.definition.ResolveTypeNameString(w.definition.FieldDefinitionType(fieldDefinition)),
+		value:        value,
+		selectionSet: selectionSet,
+	})
+}
+
+// object adds the keys of the object and walks its children
+func (w *surrogateKeyWalker) object(typeName string, data map[string]any, selectionSet int) {
+	object := &surrogateKeyObject{fields: map[string]any{}}
+	w.selectionSet(selectionSet, typeName, data, object)
+	w.addKeys(typeName, object.fields)
+
+	for _, child := range object.children {
+		w.value(child.typeName, child.value, child.selectionSet)
+	}
+}
+
+func (w *surrogateKeyWalker) value(typeName string, value any, selectionSet int) {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			w.value(typeName, item, selectionSet)
+		}
+	case map[string]any:
+		// The concrete type of abstract types is only known when the __typename is selected
+		if name, ok := v["__typename"].(string); ok {
+			typeName = name
+		}
+		w.object(typeName, v, selectionSet)
+	}
+}
+
+func (w *surrogateKeyWalker) addKeys(typeName string, fields map[string]any) {
+	keyFields, ok := w.entityKeys[typeName]
+	if !ok {
+		return
+	}
+	w.add(&w.typeNames, typeName)
+
+	for _, key := range keyFields {
+		values := make([]string, 0, len(key))
+		for _, field := range key {
+			value, ok := surrogateKeyValue(fields[field])
+			if !ok {
+				break
+			}
+			values = append(values, value)
+		}
+		if len(values) == len(key) {
+			w.add(&w.keys, typeName+":"+strings.Join(values, ":"))
+		}
+	}
+}
+
+func (w *surrogateKeyWalker) add(to *[]string, key string) {
+	if _, ok := w.seen[key]; ok {
+		return
+	}
+	w.seen[key] = struct{}{}
+	*to = append(*to, key)
+}
+
+// surrogateKeyValue formats a key field. Values are escaped because the keys are separated by spaces.
+func surrogateKeyValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return url.QueryEscape(v), true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
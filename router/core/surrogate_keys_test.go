@@ -0,0 +1,245 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"go.uber.org/zap"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+)
+
+const surrogateKeysTestSchema = `
+type Query {
+	employee(id: Int!): Employee
+	products: [Product!]!
+	search: [SearchResult!]!
+}
+
+type Mutation {
+	updateEmployee(id: Int!): Employee
+}
+
+type Employee {
+	id: Int!
+	name: String!
+	manager: Employee
+	products: [Product!]!
+}
+
+type Product {
+	upc: String!
+	sku: String!
+	name: String!
+}
+
+union SearchResult = Employee | Product
+`
+
+func TestNewEntityKeyFields(t *testing.T) {
+	t.Parallel()
+
+	keys := NewEntityKeyFields(&nodev1.EngineConfiguration{
+		DatasourceConfigurations: []*nodev1.DataSourceConfiguration{
+			{Keys: []*nodev1.RequiredField{
+				{TypeName: "Employee", SelectionSet: "id"},
+				{TypeName: "Product", SelectionSet: "upc sku"},
+				{TypeName: "Product", SelectionSet: "name organization { id }"},
+			}},
+			{Keys: []*nodev1.RequiredField{
+				{TypeName: "Employee", SelectionSet: "id"},
+				{TypeName: "Employee", FieldName: "manager", SelectionSet: "id"},
+			}},
+		},
+	})
+
+	require.Equal(t, EntityKeyFields{
+		"Employee": {{"id"}},
+		"Product":  {{"upc", "sku"}},
+	}, keys)
+}
+
+func TestSurrogateKeysOfResponse(t *testing.T) {
+	t.Parallel()
+
+	definition, report := astparser.ParseGraphqlDocumentString(surrogateKeysTestSchema)
+	require.False(t, report.HasErrors(), report.Error())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	entityKeys := EntityKeyFields{
+		"Employee": {{"id"}},
+		"Product":  {{"upc", "sku"}},
+	}
+
+	keysOf := func(t *testing.T, query, response string) ([]string, []string) {
+		operation, report := astparser.ParseGraphqlDocumentString(query)
+		require.False(t, report.HasErrors(), report.Error())
+		return surrogateKeysOfResponse(&operation, &definition, entityKeys, []byte(response))
+	}
+
+	t.Run("collects the keys of nested entities", func(t *testing.T) {
+		t.Parallel()
+
+		keys, typeNames := keysOf(t,
+			`query { employee(id: 1) { id manager { id name } products { upc sku name } } }`,
+			`{"data":{"employee":{"id":1,"manager":{"id":2,"name":"Jens"},"products":[{"upc":"top-1","sku":"a b","name":"Hat"},{"upc":"top-1","sku":"a b","name":"Hat"}]}}}`,
+		)
+		assert.Equal(t, []string{"Employee:1", "Employee:2", "Product:top-1:a+b"}, keys)
+		assert.Equal(t, []string{"Employee", "Product"}, typeNames)
+	})
+
+	t.Run("requires all key fields", func(t *testing.T) {
+		t.Parallel()
+
+		keys, typeNames := keysOf(t,
+			`query { products { upc name } employee(id: 1) { name } }`,
+			`{"data":{"products":[{"upc":"top-1","name":"Hat"}],"employee":{"name":"Jens"}}}`,
+		)
+		assert.Empty(t, keys)
+		assert.Equal(t, []string{"Product", "Employee"}, typeNames)
+	})
+
+	t.Run("uses the field names of aliased key fields", func(t *testing.T) {
+		t.Parallel()
+
+		keys, _ := keysOf(t,
+			`query { boss: employee(id: 1) { employeeID: id } }`,
+			`{"data":{"boss":{"employeeID":1}}}`,
+		)
+		assert.Equal(t, []string{"Employee:1"}, keys)
+	})
+
+	t.Run("resolves abstract types by their typename", func(t *testing.T) {
+		t.Parallel()
+
+		keys, _ := keysOf(t,
+			`query { search { __typename ... on Employee { id } ... on Product { upc sku } } }`,
+			`{"data":{"search":[{"__typename":"Employee","id":3},{"__typename":"Product","upc":"top-2","sku":"c"}]}}`,
+		)
+		assert.Equal(t, []string{"Employee:3", "Product:top-2:c"}, keys)
+	})
+
+	t.Run("collects the keys of mutations", func(t *testing.T) {
+		t.Parallel()
+
+		keys, _ := keysOf(t,
+			`mutation { updateEmployee(id: 1) { id } }`,
+			`{"data":{"updateEmployee":{"id":1}}}`,
+		)
+		assert.Equal(t, []string{"Employee:1"}, keys)
+	})
+
+	t.Run("ignores responses without data", func(t *testing.T) {
+		t.Parallel()
+
+		keys, typeNames := keysOf(t,
+			`query { employee(id: 1) { id } }`,
+			`{"errors":[{"message":"failed"}]}`,
+		)
+		assert.Empty(t, keys)
+		assert.Empty(t, typeNames)
+	})
+}
+
+type testPurgeHandler struct {
+	keys chan []string
+}
+
+func (h *testPurgeHandler) OnPurgeSurrogateKeys(_ context.Context, keys []string) error {
+	h.keys <- keys
+	return nil
+}
+
+func TestSurrogateKeysPurge(t *testing.T) {
+	t.Parallel()
+
+	type purge struct {
+		method string
+		header string
+		token  string
+		body   []string
+	}
+	purges := make(chan purge, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			SurrogateKeys []string `json:"surrogate_keys"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		purges <- purge{method: r.Method, header: r.Header.Get("xkey-purge"), token: r.Header.Get("Fastly-Key"), body: body.SurrogateKeys}
+	}))
+	defer server.Close()
+
+	s, err := NewSurrogateKeys(&SurrogateKeysOptions{
+		Logger: zap.NewNop(),
+		Header: "Surrogate-Key",
+		Purge:  true,
+		PurgeEndpoints: []SurrogateKeyPurgeEndpoint{
+			{URL: server.URL, Method: "PURGE", KeysHeader: "xkey-purge", Headers: map[string]string{"Fastly-Key": "token"}},
+		},
+	})
+	require.NoError(t, err)
+
+	handler := &testPurgeHandler{keys: make(chan []string, 1)}
+	s.addPurgeHandler(handler)
+
+	s.purgeKeys([]string{"Employee:1", "Employee:2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.Wait(ctx))
+
+	require.Equal(t, purge{method: "PURGE", header: "Employee:1 Employee:2", token: "token", body: []string{"Employee:1", "Employee:2"}}, <-purges)
+	require.Equal(t, []string{"Employee:1", "Employee:2"}, <-handler.keys)
+}
+
+func TestSurrogateKeysApply(t *testing.T) {
+	t.Parallel()
+
+	definition, report := astparser.ParseGraphqlDocumentString(surrogateKeysTestSchema)
+	require.False(t, report.HasErrors(), report.Error())
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	newOperationContext := func(t *testing.T, query string) *operationContext {
+		operation, report := astparser.ParseGraphqlDocumentString(query)
+		require.False(t, report.HasErrors(), report.Error())
+
+		opType := "query"
+		if operation.OperationDefinitions[0].OperationType == ast.OperationTypeMutation {
+			opType = "mutation"
+		}
+		return &operationContext{
+			opType:       opType,
+			preparedPlan: &planWithMetaData{operationDocument: &operation, schemaDocument: &definition},
+		}
+	}
+
+	s, err := NewSurrogateKeys(&SurrogateKeysOptions{
+		Logger:   zap.NewNop(),
+		Header:   "Surrogate-Key",
+		MaxKeys:  3,
+		TypeKeys: true,
+	})
+	require.NoError(t, err)
+
+	entityKeys := EntityKeyFields{"Employee": {{"id"}}}
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Surrogate-Key", "subgraph")
+	s.apply(rec, newOperationContext(t, `query { employee(id: 1) { id manager { id manager { id } } } }`), entityKeys,
+		[]byte(`{"data":{"employee":{"id":1,"manager":{"id":2,"manager":{"id":3}}}}}`))
+	require.Equal(t, "subgraph Employee Employee:1 Employee:2", rec.Header().Get("Surrogate-Key"))
+
+	// Mutations aren't tagged
+	rec = httptest.NewRecorder()
+	s.apply(rec, newOperationContext(t, `mutation { updateEmployee(id: 1) { id } }`), entityKeys, []byte(`{"data":{"updateEmployee":{"id":1}}}`))
+	require.Empty(t, rec.Header().Get("Surrogate-Key"))
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrConfigSwapQueueFull = errors.New("router is updating its configuration. Please try again")
+
+type SwapHandlerOptions struct {
+	Logger *zap.Logger
+	// MaxQueuedRequests is the maximum number of requests that wait for a swap to complete.
+	// Requests exceeding the limit are rejected immediately.
+	MaxQueuedRequests int
+	// QueueTimeout is the maximum time a request waits for a swap to complete
+	QueueTimeout time.Duration
+}
+
+// swapHandler is the handler of the long-lived router HTTP server. It forwards every request to the
+// handler of the active server. While the active server is drained and replaced, incoming requests are
+// held in a bounded queue instead of racing the swap or hitting a closed listener. Requests that can't be
+// queued or time out are rejected with a 503 status code and a Retry-After header.
+type swapHandler struct {
+	logger            *zap.Logger
+	maxQueuedRequests int64
+	queueTimeout      time.Duration
+
+	mu sync.RWMutex
+	// active is the generation that receives all new requests. It is nil while a swap is in progress.
+	active *handlerGeneration
+	// swapDone is closed when the current swap is completed. It is nil when no swap is in progress.
+	swapDone chan struct{}
+
+	queued atomic.Int64
+}
+
+// handlerGeneration tracks the in-flight requests of a single server
+type handlerGeneration struct {
+	handler  http.Handler
+	inFlight sync.WaitGroup
+	// requests is the number of in-flight requests, which the wait group doesn't expose
+	requests atomic.Int64
+}
+
+func newSwapHandler(opts *SwapHandlerOptions) *swapHandler {
+	return &swapHandler{
+		logger:            opts.Logger,
+		maxQueuedRequests: int64(opts.MaxQueuedRequests),
+		queueTimeout:      opts.QueueTimeout,
+	}
+}
+
+func (h *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for {
+		// The generation is retrieved and tracked under the lock so that no request
+		// is added to a generation after the swap has started
+		h.mu.RLock()
+		gen, swapDone := h.active, h.swapDone
+		if swapDone == nil {
+			gen.inFlight.Add(1)
+			gen.requests.Add(1)
+		}
+		h.mu.RUnlock()
+
+		if swapDone == nil {
+			defer gen.inFlight.Done()
+			defer gen.requests.Add(-1)
+			gen.handler.ServeHTTP(w, r)
+			return
+		}
+
+		if err := h.waitForSwap(r.Context(), swapDone); err != nil {
+			w.Header().Set("Retry-After", "1")
+			writeHTTPError(r, w, http.StatusServiceUnavailable, ErrConfigSwapQueueFull, h.logger)
+			return
+		}
+	}
+}
+
+func (h *swapHandler) waitForSwap(ctx context.Context, swapDone chan struct{}) error {
+	if h.queued.Add(1) > h.maxQueuedRequests {
+		h.queued.Add(-1)
+		return errors.New("config swap queue is full")
+	}
+	defer h.queued.Add(-1)
+
+	timer := time.NewTimer(h.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-swapDone:
+		return nil
+	case <-timer.C:
+		return errors.New("timed out waiting for config swap")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// beginSwap starts queueing incoming requests. It returns the generation that served requests until now
+// so that the caller can wait for its in-flight requests before the new handler is activated.
+func (h *swapHandler) beginSwap() *handlerGeneration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.swapDone == nil {
+		h.swapDone = make(chan struct{})
+	}
+
+	previous := h.active
+	h.active = nil
+
+	return previous
+}
+
+// completeSwap activates the new handler and releases all queued requests
+func (h *swapHandler) completeSwap(handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.active = &handlerGeneration{handler: handler}
+
+	if h.swapDone != nil {
+		close(h.swapDone)
+		h.swapDone = nil
+	}
+}
+
+// inFlightRequests returns the number of in-flight requests of the active generation
+func (h *swapHandler) inFlightRequests() int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.active == nil {
+		return 0
+	}
+	return h.active.requests.Load()
+}
+
+// wait blocks until all in-flight requests of the generation are completed or the context is done
+func (g *handlerGeneration) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
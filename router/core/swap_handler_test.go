@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestSwapHandler(maxQueuedRequests int, queueTimeout time.Duration, body string) *swapHandler {
+	h := newSwapHandler(&SwapHandlerOptions{
+		Logger:            zap.NewNop(),
+		MaxQueuedRequests: maxQueuedRequests,
+		QueueTimeout:      queueTimeout,
+	})
+	h.completeSwap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	return h
+}
+
+func TestSwapHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("queued requests are served by the new handler", func(t *testing.T) {
+		t.Parallel()
+
+		h := newTestSwapHandler(10, 5*time.Second, "old")
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		require.Equal(t, "old", rec.Body.String())
+
+		previous := h.beginSwap()
+		require.NoError(t, previous.wait(context.Background()))
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+			done <- rec
+		}()
+
+		require.Eventually(t, func() bool {
+			return h.queued.Load() == 1
+		}, time.Second, time.Millisecond)
+
+		h.completeSwap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "new")
+		}))
+
+		rec = <-done
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "new", rec.Body.String())
+	})
+
+	t.Run("requests are rejected when the queue is full", func(t *testing.T) {
+		t.Parallel()
+
+		h := newTestSwapHandler(0, 5*time.Second, "old")
+		h.beginSwap()
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.Equal(t, "1", rec.Header().Get("Retry-After"))
+		require.JSONEq(t, `{"errors":[{"message":"router is updating its configuration. Please try again"}],"data":null}`, rec.Body.String())
+	})
+
+	t.Run("requests are rejected when the queue timeout is exceeded", func(t *testing.T) {
+		t.Parallel()
+
+		h := newTestSwapHandler(10, 10*time.Millisecond, "old")
+		h.beginSwap()
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.Equal(t, int64(0), h.queued.Load())
+	})
+
+	t.Run("swap waits for in-flight requests", func(t *testing.T) {
+		t.Parallel()
+
+		h := newSwapHandler(&SwapHandlerOptions{
+			Logger:            zap.NewNop(),
+			MaxQueuedRequests: 10,
+			QueueTimeout:      5 * time.Second,
+		})
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		h.completeSwap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}))
+
+		go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		<-started
+
+		previous := h.beginSwap()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, previous.wait(ctx), context.DeadlineExceeded)
+
+		close(release)
+		require.NoError(t, previous.wait(context.Background()))
+	})
+}
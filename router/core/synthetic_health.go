@@ -0,0 +1,283 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+type SyntheticHealthOperationOptions struct {
+	Logger *zap.Logger
+	// Path is the path of the GraphQL endpoint the operation is sent to
+	Path          string
+	Interval      time.Duration
+	Timeout       time.Duration
+	Query         string
+	OperationName string
+	Variables     map[string]any
+	Headers       map[string]string
+	ClientName    string
+}
+
+// SyntheticHealthOperation runs a GraphQL operation periodically through the handler of the router, so that the
+// whole pipeline from the parsing to the subgraphs is probed like a request of a client. An operation succeeds when
+// it responds with data and without errors within the timeout. The result of the last run is exported as metrics.
+type SyntheticHealthOperation struct {
+	logger        *zap.Logger
+	path          string
+	interval      time.Duration
+	timeout       time.Duration
+	operationName string
+	headers       map[string]string
+	clientName    string
+	body          []byte
+
+	mu            sync.Mutex
+	last          *SyntheticHealthResult
+	registrations []otelmetric.Registration
+}
+
+// SyntheticHealthResult is the result of a run of the operation
+type SyntheticHealthResult struct {
+	Up         bool
+	Latency    time.Duration
+	StatusCode int
+	// Err is the reason of a failure
+	Err error
+}
+
+func NewSyntheticHealthOperation(opts *SyntheticHealthOperationOptions) (*SyntheticHealthOperation, error) {
+	if opts.Query == "" {
+		return nil, errors.New("the query of the synthetic health operation must not be empty")
+	}
+	if opts.Interval <= 0 {
+		return nil, errors.New("the interval of the synthetic health operation must be greater than 0")
+	}
+	if opts.Timeout <= 0 {
+		return nil, errors.New("the timeout of the synthetic health operation must be greater than 0")
+	}
+
+	body, err := json.Marshal(subscriptionWebhookRequest{
+		Query:         opts.Query,
+		OperationName: opts.OperationName,
+		Variables:     opts.Variables,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid variables of the synthetic health operation: %w", err)
+	}
+
+	return &SyntheticHealthOperation{
+		logger:        opts.Logger,
+		path:          opts.Path,
+		interval:      opts.Interval,
+		timeout:       opts.Timeout,
+		operationName: opts.OperationName,
+		headers:       opts.Headers,
+		clientName:    opts.ClientName,
+		body:          body,
+	}, nil
+}
+
+// Run sends the operation to the handler once and returns the result
+func (s *SyntheticHealthOperation) Run(ctx context.Context, handler http.Handler) *SyntheticHealthResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodPost, s.path, bytes.NewReader(s.body)).WithContext(ctx)
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.clientName != "" {
+		req.Header.Set("graphql-client-name", s.clientName)
+	}
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rec, req)
+
+	result := &SyntheticHealthResult{
+		Latency:    time.Since(start),
+		StatusCode: rec.Code,
+	}
+	result.Err = syntheticHealthError(ctx, rec)
+	result.Up = result.Err == nil
+
+	return result
+}
+
+func syntheticHealthError(ctx context.Context, rec *httptest.ResponseRecorder) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("the operation did not complete: %w", err)
+	}
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", rec.Code)
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if len(resp.Errors) > 0 && !bytes.Equal(resp.Errors, []byte("null")) && !bytes.Equal(resp.Errors, []byte("[]")) {
+		return fmt.Errorf("the response has errors: %s", resp.Errors)
+	}
+	if len(resp.Data) == 0 || bytes.Equal(resp.Data, []byte("null")) {
+		return errors.New("the response has no data")
+	}
+
+	return nil
+}
+
+// Start runs the operation in the interval in the background until the returned function is called. It is started
+// for every server with its handler, the result of the last run is kept across the servers.
+func (s *SyntheticHealthOperation) Start(handler http.Handler) (stop func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.record(s.Run(ctx, handler))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func(stopCtx context.Context) error {
+		cancel()
+
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	}
+}
+
+// record keeps the result and logs failures and the recovery from them
+func (s *SyntheticHealthOperation) record(result *SyntheticHealthResult) {
+	// The result of a run that was stopped by the shutdown is not a failure
+	if errors.Is(result.Err, context.Canceled) {
+		return
+	}
+
+	s.mu.Lock()
+	previous := s.last
+	s.last = result
+	s.mu.Unlock()
+
+	if !result.Up {
+		s.logger.Warn("Synthetic health operation failed",
+			zap.String("operation_name", s.operationName),
+			zap.Int("status_code", result.StatusCode),
+			zap.Duration("latency", result.Latency),
+			zap.Error(result.Err),
+		)
+		return
+	}
+
+	if previous != nil && !previous.Up {
+		s.logger.Info("Synthetic health operation recovered",
+			zap.String("operation_name", s.operationName),
+			zap.Duration("latency", result.Latency),
+		)
+	}
+}
+
+// Last returns the result of the last run, or nil when the operation didn't run yet
+func (s *SyntheticHealthOperation) Last() *SyntheticHealthResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.last
+}
+
+// RegisterMetrics exposes the result of the last run on the meter provider
+func (s *SyntheticHealthOperation) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterServerMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterServerMeterVersion),
+	)
+
+	up, err := meter.Int64ObservableGauge(
+		"router.synthetic.up",
+		otelmetric.WithDescription("Whether the last run of the synthetic health operation succeeded (1) or failed (0)"),
+	)
+	if err != nil {
+		return err
+	}
+	latency, err := meter.Float64ObservableGauge(
+		"router.synthetic.latency",
+		otelmetric.WithDescription("Latency of the last run of the synthetic health operation"),
+		otelmetric.WithUnit("ms"),
+	)
+	if err != nil {
+		return err
+	}
+
+	opt := otelmetric.WithAttributes(otel.WgOperationName.String(s.operationName), otel.WgClientName.String(s.clientName))
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		last := s.Last()
+		// Nothing to report before the first run, a failure would be an outage
+		if last == nil {
+			return nil
+		}
+
+		var value int64
+		if last.Up {
+			value = 1
+		}
+		o.ObserveInt64(up, value, opt)
+		o.ObserveFloat64(latency, float64(last.Latency)/float64(time.Millisecond), opt)
+
+		return nil
+	}, up, latency)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.registrations = append(s.registrations, reg)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Shutdown unregisters the metrics
+func (s *SyntheticHealthOperation) Shutdown() error {
+	s.mu.Lock()
+	registrations := s.registrations
+	s.registrations = nil
+	s.mu.Unlock()
+
+	var err error
+	for _, reg := range registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+
+	return err
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel"
+)
+
+func TestSyntheticHealthOperation(t *testing.T) {
+	var response atomic.Value
+	response.Store(`{"data":{"employee":{"id":1}}}`)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/graphql", r.URL.Path)
+		require.Equal(t, "synthetic", r.Header.Get("graphql-client-name"))
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"query":"query Probe($id: Int!) { employee(id: $id) { id } }","operationName":"Probe","variables":{"id":1}}`, string(body))
+
+		resp := response.Load().(string)
+		if resp == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	})
+
+	logCore, logs := observer.New(zapcore.InfoLevel)
+	s, err := NewSyntheticHealthOperation(&SyntheticHealthOperationOptions{
+		Logger:        zap.New(logCore),
+		Path:          "/graphql",
+		Interval:      time.Hour,
+		Timeout:       time.Second,
+		Query:         "query Probe($id: Int!) { employee(id: $id) { id } }",
+		OperationName: "Probe",
+		Variables:     map[string]any{"id": 1},
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		ClientName:    "synthetic",
+	})
+	require.NoError(t, err)
+
+	result := s.Run(context.Background(), handler)
+	require.True(t, result.Up)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.NoError(t, result.Err)
+
+	response.Store(`{"errors":[{"message":"Failed to fetch from Subgraph 'employees'."}],"data":null}`)
+	result = s.Run(context.Background(), handler)
+	require.False(t, result.Up)
+	require.EqualError(t, result.Err, `the response has errors: [{"message":"Failed to fetch from Subgraph 'employees'."}]`)
+
+	response.Store(`{"data":null}`)
+	require.EqualError(t, s.Run(context.Background(), handler).Err, "the response has no data")
+
+	response.Store("")
+	result = s.Run(context.Background(), handler)
+	require.EqualError(t, result.Err, "unexpected status code 503")
+	require.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+
+	// A failure is logged until the operation recovers
+	s.record(result)
+	response.Store(`{"data":{"employee":{"id":1}}}`)
+	s.record(s.Run(context.Background(), handler))
+
+	require.Equal(t, 2, logs.Len())
+	require.Equal(t, "Synthetic health operation failed", logs.All()[0].Message)
+	require.Equal(t, zapcore.WarnLevel, logs.All()[0].Level)
+	require.Equal(t, int64(http.StatusServiceUnavailable), logs.All()[0].ContextMap()["status_code"])
+	require.Equal(t, "Synthetic health operation recovered", logs.All()[1].Message)
+}
+
+func TestSyntheticHealthOperationTimeout(t *testing.T) {
+	s, err := NewSyntheticHealthOperation(&SyntheticHealthOperationOptions{
+		Logger:   zap.NewNop(),
+		Path:     "/graphql",
+		Interval: time.Hour,
+		Timeout:  10 * time.Millisecond,
+		Query:    "{ employees { id } }",
+	})
+	require.NoError(t, err)
+
+	result := s.Run(context.Background(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusOK)
+	}))
+	require.False(t, result.Up)
+	require.ErrorIs(t, result.Err, context.DeadlineExceeded)
+}
+
+func TestSyntheticHealthOperationMetrics(t *testing.T) {
+	var runs atomic.Int64
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"employees": []any{}}})
+	})
+
+	s, err := NewSyntheticHealthOperation(&SyntheticHealthOperationOptions{
+		Logger:        zap.NewNop(),
+		Path:          "/graphql",
+		Interval:      time.Hour,
+		Timeout:       time.Second,
+		Query:         "query Employees { employees { id } }",
+		OperationName: "Employees",
+		ClientName:    "synthetic",
+	})
+	require.NoError(t, err)
+
+	reader := sdkmetric.NewManualReader()
+	require.NoError(t, s.RegisterMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	// Nothing is reported before the first run
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Empty(t, rm.ScopeMetrics)
+
+	stop := s.Start(handler)
+	require.Eventually(t, func() bool { return s.Last() != nil }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	var (
+		up      metricdata.Gauge[int64]
+		latency metricdata.Gauge[float64]
+	)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch m.Name {
+		case "router.synthetic.up":
+			up = m.Data.(metricdata.Gauge[int64])
+		case "router.synthetic.latency":
+			latency = m.Data.(metricdata.Gauge[float64])
+		}
+	}
+	require.Len(t, up.DataPoints, 1)
+	require.Equal(t, int64(1), up.DataPoints[0].Value)
+	operationName, _ := up.DataPoints[0].Attributes.Value(otel.WgOperationName)
+	require.Equal(t, "Employees", operationName.AsString())
+	require.Len(t, latency.DataPoints, 1)
+	require.GreaterOrEqual(t, latency.DataPoints[0].Value, float64(0))
+
+	require.NoError(t, stop(context.Background()))
+	require.Equal(t, int64(1), runs.Load())
+	require.NoError(t, s.Shutdown())
+
+	_, err = NewSyntheticHealthOperation(&SyntheticHealthOperationOptions{Interval: time.Second, Timeout: time.Second})
+	require.EqualError(t, err, "the query of the synthetic health operation must not be empty")
+}
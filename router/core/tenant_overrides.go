@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// TenantOverrides overrides selected settings for the tenants of a multi-tenant router. The tenant of a request is
+// identified by a claim of its token or a request header. The overrides come from a file that is reloaded when it
+// changes. Requests of unknown tenants use the settings of the router.
+type TenantOverrides struct {
+	logger         *zap.Logger
+	header         string
+	claim          string
+	file           string
+	reloadInterval time.Duration
+
+	// tenants are the overrides by the ID of the tenant. They are replaced on every reload, so lookups don't lock.
+	tenants atomic.Pointer[map[string]*tenantOverride]
+
+	mu         sync.Mutex
+	fileMod    time.Time
+	fileSize   int64
+	cancel     context.CancelFunc
+	reloadDone chan struct{}
+}
+
+// tenantsFile is the content of the tenants file
+type tenantsFile struct {
+	Tenants map[string]tenantConfiguration `yaml:"tenants"`
+}
+
+type tenantConfiguration struct {
+	// Timeouts replace the timeouts of the operation types. The timeouts of the operation names still take precedence.
+	Timeouts struct {
+		Query        time.Duration `yaml:"query"`
+		Mutation     time.Duration `yaml:"mutation"`
+		Subscription time.Duration `yaml:"subscription"`
+	} `yaml:"timeouts"`
+	// RateLimit replaces the limits of the simple rate limiting strategy
+	RateLimit *struct {
+		Rate   int           `yaml:"rate"`
+		Burst  int           `yaml:"burst"`
+		Period time.Duration `yaml:"period"`
+	} `yaml:"rate_limit"`
+	// Headers are applied to the requests to the subgraphs after the header rules of the router
+	Headers config.GlobalHeaderRule `yaml:"headers"`
+	// AccessLogSampleRate replaces the sample rate of the access logs
+	AccessLogSampleRate *float64 `yaml:"access_log_sample_rate"`
+}
+
+type tenantOverride struct {
+	id                  string
+	queryTimeout        time.Duration
+	mutationTimeout     time.Duration
+	subscriptionTimeout time.Duration
+	rateLimit           *tenantRateLimit
+	headerRules         *HeaderRuleEngine
+	hasSampleRate       bool
+	sampleRate          float64
+}
+
+type tenantRateLimit struct {
+	rate   int
+	burst  int
+	period time.Duration
+}
+
+func NewTenantOverrides(logger *zap.Logger, cfg *config.TenantsConfiguration) (*TenantOverrides, error) {
+	if cfg.Header == "" && cfg.Claim == "" {
+		return nil, errors.New("the tenants require a header or a claim that identifies the tenant")
+	}
+	if cfg.File == "" {
+		return nil, errors.New("the tenants require a tenants file")
+	}
+
+	t := &TenantOverrides{
+		logger:         logger,
+		header:         cfg.Header,
+		claim:          cfg.Claim,
+		file:           cfg.File,
+		reloadInterval: cfg.ReloadInterval,
+	}
+
+	// A missing or invalid file is a misconfiguration at startup. Later, the last valid tenants are kept.
+	if _, err := t.reload(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Tenants returns the number of tenants with overrides
+func (t *TenantOverrides) Tenants() int {
+	tenants := t.tenants.Load()
+	if tenants == nil {
+		return 0
+	}
+	return len(*tenants)
+}
+
+// tenantOf returns the ID of the tenant of the request and its overrides. The claim takes precedence over the header.
+// The overrides are nil if the tenant has none.
+func (t *TenantOverrides) tenantOf(r *http.Request) (string, *tenantOverride) {
+	var id string
+	if t.claim != "" {
+		if auth := authentication.FromContext(r.Context()); auth != nil {
+			id, _ = auth.Claims()[t.claim].(string)
+		}
+	}
+	if id == "" && t.header != "" {
+		id = r.Header.Get(t.header)
+	}
+	if id == "" {
+		return "", nil
+	}
+
+	tenants := t.tenants.Load()
+	if tenants == nil {
+		return id, nil
+	}
+	return id, (*tenants)[id]
+}
+
+// OnOriginRequest applies the header rules of the tenant of the request to the request to the subgraph
+func (t *TenantOverrides) OnOriginRequest(request *http.Request, ctx RequestContext) (*http.Request, *http.Response) {
+	tenant := tenantOf(request.Context())
+	if tenant == nil || tenant.headerRules == nil {
+		return request, nil
+	}
+	return tenant.headerRules.OnOriginRequest(request, ctx)
+}
+
+// accessLogSampler returns the sampler of the access logs with the sample rates of the tenants. The exemptions of the
+// sampler still apply. Without a sampler, only the requests of tenants with a sample rate are sampled.
+func (t *TenantOverrides) accessLogSampler(sampler *AccessLogSampler) func(r *http.Request, status int) bool {
+	return func(r *http.Request, status int) bool {
+		var tenant *tenantOverride
+		if lc := getLogEntryContext(r.Context()); lc != nil && lc.requestContext != nil {
+			tenant = lc.requestContext.tenant
+		}
+
+		switch {
+		case tenant != nil && tenant.hasSampleRate && sampler != nil:
+			return sampler.sample(r, status, tenant.sampleRate)
+		case tenant != nil && tenant.hasSampleRate:
+			return sampled(tenant.sampleRate)
+		case sampler != nil:
+			return sampler.Sample(r, status)
+		}
+		return true
+	}
+}
+
+// tenantOf returns the overrides of the tenant of the request in the context or nil
+func tenantOf(ctx context.Context) *tenantOverride {
+	if reqCtx := getRequestContext(ctx); reqCtx != nil {
+		return reqCtx.tenant
+	}
+	return nil
+}
+
+// operationTimeout returns the timeout of the operation with the timeout of its type replaced by the one of the
+// tenant. The timeouts of the operation names take precedence over the tenant.
+func (o *tenantOverride) operationTimeout(timeouts *OperationTimeouts, operationName, operationType string) time.Duration {
+	if o == nil {
+		return timeouts.Timeout(operationName, operationType)
+	}
+	if timeouts != nil {
+		if timeout, ok := timeouts.operations[operationName]; ok {
+			return timeout
+		}
+	}
+
+	var timeout time.Duration
+	switch operationType {
+	case "query":
+		timeout = o.queryTimeout
+	case "mutation":
+		timeout = o.mutationTimeout
+	case "subscription":
+		timeout = o.subscriptionTimeout
+	}
+	if timeout > 0 {
+		return timeout
+	}
+	return timeouts.Timeout(operationName, operationType)
+}
+
+// Start reloads the file in the background when it was modified
+func (t *TenantOverrides) Start() {
+	if t.reloadInterval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t.mu.Lock()
+	t.cancel = cancel
+	t.reloadDone = make(chan struct{})
+	done := t.reloadDone
+	t.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(t.reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				changed, err := t.reload()
+				if err != nil {
+					t.logger.Warn("Failed to reload the tenants file. The previous tenants are kept",
+						zap.String("file", t.file),
+						zap.Error(err),
+					)
+					continue
+				}
+				if changed {
+					t.logger.Info("Reloaded the tenants file",
+						zap.String("file", t.file),
+						zap.Int("tenants", t.Tenants()),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Shutdown stops reloading the file
+func (t *TenantOverrides) Shutdown() {
+	t.mu.Lock()
+	cancel, done := t.cancel, t.reloadDone
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// reload reads the file when its modification time or size changed
+func (t *TenantOverrides) reload() (bool, error) {
+	info, err := os.Stat(t.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat the tenants file: %w", err)
+	}
+
+	t.mu.Lock()
+	unchanged := info.ModTime().Equal(t.fileMod) && info.Size() == t.fileSize
+	t.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	content, err := os.ReadFile(t.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the tenants file: %w", err)
+	}
+
+	tenants, err := parseTenants(content)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the tenants file: %w", err)
+	}
+
+	t.mu.Lock()
+	t.tenants.Store(&tenants)
+	t.fileMod = info.ModTime()
+	t.fileSize = info.Size()
+	t.mu.Unlock()
+
+	return true, nil
+}
+
+func parseTenants(content []byte) (map[string]*tenantOverride, error) {
+	var file tenantsFile
+	if err := yaml.UnmarshalWithOptions(content, &file, yaml.Strict()); err != nil {
+		return nil, err
+	}
+
+	tenants := make(map[string]*tenantOverride, len(file.Tenants))
+	for id, cfg := range file.Tenants {
+		if id == "" {
+			return nil, errors.New("the ID of a tenant must not be empty")
+		}
+		if cfg.Timeouts.Query < 0 || cfg.Timeouts.Mutation < 0 || cfg.Timeouts.Subscription < 0 {
+			return nil, fmt.Errorf("the timeouts of tenant '%s' must not be negative", id)
+		}
+
+		tenant := &tenantOverride{
+			id:                  id,
+			queryTimeout:        cfg.Timeouts.Query,
+			mutationTimeout:     cfg.Timeouts.Mutation,
+			subscriptionTimeout: cfg.Timeouts.Subscription,
+		}
+
+		if cfg.RateLimit != nil {
+			if cfg.RateLimit.Rate <= 0 || cfg.RateLimit.Burst <= 0 || cfg.RateLimit.Period <= 0 {
+				return nil, fmt.Errorf("the rate, burst and period of the rate limit of tenant '%s' must be positive", id)
+			}
+			tenant.rateLimit = &tenantRateLimit{
+				rate:   cfg.RateLimit.Rate,
+				burst:  cfg.RateLimit.Burst,
+				period: cfg.RateLimit.Period,
+			}
+		}
+
+		if len(cfg.Headers.Request) > 0 {
+			headerRules, err := NewHeaderTransformer(config.HeaderRules{All: cfg.Headers})
+			if err != nil {
+				return nil, fmt.Errorf("invalid header rules of tenant '%s': %w", id, err)
+			}
+			tenant.headerRules = headerRules
+		}
+
+		if cfg.AccessLogSampleRate != nil {
+			if *cfg.AccessLogSampleRate < 0 || *cfg.AccessLogSampleRate > 1 {
+				return nil, fmt.Errorf("the access log sample rate of tenant '%s' must be between 0 and 1, got %v", id, *cfg.AccessLogSampleRate)
+			}
+			tenant.hasSampleRate = true
+			tenant.sampleRate = *cfg.AccessLogSampleRate
+		}
+
+		tenants[id] = tenant
+	}
+
+	return tenants, nil
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const testTenantsFile = `
+tenants:
+  acme:
+    timeouts:
+      query: 5s
+    rate_limit:
+      rate: 100
+      burst: 200
+      period: 1s
+    headers:
+      request:
+        - op: propagate
+          named: X-Acme-Plan
+    access_log_sample_rate: 0
+  globex:
+    access_log_sample_rate: 1
+`
+
+func TestTenantOverrides(t *testing.T) {
+	t.Parallel()
+
+	newTenants := func(t *testing.T, content string) (*TenantOverrides, string) {
+		file := filepath.Join(t.TempDir(), "tenants.yaml")
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+		tenants, err := NewTenantOverrides(zap.NewNop(), &config.TenantsConfiguration{
+			Header: "X-Tenant-ID",
+			Claim:  "tenant_id",
+			File:   file,
+		})
+		require.NoError(t, err)
+		return tenants, file
+	}
+
+	t.Run("identifies the tenant by the claim or the header", func(t *testing.T) {
+		t.Parallel()
+
+		tenants, _ := newTenants(t, testTenantsFile)
+		require.Equal(t, 2, tenants.Tenants())
+
+		r := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		id, tenant := tenants.tenantOf(r)
+		require.Empty(t, id)
+		require.Nil(t, tenant)
+
+		r.Header.Set("X-Tenant-ID", "acme")
+		id, tenant = tenants.tenantOf(r)
+		require.Equal(t, "acme", id)
+		require.NotNil(t, tenant)
+
+		auth := &testAuthentication{claims: authentication.Claims{"tenant_id": "globex"}}
+		r = r.WithContext(authentication.NewContext(r.Context(), auth))
+		id, tenant = tenants.tenantOf(r)
+		require.Equal(t, "globex", id)
+		require.Equal(t, "globex", tenant.id)
+
+		// Tenants without overrides use the settings of the router
+		r = httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		r.Header.Set("X-Tenant-ID", "initech")
+		id, tenant = tenants.tenantOf(r)
+		require.Equal(t, "initech", id)
+		require.Nil(t, tenant)
+	})
+
+	t.Run("overrides the timeouts of the operation types", func(t *testing.T) {
+		t.Parallel()
+
+		tenants, _ := newTenants(t, testTenantsFile)
+		acme := (*tenants.tenants.Load())["acme"]
+
+		timeouts, err := NewOperationTimeouts(&config.OperationTimeoutsConfiguration{
+			Query:      time.Second,
+			Mutation:   2 * time.Second,
+			Operations: []config.OperationTimeout{{Name: "Report", Timeout: time.Minute}},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 5*time.Second, acme.operationTimeout(timeouts, "Employees", "query"))
+		require.Equal(t, 2*time.Second, acme.operationTimeout(timeouts, "UpdateEmployee", "mutation"))
+		require.Equal(t, time.Minute, acme.operationTimeout(timeouts, "Report", "query"))
+		require.Equal(t, 5*time.Second, acme.operationTimeout(nil, "Employees", "query"))
+
+		var noTenant *tenantOverride
+		require.Equal(t, time.Second, noTenant.operationTimeout(timeouts, "Employees", "query"))
+	})
+
+	t.Run("applies the header rules of the tenant", func(t *testing.T) {
+		t.Parallel()
+
+		tenants, _ := newTenants(t, testTenantsFile)
+
+		clientRequest := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		clientRequest.Header.Set("X-Acme-Plan", "enterprise")
+		reqCtx := &requestContext{request: clientRequest, tenant: (*tenants.tenants.Load())["acme"]}
+
+		subgraphRequest := httptest.NewRequest(http.MethodPost, "http://employees/graphql", nil)
+		subgraphRequest = subgraphRequest.WithContext(withRequestContext(subgraphRequest.Context(), reqCtx))
+		subgraphRequest, _ = tenants.OnOriginRequest(subgraphRequest, reqCtx)
+		require.Equal(t, "enterprise", subgraphRequest.Header.Get("X-Acme-Plan"))
+
+		reqCtx.tenant = (*tenants.tenants.Load())["globex"]
+		subgraphRequest = httptest.NewRequest(http.MethodPost, "http://employees/graphql", nil)
+		subgraphRequest = subgraphRequest.WithContext(withRequestContext(subgraphRequest.Context(), reqCtx))
+		subgraphRequest, _ = tenants.OnOriginRequest(subgraphRequest, reqCtx)
+		require.Empty(t, subgraphRequest.Header.Get("X-Acme-Plan"))
+	})
+
+	t.Run("samples the access logs with the rate of the tenant", func(t *testing.T) {
+		t.Parallel()
+
+		tenants, _ := newTenants(t, testTenantsFile)
+		sampler, err := NewAccessLogSampler(&config.AccessLogsSamplingConfiguration{
+			SampleRate: 0,
+			AlwaysLog:  config.AccessLogsSamplingExemptions{StatusCodes: []string{"5xx"}},
+		})
+		require.NoError(t, err)
+
+		newRequest := func(tenant string) *http.Request {
+			ctx, lc := withLogEntryContext(httptest.NewRequest(http.MethodPost, "/graphql", nil).Context())
+			lc.requestContext = &requestContext{tenant: (*tenants.tenants.Load())[tenant]}
+			return httptest.NewRequest(http.MethodPost, "/graphql", nil).WithContext(ctx)
+		}
+
+		sample := tenants.accessLogSampler(sampler)
+		require.True(t, sample(newRequest("globex"), http.StatusOK))
+		require.False(t, sample(newRequest("acme"), http.StatusOK))
+		require.False(t, sample(newRequest("initech"), http.StatusOK))
+		// The exemptions of the sampler still apply
+		require.True(t, sample(newRequest("acme"), http.StatusInternalServerError))
+
+		sample = tenants.accessLogSampler(nil)
+		require.False(t, sample(newRequest("acme"), http.StatusInternalServerError))
+		require.True(t, sample(newRequest("initech"), http.StatusOK))
+	})
+
+	t.Run("keeps the previous tenants when the file is invalid", func(t *testing.T) {
+		t.Parallel()
+
+		tenants, file := newTenants(t, testTenantsFile)
+
+		require.NoError(t, os.WriteFile(file, []byte("tenants:\n  acme:\n    access_log_sample_rate: 2\n"), 0o600))
+		_, err := tenants.reload()
+		require.Error(t, err)
+		require.Equal(t, 2, tenants.Tenants())
+
+		require.NoError(t, os.WriteFile(file, []byte("tenants:\n  acme: {}\n"), 0o600))
+		changed, err := tenants.reload()
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Equal(t, 1, tenants.Tenants())
+	})
+
+	t.Run("rejects invalid tenants", func(t *testing.T) {
+		t.Parallel()
+
+		for _, content := range []string{
+			"tenants:\n  acme:\n    timeouts:\n      query: -1s\n",
+			"tenants:\n  acme:\n    rate_limit:\n      rate: 10\n",
+			"tenants:\n  acme:\n    headers:\n      request:\n        - op: remove\n          named: X-Plan\n",
+			"tenants:\n  acme:\n    unknown: true\n",
+		} {
+			_, err := parseTenants([]byte(content))
+			require.Error(t, err, content)
+		}
+
+		_, err := NewTenantOverrides(zap.NewNop(), &config.TenantsConfiguration{File: "tenants.yaml"})
+		require.Error(t, err)
+		_, err = NewTenantOverrides(zap.NewNop(), &config.TenantsConfiguration{Header: "X-Tenant-ID", File: filepath.Join(t.TempDir(), "missing.yaml")})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// TopOperationsByCount ranks the operations by their number of requests
+	TopOperationsByCount = "count"
+	// TopOperationsByLatency ranks the operations by their average latency
+	TopOperationsByLatency = "latency"
+	// TopOperationsByErrorRate ranks the operations by their ratio of failed requests
+	TopOperationsByErrorRate = "error_rate"
+)
+
+// topOperationsBuckets is the number of buckets of the window. The window moves by a tenth of its length.
+const topOperationsBuckets = 10
+
+type TopOperationsOptions struct {
+	// Window is the period the statistics are computed for
+	Window time.Duration
+	// MaxOperations is the maximum number of distinct operations per tenth of the window. The requests of further
+	// operations are only counted in total.
+	MaxOperations int
+}
+
+// TopOperations keeps the statistics of the operations of the access log over a sliding window in memory, so that the
+// most used, the slowest and the most failing operations can be looked up without a metrics backend. The statistics
+// are shared between all servers, so that they survive router config updates.
+type TopOperations struct {
+	window        time.Duration
+	bucketWidth   time.Duration
+	maxOperations int
+
+	// now returns the current time. It can be replaced in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets []topOperationsBucket
+}
+
+type topOperationKey struct {
+	name   string
+	opType string
+	hash   uint64
+}
+
+type topOperationStats struct {
+	requests   int64
+	errors     int64
+	latencySum time.Duration
+	latencyMax time.Duration
+}
+
+type topOperationsBucket struct {
+	index      int64
+	operations map[topOperationKey]*topOperationStats
+	untracked  int64
+}
+
+// TopOperation are the statistics of an operation in the window
+type TopOperation struct {
+	Name         string  `json:"name"`
+	Type         string  `json:"type"`
+	Hash         string  `json:"hash"`
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+}
+
+func NewTopOperations(opts *TopOperationsOptions) (*TopOperations, error) {
+	if opts.Window < topOperationsBuckets*time.Millisecond {
+		return nil, errors.New("the window of the top operations must be at least 10ms")
+	}
+	if opts.MaxOperations <= 0 {
+		return nil, errors.New("the maximum number of operations of the top operations must be greater than 0")
+	}
+
+	return &TopOperations{
+		window:        opts.Window,
+		bucketWidth:   opts.Window / topOperationsBuckets,
+		maxOperations: opts.MaxOperations,
+		now:           time.Now,
+		// One more bucket than the window spans, because the current bucket is incomplete
+		buckets: make([]topOperationsBucket, topOperationsBuckets+1),
+	}, nil
+}
+
+// Window returns the period the statistics are computed for
+func (t *TopOperations) Window() time.Duration {
+	return t.window
+}
+
+// Observe records the request of the access log. Requests that failed before the operation was parsed aren't
+// recorded.
+func (t *TopOperations) Observe(r *http.Request, status int, latency time.Duration) {
+	lc := getLogEntryContext(r.Context())
+	if lc == nil || lc.requestContext == nil || lc.requestContext.operation == nil {
+		return
+	}
+	operation := lc.requestContext.operation
+	t.Record(operation.name, operation.opType, operation.fingerprint, sloRequestFailed(lc.requestContext.error, status), latency)
+}
+
+// Record adds a request of the operation
+func (t *TopOperations) Record(name, opType string, hash uint64, failed bool, latency time.Duration) {
+	key := topOperationKey{name: name, opType: opType, hash: hash}
+	index := t.now().UnixNano() / int64(t.bucketWidth)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &t.buckets[index%int64(len(t.buckets))]
+	if b.index != index || b.operations == nil {
+		*b = topOperationsBucket{index: index, operations: map[topOperationKey]*topOperationStats{}}
+	}
+
+	stats, ok := b.operations[key]
+	if !ok {
+		if len(b.operations) >= t.maxOperations {
+			b.untracked++
+			return
+		}
+		stats = &topOperationStats{}
+		b.operations[key] = stats
+	}
+
+	stats.requests++
+	if failed {
+		stats.errors++
+	}
+	stats.latencySum += latency
+	stats.latencyMax = max(stats.latencyMax, latency)
+}
+
+// Top returns the first operations of the window ranked by count, latency or error_rate, and the number of requests
+// of the operations above the limit of MaxOperations. Operations with fewer than minRequests requests aren't ranked,
+// so that single slow or failed requests don't dominate the ranking.
+func (t *TopOperations) Top(by string, limit int, minRequests int64) ([]TopOperation, int64, error) {
+	var before func(a, b *TopOperation) bool
+	switch by {
+	case TopOperationsByCount:
+		before = func(a, b *TopOperation) bool { return a.Requests > b.Requests }
+	case TopOperationsByLatency:
+		before = func(a, b *TopOperation) bool { return a.AvgLatencyMs > b.AvgLatencyMs }
+	case TopOperationsByErrorRate:
+		before = func(a, b *TopOperation) bool { return a.ErrorRate > b.ErrorRate }
+	default:
+		return nil, 0, errors.New("unknown ranking '" + by + "', must be count, latency or error_rate")
+	}
+
+	index := t.now().UnixNano() / int64(t.bucketWidth)
+	totals := map[topOperationKey]*topOperationStats{}
+	var untracked int64
+
+	t.mu.Lock()
+	for _, b := range t.buckets {
+		if b.index <= index-topOperationsBuckets || b.index > index {
+			continue
+		}
+		untracked += b.untracked
+		for key, stats := range b.operations {
+			total, ok := totals[key]
+			if !ok {
+				total = &topOperationStats{}
+				totals[key] = total
+			}
+			total.requests += stats.requests
+			total.errors += stats.errors
+			total.latencySum += stats.latencySum
+			total.latencyMax = max(total.latencyMax, stats.latencyMax)
+		}
+	}
+	t.mu.Unlock()
+
+	operations := make([]TopOperation, 0, len(totals))
+	for key, stats := range totals {
+		if stats.requests < minRequests {
+			continue
+		}
+		operations = append(operations, TopOperation{
+			Name:         key.name,
+			Type:         key.opType,
+			Hash:         strconv.FormatUint(key.hash, 10),
+			Requests:     stats.requests,
+			Errors:       stats.errors,
+			ErrorRate:    float64(stats.errors) / float64(stats.requests),
+			AvgLatencyMs: float64(stats.latencySum) / float64(stats.requests) / float64(time.Millisecond),
+			MaxLatencyMs: float64(stats.latencyMax) / float64(time.Millisecond),
+		})
+	}
+
+	// The ties are ranked by the number of requests and the hash, so that the order is stable
+	sort.Slice(operations, func(i, j int) bool {
+		a, b := &operations[i], &operations[j]
+		if before(a, b) != before(b, a) {
+			return before(a, b)
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Hash < b.Hash
+	})
+
+	if limit > 0 && len(operations) > limit {
+		operations = operations[:limit]
+	}
+
+	return operations, untracked, nil
+}
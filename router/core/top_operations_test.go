@@ -0,0 +1,61 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopOperations(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_000_000, 0)
+	top, err := NewTopOperations(&TopOperationsOptions{Window: time.Minute, MaxOperations: 2})
+	require.NoError(t, err)
+	top.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		top.Record("Employees", "query", 1, false, 10*time.Millisecond)
+	}
+	top.Record("UpdateEmployee", "mutation", 2, true, 50*time.Millisecond)
+	top.Record("UpdateEmployee", "mutation", 2, false, 150*time.Millisecond)
+	// Above the maximum number of operations of the bucket
+	top.Record("Products", "query", 3, false, time.Millisecond)
+
+	operations, untracked, err := top.Top(TopOperationsByCount, 10, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), untracked)
+	require.Equal(t, []TopOperation{
+		{Name: "Employees", Type: "query", Hash: "1", Requests: 3, AvgLatencyMs: 10, MaxLatencyMs: 10},
+		{Name: "UpdateEmployee", Type: "mutation", Hash: "2", Requests: 2, Errors: 1, ErrorRate: 0.5, AvgLatencyMs: 100, MaxLatencyMs: 150},
+	}, operations)
+
+	operations, _, err = top.Top(TopOperationsByLatency, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, operations, 1)
+	require.Equal(t, "UpdateEmployee", operations[0].Name)
+
+	// The operations with fewer requests aren't ranked
+	operations, _, err = top.Top(TopOperationsByErrorRate, 10, 3)
+	require.NoError(t, err)
+	require.Len(t, operations, 1)
+	require.Equal(t, "Employees", operations[0].Name)
+
+	// The requests of the next bucket are added, until the first bucket leaves the window
+	now = now.Add(6 * time.Second)
+	top.Record("Products", "query", 3, false, time.Millisecond)
+	operations, untracked, err = top.Top(TopOperationsByCount, 10, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), untracked)
+	require.Len(t, operations, 3)
+
+	now = now.Add(time.Minute)
+	operations, untracked, err = top.Top(TopOperationsByCount, 10, 1)
+	require.NoError(t, err)
+	require.Zero(t, untracked)
+	require.Empty(t, operations)
+
+	_, _, err = top.Top("size", 10, 1)
+	require.EqualError(t, err, "unknown ranking 'size', must be count, latency or error_rate")
+}
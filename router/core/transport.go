@@ -8,16 +8,19 @@ import (
 	"net/url"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
+	"github.com/wundergraph/cosmo/router/pkg/logging"
 	"github.com/wundergraph/cosmo/router/pkg/metric"
 	"github.com/wundergraph/cosmo/router/pkg/otel"
 	"github.com/wundergraph/cosmo/router/pkg/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 
 	"github.com/wundergraph/cosmo/router/internal/docker"
+	rpool "github.com/wundergraph/cosmo/router/internal/pool"
 	"github.com/wundergraph/cosmo/router/internal/retrytransport"
 	"github.com/wundergraph/cosmo/router/internal/unsafebytes"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
@@ -37,8 +40,17 @@ type CustomTransport struct {
 	postHandlers []TransportPostHandler
 	metricStore  metric.Provider
 	logger       *zap.Logger
-
-	sf *singleflight.Group
+	// subgraphLoggers caches the loggers of the subgraphs by their name
+	subgraphLoggers sync.Map
+
+	sf            *singleflight.Group
+	entityBatcher *EntityBatcher
+	// coalescingWindow delays single flight requests, so that identical requests that start later share the response
+	coalescingWindow  time.Duration
+	responseValidator *SubgraphResponseValidator
+	contractEnforcer  *ContractEnforcer
+	connectionTimings *ConnectionTimings
+	slowSubgraphs     *SlowSubgraphWarnings
 }
 
 func NewCustomTransport(
@@ -101,6 +113,41 @@ func (ct *CustomTransport) measureSubgraphMetrics(req *http.Request) func(err er
 	}
 }
 
+// subgraphLogger returns the logger of the subgraph, so that the level override of the subgraph applies
+func (ct *CustomTransport) subgraphLogger(name string) *zap.Logger {
+	if logger, ok := ct.subgraphLoggers.Load(name); ok {
+		return logger.(*zap.Logger)
+	}
+	logger, _ := ct.subgraphLoggers.LoadOrStore(name, logging.ForSubgraph(ct.logger, name))
+	return logger.(*zap.Logger)
+}
+
+func (ct *CustomTransport) logSubgraphRequest(req *http.Request, reqContext *requestContext, resp *http.Response, err error, duration time.Duration) {
+	if ct.logger == nil || reqContext == nil {
+		return
+	}
+	subgraph := reqContext.ActiveSubgraph(req)
+	if subgraph == nil {
+		return
+	}
+	ce := ct.subgraphLogger(subgraph.Name).Check(zap.DebugLevel, "Subgraph request")
+	if ce == nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("url", req.URL.String()),
+		zap.Duration("duration", duration),
+	}
+	if resp != nil {
+		fields = append(fields, zap.Int("status", resp.StatusCode))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
 func (ct *CustomTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
 
 	reqContext := getRequestContext(req.Context())
@@ -121,17 +168,65 @@ func (ct *CustomTransport) RoundTrip(req *http.Request) (resp *http.Response, er
 		}
 	}
 
-	if !ct.allowSingleFlight(req) {
-		resp, err = ct.roundTripper.RoundTrip(req)
-		if err == nil && ct.isUpgradeError(req, resp) {
-			err := &ErrUpgradeFailed{StatusCode: resp.StatusCode}
-			if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
-				err.SubgraphID = subgraph.Id
-			}
+	if reqContext != nil && reqContext.fetchLimiter != nil {
+		limiter := reqContext.fetchLimiter
+		if err = limiter.acquire(req.Context()); err != nil {
 			return nil, err
 		}
+		defer func() {
+			if resp != nil && resp.Body != nil {
+				resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: limiter.release}
+				return
+			}
+			limiter.release()
+		}()
+	}
+
+	if reqContext != nil && reqContext.memoryBudget != nil {
+		budget := reqContext.memoryBudget
+		defer func() {
+			if resp != nil && resp.Body != nil {
+				resp.Body = &memoryBudgetBody{ReadCloser: resp.Body, budget: budget}
+			}
+		}()
+	}
+
+	if ct.connectionTimings != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			req = ct.connectionTimings.traceRequest(req, ct.subgraphLogger(subgraph.Name), subgraph.Name, subgraph.Id)
+		}
+	}
+
+	start := time.Now()
+	if ct.entityBatcher != nil {
+		resp, err = ct.entityBatcher.RoundTrip(req, ct.send)
 	} else {
-		resp, err = ct.roundTripSingleFlight(req)
+		resp, err = ct.send(req)
+	}
+	duration := time.Since(start)
+	ct.logSubgraphRequest(req, reqContext, resp, err, duration)
+	if ct.slowSubgraphs != nil && err == nil && reqContext != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			var operationName string
+			if reqContext.operation != nil {
+				operationName = reqContext.operation.Name()
+			}
+			ct.slowSubgraphs.observe(subgraph.Name, duration, operationName)
+		}
+	}
+	if reqContext != nil && reqContext.executionAudit != nil {
+		if subgraph := reqContext.ActiveSubgraph(req); subgraph != nil {
+			reqContext.executionAudit.subgraphRequest(subgraph.Name, err != nil || resp.StatusCode >= http.StatusInternalServerError)
+		}
+	}
+	if _, ok := err.(*ErrUpgradeFailed); ok {
+		return nil, err
+	}
+	if err == nil && ct.responseValidator != nil {
+		err = ct.responseValidator.ValidateResponse(req, resp)
+	}
+	if err == nil && ct.contractEnforcer != nil {
+		err = ct.contractEnforcer.EnforceResponse(req, resp)
 	}
 
 	// Set the error on the request context so that it can be checked by the post handlers
@@ -156,6 +251,22 @@ func (ct *CustomTransport) RoundTrip(req *http.Request) (resp *http.Response, er
 	return resp, err
 }
 
+func (ct *CustomTransport) send(req *http.Request) (*http.Response, error) {
+	if ct.allowSingleFlight(req) {
+		return ct.roundTripSingleFlight(req)
+	}
+
+	resp, err := ct.roundTripper.RoundTrip(req)
+	if err == nil && ct.isUpgradeError(req, resp) {
+		err := &ErrUpgradeFailed{StatusCode: resp.StatusCode}
+		if subgraph := getRequestContext(req.Context()).ActiveSubgraph(req); subgraph != nil {
+			err.SubgraphID = subgraph.Id
+		}
+		return nil, err
+	}
+	return resp, err
+}
+
 type responseWithBody struct {
 	res  *http.Response
 	body []byte
@@ -239,18 +350,35 @@ func (ct *CustomTransport) roundTripSingleFlight(req *http.Request) (*http.Respo
 
 	// We need to use the single flight group to ensure that the request is only sent once
 	v, err, shared := ct.sf.Do(key, func() (interface{}, error) {
+		if ct.coalescingWindow > 0 {
+			timer := time.NewTimer(ct.coalescingWindow)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+
 		res, err := ct.roundTripper.RoundTrip(req)
 		if err != nil {
 			return nil, err
 		}
-		buf.Reset()
-		_, err = buf.ReadFrom(res.Body)
+		defer res.Body.Close()
+
+		// The body is read into a pooled buffer of the matching size class and copied once, because
+		// the shared response outlives this function and can't be returned to the pool.
+		// bytes.MinRead is added because ReadFrom grows the buffer when less space is left.
+		resBuf := rpool.GetBytesBufferWithSize(res.ContentLength + bytes.MinRead)
+		defer rpool.PutBytesBuffer(resBuf)
+
+		_, err = resBuf.ReadFrom(res.Body)
 		if err != nil {
 			return nil, err
 		}
 		return &responseWithBody{
 			res:  res,
-			body: buf.Bytes(),
+			body: bytes.Clone(resBuf.Bytes()),
 		}, nil
 	})
 	if err != nil {
@@ -289,6 +417,19 @@ type TransportFactory struct {
 	metricStore                   metric.Provider
 	logger                        *zap.Logger
 	tracerProvider                *sdktrace.TracerProvider
+	entityBatcher                 *EntityBatcher
+	coalescingWindow              time.Duration
+	responseValidator             *SubgraphResponseValidator
+	contractEnforcer              *ContractEnforcer
+	chaos                         *ChaosInjector
+	endpoints                     *SubgraphEndpointSwitch
+	adaptiveConcurrency           *AdaptiveConcurrency
+	mocks                         *SubgraphMocks
+	compression                   *SubgraphCompression
+	payloadSize                   *SubgraphPayloadSize
+	connectionTimings             *ConnectionTimings
+	slowSubgraphs                 *SlowSubgraphWarnings
+	tunnels                       *SubgraphTunnels
 }
 
 var _ ApiTransportFactory = TransportFactory{}
@@ -302,6 +443,32 @@ type TransportOptions struct {
 	MetricStore                   metric.Provider
 	Logger                        *zap.Logger
 	TracerProvider                *sdktrace.TracerProvider
+	// EntityBatcher rewrites the _entities requests to the subgraphs. Nil sends them unchanged.
+	EntityBatcher *EntityBatcher
+	// CoalescingWindow enables single flight and delays the requests by the window. Zero disables it.
+	CoalescingWindow time.Duration
+	// ResponseValidator validates the responses of the subgraphs. Nil disables it.
+	ResponseValidator *SubgraphResponseValidator
+	// ContractEnforcer removes the excluded fields from the responses of the subgraphs. Nil disables it.
+	ContractEnforcer *ContractEnforcer
+	// Chaos injects faults into the requests to the subgraphs. Nil disables it.
+	Chaos *ChaosInjector
+	// Endpoints sends a share of the requests to the alternate endpoints of the subgraphs. Nil disables it.
+	Endpoints *SubgraphEndpointSwitch
+	// AdaptiveConcurrency limits the in-flight requests of the subgraphs. Nil disables it.
+	AdaptiveConcurrency *AdaptiveConcurrency
+	// Mocks answers the requests to the subgraphs with generated data. Nil disables it.
+	Mocks *SubgraphMocks
+	// Compression requests compressed responses from the subgraphs. Nil disables it.
+	Compression *SubgraphCompression
+	// PayloadSize measures and limits the size of the subgraph payloads. Nil disables it.
+	PayloadSize *SubgraphPayloadSize
+	// ConnectionTimings measures the setup of the connections to the subgraphs. Nil disables it.
+	ConnectionTimings *ConnectionTimings
+	// SlowSubgraphs warns about the subgraph requests that are slower than the baseline of the subgraph. Nil disables it.
+	SlowSubgraphs *SlowSubgraphWarnings
+	// Tunnels sends the requests to single subgraphs through a proxy or an ssh tunnel. Nil disables it.
+	Tunnels *SubgraphTunnels
 }
 
 func NewTransport(opts *TransportOptions) *TransportFactory {
@@ -314,6 +481,19 @@ func NewTransport(opts *TransportOptions) *TransportFactory {
 		metricStore:                   opts.MetricStore,
 		logger:                        opts.Logger,
 		tracerProvider:                opts.TracerProvider,
+		entityBatcher:                 opts.EntityBatcher,
+		coalescingWindow:              opts.CoalescingWindow,
+		responseValidator:             opts.ResponseValidator,
+		contractEnforcer:              opts.ContractEnforcer,
+		chaos:                         opts.Chaos,
+		endpoints:                     opts.Endpoints,
+		adaptiveConcurrency:           opts.AdaptiveConcurrency,
+		mocks:                         opts.Mocks,
+		compression:                   opts.Compression,
+		payloadSize:                   opts.PayloadSize,
+		connectionTimings:             opts.ConnectionTimings,
+		slowSubgraphs:                 opts.SlowSubgraphs,
+		tunnels:                       opts.Tunnels,
 	}
 }
 
@@ -321,6 +501,35 @@ func (t TransportFactory) RoundTripper(enableSingleFlight bool, transport http.R
 	if t.localhostFallbackInsideDocker && docker.Inside() {
 		transport = docker.NewLocalhostFallbackRoundTripper(transport)
 	}
+	// The tunneled subgraphs are dialed from the other end of the tunnel, so the localhost fallback doesn't apply
+	if t.tunnels != nil {
+		transport = t.tunnels.RoundTripper(transport)
+	}
+	// The mocks replace the subgraphs, so that the faults and the switched endpoints apply to them as well
+	if t.mocks != nil {
+		transport = t.mocks.RoundTripper(transport)
+	}
+	// The faults are injected below the retries and the tracing, like the faults of the subgraphs
+	if t.chaos != nil {
+		transport = t.chaos.RoundTripper(transport)
+	}
+	// The endpoints are switched above the chaos mode, so that the injected faults trigger the rollback
+	if t.endpoints != nil {
+		transport = t.endpoints.RoundTripper(transport)
+	}
+	// The limit adapts to the latency of the subgraph including the faults of the chaos mode. It is below the
+	// retries, so that every attempt takes a slot.
+	if t.adaptiveConcurrency != nil {
+		transport = t.adaptiveConcurrency.RoundTripper(transport)
+	}
+	// The responses are decompressed before they are traced, validated and read by the engine
+	if t.compression != nil {
+		transport = t.compression.RoundTripper(transport)
+	}
+	// The decompressed responses are limited, so that the limit protects against decompression bombs too
+	if t.payloadSize != nil {
+		transport = t.payloadSize.RoundTripper(transport)
+	}
 	traceTransport := trace.NewTransport(
 		transport,
 		[]otelhttp.Option{
@@ -354,12 +563,18 @@ func (t TransportFactory) RoundTripper(enableSingleFlight bool, transport http.R
 		traceTransport,
 		t.retryOptions,
 		t.metricStore,
-		enableSingleFlight,
+		enableSingleFlight || t.coalescingWindow > 0,
 	)
 
 	tp.preHandlers = t.preHandlers
 	tp.postHandlers = t.postHandlers
 	tp.logger = t.logger
+	tp.entityBatcher = t.entityBatcher
+	tp.coalescingWindow = t.coalescingWindow
+	tp.responseValidator = t.responseValidator
+	tp.contractEnforcer = t.contractEnforcer
+	tp.connectionTimings = t.connectionTimings
+	tp.slowSubgraphs = t.slowSubgraphs
 
 	return tp
 }
@@ -0,0 +1,261 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+var ErrUsageQuotaExceeded = errors.New("usage quota exceeded")
+
+const (
+	// UsageQuotaPeriodDay resets the quota at midnight UTC
+	UsageQuotaPeriodDay = "day"
+	// UsageQuotaPeriodMonth resets the quota at midnight UTC of the first day of the month
+	UsageQuotaPeriodMonth = "month"
+)
+
+const (
+	// UsageQuotaUnitRequests counts every operation once
+	UsageQuotaUnitRequests = "requests"
+	// UsageQuotaUnitFetches counts the subgraph fetches of the operations as their cost
+	UsageQuotaUnitFetches = "fetches"
+)
+
+// usageQuotaScript checks the counters of the quotas of a key and consumes the units of the request if none is
+// exhausted. The arguments are the limit, the units of the request and the expiry of every counter. Quotas whose units
+// are only known after the execution are consumed with 0 units and only checked for remaining units.
+var usageQuotaScript = redis.NewScript(`
+for i = 1, #KEYS do
+  local used = tonumber(redis.call("GET", KEYS[i]) or "0")
+  local limit = tonumber(ARGV[i * 3 - 2])
+  local n = tonumber(ARGV[i * 3 - 1])
+  if used + math.max(n, 1) > limit then
+    return i
+  end
+end
+
+for i = 1, #KEYS do
+  local n = tonumber(ARGV[i * 3 - 1])
+  if n > 0 then
+    redis.call("INCRBY", KEYS[i], n)
+    redis.call("EXPIREAT", KEYS[i], ARGV[i * 3])
+  end
+end
+
+return 0
+`)
+
+// UsageQuotas are long-period budgets of the rate limit keys. The units are counted in Redis per key, quota and period,
+// so the counters of a period are shared by all instances and expire once the period ended.
+type UsageQuotas struct {
+	client *redis.Client
+	quotas []config.RateLimitUsageQuota
+	now    func() time.Time
+}
+
+func NewUsageQuotas(client *redis.Client, quotas []config.RateLimitUsageQuota) (*UsageQuotas, error) {
+	names := make(map[string]struct{}, len(quotas))
+	for _, quota := range quotas {
+		if quota.Name == "" {
+			return nil, errors.New("the usage quotas require a name")
+		}
+		if _, ok := names[quota.Name]; ok {
+			return nil, fmt.Errorf("the usage quota '%s' is defined twice", quota.Name)
+		}
+		names[quota.Name] = struct{}{}
+
+		switch quota.Period {
+		case UsageQuotaPeriodDay, UsageQuotaPeriodMonth:
+		default:
+			return nil, fmt.Errorf("unknown period '%s' of the usage quota '%s'", quota.Period, quota.Name)
+		}
+		switch quota.Unit {
+		case "", UsageQuotaUnitRequests, UsageQuotaUnitFetches:
+		default:
+			return nil, fmt.Errorf("unknown unit '%s' of the usage quota '%s'", quota.Unit, quota.Name)
+		}
+		if quota.Limit <= 0 {
+			return nil, fmt.Errorf("the limit of the usage quota '%s' must be positive", quota.Name)
+		}
+	}
+
+	return &UsageQuotas{
+		client: client,
+		quotas: quotas,
+		now:    time.Now,
+	}, nil
+}
+
+// UsageQuotaStatus is the usage of a quota of a key in the current period
+type UsageQuotaStatus struct {
+	Name     string    `json:"name"`
+	Period   string    `json:"period"`
+	Unit     string    `json:"unit"`
+	Limit    int64     `json:"limit"`
+	Used     int64     `json:"used"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// UsageQuotaExceededError is returned when a quota of the key is exhausted until the end of the period
+type UsageQuotaExceededError struct {
+	Quota    string
+	ResetsAt time.Time
+}
+
+func (e *UsageQuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUsageQuotaExceeded, e.Quota)
+}
+
+func (e *UsageQuotaExceededError) Unwrap() error {
+	return ErrUsageQuotaExceeded
+}
+
+// Allow consumes a request of the quotas counted in requests. It returns a UsageQuotaExceededError if a quota of the
+// key is exhausted, in which case nothing is consumed.
+func (u *UsageQuotas) Allow(ctx context.Context, key string) error {
+	now := u.now()
+	keys := make([]string, 0, len(u.quotas))
+	args := make([]any, 0, len(u.quotas)*3)
+	for _, quota := range u.quotas {
+		n := 0
+		if usageQuotaUnit(quota) == UsageQuotaUnitRequests {
+			n = 1
+		}
+		start, end := usageQuotaPeriod(quota.Period, now)
+		keys = append(keys, usageQuotaCounterKey(key, quota, start))
+		args = append(args, quota.Limit, n, end.Unix())
+	}
+
+	exceeded, err := usageQuotaScript.Run(ctx, u.client, keys, args...).Int()
+	if err != nil {
+		return err
+	}
+	if exceeded == 0 {
+		return nil
+	}
+
+	quota := u.quotas[exceeded-1]
+	_, end := usageQuotaPeriod(quota.Period, now)
+	return &UsageQuotaExceededError{Quota: quota.Name, ResetsAt: end}
+}
+
+// Record consumes the fetches of an executed request of the quotas counted in fetches
+func (u *UsageQuotas) Record(ctx context.Context, key string, fetches int) error {
+	if fetches <= 0 {
+		return nil
+	}
+
+	now := u.now()
+	pipe := u.client.Pipeline()
+	for _, quota := range u.quotas {
+		if usageQuotaUnit(quota) != UsageQuotaUnitFetches {
+			continue
+		}
+		start, end := usageQuotaPeriod(quota.Period, now)
+		counter := usageQuotaCounterKey(key, quota, start)
+		pipe.IncrBy(ctx, counter, int64(fetches))
+		pipe.ExpireAt(ctx, counter, end)
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Usage returns the usage of the quotas of the key in the current periods
+func (u *UsageQuotas) Usage(ctx context.Context, key string) ([]UsageQuotaStatus, error) {
+	now := u.now()
+	keys := make([]string, 0, len(u.quotas))
+	for _, quota := range u.quotas {
+		start, _ := usageQuotaPeriod(quota.Period, now)
+		keys = append(keys, usageQuotaCounterKey(key, quota, start))
+	}
+
+	values, err := u.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]UsageQuotaStatus, 0, len(u.quotas))
+	for i, quota := range u.quotas {
+		_, end := usageQuotaPeriod(quota.Period, now)
+		status := UsageQuotaStatus{
+			Name:     quota.Name,
+			Period:   quota.Period,
+			Unit:     usageQuotaUnit(quota),
+			Limit:    quota.Limit,
+			ResetsAt: end,
+		}
+		if value, ok := values[i].(string); ok {
+			status.Used, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid counter of the usage quota '%s': %w", quota.Name, err)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Reset deletes the counters of the current periods of the key. With a name, only the counter of that quota is
+// deleted. It returns false if no quota has the name.
+func (u *UsageQuotas) Reset(ctx context.Context, key, name string) (bool, error) {
+	now := u.now()
+	var keys []string
+	for _, quota := range u.quotas {
+		if name != "" && quota.Name != name {
+			continue
+		}
+		start, _ := usageQuotaPeriod(quota.Period, now)
+		keys = append(keys, usageQuotaCounterKey(key, quota, start))
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+	return true, u.client.Del(ctx, keys...).Err()
+}
+
+// countsFetches reports whether a quota is counted in fetches, so that the fetches of the requests have to be recorded
+func (u *UsageQuotas) countsFetches() bool {
+	for _, quota := range u.quotas {
+		if usageQuotaUnit(quota) == UsageQuotaUnitFetches {
+			return true
+		}
+	}
+	return false
+}
+
+func usageQuotaUnit(quota config.RateLimitUsageQuota) string {
+	if quota.Unit == "" {
+		return UsageQuotaUnitRequests
+	}
+	return quota.Unit
+}
+
+// usageQuotaPeriod returns the start and the end of the period of the quota that contains the time
+func usageQuotaPeriod(period string, now time.Time) (start, end time.Time) {
+	now = now.UTC()
+	if period == UsageQuotaPeriodMonth {
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// usageQuotaCounterKey returns the key of the counter of the quota in the period that starts at the time
+func usageQuotaCounterKey(key string, quota config.RateLimitUsageQuota, start time.Time) string {
+	layout := "2006-01-02"
+	if quota.Period == UsageQuotaPeriodMonth {
+		layout = "2006-01"
+	}
+	return key + ":usage:" + quota.Name + ":" + start.Format(layout)
+}
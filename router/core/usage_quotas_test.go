@@ -0,0 +1,64 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestUsageQuotaPeriod(t *testing.T) {
+	t.Parallel()
+
+	// The periods are in UTC, regardless of the zone of the time
+	now := time.Date(2026, 12, 31, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+
+	start, end := usageQuotaPeriod(UsageQuotaPeriodDay, now)
+	require.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC), end)
+	require.Equal(t, "cosmo_rate_limit:acme:usage:daily:2027-01-01",
+		usageQuotaCounterKey("cosmo_rate_limit:acme", config.RateLimitUsageQuota{Name: "daily", Period: UsageQuotaPeriodDay}, start))
+
+	start, end = usageQuotaPeriod(UsageQuotaPeriodMonth, now)
+	require.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC), end)
+	require.Equal(t, "cosmo_rate_limit:usage:monthly:2027-01",
+		usageQuotaCounterKey("cosmo_rate_limit", config.RateLimitUsageQuota{Name: "monthly", Period: UsageQuotaPeriodMonth}, start))
+}
+
+func TestNewUsageQuotas(t *testing.T) {
+	t.Parallel()
+
+	quotas, err := NewUsageQuotas(nil, []config.RateLimitUsageQuota{
+		{Name: "daily", Period: UsageQuotaPeriodDay, Limit: 100},
+		{Name: "monthly", Period: UsageQuotaPeriodMonth, Limit: 1000, Unit: UsageQuotaUnitRequests},
+	})
+	require.NoError(t, err)
+	require.False(t, quotas.countsFetches())
+
+	quotas, err = NewUsageQuotas(nil, []config.RateLimitUsageQuota{
+		{Name: "monthly", Period: UsageQuotaPeriodMonth, Limit: 1000, Unit: UsageQuotaUnitFetches},
+	})
+	require.NoError(t, err)
+	require.True(t, quotas.countsFetches())
+
+	_, err = NewUsageQuotas(nil, []config.RateLimitUsageQuota{
+		{Name: "daily", Period: UsageQuotaPeriodDay, Limit: 100},
+		{Name: "daily", Period: UsageQuotaPeriodMonth, Limit: 100},
+	})
+	require.EqualError(t, err, "the usage quota 'daily' is defined twice")
+
+	_, err = NewUsageQuotas(nil, []config.RateLimitUsageQuota{{Name: "weekly", Period: "week", Limit: 100}})
+	require.EqualError(t, err, "unknown period 'week' of the usage quota 'weekly'")
+
+	_, err = NewUsageQuotas(nil, []config.RateLimitUsageQuota{{Name: "daily", Period: UsageQuotaPeriodDay, Limit: 100, Unit: "bytes"}})
+	require.EqualError(t, err, "unknown unit 'bytes' of the usage quota 'daily'")
+
+	_, err = NewUsageQuotas(nil, []config.RateLimitUsageQuota{{Name: "daily", Period: UsageQuotaPeriodDay}})
+	require.EqualError(t, err, "the limit of the usage quota 'daily' must be positive")
+
+	require.EqualError(t, &UsageQuotaExceededError{Quota: "daily"}, "usage quota exceeded: daily")
+	require.ErrorIs(t, &UsageQuotaExceededError{Quota: "daily"}, ErrUsageQuotaExceeded)
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/buger/jsonparser"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+const allVariables = "*"
+
+// VariableRedactor redacts the values of the variables of the operations in the access logs and the traces. Only
+// the allowed variables of an operation keep their values, so that specific non-sensitive operations can be
+// debugged while all other variables stay redacted.
+type VariableRedactor struct {
+	replacement []byte
+	// operations maps the operation names to their allowed variables. A nil set allows all variables.
+	operations map[string]map[string]struct{}
+}
+
+func NewVariableRedactor(cfg *config.VariableRedactionConfiguration) *VariableRedactor {
+	v := &VariableRedactor{
+		replacement: []byte(strconv.Quote(cfg.Replacement)),
+		operations:  make(map[string]map[string]struct{}, len(cfg.Operations)),
+	}
+	for _, operation := range cfg.Operations {
+		allowed, ok := v.operations[operation.Name]
+		if ok && allowed == nil {
+			continue
+		}
+		if allowed == nil {
+			allowed = make(map[string]struct{}, len(operation.Variables))
+		}
+		for _, name := range operation.Variables {
+			if name == allVariables {
+				allowed = nil
+				break
+			}
+			allowed[name] = struct{}{}
+		}
+		v.operations[operation.Name] = allowed
+	}
+	return v
+}
+
+// redact returns the variables with the values of all variables that the operation doesn't allow replaced. The
+// variables are returned as is when the redactor is nil. Variables that aren't a JSON object are replaced entirely.
+func (v *VariableRedactor) redact(operationName string, variables []byte) []byte {
+	if v == nil || len(variables) == 0 {
+		return variables
+	}
+
+	allowed, ok := v.operations[operationName]
+	if ok && allowed == nil {
+		return variables
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(variables))
+	buf.WriteByte('{')
+	err := jsonparser.ObjectEach(variables, func(key []byte, value []byte, dataType jsonparser.ValueType, _ int) error {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('"')
+		buf.Write(key)
+		buf.WriteString(`":`)
+
+		if _, ok := allowed[string(key)]; !ok {
+			buf.Write(v.replacement)
+			return nil
+		}
+		// The value of a string is returned without its quotes but still escaped
+		if dataType == jsonparser.String {
+			buf.WriteByte('"')
+			buf.Write(value)
+			buf.WriteByte('"')
+			return nil
+		}
+		buf.Write(value)
+		return nil
+	})
+	if err != nil {
+		return v.replacement
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes()
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func TestVariableRedactor(t *testing.T) {
+	t.Parallel()
+
+	redactor := NewVariableRedactor(&config.VariableRedactionConfiguration{
+		Replacement: "[REDACTED]",
+		Operations: []config.VariableAllowlistOperation{
+			{Name: "GetEmployee", Variables: []string{"id"}},
+			{Name: "GetEmployee", Variables: []string{"filter"}},
+			{Name: "ListProducts", Variables: []string{"*"}},
+		},
+	})
+
+	testCases := []struct {
+		name      string
+		operation string
+		variables string
+		expected  string
+	}{
+		{
+			name:      "redacts all variables of unknown operations",
+			operation: "Login",
+			variables: `{"email":"a@b.c","password":"secret","remember":true}`,
+			expected:  `{"email":"[REDACTED]","password":"[REDACTED]","remember":"[REDACTED]"}`,
+		},
+		{
+			name:      "keeps the allowed variables",
+			operation: "GetEmployee",
+			variables: `{"id":1,"filter":{"name":"a\"b"},"token":"secret"}`,
+			expected:  `{"id":1,"filter":{"name":"a\"b"},"token":"[REDACTED]"}`,
+		},
+		{
+			name:      "keeps all variables",
+			operation: "ListProducts",
+			variables: `{"first":10, "after": "abc"}`,
+			expected:  `{"first":10, "after": "abc"}`,
+		},
+		{
+			name:      "keeps escaped strings",
+			operation: "GetEmployee",
+			variables: `{"id":"é\n"}`,
+			expected:  `{"id":"é\n"}`,
+		},
+		{
+			name:      "replaces variables that aren't an object",
+			operation: "Login",
+			variables: `null`,
+			expected:  `"[REDACTED]"`,
+		},
+		{
+			name:      "keeps empty variables",
+			operation: "Login",
+			variables: ``,
+			expected:  ``,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, string(redactor.redact(tc.operation, []byte(tc.variables))))
+		})
+	}
+
+	var disabled *VariableRedactor
+	require.Equal(t, `{"password":"secret"}`, string(disabled.redact("Login", []byte(`{"password":"secret"}`))))
+}
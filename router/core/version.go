@@ -1,4 +1,111 @@
 package core
 
-// Version set by the build system.
-var Version = "dev"
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+)
+
+// Version, Commit and Date are set by the build system. Commit and Date fall back to the
+// VCS information that Go embeds in the binary, in which case Date is the time of the commit.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// VersionInfo describes the running binary. It is returned by the version endpoint and the version command.
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	// FeatureFlags are the names of the feature flags of the active router config
+	FeatureFlags []string `json:"feature_flags,omitempty"`
+	// Features are the router features that are enabled in the running instance
+	Features *StartupReportFeatures `json:"features,omitempty"`
+	// Dependencies are the Go modules compiled into the binary
+	Dependencies []VersionDependency `json:"dependencies,omitempty"`
+}
+
+type VersionDependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+	// Replace is the module path that replaces this module, if any
+	Replace string `json:"replace,omitempty"`
+}
+
+// BuildVersionInfo returns the version information of the binary. The list of dependencies
+// is only included when requested because it's large and not needed by most consumers.
+func BuildVersionInfo(withDependencies bool) *VersionInfo {
+	info := &VersionInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: Date,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	for _, setting := range buildInfo.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	if withDependencies {
+		info.Dependencies = make([]VersionDependency, 0, len(buildInfo.Deps))
+		for _, dep := range buildInfo.Deps {
+			d := VersionDependency{
+				Path:    dep.Path,
+				Version: dep.Version,
+				Sum:     dep.Sum,
+			}
+			if dep.Replace != nil {
+				d.Replace = dep.Replace.Path
+				if dep.Replace.Version != "" {
+					d.Replace += "@" + dep.Replace.Version
+				}
+			}
+			info.Dependencies = append(info.Dependencies, d)
+		}
+	}
+
+	return info
+}
+
+// versionHandler serves the version information together with the feature flags of the server
+func (r *Router) versionHandler(featureFlags []string) http.HandlerFunc {
+	flags := make([]string, len(featureFlags))
+	copy(flags, featureFlags)
+	sort.Strings(flags)
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		info := BuildVersionInfo(req.URL.Query().Get("dependencies") == "true")
+		info.FeatureFlags = flags
+
+		features := r.enabledFeatures()
+		info.Features = &features
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(info)
+	}
+}
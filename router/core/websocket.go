@@ -35,42 +35,54 @@ import (
 
 var (
 	errClientTerminatedConnection = errors.New("client terminated connection")
+	// errWebSocketSubscriptionsOnly rejects the queries and mutations when only subscriptions are allowed
+	errWebSocketSubscriptionsOnly = errors.New("only subscriptions are allowed over WebSocket connections")
 )
 
 type WebsocketMiddlewareOptions struct {
-	OperationProcessor *OperationProcessor
-	OperationBlocker   *OperationBlocker
-	Planner            *OperationPlanner
-	GraphQLHandler     *GraphQLHandler
-	Metrics            RouterMetrics
-	AccessController   *AccessController
-	Logger             *zap.Logger
-	Stats              WebSocketsStatistics
-	ReadTimeout        time.Duration
+	OperationProcessor           *OperationProcessor
+	OperationBlocker             *OperationBlocker
+	PersistedOperationKillSwitch *PersistedOperationKillSwitch
+	SealedVariables              *SealedVariables
+	Planner                      *OperationPlanner
+	GraphQLHandler               *GraphQLHandler
+	Metrics                      RouterMetrics
+	AccessController             *AccessController
+	Logger                       *zap.Logger
+	Stats                        WebSocketsStatistics
+	ReadTimeout                  time.Duration
 
 	EnableWebSocketEpollKqueue bool
 	EpollKqueuePollTimeout     time.Duration
 	EpollKqueueConnBufferSize  int
 
 	WebSocketConfiguration *config.WebSocketConfiguration
+	// WebSocketTransport limits and compresses the messages. Optional.
+	WebSocketTransport *WebSocketTransport
+	// ClientProtocols counts the operations by the protocol of the client. Optional.
+	ClientProtocols *ClientProtocols
 }
 
 func NewWebsocketMiddleware(ctx context.Context, opts WebsocketMiddlewareOptions) func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		handler := &WebsocketHandler{
-			ctx:                ctx,
-			next:               next,
-			operationProcessor: opts.OperationProcessor,
-			operationBlocker:   opts.OperationBlocker,
-			planner:            opts.Planner,
-			graphqlHandler:     opts.GraphQLHandler,
-			metrics:            opts.Metrics,
-			accessController:   opts.AccessController,
-			logger:             opts.Logger,
-			stats:              opts.Stats,
-			readTimeout:        opts.ReadTimeout,
-			config:             opts.WebSocketConfiguration,
+			ctx:                   ctx,
+			next:                  next,
+			operationProcessor:    opts.OperationProcessor,
+			operationBlocker:      opts.OperationBlocker,
+			persistedOpKillSwitch: opts.PersistedOperationKillSwitch,
+			sealedVariables:       opts.SealedVariables,
+			planner:               opts.Planner,
+			graphqlHandler:        opts.GraphQLHandler,
+			metrics:               opts.Metrics,
+			accessController:      opts.AccessController,
+			logger:                opts.Logger,
+			stats:                 opts.Stats,
+			readTimeout:           opts.ReadTimeout,
+			config:                opts.WebSocketConfiguration,
+			transport:             opts.WebSocketTransport,
+			clientProtocols:       opts.ClientProtocols,
 		}
 		if opts.WebSocketConfiguration != nil && opts.WebSocketConfiguration.AbsintheProtocol.Enabled {
 			handler.absintheHandlerEnabled = true
@@ -144,17 +156,25 @@ type wsConnectionWrapper struct {
 	conn net.Conn
 	mu   sync.Mutex
 	rw   *bufio.ReadWriter
+	// transport limits the size of the messages and counts the bytes of all connections. Optional.
+	transport *WebSocketTransport
+	// compressed is true when the client negotiated the permessage-deflate extension
+	compressed bool
+	received   atomic.Int64
+	sent       atomic.Int64
 }
 
-func newWSConnectionWrapper(conn net.Conn, rw *bufio.ReadWriter) *wsConnectionWrapper {
+func newWSConnectionWrapper(conn net.Conn, rw *bufio.ReadWriter, transport *WebSocketTransport, compressed bool) *wsConnectionWrapper {
 	return &wsConnectionWrapper{
-		conn: conn,
-		rw:   rw,
+		conn:       conn,
+		rw:         rw,
+		transport:  transport,
+		compressed: compressed,
 	}
 }
 
 func (c *wsConnectionWrapper) ReadJSON(v interface{}) error {
-	text, err := wsutil.ReadClientText(c.conn)
+	text, err := c.readClientText()
 	if err != nil {
 		return err
 	}
@@ -164,11 +184,7 @@ func (c *wsConnectionWrapper) ReadJSON(v interface{}) error {
 func (c *wsConnectionWrapper) WriteText(text string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	err := wsutil.WriteServerText(c.rw, []byte(text))
-	if err != nil {
-		return err
-	}
-	return c.rw.Flush()
+	return c.writeServerText([]byte(text))
 }
 
 func (c *wsConnectionWrapper) WriteJSON(v interface{}) error {
@@ -178,11 +194,7 @@ func (c *wsConnectionWrapper) WriteJSON(v interface{}) error {
 	if err != nil {
 		return err
 	}
-	err = wsutil.WriteServerText(c.rw, data)
-	if err != nil {
-		return err
-	}
-	return c.rw.Flush()
+	return c.writeServerText(data)
 }
 
 func (c *wsConnectionWrapper) Close() error {
@@ -192,16 +204,20 @@ func (c *wsConnectionWrapper) Close() error {
 }
 
 type WebsocketHandler struct {
-	ctx                context.Context
-	config             *config.WebSocketConfiguration
-	next               http.Handler
-	operationProcessor *OperationProcessor
-	operationBlocker   *OperationBlocker
-	planner            *OperationPlanner
-	graphqlHandler     *GraphQLHandler
-	metrics            RouterMetrics
-	accessController   *AccessController
-	logger             *zap.Logger
+	ctx                   context.Context
+	config                *config.WebSocketConfiguration
+	next                  http.Handler
+	operationProcessor    *OperationProcessor
+	operationBlocker      *OperationBlocker
+	persistedOpKillSwitch *PersistedOperationKillSwitch
+	sealedVariables       *SealedVariables
+	planner               *OperationPlanner
+	graphqlHandler        *GraphQLHandler
+	metrics               RouterMetrics
+	accessController      *AccessController
+	logger                *zap.Logger
+	transport             *WebSocketTransport
+	clientProtocols       *ClientProtocols
 
 	epoll         epoller.Poller
 	connections   map[int]*WebSocketConnectionHandler
@@ -235,20 +251,42 @@ func (h *WebsocketHandler) handleUpgradeRequest(w http.ResponseWriter, r *http.R
 	)
 
 	requestID := middleware.GetReqID(r.Context())
-	requestLogger := h.logger.With(logging.WithRequestID(requestID))
+	requestLogger := logging.WithTraceContext(r.Context(), h.logger.With(logging.WithRequestID(requestID)))
 	clientInfo := NewClientInfoFromRequest(r)
 
 	// Check access control before upgrading the connection
 	validatedReq, err := h.accessController.Access(w, r)
-	if err != nil {
+	// The client can authenticate with the token of the initial payload instead
+	unauthenticated := err != nil && errors.Is(err, ErrUnauthorized) && h.config.Authentication.FromInitialPayload
+	if err != nil && !unauthenticated {
 		statusCode := http.StatusForbidden
 		if errors.Is(err, ErrUnauthorized) {
 			statusCode = http.StatusUnauthorized
 		}
+		logHTTPError(requestLogger, r, statusCode, err)
+		if problemDetailsEnabled(r.Context()) {
+			writeProblemDetails(r, w, statusCode, err, requestLogger)
+			return
+		}
 		http.Error(w, http.StatusText(statusCode), statusCode)
 		return
 	}
-	r = validatedReq
+	if err == nil {
+		r = validatedReq
+	}
+
+	// The connection slot is taken before the upgrade, so that the rejection is a regular HTTP response
+	limits := h.graphqlHandler.subscriptionLimits
+	var limitClient string
+	releaseConnection := func() {}
+	if limits != nil {
+		limitClient = limits.clientKey(r)
+		releaseConnection, err = limits.acquireConnection(limitClient)
+		if err != nil {
+			writeSubscriptionLimitError(w, err, requestLogger)
+			return
+		}
+	}
 
 	upgrader := ws.HTTPUpgrader{
 		Timeout: time.Second * 5,
@@ -260,10 +298,15 @@ func (h *WebsocketHandler) handleUpgradeRequest(w http.ResponseWriter, r *http.R
 			return false
 		},
 	}
+	compression := h.transport.extension()
+	if compression != nil {
+		upgrader.Negotiate = compression.Negotiate
+	}
 	c, rw, _, err := upgrader.Upgrade(r, w)
 	if err != nil {
 		requestLogger.Warn("Websocket upgrade", zap.Error(err))
 		_ = c.Close()
+		releaseConnection()
 		return
 	}
 
@@ -276,42 +319,68 @@ func (h *WebsocketHandler) handleUpgradeRequest(w http.ResponseWriter, r *http.R
 	// After successful upgrade, we can't write to the response writer anymore
 	// because it's hijacked by the websocket connection
 
-	conn := newWSConnectionWrapper(c, rw)
+	var compressed bool
+	if compression != nil {
+		_, compressed = compression.Accepted()
+	}
+	clientProtocol := webSocketClientProtocol(subProtocol)
+	if lc := getLogEntryContext(r.Context()); lc != nil && h.clientProtocols != nil {
+		lc.clientProtocol = clientProtocol
+	}
+
+	conn := newWSConnectionWrapper(c, rw, h.transport, compressed)
 	protocol, err := wsproto.NewProtocol(subProtocol, conn)
 	if err != nil {
 		requestLogger.Error("Create websocket protocol", zap.Error(err))
 		_ = c.Close()
+		releaseConnection()
 		return
 	}
 
 	handler := NewWebsocketConnectionHandler(h.ctx, WebSocketConnectionHandlerOptions{
-		OperationProcessor:    h.operationProcessor,
-		OperationBlocker:      h.operationBlocker,
-		Planner:               h.planner,
-		GraphQLHandler:        h.graphqlHandler,
-		Metrics:               h.metrics,
-		ResponseWriter:        w,
-		Request:               r,
-		Connection:            conn,
-		Protocol:              protocol,
-		Logger:                h.logger,
-		Stats:                 h.stats,
-		ConnectionID:          h.connectionIDs.Inc(),
-		ClientInfo:            clientInfo,
-		InitRequestID:         requestID,
-		Config:                h.config,
-		ForwardUpgradeHeaders: h.forwardUpgradeHeadersConfig,
-		ForwardQueryParams:    h.forwardQueryParamsConfig,
+		OperationProcessor:           h.operationProcessor,
+		OperationBlocker:             h.operationBlocker,
+		PersistedOperationKillSwitch: h.persistedOpKillSwitch,
+		SealedVariables:              h.sealedVariables,
+		Planner:                      h.planner,
+		GraphQLHandler:               h.graphqlHandler,
+		Metrics:                      h.metrics,
+		AccessController:             h.accessController,
+		Unauthenticated:              unauthenticated,
+		ResponseWriter:               w,
+		Request:                      r,
+		Connection:                   conn,
+		Protocol:                     protocol,
+		Logger:                       h.logger,
+		Stats:                        h.stats,
+		ConnectionID:                 h.connectionIDs.Inc(),
+		ClientInfo:                   clientInfo,
+		InitRequestID:                requestID,
+		Config:                       h.config,
+		ForwardUpgradeHeaders:        h.forwardUpgradeHeadersConfig,
+		ForwardQueryParams:           h.forwardQueryParamsConfig,
+		SubscriptionLimits:           limits,
+		LimitClient:                  limitClient,
+		ReleaseConnection:            releaseConnection,
+		ClientProtocols:              h.clientProtocols,
+		ClientProtocol:               clientProtocol,
 	})
 	err = handler.Initialize()
 	if err != nil {
-		requestLogger.Error("Initializing websocket connection", zap.Error(err))
+		if !errors.Is(err, errWebSocketForbidden) {
+			requestLogger.Error("Initializing websocket connection", zap.Error(err))
+		}
 		handler.Close()
 		return
 	}
 
 	// Only when epoll is available. On Windows, epoll is not available
 	if h.epoll != nil {
+		handler.disconnect = func() {
+			h.removeConnection(c, handler, socketFd(c))
+		}
+		h.trackIdle(handler)
+		handler.armAuthenticationExpiry()
 		err = h.addConnection(c, handler)
 		if err != nil {
 			requestLogger.Error("Adding connection to epoll", zap.Error(err))
@@ -320,11 +389,33 @@ func (h *WebsocketHandler) handleUpgradeRequest(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	// Closing the connection ends the read loop, which closes the handler
+	handler.disconnect = func() {
+		_ = c.Close()
+	}
+	h.trackIdle(handler)
+	handler.armAuthenticationExpiry()
+
 	// Handle messages sync when epoll is not available
 
 	go h.handleConnectionSync(handler)
 }
 
+// trackIdle lets the subscription reaper disconnect the connection when it's idle
+func (h *WebsocketHandler) trackIdle(handler *WebSocketConnectionHandler) {
+	reaper := h.graphqlHandler.subscriptionReaper
+	if reaper == nil {
+		return
+	}
+	handler.idle = reaper.track("websocket", handler.activeSubscriptions, handler.disconnect,
+		logging.WithRequestID(handler.initRequestID),
+		zap.String("client_name", handler.clientInfo.Name),
+	)
+	handler.untrackIdle = func() {
+		reaper.untrack(handler.idle)
+	}
+}
+
 func (h *WebsocketHandler) handleConnectionSync(handler *WebSocketConnectionHandler) {
 	h.stats.ConnectionsInc()
 	defer h.stats.ConnectionsDec()
@@ -372,10 +463,15 @@ func (h *WebsocketHandler) addConnection(conn net.Conn, handler *WebSocketConnec
 }
 
 func (h *WebsocketHandler) removeConnection(conn net.Conn, handler *WebSocketConnectionHandler, fd int) {
-	h.stats.ConnectionsDec()
 	h.connectionsMu.Lock()
+	// The connection can be removed by the poller and by the subscription reaper
+	if h.connections[fd] != handler {
+		h.connectionsMu.Unlock()
+		return
+	}
 	delete(h.connections, fd)
 	h.connectionsMu.Unlock()
+	h.stats.ConnectionsDec()
 	err := h.epoll.Remove(conn)
 	if err != nil {
 		h.logger.Warn("Removing connection from epoll", zap.Error(err))
@@ -478,6 +574,10 @@ type websocketResponseWriter struct {
 	logger          *zap.Logger
 	stats           WebSocketsStatistics
 	propagateErrors bool
+	// onComplete is called when the subscription is completed by the router
+	onComplete func()
+	// idle records the events as activity of the connection
+	idle *idleTracker
 }
 
 var _ http.ResponseWriter = (*websocketResponseWriter)(nil)
@@ -503,6 +603,9 @@ func (rw *websocketResponseWriter) WriteHeader(statusCode int) {
 }
 
 func (rw *websocketResponseWriter) Complete() {
+	if rw.onComplete != nil {
+		rw.onComplete()
+	}
 	err := rw.protocol.Done(rw.id)
 	if err != nil {
 		rw.logger.Debug("Sending complete message", zap.Error(err))
@@ -516,6 +619,7 @@ func (rw *websocketResponseWriter) Write(data []byte) (int, error) {
 
 func (rw *websocketResponseWriter) Flush() error {
 	if rw.buf.Len() > 0 {
+		rw.idle.touch()
 		rw.logger.Debug("flushing", zap.Int("bytes", rw.buf.Len()))
 		payload := rw.buf.Bytes()
 		var extensions []byte
@@ -559,12 +663,17 @@ type graphqlError struct {
 }
 
 type WebSocketConnectionHandlerOptions struct {
-	Config                *config.WebSocketConfiguration
-	OperationProcessor    *OperationProcessor
-	OperationBlocker      *OperationBlocker
-	Planner               *OperationPlanner
-	GraphQLHandler        *GraphQLHandler
-	Metrics               RouterMetrics
+	Config                       *config.WebSocketConfiguration
+	OperationProcessor           *OperationProcessor
+	OperationBlocker             *OperationBlocker
+	PersistedOperationKillSwitch *PersistedOperationKillSwitch
+	SealedVariables              *SealedVariables
+	Planner                      *OperationPlanner
+	GraphQLHandler               *GraphQLHandler
+	Metrics                      RouterMetrics
+	AccessController             *AccessController
+	// Unauthenticated is true when the upgrade request wasn't authenticated and the initial payload must have a token
+	Unauthenticated       bool
 	ResponseWriter        http.ResponseWriter
 	Request               *http.Request
 	Connection            *wsConnectionWrapper
@@ -577,21 +686,30 @@ type WebSocketConnectionHandlerOptions struct {
 	InitRequestID         string
 	ForwardUpgradeHeaders forwardConfig
 	ForwardQueryParams    forwardConfig
+	SubscriptionLimits    *SubscriptionLimits
+	// LimitClient is the client of the connection the subscription limits per client apply to
+	LimitClient string
+	// ReleaseConnection releases the slot of the connection in the subscription limits when it's closed
+	ReleaseConnection func()
+	// ClientProtocols counts the operations by the protocol of the client. Optional.
+	ClientProtocols *ClientProtocols
+	ClientProtocol  ClientProtocol
 }
 
 type WebSocketConnectionHandler struct {
-	ctx                context.Context
-	operationProcessor *OperationProcessor
-	operationBlocker   *OperationBlocker
-	planner            *OperationPlanner
-	graphqlHandler     *GraphQLHandler
-	metrics            RouterMetrics
-	w                  http.ResponseWriter
-	r                  *http.Request
-	conn               *wsConnectionWrapper
-	protocol           wsproto.Proto
-	clientInfo         *ClientInfo
-	logger             *zap.Logger
+	ctx                   context.Context
+	operationProcessor    *OperationProcessor
+	operationBlocker      *OperationBlocker
+	persistedOpKillSwitch *PersistedOperationKillSwitch
+	sealedVariables       *SealedVariables
+	planner               *OperationPlanner
+	graphqlHandler        *GraphQLHandler
+	metrics               RouterMetrics
+	w                     http.ResponseWriter
+	conn                  *wsConnectionWrapper
+	protocol              wsproto.Proto
+	clientInfo            *ClientInfo
+	logger                *zap.Logger
 
 	initialPayload            json.RawMessage
 	upgradeRequestHeaders     json.RawMessage
@@ -601,12 +719,41 @@ type WebSocketConnectionHandler struct {
 	connectionID    int64
 	subscriptionIDs atomic.Int64
 	subscriptions   sync.Map
-	stats           WebSocketsStatistics
+	// subscriptionTimers complete the subscriptions that exceed their timeout, by operation ID
+	subscriptionTimers sync.Map
+	stats              WebSocketsStatistics
 
 	forwardInitialPayload bool
+	// allowQueriesAndMutations executes the queries and mutations over the connection, next to the subscriptions
+	allowQueriesAndMutations bool
 
 	forwardUpgradeHeaders *forwardConfig
 	forwardQueryParams    *forwardConfig
+
+	subscriptionLimits *SubscriptionLimits
+	limitClient        string
+	releaseConnection  func()
+	// subscriptionReleases release the slots of the active subscriptions in the subscription limits, by subscription ID
+	subscriptionReleases sync.Map
+
+	// idle tracks the activity of the connection for the subscription reaper
+	idle        *idleTracker
+	untrackIdle func()
+	// disconnect closes the connection, e.g. of an idle or slow client
+	disconnect func()
+
+	clientProtocols *ClientProtocols
+	clientProtocol  ClientProtocol
+
+	accessController *AccessController
+	authentication   config.WebSocketAuthenticationConfiguration
+	// unauthenticated is true when the upgrade request wasn't authenticated and the initial payload must have a token
+	unauthenticated bool
+	// requestMu guards the upgrade request, whose authentication is replaced when the client refreshes its token,
+	// and the timer of the expiry of the token
+	requestMu sync.RWMutex
+	r         *http.Request
+	expiry    *time.Timer
 }
 
 type forwardConfig struct {
@@ -623,10 +770,12 @@ var (
 
 func NewWebsocketConnectionHandler(ctx context.Context, opts WebSocketConnectionHandlerOptions) *WebSocketConnectionHandler {
 
-	return &WebSocketConnectionHandler{
+	handler := &WebSocketConnectionHandler{
 		ctx:                   ctx,
 		operationProcessor:    opts.OperationProcessor,
 		operationBlocker:      opts.OperationBlocker,
+		persistedOpKillSwitch: opts.PersistedOperationKillSwitch,
+		sealedVariables:       opts.SealedVariables,
 		planner:               opts.Planner,
 		graphqlHandler:        opts.GraphQLHandler,
 		metrics:               opts.Metrics,
@@ -642,7 +791,19 @@ func NewWebsocketConnectionHandler(ctx context.Context, opts WebSocketConnection
 		forwardUpgradeHeaders: &opts.ForwardUpgradeHeaders,
 		forwardQueryParams:    &opts.ForwardQueryParams,
 		forwardInitialPayload: opts.Config != nil && opts.Config.ForwardInitialPayload,
+		subscriptionLimits:    opts.SubscriptionLimits,
+		limitClient:           opts.LimitClient,
+		releaseConnection:     opts.ReleaseConnection,
+		accessController:      opts.AccessController,
+		unauthenticated:       opts.Unauthenticated,
+		clientProtocols:       opts.ClientProtocols,
+		clientProtocol:        opts.ClientProtocol,
 	}
+	if opts.Config != nil {
+		handler.authentication = opts.Config.Authentication
+		handler.allowQueriesAndMutations = opts.Config.AllowQueriesAndMutations
+	}
+	return handler
 }
 
 func (h *WebSocketConnectionHandler) requestError(err error) error {
@@ -676,7 +837,12 @@ func (h *WebSocketConnectionHandler) parseAndPlan(payload []byte) (*ParsedOperat
 		return nil, nil, err
 	}
 
-	if blocked := h.operationBlocker.OperationIsBlocked(operationKit.parsedOperation); blocked != nil {
+	if operationKit.parsedOperation.IsPersistedOperation && h.persistedOpKillSwitch != nil &&
+		h.persistedOpKillSwitch.Blocked(operationKit.parsedOperation.GraphQLRequestExtensions.PersistedQuery.Sha256Hash) {
+		return nil, nil, h.persistedOpKillSwitch.Err()
+	}
+
+	if blocked := h.operationBlocker.OperationIsBlocked(operationKit.parsedOperation, h.clientInfo); blocked != nil {
 		return nil, nil, blocked
 	}
 
@@ -684,11 +850,15 @@ func (h *WebSocketConnectionHandler) parseAndPlan(payload []byte) (*ParsedOperat
 		return nil, nil, err
 	}
 
+	if err := operationKit.UnsealVariables(h.sealedVariables); err != nil {
+		return nil, nil, err
+	}
+
 	if err := operationKit.Validate(); err != nil {
 		return nil, nil, err
 	}
 
-	opContext, err := h.planner.Plan(operationKit.parsedOperation, h.clientInfo, OperationProtocolWS, ParseRequestTraceOptions(h.r))
+	opContext, err := h.planner.Plan(operationKit.parsedOperation, h.clientInfo, OperationProtocolWS, ParseRequestTraceOptions(h.request()))
 	if err != nil {
 		return operationKit.parsedOperation, nil, err
 	}
@@ -697,11 +867,19 @@ func (h *WebSocketConnectionHandler) parseAndPlan(payload []byte) (*ParsedOperat
 }
 
 func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, id resolve.SubscriptionIdentifier) {
+	// The subscription keeps the authentication of the connection at its start
+	r := h.request()
 
 	rw := newWebsocketResponseWriter(msg.ID, h.protocol, h.graphqlHandler.subgraphErrorPropagation.Enabled, h.logger, h.stats)
+	rw.idle = h.idle
 
-	_, operationCtx, err := h.parseAndPlan(msg.Payload)
+	parsedOperation, operationCtx, err := h.parseAndPlan(msg.Payload)
+	if err == nil && !h.allowQueriesAndMutations && operationCtx.Type() != "subscription" {
+		err = errWebSocketSubscriptionsOnly
+	}
 	if err != nil {
+		// The error ends the operation, so that the client can reuse its ID
+		h.subscriptions.CompareAndDelete(msg.ID, id.SubscriptionID)
 		wErr := h.writeErrorMessage(msg.ID, err)
 		if wErr != nil {
 			h.logger.Warn("writing error message", zap.Error(wErr))
@@ -709,6 +887,14 @@ func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, i
 		return
 	}
 
+	if h.clientProtocols != nil {
+		h.clientProtocols.Record(h.clientProtocol, h.clientInfo.Name)
+	}
+
+	// The entries of the execution have the metadata of the normalized operation
+	ctx := logging.WithOperation(h.ctx, loggingOperation(parsedOperation, h.clientInfo))
+	operationLogger := logging.EnrichFromOperationContext(ctx, h.logger)
+
 	if h.forwardUpgradeHeaders.enabled && h.upgradeRequestHeaders != nil {
 		if operationCtx.extensions == nil {
 			operationCtx.extensions = json.RawMessage("{}")
@@ -746,7 +932,7 @@ func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, i
 	resolveCtx := &resolve.Context{
 		Variables: operationCtx.Variables(),
 		Request: resolve.Request{
-			Header: h.r.Header.Clone(),
+			Header: r.Header.Clone(),
 			ID:     h.initRequestID,
 		},
 		RenameTypeNames: h.graphqlHandler.executor.RenameTypeNames,
@@ -756,7 +942,9 @@ func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, i
 	if h.forwardInitialPayload && operationCtx.initialPayload != nil {
 		resolveCtx.InitialPayload = operationCtx.initialPayload
 	}
-	resolveCtx = resolveCtx.WithContext(withRequestContext(h.ctx, buildRequestContext(nil, h.r, operationCtx, h.logger)))
+	requestContext := buildRequestContext(nil, r, operationCtx, operationLogger)
+	requestContext.fetchLimiter = h.graphqlHandler.fetchConcurrency.newFetchLimiter(operationCtx.Name(), operationCtx.Type())
+	resolveCtx = resolveCtx.WithContext(withRequestContext(ctx, requestContext))
 	if h.graphqlHandler.authorizer != nil {
 		resolveCtx = WithAuthorizationExtension(resolveCtx)
 		resolveCtx.SetAuthorizer(h.graphqlHandler.authorizer)
@@ -771,25 +959,122 @@ func (h *WebSocketConnectionHandler) executeSubscription(msg *wsproto.Message, i
 
 	switch p := operationCtx.preparedPlan.preparedPlan.(type) {
 	case *plan.SynchronousResponsePlan:
+		executionCtx, cancelTimeout := h.graphqlHandler.operationTimeouts.withTimeout(resolveCtx.Context(), operationCtx.Name(), operationCtx.Type())
+		defer cancelTimeout()
+		resolveCtx = resolveCtx.WithContext(executionCtx)
+
 		err = h.graphqlHandler.executor.Resolver.ResolveGraphQLResponse(resolveCtx, p.Response, nil, rw)
+		if isOperationTimeout(executionCtx) {
+			h.graphqlHandler.trackOperationTimeout(executionCtx, operationCtx, operationLogger)
+			err = ErrOperationTimeout
+		}
 		if err != nil {
-			h.logger.Warn("Resolving GraphQL response", zap.Error(err))
+			operationLogger.Warn("Resolving GraphQL response", zap.Error(err))
 			buf := pool.GetBytesBuffer()
 			defer pool.PutBytesBuffer(buf)
 			h.graphqlHandler.WriteError(resolveCtx, err, p.Response, rw, buf)
 		}
 		_ = rw.Flush()
+		// The ID is released before the complete message, so that the client can reuse it for its next operation
+		h.subscriptions.CompareAndDelete(msg.ID, id.SubscriptionID)
 		rw.Complete()
 	case *plan.SubscriptionResponsePlan:
-		err = h.graphqlHandler.executor.Resolver.AsyncResolveGraphQLSubscription(resolveCtx, p.Response, rw.SubscriptionResponseWriter(), id)
+		if h.subscriptionLimits != nil {
+			release, limitErr := h.subscriptionLimits.acquireSubscription(h.limitClient)
+			if limitErr != nil {
+				h.subscriptions.CompareAndDelete(msg.ID, id.SubscriptionID)
+				h.rejectSubscription(msg.ID, limitErr)
+				return
+			}
+			h.subscriptionReleases.Store(id.SubscriptionID, release)
+			rw.onComplete = func() {
+				h.releaseSubscription(id.SubscriptionID)
+			}
+		}
+		writer := rw.SubscriptionResponseWriter()
+		if backpressure := h.graphqlHandler.subscriptionBackpressure; backpressure != nil {
+			// A slow client disconnects with all of its subscriptions, as they share the connection
+			writer = backpressure.wrap(resolveCtx.Context(), writer, h.disconnect)
+		}
+		err = h.graphqlHandler.executor.Resolver.AsyncResolveGraphQLSubscription(resolveCtx, p.Response, writer, id)
 		if err != nil {
-			h.logger.Warn("Resolving GraphQL subscription", zap.Error(err))
+			h.releaseSubscription(id.SubscriptionID)
+			operationLogger.Warn("Resolving GraphQL subscription", zap.Error(err))
 			buf := pool.GetBytesBuffer()
 			defer pool.PutBytesBuffer(buf)
 			h.graphqlHandler.WriteError(resolveCtx, err, p.Response.Response, rw, buf)
 			return
 		}
+		if timeout := h.graphqlHandler.operationTimeouts.Timeout(operationCtx.Name(), operationCtx.Type()); timeout > 0 {
+			// The context of the subscription isn't canceled by the deadline, because the resolver doesn't
+			// complete canceled subscriptions. The subscription is completed by the timer instead.
+			h.subscriptionTimers.Store(msg.ID, time.AfterFunc(timeout, func() {
+				h.completeTimedOutSubscription(msg.ID, id, operationCtx, operationLogger)
+			}))
+		}
+	}
+}
+
+// activeSubscriptions returns the number of active subscriptions of the connection
+func (h *WebSocketConnectionHandler) activeSubscriptions() int {
+	count := 0
+	h.subscriptions.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// rejectSubscription sends the error of a subscription above the subscription limits
+func (h *WebSocketConnectionHandler) rejectSubscription(operationID string, err error) {
+	h.logger.Debug("Rejected subscription", zap.Error(err))
+	payload, err := json.Marshal(subscriptionLimitErrors(err))
+	if err == nil {
+		err = h.protocol.WriteGraphQLErrors(operationID, payload, nil)
+	}
+	if err != nil {
+		h.logger.Warn("writing error message", zap.Error(err))
+	}
+}
+
+// releaseSubscription releases the slot of the subscription in the subscription limits, if it has one
+func (h *WebSocketConnectionHandler) releaseSubscription(subscriptionID int64) {
+	if release, ok := h.subscriptionReleases.LoadAndDelete(subscriptionID); ok {
+		release.(func())()
+	}
+}
+
+// completeTimedOutSubscription sends the timeout error and completes the subscription, unless it was completed before
+func (h *WebSocketConnectionHandler) completeTimedOutSubscription(operationID string, id resolve.SubscriptionIdentifier, operationCtx *operationContext, operationLogger *zap.Logger) {
+	h.subscriptionTimers.Delete(operationID)
+	if h.ctx.Err() != nil {
+		return
+	}
+	value, ok := h.subscriptions.LoadAndDelete(operationID)
+	if !ok || value.(int64) != id.SubscriptionID {
+		if ok {
+			// The ID was reused by a newer subscription
+			h.subscriptions.Store(operationID, value)
+		}
+		return
+	}
+
+	h.graphqlHandler.trackOperationTimeout(h.ctx, operationCtx, operationLogger)
+
+	payload, err := json.Marshal([]graphqlError{{
+		Message:    "Operation timed out",
+		Extensions: &Extensions{Code: OperationTimeoutErrorCode},
+	}})
+	if err == nil {
+		err = h.protocol.WriteGraphQLErrors(operationID, payload, nil)
+	}
+	if err != nil {
+		h.logger.Warn("writing error message", zap.Error(err))
 	}
+	if err := h.graphqlHandler.executor.Resolver.AsyncUnsubscribeSubscription(id); err != nil {
+		h.logger.Warn("unsubscribing timed out subscription", zap.Error(err))
+	}
+	h.releaseSubscription(id.SubscriptionID)
 }
 
 func (h *WebSocketConnectionHandler) handleSubscribe(msg *wsproto.Message) error {
@@ -816,6 +1101,9 @@ func (h *WebSocketConnectionHandler) handleComplete(msg *wsproto.Message) error
 		return h.requestError(fmt.Errorf("no subscription was registered for ID %q", msg.ID))
 	}
 	h.subscriptions.Delete(msg.ID)
+	if timer, ok := h.subscriptionTimers.LoadAndDelete(msg.ID); ok {
+		timer.(*time.Timer).Stop()
+	}
 	subscriptionID, ok := value.(int64)
 	if !ok {
 		return h.requestError(fmt.Errorf("invalid subscription state for ID %q", msg.ID))
@@ -824,19 +1112,26 @@ func (h *WebSocketConnectionHandler) handleComplete(msg *wsproto.Message) error
 		ConnectionID:   h.connectionID,
 		SubscriptionID: subscriptionID,
 	}
+	h.releaseSubscription(subscriptionID)
 	return h.graphqlHandler.executor.Resolver.AsyncUnsubscribeSubscription(id)
 }
 
 func (h *WebsocketHandler) HandleMessage(handler *WebSocketConnectionHandler, msg *wsproto.Message) (err error) {
+	handler.idle.touch()
 
 	switch msg.Type {
 	case wsproto.MessageTypeTerminate:
 		return errClientTerminatedConnection
 	case wsproto.MessageTypePing:
+		if err := handler.refreshAuthentication(msg.Payload); err != nil {
+			return err
+		}
 		_ = handler.protocol.Pong(msg)
 	case wsproto.MessageTypePong:
 		// "Furthermore, the Pong message may even be sent unsolicited as a unidirectional heartbeat"
-		return nil
+		return handler.refreshAuthentication(msg.Payload)
+	case wsproto.MessageTypeReauthenticate:
+		return handler.refreshAuthentication(msg.Payload)
 	case wsproto.MessageTypeSubscribe:
 		h.handlerPool.Submit(func() {
 			err := handler.handleSubscribe(msg)
@@ -857,7 +1152,11 @@ func (h *WebsocketHandler) HandleMessage(handler *WebSocketConnectionHandler, ms
 
 func (h *WebSocketConnectionHandler) Initialize() (err error) {
 	h.logger.Debug("Websocket connection", zap.String("protocol", h.protocol.Subprotocol()))
-	h.initialPayload, err = h.protocol.Initialize()
+	h.initialPayload, err = h.protocol.Initialize(h.authenticateInitialPayload)
+	if errors.Is(err, errWebSocketForbidden) {
+		h.logger.Debug("Websocket connection without a valid token")
+		return err
+	}
 	if err != nil {
 		h.logger.Error("Initializing websocket connection", zap.Error(err))
 		_ = h.requestError(fmt.Errorf("error initializing session"))
@@ -938,6 +1237,11 @@ func (h *WebSocketConnectionHandler) Complete(rw *websocketResponseWriter) {
 }
 
 func (h *WebSocketConnectionHandler) Close() {
+	h.stopAuthenticationExpiry()
+	h.subscriptionTimers.Range(func(_, timer any) bool {
+		timer.(*time.Timer).Stop()
+		return true
+	})
 	// Remove any pending IDs associated with this connection
 	err := h.graphqlHandler.executor.Resolver.AsyncUnsubscribeClient(h.connectionID)
 	if err != nil {
@@ -947,4 +1251,19 @@ func (h *WebSocketConnectionHandler) Close() {
 	if err != nil {
 		h.logger.Debug("Closing websocket connection", zap.Error(err))
 	}
+	h.logger.Debug("Closed websocket connection",
+		zap.Int64("bytes_received", h.conn.received.Load()),
+		zap.Int64("bytes_sent", h.conn.sent.Load()),
+		zap.Bool("compressed", h.conn.compressed),
+	)
+	if h.untrackIdle != nil {
+		h.untrackIdle()
+	}
+	h.subscriptionReleases.Range(func(subscriptionID, _ any) bool {
+		h.releaseSubscription(subscriptionID.(int64))
+		return true
+	})
+	if h.releaseConnection != nil {
+		h.releaseConnection()
+	}
 }
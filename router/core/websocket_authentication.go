@@ -0,0 +1,154 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/gobwas/ws"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+)
+
+// wsStatusForbidden is the close status of graphql-ws for connections that aren't authorized
+const wsStatusForbidden ws.StatusCode = 4403
+
+var (
+	// errWebSocketForbidden closes the connection of a client without a valid token
+	errWebSocketForbidden = errors.New("forbidden")
+	// errWebSocketTokenExpired closes the connection of a client that didn't refresh its expired token
+	errWebSocketTokenExpired = errors.New("token expired")
+)
+
+// payloadToken returns the token of the key of the payload, or an empty string if there's none
+func payloadToken(payload json.RawMessage, key string) string {
+	if len(payload) == 0 || key == "" {
+		return ""
+	}
+	token, err := jsonparser.GetString(payload, key)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// authenticationExpiry returns the time of the exp claim of the authentication
+func authenticationExpiry(auth authentication.Authentication) (time.Time, bool) {
+	if auth == nil {
+		return time.Time{}, false
+	}
+	var seconds float64
+	switch exp := auth.Claims()["exp"].(type) {
+	case float64:
+		seconds = exp
+	case int64:
+		seconds = float64(exp)
+	case json.Number:
+		v, err := exp.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds = v
+	default:
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// request returns the upgrade request with the current authentication of the connection
+func (h *WebSocketConnectionHandler) request() *http.Request {
+	h.requestMu.RLock()
+	defer h.requestMu.RUnlock()
+	return h.r
+}
+
+// authenticateInitialPayload authenticates the connection with the token of the initial payload. It's called before
+// the connection is acknowledged.
+func (h *WebSocketConnectionHandler) authenticateInitialPayload(payload json.RawMessage) error {
+	if !h.authentication.FromInitialPayload {
+		return nil
+	}
+	token := payloadToken(payload, h.authentication.Key)
+	if token == "" {
+		if h.unauthenticated {
+			return h.closeForbidden(errWebSocketForbidden)
+		}
+		return nil
+	}
+	r, err := h.accessController.AccessHeader(h.r, http.Header{http.CanonicalHeaderKey(h.authentication.Key): {token}})
+	if err != nil {
+		return h.closeForbidden(errWebSocketForbidden)
+	}
+	h.requestMu.Lock()
+	h.r = r
+	h.requestMu.Unlock()
+	return nil
+}
+
+// refreshAuthentication replaces the authentication of the connection with the token of the payload of a ping, pong
+// or reauthenticate message. The payloads without a token are ignored. An invalid token closes the connection.
+func (h *WebSocketConnectionHandler) refreshAuthentication(payload json.RawMessage) error {
+	if !h.authentication.Refresh.Enabled {
+		return nil
+	}
+	token := payloadToken(payload, h.authentication.Key)
+	if token == "" {
+		return nil
+	}
+	r, err := h.accessController.AccessHeader(h.request(), http.Header{http.CanonicalHeaderKey(h.authentication.Key): {token}})
+	if err != nil {
+		h.logger.Debug("Refreshing websocket authentication", zap.Error(err))
+		err = h.closeForbidden(errWebSocketForbidden)
+		// The read loop ends with the closed connection
+		h.disconnect()
+		return err
+	}
+
+	h.requestMu.Lock()
+	h.r = r
+	h.requestMu.Unlock()
+	h.armAuthenticationExpiry()
+	return nil
+}
+
+// armAuthenticationExpiry closes the connection after the grace period, when the token of the connection expired
+// and wasn't refreshed. It must be called after disconnect is set.
+func (h *WebSocketConnectionHandler) armAuthenticationExpiry() {
+	if !h.authentication.Refresh.Enabled {
+		return
+	}
+
+	h.requestMu.Lock()
+	defer h.requestMu.Unlock()
+	if h.expiry != nil {
+		h.expiry.Stop()
+		h.expiry = nil
+	}
+	exp, ok := authenticationExpiry(authentication.FromContext(h.r.Context()))
+	if !ok {
+		return
+	}
+	h.expiry = time.AfterFunc(time.Until(exp)+h.authentication.Refresh.GracePeriod, func() {
+		h.logger.Debug("Closing websocket connection with an expired token", zap.Time("expired_at", exp))
+		_ = h.closeForbidden(errWebSocketTokenExpired)
+		h.disconnect()
+	})
+}
+
+// stopAuthenticationExpiry stops the timer of the expiry of the token
+func (h *WebSocketConnectionHandler) stopAuthenticationExpiry() {
+	h.requestMu.Lock()
+	defer h.requestMu.Unlock()
+	if h.expiry != nil {
+		h.expiry.Stop()
+		h.expiry = nil
+	}
+}
+
+// closeForbidden tells the client why its connection is closed
+func (h *WebSocketConnectionHandler) closeForbidden(reason error) error {
+	return h.conn.closeWithStatus(wsStatusForbidden, reason)
+}
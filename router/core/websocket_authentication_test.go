@@ -0,0 +1,184 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/router/internal/wsproto"
+	"github.com/wundergraph/cosmo/router/pkg/authentication"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// tokenAuthenticator accepts the tokens of its claims
+type tokenAuthenticator map[string]authentication.Claims
+
+func (a tokenAuthenticator) Name() string {
+	return "tokens"
+}
+
+func (a tokenAuthenticator) Authenticate(_ context.Context, p authentication.Provider) (authentication.Claims, error) {
+	token := strings.TrimPrefix(p.AuthenticationHeaders().Get("Authorization"), "Bearer ")
+	if token == "" {
+		return nil, nil
+	}
+	claims, ok := a[token]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func newTestAuthenticatedWSConnection(t *testing.T, cfg config.WebSocketAuthenticationConfiguration, unauthenticated bool) (*WebSocketConnectionHandler, net.Conn, chan struct{}) {
+	conn, client := newTestWSConnection(t, nil, false)
+	protocol, err := wsproto.NewProtocol(wsproto.GraphQLWSSubprotocol, conn)
+	require.NoError(t, err)
+
+	expired := float64(time.Now().Add(-time.Minute).Unix())
+	authenticator := tokenAuthenticator{
+		"expired": {"sub": "user-1", "exp": expired},
+		"fresh":   {"sub": "user-1", "exp": float64(time.Now().Add(time.Hour).Unix())},
+	}
+	disconnected := make(chan struct{})
+	handler := NewWebsocketConnectionHandler(context.Background(), WebSocketConnectionHandlerOptions{
+		AccessController: NewAccessController([]authentication.Authenticator{authenticator}, true),
+		Unauthenticated:  unauthenticated,
+		Request:          httptest.NewRequest(http.MethodGet, "/graphql", nil),
+		Connection:       conn,
+		Protocol:         protocol,
+		Logger:           zap.NewNop(),
+		Config:           &config.WebSocketConfiguration{Authentication: cfg},
+	})
+	handler.disconnect = func() {
+		close(disconnected)
+	}
+	return handler, client, disconnected
+}
+
+func readCloseStatus(t *testing.T, client net.Conn) ws.StatusCode {
+	frame, err := ws.ReadFrame(client)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpClose, frame.Header.OpCode)
+	code, _ := ws.ParseCloseFrameData(frame.Payload)
+	return code
+}
+
+func TestWebSocketAuthenticationFromInitialPayload(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.WebSocketAuthenticationConfiguration{FromInitialPayload: true, Key: "Authorization"}
+
+	t.Run("valid token", func(t *testing.T) {
+		t.Parallel()
+
+		handler, client, _ := newTestAuthenticatedWSConnection(t, cfg, true)
+		go func() {
+			_ = wsutil.WriteClientText(client, []byte(`{"type":"connection_init","payload":{"Authorization":"Bearer fresh"}}`))
+		}()
+		errs := make(chan error, 1)
+		go func() {
+			errs <- handler.Initialize()
+		}()
+		ack, err := wsutil.ReadServerText(client)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"type":"connection_ack"}`, string(ack))
+		require.NoError(t, <-errs)
+
+		auth := authentication.FromContext(handler.request().Context())
+		require.NotNil(t, auth)
+		require.Equal(t, "user-1", auth.Claims()["sub"])
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		t.Parallel()
+
+		handler, client, _ := newTestAuthenticatedWSConnection(t, cfg, true)
+		go func() {
+			_ = wsutil.WriteClientText(client, []byte(`{"type":"connection_init","payload":{}}`))
+		}()
+		errs := make(chan error, 1)
+		go func() {
+			errs <- handler.Initialize()
+		}()
+		require.Equal(t, wsStatusForbidden, readCloseStatus(t, client))
+		require.ErrorIs(t, <-errs, errWebSocketForbidden)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		t.Parallel()
+
+		handler, client, _ := newTestAuthenticatedWSConnection(t, cfg, false)
+		go func() {
+			_ = wsutil.WriteClientText(client, []byte(`{"type":"connection_init","payload":{"Authorization":"Bearer unknown"}}`))
+		}()
+		errs := make(chan error, 1)
+		go func() {
+			errs <- handler.Initialize()
+		}()
+		require.Equal(t, wsStatusForbidden, readCloseStatus(t, client))
+		require.ErrorIs(t, <-errs, errWebSocketForbidden)
+	})
+}
+
+func TestWebSocketAuthenticationRefresh(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.WebSocketAuthenticationConfiguration{
+		Key:     "Authorization",
+		Refresh: config.WebSocketAuthenticationRefreshConfiguration{Enabled: true, GracePeriod: time.Minute + 200*time.Millisecond},
+	}
+
+	t.Run("expired token closes the connection", func(t *testing.T) {
+		t.Parallel()
+
+		handler, client, disconnected := newTestAuthenticatedWSConnection(t, cfg, false)
+		// A ping without a token is a heartbeat
+		require.NoError(t, handler.refreshAuthentication([]byte(`{}`)))
+		require.NoError(t, handler.refreshAuthentication([]byte(`{"Authorization":"Bearer expired"}`)))
+		t.Cleanup(handler.stopAuthenticationExpiry)
+
+		require.Equal(t, wsStatusForbidden, readCloseStatus(t, client))
+		<-disconnected
+	})
+
+	t.Run("refreshed token keeps the connection", func(t *testing.T) {
+		t.Parallel()
+
+		handler, _, disconnected := newTestAuthenticatedWSConnection(t, cfg, false)
+		require.NoError(t, handler.refreshAuthentication([]byte(`{"Authorization":"Bearer expired"}`)))
+		require.NoError(t, handler.refreshAuthentication([]byte(`{"Authorization":"Bearer fresh"}`)))
+		t.Cleanup(handler.stopAuthenticationExpiry)
+
+		select {
+		case <-disconnected:
+			t.Fatal("the connection with a refreshed token was closed")
+		case <-time.After(400 * time.Millisecond):
+		}
+		exp, ok := authenticationExpiry(authentication.FromContext(handler.request().Context()))
+		require.True(t, ok)
+		require.True(t, exp.After(time.Now()))
+	})
+
+	t.Run("invalid token closes the connection", func(t *testing.T) {
+		t.Parallel()
+
+		handler, client, disconnected := newTestAuthenticatedWSConnection(t, cfg, false)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- handler.refreshAuthentication([]byte(`{"Authorization":"Bearer unknown"}`))
+		}()
+		require.Equal(t, wsStatusForbidden, readCloseStatus(t, client))
+		require.ErrorIs(t, <-errs, errWebSocketForbidden)
+		<-disconnected
+	})
+}
@@ -0,0 +1,304 @@
+package core
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+// errWebSocketMessageTooBig closes the connection of a client that sent a message above the size limits
+var errWebSocketMessageTooBig = errors.New("websocket message too big")
+
+// WebSocketTransport limits the size of the messages of the WebSocket clients and compresses the messages with the
+// permessage-deflate extension when the client supports it. It counts the bytes on the wire of all connections, so
+// that the bandwidth of the subscriptions and the effect of the compression can be observed.
+type WebSocketTransport struct {
+	maxMessageSize int64
+	maxFrameSize   int64
+	compression    bool
+	writers        sync.Pool
+
+	// The counters are indexed by whether the connection is compressed
+	received  [2]atomic.Int64
+	sent      [2]atomic.Int64
+	oversized atomic.Int64
+
+	mu            sync.Mutex
+	registrations []otelmetric.Registration
+}
+
+func NewWebSocketTransport(cfg *config.WebSocketConfiguration) (*WebSocketTransport, error) {
+	t := &WebSocketTransport{
+		maxMessageSize: int64(cfg.MaxMessageSize),
+		maxFrameSize:   int64(cfg.MaxFrameSize),
+		compression:    cfg.Compression.Enabled,
+	}
+	if cfg.Compression.Enabled {
+		level := cfg.Compression.Level
+		if level < flate.BestSpeed || level > flate.BestCompression {
+			return nil, fmt.Errorf("invalid websocket compression level %d, must be between 1 and 9", level)
+		}
+		t.writers.New = func() any {
+			return wsflate.NewWriter(nil, func(w io.Writer) wsflate.Compressor {
+				// The level is validated above
+				fw, _ := flate.NewWriter(w, level)
+				return fw
+			})
+		}
+	}
+	return t, nil
+}
+
+// BytesReceived returns the number of bytes received from the clients, including the frame headers
+func (t *WebSocketTransport) BytesReceived(compressed bool) int64 {
+	return t.received[compressedIndex(compressed)].Load()
+}
+
+// BytesSent returns the number of bytes sent to the clients, including the frame headers
+func (t *WebSocketTransport) BytesSent(compressed bool) int64 {
+	return t.sent[compressedIndex(compressed)].Load()
+}
+
+// OversizedMessages returns the number of connections closed because of a message above the size limits
+func (t *WebSocketTransport) OversizedMessages() int64 {
+	return t.oversized.Load()
+}
+
+func compressedIndex(compressed bool) int {
+	if compressed {
+		return 1
+	}
+	return 0
+}
+
+// extension returns the permessage-deflate extension to negotiate in the upgrade, or nil without compression. The
+// extension must not be shared between upgrades.
+func (t *WebSocketTransport) extension() *wsflate.Extension {
+	if t == nil || !t.compression {
+		return nil
+	}
+	// Without context takeover, every message is compressed on its own and the writers can be pooled
+	return &wsflate.Extension{Parameters: wsflate.DefaultParameters}
+}
+
+// compress returns the compressed copy of a single frame message
+func (t *WebSocketTransport) compress(frame ws.Frame) (ws.Frame, error) {
+	w := t.writers.Get().(*wsflate.Writer)
+	defer t.writers.Put(w)
+
+	var buf bytes.Buffer
+	w.Reset(&buf)
+	if _, err := w.Write(frame.Payload); err != nil {
+		return frame, err
+	}
+	if err := w.Flush(); err != nil {
+		return frame, err
+	}
+
+	var err error
+	frame.Payload = buf.Bytes()
+	frame.Header.Length = int64(len(frame.Payload))
+	frame.Header, err = wsflate.SetBit(frame.Header)
+	return frame, err
+}
+
+// wsCountingReader counts the bytes read from the connection of the client
+type wsCountingReader struct {
+	conn *wsConnectionWrapper
+}
+
+func (r wsCountingReader) Read(p []byte) (int, error) {
+	n, err := r.conn.conn.Read(p)
+	r.conn.received.Add(int64(n))
+	if t := r.conn.transport; t != nil {
+		t.received[compressedIndex(r.conn.compressed)].Add(int64(n))
+	}
+	return n, err
+}
+
+// readClientText reads the next text message of the client like wsutil.ReadClientText. It decompresses the
+// compressed messages and closes the connection when a frame or the message exceeds the size limits.
+func (c *wsConnectionWrapper) readClientText() ([]byte, error) {
+	var maxFrameSize, maxMessageSize int64
+	if c.transport != nil {
+		maxFrameSize, maxMessageSize = c.transport.maxFrameSize, c.transport.maxMessageSize
+	}
+
+	var message wsflate.MessageState
+	controlHandler := wsutil.ControlFrameHandler(c.conn, ws.StateServerSide)
+	rd := wsutil.Reader{
+		Source: wsCountingReader{conn: c},
+		State:  ws.StateServerSide,
+		// The text of a compressed message is checked after the decompression by the JSON decoder
+		CheckUTF8:      !c.compressed,
+		OnIntermediate: controlHandler,
+		MaxFrameSize:   maxFrameSize,
+	}
+	if c.compressed {
+		rd.State |= ws.StateExtended
+		rd.Extensions = []wsutil.RecvExtension{&message}
+	}
+
+	for {
+		hdr, err := rd.NextFrame()
+		if errors.Is(err, wsutil.ErrFrameTooLarge) {
+			return nil, c.closeMessageTooBig()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.OpCode.IsControl() {
+			if err := controlHandler(hdr, &rd); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if hdr.OpCode&ws.OpText == 0 {
+			if err := rd.Discard(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var payload io.Reader = &rd
+		if message.IsCompressed() {
+			payload = wsflate.NewReader(payload, func(r io.Reader) wsflate.Decompressor {
+				return flate.NewReader(r)
+			})
+		}
+		if maxMessageSize <= 0 {
+			return io.ReadAll(payload)
+		}
+
+		// The limit applies to the decompressed message, so that small frames can't inflate to a large message
+		data, err := io.ReadAll(io.LimitReader(payload, maxMessageSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > maxMessageSize {
+			return nil, c.closeMessageTooBig()
+		}
+		return data, nil
+	}
+}
+
+// closeMessageTooBig tells the client why its connection is closed
+func (c *wsConnectionWrapper) closeMessageTooBig() error {
+	if c.transport != nil {
+		c.transport.oversized.Add(1)
+	}
+	return c.closeWithStatus(ws.StatusMessageTooBig, errWebSocketMessageTooBig)
+}
+
+// closeWithStatus writes the close frame with the status and the reason. It returns the reason.
+func (c *wsConnectionWrapper) closeWithStatus(status ws.StatusCode, reason error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frame := ws.NewCloseFrame(ws.NewCloseFrameBody(status, reason.Error()))
+	if err := ws.WriteFrame(c.rw, frame); err != nil {
+		return errors.Join(reason, err)
+	}
+	if err := c.rw.Flush(); err != nil {
+		return errors.Join(reason, err)
+	}
+	return reason
+}
+
+// writeServerText writes a text message to the client and compresses it, when the client negotiated the
+// compression. The caller must hold the lock of the connection.
+func (c *wsConnectionWrapper) writeServerText(data []byte) error {
+	frame := ws.NewTextFrame(data)
+	if c.compressed {
+		var err error
+		if frame, err = c.transport.compress(frame); err != nil {
+			return err
+		}
+	}
+	if err := ws.WriteFrame(c.rw, frame); err != nil {
+		return err
+	}
+
+	n := int64(ws.HeaderSize(frame.Header) + len(frame.Payload))
+	c.sent.Add(n)
+	if c.transport != nil {
+		c.transport.sent[compressedIndex(c.compressed)].Add(n)
+	}
+	return c.rw.Flush()
+}
+
+// RegisterMetrics exposes the bandwidth of the WebSocket connections on the meter provider
+func (t *WebSocketTransport) RegisterMetrics(meterProvider *sdkmetric.MeterProvider) error {
+	meter := meterProvider.Meter(cosmoRouterSubscriptionsMeterName,
+		otelmetric.WithInstrumentationVersion(cosmoRouterSubscriptionsMeterVersion),
+	)
+
+	received, err := meter.Int64ObservableCounter(
+		"router.websockets.bytes_received",
+		otelmetric.WithDescription("Number of bytes received from the WebSocket clients, including the frame headers"),
+		otelmetric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+	sent, err := meter.Int64ObservableCounter(
+		"router.websockets.bytes_sent",
+		otelmetric.WithDescription("Number of bytes sent to the WebSocket clients, including the frame headers"),
+		otelmetric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+	oversized, err := meter.Int64ObservableCounter(
+		"router.websockets.oversized_messages",
+		otelmetric.WithDescription("Number of WebSocket connections closed because of a message above the size limits"),
+	)
+	if err != nil {
+		return err
+	}
+
+	reg, err := meter.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		for _, compressed := range []bool{false, true} {
+			attributes := otelmetric.WithAttributes(attribute.Bool("compressed", compressed))
+			o.ObserveInt64(received, t.BytesReceived(compressed), attributes)
+			o.ObserveInt64(sent, t.BytesSent(compressed), attributes)
+		}
+		o.ObserveInt64(oversized, t.OversizedMessages())
+		return nil
+	}, received, sent, oversized)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.registrations = append(t.registrations, reg)
+	t.mu.Unlock()
+
+	return nil
+}
+
+func (t *WebSocketTransport) Shutdown() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var err error
+	for _, reg := range t.registrations {
+		err = errors.Join(err, reg.Unregister())
+	}
+	t.registrations = nil
+
+	return err
+}
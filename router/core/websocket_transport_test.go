@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/cosmo/router/pkg/config"
+)
+
+func newTestWSConnection(t *testing.T, transport *WebSocketTransport, compressed bool) (*wsConnectionWrapper, net.Conn) {
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	rw := bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))
+	return newWSConnectionWrapper(server, rw, transport, compressed), client
+}
+
+func TestWebSocketTransportCompression(t *testing.T) {
+	t.Parallel()
+
+	transport, err := NewWebSocketTransport(&config.WebSocketConfiguration{
+		Compression: config.WebSocketCompressionConfiguration{Enabled: true, Level: 6},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, transport.extension())
+	conn, client := newTestWSConnection(t, transport, true)
+
+	message := `{"type":"subscribe","payload":{"query":"subscription { currentTime { unixTime } }"}}`
+	go func() {
+		frame, err := transport.compress(ws.NewTextFrame([]byte(message)))
+		if err == nil {
+			_ = ws.WriteFrame(client, ws.MaskFrame(frame))
+		}
+	}()
+	var received map[string]any
+	require.NoError(t, conn.ReadJSON(&received))
+	require.Equal(t, "subscribe", received["type"])
+
+	payload := `{"data":{"currentTime":{"unixTime":` + strings.Repeat("1", 200) + `}}}`
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.WriteText(payload)
+	}()
+	frame, err := ws.ReadFrame(client)
+	require.NoError(t, err)
+	compressed, err := wsflate.IsCompressed(frame.Header)
+	require.NoError(t, err)
+	require.True(t, compressed)
+	require.Less(t, len(frame.Payload), len(payload))
+	frame, err = wsflate.DecompressFrame(frame)
+	require.NoError(t, err)
+	require.Equal(t, payload, string(frame.Payload))
+	require.NoError(t, <-errs)
+
+	require.Greater(t, transport.BytesReceived(true), int64(0))
+	require.Equal(t, conn.sent.Load(), transport.BytesSent(true))
+	require.Zero(t, transport.BytesSent(false))
+}
+
+func TestWebSocketTransportMessageTooBig(t *testing.T) {
+	t.Parallel()
+
+	transport, err := NewWebSocketTransport(&config.WebSocketConfiguration{MaxMessageSize: 16})
+	require.NoError(t, err)
+	require.Nil(t, transport.extension())
+	conn, client := newTestWSConnection(t, transport, false)
+
+	go func() {
+		_ = wsutil.WriteClientText(client, []byte(`{"type":"ping"}`))
+		_ = wsutil.WriteClientText(client, []byte(`{"type":"subscribe","id":"1"}`))
+	}()
+	var received map[string]any
+	require.NoError(t, conn.ReadJSON(&received))
+	require.Equal(t, "ping", received["type"])
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- conn.ReadJSON(&received)
+	}()
+	frame, err := ws.ReadFrame(client)
+	require.NoError(t, err)
+	require.Equal(t, ws.OpClose, frame.Header.OpCode)
+	code, _ := ws.ParseCloseFrameData(frame.Payload)
+	require.Equal(t, ws.StatusMessageTooBig, code)
+	require.ErrorIs(t, <-errs, errWebSocketMessageTooBig)
+	require.Equal(t, int64(1), transport.OversizedMessages())
+
+	_, err = NewWebSocketTransport(&config.WebSocketConfiguration{
+		Compression: config.WebSocketCompressionConfiguration{Enabled: true, Level: 10},
+	})
+	require.EqualError(t, err, "invalid websocket compression level 10, must be between 1 and 9")
+}
@@ -0,0 +1,190 @@
+package accesslog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type Format string
+
+const (
+	FormatAvro     Format = "avro"
+	FormatProtobuf Format = "protobuf"
+)
+
+// AvroSchema is the schema of the access log entries in the Avro format
+const AvroSchema = `{
+  "type": "record",
+  "name": "AccessLog",
+  "namespace": "com.wundergraph.cosmo.router",
+  "fields": [
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "status", "type": "int"},
+    {"name": "method", "type": "string"},
+    {"name": "path", "type": "string"},
+    {"name": "query", "type": "string"},
+    {"name": "ip", "type": "string"},
+    {"name": "user_agent", "type": "string"},
+    {"name": "latency_ns", "type": "long"},
+    {"name": "trace_id", "type": "string"},
+    {"name": "request_id", "type": "string"},
+    {"name": "config_version", "type": "string"},
+    {"name": "feature_flag", "type": "string"},
+    {"name": "fields", "type": {"type": "map", "values": "string"}}
+  ]
+}`
+
+// ProtobufSchema is the schema of the access log entries in the Protobuf format
+const ProtobufSchema = `syntax = "proto3";
+
+package wg.cosmo.router.accesslog.v1;
+
+message AccessLog {
+  int64 timestamp_unix_ms = 1;
+  int32 status = 2;
+  string method = 3;
+  string path = 4;
+  string query = 5;
+  string ip = 6;
+  string user_agent = 7;
+  int64 latency_ns = 8;
+  string trace_id = 9;
+  string request_id = 10;
+  string config_version = 11;
+  string feature_flag = 12;
+  map<string, string> fields = 13;
+}
+`
+
+// encoder serializes entries in the wire format of the Confluent Schema Registry
+type encoder interface {
+	schemaType() string
+	schema() string
+	encode(buf []byte, schemaID int, e *Entry) []byte
+}
+
+func newEncoder(format Format) (encoder, error) {
+	switch format {
+	case FormatAvro, "":
+		return avroEncoder{}, nil
+	case FormatProtobuf:
+		return protobufEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported access log format: %s", format)
+	}
+}
+
+// appendSchemaHeader writes the magic byte followed by the schema ID in big endian byte order
+func appendSchemaHeader(buf []byte, schemaID int) []byte {
+	buf = append(buf, 0)
+	return binary.BigEndian.AppendUint32(buf, uint32(schemaID))
+}
+
+// sortedKeys makes the encoding of the fields deterministic
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type avroEncoder struct{}
+
+func (avroEncoder) schemaType() string { return "AVRO" }
+
+func (avroEncoder) schema() string { return AvroSchema }
+
+func (avroEncoder) encode(buf []byte, schemaID int, e *Entry) []byte {
+	buf = appendSchemaHeader(buf, schemaID)
+
+	// Avro encodes int and long as zig-zag varints and strings with their length as prefix
+	buf = binary.AppendVarint(buf, e.Time.UnixMilli())
+	buf = binary.AppendVarint(buf, int64(e.Status))
+	buf = appendAvroString(buf, e.Method)
+	buf = appendAvroString(buf, e.Path)
+	buf = appendAvroString(buf, e.Query)
+	buf = appendAvroString(buf, e.IP)
+	buf = appendAvroString(buf, e.UserAgent)
+	buf = binary.AppendVarint(buf, int64(e.Latency))
+	buf = appendAvroString(buf, e.TraceID)
+	buf = appendAvroString(buf, e.RequestID)
+	buf = appendAvroString(buf, e.ConfigVersion)
+	buf = appendAvroString(buf, e.FeatureFlag)
+
+	// Maps are written as a single block of key value pairs which is terminated by an empty block
+	if len(e.Fields) > 0 {
+		buf = binary.AppendVarint(buf, int64(len(e.Fields)))
+		for _, key := range sortedKeys(e.Fields) {
+			buf = appendAvroString(buf, key)
+			buf = appendAvroString(buf, e.Fields[key])
+		}
+	}
+
+	return binary.AppendVarint(buf, 0)
+}
+
+func appendAvroString(buf []byte, s string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+type protobufEncoder struct{}
+
+func (protobufEncoder) schemaType() string { return "PROTOBUF" }
+
+func (protobufEncoder) schema() string { return ProtobufSchema }
+
+func (protobufEncoder) encode(buf []byte, schemaID int, e *Entry) []byte {
+	buf = appendSchemaHeader(buf, schemaID)
+	// The message indexes of the first message in the schema are encoded as a single zero
+	buf = append(buf, 0)
+
+	buf = appendProtoInt(buf, 1, e.Time.UnixMilli())
+	buf = appendProtoInt(buf, 2, int64(e.Status))
+	buf = appendProtoString(buf, 3, e.Method)
+	buf = appendProtoString(buf, 4, e.Path)
+	buf = appendProtoString(buf, 5, e.Query)
+	buf = appendProtoString(buf, 6, e.IP)
+	buf = appendProtoString(buf, 7, e.UserAgent)
+	buf = appendProtoInt(buf, 8, int64(e.Latency))
+	buf = appendProtoString(buf, 9, e.TraceID)
+	buf = appendProtoString(buf, 10, e.RequestID)
+	buf = appendProtoString(buf, 11, e.ConfigVersion)
+	buf = appendProtoString(buf, 12, e.FeatureFlag)
+
+	// Every map entry is a nested message with the key as field 1 and the value as field 2
+	for _, key := range sortedKeys(e.Fields) {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, key)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, e.Fields[key])
+
+		buf = protowire.AppendTag(buf, 13, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, entry)
+	}
+
+	return buf
+}
+
+// appendProtoInt omits default values like proto3 does
+func appendProtoInt(buf []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, num, protowire.VarintType)
+	return protowire.AppendVarint(buf, uint64(v))
+}
+
+func appendProtoString(buf []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, num, protowire.BytesType)
+	return protowire.AppendString(buf, s)
+}
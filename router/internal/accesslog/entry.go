@@ -0,0 +1,105 @@
+package accesslog
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is the typed representation of an access log line. Fields that have no dedicated
+// member, e.g. custom fields of modules, are kept as strings in Fields.
+type Entry struct {
+	Time          time.Time
+	Status        int32
+	Method        string
+	Path          string
+	Query         string
+	IP            string
+	UserAgent     string
+	Latency       time.Duration
+	TraceID       string
+	RequestID     string
+	ConfigVersion string
+	FeatureFlag   string
+	Fields        map[string]string
+}
+
+// newEntry converts the zap fields of the request logger into an entry.
+// Both the legacy field names and the ones of the OpenTelemetry semantic conventions are recognized.
+func newEntry(ent zapcore.Entry, fields []zapcore.Field) *Entry {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	e := &Entry{
+		Time: ent.Time,
+		// The request logger uses the path as message
+		Path: ent.Message,
+	}
+
+	for key, value := range enc.Fields {
+		switch key {
+		case "status", "http.response.status_code":
+			e.Status = int32(asInt64(value))
+		case "method", "http.request.method":
+			e.Method = asString(value)
+		case "path", "url.path":
+			e.Path = asString(value)
+		case "query", "url.query":
+			e.Query = asString(value)
+		case "ip", "client.address":
+			e.IP = asString(value)
+		case "user-agent", "user_agent.original":
+			e.UserAgent = asString(value)
+		case "latency":
+			e.Latency = time.Duration(asInt64(value))
+		case "traceID", "trace_id":
+			e.TraceID = asString(value)
+		case "request_id":
+			e.RequestID = asString(value)
+		case "config_version":
+			e.ConfigVersion = asString(value)
+		case "feature_flag":
+			e.FeatureFlag = asString(value)
+		default:
+			if e.Fields == nil {
+				e.Fields = make(map[string]string)
+			}
+			e.Fields[key] = asString(value)
+		}
+	}
+
+	return e
+}
+
+func asInt64(value any) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case time.Duration:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+func asString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,189 @@
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// producer is the subset of the Kafka client that is used by the sink
+type producer interface {
+	TryProduce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error))
+	Flush(ctx context.Context) error
+	Close()
+}
+
+type KafkaSinkOptions struct {
+	Logger *zap.Logger
+	Topic  string
+	Format Format
+	// KafkaOptions configure the connection to the brokers, e.g. brokers, TLS and SASL
+	KafkaOptions   []kgo.Opt
+	SchemaRegistry SchemaRegistryOptions
+	// HTTPClient is used to talk to the schema registry. Defaults to a client with a timeout of 10 seconds.
+	HTTPClient *http.Client
+}
+
+// KafkaSink publishes access log entries to a Kafka topic. The entries are serialized with a schema
+// that is registered in the schema registry so that consumers can decode them without parsing log lines.
+// Publishing never blocks the request. Entries are dropped when the buffer of the Kafka client is full.
+type KafkaSink struct {
+	logger   *zap.Logger
+	topic    string
+	encoder  encoder
+	schemaID int
+	producer producer
+
+	dropped atomic.Int64
+	failing atomic.Bool
+}
+
+func NewKafkaSink(ctx context.Context, opts *KafkaSinkOptions) (*KafkaSink, error) {
+	if opts.Topic == "" {
+		return nil, errors.New("access log kafka sink requires a topic")
+	}
+	if opts.SchemaRegistry.URL == "" {
+		return nil, errors.New("access log kafka sink requires a schema registry url")
+	}
+
+	enc, err := newEncoder(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	registryOpts := opts.SchemaRegistry
+	if registryOpts.Subject == "" {
+		registryOpts.Subject = opts.Topic + "-value"
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	schemaID, err := registerSchema(ctx, httpClient, &registryOpts, enc.schemaType(), enc.schema())
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kgo.NewClient(opts.KafkaOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKafkaSink(opts.Logger, opts.Topic, enc, schemaID, client), nil
+}
+
+func newKafkaSink(logger *zap.Logger, topic string, enc encoder, schemaID int, p producer) *KafkaSink {
+	return &KafkaSink{
+		logger:   logger,
+		topic:    topic,
+		encoder:  enc,
+		schemaID: schemaID,
+		producer: p,
+	}
+}
+
+// SchemaID returns the ID of the registered schema
+func (s *KafkaSink) SchemaID() int {
+	return s.schemaID
+}
+
+// Dropped returns the number of entries that could not be published
+func (s *KafkaSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Core returns a zap core that publishes every entry written to it. Tee it with the core of the request logger.
+func (s *KafkaSink) Core() zapcore.Core {
+	return &kafkaCore{sink: s}
+}
+
+// Flush publishes the buffered entries and waits until they're published or the context is done
+func (s *KafkaSink) Flush(ctx context.Context) error {
+	return s.producer.Flush(ctx)
+}
+
+// Close publishes the buffered entries and closes the Kafka client
+func (s *KafkaSink) Close(ctx context.Context) error {
+	err := s.producer.Flush(ctx)
+	s.producer.Close()
+	return err
+}
+
+func (s *KafkaSink) publish(ent zapcore.Entry, fields []zapcore.Field) {
+	entry := newEntry(ent, fields)
+
+	record := &kgo.Record{
+		Topic:     s.topic,
+		Key:       []byte(entry.RequestID),
+		Value:     s.encoder.encode(nil, s.schemaID, entry),
+		Timestamp: ent.Time,
+	}
+
+	s.producer.TryProduce(context.Background(), record, s.onProduced)
+}
+
+// onProduced only logs when publishing starts failing or recovers to not flood the logs while Kafka is unavailable
+func (s *KafkaSink) onProduced(_ *kgo.Record, err error) {
+	if err != nil {
+		s.dropped.Add(1)
+		if !s.failing.Swap(true) {
+			s.logger.Warn("Unable to publish access log entries to Kafka. Entries are dropped until publishing succeeds again",
+				zap.String("topic", s.topic),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+
+	if s.failing.Swap(false) {
+		s.logger.Info("Publishing access log entries to Kafka recovered",
+			zap.String("topic", s.topic),
+			zap.Int64("dropped_total", s.dropped.Load()),
+		)
+	}
+}
+
+type kafkaCore struct {
+	sink   *KafkaSink
+	fields []zapcore.Field
+}
+
+func (c *kafkaCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.InfoLevel
+}
+
+func (c *kafkaCore) With(fields []zapcore.Field) zapcore.Core {
+	return &kafkaCore{
+		sink:   c.sink,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *kafkaCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *kafkaCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+
+	c.sink.publish(ent, all)
+
+	return nil
+}
+
+func (c *kafkaCore) Sync() error {
+	return nil
+}
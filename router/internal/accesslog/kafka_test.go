@@ -0,0 +1,256 @@
+package accesslog
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type fakeProducer struct {
+	mu      sync.Mutex
+	records []*kgo.Record
+	err     error
+}
+
+func (p *fakeProducer) TryProduce(_ context.Context, r *kgo.Record, promise func(*kgo.Record, error)) {
+	p.mu.Lock()
+	p.records = append(p.records, r)
+	err := p.err
+	p.mu.Unlock()
+	promise(r, err)
+}
+
+func (p *fakeProducer) Flush(context.Context) error { return nil }
+
+func (p *fakeProducer) Close() {}
+
+var testEntryTime = time.UnixMilli(1700000000123)
+
+func testEntry() *Entry {
+	return &Entry{
+		Time:          testEntryTime,
+		Status:        200,
+		Method:        http.MethodPost,
+		Path:          "/graphql",
+		IP:            "127.0.0.1",
+		UserAgent:     "curl",
+		Latency:       1500 * time.Microsecond,
+		RequestID:     "req-1",
+		ConfigVersion: "v1",
+		Fields:        map[string]string{"b": "2", "a": "1"},
+	}
+}
+
+// avroReader decodes the primitive types of the Avro binary encoding
+type avroReader struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (r *avroReader) long() int64 {
+	v, n := binary.Varint(r.buf)
+	require.Greater(r.t, n, 0)
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *avroReader) string() string {
+	n := int(r.long())
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
+
+func TestAvroEncoder(t *testing.T) {
+	t.Parallel()
+
+	data := avroEncoder{}.encode(nil, 42, testEntry())
+
+	require.Equal(t, byte(0), data[0])
+	require.Equal(t, uint32(42), binary.BigEndian.Uint32(data[1:5]))
+
+	r := &avroReader{t: t, buf: data[5:]}
+	require.Equal(t, testEntryTime.UnixMilli(), r.long())
+	require.Equal(t, int64(200), r.long())
+	require.Equal(t, http.MethodPost, r.string())
+	require.Equal(t, "/graphql", r.string())
+	require.Equal(t, "", r.string())
+	require.Equal(t, "127.0.0.1", r.string())
+	require.Equal(t, "curl", r.string())
+	require.Equal(t, int64(1500*time.Microsecond), r.long())
+	require.Equal(t, "", r.string())
+	require.Equal(t, "req-1", r.string())
+	require.Equal(t, "v1", r.string())
+	require.Equal(t, "", r.string())
+
+	// The map is written as one block with sorted keys followed by the end marker
+	require.Equal(t, int64(2), r.long())
+	require.Equal(t, "a", r.string())
+	require.Equal(t, "1", r.string())
+	require.Equal(t, "b", r.string())
+	require.Equal(t, "2", r.string())
+	require.Equal(t, int64(0), r.long())
+	require.Empty(t, r.buf)
+
+	require.True(t, json.Valid([]byte(AvroSchema)))
+}
+
+func TestProtobufEncoder(t *testing.T) {
+	t.Parallel()
+
+	data := protobufEncoder{}.encode(nil, 7, testEntry())
+
+	require.Equal(t, byte(0), data[0])
+	require.Equal(t, uint32(7), binary.BigEndian.Uint32(data[1:5]))
+	// Message index of the first message
+	require.Equal(t, byte(0), data[5])
+
+	ints := map[protowire.Number]uint64{}
+	strs := map[protowire.Number]string{}
+	fields := map[string]string{}
+
+	buf := data[6:]
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		require.GreaterOrEqual(t, n, 0)
+		buf = buf[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(buf)
+			require.GreaterOrEqual(t, n, 0)
+			ints[num] = v
+			buf = buf[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(buf)
+			require.GreaterOrEqual(t, n, 0)
+			buf = buf[n:]
+
+			if num != 13 {
+				strs[num] = string(v)
+				continue
+			}
+
+			_, _, n = protowire.ConsumeTag(v)
+			key, m := protowire.ConsumeString(v[n:])
+			v = v[n+m:]
+			_, _, n = protowire.ConsumeTag(v)
+			value, _ := protowire.ConsumeString(v[n:])
+			fields[key] = value
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+
+	require.Equal(t, uint64(testEntryTime.UnixMilli()), ints[1])
+	require.Equal(t, uint64(200), ints[2])
+	require.Equal(t, uint64(1500*time.Microsecond), ints[8])
+	require.Equal(t, http.MethodPost, strs[3])
+	require.Equal(t, "/graphql", strs[4])
+	require.Equal(t, "req-1", strs[10])
+	// Empty strings are omitted like in proto3
+	require.NotContains(t, strs, protowire.Number(5))
+	require.Equal(t, map[string]string{"a": "1", "b": "2"}, fields)
+}
+
+func TestRegisterSchema(t *testing.T) {
+	t.Parallel()
+
+	var received registerSchemaRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/subjects/access-logs-value/versions", r.URL.Path)
+		require.Equal(t, "application/vnd.schemaregistry.v1+json", r.Header.Get("Content-Type"))
+
+		user, pass, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", user)
+		require.Equal(t, "pass", pass)
+
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_, _ = w.Write([]byte(`{"id":12}`))
+	}))
+	defer srv.Close()
+
+	id, err := registerSchema(context.Background(), srv.Client(), &SchemaRegistryOptions{
+		URL:      srv.URL + "/",
+		Subject:  "access-logs-value",
+		Username: "user",
+		Password: "pass",
+	}, "PROTOBUF", ProtobufSchema)
+	require.NoError(t, err)
+	require.Equal(t, 12, id)
+	require.Equal(t, "PROTOBUF", received.SchemaType)
+	require.Equal(t, ProtobufSchema, received.Schema)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"error_code":409,"message":"incompatible schema"}`))
+	}))
+	defer failing.Close()
+
+	_, err = registerSchema(context.Background(), failing.Client(), &SchemaRegistryOptions{
+		URL:     failing.URL,
+		Subject: "access-logs-value",
+	}, "AVRO", AvroSchema)
+	require.ErrorContains(t, err, "incompatible schema")
+}
+
+func TestKafkaSinkCore(t *testing.T) {
+	t.Parallel()
+
+	p := &fakeProducer{}
+	sink := newKafkaSink(zap.NewNop(), "access-logs", avroEncoder{}, 3, p)
+
+	logger := zap.New(sink.Core()).With(zap.String("config_version", "v1"))
+	logger.Debug("ignored")
+	logger.Info("/graphql",
+		zap.Int("status", 404),
+		zap.String("method", http.MethodGet),
+		zap.String("path", "/graphql"),
+		zap.Duration("latency", time.Second),
+		zap.String("request_id", "req-2"),
+		zap.String("custom", "value"),
+	)
+
+	require.Len(t, p.records, 1)
+	record := p.records[0]
+	require.Equal(t, "access-logs", record.Topic)
+	require.Equal(t, []byte("req-2"), record.Key)
+
+	entry := newEntry(zapcore.Entry{Message: "/graphql"}, []zapcore.Field{
+		zap.String("config_version", "v1"),
+		zap.Int("status", 404),
+		zap.String("method", http.MethodGet),
+		zap.String("path", "/graphql"),
+		zap.Duration("latency", time.Second),
+		zap.String("request_id", "req-2"),
+		zap.String("custom", "value"),
+	})
+	entry.Time = record.Timestamp
+	require.Equal(t, avroEncoder{}.encode(nil, 3, entry), record.Value)
+	require.Equal(t, map[string]string{"custom": "value"}, entry.Fields)
+	require.Equal(t, int32(404), entry.Status)
+	require.Equal(t, time.Second, entry.Latency)
+
+	p.err = errors.New("broker unavailable")
+	logger.Info("/graphql")
+	logger.Info("/graphql")
+	require.Equal(t, int64(2), sink.Dropped())
+	require.True(t, sink.failing.Load())
+
+	p.err = nil
+	logger.Info("/graphql")
+	require.False(t, sink.failing.Load())
+}
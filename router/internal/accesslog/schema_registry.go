@@ -0,0 +1,70 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type SchemaRegistryOptions struct {
+	URL      string
+	Username string
+	Password string
+	// Subject defaults to the topic name strategy "<topic>-value"
+	Subject string
+}
+
+type registerSchemaRequest struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+type registerSchemaResponse struct {
+	ID int `json:"id"`
+}
+
+// registerSchema registers the schema under the subject and returns its ID.
+// Registering a schema that already exists returns the ID of the existing one.
+func registerSchema(ctx context.Context, client *http.Client, opts *SchemaRegistryOptions, schemaType, schema string) (int, error) {
+	body, err := json.Marshal(registerSchemaRequest{
+		Schema:     schema,
+		SchemaType: schemaType,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	endpoint := strings.TrimSuffix(opts.URL, "/") + "/subjects/" + url.PathEscape(opts.Subject) + "/versions"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if opts.Username != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register access log schema: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return 0, fmt.Errorf("failed to register access log schema for subject %q: unexpected status code %d: %s", opts.Subject, res.StatusCode, message)
+	}
+
+	var registered registerSchemaResponse
+	if err := json.NewDecoder(res.Body).Decode(&registered); err != nil {
+		return 0, fmt.Errorf("failed to decode schema registry response: %w", err)
+	}
+
+	return registered.ID, nil
+}
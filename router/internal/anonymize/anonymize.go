@@ -0,0 +1,76 @@
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"sync"
+	"time"
+)
+
+// TruncateIP removes the host part of the address, i.e. zeroes the last octet of IPv4 addresses and the last 80 bits
+// of IPv6 addresses. The port is dropped. Values that are not an IP address are returned as "[REDACTED]".
+func TruncateIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "[REDACTED]"
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// Hasher pseudonymizes values with a keyed hash. The key is random and replaced after every rotation interval,
+// so the same value has the same pseudonym within an interval but can't be linked across intervals or
+// recovered by hashing known values. It is safe for concurrent use.
+type Hasher struct {
+	rotationInterval time.Duration
+	now              func() time.Time
+
+	mu        sync.Mutex
+	salt      []byte
+	rotatedAt time.Time
+}
+
+// NewHasher creates a hasher. A rotation interval of zero keeps the salt for the lifetime of the process.
+func NewHasher(rotationInterval time.Duration) *Hasher {
+	return &Hasher{
+		rotationInterval: rotationInterval,
+		now:              time.Now,
+	}
+}
+
+// Hash returns the hex encoded pseudonym of the value
+func (h *Hasher) Hash(value string) string {
+	mac := hmac.New(sha256.New, h.currentSalt())
+	mac.Write([]byte(value))
+	// 16 bytes are enough to avoid collisions and keep the log lines short
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (h *Hasher) currentSalt() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if h.salt == nil || (h.rotationInterval > 0 && now.Sub(h.rotatedAt) >= h.rotationInterval) {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			// crypto/rand doesn't fail on supported platforms
+			panic(err)
+		}
+		h.salt = salt
+		h.rotatedAt = now
+	}
+
+	return h.salt
+}
@@ -0,0 +1,33 @@
+package anonymize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateIP(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "192.168.1.0", TruncateIP("192.168.1.42:51234"))
+	require.Equal(t, "10.0.0.0", TruncateIP("10.0.0.255"))
+	require.Equal(t, "2001:db8:85a3::", TruncateIP("[2001:db8:85a3:8d3:1319:8a2e:370:7348]:443"))
+	require.Equal(t, "[REDACTED]", TruncateIP("unknown"))
+}
+
+func TestHasher(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	h := NewHasher(time.Hour)
+	h.now = func() time.Time { return now }
+
+	a := h.Hash("user-1")
+	require.Len(t, a, 32)
+	require.Equal(t, a, h.Hash("user-1"))
+	require.NotEqual(t, a, h.Hash("user-2"))
+
+	now = now.Add(time.Hour)
+	require.NotEqual(t, a, h.Hash("user-1"))
+}
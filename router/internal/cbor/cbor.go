@@ -0,0 +1,153 @@
+// Package cbor encodes JSON documents as CBOR (RFC 8949), e.g. to send smaller responses to mobile clients.
+package cbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	majorUnsigned byte = 0 << 5
+	majorNegative byte = 1 << 5
+	majorText     byte = 3 << 5
+	majorArray    byte = 4 << 5
+	majorMap      byte = 5 << 5
+	majorSimple   byte = 7 << 5
+
+	simpleFalse   = majorSimple | 20
+	simpleTrue    = majorSimple | 21
+	simpleNull    = majorSimple | 22
+	simpleFloat32 = majorSimple | 26
+	simpleFloat64 = majorSimple | 27
+)
+
+// FromJSON appends the CBOR encoding of the JSON document to dst. Integers are encoded as integers, other numbers as
+// the shortest float that represents them exactly. Objects keep the order of their keys.
+func FromJSON(dst, data []byte) ([]byte, error) {
+	value, dataType, _, err := jsonparser.Get(data)
+	if err != nil {
+		return dst, err
+	}
+	return appendValue(dst, value, dataType)
+}
+
+func appendValue(dst, value []byte, dataType jsonparser.ValueType) ([]byte, error) {
+	switch dataType {
+	case jsonparser.Object:
+		return appendObject(dst, value)
+	case jsonparser.Array:
+		return appendArray(dst, value)
+	case jsonparser.String:
+		s, err := jsonparser.ParseString(value)
+		if err != nil {
+			return dst, err
+		}
+		return appendText(dst, s), nil
+	case jsonparser.Number:
+		return appendNumber(dst, value)
+	case jsonparser.Boolean:
+		if value[0] == 't' {
+			return append(dst, simpleTrue), nil
+		}
+		return append(dst, simpleFalse), nil
+	case jsonparser.Null:
+		return append(dst, simpleNull), nil
+	}
+	return dst, fmt.Errorf("unsupported JSON value %q", value)
+}
+
+func appendObject(dst, value []byte) ([]byte, error) {
+	// The definite length of the map requires the number of its keys upfront
+	n := 0
+	err := jsonparser.ObjectEach(value, func(_, _ []byte, _ jsonparser.ValueType, _ int) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return dst, err
+	}
+
+	dst = appendHead(dst, majorMap, uint64(n))
+	err = jsonparser.ObjectEach(value, func(key, v []byte, dataType jsonparser.ValueType, _ int) error {
+		k, err := jsonparser.ParseString(key)
+		if err != nil {
+			return err
+		}
+		dst = appendText(dst, k)
+		dst, err = appendValue(dst, v, dataType)
+		return err
+	})
+	return dst, err
+}
+
+func appendArray(dst, value []byte) ([]byte, error) {
+	n := 0
+	_, err := jsonparser.ArrayEach(value, func(_ []byte, _ jsonparser.ValueType, _ int, _ error) {
+		n++
+	})
+	if err != nil {
+		return dst, err
+	}
+
+	dst = appendHead(dst, majorArray, uint64(n))
+	var itemErr error
+	_, err = jsonparser.ArrayEach(value, func(v []byte, dataType jsonparser.ValueType, _ int, _ error) {
+		if itemErr != nil {
+			return
+		}
+		dst, itemErr = appendValue(dst, v, dataType)
+	})
+	if err != nil {
+		return dst, err
+	}
+	return dst, itemErr
+}
+
+func appendNumber(dst, value []byte) ([]byte, error) {
+	if i, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+		if i < 0 {
+			// A negative integer n is encoded as -1-n
+			return appendHead(dst, majorNegative, uint64(-1-i)), nil
+		}
+		return appendHead(dst, majorUnsigned, uint64(i)), nil
+	}
+	if u, err := strconv.ParseUint(string(value), 10, 64); err == nil {
+		return appendHead(dst, majorUnsigned, u), nil
+	}
+
+	f, err := strconv.ParseFloat(string(value), 64)
+	if err != nil {
+		return dst, err
+	}
+	if f32 := float32(f); float64(f32) == f {
+		dst = append(dst, simpleFloat32)
+		return binary.BigEndian.AppendUint32(dst, math.Float32bits(f32)), nil
+	}
+	dst = append(dst, simpleFloat64)
+	return binary.BigEndian.AppendUint64(dst, math.Float64bits(f)), nil
+}
+
+func appendText(dst []byte, s string) []byte {
+	dst = appendHead(dst, majorText, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// appendHead appends the initial byte of a data item with its argument in the shortest form
+func appendHead(dst []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(dst, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(dst, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, major|27), n)
+	}
+}
@@ -0,0 +1,54 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromJSON(t *testing.T) {
+	t.Parallel()
+
+	// The expected encodings are the examples of RFC 8949, appendix A, where they apply
+	for _, tc := range []struct {
+		json string
+		cbor string
+	}{
+		{json: `0`, cbor: "00"},
+		{json: `23`, cbor: "17"},
+		{json: `24`, cbor: "1818"},
+		{json: `1000`, cbor: "1903e8"},
+		{json: `1000000`, cbor: "1a000f4240"},
+		{json: `18446744073709551615`, cbor: "1bffffffffffffffff"},
+		{json: `-1`, cbor: "20"},
+		{json: `-1000`, cbor: "3903e7"},
+		{json: `1.5`, cbor: "fa3fc00000"},
+		{json: `1.1`, cbor: "fb3ff199999999999a"},
+		{json: `true`, cbor: "f5"},
+		{json: `false`, cbor: "f4"},
+		{json: `null`, cbor: "f6"},
+		{json: `""`, cbor: "60"},
+		{json: `"a"`, cbor: "6161"},
+		{json: `"ü"`, cbor: "62c3bc"},
+		{json: `"\"\\"`, cbor: "62225c"},
+		{json: `[]`, cbor: "80"},
+		{json: `[1, [2, 3], [4, 5]]`, cbor: "8301820203820405"},
+		{json: `{}`, cbor: "a0"},
+		{json: `{"a": 1, "b": [2, 3]}`, cbor: "a26161016162820203"},
+		{json: `{"data":{"employee":{"id":1,"tag":null}}}`, cbor: "a16464617461a168656d706c6f796565a26269640163746167f6"},
+	} {
+		out, err := FromJSON(nil, []byte(tc.json))
+		require.NoError(t, err, tc.json)
+		require.Equal(t, tc.cbor, hex.EncodeToString(out), tc.json)
+	}
+}
+
+func TestFromJSONInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{``, `{"a":`, `[1,`, `{"a": tru}`} {
+		_, err := FromJSON(nil, []byte(input))
+		require.Error(t, err, input)
+	}
+}
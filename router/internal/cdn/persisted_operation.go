@@ -99,6 +99,11 @@ type PersistentOperationClient struct {
 	logger          *zap.Logger
 }
 
+// CachedPersistedOperation returns the persisted operation from the in-memory cache, or nil when it isn't cached
+func (cdn *PersistentOperationClient) CachedPersistedOperation(clientName string, sha256Hash string) []byte {
+	return cdn.operationsCache.Get(clientName, sha256Hash)
+}
+
 func (cdn *PersistentOperationClient) PersistedOperation(ctx context.Context, clientName string, sha256Hash string) ([]byte, error) {
 	if data := cdn.operationsCache.Get(clientName, sha256Hash); data != nil {
 		return data, nil
@@ -25,6 +25,9 @@ type ConfigPoller interface {
 	GetRouterConfig(ctx context.Context) (*nodev1.RouterConfig, error)
 	// Stop stops the config poller. After calling stop, the config poller cannot be used again.
 	Stop(ctx context.Context) error
+	// OnFetchError registers a handler that is invoked when fetching a config update fails.
+	// It must be called before Subscribe.
+	OnFetchError(handler func(err error))
 }
 
 type configPoller struct {
@@ -35,6 +38,7 @@ type configPoller struct {
 	poller                    controlplane.Poller
 	pollInterval              time.Duration
 	cdnConfigClient           *cdn.RouterConfigClient
+	fetchErrorHandler         func(err error)
 }
 
 func New(endpoint, token string, opts ...Option) ConfigPoller {
@@ -76,12 +80,19 @@ func (c *configPoller) Stop(_ context.Context) error {
 	return c.poller.Stop()
 }
 
+func (c *configPoller) OnFetchError(handler func(err error)) {
+	c.fetchErrorHandler = handler
+}
+
 func (c *configPoller) Subscribe(ctx context.Context, handler func(newConfig *nodev1.RouterConfig, _ string) error) {
 
 	c.poller.Subscribe(ctx, func() {
 		cfg, err := c.getRouterConfig(ctx)
 		if err != nil {
 			c.logger.Sugar().Errorf("Could not fetch for config update. Trying again in %s", c.pollInterval.String())
+			if c.fetchErrorHandler != nil {
+				c.fetchErrorHandler(err)
+			}
 			return
 		}
 
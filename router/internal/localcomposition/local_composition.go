@@ -0,0 +1,207 @@
+// Package localcomposition composes the router config from local subgraph SDL files, for a standalone development
+// loop without wgc and the control plane.
+package localcomposition
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wundergraph/cosmo/composition-go"
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/cosmo/router/internal/controlplane"
+	"github.com/wundergraph/cosmo/router/internal/controlplane/configpoller"
+	"github.com/wundergraph/cosmo/router/pkg/execution_config"
+)
+
+type Subgraph struct {
+	Name       string
+	RoutingURL string
+	// SchemaFile is the path of the SDL file of the subgraph
+	SchemaFile string
+}
+
+// Composer composes the router config from the SDL files of the subgraphs. When it watches the files, a change of a
+// file composes the config again and updates the router, like a new config from the CDN.
+type Composer struct {
+	logger    *zap.Logger
+	subgraphs []Subgraph
+	// watchInterval is the interval in which the files are checked for changes. Zero doesn't watch the files.
+	watchInterval             time.Duration
+	poller                    controlplane.Poller
+	latestRouterConfigVersion string
+	// failedVersion is the version of the files that couldn't be composed
+	failedVersion     string
+	fetchErrorHandler func(err error)
+}
+
+var _ configpoller.ConfigPoller = (*Composer)(nil)
+
+type Option func(c *Composer)
+
+func New(subgraphs []Subgraph, opts ...Option) (*Composer, error) {
+	if len(subgraphs) == 0 {
+		return nil, errors.New("the local composition requires at least one subgraph")
+	}
+	names := make(map[string]struct{}, len(subgraphs))
+	for _, sg := range subgraphs {
+		if sg.Name == "" || sg.RoutingURL == "" || sg.SchemaFile == "" {
+			return nil, errors.New("every subgraph of the local composition requires a name, a routing url and a schema file")
+		}
+		if _, ok := names[sg.Name]; ok {
+			return nil, fmt.Errorf("the subgraph '%s' is listed more than once in the local composition", sg.Name)
+		}
+		names[sg.Name] = struct{}{}
+	}
+
+	c := &Composer{
+		subgraphs: subgraphs,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.logger == nil {
+		c.logger = zap.NewNop()
+	}
+	if c.watchInterval > 0 {
+		c.poller = controlplane.NewPoll(c.watchInterval)
+	}
+
+	return c, nil
+}
+
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *Composer) {
+		c.logger = logger
+	}
+}
+
+// WithWatchInterval checks the files for changes in the interval
+func WithWatchInterval(interval time.Duration) Option {
+	return func(c *Composer) {
+		c.watchInterval = interval
+	}
+}
+
+// GetRouterConfig composes the router config. Not safe for concurrent use.
+func (c *Composer) GetRouterConfig(_ context.Context) (*nodev1.RouterConfig, error) {
+	schemas, version, err := c.readSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := c.compose(schemas, version)
+	if err != nil {
+		return nil, err
+	}
+
+	c.latestRouterConfigVersion = version
+	return cfg, nil
+}
+
+func (c *Composer) Subscribe(ctx context.Context, handler func(newConfig *nodev1.RouterConfig, oldVersion string) error) {
+	if c.poller == nil {
+		return
+	}
+
+	c.poller.Subscribe(ctx, func() {
+		schemas, version, err := c.readSchemas()
+		if err != nil {
+			c.logger.Error("Could not read the subgraph schemas of the local composition", zap.Error(err))
+			if c.fetchErrorHandler != nil {
+				c.fetchErrorHandler(err)
+			}
+			return
+		}
+
+		// The files haven't changed since the last composition
+		if version == c.latestRouterConfigVersion || version == c.failedVersion {
+			return
+		}
+
+		cfg, err := c.compose(schemas, version)
+		if err != nil {
+			c.logger.Error("Could not compose the subgraph schemas, the router keeps the previous config", zap.Error(err))
+			if c.fetchErrorHandler != nil {
+				c.fetchErrorHandler(err)
+			}
+			// The same files aren't composed again until they change
+			c.failedVersion = version
+			return
+		}
+
+		if err := handler(cfg, c.latestRouterConfigVersion); err != nil {
+			c.logger.Error("Error invoking config poll handler", zap.Error(err))
+			return
+		}
+
+		c.logger.Info("Subgraph schemas changed, composed the router config", zap.String("version", version))
+
+		c.latestRouterConfigVersion = version
+	})
+}
+
+// Stop stops watching the files
+func (c *Composer) Stop(_ context.Context) error {
+	if c.poller == nil {
+		return nil
+	}
+	return c.poller.Stop()
+}
+
+func (c *Composer) OnFetchError(handler func(err error)) {
+	c.fetchErrorHandler = handler
+}
+
+// readSchemas reads the SDL files of the subgraphs. The version is the hash of the subgraphs and their schemas, so
+// that the config is only composed again when a schema changes.
+func (c *Composer) readSchemas() ([]string, string, error) {
+	hash := sha256.New()
+	schemas := make([]string, 0, len(c.subgraphs))
+
+	for _, sg := range c.subgraphs {
+		schema, err := os.ReadFile(sg.SchemaFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read the schema of the subgraph '%s': %w", sg.Name, err)
+		}
+		schemas = append(schemas, string(schema))
+
+		// The lengths separate the values, so that different inputs can't produce the same hash
+		_, _ = fmt.Fprintf(hash, "%d:%s%d:%s%d:", len(sg.Name), sg.Name, len(sg.RoutingURL), sg.RoutingURL, len(schema))
+		_, _ = hash.Write(schema)
+	}
+
+	return schemas, "local-" + hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+func (c *Composer) compose(schemas []string, version string) (*nodev1.RouterConfig, error) {
+	subgraphs := make([]*composition.Subgraph, 0, len(c.subgraphs))
+	for i, sg := range c.subgraphs {
+		subgraphs = append(subgraphs, &composition.Subgraph{
+			Name:   sg.Name,
+			URL:    sg.RoutingURL,
+			Schema: schemas[i],
+		})
+	}
+
+	routerConfigJSON, err := composition.BuildRouterConfiguration(subgraphs...)
+	if err != nil {
+		return nil, fmt.Errorf("could not compose the subgraphs: %w", err)
+	}
+
+	cfg, err := execution_config.SerializeConfigBytes([]byte(routerConfigJSON))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the composed router config: %w", err)
+	}
+	cfg.Version = version
+
+	return cfg, nil
+}
@@ -0,0 +1,122 @@
+package localcomposition
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+)
+
+const (
+	employeesSchema = `
+type Query {
+  employee(id: Int!): Employee
+}
+
+type Employee @key(fields: "id") {
+  id: Int!
+  name: String!
+}
+`
+	productsSchema = `
+type Employee @key(fields: "id") {
+  id: Int!
+  products: [String!]!
+}
+`
+)
+
+func writeSchema(t *testing.T, dir, name, schema string) string {
+	t.Helper()
+	path := filepath.Join(dir, name+".graphqls")
+	require.NoError(t, os.WriteFile(path, []byte(schema), 0o600))
+	return path
+}
+
+func TestComposer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("composes the subgraphs", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		c, err := New([]Subgraph{
+			{Name: "employees", RoutingURL: "http://localhost:4001/graphql", SchemaFile: writeSchema(t, dir, "employees", employeesSchema)},
+			{Name: "products", RoutingURL: "http://localhost:4004/graphql", SchemaFile: writeSchema(t, dir, "products", productsSchema)},
+		})
+		require.NoError(t, err)
+
+		cfg, err := c.GetRouterConfig(context.Background())
+		require.NoError(t, err)
+		require.Regexp(t, `^local-[0-9a-f]{16}$`, cfg.Version)
+		require.Len(t, cfg.Subgraphs, 2)
+		require.Equal(t, "http://localhost:4004/graphql", cfg.Subgraphs[1].RoutingUrl)
+		require.Contains(t, cfg.EngineConfig.GraphqlSchema, "products: [String!]!")
+	})
+
+	t.Run("composes again when a schema changes", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		employeesFile := writeSchema(t, dir, "employees", employeesSchema)
+		c, err := New([]Subgraph{
+			{Name: "employees", RoutingURL: "http://localhost:4001/graphql", SchemaFile: employeesFile},
+		}, WithWatchInterval(10*time.Millisecond))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, c.Stop(context.Background()))
+		})
+
+		initial, err := c.GetRouterConfig(context.Background())
+		require.NoError(t, err)
+
+		fetchErrors := make(chan error, 10)
+		c.OnFetchError(func(err error) {
+			fetchErrors <- err
+		})
+		configs := make(chan *nodev1.RouterConfig, 10)
+		c.Subscribe(context.Background(), func(newConfig *nodev1.RouterConfig, oldVersion string) error {
+			require.Equal(t, initial.Version, oldVersion)
+			configs <- newConfig
+			return nil
+		})
+
+		// An invalid schema keeps the previous config
+		writeSchema(t, dir, "employees", "type Query {")
+		select {
+		case <-fetchErrors:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the invalid schema was not reported")
+		}
+
+		writeSchema(t, dir, "employees", employeesSchema+"\nextend type Employee { email: String! }\n")
+		select {
+		case cfg := <-configs:
+			require.NotEqual(t, initial.Version, cfg.Version)
+			require.Contains(t, cfg.EngineConfig.GraphqlSchema, "email: String!")
+		case <-time.After(5 * time.Second):
+			t.Fatal("the changed schema was not composed")
+		}
+	})
+
+	t.Run("rejects invalid subgraphs", func(t *testing.T) {
+		t.Parallel()
+
+		for _, subgraphs := range [][]Subgraph{
+			nil,
+			{{Name: "employees", RoutingURL: "http://localhost:4001/graphql"}},
+			{
+				{Name: "employees", RoutingURL: "http://localhost:4001/graphql", SchemaFile: "employees.graphqls"},
+				{Name: "employees", RoutingURL: "http://localhost:4002/graphql", SchemaFile: "employees.graphqls"},
+			},
+		} {
+			_, err := New(subgraphs)
+			require.Error(t, err)
+		}
+	})
+}
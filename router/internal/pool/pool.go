@@ -2,16 +2,83 @@ package pool
 
 import (
 	"bytes"
-
-	"github.com/wundergraph/graphql-go-tools/v2/pkg/pool"
+	"sync"
 )
 
+// sizeClasses are the capacities of the pooled buffers. Every class has its own pool, so that
+// a few large responses don't bloat the buffers that are handed out for small ones and large
+// bodies don't have to grow a small buffer step by step.
+var sizeClasses = [...]int{
+	1 << 10,   // 1KB
+	4 << 10,   // 4KB
+	16 << 10,  // 16KB
+	64 << 10,  // 64KB
+	256 << 10, // 256KB
+	1 << 20,   // 1MB
+	4 << 20,   // 4MB
+}
+
+// MaxPooledBufferSize is the capacity above which buffers are dropped instead of returned to the pool
+// to avoid retaining the memory of exceptionally large bodies
+const MaxPooledBufferSize = 16 << 20
+
+var pools [len(sizeClasses)]sync.Pool
+
+func init() {
+	for i := range pools {
+		size := sizeClasses[i]
+		pools[i].New = func() any {
+			return bytes.NewBuffer(make([]byte, 0, size))
+		}
+	}
+}
+
+// GetBytesBuffer returns an empty buffer of the smallest size class.
+// Prefer GetBytesBufferWithSize when the expected size is known.
 func GetBytesBuffer() *bytes.Buffer {
-	buf := pool.BytesBuffer.Get()
+	return GetBytesBufferWithSize(0)
+}
+
+// GetBytesBufferWithSize returns an empty buffer with a capacity of at least size bytes.
+// Sizes above the largest size class return a buffer of the largest class which grows on demand.
+// This way a hint that comes from a client, e.g. the Content-Length header, can't allocate arbitrary memory.
+func GetBytesBufferWithSize(size int64) *bytes.Buffer {
+	buf := pools[classForGet(size)].Get().(*bytes.Buffer)
 	buf.Reset()
 	return buf
 }
 
+// PutBytesBuffer returns the buffer to the pool of the largest size class it can serve.
+// The buffer must not be used afterward.
 func PutBytesBuffer(buf *bytes.Buffer) {
-	pool.BytesBuffer.Put(buf)
+	class := classForPut(buf.Cap())
+	if class < 0 {
+		return
+	}
+	buf.Reset()
+	pools[class].Put(buf)
+}
+
+// classForGet returns the smallest size class that fits size or the largest class if none does
+func classForGet(size int64) int {
+	for i, classSize := range sizeClasses {
+		if size <= int64(classSize) {
+			return i
+		}
+	}
+	return len(sizeClasses) - 1
+}
+
+// classForPut returns the largest size class that a buffer with the given capacity satisfies
+// or -1 if the buffer should not be pooled
+func classForPut(capacity int) int {
+	if capacity < sizeClasses[0] || capacity > MaxPooledBufferSize {
+		return -1
+	}
+	for i := len(sizeClasses) - 1; i >= 0; i-- {
+		if capacity >= sizeClasses[i] {
+			return i
+		}
+	}
+	return -1
 }
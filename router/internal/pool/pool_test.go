@@ -0,0 +1,104 @@
+package pool
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	toolspool "github.com/wundergraph/graphql-go-tools/v2/pkg/pool"
+)
+
+func TestGetBytesBufferWithSize(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int64{-1, 0, 1, 1 << 10, 1<<10 + 1, 100 << 10, 4 << 20} {
+		buf := GetBytesBufferWithSize(size)
+		require.Zero(t, buf.Len())
+		require.GreaterOrEqual(t, int64(buf.Cap()), size)
+		PutBytesBuffer(buf)
+	}
+
+	// Hints above the largest class must not allocate the full size upfront
+	buf := GetBytesBufferWithSize(1 << 30)
+	require.Less(t, buf.Cap(), MaxPooledBufferSize)
+}
+
+func TestSizeClasses(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 0, classForGet(-1))
+	require.Equal(t, 0, classForGet(1<<10))
+	require.Equal(t, 1, classForGet(1<<10+1))
+	require.Equal(t, len(sizeClasses)-1, classForGet(1<<30))
+
+	// Buffers are returned to the largest class they can serve
+	require.Equal(t, -1, classForPut(512))
+	require.Equal(t, 0, classForPut(1<<10))
+	require.Equal(t, 0, classForPut(4<<10-1))
+	require.Equal(t, 1, classForPut(4<<10))
+	require.Equal(t, len(sizeClasses)-1, classForPut(MaxPooledBufferSize))
+	require.Equal(t, -1, classForPut(MaxPooledBufferSize+1))
+}
+
+func TestPutBytesBufferResets(t *testing.T) {
+	t.Parallel()
+
+	buf := bytes.NewBuffer(make([]byte, 0, 64<<10))
+	buf.WriteString("data")
+	PutBytesBuffer(buf)
+	require.Zero(t, buf.Len())
+}
+
+// benchmarkSizes mixes many small responses with a few large ones like typical GraphQL traffic
+var benchmarkSizes = []int{200, 800, 2 << 10, 500, 12 << 10, 300, 90 << 10, 1 << 10, 600, 700 << 10}
+
+func writePayload(buf *bytes.Buffer, payload []byte, size int) {
+	for written := 0; written < size; written += len(payload) {
+		buf.Write(payload[:min(len(payload), size-written)])
+	}
+}
+
+func BenchmarkBytesBuffer(b *testing.B) {
+	payload := bytes.Repeat([]byte("x"), 4<<10)
+
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			size := benchmarkSizes[i%len(benchmarkSizes)]
+			buf := &bytes.Buffer{}
+			writePayload(buf, payload, size)
+		}
+	})
+
+	// A single pool allocates the least but eventually holds a buffer of the largest size for every entry
+	b.Run("single pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			size := benchmarkSizes[i%len(benchmarkSizes)]
+			buf := toolspool.BytesBuffer.Get()
+			writePayload(buf, payload, size)
+			toolspool.BytesBuffer.Put(buf)
+		}
+	})
+
+	// Without a hint, every large body has to grow a buffer of the smallest class
+	b.Run("size classes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			size := benchmarkSizes[i%len(benchmarkSizes)]
+			buf := GetBytesBuffer()
+			writePayload(buf, payload, size)
+			PutBytesBuffer(buf)
+		}
+	})
+
+	b.Run("size classes with hint", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			size := benchmarkSizes[i%len(benchmarkSizes)]
+			buf := GetBytesBufferWithSize(int64(size))
+			writePayload(buf, payload, size)
+			PutBytesBuffer(buf)
+		}
+	})
+}
@@ -15,13 +15,15 @@ import (
 	"strconv"
 )
 
-var (
-	pprofPort = flag.Int("pprof-port", 6060, "Port for pprof server, set to zero to disable")
-)
+var pprofPort = 6060
+
+func registerPprofFlags() {
+	flag.IntVar(&pprofPort, "pprof-port", 6060, "Port for pprof server, set to zero to disable")
+}
 
 func initPprofHandlers() {
 	// Allow compiling in pprof but still disabling it at runtime
-	if *pprofPort == 0 {
+	if pprofPort == 0 {
 		return
 	}
 	mux := http.NewServeMux()
@@ -32,9 +34,9 @@ func initPprofHandlers() {
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
 	server := &http.Server{
-		Addr: ":" + strconv.Itoa(*pprofPort),
+		Addr: ":" + strconv.Itoa(pprofPort),
 	}
-	log.Printf("starting pprof server on port %d - do not use this in production, it is a security risk", *pprofPort)
+	log.Printf("starting pprof server on port %d - do not use this in production, it is a security risk", pprofPort)
 	go func() {
 		if err := server.ListenAndServe(); err != nil {
 			log.Fatal("error starting pprof server", err)
@@ -1,7 +1,7 @@
 // Package profile implements functions for profiling the router
 //
 // This package automatically registers pprof handlers if the build tag "pprof".
-// Additionally, the following flags are available:
+// Additionally, the following flags are available after RegisterFlags is called:
 // -cpuprofile: write cpu profile to file
 // -memprofile: write memory profile to this file
 // -pprof-port: port for pprof server, set to zero to disable (only with pprof build tag)
@@ -17,10 +17,18 @@ import (
 )
 
 var (
-	memprofile = flag.String("memprofile", "", "write memory profile to this file")
-	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
+	memprofile string
+	cpuprofile string
 )
 
+// RegisterFlags adds the profiling flags to the command line flags. It must be called before flag.Parse. The flags
+// aren't registered on import, so that the packages of the router can be imported without changing the flags.
+func RegisterFlags() {
+	flag.StringVar(&memprofile, "memprofile", "", "write memory profile to this file")
+	flag.StringVar(&cpuprofile, "cpuprofile", "", "write cpu profile to file")
+	registerPprofFlags()
+}
+
 type Profiler interface {
 	Finish()
 }
@@ -48,9 +56,9 @@ func Start() Profiler {
 	initPprofHandlers()
 
 	var cpuProfileFile *os.File
-	if *cpuprofile != "" {
+	if cpuprofile != "" {
 		var err error
-		cpuProfileFile, err = os.Create(*cpuprofile)
+		cpuProfileFile, err = os.Create(cpuprofile)
 		if err != nil {
 			log.Fatal("Could not create CPU profile", err)
 		}
@@ -65,8 +73,8 @@ func Start() Profiler {
 }
 
 func createMemprofileIfNeeded() {
-	if *memprofile != "" {
-		f, err := os.Create(*memprofile)
+	if memprofile != "" {
+		f, err := os.Create(memprofile)
 		if err != nil {
 			log.Fatal("error creating file for heap profile", err)
 		}
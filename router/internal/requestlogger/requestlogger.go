@@ -1,12 +1,13 @@
 package requestlogger
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wundergraph/cosmo/router/internal/anonymize"
+	rotel "github.com/wundergraph/cosmo/router/pkg/otel"
 
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
@@ -15,6 +16,15 @@ import (
 
 type Fn func(r *http.Request) []zapcore.Field
 
+// SampleFn reports whether the entry of the request with the response status is logged
+type SampleFn func(r *http.Request, status int) bool
+
+// ObserveFn is called with the response status and the latency of every request, regardless of the sampling
+type ObserveFn func(r *http.Request, status int, latency time.Duration)
+
+// EntryFn returns additional fields for the log entry of the request or whether the entry is dropped
+type EntryFn func(r *http.Request, fields []zapcore.Field) (extra []zapcore.Field, drop bool)
+
 // Option provides a functional approach to define
 // configuration for a handler; such as setting the logging
 // whether to print stack traces on panic.
@@ -24,14 +34,17 @@ type (
 	IPAnonymizationConfig struct {
 		Enabled bool
 		Method  IPAnonymizationMethod
+		// Hasher is used by the hash method. A hasher that never rotates its salt is used when nil.
+		Hasher *anonymize.Hasher
 	}
 )
 
 type IPAnonymizationMethod string
 
 const (
-	Hash   IPAnonymizationMethod = "hash"
-	Redact IPAnonymizationMethod = "redact"
+	Hash     IPAnonymizationMethod = "hash"
+	Redact   IPAnonymizationMethod = "redact"
+	Truncate IPAnonymizationMethod = "truncate"
 )
 
 type handler struct {
@@ -39,8 +52,17 @@ type handler struct {
 	utc                   bool
 	skipPaths             []string
 	ipAnonymizationConfig *IPAnonymizationConfig
+	ipHasher              *anonymize.Hasher
+	pseudonymizer         *anonymize.Hasher
+	pseudonymizedFields   map[string]struct{}
 	traceID               bool // optionally log Open Telemetry TraceID
+	traceContext          bool
+	logUnsampled          bool
+	semConvStability      rotel.SemConvStability
 	context               Fn
+	sample                SampleFn
+	observe               ObserveFn
+	entry                 EntryFn
 	handler               http.Handler
 	logger                *zap.Logger
 	fields                []zapcore.Field
@@ -51,6 +73,13 @@ func parseOptions(r *handler, opts ...Option) http.Handler {
 		option(r)
 	}
 
+	if r.ipAnonymizationConfig != nil {
+		r.ipHasher = r.ipAnonymizationConfig.Hasher
+		if r.ipHasher == nil {
+			r.ipHasher = anonymize.NewHasher(0)
+		}
+	}
+
 	return r
 }
 
@@ -60,12 +89,61 @@ func WithAnonymization(ipConfig *IPAnonymizationConfig) Option {
 	}
 }
 
+// WithPseudonymization replaces the values of the given fields, e.g. user identifiers added by custom modules,
+// with a pseudonym of the hasher
+func WithPseudonymization(hasher *anonymize.Hasher, fields ...string) Option {
+	return func(r *handler) {
+		r.pseudonymizer = hasher
+		r.pseudonymizedFields = make(map[string]struct{}, len(fields))
+		for _, field := range fields {
+			r.pseudonymizedFields[field] = struct{}{}
+		}
+	}
+}
+
+// WithSemConvStability names the fields after the current OpenTelemetry semantic conventions
+func WithSemConvStability(stability rotel.SemConvStability) Option {
+	return func(r *handler) {
+		r.semConvStability = stability
+	}
+}
+
+// WithTraceContext logs the W3C traceparent and the sampling decision of the request. The trace fields of unsampled
+// requests are only logged with logUnsampled, because their traces are not exported.
+func WithTraceContext(logUnsampled bool) Option {
+	return func(r *handler) {
+		r.traceContext = true
+		r.logUnsampled = logUnsampled
+	}
+}
+
 func WithRequestFields(fn Fn) Option {
 	return func(r *handler) {
 		r.context = fn
 	}
 }
 
+// WithSampler only logs the entries of the requests that are sampled by fn
+func WithSampler(fn SampleFn) Option {
+	return func(r *handler) {
+		r.sample = fn
+	}
+}
+
+// WithObserver calls fn after every request, before the entry is sampled
+func WithObserver(fn ObserveFn) Option {
+	return func(r *handler) {
+		r.observe = fn
+	}
+}
+
+// WithEntryHandler calls fn before the log entry of the request is written
+func WithEntryHandler(fn EntryFn) Option {
+	return func(r *handler) {
+		r.entry = fn
+	}
+}
+
 func WithNoTimeField() Option {
 	return func(r *handler) {
 		r.timeFormat = ""
@@ -105,6 +183,14 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 	h.handler.ServeHTTP(ww, r)
 
+	if h.observe != nil {
+		h.observe(r, ww.Status(), time.Since(start))
+	}
+
+	if h.sample != nil && !h.sample(r, ww.Status()) {
+		return
+	}
+
 	end := time.Now()
 	latency := end.Sub(start)
 	if h.utc {
@@ -114,10 +200,12 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	remoteAddr := r.RemoteAddr
 
 	if h.ipAnonymizationConfig != nil && h.ipAnonymizationConfig.Enabled {
-		if h.ipAnonymizationConfig.Method == Hash {
-			h := sha256.New()
-			remoteAddr = fmt.Sprintf("%d", h.Sum([]byte(r.RemoteAddr)))
-		} else if h.ipAnonymizationConfig.Method == Redact {
+		switch h.ipAnonymizationConfig.Method {
+		case Hash:
+			remoteAddr = h.ipHasher.Hash(r.RemoteAddr)
+		case Truncate:
+			remoteAddr = anonymize.TruncateIP(r.RemoteAddr)
+		case Redact:
 			remoteAddr = "[REDACTED]"
 		}
 	}
@@ -137,11 +225,7 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if h.traceID {
-		span := trace.SpanFromContext(r.Context())
-		spanContext := span.SpanContext()
-		if spanContext.HasTraceID() {
-			fields = append(fields, zap.String("traceID", spanContext.TraceID().String()))
-		}
+		fields = h.appendTraceFields(fields, trace.SpanFromContext(r.Context()).SpanContext())
 	}
 
 	if len(h.fields) > 0 {
@@ -152,6 +236,88 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		fields = append(fields, h.context(r)...)
 	}
 
+	fields = mapSemConvFields(h.semConvStability, fields)
+
+	if h.entry != nil {
+		extra, drop := h.entry(r, fields)
+		if drop {
+			return
+		}
+		fields = append(fields, extra...)
+	}
+
+	if h.pseudonymizer != nil {
+		h.pseudonymize(fields)
+	}
+
 	h.logger.Info(path, fields...)
 
 }
+
+func (h *handler) appendTraceFields(fields []zapcore.Field, spanContext trace.SpanContext) []zapcore.Field {
+	if !spanContext.HasTraceID() {
+		return fields
+	}
+	if !h.traceContext {
+		return append(fields, zap.String("traceID", spanContext.TraceID().String()))
+	}
+
+	sampled := spanContext.IsSampled()
+	if !sampled && !h.logUnsampled {
+		return fields
+	}
+
+	return append(fields,
+		zap.String("traceID", spanContext.TraceID().String()),
+		zap.String("traceparent", fmt.Sprintf("00-%s-%s-%s", spanContext.TraceID(), spanContext.SpanID(), spanContext.TraceFlags())),
+		zap.Bool("trace_sampled", sampled),
+	)
+}
+
+func (h *handler) pseudonymize(fields []zapcore.Field) {
+	for i, field := range fields {
+		if _, ok := h.pseudonymizedFields[field.Key]; !ok {
+			continue
+		}
+		value := field.String
+		if field.Type != zapcore.StringType {
+			enc := zapcore.NewMapObjectEncoder()
+			field.AddTo(enc)
+			value = fmt.Sprint(enc.Fields[field.Key])
+		}
+		fields[i] = zap.String(field.Key, h.pseudonymizer.Hash(value))
+	}
+}
+
+// semConvFieldNames maps the fields of the access log to the attribute names of the semantic conventions
+var semConvFieldNames = map[string]string{
+	"status":     "http.response.status_code",
+	"method":     "http.request.method",
+	"path":       "url.path",
+	"query":      "url.query",
+	"ip":         "client.address",
+	"user-agent": "user_agent.original",
+	"traceID":    "trace_id",
+}
+
+func mapSemConvFields(stability rotel.SemConvStability, fields []zapcore.Field) []zapcore.Field {
+	if stability == rotel.SemConvLegacy {
+		return fields
+	}
+
+	for i, n := 0, len(fields); i < n; i++ {
+		name, ok := semConvFieldNames[fields[i].Key]
+		if !ok {
+			continue
+		}
+		if stability == rotel.SemConvDuplicate {
+			field := fields[i]
+			field.Key = name
+			fields = append(fields, field)
+			continue
+		}
+		fields[i].Key = name
+	}
+
+	return fields
+}
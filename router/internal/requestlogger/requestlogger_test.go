@@ -5,13 +5,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"github.com/wundergraph/cosmo/router/internal/anonymize"
 	"github.com/wundergraph/cosmo/router/internal/test"
 	"github.com/wundergraph/cosmo/router/pkg/logging"
+	rotel "github.com/wundergraph/cosmo/router/pkg/otel"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestRequestLogger(t *testing.T) {
@@ -47,3 +52,176 @@ func TestRequestLogger(t *testing.T) {
 	assert.Equal(t, "/subdir/asdf", data["path"])
 
 }
+
+func TestRequestLoggerSemConvFields(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	encoder := logging.ZapJsonEncoder()
+	writer := bufio.NewWriter(&buffer)
+
+	logger := zap.New(
+		zapcore.NewCore(encoder, zapcore.AddSync(writer), zapcore.DebugLevel))
+
+	handler := New(logger, WithSemConvStability(rotel.SemConvStable))
+	handlerFunc := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	handler(handlerFunc).ServeHTTP(rec, test.NewRequest(http.MethodGet, "/subdir/asdf"))
+
+	writer.Flush()
+
+	var data map[string]interface{}
+	err := json.Unmarshal(buffer.Bytes(), &data)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "GET", data["http.request.method"])
+	assert.Equal(t, float64(200), data["http.response.status_code"])
+	assert.Equal(t, "/subdir/asdf", data["url.path"])
+	assert.NotContains(t, data, "status")
+	assert.NotContains(t, data, "method")
+}
+
+func TestRequestLoggerEntryHandler(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	encoder := logging.ZapJsonEncoder()
+	writer := bufio.NewWriter(&buffer)
+
+	logger := zap.New(
+		zapcore.NewCore(encoder, zapcore.AddSync(writer), zapcore.DebugLevel))
+
+	handler := New(logger, WithEntryHandler(func(r *http.Request, fields []zapcore.Field) ([]zapcore.Field, bool) {
+		if r.URL.Path == "/health" {
+			return nil, true
+		}
+		return []zapcore.Field{zap.String("tenant", r.Header.Get("X-Tenant"))}, false
+	}))
+	handlerFunc := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler(handlerFunc).ServeHTTP(httptest.NewRecorder(), test.NewRequest(http.MethodGet, "/health"))
+
+	req := test.NewRequest(http.MethodGet, "/graphql")
+	req.Header.Set("X-Tenant", "acme")
+	handler(handlerFunc).ServeHTTP(httptest.NewRecorder(), req)
+
+	writer.Flush()
+
+	var data map[string]interface{}
+	err := json.Unmarshal(buffer.Bytes(), &data)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "/graphql", data["path"])
+	assert.Equal(t, "acme", data["tenant"])
+}
+
+func TestRequestLoggerSampler(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	encoder := logging.ZapJsonEncoder()
+	writer := bufio.NewWriter(&buffer)
+
+	logger := zap.New(
+		zapcore.NewCore(encoder, zapcore.AddSync(writer), zapcore.DebugLevel))
+
+	// The observer is called for the requests that aren't sampled as well
+	var observed []int
+	handler := New(logger, WithSampler(func(r *http.Request, status int) bool {
+		return status >= http.StatusInternalServerError
+	}), WithObserver(func(r *http.Request, status int, latency time.Duration) {
+		observed = append(observed, status)
+	}))
+	handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(httptest.NewRecorder(), test.NewRequest(http.MethodGet, "/ok"))
+	handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})).ServeHTTP(httptest.NewRecorder(), test.NewRequest(http.MethodGet, "/failed"))
+
+	writer.Flush()
+
+	var data map[string]interface{}
+	err := json.Unmarshal(buffer.Bytes(), &data)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "/failed", data["path"])
+	assert.Equal(t, float64(http.StatusBadGateway), data["status"])
+	assert.Equal(t, []int{http.StatusOK, http.StatusBadGateway}, observed)
+}
+
+func TestRequestLoggerTraceContext(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+
+	logRequest := func(logUnsampled bool, flags trace.TraceFlags) map[string]interface{} {
+		logCore, logs := observer.New(zapcore.InfoLevel)
+		handler := New(zap.New(logCore), WithDefaultOptions(), WithTraceContext(logUnsampled))
+
+		req := test.NewRequest(http.MethodGet, "/graphql")
+		req = req.WithContext(trace.ContextWithSpanContext(req.Context(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: flags,
+		})))
+		handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+		assert.Equal(t, 1, logs.Len())
+		return logs.All()[0].ContextMap()
+	}
+
+	fields := logRequest(false, trace.FlagsSampled)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["traceID"])
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", fields["traceparent"])
+	assert.Equal(t, true, fields["trace_sampled"])
+
+	// The traces of unsampled requests are not exported
+	fields = logRequest(false, 0)
+	assert.NotContains(t, fields, "traceID")
+	assert.NotContains(t, fields, "traceparent")
+
+	fields = logRequest(true, 0)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["traceID"])
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", fields["traceparent"])
+	assert.Equal(t, false, fields["trace_sampled"])
+}
+
+func TestRequestLoggerAnonymization(t *testing.T) {
+
+	var buffer bytes.Buffer
+
+	encoder := logging.ZapJsonEncoder()
+	writer := bufio.NewWriter(&buffer)
+
+	logger := zap.New(
+		zapcore.NewCore(encoder, zapcore.AddSync(writer), zapcore.DebugLevel))
+
+	hasher := anonymize.NewHasher(0)
+	handler := New(logger,
+		WithAnonymization(&IPAnonymizationConfig{Enabled: true, Method: Truncate}),
+		WithFields(zap.String("user_id", "user-1"), zap.Int("tenant", 42)),
+		WithPseudonymization(hasher, "user_id", "tenant"),
+	)
+	handlerFunc := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := test.NewRequest(http.MethodGet, "/graphql")
+	req.RemoteAddr = "192.168.1.42:51234"
+	handler(handlerFunc).ServeHTTP(httptest.NewRecorder(), req)
+
+	writer.Flush()
+
+	var data map[string]interface{}
+	err := json.Unmarshal(buffer.Bytes(), &data)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "192.168.1.0", data["ip"])
+	assert.Equal(t, hasher.Hash("user-1"), data["user_id"])
+	assert.Equal(t, hasher.Hash("42"), data["tenant"])
+}
@@ -0,0 +1,189 @@
+// Package useragent classifies the User-Agent header of requests by device class, browser family and OS family, e.g.
+// to break the traffic down by platform without enriching the access logs downstream.
+package useragent
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+
+	FamilyOther = "Other"
+)
+
+// maxCachedLength is the length up to which the user agents are cached. Longer values are parsed on every request,
+// so that the size of the cache stays bounded.
+const maxCachedLength = 512
+
+// Info is the classification of a user agent
+type Info struct {
+	DeviceClass   string
+	BrowserFamily string
+	OSFamily      string
+}
+
+// family is a pattern of a family. The patterns are matched in order, so more specific ones come first, e.g. Edge
+// before Chrome, whose token the user agent of Edge contains as well.
+type family struct {
+	token string
+	name  string
+}
+
+var browserFamilies = []family{
+	{token: "Edg/", name: "Edge"},
+	{token: "EdgA/", name: "Edge"},
+	{token: "EdgiOS/", name: "Edge"},
+	{token: "Edge/", name: "Edge"},
+	{token: "OPR/", name: "Opera"},
+	{token: "Opera", name: "Opera"},
+	{token: "SamsungBrowser/", name: "Samsung Internet"},
+	{token: "FxiOS/", name: "Firefox"},
+	{token: "Firefox/", name: "Firefox"},
+	{token: "CriOS/", name: "Chrome"},
+	{token: "Chromium/", name: "Chromium"},
+	{token: "Chrome/", name: "Chrome"},
+	{token: "Version/", name: "Safari"},
+	{token: "curl/", name: "curl"},
+	{token: "Wget/", name: "Wget"},
+	{token: "okhttp/", name: "OkHttp"},
+	{token: "Go-http-client/", name: "Go"},
+	{token: "python-requests/", name: "Python Requests"},
+	{token: "PostmanRuntime/", name: "Postman"},
+	{token: "node-fetch", name: "Node.js"},
+	{token: "axios/", name: "Node.js"},
+}
+
+var osFamilies = []family{
+	{token: "Windows", name: "Windows"},
+	// The user agents of iOS contain "like Mac OS X"
+	{token: "iPhone", name: "iOS"},
+	{token: "iPad", name: "iOS"},
+	{token: "iPod", name: "iOS"},
+	// The user agents of Android contain "Linux"
+	{token: "Android", name: "Android"},
+	{token: "CrOS", name: "Chrome OS"},
+	{token: "Mac OS X", name: "macOS"},
+	{token: "Macintosh", name: "macOS"},
+	{token: "Linux", name: "Linux"},
+}
+
+// botTokens are matched case-insensitively
+var botTokens = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "headless"}
+
+// Parse classifies the user agent. Unknown user agents are of the device class "other" and the family "Other".
+func Parse(userAgent string) Info {
+	info := Info{
+		DeviceClass:   DeviceOther,
+		BrowserFamily: match(userAgent, browserFamilies),
+		OSFamily:      match(userAgent, osFamilies),
+	}
+
+	// Safari is identified by its version token, which other clients on Apple devices don't send
+	if info.BrowserFamily == "Safari" && !strings.Contains(userAgent, "Safari/") {
+		info.BrowserFamily = FamilyOther
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, token := range botTokens {
+		if strings.Contains(lower, token) {
+			info.DeviceClass = DeviceBot
+			return info
+		}
+	}
+
+	switch {
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet"):
+		info.DeviceClass = DeviceTablet
+	case info.OSFamily == "Android" && !strings.Contains(userAgent, "Mobile"):
+		// Android tablets don't send the Mobile token
+		info.DeviceClass = DeviceTablet
+	case info.OSFamily == "iOS" || info.OSFamily == "Android" || strings.Contains(userAgent, "Mobile"):
+		info.DeviceClass = DeviceMobile
+	case info.OSFamily == "Windows" || info.OSFamily == "macOS" || info.OSFamily == "Linux" || info.OSFamily == "Chrome OS":
+		info.DeviceClass = DeviceDesktop
+	}
+
+	return info
+}
+
+func match(userAgent string, families []family) string {
+	for _, f := range families {
+		if strings.Contains(userAgent, f.token) {
+			return f.name
+		}
+	}
+	return FamilyOther
+}
+
+// Parser parses user agents with a cache of the least recently used user agents, because the user agents of most
+// requests come from a small set of clients
+type Parser struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	// order has the most recently used entry at the front
+	order *list.List
+}
+
+type cacheEntry struct {
+	userAgent string
+	info      Info
+}
+
+// NewParser returns a parser that caches up to capacity user agents. A capacity of zero disables the cache.
+func NewParser(capacity int) *Parser {
+	return &Parser{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Parse classifies the user agent
+func (p *Parser) Parse(userAgent string) Info {
+	if p.capacity <= 0 || len(userAgent) > maxCachedLength {
+		return Parse(userAgent)
+	}
+
+	p.mu.Lock()
+	if element, ok := p.entries[userAgent]; ok {
+		p.order.MoveToFront(element)
+		info := element.Value.(*cacheEntry).info
+		p.mu.Unlock()
+		return info
+	}
+	p.mu.Unlock()
+
+	info := Parse(userAgent)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Another request may have added the user agent in the meantime
+	if element, ok := p.entries[userAgent]; ok {
+		p.order.MoveToFront(element)
+		return info
+	}
+	if p.order.Len() >= p.capacity {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.entries, oldest.Value.(*cacheEntry).userAgent)
+	}
+	p.entries[userAgent] = p.order.PushFront(&cacheEntry{userAgent: userAgent, info: info})
+
+	return info
+}
+
+// Len returns the number of cached user agents
+func (p *Parser) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.order.Len()
+}
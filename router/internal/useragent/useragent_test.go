@@ -0,0 +1,96 @@
+package useragent
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		userAgent string
+		expected  Info
+	}{
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			expected:  Info{DeviceClass: DeviceDesktop, BrowserFamily: "Chrome", OSFamily: "Windows"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			expected:  Info{DeviceClass: DeviceDesktop, BrowserFamily: "Edge", OSFamily: "Windows"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+			expected:  Info{DeviceClass: DeviceDesktop, BrowserFamily: "Safari", OSFamily: "macOS"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			expected:  Info{DeviceClass: DeviceDesktop, BrowserFamily: "Firefox", OSFamily: "Linux"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			expected:  Info{DeviceClass: DeviceMobile, BrowserFamily: "Safari", OSFamily: "iOS"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1",
+			expected:  Info{DeviceClass: DeviceTablet, BrowserFamily: "Chrome", OSFamily: "iOS"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36",
+			expected:  Info{DeviceClass: DeviceMobile, BrowserFamily: "Chrome", OSFamily: "Android"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Safari/537.36",
+			expected:  Info{DeviceClass: DeviceTablet, BrowserFamily: "Samsung Internet", OSFamily: "Android"},
+		},
+		{
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected:  Info{DeviceClass: DeviceBot, BrowserFamily: FamilyOther, OSFamily: FamilyOther},
+		},
+		{
+			userAgent: "curl/8.4.0",
+			expected:  Info{DeviceClass: DeviceOther, BrowserFamily: "curl", OSFamily: FamilyOther},
+		},
+		{
+			userAgent: "okhttp/4.12.0",
+			expected:  Info{DeviceClass: DeviceOther, BrowserFamily: "OkHttp", OSFamily: FamilyOther},
+		},
+		{
+			userAgent: "",
+			expected:  Info{DeviceClass: DeviceOther, BrowserFamily: FamilyOther, OSFamily: FamilyOther},
+		},
+	} {
+		require.Equal(t, tc.expected, Parse(tc.userAgent), tc.userAgent)
+	}
+}
+
+func TestParserCache(t *testing.T) {
+	t.Parallel()
+
+	p := NewParser(2)
+
+	require.Equal(t, "curl", p.Parse("curl/1").BrowserFamily)
+	require.Equal(t, "Wget", p.Parse("Wget/1").BrowserFamily)
+	require.Equal(t, 2, p.Len())
+
+	// The least recently used user agent is evicted
+	p.Parse("curl/1")
+	p.Parse("okhttp/1")
+	require.Equal(t, 2, p.Len())
+	require.Contains(t, p.entries, "curl/1")
+	require.Contains(t, p.entries, "okhttp/1")
+	require.NotContains(t, p.entries, "Wget/1")
+
+	for i := 0; i < 10; i++ {
+		p.Parse("curl/" + strconv.Itoa(i))
+	}
+	require.Equal(t, 2, p.Len())
+
+	// Without a cache, the user agents are parsed on every call
+	uncached := NewParser(0)
+	require.Equal(t, "curl", uncached.Parse("curl/1").BrowserFamily)
+	require.Equal(t, 0, uncached.Len())
+}
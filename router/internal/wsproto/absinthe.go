@@ -107,7 +107,7 @@ func (p *absintheWSProtocol) Subprotocol() string {
 	return GraphQLWSSubprotocol
 }
 
-func (p *absintheWSProtocol) Initialize() (json.RawMessage, error) {
+func (p *absintheWSProtocol) Initialize(accept func(initialPayload json.RawMessage) error) (json.RawMessage, error) {
 	var msg absintheMessage
 	if err := p.conn.ReadJSON(&msg); err != nil {
 		return nil, fmt.Errorf("error reading phx_join: %w", err)
@@ -115,6 +115,11 @@ func (p *absintheWSProtocol) Initialize() (json.RawMessage, error) {
 	if msg.Type != absintheMessageEventTypeJoin {
 		return nil, fmt.Errorf("first message should be %s, got %s", absintheMessageEventTypeJoin, msg.Type)
 	}
+	if accept != nil {
+		if err := accept(msg.Payload); err != nil {
+			return nil, err
+		}
+	}
 	if err := p.conn.WriteJSON(absintheMessage{
 		ID:       msg.ID,
 		Channel:  msg.Channel,
@@ -18,6 +18,8 @@ const (
 	graphQLWSMessageTypeNext           = graphQLWSMessageType("next")
 	graphQLWSMessageTypeError          = graphQLWSMessageType("error")
 	graphQLWSMessageTypeComplete       = graphQLWSMessageType("complete")
+	// graphQLWSMessageTypeReauthenticate isn't part of the protocol. It refreshes the token of a long-lived connection.
+	graphQLWSMessageTypeReauthenticate = graphQLWSMessageType("reauthenticate")
 
 	// This might seem confusing, but the protocol is called graphql-ws and uses "graphql-transport-ws" as subprotocol
 	GraphQLWSSubprotocol = "graphql-transport-ws"
@@ -46,7 +48,7 @@ func (p *graphQLWSProtocol) Subprotocol() string {
 	return GraphQLWSSubprotocol
 }
 
-func (p *graphQLWSProtocol) Initialize() (json.RawMessage, error) {
+func (p *graphQLWSProtocol) Initialize(accept func(initialPayload json.RawMessage) error) (json.RawMessage, error) {
 	// First message must be a connection_init
 	var msg graphQLWSMessage
 	if err := p.conn.ReadJSON(&msg); err != nil {
@@ -55,6 +57,11 @@ func (p *graphQLWSProtocol) Initialize() (json.RawMessage, error) {
 	if msg.Type != graphQLWSMessageTypeConnectionInit {
 		return nil, fmt.Errorf("first message should be %s, got %s", graphQLWSMessageTypeConnectionInit, msg.Type)
 	}
+	if accept != nil {
+		if err := accept(msg.Payload); err != nil {
+			return nil, err
+		}
+	}
 	if err := p.conn.WriteJSON(graphQLWSMessage{Type: graphQLWSMessageTypeConnectionAck}); err != nil {
 		return nil, fmt.Errorf("sending %s: %w", graphQLWSMessageTypeConnectionAck, err)
 	}
@@ -76,6 +83,8 @@ func (p *graphQLWSProtocol) ReadMessage() (*Message, error) {
 		messageType = MessageTypeSubscribe
 	case graphQLWSMessageTypeComplete:
 		messageType = MessageTypeComplete
+	case graphQLWSMessageTypeReauthenticate:
+		messageType = MessageTypeReauthenticate
 	default:
 		return nil, fmt.Errorf("unsupported message type %s", msg.Type)
 	}
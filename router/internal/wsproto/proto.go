@@ -8,8 +8,9 @@ import (
 
 type Proto interface {
 	Subprotocol() string
-	// Initialize starts the protocol and returns the initial payload received from the client
-	Initialize() (json.RawMessage, error)
+	// Initialize starts the protocol and returns the initial payload received from the client. The connection is only
+	// acknowledged when accept, if not nil, returns no error for the initial payload.
+	Initialize(accept func(initialPayload json.RawMessage) error) (json.RawMessage, error)
 	ReadMessage() (*Message, error)
 
 	Pong(*Message) error
@@ -33,6 +34,8 @@ const (
 	MessageTypeSubscribe
 	MessageTypeComplete
 	MessageTypeTerminate
+	// MessageTypeReauthenticate refreshes the authentication of the connection with the token in its payload
+	MessageTypeReauthenticate
 )
 
 type Message struct {
@@ -22,6 +22,9 @@ const (
 	subscriptionsTransportWSMessageTypeData                = subscriptionsTransportWSMessageType("data")
 	subscriptionsTransportWSMessageTypeError               = subscriptionsTransportWSMessageType("error")
 	subscriptionsTransportWSMessageTypeComplete            = subscriptionsTransportWSMessageType("complete")
+	// subscriptionsTransportWSMessageTypeReauthenticate isn't part of the protocol. It refreshes the token of a
+	// long-lived connection.
+	subscriptionsTransportWSMessageTypeReauthenticate = subscriptionsTransportWSMessageType("reauthenticate")
 
 	// Again, this is not a typo. Somehow they managed to give each protocol name to the other's subprotocol identifier.
 	SubscriptionsTransportWSSubprotocol = "graphql-ws"
@@ -50,7 +53,7 @@ func (p *subscriptionsTransportWSProtocol) Subprotocol() string {
 	return SubscriptionsTransportWSSubprotocol
 }
 
-func (p *subscriptionsTransportWSProtocol) Initialize() (json.RawMessage, error) {
+func (p *subscriptionsTransportWSProtocol) Initialize(accept func(initialPayload json.RawMessage) error) (json.RawMessage, error) {
 	// First message must be a connection_init
 	var msg subscriptionsTransportWSMessage
 	if err := p.conn.ReadJSON(&msg); err != nil {
@@ -59,6 +62,11 @@ func (p *subscriptionsTransportWSProtocol) Initialize() (json.RawMessage, error)
 	if msg.Type != subscriptionsTransportWSMessageTypeConnectionInit {
 		return nil, fmt.Errorf("first message should be %s, got %s", subscriptionsTransportWSMessageTypeConnectionInit, msg.Type)
 	}
+	if accept != nil {
+		if err := accept(msg.Payload); err != nil {
+			return nil, err
+		}
+	}
 	if err := p.conn.WriteJSON(subscriptionsTransportWSMessage{Type: subscriptionsTransportWSMessageTypeConnectionAck}); err != nil {
 		return nil, fmt.Errorf("sending %s: %w", subscriptionsTransportWSMessageTypeConnectionAck, err)
 	}
@@ -78,6 +86,8 @@ func (p *subscriptionsTransportWSProtocol) ReadMessage() (*Message, error) {
 		messageType = MessageTypeSubscribe
 	case subscriptionsTransportWSMessageTypeStop:
 		messageType = MessageTypeComplete
+	case subscriptionsTransportWSMessageTypeReauthenticate:
+		messageType = MessageTypeReauthenticate
 	default:
 		return nil, fmt.Errorf("unsupported message type %s", msg.Type)
 	}
@@ -17,3 +17,15 @@ func AuthenticateHTTPRequest(ctx context.Context, authenticators []Authenticator
 	provider := (*httpRequestProvider)(r)
 	return Authenticate(ctx, authenticators, provider)
 }
+
+type headerProvider http.Header
+
+func (h headerProvider) AuthenticationHeaders() http.Header {
+	return http.Header(h)
+}
+
+// AuthenticateHeader is a convenience function that calls Authenticate when the authentication information is
+// provided outside of the headers of a request, e.g. by the initial payload of a WebSocket connection
+func AuthenticateHeader(ctx context.Context, authenticators []Authenticator, header http.Header) (Authentication, error) {
+	return Authenticate(ctx, authenticators, headerProvider(header))
+}
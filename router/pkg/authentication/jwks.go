@@ -2,13 +2,19 @@ package authentication
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/MicahParks/keyfunc/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
 )
 
 const (
@@ -16,13 +22,84 @@ const (
 	defaultHeaderValuePrefix = "Bearer"
 )
 
+// UnavailablePolicy is the behavior of an authenticator while the endpoint of its keys is unreachable
+type UnavailablePolicy string
+
+const (
+	// UnavailablePolicyCachedKeys validates the tokens with the keys of the last successful fetch. The authenticator
+	// can't be created while the endpoint is unreachable.
+	UnavailablePolicyCachedKeys UnavailablePolicy = "cached_keys"
+	// UnavailablePolicyReject rejects all tokens, failing closed
+	UnavailablePolicyReject UnavailablePolicy = "reject"
+	// UnavailablePolicyAllow validates the tokens with the cached keys and accepts the tokens of unknown keys without
+	// verifying their signature, failing open. Their expiration is still checked.
+	UnavailablePolicyAllow UnavailablePolicy = "allow"
+)
+
+// UnavailableDecision is the outcome of a token authenticated while the endpoint of the keys is unreachable
+type UnavailableDecision string
+
+const (
+	UnavailableDecisionRejected UnavailableDecision = "rejected"
+	// UnavailableDecisionCachedKeys is a token validated with the cached keys, regardless of the outcome
+	UnavailableDecisionCachedKeys UnavailableDecision = "cached_keys"
+	// UnavailableDecisionUnverified is a token accepted without verifying its signature
+	UnavailableDecisionUnverified UnavailableDecision = "unverified"
+)
+
+// UnavailableDecisionCounter is implemented by the authenticators that count their decisions while the endpoint of
+// their keys is unreachable
+type UnavailableDecisionCounter interface {
+	Name() string
+	Policy() UnavailablePolicy
+	// UnavailableDecisions returns the number of tokens by decision
+	UnavailableDecisions() map[UnavailableDecision]int64
+}
+
+// JWKSCache is implemented by the authenticators that cache the keys of a JWKS endpoint
+type JWKSCache interface {
+	Name() string
+	JWKSStatus() JWKSStatus
+	// RefreshJWKS fetches the keys immediately, e.g. after an emergency rotation of the keys
+	RefreshJWKS(ctx context.Context) error
+}
+
+// JWKSStatus describes the cached keys of an authenticator and their refreshes
+type JWKSStatus struct {
+	Authenticator string   `json:"authenticator"`
+	URL           string   `json:"url"`
+	Available     bool     `json:"available"`
+	KIDs          []string `json:"kids"`
+	// LastRefresh is the time of the last successful fetch of the keys
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	// NextRefresh is the time of the next scheduled fetch of the keys
+	NextRefresh         *time.Time `json:"next_refresh,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
 type jwksAuthenticator struct {
 	// JSON Web Key Set, automatically updated in the background
 	// by keyfunc.
 	jwks                *keyfunc.JWKS
 	name                string
+	url                 string
+	refreshInterval     time.Duration
 	headerNames         []string
 	headerValuePrefixes []string
+	policy              UnavailablePolicy
+	logger              *zap.Logger
+	// unavailable is true while the last fetch of the keys failed
+	unavailable atomic.Bool
+
+	mu        sync.Mutex
+	decisions map[UnavailableDecision]int64
+
+	refreshMu           sync.Mutex
+	lastRefresh         time.Time
+	lastAttempt         time.Time
+	consecutiveFailures int
+	lastError           error
 }
 
 func (a *jwksAuthenticator) Name() string {
@@ -37,19 +114,210 @@ func (a *jwksAuthenticator) Authenticate(ctx context.Context, p Provider) (Claim
 		for _, prefix := range a.headerValuePrefixes {
 			if strings.HasPrefix(authorization, prefix) {
 				tokenString := strings.TrimSpace(authorization[len(prefix):])
-				token, err := jwt.Parse(tokenString, a.jwks.Keyfunc)
+				claims, err := a.parse(tokenString)
 				if err != nil {
 					errs = errors.Join(errs, fmt.Errorf("could not validate token: %w", err))
 					continue
 				}
-				claims := token.Claims.(jwt.MapClaims)
-				return Claims(claims), nil
+				return claims, nil
 			}
 		}
 	}
 	return nil, errs
 }
 
+func (a *jwksAuthenticator) parse(tokenString string) (Claims, error) {
+	if !a.unavailable.Load() {
+		token, err := jwt.Parse(tokenString, a.jwks.Keyfunc)
+		if err != nil {
+			return nil, err
+		}
+		return Claims(token.Claims.(jwt.MapClaims)), nil
+	}
+
+	switch a.policy {
+	case UnavailablePolicyReject:
+		a.count(UnavailableDecisionRejected)
+		return nil, errJWKSUnavailable
+	case UnavailablePolicyAllow:
+		token, err := jwt.Parse(tokenString, a.jwks.Keyfunc)
+		if err == nil {
+			a.count(UnavailableDecisionCachedKeys)
+			return Claims(token.Claims.(jwt.MapClaims)), nil
+		}
+		// Only the tokens signed with keys that couldn't be fetched are accepted, invalid signatures are not
+		if !errors.Is(err, keyfunc.ErrKIDNotFound) {
+			a.count(UnavailableDecisionCachedKeys)
+			return nil, err
+		}
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+			a.count(UnavailableDecisionRejected)
+			return nil, err
+		}
+		if err := validateTimeClaims(claims, time.Now()); err != nil {
+			a.count(UnavailableDecisionRejected)
+			return nil, err
+		}
+		a.count(UnavailableDecisionUnverified)
+		return Claims(claims), nil
+	default:
+		a.count(UnavailableDecisionCachedKeys)
+		token, err := jwt.Parse(tokenString, a.jwks.Keyfunc)
+		if err != nil {
+			return nil, err
+		}
+		return Claims(token.Claims.(jwt.MapClaims)), nil
+	}
+}
+
+var errJWKSUnavailable = errors.New("the JWKS endpoint is unavailable")
+
+// validateTimeClaims checks the expiration and the not before time of the claims, which jwt.Parse does for the verified
+// tokens
+func validateTimeClaims(claims jwt.MapClaims, now time.Time) error {
+	exp, err := claims.GetExpirationTime()
+	if err != nil {
+		return err
+	}
+	if exp != nil && !now.Before(exp.Time) {
+		return jwt.ErrTokenExpired
+	}
+	nbf, err := claims.GetNotBefore()
+	if err != nil {
+		return err
+	}
+	if nbf != nil && now.Before(nbf.Time) {
+		return jwt.ErrTokenNotValidYet
+	}
+	return nil
+}
+
+func (a *jwksAuthenticator) count(decision UnavailableDecision) {
+	a.mu.Lock()
+	a.decisions[decision]++
+	a.mu.Unlock()
+}
+
+func (a *jwksAuthenticator) Policy() UnavailablePolicy {
+	return a.policy
+}
+
+func (a *jwksAuthenticator) UnavailableDecisions() map[UnavailableDecision]int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	decisions := make(map[UnavailableDecision]int64, len(a.decisions))
+	for decision, count := range a.decisions {
+		decisions[decision] = count
+	}
+	return decisions
+}
+
+func (a *jwksAuthenticator) JWKSStatus() JWKSStatus {
+	status := JWKSStatus{
+		Authenticator: a.name,
+		URL:           a.url,
+		Available:     !a.unavailable.Load(),
+		KIDs:          []string{},
+	}
+	if a.jwks != nil {
+		status.KIDs = a.jwks.KIDs()
+		slices.Sort(status.KIDs)
+	}
+
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+
+	if !a.lastRefresh.IsZero() {
+		lastRefresh := a.lastRefresh
+		status.LastRefresh = &lastRefresh
+	}
+	if a.refreshInterval > 0 && !a.lastAttempt.IsZero() {
+		nextRefresh := a.lastAttempt.Add(a.refreshInterval)
+		status.NextRefresh = &nextRefresh
+	}
+	status.ConsecutiveFailures = a.consecutiveFailures
+	if a.lastError != nil {
+		status.LastError = a.lastError.Error()
+	}
+	return status
+}
+
+func (a *jwksAuthenticator) RefreshJWKS(ctx context.Context) error {
+	start := time.Now()
+	// The background refresh reports its errors to the error handler only, without it they are returned
+	if err := a.jwks.Refresh(ctx, keyfunc.RefreshOptions{IgnoreRateLimit: true}); err != nil {
+		if a.refreshInterval == 0 {
+			a.refreshFailed(err)
+		}
+		return err
+	}
+
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+
+	if a.lastError != nil && !a.lastAttempt.Before(start) {
+		return a.lastError
+	}
+	return nil
+}
+
+// refreshed records a successful fetch of the keys
+func (a *jwksAuthenticator) refreshed() {
+	a.refreshMu.Lock()
+	a.lastRefresh = time.Now()
+	a.lastAttempt = a.lastRefresh
+	a.consecutiveFailures = 0
+	a.lastError = nil
+	a.refreshMu.Unlock()
+
+	a.setUnavailable(false)
+}
+
+// refreshFailed records a failed fetch of the keys. The failures after the first one are logged with the time of the
+// next attempt, since keyfunc retries at the refresh interval.
+func (a *jwksAuthenticator) refreshFailed(err error) {
+	a.refreshMu.Lock()
+	a.lastAttempt = time.Now()
+	a.consecutiveFailures++
+	a.lastError = err
+	failures := a.consecutiveFailures
+	a.refreshMu.Unlock()
+
+	fields := []zap.Field{zap.Error(err), zap.Int("consecutive_failures", failures)}
+	if a.refreshInterval > 0 {
+		fields = append(fields, zap.Duration("retry_in", a.refreshInterval))
+	}
+
+	if !a.setUnavailable(true, fields...) {
+		a.logger.Warn("Failed to refresh the JWKS, the endpoint is still unavailable", fields...)
+	}
+}
+
+// setUnavailable records the availability of the keys and logs its changes with the policy that applies. It
+// returns true if the availability changed.
+func (a *jwksAuthenticator) setUnavailable(unavailable bool, fields ...zap.Field) bool {
+	if a.unavailable.Swap(unavailable) == unavailable {
+		return false
+	}
+	if !unavailable {
+		a.logger.Info("JWKS endpoint is available again, tokens are verified")
+		return true
+	}
+
+	fields = append([]zap.Field{zap.String("policy", string(a.policy))}, fields...)
+	switch a.policy {
+	case UnavailablePolicyReject:
+		a.logger.Error("JWKS endpoint is unavailable, all tokens are rejected", fields...)
+	case UnavailablePolicyAllow:
+		a.logger.Warn("JWKS endpoint is unavailable, tokens of unknown keys are accepted without verifying their signature", fields...)
+	default:
+		a.logger.Warn("JWKS endpoint is unavailable, tokens are verified with the cached keys", fields...)
+	}
+	return true
+}
+
 // JWKSAuthenticatorOptions contains the available options for the JWKS authenticator
 type JWKSAuthenticatorOptions struct {
 	// Name is the authenticator name. It cannot be empty.
@@ -65,6 +333,11 @@ type JWKSAuthenticatorOptions struct {
 	// RefreshInterval is the minimum time interval between two JWKS refreshes. It
 	// defaults to 1 minute.
 	RefreshInterval time.Duration
+	// UnavailablePolicy is the behavior while the JWKS endpoint is unreachable. It defaults to
+	// UnavailablePolicyCachedKeys.
+	UnavailablePolicy UnavailablePolicy
+	// Logger logs the changes of the availability of the JWKS endpoint. Optional.
+	Logger *zap.Logger
 }
 
 // NewJWKSAuthenticator returns a JWKS based authenticator. See JWKSAuthenticatorOptions
@@ -73,8 +346,43 @@ func NewJWKSAuthenticator(opts JWKSAuthenticatorOptions) (Authenticator, error)
 	if opts.Name == "" {
 		return nil, fmt.Errorf("authenticator Name must be provided")
 	}
+
+	policy := opts.UnavailablePolicy
+	switch policy {
+	case "":
+		policy = UnavailablePolicyCachedKeys
+	case UnavailablePolicyCachedKeys, UnavailablePolicyReject, UnavailablePolicyAllow:
+	default:
+		return nil, fmt.Errorf("unknown unavailable policy '%s', must be cached_keys, reject or allow", policy)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	a := &jwksAuthenticator{
+		name:            opts.Name,
+		url:             opts.URL,
+		refreshInterval: opts.RefreshInterval,
+		policy:          policy,
+		logger:          logger.With(zap.String("authenticator", opts.Name), zap.String("url", opts.URL)),
+		decisions:       map[UnavailableDecision]int64{},
+	}
+
 	jwks, err := keyfunc.Get(opts.URL, keyfunc.Options{
 		RefreshInterval: opts.RefreshInterval,
+		RefreshErrorHandler: func(err error) {
+			a.refreshFailed(err)
+		},
+		ResponseExtractor: func(ctx context.Context, resp *http.Response) (json.RawMessage, error) {
+			data, err := keyfunc.ResponseExtractorStatusOK(ctx, resp)
+			if err == nil {
+				a.refreshed()
+			}
+			return data, err
+		},
+		// The policies that don't depend on cached keys apply from the start
+		TolerateInitialJWKHTTPError: policy != UnavailablePolicyCachedKeys,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error initializing JWKS from %q: %w", opts.URL, err)
@@ -89,10 +397,9 @@ func NewJWKSAuthenticator(opts JWKSAuthenticatorOptions) (Authenticator, error)
 		headerValuePrefixes = []string{defaultHeaderValuePrefix}
 	}
 
-	return &jwksAuthenticator{
-		jwks:                jwks,
-		name:                opts.Name,
-		headerNames:         headerNames,
-		headerValuePrefixes: headerValuePrefixes,
-	}, nil
+	a.jwks = jwks
+	a.headerNames = headerNames
+	a.headerValuePrefixes = headerValuePrefixes
+
+	return a, nil
 }
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/goccy/go-yaml"
@@ -101,12 +104,46 @@ type OtelAttribute struct {
 	ValueFrom *OtelAttributeFromValue `yaml:"value_from,omitempty"`
 }
 
+type Profiling struct {
+	Enabled      bool              `yaml:"enabled" default:"false" envconfig:"PROFILING_ENABLED"`
+	Exporter     string            `yaml:"exporter" default:"pyroscope" envconfig:"PROFILING_EXPORTER"`
+	Endpoint     string            `yaml:"endpoint,omitempty" envconfig:"PROFILING_ENDPOINT"`
+	Interval     time.Duration     `yaml:"interval" default:"15s" envconfig:"PROFILING_INTERVAL"`
+	ProfileTypes []string          `yaml:"profile_types" default:"cpu,heap,goroutine" envconfig:"PROFILING_PROFILE_TYPES"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
+}
+
+// Logs exports the router logs with the OpenTelemetry protocol, in addition to stdout and the log files
+type Logs struct {
+	Enabled  bool                `yaml:"enabled" default:"false" envconfig:"TELEMETRY_LOGS_ENABLED"`
+	Exporter otelconfig.Exporter `yaml:"exporter" default:"http" envconfig:"TELEMETRY_LOGS_EXPORTER"`
+	Endpoint string              `yaml:"endpoint,omitempty" envconfig:"TELEMETRY_LOGS_ENDPOINT"`
+	HTTPPath string              `yaml:"path" default:"/v1/logs" envconfig:"TELEMETRY_LOGS_PATH"`
+	Headers  map[string]string   `yaml:"headers,omitempty"`
+	// BatchSize is the maximum number of log records of an export
+	BatchSize int `yaml:"batch_size" default:"512" envconfig:"TELEMETRY_LOGS_BATCH_SIZE"`
+	// QueueSize is the maximum number of log records that wait for the export. Further records are dropped.
+	QueueSize     int           `yaml:"queue_size" default:"2048" envconfig:"TELEMETRY_LOGS_QUEUE_SIZE"`
+	BatchTimeout  time.Duration `yaml:"batch_timeout" default:"5s" envconfig:"TELEMETRY_LOGS_BATCH_TIMEOUT"`
+	ExportTimeout time.Duration `yaml:"export_timeout" default:"30s" envconfig:"TELEMETRY_LOGS_EXPORT_TIMEOUT"`
+}
+
 type Telemetry struct {
 	ServiceName        string                  `yaml:"service_name" default:"cosmo-router" envconfig:"TELEMETRY_SERVICE_NAME"`
 	Attributes         []OtelAttribute         `yaml:"attributes"`
 	ResourceAttributes []OtelResourceAttribute `yaml:"resource_attributes"`
 	Tracing            Tracing                 `yaml:"tracing"`
 	Metrics            Metrics                 `yaml:"metrics"`
+	Logs               Logs                    `yaml:"logs"`
+	Profiling          Profiling               `yaml:"profiling"`
+	// SemanticConventions renames the HTTP and GraphQL attributes to the current OpenTelemetry semantic conventions
+	SemanticConventions SemanticConventions `yaml:"semantic_conventions"`
+}
+
+type SemanticConventions struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"TELEMETRY_SEMANTIC_CONVENTIONS_ENABLED"`
+	// StabilityOptIn is "http" to emit only the current attribute names or "http/dup" to emit the legacy ones as well
+	StabilityOptIn string `yaml:"stability_opt_in" default:"http/dup" envconfig:"OTEL_SEMCONV_STABILITY_OPT_IN"`
 }
 
 type CORS struct {
@@ -133,6 +170,35 @@ type FileUpload struct {
 type RouterTrafficConfiguration struct {
 	// MaxRequestBodyBytes is the maximum size of the request body in bytes
 	MaxRequestBodyBytes BytesString `yaml:"max_request_body_size" default:"5MB"`
+	// ConfigSwap controls how requests are queued while the router swaps its execution config
+	ConfigSwap ConfigSwapConfiguration `yaml:"config_swap"`
+}
+
+// ServerConfiguration hardens the HTTP server of the GraphQL listener against slow or oversized requests
+type ServerConfiguration struct {
+	// MaxHeaderBytes is the maximum size of the request line and the request headers
+	MaxHeaderBytes BytesString `yaml:"max_header_bytes" default:"1MB" envconfig:"SERVER_MAX_HEADER_BYTES"`
+	// ReadTimeout is the maximum duration for reading the entire request, including the body
+	ReadTimeout time.Duration `yaml:"read_timeout" default:"1m" envconfig:"SERVER_READ_TIMEOUT"`
+	// ReadHeaderTimeout is the maximum duration for reading the request headers. It protects against slowloris attacks.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" default:"20s" envconfig:"SERVER_READ_HEADER_TIMEOUT"`
+	// WriteTimeout is the maximum duration before timing out writes of the response
+	WriteTimeout time.Duration `yaml:"write_timeout" default:"2m" envconfig:"SERVER_WRITE_TIMEOUT"`
+	// IdleTimeout is the maximum duration to wait for the next request on a keep-alive connection
+	IdleTimeout time.Duration `yaml:"idle_timeout" default:"90s" envconfig:"SERVER_IDLE_TIMEOUT"`
+	// MaxConcurrentStreams is the maximum number of concurrent HTTP/2 streams per connection
+	MaxConcurrentStreams uint32 `yaml:"max_concurrent_streams" default:"250" envconfig:"SERVER_MAX_CONCURRENT_STREAMS"`
+	// MaxConnections is the maximum number of open client connections. Zero means unlimited.
+	MaxConnections int `yaml:"max_connections" default:"0" envconfig:"SERVER_MAX_CONNECTIONS"`
+	// MaxConnectionsPerIP is the maximum number of open connections per client IP. Zero means unlimited.
+	MaxConnectionsPerIP int `yaml:"max_connections_per_ip" default:"0" envconfig:"SERVER_MAX_CONNECTIONS_PER_IP"`
+}
+
+type ConfigSwapConfiguration struct {
+	// MaxQueuedRequests is the maximum number of requests that are queued during a config swap
+	MaxQueuedRequests int `yaml:"max_queued_requests" default:"1000"`
+	// QueueTimeout is the maximum time a request is queued before it is rejected
+	QueueTimeout time.Duration `yaml:"queue_timeout" default:"5s"`
 }
 
 type GlobalSubgraphRequestRule struct {
@@ -206,24 +272,104 @@ type EngineDebugConfiguration struct {
 }
 
 type EngineExecutionConfiguration struct {
-	Debug                                  EngineDebugConfiguration `yaml:"debug"`
-	EnableSingleFlight                     bool                     `default:"true" envconfig:"ENGINE_ENABLE_SINGLE_FLIGHT" yaml:"enable_single_flight"`
-	EnableRequestTracing                   bool                     `default:"true" envconfig:"ENGINE_ENABLE_REQUEST_TRACING" yaml:"enable_request_tracing"`
-	EnableExecutionPlanCacheResponseHeader bool                     `default:"false" envconfig:"ENGINE_ENABLE_EXECUTION_PLAN_CACHE_RESPONSE_HEADER" yaml:"enable_execution_plan_cache_response_header"`
-	MaxConcurrentResolvers                 int                      `default:"1024" envconfig:"ENGINE_MAX_CONCURRENT_RESOLVERS" yaml:"max_concurrent_resolvers,omitempty"`
-	EnableWebSocketEpollKqueue             bool                     `default:"true" envconfig:"ENGINE_ENABLE_WEBSOCKET_EPOLL_KQUEUE" yaml:"enable_websocket_epoll_kqueue"`
-	EpollKqueuePollTimeout                 time.Duration            `default:"1s" envconfig:"ENGINE_EPOLL_KQUEUE_POLL_TIMEOUT" yaml:"epoll_kqueue_poll_timeout,omitempty"`
-	EpollKqueueConnBufferSize              int                      `default:"128" envconfig:"ENGINE_EPOLL_KQUEUE_CONN_BUFFER_SIZE" yaml:"epoll_kqueue_conn_buffer_size,omitempty"`
-	WebSocketReadTimeout                   time.Duration            `default:"5s" envconfig:"ENGINE_WEBSOCKET_READ_TIMEOUT" yaml:"websocket_read_timeout,omitempty"`
-	ExecutionPlanCacheSize                 int64                    `default:"10000" envconfig:"ENGINE_EXECUTION_PLAN_CACHE_SIZE" yaml:"execution_plan_cache_size,omitempty"`
-	MinifySubgraphOperations               bool                     `default:"false" envconfig:"ENGINE_MINIFY_SUBGRAPH_OPERATIONS" yaml:"minify_subgraph_operations"`
-	EnablePersistedOperationsCache         bool                     `default:"true" envconfig:"ENGINE_ENABLE_PERSISTED_OPERATIONS_CACHE" yaml:"enable_persisted_operations_cache"`
+	Debug                                  EngineDebugConfiguration       `yaml:"debug"`
+	EnableSingleFlight                     bool                           `default:"true" envconfig:"ENGINE_ENABLE_SINGLE_FLIGHT" yaml:"enable_single_flight"`
+	EnableRequestTracing                   bool                           `default:"true" envconfig:"ENGINE_ENABLE_REQUEST_TRACING" yaml:"enable_request_tracing"`
+	EnableExecutionPlanCacheResponseHeader bool                           `default:"false" envconfig:"ENGINE_ENABLE_EXECUTION_PLAN_CACHE_RESPONSE_HEADER" yaml:"enable_execution_plan_cache_response_header"`
+	MaxConcurrentResolvers                 int                            `default:"1024" envconfig:"ENGINE_MAX_CONCURRENT_RESOLVERS" yaml:"max_concurrent_resolvers,omitempty"`
+	EnableWebSocketEpollKqueue             bool                           `default:"true" envconfig:"ENGINE_ENABLE_WEBSOCKET_EPOLL_KQUEUE" yaml:"enable_websocket_epoll_kqueue"`
+	EpollKqueuePollTimeout                 time.Duration                  `default:"1s" envconfig:"ENGINE_EPOLL_KQUEUE_POLL_TIMEOUT" yaml:"epoll_kqueue_poll_timeout,omitempty"`
+	EpollKqueueConnBufferSize              int                            `default:"128" envconfig:"ENGINE_EPOLL_KQUEUE_CONN_BUFFER_SIZE" yaml:"epoll_kqueue_conn_buffer_size,omitempty"`
+	WebSocketReadTimeout                   time.Duration                  `default:"5s" envconfig:"ENGINE_WEBSOCKET_READ_TIMEOUT" yaml:"websocket_read_timeout,omitempty"`
+	ExecutionPlanCacheSize                 int64                          `default:"10000" envconfig:"ENGINE_EXECUTION_PLAN_CACHE_SIZE" yaml:"execution_plan_cache_size,omitempty"`
+	MinifySubgraphOperations               bool                           `default:"false" envconfig:"ENGINE_MINIFY_SUBGRAPH_OPERATIONS" yaml:"minify_subgraph_operations"`
+	EnablePersistedOperationsCache         bool                           `default:"true" envconfig:"ENGINE_ENABLE_PERSISTED_OPERATIONS_CACHE" yaml:"enable_persisted_operations_cache"`
+	ResponseStreaming                      ResponseStreamingConfiguration `yaml:"response_streaming"`
+	FetchCoalescing                        FetchCoalescingConfiguration   `yaml:"fetch_coalescing"`
+}
+
+type ResponseStreamingConfiguration struct {
+	// Enabled writes large responses to the client while they are assembled instead of buffering them entirely
+	Enabled bool `default:"false" envconfig:"ENGINE_RESPONSE_STREAMING_ENABLED" yaml:"enabled"`
+	// FlushThreshold is the amount of buffered response data that is written to the client at once
+	FlushThreshold BytesString `default:"64KB" envconfig:"ENGINE_RESPONSE_STREAMING_FLUSH_THRESHOLD" yaml:"flush_threshold"`
+}
+
+// FetchCoalescingConfiguration delays identical subgraph fetches of different requests for a short window, so that
+// they are sent once and share the response
+type FetchCoalescingConfiguration struct {
+	Enabled bool          `default:"false" envconfig:"ENGINE_FETCH_COALESCING_ENABLED" yaml:"enabled"`
+	Window  time.Duration `default:"2ms" envconfig:"ENGINE_FETCH_COALESCING_WINDOW" yaml:"window"`
 }
 
 type SecurityConfiguration struct {
-	BlockMutations              bool `yaml:"block_mutations" default:"false" envconfig:"SECURITY_BLOCK_MUTATIONS"`
-	BlockSubscriptions          bool `yaml:"block_subscriptions" default:"false" envconfig:"SECURITY_BLOCK_SUBSCRIPTIONS"`
-	BlockNonPersistedOperations bool `yaml:"block_non_persisted_operations" default:"false" envconfig:"SECURITY_BLOCK_NON_PERSISTED_OPERATIONS"`
+	BlockMutations              bool                    `yaml:"block_mutations" default:"false" envconfig:"SECURITY_BLOCK_MUTATIONS"`
+	BlockSubscriptions          bool                    `yaml:"block_subscriptions" default:"false" envconfig:"SECURITY_BLOCK_SUBSCRIPTIONS"`
+	BlockNonPersistedOperations bool                    `yaml:"block_non_persisted_operations" default:"false" envconfig:"SECURITY_BLOCK_NON_PERSISTED_OPERATIONS"`
+	JSONLimits                  JSONLimitsConfiguration `yaml:"json_limits"`
+	Introspection               IntrospectionSecurity   `yaml:"introspection"`
+	// OperationRules allow or deny operations before they are planned. The first matching rule decides.
+	OperationRules               []OperationRule                           `yaml:"operation_rules,omitempty"`
+	PersistedOperationKillSwitch PersistedOperationKillSwitchConfiguration `yaml:"persisted_operation_kill_switch"`
+	FieldBlocking                FieldBlockingConfiguration                `yaml:"field_blocking,omitempty"`
+}
+
+// FieldBlockingConfiguration blocks or masks fields of the schema by their coordinate. The file is reloaded when it
+// changes and fields can be blocked at runtime with the admin API.
+type FieldBlockingConfiguration struct {
+	Enabled bool           `yaml:"enabled" default:"false" envconfig:"SECURITY_FIELD_BLOCKING_ENABLED"`
+	Fields  []BlockedField `yaml:"fields,omitempty"`
+	// File contains one coordinate per line, optionally followed by the action. Empty lines and lines starting with #
+	// are ignored.
+	File           string        `yaml:"file,omitempty" envconfig:"SECURITY_FIELD_BLOCKING_FILE"`
+	ReloadInterval time.Duration `yaml:"reload_interval" default:"10s" envconfig:"SECURITY_FIELD_BLOCKING_RELOAD_INTERVAL"`
+}
+
+type BlockedField struct {
+	// Coordinate is the schema coordinate of the field, e.g. Employee.salary
+	Coordinate string `yaml:"coordinate"`
+	// Action is "block" to reject the operations that select the field or "mask" to replace its values with null.
+	// Empty blocks the field.
+	Action string `yaml:"action,omitempty"`
+}
+
+// PersistedOperationKillSwitchConfiguration blocks persisted operations by their hash. The file is reloaded when it
+// changes and hashes can be blocked at runtime with the admin API.
+type PersistedOperationKillSwitchConfiguration struct {
+	Hashes []string `yaml:"hashes,omitempty" envconfig:"SECURITY_PERSISTED_OPERATION_KILL_SWITCH_HASHES"`
+	// File contains one hash per line. Empty lines and lines starting with # are ignored.
+	File           string        `yaml:"file,omitempty" envconfig:"SECURITY_PERSISTED_OPERATION_KILL_SWITCH_FILE"`
+	ReloadInterval time.Duration `yaml:"reload_interval" default:"10s" envconfig:"SECURITY_PERSISTED_OPERATION_KILL_SWITCH_RELOAD_INTERVAL"`
+	StatusCode     int           `yaml:"status_code" default:"200" envconfig:"SECURITY_PERSISTED_OPERATION_KILL_SWITCH_STATUS_CODE"`
+	Message        string        `yaml:"message" default:"persisted operation is blocked by the kill switch" envconfig:"SECURITY_PERSISTED_OPERATION_KILL_SWITCH_MESSAGE"`
+}
+
+// OperationRule matches operations by name, type and client. All configured conditions must match.
+type OperationRule struct {
+	// Action is either "allow" or "deny"
+	Action string `yaml:"action"`
+	// Name is a glob pattern of the operation name, e.g. "Admin*"
+	Name string `yaml:"name,omitempty"`
+	// NameRegex is a regular expression of the operation name. It is mutually exclusive with Name.
+	NameRegex string   `yaml:"name_regex,omitempty"`
+	Type      string   `yaml:"type,omitempty"`
+	Clients   []string `yaml:"clients,omitempty"`
+}
+
+// IntrospectionSecurity blocks introspection queries of some clients. Introspection queries are always counted
+// and logged per client.
+type IntrospectionSecurity struct {
+	BlockUnauthenticated bool `yaml:"block_unauthenticated" default:"false" envconfig:"SECURITY_INTROSPECTION_BLOCK_UNAUTHENTICATED"`
+	BlockUnknownClients  bool `yaml:"block_unknown_clients" default:"false" envconfig:"SECURITY_INTROSPECTION_BLOCK_UNKNOWN_CLIENTS"`
+	// KnownClients are the client names allowed to introspect. When empty, clients without a name are unknown.
+	KnownClients []string `yaml:"known_clients,omitempty" envconfig:"SECURITY_INTROSPECTION_KNOWN_CLIENTS"`
+}
+
+// JSONLimitsConfiguration limits the structure of JSON request bodies. A value of zero disables the limit.
+type JSONLimitsConfiguration struct {
+	MaxDepth        int `yaml:"max_depth" default:"64" envconfig:"SECURITY_JSON_MAX_DEPTH"`
+	MaxKeys         int `yaml:"max_keys" default:"0" envconfig:"SECURITY_JSON_MAX_KEYS"`
+	MaxStringLength int `yaml:"max_string_length" default:"0" envconfig:"SECURITY_JSON_MAX_STRING_LENGTH"`
 }
 
 type OverrideRoutingURLConfiguration struct {
@@ -246,6 +392,8 @@ type AuthenticationProviderJWKS struct {
 	HeaderNames         []string      `yaml:"header_names"`
 	HeaderValuePrefixes []string      `yaml:"header_value_prefixes"`
 	RefreshInterval     time.Duration `yaml:"refresh_interval" default:"1m"`
+	// UnavailablePolicy is cached_keys, reject or allow. If empty, the tokens are verified with the cached keys.
+	UnavailablePolicy string `yaml:"unavailable_policy,omitempty"`
 }
 
 type AuthenticationProvider struct {
@@ -270,6 +418,43 @@ type RateLimitConfiguration struct {
 	Storage        RedisConfiguration      `yaml:"storage"`
 	// Debug ensures that retryAfter and resetAfter are set to stable values for testing
 	Debug bool `yaml:"debug" default:"false" envconfig:"RATE_LIMIT_DEBUG"`
+	// KeyBy gives every client its own quota. One of client_name, claim or tag. By default, all requests share one quota.
+	KeyBy string `yaml:"key_by,omitempty" envconfig:"RATE_LIMIT_KEY_BY"`
+	// KeyClaim is the claim of the authenticated request that identifies the client with key_by claim
+	KeyClaim string `yaml:"key_claim,omitempty" default:"sub" envconfig:"RATE_LIMIT_KEY_CLAIM"`
+	// KeyTag is the request tag that identifies the client with key_by tag
+	KeyTag string `yaml:"key_tag,omitempty" envconfig:"RATE_LIMIT_KEY_TAG"`
+	// QuotaEndpoint lets the clients look up their remaining quota
+	QuotaEndpoint RateLimitQuotaEndpointConfiguration `yaml:"quota_endpoint,omitempty"`
+	// Replication shares the quotas with the cluster peers instead of storing them in Redis
+	Replication RateLimitReplicationConfiguration `yaml:"replication,omitempty"`
+	// ResponseHeaders adds the RateLimit headers of the quota to the responses and Retry-After to limited responses
+	ResponseHeaders bool `yaml:"response_headers" default:"false" envconfig:"RATE_LIMIT_RESPONSE_HEADERS"`
+	// UsageQuotas are daily or monthly budgets of the keys in addition to the rate. They require the Redis storage.
+	UsageQuotas []RateLimitUsageQuota `yaml:"usage_quotas,omitempty"`
+}
+
+type RateLimitUsageQuota struct {
+	// Name identifies the quota in the errors and the admin API
+	Name string `yaml:"name"`
+	// Period is day or month. The periods start at midnight UTC.
+	Period string `yaml:"period"`
+	// Limit is the number of units a key can consume in a period
+	Limit int64 `yaml:"limit"`
+	// Unit is requests, or fetches to count the subgraph fetches of the requests as their cost. Defaults to requests.
+	Unit string `yaml:"unit,omitempty"`
+}
+
+type RateLimitReplicationConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"RATE_LIMIT_REPLICATION_ENABLED"`
+	// SyncInterval is the period in which the consumed quotas are sent to the peers. The cluster can exceed the
+	// limit by the requests of one interval.
+	SyncInterval time.Duration `yaml:"sync_interval" default:"250ms" envconfig:"RATE_LIMIT_REPLICATION_SYNC_INTERVAL"`
+}
+
+type RateLimitQuotaEndpointConfiguration struct {
+	Enabled bool   `yaml:"enabled" default:"false" envconfig:"RATE_LIMIT_QUOTA_ENDPOINT_ENABLED"`
+	Path    string `yaml:"path" default:"/quota" envconfig:"RATE_LIMIT_QUOTA_ENDPOINT_PATH"`
 }
 
 type RedisConfiguration struct {
@@ -282,6 +467,8 @@ type RateLimitSimpleStrategy struct {
 	Burst                   int           `yaml:"burst" default:"10" envconfig:"RATE_LIMIT_SIMPLE_BURST"`
 	Period                  time.Duration `yaml:"period" default:"1s" envconfig:"RATE_LIMIT_SIMPLE_PERIOD"`
 	RejectExceedingRequests bool          `yaml:"reject_exceeding_requests" default:"false" envconfig:"RATE_LIMIT_SIMPLE_REJECT_EXCEEDING_REQUESTS"`
+	// Algorithm is token_bucket or sliding_window. The sliding window doesn't use the burst and requires Redis.
+	Algorithm string `yaml:"algorithm" default:"token_bucket" envconfig:"RATE_LIMIT_SIMPLE_ALGORITHM"`
 }
 
 type CDNConfiguration struct {
@@ -327,6 +514,682 @@ type KafkaEventSource struct {
 	Brokers        []string               `yaml:"brokers,omitempty"`
 	Authentication *KafkaAuthentication   `yaml:"authentication,omitempty"`
 	TLS            *KafkaTLSConfiguration `yaml:"tls,omitempty"`
+	// DurableSubscriptions resume the subscriptions from the last delivered event after a restart of the router
+	DurableSubscriptions KafkaDurableSubscriptionsConfiguration `yaml:"durable_subscriptions,omitempty"`
+}
+
+// KafkaDurableSubscriptionsConfiguration stores the positions of the subscriptions in Kafka consumer groups
+type KafkaDurableSubscriptionsConfiguration struct {
+	Enabled bool `yaml:"enabled"`
+	// ConsumerGroup is the prefix of the consumer groups of the subscriptions. It must be unique per router instance
+	// and stable across its restarts.
+	ConsumerGroup string `yaml:"consumer_group,omitempty"`
+}
+
+type LogEscalationConfiguration struct {
+	// Enabled keeps the entries below the log level per request and writes them when the request fails
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_ESCALATION_ENABLED"`
+	// BufferSize is the maximum number of entries that are kept per request. Older entries are discarded.
+	BufferSize int `yaml:"buffer_size" default:"100" envconfig:"LOG_ESCALATION_BUFFER_SIZE"`
+}
+
+type PanicRecoveryConfiguration struct {
+	// CrashDumpDir is the directory a crash dump file is written to for every panic of a request
+	CrashDumpDir string `yaml:"crash_dump_dir,omitempty" envconfig:"PANIC_RECOVERY_CRASH_DUMP_DIR"`
+	// Exit terminates the router after a panic was logged instead of recovering the request
+	Exit bool `yaml:"exit" default:"false" envconfig:"PANIC_RECOVERY_EXIT"`
+}
+
+type LogRetentionConfiguration struct {
+	// Enabled deletes log files after the retention period
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_RETENTION_ENABLED"`
+	// Paths are glob patterns of the log files. Compressed files of a pattern (<pattern>.gz) are included.
+	Paths []string `yaml:"paths,omitempty" envconfig:"LOG_RETENTION_PATHS"`
+	// MaxAge is the retention period of a file, counted from its last modification
+	MaxAge time.Duration `yaml:"max_age" default:"720h" envconfig:"LOG_RETENTION_MAX_AGE"`
+	// CompressAfter compresses files with gzip that were not modified within the period. Zero disables the compression.
+	CompressAfter time.Duration `yaml:"compress_after" default:"0s" envconfig:"LOG_RETENTION_COMPRESS_AFTER"`
+	Interval      time.Duration `yaml:"interval" default:"1h" envconfig:"LOG_RETENTION_INTERVAL"`
+}
+
+type LogFilesConfiguration struct {
+	// Enabled writes the log entries to rotated files by the name of their logger
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_FILES_ENABLED"`
+	// Default is the file of the loggers without a dedicated file. Without a path, their entries are written to stdout.
+	Default LogFileConfiguration         `yaml:"default,omitempty"`
+	Loggers []LoggerLogFileConfiguration `yaml:"loggers,omitempty"`
+}
+
+type LogFileConfiguration struct {
+	Path string `yaml:"path,omitempty" envconfig:"LOG_FILES_DEFAULT_PATH"`
+	// Encoding is an encoding like of log_encoding, e.g. the compact binary "msgpack". If empty, the entries are
+	// encoded like on stdout.
+	Encoding string `yaml:"encoding,omitempty" envconfig:"LOG_FILES_DEFAULT_ENCODING"`
+	// Level is the minimum level of the entries in the file. If empty, the file receives all entries of log_level.
+	Level string `yaml:"level,omitempty" envconfig:"LOG_FILES_DEFAULT_LEVEL"`
+	// MaxSize is the size after which the file is rotated
+	MaxSize BytesString `yaml:"max_size" default:"100MB" envconfig:"LOG_FILES_DEFAULT_MAX_SIZE"`
+	// MaxBackups is the number of rotated files that are kept. Zero keeps all files.
+	MaxBackups int `yaml:"max_backups" default:"0" envconfig:"LOG_FILES_DEFAULT_MAX_BACKUPS"`
+	// MaxAge deletes rotated files that are older. It is rounded up to days. Zero keeps the files.
+	MaxAge   time.Duration `yaml:"max_age" default:"0s" envconfig:"LOG_FILES_DEFAULT_MAX_AGE"`
+	Compress bool          `yaml:"compress" default:"false" envconfig:"LOG_FILES_DEFAULT_COMPRESS"`
+	// LocalTime uses the local time instead of UTC in the names of the rotated files
+	LocalTime bool `yaml:"local_time" default:"false" envconfig:"LOG_FILES_DEFAULT_LOCAL_TIME"`
+}
+
+// LoggerLogFileConfiguration writes the entries of the logger with the name and of its children to a dedicated file
+type LoggerLogFileConfiguration struct {
+	Name                 string `yaml:"name"`
+	LogFileConfiguration `yaml:",inline"`
+}
+
+// LogLevelOutput writes the entries within a range of levels to a standard stream or to a rotated file
+type LogLevelOutput struct {
+	// MinLevel and MaxLevel limit the levels of the entries, both inclusive. If empty, the range is open.
+	MinLevel string `yaml:"min_level,omitempty"`
+	MaxLevel string `yaml:"max_level,omitempty"`
+	// Stream is stdout or stderr. If empty and without a path, the entries are written to log_output.
+	Stream string `yaml:"stream,omitempty"`
+	// The path of the file replaces the stream
+	LogFileConfiguration `yaml:",inline"`
+}
+
+type LogSamplingConfiguration struct {
+	// Enabled samples the entries with the same level and message, so that a burst doesn't drown out other entries
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_SAMPLING_ENABLED"`
+	// Tick is the interval in which the entries are counted
+	Tick time.Duration `yaml:"tick" default:"1s" envconfig:"LOG_SAMPLING_TICK"`
+	// Initial is the number of entries that are written per tick, of the rest every Thereafter-th entry is written
+	Initial    int `yaml:"initial" default:"100" envconfig:"LOG_SAMPLING_INITIAL"`
+	Thereafter int `yaml:"thereafter" default:"100" envconfig:"LOG_SAMPLING_THEREAFTER"`
+	// Levels are the sampled levels
+	Levels []string `yaml:"levels" default:"warning,error" envconfig:"LOG_SAMPLING_LEVELS"`
+}
+
+type LogDeduplicationConfiguration struct {
+	// Enabled writes the entries with the same level, logger and message once per window and summarizes the
+	// suppressed duplicates
+	Enabled bool          `yaml:"enabled" default:"false" envconfig:"LOG_DEDUPLICATION_ENABLED"`
+	Window  time.Duration `yaml:"window" default:"10s" envconfig:"LOG_DEDUPLICATION_WINDOW"`
+	// MaxKeys limits the number of distinct entries that are tracked
+	MaxKeys int `yaml:"max_keys" default:"10000" envconfig:"LOG_DEDUPLICATION_MAX_KEYS"`
+	// Levels are the deduplicated levels
+	Levels []string `yaml:"levels" default:"warning,error" envconfig:"LOG_DEDUPLICATION_LEVELS"`
+}
+
+type LogBufferingConfiguration struct {
+	// Enabled buffers the log output to stdout. The buffer is flushed when it is full, after the flush interval and
+	// after every entry with the level warning or higher.
+	Enabled       bool          `yaml:"enabled" default:"false" envconfig:"LOG_BUFFERING_ENABLED"`
+	Size          BytesString   `yaml:"size" default:"256KB" envconfig:"LOG_BUFFERING_SIZE"`
+	FlushInterval time.Duration `yaml:"flush_interval" default:"1s" envconfig:"LOG_BUFFERING_FLUSH_INTERVAL"`
+}
+
+type LogAsyncConfiguration struct {
+	// Enabled writes the logs to the standard output in the background, so that a slow output doesn't stall the
+	// requests
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_ASYNC_ENABLED"`
+	// Size is the number of entries that are buffered
+	Size          int           `yaml:"size" default:"8192" envconfig:"LOG_ASYNC_SIZE"`
+	FlushInterval time.Duration `yaml:"flush_interval" default:"1s" envconfig:"LOG_ASYNC_FLUSH_INTERVAL"`
+	// Policy is drop_oldest or block. It applies when the buffer is full.
+	Policy string `yaml:"policy" default:"drop_oldest" envconfig:"LOG_ASYNC_POLICY"`
+}
+
+// LogShutdownFlushConfiguration flushes the pending entries of the log outputs, the access logs and the log sinks on
+// shutdown, so that the entries of the last seconds before the exit aren't lost
+type LogShutdownFlushConfiguration struct {
+	// Timeout is the deadline of the flush. It starts after the graceful shutdown of the router.
+	Timeout time.Duration `yaml:"timeout" default:"10s" envconfig:"LOG_SHUTDOWN_FLUSH_TIMEOUT"`
+	// Marker writes the "Logs flushed" entry with the result of the flush as the last entry of the outputs
+	Marker bool `yaml:"marker" default:"true" envconfig:"LOG_SHUTDOWN_FLUSH_MARKER"`
+}
+
+type LogRedactionConfiguration struct {
+	// Enabled redacts the sensitive values of the log entries with the rules before they are written to any output,
+	// including the access logs
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_REDACTION_ENABLED"`
+	// Replacement replaces the masked values
+	Replacement string             `yaml:"replacement" default:"[REDACTED]" envconfig:"LOG_REDACTION_REPLACEMENT"`
+	Rules       []LogRedactionRule `yaml:"rules,omitempty"`
+	// DefaultRules applies the built-in rules for credentials like the authorization header, cookies, API keys and
+	// passwords in addition to the rules. auto applies them unless the log level is debug or the development mode is
+	// enabled, enabled and disabled apply them always or never.
+	DefaultRules string `yaml:"default_rules" default:"auto" envconfig:"LOG_REDACTION_DEFAULT_RULES"`
+	// ExcludeDefaultFields are the fields of the built-in rules that are not redacted
+	ExcludeDefaultFields []string `yaml:"exclude_default_fields,omitempty" envconfig:"LOG_REDACTION_EXCLUDE_DEFAULT_FIELDS"`
+}
+
+// LogRedactionRule redacts a field, the values at a path inside the JSON of a field, or the matches of a pattern
+type LogRedactionRule struct {
+	Field   string `yaml:"field,omitempty"`
+	Path    string `yaml:"path,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"`
+	// Action is mask or hash. If empty, the values are masked.
+	Action string `yaml:"action,omitempty"`
+}
+
+type LogFiltersConfiguration struct {
+	// Rules drop, change the level of or add fields to the matching entries of the logs of the router. They are
+	// applied in order and can be replaced at runtime with the admin API.
+	Rules []LogFilterRule `yaml:"rules,omitempty"`
+}
+
+// LogFilterRule matches the entries by the name of their logger, a pattern of their message or the value of a field
+type LogFilterRule struct {
+	Logger  string `yaml:"logger,omitempty"`
+	Message string `yaml:"message,omitempty"`
+	Field   string `yaml:"field,omitempty"`
+	Value   string `yaml:"value,omitempty"`
+	// Action is drop, level or fields
+	Action string            `yaml:"action"`
+	Level  string            `yaml:"level,omitempty"`
+	Fields map[string]string `yaml:"fields,omitempty"`
+}
+
+type VariableRedactionConfiguration struct {
+	// Enabled redacts the values of the variables of all operations in the access logs and the traces, except the
+	// allowed variables of the operations
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"VARIABLE_REDACTION_ENABLED"`
+	// Replacement replaces the redacted values
+	Replacement string                       `yaml:"replacement" default:"[REDACTED]" envconfig:"VARIABLE_REDACTION_REPLACEMENT"`
+	Operations  []VariableAllowlistOperation `yaml:"operations,omitempty"`
+}
+
+// VariableAllowlistOperation allows the variables of the operation with the name to be logged and traced in
+// plaintext. The variable "*" allows all variables.
+type VariableAllowlistOperation struct {
+	Name      string   `yaml:"name"`
+	Variables []string `yaml:"variables"`
+}
+
+// LogSinksConfiguration writes the logs of the router to journald, a remote syslog server, a Kafka topic or a NATS
+// subject in addition to its other outputs
+type LogSinksConfiguration struct {
+	Journald JournaldLogSinkConfiguration `yaml:"journald,omitempty"`
+	Syslog   SyslogLogSinkConfiguration   `yaml:"syslog,omitempty"`
+	Kafka    KafkaLogSinkConfiguration    `yaml:"kafka,omitempty"`
+	Nats     NatsLogSinkConfiguration     `yaml:"nats,omitempty"`
+}
+
+type JournaldLogSinkConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_SINKS_JOURNALD_ENABLED"`
+	// Identifier is the SYSLOG_IDENTIFIER of the entries
+	Identifier string `yaml:"identifier" default:"router" envconfig:"LOG_SINKS_JOURNALD_IDENTIFIER"`
+	// Encoding is an encoding like of log_encoding. If empty, the entries are encoded as JSON.
+	Encoding string `yaml:"encoding,omitempty" envconfig:"LOG_SINKS_JOURNALD_ENCODING"`
+}
+
+type SyslogLogSinkConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOG_SINKS_SYSLOG_ENABLED"`
+	// Network is "tcp", "tls" or "udp"
+	Network string `yaml:"network" default:"tcp" envconfig:"LOG_SINKS_SYSLOG_NETWORK"`
+	// Address is the host and port of the syslog server
+	Address  string `yaml:"address,omitempty" envconfig:"LOG_SINKS_SYSLOG_ADDRESS"`
+	Facility string `yaml:"facility" default:"local0" envconfig:"LOG_SINKS_SYSLOG_FACILITY"`
+	AppName  string `yaml:"app_name" default:"router" envconfig:"LOG_SINKS_SYSLOG_APP_NAME"`
+	// Encoding is an encoding like of log_encoding. If empty, the entries are encoded as JSON.
+	Encoding string `yaml:"encoding,omitempty" envconfig:"LOG_SINKS_SYSLOG_ENCODING"`
+	// Timeout limits connecting and writing
+	Timeout time.Duration          `yaml:"timeout" default:"5s" envconfig:"LOG_SINKS_SYSLOG_TIMEOUT"`
+	TLS     SyslogTLSConfiguration `yaml:"tls,omitempty"`
+}
+
+type SyslogTLSConfiguration struct {
+	// CAFile verifies the certificate of the server. If empty, the system roots are used.
+	CAFile string `yaml:"ca_file,omitempty" envconfig:"LOG_SINKS_SYSLOG_TLS_CA_FILE"`
+	// CertFile and KeyFile are the client certificate, if the server requires one
+	CertFile string `yaml:"cert_file,omitempty" envconfig:"LOG_SINKS_SYSLOG_TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file,omitempty" envconfig:"LOG_SINKS_SYSLOG_TLS_KEY_FILE"`
+}
+
+type KafkaLogSinkConfiguration struct {
+	Enabled        bool                   `yaml:"enabled" default:"false" envconfig:"LOG_SINKS_KAFKA_ENABLED"`
+	Brokers        []string               `yaml:"brokers,omitempty" envconfig:"LOG_SINKS_KAFKA_BROKERS"`
+	Topic          string                 `yaml:"topic" default:"router-logs" envconfig:"LOG_SINKS_KAFKA_TOPIC"`
+	Authentication *KafkaAuthentication   `yaml:"authentication,omitempty"`
+	TLS            *KafkaTLSConfiguration `yaml:"tls,omitempty"`
+	// Compression is none, gzip, snappy, lz4 or zstd
+	Compression string `yaml:"compression" default:"none" envconfig:"LOG_SINKS_KAFKA_COMPRESSION"`
+	// Encoding is an encoding like of log_encoding. If empty, the entries are encoded as JSON.
+	Encoding string `yaml:"encoding,omitempty" envconfig:"LOG_SINKS_KAFKA_ENCODING"`
+	// BatchSize is the maximum number of entries that are published at once
+	BatchSize     int           `yaml:"batch_size" default:"100" envconfig:"LOG_SINKS_KAFKA_BATCH_SIZE"`
+	FlushInterval time.Duration `yaml:"flush_interval" default:"1s" envconfig:"LOG_SINKS_KAFKA_FLUSH_INTERVAL"`
+	// Timeout limits the publishing of a batch. The entries of a failed batch are written to the log output.
+	Timeout time.Duration `yaml:"timeout" default:"5s" envconfig:"LOG_SINKS_KAFKA_TIMEOUT"`
+}
+
+type NatsLogSinkConfiguration struct {
+	Enabled bool   `yaml:"enabled" default:"false" envconfig:"LOG_SINKS_NATS_ENABLED"`
+	URL     string `yaml:"url,omitempty" envconfig:"LOG_SINKS_NATS_URL"`
+	Subject string `yaml:"subject" default:"router.logs" envconfig:"LOG_SINKS_NATS_SUBJECT"`
+	// Token or Username and Password authenticate with the server
+	Token    string `yaml:"token,omitempty" envconfig:"LOG_SINKS_NATS_TOKEN"`
+	Username string `yaml:"username,omitempty" envconfig:"LOG_SINKS_NATS_USERNAME"`
+	Password string `yaml:"password,omitempty" envconfig:"LOG_SINKS_NATS_PASSWORD"`
+	// Compression is none or gzip
+	Compression string `yaml:"compression" default:"none" envconfig:"LOG_SINKS_NATS_COMPRESSION"`
+	// Encoding is an encoding like of log_encoding. If empty, the entries are encoded as JSON.
+	Encoding string `yaml:"encoding,omitempty" envconfig:"LOG_SINKS_NATS_ENCODING"`
+	// BatchSize is the maximum number of entries that are published as one message
+	BatchSize     int           `yaml:"batch_size" default:"100" envconfig:"LOG_SINKS_NATS_BATCH_SIZE"`
+	FlushInterval time.Duration `yaml:"flush_interval" default:"1s" envconfig:"LOG_SINKS_NATS_FLUSH_INTERVAL"`
+	// Timeout limits the publishing of a batch. The entries of a failed batch are written to the log output.
+	Timeout time.Duration `yaml:"timeout" default:"5s" envconfig:"LOG_SINKS_NATS_TIMEOUT"`
+}
+
+type SLOConfiguration struct {
+	// Enabled computes the availability and latency SLIs of the router and exports the burn rates as metrics
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SLO_ENABLED"`
+	// AvailabilityTarget is the ratio of requests that must not fail, e.g. 0.999
+	AvailabilityTarget float64 `yaml:"availability_target" default:"0.999" envconfig:"SLO_AVAILABILITY_TARGET"`
+	// LatencyTarget is the ratio of requests that must be faster than the LatencyThreshold, e.g. 0.99 for the p99
+	LatencyTarget    float64       `yaml:"latency_target" default:"0.99" envconfig:"SLO_LATENCY_TARGET"`
+	LatencyThreshold time.Duration `yaml:"latency_threshold" default:"500ms" envconfig:"SLO_LATENCY_THRESHOLD"`
+	// Windows are the periods the burn rates are computed for
+	Windows []time.Duration `yaml:"windows" default:"5m,1h,6h" envconfig:"SLO_WINDOWS"`
+	// MaxClients limits the number of clients with their own series per graph. Further clients are aggregated.
+	MaxClients int `yaml:"max_clients" default:"100" envconfig:"SLO_MAX_CLIENTS"`
+}
+
+type TopOperationsConfiguration struct {
+	// Enabled keeps the statistics of the operations of the access log in memory for the top operations of the admin API
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"TOP_OPERATIONS_ENABLED"`
+	// Window is the period the statistics are computed for
+	Window time.Duration `yaml:"window" default:"5m" envconfig:"TOP_OPERATIONS_WINDOW"`
+	// MaxOperations limits the memory of the statistics. The requests of further operations are only counted in total.
+	MaxOperations int `yaml:"max_operations" default:"1000" envconfig:"TOP_OPERATIONS_MAX_OPERATIONS"`
+}
+
+type AnomalyDetectionConfiguration struct {
+	// Enabled detects spikes of error logs and server errors and logs an alert to the "anomaly" logger
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ANOMALY_DETECTION_ENABLED"`
+	// Interval is the period the events are counted in
+	Interval time.Duration `yaml:"interval" default:"1m" envconfig:"ANOMALY_DETECTION_INTERVAL"`
+	// BaselineIntervals is the number of previous intervals the baseline rate is computed from
+	BaselineIntervals int `yaml:"baseline_intervals" default:"10" envconfig:"ANOMALY_DETECTION_BASELINE_INTERVALS"`
+	// Threshold is the factor the rate of an interval must exceed the baseline by to be a spike
+	Threshold float64 `yaml:"threshold" default:"5" envconfig:"ANOMALY_DETECTION_THRESHOLD"`
+	// MinEvents is the minimum number of events in an interval to be a spike
+	MinEvents int64                                `yaml:"min_events" default:"10" envconfig:"ANOMALY_DETECTION_MIN_EVENTS"`
+	Webhook   AnomalyDetectionWebhookConfiguration `yaml:"webhook,omitempty"`
+}
+
+type AnomalyDetectionWebhookConfiguration struct {
+	// URL is called with a POST request and the alert as JSON body. Empty disables the webhook.
+	URL     string        `yaml:"url,omitempty" envconfig:"ANOMALY_DETECTION_WEBHOOK_URL"`
+	Timeout time.Duration `yaml:"timeout" default:"5s" envconfig:"ANOMALY_DETECTION_WEBHOOK_TIMEOUT"`
+}
+
+type LifecycleWebhooksConfiguration struct {
+	// Enabled sends webhooks on the startup, the shutdown and config changes of the router
+	Enabled   bool                       `yaml:"enabled" default:"false" envconfig:"LIFECYCLE_WEBHOOKS_ENABLED"`
+	Timeout   time.Duration              `yaml:"timeout" default:"5s" envconfig:"LIFECYCLE_WEBHOOKS_TIMEOUT"`
+	Endpoints []LifecycleWebhookEndpoint `yaml:"endpoints,omitempty"`
+}
+
+type LifecycleWebhookEndpoint struct {
+	URL string `yaml:"url"`
+	// Format is either "json" or "slack". Empty defaults to "json".
+	Format string `yaml:"format,omitempty"`
+	// Events are the types of events sent to the endpoint. Empty sends all events.
+	Events  []string          `yaml:"events,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+type SubgraphAuthenticationConfiguration struct {
+	// Subgraphs are the credentials sent to the subgraphs, by the name of the subgraph
+	Subgraphs map[string]SubgraphCredentials `yaml:"subgraphs,omitempty"`
+}
+
+// SubgraphCredentials authenticate the requests of the router to a subgraph. Secrets are either set
+// inline, e.g. with an ${ENV} reference, or read from a file with the *_file fields.
+type SubgraphCredentials struct {
+	// Type is one of "bearer", "basic" or "oauth2_client_credentials"
+	Type string `yaml:"type"`
+
+	Token     string `yaml:"token,omitempty"`
+	TokenFile string `yaml:"token_file,omitempty"`
+
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+
+	TokenURL         string            `yaml:"token_url,omitempty"`
+	ClientID         string            `yaml:"client_id,omitempty"`
+	ClientSecret     string            `yaml:"client_secret,omitempty"`
+	ClientSecretFile string            `yaml:"client_secret_file,omitempty"`
+	Scopes           []string          `yaml:"scopes,omitempty"`
+	EndpointParams   map[string]string `yaml:"endpoint_params,omitempty"`
+}
+
+type SubgraphTunnelsConfiguration struct {
+	// Subgraphs are the tunnels of the requests to the subgraphs, by the name of the subgraph
+	Subgraphs map[string]SubgraphTunnel `yaml:"subgraphs,omitempty"`
+}
+
+// SubgraphTunnel connects to a subgraph through a SOCKS5 proxy or an SSH server. The password is either set
+// inline, e.g. with an ${ENV} reference, or read from a file with password_file.
+type SubgraphTunnel struct {
+	// Type is either "socks5" or "ssh"
+	Type string `yaml:"type"`
+	// Address is the host and the port of the SOCKS5 proxy or the SSH server
+	Address string `yaml:"address"`
+
+	Username     string `yaml:"username,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+
+	PrivateKeyFile string `yaml:"private_key_file,omitempty"`
+	KnownHostsFile string `yaml:"known_hosts_file,omitempty"`
+	// InsecureIgnoreHostKey skips the verification of the host key of the SSH server. It must only be used for
+	// development.
+	InsecureIgnoreHostKey bool `yaml:"insecure_ignore_host_key,omitempty"`
+}
+
+type RequestSigningConfiguration struct {
+	// Enabled rejects requests to the GraphQL endpoint without a valid HMAC signature
+	Enabled         bool   `yaml:"enabled" default:"false" envconfig:"REQUEST_SIGNING_ENABLED"`
+	SignatureHeader string `yaml:"signature_header" default:"X-Signature" envconfig:"REQUEST_SIGNING_SIGNATURE_HEADER"`
+	TimestampHeader string `yaml:"timestamp_header" default:"X-Signature-Timestamp" envconfig:"REQUEST_SIGNING_TIMESTAMP_HEADER"`
+	KeyIDHeader     string `yaml:"key_id_header" default:"X-Signature-Key-Id" envconfig:"REQUEST_SIGNING_KEY_ID_HEADER"`
+	// ReplayWindow is the maximum age of a signed request. A signature is only accepted once within the window.
+	ReplayWindow time.Duration       `yaml:"replay_window" default:"5m" envconfig:"REQUEST_SIGNING_REPLAY_WINDOW"`
+	Keys         []RequestSigningKey `yaml:"keys,omitempty"`
+}
+
+type RequestSigningKey struct {
+	ID         string `yaml:"id"`
+	Secret     string `yaml:"secret,omitempty"`
+	SecretFile string `yaml:"secret_file,omitempty"`
+	// Algorithm is the hash function of the HMAC, either "sha256" or "sha512". Empty defaults to "sha256".
+	Algorithm string `yaml:"algorithm,omitempty"`
+}
+
+type BotDetectionConfiguration struct {
+	// Enabled scores requests with heuristics for automated traffic
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"BOT_DETECTION_ENABLED"`
+	// Action is applied to requests with a score of at least the threshold. One of "tag", "rate_limit" or "block".
+	Action    string `yaml:"action" default:"tag" envconfig:"BOT_DETECTION_ACTION"`
+	Threshold int    `yaml:"threshold" default:"50" envconfig:"BOT_DETECTION_THRESHOLD"`
+	// TagHeader is set on the request with the score. Empty disables it.
+	TagHeader string `yaml:"tag_header" default:"X-Bot-Score" envconfig:"BOT_DETECTION_TAG_HEADER"`
+	// LogScores logs all requests with a score, not only the flagged ones
+	LogScores bool                               `yaml:"log_scores" default:"false" envconfig:"BOT_DETECTION_LOG_SCORES"`
+	Burst     BotDetectionBurstConfiguration     `yaml:"burst"`
+	RateLimit BotDetectionRateLimitConfiguration `yaml:"rate_limit"`
+}
+
+type BotDetectionBurstConfiguration struct {
+	Window      time.Duration `yaml:"window" default:"1s" envconfig:"BOT_DETECTION_BURST_WINDOW"`
+	MaxRequests int           `yaml:"max_requests" default:"20" envconfig:"BOT_DETECTION_BURST_MAX_REQUESTS"`
+}
+
+type BotDetectionRateLimitConfiguration struct {
+	Window      time.Duration `yaml:"window" default:"1m" envconfig:"BOT_DETECTION_RATE_LIMIT_WINDOW"`
+	MaxRequests int           `yaml:"max_requests" default:"30" envconfig:"BOT_DETECTION_RATE_LIMIT_MAX_REQUESTS"`
+}
+
+// OperationTimeoutsConfiguration limits the execution time of operations by type. A timeout of zero disables it.
+type OperationTimeoutsConfiguration struct {
+	Query    time.Duration `yaml:"query" default:"0s" envconfig:"OPERATION_TIMEOUTS_QUERY"`
+	Mutation time.Duration `yaml:"mutation" default:"0s" envconfig:"OPERATION_TIMEOUTS_MUTATION"`
+	// Subscription limits the lifetime of subscriptions
+	Subscription time.Duration `yaml:"subscription" default:"0s" envconfig:"OPERATION_TIMEOUTS_SUBSCRIPTION"`
+	// Operations override the timeout of the operation type by operation name
+	Operations []OperationTimeout `yaml:"operations,omitempty"`
+}
+
+type OperationTimeout struct {
+	Name    string        `yaml:"name"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// FetchConcurrencyConfiguration limits the concurrent subgraph fetches of a request. A limit of zero is unlimited.
+type FetchConcurrencyConfiguration struct {
+	MaxPerRequest int `yaml:"max_per_request" default:"0" envconfig:"FETCH_CONCURRENCY_MAX_PER_REQUEST"`
+	// Operations override MaxPerRequest by operation name
+	Operations []OperationFetchConcurrency `yaml:"operations,omitempty"`
+}
+
+type OperationFetchConcurrency struct {
+	Name          string `yaml:"name"`
+	MaxPerRequest int    `yaml:"max_per_request"`
+}
+
+// EntityBatchingConfiguration tunes the entity requests of the engine to the subgraphs. By default, the engine sends
+// all representations of a plan step in a single request and removes equal representations.
+type EntityBatchingConfiguration struct {
+	// MaxBatchSize splits entity requests with more representations into multiple requests. Zero is unlimited.
+	MaxBatchSize int `yaml:"max_batch_size" default:"0" envconfig:"ENTITY_BATCHING_MAX_BATCH_SIZE"`
+	// BatchWait merges the entity requests of concurrent operations to the same subgraph within the window
+	BatchWait time.Duration `yaml:"batch_wait" default:"0s" envconfig:"ENTITY_BATCHING_BATCH_WAIT"`
+	// DedupeKeys define the fields that identify the representations of an entity type
+	DedupeKeys []EntityDedupeKey `yaml:"dedupe_keys,omitempty"`
+}
+
+type EntityDedupeKey struct {
+	TypeName string   `yaml:"type_name"`
+	Fields   []string `yaml:"fields"`
+}
+
+// SubgraphResponseValidationConfiguration validates the subgraph responses against the federated schema and logs
+// the violations
+type SubgraphResponseValidationConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SUBGRAPH_RESPONSE_VALIDATION_ENABLED"`
+	// SampleRate is the share of the validated responses between 0 and 1
+	SampleRate          float64 `yaml:"sample_rate" default:"1" envconfig:"SUBGRAPH_RESPONSE_VALIDATION_SAMPLE_RATE"`
+	MaxLoggedViolations int     `yaml:"max_logged_violations" default:"10" envconfig:"SUBGRAPH_RESPONSE_VALIDATION_MAX_LOGGED_VIOLATIONS"`
+}
+
+// SubgraphCompressionConfiguration requests compressed responses from the subgraphs. The router decompresses the
+// responses while they are read.
+type SubgraphCompressionConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SUBGRAPH_COMPRESSION_ENABLED"`
+	// Algorithms are the accepted content encodings in the order of preference: zstd, br or gzip
+	Algorithms []string `yaml:"algorithms,omitempty" default:"zstd,br,gzip" envconfig:"SUBGRAPH_COMPRESSION_ALGORITHMS"`
+	// Subgraphs override the compression of single subgraphs, by the name of the subgraph
+	Subgraphs map[string]SubgraphCompression `yaml:"subgraphs,omitempty"`
+}
+
+type SubgraphCompression struct {
+	Enabled bool `yaml:"enabled"`
+	// Algorithms replace the algorithms of all subgraphs. Empty uses them.
+	Algorithms []string `yaml:"algorithms,omitempty"`
+}
+
+// SubgraphPayloadSizeConfiguration measures the size of the requests to and the responses of the subgraphs and limits
+// the size of the responses
+type SubgraphPayloadSizeConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SUBGRAPH_PAYLOAD_SIZE_ENABLED"`
+	// MaxResponseSize is the maximum size of the responses of all subgraphs. Zero doesn't limit the size.
+	MaxResponseSize BytesString `yaml:"max_response_size,omitempty" envconfig:"SUBGRAPH_PAYLOAD_SIZE_MAX_RESPONSE_SIZE"`
+	// Subgraphs override the maximum response size of single subgraphs, by the name of the subgraph
+	Subgraphs map[string]SubgraphPayloadSize `yaml:"subgraphs,omitempty"`
+}
+
+type SubgraphPayloadSize struct {
+	// MaxResponseSize replaces the maximum response size of all subgraphs. Zero doesn't limit the size.
+	MaxResponseSize BytesString `yaml:"max_response_size"`
+}
+
+type ResponseSizeLimitConfiguration struct {
+	Enabled bool        `yaml:"enabled" default:"false" envconfig:"RESPONSE_SIZE_LIMIT_ENABLED"`
+	MaxSize BytesString `yaml:"max_size" default:"10MB" envconfig:"RESPONSE_SIZE_LIMIT_MAX_SIZE"`
+	// Mode is "error" to replace a response over the limit with an error or "truncate" to shorten its lists
+	Mode string `yaml:"mode" default:"error" envconfig:"RESPONSE_SIZE_LIMIT_MODE"`
+}
+
+type PersistedOperationUsageConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"PERSISTED_OPERATION_USAGE_ENABLED"`
+	// MaxOperations is the maximum number of tracked operations. Zero tracks all operations.
+	MaxOperations int `yaml:"max_operations" default:"10000" envconfig:"PERSISTED_OPERATION_USAGE_MAX_OPERATIONS"`
+}
+
+type ConfigAuditConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"CONFIG_AUDIT_ENABLED"`
+	// MaxEntries is the number of the most recent config changes that are kept
+	MaxEntries int `yaml:"max_entries" default:"50" envconfig:"CONFIG_AUDIT_MAX_ENTRIES"`
+}
+
+type AuditLogConfiguration struct {
+	// Enabled records the authentication outcomes, authorization denials and config reloads in an append-only file
+	Enabled bool   `yaml:"enabled" default:"false" envconfig:"AUDIT_LOG_ENABLED"`
+	Path    string `yaml:"path" default:"audit.log" envconfig:"AUDIT_LOG_PATH"`
+	// HMACKey chains the entries with an HMAC, so that changed, removed or inserted entries are detected. If empty,
+	// the entries aren't chained.
+	HMACKey string `yaml:"hmac_key,omitempty" envconfig:"AUDIT_LOG_HMAC_KEY"`
+	// Claims are the claims of the authenticated clients that are recorded
+	Claims []string `yaml:"claims" default:"sub" envconfig:"AUDIT_LOG_CLAIMS"`
+}
+
+type ExecutionAuditConfiguration struct {
+	// Enabled exports a record of every operation with the subgraphs that were requested, the authentication and the
+	// policies that were applied, e.g. to prove which systems handled which request
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"EXECUTION_AUDIT_ENABLED"`
+	// Exporter is ndjson to append the records to a file or otlp to export them as OpenTelemetry log records
+	Exporter string `yaml:"exporter" default:"ndjson" envconfig:"EXECUTION_AUDIT_EXPORTER"`
+	// Path is the file of the ndjson exporter
+	Path string `yaml:"path" default:"execution-audit.ndjson" envconfig:"EXECUTION_AUDIT_PATH"`
+	// Claims are the claims of the authenticated clients that are recorded
+	Claims []string                        `yaml:"claims" default:"sub" envconfig:"EXECUTION_AUDIT_CLAIMS"`
+	OTLP   ExecutionAuditOTLPConfiguration `yaml:"otlp"`
+}
+
+type ExecutionAuditOTLPConfiguration struct {
+	Exporter otelconfig.Exporter `yaml:"exporter" default:"http" envconfig:"EXECUTION_AUDIT_OTLP_EXPORTER"`
+	Endpoint string              `yaml:"endpoint,omitempty" envconfig:"EXECUTION_AUDIT_OTLP_ENDPOINT"`
+	HTTPPath string              `yaml:"path" default:"/v1/logs" envconfig:"EXECUTION_AUDIT_OTLP_PATH"`
+	Headers  map[string]string   `yaml:"headers,omitempty"`
+}
+
+type DeprecationWarningsConfiguration struct {
+	// Enabled logs the usage of deprecated config options and schema fields and counts them
+	Enabled bool `yaml:"enabled" default:"true" envconfig:"DEPRECATION_WARNINGS_ENABLED"`
+	// LogInterval is the minimum time between two log entries of the same deprecation
+	LogInterval time.Duration `yaml:"log_interval" default:"1h" envconfig:"DEPRECATION_WARNINGS_LOG_INTERVAL"`
+	// ResponseExtension lists the deprecated fields used by the operation in the extensions of the response
+	ResponseExtension DeprecationResponseExtensionConfiguration `yaml:"response_extension,omitempty"`
+}
+
+type DeprecationResponseExtensionConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"DEPRECATION_WARNINGS_RESPONSE_EXTENSION_ENABLED"`
+	// Interval is the minimum time between two responses to the same client that list the same deprecated field
+	Interval time.Duration `yaml:"interval" default:"1h" envconfig:"DEPRECATION_WARNINGS_RESPONSE_EXTENSION_INTERVAL"`
+}
+
+// LogPrettyConfiguration are the options of the pretty log encoding
+type LogPrettyConfiguration struct {
+	// Theme is the color theme: default, high_contrast or monochrome. The colors are disabled by NO_COLOR.
+	Theme string `yaml:"theme,omitempty" envconfig:"LOG_PRETTY_THEME"`
+	// FieldFormat is the format of the structured fields: yaml or json
+	FieldFormat string `yaml:"field_format,omitempty" envconfig:"LOG_PRETTY_FIELD_FORMAT"`
+}
+
+type ClientProtocolsConfiguration struct {
+	// Enabled counts the operations by the protocol of the client and adds the protocol to the access logs
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"CLIENT_PROTOCOLS_ENABLED"`
+}
+
+type AccessLogsConfiguration struct {
+	// Kafka publishes the access log entries to a Kafka topic in addition to the log output
+	Kafka AccessLogsKafkaConfiguration `yaml:"kafka,omitempty"`
+	// TraceContext adds the W3C traceparent and the sampling decision to the entries
+	TraceContext AccessLogsTraceContextConfiguration `yaml:"trace_context,omitempty"`
+	// Operations adds the sampled operations to the entries, e.g. to replay them with the replay command
+	Operations AccessLogsOperationsConfiguration `yaml:"operations,omitempty"`
+	// Sampling only logs a share of the requests. The requests of the exemptions are always logged.
+	Sampling AccessLogsSamplingConfiguration `yaml:"sampling,omitempty"`
+	// Logger writes the access logs with a dedicated logger instead of the logger of the router
+	Logger AccessLogsLoggerConfiguration `yaml:"logger,omitempty"`
+	// UserAgent adds the device class and the browser and OS families of the user agent to the entries
+	UserAgent AccessLogsUserAgentConfiguration `yaml:"user_agent,omitempty"`
+}
+
+type AccessLogsUserAgentConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ACCESS_LOGS_USER_AGENT_ENABLED"`
+	// CacheSize is the number of the least recently used user agents whose classification is cached
+	CacheSize int `yaml:"cache_size" default:"10000" envconfig:"ACCESS_LOGS_USER_AGENT_CACHE_SIZE"`
+}
+
+// AccessLogsLoggerConfiguration writes the access logs to their own output, independently of the level and the
+// outputs of the router logs
+type AccessLogsLoggerConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ACCESS_LOGS_LOGGER_ENABLED"`
+	// Output is "stdout", "stderr" or "file"
+	Output string `yaml:"output" default:"stdout" envconfig:"ACCESS_LOGS_LOGGER_OUTPUT"`
+	// Encoding is an encoding like of log_encoding, e.g. "console" or the compact binary "msgpack". If empty, the
+	// encoding of log_encoding is used, or "json" if it isn't set either.
+	Encoding string                      `yaml:"encoding,omitempty" envconfig:"ACCESS_LOGS_LOGGER_ENCODING"`
+	File     AccessLogsFileConfiguration `yaml:"file,omitempty"`
+	// Fields are the names of the logged fields, e.g. method, path, operation_name or status. Empty logs all fields.
+	Fields []string `yaml:"fields,omitempty" envconfig:"ACCESS_LOGS_LOGGER_FIELDS"`
+}
+
+type AccessLogsFileConfiguration struct {
+	Path string `yaml:"path,omitempty" envconfig:"ACCESS_LOGS_LOGGER_FILE_PATH"`
+	// MaxSize is the size after which the file is rotated
+	MaxSize BytesString `yaml:"max_size" default:"100MB" envconfig:"ACCESS_LOGS_LOGGER_FILE_MAX_SIZE"`
+	// MaxBackups is the number of rotated files that are kept. Zero keeps all files.
+	MaxBackups int `yaml:"max_backups" default:"0" envconfig:"ACCESS_LOGS_LOGGER_FILE_MAX_BACKUPS"`
+	// MaxAge deletes rotated files that are older. It is rounded up to days. Zero keeps the files.
+	MaxAge   time.Duration `yaml:"max_age" default:"0s" envconfig:"ACCESS_LOGS_LOGGER_FILE_MAX_AGE"`
+	Compress bool          `yaml:"compress" default:"false" envconfig:"ACCESS_LOGS_LOGGER_FILE_COMPRESS"`
+	// LocalTime uses the local time instead of UTC in the names of the rotated files
+	LocalTime bool `yaml:"local_time" default:"false" envconfig:"ACCESS_LOGS_LOGGER_FILE_LOCAL_TIME"`
+}
+
+type AccessLogsSamplingConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ACCESS_LOGS_SAMPLING_ENABLED"`
+	// SampleRate is the share of the logged requests between 0 and 1. Zero only logs the exempted requests.
+	SampleRate float64 `yaml:"sample_rate" default:"1" envconfig:"ACCESS_LOGS_SAMPLING_SAMPLE_RATE"`
+	// AlwaysLog are the requests that are logged regardless of the sampling, with their operation if the operations
+	// are logged
+	AlwaysLog AccessLogsSamplingExemptions `yaml:"always_log,omitempty"`
+}
+
+type AccessLogsSamplingExemptions struct {
+	OperationTypes []string `yaml:"operation_types,omitempty" envconfig:"ACCESS_LOGS_SAMPLING_ALWAYS_LOG_OPERATION_TYPES"`
+	OperationNames []string `yaml:"operation_names,omitempty" envconfig:"ACCESS_LOGS_SAMPLING_ALWAYS_LOG_OPERATION_NAMES"`
+	ClientNames    []string `yaml:"client_names,omitempty" envconfig:"ACCESS_LOGS_SAMPLING_ALWAYS_LOG_CLIENT_NAMES"`
+	// StatusCodes are status codes like 401 or classes like 5xx
+	StatusCodes []string `yaml:"status_codes,omitempty" envconfig:"ACCESS_LOGS_SAMPLING_ALWAYS_LOG_STATUS_CODES"`
+}
+
+type AccessLogsOperationsConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ACCESS_LOGS_OPERATIONS_ENABLED"`
+	// SampleRate is the share of the requests with the logged operation between 0 and 1
+	SampleRate float64 `yaml:"sample_rate" default:"1" envconfig:"ACCESS_LOGS_OPERATIONS_SAMPLE_RATE"`
+	// IncludeVariables logs the variables of the operations. They can contain sensitive data, but the normalized
+	// operations can't be replayed without them.
+	IncludeVariables bool `yaml:"include_variables" default:"true" envconfig:"ACCESS_LOGS_OPERATIONS_INCLUDE_VARIABLES"`
+}
+
+type AccessLogsTraceContextConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ACCESS_LOGS_TRACE_CONTEXT_ENABLED"`
+	// LogUnsampled logs the trace fields of requests whose traces are sampled out
+	LogUnsampled bool `yaml:"log_unsampled" default:"false" envconfig:"ACCESS_LOGS_TRACE_CONTEXT_LOG_UNSAMPLED"`
+}
+
+type AccessLogsKafkaConfiguration struct {
+	Enabled        bool                   `yaml:"enabled" default:"false" envconfig:"ACCESS_LOGS_KAFKA_ENABLED"`
+	Brokers        []string               `yaml:"brokers,omitempty" envconfig:"ACCESS_LOGS_KAFKA_BROKERS"`
+	Topic          string                 `yaml:"topic" default:"router-access-logs" envconfig:"ACCESS_LOGS_KAFKA_TOPIC"`
+	Authentication *KafkaAuthentication   `yaml:"authentication,omitempty"`
+	TLS            *KafkaTLSConfiguration `yaml:"tls,omitempty"`
+	// Format is the serialization format of the entries. One of avro or protobuf.
+	Format         string                      `yaml:"format" default:"avro" envconfig:"ACCESS_LOGS_KAFKA_FORMAT"`
+	SchemaRegistry SchemaRegistryConfiguration `yaml:"schema_registry"`
+}
+
+type SchemaRegistryConfiguration struct {
+	URL string `yaml:"url" envconfig:"ACCESS_LOGS_KAFKA_SCHEMA_REGISTRY_URL"`
+	// Subject defaults to "<topic>-value"
+	Subject  string `yaml:"subject,omitempty" envconfig:"ACCESS_LOGS_KAFKA_SCHEMA_REGISTRY_SUBJECT"`
+	Username string `yaml:"username,omitempty" envconfig:"ACCESS_LOGS_KAFKA_SCHEMA_REGISTRY_USERNAME"`
+	Password string `yaml:"password,omitempty" envconfig:"ACCESS_LOGS_KAFKA_SCHEMA_REGISTRY_PASSWORD"`
 }
 
 type EventProviders struct {
@@ -336,10 +1199,38 @@ type EventProviders struct {
 
 type EventsConfiguration struct {
 	Providers EventProviders `yaml:"providers,omitempty"`
+	// Filters drop the events of the subscriptions in the router that don't match all filters of their provider and
+	// subject
+	Filters []EventFilterConfiguration `yaml:"filters,omitempty"`
+}
+
+type EventFilterConfiguration struct {
+	// ProviderID is the ID of the NATS or Kafka provider. If empty, the filter applies to all providers.
+	ProviderID string `yaml:"provider_id,omitempty"`
+	// Subjects are the NATS subjects or Kafka topics. If empty, the filter applies to all of them.
+	Subjects []string `yaml:"subjects,omitempty"`
+	// Field is the dot separated path of a field of the JSON payload that must have the value of Equals
+	Field  string `yaml:"field,omitempty"`
+	Equals string `yaml:"equals,omitempty"`
+	// Expression is a CEL expression on the JSON payload as event that must evaluate to true
+	Expression string `yaml:"expression,omitempty"`
 }
 
 type Cluster struct {
-	Name string `yaml:"name,omitempty" envconfig:"CLUSTER_NAME"`
+	Name  string                    `yaml:"name,omitempty" envconfig:"CLUSTER_NAME"`
+	Peers ClusterPeersConfiguration `yaml:"peers,omitempty"`
+}
+
+// ClusterPeersConfiguration keeps an inventory of the instances of the cluster with a gossip over the admin API
+type ClusterPeersConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"CLUSTER_PEERS_ENABLED"`
+	// AdvertiseURL is the URL of the admin API of this instance that the peers reach it at
+	AdvertiseURL string `yaml:"advertise_url,omitempty" envconfig:"CLUSTER_PEERS_ADVERTISE_URL"`
+	// Seeds are the URLs of the admin APIs of other instances, e.g. of a headless service
+	Seeds    []string      `yaml:"seeds,omitempty" envconfig:"CLUSTER_PEERS_SEEDS"`
+	Interval time.Duration `yaml:"interval" default:"10s" envconfig:"CLUSTER_PEERS_INTERVAL"`
+	// TTL removes the peers that weren't seen for this long
+	TTL time.Duration `yaml:"ttl" default:"1m" envconfig:"CLUSTER_PEERS_TTL"`
 }
 
 type AbsintheProtocolConfiguration struct {
@@ -354,6 +1245,16 @@ type AbsintheProtocolConfiguration struct {
 
 type ComplianceConfig struct {
 	AnonymizeIP AnonymizeIpConfiguration `yaml:"anonymize_ip,omitempty"`
+	// Pseudonymization replaces user identifiers in the access logs with pseudonyms
+	Pseudonymization PseudonymizationConfiguration `yaml:"pseudonymization,omitempty"`
+}
+
+type PseudonymizationConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"COMPLIANCE_PSEUDONYMIZATION_ENABLED"`
+	// Fields are the names of the access log fields whose values are replaced, e.g. fields added by custom modules
+	Fields []string `yaml:"fields,omitempty" envconfig:"COMPLIANCE_PSEUDONYMIZATION_FIELDS"`
+	// SaltRotationInterval is the interval after which the salt is replaced. Zero keeps the salt until the router restarts.
+	SaltRotationInterval time.Duration `yaml:"salt_rotation_interval" default:"24h" envconfig:"COMPLIANCE_PSEUDONYMIZATION_SALT_ROTATION_INTERVAL"`
 }
 
 type WebSocketConfiguration struct {
@@ -367,6 +1268,75 @@ type WebSocketConfiguration struct {
 	ForwardUpgradeQueryParams ForwardUpgradeQueryParamsConfiguration `yaml:"forward_upgrade_query_params"`
 	// ForwardInitialPayload true if the Router should forward the initial payload of a Subscription Request to the Subgraph
 	ForwardInitialPayload bool `yaml:"forward_initial_payload" default:"true" envconfig:"WEBSOCKETS_FORWARD_INITIAL_PAYLOAD"`
+	// AllowQueriesAndMutations executes queries and mutations over the connections, next to the subscriptions, so that
+	// realtime clients don't need a second connection. False rejects them with an error.
+	AllowQueriesAndMutations bool `yaml:"allow_queries_and_mutations" default:"true" envconfig:"WEBSOCKETS_ALLOW_QUERIES_AND_MUTATIONS"`
+	// MaxMessageSize closes the connections of clients that send larger messages. Zero means unlimited.
+	MaxMessageSize BytesString `yaml:"max_message_size,omitempty" envconfig:"WEBSOCKETS_MAX_MESSAGE_SIZE"`
+	// MaxFrameSize closes the connections of clients that send larger frames. Zero means unlimited.
+	MaxFrameSize BytesString `yaml:"max_frame_size,omitempty" envconfig:"WEBSOCKETS_MAX_FRAME_SIZE"`
+	// Compression compresses the messages with the permessage-deflate extension when the client supports it
+	Compression WebSocketCompressionConfiguration `yaml:"compression,omitempty"`
+	// Authentication authenticates the connections with the token of the initial payload and closes the connections
+	// whose token expired
+	Authentication WebSocketAuthenticationConfiguration `yaml:"authentication,omitempty"`
+}
+
+type WebSocketAuthenticationConfiguration struct {
+	// FromInitialPayload authenticates the connections with the token in the initial payload, when the upgrade
+	// request isn't authenticated
+	FromInitialPayload bool `yaml:"from_initial_payload" default:"false" envconfig:"WEBSOCKETS_AUTHENTICATION_FROM_INITIAL_PAYLOAD"`
+	// Key is the key of the token in the payloads. The token is passed to the authenticators as the header of the same name.
+	Key string `yaml:"key" default:"Authorization" envconfig:"WEBSOCKETS_AUTHENTICATION_KEY"`
+	// Refresh closes the connections whose token expired, unless the client sent a new token
+	Refresh WebSocketAuthenticationRefreshConfiguration `yaml:"refresh,omitempty"`
+}
+
+type WebSocketAuthenticationRefreshConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"WEBSOCKETS_AUTHENTICATION_REFRESH_ENABLED"`
+	// GracePeriod is the time after the expiry of the token before the connection is closed
+	GracePeriod time.Duration `yaml:"grace_period" default:"30s" envconfig:"WEBSOCKETS_AUTHENTICATION_REFRESH_GRACE_PERIOD"`
+}
+
+type WebSocketCompressionConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"WEBSOCKETS_COMPRESSION_ENABLED"`
+	// Level is the flate compression level from 1, the fastest, to 9, the smallest
+	Level int `yaml:"level" default:"6" envconfig:"WEBSOCKETS_COMPRESSION_LEVEL"`
+}
+
+type SubscriptionLimitsConfiguration struct {
+	// MaxConnections is the maximum number of open WebSocket and SSE connections. Zero means unlimited.
+	MaxConnections int `yaml:"max_connections" default:"0" envconfig:"SUBSCRIPTION_LIMITS_MAX_CONNECTIONS"`
+	// MaxConnectionsPerClient is the maximum number of open WebSocket and SSE connections per client. Zero means unlimited.
+	MaxConnectionsPerClient int `yaml:"max_connections_per_client" default:"0" envconfig:"SUBSCRIPTION_LIMITS_MAX_CONNECTIONS_PER_CLIENT"`
+	// MaxSubscriptions is the maximum number of active subscriptions. Zero means unlimited.
+	MaxSubscriptions int `yaml:"max_subscriptions" default:"0" envconfig:"SUBSCRIPTION_LIMITS_MAX_SUBSCRIPTIONS"`
+	// MaxSubscriptionsPerClient is the maximum number of active subscriptions per client. Zero means unlimited.
+	MaxSubscriptionsPerClient int `yaml:"max_subscriptions_per_client" default:"0" envconfig:"SUBSCRIPTION_LIMITS_MAX_SUBSCRIPTIONS_PER_CLIENT"`
+	// KeyBy identifies the clients of the limits per client. One of ip, client_name or claim.
+	KeyBy string `yaml:"key_by" default:"ip" envconfig:"SUBSCRIPTION_LIMITS_KEY_BY"`
+	// KeyClaim is the claim of the authenticated request that identifies the client with key_by claim
+	KeyClaim string `yaml:"key_claim,omitempty" default:"sub" envconfig:"SUBSCRIPTION_LIMITS_KEY_CLAIM"`
+}
+
+type SubscriptionReapingConfiguration struct {
+	// Enabled closes the subscription connections without events and client messages for the idle timeout
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SUBSCRIPTION_REAPING_ENABLED"`
+	// IdleTimeout is the period without events and client messages after which a connection is closed
+	IdleTimeout time.Duration `yaml:"idle_timeout" default:"30m" envconfig:"SUBSCRIPTION_REAPING_IDLE_TIMEOUT"`
+	// Interval is the period in which the connections are checked
+	Interval time.Duration `yaml:"interval" default:"1m" envconfig:"SUBSCRIPTION_REAPING_INTERVAL"`
+}
+
+type SubscriptionBackpressureConfiguration struct {
+	// Enabled buffers the events of every subscription, so that a slow client doesn't hold up the delivery of the
+	// events to the other clients
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SUBSCRIPTION_BACKPRESSURE_ENABLED"`
+	// BufferSize is the maximum number of events that wait to be written to a client
+	BufferSize int `yaml:"buffer_size" default:"100" envconfig:"SUBSCRIPTION_BACKPRESSURE_BUFFER_SIZE"`
+	// Strategy applies when the buffer of a subscription is full. One of block, drop_oldest, drop_newest or
+	// disconnect.
+	Strategy string `yaml:"strategy" default:"block" envconfig:"SUBSCRIPTION_BACKPRESSURE_STRATEGY"`
 }
 
 type ForwardUpgradeHeadersConfiguration struct {
@@ -382,6 +1352,10 @@ type ForwardUpgradeQueryParamsConfiguration struct {
 type AnonymizeIpConfiguration struct {
 	Enabled bool   `yaml:"enabled" default:"true" envconfig:"SECURITY_ANONYMIZE_IP_ENABLED"`
 	Method  string `yaml:"method" default:"redact" envconfig:"SECURITY_ANONYMIZE_IP_METHOD"`
+	// SaltRotationInterval is the interval after which the salt of the hash method is replaced
+	SaltRotationInterval time.Duration `yaml:"salt_rotation_interval" default:"24h" envconfig:"SECURITY_ANONYMIZE_IP_SALT_ROTATION_INTERVAL"`
+	// Fields are the names of further log fields with client addresses, in addition to client_ip and x_forwarded_for
+	Fields []string `yaml:"fields,omitempty" envconfig:"SECURITY_ANONYMIZE_IP_FIELDS"`
 }
 
 type TLSClientAuthConfiguration struct {
@@ -417,6 +1391,507 @@ type SubgraphErrorPropagationConfiguration struct {
 	OmitExtensions       bool                         `yaml:"omit_extensions" default:"false" envconfig:"SUBGRAPH_ERROR_PROPAGATION_OMIT_EXTENSIONS"`
 }
 
+type AdminConfiguration struct {
+	Enabled    bool   `yaml:"enabled" default:"false" envconfig:"ADMIN_ENABLED"`
+	ListenAddr string `yaml:"listen_addr" default:"127.0.0.1:3009" envconfig:"ADMIN_LISTEN_ADDR"`
+	// Token is the bearer token required to call the admin API
+	Token string `yaml:"token,omitempty" envconfig:"ADMIN_TOKEN"`
+	// LogBufferSize is the number of recent log entries kept in memory for debug bundles
+	LogBufferSize int `yaml:"log_buffer_size" default:"1000" envconfig:"ADMIN_LOG_BUFFER_SIZE"`
+	// Pprof exposes CPU, memory and runtime trace profiles on the admin listener
+	Pprof AdminPprofConfiguration `yaml:"pprof"`
+	// TLS serves the admin API with TLS. With a client CA, clients must authenticate with a certificate.
+	TLS AdminTLSConfiguration `yaml:"tls,omitempty"`
+	// OIDC accepts the tokens of an OpenID Connect provider
+	OIDC AdminOIDCConfiguration `yaml:"oidc,omitempty"`
+}
+
+type AdminTLSConfiguration struct {
+	Enabled  bool   `yaml:"enabled" default:"false" envconfig:"ADMIN_TLS_ENABLED"`
+	CertFile string `yaml:"cert_file,omitempty" envconfig:"ADMIN_TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file,omitempty" envconfig:"ADMIN_TLS_KEY_FILE"`
+	// ClientCAFile is the CA the client certificates are verified with
+	ClientCAFile string `yaml:"client_ca_file,omitempty" envconfig:"ADMIN_TLS_CLIENT_CA_FILE"`
+}
+
+type AdminOIDCConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ADMIN_OIDC_ENABLED"`
+	// Issuer is the expected issuer of the tokens. The keys are discovered from it if JWKSURL is empty.
+	Issuer          string        `yaml:"issuer,omitempty" envconfig:"ADMIN_OIDC_ISSUER"`
+	JWKSURL         string        `yaml:"jwks_url,omitempty" envconfig:"ADMIN_OIDC_JWKS_URL"`
+	Audience        string        `yaml:"audience,omitempty" envconfig:"ADMIN_OIDC_AUDIENCE"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" default:"1m" envconfig:"ADMIN_OIDC_REFRESH_INTERVAL"`
+}
+
+type AdminPprofConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ADMIN_PPROF_ENABLED"`
+	// MaxDuration is the maximum duration of CPU profiles and runtime traces
+	MaxDuration time.Duration `yaml:"max_duration" default:"60s" envconfig:"ADMIN_PPROF_MAX_DURATION"`
+}
+
+type MaintenanceOperations struct {
+	// Names restricts the maintenance mode to operations with the given names
+	Names []string `yaml:"names,omitempty" envconfig:"MAINTENANCE_OPERATION_NAMES"`
+	// Types restricts the maintenance mode to the given operation types e.g. "mutation"
+	Types []string `yaml:"types,omitempty" envconfig:"MAINTENANCE_OPERATION_TYPES"`
+}
+
+type MaintenanceConfiguration struct {
+	// Enabled starts the router in maintenance mode. The mode can be toggled at runtime with the admin API.
+	Enabled    bool          `yaml:"enabled" default:"false" envconfig:"MAINTENANCE_ENABLED"`
+	StatusCode int           `yaml:"status_code" default:"503" envconfig:"MAINTENANCE_STATUS_CODE"`
+	RetryAfter time.Duration `yaml:"retry_after" default:"30s" envconfig:"MAINTENANCE_RETRY_AFTER"`
+	Message    string        `yaml:"message" default:"The service is temporarily unavailable due to maintenance" envconfig:"MAINTENANCE_MESSAGE"`
+	// ResponseBody is a static JSON document that is returned instead of the GraphQL error
+	ResponseBody string                `yaml:"response_body,omitempty" envconfig:"MAINTENANCE_RESPONSE_BODY"`
+	Operations   MaintenanceOperations `yaml:"operations,omitempty"`
+	// FailReadinessCheck reports the router as not ready while the maintenance mode is active
+	FailReadinessCheck bool `yaml:"fail_readiness_check" default:"false" envconfig:"MAINTENANCE_FAIL_READINESS_CHECK"`
+}
+
+type StartupReportConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"true" envconfig:"STARTUP_REPORT_ENABLED"`
+	// FilePath is the path of an optional JSON file the report is written to
+	FilePath string `yaml:"file_path,omitempty" envconfig:"STARTUP_REPORT_FILE_PATH"`
+}
+
+type MemorySoftLimitConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"MEMORY_SOFT_LIMIT_ENABLED"`
+	// Threshold is the memory usage that triggers the soft limit. Defaults to 90% of the memory limit.
+	Threshold     BytesString   `yaml:"threshold,omitempty" envconfig:"MEMORY_SOFT_LIMIT_THRESHOLD"`
+	CheckInterval time.Duration `yaml:"check_interval" default:"1s" envconfig:"MEMORY_SOFT_LIMIT_CHECK_INTERVAL"`
+	// ShrinkCaches clears the execution plan and persisted operation caches when the soft limit is exceeded
+	ShrinkCaches bool `yaml:"shrink_caches" default:"true" envconfig:"MEMORY_SOFT_LIMIT_SHRINK_CACHES"`
+	// ShedLoad rejects GraphQL requests with 503 until the memory usage recovered
+	ShedLoad bool `yaml:"shed_load" default:"true" envconfig:"MEMORY_SOFT_LIMIT_SHED_LOAD"`
+}
+
+type MemoryConfiguration struct {
+	// Limit sets the memory limit of the Go runtime. It overrides the GOMEMLIMIT environment variable.
+	Limit BytesString `yaml:"limit,omitempty" envconfig:"MEMORY_LIMIT"`
+	// GCPercent sets the garbage collection target percentage. It overrides the GOGC environment variable.
+	// Zero keeps the default of the runtime and a negative value disables the garbage collector.
+	GCPercent int                          `yaml:"gc_percent,omitempty" envconfig:"MEMORY_GC_PERCENT"`
+	SoftLimit MemorySoftLimitConfiguration `yaml:"soft_limit,omitempty"`
+}
+
+type VersionEndpointConfiguration struct {
+	// Enabled serves the version information of the router on the GraphQL listener
+	Enabled bool   `yaml:"enabled" default:"false" envconfig:"VERSION_ENDPOINT_ENABLED"`
+	Path    string `yaml:"path" default:"/version" envconfig:"VERSION_ENDPOINT_PATH"`
+}
+
+// CustomResponseHeadersConfiguration adds static or templated headers to the responses of the listeners, e.g.
+// security headers for the playground or the build version of the router
+type CustomResponseHeadersConfiguration struct {
+	Rules []CustomResponseHeaderRule `yaml:"rules,omitempty"`
+}
+
+type CustomResponseHeaderRule struct {
+	// Listener is graphql or admin. If empty, the rule applies to the GraphQL listener.
+	Listener string `yaml:"listener,omitempty"`
+	// Path is the exact path of the requests, or a prefix with a trailing *. If empty, the rule applies to all paths.
+	Path    string                 `yaml:"path,omitempty"`
+	Headers []CustomResponseHeader `yaml:"headers"`
+}
+
+// CustomResponseHeader is a header with a value. The placeholders {router_version}, {config_version} and
+// {request_id} in the value are replaced per response.
+type CustomResponseHeader struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type ProblemDetailsConfiguration struct {
+	// Enabled writes the errors of the requests that are rejected before they are handled as GraphQL requests as
+	// problem details of RFC 9457 instead of GraphQL errors
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"PROBLEM_DETAILS_ENABLED"`
+}
+
+type ConnectionTimingsConfiguration struct {
+	// Enabled measures the TLS handshakes of the listeners and the DNS lookups, connects and TLS handshakes to the
+	// subgraphs
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"CONNECTION_TIMINGS_ENABLED"`
+}
+
+// RESTEndpointsConfiguration exposes persisted operations as REST endpoints on the GraphQL listener
+type RESTEndpointsConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"REST_ENDPOINTS_ENABLED"`
+	// BasePath is the prefix of the paths of the endpoints
+	BasePath string `yaml:"base_path" default:"/rest" envconfig:"REST_ENDPOINTS_BASE_PATH"`
+	// OpenAPIPath is the path of the generated OpenAPI document below the base path
+	OpenAPIPath string                      `yaml:"openapi_path" default:"/openapi.json" envconfig:"REST_ENDPOINTS_OPENAPI_PATH"`
+	Endpoints   []RESTEndpointConfiguration `yaml:"endpoints,omitempty"`
+}
+
+type RESTEndpointConfiguration struct {
+	Method string `yaml:"method"`
+	// Path is relative to the base path. Path parameters are written as {name}.
+	Path string `yaml:"path"`
+	// PersistedOperation is the SHA-256 hash of the persisted operation of the client
+	PersistedOperation string `yaml:"persisted_operation"`
+	ClientName         string `yaml:"client_name"`
+	// OperationName selects the operation of persisted documents with multiple operations
+	OperationName string                       `yaml:"operation_name,omitempty"`
+	Summary       string                       `yaml:"summary,omitempty"`
+	Parameters    []RESTParameterConfiguration `yaml:"parameters,omitempty"`
+}
+
+// RESTParameterConfiguration maps a path or query parameter to a variable of the operation
+type RESTParameterConfiguration struct {
+	Name string `yaml:"name"`
+	// In is either path or query
+	In string `yaml:"in"`
+	// Variable defaults to the name of the parameter
+	Variable string `yaml:"variable,omitempty"`
+	// Type is one of string, integer, number or boolean. Defaults to string.
+	Type        string `yaml:"type,omitempty"`
+	Required    bool   `yaml:"required,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// SubscriptionWebhooksConfiguration runs subscriptions in the router and sends their events to webhooks
+type SubscriptionWebhooksConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SUBSCRIPTION_WEBHOOKS_ENABLED"`
+	// Timeout is the timeout of a single webhook request
+	Timeout time.Duration `yaml:"timeout" default:"5s" envconfig:"SUBSCRIPTION_WEBHOOKS_TIMEOUT"`
+	// MaxRetries is the number of retries of an event after a failed webhook request
+	MaxRetries int `yaml:"max_retries" default:"3" envconfig:"SUBSCRIPTION_WEBHOOKS_MAX_RETRIES"`
+	// RetryInterval is the delay before the first retry. It doubles with every retry up to the max retry interval.
+	RetryInterval    time.Duration `yaml:"retry_interval" default:"1s" envconfig:"SUBSCRIPTION_WEBHOOKS_RETRY_INTERVAL"`
+	MaxRetryInterval time.Duration `yaml:"max_retry_interval" default:"30s" envconfig:"SUBSCRIPTION_WEBHOOKS_MAX_RETRY_INTERVAL"`
+	// QueueSize is the number of events of a webhook that are buffered while its requests are pending.
+	// Events are dropped when the queue is full.
+	QueueSize       int                                `yaml:"queue_size" default:"100" envconfig:"SUBSCRIPTION_WEBHOOKS_QUEUE_SIZE"`
+	SignatureHeader string                             `yaml:"signature_header" default:"X-Signature" envconfig:"SUBSCRIPTION_WEBHOOKS_SIGNATURE_HEADER"`
+	TimestampHeader string                             `yaml:"timestamp_header" default:"X-Signature-Timestamp" envconfig:"SUBSCRIPTION_WEBHOOKS_TIMESTAMP_HEADER"`
+	KeyIDHeader     string                             `yaml:"key_id_header" default:"X-Signature-Key-Id" envconfig:"SUBSCRIPTION_WEBHOOKS_KEY_ID_HEADER"`
+	Webhooks        []SubscriptionWebhookConfiguration `yaml:"webhooks,omitempty"`
+}
+
+type SubscriptionWebhookConfiguration struct {
+	// Name identifies the webhook in the payload and the logs
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Headers are sent with the webhook requests
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Query is the subscription operation that is run by the router
+	Query         string         `yaml:"query"`
+	OperationName string         `yaml:"operation_name,omitempty"`
+	Variables     map[string]any `yaml:"variables,omitempty"`
+	ClientName    string         `yaml:"client_name,omitempty"`
+	// SubscriptionHeaders are the headers of the subscription request, e.g. to authenticate it
+	SubscriptionHeaders map[string]string `yaml:"subscription_headers,omitempty"`
+	// Signing signs the webhook requests with the HMAC of the key like the requests of request_signing.
+	// The requests are not signed without a secret.
+	Signing RequestSigningKey `yaml:"signing,omitempty"`
+}
+
+// SurrogateKeysConfiguration tags the responses with the keys of their entities for the invalidation of CDN caches
+type SurrogateKeysConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SURROGATE_KEYS_ENABLED"`
+	// Header is the response header of the space separated keys, e.g. Surrogate-Key for Fastly or xkey for Varnish
+	Header string `yaml:"header" default:"Surrogate-Key" envconfig:"SURROGATE_KEYS_HEADER"`
+	// MaxKeys limits the number of keys of a response to stay below the header size limits of the CDN
+	MaxKeys int `yaml:"max_keys" default:"256" envconfig:"SURROGATE_KEYS_MAX_KEYS"`
+	// TypeKeys adds a key with the name of every entity type of the response, e.g. to purge all employees
+	TypeKeys bool                           `yaml:"type_keys" default:"true" envconfig:"SURROGATE_KEYS_TYPE_KEYS"`
+	Purge    SurrogateKeyPurgeConfiguration `yaml:"purge,omitempty"`
+}
+
+// SurrogateKeyPurgeConfiguration purges the keys of the entities of mutation responses
+type SurrogateKeyPurgeConfiguration struct {
+	Enabled   bool                        `yaml:"enabled" default:"false" envconfig:"SURROGATE_KEYS_PURGE_ENABLED"`
+	Timeout   time.Duration               `yaml:"timeout" default:"5s" envconfig:"SURROGATE_KEYS_PURGE_TIMEOUT"`
+	Endpoints []SurrogateKeyPurgeEndpoint `yaml:"endpoints,omitempty"`
+}
+
+type SurrogateKeyPurgeEndpoint struct {
+	URL string `yaml:"url"`
+	// Method defaults to POST
+	Method  string            `yaml:"method,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// KeysHeader is the request header of the space separated keys. The keys are also sent as JSON body.
+	KeysHeader string `yaml:"keys_header,omitempty"`
+}
+
+// ETagsConfiguration adds content based ETags to the responses of queries and answers conditional requests of
+// unchanged responses with 304 Not Modified
+type ETagsConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"ETAGS_ENABLED"`
+	// Weak marks the ETags as weak validators, e.g. when a proxy compresses the responses
+	Weak bool `yaml:"weak" default:"false" envconfig:"ETAGS_WEAK"`
+}
+
+// OperationFingerprintConfiguration is the normalization of the operations for their hash in the logs, the traces
+// and the metrics, e.g. to match the fingerprints of client tooling
+type OperationFingerprintConfiguration struct {
+	// Literals is extract or inline. Inline literals are part of the fingerprint.
+	Literals string `yaml:"literals" default:"extract" envconfig:"OPERATION_FINGERPRINT_LITERALS"`
+	// Aliases is keep or remove
+	Aliases string `yaml:"aliases" default:"keep" envconfig:"OPERATION_FINGERPRINT_ALIASES"`
+	// Whitespace is compact or indent
+	Whitespace string `yaml:"whitespace" default:"compact" envconfig:"OPERATION_FINGERPRINT_WHITESPACE"`
+}
+
+// PersistedOperationManifestConfiguration records the distinct operations of the traffic in a manifest of persisted
+// operations, e.g. to bootstrap the safelisting of an existing API
+type PersistedOperationManifestConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"PERSISTED_OPERATION_MANIFEST_ENABLED"`
+	// Path is the manifest file. The operations of an existing manifest are kept.
+	Path string `yaml:"path" default:"persisted-operations.json" envconfig:"PERSISTED_OPERATION_MANIFEST_PATH"`
+	// ClientName only records the operations of the client. Empty records the operations of all clients.
+	ClientName string `yaml:"client_name,omitempty" envconfig:"PERSISTED_OPERATION_MANIFEST_CLIENT_NAME"`
+	// MaxOperations is the maximum number of operations of the manifest. Zero records all operations.
+	MaxOperations int           `yaml:"max_operations" default:"10000" envconfig:"PERSISTED_OPERATION_MANIFEST_MAX_OPERATIONS"`
+	WriteInterval time.Duration `yaml:"write_interval" default:"10s" envconfig:"PERSISTED_OPERATION_MANIFEST_WRITE_INTERVAL"`
+}
+
+// RequestTagsConfiguration computes custom tags of the requests with CEL expressions. The tags are attached to the
+// logs, the traces and the metrics of the request, and can key the rate limit.
+type RequestTagsConfiguration struct {
+	Enabled bool         `yaml:"enabled" default:"false" envconfig:"REQUEST_TAGS_ENABLED"`
+	Tags    []RequestTag `yaml:"tags,omitempty"`
+}
+
+type RequestTag struct {
+	Name string `yaml:"name"`
+	// Expression is a CEL expression that evaluates to the value of the tag, e.g. request.header['x-tenant']
+	Expression string `yaml:"expression"`
+}
+
+// SyntheticHealthOperationConfiguration runs a GraphQL operation periodically through the router itself, to probe
+// the whole pipeline including the subgraphs. The result is exported as metrics and failures are logged.
+type SyntheticHealthOperationConfiguration struct {
+	Enabled  bool          `yaml:"enabled" default:"false" envconfig:"SYNTHETIC_HEALTH_OPERATION_ENABLED"`
+	Interval time.Duration `yaml:"interval" default:"30s" envconfig:"SYNTHETIC_HEALTH_OPERATION_INTERVAL"`
+	Timeout  time.Duration `yaml:"timeout" default:"10s" envconfig:"SYNTHETIC_HEALTH_OPERATION_TIMEOUT"`
+	// Query is the operation that is run, it should touch the subgraphs that are probed
+	Query         string         `yaml:"query" envconfig:"SYNTHETIC_HEALTH_OPERATION_QUERY"`
+	OperationName string         `yaml:"operation_name,omitempty" envconfig:"SYNTHETIC_HEALTH_OPERATION_OPERATION_NAME"`
+	Variables     map[string]any `yaml:"variables,omitempty"`
+	// Headers are sent with the operation, e.g. to authenticate it
+	Headers    map[string]string `yaml:"headers,omitempty"`
+	ClientName string            `yaml:"client_name" default:"cosmo-router-synthetic" envconfig:"SYNTHETIC_HEALTH_OPERATION_CLIENT_NAME"`
+}
+
+// ClockSkewConfiguration compares the system clock periodically with a reference clock. A skew above the threshold
+// is logged, e.g. because it breaks the validation of tokens and signed URLs.
+type ClockSkewConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"CLOCK_SKEW_ENABLED"`
+	// Source is the reference clock, http for the Date header of a response or ntp for an NTP server
+	Source string `yaml:"source" default:"http" envconfig:"CLOCK_SKEW_SOURCE"`
+	// URL is requested with the http source. Defaults to the CDN.
+	URL       string        `yaml:"url,omitempty" envconfig:"CLOCK_SKEW_URL"`
+	NTPServer string        `yaml:"ntp_server" default:"pool.ntp.org:123" envconfig:"CLOCK_SKEW_NTP_SERVER"`
+	Interval  time.Duration `yaml:"interval" default:"10m" envconfig:"CLOCK_SKEW_INTERVAL"`
+	Threshold time.Duration `yaml:"threshold" default:"5s" envconfig:"CLOCK_SKEW_THRESHOLD"`
+}
+
+// CertificateExpiryConfiguration checks periodically when the TLS certificates loaded by the router expire, and logs
+// a warning for the certificates that expire within WarnBefore
+type CertificateExpiryConfiguration struct {
+	Enabled    bool          `yaml:"enabled" default:"false" envconfig:"CERTIFICATE_EXPIRY_ENABLED"`
+	Interval   time.Duration `yaml:"interval" default:"1h" envconfig:"CERTIFICATE_EXPIRY_INTERVAL"`
+	WarnBefore time.Duration `yaml:"warn_before" default:"720h" envconfig:"CERTIFICATE_EXPIRY_WARN_BEFORE"`
+}
+
+// ChaosConfiguration injects faults into the requests to the subgraphs, to validate the resilience settings like
+// the retries and the timeouts. It must never be enabled in production.
+type ChaosConfiguration struct {
+	Enabled bool        `yaml:"enabled" default:"false" envconfig:"CHAOS_ENABLED"`
+	Rules   []ChaosRule `yaml:"rules,omitempty"`
+}
+
+// ChaosRule injects the faults into the requests of the matching subgraphs and operations. The first matching rule
+// applies to a request.
+type ChaosRule struct {
+	// Subgraphs are the names of the matching subgraphs. Empty matches all subgraphs.
+	Subgraphs []string `yaml:"subgraphs,omitempty"`
+	// Operations are the names of the matching operations. Empty matches all operations.
+	Operations     []string            `yaml:"operations,omitempty"`
+	Latency        ChaosLatency        `yaml:"latency,omitempty"`
+	Error          ChaosError          `yaml:"error,omitempty"`
+	DropConnection ChaosDropConnection `yaml:"drop_connection,omitempty"`
+}
+
+type ChaosLatency struct {
+	// Rate is the share of the requests between 0 and 1 that are delayed
+	Rate     float64       `yaml:"rate"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+type ChaosError struct {
+	// Rate is the share of the requests between 0 and 1 that are answered with the status code
+	Rate float64 `yaml:"rate"`
+	// StatusCode is the status code of the responses, 503 when it is zero
+	StatusCode int `yaml:"status_code,omitempty"`
+}
+
+type ChaosDropConnection struct {
+	// Rate is the share of the requests between 0 and 1 that fail like a connection reset by the subgraph
+	Rate float64 `yaml:"rate"`
+}
+
+// SubgraphEndpointsConfiguration defines alternate endpoints of the subgraphs, to switch the requests between a blue
+// and a green deployment of a subgraph without publishing the graph again
+type SubgraphEndpointsConfiguration struct {
+	Enabled   bool                            `yaml:"enabled" default:"false" envconfig:"SUBGRAPH_ENDPOINTS_ENABLED"`
+	Subgraphs []SubgraphEndpointConfiguration `yaml:"subgraphs,omitempty"`
+}
+
+type SubgraphEndpointConfiguration struct {
+	// Name is the name of the subgraph
+	Name string `yaml:"name"`
+	// AlternateURL replaces the routing URL of the subgraph for the switched requests
+	AlternateURL string `yaml:"alternate_url"`
+	// Weight is the percentage of the requests between 0 and 100 that are sent to the alternate URL
+	Weight   int                      `yaml:"weight,omitempty"`
+	Rollback SubgraphEndpointRollback `yaml:"rollback,omitempty"`
+}
+
+// SubgraphEndpointRollback switches all requests back to the routing URL when the alternate URL fails
+type SubgraphEndpointRollback struct {
+	// ErrorRate is the share of the failed requests between 0 and 1 to the alternate URL above which the requests are
+	// switched back. Zero disables the rollback.
+	ErrorRate float64 `yaml:"error_rate,omitempty"`
+	// MinRequests is the number of requests in the window before the error rate is evaluated, 10 when it is zero
+	MinRequests int `yaml:"min_requests,omitempty"`
+	// Window is the interval in which the error rate is measured, 1m when it is zero
+	Window time.Duration `yaml:"window,omitempty"`
+}
+
+// SubgraphMocksConfiguration answers the requests to the subgraphs with generated data that matches their schemas, to
+// develop the clients against an incomplete graph. It must only be used for development.
+type SubgraphMocksConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SUBGRAPH_MOCKS_ENABLED"`
+	// Unreachable mocks the requests to the subgraphs that can't be reached
+	Unreachable bool `yaml:"unreachable" default:"true" envconfig:"SUBGRAPH_MOCKS_UNREACHABLE"`
+	// Subgraphs are the names of the subgraphs whose requests are mocked without sending them
+	Subgraphs []string `yaml:"subgraphs,omitempty" envconfig:"SUBGRAPH_MOCKS_SUBGRAPHS"`
+	// ListLength is the number of the items of the generated lists
+	ListLength int `yaml:"list_length" default:"2" envconfig:"SUBGRAPH_MOCKS_LIST_LENGTH"`
+	// Fixtures replace the generated values of the fields by their coordinate, e.g. Employee.name, and of the
+	// scalars by their name, e.g. DateTime
+	Fixtures map[string]any `yaml:"fixtures,omitempty"`
+}
+
+// LocalCompositionConfiguration composes the router config from local subgraph SDL files instead of loading it from
+// the router config path or the CDN, for a standalone development loop without wgc and the control plane
+type LocalCompositionConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"LOCAL_COMPOSITION_ENABLED"`
+	// Watch composes the config again when a schema file changes
+	Watch         bool                       `yaml:"watch" default:"true" envconfig:"LOCAL_COMPOSITION_WATCH"`
+	WatchInterval time.Duration              `yaml:"watch_interval" default:"1s" envconfig:"LOCAL_COMPOSITION_WATCH_INTERVAL"`
+	Subgraphs     []LocalCompositionSubgraph `yaml:"subgraphs,omitempty"`
+}
+
+type LocalCompositionSubgraph struct {
+	Name       string `yaml:"name"`
+	RoutingURL string `yaml:"routing_url"`
+	// SchemaFile is the path of the SDL file of the subgraph
+	SchemaFile string `yaml:"schema_file"`
+}
+
+// RequestMemoryLimitConfiguration limits the approximate memory that a single request uses while it is executed
+type RequestMemoryLimitConfiguration struct {
+	Enabled bool        `yaml:"enabled" default:"false" envconfig:"REQUEST_MEMORY_LIMIT_ENABLED"`
+	MaxSize BytesString `yaml:"max_size" default:"64MB" envconfig:"REQUEST_MEMORY_LIMIT_MAX_SIZE"`
+}
+
+// AdaptiveConcurrencyConfiguration limits the in-flight requests of every subgraph. The limit is increased additively
+// while the subgraph responds fast and decreased multiplicatively when its latency grows or it fails.
+type AdaptiveConcurrencyConfiguration struct {
+	Enabled      bool `yaml:"enabled" default:"false" envconfig:"ADAPTIVE_CONCURRENCY_ENABLED"`
+	InitialLimit int  `yaml:"initial_limit" default:"20" envconfig:"ADAPTIVE_CONCURRENCY_INITIAL_LIMIT"`
+	MinLimit     int  `yaml:"min_limit" default:"1" envconfig:"ADAPTIVE_CONCURRENCY_MIN_LIMIT"`
+	MaxLimit     int  `yaml:"max_limit" default:"500" envconfig:"ADAPTIVE_CONCURRENCY_MAX_LIMIT"`
+	// LatencyTolerance is the ratio of the latency to the latency without load above which the limit is decreased
+	LatencyTolerance float64 `yaml:"latency_tolerance" default:"2" envconfig:"ADAPTIVE_CONCURRENCY_LATENCY_TOLERANCE"`
+	// Backoff is the factor by which the limit is decreased
+	Backoff float64 `yaml:"backoff" default:"0.9" envconfig:"ADAPTIVE_CONCURRENCY_BACKOFF"`
+	// MaxWait is how long a request waits for a free slot before it is rejected. Zero rejects it immediately.
+	MaxWait time.Duration `yaml:"max_wait" default:"50ms" envconfig:"ADAPTIVE_CONCURRENCY_MAX_WAIT"`
+}
+
+// ResponseFormatsConfiguration enables the optional encodings of the responses. The format of a response is
+// negotiated with the Accept header of the request.
+type ResponseFormatsConfiguration struct {
+	// CBOR encodes the responses with CBOR for the clients that accept application/cbor
+	CBOR bool `yaml:"cbor" default:"false" envconfig:"RESPONSE_FORMATS_CBOR"`
+}
+
+// TenantsConfiguration overrides selected settings per tenant of a multi-tenant router. The overrides are loaded
+// from a file that is reloaded when it changes.
+type TenantsConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"TENANTS_ENABLED"`
+	// Header is the request header with the ID of the tenant
+	Header string `yaml:"header,omitempty" envconfig:"TENANTS_HEADER"`
+	// Claim is the claim of the token with the ID of the tenant. It takes precedence over the header.
+	Claim string `yaml:"claim,omitempty" envconfig:"TENANTS_CLAIM"`
+	// File contains the overrides by the ID of the tenant
+	File           string        `yaml:"file,omitempty" envconfig:"TENANTS_FILE"`
+	ReloadInterval time.Duration `yaml:"reload_interval" default:"10s" envconfig:"TENANTS_RELOAD_INTERVAL"`
+}
+
+// RequestCoalescingConfiguration executes identical concurrent queries once and serves the response to all of them
+type RequestCoalescingConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"REQUEST_COALESCING_ENABLED"`
+	// ScopeHeaders are the request headers that scope the coalescing in addition to the claims of the token. Only
+	// requests with the same values of the headers share a response.
+	ScopeHeaders []string `yaml:"scope_headers" default:"Authorization,Cookie" envconfig:"REQUEST_COALESCING_SCOPE_HEADERS"`
+}
+
+// SealedVariablesConfiguration decrypts the sealed values of the variables before the operations are executed, so that
+// sensitive inputs are neither visible to the intermediaries between the clients and the router nor in the logs
+type SealedVariablesConfiguration struct {
+	Enabled bool                 `yaml:"enabled" default:"false" envconfig:"SEALED_VARIABLES_ENABLED"`
+	Keys    []SealedVariablesKey `yaml:"keys,omitempty"`
+}
+
+// SealedVariablesKey is an AES-256 key of the sealed values. The ID of the key is part of the sealed values, so that
+// keys can be rotated.
+type SealedVariablesKey struct {
+	ID string `yaml:"id"`
+	// Secret is the base64 encoded key of 32 bytes
+	Secret     string `yaml:"secret,omitempty"`
+	SecretFile string `yaml:"secret_file,omitempty"`
+}
+
+// NonGraphQLRequestsConfiguration logs and counts the requests that don't reach the GraphQL handler, e.g. the CORS
+// preflight requests, the health checks, the playground and unknown paths
+type NonGraphQLRequestsConfiguration struct {
+	// AccessLogs writes the requests to the access logs
+	AccessLogs bool `yaml:"access_logs" default:"false" envconfig:"NON_GRAPHQL_REQUESTS_ACCESS_LOGS"`
+	// Metrics counts the requests by their kind, path and status code
+	Metrics bool `yaml:"metrics" default:"false" envconfig:"NON_GRAPHQL_REQUESTS_METRICS"`
+	// ExcludePaths are neither logged nor counted, e.g. the health checks of a load balancer
+	ExcludePaths []string `yaml:"exclude_paths,omitempty" envconfig:"NON_GRAPHQL_REQUESTS_EXCLUDE_PATHS"`
+}
+
+// ContractEnforcementConfiguration removes the fields that are excluded from the contract from the subgraph
+// responses, in case a subgraph returns them without being asked for
+type ContractEnforcementConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"CONTRACT_ENFORCEMENT_ENABLED"`
+	// ExcludeTags excludes the fields with these tags in addition to the fields that aren't part of the client schema
+	ExcludeTags         []string `yaml:"exclude_tags,omitempty" envconfig:"CONTRACT_ENFORCEMENT_EXCLUDE_TAGS"`
+	MaxLoggedViolations int      `yaml:"max_logged_violations" default:"10" envconfig:"CONTRACT_ENFORCEMENT_MAX_LOGGED_VIOLATIONS"`
+}
+
+// SlowSubgraphWarningsConfiguration logs a warning when the latency of a subgraph request exceeds the p95 latency of
+// the latest requests of the subgraph by a factor
+type SlowSubgraphWarningsConfiguration struct {
+	Enabled bool `yaml:"enabled" default:"false" envconfig:"SLOW_SUBGRAPH_WARNINGS_ENABLED"`
+	// Factor is the ratio of the latency to the p95 latency of the subgraph above which a request is slow
+	Factor float64 `yaml:"factor" default:"3" envconfig:"SLOW_SUBGRAPH_WARNINGS_FACTOR"`
+	// WindowSize is the number of the latest requests of a subgraph that make up its baseline
+	WindowSize int `yaml:"window_size" default:"1000" envconfig:"SLOW_SUBGRAPH_WARNINGS_WINDOW_SIZE"`
+	// MinSamples is the number of requests of a subgraph before its requests are warned about
+	MinSamples int `yaml:"min_samples" default:"100" envconfig:"SLOW_SUBGRAPH_WARNINGS_MIN_SAMPLES"`
+	// LogInterval is the minimum time between two warnings of the same subgraph
+	LogInterval time.Duration `yaml:"log_interval" default:"1m" envconfig:"SLOW_SUBGRAPH_WARNINGS_LOG_INTERVAL"`
+}
+
 type Config struct {
 	Version string `yaml:"version,omitempty" ignored:"true"`
 
@@ -440,9 +1915,16 @@ type Config struct {
 	IntrospectionEnabled          bool                        `yaml:"introspection_enabled" default:"true" envconfig:"INTROSPECTION_ENABLED"`
 	LogLevel                      string                      `yaml:"log_level" default:"info" envconfig:"LOG_LEVEL"`
 	JSONLog                       bool                        `yaml:"json_log" default:"true" envconfig:"JSON_LOG"`
+	LogEncoding                   string                      `yaml:"log_encoding,omitempty" envconfig:"LOG_ENCODING"`
+	LogTimeFormat                 string                      `yaml:"log_time_format,omitempty" envconfig:"LOG_TIME_FORMAT"`
+	LogDurationFormat             string                      `yaml:"log_duration_format,omitempty" envconfig:"LOG_DURATION_FORMAT"`
+	LogPretty                     LogPrettyConfiguration      `yaml:"log_pretty,omitempty"`
+	LogOutput                     string                      `yaml:"log_output" default:"stdout" envconfig:"LOG_OUTPUT"`
+	JSONLogStacktraceFrames       bool                        `yaml:"json_log_stacktrace_frames" default:"false" envconfig:"JSON_LOG_STACKTRACE_FRAMES"`
 	ShutdownDelay                 time.Duration               `yaml:"shutdown_delay" default:"60s" envconfig:"SHUTDOWN_DELAY"`
 	GracePeriod                   time.Duration               `yaml:"grace_period" default:"30s" envconfig:"GRACE_PERIOD"`
 	PollInterval                  time.Duration               `yaml:"poll_interval" default:"10s" envconfig:"POLL_INTERVAL"`
+	ConfigDriftThreshold          time.Duration               `yaml:"config_drift_threshold" default:"5m" envconfig:"CONFIG_DRIFT_THRESHOLD"`
 	HealthCheckPath               string                      `yaml:"health_check_path" default:"/health" envconfig:"HEALTH_CHECK_PATH"`
 	ReadinessCheckPath            string                      `yaml:"readiness_check_path" default:"/health/ready" envconfig:"READINESS_CHECK_PATH"`
 	LivenessCheckPath             string                      `yaml:"liveness_check_path" default:"/health/live" envconfig:"LIVENESS_CHECK_PATH"`
@@ -458,6 +1940,9 @@ type Config struct {
 
 	RouterConfigPath   string `yaml:"router_config_path,omitempty" envconfig:"ROUTER_CONFIG_PATH"`
 	RouterRegistration bool   `yaml:"router_registration" envconfig:"ROUTER_REGISTRATION" default:"true"`
+	// RouterConfigFallbackPath caches the last applied router config to start with when the initial config can't be
+	// fetched
+	RouterConfigFallbackPath string `yaml:"router_config_fallback_path,omitempty" envconfig:"ROUTER_CONFIG_FALLBACK_PATH"`
 
 	OverrideRoutingURL OverrideRoutingURLConfiguration `yaml:"override_routing_url"`
 
@@ -469,15 +1954,177 @@ type Config struct {
 
 	WebSocket WebSocketConfiguration `yaml:"websocket,omitempty"`
 
+	SubscriptionLimits SubscriptionLimitsConfiguration `yaml:"subscription_limits,omitempty"`
+
+	SubscriptionReaping SubscriptionReapingConfiguration `yaml:"subscription_reaping,omitempty"`
+
+	SubscriptionBackpressure SubscriptionBackpressureConfiguration `yaml:"subscription_backpressure,omitempty"`
+
 	SubgraphErrorPropagation SubgraphErrorPropagationConfiguration `yaml:"subgraph_error_propagation"`
+
+	Admin AdminConfiguration `yaml:"admin,omitempty"`
+
+	Maintenance MaintenanceConfiguration `yaml:"maintenance,omitempty"`
+
+	StartupReport StartupReportConfiguration `yaml:"startup_report,omitempty"`
+
+	Memory MemoryConfiguration `yaml:"memory,omitempty"`
+
+	VersionEndpoint VersionEndpointConfiguration `yaml:"version_endpoint,omitempty"`
+
+	CustomResponseHeaders CustomResponseHeadersConfiguration `yaml:"custom_response_headers,omitempty"`
+
+	ConnectionTimings ConnectionTimingsConfiguration `yaml:"connection_timings,omitempty"`
+
+	ProblemDetails ProblemDetailsConfiguration `yaml:"problem_details,omitempty"`
+
+	Server ServerConfiguration `yaml:"server,omitempty"`
+
+	AccessLogs AccessLogsConfiguration `yaml:"access_logs,omitempty"`
+
+	LogEscalation LogEscalationConfiguration `yaml:"log_escalation,omitempty"`
+
+	PanicRecovery PanicRecoveryConfiguration `yaml:"panic_recovery,omitempty"`
+
+	DeprecationWarnings DeprecationWarningsConfiguration `yaml:"deprecation_warnings,omitempty"`
+
+	ClientProtocols ClientProtocolsConfiguration `yaml:"client_protocols,omitempty"`
+
+	LogRetention LogRetentionConfiguration `yaml:"log_retention,omitempty"`
+
+	LogFiles LogFilesConfiguration `yaml:"log_files,omitempty"`
+
+	LogLevelOutputs []LogLevelOutput `yaml:"log_level_outputs,omitempty"`
+
+	LogSubgraphLevels map[string]string `yaml:"log_subgraph_levels,omitempty" envconfig:"LOG_SUBGRAPH_LEVELS"`
+
+	LogBuffering LogBufferingConfiguration `yaml:"log_buffering,omitempty"`
+
+	LogAsync LogAsyncConfiguration `yaml:"log_async,omitempty"`
+
+	LogShutdownFlush LogShutdownFlushConfiguration `yaml:"log_shutdown_flush,omitempty"`
+
+	LogRedaction LogRedactionConfiguration `yaml:"log_redaction,omitempty"`
+
+	VariableRedaction VariableRedactionConfiguration `yaml:"variable_redaction,omitempty"`
+
+	LogSinks LogSinksConfiguration `yaml:"log_sinks,omitempty"`
+
+	LogSampling LogSamplingConfiguration `yaml:"log_sampling,omitempty"`
+
+	LogDeduplication LogDeduplicationConfiguration `yaml:"log_deduplication,omitempty"`
+
+	LogFilters LogFiltersConfiguration `yaml:"log_filters,omitempty"`
+
+	SLO SLOConfiguration `yaml:"slo,omitempty"`
+
+	TopOperations TopOperationsConfiguration `yaml:"top_operations,omitempty"`
+
+	AnomalyDetection AnomalyDetectionConfiguration `yaml:"anomaly_detection,omitempty"`
+
+	LifecycleWebhooks LifecycleWebhooksConfiguration `yaml:"lifecycle_webhooks,omitempty"`
+
+	SubgraphAuthentication SubgraphAuthenticationConfiguration `yaml:"subgraph_authentication,omitempty"`
+
+	RequestSigning RequestSigningConfiguration `yaml:"request_signing,omitempty"`
+
+	BotDetection BotDetectionConfiguration `yaml:"bot_detection,omitempty"`
+
+	OperationTimeouts OperationTimeoutsConfiguration `yaml:"operation_timeouts,omitempty"`
+
+	FetchConcurrency FetchConcurrencyConfiguration `yaml:"fetch_concurrency,omitempty"`
+
+	EntityBatching EntityBatchingConfiguration `yaml:"entity_batching,omitempty"`
+
+	SubgraphResponseValidation SubgraphResponseValidationConfiguration `yaml:"subgraph_response_validation,omitempty"`
+
+	ResponseSizeLimit ResponseSizeLimitConfiguration `yaml:"response_size_limit,omitempty"`
+
+	PersistedOperationUsage PersistedOperationUsageConfiguration `yaml:"persisted_operation_usage,omitempty"`
+
+	ConfigAudit ConfigAuditConfiguration `yaml:"config_audit,omitempty"`
+
+	AuditLog AuditLogConfiguration `yaml:"audit_log,omitempty"`
+
+	ExecutionAudit ExecutionAuditConfiguration `yaml:"execution_audit,omitempty"`
+
+	RESTEndpoints RESTEndpointsConfiguration `yaml:"rest_endpoints,omitempty"`
+
+	SubscriptionWebhooks SubscriptionWebhooksConfiguration `yaml:"subscription_webhooks,omitempty"`
+
+	SurrogateKeys SurrogateKeysConfiguration `yaml:"surrogate_keys,omitempty"`
+
+	ETags ETagsConfiguration `yaml:"etags,omitempty"`
+
+	OperationFingerprint OperationFingerprintConfiguration `yaml:"operation_fingerprint,omitempty"`
+
+	PersistedOperationManifest PersistedOperationManifestConfiguration `yaml:"persisted_operation_manifest,omitempty"`
+
+	RequestTags RequestTagsConfiguration `yaml:"request_tags,omitempty"`
+
+	SyntheticHealthOperation SyntheticHealthOperationConfiguration `yaml:"synthetic_health_operation,omitempty"`
+
+	Chaos ChaosConfiguration `yaml:"chaos,omitempty"`
+
+	SubgraphCompression SubgraphCompressionConfiguration `yaml:"subgraph_compression,omitempty"`
+
+	SubgraphPayloadSize SubgraphPayloadSizeConfiguration `yaml:"subgraph_payload_size,omitempty"`
+
+	ClockSkew ClockSkewConfiguration `yaml:"clock_skew,omitempty"`
+
+	CertificateExpiry CertificateExpiryConfiguration `yaml:"certificate_expiry,omitempty"`
+
+	SubgraphEndpoints SubgraphEndpointsConfiguration `yaml:"subgraph_endpoints,omitempty"`
+
+	SubgraphMocks SubgraphMocksConfiguration `yaml:"subgraph_mocks,omitempty"`
+
+	LocalComposition LocalCompositionConfiguration `yaml:"local_composition,omitempty"`
+
+	RequestMemoryLimit RequestMemoryLimitConfiguration `yaml:"request_memory_limit,omitempty"`
+
+	AdaptiveConcurrency AdaptiveConcurrencyConfiguration `yaml:"adaptive_concurrency,omitempty"`
+
+	ResponseFormats ResponseFormatsConfiguration `yaml:"response_formats,omitempty"`
+
+	Tenants TenantsConfiguration `yaml:"tenants,omitempty"`
+
+	RequestCoalescing RequestCoalescingConfiguration `yaml:"request_coalescing,omitempty"`
+
+	SealedVariables SealedVariablesConfiguration `yaml:"sealed_variables,omitempty"`
+
+	NonGraphQLRequests NonGraphQLRequestsConfiguration `yaml:"non_graphql_requests,omitempty"`
+
+	ContractEnforcement ContractEnforcementConfiguration `yaml:"contract_enforcement,omitempty"`
+
+	SlowSubgraphWarnings SlowSubgraphWarningsConfiguration `yaml:"slow_subgraph_warnings,omitempty"`
+
+	SubgraphTunnels SubgraphTunnelsConfiguration `yaml:"subgraph_tunnels,omitempty"`
 }
 
 type LoadResult struct {
 	Config        Config
 	DefaultLoaded bool
+	// Files are the config files that were merged into the config, in the order of the merge
+	Files []string
 }
 
 func LoadConfig(configFilePath string, envOverride string) (*LoadResult, error) {
+	return LoadConfigWithProfile(configFilePath, "", envOverride)
+}
+
+// LoadConfigWithProfile loads the config from layered config files. The files are merged in this order:
+//
+//  1. The base file. configFilePath can list several files separated by commas, which are merged in the listed order.
+//  2. The overlay of the profile, e.g. config.production.yaml next to the base file config.yaml for the profile
+//     production. It must exist when a profile is set. Without a profile, CONFIG_PROFILE is used.
+//  3. The local override next to the base file, e.g. config.local.yaml. It is optional, like .env.local.
+//
+// The environment variables are expanded in every file before the merge. The merge semantics are:
+//
+//   - Objects are merged key by key, recursively.
+//   - Scalars and lists of a later file replace the values of the earlier files. Lists are not appended.
+//   - A key that is set to null in a later file is removed, so that its default applies again.
+func LoadConfigWithProfile(configFilePath string, profile string, envOverride string) (*LoadResult, error) {
 	_ = godotenv.Load(".env.local")
 	_ = godotenv.Load()
 
@@ -497,9 +2144,7 @@ func LoadConfig(configFilePath string, envOverride string) (*LoadResult, error)
 		return nil, err
 	}
 
-	// Read the custom config file
-
-	var configFileBytes []byte
+	// Read the custom config files
 
 	if configFilePath == "" {
 		configFilePath = os.Getenv("CONFIG_PATH")
@@ -507,23 +2152,42 @@ func LoadConfig(configFilePath string, envOverride string) (*LoadResult, error)
 			configFilePath = DefaultConfigPath
 		}
 	}
+	if profile == "" {
+		profile = os.Getenv("CONFIG_PROFILE")
+	}
 
-	isDefaultConfigPath := configFilePath == DefaultConfigPath
-	configFileBytes, err = os.ReadFile(configFilePath)
-
+	files, err := configFiles(configFilePath, profile)
 	if err != nil {
-		if isDefaultConfigPath {
-			cfg.DefaultLoaded = false
-		} else {
-			return nil, fmt.Errorf("could not read custom config file %s: %w", configFilePath, err)
+		return nil, err
+	}
+
+	var layers [][]byte
+	for _, file := range files {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			if file.optional {
+				continue
+			}
+			if file.path == DefaultConfigPath {
+				cfg.DefaultLoaded = false
+				continue
+			}
+			return nil, fmt.Errorf("could not read custom config file %s: %w", file.path, err)
 		}
+
+		// Expand environment variables in the config file
+		layers = append(layers, []byte(os.ExpandEnv(string(data))))
+		cfg.Files = append(cfg.Files, file.path)
 	}
 
-	// Expand environment variables in the config file
-	// and unmarshal it into the config struct
+	configFileBytes, err := mergeConfigLayers(layers, cfg.Files)
+	if err != nil {
+		return nil, err
+	}
 
-	configYamlData := os.ExpandEnv(string(configFileBytes))
-	if err := yaml.Unmarshal([]byte(configYamlData), &cfg.Config); err != nil {
+	// Unmarshal the config file into the config struct
+
+	if err := yaml.Unmarshal(configFileBytes, &cfg.Config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal router config: %w", err)
 	}
 
@@ -550,8 +2214,12 @@ func LoadConfig(configFilePath string, envOverride string) (*LoadResult, error)
 
 	// Custom validation for the config
 
-	if cfg.Config.RouterConfigPath == "" && cfg.Config.Graph.Token == "" {
-		return nil, fmt.Errorf("either router config path or graph token must be provided")
+	if cfg.Config.LocalComposition.Enabled {
+		if cfg.Config.RouterConfigPath != "" {
+			return nil, fmt.Errorf("the local composition and the router config path are mutually exclusive")
+		}
+	} else if cfg.Config.RouterConfigPath == "" && cfg.Config.Graph.Token == "" {
+		return nil, fmt.Errorf("either router config path, graph token or local composition must be provided")
 	}
 
 	// Post-process the config
@@ -564,3 +2232,83 @@ func LoadConfig(configFilePath string, envOverride string) (*LoadResult, error)
 
 	return cfg, nil
 }
+
+type configFile struct {
+	path     string
+	optional bool
+}
+
+// configFiles returns the files of the layered config in the order of the merge
+func configFiles(configFilePath string, profile string) ([]configFile, error) {
+	var files []configFile
+	for _, path := range strings.Split(configFilePath, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			files = append(files, configFile{path: path})
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("the config path lists no config file")
+	}
+
+	base := files[0].path
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+
+	if profile != "" {
+		if profile == "local" || strings.ContainsAny(profile, `/\`) {
+			return nil, fmt.Errorf("invalid config profile '%s'", profile)
+		}
+		files = append(files, configFile{path: name + "." + profile + ext})
+	}
+	files = append(files, configFile{path: name + ".local" + ext, optional: true})
+
+	return files, nil
+}
+
+// mergeConfigLayers merges the YAML documents of the config files. A single document is returned as is.
+func mergeConfigLayers(layers [][]byte, files []string) ([]byte, error) {
+	if len(layers) == 0 {
+		return nil, nil
+	}
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+
+	var merged any
+	for i, layer := range layers {
+		var value any
+		if err := yaml.Unmarshal(layer, &value); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal router config file %s: %w", files[i], err)
+		}
+		merged = mergeConfigValues(merged, value)
+	}
+
+	return yaml.Marshal(merged)
+}
+
+// mergeConfigValues merges the overlay into the base. Objects are merged recursively, all other values of the overlay
+// replace the base. A null value in the overlay removes the key.
+func mergeConfigValues(base, overlay any) any {
+	baseMap, ok := base.(map[string]any)
+	if !ok {
+		return overlay
+	}
+	overlayMap, ok := overlay.(map[string]any)
+	if !ok {
+		// An empty file doesn't change the config
+		if overlay == nil {
+			return base
+		}
+		return overlay
+	}
+
+	for key, value := range overlayMap {
+		if value == nil {
+			delete(baseMap, key)
+			continue
+		}
+		baseMap[key] = mergeConfigValues(baseMap[key], value)
+	}
+
+	return baseMap
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 	"time"
@@ -16,7 +17,7 @@ func TestConfigRequiredValues(t *testing.T) {
 version: "1"
 `)
 	_, err := LoadConfig(f, "")
-	require.ErrorContains(t, err, "either router config path or graph token must be provided")
+	require.ErrorContains(t, err, "either router config path, graph token or local composition must be provided")
 }
 
 func TestTokenNotRequiredWhenPassingStaticConfig(t *testing.T) {
@@ -296,3 +297,70 @@ overrides:
 	require.ErrorAs(t, err, &js)
 	require.Equal(t, js.Causes[0].Message, "'a' is not valid 'http-url'")
 }
+
+func TestLayeredConfig(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+
+	require.NoError(t, os.WriteFile(base, []byte(`
+version: "1"
+
+graph:
+  token: "token"
+
+poll_interval: 11s
+headers:
+  all:
+    request:
+      - op: propagate
+        named: X-Base
+cors:
+  allow_origins:
+    - https://base.example.com
+  max_age: 1h
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.production.yaml"), []byte(`
+poll_interval: 22s
+cors:
+  allow_origins:
+    - https://production.example.com
+headers: null
+`), 0o600))
+
+	t.Run("merges the overlay of the profile", func(t *testing.T) {
+		result, err := LoadConfigWithProfile(base, "production", "")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{base, filepath.Join(dir, "config.production.yaml")}, result.Files)
+		require.Equal(t, 22*time.Second, result.Config.PollInterval)
+		// Lists are replaced, the other keys of an object are kept
+		require.Equal(t, []string{"https://production.example.com"}, result.Config.CORS.AllowOrigins)
+		require.Equal(t, time.Hour, result.Config.CORS.MaxAge)
+		// Null removes the key
+		require.Empty(t, result.Config.Headers.All)
+	})
+
+	t.Run("merges the listed files and the local override", func(t *testing.T) {
+		extra := filepath.Join(dir, "extra.yaml")
+		require.NoError(t, os.WriteFile(extra, []byte("poll_interval: 33s\n"), 0o600))
+		local := filepath.Join(dir, "config.local.yaml")
+		require.NoError(t, os.WriteFile(local, []byte("listen_addr: localhost:4000\n"), 0o600))
+		t.Cleanup(func() { require.NoError(t, os.Remove(local)) })
+
+		result, err := LoadConfig(base+", "+extra, "")
+		require.NoError(t, err)
+
+		require.Equal(t, []string{base, extra, local}, result.Files)
+		require.Equal(t, 33*time.Second, result.Config.PollInterval)
+		require.Equal(t, "token", result.Config.Graph.Token)
+		require.Equal(t, "localhost:4000", result.Config.ListenAddr)
+	})
+
+	t.Run("requires the overlay of the profile", func(t *testing.T) {
+		_, err := LoadConfigWithProfile(base, "staging", "")
+		require.ErrorContains(t, err, "could not read custom config file "+filepath.Join(dir, "config.staging.yaml"))
+
+		_, err = LoadConfigWithProfile(base, "../production", "")
+		require.EqualError(t, err, "invalid config profile '../production'")
+	})
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"errors"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	AccessLogOutputStdout = "stdout"
+	AccessLogOutputStderr = "stderr"
+	AccessLogOutputFile   = "file"
+
+	AccessLogEncodingConsole = "console"
+)
+
+type AccessLoggerOptions struct {
+	// Output is stdout, stderr or file. If empty, the entries are written to stdout.
+	Output string
+	// Encoding is a built-in or registered encoding, e.g. json, console or msgpack. If empty, the entries are
+	// encoded as JSON.
+	Encoding string
+	// File is the rotated file of the file output. Its encoding is ignored.
+	File FileOutput
+	// Fields are the fields of the entries that are written. If empty, all fields are written.
+	Fields []string
+	// Rotator rotates the file on demand. Optional.
+	Rotator *FileRotator
+	// Writer replaces the output, e.g. with a MemorySink in tests. Optional.
+	Writer zapcore.WriteSyncer
+}
+
+// NewAccessLogger creates the logger of the access logs. It is separate from the logger of the router, so that the
+// access logs are written to their own output regardless of the level of the router, and can be reduced to a set
+// of fields.
+func NewAccessLogger(opts *AccessLoggerOptions) (*zap.Logger, error) {
+	var syncer zapcore.WriteSyncer
+	switch {
+	case opts.Writer != nil:
+		syncer = opts.Writer
+	case opts.Output == "", opts.Output == AccessLogOutputStdout:
+		syncer = zapcore.AddSync(os.Stdout)
+	case opts.Output == AccessLogOutputStderr:
+		syncer = zapcore.AddSync(os.Stderr)
+	case opts.Output == AccessLogOutputFile:
+		if opts.File.Path == "" {
+			return nil, errors.New("the path of the access log file must not be empty")
+		}
+		syncer = zapcore.AddSync(newRotatedFile(&opts.File, opts.Rotator))
+	default:
+		return nil, errors.New("unknown access log output '" + opts.Output + "'")
+	}
+
+	encoding := opts.Encoding
+	if encoding == "" {
+		encoding = EncodingJSON
+	}
+	encoder, err := NewEncoder(encoding)
+	if err != nil {
+		return nil, errors.New("unknown access log encoding '" + opts.Encoding + "'")
+	}
+
+	// The access logs are always written, the level of the router doesn't apply
+	var core zapcore.Core = zapcore.NewCore(encoder, syncer, zapcore.InfoLevel)
+	if len(opts.Fields) > 0 {
+		allowed := make(map[string]struct{}, len(opts.Fields))
+		for _, field := range opts.Fields {
+			if field == "" {
+				return nil, errors.New("the fields of the access log must not be empty")
+			}
+			allowed[field] = struct{}{}
+		}
+		core = &fieldFilterCore{Core: core, allowed: allowed}
+	}
+
+	return zap.New(core).Named("access"), nil
+}
+
+// fieldFilterCore only writes the allowed fields of the entries
+type fieldFilterCore struct {
+	zapcore.Core
+	allowed map[string]struct{}
+}
+
+func (c *fieldFilterCore) filter(fields []zapcore.Field) []zapcore.Field {
+	filtered := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		if _, ok := c.allowed[field.Key]; ok {
+			filtered = append(filtered, field)
+		}
+	}
+	return filtered
+}
+
+func (c *fieldFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &fieldFilterCore{Core: c.Core.With(c.filter(fields)), allowed: c.allowed}
+}
+
+func (c *fieldFilterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *fieldFilterCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.filter(fields))
+}
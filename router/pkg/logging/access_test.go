@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewAccessLogger(t *testing.T) {
+	accessLog := filepath.Join(t.TempDir(), "access.log")
+
+	logger, err := NewAccessLogger(&AccessLoggerOptions{
+		Output: AccessLogOutputFile,
+		File:   FileOutput{Path: accessLog, MaxBackups: 3},
+		Fields: []string{"method", "status", "config_version"},
+	})
+	require.NoError(t, err)
+
+	logger = logger.With(zap.String("config_version", "v1"), zap.String("feature_flag", "ff"))
+	logger.Info("/graphql", zap.String("method", "POST"), zap.Int("status", 200), zap.String("query", "a=b"))
+	// The level of the router doesn't apply, debug entries are never access logs
+	logger.Debug("/debug")
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(accessLog)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `"logger":"access","msg":"/graphql","config_version":"v1","method":"POST","status":200}`)
+	require.NotContains(t, lines[0], "feature_flag")
+	require.NotContains(t, lines[0], "query")
+}
+
+func TestNewAccessLoggerValidation(t *testing.T) {
+	_, err := NewAccessLogger(&AccessLoggerOptions{Output: AccessLogOutputFile})
+	require.EqualError(t, err, "the path of the access log file must not be empty")
+
+	_, err = NewAccessLogger(&AccessLoggerOptions{Output: "syslog"})
+	require.EqualError(t, err, "unknown access log output 'syslog'")
+
+	_, err = NewAccessLogger(&AccessLoggerOptions{Encoding: "xml"})
+	require.EqualError(t, err, "unknown access log encoding 'xml'")
+
+	_, err = NewAccessLogger(&AccessLoggerOptions{Fields: []string{""}})
+	require.EqualError(t, err, "the fields of the access log must not be empty")
+}
@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/internal/anonymize"
+)
+
+const (
+	// ClientIPField is the field of the address of the client, see WithClientIP
+	ClientIPField = "client_ip"
+	// ForwardedForField is the field of the addresses of the X-Forwarded-For header
+	ForwardedForField = "x_forwarded_for"
+)
+
+type IPAnonymizationMode string
+
+const (
+	// IPAnonymizationRedact replaces the addresses with "[REDACTED]"
+	IPAnonymizationRedact IPAnonymizationMode = "redact"
+	// IPAnonymizationHash replaces the addresses with a keyed hash whose salt is rotated
+	IPAnonymizationHash IPAnonymizationMode = "hash"
+	// IPAnonymizationTruncate zeroes the last octet of IPv4 addresses and truncates IPv6 addresses to /48
+	IPAnonymizationTruncate IPAnonymizationMode = "truncate"
+)
+
+type IPAnonymizationOptions struct {
+	Mode IPAnonymizationMode
+	// SaltRotationInterval is the interval after which the salt of the hash mode is replaced. Zero keeps the salt
+	// for the lifetime of the process.
+	SaltRotationInterval time.Duration
+	// Fields are the names of further fields with addresses, in addition to client_ip and x_forwarded_for
+	Fields []string
+}
+
+// IPAnonymizer anonymizes the client addresses in the well-known fields of log entries before they are written to
+// any output
+type IPAnonymizer struct {
+	mode   IPAnonymizationMode
+	hasher *anonymize.Hasher
+	fields map[string]struct{}
+}
+
+// NewIPAnonymizer creates an anonymizer. Wrap the cores of the loggers with Core or WithIPAnonymization.
+func NewIPAnonymizer(opts *IPAnonymizationOptions) (*IPAnonymizer, error) {
+	a := &IPAnonymizer{
+		mode: opts.Mode,
+		fields: map[string]struct{}{
+			ClientIPField:     {},
+			ForwardedForField: {},
+		},
+	}
+
+	switch opts.Mode {
+	case IPAnonymizationRedact, IPAnonymizationTruncate:
+	case IPAnonymizationHash:
+		a.hasher = anonymize.NewHasher(opts.SaltRotationInterval)
+	default:
+		return nil, fmt.Errorf("unknown ip anonymization mode '%s'", opts.Mode)
+	}
+
+	for _, field := range opts.Fields {
+		a.fields[field] = struct{}{}
+	}
+
+	return a, nil
+}
+
+// WithClientIP returns the field of the address of the client. The address is anonymized by the IPAnonymizer of the
+// logger.
+func WithClientIP(addr string) zap.Field {
+	return zap.String(ClientIPField, addr)
+}
+
+// Anonymize anonymizes the address, or every address of a comma separated list like X-Forwarded-For
+func (a *IPAnonymizer) Anonymize(addr string) string {
+	if !strings.Contains(addr, ",") {
+		return a.anonymize(strings.TrimSpace(addr))
+	}
+
+	addrs := strings.Split(addr, ",")
+	for i, part := range addrs {
+		addrs[i] = a.anonymize(strings.TrimSpace(part))
+	}
+	return strings.Join(addrs, ", ")
+}
+
+func (a *IPAnonymizer) anonymize(addr string) string {
+	if addr == "" {
+		return addr
+	}
+
+	switch a.mode {
+	case IPAnonymizationHash:
+		return a.hasher.Hash(addr)
+	case IPAnonymizationTruncate:
+		return anonymize.TruncateIP(addr)
+	default:
+		return DefaultRedactionReplacement
+	}
+}
+
+// WithIPAnonymization returns an option that anonymizes the addresses in the entries of the logger
+func WithIPAnonymization(a *IPAnonymizer) zap.Option {
+	return zap.WrapCore(a.Core)
+}
+
+// Core wraps the core, so that the addresses of all entries are anonymized before they are written to it
+func (a *IPAnonymizer) Core(core zapcore.Core) zapcore.Core {
+	return &ipAnonymizationCore{Core: core, anonymizer: a}
+}
+
+func (a *IPAnonymizer) anonymizeFields(fields []zapcore.Field) []zapcore.Field {
+	var anonymized []zapcore.Field
+	for i, field := range fields {
+		if _, ok := a.fields[field.Key]; !ok {
+			continue
+		}
+
+		var addr string
+		switch field.Type {
+		case zapcore.StringType:
+			addr = field.String
+		case zapcore.StringerType:
+			addr = fmt.Sprint(field.Interface)
+		default:
+			continue
+		}
+
+		// The fields of the caller are only copied when they contain an address
+		if anonymized == nil {
+			anonymized = make([]zapcore.Field, len(fields))
+			copy(anonymized, fields)
+		}
+		anonymized[i] = zap.String(field.Key, a.Anonymize(addr))
+	}
+
+	if anonymized == nil {
+		return fields
+	}
+	return anonymized
+}
+
+type ipAnonymizationCore struct {
+	zapcore.Core
+	anonymizer *IPAnonymizer
+}
+
+func (c *ipAnonymizationCore) With(fields []zapcore.Field) zapcore.Core {
+	return &ipAnonymizationCore{Core: c.Core.With(c.anonymizer.anonymizeFields(fields)), anonymizer: c.anonymizer}
+}
+
+func (c *ipAnonymizationCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *ipAnonymizationCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.anonymizer.anonymizeFields(fields))
+}
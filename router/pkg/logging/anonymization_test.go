@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestIPAnonymizer(t *testing.T) {
+	truncate, err := NewIPAnonymizer(&IPAnonymizationOptions{Mode: IPAnonymizationTruncate})
+	require.NoError(t, err)
+	require.Equal(t, "192.168.1.0", truncate.Anonymize("192.168.1.42:51234"))
+	require.Equal(t, "2001:db8:85a3::", truncate.Anonymize("2001:db8:85a3:8d3:1319:8a2e:370:7348"))
+	require.Equal(t, "203.0.113.0, 10.0.0.0", truncate.Anonymize("203.0.113.7, 10.0.0.1"))
+
+	hash, err := NewIPAnonymizer(&IPAnonymizationOptions{Mode: IPAnonymizationHash})
+	require.NoError(t, err)
+	require.Equal(t, hash.Anonymize("203.0.113.7"), hash.Anonymize("203.0.113.7"))
+	require.NotEqual(t, "203.0.113.7", hash.Anonymize("203.0.113.7"))
+
+	_, err = NewIPAnonymizer(&IPAnonymizationOptions{Mode: "mask"})
+	require.Error(t, err)
+}
+
+func TestWithIPAnonymization(t *testing.T) {
+	anonymizer, err := NewIPAnonymizer(&IPAnonymizationOptions{Mode: IPAnonymizationTruncate, Fields: []string{"peer"}})
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core, WithIPAnonymization(anonymizer)).With(WithClientIP("192.168.1.42"))
+
+	logger.Info("request",
+		zap.String(ForwardedForField, "203.0.113.7, 10.0.0.1"),
+		zap.Stringer("peer", net.ParseIP("198.51.100.23")),
+		zap.String("operation", "employees"),
+	)
+
+	fields := logs.All()[0].ContextMap()
+	require.Equal(t, "192.168.1.0", fields[ClientIPField])
+	require.Equal(t, "203.0.113.0, 10.0.0.0", fields[ForwardedForField])
+	require.Equal(t, "198.51.100.0", fields["peer"])
+	require.Equal(t, "employees", fields["operation"])
+
+	redact, err := NewIPAnonymizer(&IPAnonymizationOptions{Mode: IPAnonymizationRedact})
+	require.NoError(t, err)
+	core, logs = observer.New(zapcore.DebugLevel)
+	zap.New(core, WithIPAnonymization(redact)).Info("request", WithClientIP("192.168.1.42"))
+	require.Equal(t, DefaultRedactionReplacement, logs.All()[0].ContextMap()[ClientIPField])
+}
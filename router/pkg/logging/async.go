@@ -0,0 +1,189 @@
+package logging
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// AsyncPolicyDropOldest drops the oldest buffered entry when the buffer is full, so that logging never blocks
+	AsyncPolicyDropOldest = "drop_oldest"
+	// AsyncPolicyBlock blocks the logging until the buffer has space again, so that no entry is lost
+	AsyncPolicyBlock = "block"
+)
+
+const (
+	defaultAsyncSize          = 8192
+	defaultAsyncFlushInterval = time.Second
+)
+
+type AsyncOptions struct {
+	// Size is the number of entries that are buffered. If zero, 8192 entries are buffered.
+	Size int
+	// FlushInterval syncs the output periodically. If zero, the output is synced every second.
+	FlushInterval time.Duration
+	// Policy is drop_oldest or block. If empty, the oldest entries are dropped.
+	Policy string
+}
+
+// AsyncWriteSyncer writes to the output in the background, so that a slow disk or network doesn't stall the
+// handling of the requests. The entries are written in batches. When the buffer is full, the oldest entry is dropped
+// or the write blocks, depending on the policy. Stop the writer before exiting to write the remaining entries.
+type AsyncWriteSyncer struct {
+	output        zapcore.WriteSyncer
+	block         bool
+	flushInterval time.Duration
+
+	entries chan []byte
+	syncs   chan chan error
+	stop    chan struct{}
+	done    chan struct{}
+
+	// mu guards stopped, the writes hold it for reading while they enqueue
+	mu      sync.RWMutex
+	stopped bool
+
+	dropped atomic.Int64
+}
+
+func NewAsyncWriteSyncer(output zapcore.WriteSyncer, opts *AsyncOptions) (*AsyncWriteSyncer, error) {
+	w := &AsyncWriteSyncer{
+		output:        output,
+		flushInterval: opts.FlushInterval,
+		syncs:         make(chan chan error),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	switch opts.Policy {
+	case "", AsyncPolicyDropOldest:
+	case AsyncPolicyBlock:
+		w.block = true
+	default:
+		return nil, errors.New("unknown async log policy '" + opts.Policy + "'")
+	}
+
+	size := opts.Size
+	if size <= 0 {
+		size = defaultAsyncSize
+	}
+	w.entries = make(chan []byte, size)
+	if w.flushInterval <= 0 {
+		w.flushInterval = defaultAsyncFlushInterval
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Write enqueues a copy of the entry. After the writer is stopped, the entry is written synchronously.
+func (w *AsyncWriteSyncer) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.stopped {
+		return w.output.Write(p)
+	}
+
+	entry := make([]byte, len(p))
+	copy(entry, p)
+
+	if w.block {
+		w.entries <- entry
+		return len(p), nil
+	}
+
+	for {
+		select {
+		case w.entries <- entry:
+			return len(p), nil
+		default:
+		}
+		// Make room by dropping the oldest entry. A concurrent write can take the room, so it's tried again.
+		select {
+		case <-w.entries:
+			w.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// Sync waits until the entries that were enqueued before are written and syncs the output
+func (w *AsyncWriteSyncer) Sync() error {
+	w.mu.RLock()
+	if w.stopped {
+		w.mu.RUnlock()
+		return w.output.Sync()
+	}
+	result := make(chan error, 1)
+	w.syncs <- result
+	w.mu.RUnlock()
+
+	return <-result
+}
+
+// Stop writes the remaining entries and syncs the output. Later writes are written synchronously.
+func (w *AsyncWriteSyncer) Stop() error {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return nil
+	}
+	w.stopped = true
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+	return w.output.Sync()
+}
+
+// Dropped returns the number of entries that were dropped because the buffer was full
+func (w *AsyncWriteSyncer) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+func (w *AsyncWriteSyncer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	var batch []byte
+	// flush writes the entry and all entries that are buffered at the moment as one batch
+	flush := func(entry []byte) error {
+		batch = append(batch[:0], entry...)
+		for drained := false; !drained; {
+			select {
+			case entry := <-w.entries:
+				batch = append(batch, entry...)
+			default:
+				drained = true
+			}
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := w.output.Write(batch)
+		return err
+	}
+
+	for {
+		select {
+		case entry := <-w.entries:
+			// Like zap, the errors of the output can't be reported to the logger
+			_ = flush(entry)
+		case result := <-w.syncs:
+			err := flush(nil)
+			result <- errors.Join(err, w.output.Sync())
+		case <-ticker.C:
+			_ = w.output.Sync()
+		case <-w.stop:
+			_ = flush(nil)
+			return
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// blockingWriter blocks the writes until it is released
+type blockingWriter struct {
+	syncBuffer
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.syncBuffer.Write(p)
+}
+
+func (w *blockingWriter) Release() {
+	w.once.Do(func() { close(w.release) })
+}
+
+func TestAsyncWriteSyncer(t *testing.T) {
+	out := &syncBuffer{}
+	async, err := NewAsyncWriteSyncer(zapcore.AddSync(out), &AsyncOptions{Size: 16, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	logger := NewWithOutput(async, false, false, zap.InfoLevel)
+	logger.Info("a")
+	logger.Info("b")
+	require.NoError(t, logger.Sync())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"msg":"a"`)
+	require.Contains(t, lines[1], `"msg":"b"`)
+
+	require.NoError(t, async.Stop())
+	// The writes after the stop are written synchronously
+	logger.Info("c")
+	require.Contains(t, out.String(), `"msg":"c"`)
+	require.Equal(t, int64(0), async.Dropped())
+}
+
+func TestAsyncWriteSyncerDropOldest(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+	defer out.Release()
+
+	async, err := NewAsyncWriteSyncer(zapcore.AddSync(out), &AsyncOptions{Size: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	// The first write blocks the background writer, the others fill the buffer without blocking
+	for i := 0; i < 10; i++ {
+		_, err := async.Write([]byte{byte('0' + i)})
+		require.NoError(t, err)
+	}
+	require.GreaterOrEqual(t, async.Dropped(), int64(7))
+
+	out.Release()
+	require.NoError(t, async.Stop())
+	// The newest entries are kept
+	require.True(t, strings.HasSuffix(out.String(), "89"))
+	require.Equal(t, int64(10), int64(len(out.String()))+async.Dropped())
+}
+
+func TestAsyncWriteSyncerBlock(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+	defer out.Release()
+
+	async, err := NewAsyncWriteSyncer(zapcore.AddSync(out), &AsyncOptions{Size: 2, Policy: AsyncPolicyBlock})
+	require.NoError(t, err)
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 0; i < 10; i++ {
+			_, _ = async.Write([]byte{byte('0' + i)})
+		}
+	}()
+
+	select {
+	case <-written:
+		t.Fatal("the writes didn't block on the full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	out.Release()
+	<-written
+	require.NoError(t, async.Stop())
+	require.Equal(t, "0123456789", out.String())
+	require.Equal(t, int64(0), async.Dropped())
+
+	_, err = NewAsyncWriteSyncer(zapcore.AddSync(out), &AsyncOptions{Policy: "drop_newest"})
+	require.EqualError(t, err, "unknown async log policy 'drop_newest'")
+}
@@ -0,0 +1,274 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	AuditEventAuthentication      = "authentication"
+	AuditEventAuthorizationDenied = "authorization_denied"
+	AuditEventConfigReload        = "config_reload"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// auditHMACField is the last field of a chained entry
+var auditHMACField = []byte(`,"hmac":"`)
+
+// auditTailSize is the size of the end of an existing audit log that is read to continue its HMAC chain
+const auditTailSize = 64 << 10
+
+type AuditOptions struct {
+	// Path is the file the entries are appended to. It is created if it doesn't exist.
+	Path string
+	// HMACKey chains the entries with an HMAC-SHA256 of the entry and the HMAC of the previous entry, so that
+	// changed, removed or inserted entries are detected by VerifyAuditLog. If empty, the entries aren't chained.
+	HMACKey []byte
+	// Claims are the claims of the authenticated clients that are recorded, e.g. sub. All other claims are left out,
+	// so that the audit log doesn't keep personal data by accident.
+	Claims []string
+}
+
+// AuditLogger records the security relevant decisions of the router, the outcomes of the authentication, the
+// authorization denials and the config reloads, in a dedicated append-only file. The entries are written
+// regardless of the log level of the router. All methods can be called on a nil AuditLogger, when the audit log is
+// disabled.
+type AuditLogger struct {
+	logger *zap.Logger
+	file   *os.File
+	claims []string
+}
+
+// NewAuditLogger opens the audit log at the path. The HMAC chain of an existing file is continued.
+func NewAuditLogger(opts *AuditOptions) (*AuditLogger, error) {
+	if opts.Path == "" {
+		return nil, errors.New("the path of the audit log must not be empty")
+	}
+
+	var syncer zapcore.WriteSyncer
+	file, err := os.OpenFile(opts.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the audit log: %w", err)
+	}
+	syncer = file
+
+	if len(opts.HMACKey) > 0 {
+		prev, err := lastAuditHMAC(opts.Path)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		syncer = &hmacChain{syncer: file, key: opts.HMACKey, prev: prev}
+	}
+
+	logger := attachBaseFields(zap.New(zapcore.NewCore(ZapJsonEncoder(), syncer, zapcore.InfoLevel)))
+
+	return &AuditLogger{
+		logger: logger.Named("audit"),
+		file:   file,
+		claims: opts.Claims,
+	}, nil
+}
+
+// Authentication records the outcome of the authentication of a request. A nil err records a success.
+func (l *AuditLogger) Authentication(authenticator string, claims map[string]any, err error, fields ...zap.Field) {
+	if l == nil {
+		return
+	}
+
+	fields = append(fields,
+		zap.String("event", AuditEventAuthentication),
+		zap.String("authenticator", authenticator),
+	)
+	if err != nil {
+		l.logger.Warn("Authentication failed", append(fields, zap.String("outcome", AuditOutcomeFailure), zap.Error(err))...)
+		return
+	}
+	l.logger.Info("Authentication succeeded", append(fields, zap.String("outcome", AuditOutcomeSuccess), l.claimsField(claims))...)
+}
+
+// AuthorizationDenied records the denied access to a field of the schema
+func (l *AuditLogger) AuthorizationDenied(typeName, fieldName, reason string, claims map[string]any, fields ...zap.Field) {
+	if l == nil {
+		return
+	}
+
+	l.logger.Warn("Authorization denied", append(fields,
+		zap.String("event", AuditEventAuthorizationDenied),
+		zap.String("outcome", AuditOutcomeFailure),
+		zap.String("type_name", typeName),
+		zap.String("field_name", fieldName),
+		zap.String("reason", reason),
+		l.claimsField(claims),
+	)...)
+}
+
+// ConfigReload records a router config that was applied or failed to be applied. A nil err records a success.
+func (l *AuditLogger) ConfigReload(source, version, previousVersion, hash string, err error) {
+	if l == nil {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("event", AuditEventConfigReload),
+		zap.String("source", source),
+		zap.String("config_version", version),
+		zap.String("previous_config_version", previousVersion),
+		zap.String("config_hash", hash),
+	}
+	if err != nil {
+		l.logger.Warn("Config reload failed", append(fields, zap.String("outcome", AuditOutcomeFailure), zap.Error(err))...)
+		return
+	}
+	l.logger.Info("Config reloaded", append(fields, zap.String("outcome", AuditOutcomeSuccess))...)
+}
+
+// claimsField keeps the configured claims
+func (l *AuditLogger) claimsField(claims map[string]any) zap.Field {
+	extract := make(map[string]any, len(l.claims))
+	for _, name := range l.claims {
+		if value, ok := claims[name]; ok {
+			extract[name] = value
+		}
+	}
+	if len(extract) == 0 {
+		return zap.Skip()
+	}
+	return zap.Any("claims", extract)
+}
+
+func (l *AuditLogger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return errors.Join(l.logger.Sync(), l.file.Close())
+}
+
+// hmacChain appends the HMAC of the entry and of the previous HMAC to every JSON entry
+type hmacChain struct {
+	syncer zapcore.WriteSyncer
+	key    []byte
+
+	mu   sync.Mutex
+	prev []byte
+	buf  []byte
+}
+
+// Write receives a single encoded entry from the core
+func (c *hmacChain) Write(p []byte) (int, error) {
+	entry := bytes.TrimRight(p, "\n")
+	if len(entry) < 2 || entry[len(entry)-1] != '}' {
+		return 0, errors.New("the audit log entry is not a JSON object")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sum := auditHMAC(c.key, c.prev, entry)
+	c.buf = append(c.buf[:0], entry[:len(entry)-1]...)
+	c.buf = append(c.buf, auditHMACField...)
+	c.buf = append(c.buf, sum...)
+	c.buf = append(c.buf, "\"}\n"...)
+
+	if _, err := c.syncer.Write(c.buf); err != nil {
+		return 0, err
+	}
+	c.prev = sum
+	return len(p), nil
+}
+
+func (c *hmacChain) Sync() error {
+	return c.syncer.Sync()
+}
+
+func auditHMAC(key, prev, entry []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(prev)
+	mac.Write(entry)
+	return []byte(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// splitAuditHMAC returns the entry as it was signed and its HMAC
+func splitAuditHMAC(line []byte) (entry, sum []byte, ok bool) {
+	i := bytes.LastIndex(line, auditHMACField)
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, nil, false
+	}
+	entry = append(bytes.Clone(line[:i]), '}')
+	return entry, line[i+len(auditHMACField) : len(line)-2], true
+}
+
+// lastAuditHMAC returns the HMAC of the last entry of an existing audit log. If the file is empty or its last
+// entry isn't chained, a new chain is started.
+func lastAuditHMAC(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(info.Size()-auditTailSize, 0)
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	tail = bytes.TrimRight(tail, "\n")
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	i := bytes.LastIndexByte(tail, '\n')
+	if i < 0 && offset > 0 {
+		return nil, errors.New("the last entry of the audit log is too large to continue its hmac chain")
+	}
+	if _, sum, ok := splitAuditHMAC(tail[i+1:]); ok {
+		return bytes.Clone(sum), nil
+	}
+	return nil, nil
+}
+
+// VerifyAuditLog checks the HMAC chain of the entries of an audit log. It returns the number of verified entries
+// and an error for the first entry that was changed, removed or inserted. Entries that were removed from the end of
+// the file can't be detected.
+func VerifyAuditLog(r io.Reader, key []byte) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+
+	var prev []byte
+	verified := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry, sum, ok := splitAuditHMAC(scanner.Bytes())
+		if !ok {
+			return verified, fmt.Errorf("the entry on line %d has no hmac", line)
+		}
+		if !hmac.Equal(sum, auditHMAC(key, prev, entry)) {
+			return verified, fmt.Errorf("the hmac of the entry on line %d doesn't match", line)
+		}
+		prev = bytes.Clone(sum)
+		verified++
+	}
+
+	return verified, scanner.Err()
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	key := []byte("secret")
+
+	audit, err := NewAuditLogger(&AuditOptions{Path: path, HMACKey: key, Claims: []string{"sub"}})
+	require.NoError(t, err)
+	audit.Authentication("jwks", map[string]any{"sub": "user-1", "email": "user@example.com"}, nil)
+	audit.Authentication("jwks", nil, errors.New("token is expired"))
+	require.NoError(t, audit.Close())
+
+	// The chain is continued when the file is opened again
+	audit, err = NewAuditLogger(&AuditOptions{Path: path, HMACKey: key})
+	require.NoError(t, err)
+	audit.AuthorizationDenied("Query", "employees", "missing required scopes", nil)
+	audit.ConfigReload("cdn", "v2", "v1", "abc", nil)
+	require.NoError(t, audit.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, AuditEventAuthentication, entry["event"])
+	require.Equal(t, AuditOutcomeSuccess, entry["outcome"])
+	// Only the configured claims are recorded
+	require.Equal(t, map[string]any{"sub": "user-1"}, entry["claims"])
+	require.NotEmpty(t, entry["hmac"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	require.Equal(t, AuditOutcomeFailure, entry["outcome"])
+	require.Equal(t, "token is expired", entry["error"])
+
+	verified, err := VerifyAuditLog(bytes.NewReader(data), key)
+	require.NoError(t, err)
+	require.Equal(t, 4, verified)
+
+	_, err = VerifyAuditLog(bytes.NewReader(data), []byte("other"))
+	require.EqualError(t, err, "the hmac of the entry on line 1 doesn't match")
+
+	tampered := strings.Replace(string(data), `"type_name":"Query"`, `"type_name":"Mutation"`, 1)
+	verified, err = VerifyAuditLog(strings.NewReader(tampered), key)
+	require.EqualError(t, err, "the hmac of the entry on line 3 doesn't match")
+	require.Equal(t, 2, verified)
+
+	removed := strings.Join([]string{lines[0], lines[2], lines[3]}, "\n")
+	_, err = VerifyAuditLog(strings.NewReader(removed), key)
+	require.EqualError(t, err, "the hmac of the entry on line 2 doesn't match")
+}
+
+func TestAuditLoggerWithoutChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	audit, err := NewAuditLogger(&AuditOptions{Path: path})
+	require.NoError(t, err)
+	audit.ConfigReload("static", "v1", "", "abc", errors.New("invalid config"))
+	require.NoError(t, audit.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), `"hmac"`)
+	require.Contains(t, string(data), `"error":"invalid config"`)
+
+	// A disabled audit log is nil
+	var disabled *AuditLogger
+	disabled.Authentication("jwks", nil, nil)
+	require.NoError(t, disabled.Close())
+
+	_, err = NewAuditLogger(&AuditOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewBufferedStdout buffers the writes to stdout to reduce the number of syscalls when logging heavily. The buffer
+// is flushed when it exceeds the size and after the flush interval. Stop the writer before exiting to flush the
+// remaining entries.
+func NewBufferedStdout(size int, flushInterval time.Duration) *zapcore.BufferedWriteSyncer {
+	return NewBufferedOutput(zapcore.AddSync(os.Stdout), size, flushInterval)
+}
+
+// NewBufferedOutput buffers the writes to the output like NewBufferedStdout, e.g. to stderr
+func NewBufferedOutput(output zapcore.WriteSyncer, size int, flushInterval time.Duration) *zapcore.BufferedWriteSyncer {
+	return &zapcore.BufferedWriteSyncer{
+		WS:            output,
+		Size:          size,
+		FlushInterval: flushInterval,
+	}
+}
+
+// WithFlushOnLevel returns an option that syncs the output after every entry of the level or higher, so that
+// warnings and errors are written immediately by a buffered output
+func WithFlushOnLevel(level zapcore.Level) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &flushOnLevelCore{Core: core, level: level}
+	})
+}
+
+type flushOnLevelCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+func (c *flushOnLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &flushOnLevelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *flushOnLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.level {
+		return c.Core.Check(ent, ce)
+	}
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *flushOnLevelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.Core.Write(ent, fields); err != nil {
+		return err
+	}
+	// Like zap on fatal entries, the error is ignored because stdout can't be synced when it is a pipe
+	_ = c.Core.Sync()
+	return nil
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWithFlushOnLevel(t *testing.T) {
+	out := &syncBuffer{}
+	buffered := &zapcore.BufferedWriteSyncer{WS: zapcore.AddSync(out), Size: 64 * 1024, FlushInterval: time.Hour}
+	defer func() {
+		require.NoError(t, buffered.Stop())
+	}()
+
+	logger := NewWithOutput(buffered, false, false, zap.InfoLevel).WithOptions(WithFlushOnLevel(zap.WarnLevel))
+
+	logger.Info("buffered")
+	require.Empty(t, out.String())
+
+	logger.With(zap.String("component", "router")).Warn("flushed")
+	require.Contains(t, out.String(), `"msg":"buffered"`)
+	require.Contains(t, out.String(), `"msg":"flushed"`)
+
+	logger.Debug("disabled")
+	logger.Info("buffered again")
+	require.NotContains(t, out.String(), "buffered again")
+	require.NoError(t, logger.Sync())
+	require.Contains(t, out.String(), "buffered again")
+	require.NotContains(t, out.String(), "disabled")
+}
+
+// BenchmarkBufferedOutput compares the throughput of logging to a file with and without the buffer. Every unbuffered
+// entry is a write syscall.
+func BenchmarkBufferedOutput(b *testing.B) {
+	newFile := func(b *testing.B) *os.File {
+		f, err := os.Create(filepath.Join(b.TempDir(), "router.log"))
+		require.NoError(b, err)
+		b.Cleanup(func() {
+			_ = f.Close()
+		})
+		return f
+	}
+
+	run := func(b *testing.B, logger *zap.Logger) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				logger.Info("request", zap.String("method", "POST"), zap.String("path", "/graphql"), zap.Int("status", 200))
+			}
+		})
+	}
+
+	b.Run("unbuffered", func(b *testing.B) {
+		run(b, NewWithOutput(zapcore.AddSync(newFile(b)), false, false, zap.InfoLevel))
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		buffered := &zapcore.BufferedWriteSyncer{WS: zapcore.AddSync(newFile(b)), FlushInterval: time.Second}
+		b.Cleanup(func() {
+			_ = buffered.Stop()
+		})
+		run(b, NewWithOutput(buffered, false, false, zap.InfoLevel).WithOptions(WithFlushOnLevel(zap.WarnLevel)))
+	})
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	traceIDField = "trace_id"
+	spanIDField  = "span_id"
+)
+
+type loggerContextKey struct{}
+
+// NewContext returns a context that carries the logger. The logger shouldn't have the fields of a span or of the
+// operation, they are added by FromContext.
+func NewContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger of the context with the fields of the operation of the context, if any, and the
+// trace_id and the span_id of the active span of the context, so that the entries can be correlated with the trace.
+// Without a logger in the context, a no-op logger is returned.
+func FromContext(ctx context.Context) *zap.Logger {
+	logger, ok := ctx.Value(loggerContextKey{}).(*zap.Logger)
+	if !ok {
+		return zap.NewNop()
+	}
+	return WithTraceContext(ctx, EnrichFromOperationContext(ctx, logger))
+}
+
+// WithTraceContext returns the logger with the trace_id and the span_id of the active span of the context. The
+// logger is returned unchanged if the context has no valid span, e.g. when tracing is disabled.
+func WithTraceContext(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	fields := TraceFields(ctx)
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.With(fields...)
+}
+
+// TraceFields returns the trace_id and the span_id of the active span of the context
+func TraceFields(ctx context.Context) []zap.Field {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String(traceIDField, spanContext.TraceID().String()),
+		zap.String(spanIDField, spanContext.SpanID().String()),
+	}
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core).With(WithRequestID("1"))
+
+	ctx := NewContext(context.Background(), logger)
+	FromContext(ctx).Info("without span")
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	FromContext(trace.ContextWithSpanContext(ctx, spanContext)).Info("with span")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	require.Equal(t, map[string]any{"reqId": "1"}, entries[0].ContextMap())
+	require.Equal(t, map[string]any{
+		"reqId":    "1",
+		"trace_id": "01000000000000000000000000000000",
+		"span_id":  "0200000000000000",
+	}, entries[1].ContextMap())
+
+	// Without a logger in the context the entries are dropped
+	FromContext(context.Background()).Info("dropped")
+	require.Len(t, logs.All(), 2)
+}
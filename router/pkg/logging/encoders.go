@@ -0,0 +1,266 @@
+package logging
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// EncodingJSON is the production JSON of the router
+	EncodingJSON = "json"
+	// EncodingConsole is the human friendly text of the router
+	EncodingConsole = "console"
+	// EncodingMsgpack is the compact binary MessagePack, decode it with the log-decode command of the router
+	EncodingMsgpack = "msgpack"
+	// EncodingLogfmt writes the entries as key=value pairs
+	EncodingLogfmt = "logfmt"
+	// EncodingECS writes the entries as JSON with the keys of the Elastic Common Schema
+	EncodingECS = "ecs"
+	// EncodingGCP writes the entries as JSON with the keys of the structured logging of GCP Cloud Logging
+	EncodingGCP = "gcp"
+	// EncodingDatadog writes the entries as JSON with the reserved attributes of Datadog
+	EncodingDatadog = "datadog"
+	// EncodingPretty is the console encoding with the structured fields as indented blocks, see SetPrettyOptions
+	EncodingPretty = "pretty"
+)
+
+const (
+	// TimeFormatEpoch writes the timestamps as seconds since the epoch with a fraction
+	TimeFormatEpoch = "epoch"
+	// TimeFormatEpochMillis writes the timestamps as milliseconds since the epoch
+	TimeFormatEpochMillis = "epoch_millis"
+	// TimeFormatRFC3339 writes the timestamps in UTC as RFC 3339 with seconds
+	TimeFormatRFC3339 = "rfc3339"
+	// TimeFormatRFC3339Nano writes the timestamps in UTC as RFC 3339 with nanoseconds
+	TimeFormatRFC3339Nano = "rfc3339nano"
+)
+
+const (
+	// DurationFormatSeconds writes the durations as seconds with a fraction
+	DurationFormatSeconds = "seconds"
+	// DurationFormatMillis writes the durations as whole milliseconds
+	DurationFormatMillis = "millis"
+	// DurationFormatString writes the durations like time.Duration.String, e.g. 1.5s
+	DurationFormatString = "string"
+)
+
+// ecsVersion is the version of the Elastic Common Schema of the ECS encoding
+const ecsVersion = "1.6.0"
+
+// EncoderFactory creates the encoder of an encoding. It's called for every output that uses the encoding, the
+// encoders must not share state.
+type EncoderFactory func() zapcore.Encoder
+
+var encoderRegistry = struct {
+	mu        sync.RWMutex
+	factories map[string]EncoderFactory
+}{
+	factories: map[string]EncoderFactory{
+		EncodingJSON:    ZapJsonEncoder,
+		EncodingConsole: zapConsoleEncoder,
+		EncodingMsgpack: NewMsgpackEncoder,
+		EncodingLogfmt:  NewLogfmtEncoder,
+		EncodingECS:     NewECSEncoder,
+		EncodingGCP:     NewGCPEncoder,
+		EncodingDatadog: NewDatadogEncoder,
+		EncodingPretty:  NewPrettyEncoder,
+	},
+}
+
+// encoderFormats override the formats of the timestamps and durations of the built-in encodings. If empty, every
+// encoding keeps its own.
+var encoderFormats = struct {
+	mu       sync.RWMutex
+	time     string
+	duration string
+}{}
+
+// SetEncoderFormats sets the format of the timestamps and durations of all built-in encodings, including the console
+// encoding. An empty format keeps the format of each encoding, e.g. epoch milliseconds and seconds of the JSON
+// encoding. Set it before the loggers are created.
+func SetEncoderFormats(timeFormat, durationFormat string) error {
+	switch timeFormat {
+	case "", TimeFormatEpoch, TimeFormatEpochMillis, TimeFormatRFC3339, TimeFormatRFC3339Nano:
+	default:
+		return errors.New("unknown log time format '" + timeFormat + "'")
+	}
+	switch durationFormat {
+	case "", DurationFormatSeconds, DurationFormatMillis, DurationFormatString:
+	default:
+		return errors.New("unknown log duration format '" + durationFormat + "'")
+	}
+
+	encoderFormats.mu.Lock()
+	defer encoderFormats.mu.Unlock()
+	encoderFormats.time = timeFormat
+	encoderFormats.duration = durationFormat
+	return nil
+}
+
+// currentEncoderFormats returns the formats of SetEncoderFormats
+func currentEncoderFormats() (timeFormat, durationFormat string) {
+	encoderFormats.mu.RLock()
+	defer encoderFormats.mu.RUnlock()
+	return encoderFormats.time, encoderFormats.duration
+}
+
+// applyEncoderFormats overrides the encoding of the timestamps and durations of the config with the formats of
+// SetEncoderFormats
+func applyEncoderFormats(ec *zapcore.EncoderConfig) {
+	timeFormat, durationFormat := currentEncoderFormats()
+
+	switch timeFormat {
+	case TimeFormatEpoch:
+		ec.EncodeTime = zapcore.EpochTimeEncoder
+	case TimeFormatEpochMillis:
+		ec.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendInt64(t.UnixMilli())
+		}
+	case TimeFormatRFC3339:
+		ec.EncodeTime = utcTimeEncoder(time.RFC3339)
+	case TimeFormatRFC3339Nano:
+		ec.EncodeTime = utcTimeEncoder(time.RFC3339Nano)
+	}
+
+	switch durationFormat {
+	case DurationFormatSeconds:
+		ec.EncodeDuration = zapcore.SecondsDurationEncoder
+	case DurationFormatMillis:
+		ec.EncodeDuration = zapcore.MillisDurationEncoder
+	case DurationFormatString:
+		ec.EncodeDuration = zapcore.StringDurationEncoder
+	}
+}
+
+// RegisterEncoder makes a custom encoding available by its name to the loggers of the router, e.g. in the
+// log_encoding option. Register it before the loggers are created. Registered encodings can't be replaced.
+func RegisterEncoder(name string, factory EncoderFactory) error {
+	if name == "" {
+		return errors.New("the name of a log encoding must not be empty")
+	}
+	if factory == nil {
+		return errors.New("the log encoding '" + name + "' requires a factory")
+	}
+
+	encoderRegistry.mu.Lock()
+	defer encoderRegistry.mu.Unlock()
+
+	if _, ok := encoderRegistry.factories[name]; ok {
+		return errors.New("the log encoding '" + name + "' is already registered")
+	}
+	encoderRegistry.factories[name] = factory
+	return nil
+}
+
+// NewEncoder creates an encoder of a built-in or registered encoding
+func NewEncoder(encoding string) (zapcore.Encoder, error) {
+	encoderRegistry.mu.RLock()
+	factory, ok := encoderRegistry.factories[encoding]
+	encoderRegistry.mu.RUnlock()
+
+	if !ok {
+		return nil, errors.New("unknown log encoding '" + encoding + "', supported are " + strings.Join(Encodings(), ", "))
+	}
+	return factory(), nil
+}
+
+// Encodings returns the names of the built-in and registered encodings in alphabetical order
+func Encodings() []string {
+	encoderRegistry.mu.RLock()
+	defer encoderRegistry.mu.RUnlock()
+
+	names := make([]string, 0, len(encoderRegistry.factories))
+	for name := range encoderRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultEncoding returns the encoding of the logger when none is configured
+func defaultEncoding(prettyLogging bool) string {
+	if prettyLogging {
+		return EncodingConsole
+	}
+	return EncodingJSON
+}
+
+func utcTimeEncoder(layout string) zapcore.TimeEncoder {
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.UTC().Format(layout))
+	}
+}
+
+// NewECSEncoder creates an encoder that writes the entries as JSON with the keys of the Elastic Common Schema
+func NewECSEncoder() zapcore.Encoder {
+	ec := zapBaseEncoderConfig()
+	ec.TimeKey = "@timestamp"
+	ec.EncodeTime = utcTimeEncoder("2006-01-02T15:04:05.000Z07:00")
+	ec.LevelKey = "log.level"
+	ec.EncodeLevel = zapcore.LowercaseLevelEncoder
+	ec.MessageKey = "message"
+	ec.NameKey = "log.logger"
+	ec.CallerKey = "log.origin.file.name"
+	ec.StacktraceKey = "error.stack_trace"
+	applyEncoderFormats(&ec)
+
+	enc := zapcore.NewJSONEncoder(ec)
+	enc.AddString("ecs.version", ecsVersion)
+	return enc
+}
+
+// NewGCPEncoder creates an encoder that writes the entries as JSON with the keys of the structured logging of GCP
+// Cloud Logging. The levels are mapped to the severities of Cloud Logging.
+func NewGCPEncoder() zapcore.Encoder {
+	ec := zapBaseEncoderConfig()
+	ec.TimeKey = "timestamp"
+	ec.EncodeTime = utcTimeEncoder(time.RFC3339Nano)
+	ec.LevelKey = "severity"
+	ec.EncodeLevel = gcpSeverityEncoder
+	ec.MessageKey = "message"
+	ec.StacktraceKey = "stack_trace"
+	applyEncoderFormats(&ec)
+	return zapcore.NewJSONEncoder(ec)
+}
+
+func gcpSeverityEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch level {
+	case zapcore.DebugLevel:
+		enc.AppendString("DEBUG")
+	case zapcore.InfoLevel:
+		enc.AppendString("INFO")
+	case zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case zapcore.ErrorLevel:
+		enc.AppendString("ERROR")
+	case zapcore.DPanicLevel:
+		enc.AppendString("CRITICAL")
+	case zapcore.PanicLevel:
+		enc.AppendString("ALERT")
+	case zapcore.FatalLevel:
+		enc.AppendString("EMERGENCY")
+	default:
+		enc.AppendString("DEFAULT")
+	}
+}
+
+// NewDatadogEncoder creates an encoder that writes the entries as JSON with the reserved attributes of Datadog
+func NewDatadogEncoder() zapcore.Encoder {
+	ec := zapBaseEncoderConfig()
+	ec.TimeKey = "timestamp"
+	ec.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendInt64(t.UnixMilli())
+	}
+	// Datadog also maps the level key to the status of the entry, the status key is taken by the access logs
+	ec.EncodeLevel = zapcore.LowercaseLevelEncoder
+	ec.MessageKey = "message"
+	ec.NameKey = "logger.name"
+	ec.StacktraceKey = "error.stack"
+	applyEncoderFormats(&ec)
+	return zapcore.NewJSONEncoder(ec)
+}
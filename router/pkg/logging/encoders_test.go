@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func encodeTestEntry(t *testing.T, encoding string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	logger, err := NewWithEncoding(zapcore.AddSync(&out), encoding, false, zap.InfoLevel)
+	require.NoError(t, err)
+
+	logger.Named("access").With(zap.String("config_version", "v1")).Warn("request failed",
+		zap.Int("status", 502),
+		zap.Error(errors.New("connection refused")),
+		zap.Strings("subgraphs", []string{"employees", "products"}),
+	)
+	require.NoError(t, logger.Sync())
+	return out.String()
+}
+
+func TestJSONEncodings(t *testing.T) {
+	tests := []struct {
+		encoding string
+		expected map[string]any
+		time     string
+	}{
+		{
+			encoding: EncodingECS,
+			expected: map[string]any{"log.level": "warn", "message": "request failed", "log.logger": "access", "ecs.version": ecsVersion},
+			time:     "@timestamp",
+		},
+		{
+			encoding: EncodingGCP,
+			expected: map[string]any{"severity": "WARNING", "message": "request failed", "logger": "access"},
+			time:     "timestamp",
+		},
+		{
+			encoding: EncodingDatadog,
+			expected: map[string]any{"level": "warn", "message": "request failed", "logger.name": "access"},
+			time:     "timestamp",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.encoding, func(t *testing.T) {
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal([]byte(encodeTestEntry(t, tc.encoding)), &entry))
+
+			for key, value := range tc.expected {
+				require.Equal(t, value, entry[key], key)
+			}
+			require.Contains(t, entry, tc.time)
+			require.Equal(t, "v1", entry["config_version"])
+			require.Equal(t, float64(502), entry["status"])
+			require.Equal(t, "connection refused", entry["error"])
+			require.Contains(t, entry, "hostname")
+		})
+	}
+}
+
+func TestSetEncoderFormats(t *testing.T) {
+	require.NoError(t, SetEncoderFormats(TimeFormatRFC3339Nano, DurationFormatMillis))
+	t.Cleanup(func() {
+		require.NoError(t, SetEncoderFormats("", ""))
+	})
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	for _, encoding := range []string{EncodingJSON, EncodingConsole, EncodingECS, EncodingLogfmt} {
+		encoder, err := NewEncoder(encoding)
+		require.NoError(t, err)
+		buf, err := encoder.EncodeEntry(zapcore.Entry{Time: now, Message: "request"}, []zap.Field{
+			zap.Duration("latency", 1500*time.Millisecond),
+		})
+		require.NoError(t, err)
+		require.Contains(t, buf.String(), "2024-01-02T03:04:05.000000006Z", encoding)
+		require.Contains(t, buf.String(), "1500", encoding)
+	}
+
+	require.NoError(t, SetEncoderFormats(TimeFormatEpochMillis, DurationFormatString))
+	buf, err := ZapJsonEncoder().EncodeEntry(zapcore.Entry{Time: now, Message: "request"}, []zap.Field{
+		zap.Duration("latency", 1500*time.Millisecond),
+	})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `"time":1704164645000`)
+	require.Contains(t, buf.String(), `"latency":"1.5s"`)
+
+	require.EqualError(t, SetEncoderFormats("unix", ""), "unknown log time format 'unix'")
+	require.EqualError(t, SetEncoderFormats("", "hours"), "unknown log duration format 'hours'")
+}
+
+func TestLogfmtEncoding(t *testing.T) {
+	line := encodeTestEntry(t, EncodingLogfmt)
+
+	require.True(t, strings.HasSuffix(line, "\n"))
+	require.Regexp(t, `^time=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z level=warn logger=access msg="request failed" `, line)
+	require.Contains(t, line, ` config_version=v1 status=502 error="connection refused" subgraphs="[\"employees\",\"products\"]"`)
+
+	enc := NewLogfmtEncoder()
+	enc.AddDuration("latency", 1500*time.Millisecond)
+	enc.OpenNamespace("request")
+	enc.AddString("query", "")
+	enc.AddBool("persisted", true)
+	buf, err := enc.EncodeEntry(zapcore.Entry{Message: "ok"}, []zapcore.Field{zap.String("path", "/graphql")})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `msg=ok latency=1.5s request.query="" request.persisted=true request.path=/graphql`)
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	require.NoError(t, RegisterEncoder("test-plain", func() zapcore.Encoder {
+		return zapcore.NewConsoleEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	}))
+	require.Contains(t, Encodings(), "test-plain")
+
+	line := encodeTestEntry(t, "test-plain")
+	require.True(t, strings.HasPrefix(line, "request failed\t{"))
+	require.Contains(t, line, `"config_version": "v1", "status": 502`)
+
+	require.EqualError(t, RegisterEncoder("test-plain", ZapJsonEncoder), "the log encoding 'test-plain' is already registered")
+	require.EqualError(t, RegisterEncoder(EncodingJSON, ZapJsonEncoder), "the log encoding 'json' is already registered")
+	require.EqualError(t, RegisterEncoder("", ZapJsonEncoder), "the name of a log encoding must not be empty")
+	require.EqualError(t, RegisterEncoder("test-nil", nil), "the log encoding 'test-nil' requires a factory")
+
+	_, err := NewEncoder("xml")
+	require.ErrorContains(t, err, "unknown log encoding 'xml', supported are console, datadog, ecs, gcp, json, logfmt, msgpack")
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"errors"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type bufferedEntry struct {
+	core   zapcore.Core
+	entry  zapcore.Entry
+	fields []zapcore.Field
+}
+
+// EscalationBuffer holds the entries of a single request that are below the level of the logger.
+// When the request fails, Escalate writes them so that the full detail of the failure is available
+// without enabling debug logging for all requests. It keeps the last size entries and is safe for concurrent use.
+type EscalationBuffer struct {
+	mu        sync.Mutex
+	level     zapcore.Level
+	entries   []bufferedEntry
+	next      int
+	full      bool
+	escalated bool
+}
+
+// NewEscalationBuffer creates a buffer that keeps the last size entries of the given level or above
+func NewEscalationBuffer(size int, level zapcore.Level) *EscalationBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &EscalationBuffer{
+		level:   level,
+		entries: make([]bufferedEntry, size),
+	}
+}
+
+func (b *EscalationBuffer) add(e bufferedEntry) error {
+	b.mu.Lock()
+	if b.escalated {
+		b.mu.Unlock()
+		// The request already failed, all further entries are written directly
+		return e.core.Write(e.entry, e.fields)
+	}
+
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Escalate writes all buffered entries, from oldest to newest, and writes subsequent entries directly.
+func (b *EscalationBuffer) Escalate() error {
+	b.mu.Lock()
+	if b.escalated {
+		b.mu.Unlock()
+		return nil
+	}
+	b.escalated = true
+
+	entries := make([]bufferedEntry, 0, len(b.entries))
+	if b.full {
+		entries = append(entries, b.entries[b.next:]...)
+	}
+	entries = append(entries, b.entries[:b.next]...)
+	b.entries = nil
+	b.mu.Unlock()
+
+	var err error
+	for _, e := range entries {
+		err = errors.Join(err, e.core.Write(e.entry, e.fields))
+	}
+
+	return err
+}
+
+// WithEscalationBuffer returns an option that keeps the entries the logger would discard in the buffer.
+// Entries enabled on the logger are written as usual.
+func WithEscalationBuffer(buffer *EscalationBuffer) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &escalationCore{Core: core, buffer: buffer}
+	})
+}
+
+type escalationCore struct {
+	zapcore.Core
+	buffer *EscalationBuffer
+}
+
+func (c *escalationCore) Enabled(level zapcore.Level) bool {
+	return c.Core.Enabled(level) || level >= c.buffer.level
+}
+
+func (c *escalationCore) With(fields []zapcore.Field) zapcore.Core {
+	return &escalationCore{Core: c.Core.With(fields), buffer: c.buffer}
+}
+
+func (c *escalationCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+	if ent.Level >= c.buffer.level {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *escalationCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.buffer.add(bufferedEntry{core: c.Core, entry: ent, fields: fields})
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEscalationBuffer(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	buffer := NewEscalationBuffer(2, zapcore.DebugLevel)
+	logger := zap.New(core).WithOptions(WithEscalationBuffer(buffer)).With(zap.String("reqId", "1"))
+
+	logger.Debug("first")
+	logger.Debug("second")
+	logger.Info("info")
+	logger.Debug("third")
+
+	// Only the enabled entries are written before the escalation
+	require.Equal(t, 1, logs.Len())
+	require.Equal(t, "info", logs.All()[0].Message)
+
+	require.NoError(t, buffer.Escalate())
+
+	// The oldest entry was evicted
+	entries := logs.TakeAll()
+	require.Len(t, entries, 3)
+	require.Equal(t, "second", entries[1].Message)
+	require.Equal(t, "third", entries[2].Message)
+	require.Equal(t, "1", entries[2].ContextMap()["reqId"])
+
+	logger.Debug("after")
+	require.Equal(t, 1, logs.Len())
+
+	require.NoError(t, buffer.Escalate())
+	require.Equal(t, 1, logs.Len())
+}
@@ -0,0 +1,250 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FileEncodingJSON    = "json"
+	FileEncodingMsgpack = "msgpack"
+)
+
+// FileOutput is a log file that is rotated when it exceeds its maximum size
+type FileOutput struct {
+	Path string
+	// Encoding is a built-in or registered encoding of the entries in the file. If empty, it is the same as of
+	// stdout.
+	Encoding string
+	// Level is the minimum level of the entries in the file, e.g. to only keep the warnings of a noisy logger. If
+	// empty, the file receives all entries enabled by the level of the router.
+	Level string
+	// MaxSize is the size in bytes after which the file is rotated. Zero uses the default of 100 MB.
+	MaxSize int64
+	// MaxBackups is the number of rotated files that are kept. Zero keeps all files.
+	MaxBackups int
+	// MaxAge deletes rotated files that are older. Zero keeps the files regardless of their age.
+	MaxAge time.Duration
+	// Compress compresses the rotated files with gzip
+	Compress bool
+	// LocalTime uses the local time instead of UTC in the names of the rotated files
+	LocalTime bool
+}
+
+// LoggerFileOutput writes the entries of the logger with the name and of its children to a dedicated file
+type LoggerFileOutput struct {
+	LoggerName string
+	File       FileOutput
+}
+
+type FileOutputs struct {
+	// Default receives the entries of all loggers without a dedicated file. If nil, they are written to stdout.
+	Default *FileOutput
+	Loggers []LoggerFileOutput
+	// Levels routes the entries of all loggers without a dedicated file by their level instead of writing them to
+	// stdout. It can't be combined with Default.
+	Levels []LevelOutput
+	// Stdout replaces the standard output, e.g. with a buffered writer
+	Stdout zapcore.WriteSyncer
+	// Rotator rotates the files on demand. Optional.
+	Rotator *FileRotator
+	// Encoding is the encoding of stdout and of the files without an encoding. If empty, the entries are encoded
+	// as JSON or, with pretty logging, for the console.
+	Encoding string
+}
+
+// NewWithFileOutputs creates the logger of the router like New, but writes the entries to rotated files by
+// the name of their logger
+func NewWithFileOutputs(prettyLogging bool, debug bool, level zapcore.LevelEnabler, outputs *FileOutputs) (*zap.Logger, error) {
+	stdoutEncoding := outputs.Encoding
+	if stdoutEncoding == "" {
+		stdoutEncoding = defaultEncoding(prettyLogging)
+	}
+	if _, err := NewEncoder(stdoutEncoding); err != nil {
+		return nil, err
+	}
+
+	newCore := func(syncer zapcore.WriteSyncer, encoding string, minLevel string) zapcore.Core {
+		if encoding == "" {
+			encoding = stdoutEncoding
+		}
+		// The encodings and levels are validated before the cores are created
+		encoder, _ := NewEncoder(encoding)
+		enabler := level
+		if minLevel != "" {
+			fileLevel, _ := ZapLogLevelFromString(minLevel)
+			enabler = levelIntersection{level, fileLevel}
+		}
+		return zapcore.NewCore(encoder, syncer, enabler)
+	}
+
+	// Loggers can share a file, which must only be rotated by one writer
+	files := map[string]*rotatedFile{}
+	fileSyncer := func(output *FileOutput) (zapcore.WriteSyncer, error) {
+		if output.Path == "" {
+			return nil, errors.New("the path of a log file must not be empty")
+		}
+		if output.Encoding != "" {
+			if _, err := NewEncoder(output.Encoding); err != nil {
+				return nil, errors.New("unknown encoding '" + output.Encoding + "' of the log file '" + output.Path + "'")
+			}
+		}
+		if output.Level != "" {
+			if _, err := ZapLogLevelFromString(output.Level); err != nil {
+				return nil, errors.New("unknown level '" + output.Level + "' of the log file '" + output.Path + "'")
+			}
+		}
+		if f, ok := files[output.Path]; ok {
+			if f.output != *output {
+				return nil, errors.New("the log file '" + output.Path + "' is configured with different settings")
+			}
+			return f.syncer, nil
+		}
+		f := &rotatedFile{output: *output, syncer: zapcore.AddSync(newRotatedFile(output, outputs.Rotator))}
+		files[output.Path] = f
+		return f.syncer, nil
+	}
+
+	stdout := outputs.Stdout
+	if stdout == nil {
+		stdout = zapcore.AddSync(os.Stdout)
+	}
+
+	var fallback zapcore.Core
+	switch {
+	case outputs.Default != nil && len(outputs.Levels) > 0:
+		return nil, errors.New("the level outputs can't be combined with a default log file")
+	case outputs.Default != nil:
+		syncer, err := fileSyncer(outputs.Default)
+		if err != nil {
+			return nil, err
+		}
+		fallback = newCore(syncer, outputs.Default.Encoding, outputs.Default.Level)
+	case len(outputs.Levels) > 0:
+		var err error
+		if fallback, err = newLevelOutputsCore(outputs.Levels, level, stdout, stdoutEncoding, fileSyncer); err != nil {
+			return nil, err
+		}
+	default:
+		fallback = newCore(stdout, "", "")
+	}
+
+	core := &loggerNameCore{fallback: fallback}
+	seen := make(map[string]struct{}, len(outputs.Loggers))
+	for _, output := range outputs.Loggers {
+		if output.LoggerName == "" {
+			return nil, errors.New("the logger name of a log file must not be empty")
+		}
+		if _, ok := seen[output.LoggerName]; ok {
+			return nil, errors.New("duplicate log file of logger '" + output.LoggerName + "'")
+		}
+		seen[output.LoggerName] = struct{}{}
+
+		syncer, err := fileSyncer(&output.File)
+		if err != nil {
+			return nil, err
+		}
+		core.routes = append(core.routes, loggerRoute{name: output.LoggerName, core: newCore(syncer, output.File.Encoding, output.File.Level)})
+	}
+	// The most specific name is matched first
+	sort.Slice(core.routes, func(i, j int) bool {
+		return len(core.routes[i].name) > len(core.routes[j].name)
+	})
+
+	return finishZapLogger(core, stdoutEncoding == EncodingConsole || stdoutEncoding == EncodingPretty, debug), nil
+}
+
+type rotatedFile struct {
+	output FileOutput
+	syncer zapcore.WriteSyncer
+}
+
+func newRotatedFile(output *FileOutput, rotator *FileRotator) *lumberjack.Logger {
+	w := &lumberjack.Logger{
+		Filename:   output.Path,
+		MaxBackups: output.MaxBackups,
+		Compress:   output.Compress,
+		LocalTime:  output.LocalTime,
+	}
+	if output.MaxSize > 0 {
+		// The size is configured in megabytes, rounded up to not rotate before the configured size
+		w.MaxSize = int((output.MaxSize + 1<<20 - 1) >> 20)
+	}
+	if output.MaxAge > 0 {
+		// The age is configured in days
+		w.MaxAge = int((output.MaxAge + 24*time.Hour - 1) / (24 * time.Hour))
+	}
+	if rotator != nil {
+		rotator.add(w)
+	}
+
+	return w
+}
+
+type loggerRoute struct {
+	name string
+	core zapcore.Core
+}
+
+// loggerNameCore dispatches the entries to the core of their logger name. Entries of loggers without a route are
+// written to the fallback core.
+type loggerNameCore struct {
+	fallback zapcore.Core
+	routes   []loggerRoute
+}
+
+func (c *loggerNameCore) coreOf(loggerName string) zapcore.Core {
+	for _, route := range c.routes {
+		if loggerName == route.name || strings.HasPrefix(loggerName, route.name+".") {
+			return route.core
+		}
+	}
+	return c.fallback
+}
+
+func (c *loggerNameCore) Enabled(level zapcore.Level) bool {
+	// The level outputs of the fallback can leave out levels that a route writes
+	if c.fallback.Enabled(level) {
+		return true
+	}
+	for _, route := range c.routes {
+		if route.core.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *loggerNameCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &loggerNameCore{
+		fallback: c.fallback.With(fields),
+		routes:   make([]loggerRoute, len(c.routes)),
+	}
+	for i, route := range c.routes {
+		clone.routes[i] = loggerRoute{name: route.name, core: route.core.With(fields)}
+	}
+	return clone
+}
+
+func (c *loggerNameCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.coreOf(ent.LoggerName).Check(ent, ce)
+}
+
+func (c *loggerNameCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.coreOf(ent.LoggerName).Write(ent, fields)
+}
+
+func (c *loggerNameCore) Sync() error {
+	err := c.fallback.Sync()
+	for _, route := range c.routes {
+		err = errors.Join(err, route.core.Sync())
+	}
+	return err
+}
@@ -0,0 +1,219 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewWithFileOutputs(t *testing.T) {
+	dir := t.TempDir()
+	routerLog := filepath.Join(dir, "router.log")
+	accessLog := filepath.Join(dir, "access.log")
+	auditLog := filepath.Join(dir, "audit.log")
+
+	logger, err := NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Default: &FileOutput{Path: routerLog},
+		Loggers: []LoggerFileOutput{
+			{LoggerName: "access", File: FileOutput{Path: accessLog}},
+			{LoggerName: "audit", File: FileOutput{Path: auditLog, MaxBackups: 3}},
+			{LoggerName: "audit.retention", File: FileOutput{Path: routerLog}},
+		},
+	})
+	require.NoError(t, err)
+
+	logger = logger.With(zap.String("component", "router"))
+	logger.Info("started")
+	logger.Named("access").Info("request")
+	logger.Named("audit").Info("deleted")
+	logger.Named("audit").Named("kafka").Info("published")
+	logger.Named("audit").Named("retention").Info("compressed")
+	logger.Named("accessible").Debug("skipped")
+	require.NoError(t, logger.Sync())
+
+	readLines := func(path string) []string {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	lines := readLines(routerLog)
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"msg":"started"`)
+	require.Contains(t, lines[0], `"component":"router"`)
+	// The most specific logger name wins
+	require.Contains(t, lines[1], `"msg":"compressed"`)
+
+	lines = readLines(accessLog)
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `"logger":"access"`)
+	require.Contains(t, lines[0], `"component":"router"`)
+
+	lines = readLines(auditLog)
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"msg":"deleted"`)
+	require.Contains(t, lines[1], `"logger":"audit.kafka"`)
+}
+
+func TestNewWithFileOutputsMsgpack(t *testing.T) {
+	accessLog := filepath.Join(t.TempDir(), "access.log")
+
+	logger, err := NewWithFileOutputs(true, false, zap.InfoLevel, &FileOutputs{
+		Loggers: []LoggerFileOutput{{LoggerName: "access", File: FileOutput{Path: accessLog, Encoding: FileEncodingMsgpack}}},
+	})
+	require.NoError(t, err)
+
+	logger.Named("access").Info("request", zap.Int("status", 200))
+	logger.Named("access").Info("request", zap.Int("status", 500))
+	// The entries are written to the file without buffering, stdout can't be synced when it is a pipe
+	_ = logger.Sync()
+
+	f, err := os.Open(accessLog)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var decoded strings.Builder
+	require.NoError(t, DecodeMsgpack(f, &decoded))
+	lines := strings.Split(strings.TrimSpace(decoded.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"logger":"access","msg":"request","status":200}`)
+	require.Contains(t, lines[1], `"status":500}`)
+}
+
+func TestNewWithFileOutputsLevel(t *testing.T) {
+	dir := t.TempDir()
+	routerLog := filepath.Join(dir, "router.log")
+	auditLog := filepath.Join(dir, "audit.log")
+
+	logger, err := NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Default: &FileOutput{Path: routerLog, Level: "warning"},
+		Loggers: []LoggerFileOutput{
+			// The level of the router can't be lowered by a file
+			{LoggerName: "audit", File: FileOutput{Path: auditLog, Level: "debug"}},
+		},
+	})
+	require.NoError(t, err)
+
+	logger.Info("started")
+	logger.Warn("slow subgraph")
+	logger.Named("audit").Debug("skipped")
+	logger.Named("audit").Info("deleted")
+	require.NoError(t, logger.Sync())
+
+	data, err := os.ReadFile(routerLog)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `"msg":"slow subgraph"`)
+
+	data, err = os.ReadFile(auditLog)
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `"msg":"deleted"`)
+}
+
+func TestNewWithFileOutputsValidation(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Loggers: []LoggerFileOutput{{LoggerName: "access"}},
+	})
+	require.Error(t, err)
+
+	_, err = NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Loggers: []LoggerFileOutput{
+			{LoggerName: "access", File: FileOutput{Path: filepath.Join(dir, "access.log")}},
+			{LoggerName: "access", File: FileOutput{Path: filepath.Join(dir, "other.log")}},
+		},
+	})
+	require.Error(t, err)
+
+	_, err = NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Loggers: []LoggerFileOutput{{LoggerName: "access", File: FileOutput{Path: filepath.Join(dir, "access.log"), Encoding: "avro"}}},
+	})
+	require.Error(t, err)
+
+	_, err = NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Loggers: []LoggerFileOutput{{LoggerName: "access", File: FileOutput{Path: filepath.Join(dir, "access.log"), Level: "verbose"}}},
+	})
+	require.EqualError(t, err, "unknown level 'verbose' of the log file '"+filepath.Join(dir, "access.log")+"'")
+
+	// A shared file must be rotated the same way
+	_, err = NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Default: &FileOutput{Path: filepath.Join(dir, "router.log"), MaxBackups: 1},
+		Loggers: []LoggerFileOutput{{LoggerName: "access", File: FileOutput{Path: filepath.Join(dir, "router.log")}}},
+	})
+	require.Error(t, err)
+}
+
+func TestNewRotatedFile(t *testing.T) {
+	w := newRotatedFile(&FileOutput{Path: "router.log", MaxSize: 100_000_000, MaxAge: 36 * time.Hour, LocalTime: true}, nil)
+	require.Equal(t, 96, w.MaxSize)
+	require.Equal(t, 2, w.MaxAge)
+	require.True(t, w.LocalTime)
+
+	w = newRotatedFile(&FileOutput{Path: "router.log"}, nil)
+	require.Zero(t, w.MaxSize)
+	require.Zero(t, w.MaxAge)
+}
+
+func TestNewWithFileOutputsLevels(t *testing.T) {
+	dir := t.TempDir()
+	errorsLog := filepath.Join(dir, "errors.log")
+	accessLog := filepath.Join(dir, "access.log")
+
+	var stdout bytes.Buffer
+	logger, err := NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Loggers: []LoggerFileOutput{
+			{LoggerName: "access", File: FileOutput{Path: accessLog}},
+		},
+		Levels: []LevelOutput{
+			{Levels: LevelRange(zapcore.DebugLevel, zapcore.InfoLevel), Encoding: EncodingLogfmt},
+			{Levels: LevelRange(zapcore.WarnLevel, zapcore.FatalLevel), File: &FileOutput{Path: errorsLog}},
+		},
+		Stdout: zapcore.AddSync(&stdout),
+	})
+	require.NoError(t, err)
+
+	logger.Debug("skipped")
+	logger.Info("started")
+	logger.Warn("slow")
+	logger.Error("failed")
+	logger.Named("access").Error("request")
+	require.NoError(t, logger.Sync())
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], `level=info msg=started`)
+
+	data, err := os.ReadFile(errorsLog)
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], `"msg":"slow"`)
+	require.Contains(t, lines[1], `"msg":"failed"`)
+
+	// The entries of loggers with a dedicated file aren't routed by their level
+	data, err = os.ReadFile(accessLog)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"msg":"request"`)
+
+	_, err = NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Default: &FileOutput{Path: filepath.Join(dir, "router.log")},
+		Levels:  []LevelOutput{{Stream: OutputStderr}},
+	})
+	require.EqualError(t, err, "the level outputs can't be combined with a default log file")
+
+	_, err = NewWithFileOutputs(false, false, zap.InfoLevel, &FileOutputs{
+		Levels: []LevelOutput{{Stream: "stdin"}},
+	})
+	require.EqualError(t, err, "unknown log output 'stdin'")
+}
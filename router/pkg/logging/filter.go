@@ -0,0 +1,222 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// FilterActionDrop discards the matching entries
+	FilterActionDrop = "drop"
+	// FilterActionLevel writes the matching entries with another level
+	FilterActionLevel = "level"
+	// FilterActionFields adds fields to the matching entries
+	FilterActionFields = "fields"
+)
+
+// FilterRule matches entries by the name of their logger, their message or the value of a field. All criteria of
+// a rule must match.
+type FilterRule struct {
+	// Logger matches the entries of the logger with the name and of its children
+	Logger string
+	// Message is a regular expression that is matched against the message
+	Message string
+	// Field matches the entries with a field of the name. With a value, the value of the field must be equal.
+	Field string
+	Value string
+	// Action is drop, level or fields
+	Action string
+	// Level is the new level of the entries of the level action: debug, info, warning or error
+	Level string
+	// Fields are added to the entries by the fields action
+	Fields map[string]string
+}
+
+type filterRule struct {
+	logger  string
+	message *regexp.Regexp
+	field   string
+	value   string
+	drop    bool
+	level   *zapcore.Level
+	fields  []zapcore.Field
+}
+
+// Filter drops, changes the level of or adds fields to the entries that match its rules. The rules can be replaced
+// at runtime with SetRules, so that known noisy entries can be silenced without a restart.
+type Filter struct {
+	rules    atomic.Pointer[[]filterRule]
+	original atomic.Pointer[[]FilterRule]
+}
+
+// NewFilter creates a filter of the rules. Wrap the cores of the loggers with Core or WithFilter.
+func NewFilter(rules []FilterRule) (*Filter, error) {
+	f := &Filter{}
+	if err := f.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetRules replaces the rules of the filter. The rules are applied to all entries written afterward. On an invalid
+// rule, the previous rules are kept.
+func (f *Filter) SetRules(rules []FilterRule) error {
+	compiled := make([]filterRule, 0, len(rules))
+	for i, rule := range rules {
+		r, err := compileFilterRule(rule)
+		if err != nil {
+			return fmt.Errorf("invalid log filter rule %d: %w", i, err)
+		}
+		compiled = append(compiled, r)
+	}
+
+	original := slices.Clone(rules)
+	f.rules.Store(&compiled)
+	f.original.Store(&original)
+	return nil
+}
+
+// Rules returns the current rules of the filter
+func (f *Filter) Rules() []FilterRule {
+	return slices.Clone(*f.original.Load())
+}
+
+func compileFilterRule(rule FilterRule) (filterRule, error) {
+	r := filterRule{logger: rule.Logger, field: rule.Field, value: rule.Value}
+	if rule.Logger == "" && rule.Message == "" && rule.Field == "" {
+		return r, fmt.Errorf("the logger, the message or the field is required")
+	}
+	if rule.Value != "" && rule.Field == "" {
+		return r, fmt.Errorf("the value requires a field")
+	}
+	if rule.Message != "" {
+		message, err := regexp.Compile(rule.Message)
+		if err != nil {
+			return r, err
+		}
+		r.message = message
+	}
+
+	switch rule.Action {
+	case FilterActionDrop:
+		r.drop = true
+	case FilterActionLevel:
+		level, err := ZapLogLevelFromString(rule.Level)
+		if err != nil {
+			return r, err
+		}
+		r.level = &level
+	case FilterActionFields:
+		if len(rule.Fields) == 0 {
+			return r, fmt.Errorf("the fields action requires fields")
+		}
+		keys := make([]string, 0, len(rule.Fields))
+		for key := range rule.Fields {
+			keys = append(keys, key)
+		}
+		// The fields are added in a stable order
+		slices.Sort(keys)
+		for _, key := range keys {
+			r.fields = append(r.fields, zap.String(key, rule.Fields[key]))
+		}
+	default:
+		return r, fmt.Errorf("unknown action '%s'", rule.Action)
+	}
+
+	return r, nil
+}
+
+func (r *filterRule) matches(ent zapcore.Entry, context, fields []zapcore.Field) bool {
+	if r.logger != "" && ent.LoggerName != r.logger && !strings.HasPrefix(ent.LoggerName, r.logger+".") {
+		return false
+	}
+	if r.message != nil && !r.message.MatchString(ent.Message) {
+		return false
+	}
+	if r.field != "" {
+		return r.matchesField(fields) || r.matchesField(context)
+	}
+	return true
+}
+
+func (r *filterRule) matchesField(fields []zapcore.Field) bool {
+	for _, field := range fields {
+		if field.Key != r.field {
+			continue
+		}
+		if r.value == "" {
+			return true
+		}
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		if fmt.Sprint(enc.Fields[field.Key]) == r.value {
+			return true
+		}
+	}
+	return false
+}
+
+// WithFilter returns an option that filters the entries of the logger
+func WithFilter(f *Filter) zap.Option {
+	return zap.WrapCore(f.Core)
+}
+
+// Core wraps the core, so that the rules are applied to all entries before they are written to it. A rule can't
+// raise the level of an entry that isn't enabled on the core.
+func (f *Filter) Core(core zapcore.Core) zapcore.Core {
+	return &filterCore{Core: core, filter: f}
+}
+
+type filterCore struct {
+	zapcore.Core
+	filter *Filter
+	// context are the fields added with With, so that the rules can match them
+	context []zapcore.Field
+}
+
+func (c *filterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &filterCore{
+		Core:    c.Core.With(fields),
+		filter:  c.filter,
+		context: append(slices.Clip(c.context), fields...),
+	}
+}
+
+func (c *filterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if len(*c.filter.rules.Load()) == 0 {
+		return c.Core.Check(ent, ce)
+	}
+	if !c.Core.Enabled(ent.Level) {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+func (c *filterCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	for _, rule := range *c.filter.rules.Load() {
+		if !rule.matches(ent, c.context, fields) {
+			continue
+		}
+		if rule.drop {
+			return nil
+		}
+		if rule.level != nil {
+			ent.Level = *rule.level
+		}
+		if len(rule.fields) > 0 {
+			fields = append(slices.Clip(fields), rule.fields...)
+		}
+	}
+
+	// The entry is checked again, so that the wrapped cores see its new level
+	if checked := c.Core.Check(ent, nil); checked != nil {
+		checked.Write(fields...)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithFilter(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	filter, err := NewFilter([]FilterRule{
+		{Logger: "kafka", Message: "^metadata refresh", Action: FilterActionDrop},
+		{Field: "subgraph", Value: "legacy", Action: FilterActionLevel, Level: "debug"},
+		{Message: "slow", Action: FilterActionLevel, Level: "error"},
+		{Message: "slow", Action: FilterActionFields, Fields: map[string]string{"owner": "team-a"}},
+	})
+	require.NoError(t, err)
+	logger := zap.New(core, WithFilter(filter))
+
+	logger.Named("kafka").Named("client").Warn("metadata refresh failed")
+	logger.Named("nats").Warn("metadata refresh failed")
+	logger.With(zap.String("subgraph", "legacy")).Warn("deprecated field used")
+	logger.Warn("deprecated field used", zap.String("subgraph", "products"))
+	logger.Info("slow request")
+
+	require.Equal(t, 1, logs.FilterMessage("metadata refresh failed").Len())
+	require.Equal(t, "nats", logs.FilterMessage("metadata refresh failed").All()[0].LoggerName)
+
+	// The entry of the legacy subgraph was downgraded below the level of the core
+	deprecated := logs.FilterMessage("deprecated field used").All()
+	require.Len(t, deprecated, 1)
+	require.Equal(t, "products", deprecated[0].ContextMap()["subgraph"])
+
+	slow := logs.FilterMessage("slow request").All()
+	require.Len(t, slow, 1)
+	require.Equal(t, zapcore.ErrorLevel, slow[0].Level)
+	require.Equal(t, "team-a", slow[0].ContextMap()["owner"])
+}
+
+func TestFilterSetRules(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	filter, err := NewFilter(nil)
+	require.NoError(t, err)
+	logger := zap.New(core, WithFilter(filter))
+
+	logger.Warn("noisy")
+	require.NoError(t, filter.SetRules([]FilterRule{{Message: "noisy", Action: FilterActionDrop}}))
+	logger.Warn("noisy")
+	require.Equal(t, 1, logs.FilterMessage("noisy").Len())
+
+	// Invalid rules keep the previous rules
+	require.Error(t, filter.SetRules([]FilterRule{{Message: "(", Action: FilterActionDrop}}))
+	require.Error(t, filter.SetRules([]FilterRule{{Action: FilterActionDrop}}))
+	require.Error(t, filter.SetRules([]FilterRule{{Message: "noisy", Action: FilterActionLevel}}))
+	require.Error(t, filter.SetRules([]FilterRule{{Message: "noisy", Action: "mute"}}))
+	require.Equal(t, []FilterRule{{Message: "noisy", Action: FilterActionDrop}}, filter.Rules())
+
+	require.NoError(t, filter.SetRules(nil))
+	logger.Warn("noisy")
+	require.Equal(t, 2, logs.FilterMessage("noisy").Len())
+}
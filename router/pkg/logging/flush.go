@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FlushMarkerMessage is the message of the last entry that is written on shutdown. It is only written after the
+// pending entries were flushed, so the entries before the marker weren't lost when it arrived at an output.
+const FlushMarkerMessage = "Logs flushed"
+
+// flushMarkerTimeout is the deadline of the flush of the marker. It is separate from the deadline of the outputs, so
+// that the marker is also flushed when an output used up the deadline.
+const flushMarkerTimeout = time.Second
+
+// FlushBarrier flushes the pending entries of the buffered and remote outputs on shutdown, e.g. the batches of the
+// Kafka sinks and the OTLP exporter, so that the last entries before the exit aren't lost.
+type FlushBarrier struct {
+	outputs []flushOutput
+}
+
+type flushOutput struct {
+	name  string
+	flush func(ctx context.Context) error
+}
+
+// FlushReport is the result of flushing the outputs of a FlushBarrier
+type FlushReport struct {
+	Duration time.Duration
+	// Flushed are the names of the outputs that were flushed
+	Flushed []string
+	// Failed are the errors of the outputs that failed or didn't finish before the deadline by their names
+	Failed map[string]error
+}
+
+// Complete returns whether all outputs were flushed
+func (r *FlushReport) Complete() bool {
+	return len(r.Failed) == 0
+}
+
+func (r *FlushReport) fields() []zap.Field {
+	fields := []zap.Field{
+		zap.Duration("duration", r.Duration),
+		zap.Bool("complete", r.Complete()),
+		zap.Strings("flushed_outputs", r.Flushed),
+	}
+	if len(r.Failed) > 0 {
+		fields = append(fields, zap.Strings("failed_outputs", r.failedNames()))
+	}
+	return fields
+}
+
+func (r *FlushReport) failedNames() []string {
+	names := make([]string, 0, len(r.Failed))
+	for name := range r.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Add adds an output with its flush function. The function should return when the context is done. Functions that
+// can't be canceled, like the Sync of a sink, are abandoned at the deadline.
+func (b *FlushBarrier) Add(name string, flush func(ctx context.Context) error) {
+	b.outputs = append(b.outputs, flushOutput{name: name, flush: flush})
+}
+
+// Flush flushes all outputs concurrently and waits until they're flushed or the context is done
+func (b *FlushBarrier) Flush(ctx context.Context) FlushReport {
+	return flushOutputs(ctx, b.outputs)
+}
+
+func flushOutputs(ctx context.Context, outputs []flushOutput) FlushReport {
+	start := time.Now()
+	report := FlushReport{Flushed: []string{}, Failed: map[string]error{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, output := range outputs {
+		wg.Add(1)
+		go func(output flushOutput) {
+			defer wg.Done()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- output.flush(ctx)
+			}()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Failed[output.name] = err
+			} else {
+				report.Flushed = append(report.Flushed, output.name)
+			}
+		}(output)
+	}
+	wg.Wait()
+
+	sort.Strings(report.Flushed)
+	report.Duration = time.Since(start)
+	return report
+}
+
+// Run flushes the outputs, writes the marker with the result to the logger and flushes the outputs that were flushed
+// again, so that the marker is the last entry of the outputs. The outputs that failed are logged before the marker.
+// Without a logger no marker is written.
+func (b *FlushBarrier) Run(ctx context.Context, logger *zap.Logger) FlushReport {
+	report := b.Flush(ctx)
+	if logger == nil {
+		return report
+	}
+
+	for _, name := range report.failedNames() {
+		logger.Error("Could not flush the pending log entries", zap.String("output", name), zap.Error(report.Failed[name]))
+	}
+	logger.Info(FlushMarkerMessage, report.fields()...)
+
+	flushed := make([]flushOutput, 0, len(report.Flushed))
+	for _, output := range b.outputs {
+		if _, failed := report.Failed[output.name]; !failed {
+			flushed = append(flushed, output)
+		}
+	}
+	markerCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushMarkerTimeout)
+	defer cancel()
+	flushOutputs(markerCtx, flushed)
+
+	return report
+}
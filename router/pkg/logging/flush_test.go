@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFlushBarrier(t *testing.T) {
+	t.Parallel()
+
+	out := NewMemorySink()
+	buffered := &zapcore.BufferedWriteSyncer{WS: out, Size: 64 * 1024, FlushInterval: time.Hour}
+	t.Cleanup(func() { _ = buffered.Stop() })
+	logger := NewWithOutput(buffered, false, false, zap.InfoLevel)
+
+	blocked := make(chan struct{})
+	t.Cleanup(func() { close(blocked) })
+
+	barrier := &FlushBarrier{}
+	barrier.Add("stdout", func(context.Context) error {
+		return buffered.Sync()
+	})
+	barrier.Add("kafka", func(context.Context) error {
+		return errors.New("broker unavailable")
+	})
+	// A flush that doesn't return is abandoned at the deadline
+	barrier.Add("nats", func(context.Context) error {
+		<-blocked
+		return nil
+	})
+
+	logger.Info("request")
+	require.Empty(t, out.Lines())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	report := barrier.Run(ctx, logger)
+
+	require.False(t, report.Complete())
+	require.Equal(t, []string{"stdout"}, report.Flushed)
+	require.EqualError(t, report.Failed["kafka"], "broker unavailable")
+	require.ErrorIs(t, report.Failed["nats"], context.DeadlineExceeded)
+
+	// The marker is the last entry and was flushed with the second flush
+	entries := out.Entries(t)
+	require.Len(t, entries, 4)
+	require.Equal(t, "request", entries[0]["msg"])
+	require.Equal(t, "kafka", entries[1]["output"])
+	require.Equal(t, "nats", entries[2]["output"])
+	out.RequireEntry(t, FlushMarkerMessage, map[string]any{
+		"complete":        false,
+		"flushed_outputs": []any{"stdout"},
+		"failed_outputs":  []any{"kafka", "nats"},
+	})
+	require.Equal(t, FlushMarkerMessage, entries[3]["msg"])
+}
+
+func TestFlushBarrierWithoutMarker(t *testing.T) {
+	t.Parallel()
+
+	flushed := 0
+	barrier := &FlushBarrier{}
+	barrier.Add("otlp", func(context.Context) error {
+		flushed++
+		return nil
+	})
+
+	report := barrier.Run(context.Background(), nil)
+	require.True(t, report.Complete())
+	require.Equal(t, []string{"otlp"}, report.Flushed)
+	require.Equal(t, 1, flushed)
+}
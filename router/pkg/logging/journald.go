@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultJournaldSocket is the socket of the native protocol of journald
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+type JournaldOptions struct {
+	// Identifier is the SYSLOG_IDENTIFIER of the entries. If empty, "router" is used.
+	Identifier string
+	// SocketPath is the socket of journald. If empty, DefaultJournaldSocket is used.
+	SocketPath string
+}
+
+// JournaldSink writes the entries to journald with its native protocol. The levels are mapped to the priorities of
+// journald and the logger name is kept in the LOGGER field. The socket is reopened when journald was restarted.
+type JournaldSink struct {
+	identifier string
+	addr       *net.UnixAddr
+
+	mu     sync.Mutex
+	conn   *net.UnixConn
+	closed bool
+}
+
+func NewJournaldSink(opts *JournaldOptions) (*JournaldSink, error) {
+	s := &JournaldSink{
+		identifier: opts.Identifier,
+		addr:       &net.UnixAddr{Name: opts.SocketPath, Net: "unixgram"},
+	}
+	if s.identifier == "" {
+		s.identifier = "router"
+	}
+	if s.addr.Name == "" {
+		s.addr.Name = DefaultJournaldSocket
+	}
+
+	// Fail early when the router doesn't run under systemd
+	if err := s.connect(); err != nil {
+		return nil, errors.New("failed to connect to journald: " + err.Error())
+	}
+	return s, nil
+}
+
+func (s *JournaldSink) connect() error {
+	conn, err := net.DialUnix("unixgram", nil, s.addr)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *JournaldSink) WriteEntry(entry zapcore.Entry, encoded []byte) error {
+	var msg bytes.Buffer
+	appendJournaldField(&msg, "PRIORITY", []byte(strconv.Itoa(syslogSeverity(entry.Level))))
+	appendJournaldField(&msg, "SYSLOG_IDENTIFIER", []byte(s.identifier))
+	if entry.LoggerName != "" {
+		appendJournaldField(&msg, "LOGGER", []byte(entry.LoggerName))
+	}
+	appendJournaldField(&msg, "MESSAGE", encoded)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("the journald sink is closed")
+	}
+
+	// A restarted journald has a new socket, the datagrams to the old one fail once
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		if _, err = s.conn.Write(msg.Bytes()); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// appendJournaldField appends a field of the native protocol. Values with newlines are written with their length.
+func appendJournaldField(buf *bytes.Buffer, name string, value []byte) {
+	buf.WriteString(name)
+	if bytes.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.Write(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
+
+func (s *JournaldSink) Sync() error {
+	return nil
+}
+
+func (s *JournaldSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+)
+
+var kafkaCompressions = map[string]kgo.CompressionCodec{
+	"none":   kgo.NoCompression(),
+	"gzip":   kgo.GzipCompression(),
+	"snappy": kgo.SnappyCompression(),
+	"lz4":    kgo.Lz4Compression(),
+	"zstd":   kgo.ZstdCompression(),
+}
+
+type KafkaSinkOptions struct {
+	Brokers []string
+	Topic   string
+	// TLSConfig enables TLS. If nil, the connection is not encrypted.
+	TLSConfig *tls.Config
+	// Username and Password authenticate with SASL/PLAIN, if set
+	Username string
+	Password string
+	// Compression is none, gzip, snappy, lz4 or zstd. If empty, the batches aren't compressed.
+	Compression string
+	Stream      StreamOptions
+}
+
+type kafkaPublisher struct {
+	client *kgo.Client
+	topic  string
+}
+
+// NewKafkaSink creates a sink that publishes every entry as a record to the topic. The level of the entry is set as
+// header, so that consumers can filter without decoding the entries.
+func NewKafkaSink(opts *KafkaSinkOptions) (*StreamSink, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, errors.New("the brokers of the kafka sink must not be empty")
+	}
+	if opts.Topic == "" {
+		return nil, errors.New("the topic of the kafka sink must not be empty")
+	}
+
+	compression := "none"
+	if opts.Compression != "" {
+		compression = opts.Compression
+	}
+	codec, ok := kafkaCompressions[compression]
+	if !ok {
+		return nil, errors.New("unknown kafka compression '" + opts.Compression + "'")
+	}
+
+	kafkaOpts := []kgo.Opt{
+		kgo.SeedBrokers(opts.Brokers...),
+		kgo.DefaultProduceTopic(opts.Topic),
+		kgo.ProducerBatchCompression(codec),
+		kgo.ClientID("cosmo.router.logs"),
+	}
+	if opts.TLSConfig != nil {
+		kafkaOpts = append(kafkaOpts, kgo.DialTLSConfig(opts.TLSConfig))
+	}
+	if opts.Username != "" {
+		kafkaOpts = append(kafkaOpts, kgo.SASL(plain.Auth{User: opts.Username, Pass: opts.Password}.AsMechanism()))
+	}
+
+	client, err := kgo.NewClient(kafkaOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamSink("kafka", &kafkaPublisher{client: client, topic: opts.Topic}, &opts.Stream), nil
+}
+
+func (p *kafkaPublisher) publish(ctx context.Context, batch []streamEntry) error {
+	records := make([]*kgo.Record, len(batch))
+	for i, e := range batch {
+		records[i] = &kgo.Record{
+			Topic:     p.topic,
+			Value:     e.encoded,
+			Timestamp: e.time,
+			Headers:   []kgo.RecordHeader{{Key: "level", Value: []byte(e.level.String())}},
+		}
+	}
+	return p.client.ProduceSync(ctx, records...).FirstErr()
+}
+
+func (p *kafkaPublisher) close() {
+	p.client.Close()
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ToggleDebugLevelOnSignal switches the level between DEBUG and the current level every time the process receives
+// one of the signals, so that a live router can be debugged without a restart. If the current level is DEBUG, it
+// switches between DEBUG and INFO. The listener stops when the context is done.
+func ToggleDebugLevelOnSignal(ctx context.Context, logger *zap.Logger, level *zap.AtomicLevel, signals ...os.Signal) {
+	base := level.Level()
+	if base == zapcore.DebugLevel {
+		base = zapcore.InfoLevel
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				previous, current := toggleDebugLevel(level, base)
+				logger.Info("Log level changed by signal",
+					zap.String("signal", sig.String()),
+					zap.String("previous_level", previous.String()),
+					zap.String("level", current.String()),
+				)
+			}
+		}
+	}()
+}
+
+// toggleDebugLevel sets the level to DEBUG, or back to the base level if it's DEBUG already. The level can also be
+// changed in between, e.g. with the admin API, so the current level decides the direction.
+func toggleDebugLevel(level *zap.AtomicLevel, base zapcore.Level) (previous, current zapcore.Level) {
+	previous = level.Level()
+	current = zapcore.DebugLevel
+	if previous == zapcore.DebugLevel {
+		current = base
+	}
+	level.SetLevel(current)
+	return previous, current
+}
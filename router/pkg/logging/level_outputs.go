@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"errors"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelOutput writes the entries within a range of levels to a standard stream or to a rotated file, e.g. the
+// warnings and errors to stderr and everything else to stdout
+type LevelOutput struct {
+	// Levels selects the entries of the output, e.g. LevelRange(zapcore.WarnLevel, zapcore.FatalLevel). If nil, all
+	// entries are written. The level of the logger applies in addition.
+	Levels zapcore.LevelEnabler
+	// Stream is stdout or stderr. If empty, the entries are written to the standard output of the logger.
+	Stream string
+	// Encoding is the encoding of the entries in the stream. If empty, it is the same as of the standard output.
+	Encoding string
+	// File writes the entries to a rotated file instead of a stream. The file has its own encoding.
+	File *FileOutput
+}
+
+// LevelRange enables the levels from min to max, both inclusive
+func LevelRange(min, max zapcore.Level) zapcore.LevelEnabler {
+	return levelRange{min: min, max: max}
+}
+
+type levelRange struct {
+	min zapcore.Level
+	max zapcore.Level
+}
+
+func (r levelRange) Enabled(level zapcore.Level) bool {
+	return level >= r.min && level <= r.max
+}
+
+// levelIntersection enables the levels that all its enablers enable
+type levelIntersection []zapcore.LevelEnabler
+
+func (l levelIntersection) Enabled(level zapcore.Level) bool {
+	for _, enabler := range l {
+		if !enabler.Enabled(level) {
+			return false
+		}
+	}
+	return true
+}
+
+// newLevelOutputsCore tees a core per output. Every entry is written to all outputs whose levels contain it, so
+// overlapping ranges write it more than once.
+func newLevelOutputsCore(outputs []LevelOutput, level zapcore.LevelEnabler, stdout zapcore.WriteSyncer, stdoutEncoding string, fileSyncer func(output *FileOutput) (zapcore.WriteSyncer, error)) (zapcore.Core, error) {
+	cores := make([]zapcore.Core, 0, len(outputs))
+	for i := range outputs {
+		output := &outputs[i]
+
+		enabler := level
+		if output.Levels != nil {
+			enabler = levelIntersection{level, output.Levels}
+		}
+
+		var syncer zapcore.WriteSyncer
+		encoding := output.Encoding
+		switch {
+		case output.File != nil:
+			var err error
+			if syncer, err = fileSyncer(output.File); err != nil {
+				return nil, err
+			}
+			encoding = output.File.Encoding
+		case output.Stream != "":
+			var err error
+			if syncer, err = StandardOutput(output.Stream); err != nil {
+				return nil, err
+			}
+		default:
+			syncer = stdout
+		}
+
+		if encoding == "" {
+			encoding = stdoutEncoding
+		}
+		encoder, err := NewEncoder(encoding)
+		if err != nil {
+			return nil, errors.New("unknown encoding '" + encoding + "' of a level output")
+		}
+		cores = append(cores, zapcore.NewCore(encoder, syncer, enabler))
+	}
+
+	return zapcore.NewTee(cores...), nil
+}
+
+// LevelSet enables exactly the levels
+func LevelSet(levels ...zapcore.Level) zapcore.LevelEnabler {
+	set := levelSet{}
+	for _, level := range levels {
+		set[level] = struct{}{}
+	}
+	return set
+}
+
+type levelSet map[zapcore.Level]struct{}
+
+func (s levelSet) Enabled(level zapcore.Level) bool {
+	_, ok := s[level]
+	return ok
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestToggleDebugLevel(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.WarnLevel)
+
+	previous, current := toggleDebugLevel(&level, zapcore.WarnLevel)
+	require.Equal(t, zapcore.WarnLevel, previous)
+	require.Equal(t, zapcore.DebugLevel, current)
+	require.Equal(t, zapcore.DebugLevel, level.Level())
+
+	_, current = toggleDebugLevel(&level, zapcore.WarnLevel)
+	require.Equal(t, zapcore.WarnLevel, current)
+
+	// A level that was changed in between, e.g. with the admin API, is switched to DEBUG as well
+	level.SetLevel(zapcore.ErrorLevel)
+	_, current = toggleDebugLevel(&level, zapcore.WarnLevel)
+	require.Equal(t, zapcore.DebugLevel, current)
+}
+
+func TestToggleDebugLevelOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent to the own process on windows")
+	}
+
+	dir := t.TempDir()
+	routerLog := filepath.Join(dir, "router.log")
+	stdout := &syncBuffer{}
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+	logger, err := NewWithFileOutputs(false, false, level, &FileOutputs{
+		Loggers: []LoggerFileOutput{{LoggerName: "router", File: FileOutput{Path: routerLog}}},
+		Stdout:  zapcore.AddSync(stdout),
+	})
+	require.NoError(t, err)
+
+	changes, logs := observer.New(zapcore.InfoLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ToggleDebugLevelOnSignal(ctx, zap.New(changes), &level, syscall.SIGHUP)
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+
+	require.Eventually(t, func() bool { return level.Level() == zapcore.DebugLevel }, 5*time.Second, 10*time.Millisecond)
+
+	// Both the stdout and the file core use the level
+	logger.Debug("stdout")
+	logger.Named("router").Debug("file")
+	require.NoError(t, logger.Sync())
+
+	require.Contains(t, stdout.String(), `"msg":"stdout"`)
+	data, err := os.ReadFile(routerLog)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"msg":"file"`)
+
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+	require.Eventually(t, func() bool { return level.Level() == zapcore.InfoLevel }, 5*time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool { return logs.Len() == 2 }, 5*time.Second, 10*time.Millisecond)
+	entry := logs.All()[0]
+	require.Equal(t, "Log level changed by signal", entry.Message)
+	require.Equal(t, "info", entry.ContextMap()["previous_level"])
+	require.Equal(t, "debug", entry.ContextMap()["level"])
+	require.True(t, strings.HasPrefix(entry.ContextMap()["signal"].(string), "hangup"))
+}
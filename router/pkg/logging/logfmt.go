@@ -0,0 +1,262 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder encodes the log entries as a line of key=value pairs. Values with spaces, quotes or equal signs are
+// quoted, arrays and objects are encoded as quoted JSON. The keys of namespaces are prefixed with the namespace.
+type logfmtEncoder struct {
+	cfg *zapcore.EncoderConfig
+	buf *buffer.Buffer
+	// namespace is the prefix of the keys of the open namespaces, e.g. "a.b."
+	namespace string
+	// timeFormat and durationFormat are the formats of SetEncoderFormats. If empty, the logfmt defaults are used.
+	timeFormat     string
+	durationFormat string
+}
+
+// NewLogfmtEncoder creates an encoder that writes the entries as logfmt
+func NewLogfmtEncoder() zapcore.Encoder {
+	ec := zapBaseEncoderConfig()
+	timeFormat, durationFormat := currentEncoderFormats()
+	return &logfmtEncoder{
+		cfg:            &ec,
+		buf:            logfmtPool.Get(),
+		timeFormat:     timeFormat,
+		durationFormat: durationFormat,
+	}
+}
+
+func (enc *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := &logfmtEncoder{
+		cfg:            enc.cfg,
+		buf:            logfmtPool.Get(),
+		namespace:      enc.namespace,
+		timeFormat:     enc.timeFormat,
+		durationFormat: enc.durationFormat,
+	}
+	_, _ = clone.buf.Write(enc.buf.Bytes())
+	return clone
+}
+
+func (enc *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	line := &logfmtEncoder{cfg: enc.cfg, buf: logfmtPool.Get(), timeFormat: enc.timeFormat, durationFormat: enc.durationFormat}
+
+	if line.cfg.TimeKey != "" {
+		line.addRaw(line.cfg.TimeKey, line.formatTime(ent.Time, "2006-01-02T15:04:05.000Z07:00"))
+	}
+	if line.cfg.LevelKey != "" {
+		line.addRaw(line.cfg.LevelKey, ent.Level.String())
+	}
+	if ent.LoggerName != "" && line.cfg.NameKey != "" {
+		line.AddString(line.cfg.NameKey, ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		if line.cfg.CallerKey != "" {
+			line.AddString(line.cfg.CallerKey, ent.Caller.TrimmedPath())
+		}
+		if line.cfg.FunctionKey != "" {
+			line.AddString(line.cfg.FunctionKey, ent.Caller.Function)
+		}
+	}
+	if line.cfg.MessageKey != "" {
+		line.AddString(line.cfg.MessageKey, ent.Message)
+	}
+
+	// The fields of the entry continue in the namespaces of the context
+	fieldsEnc := &logfmtEncoder{
+		cfg:            enc.cfg,
+		buf:            logfmtPool.Get(),
+		namespace:      enc.namespace,
+		timeFormat:     enc.timeFormat,
+		durationFormat: enc.durationFormat,
+	}
+	for i := range fields {
+		fields[i].AddTo(fieldsEnc)
+	}
+
+	for _, b := range []*buffer.Buffer{enc.buf, fieldsEnc.buf} {
+		if b.Len() > 0 {
+			line.separate()
+			_, _ = line.buf.Write(b.Bytes())
+		}
+	}
+	fieldsEnc.buf.Free()
+
+	if ent.Stack != "" && line.cfg.StacktraceKey != "" {
+		line.AddString(line.cfg.StacktraceKey, ent.Stack)
+	}
+
+	line.buf.AppendByte('\n')
+	return line.buf, nil
+}
+
+func (enc *logfmtEncoder) separate() {
+	if enc.buf.Len() > 0 {
+		enc.buf.AppendByte(' ')
+	}
+}
+
+func (enc *logfmtEncoder) addKey(key string) {
+	enc.separate()
+	enc.buf.AppendString(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, enc.namespace+key))
+	enc.buf.AppendByte('=')
+}
+
+// addRaw adds a value that never needs quotes
+func (enc *logfmtEncoder) addRaw(key, value string) {
+	enc.addKey(key)
+	enc.buf.AppendString(value)
+}
+
+// addJSON adds the value of the add function as quoted JSON
+func (enc *logfmtEncoder) addJSON(key string, add func(zapcore.ObjectEncoder) error) error {
+	m := zapcore.NewMapObjectEncoder()
+	if err := add(m); err != nil {
+		return err
+	}
+	data, err := json.Marshal(m.Fields[key])
+	if err != nil {
+		return err
+	}
+	enc.AddByteString(key, data)
+	return nil
+}
+
+func (enc *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return enc.addJSON(key, func(m zapcore.ObjectEncoder) error { return m.AddArray(key, arr) })
+}
+
+func (enc *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return enc.addJSON(key, func(m zapcore.ObjectEncoder) error { return m.AddObject(key, obj) })
+}
+
+func (enc *logfmtEncoder) AddReflected(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	enc.AddByteString(key, data)
+	return nil
+}
+
+func (enc *logfmtEncoder) AddBinary(key string, value []byte) {
+	enc.addRaw(key, base64.StdEncoding.EncodeToString(value))
+}
+
+func (enc *logfmtEncoder) AddByteString(key string, value []byte) {
+	enc.AddString(key, string(value))
+}
+
+func (enc *logfmtEncoder) AddBool(key string, value bool) {
+	enc.addKey(key)
+	enc.buf.AppendBool(value)
+}
+
+func (enc *logfmtEncoder) AddComplex128(key string, value complex128) {
+	enc.addRaw(key, strconv.FormatComplex(value, 'f', -1, 128))
+}
+
+func (enc *logfmtEncoder) AddComplex64(key string, value complex64) {
+	enc.addRaw(key, strconv.FormatComplex(complex128(value), 'f', -1, 64))
+}
+
+func (enc *logfmtEncoder) AddDuration(key string, value time.Duration) {
+	switch enc.durationFormat {
+	case DurationFormatSeconds:
+		enc.addRaw(key, strconv.FormatFloat(value.Seconds(), 'f', -1, 64))
+	case DurationFormatMillis:
+		enc.addRaw(key, strconv.FormatInt(value.Milliseconds(), 10))
+	default:
+		enc.addRaw(key, value.String())
+	}
+}
+
+func (enc *logfmtEncoder) AddFloat64(key string, value float64) {
+	enc.addKey(key)
+	enc.buf.AppendFloat(value, 64)
+}
+
+func (enc *logfmtEncoder) AddFloat32(key string, value float32) {
+	enc.addKey(key)
+	enc.buf.AppendFloat(float64(value), 32)
+}
+
+func (enc *logfmtEncoder) AddInt(key string, value int)     { enc.AddInt64(key, int64(value)) }
+func (enc *logfmtEncoder) AddInt32(key string, value int32) { enc.AddInt64(key, int64(value)) }
+func (enc *logfmtEncoder) AddInt16(key string, value int16) { enc.AddInt64(key, int64(value)) }
+func (enc *logfmtEncoder) AddInt8(key string, value int8)   { enc.AddInt64(key, int64(value)) }
+
+func (enc *logfmtEncoder) AddInt64(key string, value int64) {
+	enc.addKey(key)
+	enc.buf.AppendInt(value)
+}
+
+func (enc *logfmtEncoder) AddString(key, value string) {
+	enc.addKey(key)
+	if logfmtNeedsQuotes(value) {
+		enc.buf.AppendString(strconv.Quote(value))
+		return
+	}
+	enc.buf.AppendString(value)
+}
+
+func (enc *logfmtEncoder) AddTime(key string, value time.Time) {
+	enc.addRaw(key, enc.formatTime(value, time.RFC3339Nano))
+}
+
+// formatTime formats the time in the format of SetEncoderFormats or else in the layout
+func (enc *logfmtEncoder) formatTime(t time.Time, layout string) string {
+	switch enc.timeFormat {
+	case TimeFormatEpoch:
+		return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', -1, 64)
+	case TimeFormatEpochMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case TimeFormatRFC3339:
+		layout = time.RFC3339
+	case TimeFormatRFC3339Nano:
+		layout = time.RFC3339Nano
+	}
+	return t.UTC().Format(layout)
+}
+
+func (enc *logfmtEncoder) AddUint(key string, value uint)       { enc.AddUint64(key, uint64(value)) }
+func (enc *logfmtEncoder) AddUint32(key string, value uint32)   { enc.AddUint64(key, uint64(value)) }
+func (enc *logfmtEncoder) AddUint16(key string, value uint16)   { enc.AddUint64(key, uint64(value)) }
+func (enc *logfmtEncoder) AddUint8(key string, value uint8)     { enc.AddUint64(key, uint64(value)) }
+func (enc *logfmtEncoder) AddUintptr(key string, value uintptr) { enc.AddUint64(key, uint64(value)) }
+
+func (enc *logfmtEncoder) AddUint64(key string, value uint64) {
+	enc.addKey(key)
+	enc.buf.AppendUint(value)
+}
+
+func (enc *logfmtEncoder) OpenNamespace(key string) {
+	enc.namespace += key + "."
+}
+
+func logfmtNeedsQuotes(value string) bool {
+	if value == "" {
+		return true
+	}
+	return strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || !unicode.IsPrint(r)
+	}) >= 0
+}
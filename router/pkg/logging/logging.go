@@ -15,12 +15,58 @@ const (
 	requestIDField = "reqId"
 )
 
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
 type RequestIDKey struct{}
 
-func New(prettyLogging bool, debug bool, level zapcore.Level) *zap.Logger {
+// New creates the logger of the router. Pass a zap.AtomicLevel as level to change it at runtime.
+func New(prettyLogging bool, debug bool, level zapcore.LevelEnabler) *zap.Logger {
 	return newZapLogger(zapcore.AddSync(os.Stdout), prettyLogging, debug, level)
 }
 
+// NewWithOutput creates the logger of the router like New, but writes the entries to the output instead of stdout
+func NewWithOutput(output zapcore.WriteSyncer, prettyLogging bool, debug bool, level zapcore.LevelEnabler) *zap.Logger {
+	return newZapLogger(output, prettyLogging, debug, level)
+}
+
+// NewWithEncoding creates the logger of the router like NewWithOutput, but encodes the entries with a built-in or
+// registered encoding instead of choosing between JSON and the console encoding
+func NewWithEncoding(output zapcore.WriteSyncer, encoding string, debug bool, level zapcore.LevelEnabler) (*zap.Logger, error) {
+	encoder, err := NewEncoder(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return finishZapLogger(zapcore.NewCore(encoder, output, level), encoding == EncodingConsole || encoding == EncodingPretty, debug), nil
+}
+
+// WithTee returns an option that writes the entries of the logger to the cores in addition to its outputs. It lets an
+// application that embeds the router receive the entries of the router in its own logging pipeline. The cores apply
+// their own levels and encoders.
+func WithTee(cores ...zapcore.Core) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if len(cores) == 0 {
+			return core
+		}
+		return zapcore.NewTee(append([]zapcore.Core{core}, cores...)...)
+	})
+}
+
+// StandardOutput returns the standard stream of the name, stdout or stderr. If empty, stdout is returned. Container
+// log routers can separate the logs of the router from the access logs by their stream.
+func StandardOutput(name string) (zapcore.WriteSyncer, error) {
+	switch name {
+	case "", OutputStdout:
+		return zapcore.AddSync(os.Stdout), nil
+	case OutputStderr:
+		return zapcore.AddSync(os.Stderr), nil
+	default:
+		return nil, fmt.Errorf("unknown log output '%s'", name)
+	}
+}
+
 func zapBaseEncoderConfig() zapcore.EncoderConfig {
 	ec := zap.NewProductionEncoderConfig()
 	ec.EncodeDuration = zapcore.SecondsDurationEncoder
@@ -35,6 +81,7 @@ func ZapJsonEncoder() zapcore.Encoder {
 		millis := int64(math.Trunc(float64(nanos) / float64(time.Millisecond)))
 		enc.AppendInt64(millis)
 	}
+	applyEncoderFormats(&ec)
 	return zapcore.NewJSONEncoder(ec)
 }
 
@@ -43,6 +90,7 @@ func zapConsoleEncoder() zapcore.Encoder {
 	ec.ConsoleSeparator = " "
 	ec.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05 PM")
 	ec.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	applyEncoderFormats(&ec)
 	return zapcore.NewConsoleEncoder(ec)
 }
 
@@ -60,15 +108,20 @@ func attachBaseFields(logger *zap.Logger) *zap.Logger {
 	return logger
 }
 
-func newZapLogger(syncer zapcore.WriteSyncer, prettyLogging bool, debug bool, level zapcore.Level) *zap.Logger {
-	var encoder zapcore.Encoder
-	var zapOpts []zap.Option
-
+func newZapLogger(syncer zapcore.WriteSyncer, prettyLogging bool, debug bool, level zapcore.LevelEnabler) *zap.Logger {
+	encoder := ZapJsonEncoder()
 	if prettyLogging {
 		encoder = zapConsoleEncoder()
-	} else {
-		encoder = ZapJsonEncoder()
 	}
+	return finishZapLogger(zapcore.NewCore(
+		encoder,
+		syncer,
+		level,
+	), prettyLogging, debug)
+}
+
+func finishZapLogger(core zapcore.Core, prettyLogging bool, debug bool) *zap.Logger {
+	var zapOpts []zap.Option
 
 	if debug {
 		zapOpts = append(zapOpts, zap.AddCaller())
@@ -76,11 +129,7 @@ func newZapLogger(syncer zapcore.WriteSyncer, prettyLogging bool, debug bool, le
 
 	zapOpts = append(zapOpts, zap.AddStacktrace(zap.ErrorLevel))
 
-	zapLogger := zap.New(zapcore.NewCore(
-		encoder,
-		syncer,
-		level,
-	), zapOpts...)
+	zapLogger := zap.New(core, zapOpts...)
 
 	if prettyLogging {
 		return zapLogger
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStandardOutput(t *testing.T) {
+	output, err := StandardOutput("")
+	require.NoError(t, err)
+	require.Equal(t, zapcore.AddSync(os.Stdout), output)
+
+	output, err = StandardOutput(OutputStderr)
+	require.NoError(t, err)
+	require.Equal(t, zapcore.AddSync(os.Stderr), output)
+
+	_, err = StandardOutput("file")
+	require.EqualError(t, err, "unknown log output 'file'")
+}
+
+func TestWithTee(t *testing.T) {
+	sink := NewMemorySink()
+	external, logs := observer.New(zapcore.WarnLevel)
+
+	logger := NewWithOutput(sink, false, false, zapcore.InfoLevel).WithOptions(WithTee(external))
+	logger.Info("started")
+	logger.Warn("degraded", zap.String("subgraph", "employees"))
+
+	require.Len(t, sink.Lines(), 2)
+	// The tee applies its own level
+	require.Equal(t, 1, logs.Len())
+	require.Equal(t, "degraded", logs.All()[0].Message)
+	require.Equal(t, "employees", logs.All()[0].ContextMap()["subgraph"])
+}
@@ -0,0 +1,27 @@
+package logging
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Metrics counts the activity of the logging package, so that the router can expose it with its own metrics. The
+// dropped entries are exposed by the outputs that drop them, see AsyncWriteSyncer and StreamSink. It must be safe
+// for concurrent use.
+type Metrics interface {
+	// Entry counts a written entry of the level
+	Entry(level zapcore.Level)
+	// SinkError counts a failed write to the sink with the name
+	SinkError(sink string)
+	// Rotation counts a rotated log file
+	Rotation()
+}
+
+// WithMetrics returns an option that counts the written entries by level. Apply it after the options that filter
+// the entries, like the sampling, so that only the entries that reach the outputs are counted.
+func WithMetrics(metrics Metrics) zap.Option {
+	return zap.Hooks(func(entry zapcore.Entry) error {
+		metrics.Entry(entry.Level)
+		return nil
+	})
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type countingMetrics struct {
+	mu         sync.Mutex
+	entries    map[zapcore.Level]int
+	sinkErrors map[string]int
+	rotations  int
+}
+
+func newCountingMetrics() *countingMetrics {
+	return &countingMetrics{entries: map[zapcore.Level]int{}, sinkErrors: map[string]int{}}
+}
+
+func (m *countingMetrics) Entry(level zapcore.Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[level]++
+}
+
+func (m *countingMetrics) SinkError(sink string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sinkErrors[sink]++
+}
+
+func (m *countingMetrics) Rotation() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rotations++
+}
+
+type failingSink struct{}
+
+func (failingSink) WriteEntry(zapcore.Entry, []byte) error { return errors.New("unavailable") }
+func (failingSink) Sync() error                            { return nil }
+func (failingSink) Close() error                           { return nil }
+
+func TestMetrics(t *testing.T) {
+	metrics := newCountingMetrics()
+
+	output, err := NewSinkOutput(failingSink{}, "", zap.InfoLevel)
+	require.NoError(t, err)
+	// The failed writes are reported to the error output of the logger
+	var errorOutput bytes.Buffer
+	logger := zap.New(zapcore.NewNopCore(), WithSinks(output.WithMetrics("syslog", metrics)), zap.ErrorOutput(zapcore.AddSync(&errorOutput))).
+		WithOptions(WithMetrics(metrics))
+
+	logger.Info("first")
+	logger.Info("second")
+	logger.Error("third")
+	// Disabled entries aren't counted
+	logger.Debug("fourth")
+
+	require.Equal(t, map[zapcore.Level]int{zapcore.InfoLevel: 2, zapcore.ErrorLevel: 1}, metrics.entries)
+	require.Equal(t, map[string]int{"syslog": 3}, metrics.sinkErrors)
+	require.Contains(t, errorOutput.String(), "unavailable")
+}
+
+func TestMetricsStreamSink(t *testing.T) {
+	metrics := newCountingMetrics()
+	publisher := &fakePublisher{err: errors.New("unavailable")}
+	// The batch isn't full, so the entries are only published by Close
+	sink := newStreamSink("kafka", publisher, &StreamOptions{BatchSize: 10, FlushInterval: time.Hour, Metrics: metrics})
+
+	writeStreamEntries(t, sink, "first", "second")
+	// Close publishes the remaining entries and returns their errors
+	require.Error(t, sink.Close())
+
+	// The errors are counted per batch
+	require.Equal(t, map[string]int{"kafka": 1}, metrics.sinkErrors)
+}
+
+func TestMetricsRotation(t *testing.T) {
+	metrics := newCountingMetrics()
+	rotator := NewFileRotator(metrics)
+
+	logger, err := NewWithFileOutputs(false, false, zapcore.InfoLevel, &FileOutputs{
+		Default: &FileOutput{Path: filepath.Join(t.TempDir(), "router.log")},
+		Rotator: rotator,
+	})
+	require.NoError(t, err)
+	logger.Info("before")
+
+	require.NoError(t, rotator.Rotate())
+	require.Equal(t, 1, metrics.rotations)
+}
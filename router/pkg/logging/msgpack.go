@@ -0,0 +1,536 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var msgpackPool = buffer.NewPool()
+
+const (
+	msgpackNil     = 0xc0
+	msgpackFalse   = 0xc2
+	msgpackTrue    = 0xc3
+	msgpackBin8    = 0xc4
+	msgpackBin16   = 0xc5
+	msgpackBin32   = 0xc6
+	msgpackFloat32 = 0xca
+	msgpackFloat64 = 0xcb
+	msgpackUint8   = 0xcc
+	msgpackUint16  = 0xcd
+	msgpackUint32  = 0xce
+	msgpackUint64  = 0xcf
+	msgpackInt8    = 0xd0
+	msgpackInt16   = 0xd1
+	msgpackInt32   = 0xd2
+	msgpackInt64   = 0xd3
+	msgpackStr8    = 0xd9
+	msgpackStr16   = 0xda
+	msgpackStr32   = 0xdb
+	msgpackArray16 = 0xdc
+	msgpackArray32 = 0xdd
+	msgpackMap16   = 0xde
+	msgpackMap32   = 0xdf
+
+	msgpackFixMap   = 0x80
+	msgpackFixArray = 0x90
+	msgpackFixStr   = 0xa0
+)
+
+// msgpackContainer is an open map or array. Its header is written with the maximum size when it is opened and
+// shrunk to the smallest size when it is closed and the number of its elements is known.
+type msgpackContainer struct {
+	offset int
+	count  int
+	isMap  bool
+}
+
+// msgpackEncoder encodes the log entries as MessagePack maps with the same keys and values as the JSON encoder.
+// The entries are self-delimiting, so a file is a stream of maps without separators.
+type msgpackEncoder struct {
+	cfg *zapcore.EncoderConfig
+	buf []byte
+	// containers are the open maps and arrays. The first is the map of the entry, whose header is only written
+	// by EncodeEntry and has no offset in buf.
+	containers []msgpackContainer
+}
+
+// NewMsgpackEncoder creates an encoder that writes the entries as MessagePack. Decode them with the log-decode
+// command of the router.
+func NewMsgpackEncoder() zapcore.Encoder {
+	ec := zapBaseEncoderConfig()
+	ec.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendInt64(t.UnixMilli())
+	}
+	applyEncoderFormats(&ec)
+	return &msgpackEncoder{
+		cfg:        &ec,
+		containers: []msgpackContainer{{offset: -1, isMap: true}},
+	}
+}
+
+func (enc *msgpackEncoder) Clone() zapcore.Encoder {
+	return &msgpackEncoder{
+		cfg:        enc.cfg,
+		buf:        bytes.Clone(enc.buf),
+		containers: append([]msgpackContainer(nil), enc.containers...),
+	}
+}
+
+func (enc *msgpackEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := &msgpackEncoder{
+		cfg:        enc.cfg,
+		buf:        make([]byte, 0, 256+len(enc.buf)),
+		containers: []msgpackContainer{{offset: 0, isMap: true}},
+	}
+	final.writeContainerHeader(msgpackMap32)
+
+	if final.cfg.LevelKey != "" && final.cfg.EncodeLevel != nil {
+		final.addKey(final.cfg.LevelKey)
+		final.appendEncoded(func() { final.cfg.EncodeLevel(ent.Level, final) })
+	}
+	if final.cfg.TimeKey != "" {
+		final.AddTime(final.cfg.TimeKey, ent.Time)
+	}
+	if ent.LoggerName != "" && final.cfg.NameKey != "" {
+		final.addKey(final.cfg.NameKey)
+		if final.cfg.EncodeName != nil {
+			final.appendEncoded(func() { final.cfg.EncodeName(ent.LoggerName, final) })
+		} else {
+			final.AppendString(ent.LoggerName)
+		}
+	}
+	if ent.Caller.Defined {
+		if final.cfg.CallerKey != "" && final.cfg.EncodeCaller != nil {
+			final.addKey(final.cfg.CallerKey)
+			final.appendEncoded(func() { final.cfg.EncodeCaller(ent.Caller, final) })
+		}
+		if final.cfg.FunctionKey != "" {
+			final.AddString(final.cfg.FunctionKey, ent.Caller.Function)
+		}
+	}
+	if final.cfg.MessageKey != "" {
+		final.AddString(final.cfg.MessageKey, ent.Message)
+	}
+	if ent.Stack != "" && final.cfg.StacktraceKey != "" {
+		final.AddString(final.cfg.StacktraceKey, ent.Stack)
+	}
+
+	// The context of the logger continues the map of the entry, including its open namespaces
+	base := len(final.buf)
+	final.buf = append(final.buf, enc.buf...)
+	final.containers[0].count += enc.containers[0].count
+	for _, c := range enc.containers[1:] {
+		c.offset += base
+		final.containers = append(final.containers, c)
+	}
+
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+
+	final.closeContainers(0)
+
+	out := msgpackPool.Get()
+	_, _ = out.Write(final.buf)
+	return out, nil
+}
+
+// appendEncoded appends the value of an encoder function of the config. The count is only increased once, even
+// if the function appends nothing or several values.
+func (enc *msgpackEncoder) appendEncoded(encode func()) {
+	c := &enc.containers[len(enc.containers)-1]
+	count := c.count
+	offset := len(enc.buf)
+	encode()
+	if len(enc.buf) == offset {
+		enc.buf = append(enc.buf, msgpackNil)
+	}
+	c.count = count + 1
+}
+
+func (enc *msgpackEncoder) addKey(key string) {
+	enc.appendString(key)
+}
+
+func (enc *msgpackEncoder) element() {
+	enc.containers[len(enc.containers)-1].count++
+}
+
+func (enc *msgpackEncoder) writeContainerHeader(kind byte) {
+	enc.buf = append(enc.buf, kind, 0, 0, 0, 0)
+}
+
+func (enc *msgpackEncoder) openContainer(isMap bool) {
+	enc.element()
+	offset := len(enc.buf)
+	if isMap {
+		enc.writeContainerHeader(msgpackMap32)
+	} else {
+		enc.writeContainerHeader(msgpackArray32)
+	}
+	enc.containers = append(enc.containers, msgpackContainer{offset: offset, isMap: isMap})
+}
+
+// closeContainers closes the open containers down to the given depth, the innermost first
+func (enc *msgpackEncoder) closeContainers(depth int) {
+	for len(enc.containers) > depth {
+		c := enc.containers[len(enc.containers)-1]
+		enc.containers = enc.containers[:len(enc.containers)-1]
+		if c.offset < 0 {
+			continue
+		}
+
+		var header []byte
+		switch {
+		case c.count < 16 && c.isMap:
+			header = []byte{msgpackFixMap | byte(c.count)}
+		case c.count < 16:
+			header = []byte{msgpackFixArray | byte(c.count)}
+		case c.count <= math.MaxUint16 && c.isMap:
+			header = binary.BigEndian.AppendUint16([]byte{msgpackMap16}, uint16(c.count))
+		case c.count <= math.MaxUint16:
+			header = binary.BigEndian.AppendUint16([]byte{msgpackArray16}, uint16(c.count))
+		default:
+			binary.BigEndian.PutUint32(enc.buf[c.offset+1:], uint32(c.count))
+			continue
+		}
+
+		copy(enc.buf[c.offset:], header)
+		copy(enc.buf[c.offset+len(header):], enc.buf[c.offset+5:])
+		enc.buf = enc.buf[:len(enc.buf)-5+len(header)]
+	}
+}
+
+func (enc *msgpackEncoder) appendString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		enc.buf = append(enc.buf, msgpackFixStr|byte(n))
+	case n <= math.MaxUint8:
+		enc.buf = append(enc.buf, msgpackStr8, byte(n))
+	case n <= math.MaxUint16:
+		enc.buf = append(enc.buf, msgpackStr16)
+		enc.appendUint16(uint16(n))
+	default:
+		enc.buf = append(enc.buf, msgpackStr32)
+		enc.appendUint32(uint32(n))
+	}
+	enc.buf = append(enc.buf, s...)
+}
+
+func (enc *msgpackEncoder) appendUint16(v uint16) {
+	enc.buf = binary.BigEndian.AppendUint16(enc.buf, v)
+}
+
+func (enc *msgpackEncoder) appendUint32(v uint32) {
+	enc.buf = binary.BigEndian.AppendUint32(enc.buf, v)
+}
+
+func (enc *msgpackEncoder) appendUint64(v uint64) {
+	enc.buf = binary.BigEndian.AppendUint64(enc.buf, v)
+}
+
+// ObjectEncoder
+
+func (enc *msgpackEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	enc.addKey(key)
+	return enc.AppendArray(arr)
+}
+
+func (enc *msgpackEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	enc.addKey(key)
+	return enc.AppendObject(obj)
+}
+
+func (enc *msgpackEncoder) AddBinary(key string, value []byte) {
+	enc.addKey(key)
+	enc.element()
+	n := len(value)
+	switch {
+	case n <= math.MaxUint8:
+		enc.buf = append(enc.buf, msgpackBin8, byte(n))
+	case n <= math.MaxUint16:
+		enc.buf = append(enc.buf, msgpackBin16)
+		enc.appendUint16(uint16(n))
+	default:
+		enc.buf = append(enc.buf, msgpackBin32)
+		enc.appendUint32(uint32(n))
+	}
+	enc.buf = append(enc.buf, value...)
+}
+
+func (enc *msgpackEncoder) AddByteString(key string, value []byte) {
+	enc.addKey(key)
+	enc.AppendByteString(value)
+}
+
+func (enc *msgpackEncoder) AddBool(key string, value bool) {
+	enc.addKey(key)
+	enc.AppendBool(value)
+}
+
+func (enc *msgpackEncoder) AddComplex128(key string, value complex128) {
+	enc.addKey(key)
+	enc.AppendComplex128(value)
+}
+
+func (enc *msgpackEncoder) AddComplex64(key string, value complex64) {
+	enc.addKey(key)
+	enc.AppendComplex64(value)
+}
+
+func (enc *msgpackEncoder) AddDuration(key string, value time.Duration) {
+	enc.addKey(key)
+	enc.AppendDuration(value)
+}
+
+func (enc *msgpackEncoder) AddFloat64(key string, value float64) {
+	enc.addKey(key)
+	enc.AppendFloat64(value)
+}
+
+func (enc *msgpackEncoder) AddFloat32(key string, value float32) {
+	enc.addKey(key)
+	enc.AppendFloat32(value)
+}
+
+func (enc *msgpackEncoder) AddInt(key string, value int) { enc.AddInt64(key, int64(value)) }
+
+func (enc *msgpackEncoder) AddInt64(key string, value int64) {
+	enc.addKey(key)
+	enc.AppendInt64(value)
+}
+
+func (enc *msgpackEncoder) AddInt32(key string, value int32) { enc.AddInt64(key, int64(value)) }
+func (enc *msgpackEncoder) AddInt16(key string, value int16) { enc.AddInt64(key, int64(value)) }
+func (enc *msgpackEncoder) AddInt8(key string, value int8)   { enc.AddInt64(key, int64(value)) }
+
+func (enc *msgpackEncoder) AddString(key, value string) {
+	enc.addKey(key)
+	enc.AppendString(value)
+}
+
+func (enc *msgpackEncoder) AddTime(key string, value time.Time) {
+	enc.addKey(key)
+	enc.AppendTime(value)
+}
+
+func (enc *msgpackEncoder) AddUint(key string, value uint) { enc.AddUint64(key, uint64(value)) }
+
+func (enc *msgpackEncoder) AddUint64(key string, value uint64) {
+	enc.addKey(key)
+	enc.AppendUint64(value)
+}
+
+func (enc *msgpackEncoder) AddUint32(key string, value uint32)   { enc.AddUint64(key, uint64(value)) }
+func (enc *msgpackEncoder) AddUint16(key string, value uint16)   { enc.AddUint64(key, uint64(value)) }
+func (enc *msgpackEncoder) AddUint8(key string, value uint8)     { enc.AddUint64(key, uint64(value)) }
+func (enc *msgpackEncoder) AddUintptr(key string, value uintptr) { enc.AddUint64(key, uint64(value)) }
+
+func (enc *msgpackEncoder) AddReflected(key string, value interface{}) error {
+	enc.addKey(key)
+	return enc.AppendReflected(value)
+}
+
+// OpenNamespace nests the following fields in a map under the key. The map is closed with its parent.
+func (enc *msgpackEncoder) OpenNamespace(key string) {
+	enc.addKey(key)
+	enc.openContainer(true)
+}
+
+// ArrayEncoder
+
+func (enc *msgpackEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	depth := len(enc.containers)
+	enc.openContainer(false)
+	err := arr.MarshalLogArray(enc)
+	enc.closeContainers(depth)
+	return err
+}
+
+func (enc *msgpackEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	depth := len(enc.containers)
+	enc.openContainer(true)
+	err := obj.MarshalLogObject(enc)
+	enc.closeContainers(depth)
+	return err
+}
+
+func (enc *msgpackEncoder) AppendReflected(value interface{}) error {
+	// Like the JSON encoder, reflected values are encoded with their JSON representation
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return err
+	}
+	enc.appendValue(decoded)
+	return nil
+}
+
+func (enc *msgpackEncoder) appendValue(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		enc.element()
+		enc.buf = append(enc.buf, msgpackNil)
+	case bool:
+		enc.AppendBool(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			enc.AppendInt64(i)
+		} else if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			enc.AppendUint64(u)
+		} else {
+			f, _ := v.Float64()
+			enc.AppendFloat64(f)
+		}
+	case string:
+		enc.AppendString(v)
+	case []interface{}:
+		depth := len(enc.containers)
+		enc.openContainer(false)
+		for _, item := range v {
+			enc.appendValue(item)
+		}
+		enc.closeContainers(depth)
+	case map[string]interface{}:
+		depth := len(enc.containers)
+		enc.openContainer(true)
+		for key, item := range v {
+			enc.addKey(key)
+			enc.appendValue(item)
+		}
+		enc.closeContainers(depth)
+	}
+}
+
+func (enc *msgpackEncoder) AppendBool(value bool) {
+	enc.element()
+	if value {
+		enc.buf = append(enc.buf, msgpackTrue)
+	} else {
+		enc.buf = append(enc.buf, msgpackFalse)
+	}
+}
+
+func (enc *msgpackEncoder) AppendByteString(value []byte) {
+	enc.AppendString(string(value))
+}
+
+func (enc *msgpackEncoder) AppendComplex128(value complex128) {
+	enc.appendComplex(real(value), imag(value), 64)
+}
+
+func (enc *msgpackEncoder) AppendComplex64(value complex64) {
+	enc.appendComplex(float64(real(value)), float64(imag(value)), 32)
+}
+
+// appendComplex encodes complex numbers as strings like the JSON encoder, e.g. "1-2i"
+func (enc *msgpackEncoder) appendComplex(r, i float64, bitSize int) {
+	s := strconv.AppendFloat(nil, r, 'f', -1, bitSize)
+	if i >= 0 {
+		s = append(s, '+')
+	}
+	s = strconv.AppendFloat(s, i, 'f', -1, bitSize)
+	enc.AppendString(string(append(s, 'i')))
+}
+
+func (enc *msgpackEncoder) AppendDuration(value time.Duration) {
+	if enc.cfg.EncodeDuration == nil {
+		enc.AppendInt64(int64(value))
+		return
+	}
+	enc.appendEncoded(func() { enc.cfg.EncodeDuration(value, enc) })
+}
+
+func (enc *msgpackEncoder) AppendFloat64(value float64) {
+	enc.element()
+	enc.buf = append(enc.buf, msgpackFloat64)
+	enc.appendUint64(math.Float64bits(value))
+}
+
+func (enc *msgpackEncoder) AppendFloat32(value float32) {
+	enc.element()
+	enc.buf = append(enc.buf, msgpackFloat32)
+	enc.appendUint32(math.Float32bits(value))
+}
+
+func (enc *msgpackEncoder) AppendInt(value int) { enc.AppendInt64(int64(value)) }
+
+func (enc *msgpackEncoder) AppendInt64(value int64) {
+	if value >= 0 {
+		enc.AppendUint64(uint64(value))
+		return
+	}
+	enc.element()
+	switch {
+	case value >= -32:
+		enc.buf = append(enc.buf, byte(value))
+	case value >= math.MinInt8:
+		enc.buf = append(enc.buf, msgpackInt8, byte(value))
+	case value >= math.MinInt16:
+		enc.buf = append(enc.buf, msgpackInt16)
+		enc.appendUint16(uint16(value))
+	case value >= math.MinInt32:
+		enc.buf = append(enc.buf, msgpackInt32)
+		enc.appendUint32(uint32(value))
+	default:
+		enc.buf = append(enc.buf, msgpackInt64)
+		enc.appendUint64(uint64(value))
+	}
+}
+
+func (enc *msgpackEncoder) AppendInt32(value int32) { enc.AppendInt64(int64(value)) }
+func (enc *msgpackEncoder) AppendInt16(value int16) { enc.AppendInt64(int64(value)) }
+func (enc *msgpackEncoder) AppendInt8(value int8)   { enc.AppendInt64(int64(value)) }
+
+func (enc *msgpackEncoder) AppendString(value string) {
+	enc.element()
+	enc.appendString(value)
+}
+
+func (enc *msgpackEncoder) AppendTime(value time.Time) {
+	if enc.cfg.EncodeTime == nil {
+		enc.AppendInt64(value.UnixNano())
+		return
+	}
+	enc.appendEncoded(func() { enc.cfg.EncodeTime(value, enc) })
+}
+
+func (enc *msgpackEncoder) AppendUint(value uint) { enc.AppendUint64(uint64(value)) }
+
+func (enc *msgpackEncoder) AppendUint64(value uint64) {
+	enc.element()
+	switch {
+	case value <= 127:
+		enc.buf = append(enc.buf, byte(value))
+	case value <= math.MaxUint8:
+		enc.buf = append(enc.buf, msgpackUint8, byte(value))
+	case value <= math.MaxUint16:
+		enc.buf = append(enc.buf, msgpackUint16)
+		enc.appendUint16(uint16(value))
+	case value <= math.MaxUint32:
+		enc.buf = append(enc.buf, msgpackUint32)
+		enc.appendUint32(uint32(value))
+	default:
+		enc.buf = append(enc.buf, msgpackUint64)
+		enc.appendUint64(value)
+	}
+}
+
+func (enc *msgpackEncoder) AppendUint32(value uint32)   { enc.AppendUint64(uint64(value)) }
+func (enc *msgpackEncoder) AppendUint16(value uint16)   { enc.AppendUint64(uint64(value)) }
+func (enc *msgpackEncoder) AppendUint8(value uint8)     { enc.AppendUint64(uint64(value)) }
+func (enc *msgpackEncoder) AppendUintptr(value uintptr) { enc.AppendUint64(uint64(value)) }
@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// maxMsgpackLength limits the length of strings and containers to not allocate unbounded memory on corrupt input
+const maxMsgpackLength = 64 << 20
+
+// DecodeMsgpack converts a stream of MessagePack log entries to newline delimited JSON. The keys keep the order
+// of the entry, so the output matches the one of the JSON encoder.
+func DecodeMsgpack(r io.Reader, w io.Writer) error {
+	d := &msgpackDecoder{r: bufio.NewReader(r), w: bufio.NewWriter(w)}
+
+	for {
+		if _, err := d.r.Peek(1); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		if err := d.value(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if err := d.w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	return d.w.Flush()
+}
+
+type msgpackDecoder struct {
+	r       *bufio.Reader
+	w       *bufio.Writer
+	scratch [8]byte
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	_, err := io.ReadFull(d.r, d.scratch[:n])
+	return d.scratch[:n], err
+}
+
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *msgpackDecoder) length(n int) (int, error) {
+	length, err := d.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	if length > maxMsgpackLength {
+		return 0, fmt.Errorf("msgpack length %d exceeds the maximum", length)
+	}
+	return int(length), nil
+}
+
+func (d *msgpackDecoder) value() error {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case t <= 0x7f:
+		return d.writeRaw(strconv.AppendUint(nil, uint64(t), 10))
+	case t >= 0xe0:
+		return d.writeRaw(strconv.AppendInt(nil, int64(int8(t)), 10))
+	case t&0xf0 == msgpackFixMap:
+		return d.mapValue(int(t & 0x0f))
+	case t&0xf0 == msgpackFixArray:
+		return d.array(int(t & 0x0f))
+	case t&0xe0 == msgpackFixStr:
+		return d.str(int(t & 0x1f))
+	}
+
+	switch t {
+	case msgpackNil:
+		return d.writeRaw([]byte("null"))
+	case msgpackFalse:
+		return d.writeRaw([]byte("false"))
+	case msgpackTrue:
+		return d.writeRaw([]byte("true"))
+	case msgpackBin8, msgpackBin16, msgpackBin32:
+		n, err := d.length(1 << (t - msgpackBin8))
+		if err != nil {
+			return err
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(d.r, data); err != nil {
+			return err
+		}
+		// Like the JSON encoder, binary values are written as base64
+		return d.writeString(base64.StdEncoding.EncodeToString(data))
+	case msgpackFloat32:
+		v, err := d.uint(4)
+		if err != nil {
+			return err
+		}
+		return d.writeFloat(float64(math.Float32frombits(uint32(v))), 32)
+	case msgpackFloat64:
+		v, err := d.uint(8)
+		if err != nil {
+			return err
+		}
+		return d.writeFloat(math.Float64frombits(v), 64)
+	case msgpackUint8, msgpackUint16, msgpackUint32, msgpackUint64:
+		v, err := d.uint(1 << (t - msgpackUint8))
+		if err != nil {
+			return err
+		}
+		return d.writeRaw(strconv.AppendUint(nil, v, 10))
+	case msgpackInt8, msgpackInt16, msgpackInt32, msgpackInt64:
+		size := 1 << (t - msgpackInt8)
+		v, err := d.uint(size)
+		if err != nil {
+			return err
+		}
+		// Sign extend the value from its size
+		shift := 64 - 8*size
+		return d.writeRaw(strconv.AppendInt(nil, int64(v<<shift)>>shift, 10))
+	case msgpackStr8, msgpackStr16, msgpackStr32:
+		n, err := d.length(1 << (t - msgpackStr8))
+		if err != nil {
+			return err
+		}
+		return d.str(n)
+	case msgpackArray16, msgpackArray32:
+		n, err := d.length(2 << (t - msgpackArray16))
+		if err != nil {
+			return err
+		}
+		return d.array(n)
+	case msgpackMap16, msgpackMap32:
+		n, err := d.length(2 << (t - msgpackMap16))
+		if err != nil {
+			return err
+		}
+		return d.mapValue(n)
+	}
+
+	return fmt.Errorf("unsupported msgpack type 0x%x", t)
+}
+
+func (d *msgpackDecoder) str(n int) error {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return err
+	}
+	return d.writeString(string(data))
+}
+
+func (d *msgpackDecoder) array(n int) error {
+	if err := d.w.WriteByte('['); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if err := d.w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		if err := d.value(); err != nil {
+			return err
+		}
+	}
+	return d.w.WriteByte(']')
+}
+
+func (d *msgpackDecoder) mapValue(n int) error {
+	if err := d.w.WriteByte('{'); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if err := d.w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		// The encoder only writes string keys
+		if err := d.value(); err != nil {
+			return err
+		}
+		if err := d.w.WriteByte(':'); err != nil {
+			return err
+		}
+		if err := d.value(); err != nil {
+			return err
+		}
+	}
+	return d.w.WriteByte('}')
+}
+
+func (d *msgpackDecoder) writeString(s string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return d.writeRaw(data)
+}
+
+func (d *msgpackDecoder) writeFloat(v float64, bitSize int) error {
+	// Like the JSON encoder, values that can't be represented in JSON are written as strings
+	switch {
+	case math.IsNaN(v):
+		return d.writeString("NaN")
+	case math.IsInf(v, 1):
+		return d.writeString("+Inf")
+	case math.IsInf(v, -1):
+		return d.writeString("-Inf")
+	}
+	return d.writeRaw(strconv.AppendFloat(nil, v, 'f', -1, bitSize))
+}
+
+func (d *msgpackDecoder) writeRaw(data []byte) error {
+	_, err := d.w.Write(data)
+	return err
+}
@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type testUser struct {
+	Name  string
+	Roles []string
+}
+
+func (u testUser) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+	enc.OpenNamespace("access")
+	return enc.AddArray("roles", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for _, role := range u.Roles {
+			enc.AppendString(role)
+		}
+		return nil
+	}))
+}
+
+func TestMsgpackEncoder(t *testing.T) {
+	context := []zap.Field{
+		zap.String("hostname", "router-1"),
+		zap.Int("pid", 42),
+		zap.Namespace("request"),
+		zap.String("method", "POST"),
+	}
+	fields := []zap.Field{
+		zap.String("path", strings.Repeat("/graphql", 40)),
+		zap.Int("status", 200),
+		zap.Int64("small", -7),
+		zap.Int64("negative", -1000),
+		zap.Int64("min", math.MinInt64),
+		zap.Uint64("max", math.MaxUint64),
+		zap.Int32("int32", -70000),
+		zap.Float64("ratio", 0.25),
+		zap.Float64("nan", math.NaN()),
+		zap.Float32("float32", 1.5),
+		zap.Bool("cached", true),
+		zap.Duration("latency", 1500*time.Millisecond),
+		zap.Time("started", time.UnixMilli(1700000000000)),
+		zap.Binary("body", []byte{0, 1, 2, 255}),
+		zap.ByteString("query", []byte("{ employees { id } }")),
+		zap.Complex128("complex", complex(1, -2)),
+		zap.Object("user", testUser{Name: "jens", Roles: []string{"admin", "dev"}}),
+		zap.Strings("tags", make([]string, 20)),
+		zap.Any("variables", map[string]any{"first": 10, "filter": map[string]any{"ids": []any{1, 2.5, nil, "3"}}}),
+		zap.Error(errors.New("failed")),
+		zap.Namespace("details"),
+		zap.String("subgraph", "employees"),
+	}
+
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.UnixMilli(1700000000123),
+		LoggerName: "access",
+		Message:    "request",
+		Stack:      "main.go:1",
+	}
+
+	encode := func(enc zapcore.Encoder) []byte {
+		for _, f := range context {
+			f.AddTo(enc)
+		}
+		// The clone must not be affected by the fields of the entries
+		buf, err := enc.Clone().EncodeEntry(ent, fields)
+		require.NoError(t, err)
+		return bytes.Clone(buf.Bytes())
+	}
+
+	expected := encode(ZapJsonEncoder())
+	encoded := encode(NewMsgpackEncoder())
+	require.Less(t, len(encoded), len(expected))
+
+	// Entries are self-delimiting, so they can be concatenated
+	var decoded bytes.Buffer
+	require.NoError(t, DecodeMsgpack(bytes.NewReader(append(bytes.Clone(encoded), encoded...)), &decoded))
+
+	lines := strings.Split(strings.TrimSuffix(decoded.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.JSONEq(t, string(expected), lines[0])
+	require.JSONEq(t, string(expected), lines[1])
+	// The keys keep their order
+	require.True(t, strings.HasPrefix(lines[0], `{"level":"warn","time":1700000000123,"logger":"access","msg":"request"`), lines[0])
+}
+
+func TestMsgpackEncoderLargeContainers(t *testing.T) {
+	values := make([]int, 70000)
+	for i := range values {
+		values[i] = i
+	}
+
+	ent := zapcore.Entry{Time: time.Now(), Message: "values"}
+	for _, n := range []int{15, 16, 70000} {
+		buf, err := NewMsgpackEncoder().EncodeEntry(ent, []zap.Field{zap.Ints("values", values[:n])})
+		require.NoError(t, err)
+
+		expected, err := ZapJsonEncoder().EncodeEntry(ent, []zap.Field{zap.Ints("values", values[:n])})
+		require.NoError(t, err)
+
+		var decoded bytes.Buffer
+		require.NoError(t, DecodeMsgpack(bytes.NewReader(buf.Bytes()), &decoded))
+		require.JSONEq(t, expected.String(), decoded.String())
+	}
+}
+
+func TestDecodeMsgpackTruncated(t *testing.T) {
+	buf, err := NewMsgpackEncoder().EncodeEntry(zapcore.Entry{Message: "request"}, []zap.Field{zap.String("path", "/graphql")})
+	require.NoError(t, err)
+
+	err = DecodeMsgpack(bytes.NewReader(buf.Bytes()[:buf.Len()-3]), &bytes.Buffer{})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
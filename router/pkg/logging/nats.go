@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/nats-io/nats.go"
+)
+
+type NatsSinkOptions struct {
+	URL     string
+	Subject string
+	// Token or Username and Password authenticate with the server, if set
+	Token    string
+	Username string
+	Password string
+	// Compression is none or gzip. If empty, the batches aren't compressed.
+	Compression string
+	Stream      StreamOptions
+}
+
+type natsPublisher struct {
+	conn    *nats.Conn
+	subject string
+	gzip    bool
+}
+
+// NewNatsSink creates a sink that publishes every batch as one message to the subject. The entries of the message
+// are separated by newlines. Compressed messages have the header Content-Encoding: gzip.
+func NewNatsSink(opts *NatsSinkOptions) (*StreamSink, error) {
+	if opts.URL == "" {
+		return nil, errors.New("the url of the nats sink must not be empty")
+	}
+	if opts.Subject == "" {
+		return nil, errors.New("the subject of the nats sink must not be empty")
+	}
+
+	p := &natsPublisher{subject: opts.Subject}
+	switch opts.Compression {
+	case "", "none":
+	case "gzip":
+		p.gzip = true
+	default:
+		return nil, errors.New("unknown nats compression '" + opts.Compression + "'")
+	}
+
+	natsOpts := []nats.Option{nats.Name("cosmo.router.logs")}
+	if opts.Token != "" {
+		natsOpts = append(natsOpts, nats.Token(opts.Token))
+	} else if opts.Username != "" {
+		natsOpts = append(natsOpts, nats.UserInfo(opts.Username, opts.Password))
+	}
+
+	conn, err := nats.Connect(opts.URL, natsOpts...)
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return newStreamSink("nats", p, &opts.Stream), nil
+}
+
+func (p *natsPublisher) publish(ctx context.Context, batch []streamEntry) error {
+	msg, err := p.message(batch)
+	if err != nil {
+		return err
+	}
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	// The flush waits until the server received the message
+	return p.conn.FlushWithContext(ctx)
+}
+
+func (p *natsPublisher) message(batch []streamEntry) (*nats.Msg, error) {
+	var data bytes.Buffer
+	var w io.Writer = &data
+
+	var zw *gzip.Writer
+	if p.gzip {
+		zw = gzip.NewWriter(&data)
+		w = zw
+	}
+	for i, e := range batch {
+		if i > 0 {
+			_, _ = w.Write([]byte{'\n'})
+		}
+		_, _ = w.Write(e.encoded)
+	}
+
+	msg := nats.NewMsg(p.subject)
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		msg.Header.Set("Content-Encoding", "gzip")
+	}
+	msg.Data = data.Bytes()
+	return msg, nil
+}
+
+func (p *natsPublisher) close() {
+	p.conn.Close()
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
+
+const (
+	operationNameField        = "operation_name"
+	operationTypeField        = "operation_type"
+	operationSha256Field      = "operation_sha256"
+	persistedOperationIDField = "persisted_operation_id"
+	clientNameField           = "client_name"
+	clientVersionField        = "client_version"
+)
+
+// Operation is the metadata of a GraphQL operation that the entries of its request are enriched with
+type Operation struct {
+	Name string
+	// Type is query, mutation or subscription
+	Type string
+	// Sha256Hash is the hex encoded SHA-256 hash of the normalized operation, see OperationSha256
+	Sha256Hash string
+	// PersistedID is the hash of the persisted operation, if the operation is persisted
+	PersistedID   string
+	ClientName    string
+	ClientVersion string
+}
+
+// OperationSha256 returns the hex encoded SHA-256 hash of the normalized operation. The same operation has the same
+// hash regardless of its formatting, so the entries of its requests can be grouped.
+func OperationSha256(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Fields returns the fields of the metadata. The empty values are omitted, e.g. the name of an anonymous operation.
+func (o *Operation) Fields() []zap.Field {
+	fields := make([]zap.Field, 0, 6)
+	for _, field := range []struct{ key, value string }{
+		{operationNameField, o.Name},
+		{operationTypeField, o.Type},
+		{operationSha256Field, o.Sha256Hash},
+		{persistedOperationIDField, o.PersistedID},
+		{clientNameField, o.ClientName},
+		{clientVersionField, o.ClientVersion},
+	} {
+		if field.value != "" {
+			fields = append(fields, zap.String(field.key, field.value))
+		}
+	}
+	return fields
+}
+
+type operationContextKey struct{}
+
+// WithOperation returns a context that carries the metadata of the operation. The loggers of FromContext and
+// EnrichFromOperationContext have its fields.
+func WithOperation(ctx context.Context, operation *Operation) context.Context {
+	return context.WithValue(ctx, operationContextKey{}, operation)
+}
+
+// OperationFromContext returns the metadata of the operation of the context, or nil
+func OperationFromContext(ctx context.Context) *Operation {
+	operation, _ := ctx.Value(operationContextKey{}).(*Operation)
+	return operation
+}
+
+// EnrichFromOperationContext returns the logger with the fields of the operation of the context, like WithRequestID
+// adds the request ID. The logger is returned unchanged if the context has no operation, e.g. before the operation
+// is parsed. Use it in every phase of the request, so that all entries of the operation have the same fields.
+func EnrichFromOperationContext(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	operation := OperationFromContext(ctx)
+	if operation == nil {
+		return logger
+	}
+	return logger.With(operation.Fields()...)
+}
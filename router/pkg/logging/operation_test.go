@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEnrichFromOperationContext(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core).With(WithRequestID("1"))
+
+	// Without an operation the logger is unchanged
+	require.Same(t, logger, EnrichFromOperationContext(context.Background(), logger))
+
+	ctx := WithOperation(context.Background(), &Operation{
+		Name:          "Employees",
+		Type:          "query",
+		Sha256Hash:    OperationSha256("query Employees {employees {id}}"),
+		ClientName:    "dashboard",
+		ClientVersion: "1.0.0",
+	})
+	EnrichFromOperationContext(ctx, logger).Info("enriched")
+	FromContext(NewContext(ctx, logger)).Info("from context")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		// The persisted ID is empty and omitted
+		require.Equal(t, map[string]any{
+			"reqId":            "1",
+			"operation_name":   "Employees",
+			"operation_type":   "query",
+			"operation_sha256": "13a1ec5a077488d8b16cfacb05bc95df4cacb5173c2c0f4dd743fe336e522b3b",
+			"client_name":      "dashboard",
+			"client_version":   "1.0.0",
+		}, entry.ContextMap())
+	}
+}
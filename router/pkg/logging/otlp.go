@@ -0,0 +1,487 @@
+package logging
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel/otelconfig"
+)
+
+// otlpScopeName is the instrumentation scope of the exported log records
+const otlpScopeName = "github.com/wundergraph/cosmo/router"
+
+type OTLPOptions struct {
+	Exporter otelconfig.Exporter
+	// Endpoint is the URL of the collector. The records are sent with TLS if the scheme is https.
+	Endpoint string
+	// HTTPPath is the path of the logs of the http exporter. Empty uses /v1/logs.
+	HTTPPath string
+	Headers  map[string]string
+	// Resource describes the router, e.g. with the resource of the tracer, so that logs and traces can be correlated
+	Resource *resource.Resource
+	// BatchSize is the maximum number of records of an export. Zero uses 512.
+	BatchSize int
+	// QueueSize is the maximum number of records that wait for the export. Further records are dropped. Zero uses 2048.
+	QueueSize int
+	// BatchTimeout is the maximum time a record waits for its batch. Zero uses 5s.
+	BatchTimeout time.Duration
+	// ExportTimeout is the timeout of an export. Zero uses 30s.
+	ExportTimeout time.Duration
+	// OnError is called with the errors of the exports. The errors can't be logged with a logger that is exported.
+	OnError func(error)
+}
+
+// OTLPExporter exports log entries in batches with the OpenTelemetry protocol. The entries are queued, so that
+// logging never waits for the collector. Entries that don't fit into the queue are dropped.
+type OTLPExporter struct {
+	client        otlpLogClient
+	resource      *resourcepb.Resource
+	schemaURL     string
+	batchSize     int
+	batchTimeout  time.Duration
+	exportTimeout time.Duration
+	onError       func(error)
+
+	queue    chan *logspb.LogRecord
+	flushes  chan chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	dropped  atomic.Int64
+}
+
+// otlpLogClient sends the export requests to the collector
+type otlpLogClient interface {
+	export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error
+	shutdown() error
+}
+
+func NewOTLPExporter(opts *OTLPOptions) (*OTLPExporter, error) {
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenTelemetry logs endpoint: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid OpenTelemetry logs endpoint '%s': the host is missing", opts.Endpoint)
+	}
+	secure := u.Scheme == "https"
+
+	var client otlpLogClient
+	switch opts.Exporter {
+	case otelconfig.ExporterOLTPHTTP, "":
+		path := opts.HTTPPath
+		if path == "" {
+			path = otelconfig.DefaultLogsPath
+		}
+		client = newOTLPHTTPClient(u.Host, path, secure, opts.Headers)
+	case otelconfig.ExporterOLTPGRPC:
+		client, err = newOTLPGRPCClient(u.Host, secure, opts.Headers)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown exporter type: %s", opts.Exporter)
+	}
+
+	e := &OTLPExporter{
+		client:        client,
+		resource:      &resourcepb.Resource{},
+		batchSize:     opts.BatchSize,
+		batchTimeout:  opts.BatchTimeout,
+		exportTimeout: opts.ExportTimeout,
+		onError:       opts.OnError,
+		flushes:       make(chan chan struct{}),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = 512
+	}
+	if e.batchTimeout <= 0 {
+		e.batchTimeout = 5 * time.Second
+	}
+	if e.exportTimeout <= 0 {
+		e.exportTimeout = 30 * time.Second
+	}
+	if e.onError == nil {
+		e.onError = func(error) {}
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = 2048
+	}
+	e.queue = make(chan *logspb.LogRecord, queueSize)
+
+	if opts.Resource != nil {
+		e.resource.Attributes = otlpAttributes(opts.Resource.Attributes())
+		e.schemaURL = opts.Resource.SchemaURL()
+	}
+
+	go e.run()
+
+	return e, nil
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.batchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]*logspb.LogRecord, 0, e.batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		e.export(batch)
+		batch = make([]*logspb.LogRecord, 0, e.batchSize)
+	}
+	// drain exports the queued records without waiting for new ones
+	drain := func() {
+		for {
+			select {
+			case record := <-e.queue:
+				batch = append(batch, record)
+				if len(batch) >= e.batchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case record := <-e.queue:
+			batch = append(batch, record)
+			if len(batch) >= e.batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-e.flushes:
+			drain()
+			close(flushed)
+		case <-e.stop:
+			drain()
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) export(records []*logspb.LogRecord) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.exportTimeout)
+	defer cancel()
+
+	err := e.client.export(ctx, &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				Resource:  e.resource,
+				SchemaUrl: e.schemaURL,
+				ScopeLogs: []*logspb.ScopeLogs{
+					{
+						Scope:      &commonpb.InstrumentationScope{Name: otlpScopeName},
+						LogRecords: records,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		e.onError(fmt.Errorf("failed to export %d log records: %w", len(records), err))
+	}
+	if dropped := e.dropped.Swap(0); dropped > 0 {
+		e.onError(fmt.Errorf("dropped %d log records because the export queue was full", dropped))
+	}
+}
+
+func (e *OTLPExporter) enqueue(record *logspb.LogRecord) {
+	select {
+	case e.queue <- record:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Flush exports the queued records and waits until they are exported or the context is done
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case e.flushes <- flushed:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the queued records and closes the connection to the collector. Records of later entries are
+// dropped.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return e.client.shutdown()
+}
+
+// WithOTLP returns an option that additionally exports all log entries of the given level with the exporter
+func WithOTLP(exporter *OTLPExporter, level zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, &otlpCore{LevelEnabler: level, exporter: exporter})
+	})
+}
+
+// otlpCore converts the log entries to OTLP log records
+type otlpCore struct {
+	zapcore.LevelEnabler
+	exporter *OTLPExporter
+	fields   []zapcore.Field
+}
+
+func (c *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *otlpCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *otlpCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(enc)
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	c.exporter.enqueue(otlpLogRecord(entry, enc.Fields))
+
+	// The process exits after fatal entries
+	if entry.Level > zapcore.ErrorLevel {
+		ctx, cancel := context.WithTimeout(context.Background(), c.exporter.exportTimeout)
+		defer cancel()
+		return c.exporter.Flush(ctx)
+	}
+
+	return nil
+}
+
+func (c *otlpCore) Sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.exporter.exportTimeout)
+	defer cancel()
+	return c.exporter.Flush(ctx)
+}
+
+func otlpLogRecord(entry zapcore.Entry, fields map[string]any) *logspb.LogRecord {
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(entry.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(entry.Time.UnixNano()),
+		SeverityNumber:       otlpSeverity(entry.Level),
+		SeverityText:         entry.Level.CapitalString(),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: entry.Message}},
+	}
+
+	// The trace context of the access logs becomes the trace context of the record
+	if traceparent, ok := fields["traceparent"].(string); ok {
+		record.TraceId, record.SpanId = parseTraceparent(traceparent)
+	}
+	for _, key := range []string{"traceID", "trace_id"} {
+		if traceID, ok := fields[key].(string); ok {
+			if id, err := hex.DecodeString(traceID); err == nil && len(id) == 16 {
+				record.TraceId = id
+			}
+			delete(fields, key)
+		}
+	}
+	for _, key := range []string{"spanID", "span_id"} {
+		if spanID, ok := fields[key].(string); ok {
+			if id, err := hex.DecodeString(spanID); err == nil && len(id) == 8 {
+				record.SpanId = id
+			}
+			delete(fields, key)
+		}
+	}
+
+	if entry.LoggerName != "" {
+		fields["logger"] = entry.LoggerName
+	}
+	if entry.Caller.Defined {
+		fields["caller"] = entry.Caller.TrimmedPath()
+	}
+	if entry.Stack != "" {
+		fields["stacktrace"] = entry.Stack
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	record.Attributes = make([]*commonpb.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		record.Attributes = append(record.Attributes, &commonpb.KeyValue{Key: key, Value: otlpAnyValue(fields[key])})
+	}
+
+	return record
+}
+
+// parseTraceparent returns the trace and span ID of a W3C traceparent, e.g. 00-<trace-id>-<span-id>-01
+func parseTraceparent(traceparent string) (traceID, spanID []byte) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 {
+		return nil, nil
+	}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != 16 {
+		return nil, nil
+	}
+	spanID, err = hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != 8 {
+		return nil, nil
+	}
+	return traceID, spanID
+}
+
+func otlpSeverity(level zapcore.Level) logspb.SeverityNumber {
+	switch level {
+	case zapcore.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	case zapcore.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case zapcore.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case zapcore.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case zapcore.DPanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR2
+	case zapcore.PanicLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR3
+	case zapcore.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED
+	}
+}
+
+// otlpAnyValue converts the values of the zap map encoder
+func otlpAnyValue(value any) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case int:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int8:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int16:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case uint:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case uint8:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case uint16:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case uint32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case uint64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: int64(v)}}
+	case float32:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: float64(v)}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case []byte:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v}}
+	case time.Time:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Format(time.RFC3339Nano)}}
+	case time.Duration:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.String()}}
+	case []any:
+		values := make([]*commonpb.AnyValue, len(v))
+		for i, item := range v {
+			values[i] = otlpAnyValue(item)
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]*commonpb.KeyValue, len(keys))
+		for i, key := range keys {
+			values[i] = &commonpb.KeyValue{Key: key, Value: otlpAnyValue(v[key])}
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: values}}}
+	case error:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Error()}}
+	case fmt.Stringer:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.String()}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+	}
+}
+
+func otlpAttributes(attributes []attribute.KeyValue) []*commonpb.KeyValue {
+	values := make([]*commonpb.KeyValue, 0, len(attributes))
+	for _, kv := range attributes {
+		var value *commonpb.AnyValue
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			value = otlpAnyValue(kv.Value.AsBool())
+		case attribute.INT64:
+			value = otlpAnyValue(kv.Value.AsInt64())
+		case attribute.FLOAT64:
+			value = otlpAnyValue(kv.Value.AsFloat64())
+		default:
+			value = otlpAnyValue(kv.Value.Emit())
+		}
+		values = append(values, &commonpb.KeyValue{Key: string(kv.Key), Value: value})
+	}
+	return values
+}
@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+var errOTLPExportRejected = errors.New("the collector rejected log records")
+
+type otlpHTTPClient struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+func newOTLPHTTPClient(host, path string, secure bool, headers map[string]string) *otlpHTTPClient {
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	return &otlpHTTPClient{
+		client:  &http.Client{},
+		url:     scheme + "://" + host + path,
+		headers: headers,
+	}
+}
+
+func (c *otlpHTTPClient) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	if _, err := gz.Write(data); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, &body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	for name, value := range c.headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var exportResp collogspb.ExportLogsServiceResponse
+	if len(respBody) > 0 && resp.Header.Get("Content-Type") == "application/x-protobuf" {
+		if err := proto.Unmarshal(respBody, &exportResp); err != nil {
+			return err
+		}
+	}
+	return partialSuccessError(&exportResp)
+}
+
+func (c *otlpHTTPClient) shutdown() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+type otlpGRPCClient struct {
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	headers metadata.MD
+}
+
+func newOTLPGRPCClient(host string, secure bool, headers map[string]string) (*otlpGRPCClient, error) {
+	creds := insecure.NewCredentials()
+	if secure {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+
+	conn, err := grpc.Dial(host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the OpenTelemetry logs endpoint: %w", err)
+	}
+
+	return &otlpGRPCClient{
+		conn:    conn,
+		client:  collogspb.NewLogsServiceClient(conn),
+		headers: metadata.New(headers),
+	}, nil
+}
+
+func (c *otlpGRPCClient) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	if len(c.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, c.headers)
+	}
+	resp, err := c.client.Export(ctx, req, grpc.UseCompressor(grpcgzip.Name))
+	if err != nil {
+		return err
+	}
+	return partialSuccessError(resp)
+}
+
+func (c *otlpGRPCClient) shutdown() error {
+	return c.conn.Close()
+}
+
+func partialSuccessError(resp *collogspb.ExportLogsServiceResponse) error {
+	partialSuccess := resp.GetPartialSuccess()
+	if partialSuccess == nil || partialSuccess.GetRejectedLogRecords() == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d rejected: %s", errOTLPExportRejected, partialSuccess.GetRejectedLogRecords(), partialSuccess.GetErrorMessage())
+}
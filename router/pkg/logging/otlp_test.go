@@ -0,0 +1,219 @@
+package logging
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/wundergraph/cosmo/router/pkg/otel/otelconfig"
+)
+
+type otlpRequests struct {
+	mu       sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+	headers  []string
+}
+
+func (r *otlpRequests) add(req *collogspb.ExportLogsServiceRequest, header string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.headers = append(r.headers, header)
+}
+
+func (r *otlpRequests) records() []*logspb.LogRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var records []*logspb.LogRecord
+	for _, req := range r.requests {
+		for _, resourceLogs := range req.ResourceLogs {
+			for _, scopeLogs := range resourceLogs.ScopeLogs {
+				records = append(records, scopeLogs.LogRecords...)
+			}
+		}
+	}
+	return records
+}
+
+func otlpAttribute(attributes []*commonpb.KeyValue, key string) *commonpb.AnyValue {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+func TestOTLPExporterHTTP(t *testing.T) {
+	received := &otlpRequests{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/logs", r.URL.Path)
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		data, err := io.ReadAll(gz)
+		require.NoError(t, err)
+
+		var req collogspb.ExportLogsServiceRequest
+		require.NoError(t, proto.Unmarshal(data, &req))
+		received.add(&req, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	res := resource.NewSchemaless(attribute.String("service.name", "cosmo-router"), attribute.String("service.instance.id", "instance-1"))
+	exporter, err := NewOTLPExporter(&OTLPOptions{
+		Exporter:     otelconfig.ExporterOLTPHTTP,
+		Endpoint:     server.URL,
+		Headers:      map[string]string{"Authorization": "Bearer token"},
+		Resource:     res,
+		BatchSize:    2,
+		BatchTimeout: time.Hour,
+	})
+	require.NoError(t, err)
+
+	logger := zap.New(zapcore.NewNopCore(), zap.AddStacktrace(zap.ErrorLevel)).WithOptions(WithOTLP(exporter, zapcore.InfoLevel))
+	logger = logger.Named("access").With(zap.String("component", "router"))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	logger.Info("/graphql",
+		zap.Int("status", 200),
+		zap.Duration("latency", time.Millisecond),
+		zap.String("traceID", traceID),
+		zap.String("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01"),
+	)
+	logger.Debug("skipped")
+	// The batch is full with the second record
+	logger.Error("failed", zap.Error(errors.New("boom")))
+
+	require.Eventually(t, func() bool { return len(received.records()) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	records := received.records()
+	require.Equal(t, "/graphql", records[0].Body.GetStringValue())
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_INFO, records[0].SeverityNumber)
+	require.Equal(t, "INFO", records[0].SeverityText)
+	require.Equal(t, traceID, hex.EncodeToString(records[0].TraceId))
+	require.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(records[0].SpanId))
+	require.Equal(t, int64(200), otlpAttribute(records[0].Attributes, "status").GetIntValue())
+	require.Equal(t, "1ms", otlpAttribute(records[0].Attributes, "latency").GetStringValue())
+	require.Equal(t, "router", otlpAttribute(records[0].Attributes, "component").GetStringValue())
+	require.Equal(t, "access", otlpAttribute(records[0].Attributes, "logger").GetStringValue())
+	require.Nil(t, otlpAttribute(records[0].Attributes, "traceID"))
+
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, records[1].SeverityNumber)
+	require.Equal(t, "boom", otlpAttribute(records[1].Attributes, "error").GetStringValue())
+	require.NotEmpty(t, otlpAttribute(records[1].Attributes, "stacktrace").GetStringValue())
+
+	received.mu.Lock()
+	resourceLogs := received.requests[0].ResourceLogs[0]
+	require.Equal(t, "Bearer token", received.headers[0])
+	received.mu.Unlock()
+	require.Equal(t, "instance-1", otlpAttribute(resourceLogs.Resource.Attributes, "service.instance.id").GetStringValue())
+	require.Equal(t, otlpScopeName, resourceLogs.ScopeLogs[0].Scope.Name)
+
+	// The remaining records are exported with the shutdown
+	logger.Info("last")
+	require.NoError(t, exporter.Shutdown(context.Background()))
+	require.Len(t, received.records(), 3)
+
+	logger.Info("after shutdown")
+	require.NoError(t, logger.Sync())
+	require.Len(t, received.records(), 3)
+}
+
+type testLogsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	received *otlpRequests
+}
+
+func (s *testLogsServer) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		header = md.Get("authorization")[0]
+	}
+	s.received.add(req, header)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func TestOTLPExporterGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	received := &otlpRequests{}
+	server := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(server, &testLogsServer{received: received})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	exporter, err := NewOTLPExporter(&OTLPOptions{
+		Exporter: otelconfig.ExporterOLTPGRPC,
+		Endpoint: "http://" + listener.Addr().String(),
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+
+	logger := zap.New(zapcore.NewNopCore()).WithOptions(WithOTLP(exporter, zapcore.InfoLevel))
+	logger.Warn("slow request")
+	require.NoError(t, logger.Sync())
+
+	records := received.records()
+	require.Len(t, records, 1)
+	require.Equal(t, "slow request", records[0].Body.GetStringValue())
+	require.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_WARN, records[0].SeverityNumber)
+
+	received.mu.Lock()
+	require.Equal(t, "Bearer token", received.headers[0])
+	received.mu.Unlock()
+
+	require.NoError(t, exporter.Shutdown(context.Background()))
+}
+
+func TestOTLPExporterErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	errs := make(chan error, 10)
+	exporter, err := NewOTLPExporter(&OTLPOptions{
+		Endpoint:  server.URL,
+		QueueSize: 1,
+		OnError:   func(err error) { errs <- err },
+	})
+	require.NoError(t, err)
+
+	logger := zap.New(zapcore.NewNopCore()).WithOptions(WithOTLP(exporter, zapcore.InfoLevel))
+	logger.Info("first")
+	require.NoError(t, logger.Sync())
+
+	require.EqualError(t, <-errs, "failed to export 1 log records: unexpected status code 503")
+	require.NoError(t, exporter.Shutdown(context.Background()))
+
+	_, err = NewOTLPExporter(&OTLPOptions{Endpoint: "localhost"})
+	require.EqualError(t, err, "invalid OpenTelemetry logs endpoint 'localhost': the host is missing")
+
+	_, err = NewOTLPExporter(&OTLPOptions{Exporter: "kafka", Endpoint: "http://localhost:4318"})
+	require.EqualError(t, err, "unknown exporter type: kafka")
+}
@@ -0,0 +1,335 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// PrettyThemeDefault colors the levels with the standard colors of the terminal
+	PrettyThemeDefault = "default"
+	// PrettyThemeHighContrast colors the levels with bold bright colors
+	PrettyThemeHighContrast = "high_contrast"
+	// PrettyThemeMonochrome doesn't color the entries
+	PrettyThemeMonochrome = "monochrome"
+)
+
+const (
+	// PrettyFieldsYAML renders the structured fields as YAML
+	PrettyFieldsYAML = "yaml"
+	// PrettyFieldsJSON renders the structured fields as indented JSON
+	PrettyFieldsJSON = "json"
+)
+
+const ansiReset = "\x1b[0m"
+
+// prettyTheme are the ANSI escape sequences of the parts of an entry. Empty sequences leave the part uncolored.
+type prettyTheme struct {
+	name      string
+	key       string
+	highlight string
+	levels    map[zapcore.Level]string
+}
+
+var prettyThemes = map[string]*prettyTheme{
+	PrettyThemeDefault: {
+		name:      "\x1b[90m",
+		key:       "\x1b[36m",
+		highlight: "\x1b[35m",
+		levels: map[zapcore.Level]string{
+			zapcore.DebugLevel:  "\x1b[35m",
+			zapcore.InfoLevel:   "\x1b[34m",
+			zapcore.WarnLevel:   "\x1b[33m",
+			zapcore.ErrorLevel:  "\x1b[31m",
+			zapcore.DPanicLevel: "\x1b[31m",
+			zapcore.PanicLevel:  "\x1b[31m",
+			zapcore.FatalLevel:  "\x1b[31m",
+		},
+	},
+	PrettyThemeHighContrast: {
+		name:      "\x1b[97m",
+		key:       "\x1b[1;96m",
+		highlight: "\x1b[1;95m",
+		levels: map[zapcore.Level]string{
+			zapcore.DebugLevel:  "\x1b[1;95m",
+			zapcore.InfoLevel:   "\x1b[1;94m",
+			zapcore.WarnLevel:   "\x1b[1;93m",
+			zapcore.ErrorLevel:  "\x1b[1;91m",
+			zapcore.DPanicLevel: "\x1b[1;97;41m",
+			zapcore.PanicLevel:  "\x1b[1;97;41m",
+			zapcore.FatalLevel:  "\x1b[1;97;41m",
+		},
+	},
+	PrettyThemeMonochrome: {},
+}
+
+// prettyHighlightedFields are written first and highlighted, so that the entries of a request or a subgraph can be
+// followed
+var prettyHighlightedFields = []string{requestIDField, SubgraphField}
+
+var prettyPool = buffer.NewPool()
+
+var prettyOptions = struct {
+	mu          sync.RWMutex
+	theme       string
+	fieldFormat string
+}{}
+
+// SetPrettyOptions sets the color theme and the format of the structured fields of the pretty encoding. An empty
+// value keeps the default, the default theme and YAML. Set it before the loggers are created.
+func SetPrettyOptions(theme, fieldFormat string) error {
+	if _, ok := prettyThemes[theme]; theme != "" && !ok {
+		return errors.New("unknown pretty log theme '" + theme + "'")
+	}
+	switch fieldFormat {
+	case "", PrettyFieldsYAML, PrettyFieldsJSON:
+	default:
+		return errors.New("unknown pretty log field format '" + fieldFormat + "'")
+	}
+
+	prettyOptions.mu.Lock()
+	defer prettyOptions.mu.Unlock()
+	prettyOptions.theme = theme
+	prettyOptions.fieldFormat = fieldFormat
+	return nil
+}
+
+// prettyEncoder writes a line with the level, the logger, the message and the scalar fields of an entry, followed by
+// its structured fields as indented blocks. The fields are encoded with a JSON encoder, which holds the context of the
+// logger, and decoded again for the rendering.
+type prettyEncoder struct {
+	zapcore.Encoder
+	header      zapcore.Encoder
+	theme       *prettyTheme
+	fieldFormat string
+}
+
+// NewPrettyEncoder creates an encoder that writes the entries for humans in a terminal. The colors are disabled when
+// the NO_COLOR environment variable is set.
+func NewPrettyEncoder() zapcore.Encoder {
+	prettyOptions.mu.RLock()
+	themeName, fieldFormat := prettyOptions.theme, prettyOptions.fieldFormat
+	prettyOptions.mu.RUnlock()
+
+	if themeName == "" {
+		themeName = PrettyThemeDefault
+	}
+	// https://no-color.org
+	if os.Getenv("NO_COLOR") != "" {
+		themeName = PrettyThemeMonochrome
+	}
+	if fieldFormat == "" {
+		fieldFormat = PrettyFieldsYAML
+	}
+	theme := prettyThemes[themeName]
+
+	ec := zapBaseEncoderConfig()
+	ec.ConsoleSeparator = " "
+	ec.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05 PM")
+	ec.EncodeLevel = func(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(theme.colorize(theme.levels[level], level.CapitalString()))
+	}
+	ec.EncodeName = func(name string, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(theme.colorize(theme.name, name))
+	}
+	applyEncoderFormats(&ec)
+
+	// The fields are encoded without the keys of the entry, the timestamps of the fields keep their date
+	fields := zapBaseEncoderConfig()
+	fields.TimeKey = ""
+	fields.LevelKey = ""
+	fields.NameKey = ""
+	fields.CallerKey = ""
+	fields.MessageKey = ""
+	fields.StacktraceKey = ""
+	fields.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	applyEncoderFormats(&fields)
+
+	return &prettyEncoder{
+		Encoder:     zapcore.NewJSONEncoder(fields),
+		header:      zapcore.NewConsoleEncoder(ec),
+		theme:       theme,
+		fieldFormat: fieldFormat,
+	}
+}
+
+func (enc *prettyEncoder) Clone() zapcore.Encoder {
+	return &prettyEncoder{
+		Encoder:     enc.Encoder.Clone(),
+		header:      enc.header,
+		theme:       enc.theme,
+		fieldFormat: enc.fieldFormat,
+	}
+}
+
+func (enc *prettyEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	encoded, err := enc.Encoder.EncodeEntry(zapcore.Entry{}, fields)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	dec := json.NewDecoder(bytes.NewReader(encoded.Bytes()))
+	dec.UseNumber()
+	err = dec.Decode(&values)
+	encoded.Free()
+	if err != nil {
+		return nil, err
+	}
+
+	stack := ent.Stack
+	ent.Stack = ""
+	header, err := enc.header.EncodeEntry(ent, nil)
+	if err != nil {
+		return nil, err
+	}
+	line := prettyPool.Get()
+	_, _ = line.Write(bytes.TrimRight(header.Bytes(), "\n"))
+	header.Free()
+
+	var blocks []string
+	for _, key := range prettyFieldOrder(values) {
+		value := values[key]
+		if isPrettyBlock(value) {
+			blocks = append(blocks, key)
+			continue
+		}
+
+		keyColor, valueColor := enc.theme.key, ""
+		if slices.Contains(prettyHighlightedFields, key) {
+			keyColor, valueColor = enc.theme.highlight, enc.theme.highlight
+		}
+		line.AppendByte(' ')
+		line.AppendString(enc.theme.colorize(keyColor, key+"="))
+		line.AppendString(enc.theme.colorize(valueColor, formatPrettyScalar(value)))
+	}
+	line.AppendByte('\n')
+
+	for _, key := range blocks {
+		block, err := enc.formatBlock(values[key])
+		if err != nil {
+			line.Free()
+			return nil, err
+		}
+		line.AppendString("    ")
+		line.AppendString(enc.theme.colorize(enc.theme.key, key+":"))
+		line.AppendByte('\n')
+		for _, blockLine := range strings.Split(strings.TrimRight(block, "\n"), "\n") {
+			line.AppendString("      ")
+			line.AppendString(blockLine)
+			line.AppendByte('\n')
+		}
+	}
+
+	if stack != "" {
+		line.AppendString(stack)
+		line.AppendByte('\n')
+	}
+
+	return line, nil
+}
+
+func (enc *prettyEncoder) formatBlock(value any) (string, error) {
+	if enc.fieldFormat == PrettyFieldsJSON {
+		out, err := json.MarshalIndent(value, "", "  ")
+		return string(out), err
+	}
+	out, err := yaml.Marshal(normalizePrettyNumbers(value))
+	return string(out), err
+}
+
+func (t *prettyTheme) colorize(color, s string) string {
+	if color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// prettyFieldOrder returns the highlighted fields first and the others in alphabetical order
+func prettyFieldOrder(values map[string]any) []string {
+	keys := make([]string, 0, len(values))
+	for _, key := range prettyHighlightedFields {
+		if _, ok := values[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	others := make([]string, 0, len(values))
+	for key := range values {
+		if !slices.Contains(prettyHighlightedFields, key) {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
+}
+
+// isPrettyBlock returns whether the value is rendered as a block below the line of the entry
+func isPrettyBlock(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return len(v) > 0
+	case []any:
+		return len(v) > 0
+	default:
+		return false
+	}
+}
+
+func formatPrettyScalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+			return strconv.Quote(v)
+		}
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	default:
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+}
+
+// normalizePrettyNumbers replaces the decoded numbers, so that they are written as numbers instead of strings
+func normalizePrettyNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizePrettyNumbers(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = normalizePrettyNumbers(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
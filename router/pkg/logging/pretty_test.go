@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// encodePrettyEntry returns the entry without the time
+func encodePrettyEntry(t *testing.T) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	logger, err := NewWithEncoding(zapcore.AddSync(&out), EncodingPretty, false, zap.InfoLevel)
+	require.NoError(t, err)
+
+	logger.Named("router").With(zap.String("operation", "Employees"), zap.String(requestIDField, "req-1")).Warn("Subgraph error",
+		zap.String(SubgraphField, "employees"),
+		zap.Int("status", 500),
+		zap.Any("errors", []map[string]any{{"message": "boom", "path": []any{"employees", 1}}}),
+		zap.Strings("empty", nil),
+	)
+	require.NoError(t, logger.Sync())
+
+	parts := strings.SplitN(out.String(), " ", 3)
+	require.Len(t, parts, 3)
+	return parts[2]
+}
+
+func TestPrettyEncoding(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	require.Equal(t, "WARN router Subgraph error reqId=req-1 subgraph=employees empty=[] operation=Employees status=500\n"+
+		"    errors:\n"+
+		"      - message: boom\n"+
+		"        path:\n"+
+		"        - employees\n"+
+		"        - 1\n", encodePrettyEntry(t))
+
+	require.NoError(t, SetPrettyOptions(PrettyThemeHighContrast, PrettyFieldsJSON))
+	t.Cleanup(func() {
+		require.NoError(t, SetPrettyOptions("", ""))
+	})
+
+	require.Equal(t, "WARN router Subgraph error reqId=req-1 subgraph=employees empty=[] operation=Employees status=500\n"+
+		"    errors:\n"+
+		"      [\n"+
+		"        {\n"+
+		"          \"message\": \"boom\",\n"+
+		"          \"path\": [\n"+
+		"            \"employees\",\n"+
+		"            1\n"+
+		"          ]\n"+
+		"        }\n"+
+		"      ]\n", encodePrettyEntry(t))
+
+	// The colors of the theme are used without NO_COLOR
+	t.Setenv("NO_COLOR", "")
+	colored := encodePrettyEntry(t)
+	require.Contains(t, colored, "\x1b[1;93mWARN"+ansiReset)
+	require.Contains(t, colored, "\x1b[1;95mreqId="+ansiReset+"\x1b[1;95mreq-1"+ansiReset)
+
+	require.EqualError(t, SetPrettyOptions("neon", ""), "unknown pretty log theme 'neon'")
+	require.EqualError(t, SetPrettyOptions("", "toml"), "unknown pretty log field format 'toml'")
+}
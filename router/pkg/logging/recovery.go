@@ -0,0 +1,212 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type RecoveryOptions struct {
+	Logger *zap.Logger
+	// CrashDumpDir is the directory a crash dump is written to for every panic. Without it, no crash dumps are
+	// written.
+	CrashDumpDir string
+	// Exit terminates the process after the panic was logged instead of recovering the request
+	Exit bool
+}
+
+// RecoveryHandler is a middleware that recovers the panics of the requests. A panic is logged with the stack
+// trace, the dump of all goroutines and the fields of the request, e.g. the request ID and the name of the
+// operation. The logger is synced afterwards, so that the entry reaches all outputs and sinks even if the process
+// exits.
+type RecoveryHandler struct {
+	logger       *zap.Logger
+	crashDumpDir string
+	exit         func(code int)
+	now          func() time.Time
+}
+
+// CrashDump is the content of a crash dump file
+type CrashDump struct {
+	Time       time.Time      `json:"time"`
+	Error      string         `json:"error"`
+	Method     string         `json:"method"`
+	URL        string         `json:"url"`
+	Fields     map[string]any `json:"fields,omitempty"`
+	Stack      string         `json:"stack"`
+	Goroutines string         `json:"goroutines"`
+}
+
+// recoveryFields are the fields of a request that are added to the entry of a panic
+type recoveryFields struct {
+	mu     sync.Mutex
+	fields []zap.Field
+}
+
+type recoveryFieldsContextKey struct{}
+
+func NewRecoveryHandler(opts *RecoveryOptions) *RecoveryHandler {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	h := &RecoveryHandler{
+		logger:       logger,
+		crashDumpDir: opts.CrashDumpDir,
+		now:          time.Now,
+	}
+	if opts.Exit {
+		h.exit = os.Exit
+	}
+	return h
+}
+
+// AddRecoveryFields adds fields to the entry that is logged if the request of the context panics. The handlers
+// behind the RecoveryHandler can add the fields that are only known to them, e.g. the name of the operation.
+func AddRecoveryFields(ctx context.Context, fields ...zap.Field) {
+	rf, ok := ctx.Value(recoveryFieldsContextKey{}).(*recoveryFields)
+	if !ok {
+		return
+	}
+	rf.mu.Lock()
+	rf.fields = append(rf.fields, fields...)
+	rf.mu.Unlock()
+}
+
+func (h *RecoveryHandler) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rf := &recoveryFields{}
+		r = r.WithContext(context.WithValue(r.Context(), recoveryFieldsContextKey{}, rf))
+
+		defer func() {
+			if err := recover(); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				h.recover(r, rf, err)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *RecoveryHandler) recover(r *http.Request, rf *recoveryFields, recovered any) {
+	rf.mu.Lock()
+	requestFields := append([]zap.Field(nil), rf.fields...)
+	rf.mu.Unlock()
+
+	fields := append([]zap.Field{
+		zap.Any("error", recovered),
+		zap.String("method", r.Method),
+		zap.String("url", r.URL.String()),
+	}, requestFields...)
+
+	// A broken connection is not a condition that warrants a stack trace
+	if isBrokenPipe(recovered) {
+		h.logger.Error("Recovered from a panic of a broken connection", fields...)
+		return
+	}
+
+	dump := &CrashDump{
+		Time:       h.now(),
+		Error:      fmt.Sprint(recovered),
+		Method:     r.Method,
+		URL:        r.URL.String(),
+		Fields:     fieldsMap(requestFields),
+		Stack:      string(debug.Stack()),
+		Goroutines: goroutineDump(),
+	}
+	fields = append(fields,
+		zap.String("stack", dump.Stack),
+		zap.String("goroutines", dump.Goroutines),
+	)
+	if h.crashDumpDir != "" {
+		path, err := h.writeCrashDump(dump)
+		if err != nil {
+			fields = append(fields, zap.NamedError("crash_dump_error", err))
+		} else {
+			fields = append(fields, zap.String("crash_dump", path))
+		}
+	}
+
+	if h.exit != nil {
+		h.logger.Error("Panic while handling the request, exiting", fields...)
+	} else {
+		h.logger.Error("Recovered from a panic while handling the request", fields...)
+	}
+	_ = h.logger.Sync()
+
+	if h.exit != nil {
+		h.exit(2)
+	}
+}
+
+// writeCrashDump writes the crash dump to a new file of the crash dump directory and returns its path
+func (h *RecoveryHandler) writeCrashDump(dump *CrashDump) (string, error) {
+	if err := os.MkdirAll(h.crashDumpDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create the crash dump directory: %w", err)
+	}
+	content, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("crash-%s-%d-*.json", dump.Time.UTC().Format("20060102T150405.000Z"), os.Getpid())
+	f, err := os.CreateTemp(h.crashDumpDir, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the crash dump: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		return "", fmt.Errorf("failed to write the crash dump: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return "", fmt.Errorf("failed to write the crash dump: %w", err)
+	}
+	return f.Name(), nil
+}
+
+func isBrokenPipe(recovered any) bool {
+	ne, ok := recovered.(*net.OpError)
+	if !ok {
+		return false
+	}
+	se, ok := ne.Err.(*os.SyscallError)
+	if !ok {
+		return false
+	}
+	msg := strings.ToLower(se.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// goroutineDump returns the stack traces of all goroutines
+func goroutineDump() string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 64<<20 {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+func fieldsMap(fields []zap.Field) map[string]any {
+	if len(fields) == 0 {
+		return nil
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	return enc.Fields
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecoveryHandler(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	dir := t.TempDir()
+	h := NewRecoveryHandler(&RecoveryOptions{Logger: zap.New(core), CrashDumpDir: dir})
+
+	handler := h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddRecoveryFields(r.Context(), WithRequestID("req-1"), zap.String("operation_name", "Employees"))
+		panic("unexpected error")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	require.Equal(t, "unexpected error", fields["error"])
+	require.Equal(t, "req-1", fields[requestIDField])
+	require.Equal(t, "Employees", fields["operation_name"])
+	require.Contains(t, fields["stack"], "TestRecoveryHandler")
+	require.Contains(t, fields["goroutines"], "goroutine ")
+
+	content, err := os.ReadFile(fields["crash_dump"].(string))
+	require.NoError(t, err)
+	var dump CrashDump
+	require.NoError(t, json.Unmarshal(content, &dump))
+	require.Equal(t, "unexpected error", dump.Error)
+	require.Equal(t, "/graphql", dump.URL)
+	require.Equal(t, map[string]any{requestIDField: "req-1", "operation_name": "Employees"}, dump.Fields)
+}
+
+func TestRecoveryHandlerExit(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	h := NewRecoveryHandler(&RecoveryOptions{Logger: zap.New(core), Exit: true})
+	var code int
+	h.exit = func(c int) {
+		// The entry is logged before the process exits
+		require.Equal(t, 1, logs.Len())
+		code = c
+	}
+
+	handler := h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("unexpected error")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, 2, code)
+}
@@ -0,0 +1,330 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// RedactionActionMask replaces the values with the replacement
+	RedactionActionMask = "mask"
+	// RedactionActionHash replaces the values with their SHA-256 hash, so that equal values can still be correlated
+	RedactionActionHash = "hash"
+
+	DefaultRedactionReplacement = "[REDACTED]"
+)
+
+// defaultRedactionFields are the fields that carry credentials in the logs of the router, e.g. headers and
+// variables. They are masked by the default rules.
+var defaultRedactionFields = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"api-key",
+	"api_key",
+	"apikey",
+	"password",
+	"passwd",
+	"secret",
+	"client_secret",
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"private_key",
+}
+
+// defaultRedactionPattern matches bearer tokens, e.g. in errors that quote a header
+const defaultRedactionPattern = `(?i)\bbearer\s+[a-z0-9._~+/-]{8,}=*`
+
+// DefaultRedactionRules returns the built-in rules that mask credentials like the authorization header, cookies, API
+// keys and passwords. The excluded fields are matched case-insensitive and left out.
+func DefaultRedactionRules(excludeFields []string) []RedactionRule {
+	rules := make([]RedactionRule, 0, len(defaultRedactionFields)+1)
+	for _, field := range defaultRedactionFields {
+		if slices.ContainsFunc(excludeFields, func(excluded string) bool { return strings.EqualFold(excluded, field) }) {
+			continue
+		}
+		rules = append(rules, RedactionRule{Field: field})
+	}
+	return append(rules, RedactionRule{Pattern: defaultRedactionPattern})
+}
+
+// RedactionRule selects the values that are redacted. A rule with a field redacts the whole value of the fields
+// with the name, or with a path only the values at the path inside the JSON of the fields. A rule with a pattern
+// redacts the matches in the message and in the string values of all fields.
+type RedactionRule struct {
+	// Field is the name of the field. It is matched case-insensitive.
+	Field string
+	// Path is the path of the values inside the field, e.g. input.password. Segments are keys of objects or indexes
+	// of arrays, and * matches all of them. An optional $. prefix is ignored.
+	Path string
+	// Pattern is a regular expression
+	Pattern string
+	// Action is mask or hash. If empty, the values are masked.
+	Action string
+}
+
+type RedactionOptions struct {
+	// Replacement replaces the masked values. If empty, DefaultRedactionReplacement is used.
+	Replacement string
+	Rules       []RedactionRule
+}
+
+type redactionFieldRule struct {
+	path []string
+	hash bool
+}
+
+type redactionPatternRule struct {
+	pattern *regexp.Regexp
+	hash    bool
+}
+
+// Redactor redacts sensitive values of log entries before they are written to any output
+type Redactor struct {
+	replacement string
+	fields      map[string][]redactionFieldRule
+	patterns    []redactionPatternRule
+}
+
+// NewRedactor creates a redactor of the rules. Wrap the cores of the loggers with Core or WithRedaction.
+func NewRedactor(opts *RedactionOptions) (*Redactor, error) {
+	r := &Redactor{
+		replacement: opts.Replacement,
+		fields:      make(map[string][]redactionFieldRule),
+	}
+	if r.replacement == "" {
+		r.replacement = DefaultRedactionReplacement
+	}
+
+	for i, rule := range opts.Rules {
+		var hash bool
+		switch rule.Action {
+		case "", RedactionActionMask:
+		case RedactionActionHash:
+			hash = true
+		default:
+			return nil, fmt.Errorf("invalid redaction rule %d: unknown action '%s'", i, rule.Action)
+		}
+
+		switch {
+		case rule.Field != "" && rule.Pattern != "":
+			return nil, fmt.Errorf("invalid redaction rule %d: either the field or the pattern must be set", i)
+		case rule.Field != "":
+			key := strings.ToLower(rule.Field)
+			r.fields[key] = append(r.fields[key], redactionFieldRule{path: parseRedactionPath(rule.Path), hash: hash})
+		case rule.Pattern != "":
+			if rule.Path != "" {
+				return nil, fmt.Errorf("invalid redaction rule %d: the path requires a field", i)
+			}
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redaction rule %d: %w", i, err)
+			}
+			r.patterns = append(r.patterns, redactionPatternRule{pattern: pattern, hash: hash})
+		default:
+			return nil, fmt.Errorf("invalid redaction rule %d: the field or the pattern is required", i)
+		}
+	}
+
+	return r, nil
+}
+
+func parseRedactionPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// WithRedaction returns an option that redacts the entries of the logger
+func WithRedaction(r *Redactor) zap.Option {
+	return zap.WrapCore(r.Core)
+}
+
+// Core wraps the core, so that all entries are redacted before they are written to it
+func (r *Redactor) Core(core zapcore.Core) zapcore.Core {
+	return &redactionCore{Core: core, redactor: r}
+}
+
+func (r *Redactor) value(value string, hash bool) string {
+	if hash {
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return r.replacement
+}
+
+func (r *Redactor) redactString(s string) string {
+	for _, rule := range r.patterns {
+		s = rule.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			return r.value(match, rule.hash)
+		})
+	}
+	return s
+}
+
+func (r *Redactor) redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = r.redactField(field)
+	}
+	return redacted
+}
+
+func (r *Redactor) redactField(field zapcore.Field) zapcore.Field {
+	if rules, ok := r.fields[strings.ToLower(field.Key)]; ok {
+		for _, rule := range rules {
+			if len(rule.path) == 0 {
+				return zap.String(field.Key, r.value(fieldString(field), rule.hash))
+			}
+		}
+		field = r.redactPaths(field, rules)
+	}
+
+	if len(r.patterns) == 0 {
+		return field
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = r.redactString(field.String)
+	case zapcore.ByteStringType:
+		field = zap.ByteString(field.Key, []byte(r.redactString(string(field.Interface.([]byte)))))
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && err != nil {
+			if message := r.redactString(err.Error()); message != err.Error() {
+				field = zap.NamedError(field.Key, errors.New(message))
+			}
+		}
+	}
+
+	return field
+}
+
+// redactPaths redacts the values at the paths of the rules inside the JSON of the field. String fields that don't
+// contain JSON are left unchanged.
+func (r *Redactor) redactPaths(field zapcore.Field, rules []redactionFieldRule) zapcore.Field {
+	var data []byte
+	switch field.Type {
+	case zapcore.StringType:
+		data = []byte(field.String)
+	case zapcore.ByteStringType:
+		data = field.Interface.([]byte)
+	default:
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		encoded, ok := enc.Fields[field.Key]
+		if !ok {
+			return field
+		}
+		var err error
+		if data, err = json.Marshal(encoded); err != nil {
+			return field
+		}
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return field
+	}
+	for _, rule := range rules {
+		value = r.redactPath(value, rule.path, rule.hash)
+	}
+
+	switch field.Type {
+	case zapcore.StringType, zapcore.ByteStringType:
+		redacted, err := json.Marshal(value)
+		if err != nil {
+			return field
+		}
+		if field.Type == zapcore.StringType {
+			return zap.String(field.Key, string(redacted))
+		}
+		return zap.ByteString(field.Key, redacted)
+	default:
+		return zap.Any(field.Key, value)
+	}
+}
+
+func (r *Redactor) redactPath(value any, path []string, hash bool) any {
+	if len(path) == 0 {
+		if s, ok := value.(string); ok {
+			return r.value(s, hash)
+		}
+		data, _ := json.Marshal(value)
+		return r.value(string(data), hash)
+	}
+
+	segment, rest := path[0], path[1:]
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if segment == "*" || segment == key {
+				v[key] = r.redactPath(child, rest, hash)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				v[i] = r.redactPath(child, rest, hash)
+			}
+		}
+	}
+	return value
+}
+
+// fieldString returns the value of the field as it would be encoded, which is hashed by the hash action
+func fieldString(field zapcore.Field) string {
+	switch field.Type {
+	case zapcore.StringType:
+		return field.String
+	case zapcore.ByteStringType:
+		return string(field.Interface.([]byte))
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	if value, ok := enc.Fields[field.Key].(string); ok {
+		return value
+	}
+	data, err := json.Marshal(enc.Fields[field.Key])
+	if err != nil {
+		return fmt.Sprint(enc.Fields[field.Key])
+	}
+	return string(data)
+}
+
+type redactionCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+func (c *redactionCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactionCore{Core: c.Core.With(c.redactor.redactFields(fields)), redactor: c.redactor}
+}
+
+func (c *redactionCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactionCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.redactor.redactString(ent.Message)
+	return c.Core.Write(ent, c.redactor.redactFields(fields))
+}
@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type testHeaders http.Header
+
+func (h testHeaders) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for name, values := range h {
+		enc.AddString(name, values[0])
+	}
+	return nil
+}
+
+func TestRedactor(t *testing.T) {
+	redactor, err := NewRedactor(&RedactionOptions{
+		Rules: []RedactionRule{
+			{Field: "authorization"},
+			{Field: "cookie", Action: RedactionActionHash},
+			{Field: "operation_variables", Path: "$.input.password"},
+			{Field: "operation_variables", Path: "users.*.email", Action: RedactionActionHash},
+			{Field: "request_headers", Path: "Authorization"},
+			{Pattern: `\b\d{4}-\d{4}-\d{4}-\d{4}\b`},
+		},
+	})
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core).WithOptions(WithRedaction(redactor)).With(zap.String("Authorization", "Bearer token"))
+
+	logger.Info("payment with 1234-5678-9012-3456 failed",
+		zap.String("cookie", "session=1"),
+		zap.ByteString("operation_variables", []byte(`{"input":{"name":"a","password":"secret"},"users":[{"email":"a@b.c"}]}`)),
+		zap.Object("request_headers", testHeaders{"Authorization": {"Bearer token"}, "Accept": {"*/*"}}),
+		zap.Error(errors.New("invalid card 1234-5678-9012-3456")),
+		zap.String("query", "not json"),
+	)
+
+	sum := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(h[:])
+	}
+
+	entry := logs.All()[0]
+	require.Equal(t, "payment with [REDACTED] failed", entry.Message)
+
+	fields := entry.ContextMap()
+	require.Equal(t, "[REDACTED]", fields["Authorization"])
+	require.Equal(t, sum("session=1"), fields["cookie"])
+	require.JSONEq(t, `{"input":{"name":"a","password":"[REDACTED]"},"users":[{"email":"`+sum("a@b.c")+`"}]}`, fields["operation_variables"].(string))
+	require.Equal(t, map[string]any{"Authorization": "[REDACTED]", "Accept": "*/*"}, fields["request_headers"])
+	require.Equal(t, "invalid card [REDACTED]", fields["error"])
+	require.Equal(t, "not json", fields["query"])
+}
+
+func TestNewRedactor(t *testing.T) {
+	_, err := NewRedactor(&RedactionOptions{Rules: []RedactionRule{{Field: "a", Action: "encrypt"}}})
+	require.EqualError(t, err, "invalid redaction rule 0: unknown action 'encrypt'")
+
+	_, err = NewRedactor(&RedactionOptions{Rules: []RedactionRule{{}}})
+	require.EqualError(t, err, "invalid redaction rule 0: the field or the pattern is required")
+
+	_, err = NewRedactor(&RedactionOptions{Rules: []RedactionRule{{Field: "a", Pattern: "b"}}})
+	require.EqualError(t, err, "invalid redaction rule 0: either the field or the pattern must be set")
+
+	_, err = NewRedactor(&RedactionOptions{Rules: []RedactionRule{{Pattern: "b", Path: "c"}}})
+	require.EqualError(t, err, "invalid redaction rule 0: the path requires a field")
+
+	_, err = NewRedactor(&RedactionOptions{Rules: []RedactionRule{{Pattern: "("}}})
+	require.ErrorContains(t, err, "invalid redaction rule 0: error parsing regexp")
+}
+
+func TestDefaultRedactionRules(t *testing.T) {
+	redactor, err := NewRedactor(&RedactionOptions{Rules: DefaultRedactionRules([]string{"Cookie"})})
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core).WithOptions(WithRedaction(redactor))
+
+	logger.Info("request with Bearer eyJhbGciOiJIUzI1NiJ9.e30 failed",
+		zap.String("Authorization", "Basic dXNlcjpwYXNz"),
+		zap.String("Set-Cookie", "session=1"),
+		zap.String("x-api-key", "key"),
+		zap.String("client_secret", "secret"),
+		zap.String("cookie", "theme=dark"),
+		zap.Error(errors.New("invalid header bearer abcdefghijkl")),
+		zap.String("subgraph", "employees"),
+	)
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	require.Equal(t, "request with [REDACTED] failed", entry.Message)
+	require.Equal(t, map[string]any{
+		"Authorization": "[REDACTED]",
+		"Set-Cookie":    "[REDACTED]",
+		"x-api-key":     "[REDACTED]",
+		"client_secret": "[REDACTED]",
+		// The excluded fields are kept
+		"cookie":   "theme=dark",
+		"error":    "invalid header [REDACTED]",
+		"subgraph": "employees",
+	}, entry.ContextMap())
+}
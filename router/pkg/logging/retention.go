@@ -0,0 +1,222 @@
+package logging
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const compressedLogFileSuffix = ".gz"
+
+type RetentionJanitorOptions struct {
+	Logger *zap.Logger
+	// Patterns are the glob patterns of the log files, e.g. /var/log/router/*.log. Compressed files
+	// of a pattern, i.e. <pattern>.gz, are included.
+	Patterns []string
+	// MaxAge is the retention period. Files that were not modified within the period are deleted.
+	MaxAge time.Duration
+	// CompressAfter compresses files that were not modified within the period. Zero disables the compression.
+	CompressAfter time.Duration
+	// Interval is the time between two runs
+	Interval time.Duration
+}
+
+// RetentionJanitor enforces the retention period of log files. It runs in the background, deletes files that are older
+// than the retention period and optionally compresses files before. Every action is logged to the audit logger,
+// so the deletion of log files can be proven.
+type RetentionJanitor struct {
+	logger        *zap.Logger
+	auditLogger   *zap.Logger
+	patterns      []string
+	maxAge        time.Duration
+	compressAfter time.Duration
+	interval      time.Duration
+
+	// now returns the current time. It can be replaced in tests.
+	now func() time.Time
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRetentionJanitor(opts *RetentionJanitorOptions) (*RetentionJanitor, error) {
+	if len(opts.Patterns) == 0 {
+		return nil, errors.New("log retention requires at least one path")
+	}
+	if opts.MaxAge <= 0 {
+		return nil, errors.New("log retention requires a max age")
+	}
+	if opts.CompressAfter >= opts.MaxAge {
+		return nil, errors.New("log retention compress_after must be less than max_age")
+	}
+	for _, pattern := range opts.Patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+
+	return &RetentionJanitor{
+		logger:        opts.Logger,
+		auditLogger:   opts.Logger.Named("audit"),
+		patterns:      opts.Patterns,
+		maxAge:        opts.MaxAge,
+		compressAfter: opts.CompressAfter,
+		interval:      opts.Interval,
+		now:           time.Now,
+	}, nil
+}
+
+// Start runs the janitor immediately and then after every interval until Shutdown is called
+func (j *RetentionJanitor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	j.mu.Lock()
+	j.cancel = cancel
+	j.done = make(chan struct{})
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			j.Run()
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (j *RetentionJanitor) Shutdown() {
+	j.mu.Lock()
+	cancel, done := j.cancel, j.done
+	j.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Run deletes and compresses the files once
+func (j *RetentionJanitor) Run() {
+	now := j.now()
+
+	for _, path := range j.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				j.logger.Warn("Failed to stat log file", zap.String("path", path), zap.Error(err))
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		age := now.Sub(info.ModTime())
+
+		if age >= j.maxAge {
+			if err := os.Remove(path); err != nil {
+				j.logger.Error("Failed to delete log file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			j.auditLogger.Info("Deleted log file after the retention period",
+				zap.String("action", "delete"),
+				zap.String("path", path),
+				zap.Duration("age", age),
+				zap.Duration("max_age", j.maxAge),
+			)
+			continue
+		}
+
+		if j.compressAfter > 0 && age >= j.compressAfter && !strings.HasSuffix(path, compressedLogFileSuffix) {
+			compressed, err := compressFile(path, info)
+			if err != nil {
+				j.logger.Error("Failed to compress log file", zap.String("path", path), zap.Error(err))
+				continue
+			}
+			j.auditLogger.Info("Compressed log file",
+				zap.String("action", "compress"),
+				zap.String("path", path),
+				zap.String("compressed_path", compressed),
+				zap.Duration("age", age),
+			)
+		}
+	}
+}
+
+// files returns the matches of all patterns, including the compressed files, without duplicates
+func (j *RetentionJanitor) files() []string {
+	seen := make(map[string]struct{})
+	var files []string
+
+	for _, pattern := range j.patterns {
+		for _, p := range []string{pattern, pattern + compressedLogFileSuffix} {
+			// The patterns are validated when the janitor is created
+			matches, _ := filepath.Glob(p)
+			for _, match := range matches {
+				if _, ok := seen[match]; ok {
+					continue
+				}
+				seen[match] = struct{}{}
+				files = append(files, match)
+			}
+		}
+	}
+
+	return files
+}
+
+// compressFile compresses the file to <path>.gz and removes the original. The modification time is kept,
+// so the retention period is still counted from the last write.
+func compressFile(path string, info os.FileInfo) (string, error) {
+	target := path + compressedLogFileSuffix
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = info.ModTime()
+
+	_, err = io.Copy(zw, src)
+	err = errors.Join(err, zw.Close(), dst.Close())
+	if err != nil {
+		_ = os.Remove(target)
+		return "", err
+	}
+
+	if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+		return "", err
+	}
+
+	return target, os.Remove(path)
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRetentionJanitor(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	writeFile := func(name string, age time.Duration) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o600))
+		require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		return path
+	}
+
+	active := writeFile("router.log", time.Minute)
+	old := writeFile("router-2024-01-01.log", 48*time.Hour)
+	expired := writeFile("router-2023-01-01.log", 10*24*time.Hour)
+	expiredArchive := writeFile("router-2022-01-01.log.gz", 10*24*time.Hour)
+	unrelated := writeFile("other.txt", 10*24*time.Hour)
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	j, err := NewRetentionJanitor(&RetentionJanitorOptions{
+		Logger:        zap.New(core),
+		Patterns:      []string{filepath.Join(dir, "*.log")},
+		MaxAge:        7 * 24 * time.Hour,
+		CompressAfter: 24 * time.Hour,
+	})
+	require.NoError(t, err)
+	j.now = func() time.Time { return now }
+
+	j.Run()
+
+	require.FileExists(t, active)
+	require.FileExists(t, unrelated)
+	require.NoFileExists(t, expired)
+	require.NoFileExists(t, expiredArchive)
+	require.NoFileExists(t, old)
+
+	f, err := os.Open(old + ".gz")
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, "router-2024-01-01.log", string(content))
+
+	info, err := os.Stat(old + ".gz")
+	require.NoError(t, err)
+	require.WithinDuration(t, now.Add(-48*time.Hour), info.ModTime(), time.Second)
+
+	audit := logs.Filter(func(e observer.LoggedEntry) bool { return e.LoggerName == "audit" }).All()
+	require.Len(t, audit, 3)
+	actions := map[string]int{}
+	for _, e := range audit {
+		actions[e.ContextMap()["action"].(string)]++
+	}
+	require.Equal(t, map[string]int{"delete": 2, "compress": 1}, actions)
+
+	// The compressed file is deleted once the retention period is over
+	j.now = func() time.Time { return now.Add(7 * 24 * time.Hour) }
+	j.Run()
+	require.NoFileExists(t, old+".gz")
+}
+
+func TestRetentionJanitorOptions(t *testing.T) {
+	_, err := NewRetentionJanitor(&RetentionJanitorOptions{Logger: zap.NewNop(), MaxAge: time.Hour})
+	require.Error(t, err)
+
+	_, err = NewRetentionJanitor(&RetentionJanitorOptions{
+		Logger:        zap.NewNop(),
+		Patterns:      []string{"/var/log/*.log"},
+		MaxAge:        time.Hour,
+		CompressAfter: time.Hour,
+	})
+	require.Error(t, err)
+
+	_, err = NewRetentionJanitor(&RetentionJanitorOptions{
+		Logger:   zap.NewNop(),
+		Patterns: []string{"/var/log/[.log"},
+		MaxAge:   time.Hour,
+	})
+	require.Error(t, err)
+}
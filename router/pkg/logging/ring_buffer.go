@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RingBuffer keeps the most recent encoded log entries in memory. It is safe for concurrent use.
+type RingBuffer struct {
+	mu      sync.Mutex
+	entries [][]byte
+	next    int
+	full    bool
+}
+
+// NewRingBuffer creates a buffer that keeps the last size log entries
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &RingBuffer{
+		entries: make([][]byte, size),
+	}
+}
+
+// Write stores a single encoded log entry. The zap core calls Write once per entry.
+func (b *RingBuffer) Write(p []byte) (int, error) {
+	entry := make([]byte, len(p))
+	copy(entry, p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	return len(p), nil
+}
+
+func (b *RingBuffer) Sync() error {
+	return nil
+}
+
+// WriteTo writes all buffered entries, from oldest to newest, to w
+func (b *RingBuffer) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	entries := make([][]byte, 0, len(b.entries))
+	if b.full {
+		entries = append(entries, b.entries[b.next:]...)
+	}
+	entries = append(entries, b.entries[:b.next]...)
+	b.mu.Unlock()
+
+	var written int64
+	for _, entry := range entries {
+		n, err := w.Write(entry)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// WithRingBuffer returns an option that additionally writes all log entries of the given level as JSON to the buffer
+func WithRingBuffer(buffer *RingBuffer, level zapcore.LevelEnabler) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, zapcore.NewCore(ZapJsonEncoder(), buffer, level))
+	})
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingBuffer(t *testing.T) {
+	b := NewRingBuffer(3)
+
+	for _, entry := range []string{"1\n", "2\n"} {
+		_, err := b.Write([]byte(entry))
+		require.NoError(t, err)
+	}
+
+	var out bytes.Buffer
+	_, err := b.WriteTo(&out)
+	require.NoError(t, err)
+	require.Equal(t, "1\n2\n", out.String())
+
+	for _, entry := range []string{"3\n", "4\n", "5\n"} {
+		_, err := b.Write([]byte(entry))
+		require.NoError(t, err)
+	}
+
+	out.Reset()
+	_, err = b.WriteTo(&out)
+	require.NoError(t, err)
+	require.Equal(t, "3\n4\n5\n", out.String())
+}
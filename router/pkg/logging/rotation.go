@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// FileRotator rotates the log files on demand, in addition to the rotation by size. External setups like logrotate
+// move the files and then trigger the rotation, so that the router continues with a new file. It is safe for
+// concurrent use.
+type FileRotator struct {
+	mu      sync.Mutex
+	files   []*lumberjack.Logger
+	metrics Metrics
+}
+
+// NewFileRotator creates the rotator. The metrics count the rotated files and are optional. The rotations by size
+// happen within the files and aren't counted.
+func NewFileRotator(metrics Metrics) *FileRotator {
+	return &FileRotator{metrics: metrics}
+}
+
+func (r *FileRotator) add(file *lumberjack.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files = append(r.files, file)
+}
+
+// Rotate closes the current files, renames them with a timestamp and opens new ones. The rotated files are
+// compressed and cleaned up according to the settings of their file.
+func (r *FileRotator) Rotate() error {
+	r.mu.Lock()
+	files := append([]*lumberjack.Logger(nil), r.files...)
+	r.mu.Unlock()
+
+	var err error
+	for _, file := range files {
+		if rotateErr := file.Rotate(); rotateErr != nil {
+			err = errors.Join(err, errors.New("failed to rotate the log file '"+file.Filename+"': "+rotateErr.Error()))
+			continue
+		}
+		if r.metrics != nil {
+			r.metrics.Rotation()
+		}
+	}
+	return err
+}
+
+// RotateOnSignal rotates the files every time the process receives SIGUSR1. Windows has no such signal, the files
+// are only rotated by size there. The listener stops when the context is done.
+func RotateOnSignal(ctx context.Context, logger *zap.Logger, rotator *FileRotator) {
+	if len(rotateSignals) == 0 {
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, rotateSignals...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				if err := rotator.Rotate(); err != nil {
+					logger.Error("Failed to rotate the log files", zap.String("signal", sig.String()), zap.Error(err))
+					continue
+				}
+				logger.Info("Log files rotated by signal", zap.String("signal", sig.String()))
+			}
+		}
+	}()
+}
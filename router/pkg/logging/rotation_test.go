@@ -0,0 +1,65 @@
+//go:build !windows
+
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRotateOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	rotator := NewFileRotator(nil)
+
+	logger, err := NewWithFileOutputs(false, false, zapcore.InfoLevel, &FileOutputs{
+		Default: &FileOutput{Path: filepath.Join(dir, "router.log")},
+		Rotator: rotator,
+	})
+	require.NoError(t, err)
+	accessLogger, err := NewAccessLogger(&AccessLoggerOptions{
+		Output:  AccessLogOutputFile,
+		File:    FileOutput{Path: filepath.Join(dir, "access.log")},
+		Rotator: rotator,
+	})
+	require.NoError(t, err)
+
+	logger.Info("before")
+	accessLogger.Info("before")
+
+	rotations, logs := observer.New(zapcore.InfoLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	RotateOnSignal(ctx, zap.New(rotations), rotator)
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGUSR1))
+
+	require.Eventually(t, func() bool { return logs.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "Log files rotated by signal", logs.All()[0].Message)
+
+	logger.Info("after")
+	accessLogger.Info("after")
+
+	// Every file was renamed with a timestamp and a new one was opened
+	for _, name := range []string{"router", "access"} {
+		data, err := os.ReadFile(filepath.Join(dir, name+".log"))
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"msg":"after"`)
+		require.NotContains(t, string(data), `"msg":"before"`)
+
+		backups, err := filepath.Glob(filepath.Join(dir, name+"-*.log"))
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+	}
+}
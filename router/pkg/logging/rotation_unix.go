@@ -0,0 +1,10 @@
+//go:build !windows
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+var rotateSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package logging
+
+import "os"
+
+var rotateSignals []os.Signal
@@ -0,0 +1,233 @@
+package logging
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultSamplingTick         = time.Second
+	defaultDeduplicationWindow  = 10 * time.Second
+	defaultDeduplicationMaxKeys = 10000
+	deduplicationSummaryMessage = "Suppressed duplicate log entries"
+)
+
+type SamplingOptions struct {
+	// Tick is the interval in which the entries with the same level and message are counted. If zero, 1 second
+	// is used.
+	Tick time.Duration
+	// Initial is the number of entries with the same level and message that are written per tick
+	Initial int
+	// Thereafter writes every Thereafter-th entry after the initial ones. Zero drops all of them.
+	Thereafter int
+	// Levels are the sampled levels, e.g. LevelSet(zapcore.WarnLevel, zapcore.ErrorLevel). If nil, all levels
+	// are sampled.
+	Levels zapcore.LevelEnabler
+}
+
+// WithSampling returns an option that samples the entries of the logger with the sampler of zap, so that a burst of
+// the same entry doesn't drown out everything else
+func WithSampling(opts *SamplingOptions) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		tick := opts.Tick
+		if tick <= 0 {
+			tick = defaultSamplingTick
+		}
+		sampled := zapcore.NewSamplerWithOptions(core, tick, opts.Initial, opts.Thereafter)
+		if opts.Levels == nil {
+			return sampled
+		}
+		return &levelSplitCore{Core: core, split: sampled, levels: opts.Levels}
+	})
+}
+
+// levelSplitCore writes the entries of the levels to the split core and all other entries to the core
+type levelSplitCore struct {
+	zapcore.Core
+	split  zapcore.Core
+	levels zapcore.LevelEnabler
+}
+
+func (c *levelSplitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelSplitCore{Core: c.Core.With(fields), split: c.split.With(fields), levels: c.levels}
+}
+
+func (c *levelSplitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.levels.Enabled(ent.Level) {
+		return c.split.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
+
+type DeduplicationOptions struct {
+	// Window is the period in which the entries with the same level, logger name and message are written once.
+	// If zero, 10 seconds are used.
+	Window time.Duration
+	// MaxKeys limits the number of distinct entries that are tracked. Entries beyond it aren't deduplicated. If
+	// zero, 10000 entries are tracked.
+	MaxKeys int
+	// Levels are the deduplicated levels. If nil, all levels are deduplicated.
+	Levels zapcore.LevelEnabler
+}
+
+// WithDeduplication returns an option that writes identical entries once per window. The first entry after the
+// window, or the sync of the logger, writes a summary with the number of suppressed duplicates. The fields of the
+// entries are not compared, only their level, logger name and message.
+func WithDeduplication(opts *DeduplicationOptions) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return newDeduplicationCore(core, opts, time.Now)
+	})
+}
+
+func newDeduplicationCore(core zapcore.Core, opts *DeduplicationOptions, now func() time.Time) zapcore.Core {
+	state := &deduplicationState{
+		window:  opts.Window,
+		maxKeys: opts.MaxKeys,
+		now:     now,
+		entries: map[deduplicationKey]*deduplicatedEntry{},
+	}
+	if state.window <= 0 {
+		state.window = defaultDeduplicationWindow
+	}
+	if state.maxKeys <= 0 {
+		state.maxKeys = defaultDeduplicationMaxKeys
+	}
+	return &deduplicationCore{Core: core, levels: opts.Levels, state: state}
+}
+
+type deduplicationKey struct {
+	level   zapcore.Level
+	logger  string
+	message string
+}
+
+type deduplicatedEntry struct {
+	entry      zapcore.Entry
+	until      time.Time
+	suppressed int
+	// core is the core of the first entry, so that the summary has its context fields
+	core zapcore.Core
+}
+
+type deduplicationSummary struct {
+	entry      zapcore.Entry
+	suppressed int
+	core       zapcore.Core
+}
+
+// deduplicationState is shared by a core and all cores that are derived from it with fields
+type deduplicationState struct {
+	window  time.Duration
+	maxKeys int
+	now     func() time.Time
+
+	mu        sync.Mutex
+	entries   map[deduplicationKey]*deduplicatedEntry
+	nextSweep time.Time
+}
+
+// admit reports whether the entry is written and returns the summaries of the windows that ended
+func (s *deduplicationState) admit(ent zapcore.Entry, core zapcore.Core) ([]deduplicationSummary, bool) {
+	now := s.now()
+	key := deduplicationKey{level: ent.Level, logger: ent.LoggerName, message: ent.Message}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []deduplicationSummary
+	// The windows of entries that don't recur are ended by a periodic sweep
+	if !now.Before(s.nextSweep) {
+		summaries = s.sweep(now, false)
+		s.nextSweep = now.Add(s.window)
+	}
+
+	if e, ok := s.entries[key]; ok {
+		if now.Before(e.until) {
+			e.suppressed++
+			return summaries, false
+		}
+		if e.suppressed > 0 {
+			summaries = append(summaries, deduplicationSummary{entry: e.entry, suppressed: e.suppressed, core: e.core})
+		}
+		delete(s.entries, key)
+	}
+
+	if len(s.entries) < s.maxKeys {
+		s.entries[key] = &deduplicatedEntry{entry: ent, until: now.Add(s.window), core: core}
+	}
+	return summaries, true
+}
+
+// sweep returns the summaries of the ended windows and removes them. With all, the summaries of all windows are
+// returned and the windows are kept.
+func (s *deduplicationState) sweep(now time.Time, all bool) []deduplicationSummary {
+	var summaries []deduplicationSummary
+	for key, e := range s.entries {
+		ended := !now.Before(e.until)
+		if !ended && !all {
+			continue
+		}
+		if e.suppressed > 0 {
+			summaries = append(summaries, deduplicationSummary{entry: e.entry, suppressed: e.suppressed, core: e.core})
+			e.suppressed = 0
+		}
+		if ended {
+			delete(s.entries, key)
+		}
+	}
+	return summaries
+}
+
+func (s *deduplicationState) write(summaries []deduplicationSummary) {
+	now := s.now()
+	for _, summary := range summaries {
+		ent := zapcore.Entry{
+			Level:      summary.entry.Level,
+			Time:       now,
+			LoggerName: summary.entry.LoggerName,
+			Message:    deduplicationSummaryMessage,
+		}
+		if ce := summary.core.Check(ent, nil); ce != nil {
+			ce.Write(
+				zap.String("duplicate_msg", summary.entry.Message),
+				zap.Int("suppressed", summary.suppressed),
+				zap.Duration("window", s.window),
+			)
+		}
+	}
+}
+
+type deduplicationCore struct {
+	zapcore.Core
+	levels zapcore.LevelEnabler
+	state  *deduplicationState
+}
+
+func (c *deduplicationCore) With(fields []zapcore.Field) zapcore.Core {
+	return &deduplicationCore{Core: c.Core.With(fields), levels: c.levels, state: c.state}
+}
+
+func (c *deduplicationCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if (c.levels != nil && !c.levels.Enabled(ent.Level)) || !c.Core.Enabled(ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+
+	summaries, ok := c.state.admit(ent, c.Core)
+	c.state.write(summaries)
+	if !ok {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c *deduplicationCore) Sync() error {
+	c.state.mu.Lock()
+	summaries := c.state.sweep(c.state.now(), true)
+	c.state.mu.Unlock()
+
+	c.state.write(summaries)
+	return c.Core.Sync()
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithSampling(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core, WithSampling(&SamplingOptions{
+		Tick:       time.Minute,
+		Initial:    2,
+		Thereafter: 5,
+		Levels:     LevelSet(zapcore.ErrorLevel),
+	})).With(zap.String("component", "router"))
+
+	for i := 0; i < 12; i++ {
+		logger.Error("subgraph failed")
+		logger.Info("request")
+	}
+
+	// The first 2 and then every 5th entry
+	require.Equal(t, 4, logs.FilterMessage("subgraph failed").Len())
+	require.Equal(t, 12, logs.FilterMessage("request").Len())
+	require.Equal(t, "router", logs.All()[0].ContextMap()["component"])
+}
+
+func TestWithDeduplication(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	now := time.Unix(0, 0)
+	core := newDeduplicationCore(obs, &DeduplicationOptions{Window: 10 * time.Second, Levels: LevelSet(zapcore.ErrorLevel)}, func() time.Time {
+		return now
+	})
+	logger := zap.New(core).With(zap.String("subgraph", "employees"))
+
+	for i := 0; i < 5; i++ {
+		logger.Error("subgraph failed", zap.Int("attempt", i))
+		logger.Named("access").Error("subgraph failed")
+		logger.Info("request")
+	}
+
+	entries := logs.FilterMessage("subgraph failed").All()
+	require.Len(t, entries, 2)
+	require.Equal(t, "", entries[0].LoggerName)
+	require.Equal(t, "access", entries[1].LoggerName)
+	require.Equal(t, 5, logs.FilterMessage("request").Len())
+
+	// The next entry after the window writes the summary first
+	now = now.Add(10 * time.Second)
+	logger.Error("subgraph failed")
+
+	summaries := logs.FilterMessage(deduplicationSummaryMessage).All()
+	require.Len(t, summaries, 2)
+	for _, summary := range summaries {
+		fields := summary.ContextMap()
+		require.Equal(t, zapcore.ErrorLevel, summary.Level)
+		require.Equal(t, "subgraph failed", fields["duplicate_msg"])
+		require.Equal(t, int64(4), fields["suppressed"])
+		require.Equal(t, "employees", fields["subgraph"])
+	}
+	require.Equal(t, 3, logs.FilterMessage("subgraph failed").Len())
+
+	// The sync writes the summaries of the open windows
+	logger.Error("subgraph failed")
+	require.NoError(t, logger.Sync())
+	summaries = logs.FilterMessage(deduplicationSummaryMessage).All()
+	require.Len(t, summaries, 3)
+	require.Equal(t, int64(1), summaries[2].ContextMap()["suppressed"])
+}
@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bytes"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Sink is an output that frames every entry itself and maps its level to a severity, like syslog and journald.
+// It must be safe for concurrent use.
+type Sink interface {
+	// WriteEntry writes the encoded entry without its trailing newline
+	WriteEntry(entry zapcore.Entry, encoded []byte) error
+	Sync() error
+	Close() error
+}
+
+// SinkOutput is a sink with the encoding and the level of its entries
+type SinkOutput struct {
+	sink    Sink
+	encoder zapcore.Encoder
+	level   zapcore.LevelEnabler
+	name    string
+	metrics Metrics
+}
+
+// NewSinkOutput creates the output of the sink. The encoding is a built-in or registered encoding, if empty the
+// entries are encoded as JSON. Pass a zap.AtomicLevel as level to follow the level of the router.
+func NewSinkOutput(sink Sink, encoding string, level zapcore.LevelEnabler) (*SinkOutput, error) {
+	if encoding == "" {
+		encoding = EncodingJSON
+	}
+	encoder, err := NewEncoder(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &SinkOutput{sink: sink, encoder: encoder, level: level}, nil
+}
+
+// WithMetrics counts the failed writes to the sink with the name. Call it before the output is passed to WithSinks.
+func (o *SinkOutput) WithMetrics(name string, metrics Metrics) *SinkOutput {
+	o.name = name
+	o.metrics = metrics
+	return o
+}
+
+// Name returns the name of the sink that was set with WithMetrics
+func (o *SinkOutput) Name() string {
+	return o.name
+}
+
+// Sync writes the entries that are buffered by the sink
+func (o *SinkOutput) Sync() error {
+	return o.sink.Sync()
+}
+
+// Close closes the sink
+func (o *SinkOutput) Close() error {
+	return o.sink.Close()
+}
+
+// WithSinks returns an option that writes the entries of the logger to the sinks in addition to its outputs
+func WithSinks(outputs ...*SinkOutput) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if len(outputs) == 0 {
+			return core
+		}
+		cores := make([]zapcore.Core, 0, len(outputs)+1)
+		cores = append(cores, core)
+		for _, output := range outputs {
+			cores = append(cores, &sinkCore{
+				LevelEnabler: output.level,
+				encoder:      output.encoder.Clone(),
+				sink:         output.sink,
+				name:         output.name,
+				metrics:      output.metrics,
+			})
+		}
+		return zapcore.NewTee(cores...)
+	})
+}
+
+type sinkCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	sink    Sink
+	name    string
+	metrics Metrics
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &sinkCore{LevelEnabler: c.LevelEnabler, encoder: c.encoder.Clone(), sink: c.sink, name: c.name, metrics: c.metrics}
+	for i := range fields {
+		fields[i].AddTo(clone.encoder)
+	}
+	return clone
+}
+
+func (c *sinkCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *sinkCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	err = c.sink.WriteEntry(entry, bytes.TrimRight(buf.Bytes(), "\n"))
+	if err != nil && c.metrics != nil {
+		c.metrics.SinkError(c.name)
+	}
+	return err
+}
+
+func (c *sinkCore) Sync() error {
+	return c.sink.Sync()
+}
+
+// syslogSeverity maps the level to the severity of syslog, which journald uses as priority
+func syslogSeverity(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel:
+		return 2
+	case zapcore.PanicLevel:
+		return 1
+	case zapcore.FatalLevel:
+		return 0
+	default:
+		return 5
+	}
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// readSyslogFrame reads a message that is framed with its length
+func readSyslogFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	length, err := r.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	require.NoError(t, err)
+
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	require.NoError(t, err)
+	return string(msg)
+}
+
+func TestSyslogSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	sink, err := NewSyslogSink(&SyslogOptions{Address: ln.Addr().String(), Facility: "daemon", Hostname: "host 1"})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	output, err := NewSinkOutput(sink, EncodingLogfmt, zap.InfoLevel)
+	require.NoError(t, err)
+	logger := zap.New(zapcore.NewNopCore(), WithSinks(output)).Named("access")
+
+	logger.Debug("not written")
+	logger.Warn("slow request", zap.Int("status", 200))
+
+	conn := <-conns
+	msg := readSyslogFrame(t, bufio.NewReader(conn))
+	// daemon (3) * 8 + warning (4)
+	require.Regexp(t, `^<28>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}Z host1 router \d+ access - time=`, msg)
+	require.True(t, strings.HasSuffix(msg, ` level=warn logger=access msg="slow request" status=200`))
+
+	// The write after the server closed the connection is retried on a new connection
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool {
+		logger.Error("failed")
+		select {
+		case conn = <-conns:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 50*time.Millisecond)
+	defer conn.Close()
+
+	msg = readSyslogFrame(t, bufio.NewReader(conn))
+	require.True(t, strings.HasPrefix(msg, "<27>1 "))
+}
+
+func TestSyslogSinkBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	sink, err := NewSyslogSink(&SyslogOptions{Address: addr, Timeout: time.Second})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	entry := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: "a"}
+	require.ErrorContains(t, sink.WriteEntry(entry, []byte("a")), "failed to connect to the syslog server")
+	require.ErrorContains(t, sink.WriteEntry(entry, []byte("a")), "the syslog server is unreachable, reconnecting in")
+}
+
+func TestNewSyslogSink(t *testing.T) {
+	_, err := NewSyslogSink(&SyslogOptions{})
+	require.EqualError(t, err, "the address of the syslog server must not be empty")
+
+	_, err = NewSyslogSink(&SyslogOptions{Network: "unix", Address: "a:1"})
+	require.EqualError(t, err, "unknown syslog network 'unix'")
+
+	_, err = NewSyslogSink(&SyslogOptions{Address: "a:1", Facility: "local9"})
+	require.EqualError(t, err, "unknown syslog facility 'local9'")
+}
+
+func TestJournaldSink(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewJournaldSink(&JournaldOptions{SocketPath: socket})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	output, err := NewSinkOutput(sink, "", zap.DebugLevel)
+	require.NoError(t, err)
+	logger := zap.New(zapcore.NewNopCore(), WithSinks(output)).Named("access")
+
+	logger.Error("request failed", zap.String("query", "{\n  a\n}"))
+
+	buf := make([]byte, 65536)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	msg := buf[:n]
+
+	require.True(t, bytes.HasPrefix(msg, []byte("PRIORITY=3\nSYSLOG_IDENTIFIER=router\nLOGGER=access\n")))
+
+	// The JSON has no newlines, the escaped newlines of the field stay in the value
+	value, ok := bytes.CutPrefix(msg, []byte("PRIORITY=3\nSYSLOG_IDENTIFIER=router\nLOGGER=access\nMESSAGE="))
+	require.True(t, ok)
+	require.Contains(t, string(value), `"msg":"request failed","query":"{\n  a\n}"}`)
+
+	// Values with newlines are written with their length
+	var framed bytes.Buffer
+	appendJournaldField(&framed, "MESSAGE", []byte("a\nb"))
+	expected := append([]byte("MESSAGE\n"), binary.LittleEndian.AppendUint64(nil, 3)...)
+	require.Equal(t, append(expected, []byte("a\nb\n")...), framed.Bytes())
+
+	_, err = NewJournaldSink(&JournaldOptions{SocketPath: filepath.Join(t.TempDir(), "missing.socket")})
+	require.ErrorContains(t, err, "failed to connect to journald")
+}
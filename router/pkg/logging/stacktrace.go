@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// stacktraceKey is the key of the stacktrace in the encoder config of the router
+const stacktraceKey = "stacktrace"
+
+// StackFrame is a single frame of a stacktrace
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+func (f StackFrame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", f.Function)
+	enc.AddString("file", f.File)
+	enc.AddInt("line", f.Line)
+	return nil
+}
+
+type stackFrames []StackFrame
+
+func (s stackFrames) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, frame := range s {
+		if err := enc.AppendObject(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseStacktrace parses a stacktrace in the format of zap, i.e. the function name followed by a tab indented
+// line with the file and the line number for every frame
+func ParseStacktrace(stack string) []StackFrame {
+	lines := strings.Split(strings.TrimSpace(stack), "\n")
+	frames := make([]StackFrame, 0, len(lines)/2)
+
+	for i := 0; i < len(lines); i++ {
+		frame := StackFrame{Function: strings.TrimSpace(lines[i])}
+
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\t") {
+			i++
+			location := strings.TrimSpace(lines[i])
+			// The location can have a suffix like " +0x1d" in traces of the Go runtime
+			if idx := strings.IndexByte(location, ' '); idx != -1 {
+				location = location[:idx]
+			}
+			if idx := strings.LastIndexByte(location, ':'); idx != -1 {
+				if line, err := strconv.Atoi(location[idx+1:]); err == nil {
+					frame.File = location[:idx]
+					frame.Line = line
+				}
+			}
+			if frame.File == "" {
+				frame.File = location
+			}
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
+
+// WithStacktraceFrames returns an option that writes stacktraces as an array of frames instead of a single string,
+// so that log backends can render and group them. Use it with the JSON encoder.
+func WithStacktraceFrames() zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &stacktraceFramesCore{Core: core}
+	})
+}
+
+type stacktraceFramesCore struct {
+	zapcore.Core
+}
+
+func (c *stacktraceFramesCore) With(fields []zapcore.Field) zapcore.Core {
+	return &stacktraceFramesCore{Core: c.Core.With(fields)}
+}
+
+func (c *stacktraceFramesCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *stacktraceFramesCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Stack == "" {
+		return c.Core.Write(ent, fields)
+	}
+
+	frames := ParseStacktrace(ent.Stack)
+	ent.Stack = ""
+
+	return c.Core.Write(ent, append(fields[:len(fields):len(fields)], zap.Array(stacktraceKey, stackFrames(frames))))
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseStacktrace(t *testing.T) {
+	stack := "github.com/wundergraph/cosmo/router/core.(*Router).Start\n" +
+		"\t/app/router/core/router.go:1234\n" +
+		"main.main\n" +
+		"\t/app/router/cmd/main.go:12 +0x1d\n"
+
+	require.Equal(t, []StackFrame{
+		{Function: "github.com/wundergraph/cosmo/router/core.(*Router).Start", File: "/app/router/core/router.go", Line: 1234},
+		{Function: "main.main", File: "/app/router/cmd/main.go", Line: 12},
+	}, ParseStacktrace(stack))
+}
+
+func TestStacktraceFrames(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zap.New(
+		zapcore.NewCore(ZapJsonEncoder(), zapcore.AddSync(&buf), zapcore.InfoLevel),
+		zap.AddStacktrace(zap.ErrorLevel),
+		WithStacktraceFrames(),
+	)
+
+	logger.Error("failed")
+
+	var entry struct {
+		Stacktrace []struct {
+			Function string `json:"function"`
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+		} `json:"stacktrace"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.NotEmpty(t, entry.Stacktrace)
+	require.Contains(t, entry.Stacktrace[0].Function, "TestStacktraceFrames")
+	require.Contains(t, entry.Stacktrace[0].File, "stacktrace_test.go")
+	require.Positive(t, entry.Stacktrace[0].Line)
+
+	buf.Reset()
+	logger.Info("info")
+	require.NotContains(t, buf.String(), `"stacktrace"`)
+}
@@ -0,0 +1,219 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultStreamBatchSize     = 100
+	defaultStreamFlushInterval = time.Second
+	defaultStreamTimeout       = 5 * time.Second
+	defaultStreamBufferSize    = 10000
+)
+
+// streamPublisher publishes a batch of encoded entries to a streaming platform like Kafka or NATS
+type streamPublisher interface {
+	publish(ctx context.Context, batch []streamEntry) error
+	close()
+}
+
+type streamEntry struct {
+	level   zapcore.Level
+	time    time.Time
+	encoded []byte
+}
+
+type StreamOptions struct {
+	// BatchSize is the maximum number of entries that are published at once. If zero, 100 entries are batched.
+	BatchSize int
+	// FlushInterval publishes the incomplete batches periodically. If zero, they're published every second.
+	FlushInterval time.Duration
+	// Timeout limits the publishing of a batch. If zero, 5 seconds are used.
+	Timeout time.Duration
+	// BufferSize is the maximum number of entries that wait to be published. Beyond it, the entries are written to
+	// the fallback directly. If zero, 10000 entries are buffered.
+	BufferSize int
+	// Fallback receives the entries that couldn't be published, one per line, e.g. stdout. If nil, they're dropped.
+	Fallback zapcore.WriteSyncer
+	// Metrics counts the batches that couldn't be published as errors of the sink. Optional.
+	Metrics Metrics
+}
+
+// StreamSink publishes the entries in batches to a Kafka topic or a NATS subject. The batches are published in the
+// background, so that an unavailable platform doesn't block the logger. The entries of a batch that can't be
+// published are written to the fallback instead. Close the sink before exiting to publish the remaining entries.
+type StreamSink struct {
+	name      string
+	publisher streamPublisher
+
+	batchSize     int
+	timeout       time.Duration
+	bufferSize    int
+	fallback      zapcore.WriteSyncer
+	flushInterval time.Duration
+	metrics       Metrics
+
+	mu      sync.Mutex
+	pending []streamEntry
+
+	// flushMu serializes the publishing, so that the batches keep their order
+	flushMu sync.Mutex
+	full    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	closed  sync.Once
+
+	failed atomic.Int64
+}
+
+func newStreamSink(name string, publisher streamPublisher, opts *StreamOptions) *StreamSink {
+	s := &StreamSink{
+		name:          name,
+		publisher:     publisher,
+		batchSize:     opts.BatchSize,
+		timeout:       opts.Timeout,
+		bufferSize:    opts.BufferSize,
+		fallback:      opts.Fallback,
+		flushInterval: opts.FlushInterval,
+		metrics:       opts.Metrics,
+		full:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultStreamBatchSize
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultStreamTimeout
+	}
+	if s.bufferSize <= 0 {
+		s.bufferSize = defaultStreamBufferSize
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = defaultStreamFlushInterval
+	}
+
+	go s.run()
+	return s
+}
+
+// Name returns the name of the platform, kafka or nats
+func (s *StreamSink) Name() string {
+	return s.name
+}
+
+// Dropped returns the number of entries that couldn't be published. They were written to the fallback, if any.
+func (s *StreamSink) Dropped() int64 {
+	return s.failed.Load()
+}
+
+func (s *StreamSink) WriteEntry(entry zapcore.Entry, encoded []byte) error {
+	e := streamEntry{level: entry.Level, time: entry.Time, encoded: make([]byte, len(encoded))}
+	copy(e.encoded, encoded)
+
+	s.mu.Lock()
+	if len(s.pending) >= s.bufferSize {
+		s.mu.Unlock()
+		return s.fail([]streamEntry{e})
+	}
+	s.pending = append(s.pending, e)
+	full := len(s.pending) >= s.batchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Sync publishes the pending entries and waits until they're published or written to the fallback
+func (s *StreamSink) Sync() error {
+	return s.flush()
+}
+
+// Close publishes the pending entries and closes the connection
+func (s *StreamSink) Close() error {
+	var err error
+	s.closed.Do(func() {
+		close(s.stop)
+		<-s.done
+		err = s.flush()
+		s.publisher.close()
+	})
+	return err
+}
+
+func (s *StreamSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.full:
+			// Like zap, the errors can't be reported to the logger
+			_ = s.flush()
+		case <-ticker.C:
+			_ = s.flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *StreamSink) flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	var errs []error
+	for len(pending) > 0 {
+		batch := pending[:min(len(pending), s.batchSize)]
+		pending = pending[len(batch):]
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		err := s.publisher.publish(ctx, batch)
+		cancel()
+		if err != nil {
+			if s.metrics != nil {
+				s.metrics.SinkError(s.name)
+			}
+			errs = append(errs, err, s.fail(batch))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fail writes the entries to the fallback
+func (s *StreamSink) fail(entries []streamEntry) error {
+	s.failed.Add(int64(len(entries)))
+	if s.fallback == nil {
+		return nil
+	}
+
+	var size int
+	for _, e := range entries {
+		size += len(e.encoded) + 1
+	}
+	lines := make([]byte, 0, size)
+	for _, e := range entries {
+		lines = append(lines, e.encoded...)
+		lines = append(lines, '\n')
+	}
+	_, err := s.fallback.Write(lines)
+	return err
+}
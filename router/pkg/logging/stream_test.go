@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+type fakePublisher struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+	closed  bool
+}
+
+func (p *fakePublisher) publish(_ context.Context, batch []streamEntry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	entries := make([]string, len(batch))
+	for i, e := range batch {
+		entries[i] = string(e.encoded)
+	}
+	p.batches = append(p.batches, entries)
+	return nil
+}
+
+func (p *fakePublisher) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+}
+
+func (p *fakePublisher) published() [][]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.batches
+}
+
+func writeStreamEntries(t *testing.T, sink *StreamSink, messages ...string) {
+	t.Helper()
+
+	for _, msg := range messages {
+		require.NoError(t, sink.WriteEntry(zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: msg}, []byte(msg)))
+	}
+}
+
+func TestStreamSink(t *testing.T) {
+	publisher := &fakePublisher{}
+	sink := newStreamSink("kafka", publisher, &StreamOptions{BatchSize: 2, FlushInterval: time.Hour})
+	writeStreamEntries(t, sink, "a", "b")
+
+	// The full batch is published in the background
+	require.Eventually(t, func() bool {
+		return len(publisher.published()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"a", "b"}, publisher.published()[0])
+
+	writeStreamEntries(t, sink, "c")
+
+	// The incomplete batch is published on close
+	require.NoError(t, sink.Close())
+	require.Equal(t, []string{"c"}, publisher.published()[1])
+	require.True(t, publisher.closed)
+	require.Equal(t, int64(0), sink.Dropped())
+}
+
+func TestStreamSinkFallback(t *testing.T) {
+	var fallback bytes.Buffer
+	publisher := &fakePublisher{err: errors.New("unavailable")}
+	sink := newStreamSink("nats", publisher, &StreamOptions{BatchSize: 10, BufferSize: 2, Fallback: zapcore.AddSync(&fallback)})
+	defer sink.Close()
+
+	// Beyond the buffer, the entry is written to the fallback directly
+	writeStreamEntries(t, sink, "a", "b", "c")
+	require.Equal(t, "c\n", fallback.String())
+
+	require.ErrorContains(t, sink.Sync(), "unavailable")
+	require.Equal(t, "c\na\nb\n", fallback.String())
+	require.Equal(t, int64(3), sink.Dropped())
+}
+
+func TestNatsMessage(t *testing.T) {
+	batch := []streamEntry{{encoded: []byte(`{"msg":"a"}`)}, {encoded: []byte(`{"msg":"b"}`)}}
+
+	msg, err := (&natsPublisher{subject: "router.logs"}).message(batch)
+	require.NoError(t, err)
+	require.Equal(t, "router.logs", msg.Subject)
+	require.Equal(t, "{\"msg\":\"a\"}\n{\"msg\":\"b\"}", string(msg.Data))
+	require.Empty(t, msg.Header.Get("Content-Encoding"))
+
+	msg, err = (&natsPublisher{subject: "router.logs", gzip: true}).message(batch)
+	require.NoError(t, err)
+	require.Equal(t, "gzip", msg.Header.Get("Content-Encoding"))
+	r, err := gzip.NewReader(bytes.NewReader(msg.Data))
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(strings.Split(string(data), "\n")))
+}
+
+func TestNewStreamSinks(t *testing.T) {
+	_, err := NewKafkaSink(&KafkaSinkOptions{Brokers: []string{"localhost:9092"}})
+	require.EqualError(t, err, "the topic of the kafka sink must not be empty")
+
+	_, err = NewKafkaSink(&KafkaSinkOptions{Brokers: []string{"localhost:9092"}, Topic: "logs", Compression: "brotli"})
+	require.EqualError(t, err, "unknown kafka compression 'brotli'")
+
+	_, err = NewNatsSink(&NatsSinkOptions{URL: "nats://localhost:4222", Subject: "logs", Compression: "zstd"})
+	require.EqualError(t, err, "unknown nats compression 'zstd'")
+}
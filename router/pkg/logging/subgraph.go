@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SubgraphField is the field of the loggers of the subgraphs
+const SubgraphField = "subgraph"
+
+// ForSubgraph returns a child logger with the name of the subgraph as field. With WithSubgraphLevels, the level
+// override of the subgraph applies to it instead of the level of the router.
+func ForSubgraph(logger *zap.Logger, name string) *zap.Logger {
+	return logger.With(zap.String(SubgraphField, name))
+}
+
+// SubgraphLevels are the level overrides of the loggers of the subgraphs by the names of the subgraphs
+type SubgraphLevels map[string]zapcore.Level
+
+// OutputLevel returns the level the outputs of the logger must be created with, so that they also enable the
+// entries of the subgraphs with a lower level than the router
+func (l SubgraphLevels) OutputLevel(level zapcore.LevelEnabler) zapcore.LevelEnabler {
+	if len(l) == 0 {
+		return level
+	}
+	lowest := zapcore.FatalLevel
+	for _, override := range l {
+		lowest = min(lowest, override)
+	}
+	return zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl >= lowest || level.Enabled(lvl)
+	})
+}
+
+// WithSubgraphLevels returns an option that filters the entries of the loggers of the subgraphs with the override
+// of their subgraph and all other entries with the level of the router. The outputs of the logger must be created
+// with the OutputLevel of the overrides.
+func WithSubgraphLevels(level zapcore.LevelEnabler, levels SubgraphLevels) zap.Option {
+	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if len(levels) == 0 {
+			return core
+		}
+		return &subgraphLevelCore{Core: core, router: level, level: level, levels: levels}
+	})
+}
+
+type subgraphLevelCore struct {
+	zapcore.Core
+	router zapcore.LevelEnabler
+	// level is the override of the subgraph of the fields, or the level of the router
+	level  zapcore.LevelEnabler
+	levels SubgraphLevels
+}
+
+func (c *subgraphLevelCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl)
+}
+
+func (c *subgraphLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &subgraphLevelCore{Core: c.Core.With(fields), router: c.router, level: c.level, levels: c.levels}
+	for _, field := range fields {
+		if field.Key != SubgraphField || field.Type != zapcore.StringType {
+			continue
+		}
+		// The last subgraph field wins, like in the encoded entry
+		clone.level = c.router
+		if override, ok := c.levels[field.String]; ok {
+			clone.level = override
+		}
+	}
+	return clone
+}
+
+func (c *subgraphLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestForSubgraph(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	levels := SubgraphLevels{"employees": zapcore.DebugLevel, "products": zapcore.ErrorLevel}
+	core, logs := observer.New(levels.OutputLevel(level))
+	logger := zap.New(core, WithSubgraphLevels(level, levels))
+
+	logger.Debug("router")
+	ForSubgraph(logger, "employees").Debug("employees")
+	ForSubgraph(logger, "products").Warn("products")
+	ForSubgraph(logger, "products").Error("products failed")
+	// Subgraphs without an override follow the level of the router
+	ForSubgraph(logger, "inventory").Debug("inventory")
+	ForSubgraph(logger, "inventory").Info("inventory request")
+
+	messages := make([]string, 0, logs.Len())
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	require.Equal(t, []string{"employees", "products failed", "inventory request"}, messages)
+	require.Equal(t, "employees", logs.All()[0].ContextMap()[SubgraphField])
+
+	// The level of the router can still be changed at runtime
+	level.SetLevel(zapcore.DebugLevel)
+	logger.Debug("router")
+	require.Equal(t, 1, logs.FilterMessage("router").Len())
+}
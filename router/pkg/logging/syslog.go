@@ -0,0 +1,244 @@
+package logging
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	SyslogNetworkTCP = "tcp"
+	SyslogNetworkTLS = "tls"
+	SyslogNetworkUDP = "udp"
+)
+
+const (
+	defaultSyslogTimeout = 5 * time.Second
+	minSyslogBackoff     = 100 * time.Millisecond
+	maxSyslogBackoff     = 30 * time.Second
+)
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+type SyslogOptions struct {
+	// Network is tcp, tls or udp. If empty, tcp is used.
+	Network string
+	// Address is the host and port of the syslog server
+	Address string
+	// TLSConfig is the config of the tls network. If nil, the system roots verify the server.
+	TLSConfig *tls.Config
+	// Facility is the name of the facility, e.g. daemon or local0. If empty, local0 is used.
+	Facility string
+	// AppName is the APP-NAME of the messages. If empty, "router" is used.
+	AppName string
+	// Hostname is the HOSTNAME of the messages. If empty, the hostname of the machine is used.
+	Hostname string
+	// Timeout limits connecting and writing, so that an unresponsive server doesn't block the logger. If zero,
+	// 5 seconds are used.
+	Timeout time.Duration
+}
+
+// SyslogSink writes the entries as RFC 5424 messages to a remote syslog server. Over TCP and TLS the messages are
+// framed with their length (RFC 6587, RFC 5425). The levels are mapped to the syslog severities. When the server is
+// unreachable, the sink reconnects with an exponential backoff and drops the entries in between.
+type SyslogSink struct {
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	facility  int
+	appName   string
+	hostname  string
+	procID    string
+	timeout   time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+	closed  bool
+}
+
+func NewSyslogSink(opts *SyslogOptions) (*SyslogSink, error) {
+	s := &SyslogSink{
+		network:   opts.Network,
+		address:   opts.Address,
+		tlsConfig: opts.TLSConfig,
+		appName:   opts.AppName,
+		hostname:  opts.Hostname,
+		procID:    strconv.Itoa(os.Getpid()),
+		timeout:   opts.Timeout,
+	}
+
+	switch s.network {
+	case "":
+		s.network = SyslogNetworkTCP
+	case SyslogNetworkTCP, SyslogNetworkTLS, SyslogNetworkUDP:
+	default:
+		return nil, errors.New("unknown syslog network '" + opts.Network + "'")
+	}
+	if s.address == "" {
+		return nil, errors.New("the address of the syslog server must not be empty")
+	}
+
+	facility := opts.Facility
+	if facility == "" {
+		facility = "local0"
+	}
+	var ok bool
+	if s.facility, ok = syslogFacilities[facility]; !ok {
+		return nil, errors.New("unknown syslog facility '" + opts.Facility + "'")
+	}
+
+	if s.appName == "" {
+		s.appName = "router"
+	}
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	s.appName = syslogHeaderValue(s.appName, 48)
+	s.hostname = syslogHeaderValue(s.hostname, 255)
+	if s.timeout <= 0 {
+		s.timeout = defaultSyslogTimeout
+	}
+
+	return s, nil
+}
+
+// syslogHeaderValue returns the value as printable ASCII of at most the length, or the nil value "-"
+func syslogHeaderValue(value string, length int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) > length {
+		value = value[:length]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func (s *SyslogSink) WriteEntry(entry zapcore.Entry, encoded []byte) error {
+	msg := s.message(entry, encoded)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("the syslog sink is closed")
+	}
+
+	// A connection that the server closed is only noticed by the next write, which is retried on a new connection
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		if _, err = s.conn.Write(msg); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// message formats the RFC 5424 message of the entry with its framing
+func (s *SyslogSink) message(entry zapcore.Entry, encoded []byte) []byte {
+	msgID := "-"
+	if entry.LoggerName != "" {
+		msgID = syslogHeaderValue(entry.LoggerName, 32)
+	}
+
+	header := "<" + strconv.Itoa(s.facility*8+syslogSeverity(entry.Level)) + ">1 " +
+		entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00") + " " +
+		s.hostname + " " + s.appName + " " + s.procID + " " + msgID + " - "
+
+	if s.network == SyslogNetworkUDP {
+		return append([]byte(header), encoded...)
+	}
+
+	length := len(header) + len(encoded)
+	msg := make([]byte, 0, length+8)
+	msg = strconv.AppendInt(msg, int64(length), 10)
+	msg = append(msg, ' ')
+	msg = append(msg, header...)
+	return append(msg, encoded...)
+}
+
+func (s *SyslogSink) connect() error {
+	if now := time.Now(); now.Before(s.retryAt) {
+		return errors.New("the syslog server is unreachable, reconnecting in " + s.retryAt.Sub(now).Round(time.Millisecond).String())
+	}
+
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var conn net.Conn
+	var err error
+	switch s.network {
+	case SyslogNetworkTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	default:
+		conn, err = dialer.Dial(s.network, s.address)
+	}
+	if err != nil {
+		s.backoff = min(max(s.backoff*2, minSyslogBackoff), maxSyslogBackoff)
+		s.retryAt = time.Now().Add(s.backoff)
+		return errors.New("failed to connect to the syslog server: " + err.Error())
+	}
+
+	s.conn = conn
+	s.backoff = 0
+	s.retryAt = time.Time{}
+	return nil
+}
+
+func (s *SyslogSink) Sync() error {
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
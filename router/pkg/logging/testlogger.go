@@ -0,0 +1,194 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// UpdateGoldenLogsEnv is the environment variable that makes RequireGolden write the golden files instead of
+// comparing them
+const UpdateGoldenLogsEnv = "UPDATE_GOLDEN_LOGS"
+
+type testLoggerOptions struct {
+	level       zapcore.Level
+	failOnError bool
+}
+
+type TestLoggerOption func(*testLoggerOptions)
+
+// TestLevel captures the entries of the level and above. The default is the debug level.
+func TestLevel(level zapcore.Level) TestLoggerOption {
+	return func(o *testLoggerOptions) {
+		o.level = level
+	}
+}
+
+// FailOnError fails the test at its end when the logger wrote an entry of the error level or above, that wasn't
+// allowed with AllowError
+func FailOnError() TestLoggerOption {
+	return func(o *testLoggerOptions) {
+		o.failOnError = true
+	}
+}
+
+// TestLogger is a logger for tests that captures the entries in memory, so that the tests can assert on their
+// level, message and fields
+type TestLogger struct {
+	*zap.Logger
+
+	t    testing.TB
+	logs *observer.ObservedLogs
+
+	mu            sync.Mutex
+	allowedErrors []string
+}
+
+// NewTestLogger creates a logger that captures its entries until the end of the test
+func NewTestLogger(t testing.TB, opts ...TestLoggerOption) *TestLogger {
+	options := testLoggerOptions{level: zapcore.DebugLevel}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	core, logs := observer.New(options.level)
+	l := &TestLogger{
+		Logger: zap.New(core),
+		t:      t,
+		logs:   logs,
+	}
+	if options.failOnError {
+		t.Cleanup(l.checkErrors)
+	}
+	return l
+}
+
+// Entries returns the captured entries
+func (l *TestLogger) Entries() []observer.LoggedEntry {
+	return l.logs.All()
+}
+
+// AllowError allows the entries of the error level and above with the message, when the logger fails on errors
+func (l *TestLogger) AllowError(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.allowedErrors = append(l.allowedErrors, message)
+}
+
+// RequireEntry fails the test unless an entry has the level, the message and at least the fields. It returns the
+// first matching entry.
+func (l *TestLogger) RequireEntry(level zapcore.Level, message string, fields ...zap.Field) observer.LoggedEntry {
+	l.t.Helper()
+
+	expected := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(expected)
+	}
+
+	for _, entry := range l.logs.All() {
+		if entry.Level == level && entry.Message == message && containsFields(entry.ContextMap(), expected.Fields) {
+			return entry
+		}
+	}
+	l.t.Fatalf("no %s entry %q with the fields %v, the entries are:\n%s", level, message, expected.Fields, l.dump())
+	return observer.LoggedEntry{}
+}
+
+// RequireNoEntry fails the test if an entry has the level and the message
+func (l *TestLogger) RequireNoEntry(level zapcore.Level, message string) {
+	l.t.Helper()
+
+	for _, entry := range l.logs.All() {
+		if entry.Level == level && entry.Message == message {
+			l.t.Fatalf("unexpected %s entry %q with the fields %v", level, message, entry.ContextMap())
+		}
+	}
+}
+
+// RequireGolden compares the captured entries with the golden file. The timestamps and callers are left out, so
+// that the file is stable between the runs. With the environment variable UPDATE_GOLDEN_LOGS set, the golden file
+// is written instead.
+func (l *TestLogger) RequireGolden(path string) {
+	l.t.Helper()
+	requireGolden(l.t, path, l.dump())
+}
+
+// requireGolden compares actual with the golden file, or writes it with the environment variable UPDATE_GOLDEN_LOGS set
+func requireGolden(t testing.TB, path, actual string) {
+	t.Helper()
+
+	if os.Getenv(UpdateGoldenLogsEnv) != "" {
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatalf("could not write the golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read the golden file, set %s to create it: %v", UpdateGoldenLogsEnv, err)
+	}
+	if string(expected) != actual {
+		t.Fatalf("the entries don't match the golden file %s, set %s to update it\nexpected:\n%s\nactual:\n%s",
+			path, UpdateGoldenLogsEnv, expected, actual)
+	}
+}
+
+// dump encodes the entries as JSON lines without the timestamps and callers
+func (l *TestLogger) dump() string {
+	ec := zapBaseEncoderConfig()
+	ec.TimeKey = ""
+	ec.CallerKey = ""
+	ec.StacktraceKey = ""
+	encoder := zapcore.NewJSONEncoder(ec)
+
+	var out bytes.Buffer
+	for _, entry := range l.logs.All() {
+		buf, err := encoder.EncodeEntry(entry.Entry, entry.Context)
+		if err != nil {
+			fmt.Fprintf(&out, "could not encode the entry %q: %v\n", entry.Message, err)
+			continue
+		}
+		out.Write(buf.Bytes())
+		buf.Free()
+	}
+	return out.String()
+}
+
+// checkErrors fails the test for the entries of the error level and above that weren't allowed
+func (l *TestLogger) checkErrors() {
+	l.mu.Lock()
+	allowed := l.allowedErrors
+	l.mu.Unlock()
+
+	var unexpected []string
+	for _, entry := range l.logs.All() {
+		if entry.Level < zapcore.ErrorLevel {
+			continue
+		}
+		if !slices.Contains(allowed, entry.Message) {
+			unexpected = append(unexpected, fmt.Sprintf("%s %q %v", entry.Level, entry.Message, entry.ContextMap()))
+		}
+	}
+	if len(unexpected) > 0 {
+		l.t.Errorf("unexpected error entries:\n%s", strings.Join(unexpected, "\n"))
+	}
+}
+
+func containsFields(actual, expected map[string]any) bool {
+	for key, value := range expected {
+		if !reflect.DeepEqual(actual[key], value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// recordingTB records the failures of the assertions instead of failing the test
+type recordingTB struct {
+	testing.TB
+	failures []string
+	cleanups []func()
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+func (r *recordingTB) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func (r *recordingTB) finish() {
+	for _, f := range r.cleanups {
+		f()
+	}
+}
+
+func TestTestLogger(t *testing.T) {
+	t.Parallel()
+
+	logger := NewTestLogger(t, TestLevel(zapcore.InfoLevel))
+	logger.Debug("not captured")
+	logger.Info("Request", zap.String("operation", "employees"), zap.Int("status", 200))
+	logger.Warn("Slow request")
+
+	require.Len(t, logger.Entries(), 2)
+	entry := logger.RequireEntry(zapcore.InfoLevel, "Request", zap.Int("status", 200))
+	require.Equal(t, "employees", entry.ContextMap()["operation"])
+	logger.RequireNoEntry(zapcore.ErrorLevel, "Request")
+
+	tb := &recordingTB{}
+	failing := NewTestLogger(tb)
+	failing.Info("Request", zap.Int("status", 500))
+	failing.RequireEntry(zapcore.InfoLevel, "Request", zap.Int("status", 200))
+	failing.RequireNoEntry(zapcore.InfoLevel, "Request")
+	require.Len(t, tb.failures, 2)
+	require.Contains(t, tb.failures[0], `no info entry "Request"`)
+	require.Contains(t, tb.failures[0], `"status":500`)
+}
+
+func TestTestLoggerFailOnError(t *testing.T) {
+	t.Parallel()
+
+	tb := &recordingTB{}
+	logger := NewTestLogger(tb, FailOnError())
+	logger.AllowError("Subgraph unavailable")
+	logger.Error("Subgraph unavailable")
+	logger.Warn("Retrying")
+	tb.finish()
+	require.Empty(t, tb.failures)
+
+	tb = &recordingTB{}
+	logger = NewTestLogger(tb, FailOnError())
+	logger.Error("Could not resolve", zap.Error(errors.New("boom")))
+	tb.finish()
+	require.Len(t, tb.failures, 1)
+	require.Contains(t, tb.failures[0], `error "Could not resolve"`)
+}
+
+func TestTestLoggerGolden(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "entries.golden")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{"level":"info","logger":"router","msg":"Server started","port":3002}`+"\n"+
+			`{"level":"warn","logger":"router","msg":"Config reloaded","version":"v2"}`+"\n",
+	), 0o644))
+
+	logger := NewTestLogger(t)
+	router := logger.Named("router")
+	router.Info("Server started", zap.Int("port", 3002))
+	router.Warn("Config reloaded", zap.String("version", "v2"))
+	logger.RequireGolden(path)
+
+	tb := &recordingTB{}
+	changed := NewTestLogger(tb)
+	changed.Named("router").Info("Server started", zap.Int("port", 3003))
+	changed.RequireGolden(path)
+	require.Len(t, tb.failures, 1)
+	require.Contains(t, tb.failures[0], "don't match the golden file")
+}
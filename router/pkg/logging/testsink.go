@@ -0,0 +1,170 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// MemorySink is an output for tests that captures the encoded entries in memory, e.g. as the Writer of the access
+// logger. Unlike TestLogger, it asserts on the entries as they are written, after the encoding and the filters.
+type MemorySink struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *MemorySink) Sync() error {
+	return nil
+}
+
+// Lines returns the written entries, one line per entry
+func (s *MemorySink) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := strings.TrimSuffix(s.buf.String(), "\n")
+	if data == "" {
+		return nil
+	}
+	return strings.Split(data, "\n")
+}
+
+// Entries decodes the entries written with the JSON encoding. It fails the test for an entry that isn't JSON.
+func (s *MemorySink) Entries(t testing.TB) []map[string]any {
+	t.Helper()
+
+	lines := s.Lines()
+	entries := make([]map[string]any, 0, len(lines))
+	for _, line := range lines {
+		decoder := json.NewDecoder(strings.NewReader(line))
+		// Numbers are kept as written, so that integers and floats can be told apart
+		decoder.UseNumber()
+		entry := map[string]any{}
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatalf("the entry %q isn't JSON: %v", line, err)
+			return nil
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// RequireEntry fails the test unless an entry has the message and at least the fields. The values are compared by
+// their JSON encoding, so that e.g. an int matches the decoded number. It returns the first matching entry.
+func (s *MemorySink) RequireEntry(t testing.TB, message string, fields map[string]any) map[string]any {
+	t.Helper()
+
+	for _, entry := range s.Entries(t) {
+		if entry["msg"] == message && containsJSONFields(entry, fields) {
+			return entry
+		}
+	}
+	t.Fatalf("no entry %q with the fields %v, the entries are:\n%s", message, fields, strings.Join(s.Lines(), "\n"))
+	return nil
+}
+
+func containsJSONFields(actual, expected map[string]any) bool {
+	for key, value := range expected {
+		actualValue, ok := actual[key]
+		if !ok {
+			return false
+		}
+		expectedJSON, err := json.Marshal(value)
+		if err != nil {
+			return false
+		}
+		actualJSON, err := json.Marshal(actualValue)
+		if err != nil || !bytes.Equal(expectedJSON, actualJSON) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireGoldenSchema compares the schema of the entries with the golden file. The schema is the message of an entry
+// with the names and JSON types of its fields, so that the file locks the format of the entries without their
+// values, e.g. latencies and IDs. Entries of the same schema are listed once. With the environment variable
+// UPDATE_GOLDEN_LOGS set, the golden file is written instead.
+func (s *MemorySink) RequireGoldenSchema(t testing.TB, path string) {
+	t.Helper()
+
+	var schemas []string
+	for _, entry := range s.Entries(t) {
+		schema := entrySchema(entry)
+		if !slices.Contains(schemas, schema) {
+			schemas = append(schemas, schema)
+		}
+	}
+
+	var actual strings.Builder
+	for _, schema := range schemas {
+		actual.WriteString(schema)
+		actual.WriteByte('\n')
+	}
+	requireGolden(t, path, actual.String())
+}
+
+// entrySchema formats the message of the entry followed by its sorted fields as name=type. The fields of objects
+// are flattened with dots.
+func entrySchema(entry map[string]any) string {
+	types := map[string]string{}
+	for key, value := range entry {
+		if key == "msg" {
+			continue
+		}
+		addFieldTypes(types, key, value)
+	}
+
+	keys := make([]string, 0, len(types))
+	for key := range types {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v:", entry["msg"])
+	for _, key := range keys {
+		b.WriteString(" " + key + "=" + types[key])
+	}
+	return b.String()
+}
+
+func addFieldTypes(types map[string]string, key string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			types[key] = "object"
+		}
+		for name, field := range v {
+			addFieldTypes(types, key+"."+name, field)
+		}
+	case []any:
+		types[key] = "array"
+	case json.Number:
+		types[key] = "number"
+	case string:
+		types[key] = "string"
+	case bool:
+		types[key] = "boolean"
+	case nil:
+		types[key] = "null"
+	default:
+		types[key] = reflect.TypeOf(v).String()
+	}
+}
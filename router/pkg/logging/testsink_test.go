@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMemorySink(t *testing.T) {
+	t.Parallel()
+
+	sink := NewMemorySink()
+	logger, err := NewAccessLogger(&AccessLoggerOptions{
+		Writer: sink,
+		Fields: []string{"method", "status", "latency", "tags"},
+	})
+	require.NoError(t, err)
+
+	logger.Info("/graphql", zap.String("method", "POST"), zap.Int("status", 200), zap.Float64("latency", 0.5),
+		zap.String("query", "a=b"), zap.Any("tags", map[string]string{"team": "core"}))
+	logger.Info("/graphql", zap.String("method", "GET"), zap.Int("status", 404), zap.Float64("latency", 0.1))
+
+	require.Len(t, sink.Lines(), 2)
+	entry := sink.RequireEntry(t, "/graphql", map[string]any{"status": 200})
+	require.Equal(t, "POST", entry["method"])
+	// The fields that aren't allowed are filtered before the entry is written
+	require.NotContains(t, entry, "query")
+
+	tb := &recordingTB{}
+	sink.RequireEntry(tb, "/graphql", map[string]any{"status": 500})
+	require.Len(t, tb.failures, 1)
+	require.Contains(t, tb.failures[0], `"status":404`)
+
+	path := filepath.Join(t.TempDir(), "access.golden")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"/graphql: latency=number level=string logger=string method=string status=number tags.team=string time=number\n"+
+			"/graphql: latency=number level=string logger=string method=string status=number time=number\n",
+	), 0o644))
+	sink.RequireGoldenSchema(t, path)
+
+	// A field of another type changes the schema, other values don't
+	logger.Info("/graphql", zap.String("method", "POST"), zap.Int("status", 500), zap.String("latency", "1s"))
+	tb = &recordingTB{}
+	sink.RequireGoldenSchema(tb, path)
+	require.Len(t, tb.failures, 1)
+	require.Contains(t, tb.failures[0], "latency=string")
+}
@@ -41,6 +41,96 @@ func createMeasures(meter otelmetric.Meter) (*Measurements, error) {
 
 	h.counters[RequestError] = requestError
 
+	introspectionRequestCounter, err := meter.Int64Counter(
+		IntrospectionRequestCounter,
+		IntrospectionRequestCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create introspection request counter: %w", err)
+	}
+
+	h.counters[IntrospectionRequestCounter] = introspectionRequestCounter
+
+	operationTimeoutCounter, err := meter.Int64Counter(
+		OperationTimeoutCounter,
+		OperationTimeoutCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create operation timeout counter: %w", err)
+	}
+
+	h.counters[OperationTimeoutCounter] = operationTimeoutCounter
+
+	subgraphResponseViolations, err := meter.Int64Counter(
+		SubgraphViolationCounter,
+		SubgraphViolationCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subgraph response violations counter: %w", err)
+	}
+
+	h.counters[SubgraphViolationCounter] = subgraphResponseViolations
+
+	subgraphContractViolations, err := meter.Int64Counter(
+		SubgraphContractViolationCounter,
+		SubgraphContractViolationCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subgraph contract violations counter: %w", err)
+	}
+
+	h.counters[SubgraphContractViolationCounter] = subgraphContractViolations
+
+	subgraphCompressedBytes, err := meter.Int64Counter(
+		SubgraphCompressedBytesCounter,
+		SubgraphCompressedBytesCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subgraph compressed bytes counter: %w", err)
+	}
+
+	h.counters[SubgraphCompressedBytesCounter] = subgraphCompressedBytes
+
+	subgraphDecompressedBytes, err := meter.Int64Counter(
+		SubgraphDecompressedBytesCounter,
+		SubgraphDecompressedBytesCounterOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subgraph decompressed bytes counter: %w", err)
+	}
+
+	h.counters[SubgraphDecompressedBytesCounter] = subgraphDecompressedBytes
+
+	subgraphRequestSizeHistogram, err := meter.Float64Histogram(
+		SubgraphRequestSizeHistogram,
+		SubgraphRequestSizeHistogramOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subgraph request size histogram: %w", err)
+	}
+
+	h.histograms[SubgraphRequestSizeHistogram] = subgraphRequestSizeHistogram
+
+	subgraphResponseSizeHistogram, err := meter.Float64Histogram(
+		SubgraphResponseSizeHistogram,
+		SubgraphResponseSizeHistogramOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subgraph response size histogram: %w", err)
+	}
+
+	h.histograms[SubgraphResponseSizeHistogram] = subgraphResponseSizeHistogram
+
+	entityBatchSizeHistogram, err := meter.Float64Histogram(
+		EntityBatchSizeHistogram,
+		EntityBatchSizeHistogramOptions...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create entity batch size histogram: %w", err)
+	}
+
+	h.histograms[EntityBatchSizeHistogram] = entityBatchSizeHistogram
+
 	serverLatencyMeasure, err := meter.Float64Histogram(
 		ServerLatencyHistogram,
 		ServerLatencyHistogramOptions...,
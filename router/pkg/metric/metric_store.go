@@ -7,6 +7,7 @@ import (
 	"go.uber.org/zap"
 	"time"
 
+	rotel "github.com/wundergraph/cosmo/router/pkg/otel"
 	"go.opentelemetry.io/otel/attribute"
 	otelmetric "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/sdk/metric"
@@ -20,6 +21,17 @@ const (
 	ResponseContentLengthCounter  = "router.http.response.content_length"       // Outgoing response bytes total
 	InFlightRequestsUpDownCounter = "router.http.requests.in_flight"            // Number of requests in flight
 	RequestError                  = "router.http.requests.error"                // Total request error count
+	IntrospectionRequestCounter   = "router.graphql.introspection.requests"     // Introspection request count total
+	OperationTimeoutCounter       = "router.graphql.operation.timeouts"         // Timed out operation count total
+	EntityBatchSizeHistogram      = "router.graphql.entity.batch.size"          // Representations per subgraph entity request
+	SubgraphViolationCounter      = "router.graphql.subgraph.schema_violations" // Schema violations of subgraph responses total
+
+	SubgraphContractViolationCounter = "router.graphql.subgraph.contract_violations" // Excluded fields in subgraph responses total
+
+	SubgraphCompressedBytesCounter   = "router.http.subgraph.response.compressed_bytes"   // Compressed subgraph response bytes total
+	SubgraphDecompressedBytesCounter = "router.http.subgraph.response.decompressed_bytes" // Decompressed subgraph response bytes total
+	SubgraphRequestSizeHistogram     = "router.http.subgraph.request.size"                // Bytes sent per subgraph request
+	SubgraphResponseSizeHistogram    = "router.http.subgraph.response.size"               // Bytes received per subgraph response
 
 	unitBytes        = "bytes"
 	unitMilliseconds = "ms"
@@ -36,6 +48,47 @@ var (
 	RequestErrorCounterOptions     = []otelmetric.Int64CounterOption{
 		otelmetric.WithDescription(RequestErrorCounterDescription),
 	}
+	IntrospectionRequestCounterDescription = "Total number of introspection requests"
+	IntrospectionRequestCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithDescription(IntrospectionRequestCounterDescription),
+	}
+	OperationTimeoutCounterDescription = "Total number of operations that exceeded their timeout"
+	OperationTimeoutCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithDescription(OperationTimeoutCounterDescription),
+	}
+	SubgraphViolationCounterDescription = "Total number of schema violations in subgraph responses"
+	SubgraphViolationCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithDescription(SubgraphViolationCounterDescription),
+	}
+	SubgraphContractViolationCounterDescription = "Total number of contract excluded fields removed from subgraph responses"
+	SubgraphContractViolationCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithDescription(SubgraphContractViolationCounterDescription),
+	}
+	SubgraphCompressedBytesCounterDescription = "Total number of compressed bytes of the subgraph responses"
+	SubgraphCompressedBytesCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithUnit("bytes"),
+		otelmetric.WithDescription(SubgraphCompressedBytesCounterDescription),
+	}
+	SubgraphDecompressedBytesCounterDescription = "Total number of decompressed bytes of the subgraph responses"
+	SubgraphDecompressedBytesCounterOptions     = []otelmetric.Int64CounterOption{
+		otelmetric.WithUnit("bytes"),
+		otelmetric.WithDescription(SubgraphDecompressedBytesCounterDescription),
+	}
+	SubgraphRequestSizeHistogramDescription = "Size of the request bodies sent to the subgraphs"
+	SubgraphRequestSizeHistogramOptions     = []otelmetric.Float64HistogramOption{
+		otelmetric.WithUnit("bytes"),
+		otelmetric.WithDescription(SubgraphRequestSizeHistogramDescription),
+	}
+	SubgraphResponseSizeHistogramDescription = "Size of the response bodies received from the subgraphs"
+	SubgraphResponseSizeHistogramOptions     = []otelmetric.Float64HistogramOption{
+		otelmetric.WithUnit("bytes"),
+		otelmetric.WithDescription(SubgraphResponseSizeHistogramDescription),
+	}
+	EntityBatchSizeHistogramDescription = "Number of representations in the entity requests sent to the subgraphs"
+	EntityBatchSizeHistogramOptions     = []otelmetric.Float64HistogramOption{
+		otelmetric.WithUnit("{representation}"),
+		otelmetric.WithDescription(EntityBatchSizeHistogramDescription),
+	}
 	ServerLatencyHistogramDescription = "Server latency in milliseconds"
 	ServerLatencyHistogramOptions     = []otelmetric.Float64HistogramOption{
 		otelmetric.WithUnit("ms"),
@@ -69,8 +122,9 @@ type (
 		otlpRequestMetrics Provider
 		promRequestMetrics Provider
 
-		baseAttributes []attribute.KeyValue
-		logger         *zap.Logger
+		baseAttributes   []attribute.KeyValue
+		semConvStability rotel.SemConvStability
+		logger           *zap.Logger
 	}
 
 	Provider interface {
@@ -80,6 +134,14 @@ type (
 		MeasureResponseSize(ctx context.Context, size int64, attr ...attribute.KeyValue)
 		MeasureLatency(ctx context.Context, requestStartTime time.Time, attr ...attribute.KeyValue)
 		MeasureRequestError(ctx context.Context, attr ...attribute.KeyValue)
+		MeasureIntrospectionRequest(ctx context.Context, attr ...attribute.KeyValue)
+		MeasureOperationTimeout(ctx context.Context, attr ...attribute.KeyValue)
+		MeasureEntityBatchSize(ctx context.Context, size int, attr ...attribute.KeyValue)
+		MeasureSubgraphResponseViolations(ctx context.Context, count int64, attr ...attribute.KeyValue)
+		MeasureSubgraphContractViolations(ctx context.Context, count int64, attr ...attribute.KeyValue)
+		MeasureSubgraphResponseCompression(ctx context.Context, compressed, decompressed int64, attr ...attribute.KeyValue)
+		MeasureSubgraphRequestBytes(ctx context.Context, size int64, attr ...attribute.KeyValue)
+		MeasureSubgraphResponseBytes(ctx context.Context, size int64, attr ...attribute.KeyValue)
 		Flush(ctx context.Context) error
 	}
 
@@ -98,6 +160,8 @@ func NewStore(opts ...Option) (Store, error) {
 		opt(h)
 	}
 
+	h.baseAttributes = rotel.MapSemConvAttributes(h.semConvStability, h.baseAttributes)
+
 	// Create OTLP metrics exported to OTEL
 	oltpMetrics, err := NewOtlpMetricStore(h.logger, h.otelMeterProvider, h.baseAttributes)
 	if err != nil {
@@ -118,6 +182,7 @@ func NewStore(opts ...Option) (Store, error) {
 }
 
 func (h *Metrics) MeasureInFlight(ctx context.Context, attr ...attribute.KeyValue) func() {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
 	f1 := h.otlpRequestMetrics.MeasureInFlight(ctx, attr...)
 	f2 := h.promRequestMetrics.MeasureInFlight(ctx, attr...)
 
@@ -128,30 +193,83 @@ func (h *Metrics) MeasureInFlight(ctx context.Context, attr ...attribute.KeyValu
 }
 
 func (h *Metrics) MeasureRequestCount(ctx context.Context, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
 	h.otlpRequestMetrics.MeasureRequestCount(ctx, attr...)
 	h.promRequestMetrics.MeasureRequestCount(ctx, attr...)
 }
 
 func (h *Metrics) MeasureRequestSize(ctx context.Context, contentLength int64, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
 	h.otlpRequestMetrics.MeasureRequestSize(ctx, contentLength, attr...)
 	h.promRequestMetrics.MeasureRequestSize(ctx, contentLength, attr...)
 }
 
 func (h *Metrics) MeasureResponseSize(ctx context.Context, size int64, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
 	h.otlpRequestMetrics.MeasureResponseSize(ctx, size, attr...)
 	h.promRequestMetrics.MeasureResponseSize(ctx, size, attr...)
 }
 
 func (h *Metrics) MeasureLatency(ctx context.Context, requestStartTime time.Time, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
 	h.otlpRequestMetrics.MeasureLatency(ctx, requestStartTime, attr...)
 	h.promRequestMetrics.MeasureLatency(ctx, requestStartTime, attr...)
 }
 
 func (h *Metrics) MeasureRequestError(ctx context.Context, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
 	h.otlpRequestMetrics.MeasureRequestError(ctx, attr...)
 	h.promRequestMetrics.MeasureRequestError(ctx, attr...)
 }
 
+func (h *Metrics) MeasureIntrospectionRequest(ctx context.Context, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureIntrospectionRequest(ctx, attr...)
+	h.promRequestMetrics.MeasureIntrospectionRequest(ctx, attr...)
+}
+
+func (h *Metrics) MeasureOperationTimeout(ctx context.Context, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureOperationTimeout(ctx, attr...)
+	h.promRequestMetrics.MeasureOperationTimeout(ctx, attr...)
+}
+
+func (h *Metrics) MeasureEntityBatchSize(ctx context.Context, size int, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureEntityBatchSize(ctx, size, attr...)
+	h.promRequestMetrics.MeasureEntityBatchSize(ctx, size, attr...)
+}
+
+func (h *Metrics) MeasureSubgraphResponseViolations(ctx context.Context, count int64, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureSubgraphResponseViolations(ctx, count, attr...)
+	h.promRequestMetrics.MeasureSubgraphResponseViolations(ctx, count, attr...)
+}
+
+func (h *Metrics) MeasureSubgraphContractViolations(ctx context.Context, count int64, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureSubgraphContractViolations(ctx, count, attr...)
+	h.promRequestMetrics.MeasureSubgraphContractViolations(ctx, count, attr...)
+}
+
+func (h *Metrics) MeasureSubgraphResponseCompression(ctx context.Context, compressed, decompressed int64, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureSubgraphResponseCompression(ctx, compressed, decompressed, attr...)
+	h.promRequestMetrics.MeasureSubgraphResponseCompression(ctx, compressed, decompressed, attr...)
+}
+
+func (h *Metrics) MeasureSubgraphRequestBytes(ctx context.Context, size int64, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureSubgraphRequestBytes(ctx, size, attr...)
+	h.promRequestMetrics.MeasureSubgraphRequestBytes(ctx, size, attr...)
+}
+
+func (h *Metrics) MeasureSubgraphResponseBytes(ctx context.Context, size int64, attr ...attribute.KeyValue) {
+	attr = rotel.MapSemConvAttributes(h.semConvStability, attr)
+	h.otlpRequestMetrics.MeasureSubgraphResponseBytes(ctx, size, attr...)
+	h.promRequestMetrics.MeasureSubgraphResponseBytes(ctx, size, attr...)
+}
+
 // Flush flushes the metrics to the backend synchronously.
 func (h *Metrics) Flush(ctx context.Context) error {
 
@@ -186,6 +304,13 @@ func WithAttributes(attrs ...attribute.KeyValue) Option {
 	}
 }
 
+// WithSemConvStability renames the attributes to the current semantic conventions
+func WithSemConvStability(stability rotel.SemConvStability) Option {
+	return func(h *Metrics) {
+		h.semConvStability = stability
+	}
+}
+
 func WithLogger(logger *zap.Logger) Option {
 	return func(h *Metrics) {
 		h.logger = logger
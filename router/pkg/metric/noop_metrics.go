@@ -13,6 +13,28 @@ type NoopMetrics struct{}
 
 func (n NoopMetrics) MeasureRequestError(ctx context.Context, attr ...attribute.KeyValue) {}
 
+func (n NoopMetrics) MeasureIntrospectionRequest(ctx context.Context, attr ...attribute.KeyValue) {}
+
+func (n NoopMetrics) MeasureOperationTimeout(ctx context.Context, attr ...attribute.KeyValue) {}
+
+func (n NoopMetrics) MeasureEntityBatchSize(ctx context.Context, size int, attr ...attribute.KeyValue) {
+}
+
+func (n NoopMetrics) MeasureSubgraphResponseViolations(ctx context.Context, count int64, attr ...attribute.KeyValue) {
+}
+
+func (n NoopMetrics) MeasureSubgraphContractViolations(ctx context.Context, count int64, attr ...attribute.KeyValue) {
+}
+
+func (n NoopMetrics) MeasureSubgraphResponseCompression(ctx context.Context, compressed, decompressed int64, attr ...attribute.KeyValue) {
+}
+
+func (n NoopMetrics) MeasureSubgraphRequestBytes(ctx context.Context, size int64, attr ...attribute.KeyValue) {
+}
+
+func (n NoopMetrics) MeasureSubgraphResponseBytes(ctx context.Context, size int64, attr ...attribute.KeyValue) {
+}
+
 func NewNoopMetrics() Store {
 	return &NoopMetrics{}
 }
@@ -133,6 +133,113 @@ func (h *OtlpMetricStore) MeasureRequestError(ctx context.Context, attr ...attri
 	}
 }
 
+func (h *OtlpMetricStore) MeasureIntrospectionRequest(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[IntrospectionRequestCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureOperationTimeout(ctx context.Context, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[OperationTimeoutCounter]; ok {
+		c.Add(ctx, 1, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureEntityBatchSize(ctx context.Context, size int, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.histograms[EntityBatchSizeHistogram]; ok {
+		c.Record(ctx, float64(size), baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureSubgraphResponseViolations(ctx context.Context, count int64, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[SubgraphViolationCounter]; ok {
+		c.Add(ctx, count, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureSubgraphContractViolations(ctx context.Context, count int64, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[SubgraphContractViolationCounter]; ok {
+		c.Add(ctx, count, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureSubgraphResponseCompression(ctx context.Context, compressed, decompressed int64, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.counters[SubgraphCompressedBytesCounter]; ok {
+		c.Add(ctx, compressed, baseAttributes)
+	}
+	if c, ok := h.measurements.counters[SubgraphDecompressedBytesCounter]; ok {
+		c.Add(ctx, decompressed, baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureSubgraphRequestBytes(ctx context.Context, size int64, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.histograms[SubgraphRequestSizeHistogram]; ok {
+		c.Record(ctx, float64(size), baseAttributes)
+	}
+}
+
+func (h *OtlpMetricStore) MeasureSubgraphResponseBytes(ctx context.Context, size int64, attr ...attribute.KeyValue) {
+	var baseKeys []attribute.KeyValue
+
+	baseKeys = append(baseKeys, h.baseAttributes...)
+	baseKeys = append(baseKeys, attr...)
+
+	baseAttributes := otelmetric.WithAttributes(baseKeys...)
+
+	if c, ok := h.measurements.histograms[SubgraphResponseSizeHistogram]; ok {
+		c.Record(ctx, float64(size), baseAttributes)
+	}
+}
+
 func (h *OtlpMetricStore) Flush(ctx context.Context) error {
 	return h.meterProvider.ForceFlush(ctx)
 }
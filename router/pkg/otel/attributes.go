@@ -20,6 +20,8 @@ const (
 	WgSubgraphID                       = attribute.Key("wg.subgraph.id")
 	WgSubgraphName                     = attribute.Key("wg.subgraph.name")
 	WgRequestError                     = attribute.Key("wg.request.error")
+	WgRequestErrorClass                = attribute.Key("wg.request.error.class")
+	WgRequestErrorCode                 = attribute.Key("wg.request.error.code")
 	WgOperationPersistedID             = attribute.Key("wg.operation.persisted_id")
 	WgEnginePlanCacheHit               = attribute.Key("wg.engine.plan_cache_hit")
 	WgEnginePersistedOperationCacheHit = attribute.Key("wg.engine.persisted_operation_cache_hit")
@@ -28,7 +30,15 @@ const (
 	WgRouterClusterName                = attribute.Key("wg.router.cluster.name")
 	WgSubgraphErrorExtendedCode        = attribute.Key("wg.subgraph.error.extended_code")
 	WgSubgraphErrorMessage             = attribute.Key("wg.subgraph.error.message")
+	WgSubgraphContentEncoding          = attribute.Key("wg.subgraph.content_encoding")
 	WgFeatureFlag                      = attribute.Key("wg.feature_flag")
+	WgIntrospectionKind                = attribute.Key("wg.introspection.kind")
+	WgIntrospectionBlocked             = attribute.Key("wg.introspection.blocked")
+)
+
+const (
+	// WgRequestTagPrefix is the prefix of the attributes of the request tags
+	WgRequestTagPrefix = "wg.request.tag."
 )
 
 var (
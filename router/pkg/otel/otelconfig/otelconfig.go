@@ -11,6 +11,7 @@ const (
 	CloudDefaultTelemetryEndpoint = "https://cosmo-otel.wundergraph.com"
 	DefaultMetricsPath            = "/v1/metrics"
 	DefaultTracesPath             = "/v1/traces"
+	DefaultLogsPath               = "/v1/logs"
 )
 
 // DefaultEndpoint is the default endpoint used by subsystems that
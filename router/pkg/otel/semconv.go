@@ -0,0 +1,111 @@
+package otel
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// SemConvStability controls whether the attributes of the current OpenTelemetry semantic conventions are emitted.
+// The values follow the OTEL_SEMCONV_STABILITY_OPT_IN environment variable of the OpenTelemetry instrumentations.
+type SemConvStability string
+
+const (
+	// SemConvLegacy emits the attribute names the router has always used
+	SemConvLegacy SemConvStability = ""
+	// SemConvStable emits only the attribute names of the current semantic conventions
+	SemConvStable SemConvStability = "http"
+	// SemConvDuplicate emits the legacy and the current attribute names to allow a gradual migration of dashboards
+	SemConvDuplicate SemConvStability = "http/dup"
+)
+
+// ParseSemConvStability parses a comma separated opt-in list like "http/dup,database"
+func ParseSemConvStability(optIn string) SemConvStability {
+	stability := SemConvLegacy
+	for _, value := range strings.Split(optIn, ",") {
+		switch SemConvStability(strings.TrimSpace(value)) {
+		case SemConvDuplicate:
+			// The duplicate mode takes precedence like in the OpenTelemetry instrumentations
+			return SemConvDuplicate
+		case SemConvStable:
+			stability = SemConvStable
+		}
+	}
+	return stability
+}
+
+// legacyAttributeKeys maps the legacy attribute names to the ones of the current semantic conventions
+var legacyAttributeKeys = map[attribute.Key]attribute.Key{
+	"http.method":                  semconv.HTTPRequestMethodKey,
+	"http.status_code":             semconv.HTTPResponseStatusCodeKey,
+	"http.scheme":                  semconv.URLSchemeKey,
+	"http.url":                     semconv.URLFullKey,
+	"http.host":                    semconv.ServerAddressKey,
+	"http.client_ip":               semconv.ClientAddressKey,
+	"http.user_agent":              semconv.UserAgentOriginalKey,
+	"http.flavor":                  semconv.NetworkProtocolVersionKey,
+	"http.request_content_length":  semconv.HTTPRequestBodySizeKey,
+	"http.response_content_length": semconv.HTTPResponseBodySizeKey,
+	"net.host.name":                semconv.ServerAddressKey,
+	"net.host.port":                semconv.ServerPortKey,
+	"net.peer.name":                semconv.ServerAddressKey,
+	"net.peer.port":                semconv.ServerPortKey,
+	"net.sock.peer.addr":           semconv.NetworkPeerAddressKey,
+	"net.sock.peer.port":           semconv.NetworkPeerPortKey,
+	"net.protocol.name":            semconv.NetworkProtocolNameKey,
+	"net.protocol.version":         semconv.NetworkProtocolVersionKey,
+	WgOperationName:                semconv.GraphqlOperationNameKey,
+	WgOperationType:                semconv.GraphqlOperationTypeKey,
+	WgOperationContent:             semconv.GraphqlDocumentKey,
+}
+
+// http.target contains the path and the query which are separate attributes in the current conventions
+const legacyHTTPTargetKey = attribute.Key("http.target")
+
+// MapSemConvAttributes returns the attributes with the names of the current semantic conventions.
+// The input is returned as is in the legacy mode and never modified.
+func MapSemConvAttributes(stability SemConvStability, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if stability == SemConvLegacy {
+		return attrs
+	}
+
+	mapped := make([]attribute.KeyValue, 0, len(attrs)+2)
+	// Several legacy attributes map to the same key, e.g. http.host and net.host.name. The first one wins.
+	converted := make(map[attribute.Key]struct{}, 4)
+
+	add := func(attr attribute.KeyValue) {
+		if _, ok := converted[attr.Key]; ok {
+			return
+		}
+		converted[attr.Key] = struct{}{}
+		mapped = append(mapped, attr)
+	}
+
+	for _, attr := range attrs {
+		if attr.Key == legacyHTTPTargetKey {
+			if stability == SemConvDuplicate {
+				mapped = append(mapped, attr)
+			}
+			path, query, _ := strings.Cut(attr.Value.AsString(), "?")
+			add(semconv.URLPath(path))
+			if query != "" {
+				add(semconv.URLQuery(query))
+			}
+			continue
+		}
+
+		key, ok := legacyAttributeKeys[attr.Key]
+		if !ok {
+			mapped = append(mapped, attr)
+			continue
+		}
+
+		if stability == SemConvDuplicate {
+			mapped = append(mapped, attr)
+		}
+		add(attribute.KeyValue{Key: key, Value: attr.Value})
+	}
+
+	return mapped
+}
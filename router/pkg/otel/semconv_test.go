@@ -0,0 +1,59 @@
+package otel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestParseSemConvStability(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, SemConvLegacy, ParseSemConvStability(""))
+	require.Equal(t, SemConvLegacy, ParseSemConvStability("database"))
+	require.Equal(t, SemConvStable, ParseSemConvStability("http"))
+	require.Equal(t, SemConvDuplicate, ParseSemConvStability("http/dup"))
+	require.Equal(t, SemConvDuplicate, ParseSemConvStability("http, http/dup"))
+}
+
+func TestMapSemConvAttributes(t *testing.T) {
+	t.Parallel()
+
+	attrs := []attribute.KeyValue{
+		attribute.Int("http.status_code", 200),
+		attribute.String("http.target", "/graphql?query=1"),
+		attribute.String("http.host", "localhost"),
+		attribute.String("net.host.name", "example.com"),
+		WgOperationName.String("MyQuery"),
+		WgClientName.String("client"),
+	}
+
+	require.Equal(t, attrs, MapSemConvAttributes(SemConvLegacy, attrs))
+
+	require.Equal(t, []attribute.KeyValue{
+		attribute.Int("http.response.status_code", 200),
+		attribute.String("url.path", "/graphql"),
+		attribute.String("url.query", "query=1"),
+		attribute.String("server.address", "localhost"),
+		attribute.String("graphql.operation.name", "MyQuery"),
+		WgClientName.String("client"),
+	}, MapSemConvAttributes(SemConvStable, attrs))
+
+	require.Equal(t, []attribute.KeyValue{
+		attribute.Int("http.status_code", 200),
+		attribute.Int("http.response.status_code", 200),
+		attribute.String("http.target", "/graphql?query=1"),
+		attribute.String("url.path", "/graphql"),
+		attribute.String("url.query", "query=1"),
+		attribute.String("http.host", "localhost"),
+		attribute.String("server.address", "localhost"),
+		attribute.String("net.host.name", "example.com"),
+		WgOperationName.String("MyQuery"),
+		attribute.String("graphql.operation.name", "MyQuery"),
+		WgClientName.String("client"),
+	}, MapSemConvAttributes(SemConvDuplicate, attrs))
+
+	// The input must not be modified
+	require.Equal(t, attribute.Key("http.status_code"), attrs[0].Key)
+}
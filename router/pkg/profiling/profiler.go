@@ -0,0 +1,302 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Exporter string
+
+const (
+	// ExporterPyroscope pushes the profiles to the ingest API of Pyroscope or Grafana Cloud Profiles
+	ExporterPyroscope Exporter = "pyroscope"
+)
+
+type ProfileType string
+
+const (
+	ProfileTypeCPU       ProfileType = "cpu"
+	ProfileTypeHeap      ProfileType = "heap"
+	ProfileTypeGoroutine ProfileType = "goroutine"
+	ProfileTypeMutex     ProfileType = "mutex"
+	ProfileTypeBlock     ProfileType = "block"
+)
+
+const (
+	LabelRouterVersion       = "wg_router_version"
+	LabelRouterConfigVersion = "wg_router_config_version"
+	LabelRouterClusterName   = "wg_router_cluster_name"
+	LabelRouterInstanceID    = "wg_router_instance_id"
+	LabelFederatedGraphID    = "wg_federated_graph_id"
+)
+
+type Config struct {
+	Enabled  bool
+	Exporter Exporter
+	// Endpoint is the base URL of the profiling backend
+	Endpoint string
+	// ServiceName is used as application name in the profiling backend
+	ServiceName string
+	// Interval is the duration of a single CPU profile and the upload interval of all profiles
+	Interval     time.Duration
+	ProfileTypes []ProfileType
+	// Headers are sent with every upload, e.g. for authentication
+	Headers map[string]string
+}
+
+// Profiler collects profiles of the running process in a fixed interval and pushes them to the profiling backend.
+type Profiler struct {
+	logger *zap.Logger
+	cfg    *Config
+	client *http.Client
+
+	mu     sync.RWMutex
+	labels map[string]string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewProfiler(logger *zap.Logger, cfg *Config, labels map[string]string) (*Profiler, error) {
+	if cfg.Exporter != ExporterPyroscope {
+		return nil, fmt.Errorf("unsupported profiling exporter: %s", cfg.Exporter)
+	}
+
+	if _, err := url.ParseRequestURI(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid profiling endpoint: %w", err)
+	}
+
+	if cfg.Interval < time.Second {
+		return nil, errors.New("profiling interval must be at least 1s")
+	}
+
+	for _, profileType := range cfg.ProfileTypes {
+		switch profileType {
+		case ProfileTypeCPU, ProfileTypeHeap, ProfileTypeGoroutine, ProfileTypeMutex, ProfileTypeBlock:
+		default:
+			return nil, fmt.Errorf("unsupported profile type: %s", profileType)
+		}
+	}
+
+	l := make(map[string]string, len(labels))
+	for k, v := range labels {
+		l[k] = v
+	}
+
+	return &Profiler{
+		logger: logger,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 30 * time.Second},
+		labels: l,
+	}, nil
+}
+
+// SetLabel sets a label that is attached to all following profiles
+func (p *Profiler) SetLabel(key, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.labels[key] = value
+}
+
+// Start starts collecting profiles in the background until Shutdown is called
+func (p *Profiler) Start() {
+	// Mutex and block profiles are only recorded when the runtime samples the events
+	if p.enabled(ProfileTypeMutex) {
+		runtime.SetMutexProfileFraction(5)
+	}
+	if p.enabled(ProfileTypeBlock) {
+		runtime.SetBlockProfileRate(int(10 * time.Microsecond))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		for {
+			from := time.Now()
+
+			profiles, err := p.collect(ctx)
+			if err != nil {
+				p.logger.Error("Failed to collect profiles", zap.Error(err))
+			}
+
+			// Upload the profiles even when the context is canceled so that the last interval is not lost
+			uploadCtx, uploadCancel := context.WithTimeout(context.Background(), p.client.Timeout)
+			for profileType, data := range profiles {
+				if err := p.upload(uploadCtx, profileType, data, from, time.Now()); err != nil {
+					p.logger.Warn("Failed to upload profile", zap.String("profile_type", string(profileType)), zap.Error(err))
+				}
+			}
+			uploadCancel()
+
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+}
+
+// Shutdown stops the profiler and waits until the last profiles are uploaded
+func (p *Profiler) Shutdown(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+
+	p.cancel()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// collect records a CPU profile for one interval and takes a snapshot of all other profile types afterward
+func (p *Profiler) collect(ctx context.Context) (map[ProfileType][]byte, error) {
+	profiles := make(map[ProfileType][]byte, len(p.cfg.ProfileTypes))
+
+	var cpu *bytes.Buffer
+	if p.enabled(ProfileTypeCPU) {
+		cpu = &bytes.Buffer{}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			// Another CPU profile is running, e.g. started through the admin API. Try again in the next interval.
+			p.logger.Debug("Could not start CPU profile", zap.Error(err))
+			cpu = nil
+		}
+	}
+
+	timer := time.NewTimer(p.cfg.Interval)
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+	}
+
+	if cpu != nil {
+		pprof.StopCPUProfile()
+		profiles[ProfileTypeCPU] = cpu.Bytes()
+	}
+
+	var errs error
+
+	for _, profileType := range p.cfg.ProfileTypes {
+		if profileType == ProfileTypeCPU {
+			continue
+		}
+
+		profile := pprof.Lookup(string(profileType))
+		if profile == nil {
+			errs = errors.Join(errs, fmt.Errorf("unknown profile: %s", profileType))
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := profile.WriteTo(&buf, 0); err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		profiles[profileType] = buf.Bytes()
+	}
+
+	return profiles, errs
+}
+
+func (p *Profiler) enabled(profileType ProfileType) bool {
+	for _, t := range p.cfg.ProfileTypes {
+		if t == profileType {
+			return true
+		}
+	}
+	return false
+}
+
+// applicationName returns the name in the format of the Pyroscope ingest API, e.g. cosmo-router.cpu{key=value}
+func (p *Profiler) applicationName(profileType ProfileType) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	keys := make([]string, 0, len(p.labels))
+	for k := range p.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if p.labels[k] == "" {
+			continue
+		}
+		pairs = append(pairs, k+"="+sanitizeLabelValue(p.labels[k]))
+	}
+
+	return p.cfg.ServiceName + "." + string(profileType) + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sanitizeLabelValue(value string) string {
+	return strings.NewReplacer("{", "_", "}", "_", ",", "_", "=", "_").Replace(value)
+}
+
+func (p *Profiler) upload(ctx context.Context, profileType ProfileType, data []byte, from, until time.Time) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	fw, err := mw.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("name", p.applicationName(profileType))
+	query.Set("from", strconv.FormatInt(from.Unix(), 10))
+	query.Set("until", strconv.FormatInt(until.Unix(), 10))
+	query.Set("format", "pprof")
+	query.Set("spyName", "gospy")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.cfg.Endpoint, "/")+"/ingest?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	for k, v := range p.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
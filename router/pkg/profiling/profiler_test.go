@@ -0,0 +1,105 @@
+package profiling
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type ingestRequest struct {
+	query         url.Values
+	authorization string
+	profile       []byte
+}
+
+func TestProfiler(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []ingestRequest
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/ingest", r.URL.Path)
+
+		file, _, err := r.FormFile("profile")
+		require.NoError(t, err)
+		data, err := io.ReadAll(file)
+		require.NoError(t, err)
+
+		mu.Lock()
+		requests = append(requests, ingestRequest{
+			query:         r.URL.Query(),
+			authorization: r.Header.Get("Authorization"),
+			profile:       data,
+		})
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	p, err := NewProfiler(zap.NewNop(), &Config{
+		Enabled:      true,
+		Exporter:     ExporterPyroscope,
+		Endpoint:     srv.URL,
+		ServiceName:  "cosmo-router",
+		Interval:     time.Second,
+		ProfileTypes: []ProfileType{ProfileTypeCPU, ProfileTypeHeap},
+		Headers:      map[string]string{"Authorization": "Bearer token"},
+	}, map[string]string{
+		LabelRouterVersion: "1.0.0",
+	})
+	require.NoError(t, err)
+
+	p.SetLabel(LabelRouterConfigVersion, "config-1")
+	p.Start()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests) >= 2
+	}, 10*time.Second, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, p.Shutdown(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := map[string]bool{}
+	for _, req := range requests {
+		names[req.query.Get("name")] = true
+		require.Equal(t, "pprof", req.query.Get("format"))
+		require.Equal(t, "Bearer token", req.authorization)
+		require.NotEmpty(t, req.profile)
+	}
+
+	require.True(t, names["cosmo-router.cpu{wg_router_config_version=config-1,wg_router_version=1.0.0}"])
+	require.True(t, names["cosmo-router.heap{wg_router_config_version=config-1,wg_router_version=1.0.0}"])
+}
+
+func TestNewProfilerValidation(t *testing.T) {
+	_, err := NewProfiler(zap.NewNop(), &Config{
+		Exporter: "unknown",
+		Endpoint: "http://localhost:4040",
+		Interval: time.Second,
+	}, nil)
+	require.Error(t, err)
+
+	_, err = NewProfiler(zap.NewNop(), &Config{
+		Exporter:     ExporterPyroscope,
+		Endpoint:     "http://localhost:4040",
+		Interval:     time.Second,
+		ProfileTypes: []ProfileType{"threads"},
+	}, nil)
+	require.Error(t, err)
+}
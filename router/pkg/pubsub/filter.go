@@ -0,0 +1,9 @@
+package pubsub
+
+// EventFilter decides whether an event of a subscription is delivered to the subscribers. The events that aren't
+// allowed are dropped in the router, before they fan out to the clients.
+type EventFilter interface {
+	// Allow reports whether the event with the payload, received from the subject or topic of the provider, is
+	// delivered
+	Allow(providerID, subject string, data []byte) bool
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/pubsub_datasource"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 	"go.uber.org/zap"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -23,13 +24,26 @@ var (
 	errClientClosed = errors.New("client closed")
 )
 
+// consumerGroups are the consumer groups of the durable subscriptions of the process. A consumer group is used by one
+// subscription at a time, because Kafka would split the partitions between the subscriptions otherwise.
+var consumerGroups = struct {
+	sync.Mutex
+	inUse map[string]struct{}
+}{inUse: map[string]struct{}{}}
+
 type connector struct {
-	writeClient *kgo.Client
-	opts        []kgo.Opt
-	logger      *zap.Logger
+	writeClient   *kgo.Client
+	opts          []kgo.Opt
+	logger        *zap.Logger
+	filter        pubsub.EventFilter
+	consumerGroup string
 }
 
-func NewConnector(logger *zap.Logger, opts []kgo.Opt) (pubsub_datasource.KafkaConnector, error) {
+// NewConnector creates the connector of a Kafka provider. The filter drops the events of the subscriptions that it
+// doesn't allow and is optional. With a consumer group, the subscriptions are durable: the position of the last
+// delivered event is committed to the consumer group of the topics, so that a subscription resumes from it after a
+// restart of the router. Events are delivered at least once.
+func NewConnector(logger *zap.Logger, opts []kgo.Opt, filter pubsub.EventFilter, consumerGroup string) (pubsub_datasource.KafkaConnector, error) {
 
 	writeClient, err := kgo.NewClient(append(opts,
 		// For observability, we set the client ID to "router"
@@ -40,9 +54,11 @@ func NewConnector(logger *zap.Logger, opts []kgo.Opt) (pubsub_datasource.KafkaCo
 	}
 
 	return &connector{
-		writeClient: writeClient,
-		opts:        opts,
-		logger:      logger,
+		writeClient:   writeClient,
+		opts:          opts,
+		logger:        logger,
+		filter:        filter,
+		consumerGroup: consumerGroup,
 	}, nil
 }
 
@@ -51,12 +67,14 @@ func (c *connector) New(ctx context.Context) pubsub_datasource.KafkaPubSub {
 	ctx, cancel := context.WithCancel(ctx)
 
 	ps := &kafkaPubSub{
-		ctx:         ctx,
-		logger:      c.logger.With(zap.String("pubsub", "kafka")),
-		opts:        c.opts,
-		writeClient: c.writeClient,
-		closeWg:     sync.WaitGroup{},
-		cancel:      cancel,
+		ctx:           ctx,
+		logger:        c.logger.With(zap.String("pubsub", "kafka")),
+		opts:          c.opts,
+		writeClient:   c.writeClient,
+		filter:        c.filter,
+		consumerGroup: c.consumerGroup,
+		closeWg:       sync.WaitGroup{},
+		cancel:        cancel,
 	}
 
 	return ps
@@ -68,16 +86,20 @@ func (c *connector) New(ctx context.Context) pubsub_datasource.KafkaPubSub {
 // It uses a single write client to produce messages and a client per topic to consume messages.
 // Each client polls the Kafka topic for new records and updates the subscriptions with the new data.
 type kafkaPubSub struct {
-	ctx         context.Context
-	opts        []kgo.Opt
-	logger      *zap.Logger
-	writeClient *kgo.Client
-	closeWg     sync.WaitGroup
-	cancel      context.CancelFunc
+	ctx           context.Context
+	opts          []kgo.Opt
+	logger        *zap.Logger
+	writeClient   *kgo.Client
+	filter        pubsub.EventFilter
+	consumerGroup string
+	closeWg       sync.WaitGroup
+	cancel        context.CancelFunc
 }
 
 // topicPoller polls the Kafka topic for new records and calls the updateTriggers function.
-func (p *kafkaPubSub) topicPoller(ctx context.Context, client *kgo.Client, updater resolve.SubscriptionUpdater) error {
+// The records that the filter doesn't allow are skipped. The records of durable subscriptions are marked to be
+// committed once they were delivered.
+func (p *kafkaPubSub) topicPoller(ctx context.Context, client *kgo.Client, providerID string, durable bool, updater resolve.SubscriptionUpdater) error {
 
 	for {
 		select {
@@ -126,7 +148,12 @@ func (p *kafkaPubSub) topicPoller(ctx context.Context, client *kgo.Client, updat
 				r := iter.Next()
 
 				p.logger.Debug("subscription update", zap.String("topic", r.Topic), zap.ByteString("data", r.Value))
-				updater.Update(r.Value)
+				if p.filter == nil || p.filter.Allow(providerID, r.Topic, r.Value) {
+					updater.Update(r.Value)
+				}
+				if durable {
+					client.MarkCommitRecords(r)
+				}
 			}
 		}
 	}
@@ -144,17 +171,33 @@ func (p *kafkaPubSub) Subscribe(ctx context.Context, event pubsub_datasource.Kaf
 
 	log.Debug("subscribe")
 
-	// Create a new client for the topic
-	client, err := kgo.NewClient(append(p.opts,
+	// The options of the provider are clipped, so that the subscriptions don't append to a shared array
+	opts := append(slices.Clip(p.opts),
 		kgo.ConsumeTopics(event.Topics...),
 		// We want to consume the events produced after the first subscription was created
 		// Messages are shared among all subscriptions, therefore old events are not redelivered
 		// This replicates a stateless publish-subscribe model
+		// Durable subscriptions only start from here when their consumer group has no committed position yet
 		kgo.ConsumeResetOffset(kgo.NewOffset().AfterMilli(time.Now().UnixMilli())),
 		// For observability, we set the client ID to "router"
 		kgo.ClientID(fmt.Sprintf("cosmo.router.consumer.%s", strings.Join(event.Topics, "-"))),
-	)...)
+	)
+
+	group, durable := acquireConsumerGroup(p.consumerGroup, event.Topics)
+	if durable {
+		opts = append(opts, kgo.ConsumerGroup(group), kgo.AutoCommitMarks())
+	} else if p.consumerGroup != "" {
+		log.Warn("the consumer group of the topics is in use by another subscription, the subscription is not durable",
+			zap.String("consumerGroup", group),
+		)
+	}
+
+	// Create a new client for the topic
+	client, err := kgo.NewClient(opts...)
 	if err != nil {
+		if durable {
+			releaseConsumerGroup(group)
+		}
 		log.Error("failed to create client", zap.Error(err))
 		return err
 	}
@@ -165,7 +208,21 @@ func (p *kafkaPubSub) Subscribe(ctx context.Context, event pubsub_datasource.Kaf
 
 		defer p.closeWg.Done()
 
-		err := p.topicPoller(ctx, client, updater)
+		err := p.topicPoller(ctx, client, event.ProviderID, durable, updater)
+
+		if durable {
+			// The position of the last delivered event is committed before the group is left
+			commitCtx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+			if commitErr := client.CommitMarkedOffsets(commitCtx); commitErr != nil {
+				log.Error("failed to commit the position of the subscription", zap.Error(commitErr), zap.String("consumerGroup", group))
+			}
+			cancel()
+		}
+		client.Close()
+		if durable {
+			releaseConsumerGroup(group)
+		}
+
 		if err != nil {
 			if errors.Is(err, errClientClosed) || errors.Is(err, context.Canceled) {
 				log.Debug("poller canceled", zap.Error(err))
@@ -217,6 +274,41 @@ func (p *kafkaPubSub) Publish(ctx context.Context, event pubsub_datasource.Kafka
 	return nil
 }
 
+// commitTimeout bounds the commit of the position of a durable subscription when it is closed
+const commitTimeout = 5 * time.Second
+
+// acquireConsumerGroup returns the consumer group of the topics and true if it isn't used by another subscription.
+// Without a consumer group prefix, the subscriptions aren't durable.
+func acquireConsumerGroup(prefix string, topics []string) (string, bool) {
+	if prefix == "" {
+		return "", false
+	}
+	group := consumerGroupName(prefix, topics)
+
+	consumerGroups.Lock()
+	defer consumerGroups.Unlock()
+
+	if _, ok := consumerGroups.inUse[group]; ok {
+		return group, false
+	}
+	consumerGroups.inUse[group] = struct{}{}
+	return group, true
+}
+
+func releaseConsumerGroup(group string) {
+	consumerGroups.Lock()
+	defer consumerGroups.Unlock()
+
+	delete(consumerGroups.inUse, group)
+}
+
+// consumerGroupName returns the consumer group of a subscription to the topics, independent of their order
+func consumerGroupName(prefix string, topics []string) string {
+	sorted := slices.Clone(topics)
+	slices.Sort(sorted)
+	return prefix + "." + strings.Join(sorted, "-")
+}
+
 func (p *kafkaPubSub) Shutdown(ctx context.Context) error {
 
 	err := p.writeClient.Flush(ctx)
@@ -0,0 +1,27 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireConsumerGroup(t *testing.T) {
+	t.Parallel()
+
+	group, durable := acquireConsumerGroup("router-0", []string{"shipped", "created"})
+	require.True(t, durable)
+	require.Equal(t, "router-0.created-shipped", group)
+
+	// A subscription to the same topics in another order shares the group, so it isn't durable
+	_, durable = acquireConsumerGroup("router-0", []string{"created", "shipped"})
+	require.False(t, durable)
+
+	releaseConsumerGroup(group)
+	_, durable = acquireConsumerGroup("router-0", []string{"created", "shipped"})
+	require.True(t, durable)
+	releaseConsumerGroup(group)
+
+	_, durable = acquireConsumerGroup("", []string{"created"})
+	require.False(t, durable)
+}
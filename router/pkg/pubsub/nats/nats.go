@@ -24,13 +24,17 @@ type connector struct {
 	conn   *nats.Conn
 	logger *zap.Logger
 	js     jetstream.JetStream
+	filter pubsub.EventFilter
 }
 
-func NewConnector(logger *zap.Logger, conn *nats.Conn, js jetstream.JetStream) pubsub_datasource.NatsConnector {
+// NewConnector creates the connector of a NATS provider. The filter drops the events of the subscriptions that it
+// doesn't allow and is optional.
+func NewConnector(logger *zap.Logger, conn *nats.Conn, js jetstream.JetStream, filter pubsub.EventFilter) pubsub_datasource.NatsConnector {
 	return &connector{
 		conn:   conn,
 		logger: logger,
 		js:     js,
+		filter: filter,
 	}
 }
 
@@ -39,6 +43,7 @@ func (c *connector) New(ctx context.Context) pubsub_datasource.NatsPubSub {
 		ctx:     ctx,
 		conn:    c.conn,
 		js:      c.js,
+		filter:  c.filter,
 		logger:  c.logger.With(zap.String("pubsub", "nats")),
 		closeWg: sync.WaitGroup{},
 	}
@@ -49,9 +54,15 @@ type natsPubSub struct {
 	conn    *nats.Conn
 	logger  *zap.Logger
 	js      jetstream.JetStream
+	filter  pubsub.EventFilter
 	closeWg sync.WaitGroup
 }
 
+// allow reports whether the event is delivered to the subscribers
+func (p *natsPubSub) allow(providerID, subject string, data []byte) bool {
+	return p.filter == nil || p.filter.Allow(providerID, subject, data)
+}
+
 func (p *natsPubSub) Subscribe(ctx context.Context, event pubsub_datasource.NatsSubscriptionEventConfiguration, updater resolve.SubscriptionUpdater) error {
 	log := p.logger.With(
 		zap.String("providerID", event.ProviderID),
@@ -94,7 +105,9 @@ func (p *natsPubSub) Subscribe(ctx context.Context, event pubsub_datasource.Nats
 					for msg := range msgBatch.Messages() {
 						log.Debug("subscription update", zap.String("messageSubject", msg.Subject()), zap.ByteString("data", msg.Data()))
 
-						updater.Update(msg.Data())
+						if p.allow(event.ProviderID, msg.Subject(), msg.Data()) {
+							updater.Update(msg.Data())
+						}
 
 						// Acknowledge the message after it has been processed
 						ackErr := msg.Ack()
@@ -132,7 +145,9 @@ func (p *natsPubSub) Subscribe(ctx context.Context, event pubsub_datasource.Nats
 			case msg := <-msgChan:
 				log.Debug("subscription update", zap.String("messageSubject", msg.Subject), zap.ByteString("data", msg.Data))
 
-				updater.Update(msg.Data)
+				if p.allow(event.ProviderID, msg.Subject, msg.Data) {
+					updater.Update(msg.Data)
+				}
 			case <-p.ctx.Done():
 				// When the application context is done, we stop the subscriptions
 				for _, subscription := range subscriptions {
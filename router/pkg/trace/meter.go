@@ -4,6 +4,8 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"github.com/wundergraph/cosmo/router/internal/anonymize"
+	rotel "github.com/wundergraph/cosmo/router/pkg/otel"
 	"github.com/wundergraph/cosmo/router/pkg/otel/otelconfig"
 	"github.com/wundergraph/cosmo/router/pkg/trace/redact"
 	"go.opentelemetry.io/otel"
@@ -38,12 +40,15 @@ type (
 		IPAnonymization   *IPAnonymizationConfig
 		// MemoryExporter is used for testing purposes
 		MemoryExporter sdktrace.SpanExporter
+		// SemConvStability renames the span attributes to the current semantic conventions
+		SemConvStability rotel.SemConvStability
 	}
 )
 
 const (
-	Hash   IPAnonymizationMethod = "hash"
-	Redact IPAnonymizationMethod = "redact"
+	Hash     IPAnonymizationMethod = "hash"
+	Redact   IPAnonymizationMethod = "redact"
+	Truncate IPAnonymizationMethod = "truncate"
 )
 
 func createExporter(log *zap.Logger, exp *ExporterConfig) (sdktrace.SpanExporter, error) {
@@ -109,17 +114,23 @@ func createExporter(log *zap.Logger, exp *ExporterConfig) (sdktrace.SpanExporter
 	return exporter, nil
 }
 
-func NewTracerProvider(ctx context.Context, config *ProviderConfig) (*sdktrace.TracerProvider, error) {
-	r, err := resource.New(ctx,
-		resource.WithAttributes(semconv.ServiceNameKey.String(config.Config.Name)),
-		resource.WithAttributes(semconv.ServiceVersionKey.String(config.Config.Version)),
-		resource.WithAttributes(semconv.ServiceInstanceID(config.ServiceInstanceID)),
-		resource.WithAttributes(config.Config.ResourceAttributes...),
+// NewResource creates the resource of the traces. Other signals, e.g. the exported logs, use the same resource, so
+// that they can be correlated with the traces.
+func NewResource(ctx context.Context, config *Config, serviceInstanceID string) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String(config.Name)),
+		resource.WithAttributes(semconv.ServiceVersionKey.String(config.Version)),
+		resource.WithAttributes(semconv.ServiceInstanceID(serviceInstanceID)),
+		resource.WithAttributes(config.ResourceAttributes...),
 		resource.WithProcessPID(),
 		resource.WithOSType(),
 		resource.WithTelemetrySDK(),
 		resource.WithHost(),
 	)
+}
+
+func NewTracerProvider(ctx context.Context, config *ProviderConfig) (*sdktrace.TracerProvider, error) {
+	r, err := NewResource(ctx, config.Config, config.ServiceInstanceID)
 	if err != nil {
 		return nil, err
 	}
@@ -166,6 +177,10 @@ func NewTracerProvider(ctx context.Context, config *ProviderConfig) (*sdktrace.T
 				h := sha256.New()
 				return string(h.Sum([]byte(key.Value.AsString())))
 			}
+		} else if config.IPAnonymization.Method == Truncate {
+			rFunc = func(key attribute.KeyValue) string {
+				return anonymize.TruncateIP(key.Value.AsString())
+			}
 		} else if config.IPAnonymization.Method == Redact {
 			rFunc = func(key attribute.KeyValue) string {
 				return "[REDACTED]"
@@ -189,7 +204,7 @@ func NewTracerProvider(ctx context.Context, config *ProviderConfig) (*sdktrace.T
 
 		// Either memory exporter or the configured exporters are used.
 		if config.MemoryExporter != nil {
-			opts = append(opts, sdktrace.WithSyncer(config.MemoryExporter))
+			opts = append(opts, sdktrace.WithSyncer(newSemConvExporter(config.MemoryExporter, config.SemConvStability)))
 		} else {
 			for _, exp := range config.Config.Exporters {
 				if exp.Disabled {
@@ -206,6 +221,7 @@ func NewTracerProvider(ctx context.Context, config *ProviderConfig) (*sdktrace.T
 					config.Logger.Error("creating exporter", zap.Error(err))
 					return nil, err
 				}
+				exporter = newSemConvExporter(exporter, config.SemConvStability)
 
 				batchTimeout := exp.BatchTimeout
 				if batchTimeout == 0 {
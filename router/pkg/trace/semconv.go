@@ -0,0 +1,43 @@
+package trace
+
+import (
+	"context"
+
+	rotel "github.com/wundergraph/cosmo/router/pkg/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// semConvExporter renames the span attributes to the current semantic conventions before they are exported.
+// The attributes of the otelhttp instrumentation can't be configured, so they are rewritten on export.
+type semConvExporter struct {
+	sdktrace.SpanExporter
+	stability rotel.SemConvStability
+}
+
+func newSemConvExporter(exporter sdktrace.SpanExporter, stability rotel.SemConvStability) sdktrace.SpanExporter {
+	if stability == rotel.SemConvLegacy {
+		return exporter
+	}
+	return &semConvExporter{SpanExporter: exporter, stability: stability}
+}
+
+func (e *semConvExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	mapped := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		mapped[i] = &semConvSpan{
+			ReadOnlySpan: span,
+			attributes:   rotel.MapSemConvAttributes(e.stability, span.Attributes()),
+		}
+	}
+	return e.SpanExporter.ExportSpans(ctx, mapped)
+}
+
+type semConvSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+}
+
+func (s *semConvSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
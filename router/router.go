@@ -0,0 +1,87 @@
+// Package router is the API to embed the router in another Go service. The router is configured with the same
+// config as the standalone router, the embedding service can inject its logger and follow the lifecycle of the
+// router with hooks:
+//
+//	result, err := config.LoadConfig("config.yaml", "")
+//	if err != nil {
+//		return err
+//	}
+//	r, err := router.New(router.Options{
+//		Config: &result.Config,
+//		Logger: logger,
+//		Hooks: []core.LifecycleHook{func(event core.LifecycleEvent) {
+//			logger.Info("Router lifecycle event", zap.String("type", string(event.Type)))
+//		}},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	go r.Start(ctx)
+//	defer r.Shutdown(shutdownCtx)
+//
+// Importing the package doesn't register command line flags or global handlers.
+package router
+
+import (
+	"errors"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/wundergraph/cosmo/router/cmd"
+	"github.com/wundergraph/cosmo/router/core"
+	"github.com/wundergraph/cosmo/router/pkg/config"
+	"github.com/wundergraph/cosmo/router/pkg/logging"
+)
+
+// Options configure an embedded router
+type Options struct {
+	// Config is the config of the router, e.g. loaded with config.LoadConfig. Required.
+	Config *config.Config
+	// Logger is the logger of the router. Defaults to a logger that writes to stdout with the log level and format
+	// of the Config.
+	Logger *zap.Logger
+	// LogCore receives the entries of the router in addition to the outputs of the Logger, e.g. to route them into
+	// the logging pipeline of the embedding service. Optional.
+	LogCore zapcore.Core
+	// Hooks are called synchronously on the startup, the shutdown and the config changes of the router. Optional.
+	Hooks []core.LifecycleHook
+	// RouterOptions are applied after the options derived from the Config, so that they override them. Optional.
+	RouterOptions []core.Option
+}
+
+// New creates a router from the options. Start it with Start and stop it with Shutdown, like the standalone router.
+func New(opts Options) (*core.Router, error) {
+	if opts.Config == nil {
+		return nil, errors.New("the config of the router is required")
+	}
+
+	params := cmd.Params{
+		Config: opts.Config,
+		Logger: opts.Logger,
+	}
+
+	if params.Logger == nil {
+		level, err := logging.ZapLogLevelFromString(opts.Config.LogLevel)
+		if err != nil {
+			return nil, err
+		}
+		// The level can be changed at runtime with the admin API
+		atomicLevel := zap.NewAtomicLevelAt(level)
+		params.LogLevel = &atomicLevel
+		params.Logger = logging.NewWithOutput(zapcore.AddSync(os.Stdout), !opts.Config.JSONLog, level == zapcore.DebugLevel, atomicLevel)
+	}
+
+	if opts.LogCore != nil {
+		params.Logger = params.Logger.WithOptions(logging.WithTee(opts.LogCore))
+	}
+
+	routerOptions := make([]core.Option, 0, len(opts.RouterOptions)+1)
+	if len(opts.Hooks) > 0 {
+		routerOptions = append(routerOptions, core.WithLifecycleHooks(opts.Hooks...))
+	}
+	routerOptions = append(routerOptions, opts.RouterOptions...)
+
+	return cmd.NewRouter(params, routerOptions...)
+}